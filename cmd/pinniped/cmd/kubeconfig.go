@@ -152,14 +152,17 @@ func kubeconfigCommand(deps kubeconfigDeps) *cobra.Command {
 		"upstream-identity-provider-type",
 		"",
 		fmt.Sprintf(
-			"The type of the upstream identity provider used during login with a Supervisor (e.g. '%s', '%s', '%s', '%s')",
+			"The type of the upstream identity provider used during login with a Supervisor (e.g. '%s', '%s', '%s', '%s', '%s', '%s', '%s')",
 			idpdiscoveryv1alpha1.IDPTypeOIDC,
 			idpdiscoveryv1alpha1.IDPTypeLDAP,
 			idpdiscoveryv1alpha1.IDPTypeActiveDirectory,
 			idpdiscoveryv1alpha1.IDPTypeGitHub,
+			idpdiscoveryv1alpha1.IDPTypeSAML,
+			idpdiscoveryv1alpha1.IDPTypeOAuth2,
+			idpdiscoveryv1alpha1.IDPTypeLocalUser,
 		),
 	)
-	f.StringVar(&flags.oidc.upstreamIDPFlow, "upstream-identity-provider-flow", "", fmt.Sprintf("The type of client flow to use with the upstream identity provider during login with a Supervisor (e.g. '%s', '%s')", idpdiscoveryv1alpha1.IDPFlowCLIPassword, idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode))
+	f.StringVar(&flags.oidc.upstreamIDPFlow, "upstream-identity-provider-flow", "", fmt.Sprintf("The type of client flow to use with the upstream identity provider during login with a Supervisor (e.g. '%s', '%s', '%s')", idpdiscoveryv1alpha1.IDPFlowCLIPassword, idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode, idpdiscoveryv1alpha1.IDPFlowDeviceCode))
 	f.StringVar(&flags.kubeconfigPath, "kubeconfig", deps.getenv("KUBECONFIG"), "Path to kubeconfig file")
 	f.StringVar(&flags.kubeconfigContextOverride, "kubeconfig-context", "", "Kubeconfig context name (default: current active context)")
 	f.BoolVar(&flags.skipValidate, "skip-validation", false, "Skip final validation of the kubeconfig (default: false)")
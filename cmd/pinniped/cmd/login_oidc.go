@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package cmd
@@ -145,13 +145,16 @@ func oidcLoginCommand(deps oidcLoginCommandDeps) *cobra.Command {
 		"upstream-identity-provider-type",
 		idpdiscoveryv1alpha1.IDPTypeOIDC.String(),
 		fmt.Sprintf(
-			"The type of the upstream identity provider used during login with a Supervisor (e.g. '%s', '%s', '%s', '%s')",
+			"The type of the upstream identity provider used during login with a Supervisor (e.g. '%s', '%s', '%s', '%s', '%s', '%s', '%s')",
 			idpdiscoveryv1alpha1.IDPTypeOIDC,
 			idpdiscoveryv1alpha1.IDPTypeLDAP,
 			idpdiscoveryv1alpha1.IDPTypeActiveDirectory,
 			idpdiscoveryv1alpha1.IDPTypeGitHub,
+			idpdiscoveryv1alpha1.IDPTypeSAML,
+			idpdiscoveryv1alpha1.IDPTypeOAuth2,
+			idpdiscoveryv1alpha1.IDPTypeLocalUser,
 		))
-	cmd.Flags().StringVar(&flags.upstreamIdentityProviderFlow, "upstream-identity-provider-flow", "", fmt.Sprintf("The type of client flow to use with the upstream identity provider during login with a Supervisor (e.g. '%s', '%s')", idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode, idpdiscoveryv1alpha1.IDPFlowCLIPassword))
+	cmd.Flags().StringVar(&flags.upstreamIdentityProviderFlow, "upstream-identity-provider-flow", "", fmt.Sprintf("The type of client flow to use with the upstream identity provider during login with a Supervisor (e.g. '%s', '%s', '%s')", idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode, idpdiscoveryv1alpha1.IDPFlowCLIPassword, idpdiscoveryv1alpha1.IDPFlowDeviceCode))
 
 	// --skip-listen is mainly needed for testing. We'll leave it hidden until we have a non-testing use case.
 	mustMarkHidden(cmd, "skip-listen")
@@ -291,7 +294,7 @@ func runOIDCLogin(cmd *cobra.Command, deps oidcLoginCommandDeps, flags oidcLogin
 	// If there was a credential cache, save the resulting credential for future use.
 	if credCache != nil {
 		pLogger.Debug("caching cluster credential for future use.")
-		credCache.Put(cacheKey, cred)
+		credCache.PutForIssuer(cacheKey, flags.issuer, cred)
 	}
 	return json.NewEncoder(cmd.OutOrStdout()).Encode(cred)
 }
@@ -0,0 +1,132 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/execcredcache"
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/pkg/oidcclient"
+	"go.pinniped.dev/pkg/oidcclient/filesession"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+//nolint:gochecknoinits
+func init() {
+	rootCmd.AddCommand(logoutCommand(logoutCommandRealDeps()))
+}
+
+type logoutCommandDeps struct {
+	lookupEnv func(string) (string, bool)
+	logout    func(string, string, *oidctypes.Token, ...oidcclient.Option) error
+}
+
+func logoutCommandRealDeps() logoutCommandDeps {
+	return logoutCommandDeps{
+		lookupEnv: os.LookupEnv,
+		logout:    oidcclient.Logout,
+	}
+}
+
+type logoutFlags struct {
+	issuer              string
+	clientID            string
+	sessionCachePath    string
+	credentialCachePath string
+	caBundlePaths       []string
+	caBundleData        []string
+}
+
+func logoutCommand(deps logoutCommandDeps) *cobra.Command {
+	var (
+		cmd = &cobra.Command{
+			Args:  cobra.NoArgs,
+			Use:   "logout --issuer ISSUER",
+			Short: "End your sessions with a Pinniped Supervisor",
+			Long: here.Doc(
+				`End your sessions with a Pinniped Supervisor
+
+					Ends each of your sessions with the Supervisor FederationDomain at the given
+					issuer URL, and removes each ended session from the local session cache. The
+					next use of a kubeconfig which uses that issuer will require a new login.
+					Sessions which could not be ended are kept, so that this command can be retried.
+
+					Cluster credentials which were obtained using a session with that issuer are
+					also removed from the cluster credential cache.`,
+			),
+			SilenceUsage: true, // do not print usage message when commands fail
+		}
+		flags logoutFlags
+	)
+	cmd.Flags().StringVar(&flags.issuer, "issuer", "", "OpenID Connect issuer URL of the Supervisor FederationDomain")
+	cmd.Flags().StringVar(&flags.clientID, "client-id", oidcapi.ClientIDPinnipedCLI, "OpenID Connect client ID")
+	cmd.Flags().StringVar(&flags.sessionCachePath, "session-cache", filepath.Join(mustGetConfigDir(), "sessions.yaml"), "Path to session cache file")
+	cmd.Flags().StringVar(&flags.credentialCachePath, "credential-cache", filepath.Join(mustGetConfigDir(), "credentials.yaml"), "Path to cluster-specific credentials cache (\"\" disables the cache)")
+	cmd.Flags().StringSliceVar(&flags.caBundlePaths, "ca-bundle", nil, "Path to TLS certificate authority bundle (PEM format, optional, can be repeated)")
+	cmd.Flags().StringSliceVar(&flags.caBundleData, "ca-bundle-data", nil, "Base64 encoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)")
+	mustMarkRequired(cmd, "issuer")
+	cmd.RunE = func(cmd *cobra.Command, _args []string) error { return runLogout(cmd, deps, flags) }
+	return cmd
+}
+
+func runLogout(cmd *cobra.Command, deps logoutCommandDeps, flags logoutFlags) error {
+	pLogger, err := SetLogLevel(cmd.Context(), deps.lookupEnv)
+	if err != nil {
+		plog.WarningErr("Received error while setting log level", err)
+	}
+
+	opts := []oidcclient.Option{
+		oidcclient.WithContext(cmd.Context()),
+		oidcclient.WithLoginLogger(pLogger),
+	}
+	if len(flags.caBundlePaths) > 0 || len(flags.caBundleData) > 0 {
+		client, err := makeClient(flags.caBundlePaths, flags.caBundleData)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, oidcclient.WithClient(client))
+	}
+
+	sessionCache := filesession.New(flags.sessionCachePath, filesession.WithErrorReporter(func(err error) {
+		pLogger.Error("error during session cache operation", err)
+	}))
+	sessions := sessionCache.GetSessions(flags.issuer, flags.clientID)
+
+	if flags.credentialCachePath != "" {
+		pLogger.Debug("clearing cluster credentials for issuer from the cluster credential cache")
+		execcredcache.New(flags.credentialCachePath).ClearIssuer(flags.issuer)
+	}
+
+	if len(sessions) == 0 {
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "No cached sessions found for issuer %q.\n", flags.issuer)
+		return nil
+	}
+
+	// Only remove the local copy of a session after it was ended at the Supervisor, so that a session which
+	// could not be ended can still be found by running this command again.
+	var errs []error
+	for _, session := range sessions {
+		pLogger.Debug("Ending session", "issuer", flags.issuer, "client id", flags.clientID)
+		if err := deps.logout(flags.issuer, flags.clientID, session.Tokens, opts...); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sessionCache.DeleteToken(session.Key)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not end %d of %d sessions at the issuer, so they were kept in the session cache: %w",
+			len(errs), len(sessions), errors.Join(errs...))
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Logged out of issuer %q.\n", flags.issuer)
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+
+	"go.pinniped.dev/internal/execcredcache"
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/pkg/oidcclient"
+	"go.pinniped.dev/pkg/oidcclient/filesession"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+func TestLogoutCommand(t *testing.T) {
+	cfgDir := mustGetConfigDir()
+	future := metav1.NewTime(time.Now().Add(time.Hour))
+
+	sessionKey := func(issuer string, clientID string, upstreamProviderName string) oidcclient.SessionCacheKey {
+		return oidcclient.SessionCacheKey{
+			Issuer:               issuer,
+			ClientID:             clientID,
+			Scopes:               []string{"offline_access", "openid", "pinniped:request-audience", "username", "groups"},
+			RedirectURI:          "http://localhost:0/callback",
+			UpstreamProviderName: upstreamProviderName,
+		}
+	}
+
+	// The cached sessions for the test issuer, by ID token.
+	testIssuerSessionKeys := map[string]oidcclient.SessionCacheKey{
+		"test-id-token":        sessionKey("https://test-issuer.example.com", "pinniped-cli", ""),
+		"test-second-id-token": sessionKey("https://test-issuer.example.com", "pinniped-cli", "some-upstream"),
+		"test-client-id-token": sessionKey("https://test-issuer.example.com", "test-client-id", ""),
+	}
+
+	sessionToken := func(idToken string) *oidctypes.Token {
+		return &oidctypes.Token{
+			IDToken:      &oidctypes.IDToken{Token: idToken, Expiry: future},
+			RefreshToken: &oidctypes.RefreshToken{Token: idToken + "-refresh-token"},
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		// Errors to return when ending sessions at the issuer, by ID token.
+		logoutErrs        map[string]error
+		wantError         bool
+		wantStdout        string
+		wantStderr        string
+		wantLogoutIDToken []string
+		// The ID tokens of the cached sessions for the test issuer which should be removed from the session cache.
+		wantSessionsRemoved []string
+		wantCredsCleared    bool
+	}{
+		{
+			name: "help flag passed",
+			args: []string{"--help"},
+			wantStdout: here.Doc(`
+				End your sessions with a Pinniped Supervisor
+
+				Ends each of your sessions with the Supervisor FederationDomain at the given
+				issuer URL, and removes each ended session from the local session cache. The
+				next use of a kubeconfig which uses that issuer will require a new login.
+				Sessions which could not be ended are kept, so that this command can be retried.
+
+				Cluster credentials which were obtained using a session with that issuer are
+				also removed from the cluster credential cache.
+
+				Usage:
+				  logout --issuer ISSUER [flags]
+
+				Flags:
+				      --ca-bundle strings         Path to TLS certificate authority bundle (PEM format, optional, can be repeated)
+				      --ca-bundle-data strings    Base64 encoded TLS certificate authority bundle (base64 encoded PEM format, optional, can be repeated)
+				      --client-id string          OpenID Connect client ID (default "pinniped-cli")
+				      --credential-cache string   Path to cluster-specific credentials cache ("" disables the cache) (default "` + cfgDir + `/credentials.yaml")
+				  -h, --help                      help for logout
+				      --issuer string             OpenID Connect issuer URL of the Supervisor FederationDomain
+				      --session-cache string      Path to session cache file (default "` + cfgDir + `/sessions.yaml")
+			`),
+		},
+		{
+			name:      "missing required flags",
+			args:      []string{},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: required flag(s) "issuer" not set
+			`),
+		},
+		{
+			name:      "invalid CA bundle data",
+			args:      []string{"--issuer", "https://test-issuer.example.com", "--ca-bundle-data", "invalid-base64"},
+			wantError: true,
+			wantStderr: here.Doc(`
+				Error: could not read --ca-bundle-data: illegal base64 data at input byte 7
+			`),
+		},
+		{
+			name:                "success",
+			args:                []string{"--issuer", "https://test-issuer.example.com"},
+			wantStdout:          "Logged out of issuer \"https://test-issuer.example.com\".\n",
+			wantLogoutIDToken:   []string{"test-id-token", "test-second-id-token"},
+			wantSessionsRemoved: []string{"test-id-token", "test-second-id-token"},
+			wantCredsCleared:    true,
+		},
+		{
+			name:                "success with a different client ID",
+			args:                []string{"--issuer", "https://test-issuer.example.com", "--client-id", "test-client-id"},
+			wantStdout:          "Logged out of issuer \"https://test-issuer.example.com\".\n",
+			wantLogoutIDToken:   []string{"test-client-id-token"},
+			wantSessionsRemoved: []string{"test-client-id-token"},
+			wantCredsCleared:    true,
+		},
+		{
+			name:       "no cached sessions for the issuer",
+			args:       []string{"--issuer", "https://some-other-issuer.example.com"},
+			wantStdout: "No cached sessions found for issuer \"https://some-other-issuer.example.com\".\n",
+		},
+		{
+			name:                "credential cache disabled",
+			args:                []string{"--issuer", "https://test-issuer.example.com", "--credential-cache", ""},
+			wantStdout:          "Logged out of issuer \"https://test-issuer.example.com\".\n",
+			wantLogoutIDToken:   []string{"test-id-token", "test-second-id-token"},
+			wantSessionsRemoved: []string{"test-id-token", "test-second-id-token"},
+		},
+		{
+			name: "logout errors keep every session in the session cache",
+			args: []string{"--issuer", "https://test-issuer.example.com"},
+			logoutErrs: map[string]error{
+				"test-id-token":        errors.New("some logout error"),
+				"test-second-id-token": errors.New("some other logout error"),
+			},
+			wantError:         true,
+			wantLogoutIDToken: []string{"test-id-token", "test-second-id-token"},
+			wantCredsCleared:  true,
+			wantStderr: here.Doc(`
+				Error: could not end 2 of 2 sessions at the issuer, so they were kept in the session cache: some logout error
+				some other logout error
+			`),
+		},
+		{
+			name:                "logout error for one session keeps only that session in the session cache",
+			args:                []string{"--issuer", "https://test-issuer.example.com"},
+			logoutErrs:          map[string]error{"test-second-id-token": errors.New("some logout error")},
+			wantError:           true,
+			wantLogoutIDToken:   []string{"test-id-token", "test-second-id-token"},
+			wantSessionsRemoved: []string{"test-id-token"},
+			wantCredsCleared:    true,
+			wantStderr: here.Doc(`
+				Error: could not end 1 of 2 sessions at the issuer, so they were kept in the session cache: some logout error
+			`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpdir := t.TempDir()
+			sessionCachePath := filepath.Join(tmpdir, "sessions.yaml")
+			credentialCachePath := filepath.Join(tmpdir, "credentials.yaml")
+
+			sessionCache := filesession.New(sessionCachePath)
+			for _, idToken := range []string{"test-id-token", "test-second-id-token", "test-client-id-token"} {
+				sessionCache.PutToken(testIssuerSessionKeys[idToken], sessionToken(idToken))
+			}
+			sessionCache.PutToken(sessionKey("https://unrelated-issuer.example.com", "pinniped-cli", ""), sessionToken("unrelated-id-token"))
+
+			testCred := &clientauthv1beta1.ExecCredential{
+				Status: &clientauthv1beta1.ExecCredentialStatus{Token: "test-cluster-token", ExpirationTimestamp: &future},
+			}
+			credCacheKey := struct{ Args []string }{Args: []string{"some-args"}}
+			unrelatedCredCacheKey := struct{ Args []string }{Args: []string{"unrelated-args"}}
+			credCache := execcredcache.New(credentialCachePath)
+			credCache.PutForIssuer(credCacheKey, "https://test-issuer.example.com", testCred)
+			credCache.PutForIssuer(unrelatedCredCacheKey, "https://unrelated-issuer.example.com", testCred)
+
+			var gotLogoutIDTokens []string
+			cmd := logoutCommand(logoutCommandDeps{
+				lookupEnv: func(string) (string, bool) { return "", false },
+				logout: func(issuer string, clientID string, token *oidctypes.Token, opts ...oidcclient.Option) error {
+					require.Equal(t, "https://test-issuer.example.com", issuer)
+					gotLogoutIDTokens = append(gotLogoutIDTokens, token.IDToken.Token)
+					require.Len(t, opts, 2)
+					return tt.logoutErrs[token.IDToken.Token]
+				},
+			})
+			require.NotNil(t, cmd)
+
+			args := tt.args
+			if len(args) > 0 && args[0] != "--help" {
+				args = append([]string{"--session-cache", sessionCachePath}, args...)
+				if !slices.Contains(args, "--credential-cache") {
+					args = append(args, "--credential-cache", credentialCachePath)
+				}
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(args)
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantStdout, stdout.String(), "unexpected stdout")
+			require.Equal(t, tt.wantStderr, stderr.String(), "unexpected stderr")
+			require.Equal(t, tt.wantLogoutIDToken, gotLogoutIDTokens)
+
+			// Sessions which were ended should be gone from the session cache, and the others should remain.
+			for idToken, key := range testIssuerSessionKeys {
+				cached := sessionCache.GetToken(key)
+				if slices.Contains(tt.wantSessionsRemoved, idToken) {
+					require.Nil(t, cached)
+				} else {
+					require.NotNil(t, cached)
+				}
+			}
+			require.NotNil(t, sessionCache.GetToken(sessionKey("https://unrelated-issuer.example.com", "pinniped-cli", "")))
+
+			if tt.wantCredsCleared {
+				require.Nil(t, credCache.Get(credCacheKey))
+			} else {
+				require.NotNil(t, credCache.Get(credCacheKey))
+			}
+			// Cluster credentials from other issuers are never cleared.
+			require.NotNil(t, credCache.Get(unrelatedCredCacheKey))
+		})
+	}
+}
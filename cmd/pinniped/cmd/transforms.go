@@ -0,0 +1,359 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	"go.pinniped.dev/internal/celtransformer"
+	"go.pinniped.dev/internal/federationdomain/transformscompiler"
+	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/idtransform"
+)
+
+//nolint:gochecknoglobals
+var transformsCmd = &cobra.Command{
+	Use:          "transforms",
+	Short:        "Works with identity transformations using one of [test]",
+	SilenceUsage: true, // Do not print usage message when commands fail.
+}
+
+//nolint:gochecknoinits
+func init() {
+	rootCmd.AddCommand(transformsCmd)
+	transformsCmd.AddCommand(newTransformsTestCommand())
+}
+
+type transformsTestFlags struct {
+	file     string
+	username string
+	groups   []string
+}
+
+func newTransformsTestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs, // do not accept positional arguments for this command
+		Use:   "test",
+		Short: "Test identity transformations offline",
+		Long: here.Doc(
+			`Test identity transformations offline
+
+				Reads a FederationDomain, or the transforms of a FederationDomain identity provider,
+				from a YAML file. The file may contain several YAML documents, including any
+				IdentityTransformLibraries which are used by the transforms. Compiles the expressions
+				and runs the examples exactly as the Supervisor would, and prints the same validation
+				messages that the Supervisor would add to the status of the FederationDomain.
+
+				When --username is given, also runs the transforms of each identity provider for that
+				username and the given --groups, and prints the result of each expression.`,
+		),
+		SilenceUsage: true, // do not print usage message when commands fail
+	}
+	flags := &transformsTestFlags{}
+
+	f := cmd.Flags()
+	f.StringVarP(&flags.file, "file", "f", "", "Path to a YAML file containing a FederationDomain or a transforms block, and optionally IdentityTransformLibraries")
+	f.StringVar(&flags.username, "username", "", "Username of an identity to run through the transforms")
+	f.StringSliceVar(&flags.groups, "groups", nil, "Group names of an identity to run through the transforms (requires --username)")
+	mustMarkRequired(cmd, "file")
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return runTransformsTest(cmd.OutOrStdout(), flags)
+	}
+
+	return cmd
+}
+
+// transformsUnderTest is one set of transforms read from the file, e.g. the transforms of one identity provider.
+type transformsUnderTest struct {
+	description string
+	transforms  supervisorconfigv1alpha1.FederationDomainTransforms
+	paths       transformscompiler.PathsFunc
+}
+
+func runTransformsTest(out io.Writer, flags *transformsTestFlags) error {
+	if len(flags.groups) > 0 && flags.username == "" {
+		return errors.New("--groups requires --username")
+	}
+
+	allTransforms, libraries, err := readTransformsFile(flags.file)
+	if err != nil {
+		return err
+	}
+
+	compiler, err := celtransformer.NewCELTransformer(transformscompiler.MaxExpressionRuntime)
+	if err != nil {
+		return fmt.Errorf("could not initialize CEL: %w", err)
+	}
+
+	ctx := context.Background()
+	passed := true
+
+	compiledLibraries := map[string]*transformscompiler.Library{}
+	for _, library := range libraries {
+		compiledLibrary, err := transformscompiler.CompileLibrary(ctx, compiler, library.Spec)
+		if err != nil {
+			return fmt.Errorf("IdentityTransformLibrary %q: %w", library.Name, err)
+		}
+		compiledLibraries[library.Name] = compiledLibrary
+
+		_, _ = fmt.Fprintf(out, "IdentityTransformLibrary %q:\n", library.Name)
+		if len(compiledLibrary.ErrorMessages) > 0 {
+			passed = false
+			writeTransformsMessages(out, compiledLibrary.ErrorMessages)
+		} else {
+			_, _ = fmt.Fprintf(out, "  %s\n", transformsValidSummary(len(library.Spec.Examples)))
+		}
+		_, _ = fmt.Fprintln(out)
+	}
+	getLibrary := func(name string) (*transformscompiler.Library, error) {
+		return compiledLibraries[name], nil
+	}
+
+	for _, t := range allTransforms {
+		validationErrorMessages := &transformscompiler.ValidationErrorMessages{}
+		pipeline, _, allExamplesPassed, err := transformscompiler.CompileTransforms(
+			ctx, compiler, t.transforms, t.paths, getLibrary, validationErrorMessages)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.description, err)
+		}
+
+		_, _ = fmt.Fprintf(out, "%s:\n", t.description)
+		messages := []string{}
+		messages = append(messages, validationErrorMessages.Libraries...)
+		messages = append(messages, validationErrorMessages.Expressions...)
+		messages = append(messages, validationErrorMessages.Examples...)
+		if len(messages) > 0 || !allExamplesPassed {
+			passed = false
+			writeTransformsMessages(out, messages)
+		} else {
+			examplesCount := len(t.transforms.Examples)
+			for _, tokenExchange := range t.transforms.TokenExchange {
+				examplesCount += len(tokenExchange.Examples)
+			}
+			_, _ = fmt.Fprintf(out, "  %s\n", transformsValidSummary(examplesCount))
+		}
+
+		if flags.username != "" && pipeline != nil {
+			if !evaluateTransformsWithSteps(ctx, out, t, libraries, pipeline, flags) {
+				passed = false
+			}
+		}
+		_, _ = fmt.Fprintln(out)
+	}
+
+	if !passed {
+		return fmt.Errorf("the transforms in %s did not pass", flags.file)
+	}
+	return nil
+}
+
+// evaluateTransformsWithSteps runs the given identity through the pipeline of the transforms and prints the result
+// of each expression. It returns false when the evaluation resulted in an unexpected error.
+func evaluateTransformsWithSteps(
+	ctx context.Context,
+	out io.Writer,
+	t *transformsUnderTest,
+	libraries []*supervisorconfigv1alpha1.IdentityTransformLibrary,
+	pipeline *idtransform.TransformationPipeline,
+	flags *transformsTestFlags,
+) bool {
+	// The pipeline contains the expressions of each library, in order, followed by the expressions of the transforms.
+	type stepSource struct {
+		path       string
+		expression supervisorconfigv1alpha1.FederationDomainTransformsExpression
+	}
+	stepSources := []stepSource{}
+	for _, libraryRef := range t.transforms.Libraries {
+		for _, library := range libraries {
+			if library.Name != libraryRef.Name {
+				continue
+			}
+			for i, expression := range library.Spec.Expressions {
+				stepSources = append(stepSources, stepSource{
+					path:       fmt.Sprintf("IdentityTransformLibrary %q expressions[%d]", library.Name, i),
+					expression: expression,
+				})
+			}
+		}
+	}
+	for i, expression := range t.transforms.Expressions {
+		stepSources = append(stepSources, stepSource{path: fmt.Sprintf("expressions[%d]", i), expression: expression})
+	}
+
+	_, _ = fmt.Fprintf(out, "  evaluating username %q and groups [%s]:\n", flags.username, strings.Join(quoteAll(flags.groups), ", "))
+
+	result, steps, err := pipeline.EvaluateWithSteps(ctx, flags.username, flags.groups, nil)
+	for _, step := range steps {
+		source := stepSources[step.Index]
+		_, _ = fmt.Fprintf(out, "    %s (%s): %s\n", source.path, source.expression.Type,
+			strings.ReplaceAll(strings.TrimSpace(source.expression.Expression), "\n", "\n      "))
+		_, _ = fmt.Fprintf(out, "      => %s\n", describeTransformationResult(step.Result))
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "  result: error: %s\n", err.Error())
+		return false
+	}
+	_, _ = fmt.Fprintf(out, "  result: %s\n", describeTransformationResult(result))
+	return true
+}
+
+// readTransformsFile reads the transforms and the IdentityTransformLibraries from a YAML file which may contain
+// several YAML documents.
+func readTransformsFile(path string) ([]*transformsUnderTest, []*supervisorconfigv1alpha1.IdentityTransformLibrary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read file: %w", err)
+	}
+
+	allTransforms := []*transformsUnderTest{}
+	libraries := []*supervisorconfigv1alpha1.IdentityTransformLibrary{}
+	libraryNames := map[string]bool{}
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for docNumber := 1; ; docNumber++ {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read YAML document %d of %s: %w", docNumber, path, err)
+		}
+
+		var fields map[string]any
+		if err := yaml.Unmarshal(doc, &fields); err != nil {
+			return nil, nil, fmt.Errorf("could not parse YAML document %d of %s: %w", docNumber, path, err)
+		}
+		if len(fields) == 0 {
+			continue // an empty document, e.g. only comments
+		}
+
+		kind, _ := fields["kind"].(string)
+		switch {
+		case kind == "FederationDomain":
+			var federationDomain supervisorconfigv1alpha1.FederationDomain
+			if err := yaml.UnmarshalStrict(doc, &federationDomain); err != nil {
+				return nil, nil, fmt.Errorf("could not parse FederationDomain in YAML document %d of %s: %w", docNumber, path, err)
+			}
+			for idpIndex, idp := range federationDomain.Spec.IdentityProviders {
+				allTransforms = append(allTransforms, &transformsUnderTest{
+					description: fmt.Sprintf("FederationDomain %q identity provider %q", federationDomain.Name, idp.DisplayName),
+					transforms:  idp.Transforms,
+					paths:       transformscompiler.IdentityProviderPaths(idpIndex),
+				})
+			}
+
+		case kind == "IdentityTransformLibrary":
+			var library supervisorconfigv1alpha1.IdentityTransformLibrary
+			if err := yaml.UnmarshalStrict(doc, &library); err != nil {
+				return nil, nil, fmt.Errorf("could not parse IdentityTransformLibrary in YAML document %d of %s: %w", docNumber, path, err)
+			}
+			if libraryNames[library.Name] {
+				return nil, nil, fmt.Errorf("IdentityTransformLibrary %q appears more than once in %s", library.Name, path)
+			}
+			libraryNames[library.Name] = true
+			libraries = append(libraries, &library)
+
+		case kind != "":
+			return nil, nil, fmt.Errorf("YAML document %d of %s has unsupported kind %q: expected a FederationDomain, an IdentityTransformLibrary, or a transforms block", docNumber, path, kind)
+
+		default:
+			transforms, err := readTransformsBlock(doc, fields, docNumber)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not parse transforms in YAML document %d of %s: %w", docNumber, path, err)
+			}
+			allTransforms = append(allTransforms, transforms)
+		}
+	}
+
+	if len(allTransforms) == 0 && len(libraries) == 0 {
+		return nil, nil, fmt.Errorf("no FederationDomain, IdentityTransformLibrary, or transforms found in %s", path)
+	}
+	return allTransforms, libraries, nil
+}
+
+// readTransformsBlock reads a YAML document which is a transforms block. The block may also be written under a
+// "transforms" key, e.g. by copying a whole identity provider from a FederationDomain.
+func readTransformsBlock(doc []byte, fields map[string]any, docNumber int) (*transformsUnderTest, error) {
+	var idp supervisorconfigv1alpha1.FederationDomainIdentityProvider
+	var err error
+	if _, ok := fields["transforms"]; ok {
+		err = yaml.UnmarshalStrict(doc, &idp)
+	} else {
+		err = yaml.UnmarshalStrict(doc, &idp.Transforms)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("transforms in YAML document %d", docNumber)
+	if idp.DisplayName != "" {
+		description = fmt.Sprintf("identity provider %q", idp.DisplayName)
+	}
+	return &transformsUnderTest{
+		description: description,
+		transforms:  idp.Transforms,
+		paths: func(transformsPathSuffix string) transformscompiler.Paths {
+			path := "transforms" + transformsPathSuffix
+			return transformscompiler.Paths{Expressions: path, Examples: path, Types: path}
+		},
+	}, nil
+}
+
+func transformsValidSummary(examplesCount int) string {
+	switch examplesCount {
+	case 0:
+		return "the expressions are valid, and there are no examples"
+	case 1:
+		return "the expressions are valid, and the example passed"
+	default:
+		return fmt.Sprintf("the expressions are valid, and all %d examples passed", examplesCount)
+	}
+}
+
+func writeTransformsMessages(out io.Writer, messages []string) {
+	for i, message := range messages {
+		if i > 0 {
+			_, _ = fmt.Fprintln(out)
+		}
+		_, _ = fmt.Fprintf(out, "  %s\n", strings.ReplaceAll(message, "\n", "\n  "))
+	}
+}
+
+func describeTransformationResult(result *idtransform.TransformationResult) string {
+	if !result.AuthenticationAllowed {
+		return fmt.Sprintf("rejected with message %q", result.RejectedAuthenticationMessage)
+	}
+	description := fmt.Sprintf("username %q, groups [%s]", result.Username, strings.Join(quoteAll(result.Groups), ", "))
+	if len(result.AdditionalClaims) > 0 {
+		claimsJSON, err := json.Marshal(result.AdditionalClaims) // sorts the claim names
+		if err != nil {
+			claimsJSON = []byte(fmt.Sprintf("%v", result.AdditionalClaims))
+		}
+		description += fmt.Sprintf(", additional claims %s", claimsJSON)
+	}
+	return description
+}
+
+func quoteAll(strs []string) []string {
+	quoted := make([]string, 0, len(strs))
+	for _, s := range strs {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	return quoted
+}
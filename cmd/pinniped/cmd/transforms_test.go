@@ -0,0 +1,312 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/here"
+)
+
+func TestTransformsTest(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile := func(name string, contents string) string {
+		path := filepath.Join(tmpDir, name)
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+		return path
+	}
+
+	unsupportedKindPath := writeFile("unsupported-kind.yaml", here.Doc(`
+		apiVersion: idp.supervisor.pinniped.dev/v1alpha1
+		kind: OIDCIdentityProvider
+		metadata:
+		  name: my-oidc
+	`))
+	typoPath := writeFile("typo.yaml", here.Doc(`
+		expresions:
+		  - type: username/v1
+		    expression: username
+	`))
+	emptyPath := writeFile("empty.yaml", "# nothing here\n---\n")
+	duplicateLibraryPath := writeFile("duplicate-library.yaml", here.Doc(`
+		kind: IdentityTransformLibrary
+		metadata:
+		  name: my-library
+		---
+		kind: IdentityTransformLibrary
+		metadata:
+		  name: my-library
+	`))
+	invalidLibraryPath := writeFile("invalid-library.yaml", here.Doc(`
+		kind: IdentityTransformLibrary
+		metadata:
+		  name: my-library
+		spec:
+		  expressions:
+		    - type: username/v1
+		      expression: '"pre:" + username'
+		  examples:
+		    - username: pinny
+		      expects:
+		        username: pinny
+		---
+		transforms:
+		  libraries:
+		    - name: my-library
+	`))
+	evaluationErrorPath := writeFile("evaluation-error.yaml", here.Doc(`
+		expressions:
+		  - type: groups/v1
+		    expression: 'groups + ["new-group"]'
+		  - type: username/v1
+		    expression: '""'
+	`))
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantError  bool
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name: "help flag passed",
+			args: []string{"--help"},
+			wantStdout: here.Doc(`
+				Test identity transformations offline
+
+				Reads a FederationDomain, or the transforms of a FederationDomain identity provider,
+				from a YAML file. The file may contain several YAML documents, including any
+				IdentityTransformLibraries which are used by the transforms. Compiles the expressions
+				and runs the examples exactly as the Supervisor would, and prints the same validation
+				messages that the Supervisor would add to the status of the FederationDomain.
+
+				When --username is given, also runs the transforms of each identity provider for that
+				username and the given --groups, and prints the result of each expression.
+
+				Usage:
+				  test [flags]
+
+				Flags:
+				  -f, --file string       Path to a YAML file containing a FederationDomain or a transforms block, and optionally IdentityTransformLibraries
+				      --groups strings    Group names of an identity to run through the transforms (requires --username)
+				  -h, --help              help for test
+				      --username string   Username of an identity to run through the transforms
+			`),
+		},
+		{
+			name:       "missing required file flag",
+			args:       []string{},
+			wantError:  true,
+			wantStderr: "Error: required flag(s) \"file\" not set\n",
+		},
+		{
+			name:       "groups without username",
+			args:       []string{"-f", "testdata/transforms-block.yaml", "--groups", "a"},
+			wantError:  true,
+			wantStderr: "Error: --groups requires --username\n",
+		},
+		{
+			name:       "file does not exist",
+			args:       []string{"-f", "testdata/does-not-exist.yaml"},
+			wantError:  true,
+			wantStderr: "Error: could not read file: open testdata/does-not-exist.yaml: no such file or directory\n",
+		},
+		{
+			name:       "unsupported kind",
+			args:       []string{"-f", unsupportedKindPath},
+			wantError:  true,
+			wantStderr: "Error: YAML document 1 of " + unsupportedKindPath + " has unsupported kind \"OIDCIdentityProvider\": expected a FederationDomain, an IdentityTransformLibrary, or a transforms block\n",
+		},
+		{
+			name:       "unknown field in transforms",
+			args:       []string{"-f", typoPath},
+			wantError:  true,
+			wantStderr: "Error: could not parse transforms in YAML document 1 of " + typoPath + ": error unmarshaling JSON: while decoding JSON: json: unknown field \"expresions\"\n",
+		},
+		{
+			name:       "no documents",
+			args:       []string{"-f", emptyPath},
+			wantError:  true,
+			wantStderr: "Error: no FederationDomain, IdentityTransformLibrary, or transforms found in " + emptyPath + "\n",
+		},
+		{
+			name:       "duplicate library",
+			args:       []string{"-f", duplicateLibraryPath},
+			wantError:  true,
+			wantStderr: "Error: IdentityTransformLibrary \"my-library\" appears more than once in " + duplicateLibraryPath + "\n",
+		},
+		{
+			name: "FederationDomain with a library",
+			args: []string{"-f", "testdata/transforms-federationdomain.yaml"},
+			wantStdout: here.Doc(`
+				IdentityTransformLibrary "common-policies":
+				  the expressions are valid, and the example passed
+
+				FederationDomain "my-federation-domain" identity provider "my-ldap":
+				  the expressions are valid, and all 2 examples passed
+
+				FederationDomain "my-federation-domain" identity provider "my-oidc":
+				  the expressions are valid, and there are no examples
+
+			`),
+		},
+		{
+			name: "FederationDomain with a library and an allowed identity",
+			args: []string{"-f", "testdata/transforms-federationdomain.yaml", "--username", "pinny", "--groups", "b,a,b"},
+			wantStdout: here.Doc(`
+				IdentityTransformLibrary "common-policies":
+				  the expressions are valid, and the example passed
+
+				FederationDomain "my-federation-domain" identity provider "my-ldap":
+				  the expressions are valid, and all 2 examples passed
+				  evaluating username "pinny" and groups ["b", "a", "b"]:
+				    IdentityTransformLibrary "common-policies" expressions[0] (policy/v1): !(username in strListConst.bannedUsers)
+				      => username "pinny", groups ["b", "a", "b"]
+				    expressions[0] (username/v1): strConst.prefix + username
+				      => username "ldap:pinny", groups ["b", "a", "b"]
+				    expressions[1] (groups/v1): groups.map(g, strConst.prefix + g)
+				      => username "ldap:pinny", groups ["ldap:b", "ldap:a", "ldap:b"]
+				    expressions[2] (claims/v1): {"department": "engineering"}
+				      => username "ldap:pinny", groups ["ldap:b", "ldap:a", "ldap:b"], additional claims {"department":"engineering"}
+				  result: username "ldap:pinny", groups ["ldap:a", "ldap:b"], additional claims {"department":"engineering"}
+
+				FederationDomain "my-federation-domain" identity provider "my-oidc":
+				  the expressions are valid, and there are no examples
+				  evaluating username "pinny" and groups ["b", "a", "b"]:
+				  result: username "pinny", groups ["a", "b"]
+
+			`),
+		},
+		{
+			name: "FederationDomain with a library and a rejected identity",
+			args: []string{"-f", "testdata/transforms-federationdomain.yaml", "--username", "ryan"},
+			wantStdout: here.Doc(`
+				IdentityTransformLibrary "common-policies":
+				  the expressions are valid, and the example passed
+
+				FederationDomain "my-federation-domain" identity provider "my-ldap":
+				  the expressions are valid, and all 2 examples passed
+				  evaluating username "ryan" and groups []:
+				    IdentityTransformLibrary "common-policies" expressions[0] (policy/v1): !(username in strListConst.bannedUsers)
+				      => rejected with message "this user is banned"
+				  result: rejected with message "this user is banned"
+
+				FederationDomain "my-federation-domain" identity provider "my-oidc":
+				  the expressions are valid, and there are no examples
+				  evaluating username "ryan" and groups []:
+				  result: username "ryan", groups []
+
+			`),
+		},
+		{
+			name: "transforms block with a multi-line expression",
+			args: []string{"-f", "testdata/transforms-block.yaml", "--username", "pinny", "--groups", "g"},
+			wantStdout: here.Doc(`
+				transforms in YAML document 1:
+				  the expressions are valid, and the example passed
+				  evaluating username "pinny" and groups ["g"]:
+				    expressions[0] (policy/v1): !username.endsWith(strConst.suffix)
+				      => username "pinny", groups ["g"]
+				    expressions[1] (username/v1): username +
+				        strConst.suffix
+				      => username "pinny@example.com", groups ["g"]
+				  result: username "pinny@example.com", groups ["g"]
+
+			`),
+		},
+		{
+			name:      "invalid transforms and failing examples",
+			args:      []string{"-f", "testdata/transforms-invalid.yaml", "--username", "pinny"},
+			wantError: true,
+			wantStdout: here.Doc(`
+				transforms in YAML document 1:
+				  transforms.libraries[0].name refers to an IdentityTransformLibrary "does-not-exist" which was not found
+
+				  unable to check if the examples specified by transforms.examples[] had errors because a library was invalid
+
+				transforms in YAML document 2:
+				  transforms.expressions[0].expression was invalid:
+				  CEL expression compile error: ERROR: <input>:1:6: Syntax error: mismatched input 'is' expecting <EOF>
+				   | this is not valid
+				   | .....^
+
+				  unable to check if the examples specified by transforms.examples[] had errors because an expression was invalid
+
+				identity provider "my-github":
+				  transforms.examples[0] example failed:
+				  expected: username "pinny"
+				  actual:   username "gh:pinny"
+				  evaluating username "pinny" and groups []:
+				    expressions[0] (username/v1): "gh:" + username
+				      => username "gh:pinny", groups []
+				  result: username "gh:pinny", groups []
+
+			`),
+			wantStderr: "Error: the transforms in testdata/transforms-invalid.yaml did not pass\n",
+		},
+		{
+			name:      "invalid library",
+			args:      []string{"-f", invalidLibraryPath},
+			wantError: true,
+			wantStdout: here.Doc(`
+				IdentityTransformLibrary "my-library":
+				  .spec.examples[0] example failed:
+				  expected: username "pinny"
+				  actual:   username "pre:pinny"
+
+				transforms in YAML document 2:
+				  transforms.libraries[0].name refers to an IdentityTransformLibrary "my-library" which is invalid:
+				  .spec.examples[0] example failed:
+				  expected: username "pinny"
+				  actual:   username "pre:pinny"
+
+				  unable to check if the examples specified by transforms.examples[] had errors because a library was invalid
+
+			`),
+			wantStderr: "Error: the transforms in " + invalidLibraryPath + " did not pass\n",
+		},
+		{
+			name:      "evaluation error",
+			args:      []string{"-f", evaluationErrorPath, "--username", "pinny"},
+			wantError: true,
+			wantStdout: here.Doc(`
+				transforms in YAML document 1:
+				  the expressions are valid, and there are no examples
+				  evaluating username "pinny" and groups []:
+				    expressions[0] (groups/v1): groups + ["new-group"]
+				      => username "pinny", groups ["new-group"]
+				    expressions[1] (username/v1): ""
+				      => username "", groups ["new-group"]
+				  result: error: identity transformation returned an empty username, which is not allowed
+
+			`),
+			wantStderr: "Error: the transforms in " + evaluationErrorPath + " did not pass\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newTransformsTestCommand()
+			require.NotNil(t, cmd)
+
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(tt.args)
+			err := cmd.Execute()
+			if tt.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantStdout, stdout.String(), "unexpected stdout")
+			require.Equal(t, tt.wantStderr, stderr.String(), "unexpected stderr")
+		})
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -38,6 +38,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&ActiveDirectoryIdentityProviderList{},
 		&GitHubIdentityProvider{},
 		&GitHubIdentityProviderList{},
+		&SAMLIdentityProvider{},
+		&SAMLIdentityProviderList{},
+		&OAuth2IdentityProvider{},
+		&OAuth2IdentityProviderList{},
+		&LocalUserIdentityProvider{},
+		&LocalUserIdentityProviderList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -1,4 +1,4 @@
-// Copyright 2021-2023 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -57,6 +57,23 @@ type ActiveDirectoryIdentityProviderUserSearchAttributes struct {
 	// Optional, when empty this defaults to "objectGUID".
 	// +optional
 	UID string `json:"uid,omitempty"`
+
+	// AdditionalClaimMappings allows for additional arbitrary LDAP attribute values to be mapped into the
+	// "additionalClaims" claim of the ID tokens generated by the Supervisor. This should be specified as a map of
+	// new claim names as the keys, and LDAP attribute names as the values. These new claim names will be nested
+	// under the top-level "additionalClaims" claim in ID tokens generated by the Supervisor when this
+	// ActiveDirectoryIdentityProvider was used for user authentication. These claims will be made available to all clients.
+	// The values of the attributes are read from the user's entry during login, and read again during each
+	// session refresh. An attribute with a single value becomes a string claim, and an attribute with multiple
+	// values becomes a claim whose value is a list of strings. Attributes which are not present on the user's
+	// entry are omitted.
+	// The attribute names are case-sensitive and must match the case of the attribute names returned by the
+	// Active Directory server in the user's entry. The distinguished name can be used by specifying lower-case "dn".
+	// This feature is not required to use the Supervisor to provide authentication for Kubernetes clusters, but can be
+	// used when using the Supervisor for other authentication purposes. When this map is empty or the attributes
+	// are not available, the "additionalClaims" claim will be excluded from the ID tokens generated by the Supervisor.
+	// +optional
+	AdditionalClaimMappings map[string]string `json:"additionalClaimMappings,omitempty"`
 }
 
 type ActiveDirectoryIdentityProviderGroupSearchAttributes struct {
@@ -70,6 +87,57 @@ type ActiveDirectoryIdentityProviderGroupSearchAttributes struct {
 	GroupName string `json:"groupName,omitempty"`
 }
 
+// ActiveDirectoryGroupSearchStrategyType is the strategy used to find the groups to which a user belongs.
+// +kubebuilder:validation:Enum=search;recursiveSearch;memberOfAttribute
+type ActiveDirectoryGroupSearchStrategyType string
+
+const (
+	// ActiveDirectoryGroupSearchStrategySearch performs one group search to find the groups to which the user directly belongs.
+	ActiveDirectoryGroupSearchStrategySearch ActiveDirectoryGroupSearchStrategyType = "search"
+
+	// ActiveDirectoryGroupSearchStrategyRecursiveSearch repeats the group search for each group found, to also find the
+	// groups to which the user indirectly belongs through nested groups.
+	ActiveDirectoryGroupSearchStrategyRecursiveSearch ActiveDirectoryGroupSearchStrategyType = "recursiveSearch"
+
+	// ActiveDirectoryGroupSearchStrategyMemberOfAttribute reads the DNs of the user's groups from an attribute of the user's
+	// entry instead of performing a group search.
+	ActiveDirectoryGroupSearchStrategyMemberOfAttribute ActiveDirectoryGroupSearchStrategyType = "memberOfAttribute"
+)
+
+type ActiveDirectoryIdentityProviderGroupSearchStrategy struct {
+	// Type determines how the groups of the user are found.
+	// "search" performs one group search using the Filter, and finds the groups to which the user directly belongs.
+	// "recursiveSearch" performs the same group search, and then finds nested groups by repeating the group search
+	// for each group found, with the "{}" placeholder(s) in the Filter replaced by the dn of that group, up to MaxDepth
+	// levels of nesting.
+	// "memberOfAttribute" does not perform a group search. Instead, it reads the dn of each group from the
+	// MemberOfAttribute of the user's entry, and finds nested groups by reading the MemberOfAttribute of each group's
+	// entry, up to MaxDepth levels of nesting. When using "memberOfAttribute", the Filter and UserAttributeForFilter
+	// are ignored, and only groups whose dn is within the Base are included.
+	// Each group is only followed once, so groups which are nested within themselves do not cause an endless search.
+	// Optional. When not specified, the default will act as if "search" were specified.
+	// +kubebuilder:default=search
+	// +optional
+	Type ActiveDirectoryGroupSearchStrategyType `json:"type,omitempty"`
+
+	// MemberOfAttribute specifies the name of the attribute of user and group entries which lists the dn of each group
+	// to which the entry belongs. Only used when Type is "memberOfAttribute".
+	// The value of this field is case-sensitive and must match the case of the attribute name returned by the ActiveDirectory
+	// server. E.g. "memberOf" or "isMemberOf".
+	// Optional. When not specified, the default will act as if "memberOf" were specified.
+	// +optional
+	MemberOfAttribute string `json:"memberOfAttribute,omitempty"`
+
+	// MaxDepth is the maximum number of levels of nested groups to find when Type is "recursiveSearch" or
+	// "memberOfAttribute". A MaxDepth of 1 only finds the groups to which the user directly belongs.
+	// Groups which are nested more deeply are ignored.
+	// Optional. When not specified, the default will act as if 10 were specified.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxDepth *int32 `json:"maxDepth,omitempty"`
+}
+
 type ActiveDirectoryIdentityProviderUserSearch struct {
 	// Base is the dn (distinguished name) that should be used as the search base when searching for users.
 	// E.g. "ou=users,dc=example,dc=com".
@@ -125,6 +193,8 @@ type ActiveDirectoryIdentityProviderGroupSearch struct {
 	// Note that nested group search can be slow for some Active Directory servers. To disable it,
 	// you can set the filter to
 	// "(&(objectClass=group)(member={})"
+	// When Strategy.Type is "recursiveSearch", the default will instead act as if the filter were specified as
+	// "(&(objectClass=group)(member={}))", because nested groups are found by repeating the group search.
 	// +optional
 	Filter string `json:"filter,omitempty"`
 
@@ -144,6 +214,11 @@ type ActiveDirectoryIdentityProviderGroupSearch struct {
 	// +optional
 	Attributes ActiveDirectoryIdentityProviderGroupSearchAttributes `json:"attributes,omitempty"`
 
+	// Strategy specifies how the groups of the user are found, including whether nested groups are found.
+	// The same strategy is used to find the user's groups during each refresh, unless SkipGroupRefresh is true.
+	// +optional
+	Strategy ActiveDirectoryIdentityProviderGroupSearchStrategy `json:"strategy,omitempty"`
+
 	// The user's group membership is refreshed as they interact with the supervisor
 	// to obtain new credentials (as their old credentials expire).  This allows group
 	// membership changes to be quickly reflected into Kubernetes clusters.  Since
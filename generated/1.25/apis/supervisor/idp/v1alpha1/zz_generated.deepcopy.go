@@ -61,6 +61,7 @@ func (in *ActiveDirectoryIdentityProviderBind) DeepCopy() *ActiveDirectoryIdenti
 func (in *ActiveDirectoryIdentityProviderGroupSearch) DeepCopyInto(out *ActiveDirectoryIdentityProviderGroupSearch) {
 	*out = *in
 	out.Attributes = in.Attributes
+	in.Strategy.DeepCopyInto(&out.Strategy)
 	return
 }
 
@@ -90,6 +91,27 @@ func (in *ActiveDirectoryIdentityProviderGroupSearchAttributes) DeepCopy() *Acti
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActiveDirectoryIdentityProviderGroupSearchStrategy) DeepCopyInto(out *ActiveDirectoryIdentityProviderGroupSearchStrategy) {
+	*out = *in
+	if in.MaxDepth != nil {
+		in, out := &in.MaxDepth, &out.MaxDepth
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActiveDirectoryIdentityProviderGroupSearchStrategy.
+func (in *ActiveDirectoryIdentityProviderGroupSearchStrategy) DeepCopy() *ActiveDirectoryIdentityProviderGroupSearchStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ActiveDirectoryIdentityProviderGroupSearchStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActiveDirectoryIdentityProviderList) DeepCopyInto(out *ActiveDirectoryIdentityProviderList) {
 	*out = *in
@@ -132,8 +154,8 @@ func (in *ActiveDirectoryIdentityProviderSpec) DeepCopyInto(out *ActiveDirectory
 		(*in).DeepCopyInto(*out)
 	}
 	out.Bind = in.Bind
-	out.UserSearch = in.UserSearch
-	out.GroupSearch = in.GroupSearch
+	in.UserSearch.DeepCopyInto(&out.UserSearch)
+	in.GroupSearch.DeepCopyInto(&out.GroupSearch)
 	return
 }
 
@@ -173,7 +195,7 @@ func (in *ActiveDirectoryIdentityProviderStatus) DeepCopy() *ActiveDirectoryIden
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActiveDirectoryIdentityProviderUserSearch) DeepCopyInto(out *ActiveDirectoryIdentityProviderUserSearch) {
 	*out = *in
-	out.Attributes = in.Attributes
+	in.Attributes.DeepCopyInto(&out.Attributes)
 	return
 }
 
@@ -190,6 +212,13 @@ func (in *ActiveDirectoryIdentityProviderUserSearch) DeepCopy() *ActiveDirectory
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActiveDirectoryIdentityProviderUserSearchAttributes) DeepCopyInto(out *ActiveDirectoryIdentityProviderUserSearchAttributes) {
 	*out = *in
+	if in.AdditionalClaimMappings != nil {
+		in, out := &in.AdditionalClaimMappings, &out.AdditionalClaimMappings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -249,6 +278,18 @@ func (in *GitHubAPIConfig) DeepCopy() *GitHubAPIConfig {
 func (in *GitHubAllowAuthenticationSpec) DeepCopyInto(out *GitHubAllowAuthenticationSpec) {
 	*out = *in
 	in.Organizations.DeepCopyInto(&out.Organizations)
+	if in.Teams != nil {
+		in, out := &in.Teams, &out.Teams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]GitHubRepositoryAllowRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -262,6 +303,22 @@ func (in *GitHubAllowAuthenticationSpec) DeepCopy() *GitHubAllowAuthenticationSp
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHubAppSpec) DeepCopyInto(out *GitHubAppSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubAppSpec.
+func (in *GitHubAppSpec) DeepCopy() *GitHubAppSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHubAppSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitHubClaims) DeepCopyInto(out *GitHubClaims) {
 	*out = *in
@@ -275,6 +332,11 @@ func (in *GitHubClaims) DeepCopyInto(out *GitHubClaims) {
 		*out = new(GitHubGroupNameAttribute)
 		**out = **in
 	}
+	if in.RepositoryRoleGroups != nil {
+		in, out := &in.RepositoryRoleGroups, &out.RepositoryRoleGroups
+		*out = new(GitHubRepositoryRoleGroupsSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -372,6 +434,11 @@ func (in *GitHubIdentityProviderSpec) DeepCopyInto(out *GitHubIdentityProviderSp
 	in.Claims.DeepCopyInto(&out.Claims)
 	in.AllowAuthentication.DeepCopyInto(&out.AllowAuthentication)
 	out.Client = in.Client
+	if in.App != nil {
+		in, out := &in.App, &out.App
+		*out = new(GitHubAppSpec)
+		**out = **in
+	}
 	return
 }
 
@@ -434,6 +501,48 @@ func (in *GitHubOrganizationsSpec) DeepCopy() *GitHubOrganizationsSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHubRepositoryAllowRule) DeepCopyInto(out *GitHubRepositoryAllowRule) {
+	*out = *in
+	if in.MinimumRole != nil {
+		in, out := &in.MinimumRole, &out.MinimumRole
+		*out = new(GitHubRepositoryRole)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubRepositoryAllowRule.
+func (in *GitHubRepositoryAllowRule) DeepCopy() *GitHubRepositoryAllowRule {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHubRepositoryAllowRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHubRepositoryRoleGroupsSpec) DeepCopyInto(out *GitHubRepositoryRoleGroupsSpec) {
+	*out = *in
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubRepositoryRoleGroupsSpec.
+func (in *GitHubRepositoryRoleGroupsSpec) DeepCopy() *GitHubRepositoryRoleGroupsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHubRepositoryRoleGroupsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProvider) DeepCopyInto(out *LDAPIdentityProvider) {
 	*out = *in
@@ -478,10 +587,48 @@ func (in *LDAPIdentityProviderBind) DeepCopy() *LDAPIdentityProviderBind {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPIdentityProviderConnectionPool) DeepCopyInto(out *LDAPIdentityProviderConnectionPool) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPIdentityProviderConnectionPool.
+func (in *LDAPIdentityProviderConnectionPool) DeepCopy() *LDAPIdentityProviderConnectionPool {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPIdentityProviderConnectionPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPIdentityProviderFailover) DeepCopyInto(out *LDAPIdentityProviderFailover) {
+	*out = *in
+	if in.AdditionalHosts != nil {
+		in, out := &in.AdditionalHosts, &out.AdditionalHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPIdentityProviderFailover.
+func (in *LDAPIdentityProviderFailover) DeepCopy() *LDAPIdentityProviderFailover {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPIdentityProviderFailover)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProviderGroupSearch) DeepCopyInto(out *LDAPIdentityProviderGroupSearch) {
 	*out = *in
 	out.Attributes = in.Attributes
+	in.Strategy.DeepCopyInto(&out.Strategy)
 	return
 }
 
@@ -511,6 +658,27 @@ func (in *LDAPIdentityProviderGroupSearchAttributes) DeepCopy() *LDAPIdentityPro
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPIdentityProviderGroupSearchStrategy) DeepCopyInto(out *LDAPIdentityProviderGroupSearchStrategy) {
+	*out = *in
+	if in.MaxDepth != nil {
+		in, out := &in.MaxDepth, &out.MaxDepth
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPIdentityProviderGroupSearchStrategy.
+func (in *LDAPIdentityProviderGroupSearchStrategy) DeepCopy() *LDAPIdentityProviderGroupSearchStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPIdentityProviderGroupSearchStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProviderList) DeepCopyInto(out *LDAPIdentityProviderList) {
 	*out = *in
@@ -553,8 +721,10 @@ func (in *LDAPIdentityProviderSpec) DeepCopyInto(out *LDAPIdentityProviderSpec)
 		(*in).DeepCopyInto(*out)
 	}
 	out.Bind = in.Bind
-	out.UserSearch = in.UserSearch
-	out.GroupSearch = in.GroupSearch
+	in.UserSearch.DeepCopyInto(&out.UserSearch)
+	in.GroupSearch.DeepCopyInto(&out.GroupSearch)
+	in.Failover.DeepCopyInto(&out.Failover)
+	out.ConnectionPool = in.ConnectionPool
 	return
 }
 
@@ -594,7 +764,7 @@ func (in *LDAPIdentityProviderStatus) DeepCopy() *LDAPIdentityProviderStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProviderUserSearch) DeepCopyInto(out *LDAPIdentityProviderUserSearch) {
 	*out = *in
-	out.Attributes = in.Attributes
+	in.Attributes.DeepCopyInto(&out.Attributes)
 	return
 }
 
@@ -611,6 +781,13 @@ func (in *LDAPIdentityProviderUserSearch) DeepCopy() *LDAPIdentityProviderUserSe
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LDAPIdentityProviderUserSearchAttributes) DeepCopyInto(out *LDAPIdentityProviderUserSearchAttributes) {
 	*out = *in
+	if in.AdditionalClaimMappings != nil {
+		in, out := &in.AdditionalClaimMappings, &out.AdditionalClaimMappings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -624,6 +801,317 @@ func (in *LDAPIdentityProviderUserSearchAttributes) DeepCopy() *LDAPIdentityProv
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalUserIdentityProvider) DeepCopyInto(out *LocalUserIdentityProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalUserIdentityProvider.
+func (in *LocalUserIdentityProvider) DeepCopy() *LocalUserIdentityProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalUserIdentityProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LocalUserIdentityProvider) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalUserIdentityProviderList) DeepCopyInto(out *LocalUserIdentityProviderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LocalUserIdentityProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalUserIdentityProviderList.
+func (in *LocalUserIdentityProviderList) DeepCopy() *LocalUserIdentityProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalUserIdentityProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LocalUserIdentityProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalUserIdentityProviderSpec) DeepCopyInto(out *LocalUserIdentityProviderSpec) {
+	*out = *in
+	in.Users.DeepCopyInto(&out.Users)
+	in.Lockout.DeepCopyInto(&out.Lockout)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalUserIdentityProviderSpec.
+func (in *LocalUserIdentityProviderSpec) DeepCopy() *LocalUserIdentityProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalUserIdentityProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalUserIdentityProviderStatus) DeepCopyInto(out *LocalUserIdentityProviderStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalUserIdentityProviderStatus.
+func (in *LocalUserIdentityProviderStatus) DeepCopy() *LocalUserIdentityProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalUserIdentityProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalUserLockout) DeepCopyInto(out *LocalUserLockout) {
+	*out = *in
+	if in.MaxFailedAttempts != nil {
+		in, out := &in.MaxFailedAttempts, &out.MaxFailedAttempts
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DurationSeconds != nil {
+		in, out := &in.DurationSeconds, &out.DurationSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalUserLockout.
+func (in *LocalUserLockout) DeepCopy() *LocalUserLockout {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalUserLockout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalUserUsers) DeepCopyInto(out *LocalUserUsers) {
+	*out = *in
+	in.SecretSelector.DeepCopyInto(&out.SecretSelector)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalUserUsers.
+func (in *LocalUserUsers) DeepCopy() *LocalUserUsers {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalUserUsers)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2AuthorizationConfig) DeepCopyInto(out *OAuth2AuthorizationConfig) {
+	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdditionalAuthorizeParameters != nil {
+		in, out := &in.AdditionalAuthorizeParameters, &out.AdditionalAuthorizeParameters
+		*out = make([]Parameter, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2AuthorizationConfig.
+func (in *OAuth2AuthorizationConfig) DeepCopy() *OAuth2AuthorizationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2AuthorizationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2Claims) DeepCopyInto(out *OAuth2Claims) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2Claims.
+func (in *OAuth2Claims) DeepCopy() *OAuth2Claims {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2Claims)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2Client) DeepCopyInto(out *OAuth2Client) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2Client.
+func (in *OAuth2Client) DeepCopy() *OAuth2Client {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2Client)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2IdentityProvider) DeepCopyInto(out *OAuth2IdentityProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2IdentityProvider.
+func (in *OAuth2IdentityProvider) DeepCopy() *OAuth2IdentityProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2IdentityProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OAuth2IdentityProvider) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2IdentityProviderList) DeepCopyInto(out *OAuth2IdentityProviderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OAuth2IdentityProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2IdentityProviderList.
+func (in *OAuth2IdentityProviderList) DeepCopy() *OAuth2IdentityProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2IdentityProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OAuth2IdentityProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2IdentityProviderSpec) DeepCopyInto(out *OAuth2IdentityProviderSpec) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(TLSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.AuthorizationConfig.DeepCopyInto(&out.AuthorizationConfig)
+	out.Claims = in.Claims
+	out.Client = in.Client
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2IdentityProviderSpec.
+func (in *OAuth2IdentityProviderSpec) DeepCopy() *OAuth2IdentityProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2IdentityProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2IdentityProviderStatus) DeepCopyInto(out *OAuth2IdentityProviderStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2IdentityProviderStatus.
+func (in *OAuth2IdentityProviderStatus) DeepCopy() *OAuth2IdentityProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2IdentityProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCAuthorizationConfig) DeepCopyInto(out *OIDCAuthorizationConfig) {
 	*out = *in
@@ -637,6 +1125,21 @@ func (in *OIDCAuthorizationConfig) DeepCopyInto(out *OIDCAuthorizationConfig) {
 		*out = make([]Parameter, len(*in))
 		copy(*out, *in)
 	}
+	if in.ACRValues != nil {
+		in, out := &in.ACRValues, &out.ACRValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(int64)
+		**out = **in
+	}
+	if in.RequiredAMRValues != nil {
+		in, out := &in.RequiredAMRValues, &out.RequiredAMRValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -660,6 +1163,11 @@ func (in *OIDCClaims) DeepCopyInto(out *OIDCClaims) {
 			(*out)[key] = val
 		}
 	}
+	if in.GroupsEndpoint != nil {
+		in, out := &in.GroupsEndpoint, &out.GroupsEndpoint
+		*out = new(OIDCGroupsEndpoint)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -689,6 +1197,27 @@ func (in *OIDCClient) DeepCopy() *OIDCClient {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCGroupsEndpoint) DeepCopyInto(out *OIDCGroupsEndpoint) {
+	*out = *in
+	if in.ItemsField != nil {
+		in, out := &in.ItemsField, &out.ItemsField
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCGroupsEndpoint.
+func (in *OIDCGroupsEndpoint) DeepCopy() *OIDCGroupsEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCGroupsEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCIdentityProvider) DeepCopyInto(out *OIDCIdentityProvider) {
 	*out = *in
@@ -813,6 +1342,162 @@ func (in *Parameter) DeepCopy() *Parameter {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLClaims) DeepCopyInto(out *SAMLClaims) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLClaims.
+func (in *SAMLClaims) DeepCopy() *SAMLClaims {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLClaims)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLIdentityProvider) DeepCopyInto(out *SAMLIdentityProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLIdentityProvider.
+func (in *SAMLIdentityProvider) DeepCopy() *SAMLIdentityProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLIdentityProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SAMLIdentityProvider) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLIdentityProviderList) DeepCopyInto(out *SAMLIdentityProviderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SAMLIdentityProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLIdentityProviderList.
+func (in *SAMLIdentityProviderList) DeepCopy() *SAMLIdentityProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLIdentityProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SAMLIdentityProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLIdentityProviderMetadata) DeepCopyInto(out *SAMLIdentityProviderMetadata) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(TLSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLIdentityProviderMetadata.
+func (in *SAMLIdentityProviderMetadata) DeepCopy() *SAMLIdentityProviderMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLIdentityProviderMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLIdentityProviderSpec) DeepCopyInto(out *SAMLIdentityProviderSpec) {
+	*out = *in
+	in.IdentityProviderMetadata.DeepCopyInto(&out.IdentityProviderMetadata)
+	out.ServiceProvider = in.ServiceProvider
+	out.Claims = in.Claims
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLIdentityProviderSpec.
+func (in *SAMLIdentityProviderSpec) DeepCopy() *SAMLIdentityProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLIdentityProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLIdentityProviderStatus) DeepCopyInto(out *SAMLIdentityProviderStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLIdentityProviderStatus.
+func (in *SAMLIdentityProviderStatus) DeepCopy() *SAMLIdentityProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLIdentityProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLServiceProvider) DeepCopyInto(out *SAMLServiceProvider) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLServiceProvider.
+func (in *SAMLServiceProvider) DeepCopy() *SAMLServiceProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLServiceProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSSpec) DeepCopyInto(out *TLSSpec) {
 	*out = *in
@@ -18,7 +18,10 @@ type IDPV1alpha1Interface interface {
 	ActiveDirectoryIdentityProvidersGetter
 	GitHubIdentityProvidersGetter
 	LDAPIdentityProvidersGetter
+	OAuth2IdentityProvidersGetter
+	LocalUserIdentityProvidersGetter
 	OIDCIdentityProvidersGetter
+	SAMLIdentityProvidersGetter
 }
 
 // IDPV1alpha1Client is used to interact with features provided by the idp.supervisor.pinniped.dev group.
@@ -38,10 +41,22 @@ func (c *IDPV1alpha1Client) LDAPIdentityProviders(namespace string) LDAPIdentity
 	return newLDAPIdentityProviders(c, namespace)
 }
 
+func (c *IDPV1alpha1Client) OAuth2IdentityProviders(namespace string) OAuth2IdentityProviderInterface {
+	return newOAuth2IdentityProviders(c, namespace)
+}
+
+func (c *IDPV1alpha1Client) LocalUserIdentityProviders(namespace string) LocalUserIdentityProviderInterface {
+	return newLocalUserIdentityProviders(c, namespace)
+}
+
 func (c *IDPV1alpha1Client) OIDCIdentityProviders(namespace string) OIDCIdentityProviderInterface {
 	return newOIDCIdentityProviders(c, namespace)
 }
 
+func (c *IDPV1alpha1Client) SAMLIdentityProviders(namespace string) SAMLIdentityProviderInterface {
+	return newSAMLIdentityProviders(c, namespace)
+}
+
 // NewForConfig creates a new IDPV1alpha1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -71,8 +71,8 @@ type FederationDomainTransformsConstant struct {
 // FederationDomainTransformsExpression defines a transform expression.
 type FederationDomainTransformsExpression struct {
 	// Type determines the type of the expression. It must be one of the supported types.
-	// Allowed values are "policy/v1", "username/v1", or "groups/v1".
-	// +kubebuilder:validation:Enum=policy/v1;username/v1;groups/v1
+	// Allowed values are "policy/v1", "username/v1", "groups/v1", or "claims/v1".
+	// +kubebuilder:validation:Enum=policy/v1;username/v1;groups/v1;claims/v1
 	Type string `json:"type"`
 
 	// Expression is a CEL expression that will be evaluated based on the Type during an authentication.
@@ -95,8 +95,18 @@ type FederationDomainTransformsExample struct {
 	// +optional
 	Groups []string `json:"groups,omitempty"`
 
+	// ACR is the input Authentication Context Class Reference, which is available to the expressions
+	// as the `acr` variable.
+	// +optional
+	ACR string `json:"acr,omitempty"`
+
+	// AMR is the input list of Authentication Methods References, which is available to the expressions
+	// as the `amr` variable.
+	// +optional
+	AMR []string `json:"amr,omitempty"`
+
 	// Expects is the expected output of the entire sequence of transforms when they are run against the
-	// input Username and Groups.
+	// input Username, Groups, ACR, and AMR.
 	Expects FederationDomainTransformsExampleExpects `json:"expects"`
 }
 
@@ -110,6 +120,12 @@ type FederationDomainTransformsExampleExpects struct {
 	// +optional
 	Groups []string `json:"groups,omitempty"`
 
+	// AdditionalClaims are the expected additional claims after the transformations have been applied, keyed by
+	// claim name. Each value is the JSON representation of the expected value of the claim, e.g. `"some-string"`,
+	// `true`, or `["a", "b"]`. When empty, it is expected that the transformations would not return any additional claims.
+	// +optional
+	AdditionalClaims map[string]string `json:"additionalClaims,omitempty"`
+
 	// Rejected is a boolean that indicates whether authentication is expected to be rejected by a policy expression
 	// after the transformations have been applied. True means that it is expected that the authentication would be
 	// rejected. The default value of false means that it is expected that the authentication would not be rejected
@@ -126,8 +142,59 @@ type FederationDomainTransformsExampleExpects struct {
 	Message string `json:"message,omitempty"`
 }
 
+// FederationDomainTokenExchangeTransforms defines additional identity transformations for an identity provider's usage
+// on a FederationDomain, which are only applied when a client exchanges its access token for a cluster-scoped ID token.
+type FederationDomainTokenExchangeTransforms struct {
+	// Audiences selects the token exchanges to which these transformations apply by their requested audience, which
+	// is the audience of the cluster for which the client would like an ID token. When empty, these transformations
+	// apply regardless of the requested audience.
+	// +optional
+	Audiences []string `json:"audiences,omitempty"`
+
+	// ClientNames selects the token exchanges to which these transformations apply by the client which is performing
+	// the token exchange, which is either "pinniped-cli" or the name of an OIDCClient. When empty, these
+	// transformations apply regardless of the client.
+	// +optional
+	ClientNames []string `json:"clientNames,omitempty"`
+
+	// Expressions are a list of transforms and policies to be executed in the order given during every token exchange
+	// which is selected by Audiences and ClientNames. They are written in the same way as the expressions of the
+	// identity provider's transforms, and may use the same constants. The `username` and `groups` variables start
+	// as the results of the identity provider's transforms from the user's most recent authentication or session
+	// refresh. When the groups scope was not granted to the client, then `groups` is an empty list and any changes
+	// to it are ignored. The `request.audience` variable contains the requested audience of the token exchange.
+	// When a policy/v1 expression returns false, the token exchange is rejected, but the user's session is unaffected.
+	// +kubebuilder:validation:MinItems=1
+	Expressions []FederationDomainTransformsExpression `json:"expressions"`
+
+	// Examples can optionally be used to ensure that these transformation expressions are working as expected.
+	// They work like the examples of the identity provider's transforms, except that the input username and groups
+	// of each example are the results of the identity provider's transforms.
+	// +optional
+	Examples []FederationDomainTransformsExample `json:"examples,omitempty"`
+}
+
+// FederationDomainTransformsLibraryRef is a reference to an IdentityTransformLibrary.
+type FederationDomainTransformsLibraryRef struct {
+	// Name is the name of an IdentityTransformLibrary in the same namespace as this FederationDomain.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
 // FederationDomainTransforms defines identity transformations for an identity provider's usage on a FederationDomain.
 type FederationDomainTransforms struct {
+	// Libraries is an optional list of references to IdentityTransformLibrary resources, which allows many
+	// FederationDomains to share the same transformations. The expressions of the libraries are executed in the order
+	// given, before the Expressions below. The constants of each library are also made available to the Expressions
+	// below and to the TokenExchange pipelines, along with the Constants below. When more than one library or the
+	// Constants below define a constant with the same name, the one which is listed last is used.
+	// The Examples below are run through the expressions of the libraries followed by the Expressions below.
+	// If any library cannot be found, or has an invalid expression or a failing example, then this identity provider
+	// will not be available for use within this FederationDomain, and the error(s) will be added to the
+	// FederationDomain status.
+	// +optional
+	Libraries []FederationDomainTransformsLibraryRef `json:"libraries,omitempty"`
+
 	// Constants defines constant variables and their values which will be made available to the transform expressions.
 	// +patchMergeKey=name
 	// +patchStrategy=merge
@@ -145,10 +212,19 @@ type FederationDomainTransforms struct {
 	// The username and groups extracted from the identity provider, and the constants defined in this CR, are
 	// available as variables in all expressions. The username is provided via a variable called `username` and
 	// the list of group names is provided via a variable called `groups` (which may be an empty list).
+	// When the identity provider reports how the user authenticated, the Authentication Context Class Reference
+	// is provided via a string variable called `acr`, and the list of Authentication Methods References is
+	// provided via a variable called `amr`. These are currently only reported by OIDC identity providers which
+	// include the "acr" and "amr" claims in their ID tokens. Otherwise, `acr` is the empty string and `amr` is
+	// an empty list.
+	// The raw identity data read from the identity provider, before any transformations, is provided via a
+	// read-only map variable called `upstream`, e.g. the claims of an OIDC ID token or the DN and attributes
+	// of an LDAP user entry. Information about the client's request is provided via a variable called `request`,
+	// which has the fields `request.clientID`, `request.scopes`, and `request.audience`.
 	// Each user-provided constants is provided via a variable named `strConst.varName` for string constants
 	// and `strListConst.varName` for string list constants.
 	//
-	// The only allowed types for expressions are currently policy/v1, username/v1, and groups/v1.
+	// The only allowed types for expressions are currently policy/v1, username/v1, groups/v1, and claims/v1.
 	// Each policy/v1 must return a boolean, and when it returns false, no more expressions from the list are evaluated
 	// and the authentication attempt is rejected.
 	// Transformations of type policy/v1 do not return usernames or group names, and therefore cannot change the
@@ -158,6 +234,11 @@ type FederationDomainTransforms struct {
 	// Each groups/v1 transform must return the new groups list (list of strings), which can be the same as the old
 	// groups list.
 	// Transformations of type groups/v1 do not return usernames, and therefore cannot change the usernames.
+	// Each claims/v1 transform must return a map of additional claims (a map with string keys), which will be added
+	// to the "additionalClaims" claim of the ID tokens, replacing any additional claims of the same names which were mapped
+	// from the identity provider or returned by previous claims/v1 transforms.
+	// Transformations of type claims/v1 do not return usernames or group names, and therefore cannot change the
+	// username or group names.
 	// After each expression, the new (potentially changed) username or groups get passed to the following expression.
 	//
 	// Any compilation or static type-checking failure of any expression will cause an error status on the FederationDomain.
@@ -176,6 +257,17 @@ type FederationDomainTransforms struct {
 	// expressions, and also act as living documentation for other administrators to better understand the expressions.
 	// +optional
 	Examples []FederationDomainTransformsExample `json:"examples,omitempty"`
+
+	// TokenExchange is an optional list of additional transformation pipelines which are only applied when a client
+	// exchanges its access token for a cluster-scoped ID token, after the Expressions above. This can give each
+	// cluster or client a different view of the user's identity, e.g. to only allow certain groups into the ID tokens
+	// for production clusters. Every pipeline from this list which is selected by the token exchange is executed in
+	// the order given, and each receives the username and groups which resulted from the previous pipeline. The results
+	// are only used for the cluster-scoped ID token, and do not change the user's session.
+	// The token exchanges of sessions which were not started by an identity provider, e.g. sessions of OIDCClients
+	// which used the client_credentials grant, are rejected when any pipeline of any identity provider selects them.
+	// +optional
+	TokenExchange []FederationDomainTokenExchangeTransforms `json:"tokenExchange,omitempty"`
 }
 
 // FederationDomainIdentityProvider describes how an identity provider is made available in this FederationDomain.
@@ -192,8 +284,8 @@ type FederationDomainIdentityProvider struct {
 	// LDAPIdentityProvider, ActiveDirectoryIdentityProvider.
 	ObjectRef corev1.TypedLocalObjectReference `json:"objectRef"`
 
-	// Transforms is an optional way to specify transformations to be applied during user authentication and
-	// session refresh.
+	// Transforms is an optional way to specify transformations to be applied during user authentication,
+	// session refresh, and token exchange.
 	// +optional
 	Transforms FederationDomainTransforms `json:"transforms,omitempty"`
 }
@@ -0,0 +1,129 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type LocalUserIdentityProviderPhase string
+
+const (
+	// LocalUserPhasePending is the default phase for newly-created LocalUserIdentityProvider resources.
+	LocalUserPhasePending LocalUserIdentityProviderPhase = "Pending"
+
+	// LocalUserPhaseReady is the phase for a LocalUserIdentityProvider resource in a healthy state.
+	LocalUserPhaseReady LocalUserIdentityProviderPhase = "Ready"
+
+	// LocalUserPhaseError is the phase for a LocalUserIdentityProvider in an unhealthy state.
+	LocalUserPhaseError LocalUserIdentityProviderPhase = "Error"
+)
+
+// LocalUserIdentityProviderStatus is the status of a local user identity provider.
+type LocalUserIdentityProviderStatus struct {
+	// Phase summarizes the overall status of the LocalUserIdentityProvider.
+	//
+	// +kubebuilder:default=Pending
+	// +kubebuilder:validation:Enum=Pending;Ready;Error
+	Phase LocalUserIdentityProviderPhase `json:"phase,omitempty"`
+
+	// Conditions represents the observations of an identity provider's current state.
+	//
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// LocalUserUsers describes where the users of a LocalUserIdentityProvider are defined.
+type LocalUserUsers struct {
+	// SecretSelector selects the Secrets which each define one user. Only Secrets in the same namespace as the
+	// LocalUserIdentityProvider and of type "secrets.pinniped.dev/local-user" are selected.
+	// An empty selector selects all such Secrets in the namespace.
+	//
+	// Each Secret must have the following keys:
+	// - "username": the username of the user, which must be unique among the selected Secrets.
+	// - "passwordHash": the hash of the user's password, either as a bcrypt hash (e.g. "$2y$10$...")
+	//   or as an argon2id hash in the PHC string format (e.g. "$argon2id$v=19$m=65536,t=3,p=4$...").
+	//
+	// Each Secret may also have the following keys:
+	// - "groups": the names of the groups to which the user belongs, one per line.
+	// - "totpSecret": a base32-encoded RFC 6238 time-based one-time password secret, e.g. as shown by an
+	//   authenticator app enrollment. When present, the user must type the current 6-digit code from their
+	//   authenticator app immediately after their password whenever they log in.
+	//
+	// +optional
+	SecretSelector metav1.LabelSelector `json:"secretSelector,omitempty"`
+}
+
+// LocalUserLockout configures how users are locked out after repeated failed login attempts.
+type LocalUserLockout struct {
+	// MaxFailedAttempts is the number of consecutive failed login attempts after which a user will be locked out.
+	// A successful login resets the count. When not specified, users are locked out after 5 consecutive failed
+	// login attempts. Set to 0 to never lock out users.
+	//
+	// Failed login attempts are counted in the memory of each pod of the Supervisor. The counts are not shared between
+	// pods, and they are forgotten when a pod restarts. When the Supervisor has N pods, a user may therefore make up to
+	// N times this many failed login attempts before every pod has locked them out, and more after a pod restarts.
+	// Lockout limits online password guessing, but it is not a substitute for strong passwords.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxFailedAttempts *int32 `json:"maxFailedAttempts,omitempty"`
+
+	// DurationSeconds is the number of seconds for which a user will be locked out. While locked out, all login
+	// attempts by that user will fail, even when the correct password is provided. When not specified, users
+	// are locked out for 900 seconds (15 minutes). Each pod of the Supervisor enforces its own lockouts.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	DurationSeconds *int32 `json:"durationSeconds,omitempty"`
+}
+
+// LocalUserIdentityProviderSpec is the spec for configuring a local user identity provider.
+type LocalUserIdentityProviderSpec struct {
+	// Users describes where the users of this identity provider are defined.
+	Users LocalUserUsers `json:"users"`
+
+	// Lockout configures how users are locked out after repeated failed login attempts.
+	//
+	// +optional
+	Lockout LocalUserLockout `json:"lockout,omitempty"`
+}
+
+// LocalUserIdentityProvider describes a directory of users which is stored in Secrets in the Supervisor's namespace,
+// for clusters which do not have an external identity provider. Each user has a username, a password, optional
+// group memberships, and optional time-based one-time password (TOTP) multi-factor authentication.
+//
+// Users may log in using the pinniped-cli client by typing their username and password into the CLI, or using a
+// web browser with the Supervisor's login form. Clients configured as OIDCClients may also use the web browser
+// login form.
+//
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped;pinniped-idp;pinniped-idps
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type LocalUserIdentityProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec for configuring the identity provider.
+	Spec LocalUserIdentityProviderSpec `json:"spec"`
+
+	// Status of the identity provider.
+	Status LocalUserIdentityProviderStatus `json:"status,omitempty"`
+}
+
+// LocalUserIdentityProviderList lists LocalUserIdentityProvider objects.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type LocalUserIdentityProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []LocalUserIdentityProvider `json:"items"`
+}
@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -16,9 +16,13 @@ const (
 	IDPTypeLDAP            IDPType = "ldap"
 	IDPTypeActiveDirectory IDPType = "activedirectory"
 	IDPTypeGitHub          IDPType = "github"
+	IDPTypeSAML            IDPType = "saml"
+	IDPTypeOAuth2          IDPType = "oauth2"
+	IDPTypeLocalUser       IDPType = "localuser"
 
 	IDPFlowCLIPassword     IDPFlow = "cli_password"
 	IDPFlowBrowserAuthcode IDPFlow = "browser_authcode"
+	IDPFlowDeviceCode      IDPFlow = "device_code"
 )
 
 // Equals is a convenience function for comparing an IDPType to a string.
@@ -65,6 +69,13 @@ type PinnipedIDP struct {
 	Name  string    `json:"name"`
 	Type  IDPType   `json:"type"`
 	Flows []IDPFlow `json:"flows,omitempty"`
+
+	// Unavailable is a hint that the Supervisor has recently been unable to reach this identity provider,
+	// so logins using it are currently expected to fail. It is omitted when the identity provider is believed
+	// to be available, and it is never returned by older versions of the Supervisor.
+	// SAML and local user identity providers are not probed, because the Supervisor does not contact them
+	// over the network during logins, so they are never marked unavailable.
+	Unavailable bool `json:"unavailable,omitempty"`
 }
 
 // PinnipedSupportedIDPType describes a single identity provider type.
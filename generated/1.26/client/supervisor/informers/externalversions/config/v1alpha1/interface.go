@@ -13,6 +13,8 @@ import (
 type Interface interface {
 	// FederationDomains returns a FederationDomainInformer.
 	FederationDomains() FederationDomainInformer
+	// IdentityTransformLibraries returns a IdentityTransformLibraryInformer.
+	IdentityTransformLibraries() IdentityTransformLibraryInformer
 	// OIDCClients returns a OIDCClientInformer.
 	OIDCClients() OIDCClientInformer
 }
@@ -33,6 +35,11 @@ func (v *version) FederationDomains() FederationDomainInformer {
 	return &federationDomainInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// IdentityTransformLibraries returns a IdentityTransformLibraryInformer.
+func (v *version) IdentityTransformLibraries() IdentityTransformLibraryInformer {
+	return &identityTransformLibraryInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // OIDCClients returns a OIDCClientInformer.
 func (v *version) OIDCClients() OIDCClientInformer {
 	return &oIDCClientInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
@@ -0,0 +1,59 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// IdentityTransformLibrarySpec is a reusable list of identity transformations, along with the constants which they
+// use and the examples which test them.
+type IdentityTransformLibrarySpec struct {
+	// Constants defines constant variables and their values which will be made available to the expressions of this
+	// library, and to the expressions of every FederationDomain identity provider which uses this library.
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Constants []FederationDomainTransformsConstant `json:"constants,omitempty"`
+
+	// Expressions are an optional list of transforms and policies to be executed in the order given. When a
+	// FederationDomain identity provider uses this library, these expressions are executed before the expressions of
+	// any libraries which are listed after this library, and before the identity provider's own expressions.
+	// These expressions can only use the constants of this library. They are written in the same way as the
+	// expressions of a FederationDomain identity provider. See the documentation of
+	// FederationDomainTransforms.Expressions for details.
+	// +optional
+	Expressions []FederationDomainTransformsExpression `json:"expressions,omitempty"`
+
+	// Examples can optionally be used to ensure that the expressions of this library are working as expected.
+	// Each example is run through only the expressions of this library. If any example in this list fails, then
+	// the identity providers which use this library will not be available for use within their FederationDomains,
+	// and the error(s) will be added to the status of those FederationDomains.
+	// +optional
+	Examples []FederationDomainTransformsExample `json:"examples,omitempty"`
+}
+
+// IdentityTransformLibrary describes a reusable library of identity transformations. The identity providers of
+// the FederationDomains in the same namespace may use the library by name.
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type IdentityTransformLibrary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec of the identity transform library.
+	Spec IdentityTransformLibrarySpec `json:"spec"`
+}
+
+// List of IdentityTransformLibrary objects.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type IdentityTransformLibraryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []IdentityTransformLibrary `json:"items"`
+}
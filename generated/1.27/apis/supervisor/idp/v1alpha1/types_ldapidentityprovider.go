@@ -1,4 +1,4 @@
-// Copyright 2021-2023 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -62,6 +62,23 @@ type LDAPIdentityProviderUserSearchAttributes struct {
 	// server in the user's entry. Distinguished names can be used by specifying lower-case "dn".
 	// +kubebuilder:validation:MinLength=1
 	UID string `json:"uid,omitempty"`
+
+	// AdditionalClaimMappings allows for additional arbitrary LDAP attribute values to be mapped into the
+	// "additionalClaims" claim of the ID tokens generated by the Supervisor. This should be specified as a map of
+	// new claim names as the keys, and LDAP attribute names as the values. These new claim names will be nested
+	// under the top-level "additionalClaims" claim in ID tokens generated by the Supervisor when this
+	// LDAPIdentityProvider was used for user authentication. These claims will be made available to all clients.
+	// The values of the attributes are read from the user's entry during login, and read again during each
+	// session refresh. An attribute with a single value becomes a string claim, and an attribute with multiple
+	// values becomes a claim whose value is a list of strings. Attributes which are not present on the user's
+	// entry are omitted.
+	// The attribute names are case-sensitive and must match the case of the attribute names returned by the
+	// LDAP server in the user's entry. The distinguished name can be used by specifying lower-case "dn".
+	// This feature is not required to use the Supervisor to provide authentication for Kubernetes clusters, but can be
+	// used when using the Supervisor for other authentication purposes. When this map is empty or the attributes
+	// are not available, the "additionalClaims" claim will be excluded from the ID tokens generated by the Supervisor.
+	// +optional
+	AdditionalClaimMappings map[string]string `json:"additionalClaimMappings,omitempty"`
 }
 
 type LDAPIdentityProviderGroupSearchAttributes struct {
@@ -74,6 +91,57 @@ type LDAPIdentityProviderGroupSearchAttributes struct {
 	GroupName string `json:"groupName,omitempty"`
 }
 
+// LDAPGroupSearchStrategyType is the strategy used to find the groups to which a user belongs.
+// +kubebuilder:validation:Enum=search;recursiveSearch;memberOfAttribute
+type LDAPGroupSearchStrategyType string
+
+const (
+	// LDAPGroupSearchStrategySearch performs one group search to find the groups to which the user directly belongs.
+	LDAPGroupSearchStrategySearch LDAPGroupSearchStrategyType = "search"
+
+	// LDAPGroupSearchStrategyRecursiveSearch repeats the group search for each group found, to also find the
+	// groups to which the user indirectly belongs through nested groups.
+	LDAPGroupSearchStrategyRecursiveSearch LDAPGroupSearchStrategyType = "recursiveSearch"
+
+	// LDAPGroupSearchStrategyMemberOfAttribute reads the DNs of the user's groups from an attribute of the user's
+	// entry instead of performing a group search.
+	LDAPGroupSearchStrategyMemberOfAttribute LDAPGroupSearchStrategyType = "memberOfAttribute"
+)
+
+type LDAPIdentityProviderGroupSearchStrategy struct {
+	// Type determines how the groups of the user are found.
+	// "search" performs one group search using the Filter, and finds the groups to which the user directly belongs.
+	// "recursiveSearch" performs the same group search, and then finds nested groups by repeating the group search
+	// for each group found, with the "{}" placeholder(s) in the Filter replaced by the dn of that group, up to MaxDepth
+	// levels of nesting.
+	// "memberOfAttribute" does not perform a group search. Instead, it reads the dn of each group from the
+	// MemberOfAttribute of the user's entry, and finds nested groups by reading the MemberOfAttribute of each group's
+	// entry, up to MaxDepth levels of nesting. When using "memberOfAttribute", the Filter and UserAttributeForFilter
+	// are ignored, and only groups whose dn is within the Base are included.
+	// Each group is only followed once, so groups which are nested within themselves do not cause an endless search.
+	// Optional. When not specified, the default will act as if "search" were specified.
+	// +kubebuilder:default=search
+	// +optional
+	Type LDAPGroupSearchStrategyType `json:"type,omitempty"`
+
+	// MemberOfAttribute specifies the name of the attribute of user and group entries which lists the dn of each group
+	// to which the entry belongs. Only used when Type is "memberOfAttribute".
+	// The value of this field is case-sensitive and must match the case of the attribute name returned by the LDAP
+	// server. E.g. "memberOf" or "isMemberOf".
+	// Optional. When not specified, the default will act as if "memberOf" were specified.
+	// +optional
+	MemberOfAttribute string `json:"memberOfAttribute,omitempty"`
+
+	// MaxDepth is the maximum number of levels of nested groups to find when Type is "recursiveSearch" or
+	// "memberOfAttribute". A MaxDepth of 1 only finds the groups to which the user directly belongs.
+	// Groups which are nested more deeply are ignored.
+	// Optional. When not specified, the default will act as if 10 were specified.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxDepth *int32 `json:"maxDepth,omitempty"`
+}
+
 type LDAPIdentityProviderUserSearch struct {
 	// Base is the dn (distinguished name) that should be used as the search base when searching for users.
 	// E.g. "ou=users,dc=example,dc=com".
@@ -101,7 +169,7 @@ type LDAPIdentityProviderGroupSearch struct {
 	// Base is the dn (distinguished name) that should be used as the search base when searching for groups. E.g.
 	// "ou=groups,dc=example,dc=com". When not specified, no group search will be performed and
 	// authenticated users will not belong to any groups from the LDAP provider. Also, when not specified,
-	// the values of Filter, UserAttributeForFilter, Attributes, and SkipGroupRefresh are ignored.
+	// the values of Filter, UserAttributeForFilter, Attributes, Strategy, and SkipGroupRefresh are ignored.
 	// +optional
 	Base string `json:"base,omitempty"`
 
@@ -131,6 +199,11 @@ type LDAPIdentityProviderGroupSearch struct {
 	// +optional
 	Attributes LDAPIdentityProviderGroupSearchAttributes `json:"attributes,omitempty"`
 
+	// Strategy specifies how the groups of the user are found, including whether nested groups are found.
+	// The same strategy is used to find the user's groups during each refresh, unless SkipGroupRefresh is true.
+	// +optional
+	Strategy LDAPIdentityProviderGroupSearchStrategy `json:"strategy,omitempty"`
+
 	// The user's group membership is refreshed as they interact with the supervisor
 	// to obtain new credentials (as their old credentials expire).  This allows group
 	// membership changes to be quickly reflected into Kubernetes clusters.  Since
@@ -157,6 +230,35 @@ type LDAPIdentityProviderGroupSearch struct {
 	SkipGroupRefresh bool `json:"skipGroupRefresh,omitempty"`
 }
 
+type LDAPIdentityProviderFailover struct {
+	// AdditionalHosts are the hostnames of other LDAP servers which serve the same directory as the Host,
+	// e.g. "ldap2.example.com:636". When the Host cannot be reached, these hosts are tried in the order listed.
+	// The TLS, Bind, UserSearch, and GroupSearch settings are used for all hosts. The Host is always used to
+	// identify the users of this identity provider, so the subject of a user does not change when another
+	// host is used.
+	// +listType=set
+	// +optional
+	AdditionalHosts []string `json:"additionalHosts,omitempty"`
+
+	// SRVRecord is the name of a DNS SRV record which lists more LDAP servers which serve the same directory as the
+	// Host, e.g. "_ldap._tcp.example.com". The servers from the SRV record are tried after the Host and the
+	// AdditionalHosts, ordered by their priority and weight. The SRV record is looked up again periodically.
+	// +optional
+	SRVRecord string `json:"srvRecord,omitempty"`
+}
+
+type LDAPIdentityProviderConnectionPool struct {
+	// MaxIdleConnections is the maximum number of idle connections to the LDAP server which are kept open for reuse by
+	// logins, session refreshes, and connection tests. Idle connections are bound as the bind account, and are bound
+	// again as the bind account each time they are reused. Idle connections are closed after five minutes.
+	// Optional. When not specified or 0, a new connection is opened for each login and session refresh, and closed
+	// afterwards.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxIdleConnections int32 `json:"maxIdleConnections,omitempty"`
+}
+
 // Spec for configuring an LDAP identity provider.
 type LDAPIdentityProviderSpec struct {
 	// Host is the hostname of this LDAP identity provider, i.e., where to connect. For example: ldap.example.com:636.
@@ -175,6 +277,14 @@ type LDAPIdentityProviderSpec struct {
 
 	// GroupSearch contains the configuration for searching for a user's group membership in the LDAP provider.
 	GroupSearch LDAPIdentityProviderGroupSearch `json:"groupSearch,omitempty"`
+
+	// Failover contains the configuration for using other LDAP servers when the Host cannot be reached.
+	// +optional
+	Failover LDAPIdentityProviderFailover `json:"failover,omitempty"`
+
+	// ConnectionPool contains the configuration for reusing connections to the LDAP server.
+	// +optional
+	ConnectionPool LDAPIdentityProviderConnectionPool `json:"connectionPool,omitempty"`
 }
 
 // LDAPIdentityProvider describes the configuration of an upstream Lightweight Directory Access
@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -21,12 +21,29 @@ const (
 // +kubebuilder:validation:Pattern=`^https://.+|^http://(127\.0\.0\.1|\[::1\])(:\d+)?/`
 type RedirectURI string
 
-// +kubebuilder:validation:Enum="authorization_code";"refresh_token";"urn:ietf:params:oauth:grant-type:token-exchange"
+// +kubebuilder:validation:Enum="authorization_code";"refresh_token";"urn:ietf:params:oauth:grant-type:token-exchange";"urn:ietf:params:oauth:grant-type:device_code";"client_credentials"
 type GrantType string
 
-// +kubebuilder:validation:Enum="openid";"offline_access";"username";"groups";"pinniped:request-audience"
+// +kubebuilder:validation:Enum="openid";"offline_access";"username";"groups";"pinniped:request-audience";"pinniped:introspect"
 type Scope string
 
+// +kubebuilder:validation:Enum="client_secret_basic";"private_key_jwt";"tls_client_auth"
+type ClientAuthenticationMethod string
+
+const (
+	// ClientAuthenticationMethodClientSecretBasic is the RFC6749 client authentication method which uses
+	// HTTP basic authentication with a client secret.
+	ClientAuthenticationMethodClientSecretBasic ClientAuthenticationMethod = "client_secret_basic"
+
+	// ClientAuthenticationMethodPrivateKeyJWT is the client authentication method which uses a JWT
+	// signed by the client's private key, as described by RFC7523 and OpenID Connect Core section 9.
+	ClientAuthenticationMethodPrivateKeyJWT ClientAuthenticationMethod = "private_key_jwt"
+
+	// ClientAuthenticationMethodTLSClientAuth is the RFC8705 client authentication method which uses
+	// mutual TLS with a client certificate issued by a trusted certificate authority.
+	ClientAuthenticationMethodTLSClientAuth ClientAuthenticationMethod = "tls_client_auth"
+)
+
 // OIDCClientSpec is a struct that describes an OIDCClient.
 type OIDCClientSpec struct {
 	// allowedRedirectURIs is a list of the allowed redirect_uri param values that should be accepted during OIDC flows with this
@@ -48,6 +65,13 @@ type OIDCClientSpec struct {
 	// - urn:ietf:params:oauth:grant-type:token-exchange: allows the client to perform RFC8693 token exchange,
 	//   which is a step in the process to be able to get a cluster credential for the user.
 	//   This grant must be listed if allowedScopes lists pinniped:request-audience.
+	// - urn:ietf:params:oauth:grant-type:device_code: allows the client to perform the RFC8628 device authorization
+	//   grant flow, i.e. allows a client on a device without a web browser to authenticate users, who log in using a web
+	//   browser on another device.
+	// - client_credentials: allows the client to perform the RFC6749 client credentials grant flow, i.e. allows the
+	//   client to get tokens for itself without any end user being involved, e.g. for automation. The tokens will
+	//   represent the identity described by serviceIdentity, which must be configured when this grant is listed.
+	//   Refresh tokens are never issued for this grant.
 	// +listType=set
 	// +kubebuilder:validation:MinItems=1
 	AllowedGrantTypes []GrantType `json:"allowedGrantTypes"`
@@ -68,6 +92,10 @@ type OIDCClientSpec struct {
 	// - groups: The client is allowed to request that ID tokens contain the user's group membership,
 	//   if their group membership is discoverable by the Supervisor.
 	//   Without the groups scope being requested and allowed, the ID token will not contain groups.
+	// - pinniped:introspect: The client is allowed to use the token introspection endpoint to validate access tokens
+	//   and refresh tokens issued by the Supervisor, e.g. so a resource server can accept access tokens. This scope
+	//   does not need to be requested during OIDC flows.
+	//   The token introspection endpoint only supports the client_secret_basic client authentication method.
 	// +listType=set
 	// +kubebuilder:validation:MinItems=1
 	AllowedScopes []Scope `json:"allowedScopes"`
@@ -75,6 +103,108 @@ type OIDCClientSpec struct {
 	// tokenLifetimes are the optional overrides of token lifetimes for an OIDCClient.
 	// +optional
 	TokenLifetimes OIDCClientTokenLifetimes `json:"tokenLifetimes,omitempty"`
+
+	// serviceIdentity is the identity which this client acts as when it uses the client_credentials grant.
+	// Must be configured when allowedGrantTypes lists client_credentials, and must not be configured otherwise.
+	// The username and groups will appear in the tokens issued to this client by the client_credentials grant,
+	// subject to the username and groups scopes being requested, and will be used by clusters when those tokens
+	// are exchanged for cluster-scoped ID tokens using RFC8693 token exchange.
+	// Identity transformations and policies of the FederationDomain are not applied to this identity.
+	// +optional
+	ServiceIdentity *OIDCClientServiceIdentity `json:"serviceIdentity,omitempty"`
+
+	// clientAuthentication configures how this client authenticates itself to the Supervisor, e.g. when it calls
+	// the token endpoint. When null, the client must authenticate using one of its client secrets with the
+	// client_secret_basic method.
+	// +optional
+	ClientAuthentication *OIDCClientAuthentication `json:"clientAuthentication,omitempty"`
+
+	// requirePushedAuthorizationRequests, when true, requires this client to use the pushed authorization request
+	// endpoint described by RFC9126 to start each authorization code flow. The authorization endpoint will reject
+	// requests from this client which do not use a request_uri returned by the pushed authorization request endpoint.
+	// This keeps the parameters of the authorization request out of the end user's browser.
+	// Defaults to false.
+	// +optional
+	RequirePushedAuthorizationRequests bool `json:"requirePushedAuthorizationRequests,omitempty"`
+}
+
+// OIDCClientAuthentication describes how an OIDCClient authenticates itself to the Supervisor.
+type OIDCClientAuthentication struct {
+	// method is the client authentication method which this client must use.
+	//
+	// Must be one of the following values:
+	// - client_secret_basic: the client uses HTTP basic authentication with one of its client secrets, as
+	//   described by RFC6749 section 2.3.1. This is the default.
+	// - private_key_jwt: the client authenticates using a JWT which it signs with its private key, as described
+	//   by RFC7523 and OpenID Connect Core section 9. Client secrets are not required for this method.
+	//   privateKeyJWT must be configured when using this method.
+	// - tls_client_auth: the client authenticates using a client certificate during the TLS handshake, as
+	//   described by RFC8705 section 2.1. Client secrets are not required for this method. This method requires
+	//   that the Supervisor's mTLS listener is enabled, and that TLS connections to it are not terminated by a load
+	//   balancer or ingress in front of the Supervisor. Clients must send their requests to the mtls_endpoint_aliases
+	//   from the discovery document. tlsClientAuth must be configured when using this method.
+	// +kubebuilder:default=client_secret_basic
+	// +optional
+	Method ClientAuthenticationMethod `json:"method,omitempty"`
+
+	// privateKeyJWT configures the private_key_jwt client authentication method.
+	// Must be configured when method is private_key_jwt, and must not be configured otherwise.
+	// +optional
+	PrivateKeyJWT *OIDCClientPrivateKeyJWT `json:"privateKeyJWT,omitempty"`
+
+	// tlsClientAuth configures the tls_client_auth client authentication method.
+	// Must be configured when method is tls_client_auth, and must not be configured otherwise.
+	// +optional
+	TLSClientAuth *OIDCClientTLSClientAuth `json:"tlsClientAuth,omitempty"`
+}
+
+// OIDCClientPrivateKeyJWT describes the public keys which are used to verify the JWTs that an OIDCClient
+// uses for the private_key_jwt client authentication method. Exactly one of jwks or jwksURI must be configured.
+type OIDCClientPrivateKeyJWT struct {
+	// jwks is a JSON Web Key Set (RFC7517) document containing the client's public keys. Only the keys which
+	// have "use" set to "sig" will be used.
+	// +optional
+	JWKS string `json:"jwks,omitempty"`
+
+	// jwksURI is the URL of the client's JSON Web Key Set (RFC7517) document, which will be fetched and cached
+	// by the Supervisor. Only the keys which have "use" set to "sig" will be used. The server's certificate must
+	// be trusted by the Supervisor's system trust store.
+	// +kubebuilder:validation:Pattern=`^https://`
+	// +optional
+	JWKSURI string `json:"jwksURI,omitempty"`
+
+	// signingAlgorithm is the JWS algorithm which the client must use to sign its JWTs.
+	// +kubebuilder:validation:Enum=RS256;RS384;RS512;PS256;PS384;PS512;ES256;ES384;ES512
+	// +kubebuilder:default=RS256
+	// +optional
+	SigningAlgorithm string `json:"signingAlgorithm,omitempty"`
+}
+
+// OIDCClientTLSClientAuth describes the client certificates which an OIDCClient may use for the
+// tls_client_auth client authentication method.
+type OIDCClientTLSClientAuth struct {
+	// certificateAuthorityData is a base64 encoded PEM bundle of the certificate authorities which may
+	// issue client certificates for this client.
+	// +kubebuilder:validation:MinLength=1
+	CertificateAuthorityData string `json:"certificateAuthorityData"`
+
+	// subjectDN is the expected subject distinguished name of the client certificate, as described by
+	// RFC8705 section 2.1.2. It must exactly match the RFC2253 string representation of the certificate's
+	// subject, e.g. "CN=my-client,O=my-organization".
+	// +kubebuilder:validation:MinLength=1
+	SubjectDN string `json:"subjectDN"`
+}
+
+// OIDCClientServiceIdentity describes the fixed identity of an OIDCClient which uses the client_credentials grant.
+type OIDCClientServiceIdentity struct {
+	// username is the username of the service identity.
+	// +kubebuilder:validation:MinLength=1
+	Username string `json:"username"`
+
+	// groups are the group memberships of the service identity.
+	// +listType=set
+	// +optional
+	Groups []string `json:"groups,omitempty"`
 }
 
 // OIDCClientTokenLifetimes describes the optional overrides of token lifetimes for an OIDCClient.
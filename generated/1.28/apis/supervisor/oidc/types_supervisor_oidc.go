@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidc
@@ -36,6 +36,21 @@ const (
 	// IDTokenClaimAuthorizedParty is name of the authorized party claim defined by the OIDC spec.
 	IDTokenClaimAuthorizedParty = "azp"
 
+	// IDTokenClaimAuthTime is name of the authentication time claim defined by the OIDC spec.
+	IDTokenClaimAuthTime = "auth_time"
+
+	// IDTokenClaimAuthenticationContextClassReference is name of the authentication context class reference claim
+	// defined by the OIDC spec.
+	IDTokenClaimAuthenticationContextClassReference = "acr"
+
+	// IDTokenClaimAuthenticationMethodsReferences is name of the authentication methods references claim
+	// defined by the OIDC spec.
+	IDTokenClaimAuthenticationMethodsReferences = "amr"
+
+	// IDTokenClaimSessionID is name of the session ID claim defined by the OIDC Front-Channel Logout spec.
+	// Its value identifies the downstream session, and it can be used to end that session at the end_session_endpoint.
+	IDTokenClaimSessionID = "sid"
+
 	// IDTokenClaimUsername is the name of a custom claim in the downstream ID token whose value will contain the user's
 	// username which was mapped from the upstream identity provider.
 	IDTokenClaimUsername = "username"
@@ -57,6 +72,12 @@ const (
 	// GrantTypeTokenExchange is the name of a custom grant type for RFC8693 token exchanges.
 	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange" //nolint:gosec // this is not a credential
 
+	// GrantTypeDeviceCode is the name of the grant type for RFC8628 device authorization flows.
+	GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// GrantTypeClientCredentials is the name of the grant type for client credentials flows defined by RFC6749.
+	GrantTypeClientCredentials = "client_credentials"
+
 	// ScopeOpenID is name of the openid scope defined by the OIDC spec.
 	ScopeOpenID = "openid"
 
@@ -82,6 +103,10 @@ const (
 	// be used to request a different audience.
 	ScopeRequestAudience = "pinniped:request-audience"
 
+	// ScopeIntrospect is the name of a custom scope that determines whether a client is allowed to use the RFC7662
+	// token introspection endpoint to validate the access and refresh tokens issued by the Supervisor.
+	ScopeIntrospect = "pinniped:introspect"
+
 	// ClientIDPinnipedCLI is the client ID of the statically defined public OIDC client which is used by the CLI.
 	ClientIDPinnipedCLI = "pinniped-cli"
 
@@ -19,6 +19,10 @@ func (c *FakeConfigV1alpha1) FederationDomains(namespace string) v1alpha1.Federa
 	return &FakeFederationDomains{c, namespace}
 }
 
+func (c *FakeConfigV1alpha1) IdentityTransformLibraries(namespace string) v1alpha1.IdentityTransformLibraryInterface {
+	return &FakeIdentityTransformLibraries{c, namespace}
+}
+
 func (c *FakeConfigV1alpha1) OIDCClients(namespace string) v1alpha1.OIDCClientInterface {
 	return &FakeOIDCClients{c, namespace}
 }
@@ -29,6 +29,22 @@ type LDAPIdentityProviderListerExpansion interface{}
 // LDAPIdentityProviderNamespaceLister.
 type LDAPIdentityProviderNamespaceListerExpansion interface{}
 
+// OAuth2IdentityProviderListerExpansion allows custom methods to be added to
+// OAuth2IdentityProviderLister.
+type OAuth2IdentityProviderListerExpansion interface{}
+
+// OAuth2IdentityProviderNamespaceListerExpansion allows custom methods to be added to
+// OAuth2IdentityProviderNamespaceLister.
+type OAuth2IdentityProviderNamespaceListerExpansion interface{}
+
+// LocalUserIdentityProviderListerExpansion allows custom methods to be added to
+// LocalUserIdentityProviderLister.
+type LocalUserIdentityProviderListerExpansion interface{}
+
+// LocalUserIdentityProviderNamespaceListerExpansion allows custom methods to be added to
+// LocalUserIdentityProviderNamespaceLister.
+type LocalUserIdentityProviderNamespaceListerExpansion interface{}
+
 // OIDCIdentityProviderListerExpansion allows custom methods to be added to
 // OIDCIdentityProviderLister.
 type OIDCIdentityProviderListerExpansion interface{}
@@ -36,3 +52,11 @@ type OIDCIdentityProviderListerExpansion interface{}
 // OIDCIdentityProviderNamespaceListerExpansion allows custom methods to be added to
 // OIDCIdentityProviderNamespaceLister.
 type OIDCIdentityProviderNamespaceListerExpansion interface{}
+
+// SAMLIdentityProviderListerExpansion allows custom methods to be added to
+// SAMLIdentityProviderLister.
+type SAMLIdentityProviderListerExpansion interface{}
+
+// SAMLIdentityProviderNamespaceListerExpansion allows custom methods to be added to
+// SAMLIdentityProviderNamespaceLister.
+type SAMLIdentityProviderNamespaceListerExpansion interface{}
@@ -180,9 +180,52 @@ func (in *FederationDomainTLSSpec) DeepCopy() *FederationDomainTLSSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainTokenExchangeTransforms) DeepCopyInto(out *FederationDomainTokenExchangeTransforms) {
+	*out = *in
+	if in.Audiences != nil {
+		in, out := &in.Audiences, &out.Audiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientNames != nil {
+		in, out := &in.ClientNames, &out.ClientNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Expressions != nil {
+		in, out := &in.Expressions, &out.Expressions
+		*out = make([]FederationDomainTransformsExpression, len(*in))
+		copy(*out, *in)
+	}
+	if in.Examples != nil {
+		in, out := &in.Examples, &out.Examples
+		*out = make([]FederationDomainTransformsExample, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainTokenExchangeTransforms.
+func (in *FederationDomainTokenExchangeTransforms) DeepCopy() *FederationDomainTokenExchangeTransforms {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainTokenExchangeTransforms)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FederationDomainTransforms) DeepCopyInto(out *FederationDomainTransforms) {
 	*out = *in
+	if in.Libraries != nil {
+		in, out := &in.Libraries, &out.Libraries
+		*out = make([]FederationDomainTransformsLibraryRef, len(*in))
+		copy(*out, *in)
+	}
 	if in.Constants != nil {
 		in, out := &in.Constants, &out.Constants
 		*out = make([]FederationDomainTransformsConstant, len(*in))
@@ -202,6 +245,13 @@ func (in *FederationDomainTransforms) DeepCopyInto(out *FederationDomainTransfor
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TokenExchange != nil {
+		in, out := &in.TokenExchange, &out.TokenExchange
+		*out = make([]FederationDomainTokenExchangeTransforms, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -244,6 +294,11 @@ func (in *FederationDomainTransformsExample) DeepCopyInto(out *FederationDomainT
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AMR != nil {
+		in, out := &in.AMR, &out.AMR
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Expects.DeepCopyInto(&out.Expects)
 	return
 }
@@ -266,6 +321,13 @@ func (in *FederationDomainTransformsExampleExpects) DeepCopyInto(out *Federation
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AdditionalClaims != nil {
+		in, out := &in.AdditionalClaims, &out.AdditionalClaims
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -295,6 +357,117 @@ func (in *FederationDomainTransformsExpression) DeepCopy() *FederationDomainTran
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationDomainTransformsLibraryRef) DeepCopyInto(out *FederationDomainTransformsLibraryRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationDomainTransformsLibraryRef.
+func (in *FederationDomainTransformsLibraryRef) DeepCopy() *FederationDomainTransformsLibraryRef {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationDomainTransformsLibraryRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityTransformLibrary) DeepCopyInto(out *IdentityTransformLibrary) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityTransformLibrary.
+func (in *IdentityTransformLibrary) DeepCopy() *IdentityTransformLibrary {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityTransformLibrary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IdentityTransformLibrary) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityTransformLibraryList) DeepCopyInto(out *IdentityTransformLibraryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]IdentityTransformLibrary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityTransformLibraryList.
+func (in *IdentityTransformLibraryList) DeepCopy() *IdentityTransformLibraryList {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityTransformLibraryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IdentityTransformLibraryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityTransformLibrarySpec) DeepCopyInto(out *IdentityTransformLibrarySpec) {
+	*out = *in
+	if in.Constants != nil {
+		in, out := &in.Constants, &out.Constants
+		*out = make([]FederationDomainTransformsConstant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Expressions != nil {
+		in, out := &in.Expressions, &out.Expressions
+		*out = make([]FederationDomainTransformsExpression, len(*in))
+		copy(*out, *in)
+	}
+	if in.Examples != nil {
+		in, out := &in.Examples, &out.Examples
+		*out = make([]FederationDomainTransformsExample, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityTransformLibrarySpec.
+func (in *IdentityTransformLibrarySpec) DeepCopy() *IdentityTransformLibrarySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityTransformLibrarySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCClient) DeepCopyInto(out *OIDCClient) {
 	*out = *in
@@ -323,6 +496,32 @@ func (in *OIDCClient) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClientAuthentication) DeepCopyInto(out *OIDCClientAuthentication) {
+	*out = *in
+	if in.PrivateKeyJWT != nil {
+		in, out := &in.PrivateKeyJWT, &out.PrivateKeyJWT
+		*out = new(OIDCClientPrivateKeyJWT)
+		**out = **in
+	}
+	if in.TLSClientAuth != nil {
+		in, out := &in.TLSClientAuth, &out.TLSClientAuth
+		*out = new(OIDCClientTLSClientAuth)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClientAuthentication.
+func (in *OIDCClientAuthentication) DeepCopy() *OIDCClientAuthentication {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClientAuthentication)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCClientList) DeepCopyInto(out *OIDCClientList) {
 	*out = *in
@@ -356,6 +555,43 @@ func (in *OIDCClientList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClientPrivateKeyJWT) DeepCopyInto(out *OIDCClientPrivateKeyJWT) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClientPrivateKeyJWT.
+func (in *OIDCClientPrivateKeyJWT) DeepCopy() *OIDCClientPrivateKeyJWT {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClientPrivateKeyJWT)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClientServiceIdentity) DeepCopyInto(out *OIDCClientServiceIdentity) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClientServiceIdentity.
+func (in *OIDCClientServiceIdentity) DeepCopy() *OIDCClientServiceIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClientServiceIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCClientSpec) DeepCopyInto(out *OIDCClientSpec) {
 	*out = *in
@@ -375,6 +611,16 @@ func (in *OIDCClientSpec) DeepCopyInto(out *OIDCClientSpec) {
 		copy(*out, *in)
 	}
 	in.TokenLifetimes.DeepCopyInto(&out.TokenLifetimes)
+	if in.ServiceIdentity != nil {
+		in, out := &in.ServiceIdentity, &out.ServiceIdentity
+		*out = new(OIDCClientServiceIdentity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClientAuthentication != nil {
+		in, out := &in.ClientAuthentication, &out.ClientAuthentication
+		*out = new(OIDCClientAuthentication)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -411,6 +657,22 @@ func (in *OIDCClientStatus) DeepCopy() *OIDCClientStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClientTLSClientAuth) DeepCopyInto(out *OIDCClientTLSClientAuth) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClientTLSClientAuth.
+func (in *OIDCClientTLSClientAuth) DeepCopy() *OIDCClientTLSClientAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClientTLSClientAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCClientTokenLifetimes) DeepCopyInto(out *OIDCClientTokenLifetimes) {
 	*out = *in
@@ -0,0 +1,196 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type OAuth2IdentityProviderPhase string
+
+const (
+	// OAuth2PhasePending is the default phase for newly-created OAuth2IdentityProvider resources.
+	OAuth2PhasePending OAuth2IdentityProviderPhase = "Pending"
+
+	// OAuth2PhaseReady is the phase for an OAuth2IdentityProvider resource in a healthy state.
+	OAuth2PhaseReady OAuth2IdentityProviderPhase = "Ready"
+
+	// OAuth2PhaseError is the phase for an OAuth2IdentityProvider in an unhealthy state.
+	OAuth2PhaseError OAuth2IdentityProviderPhase = "Error"
+)
+
+// OAuth2IdentityProviderStatus is the status of an OAuth2 identity provider.
+type OAuth2IdentityProviderStatus struct {
+	// Phase summarizes the overall status of the OAuth2IdentityProvider.
+	//
+	// +kubebuilder:default=Pending
+	// +kubebuilder:validation:Enum=Pending;Ready;Error
+	Phase OAuth2IdentityProviderPhase `json:"phase,omitempty"`
+
+	// Conditions represents the observations of an identity provider's current state.
+	//
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// OAuth2AuthorizationConfig provides information about how to form the OAuth2 requests
+// to the OAuth2 authorization server.
+type OAuth2AuthorizationConfig struct {
+	// Scopes are the scopes that will be requested from your OAuth2 authorization server in the authorization
+	// request during an OAuth2 Authorization Code Flow. By default, no scopes are requested. Include the scopes
+	// which are required to call the userinfo and groups URLs with the resulting access token, and any scope which
+	// your authorization server requires before it will return a refresh token. See the documentation of your
+	// authorization server for its available scopes.
+	//
+	// +optional
+	// +listType=atomic
+	Scopes []string `json:"scopes,omitempty"`
+
+	// AdditionalAuthorizeParameters are extra query parameters that should be included in the authorize request to
+	// your OAuth2 authorization server. By default, no extra parameters are sent. The standard parameters that will
+	// be sent are "response_type", "scope", "client_id", "state", "code_challenge", "code_challenge_method", and
+	// "redirect_uri". These parameters cannot be included in this setting.
+	//
+	// +optional
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=name
+	AdditionalAuthorizeParameters []Parameter `json:"additionalAuthorizeParameters,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
+}
+
+// OAuth2Claims provides CEL expressions which map the JSON responses of the userinfo and groups URLs into identities.
+//
+// Each expression may use the variable `userInfo`, which holds the JSON response of the userinfo URL, and the
+// variable `groupsResponse`, which holds the JSON response of the groups URL. When no groups URL is configured,
+// `groupsResponse` is null. JSON numbers which are integers are available as CEL ints, and other JSON numbers
+// are available as CEL doubles. The CEL language is documented at
+// https://github.com/google/cel-spec/blob/master/doc/langdef.md, and the CEL string extensions are also available.
+type OAuth2Claims struct {
+	// Username is a CEL expression which returns the username as a string, e.g. `userInfo.username`.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Username string `json:"username"`
+
+	// Groups is a CEL expression which returns the group memberships of the user as a list of strings,
+	// e.g. `groupsResponse.map(g, g.full_path)`. When not specified, the user will not belong to any groups.
+	//
+	// +optional
+	Groups string `json:"groups,omitempty"`
+
+	// Subject is a CEL expression which returns a unique and unchanging identifier of the user as a string,
+	// e.g. `string(userInfo.id)`. It is used to determine the subject of the downstream ID tokens, and to detect
+	// when the upstream identity of a user has changed during a refresh. When not specified, the username
+	// expression is used. Because some authorization servers allow users to change their usernames, it is
+	// recommended to specify an expression which returns an identifier that cannot be changed by the user.
+	//
+	// +optional
+	Subject string `json:"subject,omitempty"`
+}
+
+// OAuth2Client contains information about an OAuth2 client (e.g., client ID and client secret).
+type OAuth2Client struct {
+	// SecretName contains the name of a namespace-local Secret object that provides the clientID and
+	// clientSecret for an OAuth2 client.
+	//
+	// This secret must be of type "secrets.pinniped.dev/oauth2-client" with keys "clientID" and "clientSecret".
+	//
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+}
+
+// OAuth2IdentityProviderSpec is the spec for configuring an OAuth2 identity provider.
+type OAuth2IdentityProviderSpec struct {
+	// AuthorizationURL is the URL of the authorization endpoint of the OAuth2 authorization server, to which the
+	// user's browser will be redirected during login.
+	//
+	// +kubebuilder:validation:Pattern=`^https://`
+	AuthorizationURL string `json:"authorizationURL"`
+
+	// TokenURL is the URL of the token endpoint of the OAuth2 authorization server, which will be used to
+	// exchange authorization codes and refresh tokens for access tokens.
+	//
+	// +kubebuilder:validation:Pattern=`^https://`
+	TokenURL string `json:"tokenURL"`
+
+	// UserInfoURL is the URL of an API which returns a JSON object describing the authenticated user when it is
+	// called with the user's access token as a bearer token, e.g. "https://gitlab.example.com/api/v4/user".
+	// The response is available to the expressions in spec.claims as the `userInfo` variable.
+	//
+	// +kubebuilder:validation:Pattern=`^https://`
+	UserInfoURL string `json:"userInfoURL"`
+
+	// GroupsURL is the URL of an optional API which returns a JSON document describing the group memberships of
+	// the authenticated user when it is called with the user's access token as a bearer token,
+	// e.g. "https://gitlab.example.com/api/v4/groups?min_access_level=10".
+	// The response is available to the expressions in spec.claims as the `groupsResponse` variable.
+	//
+	// +kubebuilder:validation:Pattern=`^https://`
+	// +optional
+	GroupsURL string `json:"groupsURL,omitempty"`
+
+	// RevocationURL is the URL of an optional RFC7009 token revocation endpoint of the OAuth2 authorization server.
+	// When it is configured, the user's upstream refresh token, or the upstream access token when the authorization server
+	// did not return a refresh token, is revoked whenever the user's downstream session ends.
+	//
+	// +kubebuilder:validation:Pattern=`^https://`
+	// +optional
+	RevocationURL string `json:"revocationURL,omitempty"`
+
+	// TLS configuration for calling the authorization server's token, userinfo, groups, and revocation URLs.
+	//
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+
+	// AuthorizationConfig holds information about how to form the OAuth2 requests to the authorization server.
+	//
+	// +optional
+	AuthorizationConfig OAuth2AuthorizationConfig `json:"authorizationConfig,omitempty"`
+
+	// Claims provides CEL expressions which map the JSON responses of the userinfo and groups URLs into identities.
+	Claims OAuth2Claims `json:"claims"`
+
+	// Client identifies the secret with credentials for an OAuth2 client registered with the authorization server.
+	Client OAuth2Client `json:"client"`
+}
+
+// OAuth2IdentityProvider describes the configuration of an upstream OAuth2 authorization server which does not
+// support OpenID Connect, such as GitLab, Bitbucket, or an internal OAuth2 server. The identity of the user is
+// determined by calling the configured userinfo URL, and optionally the configured groups URL, using the access
+// token returned by the authorization server. For authorization servers which support OpenID Connect, use an
+// OIDCIdentityProvider instead.
+//
+// Right now, only web-based logins are supported, for both the pinniped-cli client and clients configured
+// as OIDCClients.
+//
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped;pinniped-idp;pinniped-idps
+// +kubebuilder:printcolumn:name="Authorization URL",type=string,JSONPath=`.spec.authorizationURL`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type OAuth2IdentityProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec for configuring the identity provider.
+	Spec OAuth2IdentityProviderSpec `json:"spec"`
+
+	// Status of the identity provider.
+	Status OAuth2IdentityProviderStatus `json:"status,omitempty"`
+}
+
+// OAuth2IdentityProviderList lists OAuth2IdentityProvider objects.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type OAuth2IdentityProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []OAuth2IdentityProvider `json:"items"`
+}
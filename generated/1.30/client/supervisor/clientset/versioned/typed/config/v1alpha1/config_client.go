@@ -16,6 +16,7 @@ import (
 type ConfigV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	FederationDomainsGetter
+	IdentityTransformLibrariesGetter
 	OIDCClientsGetter
 }
 
@@ -28,6 +29,10 @@ func (c *ConfigV1alpha1Client) FederationDomains(namespace string) FederationDom
 	return newFederationDomains(c, namespace)
 }
 
+func (c *ConfigV1alpha1Client) IdentityTransformLibraries(namespace string) IdentityTransformLibraryInterface {
+	return newIdentityTransformLibraries(c, namespace)
+}
+
 func (c *ConfigV1alpha1Client) OIDCClients(namespace string) OIDCClientInterface {
 	return newOIDCClients(c, namespace)
 }
@@ -17,8 +17,14 @@ type Interface interface {
 	GitHubIdentityProviders() GitHubIdentityProviderInformer
 	// LDAPIdentityProviders returns a LDAPIdentityProviderInformer.
 	LDAPIdentityProviders() LDAPIdentityProviderInformer
+	// OAuth2IdentityProviders returns a OAuth2IdentityProviderInformer.
+	OAuth2IdentityProviders() OAuth2IdentityProviderInformer
+	// LocalUserIdentityProviders returns a LocalUserIdentityProviderInformer.
+	LocalUserIdentityProviders() LocalUserIdentityProviderInformer
 	// OIDCIdentityProviders returns a OIDCIdentityProviderInformer.
 	OIDCIdentityProviders() OIDCIdentityProviderInformer
+	// SAMLIdentityProviders returns a SAMLIdentityProviderInformer.
+	SAMLIdentityProviders() SAMLIdentityProviderInformer
 }
 
 type version struct {
@@ -47,7 +53,22 @@ func (v *version) LDAPIdentityProviders() LDAPIdentityProviderInformer {
 	return &lDAPIdentityProviderInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// OAuth2IdentityProviders returns a OAuth2IdentityProviderInformer.
+func (v *version) OAuth2IdentityProviders() OAuth2IdentityProviderInformer {
+	return &oAuth2IdentityProviderInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// LocalUserIdentityProviders returns a LocalUserIdentityProviderInformer.
+func (v *version) LocalUserIdentityProviders() LocalUserIdentityProviderInformer {
+	return &localUserIdentityProviderInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // OIDCIdentityProviders returns a OIDCIdentityProviderInformer.
 func (v *version) OIDCIdentityProviders() OIDCIdentityProviderInformer {
 	return &oIDCIdentityProviderInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// SAMLIdentityProviders returns a SAMLIdentityProviderInformer.
+func (v *version) SAMLIdentityProviders() SAMLIdentityProviderInformer {
+	return &sAMLIdentityProviderInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
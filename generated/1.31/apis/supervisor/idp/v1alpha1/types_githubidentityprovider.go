@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -32,6 +32,27 @@ const (
 	GitHubAllowedAuthOrganizationsPolicyOnlyUsersFromAllowedOrganizations GitHubAllowedAuthOrganizationsPolicy = "OnlyUsersFromAllowedOrganizations"
 )
 
+// GitHubRepositoryRole is a role which a user can have on a GitHub repository.
+// See [Repository roles for an organization](https://docs.github.com/en/organizations/managing-user-access-to-your-organizations-repositories/managing-repository-roles/repository-roles-for-an-organization).
+type GitHubRepositoryRole string
+
+const (
+	// GitHubRepositoryRoleRead is the "read" role, which is the least privileged role.
+	GitHubRepositoryRoleRead GitHubRepositoryRole = "read"
+
+	// GitHubRepositoryRoleTriage is the "triage" role.
+	GitHubRepositoryRoleTriage GitHubRepositoryRole = "triage"
+
+	// GitHubRepositoryRoleWrite is the "write" role.
+	GitHubRepositoryRoleWrite GitHubRepositoryRole = "write"
+
+	// GitHubRepositoryRoleMaintain is the "maintain" role.
+	GitHubRepositoryRoleMaintain GitHubRepositoryRole = "maintain"
+
+	// GitHubRepositoryRoleAdmin is the "admin" role, which is the most privileged role.
+	GitHubRepositoryRoleAdmin GitHubRepositoryRole = "admin"
+)
+
 // GitHubIdentityProviderStatus is the status of an GitHub identity provider.
 type GitHubIdentityProviderStatus struct {
 	// Phase summarizes the overall status of the GitHubIdentityProvider.
@@ -156,6 +177,34 @@ type GitHubClaims struct {
 	// +kubebuilder:validation:Enum=name;slug
 	// +optional
 	Groups *GitHubGroupNameAttribute `json:"groups"`
+
+	// RepositoryRoleGroups, when specified, adds groups to the user's group memberships based on the user's role
+	// on the listed GitHub repositories. These groups are presented to Kubernetes in addition to the groups
+	// which are derived from the user's team memberships, so that Kubernetes RBAC policies can follow access
+	// to repositories.
+	//
+	// +optional
+	RepositoryRoleGroups *GitHubRepositoryRoleGroupsSpec `json:"repositoryRoleGroups,omitempty"`
+}
+
+// GitHubRepositoryRoleGroupsSpec configures groups which are derived from the user's role on GitHub repositories.
+type GitHubRepositoryRoleGroupsSpec struct {
+	// Repositories lists the GitHub repositories for which the user's role should be presented to Kubernetes as a
+	// group. Each repository must be specified as the owner's login name, followed by a forward slash, followed by
+	// the repository name (e.g. "my-org/my-repo").
+	//
+	// For each listed repository on which the user has a role, a group named by the repository, followed by a colon,
+	// followed by the user's highest role on that repository will be presented to Kubernetes (e.g. "my-org/my-repo:write").
+	// The role will be one of "read", "triage", "write", "maintain", or "admin". No group is presented for a repository
+	// on which the user has no role.
+	//
+	// When spec.allowAuthentication.organizations.allowed has organizations listed, then each repository must be
+	// owned by one of the allowed organizations.
+	//
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=set
+	Repositories []string `json:"repositories"`
 }
 
 // GitHubClientSpec contains information about the GitHub client that this identity provider will use
@@ -170,6 +219,20 @@ type GitHubClientSpec struct {
 	SecretName string `json:"secretName"`
 }
 
+// GitHubAppSpec contains information about the GitHub App credentials that this identity provider will use
+// to make installation-scoped calls to the GitHub API.
+type GitHubAppSpec struct {
+	// SecretName contains the name of a namespace-local Secret object that provides the appID and privateKey
+	// of a GitHub App.
+	//
+	// This secret must be of type "secrets.pinniped.dev/github-app" with keys "appID" and "privateKey".
+	// The value of "appID" must be the numeric ID of the GitHub App, and the value of "privateKey" must be
+	// a PEM-encoded RSA private key which was generated for the GitHub App.
+	//
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+}
+
 type GitHubOrganizationsSpec struct {
 	// Allowed values are "OnlyUsersFromAllowedOrganizations" or "AllGitHubUsers".
 	// Defaults to "OnlyUsersFromAllowedOrganizations".
@@ -207,6 +270,53 @@ type GitHubAllowAuthenticationSpec struct {
 	// +kubebuilder:validation:XValidation:message="spec.allowAuthentication.organizations.policy must be 'OnlyUsersFromAllowedOrganizations' when spec.allowAuthentication.organizations.allowed has organizations listed",rule="!(has(self.allowed) && size(self.allowed) > 0 && self.policy == 'AllGitHubUsers')"
 	// +kubebuilder:validation:XValidation:message="spec.allowAuthentication.organizations.policy must be 'AllGitHubUsers' when spec.allowAuthentication.organizations.allowed is empty",rule="!((!has(self.allowed) || size(self.allowed) == 0) && self.policy == 'OnlyUsersFromAllowedOrganizations')"
 	Organizations GitHubOrganizationsSpec `json:"organizations"`
+
+	// Teams, when specified, allows users with membership in at least one of the listed GitHub teams to log in.
+	// Each team must be specified as the organization's login name, followed by a forward slash, followed by
+	// the team's slug (e.g. "my-org/my-team").
+	//
+	// Users must always satisfy the organizations policy. When teams or repositories are also specified,
+	// then users must additionally satisfy at least one of the team or repository rules to log in.
+	//
+	// When organizations.allowed has organizations listed, then each team must belong to one of the allowed
+	// organizations.
+	//
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=set
+	// +optional
+	Teams []string `json:"teams,omitempty"`
+
+	// Repositories, when specified, allows users who have at least the configured role on at least one of the
+	// listed GitHub repositories to log in.
+	//
+	// Users must always satisfy the organizations policy. When teams or repositories are also specified,
+	// then users must additionally satisfy at least one of the team or repository rules to log in.
+	//
+	// When organizations.allowed has organizations listed, then each repository must be owned by one of the
+	// allowed organizations.
+	//
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Repositories []GitHubRepositoryAllowRule `json:"repositories,omitempty"`
+}
+
+// GitHubRepositoryAllowRule allows users with a minimum role on a GitHub repository to log in.
+type GitHubRepositoryAllowRule struct {
+	// Name of the repository, specified as the owner's login name, followed by a forward slash, followed by the
+	// repository name (e.g. "my-org/my-repo").
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// MinimumRole is the least privileged role which the user must have on the repository to log in.
+	// Allowed values are "read", "triage", "write", "maintain", or "admin". Defaults to "read".
+	//
+	// +kubebuilder:default=read
+	// +kubebuilder:validation:Enum=read;triage;write;maintain;admin
+	// +optional
+	MinimumRole *GitHubRepositoryRole `json:"minimumRole,omitempty"`
 }
 
 // GitHubIdentityProviderSpec is the spec for configuring an GitHub identity provider.
@@ -226,6 +336,18 @@ type GitHubIdentityProviderSpec struct {
 
 	// Client identifies the secret with credentials for a GitHub App or GitHub OAuth2 App (a GitHub client).
 	Client GitHubClientSpec `json:"client"`
+
+	// App optionally identifies the secret with the credentials of the GitHub App which is also identified by
+	// spec.client. When configured, the Supervisor will use installation access tokens of the GitHub App to look up
+	// the user's roles on the repositories listed in spec.claims.repositoryRoleGroups and
+	// spec.allowAuthentication.repositories. The GitHub App must be installed on the owners of those repositories
+	// and must be granted read access to repository metadata.
+	//
+	// When not configured, the user's access token from the web-based login flow is used to look up the
+	// user's roles on repositories, which only works for repositories which are visible to that token.
+	//
+	// +optional
+	App *GitHubAppSpec `json:"app,omitempty"`
 }
 
 // GitHubIdentityProvider describes the configuration of an upstream GitHub identity provider.
@@ -7,4 +7,6 @@ package v1alpha1
 
 type FederationDomainExpansion interface{}
 
+type IdentityTransformLibraryExpansion interface{}
+
 type OIDCClientExpansion interface{}
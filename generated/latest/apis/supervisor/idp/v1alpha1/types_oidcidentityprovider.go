@@ -1,4 +1,4 @@
-// Copyright 2020-2023 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package v1alpha1
@@ -111,8 +111,70 @@ type OIDCAuthorizationConfig struct {
 	// allowPasswordGrant defaults to false.
 	// +optional
 	AllowPasswordGrant bool `json:"allowPasswordGrant,omitempty"`
+
+	// requestMode is how the parameters of the authorization request are sent to your OIDC provider when the
+	// Supervisor redirects the user's browser to its authorization endpoint during an OIDC Authorization Code Flow.
+	// When "query", the parameters are sent as query parameters of the authorization endpoint URL.
+	// When "par", the Supervisor first sends the parameters directly to your OIDC provider's pushed authorization
+	// request endpoint (see https://datatracker.ietf.org/doc/html/rfc9126), and the browser is redirected using only
+	// a reference to the pushed request. The pushed authorization request endpoint must be advertised by your OIDC
+	// provider's discovery document as "pushed_authorization_request_endpoint", and the pushed request is authenticated
+	// using the client's configured authentication method.
+	// When "jar", the parameters are sent as a request object signed by the client's private key (see
+	// https://datatracker.ietf.org/doc/html/rfc9101). In this case, the client's Secret must have the key "privateKey",
+	// and your OIDC provider must be configured with the corresponding public key.
+	// requestMode defaults to "query".
+	// +kubebuilder:default=query
+	// +optional
+	RequestMode OIDCAuthorizationRequestMode `json:"requestMode,omitempty"`
+
+	// acrValues are the requested Authentication Context Class Reference values, in order of preference, which will
+	// be sent to your OIDC provider as the "acr_values" parameter of the authorization request during an OIDC
+	// Authorization Code Flow. When set, the "acr" claim of the ID token returned by your OIDC provider during login
+	// must be one of these values, or else the login will be rejected. This is useful to require that users log in
+	// using multi-factor authentication, when your OIDC provider supports that. The value of the "acr" claim will be
+	// included in the Supervisor's ID tokens and will be available to identity transformations in the FederationDomain.
+	// When set, this overrides any "acr_values" parameter in additionalAuthorizeParameters.
+	// +optional
+	ACRValues []string `json:"acrValues,omitempty"`
+
+	// maxAge is the allowable elapsed time in seconds since the last time the user actively authenticated to your
+	// OIDC provider, which will be sent as the "max_age" parameter of the authorization request during an OIDC
+	// Authorization Code Flow. When set, the ID token returned by your OIDC provider during login must include an
+	// "auth_time" claim which is no older than maxAge (plus a small allowance for clock skew), or else the login
+	// will be rejected. When set, this overrides any "max_age" parameter in additionalAuthorizeParameters.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxAge *int64 `json:"maxAge,omitempty"`
+
+	// requiredAMRValues are Authentication Methods Reference values which must all be included in the "amr" claim
+	// of the ID token returned by your OIDC provider during login, or else the login will be rejected. For example,
+	// set this to ["mfa"] to require that users log in using multi-factor authentication, when your OIDC provider
+	// reports that using the "amr" claim. The value of the "amr" claim will be included in the Supervisor's ID tokens
+	// and will be available to identity transformations in the FederationDomain.
+	// These requirements, along with acrValues and maxAge, are also enforced for the Resource Owner Password
+	// Credentials Grant when allowPasswordGrant is true, but they are not enforced during session refreshes.
+	// +optional
+	RequiredAMRValues []string `json:"requiredAMRValues,omitempty"`
 }
 
+// OIDCAuthorizationRequestMode is how the parameters of an authorization request are sent to an OIDC provider.
+// +kubebuilder:validation:Enum=query;par;jar
+type OIDCAuthorizationRequestMode string
+
+const (
+	// OIDCAuthorizationRequestModeQuery sends the authorization request parameters as query parameters.
+	OIDCAuthorizationRequestModeQuery OIDCAuthorizationRequestMode = "query"
+
+	// OIDCAuthorizationRequestModePAR pushes the authorization request parameters to the provider
+	// before redirecting, as described in RFC 9126.
+	OIDCAuthorizationRequestModePAR OIDCAuthorizationRequestMode = "par"
+
+	// OIDCAuthorizationRequestModeJAR sends the authorization request parameters as a signed
+	// request object, as described in RFC 9101.
+	OIDCAuthorizationRequestModeJAR OIDCAuthorizationRequestMode = "jar"
+)
+
 // Parameter is a key/value pair which represents a parameter in an HTTP request.
 type Parameter struct {
 	// The name of the parameter. Required.
@@ -149,16 +211,97 @@ type OIDCClaims struct {
 	// are not available, the "additionalClaims" claim will be excluded from the ID tokens generated by the Supervisor.
 	// +optional
 	AdditionalClaimMappings map[string]string `json:"additionalClaimMappings,omitempty"`
+
+	// GroupsEndpoint optionally configures an HTTPS API endpoint of your OIDC provider which will be called to look up
+	// the group memberships of the user, for example the Microsoft Graph API's "memberOf" endpoint. This is useful when
+	// your OIDC provider cannot include all group memberships in its ID tokens, e.g. because a user is a member of too
+	// many groups. When configured, the endpoint is called with the upstream access token during every login and
+	// refresh, and the group names that it returns replace the value of the groups claim. When the groups claim is not
+	// configured, it defaults to "groups" when this setting is configured.
+	// Note that distributed and aggregated claims (the "_claim_names" and "_claim_sources" claims of OIDC Core 1.0
+	// section 5.6.2) are always resolved for the username, groups, and additional claims, without needing to configure
+	// this setting.
+	// +optional
+	GroupsEndpoint *OIDCGroupsEndpoint `json:"groupsEndpoint,omitempty"`
+}
+
+// OIDCGroupsEndpoint describes an HTTPS API endpoint which returns the group memberships of a user.
+type OIDCGroupsEndpoint struct {
+	// URL is the HTTPS URL of the endpoint, e.g. "https://graph.microsoft.com/v1.0/me/memberOf".
+	// The endpoint is called using the GET method, with the upstream access token as a bearer token.
+	// The CA bundle configured in spec.tls is also used to verify the endpoint's TLS certificate.
+	// +kubebuilder:validation:Pattern=`^https://`
+	URL string `json:"url"`
+
+	// ItemsField is the name of the field of the JSON object returned by the endpoint which contains the array of
+	// groups. Each item of the array must either be a string, which is the group name, or an object, which contains
+	// the group name in the field named by GroupNameField. When set to the empty string, the response itself must be
+	// the array of groups. Defaults to "value".
+	// +optional
+	ItemsField *string `json:"itemsField,omitempty"`
+
+	// GroupNameField is the name of the field of each group object which contains the name of the group.
+	// Items which do not contain this field, or where it is not a string, are ignored. Defaults to "displayName".
+	// +optional
+	GroupNameField string `json:"groupNameField,omitempty"`
+
+	// NextPageField is the name of the field of the JSON object returned by the endpoint which contains the URL of
+	// the next page of results. A "next" link in the Link response header is also followed. Defaults to
+	// "@odata.nextLink".
+	// +optional
+	NextPageField string `json:"nextPageField,omitempty"`
 }
 
+// OIDCClientAuthenticationMethod is the method used by the Supervisor to authenticate itself as a client of the
+// OIDC identity provider when making requests to its token and revocation endpoints.
+// +kubebuilder:validation:Enum=ClientSecret;PrivateKeyJWT;TLSClientAuth;None
+type OIDCClientAuthenticationMethod string
+
+const (
+	// OIDCClientAuthenticationMethodClientSecret authenticates using the client secret, sent either in the
+	// request parameters or using HTTP basic auth ("client_secret_post" or "client_secret_basic").
+	OIDCClientAuthenticationMethodClientSecret OIDCClientAuthenticationMethod = "ClientSecret"
+
+	// OIDCClientAuthenticationMethodPrivateKeyJWT authenticates by sending a JWT which is signed by the
+	// client's private key ("private_key_jwt"), as described in RFC 7523.
+	OIDCClientAuthenticationMethodPrivateKeyJWT OIDCClientAuthenticationMethod = "PrivateKeyJWT"
+
+	// OIDCClientAuthenticationMethodTLSClientAuth authenticates by presenting a client certificate during
+	// the TLS handshake ("tls_client_auth"), as described in RFC 8705.
+	OIDCClientAuthenticationMethodTLSClientAuth OIDCClientAuthenticationMethod = "TLSClientAuth"
+
+	// OIDCClientAuthenticationMethodNone does not authenticate the client ("none"). This may only be used
+	// with providers which allow public clients, and relies on PKCE to protect the authorization code.
+	OIDCClientAuthenticationMethodNone OIDCClientAuthenticationMethod = "None"
+)
+
 // OIDCClient contains information about an OIDC client (e.g., client ID and client
 // secret).
 type OIDCClient struct {
 	// SecretName contains the name of a namespace-local Secret object that provides the clientID and
-	// clientSecret for an OIDC client. If only the SecretName is specified in an OIDCClient
-	// struct, then it is expected that the Secret is of type "secrets.pinniped.dev/oidc-client" with keys
-	// "clientID" and "clientSecret".
+	// the client credentials for an OIDC client. It is expected that the Secret is of type
+	// "secrets.pinniped.dev/oidc-client" with the key "clientID". When the AuthenticationMethod is
+	// ClientSecret, the Secret must also have the key "clientSecret". When the AuthenticationMethod is
+	// PrivateKeyJWT, the Secret must also have the key "privateKey", which contains a PEM-encoded RSA or ECDSA
+	// private key, and may optionally have the key "privateKeyID", which is sent as the "kid" header of the
+	// signed client assertions so that the identity provider can find the corresponding public key.
+	// When the AuthenticationMethod is TLSClientAuth or None, only the key "clientID" is required.
 	SecretName string `json:"secretName"`
+
+	// AuthenticationMethod is the method used to authenticate to the identity provider's token and revocation
+	// endpoints. The identity provider must be configured to allow the same method for this client.
+	// Defaults to ClientSecret.
+	// +kubebuilder:default=ClientSecret
+	// +optional
+	AuthenticationMethod OIDCClientAuthenticationMethod `json:"authenticationMethod,omitempty"`
+
+	// CertificateSecretName is the name of a namespace-local Secret object of type "kubernetes.io/tls"
+	// which contains a client certificate and private key in its "tls.crt" and "tls.key" keys.
+	// When specified, the client certificate is presented to the identity provider during the TLS handshake
+	// of every request, which allows the use of certificate-bound tokens as described in RFC 8705.
+	// Required when the AuthenticationMethod is TLSClientAuth, and optional otherwise.
+	// +optional
+	CertificateSecretName string `json:"certificateSecretName,omitempty"`
 }
 
 // OIDCIdentityProviderSpec is the spec for configuring an OIDC identity provider.
@@ -0,0 +1,147 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type SAMLIdentityProviderPhase string
+
+const (
+	// SAMLPhasePending is the default phase for newly-created SAMLIdentityProvider resources.
+	SAMLPhasePending SAMLIdentityProviderPhase = "Pending"
+
+	// SAMLPhaseReady is the phase for an SAMLIdentityProvider resource in a healthy state.
+	SAMLPhaseReady SAMLIdentityProviderPhase = "Ready"
+
+	// SAMLPhaseError is the phase for an SAMLIdentityProvider in an unhealthy state.
+	SAMLPhaseError SAMLIdentityProviderPhase = "Error"
+)
+
+// SAMLIdentityProviderStatus is the status of an SAML identity provider.
+type SAMLIdentityProviderStatus struct {
+	// Phase summarizes the overall status of the SAMLIdentityProvider.
+	//
+	// +kubebuilder:default=Pending
+	// +kubebuilder:validation:Enum=Pending;Ready;Error
+	Phase SAMLIdentityProviderPhase `json:"phase,omitempty"`
+
+	// Conditions represents the observations of an identity provider's current state.
+	//
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// SAMLIdentityProviderMetadata provides the SAML 2.0 metadata document of the identity provider,
+// either by URL or inline.
+type SAMLIdentityProviderMetadata struct {
+	// URL is the https URL from which the identity provider's SAML 2.0 metadata document can be downloaded.
+	// The metadata will be downloaded again periodically, so rotations of the identity provider's signing
+	// certificates will be noticed.
+	//
+	// +kubebuilder:validation:Pattern=`^https://`
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// TLS configuration for downloading the metadata from the URL.
+	//
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+
+	// XML is the identity provider's SAML 2.0 metadata document, as an inline XML string.
+	//
+	// +optional
+	XML string `json:"xml,omitempty"`
+}
+
+// SAMLServiceProvider describes how the Supervisor presents itself to the SAML identity provider.
+type SAMLServiceProvider struct {
+	// EntityID is the entity ID of the Supervisor as a SAML service provider. It is sent as the Issuer of the
+	// authentication requests, and the identity provider must include it in the audience restriction of its
+	// assertions.
+	// When not specified, the issuer URL of the FederationDomain through which the user is logging in will
+	// be used.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	EntityID string `json:"entityID,omitempty"`
+}
+
+// SAMLClaims provides a mapping from SAML assertion attributes to the username and groups claims.
+type SAMLClaims struct {
+	// Username is the name of the SAML attribute which will be used as the username.
+	// When not specified, the NameID of the assertion's subject will be used as the username.
+	//
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Groups is the name of the SAML attribute which will be used as the group memberships of the user.
+	// Each value of the attribute will be a group name. When not specified, the user will not belong to
+	// any groups.
+	//
+	// +optional
+	Groups string `json:"groups,omitempty"`
+}
+
+// SAMLIdentityProviderSpec is the spec for configuring a SAML 2.0 identity provider.
+type SAMLIdentityProviderSpec struct {
+	// IdentityProviderMetadata provides the SAML 2.0 metadata of the identity provider. Exactly one of
+	// url or xml must be specified. The metadata must include an IDPSSODescriptor with a
+	// SingleSignOnService which uses the HTTP-Redirect binding and at least one signing certificate.
+	//
+	// +kubebuilder:validation:XValidation:message="exactly one of url or xml must be specified",rule="has(self.url) != has(self.xml)"
+	// +kubebuilder:validation:XValidation:message="tls can only be specified with url",rule="!has(self.tls) || has(self.url)"
+	IdentityProviderMetadata SAMLIdentityProviderMetadata `json:"identityProviderMetadata"`
+
+	// ServiceProvider describes how the Supervisor presents itself to the identity provider.
+	//
+	// +optional
+	ServiceProvider SAMLServiceProvider `json:"serviceProvider,omitempty"`
+
+	// Claims provides a mapping from SAML assertion attributes to the username and groups claims.
+	//
+	// +optional
+	Claims SAMLClaims `json:"claims,omitempty"`
+}
+
+// SAMLIdentityProvider describes the configuration of an upstream SAML 2.0 identity provider.
+//
+// The Supervisor acts as a SAML service provider which sends unsigned authentication requests using the
+// HTTP-Redirect binding, and which receives signed responses or assertions using the HTTP-POST binding at
+// the callback endpoint of each FederationDomain, i.e. the FederationDomain's issuer URL followed by "/callback".
+// Encrypted assertions are not supported.
+//
+// Right now, only web-based logins are supported.
+//
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories=pinniped;pinniped-idp;pinniped-idps
+// +kubebuilder:printcolumn:name="Metadata URL",type=string,JSONPath=`.spec.identityProviderMetadata.url`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+type SAMLIdentityProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec for configuring the identity provider.
+	Spec SAMLIdentityProviderSpec `json:"spec"`
+
+	// Status of the identity provider.
+	Status SAMLIdentityProviderStatus `json:"status,omitempty"`
+}
+
+// SAMLIdentityProviderList lists SAMLIdentityProvider objects.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SAMLIdentityProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []SAMLIdentityProvider `json:"items"`
+}
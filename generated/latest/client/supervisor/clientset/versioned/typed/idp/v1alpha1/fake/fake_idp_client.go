@@ -27,10 +27,22 @@ func (c *FakeIDPV1alpha1) LDAPIdentityProviders(namespace string) v1alpha1.LDAPI
 	return &FakeLDAPIdentityProviders{c, namespace}
 }
 
+func (c *FakeIDPV1alpha1) OAuth2IdentityProviders(namespace string) v1alpha1.OAuth2IdentityProviderInterface {
+	return &FakeOAuth2IdentityProviders{c, namespace}
+}
+
+func (c *FakeIDPV1alpha1) LocalUserIdentityProviders(namespace string) v1alpha1.LocalUserIdentityProviderInterface {
+	return &FakeLocalUserIdentityProviders{c, namespace}
+}
+
 func (c *FakeIDPV1alpha1) OIDCIdentityProviders(namespace string) v1alpha1.OIDCIdentityProviderInterface {
 	return &FakeOIDCIdentityProviders{c, namespace}
 }
 
+func (c *FakeIDPV1alpha1) SAMLIdentityProviders(namespace string) v1alpha1.SAMLIdentityProviderInterface {
+	return &FakeSAMLIdentityProviders{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeIDPV1alpha1) RESTClient() rest.Interface {
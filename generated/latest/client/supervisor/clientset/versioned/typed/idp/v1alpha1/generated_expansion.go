@@ -11,4 +11,10 @@ type GitHubIdentityProviderExpansion interface{}
 
 type LDAPIdentityProviderExpansion interface{}
 
+type OAuth2IdentityProviderExpansion interface{}
+
+type LocalUserIdentityProviderExpansion interface{}
+
 type OIDCIdentityProviderExpansion interface{}
+
+type SAMLIdentityProviderExpansion interface{}
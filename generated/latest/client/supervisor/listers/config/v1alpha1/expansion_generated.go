@@ -13,6 +13,14 @@ type FederationDomainListerExpansion interface{}
 // FederationDomainNamespaceLister.
 type FederationDomainNamespaceListerExpansion interface{}
 
+// IdentityTransformLibraryListerExpansion allows custom methods to be added to
+// IdentityTransformLibraryLister.
+type IdentityTransformLibraryListerExpansion interface{}
+
+// IdentityTransformLibraryNamespaceListerExpansion allows custom methods to be added to
+// IdentityTransformLibraryNamespaceLister.
+type IdentityTransformLibraryNamespaceListerExpansion interface{}
+
 // OIDCClientListerExpansion allows custom methods to be added to
 // OIDCClientLister.
 type OIDCClientListerExpansion interface{}
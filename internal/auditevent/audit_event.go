@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package auditevent
@@ -30,8 +30,11 @@ const (
 
 	// Supervisor session ending logging.
 
-	UpstreamOIDCTokenRevoked Message = "Upstream OIDC Token Revoked" //nolint:gosec // this is not a credential
-	SessionGarbageCollected  Message = "Session Garbage Collected"
+	UpstreamOIDCTokenRevoked   Message = "Upstream OIDC Token Revoked"   //nolint:gosec // this is not a credential
+	UpstreamOAuth2TokenRevoked Message = "Upstream OAuth2 Token Revoked" //nolint:gosec // this is not a credential
+	SessionGarbageCollected    Message = "Session Garbage Collected"
+	SessionEnded               Message = "Session Ended"
+	DownstreamTokenRevoked     Message = "Downstream Token Revoked" //nolint:gosec // this is not a credential
 
 	// Supervisor aggregated APIs logging.
 
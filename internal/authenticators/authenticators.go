@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package authenticators contains authenticator interfaces.
@@ -38,4 +38,12 @@ type Response struct {
 	User                   user.Info
 	DN                     string
 	ExtraRefreshAttributes map[string]string
+
+	// AdditionalClaims are the values of the attributes which were mapped to additional downstream claims,
+	// keyed by the name of the downstream claim. Can be nil.
+	AdditionalClaims map[string][]string
+
+	// UpstreamData is the raw identity data of the user, e.g. the DN and attributes of their LDAP entry,
+	// which is made available to identity transformations. Can be nil.
+	UpstreamData map[string]any
 }
@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package celtransformer is an implementation of upstream-to-downstream identity transformations
@@ -19,6 +19,7 @@ import (
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/ext"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"go.pinniped.dev/internal/idtransform"
 )
@@ -26,27 +27,49 @@ import (
 const (
 	usernameVariableName        = "username"
 	groupsVariableName          = "groups"
+	acrVariableName             = "acr"
+	amrVariableName             = "amr"
+	upstreamVariableName        = "upstream"
+	requestVariableName         = "request"
 	constStringVariableName     = "strConst"
 	constStringListVariableName = "strListConst"
 
 	DefaultPolicyRejectedAuthMessage = "authentication was rejected by a configured policy"
 )
 
+// requestVariable is the type of the `request` variable in CEL expressions. Its fields are available to CEL
+// expressions using the names from their cel struct tags, e.g. `request.clientID`.
+type requestVariable struct {
+	ClientID string   `cel:"clientID"`
+	Scopes   []string `cel:"scopes"`
+	Audience []string `cel:"audience"`
+}
+
+// The name of the requestVariable type in CEL, which is determined by the Go package name and type name.
+const requestVariableTypeName = "celtransformer.requestVariable"
+
 // CELTransformer can compile any number of transformation expression pipelines.
 // Each compiled pipeline can be cached in memory for later thread-safe evaluation.
 type CELTransformer struct {
 	compiler             *cel.Env
+	claimsCompiler       *cel.Env
 	maxExpressionRuntime time.Duration
 }
 
 // NewCELTransformer returns a CELTransformer.
 // A running process should only need one instance of a CELTransformer.
 func NewCELTransformer(maxExpressionRuntime time.Duration) (*CELTransformer, error) {
-	env, err := newEnv()
+	env, err := newEnv(true)
+	if err != nil {
+		return nil, err
+	}
+	// Claims expressions commonly return a map literal whose values have different types, e.g. a string claim
+	// and a boolean claim, so they are type-checked without requiring homogeneous map and list literals.
+	claimsEnv, err := newEnv(false)
 	if err != nil {
 		return nil, err
 	}
-	return &CELTransformer{compiler: env, maxExpressionRuntime: maxExpressionRuntime}, nil
+	return &CELTransformer{compiler: env, claimsCompiler: claimsEnv, maxExpressionRuntime: maxExpressionRuntime}, nil
 }
 
 // TransformationConstants can be used to make more variables available to compiled CEL expressions for convenience.
@@ -99,6 +122,7 @@ type CELTransformation interface {
 var _ CELTransformation = (*UsernameTransformation)(nil)
 var _ CELTransformation = (*GroupsTransformation)(nil)
 var _ CELTransformation = (*AllowAuthenticationPolicy)(nil)
+var _ CELTransformation = (*ClaimsTransformation)(nil)
 
 // UsernameTransformation is a CEL expression that can transform a username (or leave it unchanged).
 // It implements CELTransformation.
@@ -112,6 +136,12 @@ type GroupsTransformation struct {
 	Expression string
 }
 
+// ClaimsTransformation is a CEL expression that returns a map of additional claims for the downstream ID token,
+// keyed by claim name. It implements CELTransformation. It cannot change the username or group names.
+type ClaimsTransformation struct {
+	Expression string
+}
+
 // AllowAuthenticationPolicy is a CEL expression that can allow the authentication to proceed by returning true.
 // It implements CELTransformation. When the CEL expression returns false, the authentication is rejected and the
 // RejectedAuthenticationMessage is used. When RejectedAuthenticationMessage is empty, a default message will be
@@ -121,7 +151,7 @@ type AllowAuthenticationPolicy struct {
 	RejectedAuthenticationMessage string
 }
 
-func compileProgram(transformer *CELTransformer, expectedExpressionType *cel.Type, expr string) (cel.Program, error) {
+func compileProgram(compiler *cel.Env, expr string, allowedExpressionTypes ...*cel.Type) (cel.Program, error) {
 	if strings.TrimSpace(expr) == "" {
 		return nil, fmt.Errorf("cannot compile empty CEL expression")
 	}
@@ -129,19 +159,30 @@ func compileProgram(transformer *CELTransformer, expectedExpressionType *cel.Typ
 	// compile does both parsing and type checking. The parsing phase indicates whether the expression is
 	// syntactically valid and expands any macros present within the environment. Parsing and checking are
 	// more computationally expensive than evaluation, so parsing and checking are done in advance.
-	ast, issues := transformer.compiler.Compile(expr)
+	ast, issues := compiler.Compile(expr)
 	if issues != nil {
 		return nil, fmt.Errorf("CEL expression compile error: %s", issues.String())
 	}
 
 	// The compiler's type checker has determined the type of the expression's result.
-	// Check that it matches the type that we expect.
-	if ast.OutputType().String() != expectedExpressionType.String() {
-		return nil, fmt.Errorf("CEL expression should return type %q but returns type %q", expectedExpressionType, ast.OutputType())
+	// Check that it matches the type that we expect. Because the values of the upstream variable are dynamically
+	// typed, the type checker cannot always know the type of the result. In that case, the type of the result
+	// will be checked at evaluation time instead.
+	// A result type is also allowed when it is assignable to an allowed type, e.g. map(string, string) is
+	// assignable to map(string, dyn).
+	outputType := ast.OutputType()
+	typeAllowed := outputType.String() == cel.DynType.String()
+	for _, allowedType := range allowedExpressionTypes {
+		if outputType.String() == allowedType.String() || allowedType.IsAssignableType(outputType) {
+			typeAllowed = true
+		}
+	}
+	if !typeAllowed {
+		return nil, fmt.Errorf("CEL expression should return type %q but returns type %q", allowedExpressionTypes[0], outputType)
 	}
 
 	// The cel.Program is stateless, thread-safe, and cachable.
-	program, err := transformer.compiler.Program(ast,
+	program, err := compiler.Program(ast,
 		cel.InterruptCheckFrequency(100), // Kubernetes uses 100 here, so we'll copy that setting.
 		cel.EvalOptions(cel.OptOptimize), // Optimize certain things now rather than at evaluation time.
 	)
@@ -152,7 +193,7 @@ func compileProgram(transformer *CELTransformer, expectedExpressionType *cel.Typ
 }
 
 func (t *UsernameTransformation) compile(transformer *CELTransformer, consts *TransformationConstants) (idtransform.IdentityTransformation, error) {
-	program, err := compileProgram(transformer, cel.StringType, t.Expression)
+	program, err := compileProgram(transformer.compiler, t.Expression, cel.StringType)
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +208,7 @@ func (t *UsernameTransformation) compile(transformer *CELTransformer, consts *Tr
 }
 
 func (t *GroupsTransformation) compile(transformer *CELTransformer, consts *TransformationConstants) (idtransform.IdentityTransformation, error) {
-	program, err := compileProgram(transformer, cel.ListType(cel.StringType), t.Expression)
+	program, err := compileProgram(transformer.compiler, t.Expression, cel.ListType(cel.StringType), cel.ListType(cel.DynType))
 	if err != nil {
 		return nil, err
 	}
@@ -181,8 +222,26 @@ func (t *GroupsTransformation) compile(transformer *CELTransformer, consts *Tran
 	}, nil
 }
 
+func (t *ClaimsTransformation) compile(transformer *CELTransformer, consts *TransformationConstants) (idtransform.IdentityTransformation, error) {
+	// Any map with string keys is allowed, e.g. map(string, string) is assignable to map(string, dyn).
+	// The keys of an empty map literal have no known type, so they will be checked at evaluation time instead.
+	program, err := compileProgram(transformer.claimsCompiler, t.Expression,
+		cel.MapType(cel.StringType, cel.DynType), cel.MapType(cel.DynType, cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+	return &compiledClaimsTransformation{
+		baseCompiledTransformation: &baseCompiledTransformation{
+			program:              program,
+			consts:               consts,
+			sourceExpr:           t,
+			maxExpressionRuntime: transformer.maxExpressionRuntime,
+		},
+	}, nil
+}
+
 func (t *AllowAuthenticationPolicy) compile(transformer *CELTransformer, consts *TransformationConstants) (idtransform.IdentityTransformation, error) {
-	program, err := compileProgram(transformer, cel.BoolType, t.Expression)
+	program, err := compileProgram(transformer.compiler, t.Expression, cel.BoolType)
 	if err != nil {
 		return nil, err
 	}
@@ -215,13 +274,46 @@ type compiledGroupsTransformation struct {
 	*baseCompiledTransformation
 }
 
+// Implements idtransform.IdentityTransformation.
+type compiledClaimsTransformation struct {
+	*baseCompiledTransformation
+}
+
 // Implements idtransform.IdentityTransformation.
 type compiledAllowAuthenticationPolicy struct {
 	*baseCompiledTransformation
 	rejectedAuthenticationMessage string
 }
 
-func (c *baseCompiledTransformation) evalProgram(ctx context.Context, username string, groups []string) (ref.Val, error) {
+func (c *baseCompiledTransformation) evalProgram(
+	ctx context.Context,
+	username string,
+	groups []string,
+	authContext *idtransform.AuthenticationContext,
+) (ref.Val, error) {
+	if authContext == nil {
+		authContext = &idtransform.AuthenticationContext{}
+	}
+	amr := authContext.AMR
+	if amr == nil {
+		amr = []string{}
+	}
+	upstream := authContext.Upstream
+	if upstream == nil {
+		upstream = map[string]any{}
+	}
+	request := &requestVariable{
+		ClientID: authContext.Request.ClientID,
+		Scopes:   authContext.Request.Scopes,
+		Audience: authContext.Request.Audience,
+	}
+	if request.Scopes == nil {
+		request.Scopes = []string{}
+	}
+	if request.Audience == nil {
+		request.Audience = []string{}
+	}
+
 	// Limit the runtime of a CEL expression to avoid accidental very expensive expressions.
 	timeoutCtx, cancel := context.WithTimeout(ctx, c.maxExpressionRuntime)
 	defer cancel()
@@ -232,14 +324,23 @@ func (c *baseCompiledTransformation) evalProgram(ctx context.Context, username s
 	val, _, err := c.program.ContextEval(timeoutCtx, map[string]any{
 		usernameVariableName:        username,
 		groupsVariableName:          groups,
+		acrVariableName:             authContext.ACR,
+		amrVariableName:             amr,
+		upstreamVariableName:        upstream,
+		requestVariableName:         request,
 		constStringVariableName:     c.consts.StringConstants,
 		constStringListVariableName: c.consts.StringListConstants,
 	})
 	return val, err
 }
 
-func (c *compiledUsernameTransformation) Evaluate(ctx context.Context, username string, groups []string) (*idtransform.TransformationResult, error) {
-	val, err := c.evalProgram(ctx, username, groups)
+func (c *compiledUsernameTransformation) Evaluate(
+	ctx context.Context,
+	username string,
+	groups []string,
+	authContext *idtransform.AuthenticationContext,
+) (*idtransform.TransformationResult, error) {
+	val, err := c.evalProgram(ctx, username, groups, authContext)
 	if err != nil {
 		return nil, err
 	}
@@ -258,8 +359,13 @@ func (c *compiledUsernameTransformation) Evaluate(ctx context.Context, username
 	}, nil
 }
 
-func (c *compiledGroupsTransformation) Evaluate(ctx context.Context, username string, groups []string) (*idtransform.TransformationResult, error) {
-	val, err := c.evalProgram(ctx, username, groups)
+func (c *compiledGroupsTransformation) Evaluate(
+	ctx context.Context,
+	username string,
+	groups []string,
+	authContext *idtransform.AuthenticationContext,
+) (*idtransform.TransformationResult, error) {
+	val, err := c.evalProgram(ctx, username, groups, authContext)
 	if err != nil {
 		return nil, err
 	}
@@ -278,8 +384,46 @@ func (c *compiledGroupsTransformation) Evaluate(ctx context.Context, username st
 	}, nil
 }
 
-func (c *compiledAllowAuthenticationPolicy) Evaluate(ctx context.Context, username string, groups []string) (*idtransform.TransformationResult, error) {
-	val, err := c.evalProgram(ctx, username, groups)
+func (c *compiledClaimsTransformation) Evaluate(
+	ctx context.Context,
+	username string,
+	groups []string,
+	authContext *idtransform.AuthenticationContext,
+) (*idtransform.TransformationResult, error) {
+	val, err := c.evalProgram(ctx, username, groups, authContext)
+	if err != nil {
+		return nil, err
+	}
+	// Converting to a JSON object ensures that every claim value can be serialized into an ID token.
+	nativeValue, err := val.ConvertToNative(reflect.TypeOf(&structpb.Struct{}))
+	if err != nil {
+		return nil, fmt.Errorf("could not convert expression result to map of claims: %w", err)
+	}
+	structValue, ok := nativeValue.(*structpb.Struct)
+	if !ok {
+		return nil, fmt.Errorf("could not convert expression result to map of claims")
+	}
+	claims := structValue.AsMap()
+	for claimName := range claims {
+		if strings.TrimSpace(claimName) == "" {
+			return nil, fmt.Errorf("expression result contains an empty claim name, which is not allowed")
+		}
+	}
+	return &idtransform.TransformationResult{
+		Username:              username, // username is not modified by claims transformations
+		Groups:                groups,   // groups are not modified by claims transformations
+		AuthenticationAllowed: true,
+		AdditionalClaims:      claims,
+	}, nil
+}
+
+func (c *compiledAllowAuthenticationPolicy) Evaluate(
+	ctx context.Context,
+	username string,
+	groups []string,
+	authContext *idtransform.AuthenticationContext,
+) (*idtransform.TransformationResult, error) {
+	val, err := c.evalProgram(ctx, username, groups, authContext)
 	if err != nil {
 		return nil, err
 	}
@@ -319,23 +463,46 @@ func (c *compiledGroupsTransformation) Source() any {
 	return &CELTransformationSource{Expr: c.sourceExpr, Consts: c.consts}
 }
 
+func (c *compiledClaimsTransformation) Source() any {
+	return &CELTransformationSource{Expr: c.sourceExpr, Consts: c.consts}
+}
+
 func (c *compiledAllowAuthenticationPolicy) Source() any {
 	return &CELTransformationSource{Expr: c.sourceExpr, Consts: c.consts}
 }
 
-func newEnv() (*cel.Env, error) {
+func newEnv(homogeneousAggregateLiterals bool) (*cel.Env, error) {
 	// Note that Kubernetes uses CEL in several places, which are helpful to see as an example of
 	// how to configure the CEL compiler for production usage. Examples:
 	// https://github.com/kubernetes/kubernetes/blob/master/staging/src/k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy/compiler.go
 	// https://github.com/kubernetes/kubernetes/blob/master/staging/src/k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel/compilation.go
-	return cel.NewEnv(
+	options := commonEnvOptions()
+	if homogeneousAggregateLiterals {
+		// Check list and map literal entry types during type-checking.
+		options = append(options, cel.HomogeneousAggregateLiterals())
+	}
+	return cel.NewEnv(append(options,
+		// Make the fields of the request variable known to the type checker, using the names from the cel struct tags.
+		ext.NativeTypes(reflect.TypeOf(&requestVariable{}), ext.ParseStructTags(true)),
+
 		// Declare our variable without giving them values yet. By declaring them here, the type is known during
 		// the parsing/checking phase.
 		cel.Variable(usernameVariableName, cel.StringType),
 		cel.Variable(groupsVariableName, cel.ListType(cel.StringType)),
+		cel.Variable(acrVariableName, cel.StringType),
+		cel.Variable(amrVariableName, cel.ListType(cel.StringType)),
+		// The upstream data can be any JSON-like document, so the types of its values are not known until evaluation.
+		cel.Variable(upstreamVariableName, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(requestVariableName, cel.ObjectType(requestVariableTypeName)),
 		cel.Variable(constStringVariableName, cel.MapType(cel.StringType, cel.StringType)),
 		cel.Variable(constStringListVariableName, cel.MapType(cel.StringType, cel.ListType(cel.StringType))),
+	)...)
+}
 
+// commonEnvOptions returns the options which are shared by all CEL environments in this package,
+// regardless of which variables are declared in those environments.
+func commonEnvOptions() []cel.EnvOption {
+	return []cel.EnvOption{
 		// Enable the strings extensions.
 		// See https://github.com/google/cel-go/tree/master/ext#strings
 		// CEL also has other extensions for bas64 encoding/decoding and for math that we could choose to enable.
@@ -350,10 +517,7 @@ func newEnv() (*cel.Env, error) {
 		// an explicit timezone argument default to UTC.
 		cel.DefaultUTCTimeZone(true),
 
-		// Check list and map literal entry types during type-checking.
-		cel.HomogeneousAggregateLiterals(),
-
 		// Check for collisions in declarations now instead of later.
 		cel.EagerlyValidateDeclarations(true),
-	)
+	}
 }
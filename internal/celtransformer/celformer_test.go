@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package celtransformer
@@ -28,17 +28,19 @@ func TestTransformer(t *testing.T) {
 	cancel()
 
 	tests := []struct {
-		name       string
-		username   string
-		groups     []string
-		transforms []CELTransformation
-		consts     *TransformationConstants
-		ctx        context.Context
+		name        string
+		username    string
+		groups      []string
+		authContext *idtransform.AuthenticationContext
+		transforms  []CELTransformation
+		consts      *TransformationConstants
+		ctx         context.Context
 
 		wantUsername            string
 		wantGroups              []string
 		wantAuthRejected        bool
 		wantAuthRejectedMessage string
+		wantAdditionalClaims    map[string]any
 		wantCompileErr          string
 		wantEvaluationErr       string
 	}{
@@ -75,6 +77,155 @@ func TestTransformer(t *testing.T) {
 			wantUsername: "b:a:ryan",
 			wantGroups:   []string{"b:a:admins", "b:a:developers", "b:a:other"},
 		},
+		{
+			name:        "policies can use the acr and amr of the authentication",
+			username:    "ryan",
+			groups:      []string{"admins", "developers", "other"},
+			authContext: &idtransform.AuthenticationContext{ACR: "phr", AMR: []string{"pwd", "mfa"}},
+			transforms: []CELTransformation{
+				&AllowAuthenticationPolicy{
+					Expression:                    `!("admins" in groups) || ("mfa" in amr && acr == "phr")`,
+					RejectedAuthenticationMessage: "admins must use mfa",
+				},
+				&UsernameTransformation{Expression: `username + ":" + acr`},
+				&GroupsTransformation{Expression: `groups + amr.map(m, "amr:" + m)`},
+			},
+			wantUsername: "ryan:phr",
+			wantGroups:   []string{"admins", "amr:mfa", "amr:pwd", "developers", "other"},
+		},
+		{
+			name:        "policies can reject authentications based on the amr",
+			username:    "ryan",
+			groups:      []string{"admins", "developers", "other"},
+			authContext: &idtransform.AuthenticationContext{AMR: []string{"pwd"}},
+			transforms: []CELTransformation{
+				&AllowAuthenticationPolicy{
+					Expression:                    `!("admins" in groups) || "mfa" in amr`,
+					RejectedAuthenticationMessage: "admins must use mfa",
+				},
+			},
+			wantUsername:            "ryan",
+			wantGroups:              []string{"admins", "developers", "other"},
+			wantAuthRejected:        true,
+			wantAuthRejectedMessage: "admins must use mfa",
+		},
+		{
+			name:     "acr and amr are empty when the authentication context is unknown",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&AllowAuthenticationPolicy{Expression: `acr == "" && amr.size() == 0`},
+			},
+			wantUsername: "ryan",
+			wantGroups:   []string{"admins"},
+		},
+		{
+			name:     "transformations can use the raw upstream data",
+			username: "ryan",
+			groups:   []string{"admins", "developers", "other"},
+			authContext: &idtransform.AuthenticationContext{Upstream: map[string]any{
+				"email":          "ryan@example.com",
+				"email_verified": true,
+				"hd":             "example.com",
+				"attributes":     map[string]any{"department": []any{"eng", "ops"}},
+				"orgs":           []string{"org1", "org2"},
+			}},
+			transforms: []CELTransformation{
+				&AllowAuthenticationPolicy{
+					Expression:                    `upstream.email_verified && upstream.hd == "example.com"`,
+					RejectedAuthenticationMessage: "only verified example.com users",
+				},
+				&UsernameTransformation{Expression: `upstream.email`},
+				&GroupsTransformation{Expression: `groups + upstream.attributes.department.map(d, "dept:" + string(d)) + upstream.orgs`},
+				&GroupsTransformation{Expression: `has(upstream.missing) ? groups + ["unexpected"] : groups`},
+			},
+			wantUsername: "ryan@example.com",
+			wantGroups:   []string{"admins", "dept:eng", "dept:ops", "developers", "org1", "org2", "other"},
+		},
+		{
+			name:     "policies can reject authentications based on the raw upstream data",
+			username: "ryan",
+			groups:   []string{"admins"},
+			authContext: &idtransform.AuthenticationContext{Upstream: map[string]any{
+				"email_verified": false,
+			}},
+			transforms: []CELTransformation{
+				&AllowAuthenticationPolicy{
+					Expression:                    `has(upstream.email_verified) && upstream.email_verified == true`,
+					RejectedAuthenticationMessage: "email must be verified",
+				},
+			},
+			wantUsername:            "ryan",
+			wantGroups:              []string{"admins"},
+			wantAuthRejected:        true,
+			wantAuthRejectedMessage: "email must be verified",
+		},
+		{
+			name:     "transformations can use the downstream request",
+			username: "ryan",
+			groups:   []string{"admins", "developers", "other"},
+			authContext: &idtransform.AuthenticationContext{Request: idtransform.RequestContext{
+				ClientID: "client.oauth.pinniped.dev-my-app",
+				Scopes:   []string{"openid", "groups"},
+				Audience: []string{"my-cluster"},
+			}},
+			transforms: []CELTransformation{
+				&AllowAuthenticationPolicy{
+					Expression:                    `request.clientID.startsWith("client.oauth.pinniped.dev-") && "openid" in request.scopes`,
+					RejectedAuthenticationMessage: "only dynamic clients",
+				},
+				&UsernameTransformation{Expression: `username + "@" + request.clientID`},
+				&GroupsTransformation{Expression: `groups.filter(g, g != "admins" || "my-cluster" in request.audience)`},
+			},
+			wantUsername: "ryan@client.oauth.pinniped.dev-my-app",
+			wantGroups:   []string{"admins", "developers", "other"},
+		},
+		{
+			name:     "upstream and request are empty when the authentication context is unknown",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&AllowAuthenticationPolicy{
+					Expression: `upstream.size() == 0 && request.clientID == "" && request.scopes.size() == 0 && request.audience.size() == 0`,
+				},
+			},
+			wantUsername: "ryan",
+			wantGroups:   []string{"admins"},
+		},
+		{
+			name:     "accessing a missing key of the upstream data is an evaluation error",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&UsernameTransformation{Expression: `upstream.email`},
+			},
+			wantEvaluationErr: `identity transformation at index 0: no such key: email`,
+		},
+		{
+			name:     "upstream data of the wrong type is an evaluation error",
+			username: "ryan",
+			groups:   []string{"admins"},
+			authContext: &idtransform.AuthenticationContext{Upstream: map[string]any{
+				"email": 42.0,
+			}},
+			transforms: []CELTransformation{
+				&UsernameTransformation{Expression: `upstream.email`},
+			},
+			wantEvaluationErr: `identity transformation at index 0: could not convert expression result to string: type conversion error from Double to 'string'`,
+		},
+		{
+			name:     "unknown fields of the request are a compile error",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&UsernameTransformation{Expression: `request.clientId`},
+			},
+			wantCompileErr: here.Doc(`
+				CEL expression compile error: ERROR: <input>:1:8: undefined field 'clientId'
+				 | request.clientId
+				 | .......^`,
+			),
+		},
 		{
 			name:     "policies which return false cause the pipeline to stop running and return a rejected auth result",
 			username: "ryan",
@@ -748,6 +899,130 @@ func TestTransformer(t *testing.T) {
 			},
 			wantEvaluationErr: `identity transformation at index 0: no such key: x`,
 		},
+		{
+			name:     "claims transformations can add claims of any JSON type without changing the identity",
+			username: "ryan",
+			groups:   []string{"admins", "developers"},
+			authContext: &idtransform.AuthenticationContext{
+				Upstream: map[string]any{"email": "ryan@example.com", "tenant_id": float64(42)},
+				Request:  idtransform.RequestContext{ClientID: "client.oauth.pinniped.dev-my-app"},
+			},
+			consts: &TransformationConstants{StringConstants: map[string]string{"costCenter": "cc-123"}},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `{"email": upstream.email}`},
+				&ClaimsTransformation{Expression: `{
+					"tenant": upstream.tenant_id,
+					"cost_center": strConst.costCenter,
+					"is_admin": "admins" in groups,
+					"groups_count": size(groups),
+					"teams": ["a", "b"],
+					"nested": {"client": request.clientID},
+					"nothing": null,
+				}`},
+			},
+			wantUsername: "ryan",
+			wantGroups:   []string{"admins", "developers"},
+			wantAdditionalClaims: map[string]any{
+				"email":        "ryan@example.com",
+				"tenant":       float64(42),
+				"cost_center":  "cc-123",
+				"is_admin":     true,
+				"groups_count": float64(2),
+				"teams":        []any{"a", "b"},
+				"nested":       map[string]any{"client": "client.oauth.pinniped.dev-my-app"},
+				"nothing":      nil,
+			},
+		},
+		{
+			name:     "claims from later claims transformations replace claims of the same name from earlier claims transformations",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `{"a": "first", "b": "first"}`},
+				&UsernameTransformation{Expression: `"new-" + username`},
+				&ClaimsTransformation{Expression: `{"b": username}`},
+				&ClaimsTransformation{Expression: `{}`},
+			},
+			wantUsername:         "new-ryan",
+			wantGroups:           []string{"admins"},
+			wantAdditionalClaims: map[string]any{"a": "first", "b": "new-ryan"},
+		},
+		{
+			name:     "claims transformations which return no claims",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `{}`},
+			},
+			wantUsername: "ryan",
+			wantGroups:   []string{"admins"},
+		},
+		{
+			name:     "claims transformations which are followed by a policy which rejects the authentication",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `{"a": "b"}`},
+				&AllowAuthenticationPolicy{Expression: `false`},
+			},
+			wantUsername:            "ryan",
+			wantGroups:              []string{"admins"},
+			wantAuthRejected:        true,
+			wantAuthRejectedMessage: "authentication was rejected by a configured policy",
+		},
+		{
+			name:     "claims transformations which return a map from the dynamically typed upstream data",
+			username: "ryan",
+			groups:   []string{"admins"},
+			authContext: &idtransform.AuthenticationContext{Upstream: map[string]any{
+				"attributes": map[string]any{"department": []any{"eng"}},
+			}},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `upstream.attributes`},
+			},
+			wantUsername:         "ryan",
+			wantGroups:           []string{"admins"},
+			wantAdditionalClaims: map[string]any{"department": []any{"eng"}},
+		},
+		{
+			name:     "claims transformations which return the wrong type",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `["a", "b"]`},
+			},
+			wantCompileErr: `CEL expression should return type "map(string, dyn)" but returns type "list(string)"`,
+		},
+		{
+			name:     "claims transformations which return a map which does not have string keys",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `{1: "a"}`},
+			},
+			wantEvaluationErr: `identity transformation at index 0: could not convert expression result to map of claims: unsupported type conversion from 'int' to string`,
+		},
+		{
+			name:     "claims transformations which return a dynamically typed value which is not a map at runtime",
+			username: "ryan",
+			groups:   []string{"admins"},
+			authContext: &idtransform.AuthenticationContext{Upstream: map[string]any{
+				"email": "ryan@example.com",
+			}},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `upstream.email`},
+			},
+			wantEvaluationErr: `identity transformation at index 0: could not convert expression result to map of claims: unsupported native conversion from string to '*structpb.Struct'`,
+		},
+		{
+			name:     "claims transformations which return an empty claim name",
+			username: "ryan",
+			groups:   []string{"admins"},
+			transforms: []CELTransformation{
+				&ClaimsTransformation{Expression: `{" ": "a"}`},
+			},
+			wantEvaluationErr: `identity transformation at index 0: expression result contains an empty claim name, which is not allowed`,
+		},
 		{
 			name:     "using string list constants which were not were provided",
 			username: "ryan",
@@ -813,7 +1088,7 @@ func TestTransformer(t *testing.T) {
 				ctx = tt.ctx
 			}
 
-			result, err := pipeline.Evaluate(ctx, tt.username, tt.groups)
+			result, err := pipeline.Evaluate(ctx, tt.username, tt.groups, tt.authContext)
 			if tt.wantEvaluationErr != "" {
 				require.EqualError(t, err, tt.wantEvaluationErr)
 				return // the rest of the test doesn't make sense when there was an evaluation error
@@ -824,6 +1099,7 @@ func TestTransformer(t *testing.T) {
 			require.Equal(t, tt.wantGroups, result.Groups)
 			require.Equal(t, !tt.wantAuthRejected, result.AuthenticationAllowed, "AuthenticationAllowed had unexpected value")
 			require.Equal(t, tt.wantAuthRejectedMessage, result.RejectedAuthenticationMessage)
+			require.Equal(t, tt.wantAdditionalClaims, result.AdditionalClaims)
 
 			require.Equal(t, expectedPipelineSource, pipeline.Source())
 		})
@@ -858,7 +1134,7 @@ func TestTypicalPerformanceAndThreadSafety(t *testing.T) {
 	sort.Strings(wantGroups)
 
 	// Before looking at performance, check that the behavior of the function is correct.
-	result, err := pipeline.Evaluate(context.Background(), "ryan", groups)
+	result, err := pipeline.Evaluate(context.Background(), "ryan", groups, nil)
 	require.NoError(t, err)
 	require.Equal(t, "username_prefix:ryan", result.Username)
 	require.Equal(t, wantGroups, result.Groups)
@@ -870,7 +1146,7 @@ func TestTypicalPerformanceAndThreadSafety(t *testing.T) {
 	iterations := 1000
 	start := time.Now()
 	for range iterations {
-		_, _ = pipeline.Evaluate(context.Background(), "ryan", groups)
+		_, _ = pipeline.Evaluate(context.Background(), "ryan", groups, nil)
 	}
 	elapsed := time.Since(start)
 	t.Logf("TestTypicalPerformanceAndThreadSafety %d iterations of Evaluate took %s; average runtime %s", iterations, elapsed, elapsed/time.Duration(iterations))
@@ -889,7 +1165,7 @@ func TestTypicalPerformanceAndThreadSafety(t *testing.T) {
 		go func() {
 			defer wg.Done() // decrement WaitGroup counter when this goroutine finishes
 			for range iterations * 2 {
-				localResult, localErr := pipeline.Evaluate(context.Background(), "ryan", groups)
+				localResult, localErr := pipeline.Evaluate(context.Background(), "ryan", groups, nil)
 				require.NoError(t, localErr)
 				require.Equal(t, "username_prefix:ryan", localResult.Username)
 				require.Equal(t, wantGroups, localResult.Groups)
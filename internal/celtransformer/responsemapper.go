@@ -0,0 +1,168 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package celtransformer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+)
+
+const (
+	userInfoVariableName       = "userInfo"
+	groupsResponseVariableName = "groupsResponse"
+)
+
+// ResponseMapper can compile CEL expressions which extract parts of an identity, like a username or a list of
+// group names, from the decoded JSON responses of an upstream identity provider's APIs.
+type ResponseMapper struct {
+	compiler             *cel.Env
+	maxExpressionRuntime time.Duration
+}
+
+// NewResponseMapper returns a ResponseMapper.
+// A running process should only need one instance of a ResponseMapper.
+func NewResponseMapper(maxExpressionRuntime time.Duration) (*ResponseMapper, error) {
+	env, err := cel.NewEnv(append(commonEnvOptions(),
+		// Check list and map literal entry types during type-checking.
+		cel.HomogeneousAggregateLiterals(),
+
+		// The responses can be any JSON document, so their types are not known until evaluation time.
+		cel.Variable(userInfoVariableName, cel.DynType),
+		cel.Variable(groupsResponseVariableName, cel.DynType),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseMapper{compiler: env, maxExpressionRuntime: maxExpressionRuntime}, nil
+}
+
+// UpstreamResponses holds the decoded JSON responses which are made available to compiled response expressions.
+// The values should be the types produced by decoding JSON, e.g. map[string]any, []any, string, float64, bool, or nil.
+// Integers may also be given as int64.
+type UpstreamResponses struct {
+	// UserInfo is available to CEL expressions as the variable `userInfo`.
+	UserInfo any
+	// GroupsResponse is available to CEL expressions as the variable `groupsResponse`.
+	GroupsResponse any
+}
+
+// CompiledStringExpression is a compiled CEL expression which returns a string.
+// It can be cached in memory and evaluated repeatedly and in a thread-safe way.
+type CompiledStringExpression struct {
+	*compiledResponseExpression
+}
+
+// CompiledStringListExpression is a compiled CEL expression which returns a list of strings.
+// It can be cached in memory and evaluated repeatedly and in a thread-safe way.
+type CompiledStringListExpression struct {
+	*compiledResponseExpression
+}
+
+type compiledResponseExpression struct {
+	program              cel.Program
+	maxExpressionRuntime time.Duration
+}
+
+// CompileStringExpression compiles a CEL expression which must return a string.
+func (m *ResponseMapper) CompileStringExpression(expr string) (*CompiledStringExpression, error) {
+	compiled, err := m.compile(expr, cel.StringType)
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledStringExpression{compiledResponseExpression: compiled}, nil
+}
+
+// CompileStringListExpression compiles a CEL expression which must return a list of strings.
+func (m *ResponseMapper) CompileStringListExpression(expr string) (*CompiledStringListExpression, error) {
+	compiled, err := m.compile(expr, cel.ListType(cel.StringType), cel.ListType(cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledStringListExpression{compiledResponseExpression: compiled}, nil
+}
+
+func (m *ResponseMapper) compile(expr string, allowedExpressionTypes ...*cel.Type) (*compiledResponseExpression, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("cannot compile empty CEL expression")
+	}
+
+	ast, issues := m.compiler.Compile(expr)
+	if issues != nil {
+		return nil, fmt.Errorf("CEL expression compile error: %s", issues.String())
+	}
+
+	// Because the variables are dynamically typed, the type checker often cannot know the type of the result.
+	// In that case, the type of the result will be checked at evaluation time instead.
+	outputType := ast.OutputType().String()
+	typeAllowed := outputType == cel.DynType.String()
+	for _, allowedType := range allowedExpressionTypes {
+		if outputType == allowedType.String() {
+			typeAllowed = true
+		}
+	}
+	if !typeAllowed {
+		return nil, fmt.Errorf("CEL expression should return type %q but returns type %q", allowedExpressionTypes[0], ast.OutputType())
+	}
+
+	program, err := m.compiler.Program(ast,
+		cel.InterruptCheckFrequency(100), // Kubernetes uses 100 here, so we'll copy that setting.
+		cel.EvalOptions(cel.OptOptimize), // Optimize certain things now rather than at evaluation time.
+	)
+	if err != nil {
+		return nil, fmt.Errorf("CEL expression program construction error: %w", err)
+	}
+
+	return &compiledResponseExpression{program: program, maxExpressionRuntime: m.maxExpressionRuntime}, nil
+}
+
+func (c *compiledResponseExpression) evalProgram(ctx context.Context, responses *UpstreamResponses, nativeType reflect.Type) (any, error) {
+	// Limit the runtime of a CEL expression to avoid accidental very expensive expressions.
+	timeoutCtx, cancel := context.WithTimeout(ctx, c.maxExpressionRuntime)
+	defer cancel()
+
+	val, _, err := c.program.ContextEval(timeoutCtx, map[string]any{
+		userInfoVariableName:       responses.UserInfo,
+		groupsResponseVariableName: responses.GroupsResponse,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nativeValue, err := val.ConvertToNative(nativeType)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert expression result to %s: %w", nativeType, err)
+	}
+	return nativeValue, nil
+}
+
+// Evaluate evaluates the expression using the given responses.
+func (c *CompiledStringExpression) Evaluate(ctx context.Context, responses *UpstreamResponses) (string, error) {
+	nativeValue, err := c.evalProgram(ctx, responses, reflect.TypeOf(""))
+	if err != nil {
+		return "", err
+	}
+	stringValue, ok := nativeValue.(string)
+	if !ok {
+		return "", fmt.Errorf("could not convert expression result to string")
+	}
+	return stringValue, nil
+}
+
+// Evaluate evaluates the expression using the given responses.
+func (c *CompiledStringListExpression) Evaluate(ctx context.Context, responses *UpstreamResponses) ([]string, error) {
+	nativeValue, err := c.evalProgram(ctx, responses, reflect.TypeOf([]string{}))
+	if err != nil {
+		return nil, err
+	}
+	stringSliceValue, ok := nativeValue.([]string)
+	if !ok {
+		return nil, fmt.Errorf("could not convert expression result to []string")
+	}
+	return stringSliceValue, nil
+}
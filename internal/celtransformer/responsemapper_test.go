@@ -0,0 +1,218 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package celtransformer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseMapper(t *testing.T) {
+	userInfo := map[string]any{
+		"username": "ryan",
+		"id":       int64(1234567890),
+		"score":    12.5,
+		"email":    "ryan@example.com",
+		"admin":    true,
+		"teams":    []any{"team1", "team2"},
+	}
+	groupsResponse := []any{
+		map[string]any{"full_path": "org/group1", "id": int64(1)},
+		map[string]any{"full_path": "org/group2", "id": int64(2)},
+	}
+
+	veryLargeGroupsResponse := make([]any, 10000)
+	for i := range 10000 {
+		veryLargeGroupsResponse[i] = map[string]any{"full_path": fmt.Sprintf("g%d", i)}
+	}
+
+	alreadyCancelledContext, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name           string
+		stringExpr     string
+		stringListExpr string
+		responses      *UpstreamResponses
+		ctx            context.Context
+
+		wantString        string
+		wantStringList    []string
+		wantCompileErr    string
+		wantEvaluationErr string
+	}{
+		{
+			name:       "string field from userInfo",
+			stringExpr: `userInfo.username`,
+			responses:  &UpstreamResponses{UserInfo: userInfo},
+			wantString: "ryan",
+		},
+		{
+			name:       "integer field from userInfo converted to a string",
+			stringExpr: `string(userInfo.id)`,
+			responses:  &UpstreamResponses{UserInfo: userInfo},
+			wantString: "1234567890",
+		},
+		{
+			name:       "string expression using the strings extension",
+			stringExpr: `userInfo.email.split("@")[0] + ":" + string(userInfo.id)`,
+			responses:  &UpstreamResponses{UserInfo: userInfo},
+			wantString: "ryan:1234567890",
+		},
+		{
+			name:           "string list from groupsResponse",
+			stringListExpr: `groupsResponse.map(g, g.full_path)`,
+			responses:      &UpstreamResponses{UserInfo: userInfo, GroupsResponse: groupsResponse},
+			wantStringList: []string{"org/group1", "org/group2"},
+		},
+		{
+			name:           "string list from userInfo",
+			stringListExpr: `userInfo.teams`,
+			responses:      &UpstreamResponses{UserInfo: userInfo},
+			wantStringList: []string{"team1", "team2"},
+		},
+		{
+			name:           "string list combining both responses",
+			stringListExpr: `userInfo.teams + groupsResponse.map(g, g.full_path)`,
+			responses:      &UpstreamResponses{UserInfo: userInfo, GroupsResponse: groupsResponse},
+			wantStringList: []string{"team1", "team2", "org/group1", "org/group2"},
+		},
+		{
+			name:           "string list literal",
+			stringListExpr: `["a", "b"]`,
+			responses:      &UpstreamResponses{},
+			wantStringList: []string{"a", "b"},
+		},
+		{
+			name:           "string list which handles a null groupsResponse",
+			stringListExpr: `groupsResponse == null ? [] : groupsResponse.map(g, g.full_path)`,
+			responses:      &UpstreamResponses{UserInfo: userInfo},
+			wantStringList: []string{},
+		},
+		{
+			name:           "empty string expression",
+			stringExpr:     `  `,
+			wantCompileErr: "cannot compile empty CEL expression",
+		},
+		{
+			name:           "empty string list expression",
+			stringListExpr: "\t",
+			wantCompileErr: "cannot compile empty CEL expression",
+		},
+		{
+			name:           "string expression with a syntax error",
+			stringExpr:     `userInfo.`,
+			wantCompileErr: "CEL expression compile error: ERROR: <input>:1:10: Syntax error: no viable alternative at input '.'\n | userInfo.\n | .........^",
+		},
+		{
+			name:           "string expression which returns the wrong type",
+			stringExpr:     `1`,
+			wantCompileErr: `CEL expression should return type "string" but returns type "int"`,
+		},
+		{
+			name:           "string list expression which returns the wrong type",
+			stringListExpr: `"a"`,
+			wantCompileErr: `CEL expression should return type "list(string)" but returns type "string"`,
+		},
+		{
+			name:           "string list expression which returns a list of the wrong type",
+			stringListExpr: `[1, 2]`,
+			wantCompileErr: `CEL expression should return type "list(string)" but returns type "list(int)"`,
+		},
+		{
+			name:              "string expression which returns the wrong type at evaluation time",
+			stringExpr:        `userInfo.id`,
+			responses:         &UpstreamResponses{UserInfo: userInfo},
+			wantEvaluationErr: "could not convert expression result to string: unsupported type conversion from 'int' to string",
+		},
+		{
+			name:              "string expression which returns a bool at evaluation time",
+			stringExpr:        `userInfo.admin`,
+			responses:         &UpstreamResponses{UserInfo: userInfo},
+			wantEvaluationErr: "could not convert expression result to string: type conversion error from bool to 'string'",
+		},
+		{
+			name:              "string list expression which returns the wrong type at evaluation time",
+			stringListExpr:    `userInfo.username`,
+			responses:         &UpstreamResponses{UserInfo: userInfo},
+			wantEvaluationErr: "could not convert expression result to []string: unsupported native conversion from string to '[]string'",
+		},
+		{
+			name:              "string list expression which returns a list of the wrong type at evaluation time",
+			stringListExpr:    `groupsResponse.map(g, g.id)`,
+			responses:         &UpstreamResponses{UserInfo: userInfo, GroupsResponse: groupsResponse},
+			wantEvaluationErr: "could not convert expression result to []string: unsupported type conversion from 'int' to string",
+		},
+		{
+			name:              "string expression which references a missing key",
+			stringExpr:        `userInfo.missing`,
+			responses:         &UpstreamResponses{UserInfo: userInfo},
+			wantEvaluationErr: "no such key: missing",
+		},
+		{
+			name:              "string list expression which references a null groupsResponse",
+			stringListExpr:    `groupsResponse.map(g, g.full_path)`,
+			responses:         &UpstreamResponses{UserInfo: userInfo},
+			wantEvaluationErr: "got 'types.Null', expected iterable type",
+		},
+		{
+			name:              "slow expressions are canceled by the cancel context after partial evaluation",
+			stringListExpr:    `groupsResponse.filter(x, groupsResponse.all(y, true)).map(g, g.full_path)`,
+			responses:         &UpstreamResponses{UserInfo: userInfo, GroupsResponse: veryLargeGroupsResponse},
+			ctx:               alreadyCancelledContext,
+			wantEvaluationErr: "operation interrupted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapper, err := NewResponseMapper(5 * time.Second)
+			require.NoError(t, err)
+
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			if tt.stringListExpr != "" {
+				compiled, err := mapper.CompileStringListExpression(tt.stringListExpr)
+				if tt.wantCompileErr != "" {
+					require.EqualError(t, err, tt.wantCompileErr)
+					return
+				}
+				require.NoError(t, err)
+
+				result, err := compiled.Evaluate(ctx, tt.responses)
+				if tt.wantEvaluationErr != "" {
+					require.EqualError(t, err, tt.wantEvaluationErr)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, tt.wantStringList, result)
+				return
+			}
+
+			compiled, err := mapper.CompileStringExpression(tt.stringExpr)
+			if tt.wantCompileErr != "" {
+				require.EqualError(t, err, tt.wantCompileErr)
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := compiled.Evaluate(ctx, tt.responses)
+			if tt.wantEvaluationErr != "" {
+				require.EqualError(t, err, tt.wantEvaluationErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantString, result)
+		})
+	}
+}
@@ -83,6 +83,7 @@ func FromPath(ctx context.Context, path string, setAllowedCiphers ptls.SetAllowe
 	maybeSetEndpointDefault(&config.Endpoints.HTTP, Endpoint{
 		Network: NetworkDisabled,
 	})
+	maybeSetMTLSEndpointDefault(&config.Endpoints.MTLS)
 
 	if err := validateEndpoint(*config.Endpoints.HTTPS); err != nil {
 		return nil, fmt.Errorf("validate https endpoint: %w", err)
@@ -93,6 +94,9 @@ func FromPath(ctx context.Context, path string, setAllowedCiphers ptls.SetAllowe
 	if err := validateAdditionalHTTPEndpointRequirements(*config.Endpoints.HTTP); err != nil {
 		return nil, fmt.Errorf("validate http endpoint: %w", err)
 	}
+	if err := validateMTLSEndpoint(*config.Endpoints.MTLS); err != nil {
+		return nil, fmt.Errorf("validate mtls endpoint: %w", err)
+	}
 	if err := validateAtLeastOneEnabledEndpoint(*config.Endpoints.HTTPS, *config.Endpoints.HTTP); err != nil {
 		return nil, fmt.Errorf("validate endpoints: %w", err)
 	}
@@ -114,6 +118,13 @@ func maybeSetEndpointDefault(endpoint **Endpoint, defaultEndpoint Endpoint) {
 	*endpoint = &defaultEndpoint
 }
 
+func maybeSetMTLSEndpointDefault(endpoint **MTLSEndpoint) {
+	if *endpoint != nil {
+		return
+	}
+	*endpoint = &MTLSEndpoint{Endpoint: Endpoint{Network: NetworkDisabled}}
+}
+
 func maybeSetAPIGroupSuffixDefault(apiGroupSuffix **string) {
 	if *apiGroupSuffix == nil {
 		*apiGroupSuffix = ptr.To(groupsuffix.PinnipedDefaultSuffix)
@@ -168,6 +179,22 @@ func validateAdditionalHTTPEndpointRequirements(endpoint Endpoint) error {
 	return nil
 }
 
+func validateMTLSEndpoint(endpoint MTLSEndpoint) error {
+	if err := validateEndpoint(endpoint.Endpoint); err != nil {
+		return err
+	}
+	if endpoint.Network == NetworkDisabled {
+		if endpoint.AdvertisedPort != 0 {
+			return fmt.Errorf("advertisedPort set to %d when disabled, should be empty", endpoint.AdvertisedPort)
+		}
+		return nil
+	}
+	if endpoint.AdvertisedPort < 1 || endpoint.AdvertisedPort > 65535 {
+		return fmt.Errorf("advertisedPort must be between 1 and 65535 with %q network", endpoint.Network)
+	}
+	return nil
+}
+
 func validateAtLeastOneEnabledEndpoint(endpoints ...Endpoint) error {
 	for _, endpoint := range endpoints {
 		if endpoint.Network != NetworkDisabled {
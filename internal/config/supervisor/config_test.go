@@ -41,6 +41,10 @@ func TestFromPath(t *testing.T) {
 				  http:
 				    network: tcp
 				    address: 127.0.0.1:1234
+				  mtls:
+				    network: tcp
+				    address: :8444
+				    advertisedPort: 443
 				insecureAcceptExternalUnencryptedHttpRequests: false
 				log:
 				  level: info
@@ -74,6 +78,13 @@ func TestFromPath(t *testing.T) {
 						Network: "tcp",
 						Address: "127.0.0.1:1234",
 					},
+					MTLS: &MTLSEndpoint{
+						Endpoint: Endpoint{
+							Network: "tcp",
+							Address: ":8444",
+						},
+						AdvertisedPort: 443,
+					},
 				},
 				Log: plog.LogSpec{
 					Level:  plog.LevelInfo,
@@ -128,6 +139,11 @@ func TestFromPath(t *testing.T) {
 					HTTP: &Endpoint{
 						Network: "disabled",
 					},
+					MTLS: &MTLSEndpoint{
+						Endpoint: Endpoint{
+							Network: "disabled",
+						},
+					},
 				},
 				AggregatedAPIServerPort: ptr.To[int64](10250),
 				Audit: AuditSpec{
@@ -160,6 +176,11 @@ func TestFromPath(t *testing.T) {
 					HTTP: &Endpoint{
 						Network: "disabled",
 					},
+					MTLS: &MTLSEndpoint{
+						Endpoint: Endpoint{
+							Network: "disabled",
+						},
+					},
 				},
 				AggregatedAPIServerPort: ptr.To[int64](10250),
 				Audit: AuditSpec{
@@ -277,6 +298,58 @@ func TestFromPath(t *testing.T) {
 			`),
 			wantError: `validate https endpoint: address must be set with "unix" network`,
 		},
+		{
+			name: "invalid mtls endpoint",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  mtls:
+				    network: baz
+			`),
+			wantError: `validate mtls endpoint: unknown network "baz"`,
+		},
+		{
+			name: "mtls endpoint enabled without advertisedPort",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  mtls:
+				    network: tcp
+				    address: :8444
+			`),
+			wantError: `validate mtls endpoint: advertisedPort must be between 1 and 65535 with "tcp" network`,
+		},
+		{
+			name: "mtls endpoint advertisedPort too large",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  mtls:
+				    network: tcp
+				    address: :8444
+				    advertisedPort: 65536
+			`),
+			wantError: `validate mtls endpoint: advertisedPort must be between 1 and 65535 with "tcp" network`,
+		},
+		{
+			name: "mtls endpoint disabled with advertisedPort",
+			yaml: here.Doc(`
+				---
+				names:
+				  defaultTLSCertificateSecret: my-secret-name
+				endpoints:
+				  mtls:
+				    network: disabled
+				    advertisedPort: 443
+			`),
+			wantError: `validate mtls endpoint: advertisedPort set to 443 when disabled, should be empty`,
+		},
 		{
 			name: "Missing defaultTLSCertificateSecret name",
 			yaml: here.Doc(`
@@ -57,11 +57,21 @@ type NamesConfigSpec struct {
 }
 
 type Endpoints struct {
-	HTTPS *Endpoint `json:"https,omitempty"`
-	HTTP  *Endpoint `json:"http,omitempty"`
+	HTTPS *Endpoint     `json:"https,omitempty"`
+	HTTP  *Endpoint     `json:"http,omitempty"`
+	MTLS  *MTLSEndpoint `json:"mtls,omitempty"`
 }
 
 type Endpoint struct {
 	Network string `json:"network"`
 	Address string `json:"address"`
 }
+
+// MTLSEndpoint configures the listener which asks clients for TLS client certificates, for use by OIDCClients
+// which use the tls_client_auth client authentication method.
+type MTLSEndpoint struct {
+	Endpoint `json:",inline"`
+	// AdvertisedPort is the port on the host of each FederationDomain's issuer at which clients can reach this
+	// listener. It is used to advertise the mtls_endpoint_aliases in each FederationDomain's discovery document.
+	AdvertisedPort int `json:"advertisedPort,omitempty"`
+}
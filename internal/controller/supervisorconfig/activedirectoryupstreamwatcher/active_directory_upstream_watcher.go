@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package activedirectoryupstreamwatcher implements a controller which watches ActiveDirectoryIdentityProviders.
@@ -19,6 +19,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/utils/ptr"
 
 	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
 	supervisorclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
@@ -58,6 +59,11 @@ const (
 	// - perform nested group search by default.
 	defaultActiveDirectoryGroupSearchFilter = "(&(objectClass=group)(member:1.2.840.113556.1.4.1941:={}))"
 
+	// - is a group.
+	// - has a member that matches the DN of the user or group being searched.
+	// - does not perform nested group search, because the recursiveSearch group search strategy repeats the search instead.
+	defaultActiveDirectoryRecursiveGroupSearchFilter = "(&(objectClass=group)(member={}))"
+
 	sAMAccountNameAttribute = "sAMAccountName"
 	// pwdLastSetAttribute is the date and time that the password for this account was last changed.
 	// https://docs.microsoft.com/en-us/windows/win32/adschema/a-pwdlastset
@@ -205,6 +211,9 @@ func (g *activeDirectoryUpstreamGenericLDAPGroupSearch) Base() string {
 
 func (g *activeDirectoryUpstreamGenericLDAPGroupSearch) Filter() string {
 	if len(g.groupSearch.Filter) == 0 {
+		if g.groupSearch.Strategy.Type == idpv1alpha1.ActiveDirectoryGroupSearchStrategyRecursiveSearch {
+			return defaultActiveDirectoryRecursiveGroupSearchFilter
+		}
 		return defaultActiveDirectoryGroupSearchFilter
 	}
 	return g.groupSearch.Filter
@@ -342,16 +351,20 @@ func (c *activeDirectoryWatcherController) validateUpstream(ctx context.Context,
 		ResourceUID: upstream.UID,
 		Host:        spec.Host,
 		UserSearch: upstreamldap.UserSearchConfig{
-			Base:              spec.UserSearch.Base,
-			Filter:            adUpstreamImpl.Spec().UserSearch().Filter(),
-			UsernameAttribute: adUpstreamImpl.Spec().UserSearch().UsernameAttribute(),
-			UIDAttribute:      adUpstreamImpl.Spec().UserSearch().UIDAttribute(),
+			Base:                    spec.UserSearch.Base,
+			Filter:                  adUpstreamImpl.Spec().UserSearch().Filter(),
+			UsernameAttribute:       adUpstreamImpl.Spec().UserSearch().UsernameAttribute(),
+			UIDAttribute:            adUpstreamImpl.Spec().UserSearch().UIDAttribute(),
+			AdditionalClaimMappings: spec.UserSearch.Attributes.AdditionalClaimMappings,
 		},
 		GroupSearch: upstreamldap.GroupSearchConfig{
 			Base:                   spec.GroupSearch.Base,
 			Filter:                 adUpstreamImpl.Spec().GroupSearch().Filter(),
 			UserAttributeForFilter: adUpstreamImpl.Spec().GroupSearch().UserAttributeForFilter(),
 			GroupNameAttribute:     adUpstreamImpl.Spec().GroupSearch().GroupNameAttribute(),
+			Strategy:               upstreamldap.GroupSearchStrategy(spec.GroupSearch.Strategy.Type),
+			MemberOfAttribute:      spec.GroupSearch.Strategy.MemberOfAttribute,
+			MaxDepth:               int(ptr.Deref(spec.GroupSearch.Strategy.MaxDepth, 0)),
 			SkipGroupRefresh:       spec.GroupSearch.SkipGroupRefresh,
 		},
 		Dialer: c.ldapDialer,
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package supervisorconfig
@@ -6,10 +6,10 @@ package supervisorconfig
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net/url"
 	"sort"
 	"strings"
-	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -30,6 +30,7 @@ import (
 	"go.pinniped.dev/internal/controller/conditionsutil"
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
+	"go.pinniped.dev/internal/federationdomain/transformscompiler"
 	"go.pinniped.dev/internal/idtransform"
 	"go.pinniped.dev/internal/plog"
 )
@@ -45,6 +46,7 @@ const (
 	typeIdentityProvidersDisplayNamesUnique  = "IdentityProvidersDisplayNamesUnique"
 	typeIdentityProvidersAPIGroupSuffixValid = "IdentityProvidersObjectRefAPIGroupSuffixValid"
 	typeIdentityProvidersObjectRefKindValid  = "IdentityProvidersObjectRefKindValid"
+	typeTransformsLibrariesValid             = "TransformsLibrariesValid"
 	typeTransformsExpressionsValid           = "TransformsExpressionsValid"
 	typeTransformsExamplesPassed             = "TransformsExamplesPassed"
 
@@ -57,6 +59,7 @@ const (
 	reasonDuplicateDisplayNames                       = "DuplicateDisplayNames"
 	reasonAPIGroupNameUnrecognized                    = "APIGroupUnrecognized"
 	reasonKindUnrecognized                            = "KindUnrecognized"
+	reasonInvalidTransformsLibraries                  = "InvalidTransformsLibraries"
 	reasonInvalidTransformsExpressions                = "InvalidTransformsExpressions"
 	reasonTransformsExamplesFailed                    = "TransformsExamplesFailed"
 
@@ -64,8 +67,11 @@ const (
 	kindOIDCIdentityProvider            = "OIDCIdentityProvider"
 	kindActiveDirectoryIdentityProvider = "ActiveDirectoryIdentityProvider"
 	kindGitHubIdentityProvider          = "GitHubIdentityProvider"
+	kindSAMLIdentityProvider            = "SAMLIdentityProvider"
+	kindOAuth2IdentityProvider          = "OAuth2IdentityProvider"
+	kindLocalUserIdentityProvider       = "LocalUserIdentityProvider"
 
-	celTransformerMaxExpressionRuntime = 5 * time.Second
+	celTransformerMaxExpressionRuntime = transformscompiler.MaxExpressionRuntime
 )
 
 // FederationDomainsSetter can be notified of all known valid providers with its SetFederationDomains function.
@@ -82,13 +88,27 @@ type federationDomainWatcherController struct {
 	client                  supervisorclientset.Interface
 
 	federationDomainInformer                configinformers.FederationDomainInformer
+	identityTransformLibraryInformer        configinformers.IdentityTransformLibraryInformer
 	oidcIdentityProviderInformer            idpinformers.OIDCIdentityProviderInformer
 	ldapIdentityProviderInformer            idpinformers.LDAPIdentityProviderInformer
 	activeDirectoryIdentityProviderInformer idpinformers.ActiveDirectoryIdentityProviderInformer
 	githubIdentityProviderInformer          idpinformers.GitHubIdentityProviderInformer
+	samlIdentityProviderInformer            idpinformers.SAMLIdentityProviderInformer
+	oauth2IdentityProviderInformer          idpinformers.OAuth2IdentityProviderInformer
+	localUserIdentityProviderInformer       idpinformers.LocalUserIdentityProviderInformer
 
 	celTransformer *celtransformer.CELTransformer
 	allowedKinds   sets.Set[string]
+
+	// compiledLibraries caches the compiled IdentityTransformLibraries, so each library is only compiled once
+	// no matter how many FederationDomain identity providers use it, and is only compiled again when it changes.
+	compiledLibraries map[types.NamespacedName]*compiledTransformsLibrary
+}
+
+// compiledTransformsLibrary is the cached result of compiling an IdentityTransformLibrary.
+type compiledTransformsLibrary struct {
+	spec    supervisorconfigv1alpha1.IdentityTransformLibrarySpec // the spec which was compiled
+	library *transformscompiler.Library
 }
 
 // NewFederationDomainWatcherController creates a controllerlib.Controller that watches
@@ -99,13 +119,17 @@ func NewFederationDomainWatcherController(
 	clock clock.Clock,
 	client supervisorclientset.Interface,
 	federationDomainInformer configinformers.FederationDomainInformer,
+	identityTransformLibraryInformer configinformers.IdentityTransformLibraryInformer,
 	oidcIdentityProviderInformer idpinformers.OIDCIdentityProviderInformer,
 	ldapIdentityProviderInformer idpinformers.LDAPIdentityProviderInformer,
 	activeDirectoryIdentityProviderInformer idpinformers.ActiveDirectoryIdentityProviderInformer,
 	githubProviderInformer idpinformers.GitHubIdentityProviderInformer,
+	samlProviderInformer idpinformers.SAMLIdentityProviderInformer,
+	oauth2ProviderInformer idpinformers.OAuth2IdentityProviderInformer,
+	localUserProviderInformer idpinformers.LocalUserIdentityProviderInformer,
 	withInformer pinnipedcontroller.WithInformerOptionFunc,
 ) controllerlib.Controller {
-	allowedKinds := sets.New(kindActiveDirectoryIdentityProvider, kindLDAPIdentityProvider, kindOIDCIdentityProvider, kindGitHubIdentityProvider)
+	allowedKinds := sets.New(kindActiveDirectoryIdentityProvider, kindLDAPIdentityProvider, kindOIDCIdentityProvider, kindGitHubIdentityProvider, kindSAMLIdentityProvider, kindOAuth2IdentityProvider, kindLocalUserIdentityProvider)
 	return controllerlib.New(
 		controllerlib.Config{
 			Name: controllerName,
@@ -115,11 +139,16 @@ func NewFederationDomainWatcherController(
 				clock:                                   clock,
 				client:                                  client,
 				federationDomainInformer:                federationDomainInformer,
+				identityTransformLibraryInformer:        identityTransformLibraryInformer,
 				oidcIdentityProviderInformer:            oidcIdentityProviderInformer,
 				ldapIdentityProviderInformer:            ldapIdentityProviderInformer,
 				activeDirectoryIdentityProviderInformer: activeDirectoryIdentityProviderInformer,
 				githubIdentityProviderInformer:          githubProviderInformer,
+				samlIdentityProviderInformer:            samlProviderInformer,
+				oauth2IdentityProviderInformer:          oauth2ProviderInformer,
+				localUserIdentityProviderInformer:       localUserProviderInformer,
 				allowedKinds:                            allowedKinds,
+				compiledLibraries:                       map[types.NamespacedName]*compiledTransformsLibrary{},
 			},
 		},
 		withInformer(
@@ -127,6 +156,12 @@ func NewFederationDomainWatcherController(
 			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
 			controllerlib.InformerOption{},
 		),
+		withInformer(
+			identityTransformLibraryInformer,
+			// Any change to a library may change the transforms of the FederationDomains which use it.
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
 		withInformer(
 			oidcIdentityProviderInformer,
 			// Since this controller only cares about IDP metadata names and UIDs (immutable fields),
@@ -155,6 +190,27 @@ func NewFederationDomainWatcherController(
 			pinnipedcontroller.MatchAnythingIgnoringUpdatesFilter(pinnipedcontroller.SingletonQueue()),
 			controllerlib.InformerOption{},
 		),
+		withInformer(
+			samlProviderInformer,
+			// Since this controller only cares about IDP metadata names and UIDs (immutable fields),
+			// we only need to trigger Sync on creates and deletes.
+			pinnipedcontroller.MatchAnythingIgnoringUpdatesFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			oauth2ProviderInformer,
+			// Since this controller only cares about IDP metadata names and UIDs (immutable fields),
+			// we only need to trigger Sync on creates and deletes.
+			pinnipedcontroller.MatchAnythingIgnoringUpdatesFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			localUserProviderInformer,
+			// Since this controller only cares about IDP metadata names and UIDs (immutable fields),
+			// we only need to trigger Sync on creates and deletes.
+			pinnipedcontroller.MatchAnythingIgnoringUpdatesFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
 	)
 }
 
@@ -172,6 +228,19 @@ func (c *federationDomainWatcherController) Sync(ctx controllerlib.Context) erro
 		}
 	}
 
+	// Forget the compiled libraries which no longer exist.
+	libraries, err := c.identityTransformLibraryInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	existingLibraries := sets.New[types.NamespacedName]()
+	for _, library := range libraries {
+		existingLibraries.Insert(types.NamespacedName{Namespace: library.Namespace, Name: library.Name})
+	}
+	maps.DeleteFunc(c.compiledLibraries, func(key types.NamespacedName, _ *compiledTransformsLibrary) bool {
+		return !existingLibraries.Has(key)
+	})
+
 	// Process each FederationDomain to validate its spec and to turn it into a FederationDomainIssuer.
 	federationDomainIssuers, fdToConditionsMap, err := c.processAllFederationDomains(ctx.Context, federationDomains)
 	if err != nil {
@@ -275,8 +344,20 @@ func (c *federationDomainWatcherController) makeLegacyFederationDomainIssuer(
 	if err != nil {
 		return nil, nil, err
 	}
+	samlIdentityProviders, err := c.samlIdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+	oauth2IdentityProviders, err := c.oauth2IdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+	localUserIdentityProviders, err := c.localUserIdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
 	// Check if that there is exactly one IDP defined in the Supervisor namespace of any IDP CRD type.
-	idpCRsCount := len(oidcIdentityProviders) + len(ldapIdentityProviders) + len(activeDirectoryIdentityProviders) + len(githubIdentityProviders)
+	idpCRsCount := len(oidcIdentityProviders) + len(ldapIdentityProviders) + len(activeDirectoryIdentityProviders) + len(githubIdentityProviders) + len(samlIdentityProviders) + len(oauth2IdentityProviders) + len(localUserIdentityProviders)
 
 	switch {
 	case idpCRsCount == 1:
@@ -300,6 +381,18 @@ func (c *federationDomainWatcherController) makeLegacyFederationDomainIssuer(
 			defaultFederationDomainIdentityProvider.DisplayName = githubIdentityProviders[0].Name
 			defaultFederationDomainIdentityProvider.UID = githubIdentityProviders[0].UID
 			foundIDPName = githubIdentityProviders[0].Name
+		case len(samlIdentityProviders) == 1:
+			defaultFederationDomainIdentityProvider.DisplayName = samlIdentityProviders[0].Name
+			defaultFederationDomainIdentityProvider.UID = samlIdentityProviders[0].UID
+			foundIDPName = samlIdentityProviders[0].Name
+		case len(oauth2IdentityProviders) == 1:
+			defaultFederationDomainIdentityProvider.DisplayName = oauth2IdentityProviders[0].Name
+			defaultFederationDomainIdentityProvider.UID = oauth2IdentityProviders[0].UID
+			foundIDPName = oauth2IdentityProviders[0].Name
+		case len(localUserIdentityProviders) == 1:
+			defaultFederationDomainIdentityProvider.DisplayName = localUserIdentityProviders[0].Name
+			defaultFederationDomainIdentityProvider.UID = localUserIdentityProviders[0].UID
+			foundIDPName = localUserIdentityProviders[0].Name
 		}
 		// Backwards compatibility mode always uses an empty identity transformation pipeline since no
 		// transformations are defined on the FederationDomain.
@@ -342,6 +435,7 @@ func (c *federationDomainWatcherController) makeLegacyFederationDomainIssuer(
 	conditions = appendIdentityProviderDuplicateDisplayNamesCondition(sets.Set[string]{}, conditions)
 	conditions = appendIdentityProviderObjectRefAPIGroupSuffixCondition(c.apiGroup, []string{}, conditions)
 	conditions = appendIdentityProviderObjectRefKindCondition(c.sortedAllowedKinds(), []string{}, conditions)
+	conditions = appendTransformsLibrariesValidCondition([]string{}, conditions)
 	conditions = appendTransformsExpressionsValidCondition([]string{}, conditions)
 	conditions = appendTransformsExamplesPassedCondition([]string{}, conditions)
 
@@ -360,7 +454,7 @@ func (c *federationDomainWatcherController) makeFederationDomainIssuerWithExplic
 	duplicateDisplayNames := sets.Set[string]{}
 	badAPIGroupNames := []string{}
 	badKinds := []string{}
-	validationErrorMessages := &transformsValidationErrorMessages{}
+	validationErrorMessages := &transformscompiler.ValidationErrorMessages{}
 
 	for index, idp := range federationDomain.Spec.IdentityProviders {
 		idpIsValid := true
@@ -410,9 +504,11 @@ func (c *federationDomainWatcherController) makeFederationDomainIssuerWithExplic
 
 		var err error
 		var pipeline *idtransform.TransformationPipeline
+		var tokenExchangePipelines idtransform.TokenExchangePipelines
 		var allExamplesPassed bool
-		pipeline, allExamplesPassed, err = c.makeTransformationPipelineAndEvaluateExamplesForIdentityProvider(
-			ctx, idp, index, validationErrorMessages)
+		pipeline, tokenExchangePipelines, allExamplesPassed, err = transformscompiler.CompileTransforms(
+			ctx, c.celTransformer, idp.Transforms, transformscompiler.IdentityProviderPaths(index),
+			c.getTransformsLibraryFunc(ctx, federationDomain.Namespace), validationErrorMessages)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -427,9 +523,10 @@ func (c *federationDomainWatcherController) makeFederationDomainIssuerWithExplic
 
 		// For a valid IDP (unique displayName, valid objectRef, valid transforms), add it to the list.
 		federationDomainIdentityProviders = append(federationDomainIdentityProviders, &federationdomainproviders.FederationDomainIdentityProvider{
-			DisplayName: idp.DisplayName,
-			UID:         idpResourceUID,
-			Transforms:  pipeline,
+			DisplayName:             idp.DisplayName,
+			UID:                     idpResourceUID,
+			Transforms:              pipeline,
+			TokenExchangeTransforms: tokenExchangePipelines,
 		})
 	}
 
@@ -442,8 +539,9 @@ func (c *federationDomainWatcherController) makeFederationDomainIssuerWithExplic
 	conditions = appendIdentityProviderObjectRefAPIGroupSuffixCondition(c.apiGroup, badAPIGroupNames, conditions)
 	conditions = appendIdentityProviderObjectRefKindCondition(c.sortedAllowedKinds(), badKinds, conditions)
 
-	conditions = appendTransformsExpressionsValidCondition(validationErrorMessages.errorsForExpressions, conditions)
-	conditions = appendTransformsExamplesPassedCondition(validationErrorMessages.errorsForExamples, conditions)
+	conditions = appendTransformsLibrariesValidCondition(validationErrorMessages.Libraries, conditions)
+	conditions = appendTransformsExpressionsValidCondition(validationErrorMessages.Expressions, conditions)
+	conditions = appendTransformsExamplesPassedCondition(validationErrorMessages.Examples, conditions)
 
 	return federationDomainIssuer, conditions, nil
 }
@@ -462,6 +560,12 @@ func (c *federationDomainWatcherController) findIDPsUIDByObjectRef(objectRef cor
 		foundIDP, err = c.oidcIdentityProviderInformer.Lister().OIDCIdentityProviders(namespace).Get(objectRef.Name)
 	case kindGitHubIdentityProvider:
 		foundIDP, err = c.githubIdentityProviderInformer.Lister().GitHubIdentityProviders(namespace).Get(objectRef.Name)
+	case kindSAMLIdentityProvider:
+		foundIDP, err = c.samlIdentityProviderInformer.Lister().SAMLIdentityProviders(namespace).Get(objectRef.Name)
+	case kindOAuth2IdentityProvider:
+		foundIDP, err = c.oauth2IdentityProviderInformer.Lister().OAuth2IdentityProviders(namespace).Get(objectRef.Name)
+	case kindLocalUserIdentityProvider:
+		foundIDP, err = c.localUserIdentityProviderInformer.Lister().LocalUserIdentityProviders(namespace).Get(objectRef.Name)
 	default:
 		// This shouldn't happen because this helper function is not called when the kind is invalid.
 		return "", false, fmt.Errorf("unexpected kind: %s", objectRef.Kind)
@@ -478,182 +582,38 @@ func (c *federationDomainWatcherController) findIDPsUIDByObjectRef(objectRef cor
 	return idpResourceUID, true, nil
 }
 
-func (c *federationDomainWatcherController) makeTransformationPipelineAndEvaluateExamplesForIdentityProvider(
-	ctx context.Context,
-	idp supervisorconfigv1alpha1.FederationDomainIdentityProvider,
-	idpIndex int,
-	validationErrorMessages *transformsValidationErrorMessages,
-) (*idtransform.TransformationPipeline, bool, error) {
-	consts, err := c.makeTransformsConstantsForIdentityProvider(idp)
-	if err != nil {
-		return nil, false, err
-	}
-
-	pipeline, errorsForExpressions, err := c.makeTransformationPipelineForIdentityProvider(idp, idpIndex, consts)
-	if err != nil {
-		return nil, false, err
-	}
-	if len(errorsForExpressions) > 0 {
-		validationErrorMessages.errorsForExpressions = append(validationErrorMessages.errorsForExpressions, errorsForExpressions)
-	}
-
-	allExamplesPassed, errorsForExamples := c.evaluateExamplesForIdentityProvider(ctx, idp, idpIndex, pipeline)
-	if len(errorsForExamples) > 0 {
-		validationErrorMessages.errorsForExamples = append(validationErrorMessages.errorsForExamples, errorsForExamples)
-	}
-
-	return pipeline, allExamplesPassed, nil
-}
-
-func (c *federationDomainWatcherController) makeTransformsConstantsForIdentityProvider(
-	idp supervisorconfigv1alpha1.FederationDomainIdentityProvider,
-) (*celtransformer.TransformationConstants, error) {
-	consts := &celtransformer.TransformationConstants{
-		StringConstants:     map[string]string{},
-		StringListConstants: map[string][]string{},
-	}
-	constNames := sets.Set[string]{}
-
-	// Read all the declared constants.
-	for _, constant := range idp.Transforms.Constants {
-		// The CRD requires the name field, and validates that it has at least one character,
-		// and validates that the names are unique within the list.
-		constNames.Insert(constant.Name)
-		switch constant.Type {
-		case "string":
-			consts.StringConstants[constant.Name] = constant.StringValue
-		case "stringList":
-			consts.StringListConstants[constant.Name] = constant.StringListValue
-		default:
-			// This shouldn't really happen since the CRD validates it, but handle it as an error.
-			return nil, fmt.Errorf("one of spec.identityProvider[].transforms.constants[].type is invalid: %q", constant.Type)
-		}
-	}
-
-	return consts, nil
-}
-
-func (c *federationDomainWatcherController) makeTransformationPipelineForIdentityProvider(
-	idp supervisorconfigv1alpha1.FederationDomainIdentityProvider,
-	idpIndex int,
-	consts *celtransformer.TransformationConstants,
-) (*idtransform.TransformationPipeline, string, error) {
-	pipeline := idtransform.NewTransformationPipeline()
-	expressionsCompileErrors := []string{}
-
-	// Compile all the expressions and add them to the pipeline.
-	for exprIndex, expr := range idp.Transforms.Expressions {
-		var rawTransform celtransformer.CELTransformation
-		switch expr.Type {
-		case "username/v1":
-			rawTransform = &celtransformer.UsernameTransformation{Expression: expr.Expression}
-		case "groups/v1":
-			rawTransform = &celtransformer.GroupsTransformation{Expression: expr.Expression}
-		case "policy/v1":
-			rawTransform = &celtransformer.AllowAuthenticationPolicy{
-				Expression:                    expr.Expression,
-				RejectedAuthenticationMessage: expr.Message,
-			}
-		default:
-			// This shouldn't really happen since the CRD validates it, but handle it as an error.
-			return nil, "", fmt.Errorf("one of spec.identityProvider[].transforms.expressions[].type is invalid: %q", expr.Type)
-		}
-
-		compiledTransform, err := c.celTransformer.CompileTransformation(rawTransform, consts)
-		if err != nil {
-			expressionsCompileErrors = append(expressionsCompileErrors,
-				fmt.Sprintf("spec.identityProvider[%d].transforms.expressions[%d].expression was invalid:\n%s",
-					idpIndex, exprIndex, err.Error()))
+// getTransformsLibraryFunc returns a function which gets the compiled IdentityTransformLibraries of a namespace.
+func (c *federationDomainWatcherController) getTransformsLibraryFunc(ctx context.Context, namespace string) transformscompiler.LibraryGetter {
+	return func(name string) (*transformscompiler.Library, error) {
+		library, err := c.identityTransformLibraryInformer.Lister().IdentityTransformLibraries(namespace).Get(name)
+		switch {
+		case apierrors.IsNotFound(err):
+			return nil, nil
+		case err != nil:
+			return nil, err // unexpected error from the informer
 		}
-
-		pipeline.AppendTransformation(compiledTransform)
-	}
-
-	if len(expressionsCompileErrors) > 0 {
-		// One or more of the expressions did not compile, so we don't have a useful pipeline to return.
-		// Return the validation messages.
-		return nil, strings.Join(expressionsCompileErrors, "\n\n"), nil
+		return c.compileTransformsLibrary(ctx, library)
 	}
-
-	return pipeline, "", nil
 }
 
-func (c *federationDomainWatcherController) evaluateExamplesForIdentityProvider(
+// compileTransformsLibrary compiles a library and evaluates its examples, or returns the cached result of having
+// previously done so for the same spec.
+func (c *federationDomainWatcherController) compileTransformsLibrary(
 	ctx context.Context,
-	idp supervisorconfigv1alpha1.FederationDomainIdentityProvider,
-	idpIndex int,
-	pipeline *idtransform.TransformationPipeline,
-) (bool, string) {
-	const errorFmt = ".spec.identityProviders[%d].transforms.examples[%d] example failed:\nexpected: %s\nactual:   %s"
-	examplesErrors := []string{}
-
-	if pipeline == nil {
-		// Unable to evaluate the conditions where the pipeline of expressions was invalid.
-		return false, fmt.Sprintf(
-			"unable to check if the examples specified by .spec.identityProviders[%d].transforms.examples[] had errors because an expression was invalid",
-			idpIndex)
-	}
-
-	// Run all the provided transform examples. If any fail, put errors on the FederationDomain status.
-	for exIndex, e := range idp.Transforms.Examples {
-		result, err := pipeline.Evaluate(ctx, e.Username, e.Groups)
-		if err != nil {
-			examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, idpIndex, exIndex,
-				"no transformation errors",
-				fmt.Sprintf("transformations resulted in an unexpected error %q", err.Error())))
-			continue
-		}
-		resultWasAuthRejected := !result.AuthenticationAllowed
-
-		if e.Expects.Rejected && !resultWasAuthRejected {
-			examplesErrors = append(examplesErrors,
-				fmt.Sprintf(errorFmt, idpIndex, exIndex, "authentication to be rejected", "authentication was not rejected"))
-			continue
-		}
-
-		if !e.Expects.Rejected && resultWasAuthRejected {
-			examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, idpIndex, exIndex,
-				"authentication not to be rejected",
-				fmt.Sprintf("authentication was rejected with message %q", result.RejectedAuthenticationMessage)))
-			continue
-		}
-
-		expectedRejectionMessage := e.Expects.Message
-		if len(expectedRejectionMessage) == 0 {
-			expectedRejectionMessage = celtransformer.DefaultPolicyRejectedAuthMessage
-		}
-		if e.Expects.Rejected && resultWasAuthRejected && expectedRejectionMessage != result.RejectedAuthenticationMessage {
-			examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, idpIndex, exIndex,
-				fmt.Sprintf("authentication rejection message %q", expectedRejectionMessage),
-				fmt.Sprintf("authentication rejection message %q", result.RejectedAuthenticationMessage)))
-			continue
-		}
-
-		if result.AuthenticationAllowed {
-			// In the case where the user expected the auth to be allowed and it was allowed, then compare
-			// the expected username and group names to the actual username and group names.
-			if e.Expects.Username != result.Username {
-				examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, idpIndex, exIndex,
-					fmt.Sprintf("username %q", e.Expects.Username),
-					fmt.Sprintf("username %q", result.Username)))
-			}
-			expectedGroups := e.Expects.Groups
-			if expectedGroups == nil {
-				expectedGroups = []string{}
-			}
-			if !stringSetsEqual(expectedGroups, result.Groups) {
-				examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, idpIndex, exIndex,
-					fmt.Sprintf("groups [%s]", strings.Join(sortAndQuote(expectedGroups), ", ")),
-					fmt.Sprintf("groups [%s]", strings.Join(sortAndQuote(result.Groups), ", "))))
-			}
-		}
+	library *supervisorconfigv1alpha1.IdentityTransformLibrary,
+) (*transformscompiler.Library, error) {
+	key := types.NamespacedName{Namespace: library.Namespace, Name: library.Name}
+	if cached, ok := c.compiledLibraries[key]; ok && equality.Semantic.DeepEqual(cached.spec, library.Spec) {
+		return cached.library, nil
 	}
 
-	if len(examplesErrors) > 0 {
-		return false, strings.Join(examplesErrors, "\n\n")
+	compiledLibrary, err := transformscompiler.CompileLibrary(ctx, c.celTransformer, library.Spec)
+	if err != nil {
+		return nil, err
 	}
 
-	return true, ""
+	c.compiledLibraries[key] = &compiledTransformsLibrary{spec: *library.Spec.DeepCopy(), library: compiledLibrary}
+	return compiledLibrary, nil
 }
 
 func appendIdentityProviderObjectRefKindCondition(expectedKinds []string, badSuffixNames []string, conditions []*metav1.Condition) []*metav1.Condition {
@@ -724,6 +684,25 @@ func appendIdentityProviderObjectRefAPIGroupSuffixCondition(expectedSuffixName s
 	return conditions
 }
 
+func appendTransformsLibrariesValidCondition(messages []string, conditions []*metav1.Condition) []*metav1.Condition {
+	if len(messages) > 0 {
+		conditions = append(conditions, &metav1.Condition{
+			Type:    typeTransformsLibrariesValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonInvalidTransformsLibraries,
+			Message: strings.Join(messages, "\n\n"),
+		})
+	} else {
+		conditions = append(conditions, &metav1.Condition{
+			Type:    typeTransformsLibrariesValid,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditionsutil.ReasonSuccess,
+			Message: "the libraries specified by .spec.identityProviders[].transforms.libraries[] are valid",
+		})
+	}
+	return conditions
+}
+
 func appendTransformsExpressionsValidCondition(messages []string, conditions []*metav1.Condition) []*metav1.Condition {
 	if len(messages) > 0 {
 		conditions = append(conditions, &metav1.Condition{
@@ -861,11 +840,6 @@ func (c *federationDomainWatcherController) sortedAllowedKinds() []string {
 	return sortAndQuote(c.allowedKinds.UnsortedList())
 }
 
-type transformsValidationErrorMessages struct {
-	errorsForExpressions []string
-	errorsForExamples    []string
-}
-
 type crossFederationDomainConfigValidator struct {
 	issuerCounts                      map[string]int
 	uniqueSecretNamesPerIssuerAddress map[string]map[string]bool
@@ -970,9 +944,3 @@ func newCrossFederationDomainConfigValidator(federationDomains []*supervisorconf
 		uniqueSecretNamesPerIssuerAddress: uniqueSecretNamesPerIssuerAddress,
 	}
 }
-
-func stringSetsEqual(a []string, b []string) bool {
-	aSet := sets.New(a...)
-	bSet := sets.New(b...)
-	return aSet.Equal(bSet)
-}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package supervisorconfig
@@ -39,10 +39,14 @@ func TestFederationDomainWatcherControllerInformerFilters(t *testing.T) {
 	t.Parallel()
 
 	federationDomainInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).Config().V1alpha1().FederationDomains()
+	identityTransformLibraryInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).Config().V1alpha1().IdentityTransformLibraries()
 	oidcIdentityProviderInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).IDP().V1alpha1().OIDCIdentityProviders()
 	ldapIdentityProviderInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).IDP().V1alpha1().LDAPIdentityProviders()
 	adIdentityProviderInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).IDP().V1alpha1().ActiveDirectoryIdentityProviders()
 	githubIdentityProviderInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).IDP().V1alpha1().GitHubIdentityProviders()
+	samlIdentityProviderInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).IDP().V1alpha1().SAMLIdentityProviders()
+	oauth2IdentityProviderInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).IDP().V1alpha1().OAuth2IdentityProviders()
+	localUserIdentityProviderInformer := supervisorinformers.NewSharedInformerFactoryWithOptions(nil, 0).IDP().V1alpha1().LocalUserIdentityProviders()
 
 	tests := []struct {
 		name       string
@@ -60,6 +64,14 @@ func TestFederationDomainWatcherControllerInformerFilters(t *testing.T) {
 			wantUpdate: true,
 			wantDelete: true,
 		},
+		{
+			name:       "any IdentityTransformLibrary changes",
+			obj:        &supervisorconfigv1alpha1.IdentityTransformLibrary{},
+			informer:   identityTransformLibraryInformer,
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
 		{
 			name:       "any OIDCIdentityProvider adds or deletes, but updates are ignored",
 			obj:        &idpv1alpha1.OIDCIdentityProvider{},
@@ -91,6 +103,30 @@ func TestFederationDomainWatcherControllerInformerFilters(t *testing.T) {
 			wantUpdate: false,
 			wantDelete: true,
 		},
+		{
+			name:       "any SAMLIdentityProvider adds or deletes, but updates are ignored",
+			obj:        &idpv1alpha1.SAMLIdentityProvider{},
+			informer:   samlIdentityProviderInformer,
+			wantAdd:    true,
+			wantUpdate: false,
+			wantDelete: true,
+		},
+		{
+			name:       "any OAuth2IdentityProvider adds or deletes, but updates are ignored",
+			obj:        &idpv1alpha1.OAuth2IdentityProvider{},
+			informer:   oauth2IdentityProviderInformer,
+			wantAdd:    true,
+			wantUpdate: false,
+			wantDelete: true,
+		},
+		{
+			name:       "any LocalUserIdentityProvider adds or deletes, but updates are ignored",
+			obj:        &idpv1alpha1.LocalUserIdentityProvider{},
+			informer:   localUserIdentityProviderInformer,
+			wantAdd:    true,
+			wantUpdate: false,
+			wantDelete: true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -104,10 +140,14 @@ func TestFederationDomainWatcherControllerInformerFilters(t *testing.T) {
 				nil,
 				nil,
 				federationDomainInformer,
+				identityTransformLibraryInformer,
 				oidcIdentityProviderInformer,
 				ldapIdentityProviderInformer,
 				adIdentityProviderInformer,
 				githubIdentityProviderInformer,
+				samlIdentityProviderInformer,
+				oauth2IdentityProviderInformer,
+				localUserIdentityProviderInformer,
 				withInformer.WithInformer, // make it possible to observe the behavior of the Filters
 			)
 
@@ -179,6 +219,67 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 		},
 	}
 
+	samlIdentityProvider := &idpv1alpha1.SAMLIdentityProvider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "some-saml-idp",
+			Namespace: namespace,
+			UID:       "some-saml-uid",
+		},
+	}
+
+	oauth2IdentityProvider := &idpv1alpha1.OAuth2IdentityProvider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "some-oauth2-idp",
+			Namespace: namespace,
+			UID:       "some-oauth2-uid",
+		},
+	}
+
+	localUserIdentityProvider := &idpv1alpha1.LocalUserIdentityProvider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "some-localuser-idp",
+			Namespace: namespace,
+			UID:       "some-localuser-uid",
+		},
+	}
+
+	transformsLibraryA := &supervisorconfigv1alpha1.IdentityTransformLibrary{
+		ObjectMeta: metav1.ObjectMeta{Name: "library-a", Namespace: namespace},
+		Spec: supervisorconfigv1alpha1.IdentityTransformLibrarySpec{
+			Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
+				{Name: "prefix", Type: "string", StringValue: "a:"},
+				{Name: "admins", Type: "stringList", StringListValue: []string{"lib-admins"}},
+			},
+			Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+				{Type: "username/v1", Expression: `strConst.prefix + username`},
+			},
+			Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
+				{
+					Username: "ryan",
+					Expects:  supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{Username: "a:ryan"},
+				},
+			},
+		},
+	}
+
+	transformsLibraryB := &supervisorconfigv1alpha1.IdentityTransformLibrary{
+		ObjectMeta: metav1.ObjectMeta{Name: "library-b", Namespace: namespace},
+		Spec: supervisorconfigv1alpha1.IdentityTransformLibrarySpec{
+			Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
+				{Name: "prefix", Type: "string", StringValue: "b:"},
+			},
+			Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+				{Type: "policy/v1", Expression: `!username.contains("banned")`, Message: "no banned users"},
+			},
+			Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
+				{
+					Username: "banned-ryan",
+					Expects:  supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{Rejected: true, Message: "no banned users"},
+				},
+			},
+		},
+	}
+
 	federationDomain1 := &supervisorconfigv1alpha1.FederationDomain{
 		ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
 		Spec:       supervisorconfigv1alpha1.FederationDomainSpec{Issuer: "https://issuer1.com"},
@@ -417,6 +518,28 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 		}
 	}
 
+	happyTransformationLibrariesCondition := func(time metav1.Time, observedGeneration int64) metav1.Condition {
+		return metav1.Condition{
+			Type:               "TransformsLibrariesValid",
+			Status:             "True",
+			ObservedGeneration: observedGeneration,
+			LastTransitionTime: time,
+			Reason:             "Success",
+			Message:            "the libraries specified by .spec.identityProviders[].transforms.libraries[] are valid",
+		}
+	}
+
+	sadTransformationLibrariesCondition := func(errorMessages string, time metav1.Time, observedGeneration int64) metav1.Condition {
+		return metav1.Condition{
+			Type:               "TransformsLibrariesValid",
+			Status:             "False",
+			ObservedGeneration: observedGeneration,
+			LastTransitionTime: time,
+			Reason:             "InvalidTransformsLibraries",
+			Message:            errorMessages,
+		}
+	}
+
 	happyTransformationExpressionsCondition := func(time metav1.Time, observedGeneration int64) metav1.Condition {
 		return metav1.Condition{
 			Type:               "TransformsExpressionsValid",
@@ -503,7 +626,7 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 			LastTransitionTime: time,
 			Reason:             "KindUnrecognized",
 			Message: fmt.Sprintf(`some kinds specified by .spec.identityProviders[].objectRef.kind are `+
-				`not recognized (should be one of "ActiveDirectoryIdentityProvider", "GitHubIdentityProvider", "LDAPIdentityProvider", "OIDCIdentityProvider"): %s`, badKinds),
+				`not recognized (should be one of "ActiveDirectoryIdentityProvider", "GitHubIdentityProvider", "LDAPIdentityProvider", "LocalUserIdentityProvider", "OAuth2IdentityProvider", "OIDCIdentityProvider", "SAMLIdentityProvider"): %s`, badKinds),
 		}
 	}
 
@@ -511,6 +634,7 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 		return conditionstestutil.SortByType([]metav1.Condition{
 			happyTransformationExamplesCondition(frozenMetav1Now, 123),
 			happyTransformationExpressionsCondition(frozenMetav1Now, 123),
+			happyTransformationLibrariesCondition(frozenMetav1Now, 123),
 			happyKindCondition(frozenMetav1Now, 123),
 			happyAPIGroupSuffixCondition(frozenMetav1Now, 123),
 			happyDisplayNamesUniqueCondition(frozenMetav1Now, 123),
@@ -644,6 +768,78 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "legacy config: when no identity provider is specified on federation domains, but exactly one SAML identity " +
+				"provider resource exists on cluster, the controller will set a default IDP on each federation domain " +
+				"matching the only identity provider found",
+			inputObjects: []runtime.Object{
+				federationDomain1,
+				federationDomain2,
+				samlIdentityProvider,
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{
+				federationDomainIssuerWithDefaultIDP(t, federationDomain1.Spec.Issuer, samlIdentityProvider.ObjectMeta),
+				federationDomainIssuerWithDefaultIDP(t, federationDomain2.Spec.Issuer, samlIdentityProvider.ObjectMeta),
+			},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(federationDomain1,
+					supervisorconfigv1alpha1.FederationDomainPhaseReady,
+					allHappyConditionsLegacyConfigurationSuccess(federationDomain1.Spec.Issuer, samlIdentityProvider.Name, frozenMetav1Now, 123),
+				),
+				expectedFederationDomainStatusUpdate(federationDomain2,
+					supervisorconfigv1alpha1.FederationDomainPhaseReady,
+					allHappyConditionsLegacyConfigurationSuccess(federationDomain2.Spec.Issuer, samlIdentityProvider.Name, frozenMetav1Now, 123),
+				),
+			},
+		},
+		{
+			name: "legacy config: when no identity provider is specified on federation domains, but exactly one OAuth2 identity " +
+				"provider resource exists on cluster, the controller will set a default IDP on each federation domain " +
+				"matching the only identity provider found",
+			inputObjects: []runtime.Object{
+				federationDomain1,
+				federationDomain2,
+				oauth2IdentityProvider,
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{
+				federationDomainIssuerWithDefaultIDP(t, federationDomain1.Spec.Issuer, oauth2IdentityProvider.ObjectMeta),
+				federationDomainIssuerWithDefaultIDP(t, federationDomain2.Spec.Issuer, oauth2IdentityProvider.ObjectMeta),
+			},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(federationDomain1,
+					supervisorconfigv1alpha1.FederationDomainPhaseReady,
+					allHappyConditionsLegacyConfigurationSuccess(federationDomain1.Spec.Issuer, oauth2IdentityProvider.Name, frozenMetav1Now, 123),
+				),
+				expectedFederationDomainStatusUpdate(federationDomain2,
+					supervisorconfigv1alpha1.FederationDomainPhaseReady,
+					allHappyConditionsLegacyConfigurationSuccess(federationDomain2.Spec.Issuer, oauth2IdentityProvider.Name, frozenMetav1Now, 123),
+				),
+			},
+		},
+		{
+			name: "legacy config: when no identity provider is specified on federation domains, but exactly one LocalUser identity " +
+				"provider resource exists on cluster, the controller will set a default IDP on each federation domain " +
+				"matching the only identity provider found",
+			inputObjects: []runtime.Object{
+				federationDomain1,
+				federationDomain2,
+				localUserIdentityProvider,
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{
+				federationDomainIssuerWithDefaultIDP(t, federationDomain1.Spec.Issuer, localUserIdentityProvider.ObjectMeta),
+				federationDomainIssuerWithDefaultIDP(t, federationDomain2.Spec.Issuer, localUserIdentityProvider.ObjectMeta),
+			},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(federationDomain1,
+					supervisorconfigv1alpha1.FederationDomainPhaseReady,
+					allHappyConditionsLegacyConfigurationSuccess(federationDomain1.Spec.Issuer, localUserIdentityProvider.Name, frozenMetav1Now, 123),
+				),
+				expectedFederationDomainStatusUpdate(federationDomain2,
+					supervisorconfigv1alpha1.FederationDomainPhaseReady,
+					allHappyConditionsLegacyConfigurationSuccess(federationDomain2.Spec.Issuer, localUserIdentityProvider.Name, frozenMetav1Now, 123),
+				),
+			},
+		},
 		{
 			name: "when there are two valid FederationDomains, but one is already up to date, the sync loop only updates " +
 				"the out-of-date FederationDomain",
@@ -1078,6 +1274,9 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 				ldapIdentityProvider,
 				adIdentityProvider,
 				gitHubIdentityProvider,
+				samlIdentityProvider,
+				oauth2IdentityProvider,
+				localUserIdentityProvider,
 				&supervisorconfigv1alpha1.FederationDomain{
 					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
 					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
@@ -1115,6 +1314,30 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 									Name:     gitHubIdentityProvider.Name,
 								},
 							},
+							{
+								DisplayName: "can-find-me-five",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "SAMLIdentityProvider",
+									Name:     samlIdentityProvider.Name,
+								},
+							},
+							{
+								DisplayName: "can-find-me-six",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "OAuth2IdentityProvider",
+									Name:     oauth2IdentityProvider.Name,
+								},
+							},
+							{
+								DisplayName: "can-find-me-seven",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "LocalUserIdentityProvider",
+									Name:     localUserIdentityProvider.Name,
+								},
+							},
 						},
 					},
 				},
@@ -1142,6 +1365,21 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 							UID:         gitHubIdentityProvider.UID,
 							Transforms:  idtransform.NewTransformationPipeline(),
 						},
+						{
+							DisplayName: "can-find-me-five",
+							UID:         samlIdentityProvider.UID,
+							Transforms:  idtransform.NewTransformationPipeline(),
+						},
+						{
+							DisplayName: "can-find-me-six",
+							UID:         oauth2IdentityProvider.UID,
+							Transforms:  idtransform.NewTransformationPipeline(),
+						},
+						{
+							DisplayName: "can-find-me-seven",
+							UID:         localUserIdentityProvider.UID,
+							Transforms:  idtransform.NewTransformationPipeline(),
+						},
 					}),
 			},
 			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
@@ -1610,7 +1848,7 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 			},
 		},
 		{
-			name: "the federation domain has transformation expressions that return illegal values with examples which exercise them",
+			name: "the federation domain has transformation examples which use the authentication context",
 			inputObjects: []runtime.Object{
 				oidcIdentityProvider,
 				&supervisorconfigv1alpha1.FederationDomain{
@@ -1627,26 +1865,35 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 								},
 								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
 									Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
-										{Type: "username/v1", Expression: `username == "ryan" ? "" : username`}, // not allowed to return an empty string as the transformed username
+										{Type: "policy/v1", Expression: `!("admins" in groups) || "mfa" in amr`, Message: "admins must use mfa"},
+										{Type: "username/v1", Expression: `acr == "" ? username : acr + ":" + username`},
 									},
 									Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
-										{ // every example which encounters an unexpected error should fail because the transformation pipeline returned an error
+										{ // this example should pass
 											Username: "ryan",
-											Groups:   []string{"a", "b"},
-											Expects:  supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{},
+											Groups:   []string{"admins"},
+											ACR:      "phr",
+											AMR:      []string{"pwd", "mfa"},
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Username: "phr:ryan",
+												Groups:   []string{"admins"},
+											},
 										},
-										{ // every example which encounters an unexpected error should fail because the transformation pipeline returned an error
+										{ // this example should pass
 											Username: "ryan",
-											Groups:   []string{"a", "b"},
-											Expects:  supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{},
+											Groups:   []string{"admins"},
+											AMR:      []string{"pwd"},
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Rejected: true,
+												Message:  "admins must use mfa",
+											},
 										},
-										{ // this should pass
-											Username: "other",
-											Groups:   []string{"a", "b"},
+										{ // this example should fail because the user is rejected when no amr is given
+											Username: "ryan",
+											Groups:   []string{"admins"},
 											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
-												Username: "other",
-												Groups:   []string{"a", "b"},
-												Rejected: false,
+												Username: "ryan",
+												Groups:   []string{"admins"},
 											},
 										},
 									},
@@ -1667,13 +1914,9 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 						allHappyConditionsSuccess("https://issuer1.com", frozenMetav1Now, 123),
 						[]metav1.Condition{
 							sadTransformationExamplesCondition(here.Doc(
-								`.spec.identityProviders[0].transforms.examples[0] example failed:
-								 expected: no transformation errors
-								 actual:   transformations resulted in an unexpected error "identity transformation returned an empty username, which is not allowed"
-
-								 .spec.identityProviders[0].transforms.examples[1] example failed:
-								 expected: no transformation errors
-								 actual:   transformations resulted in an unexpected error "identity transformation returned an empty username, which is not allowed"`,
+								`.spec.identityProviders[0].transforms.examples[2] example failed:
+								 expected: authentication not to be rejected
+								 actual:   authentication was rejected with message "admins must use mfa"`,
 							), frozenMetav1Now, 123),
 							sadReadyCondition(frozenMetav1Now, 123),
 						}),
@@ -1681,74 +1924,237 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 			},
 		},
 		{
-			name: "the federation domain has lots of errors including errors from multiple IDPs, which are all shown in the status conditions using IDP indices in the messages",
+			name: "the federation domain has transformation examples which expect additional claims",
 			inputObjects: []runtime.Object{
 				oidcIdentityProvider,
 				&supervisorconfigv1alpha1.FederationDomain{
 					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
 					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
-						Issuer: "https://not-unique.com",
+						Issuer: "https://issuer1.com",
 						IdentityProviders: []supervisorconfigv1alpha1.FederationDomainIdentityProvider{
 							{
-								DisplayName: "not unique",
+								DisplayName: "name1",
 								ObjectRef: corev1.TypedLocalObjectReference{
 									APIGroup: ptr.To(apiGroupSupervisor),
 									Kind:     "OIDCIdentityProvider",
-									Name:     "this will not be found",
+									Name:     oidcIdentityProvider.Name,
 								},
 								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
-									Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
-										{Name: "foo", Type: "string", StringValue: "bar"},
-										{Name: "bar", Type: "string", StringValue: "baz"},
-									},
 									Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
-										{Type: "username/v1", Expression: `username + ":suffix"`},
+										{Type: "claims/v1", Expression: `{"email": username + "@example.com", "admin": "admins" in groups}`},
+										{Type: "claims/v1", Expression: `"admins" in groups ? {"teams": groups, "level": 2} : {}`},
 									},
 									Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
-										{ // this should fail
+										{ // this example should pass
 											Username: "ryan",
-											Groups:   []string{"a", "b"},
+											Groups:   []string{"admins"},
 											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
-												Username: "this is wrong string",
-												Groups:   []string{"this is wrong string list"},
+												Username: "ryan",
+												Groups:   []string{"admins"},
+												AdditionalClaims: map[string]string{
+													"email": `"ryan@example.com"`,
+													"admin": `true`,
+													"teams": `["admins"]`,
+													"level": `2`,
+												},
 											},
 										},
-										{ // this should fail
+										{ // this example should fail because it expects the wrong claims
 											Username: "ryan",
-											Groups:   []string{"a", "b"},
 											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
-												Username: "this is also wrong string",
-												Groups:   []string{"this is also wrong string list"},
+												Username:         "ryan",
+												AdditionalClaims: map[string]string{"email": `"ryan@example.com"`},
+											},
+										},
+										{ // this example should fail because it expects no claims
+											Username: "ryan",
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Username: "ryan",
+											},
+										},
+										{ // this example should fail because an expected claim is not valid JSON
+											Username: "ryan",
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Username: "ryan",
+												AdditionalClaims: map[string]string{
+													"email": `ryan@example.com`,
+													"admin": `false`,
+												},
 											},
 										},
 									},
 								},
 							},
+						},
+					},
+				},
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(
+					&supervisorconfigv1alpha1.FederationDomain{
+						ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					},
+					supervisorconfigv1alpha1.FederationDomainPhaseError,
+					conditionstestutil.Replace(
+						allHappyConditionsSuccess("https://issuer1.com", frozenMetav1Now, 123),
+						[]metav1.Condition{
+							sadTransformationExamplesCondition(here.Doc(
+								`.spec.identityProviders[0].transforms.examples[1] example failed:
+								 expected: additional claims {"email":"ryan@example.com"}
+								 actual:   additional claims {"admin":false,"email":"ryan@example.com"}
+
+								 .spec.identityProviders[0].transforms.examples[2] example failed:
+								 expected: additional claims {}
+								 actual:   additional claims {"admin":false,"email":"ryan@example.com"}
+
+								 .spec.identityProviders[0].transforms.examples[3].expects.additionalClaims is invalid: the value of claim "email" is not valid JSON: invalid character 'r' looking for beginning of value`,
+							), frozenMetav1Now, 123),
+							sadReadyCondition(frozenMetav1Now, 123),
+						}),
+				),
+			},
+		},
+		{
+			name: "the federation domain has transformation expressions that return illegal values with examples which exercise them",
+			inputObjects: []runtime.Object{
+				oidcIdentityProvider,
+				&supervisorconfigv1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer1.com",
+						IdentityProviders: []supervisorconfigv1alpha1.FederationDomainIdentityProvider{
 							{
-								DisplayName: "not unique",
+								DisplayName: "name1",
 								ObjectRef: corev1.TypedLocalObjectReference{
 									APIGroup: ptr.To(apiGroupSupervisor),
-									Kind:     "this is wrong",
-									Name:     "foo",
+									Kind:     "OIDCIdentityProvider",
+									Name:     oidcIdentityProvider.Name,
 								},
 								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
-									Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
-										{Name: "foo", Type: "string", StringValue: "bar"},
-										{Name: "bar", Type: "string", StringValue: "baz"},
-									},
 									Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
-										{Type: "username/v1", Expression: `username + ":suffix"`},
+										{Type: "username/v1", Expression: `username == "ryan" ? "" : username`}, // not allowed to return an empty string as the transformed username
 									},
 									Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
-										{ // this should pass
+										{ // every example which encounters an unexpected error should fail because the transformation pipeline returned an error
 											Username: "ryan",
 											Groups:   []string{"a", "b"},
-											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
-												Username: "ryan:suffix",
-												Groups:   []string{"a", "b"},
-											},
+											Expects:  supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{},
 										},
-										{ // this should fail
+										{ // every example which encounters an unexpected error should fail because the transformation pipeline returned an error
+											Username: "ryan",
+											Groups:   []string{"a", "b"},
+											Expects:  supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{},
+										},
+										{ // this should pass
+											Username: "other",
+											Groups:   []string{"a", "b"},
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Username: "other",
+												Groups:   []string{"a", "b"},
+												Rejected: false,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(
+					&supervisorconfigv1alpha1.FederationDomain{
+						ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					},
+					supervisorconfigv1alpha1.FederationDomainPhaseError,
+					conditionstestutil.Replace(
+						allHappyConditionsSuccess("https://issuer1.com", frozenMetav1Now, 123),
+						[]metav1.Condition{
+							sadTransformationExamplesCondition(here.Doc(
+								`.spec.identityProviders[0].transforms.examples[0] example failed:
+								 expected: no transformation errors
+								 actual:   transformations resulted in an unexpected error "identity transformation returned an empty username, which is not allowed"
+
+								 .spec.identityProviders[0].transforms.examples[1] example failed:
+								 expected: no transformation errors
+								 actual:   transformations resulted in an unexpected error "identity transformation returned an empty username, which is not allowed"`,
+							), frozenMetav1Now, 123),
+							sadReadyCondition(frozenMetav1Now, 123),
+						}),
+				),
+			},
+		},
+		{
+			name: "the federation domain has lots of errors including errors from multiple IDPs, which are all shown in the status conditions using IDP indices in the messages",
+			inputObjects: []runtime.Object{
+				oidcIdentityProvider,
+				&supervisorconfigv1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
+						Issuer: "https://not-unique.com",
+						IdentityProviders: []supervisorconfigv1alpha1.FederationDomainIdentityProvider{
+							{
+								DisplayName: "not unique",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "OIDCIdentityProvider",
+									Name:     "this will not be found",
+								},
+								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
+									Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
+										{Name: "foo", Type: "string", StringValue: "bar"},
+										{Name: "bar", Type: "string", StringValue: "baz"},
+									},
+									Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+										{Type: "username/v1", Expression: `username + ":suffix"`},
+									},
+									Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
+										{ // this should fail
+											Username: "ryan",
+											Groups:   []string{"a", "b"},
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Username: "this is wrong string",
+												Groups:   []string{"this is wrong string list"},
+											},
+										},
+										{ // this should fail
+											Username: "ryan",
+											Groups:   []string{"a", "b"},
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Username: "this is also wrong string",
+												Groups:   []string{"this is also wrong string list"},
+											},
+										},
+									},
+								},
+							},
+							{
+								DisplayName: "not unique",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "this is wrong",
+									Name:     "foo",
+								},
+								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
+									Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
+										{Name: "foo", Type: "string", StringValue: "bar"},
+										{Name: "bar", Type: "string", StringValue: "baz"},
+									},
+									Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+										{Type: "username/v1", Expression: `username + ":suffix"`},
+									},
+									Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
+										{ // this should pass
+											Username: "ryan",
+											Groups:   []string{"a", "b"},
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Username: "ryan:suffix",
+												Groups:   []string{"a", "b"},
+											},
+										},
+										{ // this should fail
 											Username: "ryan",
 											Groups:   []string{"a", "b"},
 											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
@@ -2020,6 +2426,442 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "the federation domain has token exchange transformation pipelines which are valid and whose examples pass",
+			inputObjects: []runtime.Object{
+				oidcIdentityProvider,
+				&supervisorconfigv1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer1.com",
+						IdentityProviders: []supervisorconfigv1alpha1.FederationDomainIdentityProvider{
+							{
+								DisplayName: "name1",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "OIDCIdentityProvider",
+									Name:     oidcIdentityProvider.Name,
+								},
+								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
+									Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
+										{Name: "prefix", Type: "string", StringValue: "prod:"},
+									},
+									Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+										{Type: "username/v1", Expression: `"pre:" + username`},
+									},
+									TokenExchange: []supervisorconfigv1alpha1.FederationDomainTokenExchangeTransforms{
+										{
+											Audiences: []string{"prod-cluster-1", "prod-cluster-2"},
+											Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+												{Type: "groups/v1", Expression: `groups.filter(g, g.startsWith(strConst.prefix))`},
+											},
+											Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
+												{
+													Username: "ryan",
+													Groups:   []string{"prod:admins", "dev:admins"},
+													Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+														Username: "ryan",
+														Groups:   []string{"prod:admins"},
+													},
+												},
+											},
+										},
+										{
+											ClientNames: []string{"client.oauth.pinniped.dev-webapp"},
+											Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+												{Type: "policy/v1", Expression: `"webapp-users" in groups`, Message: "only webapp users allowed"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{
+				federationDomainIssuerWithIDPs(t, "https://issuer1.com", []*federationdomainproviders.FederationDomainIdentityProvider{
+					{
+						DisplayName: "name1",
+						UID:         oidcIdentityProvider.UID,
+						Transforms: newTransformationPipeline(t, &celtransformer.TransformationConstants{
+							StringConstants: map[string]string{"prefix": "prod:"},
+						},
+							&celtransformer.UsernameTransformation{Expression: `"pre:" + username`},
+						),
+						TokenExchangeTransforms: idtransform.TokenExchangePipelines{
+							idtransform.NewTokenExchangePipeline(
+								[]string{"prod-cluster-1", "prod-cluster-2"},
+								nil,
+								newTransformationPipeline(t, &celtransformer.TransformationConstants{
+									StringConstants: map[string]string{"prefix": "prod:"},
+								},
+									&celtransformer.GroupsTransformation{Expression: `groups.filter(g, g.startsWith(strConst.prefix))`},
+								),
+							),
+							idtransform.NewTokenExchangePipeline(
+								nil,
+								[]string{"client.oauth.pinniped.dev-webapp"},
+								newTransformationPipeline(t, &celtransformer.TransformationConstants{
+									StringConstants: map[string]string{"prefix": "prod:"},
+								},
+									&celtransformer.AllowAuthenticationPolicy{
+										Expression:                    `"webapp-users" in groups`,
+										RejectedAuthenticationMessage: "only webapp users allowed",
+									},
+								),
+							),
+						},
+					},
+				}),
+			},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(
+					&supervisorconfigv1alpha1.FederationDomain{
+						ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					},
+					supervisorconfigv1alpha1.FederationDomainPhaseReady,
+					allHappyConditionsSuccess("https://issuer1.com", frozenMetav1Now, 123),
+				),
+			},
+		},
+		{
+			name: "the federation domain has token exchange transformation pipelines with invalid expressions and examples which don't pass",
+			inputObjects: []runtime.Object{
+				oidcIdentityProvider,
+				&supervisorconfigv1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer1.com",
+						IdentityProviders: []supervisorconfigv1alpha1.FederationDomainIdentityProvider{
+							{
+								DisplayName: "name1",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "OIDCIdentityProvider",
+									Name:     oidcIdentityProvider.Name,
+								},
+								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
+									TokenExchange: []supervisorconfigv1alpha1.FederationDomainTokenExchangeTransforms{
+										{
+											Audiences: []string{"prod-cluster"},
+											Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+												{Type: "groups/v1", Expression: `groups.filter(g, g.startsWith("prod:"))`},
+											},
+											Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
+												{ // this example should fail because it expects the wrong groups
+													Username: "ryan",
+													Groups:   []string{"prod:admins", "dev:admins"},
+													Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+														Username: "ryan",
+														Groups:   []string{"prod:admins", "dev:admins"},
+													},
+												},
+											},
+										},
+										{
+											Audiences: []string{"dev-cluster"},
+											Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+												{Type: "groups/v1", Expression: `this is not a valid cel expression`},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(
+					&supervisorconfigv1alpha1.FederationDomain{
+						ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					},
+					supervisorconfigv1alpha1.FederationDomainPhaseError,
+					conditionstestutil.Replace(
+						allHappyConditionsSuccess("https://issuer1.com", frozenMetav1Now, 123),
+						[]metav1.Condition{
+							sadTransformationExpressionsCondition(here.Doc(
+								`spec.identityProvider[0].transforms.tokenExchange[1].expressions[0].expression was invalid:
+								 CEL expression compile error: ERROR: <input>:1:6: Syntax error: mismatched input 'is' expecting <EOF>
+								  | this is not a valid cel expression
+								  | .....^`,
+							), frozenMetav1Now, 123),
+							sadTransformationExamplesCondition(here.Doc(
+								`.spec.identityProviders[0].transforms.tokenExchange[0].examples[0] example failed:
+								 expected: groups ["dev:admins", "prod:admins"]
+								 actual:   groups ["prod:admins"]
+
+								 unable to check if the examples specified by .spec.identityProviders[0].transforms.tokenExchange[1].examples[] had errors because an expression was invalid`,
+							), frozenMetav1Now, 123),
+							sadReadyCondition(frozenMetav1Now, 123),
+						}),
+				),
+			},
+		},
+		{
+			name: "the federation domain has identity providers which use transforms libraries",
+			inputObjects: []runtime.Object{
+				oidcIdentityProvider,
+				transformsLibraryA,
+				transformsLibraryB,
+				&supervisorconfigv1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer1.com",
+						IdentityProviders: []supervisorconfigv1alpha1.FederationDomainIdentityProvider{
+							{
+								DisplayName: "name1",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "OIDCIdentityProvider",
+									Name:     oidcIdentityProvider.Name,
+								},
+								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
+									Libraries: []supervisorconfigv1alpha1.FederationDomainTransformsLibraryRef{
+										{Name: transformsLibraryA.Name},
+										{Name: transformsLibraryB.Name},
+									},
+									Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
+										{Name: "suffix", Type: "string", StringValue: "@idp"},
+										{Name: "admins", Type: "stringList", StringListValue: []string{"idp-admins"}},
+									},
+									Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+										{Type: "username/v1", Expression: `username + strConst.suffix`},
+										{Type: "groups/v1", Expression: `groups.map(g, strConst.prefix + g) + strListConst.admins`},
+									},
+									Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
+										{ // runs through the expressions of both libraries and then the identity provider's own expressions
+											Username: "ryan",
+											Groups:   []string{"devs"},
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Username: "a:ryan@idp",
+												Groups:   []string{"b:devs", "idp-admins"},
+											},
+										},
+										{ // rejected by the policy of the second library
+											Username: "banned-ryan",
+											Expects: supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{
+												Rejected: true,
+												Message:  "no banned users",
+											},
+										},
+									},
+									TokenExchange: []supervisorconfigv1alpha1.FederationDomainTokenExchangeTransforms{
+										{
+											Audiences: []string{"prod-cluster"},
+											Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+												{Type: "groups/v1", Expression: `groups.filter(g, g.startsWith(strConst.prefix))`},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{
+				federationDomainIssuerWithIDPs(t, "https://issuer1.com", []*federationdomainproviders.FederationDomainIdentityProvider{
+					{
+						DisplayName: "name1",
+						UID:         oidcIdentityProvider.UID,
+						Transforms: composeTransformationPipelines(
+							newTransformationPipeline(t, &celtransformer.TransformationConstants{
+								StringConstants:     map[string]string{"prefix": "a:"},
+								StringListConstants: map[string][]string{"admins": {"lib-admins"}},
+							},
+								&celtransformer.UsernameTransformation{Expression: `strConst.prefix + username`},
+							),
+							newTransformationPipeline(t, &celtransformer.TransformationConstants{
+								StringConstants: map[string]string{"prefix": "b:"},
+							},
+								&celtransformer.AllowAuthenticationPolicy{
+									Expression:                    `!username.contains("banned")`,
+									RejectedAuthenticationMessage: "no banned users",
+								},
+							),
+							// The last library's constant wins, and the identity provider's own constants win over the libraries.
+							newTransformationPipeline(t, &celtransformer.TransformationConstants{
+								StringConstants:     map[string]string{"prefix": "b:", "suffix": "@idp"},
+								StringListConstants: map[string][]string{"admins": {"idp-admins"}},
+							},
+								&celtransformer.UsernameTransformation{Expression: `username + strConst.suffix`},
+								&celtransformer.GroupsTransformation{Expression: `groups.map(g, strConst.prefix + g) + strListConst.admins`},
+							),
+						),
+						TokenExchangeTransforms: idtransform.TokenExchangePipelines{
+							idtransform.NewTokenExchangePipeline(
+								[]string{"prod-cluster"},
+								nil,
+								newTransformationPipeline(t, &celtransformer.TransformationConstants{
+									StringConstants:     map[string]string{"prefix": "b:", "suffix": "@idp"},
+									StringListConstants: map[string][]string{"admins": {"idp-admins"}},
+								},
+									&celtransformer.GroupsTransformation{Expression: `groups.filter(g, g.startsWith(strConst.prefix))`},
+								),
+							),
+						},
+					},
+				}),
+			},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(
+					&supervisorconfigv1alpha1.FederationDomain{
+						ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					},
+					supervisorconfigv1alpha1.FederationDomainPhaseReady,
+					allHappyConditionsSuccess("https://issuer1.com", frozenMetav1Now, 123),
+				),
+			},
+		},
+		{
+			name: "the federation domain has identity providers which use transforms libraries which cannot be found or are invalid",
+			inputObjects: []runtime.Object{
+				oidcIdentityProvider,
+				ldapIdentityProvider,
+				transformsLibraryA,
+				&supervisorconfigv1alpha1.IdentityTransformLibrary{
+					ObjectMeta: metav1.ObjectMeta{Name: "invalid-expression-library", Namespace: namespace},
+					Spec: supervisorconfigv1alpha1.IdentityTransformLibrarySpec{
+						Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+							{Type: "username/v1", Expression: `this is not a valid cel expression`},
+						},
+					},
+				},
+				&supervisorconfigv1alpha1.IdentityTransformLibrary{
+					ObjectMeta: metav1.ObjectMeta{Name: "failing-example-library", Namespace: namespace},
+					Spec: supervisorconfigv1alpha1.IdentityTransformLibrarySpec{
+						Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+							{Type: "username/v1", Expression: `"pre:" + username`},
+						},
+						Examples: []supervisorconfigv1alpha1.FederationDomainTransformsExample{
+							{
+								Username: "ryan",
+								Expects:  supervisorconfigv1alpha1.FederationDomainTransformsExampleExpects{Username: "wrong"},
+							},
+						},
+					},
+				},
+				&supervisorconfigv1alpha1.IdentityTransformLibrary{
+					ObjectMeta: metav1.ObjectMeta{Name: "library-in-another-namespace", Namespace: "other-namespace"},
+				},
+				&supervisorconfigv1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer1.com",
+						IdentityProviders: []supervisorconfigv1alpha1.FederationDomainIdentityProvider{
+							{
+								DisplayName: "name1",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "OIDCIdentityProvider",
+									Name:     oidcIdentityProvider.Name,
+								},
+								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
+									Libraries: []supervisorconfigv1alpha1.FederationDomainTransformsLibraryRef{
+										{Name: transformsLibraryA.Name},
+										{Name: "library-in-another-namespace"},
+									},
+									Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+										// this would not compile without the constant from the missing library, but it is not compiled
+										{Type: "username/v1", Expression: `strConst.fromMissingLibrary + username`},
+									},
+								},
+							},
+							{
+								DisplayName: "name2",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "LDAPIdentityProvider",
+									Name:     ldapIdentityProvider.Name,
+								},
+								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
+									Libraries: []supervisorconfigv1alpha1.FederationDomainTransformsLibraryRef{
+										{Name: "invalid-expression-library"},
+										{Name: "failing-example-library"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantFDIssuers: []*federationdomainproviders.FederationDomainIssuer{},
+			wantStatusUpdates: []*supervisorconfigv1alpha1.FederationDomain{
+				expectedFederationDomainStatusUpdate(
+					&supervisorconfigv1alpha1.FederationDomain{
+						ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					},
+					supervisorconfigv1alpha1.FederationDomainPhaseError,
+					conditionstestutil.Replace(
+						allHappyConditionsSuccess("https://issuer1.com", frozenMetav1Now, 123),
+						[]metav1.Condition{
+							sadTransformationLibrariesCondition(here.Doc(
+								`.spec.identityProviders[0].transforms.libraries[1].name refers to an IdentityTransformLibrary "library-in-another-namespace" which was not found
+
+								 .spec.identityProviders[1].transforms.libraries[0].name refers to an IdentityTransformLibrary "invalid-expression-library" which is invalid:
+								 spec.expressions[0].expression was invalid:
+								 CEL expression compile error: ERROR: <input>:1:6: Syntax error: mismatched input 'is' expecting <EOF>
+								  | this is not a valid cel expression
+								  | .....^
+
+								 unable to check if the examples specified by .spec.examples[] had errors because an expression was invalid
+
+								 .spec.identityProviders[1].transforms.libraries[1].name refers to an IdentityTransformLibrary "failing-example-library" which is invalid:
+								 .spec.examples[0] example failed:
+								 expected: username "wrong"
+								 actual:   username "pre:ryan"`,
+							), frozenMetav1Now, 123),
+							sadTransformationExamplesCondition(here.Doc(
+								`unable to check if the examples specified by .spec.identityProviders[0].transforms.examples[] had errors because a library was invalid
+
+								 unable to check if the examples specified by .spec.identityProviders[1].transforms.examples[] had errors because a library was invalid`,
+							), frozenMetav1Now, 123),
+							sadReadyCondition(frozenMetav1Now, 123),
+						}),
+				),
+			},
+		},
+		{
+			name: "the transforms library specifies illegal const type, which shouldn't really happen since the CRD validates it",
+			inputObjects: []runtime.Object{
+				oidcIdentityProvider,
+				&supervisorconfigv1alpha1.IdentityTransformLibrary{
+					ObjectMeta: metav1.ObjectMeta{Name: "illegal-library", Namespace: namespace},
+					Spec: supervisorconfigv1alpha1.IdentityTransformLibrarySpec{
+						Constants: []supervisorconfigv1alpha1.FederationDomainTransformsConstant{
+							{
+								Type: "this is illegal",
+							},
+						},
+					},
+				},
+				&supervisorconfigv1alpha1.FederationDomain{
+					ObjectMeta: metav1.ObjectMeta{Name: "config1", Namespace: namespace, Generation: 123},
+					Spec: supervisorconfigv1alpha1.FederationDomainSpec{
+						Issuer: "https://issuer1.com",
+						IdentityProviders: []supervisorconfigv1alpha1.FederationDomainIdentityProvider{
+							{
+								DisplayName: "can-find-me",
+								ObjectRef: corev1.TypedLocalObjectReference{
+									APIGroup: ptr.To(apiGroupSupervisor),
+									Kind:     "OIDCIdentityProvider",
+									Name:     oidcIdentityProvider.Name,
+								},
+								Transforms: supervisorconfigv1alpha1.FederationDomainTransforms{
+									Libraries: []supervisorconfigv1alpha1.FederationDomainTransformsLibraryRef{
+										{Name: "illegal-library"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: `one of spec.constants[].type is invalid: "this is illegal"`,
+		},
 		{
 			name: "the federation domain specifies illegal const type, which shouldn't really happen since the CRD validates it",
 			inputObjects: []runtime.Object{
@@ -2104,10 +2946,14 @@ func TestTestFederationDomainWatcherControllerSync(t *testing.T) {
 				clocktesting.NewFakeClock(frozenNow),
 				pinnipedAPIClient,
 				pinnipedInformers.Config().V1alpha1().FederationDomains(),
+				pinnipedInformers.Config().V1alpha1().IdentityTransformLibraries(),
 				pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
 				pinnipedInformers.IDP().V1alpha1().LDAPIdentityProviders(),
 				pinnipedInformers.IDP().V1alpha1().ActiveDirectoryIdentityProviders(),
 				pinnipedInformers.IDP().V1alpha1().GitHubIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().SAMLIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().OAuth2IdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().LocalUserIdentityProviders(),
 				controllerlib.WithInformer,
 			)
 
@@ -2163,8 +3009,15 @@ type comparableFederationDomainIssuer struct {
 }
 
 type comparableFederationDomainIdentityProvider struct {
-	DisplayName      string
-	UID              types.UID
+	DisplayName             string
+	UID                     types.UID
+	TransformsSource        []any
+	TokenExchangeTransforms []*comparableTokenExchangePipeline
+}
+
+type comparableTokenExchangePipeline struct {
+	Audiences        []string
+	ClientIDs        []string
 	TransformsSource []any
 }
 
@@ -2172,10 +3025,19 @@ func makeFederationDomainIdentityProviderComparable(fdi *federationdomainprovide
 	if fdi == nil {
 		return nil
 	}
+	var tokenExchangeTransforms []*comparableTokenExchangePipeline
+	for _, p := range fdi.TokenExchangeTransforms {
+		tokenExchangeTransforms = append(tokenExchangeTransforms, &comparableTokenExchangePipeline{
+			Audiences:        p.Audiences(),
+			ClientIDs:        p.ClientIDs(),
+			TransformsSource: p.Pipeline().Source(),
+		})
+	}
 	return &comparableFederationDomainIdentityProvider{
-		DisplayName:      fdi.DisplayName,
-		UID:              fdi.UID,
-		TransformsSource: fdi.Transforms.Source(),
+		DisplayName:             fdi.DisplayName,
+		UID:                     fdi.UID,
+		TransformsSource:        fdi.Transforms.Source(),
+		TokenExchangeTransforms: tokenExchangeTransforms,
 	}
 }
 
@@ -2270,6 +3132,14 @@ func newTransformationPipeline(
 	return pipeline
 }
 
+func composeTransformationPipelines(pipelines ...*idtransform.TransformationPipeline) *idtransform.TransformationPipeline {
+	composed := idtransform.NewTransformationPipeline()
+	for _, pipeline := range pipelines {
+		composed.AppendPipeline(pipeline)
+	}
+	return composed
+}
+
 func TestTransformationPipelinesCanBeTestedForEqualityUsingSourceToMakeTestingEasier(t *testing.T) {
 	compiler, err := celtransformer.NewCELTransformer(5 * time.Second)
 	require.NoError(t, err)
@@ -2356,3 +3226,43 @@ func TestTransformationPipelinesCanBeTestedForEqualityUsingSourceToMakeTestingEa
 	require.NotEqual(t, pipeline.Source(), differentPipeline1.Source())
 	require.NotEqual(t, pipeline.Source(), differentPipeline2.Source())
 }
+
+func TestCompileTransformsLibraryOnlyCompilesAgainWhenTheSpecChanges(t *testing.T) {
+	compiler, err := celtransformer.NewCELTransformer(5 * time.Second)
+	require.NoError(t, err)
+
+	c := &federationDomainWatcherController{
+		celTransformer:    compiler,
+		compiledLibraries: map[types.NamespacedName]*compiledTransformsLibrary{},
+	}
+
+	library := &supervisorconfigv1alpha1.IdentityTransformLibrary{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-library", Namespace: "some-namespace", ResourceVersion: "1"},
+		Spec: supervisorconfigv1alpha1.IdentityTransformLibrarySpec{
+			Expressions: []supervisorconfigv1alpha1.FederationDomainTransformsExpression{
+				{Type: "username/v1", Expression: `"pre:" + username`},
+			},
+		},
+	}
+
+	compiled, err := c.compileTransformsLibrary(context.Background(), library)
+	require.NoError(t, err)
+	require.Empty(t, compiled.ErrorMessages)
+
+	// Compiling the same spec again uses the cached result, even when other fields of the library have changed.
+	sameSpec := library.DeepCopy()
+	sameSpec.ResourceVersion = "2"
+	compiledAgain, err := c.compileTransformsLibrary(context.Background(), sameSpec)
+	require.NoError(t, err)
+	require.Same(t, compiled, compiledAgain)
+
+	// Compiling a changed spec compiles it again.
+	changedSpec := library.DeepCopy()
+	changedSpec.Spec.Expressions[0].Expression = `this is not a valid cel expression`
+	compiledChanged, err := c.compileTransformsLibrary(context.Background(), changedSpec)
+	require.NoError(t, err)
+	require.NotSame(t, compiled, compiledChanged)
+	require.Nil(t, compiledChanged.Pipeline)
+	require.Len(t, compiledChanged.ErrorMessages, 2)
+	require.Same(t, compiledChanged, c.compiledLibraries[types.NamespacedName{Namespace: "some-namespace", Name: "some-library"}].library)
+}
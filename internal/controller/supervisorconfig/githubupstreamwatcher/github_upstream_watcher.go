@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package githubupstreamwatcher implements a controller which watches GitHubIdentityProviders.
@@ -6,11 +6,13 @@ package githubupstreamwatcher
 
 import (
 	"context"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,6 +37,7 @@ import (
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/endpointaddr"
 	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/githubclient"
 	"go.pinniped.dev/internal/net/phttp"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/setutil"
@@ -48,12 +51,17 @@ const (
 	gitHubClientSecretType               corev1.SecretType = "secrets.pinniped.dev/github-client"
 	clientIDDataKey, clientSecretDataKey string            = "clientID", "clientSecret"
 
-	countExpectedConditions = 6
+	// Constants related to the GitHub App credentials Secret.
+	gitHubAppSecretType             corev1.SecretType = "secrets.pinniped.dev/github-app"
+	appIDDataKey, privateKeyDataKey string            = "appID", "privateKey"
+
+	countExpectedConditions = 7
 
 	HostValid                    string = "HostValid"
 	TLSConfigurationValid        string = "TLSConfigurationValid"
 	OrganizationsPolicyValid     string = "OrganizationsPolicyValid"
 	ClientCredentialsSecretValid string = "ClientCredentialsSecretValid" //nolint:gosec // this is not a credential
+	GitHubAppSecretValid         string = "GitHubAppSecretValid"         //nolint:gosec // this is not a credential
 	GitHubConnectionValid        string = "GitHubConnectionValid"
 	ClaimsValid                  string = "ClaimsValid"
 
@@ -72,6 +80,15 @@ const (
 	headerValueAcceptGitHubJSON   = "application/vnd.github+json"
 )
 
+// allRepositoryRoles are the valid values for the minimumRole of a repository rule.
+var allRepositoryRoles = []idpv1alpha1.GitHubRepositoryRole{ //nolint:gochecknoglobals
+	idpv1alpha1.GitHubRepositoryRoleRead,
+	idpv1alpha1.GitHubRepositoryRoleTriage,
+	idpv1alpha1.GitHubRepositoryRoleWrite,
+	idpv1alpha1.GitHubRepositoryRoleMaintain,
+	idpv1alpha1.GitHubRepositoryRoleAdmin,
+}
+
 // UpstreamGitHubIdentityProviderICache is a thread safe cache that holds a list of validated upstream GitHub IDP configurations.
 type UpstreamGitHubIdentityProviderICache interface {
 	SetGitHubIdentityProviders([]upstreamprovider.UpstreamGithubIdentityProviderI)
@@ -168,6 +185,7 @@ func New(
 			pinnipedcontroller.MatchAnySecretOfTypesFilter(
 				[]corev1.SecretType{
 					gitHubClientSecretType,
+					gitHubAppSecretType,
 					corev1.SecretTypeOpaque,
 					corev1.SecretTypeTLS,
 				},
@@ -271,7 +289,80 @@ func (c *gitHubWatcherController) validateClientSecret(secretName string) (*meta
 	}, clientID, clientSecret, nil
 }
 
-func validateOrganizationsPolicy(organizationsSpec *idpv1alpha1.GitHubOrganizationsSpec) *metav1.Condition {
+func (c *gitHubWatcherController) validateAppSecret(appSpec *idpv1alpha1.GitHubAppSpec) (*metav1.Condition, int64, *rsa.PrivateKey, error) {
+	if appSpec == nil {
+		return &metav1.Condition{
+			Type:    GitHubAppSecretValid,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditionsutil.ReasonSuccess,
+			Message: "spec.app is not configured: using the user's access token to look up repository roles",
+		}, 0, nil, nil
+	}
+
+	secretName := appSpec.SecretName
+	secret, unableToRetrieveSecretErr := c.secretInformer.Lister().Secrets(c.namespace).Get(secretName)
+
+	// This error requires user interaction, so ignore it.
+	if apierrors.IsNotFound(unableToRetrieveSecretErr) {
+		unableToRetrieveSecretErr = nil
+	}
+
+	buildFalseCondition := func(prefix string) (*metav1.Condition, int64, *rsa.PrivateKey, error) {
+		return &metav1.Condition{
+			Type:   GitHubAppSecretValid,
+			Status: metav1.ConditionFalse,
+			Reason: upstreamwatchers.ReasonNotFound,
+			Message: fmt.Sprintf("%s: secret from spec.app.secretName (%q) must be found in namespace %q with type %q and keys %q and %q",
+				prefix,
+				secretName,
+				c.namespace,
+				gitHubAppSecretType,
+				appIDDataKey,
+				privateKeyDataKey),
+		}, 0, nil, unableToRetrieveSecretErr
+	}
+
+	if unableToRetrieveSecretErr != nil || secret == nil {
+		return buildFalseCondition(fmt.Sprintf("secret %q not found", secretName))
+	}
+
+	if secret.Type != gitHubAppSecretType {
+		return buildFalseCondition(fmt.Sprintf("wrong secret type %q", secret.Type))
+	}
+
+	appIDString := string(secret.Data[appIDDataKey])
+	if len(appIDString) < 1 {
+		return buildFalseCondition(fmt.Sprintf("missing key %q", appIDDataKey))
+	}
+	appID, err := strconv.ParseInt(strings.TrimSpace(appIDString), 10, 64)
+	if err != nil || appID < 1 {
+		return buildFalseCondition(fmt.Sprintf("invalid value for key %q: must be a positive integer", appIDDataKey))
+	}
+
+	privateKeyPEM := secret.Data[privateKeyDataKey]
+	if len(privateKeyPEM) < 1 {
+		return buildFalseCondition(fmt.Sprintf("missing key %q", privateKeyDataKey))
+	}
+	privateKey, err := githubclient.ParseAppPrivateKey(privateKeyPEM)
+	if err != nil {
+		return buildFalseCondition(fmt.Sprintf("invalid value for key %q: %s", privateKeyDataKey, err.Error()))
+	}
+
+	if len(secret.Data) != 2 {
+		return buildFalseCondition("extra keys found")
+	}
+
+	return &metav1.Condition{
+		Type:    GitHubAppSecretValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  conditionsutil.ReasonSuccess,
+		Message: fmt.Sprintf("appID and privateKey have been read from spec.app.secretName (%q)", secretName),
+	}, appID, privateKey, nil
+}
+
+func validateOrganizationsPolicy(allowAuthenticationSpec *idpv1alpha1.GitHubAllowAuthenticationSpec) *metav1.Condition {
+	organizationsSpec := &allowAuthenticationSpec.Organizations
+
 	var policy idpv1alpha1.GitHubAllowedAuthOrganizationsPolicy
 	if organizationsSpec.Policy != nil {
 		policy = *organizationsSpec.Policy
@@ -281,6 +372,15 @@ func validateOrganizationsPolicy(organizationsSpec *idpv1alpha1.GitHubOrganizati
 	// That is why the message here is very minimal
 	if (policy == idpv1alpha1.GitHubAllowedAuthOrganizationsPolicyAllGitHubUsers && len(organizationsSpec.Allowed) == 0) ||
 		(policy == idpv1alpha1.GitHubAllowedAuthOrganizationsPolicyOnlyUsersFromAllowedOrganizations && len(organizationsSpec.Allowed) > 0) {
+		if invalidRuleMessage := validateTeamAndRepositoryRules(allowAuthenticationSpec); invalidRuleMessage != "" {
+			return &metav1.Condition{
+				Type:    OrganizationsPolicyValid,
+				Status:  metav1.ConditionFalse,
+				Reason:  reasonInvalid,
+				Message: invalidRuleMessage,
+			}
+		}
+
 		return &metav1.Condition{
 			Type:    OrganizationsPolicyValid,
 			Status:  metav1.ConditionTrue,
@@ -306,6 +406,46 @@ func validateOrganizationsPolicy(organizationsSpec *idpv1alpha1.GitHubOrganizati
 	}
 }
 
+// validateTeamAndRepositoryRules returns a message describing the first invalid team or repository rule,
+// or an empty string when all rules are valid.
+func validateTeamAndRepositoryRules(allowAuthenticationSpec *idpv1alpha1.GitHubAllowAuthenticationSpec) string {
+	allowedOrganizations := setutil.NewCaseInsensitiveSet(allowAuthenticationSpec.Organizations.Allowed...)
+
+	for i, team := range allowAuthenticationSpec.Teams {
+		if msg := validateOwnerAndName(team, allowedOrganizations, fmt.Sprintf("spec.allowAuthentication.teams[%d]", i), "org/team-slug"); msg != "" {
+			return msg
+		}
+	}
+
+	for i, rule := range allowAuthenticationSpec.Repositories {
+		if msg := validateOwnerAndName(rule.Name, allowedOrganizations, fmt.Sprintf("spec.allowAuthentication.repositories[%d].name", i), "owner/repo"); msg != "" {
+			return msg
+		}
+
+		// Should not happen due to CRD defaulting and enum validation.
+		if rule.MinimumRole != nil && !slices.Contains(allRepositoryRoles, *rule.MinimumRole) {
+			return fmt.Sprintf("spec.allowAuthentication.repositories[%d].minimumRole (%q) is not valid", i, *rule.MinimumRole)
+		}
+	}
+
+	return ""
+}
+
+// validateOwnerAndName validates that value is in the form "owner/name", and that the owner is one of the
+// allowed organizations, unless all organizations are allowed. Returns an empty string when valid.
+func validateOwnerAndName(value string, allowedOrganizations *setutil.CaseInsensitiveSet, fieldPath, form string) string {
+	owner, name, found := strings.Cut(value, "/")
+	if !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Sprintf("%s (%q) must be in the form %q", fieldPath, value, form)
+	}
+
+	if !allowedOrganizations.Empty() && !allowedOrganizations.ContainsIgnoringCase(owner) {
+		return fmt.Sprintf("%s (%q) must belong to one of the organizations listed in spec.allowAuthentication.organizations.allowed", fieldPath, value)
+	}
+
+	return ""
+}
+
 func (c *gitHubWatcherController) validateUpstreamAndUpdateConditions(ctx context.Context, upstream *idpv1alpha1.GitHubIdentityProvider) (
 	*upstreamgithub.Provider, // If validated, returns the config
 	error, // This error will only refer to programmatic errors such as inability to perform a connection probe or dereference a pointer, not configuration errors
@@ -319,13 +459,19 @@ func (c *gitHubWatcherController) validateUpstreamAndUpdateConditions(ctx contex
 		applicationErrors = append(applicationErrors, clientSecretErr)
 	}
 
+	appSecretCondition, appID, appPrivateKey, appSecretErr := c.validateAppSecret(upstream.Spec.App)
+	conditions = append(conditions, appSecretCondition)
+	if appSecretErr != nil { // untested
+		applicationErrors = append(applicationErrors, appSecretErr)
+	}
+
 	// Should there be some sort of catch-all condition to capture this?
 	// This does not actually prevent a GitHub IDP from being added to the cache.
 	// CRD defaulting and validation should eliminate the possibility of an error here.
 	userAndGroupCondition, groupNameAttribute, usernameAttribute := validateUserAndGroupAttributes(upstream)
 	conditions = append(conditions, userAndGroupCondition)
 
-	organizationPolicyCondition := validateOrganizationsPolicy(&upstream.Spec.AllowAuthentication.Organizations)
+	organizationPolicyCondition := validateOrganizationsPolicy(&upstream.Spec.AllowAuthentication)
 	conditions = append(conditions, organizationPolicyCondition)
 
 	hostCondition, apiHostPort := validateHost(upstream.Spec.GitHubAPI.Host)
@@ -370,6 +516,21 @@ func (c *gitHubWatcherController) validateUpstreamAndUpdateConditions(ctx contex
 
 	oauthBaseURL := oauthBaseUrl(apiHostPort)
 
+	var appClient githubclient.GitHubAppInterface
+	if upstream.Spec.App != nil {
+		var appClientErr error
+		appClient, appClientErr = githubclient.NewGitHubAppClient(httpClient, apiBaseURL(apiHostPort), appID, appPrivateKey)
+		if appClientErr != nil { // untested, since the inputs were already validated
+			applicationErrors = append(applicationErrors, appClientErr)
+			return nil, utilerrors.NewAggregate(applicationErrors)
+		}
+	}
+
+	var repositoryRoleGroups []string
+	if upstream.Spec.Claims.RepositoryRoleGroups != nil {
+		repositoryRoleGroups = upstream.Spec.Claims.RepositoryRoleGroups.Repositories
+	}
+
 	provider := upstreamgithub.New(
 		upstreamgithub.ProviderConfig{
 			Name:               upstream.Name,
@@ -391,12 +552,35 @@ func (c *gitHubWatcherController) validateUpstreamAndUpdateConditions(ctx contex
 				Scopes:      []string{"read:user", "read:org"},
 			},
 			AllowedOrganizations: setutil.NewCaseInsensitiveSet(upstream.Spec.AllowAuthentication.Organizations.Allowed...),
+			AllowedTeams:         setutil.NewCaseInsensitiveSet(upstream.Spec.AllowAuthentication.Teams...),
+			AllowedRepositories:  buildRepositoryAllowRules(upstream.Spec.AllowAuthentication.Repositories),
+			RepositoryRoleGroups: repositoryRoleGroups,
+			AppClient:            appClient,
 			HttpClient:           httpClient,
 		},
 	)
 	return provider, utilerrors.NewAggregate(applicationErrors)
 }
 
+func buildRepositoryAllowRules(rules []idpv1alpha1.GitHubRepositoryAllowRule) []upstreamgithub.RepositoryAllowRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	result := make([]upstreamgithub.RepositoryAllowRule, 0, len(rules))
+	for _, rule := range rules {
+		minimumRole := idpv1alpha1.GitHubRepositoryRoleRead
+		if rule.MinimumRole != nil {
+			minimumRole = *rule.MinimumRole
+		}
+		result = append(result, upstreamgithub.RepositoryAllowRule{
+			Repository:  rule.Name,
+			MinimumRole: minimumRole,
+		})
+	}
+	return result
+}
+
 func apiBaseURL(apiHostPort *endpointaddr.HostPort) string {
 	endpoint := hostPortForHTTPS(apiHostPort)
 
@@ -608,6 +792,16 @@ func validateUserAndGroupAttributes(upstream *idpv1alpha1.GitHubIdentityProvider
 		return buildInvalidCondition(fmt.Sprintf("spec.claims.groups (%q) is not valid", groupNameAttribute)), "", ""
 	}
 
+	if upstream.Spec.Claims.RepositoryRoleGroups != nil {
+		allowedOrganizations := setutil.NewCaseInsensitiveSet(upstream.Spec.AllowAuthentication.Organizations.Allowed...)
+		for i, repository := range upstream.Spec.Claims.RepositoryRoleGroups.Repositories {
+			fieldPath := fmt.Sprintf("spec.claims.repositoryRoleGroups.repositories[%d]", i)
+			if msg := validateOwnerAndName(repository, allowedOrganizations, fieldPath, "owner/repo"); msg != "" {
+				return buildInvalidCondition(msg), "", ""
+			}
+		}
+	}
+
 	return &metav1.Condition{
 		Type:    ClaimsValid,
 		Status:  metav1.ConditionTrue,
@@ -1,12 +1,15 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package githubupstreamwatcher
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"net"
 	"net/http"
@@ -109,7 +112,7 @@ func TestProbeURL(t *testing.T) {
 }
 
 func TestController(t *testing.T) {
-	require.Equal(t, 6, countExpectedConditions)
+	require.Equal(t, 7, countExpectedConditions)
 
 	goodServer, goodServerCA := tlsserver.TestServerIPv4(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	}), tlsserver.RecordTLSHello)
@@ -153,6 +156,22 @@ func TestController(t *testing.T) {
 		},
 	}
 
+	appPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	appPrivateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(appPrivateKey)})
+
+	goodGitHubAppSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "some-app-secret-name",
+			Namespace: namespace,
+		},
+		Type: "secrets.pinniped.dev/github-app",
+		Data: map[string][]byte{
+			"appID":      []byte("12345"),
+			"privateKey": appPrivateKeyPEM,
+		},
+	}
+
 	goodCABundleSecret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "ca-bundle-secret-name",
@@ -235,6 +254,17 @@ func TestController(t *testing.T) {
 		},
 	}
 
+	validIDPWithAppAndRules := validFilledOutIDP.DeepCopy()
+	validIDPWithAppAndRules.Spec.App = &idpv1alpha1.GitHubAppSpec{SecretName: goodGitHubAppSecret.Name}
+	validIDPWithAppAndRules.Spec.AllowAuthentication.Teams = []string{"organization1/team1", "ORG2/Team2"}
+	validIDPWithAppAndRules.Spec.AllowAuthentication.Repositories = []idpv1alpha1.GitHubRepositoryAllowRule{
+		{Name: "org2/repo1", MinimumRole: ptr.To(idpv1alpha1.GitHubRepositoryRoleMaintain)},
+		{Name: "organization1/repo2"},
+	}
+	validIDPWithAppAndRules.Spec.Claims.RepositoryRoleGroups = &idpv1alpha1.GitHubRepositoryRoleGroupsSpec{
+		Repositories: []string{"org2/repo1", "organization1/repo3"},
+	}
+
 	buildHostValidTrue := func(t *testing.T, host string) metav1.Condition {
 		return metav1.Condition{
 			Type:               HostValid,
@@ -315,6 +345,17 @@ func TestController(t *testing.T) {
 		}
 	}
 
+	buildGitHubAppSecretValidNotConfigured := func(t *testing.T) metav1.Condition {
+		return metav1.Condition{
+			Type:               GitHubAppSecretValid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: wantObservedGeneration,
+			LastTransitionTime: wantLastTransitionTime,
+			Reason:             conditionsutil.ReasonSuccess,
+			Message:            "spec.app is not configured: using the user's access token to look up repository roles",
+		}
+	}
+
 	buildClientCredentialsSecretValidFalse := func(t *testing.T, prefix, secretName, namespace, reason string) metav1.Condition {
 		return metav1.Condition{
 			Type:               ClientCredentialsSecretValid,
@@ -331,6 +372,33 @@ func TestController(t *testing.T) {
 		}
 	}
 
+	buildGitHubAppSecretValidTrue := func(t *testing.T, secretName string) metav1.Condition {
+		return metav1.Condition{
+			Type:               GitHubAppSecretValid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: wantObservedGeneration,
+			LastTransitionTime: wantLastTransitionTime,
+			Reason:             conditionsutil.ReasonSuccess,
+			Message:            fmt.Sprintf("appID and privateKey have been read from spec.app.secretName (%q)", secretName),
+		}
+	}
+
+	buildGitHubAppSecretValidFalse := func(t *testing.T, prefix, secretName, namespace string) metav1.Condition {
+		return metav1.Condition{
+			Type:               GitHubAppSecretValid,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: wantObservedGeneration,
+			LastTransitionTime: wantLastTransitionTime,
+			Reason:             upstreamwatchers.ReasonNotFound,
+			Message: fmt.Sprintf(
+				`%s: secret from spec.app.secretName (%q) must be found in namespace %q with type "secrets.pinniped.dev/github-app" and keys "appID" and "privateKey"`,
+				prefix,
+				secretName,
+				namespace,
+			),
+		}
+	}
+
 	buildClaimsValidatedTrue := func(t *testing.T) metav1.Condition {
 		return metav1.Condition{
 			Type:               ClaimsValid,
@@ -398,6 +466,14 @@ func TestController(t *testing.T) {
 		return fmt.Sprintf(`{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"github-upstream-observer","caller":"conditionsutil/conditions_util.go:<line>$conditionsutil.MergeConditions","message":"updated condition","namespace":"some-namespace","name":"%s","type":"ClientCredentialsSecretValid","status":"%s","reason":"%s","message":"%s"}`, name, status, reason, message)
 	}
 
+	buildLogForUpdatingGitHubAppSecretValid := func(name, status, reason, message string) string {
+		return fmt.Sprintf(`{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"github-upstream-observer","caller":"conditionsutil/conditions_util.go:<line>$conditionsutil.MergeConditions","message":"updated condition","namespace":"some-namespace","name":"%s","type":"GitHubAppSecretValid","status":"%s","reason":"%s","message":"%s"}`, name, status, reason, message)
+	}
+
+	buildLogForUpdatingGitHubAppSecretValidNotConfigured := func(name string) string {
+		return buildLogForUpdatingGitHubAppSecretValid(name, "True", "Success", "spec.app is not configured: using the user's access token to look up repository roles")
+	}
+
 	buildLogForUpdatingOrganizationPolicyValid := func(name, status, reason, message string) string {
 		return fmt.Sprintf(`{"level":"info","timestamp":"2099-08-08T13:57:36.123456Z","logger":"github-upstream-observer","caller":"conditionsutil/conditions_util.go:<line>$conditionsutil.MergeConditions","message":"updated condition","namespace":"some-namespace","name":"%s","type":"OrganizationsPolicyValid","status":"%s","reason":"%s","message":"%s"}`, name, status, reason, message)
 	}
@@ -437,6 +513,7 @@ func TestController(t *testing.T) {
 		wantResultingCache        []*upstreamgithub.ProviderConfig
 		wantResultingUpstreams    []idpv1alpha1.GitHubIdentityProvider
 		wantValidatedCache        []GitHubValidatedAPICacheKey
+		wantAppClient             bool
 	}{
 		{
 			name:                   "no GitHubIdentityProviders",
@@ -488,6 +565,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -498,6 +576,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -550,6 +629,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -560,6 +640,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validMinimalIDP.Spec.GitHubAPI.Host),
@@ -625,6 +706,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, "api.github.com:443", "github.com"),
 							buildHostValidTrue(t, "github.com"),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -635,6 +717,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, "github.com"),
@@ -700,6 +783,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, "api.github.com:443", "GitHub.com"),
 							buildHostValidTrue(t, "GitHub.com"),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -710,6 +794,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, "GitHub.com"),
@@ -775,6 +860,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, "api.github.com:443", "api.github.com"),
 							buildHostValidTrue(t, "api.github.com"),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -785,6 +871,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, "api.github.com"),
@@ -850,6 +937,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, "api.github.com:443", "github.com:443"),
 							buildHostValidTrue(t, "github.com:443"),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -860,6 +948,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, "github.com:443"),
@@ -925,6 +1014,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, "api.github.com:443", "api.GitHub.com:443"),
 							buildHostValidTrue(t, "api.GitHub.com:443"),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -935,6 +1025,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, "api.GitHub.com:443"),
@@ -1002,6 +1093,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, goodServerIPv6Domain, goodServerIPv6Domain),
 							buildHostValidTrue(t, goodServerIPv6Domain),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1012,6 +1104,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, goodServerIPv6Domain),
@@ -1123,6 +1216,7 @@ func TestController(t *testing.T) {
 								namespace,
 								upstreamwatchers.ReasonNotFound,
 							),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1147,6 +1241,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, "other-secret-name"),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1162,6 +1257,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1172,6 +1268,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("invalid-idp-name", "False", "SecretNotFound", `secret \"no-secret-with-this-name\" not found: secret from spec.client.SecretName (\"no-secret-with-this-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("invalid-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("invalid-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("invalid-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("invalid-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1180,6 +1277,7 @@ func TestController(t *testing.T) {
 				buildLogForUpdatingPhase("invalid-idp-name", "Error"),
 
 				buildLogForUpdatingClientCredentialsSecretValid("other-idp-name", "True", "Success", `clientID and clientSecret have been read from spec.client.SecretName (\"other-secret-name\")`),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("other-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("other-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("other-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("other-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1188,6 +1286,7 @@ func TestController(t *testing.T) {
 				buildLogForUpdatingPhase("other-idp-name", "Ready"),
 
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1304,6 +1403,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, "some-secret-name"),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1333,6 +1433,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, "some-secret-name"),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1343,6 +1444,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("idp-with-tls-in-config-map", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("idp-with-tls-in-config-map"),
 				buildLogForUpdatingClaimsValidTrue("idp-with-tls-in-config-map"),
 				buildLogForUpdatingOrganizationPolicyValid("idp-with-tls-in-config-map", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("idp-with-tls-in-config-map", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1351,6 +1453,7 @@ func TestController(t *testing.T) {
 				buildLogForUpdatingPhase("idp-with-tls-in-config-map", "Ready"),
 
 				buildLogForUpdatingClientCredentialsSecretValid("idp-with-tls-in-secret", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("idp-with-tls-in-secret"),
 				buildLogForUpdatingClaimsValidTrue("idp-with-tls-in-secret"),
 				buildLogForUpdatingOrganizationPolicyValid("idp-with-tls-in-secret", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("idp-with-tls-in-secret", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1408,6 +1511,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1418,6 +1522,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1449,6 +1554,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidUnknown(t),
 							buildHostValidFalse(t, "", "must not be empty"),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1459,6 +1565,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "False", "InvalidHost", `spec.githubAPI.host (\"%s\") is not valid: must not be empty`, ""),
@@ -1490,6 +1597,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidUnknown(t),
 							buildHostValidFalse(t, "https://example.com", `invalid port "//example.com"`),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1500,6 +1608,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "False", "InvalidHost", `spec.githubAPI.host (\"%s\") is not valid: invalid port \"//example.com\"`, "https://example.com"),
@@ -1531,6 +1640,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidUnknown(t),
 							buildHostValidFalse(t, "example.com/foo", `host "example.com/foo" is not a valid hostname or IP address`),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1541,6 +1651,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "False", "InvalidHost", `spec.githubAPI.host (\"%s\") is not valid: host \"example.com/foo\" is not a valid hostname or IP address`, "example.com/foo"),
@@ -1572,6 +1683,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidUnknown(t),
 							buildHostValidFalse(t, "u:p@example.com", `invalid port "p@example.com"`),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1582,6 +1694,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "False", "InvalidHost", `spec.githubAPI.host (\"%s\") is not valid: invalid port \"p@example.com\"`, "u:p@example.com"),
@@ -1613,6 +1726,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidUnknown(t),
 							buildHostValidFalse(t, "example.com?a=b", `host "example.com?a=b" is not a valid hostname or IP address`),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1623,6 +1737,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "False", "InvalidHost", `spec.githubAPI.host (\"%s\") is not valid: host \"example.com?a=b\" is not a valid hostname or IP address`, "example.com?a=b"),
@@ -1654,6 +1769,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidUnknown(t),
 							buildHostValidFalse(t, "example.com#a", `host "example.com#a" is not a valid hostname or IP address`),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1664,6 +1780,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "False", "InvalidHost", `spec.githubAPI.host (\"%s\") is not valid: host \"example.com#a\" is not a valid hostname or IP address`, "example.com#a"),
@@ -1699,6 +1816,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidUnknown(t),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1709,6 +1827,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1741,6 +1860,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidFalse(t, fmt.Sprintf(`cannot make connection probe request for spec.githubAPI.host (%q): dial tcp: lookup nowhere.bad-tld: no such host`, "nowhere.bad-tld")),
 							buildHostValidTrue(t, "nowhere.bad-tld"),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1751,6 +1871,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, "nowhere.bad-tld"),
@@ -1782,6 +1903,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validMinimalIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidUnknown(t),
 							buildHostValidFalse(t, "0:0:0:0:0:0:0:1:9876", `host "0:0:0:0:0:0:0:1:9876" is not a valid hostname or IP address`),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1792,6 +1914,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validMinimalIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "False", "InvalidHost", `spec.githubAPI.host (\"0:0:0:0:0:0:0:1:9876\") is not valid: host \"%s\" is not a valid hostname or IP address`, "0:0:0:0:0:0:0:1:9876"),
@@ -1824,6 +1947,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidFalse(t, fmt.Sprintf(`cannot make connection probe request for spec.githubAPI.host (%q): Get %q: tls: failed to verify certificate: x509: certificate signed by unknown authority`, *validFilledOutIDP.Spec.GitHubAPI.Host, fmt.Sprintf("https://%s/api/v3/rate_limit", *validFilledOutIDP.Spec.GitHubAPI.Host))),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1834,6 +1958,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1870,6 +1995,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidFalse(t, fmt.Sprintf(`cannot make connection probe request for spec.githubAPI.host (%q): Get %q: tls: failed to verify certificate: x509: certificate signed by unknown authority`, *validFilledOutIDP.Spec.GitHubAPI.Host, fmt.Sprintf("https://%s/api/v3/rate_limit", *validFilledOutIDP.Spec.GitHubAPI.Host))),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -1880,6 +2006,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1911,6 +2038,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidFalse(t, "spec.allowAuthentication.organizations.policy must be 'OnlyUsersFromAllowedOrganizations' when spec.allowAuthentication.organizations.allowed has organizations listed"),
@@ -1921,6 +2049,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "False", "Invalid", "spec.allowAuthentication.organizations.policy must be 'OnlyUsersFromAllowedOrganizations' when spec.allowAuthentication.organizations.allowed has organizations listed"),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1952,6 +2081,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidFalse(t, "spec.allowAuthentication.organizations.policy must be 'OnlyUsersFromAllowedOrganizations' when spec.allowAuthentication.organizations.allowed has organizations listed"),
@@ -1962,6 +2092,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "False", "Invalid", "spec.allowAuthentication.organizations.policy must be 'OnlyUsersFromAllowedOrganizations' when spec.allowAuthentication.organizations.allowed has organizations listed"),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -1993,6 +2124,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidFalse(t, "spec.allowAuthentication.organizations.policy must be 'OnlyUsersFromAllowedOrganizations' when spec.allowAuthentication.organizations.allowed has organizations listed"),
@@ -2003,6 +2135,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "False", "Invalid", "spec.allowAuthentication.organizations.policy must be 'OnlyUsersFromAllowedOrganizations' when spec.allowAuthentication.organizations.allowed has organizations listed"),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -2034,6 +2167,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidFalse(t, "spec.allowAuthentication.organizations.policy must be 'AllGitHubUsers' when spec.allowAuthentication.organizations.allowed is empty"),
@@ -2044,6 +2178,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "False", "Invalid", "spec.allowAuthentication.organizations.policy must be 'AllGitHubUsers' when spec.allowAuthentication.organizations.allowed is empty"),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -2075,6 +2210,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedFalse(t, "spec.claims.username is required"),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -2085,6 +2221,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidFalse("some-idp-name", "spec.claims.username is required"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -2116,6 +2253,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedFalse(t, `spec.claims.username ("a") is not valid`),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -2126,6 +2264,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidFalse("some-idp-name", `spec.claims.username (\"a\") is not valid`),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -2157,6 +2296,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedFalse(t, "spec.claims.groups is required"),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -2167,6 +2307,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidFalse("some-idp-name", "spec.claims.groups is required"),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -2198,6 +2339,7 @@ func TestController(t *testing.T) {
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedFalse(t, `spec.claims.groups ("b") is not valid`),
 							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -2208,6 +2350,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("some-idp-name"),
 				buildLogForUpdatingClaimsValidFalse("some-idp-name", `spec.claims.groups (\"b\") is not valid`),
 				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -2217,202 +2360,841 @@ func TestController(t *testing.T) {
 			},
 		},
 		{
-			name: "Client Secret error - in different namespace",
-			secretsAndConfigMaps: []runtime.Object{
-				func() runtime.Object {
-					badSecret := goodClientCredentialsSecret.DeepCopy()
-					badSecret.Namespace = "other-namespace"
-					return badSecret
-				}(),
+			name:                 "happy path with GitHub App, team and repository rules, and repository role groups",
+			secretsAndConfigMaps: []runtime.Object{goodClientCredentialsSecret, goodGitHubAppSecret},
+			githubIdentityProviders: []runtime.Object{
+				validIDPWithAppAndRules,
 			},
-			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			wantResultingCache: []*upstreamgithub.ProviderConfig{
+				{
+					Name:               "some-idp-name",
+					ResourceUID:        "some-resource-uid",
+					APIBaseURL:         fmt.Sprintf("https://%s/api/v3", *validFilledOutIDP.Spec.GitHubAPI.Host),
+					UsernameAttribute:  "id",
+					GroupNameAttribute: "name",
+					OAuth2Config: &oauth2.Config{
+						ClientID:     "some-client-id",
+						ClientSecret: "some-client-secret",
+						Endpoint: oauth2.Endpoint{
+							AuthURL:       fmt.Sprintf("https://%s/login/oauth/authorize", *validFilledOutIDP.Spec.GitHubAPI.Host),
+							DeviceAuthURL: "", // not used
+							TokenURL:      fmt.Sprintf("https://%s/login/oauth/access_token", *validFilledOutIDP.Spec.GitHubAPI.Host),
+							AuthStyle:     oauth2.AuthStyleInParams,
+						},
+						RedirectURL: "", // not used
+						Scopes:      []string{"read:user", "read:org"},
+					},
+					AllowedOrganizations: setutil.NewCaseInsensitiveSet("organization1", "org2"),
+					AllowedTeams:         setutil.NewCaseInsensitiveSet("organization1/team1", "ORG2/Team2"),
+					AllowedRepositories: []upstreamgithub.RepositoryAllowRule{
+						{Repository: "org2/repo1", MinimumRole: idpv1alpha1.GitHubRepositoryRoleMaintain},
+						{Repository: "organization1/repo2", MinimumRole: idpv1alpha1.GitHubRepositoryRoleRead},
+					},
+					RepositoryRoleGroups: []string{"org2/repo1", "organization1/repo3"},
+					HttpClient:           phttp.Default(goodServerCertPool),
+				},
+			},
+			wantValidatedCache: []GitHubValidatedAPICacheKey{
+				{
+					address:      goodServerDomain,
+					caBundleHash: tlsconfigutil.NewCABundleHash(goodServerCA),
+				},
+			},
+			wantAppClient: true,
 			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
 				{
-					ObjectMeta: validMinimalIDP.ObjectMeta,
-					Spec:       validMinimalIDP.Spec,
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec:       validIDPWithAppAndRules.Spec,
 					Status: idpv1alpha1.GitHubIdentityProviderStatus{
-						Phase: idpv1alpha1.GitHubPhaseError,
+						Phase: idpv1alpha1.GitHubPhaseReady,
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
-							buildClientCredentialsSecretValidFalse(
-								t,
-								fmt.Sprintf("secret %q not found", validMinimalIDP.Spec.Client.SecretName),
-								validMinimalIDP.Spec.Client.SecretName,
-								validMinimalIDP.Namespace,
-								upstreamwatchers.ReasonNotFound,
-							),
-							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
-							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
-							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidTrue(t, goodGitHubAppSecret.Name),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
 							buildTLSConfigurationValidTrue(t),
 						},
 					},
 				},
 			},
 			wantLogs: []string{
-				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `secret \"some-secret-name\" not found: secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
-				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
-				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
-				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
-				buildLogForUpdatingTLSConfigurationValid("minimal-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
-				buildLogForUpdatingGitHubConnectionValid("minimal-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
-				buildLogForUpdatingPhase("minimal-idp-name", "Error"),
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "True", "Success", `appID and privateKey have been read from spec.app.secretName (\"some-app-secret-name\")`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Ready"),
 			},
 		},
 		{
-			name: "Client Secret error - wrong type",
+			name: "GitHub App Secret error - not found",
 			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+			},
+			githubIdentityProviders: []runtime.Object{
 				func() runtime.Object {
-					badSecret := goodClientCredentialsSecret.DeepCopy()
-					badSecret.Type = "other-type"
-					return badSecret
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					return badIDP
 				}(),
 			},
-			githubIdentityProviders: []runtime.Object{validMinimalIDP},
 			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
 				{
-					ObjectMeta: validMinimalIDP.ObjectMeta,
-					Spec:       validMinimalIDP.Spec,
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						return badIDP.Spec
+					}(),
 					Status: idpv1alpha1.GitHubIdentityProviderStatus{
 						Phase: idpv1alpha1.GitHubPhaseError,
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
-							buildClientCredentialsSecretValidFalse(
-								t,
-								`wrong secret type "other-type"`,
-								validMinimalIDP.Spec.Client.SecretName,
-								validMinimalIDP.Namespace,
-								upstreamwatchers.ReasonNotFound,
-							),
-							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
-							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
-							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidFalse(t, `secret "some-app-secret-name" not found`, goodGitHubAppSecret.Name, namespace),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
 							buildTLSConfigurationValidTrue(t),
 						},
 					},
 				},
 			},
 			wantLogs: []string{
-				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `wrong secret type \"other-type\": secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
-				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
-				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
-				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validMinimalIDP.Spec.GitHubAPI.Host),
-				buildLogForUpdatingTLSConfigurationValid("minimal-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
-				buildLogForUpdatingGitHubConnectionValid("minimal-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
-				buildLogForUpdatingPhase("minimal-idp-name", "Error"),
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "False", "SecretNotFound", `secret \"some-app-secret-name\" not found: secret from spec.app.secretName (\"some-app-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-app\" and keys \"appID\" and \"privateKey\"`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
 			},
 		},
 		{
-			name: "Client Secret error - missing clientId",
+			name: "GitHub App Secret error - wrong type",
 			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
 				func() runtime.Object {
-					badSecret := goodClientCredentialsSecret.DeepCopy()
-					delete(badSecret.Data, "clientID")
+					badSecret := goodGitHubAppSecret.DeepCopy()
+					badSecret.Type = "secrets.pinniped.dev/github-client"
 					return badSecret
 				}(),
 			},
-			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					return badIDP
+				}(),
+			},
 			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
 				{
-					ObjectMeta: validMinimalIDP.ObjectMeta,
-					Spec:       validMinimalIDP.Spec,
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						return badIDP.Spec
+					}(),
 					Status: idpv1alpha1.GitHubIdentityProviderStatus{
 						Phase: idpv1alpha1.GitHubPhaseError,
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
-							buildClientCredentialsSecretValidFalse(
-								t,
-								`missing key "clientID"`,
-								validMinimalIDP.Spec.Client.SecretName,
-								validMinimalIDP.Namespace,
-								upstreamwatchers.ReasonNotFound,
-							),
-							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
-							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
-							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidFalse(t, `wrong secret type "secrets.pinniped.dev/github-client"`, goodGitHubAppSecret.Name, namespace),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
 							buildTLSConfigurationValidTrue(t),
 						},
 					},
 				},
 			},
 			wantLogs: []string{
-				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `missing key \"clientID\": secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
-				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
-				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
-				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
-				buildLogForUpdatingTLSConfigurationValid("minimal-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
-				buildLogForUpdatingGitHubConnectionValid("minimal-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
-				buildLogForUpdatingPhase("minimal-idp-name", "Error"),
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "False", "SecretNotFound", `wrong secret type \"secrets.pinniped.dev/github-client\": secret from spec.app.secretName (\"some-app-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-app\" and keys \"appID\" and \"privateKey\"`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
 			},
 		},
 		{
-			name: "Client Secret error - missing clientSecret",
+			name: "GitHub App Secret error - missing privateKey",
 			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
 				func() runtime.Object {
-					badSecret := goodClientCredentialsSecret.DeepCopy()
-					delete(badSecret.Data, "clientSecret")
+					badSecret := goodGitHubAppSecret.DeepCopy()
+					delete(badSecret.Data, "privateKey")
 					return badSecret
 				}(),
 			},
-			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					return badIDP
+				}(),
+			},
 			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
 				{
-					ObjectMeta: validMinimalIDP.ObjectMeta,
-					Spec:       validMinimalIDP.Spec,
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						return badIDP.Spec
+					}(),
 					Status: idpv1alpha1.GitHubIdentityProviderStatus{
 						Phase: idpv1alpha1.GitHubPhaseError,
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
-							buildClientCredentialsSecretValidFalse(
-								t,
-								`missing key "clientSecret"`,
-								validMinimalIDP.Spec.Client.SecretName,
-								validMinimalIDP.Namespace,
-								upstreamwatchers.ReasonNotFound,
-							),
-							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
-							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
-							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidFalse(t, `missing key "privateKey"`, goodGitHubAppSecret.Name, namespace),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
 							buildTLSConfigurationValidTrue(t),
 						},
 					},
 				},
 			},
 			wantLogs: []string{
-				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `missing key \"clientSecret\": secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
-				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
-				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
-				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validMinimalIDP.Spec.GitHubAPI.Host),
-				buildLogForUpdatingTLSConfigurationValid("minimal-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
-				buildLogForUpdatingGitHubConnectionValid("minimal-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
-				buildLogForUpdatingPhase("minimal-idp-name", "Error"),
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "False", "SecretNotFound", `missing key \"privateKey\": secret from spec.app.secretName (\"some-app-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-app\" and keys \"appID\" and \"privateKey\"`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
 			},
 		},
 		{
-			name: "Client Secret error - additional data",
+			name: "GitHub App Secret error - invalid appID",
 			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
 				func() runtime.Object {
-					badSecret := goodClientCredentialsSecret.DeepCopy()
-					badSecret.Data["foo"] = []byte("bar")
+					badSecret := goodGitHubAppSecret.DeepCopy()
+					badSecret.Data["appID"] = []byte("not-a-number")
 					return badSecret
 				}(),
 			},
-			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					return badIDP
+				}(),
+			},
 			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
 				{
-					ObjectMeta: validMinimalIDP.ObjectMeta,
-					Spec:       validMinimalIDP.Spec,
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						return badIDP.Spec
+					}(),
 					Status: idpv1alpha1.GitHubIdentityProviderStatus{
 						Phase: idpv1alpha1.GitHubPhaseError,
 						Conditions: []metav1.Condition{
 							buildClaimsValidatedTrue(t),
-							buildClientCredentialsSecretValidFalse(
-								t,
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidFalse(t, `invalid value for key "appID": must be a positive integer`, goodGitHubAppSecret.Name, namespace),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "False", "SecretNotFound", `invalid value for key \"appID\": must be a positive integer: secret from spec.app.secretName (\"some-app-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-app\" and keys \"appID\" and \"privateKey\"`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "GitHub App Secret error - invalid privateKey",
+			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+				func() runtime.Object {
+					badSecret := goodGitHubAppSecret.DeepCopy()
+					badSecret.Data["privateKey"] = []byte("not a PEM block")
+					return badSecret
+				}(),
+			},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					return badIDP
+				}(),
+			},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						return badIDP.Spec
+					}(),
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidFalse(t, `invalid value for key "privateKey": no PEM block found`, goodGitHubAppSecret.Name, namespace),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "False", "SecretNotFound", `invalid value for key \"privateKey\": no PEM block found: secret from spec.app.secretName (\"some-app-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-app\" and keys \"appID\" and \"privateKey\"`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "GitHub App Secret error - additional data",
+			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+				func() runtime.Object {
+					badSecret := goodGitHubAppSecret.DeepCopy()
+					badSecret.Data["foo"] = []byte("bar")
+					return badSecret
+				}(),
+			},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					return badIDP
+				}(),
+			},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						return badIDP.Spec
+					}(),
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidFalse(t, "extra keys found", goodGitHubAppSecret.Name, namespace),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "False", "SecretNotFound", `extra keys found: secret from spec.app.secretName (\"some-app-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-app\" and keys \"appID\" and \"privateKey\"`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Organization Policy error - team is not in the form org/team-slug",
+			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+				goodGitHubAppSecret,
+			},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					badIDP.Spec.AllowAuthentication.Teams = []string{"organization1/team1", "team-without-org"}
+					return badIDP
+				}(),
+			},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						badIDP.Spec.AllowAuthentication.Teams = []string{"organization1/team1", "team-without-org"}
+						return badIDP.Spec
+					}(),
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidTrue(t, goodGitHubAppSecret.Name),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidFalse(t, `spec.allowAuthentication.teams[1] ("team-without-org") must be in the form "org/team-slug"`),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "True", "Success", `appID and privateKey have been read from spec.app.secretName (\"some-app-secret-name\")`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "False", "Invalid", `spec.allowAuthentication.teams[1] (\"team-without-org\") must be in the form \"org/team-slug\"`),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Organization Policy error - team does not belong to an allowed organization",
+			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+				goodGitHubAppSecret,
+			},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					badIDP.Spec.AllowAuthentication.Teams = []string{"other-org/team1"}
+					return badIDP
+				}(),
+			},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						badIDP.Spec.AllowAuthentication.Teams = []string{"other-org/team1"}
+						return badIDP.Spec
+					}(),
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidTrue(t, goodGitHubAppSecret.Name),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidFalse(t, `spec.allowAuthentication.teams[0] ("other-org/team1") must belong to one of the organizations listed in spec.allowAuthentication.organizations.allowed`),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "True", "Success", `appID and privateKey have been read from spec.app.secretName (\"some-app-secret-name\")`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "False", "Invalid", `spec.allowAuthentication.teams[0] (\"other-org/team1\") must belong to one of the organizations listed in spec.allowAuthentication.organizations.allowed`),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Organization Policy error - repository is not in the form owner/repo",
+			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+				goodGitHubAppSecret,
+			},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					badIDP.Spec.AllowAuthentication.Repositories[0].Name = "org2/repo1/extra"
+					return badIDP
+				}(),
+			},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						badIDP.Spec.AllowAuthentication.Repositories[0].Name = "org2/repo1/extra"
+						return badIDP.Spec
+					}(),
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidTrue(t, goodGitHubAppSecret.Name),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidFalse(t, `spec.allowAuthentication.repositories[0].name ("org2/repo1/extra") must be in the form "owner/repo"`),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "True", "Success", `appID and privateKey have been read from spec.app.secretName (\"some-app-secret-name\")`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "False", "Invalid", `spec.allowAuthentication.repositories[0].name (\"org2/repo1/extra\") must be in the form \"owner/repo\"`),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Organization Policy error - invalid repository minimumRole",
+			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+				goodGitHubAppSecret,
+			},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					badIDP.Spec.AllowAuthentication.Repositories[1].MinimumRole = ptr.To(idpv1alpha1.GitHubRepositoryRole("owner"))
+					return badIDP
+				}(),
+			},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						badIDP.Spec.AllowAuthentication.Repositories[1].MinimumRole = ptr.To(idpv1alpha1.GitHubRepositoryRole("owner"))
+						return badIDP.Spec
+					}(),
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidTrue(t, goodGitHubAppSecret.Name),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidFalse(t, `spec.allowAuthentication.repositories[1].minimumRole ("owner") is not valid`),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "True", "Success", `appID and privateKey have been read from spec.app.secretName (\"some-app-secret-name\")`),
+				buildLogForUpdatingClaimsValidTrue("some-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "False", "Invalid", `spec.allowAuthentication.repositories[1].minimumRole (\"owner\") is not valid`),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Invalid Claims - spec.claims.repositoryRoleGroups repository does not belong to an allowed organization",
+			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+				goodGitHubAppSecret,
+			},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					badIDP.Spec.Claims.RepositoryRoleGroups.Repositories[1] = "other-org/repo3"
+					return badIDP
+				}(),
+			},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						badIDP.Spec.Claims.RepositoryRoleGroups.Repositories[1] = "other-org/repo3"
+						return badIDP.Spec
+					}(),
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedFalse(t, `spec.claims.repositoryRoleGroups.repositories[1] ("other-org/repo3") must belong to one of the organizations listed in spec.allowAuthentication.organizations.allowed`),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidTrue(t, goodGitHubAppSecret.Name),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "True", "Success", `appID and privateKey have been read from spec.app.secretName (\"some-app-secret-name\")`),
+				buildLogForUpdatingClaimsValidFalse("some-idp-name", `spec.claims.repositoryRoleGroups.repositories[1] (\"other-org/repo3\") must belong to one of the organizations listed in spec.allowAuthentication.organizations.allowed`),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Invalid Claims - spec.claims.repositoryRoleGroups repository is not in the form owner/repo",
+			secretsAndConfigMaps: []runtime.Object{
+				goodClientCredentialsSecret,
+				goodGitHubAppSecret,
+			},
+			githubIdentityProviders: []runtime.Object{
+				func() runtime.Object {
+					badIDP := validIDPWithAppAndRules.DeepCopy()
+					badIDP.Spec.Claims.RepositoryRoleGroups.Repositories[0] = "repo-without-owner"
+					return badIDP
+				}(),
+			},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validIDPWithAppAndRules.ObjectMeta,
+					Spec: func() idpv1alpha1.GitHubIdentityProviderSpec {
+						badIDP := validIDPWithAppAndRules.DeepCopy()
+						badIDP.Spec.Claims.RepositoryRoleGroups.Repositories[0] = "repo-without-owner"
+						return badIDP.Spec
+					}(),
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedFalse(t, `spec.claims.repositoryRoleGroups.repositories[0] ("repo-without-owner") must be in the form "owner/repo"`),
+							buildClientCredentialsSecretValidTrue(t, validFilledOutIDP.Spec.Client.SecretName),
+							buildGitHubAppSecretValidTrue(t, goodGitHubAppSecret.Name),
+							buildGitHubConnectionValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validFilledOutIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("some-idp-name", "True", "Success", fmt.Sprintf(`clientID and clientSecret have been read from spec.client.SecretName (\"%s\")`, validFilledOutIDP.Spec.Client.SecretName)),
+				buildLogForUpdatingGitHubAppSecretValid("some-idp-name", "True", "Success", `appID and privateKey have been read from spec.app.secretName (\"some-app-secret-name\")`),
+				buildLogForUpdatingClaimsValidFalse("some-idp-name", `spec.claims.repositoryRoleGroups.repositories[0] (\"repo-without-owner\") must be in the form \"owner/repo\"`),
+				buildLogForUpdatingOrganizationPolicyValid("some-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validFilledOutIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("some-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("some-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("some-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("some-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Client Secret error - in different namespace",
+			secretsAndConfigMaps: []runtime.Object{
+				func() runtime.Object {
+					badSecret := goodClientCredentialsSecret.DeepCopy()
+					badSecret.Namespace = "other-namespace"
+					return badSecret
+				}(),
+			},
+			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validMinimalIDP.ObjectMeta,
+					Spec:       validMinimalIDP.Spec,
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidFalse(
+								t,
+								fmt.Sprintf("secret %q not found", validMinimalIDP.Spec.Client.SecretName),
+								validMinimalIDP.Spec.Client.SecretName,
+								validMinimalIDP.Namespace,
+								upstreamwatchers.ReasonNotFound,
+							),
+							buildGitHubAppSecretValidNotConfigured(t),
+							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `secret \"some-secret-name\" not found: secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
+				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("minimal-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("minimal-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("minimal-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Client Secret error - wrong type",
+			secretsAndConfigMaps: []runtime.Object{
+				func() runtime.Object {
+					badSecret := goodClientCredentialsSecret.DeepCopy()
+					badSecret.Type = "other-type"
+					return badSecret
+				}(),
+			},
+			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validMinimalIDP.ObjectMeta,
+					Spec:       validMinimalIDP.Spec,
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidFalse(
+								t,
+								`wrong secret type "other-type"`,
+								validMinimalIDP.Spec.Client.SecretName,
+								validMinimalIDP.Namespace,
+								upstreamwatchers.ReasonNotFound,
+							),
+							buildGitHubAppSecretValidNotConfigured(t),
+							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `wrong secret type \"other-type\": secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
+				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validMinimalIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("minimal-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("minimal-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("minimal-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Client Secret error - missing clientId",
+			secretsAndConfigMaps: []runtime.Object{
+				func() runtime.Object {
+					badSecret := goodClientCredentialsSecret.DeepCopy()
+					delete(badSecret.Data, "clientID")
+					return badSecret
+				}(),
+			},
+			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validMinimalIDP.ObjectMeta,
+					Spec:       validMinimalIDP.Spec,
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidFalse(
+								t,
+								`missing key "clientID"`,
+								validMinimalIDP.Spec.Client.SecretName,
+								validMinimalIDP.Namespace,
+								upstreamwatchers.ReasonNotFound,
+							),
+							buildGitHubAppSecretValidNotConfigured(t),
+							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `missing key \"clientID\": secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
+				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("minimal-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("minimal-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validFilledOutIDP.Spec.GitHubAPI.Host, *validFilledOutIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("minimal-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Client Secret error - missing clientSecret",
+			secretsAndConfigMaps: []runtime.Object{
+				func() runtime.Object {
+					badSecret := goodClientCredentialsSecret.DeepCopy()
+					delete(badSecret.Data, "clientSecret")
+					return badSecret
+				}(),
+			},
+			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validMinimalIDP.ObjectMeta,
+					Spec:       validMinimalIDP.Spec,
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidFalse(
+								t,
+								`missing key "clientSecret"`,
+								validMinimalIDP.Spec.Client.SecretName,
+								validMinimalIDP.Namespace,
+								upstreamwatchers.ReasonNotFound,
+							),
+							buildGitHubAppSecretValidNotConfigured(t),
+							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
+							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
+							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
+							buildTLSConfigurationValidTrue(t),
+						},
+					},
+				},
+			},
+			wantLogs: []string{
+				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `missing key \"clientSecret\": secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
+				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
+				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
+				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validMinimalIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingTLSConfigurationValid("minimal-idp-name", "True", "Success", "spec.githubAPI.tls is valid: using configured CA bundle"),
+				buildLogForUpdatingGitHubConnectionValid("minimal-idp-name", "True", "Success", `probed connection to \"%s\" for spec.githubAPI.host (\"%s\"): host is reachable and TLS verification succeeds`, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
+				buildLogForUpdatingPhase("minimal-idp-name", "Error"),
+			},
+		},
+		{
+			name: "Client Secret error - additional data",
+			secretsAndConfigMaps: []runtime.Object{
+				func() runtime.Object {
+					badSecret := goodClientCredentialsSecret.DeepCopy()
+					badSecret.Data["foo"] = []byte("bar")
+					return badSecret
+				}(),
+			},
+			githubIdentityProviders: []runtime.Object{validMinimalIDP},
+			wantResultingUpstreams: []idpv1alpha1.GitHubIdentityProvider{
+				{
+					ObjectMeta: validMinimalIDP.ObjectMeta,
+					Spec:       validMinimalIDP.Spec,
+					Status: idpv1alpha1.GitHubIdentityProviderStatus{
+						Phase: idpv1alpha1.GitHubPhaseError,
+						Conditions: []metav1.Condition{
+							buildClaimsValidatedTrue(t),
+							buildClientCredentialsSecretValidFalse(
+								t,
 								"extra keys found",
 								validMinimalIDP.Spec.Client.SecretName,
 								validMinimalIDP.Namespace,
 								upstreamwatchers.ReasonNotFound,
 							),
+							buildGitHubAppSecretValidNotConfigured(t),
 							buildGitHubConnectionValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host, *validMinimalIDP.Spec.GitHubAPI.Host),
 							buildHostValidTrue(t, *validMinimalIDP.Spec.GitHubAPI.Host),
 							buildOrganizationsPolicyValidTrue(t, *validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy),
@@ -2423,6 +3205,7 @@ func TestController(t *testing.T) {
 			},
 			wantLogs: []string{
 				buildLogForUpdatingClientCredentialsSecretValid("minimal-idp-name", "False", "SecretNotFound", `extra keys found: secret from spec.client.SecretName (\"some-secret-name\") must be found in namespace \"some-namespace\" with type \"secrets.pinniped.dev/github-client\" and keys \"clientID\" and \"clientSecret\"`),
+				buildLogForUpdatingGitHubAppSecretValidNotConfigured("minimal-idp-name"),
 				buildLogForUpdatingClaimsValidTrue("minimal-idp-name"),
 				buildLogForUpdatingOrganizationPolicyValid("minimal-idp-name", "True", "Success", fmt.Sprintf(`spec.allowAuthentication.organizations.policy (\"%s\") is valid`, string(*validMinimalIDP.Spec.AllowAuthentication.Organizations.Policy))),
 				buildLogForUpdatingHostValid("minimal-idp-name", "True", "Success", `spec.githubAPI.host (\"%s\") is valid`, *validFilledOutIDP.Spec.GitHubAPI.Host),
@@ -2516,6 +3299,15 @@ func TestController(t *testing.T) {
 				require.Equal(t, tt.wantResultingCache[i].UsernameAttribute, actualProvider.GetUsernameAttribute())
 				require.Equal(t, tt.wantResultingCache[i].AllowedOrganizations, actualProvider.GetAllowedOrganizations())
 
+				wantAllowedTeams := tt.wantResultingCache[i].AllowedTeams
+				if wantAllowedTeams == nil {
+					wantAllowedTeams = setutil.NewCaseInsensitiveSet()
+				}
+				require.Equal(t, wantAllowedTeams, actualProvider.GetConfig().AllowedTeams)
+				require.Equal(t, tt.wantResultingCache[i].AllowedRepositories, actualProvider.GetConfig().AllowedRepositories)
+				require.Equal(t, tt.wantResultingCache[i].RepositoryRoleGroups, actualProvider.GetConfig().RepositoryRoleGroups)
+				require.Equal(t, tt.wantAppClient, actualProvider.GetConfig().AppClient != nil)
+
 				compareTLSClientConfigWithinHttpClients(t, tt.wantResultingCache[i].HttpClient, actualProvider.GetConfig().HttpClient)
 				require.Equal(t, tt.wantResultingCache[i].OAuth2Config, actualProvider.GetConfig().OAuth2Config)
 				require.Contains(t, tt.wantResultingCache[i].APIBaseURL, actualProvider.GetConfig().APIBaseURL)
@@ -2572,7 +3364,7 @@ func TestController(t *testing.T) {
 }
 
 func TestController_OnlyWantActions(t *testing.T) {
-	require.Equal(t, 6, countExpectedConditions)
+	require.Equal(t, 7, countExpectedConditions)
 
 	goodServer, goodServerCA := tlsserver.TestServerIPv4(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	}), tlsserver.RecordTLSHello)
@@ -2674,6 +3466,14 @@ func TestController_OnlyWantActions(t *testing.T) {
 					Reason:             "SecretNotFound",
 					Message:            fmt.Sprintf(`secret "unknown-secret" not found: secret from spec.client.SecretName ("unknown-secret") must be found in namespace %q with type "secrets.pinniped.dev/github-client" and keys "clientID" and "clientSecret"`, namespace),
 				},
+				{
+					Type:               GitHubAppSecretValid,
+					Status:             metav1.ConditionTrue,
+					ObservedGeneration: 333,
+					LastTransitionTime: oneHourAgo,
+					Reason:             conditionsutil.ReasonSuccess,
+					Message:            "spec.app is not configured: using the user's access token to look up repository roles",
+				},
 				{
 					Type:               GitHubConnectionValid,
 					Status:             metav1.ConditionTrue,
@@ -2786,6 +3586,14 @@ func TestController_OnlyWantActions(t *testing.T) {
 								Reason:             conditionsutil.ReasonSuccess,
 								Message:            `clientID and clientSecret have been read from spec.client.SecretName ("some-secret-name")`,
 							},
+							{
+								Type:               GitHubAppSecretValid,
+								Status:             metav1.ConditionTrue,
+								ObservedGeneration: 1234,
+								LastTransitionTime: wantLastTransitionTime,
+								Reason:             conditionsutil.ReasonSuccess,
+								Message:            "spec.app is not configured: using the user's access token to look up repository roles",
+							},
 							{
 								Type:               GitHubConnectionValid,
 								Status:             metav1.ConditionTrue,
@@ -2916,6 +3724,17 @@ func TestGitHubUpstreamWatcherControllerFilterSecret(t *testing.T) {
 			wantUpdate: true,
 			wantDelete: true,
 		},
+		{
+			name: "should return true for a secret of the type secrets.pinniped.dev/github-app",
+			secret: func() *corev1.Secret {
+				otherSecret := goodSecret.DeepCopy()
+				otherSecret.Type = "secrets.pinniped.dev/github-app"
+				return otherSecret
+			}(),
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
 		{
 			name: "should return true for a secret of the type Opaque",
 			secret: func() *corev1.Secret {
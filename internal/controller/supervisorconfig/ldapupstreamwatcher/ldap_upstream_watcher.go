@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package ldapupstreamwatcher implements a controller which watches LDAPIdentityProviders.
@@ -7,12 +7,17 @@ package ldapupstreamwatcher
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/utils/ptr"
 
 	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
 	supervisorclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
@@ -28,6 +33,14 @@ import (
 
 const (
 	ldapControllerName = "ldap-upstream-observer"
+
+	// The maximum amount of time to spend dialing the hosts while checking their health.
+	checkHostHealthTimeout = 90 * time.Second
+
+	// Constants related to conditions.
+	typeLDAPHostsHealthy     = "LDAPHostsHealthy"
+	reasonSomeHostsUnhealthy = "SomeHostsUnhealthy"
+	reasonAllHostsUnhealthy  = "AllHostsUnhealthy"
 )
 
 type ldapUpstreamGenericLDAPImpl struct {
@@ -132,6 +145,7 @@ type UpstreamLDAPIdentityProviderICache interface {
 type ldapWatcherController struct {
 	cache                        UpstreamLDAPIdentityProviderICache
 	validatedSettingsCache       upstreamwatchers.ValidatedSettingsCacheI
+	connectionPools              *upstreamldap.ConnectionPools
 	ldapDialer                   upstreamldap.LDAPDialer
 	client                       supervisorclientset.Interface
 	ldapIdentityProviderInformer idpinformers.LDAPIdentityProviderInformer
@@ -176,6 +190,7 @@ func newInternal(
 	c := ldapWatcherController{
 		cache:                        idpCache,
 		validatedSettingsCache:       validatedSettingsCache,
+		connectionPools:              upstreamldap.NewConnectionPools(),
 		ldapDialer:                   ldapDialer,
 		client:                       client,
 		ldapIdentityProviderInformer: ldapIdentityProviderInformer,
@@ -216,7 +231,9 @@ func (c *ldapWatcherController) Sync(ctx controllerlib.Context) error {
 
 	requeue := false
 	validatedUpstreams := make([]upstreamprovider.UpstreamLDAPIdentityProviderI, 0, len(actualUpstreams))
+	actualUpstreamUIDs := sets.New[types.UID]()
 	for _, upstream := range actualUpstreams {
+		actualUpstreamUIDs.Insert(upstream.UID)
 		validProvider, requestedRequeue := c.validateUpstream(ctx.Context, upstream)
 		if validProvider != nil {
 			validatedUpstreams = append(validatedUpstreams, validProvider)
@@ -228,6 +245,9 @@ func (c *ldapWatcherController) Sync(ctx controllerlib.Context) error {
 
 	c.cache.SetLDAPIdentityProviders(validatedUpstreams)
 
+	// Close the idle connections of upstreams which were deleted.
+	c.connectionPools.Retain(actualUpstreamUIDs)
+
 	if requeue {
 		return controllerlib.ErrSyntheticRequeue
 	}
@@ -238,32 +258,103 @@ func (c *ldapWatcherController) validateUpstream(ctx context.Context, upstream *
 	spec := upstream.Spec
 
 	config := &upstreamldap.ProviderConfig{
-		Name:        upstream.Name,
-		ResourceUID: upstream.UID,
-		Host:        spec.Host,
+		Name:            upstream.Name,
+		ResourceUID:     upstream.UID,
+		Host:            spec.Host,
+		AdditionalHosts: spec.Failover.AdditionalHosts,
+		SRVRecord:       spec.Failover.SRVRecord,
 		UserSearch: upstreamldap.UserSearchConfig{
-			Base:              spec.UserSearch.Base,
-			Filter:            spec.UserSearch.Filter,
-			UsernameAttribute: spec.UserSearch.Attributes.Username,
-			UIDAttribute:      spec.UserSearch.Attributes.UID,
+			Base:                    spec.UserSearch.Base,
+			Filter:                  spec.UserSearch.Filter,
+			UsernameAttribute:       spec.UserSearch.Attributes.Username,
+			UIDAttribute:            spec.UserSearch.Attributes.UID,
+			AdditionalClaimMappings: spec.UserSearch.Attributes.AdditionalClaimMappings,
 		},
 		GroupSearch: upstreamldap.GroupSearchConfig{
 			Base:                   spec.GroupSearch.Base,
 			Filter:                 spec.GroupSearch.Filter,
 			UserAttributeForFilter: spec.GroupSearch.UserAttributeForFilter,
 			GroupNameAttribute:     spec.GroupSearch.Attributes.GroupName,
+			Strategy:               upstreamldap.GroupSearchStrategy(spec.GroupSearch.Strategy.Type),
+			MemberOfAttribute:      spec.GroupSearch.Strategy.MemberOfAttribute,
+			MaxDepth:               int(ptr.Deref(spec.GroupSearch.Strategy.MaxDepth, 0)),
 			SkipGroupRefresh:       spec.GroupSearch.SkipGroupRefresh,
 		},
-		Dialer: c.ldapDialer,
+		Dialer:         c.ldapDialer,
+		ConnectionPool: c.connectionPools.Get(upstream.UID, int(spec.ConnectionPool.MaxIdleConnections)),
 	}
 
 	conditions := upstreamwatchers.ValidateGenericLDAP(ctx, &ldapUpstreamGenericLDAPImpl{*upstream}, c.secretInformer, c.configMapInformer, c.validatedSettingsCache, config)
+	conditions.Append(c.validateHosts(ctx, conditions, config), false)
 
 	c.updateStatus(ctx, upstream, conditions.Conditions())
 
 	return upstreamwatchers.EvaluateConditions(conditions, config)
 }
 
+// validateHosts checks the health of all hosts of the upstream, and returns the LDAPHostsHealthy condition.
+// The condition also describes the configuration of the connection pool.
+func (c *ldapWatcherController) validateHosts(ctx context.Context, conditions upstreamwatchers.GradatedConditions, config *upstreamldap.ProviderConfig) *metav1.Condition {
+	// No point in trying to connect to the hosts if the config was already determined to be invalid.
+	if conditions.HasFatalFailure() {
+		return &metav1.Condition{
+			Type:    typeLDAPHostsHealthy,
+			Status:  metav1.ConditionUnknown,
+			Reason:  conditionsutil.ReasonUnableToValidate,
+			Message: conditionsutil.MessageUnableToValidate,
+		}
+	}
+
+	checkHostHealthCtx, cancelFunc := context.WithTimeout(ctx, checkHostHealthTimeout)
+	defer cancelFunc()
+	hostStatuses := upstreamldap.New(*config).CheckHostHealth(checkHostHealthCtx)
+
+	var healthyHosts, unhealthyHostMessages []string
+	for _, hostStatus := range hostStatuses {
+		if hostStatus.Error == nil {
+			healthyHosts = append(healthyHosts, hostStatus.Host)
+		} else {
+			unhealthyHostMessages = append(unhealthyHostMessages, fmt.Sprintf("could not connect to host %q: %s", hostStatus.Host, hostStatus.Error.Error()))
+		}
+	}
+
+	// Only describe the configuration of the connection pool, since the number of idle connections changes with
+	// login traffic, and a changing message would cause a status update on every sync.
+	connectionPoolMessage := "connection pooling is disabled"
+	if maxIdle := config.ConnectionPool.MaxIdleConnections(); maxIdle > 0 {
+		connectionPoolMessage = fmt.Sprintf("connection pool keeps at most %d idle connections", maxIdle)
+		plog.Debug("LDAP connection pool",
+			"upstreamName", config.Name,
+			"idleConnections", config.ConnectionPool.IdleConnections(),
+			"maxIdleConnections", maxIdle)
+	}
+
+	switch {
+	case len(unhealthyHostMessages) == 0:
+		return &metav1.Condition{
+			Type:    typeLDAPHostsHealthy,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditionsutil.ReasonSuccess,
+			Message: fmt.Sprintf("all hosts are healthy: %q; %s", healthyHosts, connectionPoolMessage),
+		}
+	case len(healthyHosts) == 0:
+		return &metav1.Condition{
+			Type:    typeLDAPHostsHealthy,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonAllHostsUnhealthy,
+			Message: fmt.Sprintf("%s; %s", strings.Join(unhealthyHostMessages, "; "), connectionPoolMessage),
+		}
+	default:
+		return &metav1.Condition{
+			Type:   typeLDAPHostsHealthy,
+			Status: metav1.ConditionFalse,
+			Reason: reasonSomeHostsUnhealthy,
+			Message: fmt.Sprintf("%s; healthy hosts: %q; %s",
+				strings.Join(unhealthyHostMessages, "; "), healthyHosts, connectionPoolMessage),
+		}
+	}
+}
+
 func (c *ldapWatcherController) updateStatus(ctx context.Context, upstream *idpv1alpha1.LDAPIdentityProvider, conditions []*metav1.Condition) {
 	log := plog.WithValues("namespace", upstream.Namespace, "name", upstream.Name)
 	updated := upstream.DeepCopy()
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package ldapupstreamwatcher
@@ -20,6 +20,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
 
 	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
 	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
@@ -392,6 +393,27 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 		}
 	}
 
+	ldapHostsHealthyTrueCondition := func(gen int64) metav1.Condition {
+		return metav1.Condition{
+			Type:               "LDAPHostsHealthy",
+			Status:             "True",
+			LastTransitionTime: now,
+			Reason:             "Success",
+			Message:            fmt.Sprintf(`all hosts are healthy: ["%s"]; connection pooling is disabled`, testHost),
+			ObservedGeneration: gen,
+		}
+	}
+	ldapHostsHealthyUnknownCondition := func(gen int64) metav1.Condition {
+		return metav1.Condition{
+			Type:               "LDAPHostsHealthy",
+			Status:             "Unknown",
+			LastTransitionTime: now,
+			Reason:             "UnableToValidate",
+			Message:            "unable to validate; see other conditions for details",
+			ObservedGeneration: gen,
+		}
+	}
+
 	condPtr := func(c metav1.Condition) *metav1.Condition {
 		return &c
 	}
@@ -411,6 +433,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 		return []metav1.Condition{
 			bindSecretValidTrueCondition(gen),
 			ldapConnectionValidTrueCondition(gen, secretVersion),
+			ldapHostsHealthyTrueCondition(gen),
 			tlsConfigurationValidLoadedTrueCondition(gen, "using configured CA bundle"),
 		}
 	}
@@ -603,6 +626,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 							ObservedGeneration: 1234,
 						},
 						ldapConnectionValidUnknownCondition(1234),
+						ldapHostsHealthyUnknownCondition(1234),
 						tlsConfigurationValidLoadedTrueCondition(1234, "using configured CA bundle"),
 					},
 				},
@@ -632,6 +656,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 							ObservedGeneration: 1234,
 						},
 						ldapConnectionValidUnknownCondition(1234),
+						ldapHostsHealthyUnknownCondition(1234),
 						tlsConfigurationValidLoadedTrueCondition(1234, "using configured CA bundle"),
 					},
 				},
@@ -660,6 +685,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 							ObservedGeneration: 1234,
 						},
 						ldapConnectionValidUnknownCondition(1234),
+						ldapHostsHealthyUnknownCondition(1234),
 						tlsConfigurationValidLoadedTrueCondition(1234, "using configured CA bundle"),
 					},
 				},
@@ -680,6 +706,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						bindSecretValidTrueCondition(1234),
 						ldapConnectionValidUnknownCondition(1234),
+						ldapHostsHealthyUnknownCondition(1234),
 						{
 							Type:               "TLSConfigurationValid",
 							Status:             "False",
@@ -707,6 +734,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						bindSecretValidTrueCondition(1234),
 						ldapConnectionValidUnknownCondition(1234),
+						ldapHostsHealthyUnknownCondition(1234),
 						{
 							Type:               "TLSConfigurationValid",
 							Status:             "False",
@@ -760,6 +788,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						bindSecretValidTrueCondition(1234),
 						ldapConnectionValidTrueCondition(1234, "4242"),
+						ldapHostsHealthyTrueCondition(1234),
 						{
 							Type:               "TLSConfigurationValid",
 							Status:             "True",
@@ -835,6 +864,14 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 								"ldap.example.com", testBindUsername, testBindSecretName, "4242"),
 							ObservedGeneration: 1234,
 						},
+						{
+							Type:               "LDAPHostsHealthy",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            `all hosts are healthy: ["ldap.example.com"]; connection pooling is disabled`,
+							ObservedGeneration: 1234,
+						},
 						tlsConfigurationValidLoadedTrueCondition(1234, "using configured CA bundle"),
 					},
 				},
@@ -909,6 +946,14 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 								"ldap.example.com:5678", testBindUsername, "ldap.example.com:5678"),
 							ObservedGeneration: 1234,
 						},
+						{
+							Type:               "LDAPHostsHealthy",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "AllHostsUnhealthy",
+							Message:            `could not connect to host "ldap.example.com:5678": some dial error; connection pooling is disabled`,
+							ObservedGeneration: 1234,
+						},
 						tlsConfigurationValidLoadedTrueCondition(1234, "using configured CA bundle"),
 					},
 				},
@@ -956,6 +1001,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						bindSecretValidTrueCondition(1234),
 						ldapConnectionValidTrueCondition(1234, "4242"),
+						ldapHostsHealthyTrueCondition(1234),
 						tlsConfigurationValidLoadedTrueCondition(1234, "no TLS configuration provided: using default root CA bundle from container image"),
 					},
 				},
@@ -1001,6 +1047,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 								ObservedGeneration: 42,
 							},
 							ldapConnectionValidUnknownCondition(42),
+							ldapHostsHealthyUnknownCondition(42),
 							tlsConfigurationValidLoadedTrueCondition(42, "using configured CA bundle"),
 						},
 					},
@@ -1051,6 +1098,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 								testHost, testBindUsername, testBindUsername),
 							ObservedGeneration: 1234,
 						},
+						ldapHostsHealthyTrueCondition(1234),
 						tlsConfigurationValidLoadedTrueCondition(1234, "using configured CA bundle"),
 					},
 				},
@@ -1063,6 +1111,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				upstream.Generation = 1234
 				upstream.Status.Conditions = []metav1.Condition{
 					ldapConnectionValidTrueCondition(1234, "4242"),
+					ldapHostsHealthyTrueCondition(1234),
 				}
 			})},
 			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
@@ -1076,7 +1125,8 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 					ConnectionValidCondition: condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
 				}},
 			setupMocks: func(conn *mockldapconn.MockConn) {
-				// Should not perform a test dial and bind. No mocking here means the test will fail if Bind() or Close() are called.
+				// Should not perform a test bind. The health of the host is not known yet, so it should be dialed once to check it.
+				conn.EXPECT().Close().Times(1)
 			},
 			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
 			wantResultingUpstreams: []idpv1alpha1.LDAPIdentityProvider{{
@@ -1102,6 +1152,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				upstream.Generation = 1234
 				upstream.Status.Conditions = []metav1.Condition{
 					ldapConnectionValidTrueCondition(1234, "4242"),
+					ldapHostsHealthyTrueCondition(1234),
 				}
 			})},
 			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
@@ -1115,7 +1166,8 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
 			}},
 			setupMocks: func(conn *mockldapconn.MockConn) {
-				// Should not perform a test dial and bind. No mocking here means the test will fail if Bind() or Close() are called.
+				// Should not perform a test bind. The health of the host is not known yet, so it should be dialed once to check it.
+				conn.EXPECT().Close().Times(1)
 			},
 			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithStartTLS},
 			wantResultingUpstreams: []idpv1alpha1.LDAPIdentityProvider{{
@@ -1195,7 +1247,8 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 			}},
 			setupMocks: func(conn *mockldapconn.MockConn) {
 				// The connection had already been validated previously and the result was cached, so don't probe the server again.
-				// Should not perform a test dial and bind. No mocking here means the test will fail if Bind() or Close() are called.
+				// Should not perform a test bind. The health of the host is not known yet, so it should be dialed once to check it.
+				conn.EXPECT().Close().Times(1)
 			},
 			wantResultingCache: []*upstreamldap.ProviderConfig{providerConfigForValidUpstreamWithTLS},
 			wantResultingUpstreams: []idpv1alpha1.LDAPIdentityProvider{{
@@ -1378,6 +1431,7 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						bindSecretValidTrueCondition(1234),
 						ldapConnectionValidTrueCondition(1234, "4242"),
+						ldapHostsHealthyTrueCondition(1234),
 						{
 							Type:               "TLSConfigurationValid",
 							Status:             "True",
@@ -1399,6 +1453,220 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
 			}},
 		},
+		{
+			name: "additional claim mappings are passed through to the provider",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *idpv1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.UserSearch.Attributes.AdditionalClaimMappings = map[string]string{
+					"email":       "mail",
+					"displayName": "displayName",
+				}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUserSearchUsernameAttrName,
+						UIDAttribute:      testUserSearchUIDAttrName,
+						AdditionalClaimMappings: map[string]string{
+							"email":       "mail",
+							"displayName": "displayName",
+						},
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:                   testGroupSearchBase,
+						Filter:                 testGroupSearchFilter,
+						UserAttributeForFilter: testGroupSearchUserAttributeForFilter,
+						GroupNameAttribute:     testGroupSearchNameAttrName,
+					},
+				},
+			},
+			wantResultingUpstreams: []idpv1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: idpv1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						bindSecretValidTrueCondition(1234),
+						ldapConnectionValidTrueCondition(1234, "4242"),
+						ldapHostsHealthyTrueCondition(1234),
+						{
+							Type:               "TLSConfigurationValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "spec.tls is valid: using configured CA bundle",
+							ObservedGeneration: 1234,
+						},
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				CABundleHash:              tlsconfigutil.NewCABundleHash(testCABundle),
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
+		{
+			name: "group search strategy is passed through to the provider",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *idpv1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.GroupSearch.Strategy = idpv1alpha1.LDAPIdentityProviderGroupSearchStrategy{
+					Type:              idpv1alpha1.LDAPGroupSearchStrategyMemberOfAttribute,
+					MemberOfAttribute: "isMemberOf",
+					MaxDepth:          ptr.To[int32](3),
+				}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUserSearchUsernameAttrName,
+						UIDAttribute:      testUserSearchUIDAttrName,
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:                   testGroupSearchBase,
+						Filter:                 testGroupSearchFilter,
+						UserAttributeForFilter: testGroupSearchUserAttributeForFilter,
+						GroupNameAttribute:     testGroupSearchNameAttrName,
+						Strategy:               upstreamldap.GroupSearchStrategyMemberOfAttribute,
+						MemberOfAttribute:      "isMemberOf",
+						MaxDepth:               3,
+					},
+				},
+			},
+			wantResultingUpstreams: []idpv1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: idpv1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						bindSecretValidTrueCondition(1234),
+						ldapConnectionValidTrueCondition(1234, "4242"),
+						ldapHostsHealthyTrueCondition(1234),
+						{
+							Type:               "TLSConfigurationValid",
+							Status:             "True",
+							LastTransitionTime: now,
+							Reason:             "Success",
+							Message:            "spec.tls is valid: using configured CA bundle",
+							ObservedGeneration: 1234,
+						},
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				CABundleHash:              tlsconfigutil.NewCABundleHash(testCABundle),
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
+		{
+			name: "failover hosts are checked and pooled connections are kept when some hosts are unhealthy",
+			inputUpstreams: []runtime.Object{editedValidUpstream(func(upstream *idpv1alpha1.LDAPIdentityProvider) {
+				upstream.Spec.Failover = idpv1alpha1.LDAPIdentityProviderFailover{
+					AdditionalHosts: []string{"ldap2.example.com:123"},
+					SRVRecord:       "",
+				}
+				upstream.Spec.ConnectionPool = idpv1alpha1.LDAPIdentityProviderConnectionPool{MaxIdleConnections: 3}
+			})},
+			inputSecrets: []runtime.Object{validBindUserSecret("4242")},
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				// Should perform a test dial and bind. The connection is kept in the pool instead of being closed.
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+			},
+			dialErrors: map[string]error{
+				"ldap2.example.com:123": fmt.Errorf("some dial error"),
+			},
+			wantErr: controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamldap.ProviderConfig{
+				// even though a host is unhealthy, still loads into the cache because it is treated like a warning
+				{
+					Name:               testName,
+					ResourceUID:        testResourceUID,
+					Host:               testHost,
+					AdditionalHosts:    []string{"ldap2.example.com:123"},
+					ConnectionProtocol: upstreamldap.TLS,
+					CABundle:           testCABundle,
+					BindUsername:       testBindUsername,
+					BindPassword:       testBindPassword,
+					UserSearch: upstreamldap.UserSearchConfig{
+						Base:              testUserSearchBase,
+						Filter:            testUserSearchFilter,
+						UsernameAttribute: testUserSearchUsernameAttrName,
+						UIDAttribute:      testUserSearchUIDAttrName,
+					},
+					GroupSearch: upstreamldap.GroupSearchConfig{
+						Base:                   testGroupSearchBase,
+						Filter:                 testGroupSearchFilter,
+						UserAttributeForFilter: testGroupSearchUserAttributeForFilter,
+						GroupNameAttribute:     testGroupSearchNameAttrName,
+					},
+				},
+			},
+			wantResultingUpstreams: []idpv1alpha1.LDAPIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testResourceUID},
+				Status: idpv1alpha1.LDAPIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						bindSecretValidTrueCondition(1234),
+						ldapConnectionValidTrueCondition(1234, "4242"),
+						{
+							Type:               "LDAPHostsHealthy",
+							Status:             "False",
+							LastTransitionTime: now,
+							Reason:             "SomeHostsUnhealthy",
+							Message: fmt.Sprintf(
+								`could not connect to host "ldap2.example.com:123": some dial error; healthy hosts: ["%s"]; connection pool keeps at most 3 idle connections`,
+								testHost),
+							ObservedGeneration: 1234,
+						},
+						tlsConfigurationValidLoadedTrueCondition(1234, "using configured CA bundle"),
+					},
+				},
+			}},
+			wantValidatedSettings: map[string]upstreamwatchers.ValidatedSettings{testName: {
+				BindSecretResourceVersion: "4242",
+				LDAPConnectionProtocol:    upstreamldap.TLS,
+				UserSearchBase:            testUserSearchBase,
+				GroupSearchBase:           testGroupSearchBase,
+				CABundleHash:              tlsconfigutil.NewCABundleHash(testCABundle),
+				IDPSpecGeneration:         1234,
+				ConnectionValidCondition:  condPtr(ldapConnectionValidTrueConditionWithoutTimeOrGeneration("4242")),
+			}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1477,7 +1745,11 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 				// The dialer that was passed in to the controller's constructor should always have been
 				// passed through to the provider.
 				copyOfExpectedValueForResultingCache.Dialer = dialer
-				require.Equal(t, copyOfExpectedValueForResultingCache, actualIDP.GetConfig())
+				// Every provider should have been given a connection pool, which is shared by all providers for this upstream.
+				actualConfig := actualIDP.GetConfig()
+				require.NotNil(t, actualConfig.ConnectionPool)
+				actualConfig.ConnectionPool = nil
+				require.Equal(t, copyOfExpectedValueForResultingCache, actualConfig)
 			}
 
 			actualUpstreams, err := fakePinnipedClient.IDPV1alpha1().LDAPIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
@@ -1500,6 +1772,133 @@ func TestLDAPUpstreamWatcherControllerSync(t *testing.T) {
 	}
 }
 
+func TestLDAPUpstreamWatcherControllerSyncDoesNotUpdateStatusWhenOnlyIdleConnectionsChange(t *testing.T) {
+	t.Parallel()
+
+	const (
+		testNamespace      = "test-namespace"
+		testName           = "test-name"
+		testBindSecretName = "test-bind-secret"
+		testBindUsername   = "test-bind-username"
+		testBindPassword   = "test-bind-password"
+	)
+
+	testCA, err := certauthority.New("test CA", time.Minute)
+	require.NoError(t, err)
+
+	upstream := &idpv1alpha1.LDAPIdentityProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace, Generation: 1234, UID: "test-resource-uid"},
+		Spec: idpv1alpha1.LDAPIdentityProviderSpec{
+			Host: "ldap.example.com:123",
+			TLS:  &idpv1alpha1.TLSSpec{CertificateAuthorityData: base64.StdEncoding.EncodeToString(testCA.Bundle())},
+			Bind: idpv1alpha1.LDAPIdentityProviderBind{SecretName: testBindSecretName},
+			UserSearch: idpv1alpha1.LDAPIdentityProviderUserSearch{
+				Base:       "test-user-search-base",
+				Attributes: idpv1alpha1.LDAPIdentityProviderUserSearchAttributes{Username: "uid", UID: "uidNumber"},
+			},
+			GroupSearch:    idpv1alpha1.LDAPIdentityProviderGroupSearch{Base: "test-group-search-base"},
+			ConnectionPool: idpv1alpha1.LDAPIdentityProviderConnectionPool{MaxIdleConnections: 3},
+		},
+	}
+	bindSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testBindSecretName, Namespace: testNamespace, ResourceVersion: "4242"},
+		Type:       corev1.SecretTypeBasicAuth,
+		Data:       map[string][]byte{"username": []byte(testBindUsername), "password": []byte(testBindPassword)},
+	}
+
+	fakePinnipedClient := supervisorfake.NewSimpleClientset(upstream)
+	pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+	fakeKubeClient := fake.NewSimpleClientset(bindSecret)
+	kubeInformers := informers.NewSharedInformerFactory(fakeKubeClient, 0)
+	cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	// Only the second sync performs a test dial and bind, and then keeps the connection in the pool.
+	conn := mockldapconn.NewMockConn(ctrl)
+	conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+	conn.EXPECT().Close().AnyTimes()
+	dialer := &comparableDialer{upstreamldap.LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (upstreamldap.Conn, error) {
+		return conn, nil
+	})}
+
+	// The connection was already validated, so the first sync does not perform a test dial and bind.
+	validatedSettings := upstreamwatchers.ValidatedSettings{
+		BindSecretResourceVersion: "4242",
+		LDAPConnectionProtocol:    upstreamldap.TLS,
+		UserSearchBase:            "test-user-search-base",
+		GroupSearchBase:           "test-group-search-base",
+		CABundleHash:              tlsconfigutil.NewCABundleHash(testCA.Bundle()),
+		IDPSpecGeneration:         1234,
+		ConnectionValidCondition: &metav1.Condition{
+			Type:   "LDAPConnectionValid",
+			Status: "True",
+			Reason: "Success",
+			Message: fmt.Sprintf(
+				`successfully able to connect to "ldap.example.com:123" and bind as user "%s" [validated with Secret "%s" at version "4242"]`,
+				testBindUsername, testBindSecretName),
+		},
+	}
+	validatedSettingsCache := &upstreamwatchers.ValidatedSettingsCache{
+		ValidatedSettingsByName: map[string]upstreamwatchers.ValidatedSettings{testName: validatedSettings},
+	}
+
+	controller := newInternal(
+		cache,
+		validatedSettingsCache,
+		dialer,
+		fakePinnipedClient,
+		pinnipedInformers.IDP().V1alpha1().LDAPIdentityProviders(),
+		kubeInformers.Core().V1().Secrets(),
+		kubeInformers.Core().V1().ConfigMaps(),
+		controllerlib.WithInformer,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pinnipedInformers.Start(ctx.Done())
+	kubeInformers.Start(ctx.Done())
+	controllerlib.TestRunSynchronously(t, controller)
+
+	syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}}
+
+	requireIdleConnections := func(want int) {
+		providers := cache.GetLDAPIdentityProviders()
+		require.Len(t, providers, 1)
+		require.Equal(t, want, providers[0].(*upstreamldap.Provider).GetConfig().ConnectionPool.IdleConnections())
+	}
+
+	statusUpdates := func() int {
+		count := 0
+		for _, action := range fakePinnipedClient.Actions() {
+			if action.GetVerb() == "update" && action.GetSubresource() == "status" {
+				count++
+			}
+		}
+		return count
+	}
+
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	requireIdleConnections(0)
+	require.Equal(t, 1, statusUpdates())
+
+	// Wait for the informer to see the status update, so that the next sync compares against it.
+	require.Eventually(t, func() bool {
+		cached, err := pinnipedInformers.IDP().V1alpha1().LDAPIdentityProviders().Lister().LDAPIdentityProviders(testNamespace).Get(testName)
+		return err == nil && len(cached.Status.Conditions) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Forget the validated settings, so that the next sync dials and binds, which adds an idle connection to
+	// the pool, like the connections which are returned to the pool after logins.
+	validatedSettingsCache.ValidatedSettingsByName = map[string]upstreamwatchers.ValidatedSettings{}
+
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	requireIdleConnections(1)
+	require.Equal(t, 1, statusUpdates(), "the status should not be updated when only the number of idle connections changed")
+}
+
 func normalizeLDAPUpstreams(upstreams []idpv1alpha1.LDAPIdentityProvider, now metav1.Time) []idpv1alpha1.LDAPIdentityProvider {
 	result := make([]idpv1alpha1.LDAPIdentityProvider, 0, len(upstreams))
 	for _, u := range upstreams {
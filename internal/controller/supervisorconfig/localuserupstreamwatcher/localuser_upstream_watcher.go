@@ -0,0 +1,315 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package localuserupstreamwatcher implements a controller which watches LocalUserIdentityProviders.
+package localuserupstreamwatcher
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/utils/clock"
+
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	supervisorclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
+	idpinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/idp/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/conditionsutil"
+	"go.pinniped.dev/internal/controller/supervisorconfig/upstreamwatchers"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/upstreamlocaluser"
+)
+
+const (
+	// Setup for the name of our controller in logs.
+	localUserControllerName = "localuser-upstream-observer"
+
+	// Constants related to the user Secrets.
+	localUserSecretType corev1.SecretType = "secrets.pinniped.dev/local-user"
+	usernameDataKey                       = "username"
+	passwordHashDataKey                   = "passwordHash"
+	groupsDataKey                         = "groups"
+	totpSecretDataKey                     = "totpSecret"
+
+	// Defaults for the lockout settings.
+	defaultMaxFailedAttempts      = 5
+	defaultLockoutDurationSeconds = 900
+
+	// Constants related to conditions.
+	typeUserSecretsValid = "UserSecretsValid" //nolint:gosec // this is not a credential
+
+	reasonInvalidSelector    = "InvalidSelector"
+	reasonInvalidUserSecrets = "InvalidUserSecrets" //nolint:gosec // this is not a credential
+)
+
+// UpstreamLocalUserIdentityProviderICache is a thread safe cache that holds a list of validated upstream local user IDP configurations.
+type UpstreamLocalUserIdentityProviderICache interface {
+	SetLocalUserIdentityProviders([]upstreamprovider.UpstreamLocalUserIdentityProviderI)
+}
+
+type localUserWatcherController struct {
+	cache                             UpstreamLocalUserIdentityProviderICache
+	log                               plog.Logger
+	client                            supervisorclientset.Interface
+	localUserIdentityProviderInformer idpinformers.LocalUserIdentityProviderInformer
+	secretInformer                    corev1informers.SecretInformer
+	clock                             clock.PassiveClock
+
+	// loginAttempts holds a tracker for each LocalUserIdentityProvider, by UID, so that failed login attempts
+	// are remembered when the users of a LocalUserIdentityProvider are reloaded.
+	loginAttempts map[types.UID]*upstreamlocaluser.LoginAttemptTracker
+}
+
+// New instantiates a new controllerlib.Controller which will populate the provided UpstreamLocalUserIdentityProviderICache.
+func New(
+	idpCache UpstreamLocalUserIdentityProviderICache,
+	client supervisorclientset.Interface,
+	localUserIdentityProviderInformer idpinformers.LocalUserIdentityProviderInformer,
+	secretInformer corev1informers.SecretInformer,
+	clock clock.PassiveClock,
+	log plog.Logger,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+) controllerlib.Controller {
+	c := localUserWatcherController{
+		cache:                             idpCache,
+		log:                               log.WithName(localUserControllerName),
+		client:                            client,
+		localUserIdentityProviderInformer: localUserIdentityProviderInformer,
+		secretInformer:                    secretInformer,
+		clock:                             clock,
+		loginAttempts:                     map[types.UID]*upstreamlocaluser.LoginAttemptTracker{},
+	}
+	return controllerlib.New(
+		controllerlib.Config{Name: localUserControllerName, Syncer: &c},
+		withInformer(
+			localUserIdentityProviderInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			secretInformer,
+			pinnipedcontroller.MatchAnySecretOfTypesFilter(
+				[]corev1.SecretType{localUserSecretType},
+				pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+	)
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *localUserWatcherController) Sync(ctx controllerlib.Context) error {
+	actualUpstreams, err := c.localUserIdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list LocalUserIdentityProviders: %w", err)
+	}
+
+	requeue := false
+	validatedUpstreams := make([]upstreamprovider.UpstreamLocalUserIdentityProviderI, 0, len(actualUpstreams))
+	currentUIDs := make(map[types.UID]bool, len(actualUpstreams))
+	for _, upstream := range actualUpstreams {
+		currentUIDs[upstream.UID] = true
+		valid := c.validateUpstream(ctx, upstream)
+		if valid == nil {
+			requeue = true
+		} else {
+			validatedUpstreams = append(validatedUpstreams, upstreamlocaluser.New(*valid))
+		}
+	}
+	c.cache.SetLocalUserIdentityProviders(validatedUpstreams)
+
+	// Forget the login attempts for LocalUserIdentityProviders which were deleted.
+	for uid := range c.loginAttempts {
+		if !currentUIDs[uid] {
+			delete(c.loginAttempts, uid)
+		}
+	}
+
+	if requeue {
+		return controllerlib.ErrSyntheticRequeue
+	}
+	return nil
+}
+
+// validateUpstream validates the provided idpv1alpha1.LocalUserIdentityProvider and returns the validated configuration as a
+// upstreamlocaluser.ProviderConfig. As a side effect, it also updates the status of the idpv1alpha1.LocalUserIdentityProvider.
+func (c *localUserWatcherController) validateUpstream(ctx controllerlib.Context, upstream *idpv1alpha1.LocalUserIdentityProvider) *upstreamlocaluser.ProviderConfig {
+	maxFailedAttempts := int32(defaultMaxFailedAttempts)
+	if upstream.Spec.Lockout.MaxFailedAttempts != nil {
+		maxFailedAttempts = *upstream.Spec.Lockout.MaxFailedAttempts
+	}
+	lockoutDurationSeconds := int32(defaultLockoutDurationSeconds)
+	if upstream.Spec.Lockout.DurationSeconds != nil {
+		lockoutDurationSeconds = *upstream.Spec.Lockout.DurationSeconds
+	}
+
+	loginAttempts, ok := c.loginAttempts[upstream.UID]
+	if !ok {
+		loginAttempts = upstreamlocaluser.NewLoginAttemptTracker(c.clock)
+		c.loginAttempts[upstream.UID] = loginAttempts
+	}
+
+	result := upstreamlocaluser.ProviderConfig{
+		Name:              upstream.Name,
+		ResourceUID:       upstream.UID,
+		MaxFailedAttempts: maxFailedAttempts,
+		LockoutDuration:   time.Duration(lockoutDurationSeconds) * time.Second,
+		LoginAttempts:     loginAttempts,
+	}
+
+	conditions := []*metav1.Condition{
+		c.validateUsers(upstream, &result),
+	}
+
+	hadErrorCondition := c.updateStatus(ctx.Context, upstream, conditions)
+
+	if hadErrorCondition {
+		return nil
+	}
+	return &result
+}
+
+// validateUsers loads the users from the Secrets selected by .spec.users.secretSelector, and returns the
+// appropriate UserSecretsValid condition.
+func (c *localUserWatcherController) validateUsers(upstream *idpv1alpha1.LocalUserIdentityProvider, result *upstreamlocaluser.ProviderConfig) *metav1.Condition {
+	buildInvalidCondition := func(reason, message string) *metav1.Condition {
+		return &metav1.Condition{
+			Type:    typeUserSecretsValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		}
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&upstream.Spec.Users.SecretSelector)
+	if err != nil {
+		return buildInvalidCondition(reasonInvalidSelector, fmt.Sprintf("spec.users.secretSelector is invalid: %s", err.Error()))
+	}
+
+	// Fetch the Secrets from informer cache.
+	secrets, err := c.secretInformer.Lister().Secrets(upstream.Namespace).List(selector)
+	if err != nil {
+		// This shouldn't really happen, since the informer's lister does not return errors.
+		return buildInvalidCondition(upstreamwatchers.ReasonNotFound, err.Error())
+	}
+
+	secrets = slices.DeleteFunc(secrets, func(secret *corev1.Secret) bool {
+		return secret.Type != localUserSecretType
+	})
+	if len(secrets) == 0 {
+		return buildInvalidCondition(upstreamwatchers.ReasonNotFound,
+			fmt.Sprintf("no Secrets of type %q were selected by spec.users.secretSelector", localUserSecretType))
+	}
+
+	// Sort the Secrets to make the error messages stable.
+	slices.SortFunc(secrets, func(a, b *corev1.Secret) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	users := make(map[string]*upstreamlocaluser.User, len(secrets))
+	var problems []string
+	for _, secret := range secrets {
+		user, err := userFromSecret(secret)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Secret %q %s", secret.Name, err.Error()))
+			continue
+		}
+		if _, duplicate := users[user.Username]; duplicate {
+			problems = append(problems, fmt.Sprintf("Secret %q has a duplicate username %q", secret.Name, user.Username))
+			continue
+		}
+		users[user.Username] = user
+	}
+	if len(problems) > 0 {
+		return buildInvalidCondition(reasonInvalidUserSecrets, strings.Join(problems, "; "))
+	}
+
+	// If everything is valid, update the result and set the condition to true.
+	result.Users = users
+	return &metav1.Condition{
+		Type:    typeUserSecretsValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  conditionsutil.ReasonSuccess,
+		Message: fmt.Sprintf("loaded %d users", len(users)),
+	}
+}
+
+func userFromSecret(secret *corev1.Secret) (*upstreamlocaluser.User, error) {
+	username := string(secret.Data[usernameDataKey])
+	passwordHash := string(secret.Data[passwordHashDataKey])
+	if username == "" || passwordHash == "" {
+		return nil, fmt.Errorf("is missing required keys %q", []string{usernameDataKey, passwordHashDataKey})
+	}
+
+	parsedPasswordHash, err := upstreamlocaluser.ParsePasswordHash(passwordHash)
+	if err != nil {
+		return nil, fmt.Errorf("has an invalid %q: %w", passwordHashDataKey, err)
+	}
+
+	user := &upstreamlocaluser.User{
+		Username:     username,
+		UID:          secret.UID,
+		PasswordHash: parsedPasswordHash,
+	}
+
+	for _, group := range strings.Split(string(secret.Data[groupsDataKey]), "\n") {
+		if group = strings.TrimSpace(group); group != "" {
+			user.Groups = append(user.Groups, group)
+		}
+	}
+
+	if totpSecret := secret.Data[totpSecretDataKey]; len(totpSecret) > 0 {
+		user.TOTPSecret, err = upstreamlocaluser.ParseTOTPSecret(string(totpSecret))
+		if err != nil {
+			return nil, fmt.Errorf("has an invalid %q: %w", totpSecretDataKey, err)
+		}
+	}
+
+	return user, nil
+}
+
+func (c *localUserWatcherController) updateStatus(
+	ctx context.Context,
+	upstream *idpv1alpha1.LocalUserIdentityProvider,
+	conditions []*metav1.Condition,
+) bool {
+	log := c.log.WithValues("namespace", upstream.Namespace, "name", upstream.Name)
+	updated := upstream.DeepCopy()
+
+	hadErrorCondition := conditionsutil.MergeConditions(
+		conditions,
+		&updated.Status.Conditions,
+		upstream.Generation,
+		metav1.Now(),
+		log,
+	)
+
+	updated.Status.Phase = idpv1alpha1.LocalUserPhaseReady
+	if hadErrorCondition {
+		updated.Status.Phase = idpv1alpha1.LocalUserPhaseError
+	}
+
+	if equality.Semantic.DeepEqual(upstream, updated) {
+		return hadErrorCondition
+	}
+
+	_, err := c.client.
+		IDPV1alpha1().
+		LocalUserIdentityProviders(upstream.Namespace).
+		UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		log.Error("failed to update status", err)
+	}
+
+	return hadErrorCondition
+}
@@ -0,0 +1,445 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package localuserupstreamwatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	supervisorinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/federationdomain/dynamicupstreamprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/upstreamlocaluser"
+)
+
+func TestLocalUserUpstreamWatcherControllerFilterSecret(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		secret     metav1.Object
+		wantAdd    bool
+		wantUpdate bool
+		wantDelete bool
+	}{
+		{
+			name: "should return true for a secret of the type secrets.pinniped.dev/local-user",
+			secret: &corev1.Secret{
+				Type:       "secrets.pinniped.dev/local-user",
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
+		{
+			name: "should return false for a secret of the type Opaque",
+			secret: &corev1.Secret{
+				Type:       corev1.SecretTypeOpaque,
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+		},
+		{
+			name: "should return false for a secret of the wrong type",
+			secret: &corev1.Secret{
+				Type:       "secrets.pinniped.dev/some-other-type",
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+		},
+		{
+			name: "should return false for resource of wrong data type",
+			secret: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakePinnipedClient := supervisorfake.NewSimpleClientset()
+			pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+			fakeKubeClient := fake.NewSimpleClientset()
+			kubeInformers := informers.NewSharedInformerFactory(fakeKubeClient, 0)
+			cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+			secretInformer := kubeInformers.Core().V1().Secrets()
+			withInformer := testutil.NewObservableWithInformerOption()
+
+			logger, _ := plog.TestLogger(t)
+
+			New(
+				cache,
+				nil,
+				pinnipedInformers.IDP().V1alpha1().LocalUserIdentityProviders(),
+				secretInformer,
+				clock.RealClock{},
+				logger,
+				withInformer.WithInformer,
+			)
+
+			unrelated := corev1.Secret{}
+			filter := withInformer.GetFilterForInformer(secretInformer)
+			require.Equal(t, test.wantAdd, filter.Add(test.secret))
+			require.Equal(t, test.wantUpdate, filter.Update(&unrelated, test.secret))
+			require.Equal(t, test.wantUpdate, filter.Update(test.secret, &unrelated))
+			require.Equal(t, test.wantDelete, filter.Delete(test.secret))
+		})
+	}
+}
+
+func TestLocalUserUpstreamWatcherControllerSync(t *testing.T) {
+	t.Parallel()
+	now := metav1.NewTime(time.Now().UTC())
+
+	var (
+		testNamespace  = "test-namespace"
+		testName       = "test-name"
+		testUID        = types.UID("test-uid")
+		testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+		testLabels     = map[string]string{"pinniped.dev/local-users": "test"}
+	)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("alice-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	testPasswordHash := string(hash)
+
+	userSecret := func(name, username string, extraData map[string]string) *corev1.Secret {
+		data := map[string][]byte{
+			"username":     []byte(username),
+			"passwordHash": []byte(testPasswordHash),
+		}
+		for k, v := range extraData {
+			data[k] = []byte(v)
+		}
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: testNamespace,
+				UID:       types.UID(name + "-uid"),
+				Labels:    testLabels,
+			},
+			Type: "secrets.pinniped.dev/local-user",
+			Data: data,
+		}
+	}
+
+	validSpec := func() idpv1alpha1.LocalUserIdentityProviderSpec {
+		return idpv1alpha1.LocalUserIdentityProviderSpec{
+			Users: idpv1alpha1.LocalUserUsers{
+				SecretSelector: metav1.LabelSelector{MatchLabels: testLabels},
+			},
+		}
+	}
+
+	// wantUser describes the parts of an upstreamlocaluser.User which can be compared directly.
+	type wantUser struct {
+		uid        types.UID
+		groups     []string
+		totpSecret bool
+	}
+
+	// wantProvider describes the parts of an upstreamlocaluser.ProviderConfig which can be compared directly.
+	type wantProvider struct {
+		maxFailedAttempts int32
+		lockoutDuration   time.Duration
+		users             map[string]wantUser
+	}
+
+	tests := []struct {
+		name                   string
+		inputUpstreams         []runtime.Object
+		inputResources         []runtime.Object
+		wantErr                string
+		wantResultingCache     []wantProvider
+		wantResultingUpstreams []idpv1alpha1.LocalUserIdentityProvider
+	}{
+		{
+			name: "no upstreams",
+		},
+		{
+			name: "valid upstream with default lockout settings",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.LocalUserIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec:       validSpec(),
+			}},
+			inputResources: []runtime.Object{
+				userSecret("bob-secret", "bob", nil),
+				userSecret("alice-secret", "alice", map[string]string{
+					"groups":     "group1\n  group2  \n\n",
+					"totpSecret": testTOTPSecret,
+				}),
+				// Secrets which are not selected are ignored.
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: testNamespace},
+					Type:       "secrets.pinniped.dev/local-user",
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "other", Labels: testLabels},
+					Type:       "secrets.pinniped.dev/local-user",
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "wrong-type", Namespace: testNamespace, Labels: testLabels},
+					Type:       corev1.SecretTypeOpaque,
+				},
+			},
+			wantResultingCache: []wantProvider{{
+				maxFailedAttempts: 5,
+				lockoutDuration:   15 * time.Minute,
+				users: map[string]wantUser{
+					"alice": {uid: "alice-secret-uid", groups: []string{"group1", "group2"}, totpSecret: true},
+					"bob":   {uid: "bob-secret-uid"},
+				},
+			}},
+			wantResultingUpstreams: []idpv1alpha1.LocalUserIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.LocalUserIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{{
+						Type: "UserSecretsValid", Status: "True", LastTransitionTime: now, Reason: "Success",
+						Message: "loaded 2 users",
+					}},
+				},
+			}},
+		},
+		{
+			name: "valid upstream with custom lockout settings",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.LocalUserIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.LocalUserIdentityProviderSpec {
+					spec := validSpec()
+					spec.Lockout = idpv1alpha1.LocalUserLockout{
+						MaxFailedAttempts: ptr.To[int32](0),
+						DurationSeconds:   ptr.To[int32](60),
+					}
+					return spec
+				}(),
+			}},
+			inputResources: []runtime.Object{userSecret("alice-secret", "alice", nil)},
+			wantResultingCache: []wantProvider{{
+				maxFailedAttempts: 0,
+				lockoutDuration:   time.Minute,
+				users: map[string]wantUser{
+					"alice": {uid: "alice-secret-uid"},
+				},
+			}},
+			wantResultingUpstreams: []idpv1alpha1.LocalUserIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.LocalUserIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{{
+						Type: "UserSecretsValid", Status: "True", LastTransitionTime: now, Reason: "Success",
+						Message: "loaded 1 users",
+					}},
+				},
+			}},
+		},
+		{
+			name: "no Secrets are selected",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.LocalUserIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec:       validSpec(),
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.LocalUserIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.LocalUserIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{{
+						Type: "UserSecretsValid", Status: "False", LastTransitionTime: now, Reason: "SecretNotFound",
+						Message: `no Secrets of type "secrets.pinniped.dev/local-user" were selected by spec.users.secretSelector`,
+					}},
+				},
+			}},
+		},
+		{
+			name: "invalid selector",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.LocalUserIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.LocalUserIdentityProviderSpec{
+					Users: idpv1alpha1.LocalUserUsers{
+						SecretSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: "foo", Operator: "NotAnOperator"},
+						}},
+					},
+				},
+			}},
+			inputResources:     []runtime.Object{userSecret("alice-secret", "alice", nil)},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.LocalUserIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.LocalUserIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{{
+						Type: "UserSecretsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidSelector",
+						Message: `spec.users.secretSelector is invalid: "NotAnOperator" is not a valid label selector operator`,
+					}},
+				},
+			}},
+		},
+		{
+			name: "invalid and duplicate user Secrets",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.LocalUserIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec:       validSpec(),
+			}},
+			inputResources: []runtime.Object{
+				userSecret("a-secret", "alice", nil),
+				userSecret("b-secret", "alice", nil),
+				userSecret("c-secret", "", nil),
+				userSecret("d-secret", "dave", map[string]string{"passwordHash": "plaintext"}),
+				userSecret("e-secret", "eve", map[string]string{"totpSecret": "short"}),
+			},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.LocalUserIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.LocalUserIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{{
+						Type: "UserSecretsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidUserSecrets",
+						Message: `Secret "b-secret" has a duplicate username "alice"; ` +
+							`Secret "c-secret" is missing required keys ["username" "passwordHash"]; ` +
+							`Secret "d-secret" has an invalid "passwordHash": unsupported hash format: must be a bcrypt hash or an argon2id hash in the PHC string format; ` +
+							`Secret "e-secret" has an invalid "totpSecret": invalid TOTP secret: must be at least 16 bytes (26 base32 characters)`,
+					}},
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fakePinnipedClient := supervisorfake.NewSimpleClientset(tt.inputUpstreams...)
+			pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+			fakeKubeClient := fake.NewSimpleClientset(tt.inputResources...)
+			kubeInformers := informers.NewSharedInformerFactory(fakeKubeClient, 0)
+			cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+			cache.SetLocalUserIdentityProviders([]upstreamprovider.UpstreamLocalUserIdentityProviderI{
+				upstreamlocaluser.New(upstreamlocaluser.ProviderConfig{Name: "initial-entry"}),
+			})
+
+			logger, _ := plog.TestLogger(t)
+
+			controller := New(
+				cache,
+				fakePinnipedClient,
+				pinnipedInformers.IDP().V1alpha1().LocalUserIdentityProviders(),
+				kubeInformers.Core().V1().Secrets(),
+				clock.RealClock{},
+				logger,
+				controllerlib.WithInformer,
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			pinnipedInformers.Start(ctx.Done())
+			kubeInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			actualIDPList := cache.GetLocalUserIdentityProviders()
+			require.Equal(t, len(tt.wantResultingCache), len(actualIDPList))
+			firstLoginAttempts := make([]*upstreamlocaluser.LoginAttemptTracker, len(actualIDPList))
+			for i := range actualIDPList {
+				actualConfig := actualIDPList[i].(*upstreamlocaluser.Provider).GetConfig()
+				want := tt.wantResultingCache[i]
+
+				require.Equal(t, testName, actualConfig.Name)
+				require.Equal(t, testUID, actualConfig.ResourceUID)
+				require.Equal(t, want.maxFailedAttempts, actualConfig.MaxFailedAttempts)
+				require.Equal(t, want.lockoutDuration, actualConfig.LockoutDuration)
+				require.NotNil(t, actualConfig.LoginAttempts)
+				firstLoginAttempts[i] = actualConfig.LoginAttempts
+
+				require.Len(t, actualConfig.Users, len(want.users))
+				for username, wantUser := range want.users {
+					actualUser := actualConfig.Users[username]
+					require.NotNil(t, actualUser)
+					require.Equal(t, username, actualUser.Username)
+					require.Equal(t, wantUser.uid, actualUser.UID)
+					require.Equal(t, wantUser.groups, actualUser.Groups)
+					require.True(t, actualUser.PasswordHash.Matches("alice-password"))
+					require.False(t, actualUser.PasswordHash.Matches("wrong-password"))
+					if wantUser.totpSecret {
+						require.Len(t, actualUser.TOTPSecret, 20)
+					} else {
+						require.Nil(t, actualUser.TOTPSecret)
+					}
+				}
+			}
+
+			actualUpstreams, err := fakePinnipedClient.IDPV1alpha1().LocalUserIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+
+			// Assert on the expected Status of the upstreams. Preprocess the upstreams a bit so that they're easier to assert against.
+			require.ElementsMatch(t, tt.wantResultingUpstreams, normalizeLocalUserUpstreams(actualUpstreams.Items, now))
+
+			// Running the sync() a second time should be idempotent, and should return the same error.
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			actualUpstreams, err = fakePinnipedClient.IDPV1alpha1().LocalUserIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.wantResultingUpstreams, normalizeLocalUserUpstreams(actualUpstreams.Items, now))
+
+			// The failed login attempts should be remembered when the users are reloaded.
+			actualIDPList = cache.GetLocalUserIdentityProviders()
+			require.Equal(t, len(tt.wantResultingCache), len(actualIDPList))
+			for i := range actualIDPList {
+				actualConfig := actualIDPList[i].(*upstreamlocaluser.Provider).GetConfig()
+				require.Same(t, firstLoginAttempts[i], actualConfig.LoginAttempts)
+			}
+		})
+	}
+}
+
+func normalizeLocalUserUpstreams(upstreams []idpv1alpha1.LocalUserIdentityProvider, now metav1.Time) []idpv1alpha1.LocalUserIdentityProvider {
+	result := make([]idpv1alpha1.LocalUserIdentityProvider, 0, len(upstreams))
+	for _, u := range upstreams {
+		normalized := u.DeepCopy()
+
+		// We're only interested in comparing the status, so zero out the spec.
+		normalized.Spec = idpv1alpha1.LocalUserIdentityProviderSpec{}
+
+		// Round down the LastTransitionTime values to `now` if they were just updated. This makes
+		// it much easier to encode assertions about the expected timestamps.
+		for i := range normalized.Status.Conditions {
+			if time.Since(normalized.Status.Conditions[i].LastTransitionTime.Time) < 5*time.Second {
+				normalized.Status.Conditions[i].LastTransitionTime = now
+			}
+		}
+		result = append(result, *normalized)
+	}
+
+	return result
+}
@@ -0,0 +1,417 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package oauth2upstreamwatcher implements a controller which watches OAuth2IdentityProviders.
+package oauth2upstreamwatcher
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	supervisorclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
+	idpinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/idp/v1alpha1"
+	"go.pinniped.dev/internal/celtransformer"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/conditionsutil"
+	"go.pinniped.dev/internal/controller/supervisorconfig/upstreamwatchers"
+	"go.pinniped.dev/internal/controller/tlsconfigutil"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/net/phttp"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/upstreamoauth2"
+)
+
+const (
+	// Setup for the name of our controller in logs.
+	oauth2ControllerName = "oauth2-upstream-observer"
+
+	// Constants related to the client credentials Secret.
+	oauth2ClientSecretType corev1.SecretType = "secrets.pinniped.dev/oauth2-client"
+	clientIDDataKey                          = "clientID"
+	clientSecretDataKey                      = "clientSecret"
+
+	// The maximum amount of time that the evaluation of any one claims expression may take during a login.
+	claimsMaxExpressionRuntime = 5 * time.Second
+
+	// Constants related to conditions.
+	typeClientCredentialsSecretValid       = "ClientCredentialsSecretValid" //nolint:gosec // this is not a credential
+	typeAdditionalAuthorizeParametersValid = "AdditionalAuthorizeParametersValid"
+	typeClaimsValid                        = "ClaimsValid"
+
+	reasonDisallowedParameterName = "DisallowedParameterName"
+	reasonInvalidClaims           = "InvalidClaims"
+	allParamNamesAllowedMsg       = "additionalAuthorizeParameters parameter names are allowed"
+)
+
+var (
+	disallowedAdditionalAuthorizeParameters = map[string]bool{ //nolint:gochecknoglobals
+		// Reject these AdditionalAuthorizeParameters to avoid allowing the user's config to overwrite the parameters
+		// that are always used by Pinniped in authcode authorization requests. Users can already set the "client_id"
+		// and "scope" params using other settings, and the others never make sense to override. This map should be
+		// treated as read-only since it is a global variable.
+		"response_type":         true,
+		"scope":                 true,
+		"client_id":             true,
+		"state":                 true,
+		"code_challenge":        true,
+		"code_challenge_method": true,
+		"redirect_uri":          true,
+	}
+)
+
+// UpstreamOAuth2IdentityProviderICache is a thread safe cache that holds a list of validated upstream OAuth2 IDP configurations.
+type UpstreamOAuth2IdentityProviderICache interface {
+	SetOAuth2IdentityProviders([]upstreamprovider.UpstreamOAuth2IdentityProviderI)
+}
+
+type oauth2WatcherController struct {
+	cache                          UpstreamOAuth2IdentityProviderICache
+	log                            plog.Logger
+	client                         supervisorclientset.Interface
+	oauth2IdentityProviderInformer idpinformers.OAuth2IdentityProviderInformer
+	secretInformer                 corev1informers.SecretInformer
+	configMapInformer              corev1informers.ConfigMapInformer
+	responseMapper                 *celtransformer.ResponseMapper
+}
+
+// New instantiates a new controllerlib.Controller which will populate the provided UpstreamOAuth2IdentityProviderICache.
+func New(
+	idpCache UpstreamOAuth2IdentityProviderICache,
+	client supervisorclientset.Interface,
+	oauth2IdentityProviderInformer idpinformers.OAuth2IdentityProviderInformer,
+	secretInformer corev1informers.SecretInformer,
+	configMapInformer corev1informers.ConfigMapInformer,
+	log plog.Logger,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+) controllerlib.Controller {
+	c := oauth2WatcherController{
+		cache:                          idpCache,
+		log:                            log.WithName(oauth2ControllerName),
+		client:                         client,
+		oauth2IdentityProviderInformer: oauth2IdentityProviderInformer,
+		secretInformer:                 secretInformer,
+		configMapInformer:              configMapInformer,
+	}
+	return controllerlib.New(
+		controllerlib.Config{Name: oauth2ControllerName, Syncer: &c},
+		withInformer(
+			oauth2IdentityProviderInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			secretInformer,
+			pinnipedcontroller.MatchAnySecretOfTypesFilter(
+				[]corev1.SecretType{
+					oauth2ClientSecretType,
+					corev1.SecretTypeOpaque,
+					corev1.SecretTypeTLS,
+				},
+				pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			configMapInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+	)
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *oauth2WatcherController) Sync(ctx controllerlib.Context) error {
+	actualUpstreams, err := c.oauth2IdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list OAuth2IdentityProviders: %w", err)
+	}
+
+	if c.responseMapper == nil {
+		c.responseMapper, err = celtransformer.NewResponseMapper(claimsMaxExpressionRuntime)
+		if err != nil {
+			return err // shouldn't really happen
+		}
+	}
+
+	requeue := false
+	validatedUpstreams := make([]upstreamprovider.UpstreamOAuth2IdentityProviderI, 0, len(actualUpstreams))
+	for _, upstream := range actualUpstreams {
+		valid := c.validateUpstream(ctx, upstream)
+		if valid == nil {
+			requeue = true
+		} else {
+			validatedUpstreams = append(validatedUpstreams, upstreamoauth2.New(*valid))
+		}
+	}
+	c.cache.SetOAuth2IdentityProviders(validatedUpstreams)
+	if requeue {
+		return controllerlib.ErrSyntheticRequeue
+	}
+	return nil
+}
+
+// validateUpstream validates the provided idpv1alpha1.OAuth2IdentityProvider and returns the validated configuration as a
+// upstreamoauth2.ProviderConfig. As a side effect, it also updates the status of the idpv1alpha1.OAuth2IdentityProvider.
+func (c *oauth2WatcherController) validateUpstream(ctx controllerlib.Context, upstream *idpv1alpha1.OAuth2IdentityProvider) *upstreamoauth2.ProviderConfig {
+	spec := upstream.Spec
+
+	additionalAuthcodeAuthorizeParameters := map[string]string{}
+	var rejectedAuthcodeAuthorizeParameters []string
+	for _, p := range spec.AuthorizationConfig.AdditionalAuthorizeParameters {
+		if disallowedAdditionalAuthorizeParameters[p.Name] {
+			rejectedAuthcodeAuthorizeParameters = append(rejectedAuthcodeAuthorizeParameters, p.Name)
+		} else {
+			additionalAuthcodeAuthorizeParameters[p.Name] = p.Value
+		}
+	}
+
+	result := upstreamoauth2.ProviderConfig{
+		Name:        upstream.Name,
+		ResourceUID: upstream.UID,
+		OAuth2Config: &oauth2.Config{
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  spec.AuthorizationURL,
+				TokenURL: spec.TokenURL,
+				// Try sending the client credentials in the Authorization header first, then fall back
+				// to sending them in the request body, since authorization servers vary in what they support.
+				AuthStyle: oauth2.AuthStyleAutoDetect,
+			},
+			RedirectURL: "", // this will be different for each FederationDomain, so we do not set it here
+			Scopes:      spec.AuthorizationConfig.Scopes,
+		},
+		RevocationURL:            spec.RevocationURL,
+		AdditionalAuthcodeParams: additionalAuthcodeAuthorizeParameters,
+	}
+
+	conditions := []*metav1.Condition{
+		c.validateSecret(upstream, &result),
+		c.validateTLS(upstream, &result),
+		c.validateClaims(upstream, &result),
+	}
+
+	if len(rejectedAuthcodeAuthorizeParameters) > 0 {
+		conditions = append(conditions, &metav1.Condition{
+			Type:   typeAdditionalAuthorizeParametersValid,
+			Status: metav1.ConditionFalse,
+			Reason: reasonDisallowedParameterName,
+			Message: fmt.Sprintf("the following additionalAuthorizeParameters are not allowed: %s",
+				strings.Join(rejectedAuthcodeAuthorizeParameters, ",")),
+		})
+	} else {
+		conditions = append(conditions, &metav1.Condition{
+			Type:    typeAdditionalAuthorizeParametersValid,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditionsutil.ReasonSuccess,
+			Message: allParamNamesAllowedMsg,
+		})
+	}
+
+	hadErrorCondition := c.updateStatus(ctx.Context, upstream, conditions)
+
+	if hadErrorCondition {
+		return nil
+	}
+	return &result
+}
+
+// validateSecret validates the client credentials Secret referenced by .spec.client.secretName, and returns the
+// appropriate ClientCredentialsSecretValid condition.
+func (c *oauth2WatcherController) validateSecret(upstream *idpv1alpha1.OAuth2IdentityProvider, result *upstreamoauth2.ProviderConfig) *metav1.Condition {
+	secretName := upstream.Spec.Client.SecretName
+
+	// Fetch the Secret from informer cache.
+	secret, err := c.secretInformer.Lister().Secrets(upstream.Namespace).Get(secretName)
+	if err != nil {
+		return &metav1.Condition{
+			Type:    typeClientCredentialsSecretValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  upstreamwatchers.ReasonNotFound,
+			Message: err.Error(),
+		}
+	}
+
+	// Validate the secret .type field.
+	if secret.Type != oauth2ClientSecretType {
+		return &metav1.Condition{
+			Type:    typeClientCredentialsSecretValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  upstreamwatchers.ReasonWrongType,
+			Message: fmt.Sprintf("referenced Secret %q has wrong type %q (should be %q)", secretName, secret.Type, oauth2ClientSecretType),
+		}
+	}
+
+	// Validate the secret .data field.
+	clientID := secret.Data[clientIDDataKey]
+	clientSecret := secret.Data[clientSecretDataKey]
+	if len(clientID) == 0 || len(clientSecret) == 0 {
+		return &metav1.Condition{
+			Type:    typeClientCredentialsSecretValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  upstreamwatchers.ReasonMissingKeys,
+			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q", secretName, []string{clientIDDataKey, clientSecretDataKey}),
+		}
+	}
+
+	// If everything is valid, update the result and set the condition to true.
+	result.OAuth2Config.ClientID = string(clientID)
+	result.OAuth2Config.ClientSecret = string(clientSecret)
+	return &metav1.Condition{
+		Type:    typeClientCredentialsSecretValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  conditionsutil.ReasonSuccess,
+		Message: "loaded client credentials",
+	}
+}
+
+// validateTLS validates .spec.tls and returns the appropriate TLSConfigurationValid condition.
+func (c *oauth2WatcherController) validateTLS(upstream *idpv1alpha1.OAuth2IdentityProvider, result *upstreamoauth2.ProviderConfig) *metav1.Condition {
+	tlsCondition, caBundle := tlsconfigutil.ValidateTLSConfig(
+		tlsconfigutil.TLSSpecForSupervisor(upstream.Spec.TLS),
+		"spec.tls",
+		upstream.Namespace,
+		c.secretInformer,
+		c.configMapInformer)
+
+	if tlsCondition.Reason == conditionsutil.ReasonSuccess {
+		result.HttpClient = defaultClientShortTimeout(caBundle.CertPool())
+	}
+	return tlsCondition
+}
+
+// validateClaims validates the URLs and compiles the expressions which together determine the identity of the user,
+// and returns the appropriate ClaimsValid condition.
+func (c *oauth2WatcherController) validateClaims(upstream *idpv1alpha1.OAuth2IdentityProvider, result *upstreamoauth2.ProviderConfig) *metav1.Condition {
+	spec := upstream.Spec
+
+	buildInvalidCondition := func(message string) *metav1.Condition {
+		return &metav1.Condition{
+			Type:    typeClaimsValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonInvalidClaims,
+			Message: message,
+		}
+	}
+
+	userInfoURL, err := parseHTTPSURL(spec.UserInfoURL)
+	if err != nil {
+		return buildInvalidCondition(fmt.Sprintf("spec.userInfoURL (%q) is invalid: %s", spec.UserInfoURL, err.Error()))
+	}
+
+	var groupsURL *url.URL
+	if spec.GroupsURL != "" {
+		groupsURL, err = parseHTTPSURL(spec.GroupsURL)
+		if err != nil {
+			return buildInvalidCondition(fmt.Sprintf("spec.groupsURL (%q) is invalid: %s", spec.GroupsURL, err.Error()))
+		}
+	}
+
+	if spec.Claims.Username == "" {
+		// Should not happen due to CRD validation.
+		return buildInvalidCondition("spec.claims.username is required")
+	}
+	username, err := c.responseMapper.CompileStringExpression(spec.Claims.Username)
+	if err != nil {
+		return buildInvalidCondition(fmt.Sprintf("spec.claims.username is invalid:\n%s", pinnipedcontroller.TruncateMostLongErr(err)))
+	}
+
+	// When not specified, the subject is determined by the same expression as the username.
+	subject := username
+	if spec.Claims.Subject != "" {
+		subject, err = c.responseMapper.CompileStringExpression(spec.Claims.Subject)
+		if err != nil {
+			return buildInvalidCondition(fmt.Sprintf("spec.claims.subject is invalid:\n%s", pinnipedcontroller.TruncateMostLongErr(err)))
+		}
+	}
+
+	var groups *celtransformer.CompiledStringListExpression
+	if spec.Claims.Groups != "" {
+		groups, err = c.responseMapper.CompileStringListExpression(spec.Claims.Groups)
+		if err != nil {
+			return buildInvalidCondition(fmt.Sprintf("spec.claims.groups is invalid:\n%s", pinnipedcontroller.TruncateMostLongErr(err)))
+		}
+	}
+
+	// If everything is valid, update the result and set the condition to true.
+	result.UserInfoURL = userInfoURL
+	result.GroupsURL = groupsURL
+	result.Claims = &upstreamoauth2.CompiledClaims{
+		Username: username,
+		Subject:  subject,
+		Groups:   groups,
+	}
+	return &metav1.Condition{
+		Type:    typeClaimsValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  conditionsutil.ReasonSuccess,
+		Message: "spec.claims are valid",
+	}
+}
+
+func (c *oauth2WatcherController) updateStatus(
+	ctx context.Context,
+	upstream *idpv1alpha1.OAuth2IdentityProvider,
+	conditions []*metav1.Condition,
+) bool {
+	log := c.log.WithValues("namespace", upstream.Namespace, "name", upstream.Name)
+	updated := upstream.DeepCopy()
+
+	hadErrorCondition := conditionsutil.MergeConditions(
+		conditions,
+		&updated.Status.Conditions,
+		upstream.Generation,
+		metav1.Now(),
+		log,
+	)
+
+	updated.Status.Phase = idpv1alpha1.OAuth2PhaseReady
+	if hadErrorCondition {
+		updated.Status.Phase = idpv1alpha1.OAuth2PhaseError
+	}
+
+	if equality.Semantic.DeepEqual(upstream, updated) {
+		return hadErrorCondition
+	}
+
+	_, err := c.client.
+		IDPV1alpha1().
+		OAuth2IdentityProviders(upstream.Namespace).
+		UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		log.Error("failed to update status", err)
+	}
+
+	return hadErrorCondition
+}
+
+func parseHTTPSURL(rawURL string) (*url.URL, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be %q", "https")
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("host must not be empty")
+	}
+	return parsed, nil
+}
+
+func defaultClientShortTimeout(rootCAs *x509.CertPool) *http.Client {
+	c := phttp.Default(rootCAs)
+	c.Timeout = time.Minute
+	return c
+}
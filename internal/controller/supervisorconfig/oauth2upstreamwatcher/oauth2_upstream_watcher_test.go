@@ -0,0 +1,734 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oauth2upstreamwatcher
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	supervisorinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/celtransformer"
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/federationdomain/dynamicupstreamprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/upstreamoauth2"
+)
+
+func TestOAuth2UpstreamWatcherControllerFilterSecret(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		secret     metav1.Object
+		wantAdd    bool
+		wantUpdate bool
+		wantDelete bool
+	}{
+		{
+			name: "should return true for a secret of the type secrets.pinniped.dev/oauth2-client",
+			secret: &corev1.Secret{
+				Type:       "secrets.pinniped.dev/oauth2-client",
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
+		{
+			name: "should return true for a secret of the type Opaque",
+			secret: &corev1.Secret{
+				Type:       corev1.SecretTypeOpaque,
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
+		{
+			name: "should return true for a secret of the type TLS",
+			secret: &corev1.Secret{
+				Type:       corev1.SecretTypeTLS,
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
+		{
+			name: "should return false for a secret of the wrong type",
+			secret: &corev1.Secret{
+				Type:       "secrets.pinniped.dev/some-other-type",
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+		},
+		{
+			name: "should return false for resource of wrong data type",
+			secret: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakePinnipedClient := supervisorfake.NewSimpleClientset()
+			pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+			fakeKubeClient := fake.NewSimpleClientset()
+			kubeInformers := informers.NewSharedInformerFactory(fakeKubeClient, 0)
+			cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+			secretInformer := kubeInformers.Core().V1().Secrets()
+			configMapInformer := kubeInformers.Core().V1().ConfigMaps()
+			withInformer := testutil.NewObservableWithInformerOption()
+
+			logger, _ := plog.TestLogger(t)
+
+			New(
+				cache,
+				nil,
+				pinnipedInformers.IDP().V1alpha1().OAuth2IdentityProviders(),
+				secretInformer,
+				configMapInformer,
+				logger,
+				withInformer.WithInformer,
+			)
+
+			unrelated := corev1.Secret{}
+			filter := withInformer.GetFilterForInformer(secretInformer)
+			require.Equal(t, test.wantAdd, filter.Add(test.secret))
+			require.Equal(t, test.wantUpdate, filter.Update(&unrelated, test.secret))
+			require.Equal(t, test.wantUpdate, filter.Update(test.secret, &unrelated))
+			require.Equal(t, test.wantDelete, filter.Delete(test.secret))
+		})
+	}
+}
+
+func TestOAuth2UpstreamWatcherControllerSync(t *testing.T) {
+	t.Parallel()
+	now := metav1.NewTime(time.Now().UTC())
+
+	testCA, err := certauthority.New("foo", time.Hour)
+	require.NoError(t, err)
+	testCABase64 := base64.StdEncoding.EncodeToString(testCA.Bundle())
+
+	var (
+		testNamespace   = "test-namespace"
+		testName        = "test-name"
+		testUID         = types.UID("test-uid")
+		testSecretName  = "test-client-secret"
+		testClientID    = "test-oauth2-client-id"
+		testSecret      = "test-oauth2-client-secret"
+		testAuthURL     = "https://oauth2.example.com/oauth/authorize"
+		testTokenURL    = "https://oauth2.example.com/oauth/token"
+		testRevokeURL   = "https://oauth2.example.com/oauth/revoke"
+		testUserInfoURL = "https://oauth2.example.com/api/user"
+		testGroupsURL   = "https://oauth2.example.com/api/groups"
+	)
+
+	testResponses := &celtransformer.UpstreamResponses{
+		UserInfo:       map[string]any{"login": "some-login", "id": int64(42)},
+		GroupsResponse: []any{map[string]any{"path": "group1"}, map[string]any{"path": "group2"}},
+	}
+
+	validClientSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace},
+		Type:       "secrets.pinniped.dev/oauth2-client",
+		Data:       map[string][]byte{"clientID": []byte(testClientID), "clientSecret": []byte(testSecret)},
+	}
+
+	validSpec := func() idpv1alpha1.OAuth2IdentityProviderSpec {
+		return idpv1alpha1.OAuth2IdentityProviderSpec{
+			AuthorizationURL: testAuthURL,
+			TokenURL:         testTokenURL,
+			UserInfoURL:      testUserInfoURL,
+			GroupsURL:        testGroupsURL,
+			RevocationURL:    testRevokeURL,
+			TLS:              &idpv1alpha1.TLSSpec{CertificateAuthorityData: testCABase64},
+			AuthorizationConfig: idpv1alpha1.OAuth2AuthorizationConfig{
+				Scopes: []string{"read_user", "read_api"},
+				AdditionalAuthorizeParameters: []idpv1alpha1.Parameter{
+					{Name: "prompt", Value: "consent"},
+				},
+			},
+			Claims: idpv1alpha1.OAuth2Claims{
+				Username: "userInfo.login",
+				Subject:  "string(userInfo.id)",
+				Groups:   "groupsResponse.map(g, g.path)",
+			},
+			Client: idpv1alpha1.OAuth2Client{SecretName: testSecretName},
+		}
+	}
+
+	happySecretCondition := metav1.Condition{
+		Type: "ClientCredentialsSecretValid", Status: "True", LastTransitionTime: now, Reason: "Success",
+		Message: "loaded client credentials",
+	}
+	happyTLSCondition := metav1.Condition{
+		Type: "TLSConfigurationValid", Status: "True", LastTransitionTime: now, Reason: "Success",
+		Message: "spec.tls is valid: using configured CA bundle",
+	}
+	happyClaimsCondition := metav1.Condition{
+		Type: "ClaimsValid", Status: "True", LastTransitionTime: now, Reason: "Success",
+		Message: "spec.claims are valid",
+	}
+	happyAdditionalAuthorizeParametersCondition := metav1.Condition{
+		Type: "AdditionalAuthorizeParametersValid", Status: "True", LastTransitionTime: now, Reason: "Success",
+		Message: "additionalAuthorizeParameters parameter names are allowed",
+	}
+
+	// wantProvider describes the parts of an upstreamoauth2.ProviderConfig which can be compared directly.
+	type wantProvider struct {
+		config       upstreamoauth2.ProviderConfig
+		wantUsername string
+		wantSubject  string
+		wantGroups   []string
+	}
+
+	happyWantProvider := wantProvider{
+		config: upstreamoauth2.ProviderConfig{
+			Name:                     testName,
+			ResourceUID:              testUID,
+			UserInfoURL:              mustParseURL(t, testUserInfoURL),
+			GroupsURL:                mustParseURL(t, testGroupsURL),
+			RevocationURL:            testRevokeURL,
+			AdditionalAuthcodeParams: map[string]string{"prompt": "consent"},
+			OAuth2Config: &oauth2.Config{
+				ClientID:     testClientID,
+				ClientSecret: testSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:   testAuthURL,
+					TokenURL:  testTokenURL,
+					AuthStyle: oauth2.AuthStyleAutoDetect,
+				},
+				Scopes: []string{"read_user", "read_api"},
+			},
+		},
+		wantUsername: "some-login",
+		wantSubject:  "42",
+		wantGroups:   []string{"group1", "group2"},
+	}
+
+	tests := []struct {
+		name                   string
+		inputUpstreams         []runtime.Object
+		inputResources         []runtime.Object
+		wantErr                string
+		wantResultingCache     []wantProvider
+		wantResultingUpstreams []idpv1alpha1.OAuth2IdentityProvider
+	}{
+		{
+			name: "no upstreams",
+		},
+		{
+			name: "valid upstream",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec:       validSpec(),
+			}},
+			inputResources:     []runtime.Object{validClientSecret},
+			wantResultingCache: []wantProvider{happyWantProvider},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						happyClaimsCondition,
+						happySecretCondition,
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "valid upstream without optional groups URL, revocation URL, groups expression, or subject expression",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.OAuth2IdentityProviderSpec {
+					spec := validSpec()
+					spec.GroupsURL = ""
+					spec.RevocationURL = ""
+					spec.TLS = nil
+					spec.AuthorizationConfig = idpv1alpha1.OAuth2AuthorizationConfig{}
+					spec.Claims.Groups = ""
+					spec.Claims.Subject = ""
+					return spec
+				}(),
+			}},
+			inputResources: []runtime.Object{validClientSecret},
+			wantResultingCache: []wantProvider{{
+				config: upstreamoauth2.ProviderConfig{
+					Name:                     testName,
+					ResourceUID:              testUID,
+					UserInfoURL:              mustParseURL(t, testUserInfoURL),
+					AdditionalAuthcodeParams: map[string]string{},
+					OAuth2Config: &oauth2.Config{
+						ClientID:     testClientID,
+						ClientSecret: testSecret,
+						Endpoint: oauth2.Endpoint{
+							AuthURL:   testAuthURL,
+							TokenURL:  testTokenURL,
+							AuthStyle: oauth2.AuthStyleAutoDetect,
+						},
+					},
+				},
+				wantUsername: "some-login",
+				wantSubject:  "some-login",
+			}},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						happyClaimsCondition,
+						happySecretCondition,
+						{
+							Type: "TLSConfigurationValid", Status: "True", LastTransitionTime: now, Reason: "Success",
+							Message: "spec.tls is valid: no TLS configuration provided: using default root CA bundle from container image",
+						},
+					},
+				},
+			}},
+		},
+		{
+			name: "missing client secret",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec:       validSpec(),
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						happyClaimsCondition,
+						{
+							Type: "ClientCredentialsSecretValid", Status: "False", LastTransitionTime: now, Reason: "SecretNotFound",
+							Message: `secret "test-client-secret" not found`,
+						},
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "client secret has wrong type",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec:       validSpec(),
+			}},
+			inputResources: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace},
+				Type:       "some-other-type",
+				Data:       validClientSecret.Data,
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						happyClaimsCondition,
+						{
+							Type: "ClientCredentialsSecretValid", Status: "False", LastTransitionTime: now, Reason: "SecretWrongType",
+							Message: `referenced Secret "test-client-secret" has wrong type "some-other-type" (should be "secrets.pinniped.dev/oauth2-client")`,
+						},
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "client secret is missing a key",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec:       validSpec(),
+			}},
+			inputResources: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace},
+				Type:       "secrets.pinniped.dev/oauth2-client",
+				Data:       map[string][]byte{"clientID": []byte(testClientID)},
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						happyClaimsCondition,
+						{
+							Type: "ClientCredentialsSecretValid", Status: "False", LastTransitionTime: now, Reason: "SecretMissingKeys",
+							Message: `referenced Secret "test-client-secret" is missing required keys ["clientID" "clientSecret"]`,
+						},
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "invalid TLS configuration",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.OAuth2IdentityProviderSpec {
+					spec := validSpec()
+					spec.TLS = &idpv1alpha1.TLSSpec{CertificateAuthorityData: "invalid-base64"}
+					return spec
+				}(),
+			}},
+			inputResources:     []runtime.Object{validClientSecret},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						happyClaimsCondition,
+						happySecretCondition,
+						{
+							Type: "TLSConfigurationValid", Status: "False", LastTransitionTime: now, Reason: "InvalidTLSConfig",
+							Message: "spec.tls.certificateAuthorityData is invalid: illegal base64 data at input byte 7",
+						},
+					},
+				},
+			}},
+		},
+		{
+			name: "disallowed additional authorize parameters",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.OAuth2IdentityProviderSpec {
+					spec := validSpec()
+					spec.AuthorizationConfig.AdditionalAuthorizeParameters = []idpv1alpha1.Parameter{
+						{Name: "response_type", Value: "foo"},
+						{Name: "prompt", Value: "consent"},
+						{Name: "redirect_uri", Value: "bar"},
+					}
+					return spec
+				}(),
+			}},
+			inputResources:     []runtime.Object{validClientSecret},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						{
+							Type: "AdditionalAuthorizeParametersValid", Status: "False", LastTransitionTime: now, Reason: "DisallowedParameterName",
+							Message: "the following additionalAuthorizeParameters are not allowed: response_type,redirect_uri",
+						},
+						happyClaimsCondition,
+						happySecretCondition,
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "userinfo URL is not https",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.OAuth2IdentityProviderSpec {
+					spec := validSpec()
+					spec.UserInfoURL = "http://oauth2.example.com/api/user"
+					return spec
+				}(),
+			}},
+			inputResources:     []runtime.Object{validClientSecret},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						{
+							Type: "ClaimsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidClaims",
+							Message: `spec.userInfoURL ("http://oauth2.example.com/api/user") is invalid: scheme must be "https"`,
+						},
+						happySecretCondition,
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "groups URL has no host",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.OAuth2IdentityProviderSpec {
+					spec := validSpec()
+					spec.GroupsURL = "https:///api/groups"
+					return spec
+				}(),
+			}},
+			inputResources:     []runtime.Object{validClientSecret},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						{
+							Type: "ClaimsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidClaims",
+							Message: `spec.groupsURL ("https:///api/groups") is invalid: host must not be empty`,
+						},
+						happySecretCondition,
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "username expression does not compile",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.OAuth2IdentityProviderSpec {
+					spec := validSpec()
+					spec.Claims.Username = "this is not valid cel"
+					return spec
+				}(),
+			}},
+			inputResources:     []runtime.Object{validClientSecret},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						{
+							Type: "ClaimsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidClaims",
+							Message: "spec.claims.username is invalid:\n" +
+								"CEL expression compile error: ERROR: <input>:1:6: Syntax error: mismatched input 'is' expecting <EOF>\n" +
+								" | this is not valid cel\n" +
+								" | .....^",
+						},
+						happySecretCondition,
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "subject expression returns the wrong type",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.OAuth2IdentityProviderSpec {
+					spec := validSpec()
+					spec.Claims.Subject = "1 + 2"
+					return spec
+				}(),
+			}},
+			inputResources:     []runtime.Object{validClientSecret},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						{
+							Type: "ClaimsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidClaims",
+							Message: "spec.claims.subject is invalid:\n" +
+								`CEL expression should return type "string" but returns type "int"`,
+						},
+						happySecretCondition,
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "groups expression returns the wrong type",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.OAuth2IdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: func() idpv1alpha1.OAuth2IdentityProviderSpec {
+					spec := validSpec()
+					spec.Claims.Groups = `"not-a-list"`
+					return spec
+				}(),
+			}},
+			inputResources:     []runtime.Object{validClientSecret},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []wantProvider{},
+			wantResultingUpstreams: []idpv1alpha1.OAuth2IdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.OAuth2IdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAdditionalAuthorizeParametersCondition,
+						{
+							Type: "ClaimsValid", Status: "False", LastTransitionTime: now, Reason: "InvalidClaims",
+							Message: "spec.claims.groups is invalid:\n" +
+								`CEL expression should return type "list(string)" but returns type "string"`,
+						},
+						happySecretCondition,
+						happyTLSCondition,
+					},
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fakePinnipedClient := supervisorfake.NewSimpleClientset(tt.inputUpstreams...)
+			pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+			fakeKubeClient := fake.NewSimpleClientset(tt.inputResources...)
+			kubeInformers := informers.NewSharedInformerFactory(fakeKubeClient, 0)
+			cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+			cache.SetOAuth2IdentityProviders([]upstreamprovider.UpstreamOAuth2IdentityProviderI{
+				upstreamoauth2.New(upstreamoauth2.ProviderConfig{Name: "initial-entry"}),
+			})
+
+			logger, _ := plog.TestLogger(t)
+
+			controller := New(
+				cache,
+				fakePinnipedClient,
+				pinnipedInformers.IDP().V1alpha1().OAuth2IdentityProviders(),
+				kubeInformers.Core().V1().Secrets(),
+				kubeInformers.Core().V1().ConfigMaps(),
+				logger,
+				controllerlib.WithInformer,
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			pinnipedInformers.Start(ctx.Done())
+			kubeInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			actualIDPList := cache.GetOAuth2IdentityProviders()
+			require.Equal(t, len(tt.wantResultingCache), len(actualIDPList))
+			for i := range actualIDPList {
+				actualConfig := actualIDPList[i].(*upstreamoauth2.Provider).GetConfig()
+				want := tt.wantResultingCache[i]
+
+				// The HTTP client and compiled expressions cannot be compared directly, so check them separately.
+				require.NotNil(t, actualConfig.HttpClient)
+				require.NotNil(t, actualConfig.Claims)
+				requireCompiledClaims(t, actualConfig.Claims, testResponses, want.wantUsername, want.wantSubject, want.wantGroups)
+				actualConfig.HttpClient = nil
+				actualConfig.Claims = nil
+
+				require.Equal(t, want.config, actualConfig)
+			}
+
+			actualUpstreams, err := fakePinnipedClient.IDPV1alpha1().OAuth2IdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+
+			// Assert on the expected Status of the upstreams. Preprocess the upstreams a bit so that they're easier to assert against.
+			require.ElementsMatch(t, tt.wantResultingUpstreams, normalizeOAuth2Upstreams(actualUpstreams.Items, now))
+
+			// Running the sync() a second time should be idempotent, and should return the same error.
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			actualUpstreams, err = fakePinnipedClient.IDPV1alpha1().OAuth2IdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.wantResultingUpstreams, normalizeOAuth2Upstreams(actualUpstreams.Items, now))
+		})
+	}
+}
+
+func requireCompiledClaims(
+	t *testing.T,
+	claims *upstreamoauth2.CompiledClaims,
+	responses *celtransformer.UpstreamResponses,
+	wantUsername, wantSubject string,
+	wantGroups []string,
+) {
+	t.Helper()
+	ctx := context.Background()
+
+	username, err := claims.Username.Evaluate(ctx, responses)
+	require.NoError(t, err)
+	require.Equal(t, wantUsername, username)
+
+	subject, err := claims.Subject.Evaluate(ctx, responses)
+	require.NoError(t, err)
+	require.Equal(t, wantSubject, subject)
+
+	if wantGroups == nil {
+		require.Nil(t, claims.Groups)
+		return
+	}
+	groups, err := claims.Groups.Evaluate(ctx, responses)
+	require.NoError(t, err)
+	require.Equal(t, wantGroups, groups)
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return parsed
+}
+
+func normalizeOAuth2Upstreams(upstreams []idpv1alpha1.OAuth2IdentityProvider, now metav1.Time) []idpv1alpha1.OAuth2IdentityProvider {
+	result := make([]idpv1alpha1.OAuth2IdentityProvider, 0, len(upstreams))
+	for _, u := range upstreams {
+		normalized := u.DeepCopy()
+
+		// We're only interested in comparing the status, so zero out the spec.
+		normalized.Spec = idpv1alpha1.OAuth2IdentityProviderSpec{}
+
+		// Round down the LastTransitionTime values to `now` if they were just updated. This makes
+		// it much easier to encode assertions about the expected timestamps.
+		for i := range normalized.Status.Conditions {
+			if time.Since(normalized.Status.Conditions[i].LastTransitionTime.Time) < 5*time.Second {
+				normalized.Status.Conditions[i].LastTransitionTime = now
+			}
+		}
+		result = append(result, *normalized)
+	}
+
+	return result
+}
@@ -1,14 +1,20 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidcclientwatcher
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -19,6 +25,7 @@ import (
 	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
 	supervisorinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/certauthority"
 	"go.pinniped.dev/internal/controllerlib"
 	"go.pinniped.dev/internal/testutil"
 )
@@ -169,6 +176,18 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 	now := metav1.NewTime(time.Now().UTC())
 	earlier := metav1.NewTime(now.Add(-1 * time.Hour).UTC())
 
+	clientAssertionKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwksBytes, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: clientAssertionKey.Public(), KeyID: "some-key-id", Algorithm: "ES256", Use: "sig"},
+	}})
+	require.NoError(t, err)
+	validJWKS := string(jwksBytes)
+
+	testCA, err := certauthority.New("Test CA", time.Hour)
+	require.NoError(t, err)
+	validCAData := base64.StdEncoding.EncodeToString(testCA.Bundle())
+
 	happyAllowedGrantTypesCondition := func(time metav1.Time, observedGeneration int64) metav1.Condition {
 		return metav1.Condition{
 			Type:               "AllowedGrantTypesValid",
@@ -202,6 +221,17 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 		}
 	}
 
+	happyClientSecretsNotRequiredCondition := func(method string, time metav1.Time, observedGeneration int64) metav1.Condition {
+		return metav1.Condition{
+			Type:               "ClientSecretExists",
+			Status:             "True",
+			LastTransitionTime: time,
+			Reason:             "Success",
+			Message:            fmt.Sprintf(`client secrets are not required when "clientAuthentication.method" is %q`, method),
+			ObservedGeneration: observedGeneration,
+		}
+	}
+
 	sadNoClientSecretsCondition := func(time metav1.Time, observedGeneration int64, message string) metav1.Condition {
 		return metav1.Condition{
 			Type:               "ClientSecretExists",
@@ -246,6 +276,28 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 		}
 	}
 
+	happyClientAuthenticationCondition := func(time metav1.Time, observedGeneration int64) metav1.Condition {
+		return metav1.Condition{
+			Type:               "ClientAuthenticationValid",
+			Status:             "True",
+			LastTransitionTime: time,
+			Reason:             "Success",
+			Message:            `"clientAuthentication" is valid`,
+			ObservedGeneration: observedGeneration,
+		}
+	}
+
+	sadClientAuthenticationCondition := func(time metav1.Time, observedGeneration int64, message string) metav1.Condition {
+		return metav1.Condition{
+			Type:               "ClientAuthenticationValid",
+			Status:             "False",
+			LastTransitionTime: time,
+			Reason:             "InvalidClientAuthentication",
+			Message:            message,
+			ObservedGeneration: observedGeneration,
+		}
+	}
+
 	tests := []struct {
 		name                     string
 		inputObjects             []runtime.Object
@@ -295,6 +347,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 						Conditions: []metav1.Condition{
 							happyAllowedGrantTypesCondition(now, 1234),
 							happyAllowedScopesCondition(now, 1234),
+							happyClientAuthenticationCondition(now, 1234),
 							happyClientSecretsCondition(1, now, 1234),
 						},
 						TotalClientSecrets: 1,
@@ -320,6 +373,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(2, now, 1234),
 					},
 					TotalClientSecrets: 2,
@@ -339,6 +393,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(earlier, 1234),
 						happyAllowedScopesCondition(earlier, 1234),
+						happyClientAuthenticationCondition(earlier, 1234),
 						happyClientSecretsCondition(1, earlier, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -353,6 +408,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(earlier, 1234),
 						happyAllowedScopesCondition(earlier, 1234),
+						happyClientAuthenticationCondition(earlier, 1234),
 						happyClientSecretsCondition(1, earlier, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -373,6 +429,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						sadAllowedGrantTypesCondition(now, 1234, `"authorization_code" must always be included in "allowedGrantTypes"`),
 						sadAllowedScopesCondition(now, 1234, `"openid" must always be included in "allowedScopes"`),
+						happyClientAuthenticationCondition(now, 1234),
 						sadNoClientSecretsCondition(now, 1234, "no client secret found (no Secret storage found)"),
 					},
 				},
@@ -396,6 +453,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						sadNoClientSecretsCondition(now, 1234, "error reading client secret storage: OIDC client secret storage data has wrong version: OIDC client secret storage has version wrong-version instead of 1"),
 					},
 				},
@@ -419,6 +477,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						sadNoClientSecretsCondition(now, 1234, "no client secret found (empty list in storage)"),
 					},
 					TotalClientSecrets: 0,
@@ -446,6 +505,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						sadInvalidClientSecretsCondition(now, 1234,
 							"3 stored client secrets found, but some were invalid, so none will be used: "+
 								"hashed client secret at index 1: bcrypt cost 11 is below the required minimum of 12; "+
@@ -480,6 +540,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 						Conditions: []metav1.Condition{
 							happyAllowedGrantTypesCondition(now, 1234),
 							happyAllowedScopesCondition(now, 1234),
+							happyClientAuthenticationCondition(now, 1234),
 							happyClientSecretsCondition(1, now, 1234),
 						},
 						TotalClientSecrets: 1,
@@ -492,6 +553,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 						Conditions: []metav1.Condition{
 							sadAllowedGrantTypesCondition(now, 4567, `"authorization_code" must always be included in "allowedGrantTypes"`),
 							sadAllowedScopesCondition(now, 4567, `"openid" must always be included in "allowedScopes"`),
+							happyClientAuthenticationCondition(now, 4567),
 							sadNoClientSecretsCondition(now, 4567, "no client secret found (no Secret storage found)"),
 						},
 						TotalClientSecrets: 0,
@@ -513,6 +575,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						sadAllowedGrantTypesCondition(earlier, 1234, `"authorization_code" must always be included in "allowedGrantTypes"`),
 						sadAllowedScopesCondition(earlier, 1234, `"openid" must always be included in "allowedScopes"`),
+						happyClientAuthenticationCondition(earlier, 1234),
 						happyClientSecretsCondition(1, earlier, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -528,6 +591,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 4567),
 						happyAllowedScopesCondition(now, 4567),
+						happyClientAuthenticationCondition(earlier, 4567),
 						happyClientSecretsCondition(1, earlier, 4567), // was already validated earlier
 					},
 					TotalClientSecrets: 1,
@@ -552,6 +616,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						sadAllowedGrantTypesCondition(now, 1234, `"refresh_token" must be included in "allowedGrantTypes" when "offline_access" is included in "allowedScopes"`),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -581,6 +646,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 							`"openid" must always be included in "allowedScopes"; `+
 								`"offline_access" must be included in "allowedScopes" when "refresh_token" is included in "allowedGrantTypes"; `+
 								`"username" and "groups" must be included in "allowedScopes" when "pinniped:request-audience" is included in "allowedScopes"`),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -609,6 +675,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 						sadAllowedScopesCondition(now, 1234,
 							`"openid" must always be included in "allowedScopes"; `+
 								`"pinniped:request-audience" must be included in "allowedScopes" when "urn:ietf:params:oauth:grant-type:token-exchange" is included in "allowedGrantTypes"`),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -633,6 +700,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						sadAllowedGrantTypesCondition(now, 1234, `"urn:ietf:params:oauth:grant-type:token-exchange" must be included in "allowedGrantTypes" when "pinniped:request-audience" is included in "allowedScopes"`),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -657,6 +725,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						sadAllowedScopesCondition(now, 1234, `"offline_access" must be included in "allowedScopes" when "refresh_token" is included in "allowedGrantTypes"`),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -681,6 +750,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						sadAllowedScopesCondition(now, 1234, `"username" and "groups" must be included in "allowedScopes" when "pinniped:request-audience" is included in "allowedScopes"`),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -705,6 +775,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						sadAllowedScopesCondition(now, 1234, `"username" and "groups" must be included in "allowedScopes" when "pinniped:request-audience" is included in "allowedScopes"`),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -729,6 +800,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						sadAllowedScopesCondition(now, 1234, `"username" and "groups" must be included in "allowedScopes" when "pinniped:request-audience" is included in "allowedScopes"`),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -753,6 +825,87 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						sadAllowedScopesCondition(now, 1234, `"pinniped:request-audience" must be included in "allowedScopes" when "urn:ietf:params:oauth:grant-type:token-exchange" is included in "allowedGrantTypes"`),
+						happyClientAuthenticationCondition(now, 1234),
+						happyClientSecretsCondition(1, now, 1234),
+					},
+					TotalClientSecrets: 1,
+				},
+			}},
+		},
+		{
+			name: "serviceIdentity must be configured when client_credentials is included in allowedGrantTypes",
+			inputObjects: []runtime.Object{&supervisorconfigv1alpha1.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+					AllowedGrantTypes: []supervisorconfigv1alpha1.GrantType{"authorization_code", "client_credentials"},
+					AllowedScopes:     []supervisorconfigv1alpha1.Scope{"openid"},
+				},
+			}},
+			inputSecrets:   []runtime.Object{testutil.OIDCClientSecretStorageSecretForUID(t, testNamespace, testUID, []string{testutil.HashedPassword1AtSupervisorMinCost})},
+			wantAPIActions: 1, // one update
+			wantResultingOIDCClients: []supervisorconfigv1alpha1.OIDCClient{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Status: supervisorconfigv1alpha1.OIDCClientStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						sadAllowedGrantTypesCondition(now, 1234, `"serviceIdentity" must be configured when "client_credentials" is included in "allowedGrantTypes"`),
+						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
+						happyClientSecretsCondition(1, now, 1234),
+					},
+					TotalClientSecrets: 1,
+				},
+			}},
+		},
+		{
+			name: "client_credentials must be included in allowedGrantTypes when serviceIdentity is configured",
+			inputObjects: []runtime.Object{&supervisorconfigv1alpha1.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+					AllowedGrantTypes: []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+					AllowedScopes:     []supervisorconfigv1alpha1.Scope{"openid"},
+					ServiceIdentity:   &supervisorconfigv1alpha1.OIDCClientServiceIdentity{Username: "some-service-username"},
+				},
+			}},
+			inputSecrets:   []runtime.Object{testutil.OIDCClientSecretStorageSecretForUID(t, testNamespace, testUID, []string{testutil.HashedPassword1AtSupervisorMinCost})},
+			wantAPIActions: 1, // one update
+			wantResultingOIDCClients: []supervisorconfigv1alpha1.OIDCClient{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Status: supervisorconfigv1alpha1.OIDCClientStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						sadAllowedGrantTypesCondition(now, 1234, `"client_credentials" must be included in "allowedGrantTypes" when "serviceIdentity" is configured`),
+						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
+						happyClientSecretsCondition(1, now, 1234),
+					},
+					TotalClientSecrets: 1,
+				},
+			}},
+		},
+		{
+			name: "successfully validate an OIDCClient which uses client_credentials with a serviceIdentity",
+			inputObjects: []runtime.Object{&supervisorconfigv1alpha1.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+					AllowedGrantTypes: []supervisorconfigv1alpha1.GrantType{"authorization_code", "client_credentials", "urn:ietf:params:oauth:grant-type:token-exchange"},
+					AllowedScopes:     []supervisorconfigv1alpha1.Scope{"openid", "pinniped:request-audience", "username", "groups"},
+					ServiceIdentity: &supervisorconfigv1alpha1.OIDCClientServiceIdentity{
+						Username: "some-service-username",
+						Groups:   []string{"some-service-group"},
+					},
+				},
+			}},
+			inputSecrets:   []runtime.Object{testutil.OIDCClientSecretStorageSecretForUID(t, testNamespace, testUID, []string{testutil.HashedPassword1AtSupervisorMinCost})},
+			wantAPIActions: 1, // one update
+			wantResultingOIDCClients: []supervisorconfigv1alpha1.OIDCClient{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Status: supervisorconfigv1alpha1.OIDCClientStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						happyAllowedGrantTypesCondition(now, 1234),
+						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -777,6 +930,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -801,6 +955,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -825,6 +980,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -849,6 +1005,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -873,6 +1030,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -897,6 +1055,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -921,6 +1080,7 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
@@ -945,12 +1105,137 @@ func TestOIDCClientWatcherControllerSync(t *testing.T) {
 					Conditions: []metav1.Condition{
 						happyAllowedGrantTypesCondition(now, 1234),
 						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
 						happyClientSecretsCondition(1, now, 1234),
 					},
 					TotalClientSecrets: 1,
 				},
 			}},
 		},
+		{
+			name: "private_key_jwt client with an inline JWKS is valid without any client secrets",
+			inputObjects: []runtime.Object{&supervisorconfigv1alpha1.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+					AllowedGrantTypes: []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+					AllowedScopes:     []supervisorconfigv1alpha1.Scope{"openid"},
+					ClientAuthentication: &supervisorconfigv1alpha1.OIDCClientAuthentication{
+						Method:        "private_key_jwt",
+						PrivateKeyJWT: &supervisorconfigv1alpha1.OIDCClientPrivateKeyJWT{JWKS: validJWKS, SigningAlgorithm: "ES256"},
+					},
+				},
+			}},
+			wantAPIActions: 1, // one update
+			wantResultingOIDCClients: []supervisorconfigv1alpha1.OIDCClient{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Status: supervisorconfigv1alpha1.OIDCClientStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						happyAllowedGrantTypesCondition(now, 1234),
+						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
+						happyClientSecretsNotRequiredCondition("private_key_jwt", now, 1234),
+					},
+					TotalClientSecrets: 0,
+				},
+			}},
+		},
+		{
+			name: "tls_client_auth client with a CA bundle is valid without any client secrets",
+			inputObjects: []runtime.Object{&supervisorconfigv1alpha1.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+					AllowedGrantTypes: []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+					AllowedScopes:     []supervisorconfigv1alpha1.Scope{"openid"},
+					ClientAuthentication: &supervisorconfigv1alpha1.OIDCClientAuthentication{
+						Method: "tls_client_auth",
+						TLSClientAuth: &supervisorconfigv1alpha1.OIDCClientTLSClientAuth{
+							CertificateAuthorityData: validCAData,
+							SubjectDN:                "CN=some-client",
+						},
+					},
+				},
+			}},
+			wantAPIActions: 1, // one update
+			wantResultingOIDCClients: []supervisorconfigv1alpha1.OIDCClient{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Status: supervisorconfigv1alpha1.OIDCClientStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						happyAllowedGrantTypesCondition(now, 1234),
+						happyAllowedScopesCondition(now, 1234),
+						happyClientAuthenticationCondition(now, 1234),
+						happyClientSecretsNotRequiredCondition("tls_client_auth", now, 1234),
+					},
+					TotalClientSecrets: 0,
+				},
+			}},
+		},
+		{
+			name: "invalid clientAuthentication settings",
+			inputObjects: []runtime.Object{&supervisorconfigv1alpha1.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+					AllowedGrantTypes: []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+					AllowedScopes:     []supervisorconfigv1alpha1.Scope{"openid"},
+					ClientAuthentication: &supervisorconfigv1alpha1.OIDCClientAuthentication{
+						Method: "tls_client_auth",
+						PrivateKeyJWT: &supervisorconfigv1alpha1.OIDCClientPrivateKeyJWT{
+							JWKSURI: "https://example.com/jwks.json",
+						},
+						TLSClientAuth: &supervisorconfigv1alpha1.OIDCClientTLSClientAuth{
+							CertificateAuthorityData: "this is not base64",
+							SubjectDN:                "CN=some-client",
+						},
+					},
+				},
+			}},
+			wantAPIActions: 1, // one update
+			wantResultingOIDCClients: []supervisorconfigv1alpha1.OIDCClient{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Status: supervisorconfigv1alpha1.OIDCClientStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAllowedGrantTypesCondition(now, 1234),
+						happyAllowedScopesCondition(now, 1234),
+						sadClientAuthenticationCondition(now, 1234,
+							`"clientAuthentication.privateKeyJWT" must not be configured when "clientAuthentication.method" is "tls_client_auth"; `+
+								`"clientAuthentication.tlsClientAuth.certificateAuthorityData" is invalid: illegal base64 data at input byte 4`),
+						happyClientSecretsNotRequiredCondition("tls_client_auth", now, 1234),
+					},
+					TotalClientSecrets: 0,
+				},
+			}},
+		},
+		{
+			name: "private_key_jwt client without a JWKS",
+			inputObjects: []runtime.Object{&supervisorconfigv1alpha1.OIDCClient{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+					AllowedGrantTypes: []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+					AllowedScopes:     []supervisorconfigv1alpha1.Scope{"openid"},
+					ClientAuthentication: &supervisorconfigv1alpha1.OIDCClientAuthentication{
+						Method:        "private_key_jwt",
+						PrivateKeyJWT: &supervisorconfigv1alpha1.OIDCClientPrivateKeyJWT{},
+					},
+				},
+			}},
+			wantAPIActions: 1, // one update
+			wantResultingOIDCClients: []supervisorconfigv1alpha1.OIDCClient{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+				Status: supervisorconfigv1alpha1.OIDCClientStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						happyAllowedGrantTypesCondition(now, 1234),
+						happyAllowedScopesCondition(now, 1234),
+						sadClientAuthenticationCondition(now, 1234,
+							`exactly one of "clientAuthentication.privateKeyJWT.jwks" or "clientAuthentication.privateKeyJWT.jwksURI" must be configured`),
+						happyClientSecretsNotRequiredCondition("private_key_jwt", now, 1234),
+					},
+					TotalClientSecrets: 0,
+				},
+			}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1,15 +1,17 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package oidcupstreamwatcher implements a controller which watches OIDCIdentityProviders.
 package oidcupstreamwatcher
 
 import (
+	"cmp"
 	"context"
 	"crypto/x509"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +24,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/cache"
 	"k8s.io/apimachinery/pkg/util/sets"
 	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/utils/ptr"
 
 	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
 	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
@@ -47,6 +50,8 @@ const (
 
 	clientIDDataKey     = "clientID"
 	clientSecretDataKey = "clientSecret"
+	privateKeyDataKey   = "privateKey"
+	privateKeyIDDataKey = "privateKeyID"
 
 	// Constants related to the OIDC provider discovery cache. These do not affect the cache of JWKS.
 	oidcValidatorCacheTTL = 15 * time.Minute
@@ -56,10 +61,17 @@ const (
 	typeAdditionalAuthorizeParametersValid = "AdditionalAuthorizeParametersValid"
 	typeOIDCDiscoverySucceeded             = "OIDCDiscoverySucceeded"
 
-	reasonUnreachable             = "Unreachable"
-	reasonInvalidResponse         = "InvalidResponse"
-	reasonDisallowedParameterName = "DisallowedParameterName"
-	allParamNamesAllowedMsg       = "additionalAuthorizeParameters parameter names are allowed"
+	reasonUnreachable              = "Unreachable"
+	reasonInvalidResponse          = "InvalidResponse"
+	reasonDisallowedParameterName  = "DisallowedParameterName"
+	reasonInvalidClientCredentials = "InvalidClientCredentials"
+	allParamNamesAllowedMsg        = "additionalAuthorizeParameters parameter names are allowed"
+
+	// Defaults for the groups endpoint, which match the Microsoft Graph API.
+	defaultGroupsClaimForGroupsEndpoint = "groups"
+	defaultGroupsEndpointItemsField     = "value"
+	defaultGroupsEndpointGroupNameField = "displayName"
+	defaultGroupsEndpointNextPageField  = "@odata.nextLink"
 )
 
 var (
@@ -91,8 +103,9 @@ type UpstreamOIDCIdentityProviderICache interface {
 
 // oidcDiscoveryCacheKey is the type of keys in an oidcDiscoveryCache.
 type oidcDiscoveryCacheKey struct {
-	issuer       string
-	caBundleHash tlsconfigutil.CABundleHash
+	issuer                string
+	caBundleHash          tlsconfigutil.CABundleHash
+	clientCertificateHash tlsconfigutil.ClientCertificateHash
 }
 
 // oidcDiscoveryCacheValue is the type of cache entries in an oidcDiscoveryCache.
@@ -223,6 +236,13 @@ func (c *oidcWatcherController) validateUpstream(ctx controllerlib.Context, upst
 			additionalAuthcodeAuthorizeParameters[p.Name] = p.Value
 		}
 	}
+	// The login policy settings take precedence over the same parameters in additionalAuthorizeParameters.
+	if len(authorizationConfig.ACRValues) > 0 {
+		additionalAuthcodeAuthorizeParameters["acr_values"] = strings.Join(authorizationConfig.ACRValues, " ")
+	}
+	if authorizationConfig.MaxAge != nil {
+		additionalAuthcodeAuthorizeParameters["max_age"] = strconv.FormatInt(*authorizationConfig.MaxAge, 10)
+	}
 
 	result := upstreamoidc.ProviderConfig{
 		Name: upstream.Name,
@@ -234,13 +254,31 @@ func (c *oidcWatcherController) validateUpstream(ctx controllerlib.Context, upst
 		AllowPasswordGrant:       authorizationConfig.AllowPasswordGrant,
 		AdditionalAuthcodeParams: additionalAuthcodeAuthorizeParameters,
 		AdditionalClaimMappings:  upstream.Spec.Claims.AdditionalClaimMappings,
+		LoginPolicy:              computeLoginPolicy(authorizationConfig),
 		ResourceUID:              upstream.UID,
 	}
 
-	conditions := []*metav1.Condition{
-		c.validateSecret(upstream, &result),
+	if groupsEndpoint := upstream.Spec.Claims.GroupsEndpoint; groupsEndpoint != nil {
+		result.GroupsEndpoint = &upstreamoidc.GroupsEndpointConfig{
+			URL:            groupsEndpoint.URL,
+			ItemsField:     ptr.Deref(groupsEndpoint.ItemsField, defaultGroupsEndpointItemsField),
+			GroupNameField: cmp.Or(groupsEndpoint.GroupNameField, defaultGroupsEndpointGroupNameField),
+			NextPageField:  cmp.Or(groupsEndpoint.NextPageField, defaultGroupsEndpointNextPageField),
+		}
+		result.GroupsClaim = cmp.Or(result.GroupsClaim, defaultGroupsClaimForGroupsEndpoint)
+	}
+
+	secretCondition, clientCertificate := c.validateSecret(upstream, &result)
+	conditions := []*metav1.Condition{secretCondition}
+	conditions = append(conditions, c.validateIssuer(ctx.Context, upstream, clientCertificate, &result)...)
+
+	switch upstream.Spec.Client.AuthenticationMethod {
+	case idpv1alpha1.OIDCClientAuthenticationMethodPrivateKeyJWT,
+		idpv1alpha1.OIDCClientAuthenticationMethodTLSClientAuth,
+		idpv1alpha1.OIDCClientAuthenticationMethodNone:
+		// There is no client secret, so only send the client ID in the request params.
+		result.Config.Endpoint.AuthStyle = oauth2.AuthStyleInParams
 	}
-	conditions = append(conditions, c.validateIssuer(ctx.Context, upstream, &result)...)
 
 	if len(rejectedAuthcodeAuthorizeParameters) > 0 {
 		conditions = append(conditions, &metav1.Condition{
@@ -267,9 +305,17 @@ func (c *oidcWatcherController) validateUpstream(ctx controllerlib.Context, upst
 	return &result
 }
 
-// validateSecret validates the .spec.client.secretName field and returns the appropriate ClientCredentialsSecretValid condition.
-func (c *oidcWatcherController) validateSecret(upstream *idpv1alpha1.OIDCIdentityProvider, result *upstreamoidc.ProviderConfig) *metav1.Condition {
+// validateSecret validates the .spec.client.secretName and .spec.client.certificateSecretName fields and returns
+// the appropriate ClientCredentialsSecretValid condition, along with the client certificate, if there is one.
+func (c *oidcWatcherController) validateSecret(
+	upstream *idpv1alpha1.OIDCIdentityProvider,
+	result *upstreamoidc.ProviderConfig,
+) (*metav1.Condition, *tlsconfigutil.ClientCertificate) {
 	secretName := upstream.Spec.Client.SecretName
+	authenticationMethod := cmp.Or(upstream.Spec.Client.AuthenticationMethod, idpv1alpha1.OIDCClientAuthenticationMethodClientSecret)
+	// Signed authorization requests are signed using the same private key as the client assertions.
+	signsAuthorizationRequests := upstream.Spec.AuthorizationConfig.RequestMode == idpv1alpha1.OIDCAuthorizationRequestModeJAR
+	needsPrivateKey := authenticationMethod == idpv1alpha1.OIDCClientAuthenticationMethodPrivateKeyJWT || signsAuthorizationRequests
 
 	// Fetch the Secret from informer cache.
 	secret, err := c.secretInformer.Lister().Secrets(upstream.Namespace).Get(secretName)
@@ -279,7 +325,7 @@ func (c *oidcWatcherController) validateSecret(upstream *idpv1alpha1.OIDCIdentit
 			Status:  metav1.ConditionFalse,
 			Reason:  upstreamwatchers.ReasonNotFound,
 			Message: err.Error(),
-		}
+		}, nil
 	}
 
 	// Validate the secret .type field.
@@ -289,34 +335,92 @@ func (c *oidcWatcherController) validateSecret(upstream *idpv1alpha1.OIDCIdentit
 			Status:  metav1.ConditionFalse,
 			Reason:  upstreamwatchers.ReasonWrongType,
 			Message: fmt.Sprintf("referenced Secret %q has wrong type %q (should be %q)", secretName, secret.Type, oidcClientSecretType),
+		}, nil
+	}
+
+	// Validate the secret .data field. The required keys depend on the client authentication method
+	// and on the authorization request mode.
+	requiredKeys := []string{clientIDDataKey}
+	if authenticationMethod == idpv1alpha1.OIDCClientAuthenticationMethodClientSecret {
+		requiredKeys = append(requiredKeys, clientSecretDataKey)
+	}
+	if needsPrivateKey {
+		requiredKeys = append(requiredKeys, privateKeyDataKey)
+	}
+	for _, key := range requiredKeys {
+		if len(secret.Data[key]) == 0 {
+			return &metav1.Condition{
+				Type:    typeClientCredentialsSecretValid,
+				Status:  metav1.ConditionFalse,
+				Reason:  upstreamwatchers.ReasonMissingKeys,
+				Message: fmt.Sprintf("referenced Secret %q is missing required keys %q", secretName, requiredKeys),
+			}, nil
+		}
+	}
+
+	var clientAssertionSigner *upstreamoidc.ClientAssertionSigner
+	if needsPrivateKey {
+		clientAssertionSigner, err = upstreamoidc.NewClientAssertionSigner(
+			secret.Data[privateKeyDataKey], string(secret.Data[privateKeyIDDataKey]))
+		if err != nil {
+			return &metav1.Condition{
+				Type:    typeClientCredentialsSecretValid,
+				Status:  metav1.ConditionFalse,
+				Reason:  reasonInvalidClientCredentials,
+				Message: fmt.Sprintf("referenced Secret %q has invalid value for key %q: %s", secretName, privateKeyDataKey, err.Error()),
+			}, nil
 		}
 	}
 
-	// Validate the secret .data field.
-	clientID := secret.Data[clientIDDataKey]
-	clientSecret := secret.Data[clientSecretDataKey]
-	if len(clientID) == 0 || len(clientSecret) == 0 {
+	// Load the client certificate, when there is one.
+	certificateSecretName := upstream.Spec.Client.CertificateSecretName
+	if certificateSecretName == "" && authenticationMethod == idpv1alpha1.OIDCClientAuthenticationMethodTLSClientAuth {
 		return &metav1.Condition{
 			Type:    typeClientCredentialsSecretValid,
 			Status:  metav1.ConditionFalse,
-			Reason:  upstreamwatchers.ReasonMissingKeys,
-			Message: fmt.Sprintf("referenced Secret %q is missing required keys %q", secretName, []string{clientIDDataKey, clientSecretDataKey}),
+			Reason:  reasonInvalidClientCredentials,
+			Message: fmt.Sprintf("spec.client.certificateSecretName is required when spec.client.authenticationMethod is %q", authenticationMethod),
+		}, nil
+	}
+	var clientCertificate *tlsconfigutil.ClientCertificate
+	if certificateSecretName != "" {
+		clientCertificate, err = tlsconfigutil.ReadClientCertificate(upstream.Namespace, certificateSecretName, c.secretInformer)
+		if err != nil {
+			return &metav1.Condition{
+				Type:    typeClientCredentialsSecretValid,
+				Status:  metav1.ConditionFalse,
+				Reason:  reasonInvalidClientCredentials,
+				Message: fmt.Sprintf("spec.client.certificateSecretName is invalid: %s", err.Error()),
+			}, nil
 		}
 	}
 
 	// If everything is valid, update the result and set the condition to true.
-	result.Config.ClientID = string(clientID)
-	result.Config.ClientSecret = string(clientSecret)
+	result.Config.ClientID = string(secret.Data[clientIDDataKey])
+	if authenticationMethod == idpv1alpha1.OIDCClientAuthenticationMethodClientSecret {
+		result.Config.ClientSecret = string(secret.Data[clientSecretDataKey])
+	}
+	if authenticationMethod == idpv1alpha1.OIDCClientAuthenticationMethodPrivateKeyJWT {
+		result.ClientAssertionSigner = clientAssertionSigner
+	}
+	if signsAuthorizationRequests {
+		result.RequestObjectSigner = clientAssertionSigner
+	}
 	return &metav1.Condition{
 		Type:    typeClientCredentialsSecretValid,
 		Status:  metav1.ConditionTrue,
 		Reason:  conditionsutil.ReasonSuccess,
 		Message: "loaded client credentials",
-	}
+	}, clientCertificate
 }
 
 // validateIssuer validates the .spec.issuer field, performs OIDC discovery, and returns the appropriate OIDCDiscoverySucceeded condition.
-func (c *oidcWatcherController) validateIssuer(ctx context.Context, upstream *idpv1alpha1.OIDCIdentityProvider, result *upstreamoidc.ProviderConfig) []*metav1.Condition {
+func (c *oidcWatcherController) validateIssuer(
+	ctx context.Context,
+	upstream *idpv1alpha1.OIDCIdentityProvider,
+	clientCertificate *tlsconfigutil.ClientCertificate,
+	result *upstreamoidc.ProviderConfig,
+) []*metav1.Condition {
 	tlsCondition, caBundle := tlsconfigutil.ValidateTLSConfig(
 		tlsconfigutil.TLSSpecForSupervisor(upstream.Spec.TLS),
 		"spec.tls",
@@ -342,8 +446,9 @@ func (c *oidcWatcherController) validateIssuer(ctx context.Context, upstream *id
 
 	// Get the discovered provider and HTTP client from cache, if they are found in the cache.
 	cacheKey := oidcDiscoveryCacheKey{
-		issuer:       upstream.Spec.Issuer,
-		caBundleHash: caBundle.Hash(),
+		issuer:                upstream.Spec.Issuer,
+		caBundleHash:          caBundle.Hash(),
+		clientCertificateHash: clientCertificate.Hash(),
 	}
 	if cacheEntry := c.validatorCache.getProvider(cacheKey); cacheEntry != nil {
 		discoveredProvider = cacheEntry.provider
@@ -357,7 +462,7 @@ func (c *oidcWatcherController) validateIssuer(ctx context.Context, upstream *id
 
 	// If the provider does not exist in the cache, do a fresh discovery lookup and save to the cache.
 	if discoveredProvider == nil {
-		httpClient = defaultClientShortTimeout(caBundle.CertPool())
+		httpClient = defaultClientShortTimeout(caBundle.CertPool(), clientCertificate)
 
 		_, issuerURLCondition := validateHTTPSURL(upstream.Spec.Issuer, "issuer", reasonUnreachable)
 		if issuerURLCondition != nil {
@@ -388,10 +493,13 @@ func (c *oidcWatcherController) validateIssuer(ctx context.Context, upstream *id
 		c.validatorCache.putProvider(cacheKey, &oidcDiscoveryCacheValue{provider: discoveredProvider, client: httpClient})
 	}
 
-	// Get the revocation endpoint, if there is one. Many providers do not offer a revocation endpoint.
+	// Get the revocation endpoint and the pushed authorization request endpoint, if there are any.
+	// Many providers do not offer these endpoints.
 	var additionalDiscoveryClaims struct {
 		// "revocation_endpoint" is specified by https://datatracker.ietf.org/doc/html/rfc8414#section-2
 		RevocationEndpoint string `json:"revocation_endpoint"`
+		// "pushed_authorization_request_endpoint" is specified by https://datatracker.ietf.org/doc/html/rfc9126#section-5
+		PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint"`
 	}
 	if err := discoveredProvider.Claims(&additionalDiscoveryClaims); err != nil {
 		// This shouldn't actually happen because the above call to NewProvider() would have already returned this error.
@@ -419,6 +527,31 @@ func (c *oidcWatcherController) validateIssuer(ctx context.Context, upstream *id
 		result.RevocationURL = revocationURL
 	}
 
+	if upstream.Spec.AuthorizationConfig.RequestMode == idpv1alpha1.OIDCAuthorizationRequestModePAR {
+		// Pushed authorization requests require the provider to advertise its pushed authorization request endpoint.
+		if additionalDiscoveryClaims.PushedAuthorizationRequestEndpoint == "" {
+			return []*metav1.Condition{
+				{
+					Type:   typeOIDCDiscoverySucceeded,
+					Status: metav1.ConditionFalse,
+					Reason: reasonInvalidResponse,
+					Message: fmt.Sprintf("spec.authorizationConfig.requestMode is %q but the OIDC discovery response from %q "+
+						"does not include a pushed_authorization_request_endpoint", upstream.Spec.AuthorizationConfig.RequestMode, upstream.Spec.Issuer),
+				},
+				tlsCondition,
+			}
+		}
+		pushedAuthorizationRequestURL, pushedAuthorizationRequestURLCondition := validateHTTPSURL(
+			additionalDiscoveryClaims.PushedAuthorizationRequestEndpoint,
+			"pushed authorization request endpoint",
+			reasonInvalidResponse,
+		)
+		if pushedAuthorizationRequestURLCondition != nil {
+			return []*metav1.Condition{pushedAuthorizationRequestURLCondition, tlsCondition}
+		}
+		result.PushedAuthorizationRequestURL = pushedAuthorizationRequestURL
+	}
+
 	_, authorizeURLCondition := validateHTTPSURL(
 		discoveredProvider.Endpoint().AuthURL,
 		"authorization endpoint",
@@ -488,8 +621,8 @@ func (c *oidcWatcherController) updateStatus(
 	return hadErrorCondition
 }
 
-func defaultClientShortTimeout(rootCAs *x509.CertPool) *http.Client {
-	c := phttp.Default(rootCAs)
+func defaultClientShortTimeout(rootCAs *x509.CertPool, clientCertificate *tlsconfigutil.ClientCertificate) *http.Client {
+	c := phttp.DefaultWithClientCertificates(rootCAs, clientCertificate.TLSCertificates())
 	c.Timeout = time.Minute
 	return c
 }
@@ -511,6 +644,21 @@ func computeScopes(additionalScopes []string) []string {
 	return set.List()
 }
 
+// computeLoginPolicy returns the login policy configured for the upstream, or nil when none is configured.
+func computeLoginPolicy(authorizationConfig idpv1alpha1.OIDCAuthorizationConfig) *upstreamoidc.LoginPolicy {
+	if len(authorizationConfig.ACRValues) == 0 && authorizationConfig.MaxAge == nil && len(authorizationConfig.RequiredAMRValues) == 0 {
+		return nil
+	}
+	policy := &upstreamoidc.LoginPolicy{
+		ACRValues:         authorizationConfig.ACRValues,
+		RequiredAMRValues: authorizationConfig.RequiredAMRValues,
+	}
+	if authorizationConfig.MaxAge != nil {
+		policy.MaxAge = ptr.To(time.Duration(*authorizationConfig.MaxAge) * time.Second)
+	}
+	return policy
+}
+
 func validateHTTPSURL(maybeHTTPSURL, endpointType, reason string) (*url.URL, *metav1.Condition) {
 	parsedURL, err := url.Parse(maybeHTTPSURL)
 	if err != nil {
@@ -0,0 +1,377 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package samlupstreamwatcher implements a controller which watches SAMLIdentityProviders.
+package samlupstreamwatcher
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/cache"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	supervisorclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
+	idpinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/idp/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/conditionsutil"
+	"go.pinniped.dev/internal/controller/tlsconfigutil"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/net/phttp"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/upstreamsaml"
+)
+
+const (
+	// Setup for the name of our controller in logs.
+	samlControllerName = "saml-upstream-observer"
+
+	// Constants related to the metadata cache. Entries expire so that rotations of the identity
+	// provider's signing certificates are noticed when the metadata is downloaded again.
+	metadataCacheTTL = 15 * time.Minute
+
+	// The largest metadata document which will be downloaded.
+	maxMetadataBytes = 1024 * 1024
+
+	// Constants related to conditions.
+	typeMetadataValid = "MetadataValid"
+
+	reasonUnreachable     = "Unreachable"
+	reasonInvalidMetadata = "InvalidMetadata"
+)
+
+// UpstreamSAMLIdentityProviderICache is a thread safe cache that holds a list of validated upstream SAML IDP configurations.
+type UpstreamSAMLIdentityProviderICache interface {
+	SetSAMLIdentityProviders([]upstreamprovider.UpstreamSAMLIdentityProviderI)
+}
+
+// metadataCacheKey is the type of keys in a metadataCache.
+type metadataCacheKey struct {
+	url          string
+	caBundleHash tlsconfigutil.CABundleHash
+}
+
+// metadataCache caches the downloaded and validated metadata for a particular combination of metadata URL and
+// CA bundle for that URL.
+type metadataCache interface {
+	getMetadata(metadataCacheKey) *upstreamsaml.IdentityProviderMetadata
+	putMetadata(metadataCacheKey, *upstreamsaml.IdentityProviderMetadata)
+}
+
+// ttlMetadataCache caches the metadata associated with a particular URL/TLS configuration, for a limited time (TTL).
+type ttlMetadataCache struct{ cache *cache.Expiring }
+
+// ttlMetadataCache implements the metadataCache interface.
+var _ metadataCache = (*ttlMetadataCache)(nil)
+
+// getMetadata gets an entry from the ttlMetadataCache.
+func (c *ttlMetadataCache) getMetadata(key metadataCacheKey) *upstreamsaml.IdentityProviderMetadata {
+	if result, ok := c.cache.Get(key); ok {
+		return result.(*upstreamsaml.IdentityProviderMetadata)
+	}
+	return nil
+}
+
+// putMetadata adds to the ttlMetadataCache for a limited period of time.
+func (c *ttlMetadataCache) putMetadata(key metadataCacheKey, value *upstreamsaml.IdentityProviderMetadata) {
+	c.cache.Set(key, value, metadataCacheTTL)
+}
+
+type samlWatcherController struct {
+	cache                        UpstreamSAMLIdentityProviderICache
+	log                          plog.Logger
+	client                       supervisorclientset.Interface
+	samlIdentityProviderInformer idpinformers.SAMLIdentityProviderInformer
+	secretInformer               corev1informers.SecretInformer
+	configMapInformer            corev1informers.ConfigMapInformer
+	metadataCache                metadataCache
+}
+
+// New instantiates a new controllerlib.Controller which will populate the provided UpstreamSAMLIdentityProviderICache.
+func New(
+	idpCache UpstreamSAMLIdentityProviderICache,
+	client supervisorclientset.Interface,
+	samlIdentityProviderInformer idpinformers.SAMLIdentityProviderInformer,
+	secretInformer corev1informers.SecretInformer,
+	configMapInformer corev1informers.ConfigMapInformer,
+	log plog.Logger,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+	metadataCache *cache.Expiring,
+) controllerlib.Controller {
+	c := samlWatcherController{
+		cache:                        idpCache,
+		log:                          log.WithName(samlControllerName),
+		client:                       client,
+		samlIdentityProviderInformer: samlIdentityProviderInformer,
+		secretInformer:               secretInformer,
+		configMapInformer:            configMapInformer,
+		metadataCache:                &ttlMetadataCache{cache: metadataCache},
+	}
+	return controllerlib.New(
+		controllerlib.Config{Name: samlControllerName, Syncer: &c},
+		withInformer(
+			samlIdentityProviderInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			secretInformer,
+			pinnipedcontroller.MatchAnySecretOfTypesFilter(
+				[]corev1.SecretType{
+					corev1.SecretTypeOpaque,
+					corev1.SecretTypeTLS,
+				},
+				pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			configMapInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+	)
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *samlWatcherController) Sync(ctx controllerlib.Context) error {
+	actualUpstreams, err := c.samlIdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list SAMLIdentityProviders: %w", err)
+	}
+
+	requeue := false
+	validatedUpstreams := make([]upstreamprovider.UpstreamSAMLIdentityProviderI, 0, len(actualUpstreams))
+	for _, upstream := range actualUpstreams {
+		valid := c.validateUpstream(ctx, upstream)
+		if valid == nil {
+			requeue = true
+		} else {
+			validatedUpstreams = append(validatedUpstreams, upstreamsaml.New(*valid))
+		}
+	}
+	c.cache.SetSAMLIdentityProviders(validatedUpstreams)
+	if requeue {
+		return controllerlib.ErrSyntheticRequeue
+	}
+	return nil
+}
+
+// validateUpstream validates the provided idpv1alpha1.SAMLIdentityProvider and returns the validated configuration as a
+// upstreamsaml.ProviderConfig. As a side effect, it also updates the status of the idpv1alpha1.SAMLIdentityProvider.
+func (c *samlWatcherController) validateUpstream(ctx controllerlib.Context, upstream *idpv1alpha1.SAMLIdentityProvider) *upstreamsaml.ProviderConfig {
+	result := upstreamsaml.ProviderConfig{
+		Name:                    upstream.Name,
+		ResourceUID:             upstream.UID,
+		ServiceProviderEntityID: upstream.Spec.ServiceProvider.EntityID,
+		UsernameAttribute:       upstream.Spec.Claims.Username,
+		GroupsAttribute:         upstream.Spec.Claims.Groups,
+	}
+
+	conditions := c.validateMetadata(ctx.Context, upstream, &result)
+
+	hadErrorCondition := c.updateStatus(ctx.Context, upstream, conditions)
+
+	if hadErrorCondition {
+		return nil
+	}
+	return &result
+}
+
+// validateMetadata loads the identity provider's metadata from .spec.identityProviderMetadata, and returns the
+// appropriate MetadataValid and TLSConfigurationValid conditions.
+func (c *samlWatcherController) validateMetadata(ctx context.Context, upstream *idpv1alpha1.SAMLIdentityProvider, result *upstreamsaml.ProviderConfig) []*metav1.Condition {
+	spec := upstream.Spec.IdentityProviderMetadata
+
+	tlsCondition, caBundle := tlsconfigutil.ValidateTLSConfig(
+		tlsconfigutil.TLSSpecForSupervisor(spec.TLS),
+		"spec.identityProviderMetadata.tls",
+		upstream.Namespace,
+		c.secretInformer,
+		c.configMapInformer)
+
+	// When the TLS config is invalid, return some error conditions.
+	if tlsCondition.Reason != conditionsutil.ReasonSuccess {
+		return []*metav1.Condition{
+			{
+				Type:    typeMetadataValid,
+				Status:  metav1.ConditionUnknown,
+				Reason:  conditionsutil.ReasonUnableToValidate,
+				Message: conditionsutil.MessageUnableToValidate,
+			},
+			tlsCondition,
+		}
+	}
+
+	var metadata *upstreamsaml.IdentityProviderMetadata
+	switch {
+	case spec.XML != "":
+		var err error
+		metadata, err = upstreamsaml.ParseIdentityProviderMetadata([]byte(spec.XML))
+		if err != nil {
+			return []*metav1.Condition{
+				{
+					Type:    typeMetadataValid,
+					Status:  metav1.ConditionFalse,
+					Reason:  reasonInvalidMetadata,
+					Message: fmt.Sprintf("failed to parse metadata from spec.identityProviderMetadata.xml:\n%s", pinnipedcontroller.TruncateMostLongErr(err)),
+				},
+				tlsCondition,
+			}
+		}
+
+	case spec.URL != "":
+		// Get the metadata from cache, if it is found in the cache.
+		cacheKey := metadataCacheKey{
+			url:          spec.URL,
+			caBundleHash: caBundle.Hash(),
+		}
+		metadata = c.metadataCache.getMetadata(cacheKey)
+		if metadata != nil {
+			c.log.WithValues(
+				"namespace", upstream.Namespace,
+				"name", upstream.Name,
+				"url", spec.URL,
+			).Debug("found previous SAML metadata in cache")
+		}
+
+		// If the metadata does not exist in the cache, download it and save it to the cache.
+		if metadata == nil {
+			data, err := fetchMetadata(ctx, defaultClientShortTimeout(caBundle.CertPool()), spec.URL)
+			if err != nil {
+				c.log.WithValues(
+					"namespace", upstream.Namespace,
+					"name", upstream.Name,
+					"url", spec.URL,
+				).Error("failed to download SAML metadata", err)
+				return []*metav1.Condition{
+					{
+						Type:    typeMetadataValid,
+						Status:  metav1.ConditionFalse,
+						Reason:  reasonUnreachable,
+						Message: fmt.Sprintf("failed to download metadata from %q:\n%s", spec.URL, pinnipedcontroller.TruncateMostLongErr(err)),
+					},
+					tlsCondition,
+				}
+			}
+
+			metadata, err = upstreamsaml.ParseIdentityProviderMetadata(data)
+			if err != nil {
+				return []*metav1.Condition{
+					{
+						Type:    typeMetadataValid,
+						Status:  metav1.ConditionFalse,
+						Reason:  reasonInvalidMetadata,
+						Message: fmt.Sprintf("failed to parse metadata from %q:\n%s", spec.URL, pinnipedcontroller.TruncateMostLongErr(err)),
+					},
+					tlsCondition,
+				}
+			}
+
+			// Update the cache with the newly downloaded value.
+			c.metadataCache.putMetadata(cacheKey, metadata)
+		}
+
+	default:
+		// This should not happen because the CRD validations require either a URL or XML.
+		return []*metav1.Condition{
+			{
+				Type:    typeMetadataValid,
+				Status:  metav1.ConditionFalse,
+				Reason:  reasonInvalidMetadata,
+				Message: "exactly one of spec.identityProviderMetadata.url or spec.identityProviderMetadata.xml must be specified",
+			},
+			tlsCondition,
+		}
+	}
+
+	// If everything is valid, update the result and set the condition to true.
+	result.Metadata = metadata
+	return []*metav1.Condition{
+		{
+			Type:   typeMetadataValid,
+			Status: metav1.ConditionTrue,
+			Reason: conditionsutil.ReasonSuccess,
+			Message: fmt.Sprintf("loaded metadata for identity provider %q with %d signing certificate(s)",
+				metadata.EntityID, len(metadata.SigningCertificates)),
+		},
+		tlsCondition,
+	}
+}
+
+func (c *samlWatcherController) updateStatus(
+	ctx context.Context,
+	upstream *idpv1alpha1.SAMLIdentityProvider,
+	conditions []*metav1.Condition,
+) bool {
+	log := c.log.WithValues("namespace", upstream.Namespace, "name", upstream.Name)
+	updated := upstream.DeepCopy()
+
+	hadErrorCondition := conditionsutil.MergeConditions(
+		conditions,
+		&updated.Status.Conditions,
+		upstream.Generation,
+		metav1.Now(),
+		log,
+	)
+
+	updated.Status.Phase = idpv1alpha1.SAMLPhaseReady
+	if hadErrorCondition {
+		updated.Status.Phase = idpv1alpha1.SAMLPhaseError
+	}
+
+	if equality.Semantic.DeepEqual(upstream, updated) {
+		return hadErrorCondition
+	}
+
+	_, err := c.client.
+		IDPV1alpha1().
+		SAMLIdentityProviders(upstream.Namespace).
+		UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		log.Error("failed to update status", err)
+	}
+
+	return hadErrorCondition
+}
+
+func fetchMetadata(ctx context.Context, client *http.Client, metadataURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxMetadataBytes {
+		return nil, fmt.Errorf("metadata is larger than %d bytes", maxMetadataBytes)
+	}
+	return data, nil
+}
+
+func defaultClientShortTimeout(rootCAs *x509.CertPool) *http.Client {
+	c := phttp.Default(rootCAs)
+	c.Timeout = time.Minute
+	return c
+}
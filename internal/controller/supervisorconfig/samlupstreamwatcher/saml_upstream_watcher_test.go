@@ -0,0 +1,536 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package samlupstreamwatcher
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	expiringcache "k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	supervisorinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/controller/tlsconfigutil"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/federationdomain/dynamicupstreamprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/testutil/samltestutil"
+	"go.pinniped.dev/internal/testutil/tlsserver"
+	"go.pinniped.dev/internal/upstreamsaml"
+)
+
+func TestSAMLUpstreamWatcherControllerFilterSecret(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		secret     metav1.Object
+		wantAdd    bool
+		wantUpdate bool
+		wantDelete bool
+	}{
+		{
+			name: "should return true for a secret of the type Opaque",
+			secret: &corev1.Secret{
+				Type:       corev1.SecretTypeOpaque,
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
+		{
+			name: "should return true for a secret of the type TLS",
+			secret: &corev1.Secret{
+				Type:       corev1.SecretTypeTLS,
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+			wantAdd:    true,
+			wantUpdate: true,
+			wantDelete: true,
+		},
+		{
+			name: "should return false for a secret of the wrong type",
+			secret: &corev1.Secret{
+				Type:       "secrets.pinniped.dev/some-other-type",
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+		},
+		{
+			name: "should return false for resource of wrong data type",
+			secret: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-name", Namespace: "some-namespace"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakePinnipedClient := supervisorfake.NewSimpleClientset()
+			pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+			fakeKubeClient := fake.NewSimpleClientset()
+			kubeInformers := informers.NewSharedInformerFactory(fakeKubeClient, 0)
+			cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+			secretInformer := kubeInformers.Core().V1().Secrets()
+			configMapInformer := kubeInformers.Core().V1().ConfigMaps()
+			withInformer := testutil.NewObservableWithInformerOption()
+
+			logger, _ := plog.TestLogger(t)
+
+			New(
+				cache,
+				nil,
+				pinnipedInformers.IDP().V1alpha1().SAMLIdentityProviders(),
+				secretInformer,
+				configMapInformer,
+				logger,
+				withInformer.WithInformer,
+				expiringcache.NewExpiring(),
+			)
+
+			unrelated := corev1.Secret{}
+			filter := withInformer.GetFilterForInformer(secretInformer)
+			require.Equal(t, test.wantAdd, filter.Add(test.secret))
+			require.Equal(t, test.wantUpdate, filter.Update(&unrelated, test.secret))
+			require.Equal(t, test.wantUpdate, filter.Update(test.secret, &unrelated))
+			require.Equal(t, test.wantDelete, filter.Delete(test.secret))
+		})
+	}
+}
+
+func TestSAMLUpstreamWatcherControllerSync(t *testing.T) {
+	t.Parallel()
+	now := metav1.NewTime(time.Now().UTC())
+	earlier := metav1.NewTime(now.Add(-1 * time.Hour).UTC())
+
+	testIDP := samltestutil.NewIdentityProvider(t, "https://saml-idp.example.com/metadata", "https://saml-idp.example.com/sso")
+	testMetadata, err := upstreamsaml.ParseIdentityProviderMetadata([]byte(testIDP.MetadataXML()))
+	require.NoError(t, err)
+
+	// Start a test server that serves the metadata.
+	testServerURL, testServerCA := newTestMetadataServer(t, testIDP)
+	testServerCABase64 := base64.StdEncoding.EncodeToString(testServerCA)
+
+	wrongCA, err := certauthority.New("foo", time.Hour)
+	require.NoError(t, err)
+	wrongCABase64 := base64.StdEncoding.EncodeToString(wrongCA.Bundle())
+
+	var (
+		testNamespace         = "test-namespace"
+		testName              = "test-name"
+		testUID               = types.UID("test-uid")
+		testEntityID          = "https://supervisor.example.com/some-entity-id"
+		testUsernameAttribute = "test-username-attribute"
+		testGroupsAttribute   = "test-groups-attribute"
+	)
+
+	happyMetadataValidCondition := metav1.Condition{
+		Type:               "MetadataValid",
+		Status:             "True",
+		Reason:             "Success",
+		Message:            `loaded metadata for identity provider "https://saml-idp.example.com/metadata" with 1 signing certificate(s)`,
+		LastTransitionTime: now,
+	}
+	happyTLSConfigurationValidCondition := metav1.Condition{
+		Type:               "TLSConfigurationValid",
+		Status:             "True",
+		Reason:             "Success",
+		Message:            "spec.identityProviderMetadata.tls is valid: using configured CA bundle",
+		LastTransitionTime: now,
+	}
+	happyTLSConfigurationValidConditionNoTLS := metav1.Condition{
+		Type:               "TLSConfigurationValid",
+		Status:             "True",
+		Reason:             "Success",
+		Message:            "spec.identityProviderMetadata.tls is valid: no TLS configuration provided: using default root CA bundle from container image",
+		LastTransitionTime: now,
+	}
+
+	tests := []struct {
+		name                   string
+		inputUpstreams         []runtime.Object
+		inputResources         []runtime.Object
+		inputMetadataCache     map[metadataCacheKey]*upstreamsaml.IdentityProviderMetadata
+		wantErr                string
+		wantResultingCache     []*upstreamsaml.ProviderConfig
+		wantResultingUpstreams []idpv1alpha1.SAMLIdentityProvider
+	}{
+		{
+			name: "no upstreams",
+		},
+		{
+			name: "valid upstream with inline metadata",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{XML: testIDP.MetadataXML()},
+					ServiceProvider:          idpv1alpha1.SAMLServiceProvider{EntityID: testEntityID},
+					Claims:                   idpv1alpha1.SAMLClaims{Username: testUsernameAttribute, Groups: testGroupsAttribute},
+				},
+			}},
+			wantResultingCache: []*upstreamsaml.ProviderConfig{{
+				Name:                    testName,
+				ResourceUID:             testUID,
+				Metadata:                testMetadata,
+				ServiceProviderEntityID: testEntityID,
+				UsernameAttribute:       testUsernameAttribute,
+				GroupsAttribute:         testGroupsAttribute,
+			}},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						happyMetadataValidCondition,
+						happyTLSConfigurationValidConditionNoTLS,
+					},
+				},
+			}},
+		},
+		{
+			name: "valid upstream with metadata URL",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{
+						URL: testServerURL + "/metadata",
+						TLS: &idpv1alpha1.TLSSpec{CertificateAuthorityData: testServerCABase64},
+					},
+				},
+			}},
+			wantResultingCache: []*upstreamsaml.ProviderConfig{{
+				Name:        testName,
+				ResourceUID: testUID,
+				Metadata:    testMetadata,
+			}},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						happyMetadataValidCondition,
+						happyTLSConfigurationValidCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "valid upstream with metadata URL which was previously downloaded uses the cached metadata",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{
+						URL: "https://unreachable.example.com/metadata",
+						TLS: &idpv1alpha1.TLSSpec{CertificateAuthorityData: testServerCABase64},
+					},
+				},
+			}},
+			inputMetadataCache: map[metadataCacheKey]*upstreamsaml.IdentityProviderMetadata{
+				{url: "https://unreachable.example.com/metadata", caBundleHash: tlsconfigutil.NewCABundleHash(testServerCA)}: testMetadata,
+			},
+			wantResultingCache: []*upstreamsaml.ProviderConfig{{
+				Name:        testName,
+				ResourceUID: testUID,
+				Metadata:    testMetadata,
+			}},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						happyMetadataValidCondition,
+						happyTLSConfigurationValidCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "existing valid upstream keeps its previous condition timestamps",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID, Generation: 1234},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{XML: testIDP.MetadataXML()},
+				},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						{Type: "MetadataValid", Status: "True", LastTransitionTime: earlier, Reason: "Success", ObservedGeneration: 1234,
+							Message: happyMetadataValidCondition.Message},
+						{Type: "TLSConfigurationValid", Status: "True", LastTransitionTime: earlier, Reason: "Success", ObservedGeneration: 1234,
+							Message: happyTLSConfigurationValidConditionNoTLS.Message},
+					},
+				},
+			}},
+			wantResultingCache: []*upstreamsaml.ProviderConfig{{
+				Name:        testName,
+				ResourceUID: testUID,
+				Metadata:    testMetadata,
+			}},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID, Generation: 1234},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Ready",
+					Conditions: []metav1.Condition{
+						{Type: "MetadataValid", Status: "True", LastTransitionTime: earlier, Reason: "Success", ObservedGeneration: 1234,
+							Message: happyMetadataValidCondition.Message},
+						{Type: "TLSConfigurationValid", Status: "True", LastTransitionTime: earlier, Reason: "Success", ObservedGeneration: 1234,
+							Message: happyTLSConfigurationValidConditionNoTLS.Message},
+					},
+				},
+			}},
+		},
+		{
+			name: "invalid inline metadata",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{XML: `<Something/>`},
+				},
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamsaml.ProviderConfig{},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						{Type: "MetadataValid", Status: "False", LastTransitionTime: now, Reason: "InvalidMetadata",
+							Message: "failed to parse metadata from spec.identityProviderMetadata.xml:\nmetadata root element must be an EntityDescriptor or EntitiesDescriptor"},
+						happyTLSConfigurationValidConditionNoTLS,
+					},
+				},
+			}},
+		},
+		{
+			name: "metadata URL returns invalid metadata",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{
+						URL: testServerURL + "/invalid",
+						TLS: &idpv1alpha1.TLSSpec{CertificateAuthorityData: testServerCABase64},
+					},
+				},
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamsaml.ProviderConfig{},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						{Type: "MetadataValid", Status: "False", LastTransitionTime: now, Reason: "InvalidMetadata",
+							Message: `failed to parse metadata from "` + testServerURL + `/invalid":` + "\n" +
+								"metadata must contain a SingleSignOnService which uses the HTTP-Redirect binding"},
+						happyTLSConfigurationValidCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "metadata URL returns an error status",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{
+						URL: testServerURL + "/not-found",
+						TLS: &idpv1alpha1.TLSSpec{CertificateAuthorityData: testServerCABase64},
+					},
+				},
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamsaml.ProviderConfig{},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						{Type: "MetadataValid", Status: "False", LastTransitionTime: now, Reason: "Unreachable",
+							Message: `failed to download metadata from "` + testServerURL + `/not-found":` + "\n" +
+								`unexpected response status "404 Not Found"`},
+						happyTLSConfigurationValidCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "metadata URL is served with a certificate which is not trusted by the configured CA bundle",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{
+						URL: testServerURL + "/metadata",
+						TLS: &idpv1alpha1.TLSSpec{CertificateAuthorityData: wrongCABase64},
+					},
+				},
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamsaml.ProviderConfig{},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						{Type: "MetadataValid", Status: "False", LastTransitionTime: now, Reason: "Unreachable",
+							Message: `failed to download metadata from "` + testServerURL + `/metadata":` + "\n" +
+								`Get "` + testServerURL + `/metadata": tls: failed to verify certificate: x509: certificate signed by unknown authority`},
+						happyTLSConfigurationValidCondition,
+					},
+				},
+			}},
+		},
+		{
+			name: "invalid TLS configuration",
+			inputUpstreams: []runtime.Object{&idpv1alpha1.SAMLIdentityProvider{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Spec: idpv1alpha1.SAMLIdentityProviderSpec{
+					IdentityProviderMetadata: idpv1alpha1.SAMLIdentityProviderMetadata{
+						URL: testServerURL + "/metadata",
+						TLS: &idpv1alpha1.TLSSpec{CertificateAuthorityData: "invalid-base64"},
+					},
+				},
+			}},
+			wantErr:            controllerlib.ErrSyntheticRequeue.Error(),
+			wantResultingCache: []*upstreamsaml.ProviderConfig{},
+			wantResultingUpstreams: []idpv1alpha1.SAMLIdentityProvider{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, UID: testUID},
+				Status: idpv1alpha1.SAMLIdentityProviderStatus{
+					Phase: "Error",
+					Conditions: []metav1.Condition{
+						{Type: "MetadataValid", Status: "Unknown", LastTransitionTime: now, Reason: "UnableToValidate",
+							Message: "unable to validate; see other conditions for details"},
+						{Type: "TLSConfigurationValid", Status: "False", LastTransitionTime: now, Reason: "InvalidTLSConfig",
+							Message: "spec.identityProviderMetadata.tls.certificateAuthorityData is invalid: illegal base64 data at input byte 7"},
+					},
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fakePinnipedClient := supervisorfake.NewSimpleClientset(tt.inputUpstreams...)
+			pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+			fakeKubeClient := fake.NewSimpleClientset(tt.inputResources...)
+			kubeInformers := informers.NewSharedInformerFactory(fakeKubeClient, 0)
+			cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+			cache.SetSAMLIdentityProviders([]upstreamprovider.UpstreamSAMLIdentityProviderI{
+				upstreamsaml.New(upstreamsaml.ProviderConfig{Name: "initial-entry"}),
+			})
+
+			logger, _ := plog.TestLogger(t)
+
+			metadataCache := expiringcache.NewExpiring()
+			for key, value := range tt.inputMetadataCache {
+				(&ttlMetadataCache{cache: metadataCache}).putMetadata(key, value)
+			}
+
+			controller := New(
+				cache,
+				fakePinnipedClient,
+				pinnipedInformers.IDP().V1alpha1().SAMLIdentityProviders(),
+				kubeInformers.Core().V1().Secrets(),
+				kubeInformers.Core().V1().ConfigMaps(),
+				logger,
+				controllerlib.WithInformer,
+				metadataCache,
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			pinnipedInformers.Start(ctx.Done())
+			kubeInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}}
+
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			actualIDPList := cache.GetSAMLIdentityProviders()
+			require.Equal(t, len(tt.wantResultingCache), len(actualIDPList))
+			for i := range actualIDPList {
+				actualIDP := actualIDPList[i].(*upstreamsaml.Provider)
+				require.Equal(t, *tt.wantResultingCache[i], actualIDP.GetConfig())
+			}
+
+			actualUpstreams, err := fakePinnipedClient.IDPV1alpha1().SAMLIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+
+			// Assert on the expected Status of the upstreams. Preprocess the upstreams a bit so that they're easier to assert against.
+			require.ElementsMatch(t, tt.wantResultingUpstreams, normalizeSAMLUpstreams(actualUpstreams.Items, now))
+
+			// Running the sync() a second time should be idempotent, and should return the same error.
+			// This also helps exercise code paths where the metadata is found in the cache.
+			if err := controllerlib.TestSync(t, controller, syncCtx); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			actualUpstreams, err = fakePinnipedClient.IDPV1alpha1().SAMLIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.wantResultingUpstreams, normalizeSAMLUpstreams(actualUpstreams.Items, now))
+		})
+	}
+}
+
+func normalizeSAMLUpstreams(upstreams []idpv1alpha1.SAMLIdentityProvider, now metav1.Time) []idpv1alpha1.SAMLIdentityProvider {
+	result := make([]idpv1alpha1.SAMLIdentityProvider, 0, len(upstreams))
+	for _, u := range upstreams {
+		normalized := u.DeepCopy()
+
+		// We're only interested in comparing the status, so zero out the spec.
+		normalized.Spec = idpv1alpha1.SAMLIdentityProviderSpec{}
+
+		// Round down the LastTransitionTime values to `now` if they were just updated. This makes
+		// it much easier to encode assertions about the expected timestamps.
+		for i := range normalized.Status.Conditions {
+			if time.Since(normalized.Status.Conditions[i].LastTransitionTime.Time) < 5*time.Second {
+				normalized.Status.Conditions[i].LastTransitionTime = now
+			}
+		}
+		result = append(result, *normalized)
+	}
+
+	return result
+}
+
+func newTestMetadataServer(t *testing.T, idp *samltestutil.IdentityProvider) (string, []byte) {
+	mux := http.NewServeMux()
+	server, serverCA := tlsserver.TestServerIPv4(t, http.HandlerFunc(mux.ServeHTTP), nil)
+
+	// At "/metadata", serve the valid metadata of the test identity provider.
+	mux.HandleFunc("/metadata", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/samlmetadata+xml")
+		_, _ = w.Write([]byte(idp.MetadataXML()))
+	})
+
+	// At "/invalid", serve metadata which does not have a SingleSignOnService using the HTTP-Redirect binding.
+	mux.HandleFunc("/invalid", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/samlmetadata+xml")
+		_, _ = w.Write([]byte(strings.ReplaceAll(idp.MetadataXML(), "HTTP-Redirect", "SOAP")))
+	})
+
+	return server.URL, serverCA
+}
@@ -0,0 +1,332 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package upstreamhealthprober implements a controller which periodically probes the reachability of the upstream
+// servers of OIDCIdentityProviders, LDAPIdentityProviders, ActiveDirectoryIdentityProviders, GitHubIdentityProviders,
+// and OAuth2IdentityProviders.
+//
+// SAMLIdentityProviders and LocalUserIdentityProviders are not probed. The Supervisor never calls a SAML identity
+// provider directly during a login, because all SAML messages are passed through the end user's browser, so the
+// Supervisor may not even have network access to it. A local user directory has no upstream server, since its
+// users are read from Secrets in the Supervisor's own namespace. Because logins using these identity providers
+// never fail to reach them either, they are never considered to be unavailable.
+package upstreamhealthprober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+
+	supervisorclientset "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned"
+	idpinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions/idp/v1alpha1"
+	pinnipedcontroller "go.pinniped.dev/internal/controller"
+	"go.pinniped.dev/internal/controller/conditionsutil"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/leaderelection"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	controllerName = "upstream-health-prober"
+
+	// How often to probe each upstream.
+	probeInterval = 30 * time.Second
+
+	// The maximum amount of time to wait for any single probe.
+	probeTimeout = 10 * time.Second
+
+	// Constants related to conditions.
+	typeUpstreamReachable = "UpstreamReachable"
+	reasonUnreachable     = "Unreachable"
+)
+
+// UpstreamIdentityProvidersCache is a thread safe cache that holds the validated upstream IDP configurations
+// which may be probed.
+type UpstreamIdentityProvidersCache interface {
+	GetOIDCIdentityProviders() []upstreamprovider.UpstreamOIDCIdentityProviderI
+	GetLDAPIdentityProviders() []upstreamprovider.UpstreamLDAPIdentityProviderI
+	GetActiveDirectoryIdentityProviders() []upstreamprovider.UpstreamLDAPIdentityProviderI
+	GetGitHubIdentityProviders() []upstreamprovider.UpstreamGithubIdentityProviderI
+	GetOAuth2IdentityProviders() []upstreamprovider.UpstreamOAuth2IdentityProviderI
+}
+
+type healthProberController struct {
+	idpCache                                UpstreamIdentityProvidersCache
+	upstreamHealth                          *upstreamhealth.Tracker
+	client                                  supervisorclientset.Interface
+	oidcIdentityProviderInformer            idpinformers.OIDCIdentityProviderInformer
+	ldapIdentityProviderInformer            idpinformers.LDAPIdentityProviderInformer
+	activeDirectoryIdentityProviderInformer idpinformers.ActiveDirectoryIdentityProviderInformer
+	gitHubIdentityProviderInformer          idpinformers.GitHubIdentityProviderInformer
+	oauth2IdentityProviderInformer          idpinformers.OAuth2IdentityProviderInformer
+	clock                                   clock.Clock
+	log                                     plog.Logger
+
+	timeOfMostRecentProbe time.Time
+}
+
+// New instantiates a new controllerlib.Controller which will periodically probe each upstream identity provider
+// in the cache which implements upstreamprovider.UpstreamHealthProberI. It records the results in upstreamHealth,
+// and in the UpstreamReachable condition on the status of the corresponding identity provider resource.
+func New(
+	idpCache UpstreamIdentityProvidersCache,
+	upstreamHealth *upstreamhealth.Tracker,
+	client supervisorclientset.Interface,
+	oidcIdentityProviderInformer idpinformers.OIDCIdentityProviderInformer,
+	ldapIdentityProviderInformer idpinformers.LDAPIdentityProviderInformer,
+	activeDirectoryIdentityProviderInformer idpinformers.ActiveDirectoryIdentityProviderInformer,
+	gitHubIdentityProviderInformer idpinformers.GitHubIdentityProviderInformer,
+	oauth2IdentityProviderInformer idpinformers.OAuth2IdentityProviderInformer,
+	clock clock.Clock,
+	log plog.Logger,
+	withInformer pinnipedcontroller.WithInformerOptionFunc,
+) controllerlib.Controller {
+	return controllerlib.New(
+		controllerlib.Config{
+			Name: controllerName,
+			Syncer: &healthProberController{
+				idpCache:                                idpCache,
+				upstreamHealth:                          upstreamHealth,
+				client:                                  client,
+				oidcIdentityProviderInformer:            oidcIdentityProviderInformer,
+				ldapIdentityProviderInformer:            ldapIdentityProviderInformer,
+				activeDirectoryIdentityProviderInformer: activeDirectoryIdentityProviderInformer,
+				gitHubIdentityProviderInformer:          gitHubIdentityProviderInformer,
+				oauth2IdentityProviderInformer:          oauth2IdentityProviderInformer,
+				clock:                                   clock,
+				log:                                     log.WithName(controllerName),
+			},
+		},
+		withInformer(
+			oidcIdentityProviderInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			ldapIdentityProviderInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			activeDirectoryIdentityProviderInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			gitHubIdentityProviderInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+		withInformer(
+			oauth2IdentityProviderInformer,
+			pinnipedcontroller.MatchAnythingFilter(pinnipedcontroller.SingletonQueue()),
+			controllerlib.InformerOption{},
+		),
+	)
+}
+
+// Sync implements controllerlib.Syncer.
+func (c *healthProberController) Sync(ctx controllerlib.Context) error {
+	// The Sync method is triggered upon any change to any identity provider resource, including the status
+	// updates made by this controller, so it rate limits itself to probe at most once per interval.
+	if since := c.clock.Since(c.timeOfMostRecentProbe); since < probeInterval {
+		ctx.Queue.AddAfter(ctx.Key, probeInterval-since)
+		return nil
+	}
+	c.timeOfMostRecentProbe = c.clock.Now()
+
+	// Keep probing periodically, even while none of the identity provider resources are changing.
+	ctx.Queue.AddAfter(ctx.Key, probeInterval)
+
+	conditions := c.probeAll(ctx.Context)
+
+	oidcUpstreams, err := c.oidcIdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list OIDCIdentityProviders: %w", err)
+	}
+	for _, upstream := range oidcUpstreams {
+		condition, ok := conditions[upstream.UID]
+		if !ok {
+			continue
+		}
+		updated := upstream.DeepCopy()
+		conditionsutil.MergeConditions([]*metav1.Condition{condition}, &updated.Status.Conditions, upstream.Generation, metav1.Now(), c.log)
+		if equality.Semantic.DeepEqual(upstream, updated) {
+			continue
+		}
+		_, err := c.client.IDPV1alpha1().OIDCIdentityProviders(upstream.Namespace).UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{})
+		c.logUpdateStatusError(err, upstream.Namespace, upstream.Name)
+	}
+
+	ldapUpstreams, err := c.ldapIdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list LDAPIdentityProviders: %w", err)
+	}
+	for _, upstream := range ldapUpstreams {
+		condition, ok := conditions[upstream.UID]
+		if !ok {
+			continue
+		}
+		updated := upstream.DeepCopy()
+		conditionsutil.MergeConditions([]*metav1.Condition{condition}, &updated.Status.Conditions, upstream.Generation, metav1.Now(), c.log)
+		if equality.Semantic.DeepEqual(upstream, updated) {
+			continue
+		}
+		_, err := c.client.IDPV1alpha1().LDAPIdentityProviders(upstream.Namespace).UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{})
+		c.logUpdateStatusError(err, upstream.Namespace, upstream.Name)
+	}
+
+	adUpstreams, err := c.activeDirectoryIdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list ActiveDirectoryIdentityProviders: %w", err)
+	}
+	for _, upstream := range adUpstreams {
+		condition, ok := conditions[upstream.UID]
+		if !ok {
+			continue
+		}
+		updated := upstream.DeepCopy()
+		conditionsutil.MergeConditions([]*metav1.Condition{condition}, &updated.Status.Conditions, upstream.Generation, metav1.Now(), c.log)
+		if equality.Semantic.DeepEqual(upstream, updated) {
+			continue
+		}
+		_, err := c.client.IDPV1alpha1().ActiveDirectoryIdentityProviders(upstream.Namespace).UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{})
+		c.logUpdateStatusError(err, upstream.Namespace, upstream.Name)
+	}
+
+	gitHubUpstreams, err := c.gitHubIdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list GitHubIdentityProviders: %w", err)
+	}
+	for _, upstream := range gitHubUpstreams {
+		condition, ok := conditions[upstream.UID]
+		if !ok {
+			continue
+		}
+		updated := upstream.DeepCopy()
+		conditionsutil.MergeConditions([]*metav1.Condition{condition}, &updated.Status.Conditions, upstream.Generation, metav1.Now(), c.log)
+		if equality.Semantic.DeepEqual(upstream, updated) {
+			continue
+		}
+		_, err := c.client.IDPV1alpha1().GitHubIdentityProviders(upstream.Namespace).UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{})
+		c.logUpdateStatusError(err, upstream.Namespace, upstream.Name)
+	}
+
+	oauth2Upstreams, err := c.oauth2IdentityProviderInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list OAuth2IdentityProviders: %w", err)
+	}
+	for _, upstream := range oauth2Upstreams {
+		condition, ok := conditions[upstream.UID]
+		if !ok {
+			continue
+		}
+		updated := upstream.DeepCopy()
+		conditionsutil.MergeConditions([]*metav1.Condition{condition}, &updated.Status.Conditions, upstream.Generation, metav1.Now(), c.log)
+		if equality.Semantic.DeepEqual(upstream, updated) {
+			continue
+		}
+		_, err := c.client.IDPV1alpha1().OAuth2IdentityProviders(upstream.Namespace).UpdateStatus(ctx.Context, updated, metav1.UpdateOptions{})
+		c.logUpdateStatusError(err, upstream.Namespace, upstream.Name)
+	}
+
+	return nil
+}
+
+// probeAll concurrently probes every upstream in the cache which can be probed, and returns the resulting
+// UpstreamReachable condition for each upstream, keyed by the UID of its resource. SAML and local user
+// upstreams are intentionally not probed, for the reasons given in the package documentation.
+func (c *healthProberController) probeAll(ctx context.Context) map[types.UID]*metav1.Condition {
+	var upstreams []upstreamprovider.UpstreamIdentityProviderI
+	for _, p := range c.idpCache.GetOIDCIdentityProviders() {
+		upstreams = append(upstreams, p)
+	}
+	for _, p := range c.idpCache.GetLDAPIdentityProviders() {
+		upstreams = append(upstreams, p)
+	}
+	for _, p := range c.idpCache.GetActiveDirectoryIdentityProviders() {
+		upstreams = append(upstreams, p)
+	}
+	for _, p := range c.idpCache.GetGitHubIdentityProviders() {
+		upstreams = append(upstreams, p)
+	}
+	for _, p := range c.idpCache.GetOAuth2IdentityProviders() {
+		upstreams = append(upstreams, p)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	conditions := map[types.UID]*metav1.Condition{}
+	for _, upstream := range upstreams {
+		prober, ok := upstream.(upstreamprovider.UpstreamHealthProberI)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			condition := c.probe(ctx, upstream.GetResourceUID(), prober)
+			mu.Lock()
+			defer mu.Unlock()
+			conditions[upstream.GetResourceUID()] = condition
+		}()
+	}
+	wg.Wait()
+
+	return conditions
+}
+
+func (c *healthProberController) probe(
+	ctx context.Context,
+	uid types.UID,
+	prober upstreamprovider.UpstreamHealthProberI,
+) *metav1.Condition {
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := c.clock.Now()
+	err := prober.ProbeHealth(probeCtx)
+
+	// The latency is only logged, and not written to the condition, so that the status
+	// of the resource only changes when the reachability of the upstream changes.
+	c.log.Debug("probed upstream", "resourceUID", uid, "reachable", err == nil, "latency", c.clock.Since(start))
+
+	c.upstreamHealth.RecordProbe(uid, err)
+
+	if err != nil {
+		return &metav1.Condition{
+			Type:    typeUpstreamReachable,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonUnreachable,
+			Message: fmt.Sprintf("upstream could not be reached: %s", err.Error()),
+		}
+	}
+	return &metav1.Condition{
+		Type:    typeUpstreamReachable,
+		Status:  metav1.ConditionTrue,
+		Reason:  conditionsutil.ReasonSuccess,
+		Message: "upstream is reachable",
+	}
+}
+
+func (c *healthProberController) logUpdateStatusError(err error, namespace, name string) {
+	switch {
+	case err == nil:
+	case errors.Is(err, leaderelection.ErrNotLeader):
+		// Every pod probes its upstreams so that every pod's circuit breakers are up-to-date,
+		// but only the leader may update the status.
+		c.log.Debug("not updating status because this pod is not the leader", "namespace", namespace, "name", name)
+	default:
+		c.log.Error("failed to update status", err, "namespace", namespace, "name", name)
+	}
+}
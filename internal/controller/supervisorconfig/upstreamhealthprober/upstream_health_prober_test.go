@@ -0,0 +1,380 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamhealthprober
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	idpv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	supervisorinformers "go.pinniped.dev/generated/latest/client/supervisor/informers/externalversions"
+	"go.pinniped.dev/internal/controllerlib"
+	"go.pinniped.dev/internal/federationdomain/dynamicupstreamprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+)
+
+type probingOIDCProvider struct {
+	*oidctestutil.TestUpstreamOIDCIdentityProvider
+	probeErr   error
+	probeCount int
+}
+
+func (p *probingOIDCProvider) ProbeHealth(_ context.Context) error {
+	p.probeCount++
+	return p.probeErr
+}
+
+type probingLDAPProvider struct {
+	*oidctestutil.TestUpstreamLDAPIdentityProvider
+	probeErr error
+}
+
+func (p *probingLDAPProvider) ProbeHealth(_ context.Context) error {
+	return p.probeErr
+}
+
+type probingGitHubProvider struct {
+	*oidctestutil.TestUpstreamGitHubIdentityProvider
+	probeErr error
+}
+
+func (p *probingGitHubProvider) ProbeHealth(_ context.Context) error {
+	return p.probeErr
+}
+
+type probingOAuth2Provider struct {
+	*oidctestutil.TestUpstreamOAuth2IdentityProvider
+	probeErr error
+}
+
+func (p *probingOAuth2Provider) ProbeHealth(_ context.Context) error {
+	return p.probeErr
+}
+
+// probingSAMLProvider could be probed, but SAML upstreams should never be probed by the controller.
+type probingSAMLProvider struct {
+	*oidctestutil.TestUpstreamSAMLIdentityProvider
+}
+
+func (p *probingSAMLProvider) ProbeHealth(_ context.Context) error {
+	return errors.New("SAML upstreams should not be probed")
+}
+
+type testQueue struct {
+	addAfterDurations []time.Duration
+
+	controllerlib.Queue // panic if any other methods called
+}
+
+func (q *testQueue) AddAfter(_ controllerlib.Key, duration time.Duration) {
+	q.addAfterDurations = append(q.addAfterDurations, duration)
+}
+
+func TestUpstreamHealthProberControllerSync(t *testing.T) {
+	t.Parallel()
+
+	const testNamespace = "test-namespace"
+
+	existingCondition := metav1.Condition{
+		Type:    "SomeOtherCondition",
+		Status:  metav1.ConditionTrue,
+		Reason:  "Success",
+		Message: "some other condition",
+	}
+	objectMeta := func(name string, uid types.UID) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: testNamespace, UID: uid, Generation: 42}
+	}
+	oidcProvider := func(uid types.UID, probeErr error) *probingOIDCProvider {
+		return &probingOIDCProvider{
+			TestUpstreamOIDCIdentityProvider: oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+				WithName("some-oidc-idp").WithResourceUID(uid).Build(),
+			probeErr: probeErr,
+		}
+	}
+	ldapProvider := func(name string, uid types.UID, probeErr error) *probingLDAPProvider {
+		return &probingLDAPProvider{
+			TestUpstreamLDAPIdentityProvider: oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+				WithName(name).WithResourceUID(uid).Build(),
+			probeErr: probeErr,
+		}
+	}
+	gitHubProvider := func(uid types.UID, probeErr error) *probingGitHubProvider {
+		return &probingGitHubProvider{
+			TestUpstreamGitHubIdentityProvider: oidctestutil.NewTestUpstreamGitHubIdentityProviderBuilder().
+				WithName("some-github-idp").WithResourceUID(uid).Build(),
+			probeErr: probeErr,
+		}
+	}
+	oauth2Provider := func(uid types.UID, probeErr error) *probingOAuth2Provider {
+		return &probingOAuth2Provider{
+			TestUpstreamOAuth2IdentityProvider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithName("some-oauth2-idp").WithResourceUID(uid).Build(),
+			probeErr: probeErr,
+		}
+	}
+	reachableCondition := metav1.Condition{
+		Type:               "UpstreamReachable",
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: 42,
+		Reason:             "Success",
+		Message:            "upstream is reachable",
+	}
+	unreachableCondition := metav1.Condition{
+		Type:               "UpstreamReachable",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: 42,
+		Reason:             "Unreachable",
+		Message:            "upstream could not be reached: some probe error",
+	}
+
+	tests := []struct {
+		name            string
+		inputUpstreams  []runtime.Object
+		oidcProviders   []upstreamprovider.UpstreamOIDCIdentityProviderI
+		ldapProviders   []upstreamprovider.UpstreamLDAPIdentityProviderI
+		adProviders     []upstreamprovider.UpstreamLDAPIdentityProviderI
+		gitHubProviders []upstreamprovider.UpstreamGithubIdentityProviderI
+		oauth2Providers []upstreamprovider.UpstreamOAuth2IdentityProviderI
+		samlProviders   []upstreamprovider.UpstreamSAMLIdentityProviderI
+		wantConditions  map[string][]metav1.Condition
+		wantNoUpdates   bool
+	}{
+		{
+			name: "reachable and unreachable upstreams of every type get the UpstreamReachable condition",
+			inputUpstreams: []runtime.Object{
+				&idpv1alpha1.OIDCIdentityProvider{
+					ObjectMeta: objectMeta("some-oidc-idp", "oidc-uid"),
+					Status: idpv1alpha1.OIDCIdentityProviderStatus{
+						Phase:      idpv1alpha1.PhaseReady,
+						Conditions: []metav1.Condition{existingCondition},
+					},
+				},
+				&idpv1alpha1.LDAPIdentityProvider{ObjectMeta: objectMeta("some-ldap-idp", "ldap-uid")},
+				&idpv1alpha1.ActiveDirectoryIdentityProvider{ObjectMeta: objectMeta("some-ad-idp", "ad-uid")},
+				&idpv1alpha1.GitHubIdentityProvider{ObjectMeta: objectMeta("some-github-idp", "github-uid")},
+				&idpv1alpha1.OAuth2IdentityProvider{ObjectMeta: objectMeta("some-oauth2-idp", "oauth2-uid")},
+			},
+			oidcProviders:   []upstreamprovider.UpstreamOIDCIdentityProviderI{oidcProvider("oidc-uid", nil)},
+			ldapProviders:   []upstreamprovider.UpstreamLDAPIdentityProviderI{ldapProvider("some-ldap-idp", "ldap-uid", errors.New("some probe error"))},
+			adProviders:     []upstreamprovider.UpstreamLDAPIdentityProviderI{ldapProvider("some-ad-idp", "ad-uid", nil)},
+			gitHubProviders: []upstreamprovider.UpstreamGithubIdentityProviderI{gitHubProvider("github-uid", nil)},
+			oauth2Providers: []upstreamprovider.UpstreamOAuth2IdentityProviderI{oauth2Provider("oauth2-uid", errors.New("some probe error"))},
+			wantConditions: map[string][]metav1.Condition{
+				"OIDCIdentityProvider/some-oidc-idp":          {existingCondition, reachableCondition},
+				"LDAPIdentityProvider/some-ldap-idp":          {unreachableCondition},
+				"ActiveDirectoryIdentityProvider/some-ad-idp": {reachableCondition},
+				"GitHubIdentityProvider/some-github-idp":      {reachableCondition},
+				"OAuth2IdentityProvider/some-oauth2-idp":      {unreachableCondition},
+			},
+		},
+		{
+			name: "upstreams which are already up to date are not updated",
+			inputUpstreams: []runtime.Object{
+				&idpv1alpha1.OIDCIdentityProvider{
+					ObjectMeta: objectMeta("some-oidc-idp", "oidc-uid"),
+					Status: idpv1alpha1.OIDCIdentityProviderStatus{
+						Conditions: []metav1.Condition{reachableCondition},
+					},
+				},
+			},
+			oidcProviders: []upstreamprovider.UpstreamOIDCIdentityProviderI{oidcProvider("oidc-uid", nil)},
+			wantNoUpdates: true,
+		},
+		{
+			name: "upstreams which cannot be probed are ignored",
+			inputUpstreams: []runtime.Object{
+				&idpv1alpha1.OIDCIdentityProvider{ObjectMeta: objectMeta("some-oidc-idp", "oidc-uid")},
+			},
+			oidcProviders: []upstreamprovider.UpstreamOIDCIdentityProviderI{
+				oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().WithName("some-oidc-idp").WithResourceUID("oidc-uid").Build(),
+			},
+			wantNoUpdates: true,
+		},
+		{
+			name: "SAML upstreams are never probed",
+			inputUpstreams: []runtime.Object{
+				&idpv1alpha1.SAMLIdentityProvider{ObjectMeta: objectMeta("some-saml-idp", "saml-uid")},
+			},
+			samlProviders: []upstreamprovider.UpstreamSAMLIdentityProviderI{
+				&probingSAMLProvider{
+					TestUpstreamSAMLIdentityProvider: oidctestutil.NewTestUpstreamSAMLIdentityProviderBuilder().
+						WithName("some-saml-idp").WithResourceUID("saml-uid").Build(),
+				},
+			},
+			wantNoUpdates: true,
+		},
+		{
+			name: "resources which are not in the cache are ignored",
+			inputUpstreams: []runtime.Object{
+				&idpv1alpha1.LDAPIdentityProvider{ObjectMeta: objectMeta("some-ldap-idp", "ldap-uid")},
+			},
+			wantNoUpdates: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakePinnipedClient := supervisorfake.NewSimpleClientset(tt.inputUpstreams...)
+			pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+			cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+			cache.SetOIDCIdentityProviders(tt.oidcProviders)
+			cache.SetLDAPIdentityProviders(tt.ldapProviders)
+			cache.SetActiveDirectoryIdentityProviders(tt.adProviders)
+			cache.SetGitHubIdentityProviders(tt.gitHubProviders)
+			cache.SetOAuth2IdentityProviders(tt.oauth2Providers)
+			cache.SetSAMLIdentityProviders(tt.samlProviders)
+			fakeClock := clocktesting.NewFakeClock(time.Now())
+			upstreamHealth := upstreamhealth.NewTracker(fakeClock)
+			upstreamHealth.FailureThreshold = 1
+
+			controller := New(
+				cache,
+				upstreamHealth,
+				fakePinnipedClient,
+				pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().LDAPIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().ActiveDirectoryIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().GitHubIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().OAuth2IdentityProviders(),
+				fakeClock,
+				plog.New(),
+				controllerlib.WithInformer,
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+
+			pinnipedInformers.Start(ctx.Done())
+			controllerlib.TestRunSynchronously(t, controller)
+
+			queue := &testQueue{}
+			syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}, Queue: queue}
+			require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+			require.Equal(t, []time.Duration{probeInterval}, queue.addAfterDurations)
+
+			updateActions := 0
+			for _, action := range fakePinnipedClient.Actions() {
+				if action.GetVerb() == "update" {
+					require.Equal(t, "status", action.GetSubresource())
+					updateActions++
+				}
+			}
+			if tt.wantNoUpdates {
+				require.Zero(t, updateActions)
+				return
+			}
+			require.Equal(t, len(tt.wantConditions), updateActions)
+
+			oidcUpstreams, err := fakePinnipedClient.IDPV1alpha1().OIDCIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			for _, upstream := range oidcUpstreams.Items {
+				require.Equal(t, idpv1alpha1.PhaseReady, upstream.Status.Phase, "phase should not be changed")
+				requireConditions(t, tt.wantConditions["OIDCIdentityProvider/"+upstream.Name], upstream.Status.Conditions)
+				require.True(t, upstreamHealth.IsAvailable(upstream.UID))
+			}
+			ldapUpstreams, err := fakePinnipedClient.IDPV1alpha1().LDAPIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			for _, upstream := range ldapUpstreams.Items {
+				requireConditions(t, tt.wantConditions["LDAPIdentityProvider/"+upstream.Name], upstream.Status.Conditions)
+				require.False(t, upstreamHealth.IsAvailable(upstream.UID))
+			}
+			adUpstreams, err := fakePinnipedClient.IDPV1alpha1().ActiveDirectoryIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			for _, upstream := range adUpstreams.Items {
+				requireConditions(t, tt.wantConditions["ActiveDirectoryIdentityProvider/"+upstream.Name], upstream.Status.Conditions)
+				require.True(t, upstreamHealth.IsAvailable(upstream.UID))
+			}
+			gitHubUpstreams, err := fakePinnipedClient.IDPV1alpha1().GitHubIdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			for _, upstream := range gitHubUpstreams.Items {
+				requireConditions(t, tt.wantConditions["GitHubIdentityProvider/"+upstream.Name], upstream.Status.Conditions)
+				require.True(t, upstreamHealth.IsAvailable(upstream.UID))
+			}
+			oauth2Upstreams, err := fakePinnipedClient.IDPV1alpha1().OAuth2IdentityProviders(testNamespace).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			for _, upstream := range oauth2Upstreams.Items {
+				requireConditions(t, tt.wantConditions["OAuth2IdentityProvider/"+upstream.Name], upstream.Status.Conditions)
+				require.False(t, upstreamHealth.IsAvailable(upstream.UID))
+			}
+		})
+	}
+}
+
+func TestUpstreamHealthProberControllerSyncIsRateLimited(t *testing.T) {
+	t.Parallel()
+
+	fakePinnipedClient := supervisorfake.NewSimpleClientset()
+	pinnipedInformers := supervisorinformers.NewSharedInformerFactory(fakePinnipedClient, 0)
+	provider := &probingOIDCProvider{
+		TestUpstreamOIDCIdentityProvider: oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+			WithName("some-oidc-idp").WithResourceUID("oidc-uid").Build(),
+	}
+	cache := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+	cache.SetOIDCIdentityProviders([]upstreamprovider.UpstreamOIDCIdentityProviderI{provider})
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+
+	controller := New(
+		cache,
+		upstreamhealth.NewTracker(fakeClock),
+		fakePinnipedClient,
+		pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
+		pinnipedInformers.IDP().V1alpha1().LDAPIdentityProviders(),
+		pinnipedInformers.IDP().V1alpha1().ActiveDirectoryIdentityProviders(),
+		pinnipedInformers.IDP().V1alpha1().GitHubIdentityProviders(),
+		pinnipedInformers.IDP().V1alpha1().OAuth2IdentityProviders(),
+		fakeClock,
+		plog.New(),
+		controllerlib.WithInformer,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	pinnipedInformers.Start(ctx.Done())
+	controllerlib.TestRunSynchronously(t, controller)
+
+	queue := &testQueue{}
+	syncCtx := controllerlib.Context{Context: ctx, Key: controllerlib.Key{}, Queue: queue}
+
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	require.Equal(t, 1, provider.probeCount)
+
+	// Syncing again before the interval has passed should not probe again, but should try again later.
+	fakeClock.Step(10 * time.Second)
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	require.Equal(t, 1, provider.probeCount)
+
+	// Once the interval has passed, it should probe again.
+	fakeClock.Step(20 * time.Second)
+	require.NoError(t, controllerlib.TestSync(t, controller, syncCtx))
+	require.Equal(t, 2, provider.probeCount)
+
+	require.Equal(t, []time.Duration{30 * time.Second, 20 * time.Second, 30 * time.Second}, queue.addAfterDurations)
+}
+
+func requireConditions(t *testing.T, want []metav1.Condition, actual []metav1.Condition) {
+	t.Helper()
+
+	// The LastTransitionTime is set using the real clock, so ignore it.
+	normalized := make([]metav1.Condition, len(actual))
+	for i := range actual {
+		normalized[i] = actual[i]
+		normalized[i].LastTransitionTime = metav1.Time{}
+	}
+	require.Equal(t, want, normalized)
+}
@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package upstreamwatchers
@@ -241,6 +241,16 @@ func (g *GradatedConditions) Append(condition *metav1.Condition, isFatal bool) {
 	g.gradatedConditions = append(g.gradatedConditions, gradatedCondition{condition: condition, isFatal: isFatal})
 }
 
+// HasFatalFailure returns true when any fatal condition is not true.
+func (g *GradatedConditions) HasFatalFailure() bool {
+	for _, gradatedCondition := range g.gradatedConditions {
+		if gradatedCondition.condition.Status != metav1.ConditionTrue && gradatedCondition.isFatal {
+			return true
+		}
+	}
+	return false
+}
+
 func ValidateGenericLDAP(
 	ctx context.Context,
 	upstream UpstreamGenericLDAPIDP,
@@ -337,11 +347,9 @@ func validateAndSetLDAPServerConnectivityAndSearchBase(
 }
 
 func EvaluateConditions(conditions GradatedConditions, config *upstreamldap.ProviderConfig) (upstreamprovider.UpstreamLDAPIdentityProviderI, bool) {
-	for _, gradatedCondition := range conditions.gradatedConditions {
-		if gradatedCondition.condition.Status != metav1.ConditionTrue && gradatedCondition.isFatal {
-			// Invalid provider, so do not load it into the cache.
-			return nil, true
-		}
+	if conditions.HasFatalFailure() {
+		// Invalid provider, so do not load it into the cache.
+		return nil, true
 	}
 
 	for _, gradatedCondition := range conditions.gradatedConditions {
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package supervisorstorage
@@ -19,6 +19,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	k8sinformers "k8s.io/client-go/informers"
 	kubernetesfake "k8s.io/client-go/kubernetes/fake"
 	kubetesting "k8s.io/client-go/testing"
@@ -31,6 +32,9 @@ import (
 	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
 	"go.pinniped.dev/internal/fositestorage/accesstoken"
 	"go.pinniped.dev/internal/fositestorage/authorizationcode"
+	"go.pinniped.dev/internal/fositestorage/clientassertion"
+	"go.pinniped.dev/internal/fositestorage/devicecode"
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
 	"go.pinniped.dev/internal/fositestorage/refreshtoken"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/psession"
@@ -124,8 +128,9 @@ func TestGarbageCollectorControllerSync(t *testing.T) {
 
 	spec.Run(t, "Sync", func(t *testing.T, when spec.G, it spec.S) {
 		const (
-			installedInNamespace         = "some-namespace"
-			currentSessionStorageVersion = "8" // update this when you update the storage version in the production code
+			installedInNamespace             = "some-namespace"
+			currentSessionStorageVersion     = "14" // update this when you update the storage version in the production code
+			expectedDeviceCodeStorageVersion = "1"  // update this when you update the device code storage version in the production code
 		)
 
 		var (
@@ -418,6 +423,126 @@ func TestGarbageCollectorControllerSync(t *testing.T) {
 			})
 		})
 
+		when("there is a valid, expired authcode secret which contains an upstream OAuth2 refresh token", func() {
+			it.Before(func() {
+				activeOAuth2AuthcodeSession := &authorizationcode.Session{
+					Version: currentSessionStorageVersion,
+					Active:  true,
+					Request: &fosite.Request{
+						ID:     "request-id-1",
+						Client: &clientregistry.Client{},
+						Session: &psession.PinnipedSession{
+							Custom: &psession.CustomSessionData{
+								Username:     "should be ignored by garbage collector",
+								ProviderUID:  "upstream-oauth2-provider-uid",
+								ProviderName: "upstream-oauth2-provider-name",
+								ProviderType: psession.ProviderTypeOAuth2,
+								OAuth2: &psession.OAuth2SessionData{
+									UpstreamRefreshToken: "fake-upstream-refresh-token",
+								},
+							},
+						},
+					},
+				}
+				activeOAuth2AuthcodeSessionJSON, err := json.Marshal(activeOAuth2AuthcodeSession)
+				r.NoError(err)
+				activeOAuth2AuthcodeSessionSecret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "activeOAuth2AuthcodeSession",
+						Namespace:       installedInNamespace,
+						UID:             "uid-123",
+						ResourceVersion: "rv-123",
+						Annotations: map[string]string{
+							"storage.pinniped.dev/garbage-collect-after": frozenNow.Add(-time.Second).Format(time.RFC3339),
+						},
+						Labels: map[string]string{
+							"storage.pinniped.dev/type": authorizationcode.TypeLabelValue,
+						},
+					},
+					Data: map[string][]byte{
+						"pinniped-storage-data":    activeOAuth2AuthcodeSessionJSON,
+						"pinniped-storage-version": []byte("1"),
+					},
+					Type: "storage.pinniped.dev/" + authorizationcode.TypeLabelValue,
+				}
+				_, err = authorizationcode.ReadFromSecret(activeOAuth2AuthcodeSessionSecret)
+				r.NoError(err, "the test author accidentally formed an invalid authcode secret")
+				r.NoError(kubeInformerClient.Tracker().Add(activeOAuth2AuthcodeSessionSecret))
+				r.NoError(kubeClient.Tracker().Add(activeOAuth2AuthcodeSessionSecret))
+			})
+
+			it("should revoke the upstream token using the OAuth2 provider and delete the secret", func() {
+				happyOIDCUpstream := oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+					WithName("upstream-oidc-provider-name").
+					WithResourceUID("upstream-oidc-provider-uid").
+					WithRevokeTokenError(nil)
+				happyOAuth2Upstream := oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+					WithName("upstream-oauth2-provider-name").
+					WithResourceUID("upstream-oauth2-provider-uid").
+					WithRevokeTokenError(nil)
+				idpListerBuilder := testidplister.NewUpstreamIDPListerBuilder().
+					WithOIDC(happyOIDCUpstream.Build()).
+					WithOAuth2(happyOAuth2Upstream.Build())
+
+				startInformersAndController(idpListerBuilder.BuildDynamicUpstreamIDPProvider())
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				idpListerBuilder.RequireExactlyOneCallToRevokeToken(t,
+					"upstream-oauth2-provider-name",
+					&oidctestutil.RevokeTokenArgs{
+						Ctx:       syncContext.Context,
+						Token:     "fake-upstream-refresh-token",
+						TokenType: upstreamprovider.RefreshTokenType,
+					},
+				)
+
+				r.ElementsMatch(
+					[]kubetesting.Action{
+						kubetesting.NewDeleteActionWithOptions(secretsGVR, installedInNamespace, "activeOAuth2AuthcodeSession", testutil.NewPreconditions("uid-123", "rv-123")),
+					},
+					kubeClient.Actions(),
+				)
+
+				wantAuditLogs = []testutil.WantedAuditLog{
+					testutil.WantAuditLog("Upstream OAuth2 Token Revoked",
+						map[string]any{
+							"sessionID": "request-id-1",
+							"type":      "refresh_token",
+						},
+					),
+					testutil.WantAuditLog("Session Garbage Collected",
+						map[string]any{
+							"sessionID":   "request-id-1",
+							"storageType": "authcode",
+						},
+					),
+				}
+			})
+
+			it("keeps the secret for a while longer so the revocation can be retried on a future sync for retryable errors", func() {
+				happyOAuth2Upstream := oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+					WithName("upstream-oauth2-provider-name").
+					WithResourceUID("upstream-oauth2-provider-uid").
+					WithRevokeTokenError(dynamicupstreamprovider.NewRetryableRevocationError(errors.New("some retryable upstream revocation error")))
+				idpListerBuilder := testidplister.NewUpstreamIDPListerBuilder().WithOAuth2(happyOAuth2Upstream.Build())
+
+				startInformersAndController(idpListerBuilder.BuildDynamicUpstreamIDPProvider())
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				idpListerBuilder.RequireExactlyOneCallToRevokeToken(t,
+					"upstream-oauth2-provider-name",
+					&oidctestutil.RevokeTokenArgs{
+						Ctx:       syncContext.Context,
+						Token:     "fake-upstream-refresh-token",
+						TokenType: upstreamprovider.RefreshTokenType,
+					},
+				)
+
+				// The secret is not deleted.
+				r.Empty(kubeClient.Actions())
+			})
+		})
+
 		when("there are valid, expired authcode secrets which contain upstream access tokens", func() {
 			it.Before(func() {
 				activeOIDCAuthcodeSession := &authorizationcode.Session{
@@ -1468,6 +1593,255 @@ func TestGarbageCollectorControllerSync(t *testing.T) {
 			})
 		})
 
+		when("there are valid, expired device code and user code secrets", func() {
+			it.Before(func() {
+				addDeviceCodeSecret := func(name, uid string, session *devicecode.Session) {
+					sessionJSON, err := json.Marshal(session)
+					r.NoError(err)
+					secret := &corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:            name,
+							Namespace:       installedInNamespace,
+							UID:             types.UID("uid-" + uid),
+							ResourceVersion: "rv-" + uid,
+							Annotations: map[string]string{
+								"storage.pinniped.dev/garbage-collect-after": frozenNow.Add(-time.Second).Format(time.RFC3339),
+							},
+							Labels: map[string]string{
+								"storage.pinniped.dev/type": devicecode.TypeLabelValue,
+							},
+						},
+						Data: map[string][]byte{
+							"pinniped-storage-data":    sessionJSON,
+							"pinniped-storage-version": []byte("1"),
+						},
+						Type: "storage.pinniped.dev/" + devicecode.TypeLabelValue,
+					}
+					_, err = devicecode.ReadFromSecret(secret)
+					r.NoError(err, "the test author accidentally formed an invalid device code secret")
+					r.NoError(kubeInformerClient.Tracker().Add(secret))
+					r.NoError(kubeClient.Tracker().Add(secret))
+				}
+
+				oidcSession := func(upstreamRefreshToken string) *psession.PinnipedSession {
+					return &psession.PinnipedSession{
+						Custom: &psession.CustomSessionData{
+							Username:     "should be ignored by garbage collector",
+							ProviderUID:  "upstream-oidc-provider-uid",
+							ProviderName: "upstream-oidc-provider-name",
+							ProviderType: psession.ProviderTypeOIDC,
+							OIDC: &psession.OIDCSessionData{
+								UpstreamRefreshToken: upstreamRefreshToken,
+							},
+						},
+					}
+				}
+
+				// Approved by the end user, but never redeemed by the device.
+				addDeviceCodeSecret("acceptedOIDCDeviceCodeSession", "123", &devicecode.Session{
+					Version: expectedDeviceCodeStorageVersion,
+					Active:  true,
+					Request: &fosite.DeviceRequest{
+						UserCodeState: fosite.UserCodeAccepted,
+						Request: fosite.Request{
+							ID:      "request-id-1",
+							Client:  &clientregistry.Client{},
+							Session: oidcSession("fake-upstream-refresh-token"),
+						},
+					},
+				})
+
+				// Never approved by the end user, so the session is still empty.
+				addDeviceCodeSecret("pendingDeviceCodeSession", "456", &devicecode.Session{
+					Version: expectedDeviceCodeStorageVersion,
+					Active:  true,
+					Request: &fosite.DeviceRequest{
+						UserCodeState: fosite.UserCodeUnused,
+						Request: fosite.Request{
+							ID:      "request-id-2",
+							Client:  &clientregistry.Client{},
+							Session: &psession.PinnipedSession{},
+						},
+					},
+				})
+
+				// Approved and already redeemed, so the upstream token is held by the other storage types.
+				addDeviceCodeSecret("redeemedOIDCDeviceCodeSession", "789", &devicecode.Session{
+					Version: expectedDeviceCodeStorageVersion,
+					Active:  false,
+					Request: &fosite.DeviceRequest{
+						UserCodeState: fosite.UserCodeAccepted,
+						Request: fosite.Request{
+							ID:      "request-id-3",
+							Client:  &clientregistry.Client{},
+							Session: oidcSession("other-fake-upstream-refresh-token"),
+						},
+					},
+				})
+
+				userCodeSecret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "userCodeSession",
+						Namespace:       installedInNamespace,
+						UID:             "uid-abc",
+						ResourceVersion: "rv-abc",
+						Annotations: map[string]string{
+							"storage.pinniped.dev/garbage-collect-after": frozenNow.Add(-time.Second).Format(time.RFC3339),
+						},
+						Labels: map[string]string{
+							"storage.pinniped.dev/type": devicecode.UserCodeTypeLabelValue,
+						},
+					},
+					Data: map[string][]byte{
+						"pinniped-storage-data":    []byte(`{"deviceCodeSignature":"some-signature","version":"1"}`),
+						"pinniped-storage-version": []byte("1"),
+					},
+					Type: "storage.pinniped.dev/" + devicecode.UserCodeTypeLabelValue,
+				}
+				r.NoError(kubeInformerClient.Tracker().Add(userCodeSecret))
+				r.NoError(kubeClient.Tracker().Add(userCodeSecret))
+			})
+
+			it("should revoke upstream tokens only from the accepted and unredeemed device code secrets and delete them all", func() {
+				happyOIDCUpstream := oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+					WithName("upstream-oidc-provider-name").
+					WithResourceUID("upstream-oidc-provider-uid").
+					WithRevokeTokenError(nil)
+				idpListerBuilder := testidplister.NewUpstreamIDPListerBuilder().WithOIDC(happyOIDCUpstream.Build())
+
+				startInformersAndController(idpListerBuilder.BuildDynamicUpstreamIDPProvider())
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				// The upstream refresh token is only revoked for the accepted device code session which was never redeemed.
+				idpListerBuilder.RequireExactlyOneCallToRevokeToken(t,
+					"upstream-oidc-provider-name",
+					&oidctestutil.RevokeTokenArgs{
+						Ctx:       syncContext.Context,
+						Token:     "fake-upstream-refresh-token",
+						TokenType: upstreamprovider.RefreshTokenType,
+					},
+				)
+
+				// All the secrets are deleted.
+				r.ElementsMatch(
+					[]kubetesting.Action{
+						kubetesting.NewDeleteActionWithOptions(secretsGVR, installedInNamespace, "acceptedOIDCDeviceCodeSession", testutil.NewPreconditions("uid-123", "rv-123")),
+						kubetesting.NewDeleteActionWithOptions(secretsGVR, installedInNamespace, "pendingDeviceCodeSession", testutil.NewPreconditions("uid-456", "rv-456")),
+						kubetesting.NewDeleteActionWithOptions(secretsGVR, installedInNamespace, "redeemedOIDCDeviceCodeSession", testutil.NewPreconditions("uid-789", "rv-789")),
+						kubetesting.NewDeleteActionWithOptions(secretsGVR, installedInNamespace, "userCodeSession", testutil.NewPreconditions("uid-abc", "rv-abc")),
+					},
+					kubeClient.Actions(),
+				)
+
+				// Only the device code sessions which were approved by the end user have downstream sessions to audit.
+				wantAuditLogs = []testutil.WantedAuditLog{
+					testutil.WantAuditLog("Upstream OIDC Token Revoked",
+						map[string]any{
+							"sessionID": "request-id-1",
+							"type":      "refresh_token",
+						},
+					),
+					testutil.WantAuditLog("Session Garbage Collected",
+						map[string]any{
+							"sessionID":   "request-id-1",
+							"storageType": "device-code",
+						},
+					),
+					testutil.WantAuditLog("Session Garbage Collected",
+						map[string]any{
+							"sessionID":   "request-id-3",
+							"storageType": "device-code",
+						},
+					),
+				}
+			})
+		})
+
+		when("there is an expired client assertion secret", func() {
+			it.Before(func() {
+				clientAssertionSecret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "clientAssertion",
+						Namespace:       installedInNamespace,
+						UID:             "uid-123",
+						ResourceVersion: "rv-123",
+						Annotations: map[string]string{
+							"storage.pinniped.dev/garbage-collect-after": frozenNow.Add(-time.Second).Format(time.RFC3339),
+						},
+						Labels: map[string]string{
+							"storage.pinniped.dev/type": clientassertion.TypeLabelValue,
+						},
+					},
+					Data: map[string][]byte{
+						"pinniped-storage-data":    []byte(`{"expiresAt":"2020-01-01T00:00:00Z","version":"1"}`),
+						"pinniped-storage-version": []byte("1"),
+					},
+					Type: "storage.pinniped.dev/" + clientassertion.TypeLabelValue,
+				}
+				r.NoError(kubeInformerClient.Tracker().Add(clientAssertionSecret))
+				r.NoError(kubeClient.Tracker().Add(clientAssertionSecret))
+			})
+
+			it("should delete the secret without revoking any upstream tokens or audit logging any sessions", func() {
+				idpListerBuilder := testidplister.NewUpstreamIDPListerBuilder()
+
+				startInformersAndController(idpListerBuilder.BuildDynamicUpstreamIDPProvider())
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				idpListerBuilder.RequireExactlyZeroCallsToRevokeToken(t)
+
+				r.ElementsMatch(
+					[]kubetesting.Action{
+						kubetesting.NewDeleteActionWithOptions(secretsGVR, installedInNamespace, "clientAssertion", testutil.NewPreconditions("uid-123", "rv-123")),
+					},
+					kubeClient.Actions(),
+				)
+			})
+		})
+
+		when("there is an expired pushed authorization request secret", func() {
+			it.Before(func() {
+				pushedAuthorizeRequestSecret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "pushedAuthorizeRequest",
+						Namespace:       installedInNamespace,
+						UID:             "uid-123",
+						ResourceVersion: "rv-123",
+						Annotations: map[string]string{
+							"storage.pinniped.dev/garbage-collect-after": frozenNow.Add(-time.Second).Format(time.RFC3339),
+						},
+						Labels: map[string]string{
+							"storage.pinniped.dev/type":       pushedauthorizerequest.TypeLabelValue,
+							"storage.pinniped.dev/request-id": "request-id-1",
+						},
+					},
+					Data: map[string][]byte{
+						"pinniped-storage-data":    []byte(`{"request":{"id":"request-id-1"},"version":"1"}`),
+						"pinniped-storage-version": []byte("1"),
+					},
+					Type: "storage.pinniped.dev/" + pushedauthorizerequest.TypeLabelValue,
+				}
+				r.NoError(kubeInformerClient.Tracker().Add(pushedAuthorizeRequestSecret))
+				r.NoError(kubeClient.Tracker().Add(pushedAuthorizeRequestSecret))
+			})
+
+			it("should delete the secret without revoking any upstream tokens or audit logging any sessions", func() {
+				idpListerBuilder := testidplister.NewUpstreamIDPListerBuilder()
+
+				startInformersAndController(idpListerBuilder.BuildDynamicUpstreamIDPProvider())
+				r.NoError(controllerlib.TestSync(t, subject, *syncContext))
+
+				idpListerBuilder.RequireExactlyZeroCallsToRevokeToken(t)
+
+				r.ElementsMatch(
+					[]kubetesting.Action{
+						kubetesting.NewDeleteActionWithOptions(secretsGVR, installedInNamespace, "pushedAuthorizeRequest", testutil.NewPreconditions("uid-123", "rv-123")),
+					},
+					kubeClient.Actions(),
+				)
+			})
+		})
+
 		when("very little time has passed since the previous sync call", func() {
 			it.Before(func() {
 				// Add a secret that will expire in 20 seconds.
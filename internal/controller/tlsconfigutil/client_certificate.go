@@ -0,0 +1,83 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package tlsconfigutil
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+)
+
+type ClientCertificateHash struct {
+	hash [sha256.Size]byte
+}
+
+func (a ClientCertificateHash) Equal(b ClientCertificateHash) bool {
+	return a == b
+}
+
+// ClientCertificate abstracts the internal representation of a client certificate and its private key,
+// which are presented to servers which require mutual TLS.
+type ClientCertificate struct {
+	certificate tls.Certificate
+	sha256      ClientCertificateHash
+}
+
+// NewClientCertificate parses a PEM-encoded certificate chain and private key.
+func NewClientCertificate(certPEM, keyPEM []byte) (*ClientCertificate, error) {
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write(certPEM)
+	h.Write(keyPEM)
+	var hash ClientCertificateHash
+	copy(hash.hash[:], h.Sum(nil))
+
+	return &ClientCertificate{certificate: certificate, sha256: hash}, nil
+}
+
+// TLSCertificates returns the client certificate in the form used by tls.Config, or nil when there is no client certificate.
+func (c *ClientCertificate) TLSCertificates() []tls.Certificate {
+	if c == nil {
+		return nil
+	}
+	return []tls.Certificate{c.certificate}
+}
+
+// Hash returns a sha256 sum of the certificate and private key bytes.
+func (c *ClientCertificate) Hash() ClientCertificateHash {
+	if c == nil {
+		return ClientCertificateHash{}
+	}
+	return c.sha256
+}
+
+// ReadClientCertificate reads a client certificate from the "tls.crt" and "tls.key" keys of a Secret
+// of type kubernetes.io/tls, such as the Secrets which are created by cert-manager.
+func ReadClientCertificate(namespace string, name string, secretInformer corev1informers.SecretInformer) (*ClientCertificate, error) {
+	namespacedName := fmt.Sprintf("%s/%s", namespace, name)
+
+	s, err := secretInformer.Lister().Secrets(namespace).Get(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get secret %q", namespacedName)
+	}
+
+	if s.Type != corev1.SecretTypeTLS {
+		return nil, fmt.Errorf("secret %q of type %q cannot be used as a client certificate (should be %q)",
+			namespacedName, s.Type, corev1.SecretTypeTLS)
+	}
+
+	clientCertificate, err := NewClientCertificate(s.Data[corev1.TLSCertKey], s.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load client certificate from secret %q", namespacedName)
+	}
+	return clientCertificate, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package tlsconfigutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"go.pinniped.dev/internal/certauthority"
+)
+
+func TestClientCertificate(t *testing.T) {
+	testCA, err := certauthority.New("Test CA", 1*time.Hour)
+	require.NoError(t, err)
+	clientCertPEM, err := testCA.IssueClientCertPEM("some-client", nil, 1*time.Hour)
+	require.NoError(t, err)
+	otherClientCertPEM, err := testCA.IssueClientCertPEM("some-other-client", nil, 1*time.Hour)
+	require.NoError(t, err)
+
+	clientCertificate, err := NewClientCertificate(clientCertPEM.CertPEM, clientCertPEM.KeyPEM)
+	require.NoError(t, err)
+	sameClientCertificate, err := NewClientCertificate(clientCertPEM.CertPEM, clientCertPEM.KeyPEM)
+	require.NoError(t, err)
+	otherClientCertificate, err := NewClientCertificate(otherClientCertPEM.CertPEM, otherClientCertPEM.KeyPEM)
+	require.NoError(t, err)
+
+	t.Run("hashes are equal only for the same certificate and key", func(t *testing.T) {
+		require.True(t, clientCertificate.Hash().Equal(sameClientCertificate.Hash()))
+		require.False(t, clientCertificate.Hash().Equal(otherClientCertificate.Hash()))
+		require.False(t, clientCertificate.Hash().Equal((*ClientCertificate)(nil).Hash()))
+	})
+
+	t.Run("TLSCertificates", func(t *testing.T) {
+		certificates := clientCertificate.TLSCertificates()
+		require.Len(t, certificates, 1)
+		require.Equal(t, "some-client", certificates[0].Leaf.Subject.CommonName)
+		require.Nil(t, (*ClientCertificate)(nil).TLSCertificates())
+	})
+
+	t.Run("mismatched certificate and key", func(t *testing.T) {
+		_, err := NewClientCertificate(clientCertPEM.CertPEM, otherClientCertPEM.KeyPEM)
+		require.EqualError(t, err, "tls: private key does not match public key")
+	})
+}
+
+func TestReadClientCertificate(t *testing.T) {
+	testCA, err := certauthority.New("Test CA", 1*time.Hour)
+	require.NoError(t, err)
+	clientCertPEM, err := testCA.IssueClientCertPEM("some-client", nil, 1*time.Hour)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		k8sObjects      []runtime.Object
+		wantCommonName  string
+		wantErr         string
+		wantErrContains string
+	}{
+		{
+			name: "valid TLS secret",
+			k8sObjects: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-secret", Namespace: "some-namespace"},
+				Type:       corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       clientCertPEM.CertPEM,
+					corev1.TLSPrivateKeyKey: clientCertPEM.KeyPEM,
+				},
+			}},
+			wantCommonName: "some-client",
+		},
+		{
+			name:            "secret not found",
+			wantErrContains: `failed to get secret "some-namespace/some-secret"`,
+		},
+		{
+			name: "secret has wrong type",
+			k8sObjects: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-secret", Namespace: "some-namespace"},
+				Type:       corev1.SecretTypeOpaque,
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       clientCertPEM.CertPEM,
+					corev1.TLSPrivateKeyKey: clientCertPEM.KeyPEM,
+				},
+			}},
+			wantErr: `secret "some-namespace/some-secret" of type "Opaque" cannot be used as a client certificate (should be "kubernetes.io/tls")`,
+		},
+		{
+			name: "secret has an invalid key",
+			k8sObjects: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "some-secret", Namespace: "some-namespace"},
+				Type:       corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       clientCertPEM.CertPEM,
+					corev1.TLSPrivateKeyKey: []byte("not a key"),
+				},
+			}},
+			wantErr: `failed to load client certificate from secret "some-namespace/some-secret": ` +
+				`tls: failed to find any PEM data in key input`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := fake.NewSimpleClientset(tt.k8sObjects...)
+			sharedInformers := informers.NewSharedInformerFactory(fakeClient, 0)
+			secretsInformer := sharedInformers.Core().V1().Secrets()
+			secretsInformer.Informer()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			sharedInformers.Start(ctx.Done())
+			sharedInformers.WaitForCacheSync(ctx.Done())
+
+			clientCertificate, err := ReadClientCertificate("some-namespace", "some-secret", secretsInformer)
+			switch {
+			case tt.wantErr != "":
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, clientCertificate)
+			case tt.wantErrContains != "":
+				require.ErrorContains(t, err, tt.wantErrContains)
+				require.Nil(t, clientCertificate)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, tt.wantCommonName, clientCertificate.TLSCertificates()[0].Leaf.Subject.CommonName)
+			}
+		})
+	}
+}
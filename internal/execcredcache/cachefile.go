@@ -1,4 +1,4 @@
-// Copyright 2021-2022 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package execcredcache
@@ -45,6 +45,10 @@ type (
 		CreationTimestamp metav1.Time                                       `json:"creationTimestamp"`
 		LastUsedTimestamp metav1.Time                                       `json:"lastUsedTimestamp"`
 		Credential        *clientauthenticationv1beta1.ExecCredentialStatus `json:"credential"`
+
+		// Issuer is the issuer of the login session which was used to get the credential. It is empty when the
+		// credential did not come from a login session, or when it was cached by an older version of the CLI.
+		Issuer string `json:"issuer,omitempty"`
 	}
 )
 
@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package execcredcache implements a cache for Kubernetes ExecCredential data.
@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/gofrs/flock"
@@ -81,6 +82,12 @@ func (c *Cache) Get(key any) *clientauthenticationv1beta1.ExecCredential {
 }
 
 func (c *Cache) Put(key any, cred *clientauthenticationv1beta1.ExecCredential) {
+	c.PutForIssuer(key, "", cred)
+}
+
+// PutForIssuer is like Put, but it also remembers the issuer of the login session which was used to get the
+// credential, so that ClearIssuer can remove the credential after a logout from that issuer.
+func (c *Cache) PutForIssuer(key any, issuer string, cred *clientauthenticationv1beta1.ExecCredential) {
 	// Create the cache directory if it does not exist.
 	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil && !errors.Is(err, os.ErrExist) {
 		c.errReporter(fmt.Errorf("could not create credential cache directory: %w", err))
@@ -96,6 +103,7 @@ func (c *Cache) Put(key any, cred *clientauthenticationv1beta1.ExecCredential) {
 				// Update the stored entry and return.
 				cache.Entries[i].Credential = cred.Status
 				cache.Entries[i].LastUsedTimestamp = metav1.Now()
+				cache.Entries[i].Issuer = issuer
 				return
 			}
 		}
@@ -107,6 +115,23 @@ func (c *Cache) Put(key any, cred *clientauthenticationv1beta1.ExecCredential) {
 			CreationTimestamp: now,
 			LastUsedTimestamp: now,
 			Credential:        cred.Status,
+			Issuer:            issuer,
+		})
+	})
+}
+
+// ClearIssuer removes the entries which were cached using a login session from the given issuer, so that none of
+// them will be used after a logout from that issuer. Entries without a known issuer are also removed, since they
+// might have been cached by an older version of the CLI using a login session from the same issuer.
+func (c *Cache) ClearIssuer(issuer string) {
+	// If the cache file does not exist, exit immediately with no error log
+	if _, err := os.Stat(c.path); errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	c.withCache(func(cache *credCache) {
+		cache.Entries = slices.DeleteFunc(cache.Entries, func(e entry) bool {
+			return e.Issuer == "" || e.Issuer == issuer
 		})
 	})
 }
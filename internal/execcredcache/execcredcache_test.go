@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package execcredcache
@@ -200,6 +200,7 @@ func TestPutToken(t *testing.T) {
 		name         string
 		makeTestFile func(t *testing.T, tmp string)
 		key          testKey
+		issuer       string
 		cred         *clientauthenticationv1beta1.ExecCredential
 		wantErrors   []string
 		wantTestFile func(t *testing.T, tmp string)
@@ -304,6 +305,28 @@ func TestPutToken(t *testing.T) {
 				}, cache.Entries[1].Credential)
 			},
 		},
+		{
+			name:   "new entry with an issuer",
+			key:    testKey{K1: "v1", K2: "v2"},
+			issuer: "https://test-issuer.example.com",
+			cred: &clientauthenticationv1beta1.ExecCredential{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "ExecCredential",
+					APIVersion: "client.authentication.k8s.io/v1beta1",
+				},
+				Status: &clientauthenticationv1beta1.ExecCredentialStatus{
+					ExpirationTimestamp: timePtr(now.Add(1 * time.Hour)),
+					Token:               "token-one",
+				},
+			},
+			wantTestFile: func(t *testing.T, tmp string) {
+				cache, err := readCache(tmp)
+				require.NoError(t, err)
+				require.Len(t, cache.Entries, 1)
+				require.Equal(t, "https://test-issuer.example.com", cache.Entries[0].Issuer)
+				require.Equal(t, "token-one", cache.Entries[0].Credential.Token)
+			},
+		},
 		{
 			name: "error writing cache",
 			makeTestFile: func(t *testing.T, tmp string) {
@@ -337,7 +360,93 @@ func TestPutToken(t *testing.T) {
 			errors := errorCollector{t: t}
 			c := New(tmp)
 			c.errReporter = errors.report
-			c.Put(tt.key, tt.cred)
+			if tt.issuer != "" {
+				c.PutForIssuer(tt.key, tt.issuer, tt.cred)
+			} else {
+				c.Put(tt.key, tt.cred)
+			}
+			errors.require(tt.wantErrors, "TEMPFILE", tmp, "TEMPDIR", filepath.Dir(tmp))
+			if tt.wantTestFile != nil {
+				tt.wantTestFile(t, tmp)
+			}
+		})
+	}
+}
+
+func TestClearIssuer(t *testing.T) {
+	t.Parallel()
+	now := time.Now().Round(1 * time.Second)
+
+	type testKey struct{ K1, K2 string }
+
+	tests := []struct {
+		name         string
+		makeTestFile func(t *testing.T, tmp string)
+		wantErrors   []string
+		wantTestFile func(t *testing.T, tmp string)
+	}{
+		{
+			name: "file does not exist",
+			wantTestFile: func(t *testing.T, tmp string) {
+				require.NoFileExists(t, tmp)
+			},
+		},
+		{
+			name: "valid file with entries removes the entries for the issuer and the entries without an issuer",
+			makeTestFile: func(t *testing.T, tmp string) {
+				newEntry := func(k string, issuer string, token string) entry {
+					return entry{
+						Key:               jsonSHA256Hex(testKey{K1: k, K2: "v2"}),
+						CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Minute)),
+						LastUsedTimestamp: metav1.NewTime(now.Add(-1 * time.Minute)),
+						Credential: &clientauthenticationv1beta1.ExecCredentialStatus{
+							Token:               token,
+							ExpirationTimestamp: timePtr(now.Add(1 * time.Hour)),
+						},
+						Issuer: issuer,
+					}
+				}
+				validCache := emptyCache()
+				validCache.Entries = []entry{
+					newEntry("v1", "https://test-issuer.example.com", "token-one"),
+					newEntry("v2", "https://other-issuer.example.com", "token-two"),
+					newEntry("v3", "", "token-three"),
+					newEntry("v4", "https://test-issuer.example.com", "token-four"),
+				}
+				require.NoError(t, os.MkdirAll(filepath.Dir(tmp), 0700))
+				require.NoError(t, validCache.writeTo(tmp))
+			},
+			wantTestFile: func(t *testing.T, tmp string) {
+				cache, err := readCache(tmp)
+				require.NoError(t, err)
+				require.Len(t, cache.Entries, 1)
+				require.Equal(t, "token-two", cache.Entries[0].Credential.Token)
+				require.Equal(t, "https://other-issuer.example.com", cache.Entries[0].Issuer)
+			},
+		},
+		{
+			name: "error writing cache",
+			makeTestFile: func(t *testing.T, tmp string) {
+				require.NoError(t, os.MkdirAll(tmp, 0700))
+			},
+			wantErrors: []string{
+				"failed to read cache, resetting: could not read cache file: read TEMPFILE: is a directory",
+				"could not write cache: open TEMPFILE: is a directory",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmp := t.TempDir() + "/cachedir/credentials.yaml"
+			if tt.makeTestFile != nil {
+				tt.makeTestFile(t, tmp)
+			}
+			// Initialize a cache with a reporter that collects errors
+			errors := errorCollector{t: t}
+			c := New(tmp)
+			c.errReporter = errors.report
+			c.ClearIssuer("https://test-issuer.example.com")
 			errors.require(tt.wantErrors, "TEMPFILE", tmp, "TEMPDIR", filepath.Dir(tmp))
 			if tt.wantTestFile != nil {
 				tt.wantTestFile(t, tmp)
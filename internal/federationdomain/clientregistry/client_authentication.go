@@ -0,0 +1,78 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package clientregistry
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/ory/fosite"
+	errorsx "github.com/pkg/errors"
+)
+
+// NewClientAuthenticationStrategy returns a fosite.ClientAuthenticationStrategy which authenticates clients that use
+// the tls_client_auth method (see RFC8705 section 2.1) by their TLS client certificate. Requests from all other clients
+// are authenticated by the given default strategy, which handles the client_secret_basic and private_key_jwt methods.
+func NewClientAuthenticationStrategy(
+	clients fosite.ClientManager,
+	defaultStrategy fosite.ClientAuthenticationStrategy,
+) fosite.ClientAuthenticationStrategy {
+	return func(ctx context.Context, r *http.Request, form url.Values) (fosite.Client, error) {
+		// Clients using tls_client_auth identify themselves using only the client_id request parameter.
+		clientID := form.Get("client_id")
+		_, _, hasBasicAuth := r.BasicAuth()
+		if clientID == "" || hasBasicAuth || form.Get("client_secret") != "" || form.Get("client_assertion_type") != "" {
+			return defaultStrategy(ctx, r, form)
+		}
+
+		client, err := clients.GetClient(ctx, clientID)
+		if err != nil {
+			return nil, errorsx.WithStack(fosite.ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
+		}
+
+		pinnipedClient, ok := client.(*Client)
+		if !ok || pinnipedClient.GetTLSClientAuth() == nil {
+			return defaultStrategy(ctx, r, form)
+		}
+
+		if err := verifyTLSClientCertificate(r, pinnipedClient.GetTLSClientAuth()); err != nil {
+			return nil, errorsx.WithStack(fosite.ErrInvalidClient.
+				WithHint("The OAuth 2.0 Client could not be authenticated by its TLS client certificate.").
+				WithWrap(err).WithDebug(err.Error()))
+		}
+
+		return pinnipedClient, nil
+	}
+}
+
+func verifyTLSClientCertificate(r *http.Request, tlsClientAuth *TLSClientAuth) error {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return errors.New("no TLS client certificate was presented")
+	}
+
+	leaf := r.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         tlsClientAuth.CertPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return fmt.Errorf("TLS client certificate could not be verified: %w", err)
+	}
+
+	if leaf.Subject.String() != tlsClientAuth.SubjectDN {
+		return fmt.Errorf("TLS client certificate subject %q does not match the expected subject %q",
+			leaf.Subject.String(), tlsClientAuth.SubjectDN)
+	}
+
+	return nil
+}
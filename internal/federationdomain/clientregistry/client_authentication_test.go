@@ -0,0 +1,205 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package clientregistry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/certauthority"
+)
+
+type fakeClientManager struct {
+	clients map[string]fosite.Client
+}
+
+func (m *fakeClientManager) GetClient(_ context.Context, id string) (fosite.Client, error) {
+	client, ok := m.clients[id]
+	if !ok {
+		return nil, fosite.ErrNotFound.WithDescription("no such client")
+	}
+	return client, nil
+}
+
+func (m *fakeClientManager) ClientAssertionJWTValid(_ context.Context, _ string) error {
+	return errors.New("not implemented")
+}
+
+func (m *fakeClientManager) SetClientAssertionJWT(_ context.Context, _ string, _ time.Time) error {
+	return errors.New("not implemented")
+}
+
+func TestClientAuthenticationStrategy(t *testing.T) {
+	const (
+		tlsClientID   = "client.oauth.pinniped.dev-tls"
+		otherClientID = "client.oauth.pinniped.dev-other"
+
+		invalidClientDescription = "Client authentication failed (e.g., unknown client, no client authentication included, or unsupported authentication method)."
+		invalidCertDescription   = invalidClientDescription + " The OAuth 2.0 Client could not be authenticated by its TLS client certificate."
+	)
+
+	trustedCA, err := certauthority.New("Trusted CA", time.Hour)
+	require.NoError(t, err)
+	untrustedCA, err := certauthority.New("Untrusted CA", time.Hour)
+	require.NoError(t, err)
+
+	issueCert := func(ca *certauthority.CA, clientCert bool, commonName string) []*x509.Certificate {
+		var tlsCert *tls.Certificate
+		var err error
+		if clientCert {
+			tlsCert, err = ca.IssueClientCert(commonName, nil, time.Hour)
+		} else {
+			tlsCert, err = ca.IssueServerCert([]string{commonName}, nil, time.Hour)
+		}
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(tlsCert.Certificate[0])
+		require.NoError(t, err)
+		return []*x509.Certificate{leaf}
+	}
+
+	tlsClient := &Client{
+		DefaultOpenIDConnectClient: fosite.DefaultOpenIDConnectClient{
+			DefaultClient:           &fosite.DefaultClient{ID: tlsClientID},
+			TokenEndpointAuthMethod: "tls_client_auth",
+		},
+		TLSClientAuth: &TLSClientAuth{CertPool: trustedCA.Pool(), SubjectDN: "CN=some-client"},
+	}
+	otherClient := &Client{
+		DefaultOpenIDConnectClient: fosite.DefaultOpenIDConnectClient{
+			DefaultClient:           &fosite.DefaultClient{ID: otherClientID},
+			TokenEndpointAuthMethod: "client_secret_basic",
+		},
+	}
+	defaultStrategyClient := &fosite.DefaultClient{ID: "returned-by-default-strategy"}
+
+	tests := []struct {
+		name             string
+		form             url.Values
+		basicAuthUser    string
+		peerCertificates []*x509.Certificate
+		noTLS            bool
+
+		wantClient           fosite.Client
+		wantErrorDescription string
+		wantErrorDebug       string
+	}{
+		{
+			name:          "requests using basic auth are handled by the default strategy",
+			form:          url.Values{"client_id": {tlsClientID}},
+			basicAuthUser: tlsClientID,
+			wantClient:    defaultStrategyClient,
+		},
+		{
+			name:       "requests without a client_id param are handled by the default strategy",
+			form:       url.Values{},
+			wantClient: defaultStrategyClient,
+		},
+		{
+			name:       "requests using a client assertion are handled by the default strategy",
+			form:       url.Values{"client_id": {tlsClientID}, "client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"}},
+			wantClient: defaultStrategyClient,
+		},
+		{
+			name:       "requests using a client_secret param are handled by the default strategy",
+			form:       url.Values{"client_id": {tlsClientID}, "client_secret": {"some-secret"}},
+			wantClient: defaultStrategyClient,
+		},
+		{
+			name:       "requests from clients which do not use tls_client_auth are handled by the default strategy",
+			form:       url.Values{"client_id": {otherClientID}},
+			wantClient: defaultStrategyClient,
+		},
+		{
+			name:                 "unknown client",
+			form:                 url.Values{"client_id": {"client.oauth.pinniped.dev-unknown"}},
+			wantErrorDescription: invalidClientDescription,
+			wantErrorDebug:       "not_found",
+		},
+		{
+			name:             "valid TLS client certificate",
+			form:             url.Values{"client_id": {tlsClientID}},
+			peerCertificates: issueCert(trustedCA, true, "some-client"),
+			wantClient:       tlsClient,
+		},
+		{
+			name:                 "no TLS connection",
+			form:                 url.Values{"client_id": {tlsClientID}},
+			noTLS:                true,
+			wantErrorDescription: invalidCertDescription,
+			wantErrorDebug:       "no TLS client certificate was presented",
+		},
+		{
+			name:                 "no TLS client certificate",
+			form:                 url.Values{"client_id": {tlsClientID}},
+			wantErrorDescription: invalidCertDescription,
+			wantErrorDebug:       "no TLS client certificate was presented",
+		},
+		{
+			name:                 "TLS client certificate from an untrusted CA",
+			form:                 url.Values{"client_id": {tlsClientID}},
+			peerCertificates:     issueCert(untrustedCA, true, "some-client"),
+			wantErrorDescription: invalidCertDescription,
+			wantErrorDebug:       "TLS client certificate could not be verified: x509: certificate signed by unknown authority",
+		},
+		{
+			name:                 "TLS certificate which is not a client certificate",
+			form:                 url.Values{"client_id": {tlsClientID}},
+			peerCertificates:     issueCert(trustedCA, false, "some-client"),
+			wantErrorDescription: invalidCertDescription,
+			wantErrorDebug:       "TLS client certificate could not be verified: x509: certificate specifies an incompatible key usage",
+		},
+		{
+			name:                 "TLS client certificate with the wrong subject",
+			form:                 url.Values{"client_id": {tlsClientID}},
+			peerCertificates:     issueCert(trustedCA, true, "some-other-client"),
+			wantErrorDescription: invalidCertDescription,
+			wantErrorDebug:       `TLS client certificate subject "CN=some-other-client" does not match the expected subject "CN=some-client"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clients := &fakeClientManager{clients: map[string]fosite.Client{tlsClientID: tlsClient, otherClientID: otherClient}}
+			defaultStrategy := func(_ context.Context, _ *http.Request, _ url.Values) (fosite.Client, error) {
+				return defaultStrategyClient, nil
+			}
+			subject := NewClientAuthenticationStrategy(clients, defaultStrategy)
+
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(test.form.Encode()))
+			if test.basicAuthUser != "" {
+				req.SetBasicAuth(test.basicAuthUser, "some-password")
+			}
+			if test.noTLS {
+				req.TLS = nil
+			} else {
+				req.TLS = &tls.ConnectionState{PeerCertificates: test.peerCertificates}
+			}
+
+			client, err := subject(context.Background(), req, test.form)
+
+			if test.wantErrorDescription != "" {
+				require.Error(t, err)
+				rfc6749Error := fosite.ErrorToRFC6749Error(err)
+				require.Equal(t, "invalid_client", rfc6749Error.ErrorField)
+				require.Equal(t, test.wantErrorDescription, rfc6749Error.GetDescription())
+				require.Equal(t, test.wantErrorDebug, rfc6749Error.DebugField)
+				require.Nil(t, client)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Same(t, test.wantClient, client)
+		})
+	}
+}
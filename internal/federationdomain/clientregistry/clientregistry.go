@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package clientregistry defines Pinniped's OAuth2/OIDC clients.
@@ -6,11 +6,13 @@ package clientregistry
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"strings"
 	"time"
 
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	oldjosev3 "github.com/go-jose/go-jose/v3" // fosite uses this older version of jose for client JSON Web Key Sets
 	"github.com/ory/fosite"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,12 +35,46 @@ type Client struct {
 	// via RFC8693 token exchange. When zero, the ID token lifetime will be determined by the defaults
 	// for the FederationDomain.
 	IDTokenLifetimeConfiguration time.Duration
+
+	// Optionally provide the fixed identity which this client acts as during the client_credentials grant.
+	// This is not saved into session storage along with the rest of the client, because it is only needed
+	// by the token endpoint, which always has the client freshly loaded by GetClient().
+	ServiceIdentity *supervisorconfigv1alpha1.OIDCClientServiceIdentity `json:"-"`
+
+	// Optionally provide the settings for the tls_client_auth client authentication method. Like ServiceIdentity,
+	// this is not saved into session storage, because it is only needed while authenticating the client.
+	TLSClientAuth *TLSClientAuth `json:"-"`
+
+	// Optionally require that this client uses pushed authorization requests (RFC9126). Like ServiceIdentity,
+	// this is not saved into session storage, because it is only needed by the authorization endpoint,
+	// which has the client freshly loaded by GetClient() when no pushed authorization request was used.
+	RequirePushedAuthorizationRequests bool `json:"-"`
+}
+
+// TLSClientAuth holds the settings used to authenticate a client by its TLS client certificate (RFC8705 section 2.1).
+type TLSClientAuth struct {
+	// CertPool contains the CAs which are trusted to issue the client's certificate.
+	CertPool *x509.CertPool
+	// SubjectDN is the expected subject distinguished name of the client's certificate.
+	SubjectDN string
 }
 
 func (c *Client) GetIDTokenLifetimeConfiguration() time.Duration {
 	return c.IDTokenLifetimeConfiguration
 }
 
+func (c *Client) GetServiceIdentity() *supervisorconfigv1alpha1.OIDCClientServiceIdentity {
+	return c.ServiceIdentity
+}
+
+func (c *Client) GetTLSClientAuth() *TLSClientAuth {
+	return c.TLSClientAuth
+}
+
+func (c *Client) GetRequirePushedAuthorizationRequests() bool {
+	return c.RequirePushedAuthorizationRequests
+}
+
 // Client implements the base, OIDC, and response_mode client interfaces of Fosite.
 var (
 	_ fosite.Client              = (*Client)(nil)
@@ -120,7 +156,8 @@ func (m *ClientManager) GetClient(ctx context.Context, id string) (fosite.Client
 		return nil, fmt.Errorf("client %q exists but is invalid or not ready", id)
 	}
 
-	// Everything is valid, so return the client. Note that it has at least one client secret to be considered valid.
+	// Everything is valid, so return the client. Note that it has at least one client secret to be considered valid,
+	// unless it uses one of the client authentication methods which does not use client secrets.
 	return oidcClientCRToFositeClient(oidcClient, clientSecrets), nil
 }
 
@@ -154,6 +191,7 @@ func PinnipedCLI() *Client {
 					oidcapi.GrantTypeAuthorizationCode,
 					oidcapi.GrantTypeRefreshToken,
 					oidcapi.GrantTypeTokenExchange,
+					oidcapi.GrantTypeDeviceCode,
 				},
 				ResponseTypes: []string{"code"},
 				Scopes: fosite.Arguments{
@@ -188,6 +226,28 @@ func oidcClientCRToFositeClient(oidcClient *supervisorconfigv1alpha1.OIDCClient,
 		idTokenLifetime = time.Duration(*(idTokenLifetimeOverrideInSeconds)) * time.Second
 	}
 
+	// The OIDCClient has already been validated, so it is safe to ignore parse errors here.
+	method := oidcclientvalidator.ClientAuthenticationMethod(oidcClient)
+	var jwks *oldjosev3.JSONWebKeySet
+	jwksURI := ""
+	signingAlgorithm := coreosoidc.RS256
+	var tlsClientAuth *TLSClientAuth
+	if clientAuth := oidcClient.Spec.ClientAuthentication; clientAuth != nil {
+		if clientAuth.PrivateKeyJWT != nil {
+			if clientAuth.PrivateKeyJWT.JWKS != "" {
+				jwks, _ = oidcclientvalidator.ParseJWKS(clientAuth.PrivateKeyJWT.JWKS)
+			}
+			jwksURI = clientAuth.PrivateKeyJWT.JWKSURI
+			if clientAuth.PrivateKeyJWT.SigningAlgorithm != "" {
+				signingAlgorithm = clientAuth.PrivateKeyJWT.SigningAlgorithm
+			}
+		}
+		if clientAuth.TLSClientAuth != nil {
+			certPool, _ := oidcclientvalidator.ParseTLSClientAuthCABundle(clientAuth.TLSClientAuth.CertificateAuthorityData)
+			tlsClientAuth = &TLSClientAuth{CertPool: certPool, SubjectDN: clientAuth.TLSClientAuth.SubjectDN}
+		}
+	}
+
 	return &Client{
 		DefaultOpenIDConnectClient: fosite.DefaultOpenIDConnectClient{
 			DefaultClient: &fosite.DefaultClient{
@@ -205,13 +265,17 @@ func oidcClientCRToFositeClient(oidcClient *supervisorconfigv1alpha1.OIDCClient,
 				Public:         false,
 			},
 			RequestURIs:                       nil,
-			JSONWebKeys:                       nil,
-			JSONWebKeysURI:                    "",
+			JSONWebKeys:                       jwks,
+			JSONWebKeysURI:                    jwksURI,
 			RequestObjectSigningAlgorithm:     "",
-			TokenEndpointAuthSigningAlgorithm: coreosoidc.RS256,
-			TokenEndpointAuthMethod:           "client_secret_basic",
+			TokenEndpointAuthSigningAlgorithm: signingAlgorithm,
+			TokenEndpointAuthMethod:           string(method),
 		},
 		IDTokenLifetimeConfiguration: idTokenLifetime,
+		ServiceIdentity:              oidcClient.Spec.ServiceIdentity.DeepCopy(),
+		TLSClientAuth:                tlsClientAuth,
+
+		RequirePushedAuthorizationRequests: oidcClient.Spec.RequirePushedAuthorizationRequests,
 	}
 }
 
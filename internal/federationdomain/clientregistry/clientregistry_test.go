@@ -1,16 +1,21 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package clientregistry
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
 
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
 	"github.com/ory/fosite"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -22,6 +27,7 @@ import (
 
 	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/certauthority"
 	"go.pinniped.dev/internal/federationdomain/oidcclientvalidator"
 	"go.pinniped.dev/internal/oidcclientsecretstorage"
 	"go.pinniped.dev/internal/testutil"
@@ -36,6 +42,16 @@ func TestClientManager(t *testing.T) {
 		testUID       = "test-uid-123"
 	)
 
+	clientAssertionKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwksBytes, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: clientAssertionKey.Public(), KeyID: "some-key-id", Algorithm: "ES256", Use: "sig"},
+	}})
+	require.NoError(t, err)
+
+	testCA, err := certauthority.New("Test CA", time.Hour)
+	require.NoError(t, err)
+
 	tests := []struct {
 		name                   string
 		secrets                []*corev1.Secret
@@ -250,6 +266,187 @@ func TestClientManager(t *testing.T) {
 				)
 			},
 		},
+		{
+			name: "find a valid dynamic client with a service identity",
+			oidcClients: []*supervisorconfigv1alpha1.OIDCClient{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+					Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+						AllowedGrantTypes:   []supervisorconfigv1alpha1.GrantType{"authorization_code", "client_credentials"},
+						AllowedScopes:       []supervisorconfigv1alpha1.Scope{"openid", "username", "groups"},
+						AllowedRedirectURIs: []supervisorconfigv1alpha1.RedirectURI{"http://localhost:8080"},
+						ServiceIdentity: &supervisorconfigv1alpha1.OIDCClientServiceIdentity{
+							Username: "some-service-username",
+							Groups:   []string{"some-service-group1", "some-service-group2"},
+						},
+					},
+				},
+			},
+			secrets: []*corev1.Secret{
+				testutil.OIDCClientSecretStorageSecretForUID(t, testNamespace, testUID, []string{testutil.HashedPassword1AtSupervisorMinCost}),
+			},
+			run: func(t *testing.T, subject *ClientManager) {
+				got, err := subject.GetClient(ctx, testName)
+				require.NoError(t, err)
+				require.IsType(t, &Client{}, got)
+				c := got.(*Client)
+
+				requireDynamicOIDCClient(t, c,
+					testName,
+					[]string{testutil.HashedPassword1AtSupervisorMinCost},
+					fosite.Arguments{"authorization_code", "client_credentials"},
+					fosite.Arguments{"openid", "username", "groups"},
+					[]string{"http://localhost:8080"},
+					0*time.Second,
+				)
+				require.Equal(t, &supervisorconfigv1alpha1.OIDCClientServiceIdentity{
+					Username: "some-service-username",
+					Groups:   []string{"some-service-group1", "some-service-group2"},
+				}, c.GetServiceIdentity())
+
+				// The service identity is not saved into session storage along with the rest of the client.
+				marshaled, err := json.Marshal(c)
+				require.NoError(t, err)
+				require.NotContains(t, string(marshaled), "some-service-username")
+			},
+		},
+		{
+			name: "find a valid dynamic client which uses private_key_jwt client authentication without any client secrets",
+			oidcClients: []*supervisorconfigv1alpha1.OIDCClient{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+					Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+						AllowedGrantTypes:   []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+						AllowedScopes:       []supervisorconfigv1alpha1.Scope{"openid"},
+						AllowedRedirectURIs: []supervisorconfigv1alpha1.RedirectURI{"http://localhost:8080"},
+						ClientAuthentication: &supervisorconfigv1alpha1.OIDCClientAuthentication{
+							Method: "private_key_jwt",
+							PrivateKeyJWT: &supervisorconfigv1alpha1.OIDCClientPrivateKeyJWT{
+								JWKS:             string(jwksBytes),
+								SigningAlgorithm: "ES256",
+							},
+						},
+					},
+				},
+			},
+			run: func(t *testing.T, subject *ClientManager) {
+				got, err := subject.GetClient(ctx, testName)
+				require.NoError(t, err)
+				require.IsType(t, &Client{}, got)
+				c := got.(*Client)
+
+				require.Equal(t, testName, c.GetID())
+				require.Empty(t, c.GetRotatedHashes())
+				require.Equal(t, "private_key_jwt", c.GetTokenEndpointAuthMethod())
+				require.Equal(t, "ES256", c.GetTokenEndpointAuthSigningAlgorithm())
+				require.Equal(t, "", c.GetJSONWebKeysURI())
+				require.NotNil(t, c.GetJSONWebKeys())
+				require.Len(t, c.GetJSONWebKeys().Key("some-key-id"), 1)
+				require.Nil(t, c.GetTLSClientAuth())
+			},
+		},
+		{
+			name: "find a valid dynamic client which uses private_key_jwt client authentication with a JWKS URI",
+			oidcClients: []*supervisorconfigv1alpha1.OIDCClient{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+					Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+						AllowedGrantTypes:   []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+						AllowedScopes:       []supervisorconfigv1alpha1.Scope{"openid"},
+						AllowedRedirectURIs: []supervisorconfigv1alpha1.RedirectURI{"http://localhost:8080"},
+						ClientAuthentication: &supervisorconfigv1alpha1.OIDCClientAuthentication{
+							Method: "private_key_jwt",
+							PrivateKeyJWT: &supervisorconfigv1alpha1.OIDCClientPrivateKeyJWT{
+								JWKSURI: "https://example.com/jwks.json",
+							},
+						},
+					},
+				},
+			},
+			run: func(t *testing.T, subject *ClientManager) {
+				got, err := subject.GetClient(ctx, testName)
+				require.NoError(t, err)
+				require.IsType(t, &Client{}, got)
+				c := got.(*Client)
+
+				require.Equal(t, "private_key_jwt", c.GetTokenEndpointAuthMethod())
+				require.Equal(t, "RS256", c.GetTokenEndpointAuthSigningAlgorithm())
+				require.Equal(t, "https://example.com/jwks.json", c.GetJSONWebKeysURI())
+				require.Nil(t, c.GetJSONWebKeys())
+			},
+		},
+		{
+			name: "find a valid dynamic client which uses tls_client_auth client authentication without any client secrets",
+			oidcClients: []*supervisorconfigv1alpha1.OIDCClient{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+					Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+						AllowedGrantTypes:   []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+						AllowedScopes:       []supervisorconfigv1alpha1.Scope{"openid"},
+						AllowedRedirectURIs: []supervisorconfigv1alpha1.RedirectURI{"http://localhost:8080"},
+						ClientAuthentication: &supervisorconfigv1alpha1.OIDCClientAuthentication{
+							Method: "tls_client_auth",
+							TLSClientAuth: &supervisorconfigv1alpha1.OIDCClientTLSClientAuth{
+								CertificateAuthorityData: base64.StdEncoding.EncodeToString(testCA.Bundle()),
+								SubjectDN:                "CN=some-client",
+							},
+						},
+					},
+				},
+			},
+			run: func(t *testing.T, subject *ClientManager) {
+				got, err := subject.GetClient(ctx, testName)
+				require.NoError(t, err)
+				require.IsType(t, &Client{}, got)
+				c := got.(*Client)
+
+				require.Equal(t, testName, c.GetID())
+				require.Empty(t, c.GetRotatedHashes())
+				require.Equal(t, "tls_client_auth", c.GetTokenEndpointAuthMethod())
+				require.Nil(t, c.GetJSONWebKeys())
+				require.NotNil(t, c.GetTLSClientAuth())
+				require.Equal(t, "CN=some-client", c.GetTLSClientAuth().SubjectDN)
+				require.True(t, testCA.Pool().Equal(c.GetTLSClientAuth().CertPool))
+
+				// The TLS client auth settings are not saved into session storage along with the rest of the client.
+				marshaled, err := json.Marshal(c)
+				require.NoError(t, err)
+				require.NotContains(t, string(marshaled), "CN=some-client")
+			},
+		},
+		{
+			name: "find a valid dynamic client which requires pushed authorization requests",
+			oidcClients: []*supervisorconfigv1alpha1.OIDCClient{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName, Generation: 1234, UID: testUID},
+					Spec: supervisorconfigv1alpha1.OIDCClientSpec{
+						AllowedGrantTypes:                  []supervisorconfigv1alpha1.GrantType{"authorization_code"},
+						AllowedScopes:                      []supervisorconfigv1alpha1.Scope{"openid"},
+						AllowedRedirectURIs:                []supervisorconfigv1alpha1.RedirectURI{"http://localhost:8080"},
+						RequirePushedAuthorizationRequests: true,
+					},
+				},
+			},
+			secrets: []*corev1.Secret{
+				testutil.OIDCClientSecretStorageSecretForUID(t, testNamespace, testUID, []string{testutil.HashedPassword1AtSupervisorMinCost}),
+			},
+			run: func(t *testing.T, subject *ClientManager) {
+				got, err := subject.GetClient(ctx, testName)
+				require.NoError(t, err)
+				require.IsType(t, &Client{}, got)
+				c := got.(*Client)
+
+				requireDynamicOIDCClient(t, c,
+					testName,
+					[]string{testutil.HashedPassword1AtSupervisorMinCost},
+					fosite.Arguments{"authorization_code"},
+					fosite.Arguments{"openid"},
+					[]string{"http://localhost:8080"},
+					0*time.Second,
+				)
+				require.True(t, c.GetRequirePushedAuthorizationRequests())
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -292,7 +489,7 @@ func requireEqualsPinnipedCLI(t *testing.T, c *Client) {
 	require.Equal(t, "pinniped-cli", c.GetID())
 	require.Nil(t, c.GetHashedSecret())
 	require.Equal(t, []string{"http://127.0.0.1/callback"}, c.GetRedirectURIs())
-	require.Equal(t, fosite.Arguments{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange"}, c.GetGrantTypes())
+	require.Equal(t, fosite.Arguments{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange", "urn:ietf:params:oauth:grant-type:device_code"}, c.GetGrantTypes())
 	require.Equal(t, fosite.Arguments{"code"}, c.GetResponseTypes())
 	require.Equal(t, fosite.Arguments{coreosoidc.ScopeOpenID, coreosoidc.ScopeOfflineAccess, "profile", "email", "pinniped:request-audience", "username", "groups"}, c.GetScopes())
 	require.True(t, c.IsPublic())
@@ -305,6 +502,9 @@ func requireEqualsPinnipedCLI(t *testing.T, c *Client) {
 	require.Equal(t, "RS256", c.GetTokenEndpointAuthSigningAlgorithm())
 	require.Equal(t, []fosite.ResponseModeType{"", "query", "form_post"}, c.GetResponseModes())
 	require.Equal(t, 0*time.Second, c.GetIDTokenLifetimeConfiguration())
+	require.Nil(t, c.GetServiceIdentity())
+	require.Nil(t, c.GetTLSClientAuth())
+	require.False(t, c.GetRequirePushedAuthorizationRequests())
 
 	marshaled, err := json.Marshal(c)
 	require.NoError(t, err)
@@ -317,7 +517,8 @@ func requireEqualsPinnipedCLI(t *testing.T, c *Client) {
 		  "grant_types": [
 			"authorization_code",
 			"refresh_token",
-			"urn:ietf:params:oauth:grant-type:token-exchange"
+			"urn:ietf:params:oauth:grant-type:token-exchange",
+			"urn:ietf:params:oauth:grant-type:device_code"
 		  ],
 		  "response_types": [
 			"code"
@@ -377,4 +578,5 @@ func requireDynamicOIDCClient(
 	require.Equal(t, "client_secret_basic", c.GetTokenEndpointAuthMethod())
 	require.Equal(t, "RS256", c.GetTokenEndpointAuthSigningAlgorithm())
 	require.Equal(t, []fosite.ResponseModeType{"", "query"}, c.GetResponseModes())
+	require.Nil(t, c.GetTLSClientAuth())
 }
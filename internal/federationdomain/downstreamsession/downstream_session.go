@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package downstreamsession provides some shared helpers for creating downstream OIDC sessions.
@@ -7,6 +7,7 @@ package downstreamsession
 import (
 	"context"
 	"fmt"
+	"maps"
 	"slices"
 	"time"
 
@@ -35,6 +36,10 @@ type SessionConfig struct {
 	ClientID string
 	// The scopes that were granted for the new downstream session.
 	GrantedScopes []string
+	// The scopes that were requested by the client for the new downstream session.
+	RequestedScopes []string
+	// The audience that was requested by the client for the new downstream session.
+	RequestedAudience []string
 	// The identity provider used to authenticate the user.
 	IdentityProvider resolvedprovider.FederationDomainResolvedIdentityProvider
 	// The fosite Requester that is starting this session.
@@ -64,8 +69,18 @@ func NewPinnipedSession(
 		},
 	})
 
-	downstreamUsername, downstreamGroups, err := applyIdentityTransformations(ctx,
-		c.IdentityProvider.GetTransforms(), c.UpstreamIdentity.UpstreamUsername, c.UpstreamIdentity.UpstreamGroups)
+	authContext := &idtransform.AuthenticationContext{}
+	if c.UpstreamLoginExtras.AuthenticationContext != nil {
+		*authContext = *c.UpstreamLoginExtras.AuthenticationContext
+	}
+	authContext.Request = idtransform.RequestContext{
+		ClientID: c.ClientID,
+		Scopes:   c.RequestedScopes,
+		Audience: c.RequestedAudience,
+	}
+
+	downstreamUsername, downstreamGroups, transformedClaims, err := applyIdentityTransformations(ctx,
+		c.IdentityProvider.GetTransforms(), c.UpstreamIdentity.UpstreamUsername, c.UpstreamIdentity.UpstreamGroups, authContext)
 	if err != nil {
 		auditLogger.Audit(auditevent.AuthenticationRejectedByTransforms, &plog.AuditParams{
 			ReqCtx:        ctx,
@@ -74,14 +89,28 @@ func NewPinnipedSession(
 		return nil, err
 	}
 
+	// Claims returned by the identity transformations replace any claims of the same name which were mapped
+	// from the upstream identity provider.
+	additionalClaims := maps.Clone(c.UpstreamLoginExtras.DownstreamAdditionalClaims)
+	var transformedClaimNames []string
+	if len(transformedClaims) > 0 {
+		if additionalClaims == nil {
+			additionalClaims = map[string]any{}
+		}
+		maps.Copy(additionalClaims, transformedClaims)
+		transformedClaimNames = slices.Sorted(maps.Keys(transformedClaims))
+	}
+
 	customSessionData := &psession.CustomSessionData{
-		Username:         downstreamUsername,
-		UpstreamUsername: c.UpstreamIdentity.UpstreamUsername,
-		UpstreamGroups:   c.UpstreamIdentity.UpstreamGroups,
-		ProviderUID:      c.IdentityProvider.GetProvider().GetResourceUID(),
-		ProviderName:     c.IdentityProvider.GetProvider().GetResourceName(),
-		ProviderType:     c.IdentityProvider.GetSessionProviderType(),
-		Warnings:         c.UpstreamLoginExtras.Warnings,
+		Username:              downstreamUsername,
+		UpstreamUsername:      c.UpstreamIdentity.UpstreamUsername,
+		UpstreamGroups:        c.UpstreamIdentity.UpstreamGroups,
+		UpstreamData:          authContext.Upstream,
+		TransformedClaimNames: transformedClaimNames,
+		ProviderUID:           c.IdentityProvider.GetProvider().GetResourceUID(),
+		ProviderName:          c.IdentityProvider.GetProvider().GetResourceName(),
+		ProviderType:          c.IdentityProvider.GetSessionProviderType(),
+		Warnings:              c.UpstreamLoginExtras.Warnings,
 	}
 	c.IdentityProvider.ApplyIDPSpecificSessionDataToSession(customSessionData, c.UpstreamIdentity.IDPSpecificSessionData)
 
@@ -91,6 +120,9 @@ func NewPinnipedSession(
 				Subject:     c.UpstreamIdentity.DownstreamSubject,
 				RequestedAt: now,
 				AuthTime:    now,
+				// Pass through how the user authenticated to the upstream identity provider, if known.
+				AuthenticationContextClassReference: authContext.ACR,
+				AuthenticationMethodsReferences:     authContext.AMR,
 			},
 		},
 		Custom: customSessionData,
@@ -100,6 +132,10 @@ func NewPinnipedSession(
 
 	extras[oidcapi.IDTokenClaimAuthorizedParty] = c.ClientID
 
+	// The ID of the fosite Requester remains the same for the lifetime of the downstream session,
+	// including across refreshes, so it uniquely identifies the session.
+	extras[oidcapi.IDTokenClaimSessionID] = c.SessionIDGetter.GetID()
+
 	if slices.Contains(c.GrantedScopes, oidcapi.ScopeUsername) {
 		extras[oidcapi.IDTokenClaimUsername] = downstreamUsername
 	}
@@ -111,8 +147,8 @@ func NewPinnipedSession(
 		extras[oidcapi.IDTokenClaimGroups] = downstreamGroups
 	}
 
-	if len(c.UpstreamLoginExtras.DownstreamAdditionalClaims) > 0 {
-		extras[oidcapi.IDTokenClaimAdditionalClaims] = c.UpstreamLoginExtras.DownstreamAdditionalClaims
+	if len(additionalClaims) > 0 {
+		extras[oidcapi.IDTokenClaimAdditionalClaims] = additionalClaims
 	}
 
 	pinnipedSession.IDTokenClaims().Extra = extras
@@ -124,7 +160,7 @@ func NewPinnipedSession(
 			"username", downstreamUsername,
 			"groups", downstreamGroups,
 			"subject", c.UpstreamIdentity.DownstreamSubject,
-			"additionalClaims", c.UpstreamLoginExtras.DownstreamAdditionalClaims,
+			"additionalClaims", additionalClaims,
 		},
 		KeysAndValues: []any{
 			"warnings", c.UpstreamLoginExtras.Warnings,
@@ -137,7 +173,7 @@ func NewPinnipedSession(
 // AutoApproveScopes auto-grants the scopes which we support and for which we do not require end-user approval,
 // if they were requested. This should only be called after it has been validated that the client is allowed to request
 // the scopes that it requested (which is a check performed by fosite).
-func AutoApproveScopes(authorizeRequester fosite.AuthorizeRequester) {
+func AutoApproveScopes(requester fosite.Requester) {
 	for _, scope := range []string{
 		oidcapi.ScopeOpenID,
 		oidcapi.ScopeOfflineAccess,
@@ -145,7 +181,7 @@ func AutoApproveScopes(authorizeRequester fosite.AuthorizeRequester) {
 		oidcapi.ScopeUsername,
 		oidcapi.ScopeGroups,
 	} {
-		oidc.GrantScopeIfRequested(authorizeRequester, scope)
+		oidc.GrantScopeIfRequested(requester, scope)
 	}
 
 	// For backwards-compatibility with old pinniped CLI binaries which never request the username and groups scopes
@@ -154,28 +190,29 @@ func AutoApproveScopes(authorizeRequester fosite.AuthorizeRequester) {
 	// them. Newer versions of the CLI binaries will request these scopes, so after enough time has passed that
 	// we can assume the old versions of the CLI are no longer in use in the wild, then we can remove this code and
 	// just let the above logic handle all clients.
-	if authorizeRequester.GetClient().GetID() == oidcapi.ClientIDPinnipedCLI {
-		authorizeRequester.GrantScope(oidcapi.ScopeUsername)
-		authorizeRequester.GrantScope(oidcapi.ScopeGroups)
+	if requester.GetClient().GetID() == oidcapi.ClientIDPinnipedCLI {
+		requester.GrantScope(oidcapi.ScopeUsername)
+		requester.GrantScope(oidcapi.ScopeGroups)
 	}
 }
 
 // applyIdentityTransformations applies an identity transformation pipeline to an upstream identity to transform
-// or potentially reject the identity.
+// or potentially reject the identity. It also returns the additional claims which were returned by the transformations.
 func applyIdentityTransformations(
 	ctx context.Context,
 	transforms *idtransform.TransformationPipeline,
 	username string,
 	groups []string,
-) (string, []string, error) {
-	transformationResult, err := transforms.Evaluate(ctx, username, groups)
+	authContext *idtransform.AuthenticationContext,
+) (string, []string, map[string]any, error) {
+	transformationResult, err := transforms.Evaluate(ctx, username, groups, authContext)
 	if err != nil {
 		plog.Error("unexpected identity transformation error during authentication", err, "inputUsername", username)
-		return "", nil, idTransformUnexpectedErr
+		return "", nil, nil, idTransformUnexpectedErr
 	}
 	if !transformationResult.AuthenticationAllowed {
 		plog.Debug("authentication rejected by configured policy", "inputUsername", username, "inputGroups", groups)
-		return "", nil, fmt.Errorf("configured identity policy rejected this authentication: %s", transformationResult.RejectedAuthenticationMessage)
+		return "", nil, nil, fmt.Errorf("configured identity policy rejected this authentication: %s", transformationResult.RejectedAuthenticationMessage)
 	}
 	plog.Debug("identity transformation successfully applied during authentication",
 		"originalUsername", username,
@@ -183,5 +220,5 @@ func applyIdentityTransformations(
 		"originalGroups", groups,
 		"newGroups", transformationResult.Groups,
 	)
-	return transformationResult.Username, transformationResult.Groups, nil
+	return transformationResult.Username, transformationResult.Groups, transformationResult.AdditionalClaims, nil
 }
@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package downstreamsession
@@ -20,8 +20,10 @@ func TestApplyIdentityTransformations(t *testing.T) {
 		transforms   []celtransformer.CELTransformation
 		username     string
 		groups       []string
+		authContext  *idtransform.AuthenticationContext
 		wantUsername string
 		wantGroups   []string
+		wantClaims   map[string]any
 		wantErr      string
 	}{
 		{
@@ -65,6 +67,54 @@ func TestApplyIdentityTransformations(t *testing.T) {
 			wantUsername: "pre:ryan",
 			wantGroups:   []string{"pre:a", "pre:b"},
 		},
+		{
+			name: "auth disallowed by policy using the authentication context",
+			transforms: []celtransformer.CELTransformation{
+				&celtransformer.AllowAuthenticationPolicy{
+					Expression:                    `"mfa" in amr`,
+					RejectedAuthenticationMessage: "mfa required",
+				},
+			},
+			username:    "ryan",
+			groups:      []string{"a", "b"},
+			authContext: &idtransform.AuthenticationContext{ACR: "some-acr", AMR: []string{"pwd"}},
+			wantErr:     "configured identity policy rejected this authentication: mfa required",
+		},
+		{
+			name: "successful auth using the authentication context",
+			transforms: []celtransformer.CELTransformation{
+				&celtransformer.AllowAuthenticationPolicy{Expression: `"mfa" in amr`},
+				&celtransformer.UsernameTransformation{Expression: `acr + ":" + username`},
+			},
+			username:     "ryan",
+			groups:       []string{"a", "b"},
+			authContext:  &idtransform.AuthenticationContext{ACR: "some-acr", AMR: []string{"pwd", "mfa"}},
+			wantUsername: "some-acr:ryan",
+			wantGroups:   []string{"a", "b"},
+		},
+		{
+			name: "successful auth with additional claims",
+			transforms: []celtransformer.CELTransformation{
+				&celtransformer.ClaimsTransformation{Expression: `{"email": upstream.email, "is_a": "a" in groups}`},
+				&celtransformer.UsernameTransformation{Expression: `"pre:" + username`},
+			},
+			username:     "ryan",
+			groups:       []string{"a", "b"},
+			authContext:  &idtransform.AuthenticationContext{Upstream: map[string]any{"email": "ryan@example.com"}},
+			wantUsername: "pre:ryan",
+			wantGroups:   []string{"a", "b"},
+			wantClaims:   map[string]any{"email": "ryan@example.com", "is_a": true},
+		},
+		{
+			name: "auth disallowed by policy after additional claims",
+			transforms: []celtransformer.CELTransformation{
+				&celtransformer.ClaimsTransformation{Expression: `{"email": "ryan@example.com"}`},
+				&celtransformer.AllowAuthenticationPolicy{Expression: `false`},
+			},
+			username: "ryan",
+			groups:   []string{"a", "b"},
+			wantErr:  "configured identity policy rejected this authentication: authentication was rejected by a configured policy",
+		},
 	}
 
 	for _, test := range tests {
@@ -82,15 +132,17 @@ func TestApplyIdentityTransformations(t *testing.T) {
 				pipeline.AppendTransformation(compiledTransform)
 			}
 
-			gotUsername, gotGroups, err := applyIdentityTransformations(context.Background(), pipeline, tt.username, tt.groups)
+			gotUsername, gotGroups, gotClaims, err := applyIdentityTransformations(context.Background(), pipeline, tt.username, tt.groups, tt.authContext)
 			if tt.wantErr != "" {
 				require.EqualError(t, err, tt.wantErr)
 				require.Empty(t, gotUsername)
 				require.Nil(t, gotGroups)
+				require.Nil(t, gotClaims)
 			} else {
 				require.NoError(t, err)
 				require.Equal(t, tt.wantUsername, gotUsername)
 				require.Equal(t, tt.wantGroups, gotGroups)
+				require.Equal(t, tt.wantClaims, gotClaims)
 			}
 		})
 	}
@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package downstreamsubject
@@ -32,3 +32,25 @@ func GitHub(apiBaseURL, idpDisplayName, login, id string) string {
 		url.QueryEscape(id),
 	)
 }
+
+func SAML(upstreamIssuer string, upstreamNameID string, idpDisplayName string) string {
+	return fmt.Sprintf("%s?%s=%s&nameID=%s", upstreamIssuer,
+		oidc.IDTokenSubClaimIDPNameQueryParam, url.QueryEscape(idpDisplayName),
+		url.QueryEscape(upstreamNameID),
+	)
+}
+
+func OAuth2(upstreamSubject string, userInfoURL url.URL, idpDisplayName string) string {
+	q := userInfoURL.Query()
+	q.Set(oidc.IDTokenSubClaimIDPNameQueryParam, idpDisplayName)
+	q.Set(oidc.IDTokenClaimSubject, upstreamSubject)
+	userInfoURL.RawQuery = q.Encode()
+	return userInfoURL.String()
+}
+
+func LocalUser(resourceName string, username string, idpDisplayName string) string {
+	return fmt.Sprintf("localuser://%s?%s=%s&%s=%s", url.PathEscape(resourceName),
+		oidc.IDTokenSubClaimIDPNameQueryParam, url.QueryEscape(idpDisplayName),
+		oidc.IDTokenClaimSubject, url.QueryEscape(username),
+	)
+}
@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package downstreamsubject
@@ -127,3 +127,118 @@ func TestGitHub(t *testing.T) {
 		})
 	}
 }
+
+func TestSAML(t *testing.T) {
+	tests := []struct {
+		name           string
+		upstreamIssuer string
+		upstreamNameID string
+		idpDisplayName string
+		wantSubject    string
+	}{
+		{
+			name:           "simple display name",
+			upstreamIssuer: "https://server.example.com:1234/path",
+			upstreamNameID: "some name id",
+			idpDisplayName: "simpleName",
+			wantSubject:    "https://server.example.com:1234/path?idpName=simpleName&nameID=some+name+id",
+		},
+		{
+			name:           "interesting display name",
+			upstreamIssuer: "urn:example:idp",
+			upstreamNameID: "user@example.com",
+			idpDisplayName: "this is a 👍 display name that 🦭 can handle",
+			wantSubject:    "urn:example:idp?idpName=this+is+a+%F0%9F%91%8D+display+name+that+%F0%9F%A6%AD+can+handle&nameID=user%40example.com",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual := SAML(test.upstreamIssuer, test.upstreamNameID, test.idpDisplayName)
+
+			require.Equal(t, test.wantSubject, actual)
+		})
+	}
+}
+
+func TestOAuth2(t *testing.T) {
+	tests := []struct {
+		name            string
+		upstreamSubject string
+		userInfoURL     string
+		idpDisplayName  string
+		wantSubject     string
+	}{
+		{
+			name:            "simple display name",
+			upstreamSubject: "12345",
+			userInfoURL:     "https://gitlab.example.com/api/v4/user",
+			idpDisplayName:  "simpleName",
+			wantSubject:     "https://gitlab.example.com/api/v4/user?idpName=simpleName&sub=12345",
+		},
+		{
+			name:            "interesting display name",
+			upstreamSubject: "some subject",
+			userInfoURL:     "https://gitlab.example.com/api/v4/user",
+			idpDisplayName:  "this is a 👍 display name that 🦭 can handle",
+			wantSubject:     "https://gitlab.example.com/api/v4/user?idpName=this+is+a+%F0%9F%91%8D+display+name+that+%F0%9F%A6%AD+can+handle&sub=some+subject",
+		},
+		{
+			name:            "url already has query",
+			upstreamSubject: "some subject",
+			userInfoURL:     "https://api.example.com/user?fields=id%2Cname",
+			idpDisplayName:  "some name",
+			wantSubject:     "https://api.example.com/user?fields=id%2Cname&idpName=some+name&sub=some+subject",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			u, err := url.Parse(test.userInfoURL)
+			require.NoError(t, err)
+
+			actual := OAuth2(test.upstreamSubject, *u, test.idpDisplayName)
+
+			require.Equal(t, test.wantSubject, actual)
+		})
+	}
+}
+
+func TestLocalUser(t *testing.T) {
+	tests := []struct {
+		name           string
+		resourceName   string
+		username       string
+		idpDisplayName string
+		wantSubject    string
+	}{
+		{
+			name:           "simple display name",
+			resourceName:   "my-users",
+			username:       "pinny",
+			idpDisplayName: "simpleName",
+			wantSubject:    "localuser://my-users?idpName=simpleName&sub=pinny",
+		},
+		{
+			name:           "interesting display name and username",
+			resourceName:   "my-users",
+			username:       "pinny@example.com",
+			idpDisplayName: "this is a 👍 display name that 🦭 can handle",
+			wantSubject:    "localuser://my-users?idpName=this+is+a+%F0%9F%91%8D+display+name+that+%F0%9F%A6%AD+can+handle&sub=pinny%40example.com",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual := LocalUser(test.resourceName, test.username, test.idpDisplayName)
+
+			require.Equal(t, test.wantSubject, actual)
+		})
+	}
+}
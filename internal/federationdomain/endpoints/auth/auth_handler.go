@@ -1,13 +1,14 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
-// Package auth provides a handler for the OIDC authorization endpoint.
+// Package auth provides handlers for the OIDC authorization endpoint and the pushed authorization request endpoint.
 package auth
 
 import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/ory/fosite"
@@ -17,13 +18,16 @@ import (
 
 	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
 	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
 	"go.pinniped.dev/internal/federationdomain/csrftoken"
 	"go.pinniped.dev/internal/federationdomain/downstreamsession"
+	"go.pinniped.dev/internal/federationdomain/endpoints/upstreamlogin"
 	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
 	"go.pinniped.dev/internal/federationdomain/formposthtml"
 	"go.pinniped.dev/internal/federationdomain/oidc"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedldap"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedlocaluser"
 	"go.pinniped.dev/internal/federationdomain/stateparam"
 	"go.pinniped.dev/internal/httputil/responseutil"
 	"go.pinniped.dev/internal/httputil/securityheader"
@@ -36,6 +40,8 @@ import (
 const (
 	promptParamName = "prompt"
 	promptParamNone = "none"
+
+	requestURIParamName = "request_uri"
 )
 
 func paramsSafeToLog() sets.Set[string] {
@@ -59,6 +65,7 @@ type authorizeHandler struct {
 	idpFinder                 federationdomainproviders.FederationDomainIdentityProvidersFinderI
 	oauthHelperWithoutStorage fosite.OAuth2Provider
 	oauthHelperWithStorage    fosite.OAuth2Provider
+	parStorage                fosite.PARStorage
 	generateCSRF              func() (csrftoken.CSRFToken, error)
 	generatePKCE              func() (pkce.Code, error)
 	generateNonce             func() (nonce.Nonce, error)
@@ -72,6 +79,7 @@ func NewHandler(
 	idpFinder federationdomainproviders.FederationDomainIdentityProvidersFinderI,
 	oauthHelperWithoutStorage fosite.OAuth2Provider,
 	oauthHelperWithStorage fosite.OAuth2Provider,
+	parStorage fosite.PARStorage,
 	generateCSRF func() (csrftoken.CSRFToken, error),
 	generatePKCE func() (pkce.Code, error),
 	generateNonce func() (nonce.Nonce, error),
@@ -84,6 +92,7 @@ func NewHandler(
 		idpFinder:                 idpFinder,
 		oauthHelperWithoutStorage: oauthHelperWithoutStorage,
 		oauthHelperWithStorage:    oauthHelperWithStorage,
+		parStorage:                parStorage,
 		generateCSRF:              generateCSRF,
 		generatePKCE:              generatePKCE,
 		generateNonce:             generateNonce,
@@ -134,11 +143,26 @@ func (h *authorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// The Pinniped CLI has been sending these params since v0.9.0.
 	idpNameQueryParamValue := r.Form.Get(oidcapi.AuthorizeUpstreamIDPNameParamName)
 
+	// When the client used a pushed authorization request, then the params that it pushed are not in this request.
+	// Look at them without using up the pushed authorization request, which will be used by fosite below.
+	pushedAuthorizeRequest, err := h.lookupPushedAuthorizeRequest(r)
+	if err != nil {
+		oidc.WriteAuthorizeError(r, w,
+			h.oauthHelperWithoutStorage, fosite.NewAuthorizeRequest(), err, requestedBrowserlessFlow)
+		return
+	}
+	usedPushedAuthorizeRequest := pushedAuthorizeRequest != nil
+	if usedPushedAuthorizeRequest && len(idpNameQueryParamValue) == 0 {
+		idpNameQueryParamValue = pushedAuthorizeRequest.GetRequestForm().Get(oidcapi.AuthorizeUpstreamIDPNameParamName)
+	}
+
 	// Check if we are in a special case where we should inject an interstitial page to ask the user
 	// which IDP they would like to use.
-	if shouldShowIDPChooser(h.idpFinder, idpNameQueryParamValue, requestedBrowserlessFlow) {
+	if !requestedBrowserlessFlow && upstreamlogin.ShouldShowIDPChooser(h.idpFinder, idpNameQueryParamValue) {
 		// Redirect to the IDP chooser page with all the same query/form params. When the user chooses an IDP,
 		// it will redirect back to here with all the same params again, with the pinniped_idp_name param added.
+		// When a pushed authorization request was used, then these params include its request_uri, which has
+		// not been used up yet.
 		http.Redirect(w, r,
 			fmt.Sprintf("%s%s?%s", h.downstreamIssuerURL, oidc.ChooseIDPEndpointPath, r.Form.Encode()),
 			http.StatusSeeOther,
@@ -146,7 +170,7 @@ func (h *authorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	idp, err := chooseUpstreamIDP(idpNameQueryParamValue, h.idpFinder)
+	idp, err := upstreamlogin.ChooseUpstreamIDP(idpNameQueryParamValue, h.idpFinder)
 	if err != nil {
 		oidc.WriteAuthorizeError(r, w,
 			h.oauthHelperWithoutStorage,
@@ -168,13 +192,14 @@ func (h *authorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	h.authorize(w, r, requestedBrowserlessFlow, idp)
+	h.authorize(w, r, requestedBrowserlessFlow, usedPushedAuthorizeRequest, idp)
 }
 
 func (h *authorizeHandler) authorize(
 	w http.ResponseWriter,
 	r *http.Request,
 	requestedBrowserlessFlow bool,
+	usedPushedAuthorizeRequest bool,
 	idp resolvedprovider.FederationDomainResolvedIdentityProvider,
 ) {
 	// Browser flows do not need session storage at this step. For browser flows, the request parameters
@@ -191,6 +216,11 @@ func (h *authorizeHandler) authorize(
 		return
 	}
 
+	if err = requirePushedAuthorizeRequestWhenConfigured(authorizeRequester, usedPushedAuthorizeRequest); err != nil {
+		oidc.WriteAuthorizeError(r, w, oauthHelper, authorizeRequester, err, requestedBrowserlessFlow)
+		return
+	}
+
 	// Automatically grant certain scopes, but only if they were requested.
 	// Grant the openid scope (for now) if they asked for it so that `NewAuthorizeResponse` will perform its OIDC validations.
 	// There don't seem to be any validations inside `NewAuthorizeResponse` related to the offline_access scope
@@ -237,7 +267,8 @@ func (h *authorizeHandler) authorizeWithoutBrowser(
 
 	identity, loginExtras, err := idp.Login(r.Context(), submittedUsername, submittedPassword)
 	if err != nil {
-		if err == resolvedldap.ErrAccessDeniedDueToUsernamePasswordNotAccepted {
+		if err == resolvedldap.ErrAccessDeniedDueToUsernamePasswordNotAccepted ||
+			err == resolvedlocaluser.ErrAccessDeniedDueToUsernamePasswordNotAccepted {
 			h.auditLogger.Audit(auditevent.IncorrectUsernameOrPassword, &plog.AuditParams{
 				ReqCtx: r.Context(),
 			})
@@ -250,6 +281,8 @@ func (h *authorizeHandler) authorizeWithoutBrowser(
 		UpstreamLoginExtras: loginExtras,
 		ClientID:            authorizeRequester.GetClient().GetID(),
 		GrantedScopes:       authorizeRequester.GetGrantedScopes(),
+		RequestedScopes:     authorizeRequester.GetRequestedScopes(),
+		RequestedAudience:   authorizeRequester.GetRequestedAudience(),
 		IdentityProvider:    idp,
 		SessionIDGetter:     authorizeRequester,
 	})
@@ -284,7 +317,7 @@ func (h *authorizeHandler) authorizeWithBrowser(
 		return "", err
 	}
 
-	redirectURL, err := idp.UpstreamAuthorizeRedirectURL(authRequestState, h.downstreamIssuerURL)
+	redirectURL, err := idp.UpstreamAuthorizeRedirectURL(r.Context(), authRequestState, h.downstreamIssuerURL)
 	if err != nil {
 		return "", err
 	}
@@ -296,18 +329,33 @@ func (h *authorizeHandler) authorizeWithBrowser(
 	return authRequestState.EncodedStateParam.AuthorizeID(), nil
 }
 
-func shouldShowIDPChooser(
-	idpFinder federationdomainproviders.FederationDomainIdentityProvidersFinderI,
-	idpNameQueryParamValue string,
-	requestedBrowserlessFlow bool,
-) bool {
-	clientDidNotRequestSpecificIDP := len(idpNameQueryParamValue) == 0
-	clientRequestedBrowserBasedFlow := !requestedBrowserlessFlow
-	inBackwardsCompatMode := idpFinder.HasDefaultIDP()
-	federationDomainSpecHasSomeValidIDPs := idpFinder.IDPCount() > 0
-
-	return clientDidNotRequestSpecificIDP && clientRequestedBrowserBasedFlow &&
-		!inBackwardsCompatMode && federationDomainSpecHasSomeValidIDPs
+// lookupPushedAuthorizeRequest returns the pushed authorization request referenced by the request_uri param,
+// or nil when the client did not use a pushed authorization request. It does not use up the pushed authorization
+// request, so fosite can still use it up later while handling this request.
+func (h *authorizeHandler) lookupPushedAuthorizeRequest(r *http.Request) (fosite.AuthorizeRequester, error) {
+	requestURI := r.Form.Get(requestURIParamName)
+	if !strings.HasPrefix(requestURI, oidc.PushedAuthorizeRequestURIPrefix) {
+		return nil, nil
+	}
+
+	pushedAuthorizeRequest, err := h.parStorage.GetPARSession(r.Context(), requestURI)
+	if err != nil {
+		// This error message is copied from the similar check in fosite's authorize_request_handler.go.
+		return nil, fosite.ErrInvalidRequestURI.WithHint("Invalid PAR session").WithWrap(err).WithDebug(err.Error())
+	}
+
+	return pushedAuthorizeRequest, nil
+}
+
+func requirePushedAuthorizeRequestWhenConfigured(authorizeRequester fosite.AuthorizeRequester, usedPushedAuthorizeRequest bool) error {
+	if usedPushedAuthorizeRequest {
+		return nil
+	}
+	client, ok := authorizeRequester.GetClient().(*clientregistry.Client)
+	if ok && client.GetRequirePushedAuthorizationRequests() {
+		return fosite.ErrInvalidRequest.WithHint("This client must use a pushed authorization request.")
+	}
+	return nil
 }
 
 func requireStaticClientForUsernameAndPasswordHeaders(authorizeRequester fosite.AuthorizeRequester) error {
@@ -326,40 +374,6 @@ func requireNonEmptyUsernameAndPasswordHeaders(r *http.Request) (string, string,
 	return username, password, nil
 }
 
-func readCSRFCookie(r *http.Request, codec oidc.Decoder) csrftoken.CSRFToken {
-	receivedCSRFCookie, err := r.Cookie(oidc.CSRFCookieName)
-	if err != nil {
-		// Error means that the cookie was not found
-		return ""
-	}
-
-	var csrfFromCookie csrftoken.CSRFToken
-	err = codec.Decode(oidc.CSRFCookieEncodingName, receivedCSRFCookie.Value, &csrfFromCookie)
-	if err != nil {
-		// We can ignore any errors and just make a new cookie. Hopefully this will
-		// make the user experience better if, for example, the server rotated
-		// cookie signing keys and then a user submitted a very old cookie.
-		return ""
-	}
-
-	return csrfFromCookie
-}
-
-// chooseUpstreamIDP selects an upstream IDP, or returns an error.
-func chooseUpstreamIDP(idpDisplayName string, idpLister federationdomainproviders.FederationDomainIdentityProvidersFinderI) (
-	resolvedprovider.FederationDomainResolvedIdentityProvider,
-	error,
-) {
-	// When a request is made to the authorization endpoint which does not specify the IDP name, then it might
-	// be an old dynamic client (OIDCClient). We need to make this work, but only in the backwards compatibility case
-	// where there is exactly one IDP defined in the namespace and no IDPs listed on the FederationDomain.
-	// This backwards compatibility mode is handled by FindDefaultIDP().
-	if len(idpDisplayName) == 0 {
-		return idpLister.FindDefaultIDP()
-	}
-	return idpLister.FindUpstreamIDPByDisplayName(idpDisplayName)
-}
-
 // generateUpstreamAuthorizeRequestState performs the shared validations and setup between browser based
 // auth requests regardless of IDP type.
 // It generates the state param, sets the CSRF cookie, and validates the prompt param.
@@ -401,7 +415,7 @@ func generateUpstreamAuthorizeRequestState(
 		plog.Error("authorize generate error", err)
 		return nil, fosite.ErrServerError.WithHint("Server could not generate necessary values.").WithWrap(err)
 	}
-	csrfFromCookie := readCSRFCookie(r, cookieCodec)
+	csrfFromCookie := upstreamlogin.ReadCSRFCookie(r, cookieCodec)
 	if csrfFromCookie != "" {
 		csrfValue = csrfFromCookie
 	}
@@ -427,7 +441,7 @@ func generateUpstreamAuthorizeRequestState(
 
 	if csrfFromCookie == "" {
 		// We did not receive an incoming CSRF cookie, so write a new one.
-		err = addCSRFSetCookieHeader(w, csrfValue, cookieCodec)
+		err = upstreamlogin.AddCSRFSetCookieHeader(w, csrfValue, cookieCodec)
 		if err != nil {
 			plog.Error("error setting CSRF cookie", err)
 			return nil, fosite.ErrServerError.WithHint("Error encoding CSRF cookie.").WithWrap(err)
@@ -476,7 +490,9 @@ func upstreamStateParam(
 		// that are reading from the encoded upstream state param being built here.
 		// The UpstreamName and UpstreamType struct fields can be used instead.
 		// Remove those params here to avoid potential confusion about which should be used later.
-		AuthParams:    removeCustomIDPParams(authorizeRequester.GetRequestForm()).Encode(),
+		// The auth params might have also included the request_uri of a pushed authorization request, but fosite
+		// has already merged the pushed params into these params and used it up, so remove it too.
+		AuthParams:    removeCustomIDPAndRequestURIParams(authorizeRequester.GetRequestForm()).Encode(),
 		UpstreamName:  upstreamDisplayName,
 		UpstreamType:  upstreamType,
 		Nonce:         nonceValue,
@@ -491,7 +507,7 @@ func upstreamStateParam(
 	return stateparam.Encoded(encodedStateParamValue), nil
 }
 
-func removeCustomIDPParams(params url.Values) url.Values {
+func removeCustomIDPAndRequestURIParams(params url.Values) url.Values {
 	p := url.Values{}
 	// Copy all params.
 	for k, v := range params {
@@ -500,23 +516,6 @@ func removeCustomIDPParams(params url.Values) url.Values {
 	// Remove the unnecessary params.
 	delete(p, oidcapi.AuthorizeUpstreamIDPNameParamName)
 	delete(p, oidcapi.AuthorizeUpstreamIDPTypeParamName)
+	delete(p, requestURIParamName)
 	return p
 }
-
-func addCSRFSetCookieHeader(w http.ResponseWriter, csrfValue csrftoken.CSRFToken, codec oidc.Encoder) error {
-	encodedCSRFValue, err := codec.Encode(oidc.CSRFCookieEncodingName, csrfValue)
-	if err != nil {
-		return fmt.Errorf("error encoding CSRF cookie: %w", err)
-	}
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     oidc.CSRFCookieName,
-		Value:    encodedCSRFValue,
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   true,
-		Path:     "/",
-	})
-
-	return nil
-}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package auth
@@ -6,9 +6,11 @@ package auth
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -26,6 +28,7 @@ import (
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/kubernetes/fake"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	kubetesting "k8s.io/client-go/testing"
 	"k8s.io/utils/ptr"
 
 	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
@@ -38,7 +41,9 @@ import (
 	"go.pinniped.dev/internal/federationdomain/oidcclientvalidator"
 	"go.pinniped.dev/internal/federationdomain/stateparam"
 	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
 	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/idtransform"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/psession"
 	"go.pinniped.dev/internal/testutil"
@@ -61,6 +66,8 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 		activeDirectoryUpstreamResourceUID   = "active-directory-resource-uid"
 		githubUpstreamName                   = "some-github-idp"
 		githubUpstreamResourceUID            = "github-resource-uid"
+		localUserUpstreamName                = "some-localuser-idp"
+		localUserUpstreamResourceUID         = "localuser-resource-uid"
 
 		oidcUpstreamIssuer                    = "https://my-upstream-issuer.com"
 		oidcUpstreamSubject                   = "abc123-some guid" // has a space character which should get escaped in URL
@@ -118,6 +125,19 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			"state":             happyState,
 		}
 
+		fositePushedAuthorizationRequestRequiredErrorQuery = map[string]string{
+			"error":             "invalid_request",
+			"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. This client must use a pushed authorization request.",
+			"state":             happyState,
+		}
+
+		fositeInvalidPushedAuthorizationRequestURIErrorBody = here.Doc(`
+			{
+				"error":             "invalid_request_uri",
+				"error_description": "The request_uri in the Authorization Request returns an error or contains invalid data. Invalid PAR session"
+			}
+		`)
+
 		fositeMissingCodeChallengeErrorQuery = map[string]string{
 			"error":             "invalid_request",
 			"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. Clients must include a code_challenge when performing the authorize code flow, but it is missing.",
@@ -172,6 +192,12 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			"state":             happyState,
 		}
 
+		fositeAccessDeniedWithBadLocalUserUsernamePasswordHintErrorQuery = map[string]string{
+			"error":             "access_denied",
+			"error_description": "The resource owner or authorization server denied the request. Username/password not accepted by local user provider.",
+			"state":             happyState,
+		}
+
 		fositeAccessDeniedWithMissingUsernamePasswordHintErrorQuery = map[string]string{
 			"error":             "access_denied",
 			"error_description": "The resource owner or authorization server denied the request. Missing or blank username or password.",
@@ -278,7 +304,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 	createOauthHelperWithNullStorage := func(secretsClient v1.SecretInterface, oidcClientsClient v1alpha1.OIDCClientInterface) (fosite.OAuth2Provider, *storage.NullStorage) {
 		// Configure fosite the same way that the production code would, using NullStorage to turn off storage.
 		// Use lower minimum required bcrypt cost than we would use in production to keep unit the tests fast.
-		nullOauthStore := storage.NewNullStorage(secretsClient, oidcClientsClient, bcrypt.MinCost)
+		nullOauthStore := storage.NewNullStorage(secretsClient, oidcClientsClient, timeoutsConfiguration, bcrypt.MinCost)
 		return oidc.FositeOauth2Helper(nullOauthStore, downstreamIssuer, hmacSecretFunc, jwksProviderIsUnused, timeoutsConfiguration), nullOauthStore
 	}
 
@@ -390,6 +416,26 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			return nil, false, fmt.Errorf("some ldap upstream auth error")
 		}).Build()
 
+	happyLocalUserUID := "some-local-user-secret-uid"
+	happyLocalUserDownstreamSubject := "localuser://some-localuser-idp?idpName=some-localuser-idp&sub=" + happyLDAPUsername
+
+	upstreamLocalUserIdentityProvider := oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().
+		WithName(localUserUpstreamName).
+		WithResourceUID(localUserUpstreamResourceUID).
+		WithAuthenticateFunc(func(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error) {
+			if username != happyLDAPUsername || password != happyLDAPPassword {
+				return nil, false, nil
+			}
+			return &upstreamprovider.LocalUser{
+				Username:          username,
+				Groups:            happyLDAPGroups,
+				UserUID:           happyLocalUserUID,
+				DownstreamSubject: happyLocalUserDownstreamSubject,
+				AMR:               []string{"pwd"},
+			}, true, nil
+		}).
+		Build()
+
 	happyCSRF := "test-csrf"
 	happyPKCE := "test-pkce"
 	happyNonce := "test-nonce"
@@ -479,6 +525,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 	happyGetRequestPathForLDAPUpstream := modifiedHappyGetRequestPath(map[string]string{"pinniped_idp_name": ldapUpstreamName})
 	happyGetRequestPathForADUpstream := modifiedHappyGetRequestPath(map[string]string{"pinniped_idp_name": activeDirectoryUpstreamName})
 	happyGetRequestPathForGithubUpstream := modifiedHappyGetRequestPath(map[string]string{"pinniped_idp_name": githubUpstreamName})
+	happyGetRequestPathForLocalUserUpstream := modifiedHappyGetRequestPath(map[string]string{"pinniped_idp_name": localUserUpstreamName})
 
 	modifiedHappyGetRequestPathForOIDCUpstream := func(queryOverrides map[string]string) string {
 		queryOverrides["pinniped_idp_name"] = oidcUpstreamName
@@ -564,6 +611,24 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 		return urlWithQuery(upstreamAuthURL.String(), query)
 	}
 
+	// The raw upstream identity data in the session, as it looks after being read back from storage as JSON.
+	happyOIDCPasswordGrantUpstreamData := map[string]any{
+		"iss":                     oidcUpstreamIssuer,
+		"sub":                     oidcUpstreamSubject,
+		oidcUpstreamUsernameClaim: oidcUpstreamUsername,
+		oidcUpstreamGroupsClaim:   []any{oidcUpstreamGroupMembership[0], oidcUpstreamGroupMembership[1]},
+		"other-claim":             "should be ignored",
+	}
+
+	withOIDCPasswordGrantUpstreamData := func(changedClaims map[string]any, removedClaims ...string) map[string]any {
+		upstreamData := maps.Clone(happyOIDCPasswordGrantUpstreamData)
+		maps.Copy(upstreamData, changedClaims)
+		for _, claim := range removedClaims {
+			delete(upstreamData, claim)
+		}
+		return upstreamData
+	}
+
 	expectedHappyActiveDirectoryUpstreamCustomSession := &psession.CustomSessionData{
 		Username:         happyLDAPUsernameFromAuthenticator,
 		UpstreamUsername: happyLDAPUsernameFromAuthenticator,
@@ -579,6 +644,20 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 		},
 	}
 
+	expectedHappyLocalUserUpstreamCustomSession := &psession.CustomSessionData{
+		Username:         happyLDAPUsername,
+		UpstreamUsername: happyLDAPUsername,
+		UpstreamGroups:   happyLDAPGroups,
+		ProviderUID:      localUserUpstreamResourceUID,
+		ProviderName:     localUserUpstreamName,
+		ProviderType:     psession.ProviderTypeLocalUser,
+		LocalUser:        &psession.LocalUserSessionData{UserUID: happyLocalUserUID},
+		UpstreamData: map[string]any{
+			"username": happyLDAPUsername,
+			"groups":   []any{happyLDAPGroups[0], happyLDAPGroups[1], happyLDAPGroups[2]},
+		},
+	}
+
 	expectedHappyLDAPUpstreamCustomSession := &psession.CustomSessionData{
 		Username:         happyLDAPUsernameFromAuthenticator,
 		UpstreamUsername: happyLDAPUsernameFromAuthenticator,
@@ -606,6 +685,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			UpstreamSubject:      oidcUpstreamSubject,
 			UpstreamIssuer:       oidcUpstreamIssuer,
 		},
+		UpstreamData: happyOIDCPasswordGrantUpstreamData,
 	}
 
 	expectedHappyOIDCPasswordGrantCustomSessionWithAccessToken := &psession.CustomSessionData{
@@ -620,6 +700,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			UpstreamSubject:     oidcUpstreamSubject,
 			UpstreamIssuer:      oidcUpstreamIssuer,
 		},
+		UpstreamData: happyOIDCPasswordGrantUpstreamData,
 	}
 
 	withUsernameAndGroupsInCustomSession := func(expectedCustomSessionData *psession.CustomSessionData, wantDownstreamUsername string, wantUpstreamUsername string, wantUpstreamGroups []string) *psession.CustomSessionData {
@@ -642,6 +723,12 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 		return &copyOfCustomSession
 	}
 
+	withUpstreamDataInCustomSession := func(expectedCustomSessionData *psession.CustomSessionData, wantUpstreamData map[string]any) *psession.CustomSessionData {
+		copyOfCustomSession := *expectedCustomSessionData
+		copyOfCustomSession.UpstreamData = wantUpstreamData
+		return &copyOfCustomSession
+	}
+
 	addFullyCapableDynamicClientAndSecretToKubeResources := func(t *testing.T, supervisorClient *supervisorfake.Clientset, kubeClient *fake.Clientset) {
 		oidcClient, secret := testutil.FullyCapableOIDCClientAndStorageSecret(t,
 			"some-namespace", dynamicClientID, dynamicClientUID, downstreamRedirectURI, nil,
@@ -650,6 +737,15 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 		require.NoError(t, kubeClient.Tracker().Add(secret))
 	}
 
+	addDynamicClientWhichRequiresPushedAuthorizationRequestsToKubeResources := func(t *testing.T, supervisorClient *supervisorfake.Clientset, kubeClient *fake.Clientset) {
+		oidcClient, secret := testutil.FullyCapableOIDCClientAndStorageSecret(t,
+			"some-namespace", dynamicClientID, dynamicClientUID, downstreamRedirectURI, nil,
+			[]string{testutil.HashedPassword1AtGoMinCost}, oidcclientvalidator.Validate)
+		oidcClient.Spec.RequirePushedAuthorizationRequests = true
+		require.NoError(t, supervisorClient.Tracker().Add(oidcClient))
+		require.NoError(t, kubeClient.Tracker().Add(secret))
+	}
+
 	// Note that fosite puts the granted scopes as a param in the redirect URI even though the spec doesn't seem to require it
 	happyAuthcodeDownstreamRedirectLocationRegexp := downstreamRedirectURI + `\?code=([^&]+)&scope=openid\+username\+groups&state=` + happyState
 
@@ -706,6 +802,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 		wantPasswordGrantCall             *expectedPasswordGrant
 		wantDownstreamCustomSessionData   *psession.CustomSessionData
 		wantDownstreamAdditionalClaims    map[string]any
+		wantDownstreamAuthnContext        *idtransform.AuthenticationContext
 	}
 	tests := []testCase{
 		{
@@ -803,6 +900,36 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 				}
 			},
 		},
+		{
+			name:               "OIDC upstream browser flow using a dynamic client which requires pushed authorization requests without using one",
+			idps:               testidplister.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			kubeResources:      addDynamicClientWhichRequiresPushedAuthorizationRequestsToKubeResources,
+			generateCSRF:       happyCSRFGenerator,
+			generatePKCE:       happyPKCEGenerator,
+			generateNonce:      happyNonceGenerator,
+			stateEncoder:       happyStateEncoder,
+			cookieEncoder:      happyCookieEncoder,
+			method:             http.MethodGet,
+			path:               modifiedHappyGetRequestPathForOIDCUpstream(map[string]string{"client_id": dynamicClientID, "scope": testutil.AllDynamicClientScopesSpaceSep}),
+			wantStatus:         http.StatusSeeOther,
+			wantContentType:    jsonContentType,
+			wantLocationHeader: urlWithQuery(downstreamRedirectURI, fositePushedAuthorizationRequestRequiredErrorQuery),
+			wantBodyString:     "",
+		},
+		{
+			name:            "OIDC upstream browser flow using a request_uri which does not refer to any pushed authorization request",
+			idps:            testidplister.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
+			generateCSRF:    happyCSRFGenerator,
+			generatePKCE:    happyPKCEGenerator,
+			generateNonce:   happyNonceGenerator,
+			stateEncoder:    happyStateEncoder,
+			cookieEncoder:   happyCookieEncoder,
+			method:          http.MethodGet,
+			path:            pathWithQuery("/some/path", map[string]string{"client_id": pinnipedCLIClientID, "request_uri": "urn:ietf:params:oauth:request_uri:does-not-exist"}),
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: jsonContentType,
+			wantBodyJSON:    fositeInvalidPushedAuthorizationRequestURIErrorBody,
+		},
 		{
 			name:                                   "GitHub upstream browser flow happy path using GET without a CSRF cookie",
 			idps:                                   testidplister.NewUpstreamIDPListerBuilder().WithGitHub(upstreamGitHubIdentityProviderBuilder().Build()),
@@ -1331,7 +1458,13 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData:   expectedHappyOIDCPasswordGrantCustomSession,
+			wantDownstreamCustomSessionData: withUpstreamDataInCustomSession(
+				expectedHappyOIDCPasswordGrantCustomSession,
+				withOIDCPasswordGrantUpstreamData(map[string]any{
+					"upstreamCustomClaim": "i am a claim value",
+					"upstreamOtherClaim":  []any{"hello", true},
+				}),
+			),
 			wantDownstreamAdditionalClaims: map[string]any{
 				"downstreamCustomClaim": "i am a claim value",
 				"downstreamOtherClaim":  []any{"hello", true},
@@ -1362,7 +1495,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData:   expectedHappyOIDCPasswordGrantCustomSession,
+			wantDownstreamCustomSessionData:   withUpstreamDataInCustomSession(expectedHappyOIDCPasswordGrantCustomSession, withOIDCPasswordGrantUpstreamData(map[string]any{"not-upstream": "value"})),
 			wantDownstreamAdditionalClaims:    nil, // downstream claims are empty
 		},
 		{
@@ -1541,6 +1674,28 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
 			wantDownstreamCustomSessionData:   expectedHappyActiveDirectoryUpstreamCustomSession,
 		},
+		{
+			name:                              "LocalUser cli upstream happy path using GET",
+			idps:                              testidplister.NewUpstreamIDPListerBuilder().WithLocalUser(upstreamLocalUserIdentityProvider),
+			method:                            http.MethodGet,
+			path:                              happyGetRequestPathForLocalUserUpstream,
+			customUsernameHeader:              ptr.To(happyLDAPUsername),
+			customPasswordHeader:              ptr.To(happyLDAPPassword),
+			wantStatus:                        http.StatusFound,
+			wantContentType:                   htmlContentType,
+			wantRedirectLocationRegexp:        happyAuthcodeDownstreamRedirectLocationRegexp,
+			wantDownstreamIDTokenSubject:      happyLocalUserDownstreamSubject,
+			wantDownstreamIDTokenUsername:     happyLDAPUsername,
+			wantDownstreamIDTokenGroups:       happyLDAPGroups,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamRedirectURI:         downstreamRedirectURI,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData:   expectedHappyLocalUserUpstreamCustomSession,
+			wantDownstreamAuthnContext:        &idtransform.AuthenticationContext{AMR: []string{"pwd"}},
+		},
 		{
 			name:                                   "OIDC upstream browser flow happy path using GET with a CSRF cookie",
 			idps:                                   testidplister.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().Build()),
@@ -2108,6 +2263,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 					UpstreamSubject:     oidcUpstreamSubject,
 					UpstreamIssuer:      oidcUpstreamIssuer,
 				},
+				UpstreamData: happyOIDCPasswordGrantUpstreamData,
 			},
 		},
 		{
@@ -2258,6 +2414,18 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantLocationHeader:   urlWithQuery(downstreamRedirectURI, fositeAccessDeniedWithBadUsernamePasswordHintErrorQuery),
 			wantBodyString:       "",
 		},
+		{
+			name:                 "wrong upstream password for LocalUser authentication",
+			idps:                 testidplister.NewUpstreamIDPListerBuilder().WithLocalUser(upstreamLocalUserIdentityProvider),
+			method:               http.MethodGet,
+			path:                 happyGetRequestPathForLocalUserUpstream,
+			customUsernameHeader: ptr.To(happyLDAPUsername),
+			customPasswordHeader: ptr.To("wrong-password"),
+			wantStatus:           http.StatusFound,
+			wantContentType:      jsonContentType,
+			wantLocationHeader:   urlWithQuery(downstreamRedirectURI, fositeAccessDeniedWithBadLocalUserUsernamePasswordHintErrorQuery),
+			wantBodyString:       "",
+		},
 		{
 			name:                 "wrong upstream username for LDAP authentication",
 			idps:                 testidplister.NewUpstreamIDPListerBuilder().WithLDAP(upstreamLDAPIdentityProviderBuilder().Build()),
@@ -3424,11 +3592,14 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: withUsernameAndGroupsInCustomSession(
-				expectedHappyOIDCPasswordGrantCustomSession,
-				"joe@whitehouse.gov",
-				"joe@whitehouse.gov",
-				oidcUpstreamGroupMembership,
+			wantDownstreamCustomSessionData: withUpstreamDataInCustomSession(
+				withUsernameAndGroupsInCustomSession(
+					expectedHappyOIDCPasswordGrantCustomSession,
+					"joe@whitehouse.gov",
+					"joe@whitehouse.gov",
+					oidcUpstreamGroupMembership,
+				),
+				withOIDCPasswordGrantUpstreamData(map[string]any{"email": "joe@whitehouse.gov"}),
 			),
 		},
 		{
@@ -3456,11 +3627,14 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: withUsernameAndGroupsInCustomSession(
-				expectedHappyOIDCPasswordGrantCustomSession,
-				"joe@whitehouse.gov",
-				"joe@whitehouse.gov",
-				oidcUpstreamGroupMembership,
+			wantDownstreamCustomSessionData: withUpstreamDataInCustomSession(
+				withUsernameAndGroupsInCustomSession(
+					expectedHappyOIDCPasswordGrantCustomSession,
+					"joe@whitehouse.gov",
+					"joe@whitehouse.gov",
+					oidcUpstreamGroupMembership,
+				),
+				withOIDCPasswordGrantUpstreamData(map[string]any{"email": "joe@whitehouse.gov", "email_verified": true}),
 			),
 		},
 		{
@@ -3489,11 +3663,14 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: withUsernameAndGroupsInCustomSession(
-				expectedHappyOIDCPasswordGrantCustomSession,
-				"joe",
-				"joe",
-				oidcUpstreamGroupMembership,
+			wantDownstreamCustomSessionData: withUpstreamDataInCustomSession(
+				withUsernameAndGroupsInCustomSession(
+					expectedHappyOIDCPasswordGrantCustomSession,
+					"joe",
+					"joe",
+					oidcUpstreamGroupMembership,
+				),
+				withOIDCPasswordGrantUpstreamData(map[string]any{"some-claim": "joe", "email": "joe@whitehouse.gov", "email_verified": false}),
 			),
 		},
 		{
@@ -3584,11 +3761,14 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: withUsernameAndGroupsInCustomSession(
-				expectedHappyOIDCPasswordGrantCustomSession,
-				oidcUpstreamUsername,
-				oidcUpstreamUsername,
-				[]string{"notAnArrayGroup1 notAnArrayGroup2"},
+			wantDownstreamCustomSessionData: withUpstreamDataInCustomSession(
+				withUsernameAndGroupsInCustomSession(
+					expectedHappyOIDCPasswordGrantCustomSession,
+					oidcUpstreamUsername,
+					oidcUpstreamUsername,
+					[]string{"notAnArrayGroup1 notAnArrayGroup2"},
+				),
+				withOIDCPasswordGrantUpstreamData(map[string]any{oidcUpstreamGroupsClaim: "notAnArrayGroup1 notAnArrayGroup2"}),
 			),
 		},
 		{
@@ -3614,11 +3794,14 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: withUsernameAndGroupsInCustomSession(
-				expectedHappyOIDCPasswordGrantCustomSession,
-				oidcUpstreamUsername,
-				oidcUpstreamUsername,
-				[]string{"group1", "group2"},
+			wantDownstreamCustomSessionData: withUpstreamDataInCustomSession(
+				withUsernameAndGroupsInCustomSession(
+					expectedHappyOIDCPasswordGrantCustomSession,
+					oidcUpstreamUsername,
+					oidcUpstreamUsername,
+					[]string{"group1", "group2"},
+				),
+				withOIDCPasswordGrantUpstreamData(map[string]any{oidcUpstreamGroupsClaim: []any{"group1", "group2"}}),
 			),
 		},
 		{
@@ -3658,11 +3841,14 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantDownstreamNonce:               downstreamNonce,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: withUsernameAndGroupsInCustomSession(
-				expectedHappyOIDCPasswordGrantCustomSession,
-				oidcUpstreamUsername,
-				oidcUpstreamUsername,
-				nil,
+			wantDownstreamCustomSessionData: withUpstreamDataInCustomSession(
+				withUsernameAndGroupsInCustomSession(
+					expectedHappyOIDCPasswordGrantCustomSession,
+					oidcUpstreamUsername,
+					oidcUpstreamUsername,
+					nil,
+				),
+				withOIDCPasswordGrantUpstreamData(nil, oidcUpstreamGroupsClaim),
 			),
 		},
 		{
@@ -3960,6 +4146,43 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			wantLocationHeader: urlWithQuery(downstreamRedirectURI, fositeInternalServerErrorQueryWithHint("Server could not generate necessary values.")),
 			wantBodyString:     "",
 		},
+		{
+			name: "OIDC upstream browser flow using a pushed authorization request",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().
+				WithPreparedAuthorizationRequestParams(url.Values{
+					"client_id":   []string{"some-client-id"},
+					"request_uri": []string{"urn:ietf:params:oauth:request_uri:some-request"},
+				}).Build()),
+			generateCSRF:                     happyCSRFGenerator,
+			generatePKCE:                     happyPKCEGenerator,
+			generateNonce:                    happyNonceGenerator,
+			stateEncoder:                     happyStateEncoder,
+			cookieEncoder:                    happyCookieEncoder,
+			method:                           http.MethodGet,
+			path:                             happyGetRequestPathForOIDCUpstream,
+			wantStatus:                       http.StatusSeeOther,
+			wantContentType:                  htmlContentType,
+			wantCSRFValueInCookieHeader:      happyCSRF,
+			wantLocationHeader:               upstreamAuthURL.String() + "?client_id=some-client-id&request_uri=urn%3Aietf%3Aparams%3Aoauth%3Arequest_uri%3Asome-request",
+			wantBodyStringWithLocationInHref: true,
+		},
+		{
+			name: "error while preparing the upstream authorization request using OIDC upstream browser flow",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(upstreamOIDCIdentityProviderBuilder().
+				WithPrepareAuthorizationRequestError(errors.New("some pushed authorization request error")).Build()),
+			generateCSRF:                happyCSRFGenerator,
+			generatePKCE:                happyPKCEGenerator,
+			generateNonce:               happyNonceGenerator,
+			stateEncoder:                happyStateEncoder,
+			cookieEncoder:               happyCookieEncoder,
+			method:                      http.MethodGet,
+			path:                        happyGetRequestPathForOIDCUpstream,
+			wantStatus:                  http.StatusSeeOther,
+			wantContentType:             jsonContentType,
+			wantCSRFValueInCookieHeader: happyCSRF,
+			wantLocationHeader:          urlWithQuery(downstreamRedirectURI, fositeInternalServerErrorQueryWithHint("Server could not prepare upstream authorization request.")),
+			wantBodyString:              "",
+		},
 		{
 			name:            "no default upstream provider is configured and no specific IDP was requested in the request params",
 			idps:            testidplister.NewUpstreamIDPListerBuilder().WithOIDC(), // empty
@@ -4158,7 +4381,8 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			// OIDC validations are checked in fosite after the OAuth authcode (and sometimes the OIDC session)
 			// is stored, so it is possible with an LDAP upstream to store objects and then return an error to
 			// the client anyway (which makes the stored objects useless, but oh well).
-			require.Len(t, oidctestutil.FilterClientSecretCreateActions(kubeClient.Actions()), test.wantUnnecessaryStoredRecords)
+			// Reading and using up a pushed authorization request is not considered to be storing anything here.
+			require.Len(t, filterPushedAuthorizeRequestActions(oidctestutil.FilterClientSecretCreateActions(kubeClient.Actions())), test.wantUnnecessaryStoredRecords)
 		case test.wantRedirectLocationRegexp != "":
 			if test.wantDownstreamClientID == "" {
 				test.wantDownstreamClientID = pinnipedCLIClientID // default assertion value when not provided by test case
@@ -4183,6 +4407,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 				test.wantDownstreamRedirectURI,
 				test.wantDownstreamCustomSessionData,
 				test.wantDownstreamAdditionalClaims,
+				test.wantDownstreamAuthnContext,
 			)
 		default:
 			require.Empty(t, rsp.Header().Values("Location"))
@@ -4255,6 +4480,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 				downstreamIssuer,
 				idps,
 				oauthHelperWithNullStorage, oauthHelperWithRealStorage,
+				kubeOauthStore,
 				test.generateCSRF, test.generatePKCE, test.generateNonce,
 				test.stateEncoder, test.cookieEncoder,
 				auditLogger,
@@ -4280,6 +4506,7 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 			downstreamIssuer,
 			idpLister,
 			oauthHelperWithNullStorage, oauthHelperWithRealStorage,
+			kubeOauthStore,
 			test.generateCSRF, test.generatePKCE, test.generateNonce,
 			test.stateEncoder, test.cookieEncoder,
 			auditLogger,
@@ -4363,6 +4590,112 @@ func TestAuthorizationEndpoint(t *testing.T) { //nolint:gocyclo
 		// on every request.
 		runOneTestCase(t, test, subject, kubeOauthStore, supervisorClient, kubeClient, secretsClient, actualAuditLog)
 	})
+
+	t.Run("uses the params of a pushed authorization request, and uses it up", func(t *testing.T) {
+		test := tests[0]
+		// Double-check that we are re-using the happy path test case here as we intend.
+		require.Equal(t, "OIDC upstream browser flow happy path using GET without a CSRF cookie", test.name)
+
+		kubeClient := fake.NewSimpleClientset()
+		supervisorClient := supervisorfake.NewSimpleClientset()
+		secretsClient := kubeClient.CoreV1().Secrets("some-namespace")
+		oidcClientsClient := supervisorClient.ConfigV1alpha1().OIDCClients("some-namespace")
+		oauthHelperWithRealStorage, kubeOauthStore := createOauthHelperWithRealStorage(secretsClient, oidcClientsClient)
+		oauthHelperWithNullStorage, _ := createOauthHelperWithNullStorage(secretsClient, oidcClientsClient)
+		idpLister := test.idps.BuildFederationDomainIdentityProvidersListerFinder()
+		auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+		subject := NewHandler(
+			downstreamIssuer,
+			idpLister,
+			oauthHelperWithNullStorage, oauthHelperWithRealStorage,
+			kubeOauthStore,
+			test.generateCSRF, test.generatePKCE, test.generateNonce,
+			test.stateEncoder, test.cookieEncoder,
+			auditLogger,
+		)
+
+		// Push the same params that the happy path test case would have sent to the authorization endpoint.
+		pushRequest := httptest.NewRequest(http.MethodPost, "/some/par/path",
+			strings.NewReader(encodeQuery(happyGetRequestQueryMapForOIDCUpstream)))
+		pushRequest.Header.Set("Content-Type", formContentType)
+		pushResponse := httptest.NewRecorder()
+		NewPushedAuthorizeHandler(idpLister, oauthHelperWithRealStorage, auditLogger).ServeHTTP(pushResponse, pushRequest)
+		require.Equal(t, http.StatusCreated, pushResponse.Code, pushResponse.Body.String())
+		var pushResponseBody struct {
+			RequestURI string `json:"request_uri"`
+		}
+		require.NoError(t, json.Unmarshal(pushResponse.Body.Bytes(), &pushResponseBody))
+		require.True(t, strings.HasPrefix(pushResponseBody.RequestURI, "urn:ietf:params:oauth:request_uri:"))
+		actualAuditLog.Reset() // only check the audit logs of the authorize calls below
+		kubeClient.ClearActions()
+
+		test.path = pathWithQuery("/some/path", map[string]string{
+			"client_id":   pinnipedCLIClientID,
+			"request_uri": pushResponseBody.RequestURI,
+		})
+		test.wantAuditLogs = func(encodedStateParam stateparam.Encoded, sessionID string) []testutil.WantedAuditLog {
+			return []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"client_id":   "pinniped-cli",
+						"request_uri": pushResponseBody.RequestURI,
+					},
+				}),
+				testutil.WantAuditLog("HTTP Request Custom Headers Used", map[string]any{
+					"Pinniped-Username": false,
+					"Pinniped-Password": false,
+				}),
+				testutil.WantAuditLog("Using Upstream IDP", map[string]any{
+					"displayName":  "some-oidc-idp",
+					"resourceName": "some-oidc-idp",
+					"resourceUID":  "oidc-resource-uid",
+					"type":         "oidc",
+				}),
+				testutil.WantAuditLog("Upstream Authorize Redirect", map[string]any{
+					"authorizeID": encodedStateParam.AuthorizeID(),
+				}),
+			}
+		}
+
+		// The pushed params are used, and the request_uri param is not saved into the upstream state param.
+		runOneTestCase(t, test, subject, kubeOauthStore, supervisorClient, kubeClient, secretsClient, actualAuditLog)
+		actualAuditLog.Reset() // clear the log for the next authorize call
+
+		// The pushed authorization request was used up, so it cannot be used again.
+		test.wantStatus = http.StatusBadRequest
+		test.wantContentType = jsonContentType
+		test.wantCSRFValueInCookieHeader = ""
+		test.wantLocationHeader = ""
+		test.wantUpstreamStateParamInLocationHeader = false
+		test.wantBodyStringWithLocationInHref = false
+		test.wantBodyJSON = fositeInvalidPushedAuthorizationRequestURIErrorBody
+		test.wantAuditLogs = func(encodedStateParam stateparam.Encoded, sessionID string) []testutil.WantedAuditLog {
+			return []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"client_id":   "pinniped-cli",
+						"request_uri": pushResponseBody.RequestURI,
+					},
+				}),
+				testutil.WantAuditLog("HTTP Request Custom Headers Used", map[string]any{
+					"Pinniped-Username": false,
+					"Pinniped-Password": false,
+				}),
+			}
+		}
+		runOneTestCase(t, test, subject, kubeOauthStore, supervisorClient, kubeClient, secretsClient, actualAuditLog)
+	})
+}
+
+func filterPushedAuthorizeRequestActions(actions []kubetesting.Action) []kubetesting.Action {
+	filtered := make([]kubetesting.Action, 0, len(actions))
+	for _, action := range actions {
+		if a, ok := action.(interface{ GetName() string }); ok && strings.HasPrefix(a.GetName(), "pinniped-storage-pushed-auth-request-") {
+			continue // filter out reads and deletes of pushed authorization requests
+		}
+		filtered = append(filtered, action)
+	}
+	return filtered
 }
 
 type errorReturningEncoder struct {
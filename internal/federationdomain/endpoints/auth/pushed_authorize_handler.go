@@ -0,0 +1,78 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"net/http"
+
+	"github.com/ory/fosite"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/federationdomain/endpoints/upstreamlogin"
+	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+// NewPushedAuthorizeHandler returns a http.Handler that serves the pushed authorization request endpoint from
+// https://datatracker.ietf.org/doc/html/rfc9126. After authenticating the client, the authorization request
+// params are validated in the same way as the authorization endpoint would validate them, and then they are
+// stored. The client receives a request_uri, which it sends to the authorization endpoint instead of the params.
+func NewPushedAuthorizeHandler(
+	idpFinder federationdomainproviders.FederationDomainIdentityProvidersFinderI,
+	oauthHelper fosite.OAuth2Provider,
+	auditLogger plog.AuditLogger,
+) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if err := auditLogger.AuditRequestParams(r, paramsSafeToLog()); err != nil {
+			oauthHelper.WritePushedAuthorizeError(r.Context(), w, fosite.NewAuthorizeRequest(), err)
+			return nil
+		}
+
+		// For dynamic clients, the client ID is from basic auth, not from the request parameters.
+		if clientID, _, basicAuthUsed := r.BasicAuth(); basicAuthUsed {
+			auditLogger.Audit(auditevent.HTTPRequestBasicAuthUsed, &plog.AuditParams{
+				ReqCtx:        r.Context(),
+				KeysAndValues: []any{"clientID", clientID},
+			})
+		}
+
+		// Validates that the request is a POST, authenticates the client, and validates the authorization
+		// request params, e.g. the redirect URI, response type, scopes, and PKCE params.
+		authorizeRequester, err := oauthHelper.NewPushedAuthorizeRequest(r.Context(), r)
+		if err != nil {
+			plog.Info("pushed authorize request error", oidc.FositeErrorForLog(err)...)
+			oauthHelper.WritePushedAuthorizeError(r.Context(), w, authorizeRequester, err)
+			return nil
+		}
+
+		// When the client chose an identity provider, then it should exist, or else the authorization endpoint
+		// would reject this request later. The IDP chooser page may be shown later when no IDP was chosen.
+		if idpName := authorizeRequester.GetRequestForm().Get(oidcapi.AuthorizeUpstreamIDPNameParamName); idpName != "" {
+			if _, err = upstreamlogin.ChooseUpstreamIDP(idpName, idpFinder); err != nil {
+				oauthHelper.WritePushedAuthorizeError(r.Context(), w, authorizeRequester,
+					fosite.ErrInvalidRequest.
+						WithHintf("%q param error: %s", oidcapi.AuthorizeUpstreamIDPNameParamName, err.Error()).
+						WithWrap(err).WithDebug(err.Error()))
+				return nil
+			}
+		}
+
+		// Store the request, which will hold an empty session until the end user has authenticated
+		// after the request_uri is used at the authorization endpoint.
+		pushedAuthorizeResponder, err := oauthHelper.NewPushedAuthorizeResponse(r.Context(), authorizeRequester, psession.NewPinnipedSession())
+		if err != nil {
+			plog.Info("pushed authorize response error", oidc.FositeErrorForLog(err)...)
+			oauthHelper.WritePushedAuthorizeError(r.Context(), w, authorizeRequester, err)
+			return nil
+		}
+
+		oauthHelper.WritePushedAuthorizeResponse(r.Context(), w, authorizeRequester, pushedAuthorizeResponder)
+
+		return nil
+	})
+}
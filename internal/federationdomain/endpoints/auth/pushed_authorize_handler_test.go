@@ -0,0 +1,217 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+	"go.pinniped.dev/internal/testutil/testidplister"
+)
+
+func TestPushedAuthorizeHandler(t *testing.T) {
+	const (
+		downstreamIssuer = "https://my-downstream-issuer.com/some-path"
+		oidcUpstreamName = "some-oidc-idp"
+	)
+
+	happyParams := func() url.Values {
+		return url.Values{
+			"response_type":         []string{"code"},
+			"scope":                 []string{"openid username groups"},
+			"client_id":             []string{"pinniped-cli"},
+			"state":                 []string{"8b-state"},
+			"nonce":                 []string{"some-nonce-value"},
+			"code_challenge":        []string{"some-challenge"},
+			"code_challenge_method": []string{"S256"},
+			"redirect_uri":          []string{"http://127.0.0.1/callback"},
+		}
+	}
+
+	modifiedHappyParams := func(overrides url.Values) url.Values {
+		params := happyParams()
+		for k, v := range overrides {
+			params[k] = v
+		}
+		return params
+	}
+
+	happyParamsAuditLog := func(overrides map[string]any) []testutil.WantedAuditLog {
+		params := map[string]any{
+			"client_id":             "pinniped-cli",
+			"code_challenge":        "redacted",
+			"code_challenge_method": "S256",
+			"nonce":                 "redacted",
+			"redirect_uri":          "http://127.0.0.1/callback",
+			"response_type":         "code",
+			"scope":                 "openid username groups",
+			"state":                 "redacted",
+		}
+		for k, v := range overrides {
+			params[k] = v
+		}
+		return []testutil.WantedAuditLog{
+			testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": params}),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   url.Values
+
+		wantStatus    int
+		wantBodyJSON  string
+		wantAuditLogs []testutil.WantedAuditLog
+	}{
+		{
+			name:          "happy path",
+			method:        http.MethodPost,
+			body:          happyParams(),
+			wantStatus:    http.StatusCreated,
+			wantAuditLogs: happyParamsAuditLog(nil),
+		},
+		{
+			name:          "happy path with an identity provider name",
+			method:        http.MethodPost,
+			body:          modifiedHappyParams(url.Values{"pinniped_idp_name": []string{oidcUpstreamName}}),
+			wantStatus:    http.StatusCreated,
+			wantAuditLogs: happyParamsAuditLog(map[string]any{"pinniped_idp_name": oidcUpstreamName}),
+		},
+		{
+			name:       "wrong HTTP method",
+			method:     http.MethodGet,
+			body:       happyParams(),
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_request",
+				"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. HTTP method is 'GET', expected 'POST'."
+			}`,
+			wantAuditLogs: happyParamsAuditLog(nil),
+		},
+		{
+			name:       "unknown client",
+			method:     http.MethodPost,
+			body:       modifiedHappyParams(url.Values{"client_id": []string{"some-unknown-client"}}),
+			wantStatus: http.StatusUnauthorized,
+			wantBodyJSON: `{
+				"error":             "invalid_client",
+				"error_description": "Client authentication failed (e.g., unknown client, no client authentication included, or unsupported authentication method)."
+			}`,
+			wantAuditLogs: happyParamsAuditLog(map[string]any{"client_id": "some-unknown-client"}),
+		},
+		{
+			name:       "redirect URI not allowed for client",
+			method:     http.MethodPost,
+			body:       modifiedHappyParams(url.Values{"redirect_uri": []string{"https://some-other-host/callback"}}),
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_request",
+				"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. The 'redirect_uri' parameter does not match any of the OAuth 2.0 Client's pre-registered redirect urls."
+			}`,
+			wantAuditLogs: happyParamsAuditLog(map[string]any{"redirect_uri": "https://some-other-host/callback"}),
+		},
+		{
+			name:       "request_uri param is not allowed",
+			method:     http.MethodPost,
+			body:       modifiedHappyParams(url.Values{"request_uri": []string{"urn:ietf:params:oauth:request_uri:some-request-uri"}}),
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_request",
+				"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. The request must not contain 'request_uri'."
+			}`,
+			wantAuditLogs: happyParamsAuditLog(map[string]any{"request_uri": "urn:ietf:params:oauth:request_uri:some-request-uri"}),
+		},
+		{
+			name:       "unknown identity provider name",
+			method:     http.MethodPost,
+			body:       modifiedHappyParams(url.Values{"pinniped_idp_name": []string{"some-unknown-idp"}}),
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_request",
+				"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. 'pinniped_idp_name' param error: did not find IDP with name 'some-unknown-idp'"
+			}`,
+			wantAuditLogs: happyParamsAuditLog(map[string]any{"pinniped_idp_name": "some-unknown-idp"}),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secrets := fake.NewSimpleClientset().CoreV1().Secrets("some-namespace")
+			oidcClientsClient := supervisorfake.NewSimpleClientset().ConfigV1alpha1().OIDCClients("some-namespace")
+			// Use lower minimum required bcrypt cost than we would use in production to keep unit the tests fast.
+			kubeOauthStore := storage.NewKubeStorage(secrets, oidcClientsClient, oidc.DefaultOIDCTimeoutsConfiguration(), bcrypt.MinCost)
+			hmacSecretFunc := func() []byte { return []byte("some secret - must have at least 32 bytes") }
+			oauthHelper := oidc.FositeOauth2Helper(kubeOauthStore, downstreamIssuer, hmacSecretFunc, nil, oidc.DefaultOIDCTimeoutsConfiguration())
+
+			idpFinder := testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().WithName(oidcUpstreamName).Build()).
+				BuildFederationDomainIdentityProvidersListerFinder()
+
+			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+			subject := NewPushedAuthorizeHandler(idpFinder, oauthHelper, auditLogger)
+
+			var req *http.Request
+			if test.method == http.MethodPost {
+				req = httptest.NewRequest(test.method, downstreamIssuer+oidc.PushedAuthorizeEndpointPath, strings.NewReader(test.body.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(test.method, downstreamIssuer+oidc.PushedAuthorizeEndpointPath+"?"+test.body.Encode(), nil)
+			}
+			rsp := httptest.NewRecorder()
+
+			subject.ServeHTTP(rsp, req)
+			t.Logf("response: %#v", rsp)
+			t.Logf("response body: %q", rsp.Body.String())
+
+			require.Equal(t, test.wantStatus, rsp.Code)
+			require.Equal(t, "application/json;charset=UTF-8", rsp.Header().Get("Content-Type"))
+
+			testutil.CompareAuditLogs(t, test.wantAuditLogs, actualAuditLog.String())
+
+			allSecrets, err := secrets.List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+
+			if test.wantBodyJSON != "" {
+				require.JSONEq(t, test.wantBodyJSON, rsp.Body.String())
+				require.Empty(t, allSecrets.Items)
+				return
+			}
+
+			var body struct {
+				RequestURI string `json:"request_uri"`
+				ExpiresIn  int    `json:"expires_in"`
+			}
+			require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &body))
+
+			require.True(t, strings.HasPrefix(body.RequestURI, oidc.PushedAuthorizeRequestURIPrefix), "unexpected request_uri %q", body.RequestURI)
+			require.Equal(t, int(oidc.DefaultOIDCTimeoutsConfiguration().PushedAuthorizeRequestLifespan.Seconds()), body.ExpiresIn)
+
+			// The pushed params should have been stored, so they can be used later by the authorization endpoint.
+			require.Len(t, allSecrets.Items, 1)
+			require.Equal(t, pushedauthorizerequest.TypeLabelValue, allSecrets.Items[0].Labels["storage.pinniped.dev/type"])
+
+			pushedAuthorizeRequest, err := kubeOauthStore.GetPARSession(context.Background(), body.RequestURI)
+			require.NoError(t, err)
+			require.Equal(t, "pinniped-cli", pushedAuthorizeRequest.GetClient().GetID())
+			require.Equal(t, test.body, pushedAuthorizeRequest.GetRequestForm())
+		})
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package callback provides a handler for the OIDC callback endpoint.
@@ -15,20 +15,27 @@ import (
 
 	"go.pinniped.dev/internal/auditevent"
 	"go.pinniped.dev/internal/federationdomain/downstreamsession"
+	"go.pinniped.dev/internal/federationdomain/endpoints/device"
 	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
 	"go.pinniped.dev/internal/federationdomain/formposthtml"
 	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/samlposthtml"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/httputil/securityheader"
 	"go.pinniped.dev/internal/plog"
 )
 
+// samlResponseParamName is the name of the form param which is posted by SAML identity providers.
+const samlResponseParamName = "SAMLResponse"
+
 func paramsSafeToLog() sets.Set[string] {
 	return sets.New[string](
 		// Due to https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1,
 		// authorize errors can have these parameters, which should not contain PII or secrets and are safe to log.
 		"error", "error_description", "error_uri",
 		// Note that this endpoint also receives 'code' and 'state' params, which are not safe to log.
+		// For SAML identity providers, it receives 'SAMLResponse' and 'RelayState' params, which are also not safe to log.
 	)
 }
 
@@ -37,6 +44,7 @@ func NewHandler(
 	oauthHelper fosite.OAuth2Provider,
 	stateDecoder, cookieDecoder oidc.Decoder,
 	redirectURI string,
+	deviceApprover *device.Approver,
 	auditLogger plog.AuditLogger,
 ) http.Handler {
 	handler := httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
@@ -45,6 +53,10 @@ func NewHandler(
 			return httperr.New(http.StatusBadRequest, "error parsing request params")
 		}
 
+		if needsSAMLResubmit(r) {
+			return writeSAMLResubmitPage(w, r, redirectURI)
+		}
+
 		decodedState, err := validateRequest(r, stateDecoder, cookieDecoder, auditLogger)
 		if err != nil {
 			return err
@@ -66,6 +78,12 @@ func NewHandler(
 			},
 		})
 
+		if decodedState.DeviceUserCodeSignature != "" {
+			// This login was started at the device verification endpoint, so there is no downstream
+			// authorization request. Instead, approve the device's pending device authorization request.
+			return finishDeviceLogin(w, r, decodedState, idp, redirectURI, deviceApprover)
+		}
+
 		downstreamAuthParams, err := url.ParseQuery(decodedState.AuthParams)
 		if err != nil {
 			plog.Error("error reading state downstream auth params", err)
@@ -103,6 +121,8 @@ func NewHandler(
 			UpstreamLoginExtras: loginExtras,
 			ClientID:            authorizeRequester.GetClient().GetID(),
 			GrantedScopes:       authorizeRequester.GetGrantedScopes(),
+			RequestedScopes:     authorizeRequester.GetRequestedScopes(),
+			RequestedAudience:   authorizeRequester.GetRequestedAudience(),
 			IdentityProvider:    idp,
 			SessionIDGetter:     authorizeRequester,
 		})
@@ -131,16 +151,71 @@ func NewHandler(
 	return securityheader.WrapWithCustomCSP(handler, formposthtml.ContentSecurityPolicy())
 }
 
+func finishDeviceLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	decodedState *oidc.UpstreamStateParamData,
+	idp resolvedprovider.FederationDomainResolvedIdentityProvider,
+	redirectURI string,
+	deviceApprover *device.Approver,
+) error {
+	identity, loginExtras, err := idp.LoginFromCallback(r.Context(), authcode(r), decodedState.PKCECode, decodedState.Nonce, redirectURI)
+	if err != nil {
+		plog.WarningErr("unable to complete device login from callback", err,
+			"identityProviderDisplayName", idp.GetDisplayName(),
+			"identityProviderResourceName", idp.GetProvider().GetResourceName(),
+			"supervisorCallbackURL", redirectURI)
+		return err
+	}
+
+	return deviceApprover.Approve(w, r, decodedState.DeviceUserCodeSignature, idp, identity, loginExtras)
+}
+
 func authcode(r *http.Request) string {
 	if r.Method == http.MethodPost {
+		if samlResponse := r.PostFormValue(samlResponseParamName); samlResponse != "" {
+			// SAML identity providers post a SAMLResponse instead of returning an authcode.
+			return samlResponse
+		}
 		return r.PostFormValue("code")
 	}
 
 	return r.FormValue("code")
 }
 
+// isSAMLPost returns true when the request is a SAML identity provider's response using the HTTP-POST binding.
+func isSAMLPost(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.PostFormValue(samlResponseParamName) != ""
+}
+
+// needsSAMLResubmit returns true when the request is a SAML response which was posted by the browser
+// without the CSRF cookie. Browsers do not send the SameSite=Lax CSRF cookie on the cross-site POST from the
+// SAML identity provider, so the response must be resubmitted from this origin. The resubmitted request is
+// marked to avoid resubmitting it again when the cookie is really missing, e.g. when cookies are disabled.
+func needsSAMLResubmit(r *http.Request) bool {
+	if !isSAMLPost(r) || r.PostFormValue(samlposthtml.ResubmittedParamName) != "" {
+		return false
+	}
+	_, err := r.Cookie(oidc.CSRFCookieName)
+	return err != nil
+}
+
+func writeSAMLResubmitPage(w http.ResponseWriter, r *http.Request, redirectURI string) error {
+	w.Header().Set("Content-Security-Policy", samlposthtml.ContentSecurityPolicy())
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := samlposthtml.Template().Execute(w, &samlposthtml.PageData{
+		Action:       redirectURI,
+		SAMLResponse: r.PostFormValue(samlResponseParamName),
+		RelayState:   r.PostFormValue("RelayState"),
+	}); err != nil {
+		plog.Error("error while rendering SAML resubmit page", err)
+	}
+	return nil
+}
+
 func validateRequest(r *http.Request, stateDecoder, cookieDecoder oidc.Decoder, auditLogger plog.AuditLogger) (*oidc.UpstreamStateParamData, error) {
-	if r.Method != http.MethodGet and r.Method != http.MethodPost {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
 		return nil, httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET)", r.Method)
 	}
 
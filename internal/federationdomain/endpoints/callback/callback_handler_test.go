@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package callback
@@ -7,6 +7,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -17,12 +19,15 @@ import (
 	"github.com/gorilla/securecookie"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 
 	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
 	"go.pinniped.dev/internal/auditid"
+	"go.pinniped.dev/internal/celtransformer"
+	"go.pinniped.dev/internal/federationdomain/endpoints/device"
 	"go.pinniped.dev/internal/federationdomain/endpoints/jwks"
 	"go.pinniped.dev/internal/federationdomain/oidc"
 	"go.pinniped.dev/internal/federationdomain/oidcclientvalidator"
@@ -30,6 +35,7 @@ import (
 	"go.pinniped.dev/internal/federationdomain/storage"
 	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
 	"go.pinniped.dev/internal/here"
+	"go.pinniped.dev/internal/idtransform"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/psession"
 	"go.pinniped.dev/internal/testutil"
@@ -59,6 +65,20 @@ const (
 	happyGithubIDPName        = "upstream-github-idp-name"
 	happyGithubIDPResourceUID = "upstream-github-idp-resource-uid"
 
+	// Upstream SAML.
+	happySAMLIDPName        = "upstream-saml-idp-name"
+	happySAMLIDPResourceUID = "upstream-saml-idp-resource-uid"
+	happySAMLResponse       = "some-base64-encoded-saml-response"
+	samlUpstreamIssuer      = "https://my-upstream-saml-idp.com/metadata"
+	samlUpstreamNameID      = "some-name-id"
+	samlUpstreamUsername    = "some-saml-username"
+
+	// Upstream generic OAuth2.
+	happyOAuth2IDPName        = "upstream-oauth2-idp-name"
+	happyOAuth2IDPResourceUID = "upstream-oauth2-idp-resource-uid"
+	oauth2UpstreamUsername    = "some-oauth2-username"
+	oauth2UpstreamSubject     = "12345"
+
 	// Upstream OAuth2 (OIDC or GitHub).
 	happyUpstreamAuthcode    = "upstream-auth-code"
 	happyUpstreamRedirectURI = "https://example.com/callback"
@@ -112,6 +132,23 @@ var (
 	)
 	happyDownstreamRequestParamsForDynamicClient = happyDownstreamRequestParamsQueryForDynamicClient.Encode()
 
+	// The raw upstream identity data in the session, as it looks after being read back from storage as JSON.
+	happyOIDCUpstreamData = map[string]any{
+		"iss":                     oidcUpstreamIssuer,
+		"sub":                     oidcUpstreamSubject,
+		oidcUpstreamUsernameClaim: oidcUpstreamUsername,
+		oidcUpstreamGroupsClaim:   []any{oidcUpstreamGroupMembership[0], oidcUpstreamGroupMembership[1]},
+		"other-claim":             "should be ignored",
+	}
+	happyOIDCUpstreamDataWithClaims = func(changedClaims map[string]any, removedClaims ...string) map[string]any {
+		upstreamData := maps.Clone(happyOIDCUpstreamData)
+		maps.Copy(upstreamData, changedClaims)
+		for _, claim := range removedClaims {
+			delete(upstreamData, claim)
+		}
+		return upstreamData
+	}
+
 	happyDownstreamCustomSessionDataForOIDCUpstream = &psession.CustomSessionData{
 		Username:         oidcUpstreamUsername,
 		UpstreamUsername: oidcUpstreamUsername,
@@ -124,6 +161,7 @@ var (
 			UpstreamIssuer:       oidcUpstreamIssuer,
 			UpstreamSubject:      oidcUpstreamSubject,
 		},
+		UpstreamData: happyOIDCUpstreamData,
 	}
 	happyDownstreamCustomSessionDataWithUsernameAndGroups = func(startingSessionData *psession.CustomSessionData, wantDownstreamUsername, wantUpstreamUsername string, wantUpstreamGroups []string) *psession.CustomSessionData {
 		copyOfCustomSession := *startingSessionData
@@ -140,6 +178,11 @@ var (
 		copyOfCustomSession.UpstreamGroups = wantUpstreamGroups
 		return &copyOfCustomSession
 	}
+	happyDownstreamCustomSessionDataWithUpstreamData = func(startingSessionData *psession.CustomSessionData, wantUpstreamData map[string]any) *psession.CustomSessionData {
+		copyOfCustomSession := *startingSessionData
+		copyOfCustomSession.UpstreamData = wantUpstreamData
+		return &copyOfCustomSession
+	}
 	happyDownstreamAccessTokenCustomSessionData = &psession.CustomSessionData{
 		Username:         oidcUpstreamUsername,
 		UpstreamUsername: oidcUpstreamUsername,
@@ -152,6 +195,40 @@ var (
 			UpstreamIssuer:      oidcUpstreamIssuer,
 			UpstreamSubject:     oidcUpstreamSubject,
 		},
+		UpstreamData: happyOIDCUpstreamData,
+	}
+	samlUpstreamGroupMembership  = []string{"saml-group-1", "saml-group-2"}
+	samlDownstreamSubject        = fmt.Sprintf("%s?idpName=%s&nameID=%s", samlUpstreamIssuer, happySAMLIDPName, samlUpstreamNameID)
+	samlUpstreamSessionExpiresAt = time.Date(2035, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	happyDownstreamCustomSessionDataForSAMLUpstream = &psession.CustomSessionData{
+		Username:         samlUpstreamUsername,
+		UpstreamUsername: samlUpstreamUsername,
+		UpstreamGroups:   samlUpstreamGroupMembership,
+		ProviderUID:      happySAMLIDPResourceUID,
+		ProviderName:     happySAMLIDPName,
+		ProviderType:     psession.ProviderTypeSAML,
+		SAML: &psession.SAMLSessionData{
+			UpstreamIssuer:      samlUpstreamIssuer,
+			UpstreamNameID:      samlUpstreamNameID,
+			SessionNotOnOrAfter: samlUpstreamSessionExpiresAt,
+		},
+	}
+	oauth2UpstreamGroupMembership = []string{"oauth2-group-1", "oauth2-group-2"}
+	oauth2DownstreamSubject       = fmt.Sprintf("https://my-upstream-oauth2-idp.com/api/user?idpName=%s&sub=%s", happyOAuth2IDPName, oauth2UpstreamSubject)
+	oauth2UpstreamRefreshToken    = "some-opaque-refresh-token-from-oauth2" //nolint:gosec // this is not a credential
+
+	happyDownstreamCustomSessionDataForOAuth2Upstream = &psession.CustomSessionData{
+		Username:         oauth2UpstreamUsername,
+		UpstreamUsername: oauth2UpstreamUsername,
+		UpstreamGroups:   oauth2UpstreamGroupMembership,
+		ProviderUID:      happyOAuth2IDPResourceUID,
+		ProviderName:     happyOAuth2IDPName,
+		ProviderType:     psession.ProviderTypeOAuth2,
+		OAuth2: &psession.OAuth2SessionData{
+			UpstreamRefreshToken: oauth2UpstreamRefreshToken,
+			UpstreamSubject:      oauth2UpstreamSubject,
+		},
 	}
 	happyDownstreamCustomSessionDataForGitHubUpstream = &psession.CustomSessionData{
 		Username:         githubUpstreamUsername,
@@ -208,6 +285,21 @@ func TestCallbackEndpoint(t *testing.T) {
 		RedirectURI: happyUpstreamRedirectURI,
 	}
 
+	happyOAuth2UpstreamExchangeAuthcodeArgs := &oidctestutil.ExchangeOAuth2AuthcodeArgs{
+		Authcode:         happyUpstreamAuthcode,
+		PKCECodeVerifier: oidcpkce.Code(happyDownstreamPKCEVerifier),
+		RedirectURI:      happyUpstreamRedirectURI,
+	}
+
+	happySAMLUpstreamValidateResponseArgs := &oidctestutil.ValidateResponseArgs{
+		EncodedResponse: happySAMLResponse,
+		// The AuthnRequest ID is derived from the nonce.
+		RequestID:                   "_ed04c4e9ea6c49cf9ceb39098787c5b9842524f96b07ef45305476a11caec9b4",
+		ServiceProviderEntityID:     "https://example.com",
+		AssertionConsumerServiceURL: happyUpstreamRedirectURI,
+		IDPDisplayName:              happySAMLIDPName,
+	}
+
 	// Note that fosite puts the granted scopes as a param in the redirect URI even though the spec doesn't seem to require it
 	happyDownstreamRedirectLocationRegexp := downstreamRedirectURI + `\?code=([^&]+)&scope=openid\+username\+groups&state=` + happyDownstreamState
 
@@ -229,27 +321,33 @@ func TestCallbackEndpoint(t *testing.T) {
 		kubeResources func(t *testing.T, supervisorClient *supervisorfake.Clientset, kubeClient *fake.Clientset)
 		method        string
 		path          string
+		body          string
 		csrfCookie    string
 
-		wantStatus                        int
-		wantContentType                   string
-		wantBody                          string
-		wantRedirectLocationRegexp        string
-		wantBodyFormResponseRegexp        string
-		wantDownstreamGrantedScopes       []string
-		wantDownstreamIDTokenSubject      string
-		wantDownstreamIDTokenUsername     string
-		wantDownstreamIDTokenGroups       []string
-		wantDownstreamRequestedScopes     []string
-		wantDownstreamNonce               string
-		wantDownstreamClientID            string
-		wantDownstreamPKCEChallenge       string
-		wantDownstreamPKCEChallengeMethod string
-		wantDownstreamCustomSessionData   *psession.CustomSessionData
-		wantDownstreamAdditionalClaims    map[string]any
-		wantOIDCAuthcodeExchangeCall      *expectedOIDCAuthcodeExchange
-		wantGitHubAuthcodeExchangeCall    *expectedGitHubAuthcodeExchange
-		wantAuditLogs                     func(encodedStateParam stateparam.Encoded, sessionID string) []testutil.WantedAuditLog
+		wantStatus                          int
+		wantContentType                     string
+		wantBody                            string
+		wantBodyContains                    []string
+		wantSAMLResubmitPage                bool
+		wantRedirectLocationRegexp          string
+		wantBodyFormResponseRegexp          string
+		wantDownstreamGrantedScopes         []string
+		wantDownstreamIDTokenSubject        string
+		wantDownstreamIDTokenUsername       string
+		wantDownstreamIDTokenGroups         []string
+		wantDownstreamRequestedScopes       []string
+		wantDownstreamNonce                 string
+		wantDownstreamClientID              string
+		wantDownstreamPKCEChallenge         string
+		wantDownstreamPKCEChallengeMethod   string
+		wantDownstreamCustomSessionData     *psession.CustomSessionData
+		wantDownstreamAdditionalClaims      map[string]any
+		wantDownstreamAuthenticationContext *idtransform.AuthenticationContext
+		wantOIDCAuthcodeExchangeCall        *expectedOIDCAuthcodeExchange
+		wantGitHubAuthcodeExchangeCall      *expectedGitHubAuthcodeExchange
+		wantOAuth2AuthcodeExchangeCall      *expectedOAuth2AuthcodeExchange
+		wantSAMLResponseValidationCall      *expectedSAMLResponseValidation
+		wantAuditLogs                       func(encodedStateParam stateparam.Encoded, sessionID string) []testutil.WantedAuditLog
 	}{
 		{
 			name:   "OIDC: GET with good state and cookie and successful upstream token exchange with response_mode=form_post returns 200 with HTML+JS form",
@@ -385,6 +483,73 @@ func TestCallbackEndpoint(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:   "OAuth2: GET with good state and cookie and successful upstream token exchange with response_mode=form_post returns 200 with HTML+JS form",
+			idps:   testidplister.NewUpstreamIDPListerBuilder().WithOAuth2(happyOAuth2Upstream().Build()),
+			method: http.MethodGet,
+			path: newRequestPath().WithState(
+				happyOAuth2UpstreamStateParam().WithAuthorizeRequestParams(
+					shallowCopyAndModifyQuery(
+						happyDownstreamRequestParamsQuery,
+						map[string]string{"response_mode": "form_post"},
+					).Encode(),
+				).Build(t, happyStateCodec),
+			).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusOK,
+			wantContentType:                   "text/html;charset=UTF-8",
+			wantBodyFormResponseRegexp:        `<code id="manual-auth-code">(.+)</code>`,
+			wantDownstreamIDTokenSubject:      oauth2DownstreamSubject,
+			wantDownstreamIDTokenUsername:     oauth2UpstreamUsername,
+			wantDownstreamIDTokenGroups:       oauth2UpstreamGroupMembership,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamClientID:            downstreamPinnipedClientID,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData:   happyDownstreamCustomSessionDataForOAuth2Upstream,
+			wantOAuth2AuthcodeExchangeCall: &expectedOAuth2AuthcodeExchange{
+				performedByUpstreamName: happyOAuth2IDPName,
+				args:                    happyOAuth2UpstreamExchangeAuthcodeArgs,
+			},
+			wantAuditLogs: func(encodedStateParam stateparam.Encoded, sessionID string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"code": "redacted", "state": "redacted"},
+					}),
+					testutil.WantAuditLog("AuthorizeID From Parameters", map[string]any{
+						"authorizeID": encodedStateParam.AuthorizeID(),
+					}),
+					testutil.WantAuditLog("Using Upstream IDP", map[string]any{
+						"displayName":  "upstream-oauth2-idp-name",
+						"resourceName": "upstream-oauth2-idp-name",
+						"resourceUID":  "upstream-oauth2-idp-resource-uid",
+						"type":         "oauth2",
+					}),
+					testutil.WantAuditLog("Identity From Upstream IDP", map[string]any{
+						"upstreamIDPDisplayName":  "upstream-oauth2-idp-name",
+						"upstreamIDPType":         "oauth2",
+						"upstreamIDPResourceName": "upstream-oauth2-idp-name",
+						"upstreamIDPResourceUID":  "upstream-oauth2-idp-resource-uid",
+						"personalInfo": map[string]any{
+							"upstreamUsername": "some-oauth2-username",
+							"upstreamGroups":   []any{"oauth2-group-1", "oauth2-group-2"},
+						},
+					}),
+					testutil.WantAuditLog("Session Started", map[string]any{
+						"sessionID": sessionID,
+						"warnings":  []any{}, // json: []
+						"personalInfo": map[string]any{
+							"username":         "some-oauth2-username",
+							"groups":           []any{"oauth2-group-1", "oauth2-group-2"},
+							"subject":          "https://my-upstream-oauth2-idp.com/api/user?idpName=upstream-oauth2-idp-name&sub=12345",
+							"additionalClaims": map[string]any{}, // json: {}
+						},
+					}),
+				}
+			},
+		},
 		{
 			name: "GET with good state and cookie with additional params",
 			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(happyOIDCUpstream().
@@ -418,7 +583,13 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamClientID:            downstreamPinnipedClientID,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData:   happyDownstreamCustomSessionDataForOIDCUpstream,
+			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUpstreamData(
+				happyDownstreamCustomSessionDataForOIDCUpstream,
+				happyOIDCUpstreamDataWithClaims(map[string]any{
+					"upstreamCustomClaim": "i am a claim value",
+					"upstreamOtherClaim":  "other claim value",
+				}),
+			),
 			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
 				performedByUpstreamName: happyOIDCUpstreamIDPName,
 				args:                    happyOIDCUpstreamExchangeAuthcodeAndValidateTokenArgs,
@@ -567,6 +738,7 @@ func TestCallbackEndpoint(t *testing.T) {
 					UpstreamIssuer:      oidcUpstreamIssuer,
 					UpstreamSubject:     oidcUpstreamSubject,
 				},
+				UpstreamData: happyOIDCUpstreamData,
 			},
 			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
 				performedByUpstreamName: happyOIDCUpstreamIDPName,
@@ -624,11 +796,14 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamClientID:            downstreamPinnipedClientID,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUsernameAndGroups(
-				happyDownstreamCustomSessionDataForOIDCUpstream,
-				"joe@whitehouse.gov",
-				"joe@whitehouse.gov",
-				oidcUpstreamGroupMembership,
+			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUpstreamData(
+				happyDownstreamCustomSessionDataWithUsernameAndGroups(
+					happyDownstreamCustomSessionDataForOIDCUpstream,
+					"joe@whitehouse.gov",
+					"joe@whitehouse.gov",
+					oidcUpstreamGroupMembership,
+				),
+				happyOIDCUpstreamDataWithClaims(map[string]any{"email": "joe@whitehouse.gov"}),
 			),
 			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
 				performedByUpstreamName: happyOIDCUpstreamIDPName,
@@ -657,11 +832,14 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamClientID:            downstreamPinnipedClientID,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUsernameAndGroups(
-				happyDownstreamCustomSessionDataForOIDCUpstream,
-				"joe@whitehouse.gov",
-				"joe@whitehouse.gov",
-				oidcUpstreamGroupMembership,
+			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUpstreamData(
+				happyDownstreamCustomSessionDataWithUsernameAndGroups(
+					happyDownstreamCustomSessionDataForOIDCUpstream,
+					"joe@whitehouse.gov",
+					"joe@whitehouse.gov",
+					oidcUpstreamGroupMembership,
+				),
+				happyOIDCUpstreamDataWithClaims(map[string]any{"email": "joe@whitehouse.gov", "email_verified": true}),
 			),
 			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
 				performedByUpstreamName: happyOIDCUpstreamIDPName,
@@ -691,11 +869,14 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamClientID:            downstreamPinnipedClientID,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUsernameAndGroups(
-				happyDownstreamCustomSessionDataForOIDCUpstream,
-				"joe",
-				"joe",
-				oidcUpstreamGroupMembership,
+			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUpstreamData(
+				happyDownstreamCustomSessionDataWithUsernameAndGroups(
+					happyDownstreamCustomSessionDataForOIDCUpstream,
+					"joe",
+					"joe",
+					oidcUpstreamGroupMembership,
+				),
+				happyOIDCUpstreamDataWithClaims(map[string]any{"some-claim": "joe", "email": "joe@whitehouse.gov", "email_verified": false}),
 			),
 			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
 				performedByUpstreamName: happyOIDCUpstreamIDPName,
@@ -874,11 +1055,14 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamClientID:            downstreamPinnipedClientID,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUsernameAndGroups(
-				happyDownstreamCustomSessionDataForOIDCUpstream,
-				oidcUpstreamUsername,
-				oidcUpstreamUsername,
-				[]string{"notAnArrayGroup1 notAnArrayGroup2"},
+			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUpstreamData(
+				happyDownstreamCustomSessionDataWithUsernameAndGroups(
+					happyDownstreamCustomSessionDataForOIDCUpstream,
+					oidcUpstreamUsername,
+					oidcUpstreamUsername,
+					[]string{"notAnArrayGroup1 notAnArrayGroup2"},
+				),
+				happyOIDCUpstreamDataWithClaims(map[string]any{oidcUpstreamGroupsClaim: "notAnArrayGroup1 notAnArrayGroup2"}),
 			),
 			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
 				performedByUpstreamName: happyOIDCUpstreamIDPName,
@@ -905,11 +1089,14 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamClientID:            downstreamPinnipedClientID,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUsernameAndGroups(
-				happyDownstreamCustomSessionDataForOIDCUpstream,
-				oidcUpstreamUsername,
-				oidcUpstreamUsername,
-				[]string{"group1", "group2"},
+			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUpstreamData(
+				happyDownstreamCustomSessionDataWithUsernameAndGroups(
+					happyDownstreamCustomSessionDataForOIDCUpstream,
+					oidcUpstreamUsername,
+					oidcUpstreamUsername,
+					[]string{"group1", "group2"},
+				),
+				happyOIDCUpstreamDataWithClaims(map[string]any{oidcUpstreamGroupsClaim: []any{"group1", "group2"}}),
 			),
 			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
 				performedByUpstreamName: happyOIDCUpstreamIDPName,
@@ -1092,6 +1279,114 @@ func TestCallbackEndpoint(t *testing.T) {
 				args:                    happyOIDCUpstreamExchangeAuthcodeAndValidateTokenArgs,
 			},
 		},
+		{
+			name: "OIDC: using identity transformations which use how the user authenticated to the upstream",
+			idps: testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(happyOIDCUpstream().
+					WithIDTokenClaim("acr", "phr").
+					WithIDTokenClaim("amr", []any{"pwd", "mfa"}).
+					WithTransformsForFederationDomain(transformtestutil.NewPipeline(t, []celtransformer.CELTransformation{
+						&celtransformer.AllowAuthenticationPolicy{Expression: `"mfa" in amr`},
+						&celtransformer.UsernameTransformation{Expression: `acr + ":" + username`},
+					})).Build()),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyOIDCState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusSeeOther,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      oidcUpstreamIssuer + "?idpName=" + happyOIDCUpstreamIDPName + "&sub=" + oidcUpstreamSubjectQueryEscaped,
+			wantDownstreamIDTokenUsername:     "phr:" + oidcUpstreamUsername,
+			wantDownstreamIDTokenGroups:       oidcUpstreamGroupMembership,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamClientID:            downstreamPinnipedClientID,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUpstreamData(
+				happyDownstreamCustomSessionDataWithUsernameAndGroups(
+					happyDownstreamCustomSessionDataForOIDCUpstream,
+					"phr:"+oidcUpstreamUsername,
+					oidcUpstreamUsername,
+					oidcUpstreamGroupMembership,
+				),
+				happyOIDCUpstreamDataWithClaims(map[string]any{"acr": "phr", "amr": []any{"pwd", "mfa"}}),
+			),
+			wantDownstreamAuthenticationContext: &idtransform.AuthenticationContext{ACR: "phr", AMR: []string{"pwd", "mfa"}},
+			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
+				performedByUpstreamName: happyOIDCUpstreamIDPName,
+				args:                    happyOIDCUpstreamExchangeAuthcodeAndValidateTokenArgs,
+			},
+		},
+		{
+			name: "OIDC: using identity transformations which return additional claims",
+			idps: testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(happyOIDCUpstream().
+					WithAdditionalClaimMappings(map[string]string{
+						"downstreamCustomClaim": "upstreamCustomClaim",
+						"email":                 "upstreamCustomClaim",
+					}).
+					WithIDTokenClaim("upstreamCustomClaim", "i am a claim value").
+					WithIDTokenClaim("email", "ryan@example.com").
+					WithTransformsForFederationDomain(transformtestutil.NewPipeline(t, []celtransformer.CELTransformation{
+						&celtransformer.ClaimsTransformation{Expression: `{"email": upstream.email, "tenant": request.clientID + ":" + username}`},
+					})).Build()),
+			method:                            http.MethodGet,
+			path:                              newRequestPath().WithState(happyOIDCState).String(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusSeeOther,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      oidcUpstreamIssuer + "?idpName=" + happyOIDCUpstreamIDPName + "&sub=" + oidcUpstreamSubjectQueryEscaped,
+			wantDownstreamIDTokenUsername:     oidcUpstreamUsername,
+			wantDownstreamIDTokenGroups:       oidcUpstreamGroupMembership,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamClientID:            downstreamPinnipedClientID,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData: func() *psession.CustomSessionData {
+				sessionData := happyDownstreamCustomSessionDataWithUpstreamData(
+					happyDownstreamCustomSessionDataForOIDCUpstream,
+					happyOIDCUpstreamDataWithClaims(map[string]any{
+						"upstreamCustomClaim": "i am a claim value",
+						"email":               "ryan@example.com",
+					}),
+				)
+				sessionData.TransformedClaimNames = []string{"email", "tenant"}
+				return sessionData
+			}(),
+			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
+				performedByUpstreamName: happyOIDCUpstreamIDPName,
+				args:                    happyOIDCUpstreamExchangeAuthcodeAndValidateTokenArgs,
+			},
+			wantDownstreamAdditionalClaims: map[string]any{
+				"downstreamCustomClaim": "i am a claim value",
+				"email":                 "ryan@example.com", // the transformed claim replaced the mapped claim
+				"tenant":                downstreamPinnipedClientID + ":" + oidcUpstreamUsername,
+			},
+		},
+		{
+			name: "OIDC: using identity transformations which reject the authentication because of how the user authenticated to the upstream",
+			idps: testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(happyOIDCUpstream().
+					WithIDTokenClaim("amr", []any{"pwd"}).
+					WithTransformsForFederationDomain(transformtestutil.NewPipeline(t, []celtransformer.CELTransformation{
+						&celtransformer.AllowAuthenticationPolicy{Expression: `"mfa" in amr`, RejectedAuthenticationMessage: "mfa is required"},
+					})).Build()),
+			method:          http.MethodGet,
+			path:            newRequestPath().WithState(happyOIDCState).String(),
+			csrfCookie:      happyCSRFCookie,
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantContentType: htmlContentType,
+			wantBody:        "Unprocessable Entity: configured identity policy rejected this authentication: mfa is required\n",
+			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
+				performedByUpstreamName: happyOIDCUpstreamIDPName,
+				args:                    happyOIDCUpstreamExchangeAuthcodeAndValidateTokenArgs,
+			},
+		},
 		{
 			name: "GitHub: using identity transformations which modify the username and group names",
 			idps: testidplister.NewUpstreamIDPListerBuilder().
@@ -1141,13 +1436,56 @@ func TestCallbackEndpoint(t *testing.T) {
 			},
 		},
 		{
-			name:            "POST method is invalid",
+			name:                              "OIDC: POST with good state and code in the body and cookie and successful upstream token exchange returns 303 to downstream client callback with its state and code",
+			idps:                              testidplister.NewUpstreamIDPListerBuilder().WithOIDC(happyOIDCUpstream().Build()),
+			method:                            http.MethodPost,
+			path:                              samlCallbackPath,
+			body:                              url.Values{"code": []string{happyUpstreamAuthcode}, "state": []string{happyOIDCState.String()}}.Encode(),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusSeeOther,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      oidcUpstreamIssuer + "?idpName=" + happyOIDCUpstreamIDPName + "&sub=" + oidcUpstreamSubjectQueryEscaped,
+			wantDownstreamIDTokenUsername:     oidcUpstreamUsername,
+			wantDownstreamIDTokenGroups:       oidcUpstreamGroupMembership,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamClientID:            downstreamPinnipedClientID,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData:   happyDownstreamCustomSessionDataForOIDCUpstream,
+			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
+				performedByUpstreamName: happyOIDCUpstreamIDPName,
+				args:                    happyOIDCUpstreamExchangeAuthcodeAndValidateTokenArgs,
+			},
+		},
+		{
+			name:            "OIDC: POST with good state in the body but without code is an error",
 			idps:            testidplister.NewUpstreamIDPListerBuilder().WithOIDC(happyOIDCUpstream().Build()),
 			method:          http.MethodPost,
-			path:            newRequestPath().String(),
-			wantStatus:      http.StatusMethodNotAllowed,
+			path:            samlCallbackPath,
+			body:            url.Values{"state": []string{happyOIDCState.String()}}.Encode(),
+			csrfCookie:      happyCSRFCookie,
+			wantStatus:      http.StatusBadRequest,
 			wantContentType: htmlContentType,
-			wantBody:        "Method Not Allowed: POST (try GET)\n",
+			wantBody: here.Doc(`Bad Request: code param not found
+
+				Something went wrong with your authentication attempt at your external identity provider.
+
+				Pinniped AuditID: fake-audit-id
+			`),
+		},
+		{
+			name:            "OIDC: POST with state only in the URL query is an error",
+			idps:            testidplister.NewUpstreamIDPListerBuilder().WithOIDC(happyOIDCUpstream().Build()),
+			method:          http.MethodPost,
+			path:            newRequestPath().WithState(happyOIDCState).WithoutCode().String(),
+			body:            url.Values{"code": []string{happyUpstreamAuthcode}}.Encode(),
+			csrfCookie:      happyCSRFCookie,
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: htmlContentType,
+			wantBody:        "Bad Request: state param not found\n",
 		},
 		{
 			name:            "PATCH method is invalid",
@@ -1565,6 +1903,153 @@ func TestCallbackEndpoint(t *testing.T) {
 				args:                    happyGitHubUpstreamExchangeAuthcodeArgs,
 			},
 		},
+		{
+			name:                              "SAML: POST with good RelayState and cookie and successful upstream response validation returns 303 to downstream client callback",
+			idps:                              testidplister.NewUpstreamIDPListerBuilder().WithSAML(happySAMLUpstream().Build()),
+			method:                            http.MethodPost,
+			path:                              samlCallbackPath,
+			body:                              newSAMLPostBody(happySAMLUpstreamStateParam().Build(t, happyStateCodec)),
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusSeeOther,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      samlDownstreamSubject,
+			wantDownstreamIDTokenUsername:     samlUpstreamUsername,
+			wantDownstreamIDTokenGroups:       samlUpstreamGroupMembership,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamClientID:            downstreamPinnipedClientID,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData:   happyDownstreamCustomSessionDataForSAMLUpstream,
+			wantSAMLResponseValidationCall: &expectedSAMLResponseValidation{
+				performedByUpstreamName: happySAMLIDPName,
+				args:                    happySAMLUpstreamValidateResponseArgs,
+			},
+			wantAuditLogs: func(encodedStateParam stateparam.Encoded, sessionID string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"SAMLResponse": "redacted", "RelayState": "redacted"},
+					}),
+					testutil.WantAuditLog("AuthorizeID From Parameters", map[string]any{
+						"authorizeID": encodedStateParam.AuthorizeID(),
+					}),
+					testutil.WantAuditLog("Using Upstream IDP", map[string]any{
+						"displayName":  "upstream-saml-idp-name",
+						"resourceName": "upstream-saml-idp-name",
+						"resourceUID":  "upstream-saml-idp-resource-uid",
+						"type":         "saml",
+					}),
+					testutil.WantAuditLog("Identity From Upstream IDP", map[string]any{
+						"upstreamIDPDisplayName":  "upstream-saml-idp-name",
+						"upstreamIDPType":         "saml",
+						"upstreamIDPResourceName": "upstream-saml-idp-name",
+						"upstreamIDPResourceUID":  "upstream-saml-idp-resource-uid",
+						"personalInfo": map[string]any{
+							"upstreamUsername": "some-saml-username",
+							"upstreamGroups":   []any{"saml-group-1", "saml-group-2"},
+						},
+					}),
+					testutil.WantAuditLog("Session Started", map[string]any{
+						"sessionID": sessionID,
+						"warnings":  []any{}, // json: []
+						"personalInfo": map[string]any{
+							"username":         "some-saml-username",
+							"groups":           []any{"saml-group-1", "saml-group-2"},
+							"subject":          "https://my-upstream-saml-idp.com/metadata?idpName=upstream-saml-idp-name&nameID=some-name-id",
+							"additionalClaims": map[string]any{}, // json: {}
+						},
+					}),
+				}
+			},
+		},
+		{
+			name:                              "SAML: POST which was resubmitted with good RelayState and cookie and successful upstream response validation returns 303 to downstream client callback",
+			idps:                              testidplister.NewUpstreamIDPListerBuilder().WithSAML(happySAMLUpstream().Build()),
+			method:                            http.MethodPost,
+			path:                              samlCallbackPath,
+			body:                              newSAMLPostBody(happySAMLUpstreamStateParam().Build(t, happyStateCodec)) + "&pinniped_resubmitted=true",
+			csrfCookie:                        happyCSRFCookie,
+			wantStatus:                        http.StatusSeeOther,
+			wantRedirectLocationRegexp:        happyDownstreamRedirectLocationRegexp,
+			wantBody:                          "",
+			wantDownstreamIDTokenSubject:      samlDownstreamSubject,
+			wantDownstreamIDTokenUsername:     samlUpstreamUsername,
+			wantDownstreamIDTokenGroups:       samlUpstreamGroupMembership,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamClientID:            downstreamPinnipedClientID,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData:   happyDownstreamCustomSessionDataForSAMLUpstream,
+			wantSAMLResponseValidationCall: &expectedSAMLResponseValidation{
+				performedByUpstreamName: happySAMLIDPName,
+				args:                    happySAMLUpstreamValidateResponseArgs,
+			},
+		},
+		{
+			name:                 "SAML: POST without cookie renders a page which resubmits the SAML response from the same origin",
+			idps:                 testidplister.NewUpstreamIDPListerBuilder().WithSAML(happySAMLUpstream().Build()),
+			method:               http.MethodPost,
+			path:                 samlCallbackPath,
+			body:                 newSAMLPostBody("some-encoded-state"),
+			wantStatus:           http.StatusOK,
+			wantContentType:      htmlContentType,
+			wantSAMLResubmitPage: true,
+			wantBodyContains: []string{
+				`<form method="post" action="https://example.com/callback">`,
+				`name="SAMLResponse" value="some-base64-encoded-saml-response"`,
+				`name="RelayState" value="some-encoded-state"`,
+				`name="pinniped_resubmitted" value="true"`,
+			},
+			wantAuditLogs: func(encodedStateParam stateparam.Encoded, sessionID string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"SAMLResponse": "redacted", "RelayState": "redacted"},
+					}),
+				}
+			},
+		},
+		{
+			name:            "SAML: POST which was resubmitted without cookie is an error",
+			idps:            testidplister.NewUpstreamIDPListerBuilder().WithSAML(happySAMLUpstream().Build()),
+			method:          http.MethodPost,
+			path:            samlCallbackPath,
+			body:            newSAMLPostBody(happySAMLUpstreamStateParam().Build(t, happyStateCodec)) + "&pinniped_resubmitted=true",
+			wantStatus:      http.StatusForbidden,
+			wantContentType: htmlContentType,
+			wantBody:        "Forbidden: CSRF cookie is missing\n",
+		},
+		{
+			name:            "SAML: POST without RelayState is an error",
+			idps:            testidplister.NewUpstreamIDPListerBuilder().WithSAML(happySAMLUpstream().Build()),
+			method:          http.MethodPost,
+			path:            samlCallbackPath,
+			body:            url.Values{"SAMLResponse": []string{happySAMLResponse}}.Encode(),
+			csrfCookie:      happyCSRFCookie,
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: htmlContentType,
+			wantBody:        "Bad Request: state param not found\n",
+		},
+		{
+			name: "SAML: upstream response validation fails",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithSAML(
+				happySAMLUpstream().WithValidateResponseError(errors.New("some error")).Build(),
+			),
+			method:          http.MethodPost,
+			path:            samlCallbackPath,
+			body:            newSAMLPostBody(happySAMLUpstreamStateParam().Build(t, happyStateCodec)),
+			csrfCookie:      happyCSRFCookie,
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantBody:        "Unprocessable Entity: failed to validate SAML response\n",
+			wantContentType: htmlContentType,
+			wantSAMLResponseValidationCall: &expectedSAMLResponseValidation{
+				performedByUpstreamName: happySAMLIDPName,
+				args:                    happySAMLUpstreamValidateResponseArgs,
+			},
+		},
 		{
 			name:          "GitHub: GET with good state and cookie and successful upstream token exchange with dynamic client returns 303 to downstream client callback, with dynamic client",
 			idps:          testidplister.NewUpstreamIDPListerBuilder().WithGitHub(happyGitHubUpstream().Build()),
@@ -1714,11 +2199,14 @@ func TestCallbackEndpoint(t *testing.T) {
 			wantDownstreamClientID:            downstreamPinnipedClientID,
 			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
-			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUsernameAndGroups(
-				happyDownstreamCustomSessionDataForOIDCUpstream,
-				oidcUpstreamUsername,
-				oidcUpstreamUsername,
-				nil,
+			wantDownstreamCustomSessionData: happyDownstreamCustomSessionDataWithUpstreamData(
+				happyDownstreamCustomSessionDataWithUsernameAndGroups(
+					happyDownstreamCustomSessionDataForOIDCUpstream,
+					oidcUpstreamUsername,
+					oidcUpstreamUsername,
+					nil,
+				),
+				happyOIDCUpstreamDataWithClaims(nil, oidcUpstreamGroupsClaim),
 			),
 			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
 				performedByUpstreamName: happyOIDCUpstreamIDPName,
@@ -1989,6 +2477,36 @@ func TestCallbackEndpoint(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:            "OIDC: device login state for a device authorization request which does not exist",
+			idps:            testidplister.NewUpstreamIDPListerBuilder().WithOIDC(happyOIDCUpstream().Build()),
+			method:          http.MethodGet,
+			path:            newRequestPath().WithState(happyOIDCUpstreamStateParam().WithDeviceUserCodeSignature("some-user-code-signature").Build(t, happyStateCodec)).String(),
+			csrfCookie:      happyCSRFCookie,
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: htmlContentType,
+			wantBody:        "Bad Request: device authorization request not found, already used, or expired\n",
+			wantOIDCAuthcodeExchangeCall: &expectedOIDCAuthcodeExchange{
+				performedByUpstreamName: happyOIDCUpstreamIDPName,
+				args:                    happyOIDCUpstreamExchangeAuthcodeAndValidateTokenArgs,
+			},
+			wantAuditLogs: func(encodedStateParam stateparam.Encoded, sessionID string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"code": "redacted", "state": "redacted"},
+					}),
+					testutil.WantAuditLog("AuthorizeID From Parameters", map[string]any{
+						"authorizeID": encodedStateParam.AuthorizeID(),
+					}),
+					testutil.WantAuditLog("Using Upstream IDP", map[string]any{
+						"displayName":  "upstream-oidc-idp-name",
+						"resourceName": "upstream-oidc-idp-name",
+						"resourceUID":  "upstream-oidc-resource-uid",
+						"type":         "oidc",
+					}),
+				}
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -2020,11 +2538,19 @@ func TestCallbackEndpoint(t *testing.T) {
 				happyStateCodec,
 				happyCookieCodec,
 				happyUpstreamRedirectURI,
+				device.NewApprover(downstreamIssuer, oauthStore, auditLogger),
 				auditLogger,
 			)
 
 			reqContext := context.WithValue(context.Background(), struct{ name string }{name: "test"}, "request-context")
-			req := httptest.NewRequest(test.method, test.path, nil).WithContext(reqContext)
+			var body io.Reader
+			if test.body != "" {
+				body = strings.NewReader(test.body)
+			}
+			req := httptest.NewRequest(test.method, test.path, body).WithContext(reqContext)
+			if test.body != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
 			if test.csrfCookie != "" {
 				req.Header.Set("Cookie", test.csrfCookie)
 			}
@@ -2034,7 +2560,11 @@ func TestCallbackEndpoint(t *testing.T) {
 			t.Logf("response: %#v", rsp)
 			t.Logf("response body: %q", rsp.Body.String())
 
-			testutil.RequireSecurityHeadersWithFormPostPageCSPs(t, rsp)
+			if test.wantSAMLResubmitPage {
+				testutil.RequireSecurityHeadersWithSAMLPostPageCSPs(t, rsp)
+			} else {
+				testutil.RequireSecurityHeadersWithFormPostPageCSPs(t, rsp)
+			}
 
 			switch {
 			case test.wantOIDCAuthcodeExchangeCall != nil:
@@ -2049,6 +2579,17 @@ func TestCallbackEndpoint(t *testing.T) {
 					test.wantGitHubAuthcodeExchangeCall.performedByUpstreamName,
 					test.wantGitHubAuthcodeExchangeCall.args,
 				)
+			case test.wantOAuth2AuthcodeExchangeCall != nil:
+				test.wantOAuth2AuthcodeExchangeCall.args.Ctx = req.Context()
+				test.idps.RequireExactlyOneOAuth2AuthcodeExchange(t,
+					test.wantOAuth2AuthcodeExchangeCall.performedByUpstreamName,
+					test.wantOAuth2AuthcodeExchangeCall.args,
+				)
+			case test.wantSAMLResponseValidationCall != nil:
+				test.idps.RequireExactlyOneSAMLResponseValidation(t,
+					test.wantSAMLResponseValidationCall.performedByUpstreamName,
+					test.wantSAMLResponseValidationCall.args,
+				)
 			default:
 				test.idps.RequireExactlyZeroAuthcodeExchanges(t)
 			}
@@ -2063,6 +2604,12 @@ func TestCallbackEndpoint(t *testing.T) {
 			case test.wantBody != "":
 				require.Equal(t, test.wantBody, rsp.Body.String())
 
+			// Else if we want a body that contains some specific strings, assert that.
+			case len(test.wantBodyContains) > 0:
+				for _, want := range test.wantBodyContains {
+					require.Contains(t, rsp.Body.String(), want)
+				}
+
 			// Else if we want a body that contains a regex-matched auth code, assert that (for "response_mode=form_post").
 			case test.wantBodyFormResponseRegexp != "":
 				sessionID = oidctestutil.RequireAuthCodeRegexpMatch(
@@ -2084,6 +2631,7 @@ func TestCallbackEndpoint(t *testing.T) {
 					downstreamRedirectURI,
 					test.wantDownstreamCustomSessionData,
 					test.wantDownstreamAdditionalClaims,
+					test.wantDownstreamAuthenticationContext,
 				)
 
 			// Otherwise, expect an empty response body.
@@ -2112,11 +2660,19 @@ func TestCallbackEndpoint(t *testing.T) {
 					downstreamRedirectURI,
 					test.wantDownstreamCustomSessionData,
 					test.wantDownstreamAdditionalClaims,
+					test.wantDownstreamAuthenticationContext,
 				)
 			}
 
 			if test.wantAuditLogs != nil {
-				wantAuditLogs := test.wantAuditLogs(testutil.GetStateParam(t, test.path), sessionID)
+				encodedStateParam := testutil.GetStateParam(t, test.path)
+				if test.body != "" {
+					// SAML identity providers post the state param as the RelayState.
+					postForm, err := url.ParseQuery(test.body)
+					require.NoError(t, err)
+					encodedStateParam = stateparam.Encoded(postForm.Get("RelayState"))
+				}
+				wantAuditLogs := test.wantAuditLogs(encodedStateParam, sessionID)
 				testutil.WantAuditIDOnEveryAuditLog(wantAuditLogs, "fake-audit-id")
 				testutil.CompareAuditLogs(t, wantAuditLogs, actualAuditLog.String())
 			}
@@ -2134,6 +2690,16 @@ type expectedGitHubAuthcodeExchange struct {
 	args                    *oidctestutil.ExchangeAuthcodeArgs
 }
 
+type expectedOAuth2AuthcodeExchange struct {
+	performedByUpstreamName string
+	args                    *oidctestutil.ExchangeOAuth2AuthcodeArgs
+}
+
+type expectedSAMLResponseValidation struct {
+	performedByUpstreamName string
+	args                    *oidctestutil.ValidateResponseArgs
+}
+
 type requestPath struct {
 	code  *string
 	state *stateparam.Encoded
@@ -2204,6 +2770,41 @@ func happyGitHubUpstreamStateParam() *oidctestutil.UpstreamStateParamBuilder {
 	}
 }
 
+func happyOAuth2UpstreamStateParam() *oidctestutil.UpstreamStateParamBuilder {
+	return &oidctestutil.UpstreamStateParamBuilder{
+		U: happyOAuth2IDPName,
+		P: happyDownstreamRequestParams,
+		T: "oauth2",
+		N: happyDownstreamNonce,
+		C: happyDownstreamCSRF,
+		K: happyDownstreamPKCEVerifier,
+		V: happyDownstreamStateVersion,
+	}
+}
+
+// samlCallbackPath is the path to which SAML identity providers post their response. Unlike the other
+// identity provider types, the state param is in the body of the request, as the RelayState.
+const samlCallbackPath = "/downstream-provider-name/callback"
+
+func newSAMLPostBody(state stateparam.Encoded) string {
+	return url.Values{
+		"SAMLResponse": []string{happySAMLResponse},
+		"RelayState":   []string{state.String()},
+	}.Encode()
+}
+
+func happySAMLUpstreamStateParam() *oidctestutil.UpstreamStateParamBuilder {
+	return &oidctestutil.UpstreamStateParamBuilder{
+		U: happySAMLIDPName,
+		P: happyDownstreamRequestParams,
+		T: "saml",
+		N: happyDownstreamNonce,
+		C: happyDownstreamCSRF,
+		K: happyDownstreamPKCEVerifier,
+		V: happyDownstreamStateVersion,
+	}
+}
+
 func happyOIDCUpstreamStateParamForDynamicClient() *oidctestutil.UpstreamStateParamBuilder {
 	p := happyOIDCUpstreamStateParam()
 	p.P = happyDownstreamRequestParamsForDynamicClient
@@ -2241,6 +2842,37 @@ func happyGitHubUpstream() *oidctestutil.TestUpstreamGitHubIdentityProviderBuild
 		})
 }
 
+func happyOAuth2Upstream() *oidctestutil.TestUpstreamOAuth2IdentityProviderBuilder {
+	return oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+		WithName(happyOAuth2IDPName).
+		WithResourceUID(happyOAuth2IDPResourceUID).
+		WithClientID("some-client-id").
+		WithToken(&oauth2.Token{
+			AccessToken:  "some-opaque-access-token-from-oauth2",
+			RefreshToken: oauth2UpstreamRefreshToken,
+		}).
+		WithUser(&upstreamprovider.OAuth2User{
+			Username:          oauth2UpstreamUsername,
+			Groups:            oauth2UpstreamGroupMembership,
+			Subject:           oauth2UpstreamSubject,
+			DownstreamSubject: oauth2DownstreamSubject,
+		})
+}
+
+func happySAMLUpstream() *oidctestutil.TestUpstreamSAMLIdentityProviderBuilder {
+	return oidctestutil.NewTestUpstreamSAMLIdentityProviderBuilder().
+		WithName(happySAMLIDPName).
+		WithResourceUID(happySAMLIDPResourceUID).
+		WithUser(&upstreamprovider.SAMLUser{
+			Username:            samlUpstreamUsername,
+			Groups:              samlUpstreamGroupMembership,
+			DownstreamSubject:   samlDownstreamSubject,
+			Issuer:              samlUpstreamIssuer,
+			NameID:              samlUpstreamNameID,
+			SessionNotOnOrAfter: samlUpstreamSessionExpiresAt,
+		})
+}
+
 func shallowCopyAndModifyQuery(query url.Values, modifications map[string]string) url.Values {
 	copied := url.Values{}
 	for key, value := range query {
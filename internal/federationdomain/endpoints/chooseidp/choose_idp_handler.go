@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package chooseidp
@@ -12,6 +12,7 @@ import (
 	"go.pinniped.dev/generated/latest/apis/supervisor/oidc"
 	"go.pinniped.dev/internal/federationdomain/endpoints/chooseidp/chooseidphtml"
 	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/httputil/securityheader"
 )
@@ -20,8 +21,13 @@ import (
 // to this page, copying all the same parameters from the original authorization request. Each button on this page
 // simply adds the IDP's name as an additional request parameter to the original authorization request's parameters,
 // and sends the user back to the authorization endpoint, where the authorization flow can start from scratch using
-// the original params with the extra pinniped_idp_name param added.
-func NewHandler(authURL string, upstreamIDPs federationdomainproviders.FederationDomainIdentityProvidersListerI) http.Handler {
+// the original params with the extra pinniped_idp_name param added. When upstreamHealth is not nil, IDPs which
+// are currently unavailable are marked as such on the page.
+func NewHandler(
+	authURL string,
+	upstreamIDPs federationdomainproviders.FederationDomainIdentityProvidersListerI,
+	upstreamHealth *upstreamhealth.Tracker,
+) http.Handler {
 	handler := httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		if r.Method != http.MethodGet {
 			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET)", r.Method)
@@ -34,17 +40,18 @@ func NewHandler(authURL string, upstreamIDPs federationdomainproviders.Federatio
 			return httperr.New(http.StatusBadRequest, "missing required query params (must include client_id, redirect_uri, scope, and response_type)")
 		}
 
-		newIDPForPageData := func(displayName string) chooseidphtml.IdentityProvider {
+		newIDPForPageData := func(displayName string, unavailable bool) chooseidphtml.IdentityProvider {
 			return chooseidphtml.IdentityProvider{
 				DisplayName: displayName,
 				URL: fmt.Sprintf("%s?%s&%s=%s",
 					authURL, r.URL.Query().Encode(), oidc.AuthorizeUpstreamIDPNameParamName, url.QueryEscape(displayName)),
+				Unavailable: unavailable,
 			}
 		}
 
 		var idps []chooseidphtml.IdentityProvider
 		for _, p := range upstreamIDPs.GetIdentityProviders() {
-			idps = append(idps, newIDPForPageData(p.GetDisplayName()))
+			idps = append(idps, newIDPForPageData(p.GetDisplayName(), !upstreamHealth.IsAvailable(p.GetProvider().GetResourceUID())))
 		}
 
 		sort.SliceStable(idps, func(i, j int) bool {
@@ -1,19 +1,24 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package chooseidp
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
 
 	"go.pinniped.dev/internal/federationdomain/endpoints/chooseidp/chooseidphtml"
 	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
 	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
 	"go.pinniped.dev/internal/testutil"
 	"go.pinniped.dev/internal/testutil/oidctestutil"
 	"go.pinniped.dev/internal/testutil/testidplister"
@@ -37,6 +42,8 @@ func TestChooseIDPHandler(t *testing.T) {
 		reqTarget string
 		idps      federationdomainproviders.FederationDomainIdentityProvidersListerI
 
+		unavailableUpstreamUIDs []types.UID
+
 		wantStatus      int
 		wantContentType string
 		wantBodyString  string
@@ -87,6 +94,22 @@ func TestChooseIDPHandler(t *testing.T) {
 				},
 			}),
 		},
+		{
+			name:      "happy path when some IDPs are currently unavailable",
+			method:    http.MethodGet,
+			reqTarget: "/some/path" + oidc.ChooseIDPEndpointPath + "?" + testReqQuery.Encode(),
+			idps: testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().WithName("oidc1").WithResourceUID("oidc1-uid").Build()).
+				WithLDAP(oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().WithName("ldap1").WithResourceUID("ldap1-uid").Build()).
+				BuildFederationDomainIdentityProvidersListerFinder(),
+			unavailableUpstreamUIDs: []types.UID{"oidc1-uid"},
+			wantStatus:              http.StatusOK,
+			wantContentType:         "text/html; charset=utf-8",
+			wantBodyString: testutil.ExpectedChooseIDPPageHTML(chooseidphtml.CSS(), chooseidphtml.JS(), []testutil.ChooseIDPPageExpectedValue{
+				{DisplayName: "ldap1", URL: testIssuerWithTestReqQuery + "&pinniped_idp_name=ldap1"},
+				{DisplayName: "oidc1", URL: testIssuerWithTestReqQuery + "&pinniped_idp_name=oidc1", Unavailable: true},
+			}),
+		},
 		{
 			name:      "no valid IDPs are configured on the FederationDomain",
 			method:    http.MethodGet,
@@ -135,7 +158,14 @@ func TestChooseIDPHandler(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			handler := NewHandler(testIssuer, test.idps)
+			upstreamHealth := upstreamhealth.NewTracker(clocktesting.NewFakeClock(time.Now()))
+			for _, uid := range test.unavailableUpstreamUIDs {
+				for range upstreamHealth.FailureThreshold {
+					upstreamHealth.RecordProbe(uid, errors.New("some probe error"))
+				}
+			}
+
+			handler := NewHandler(testIssuer, test.idps, upstreamHealth)
 
 			req := httptest.NewRequest(test.method, test.reqTarget, nil)
 			rsp := httptest.NewRecorder()
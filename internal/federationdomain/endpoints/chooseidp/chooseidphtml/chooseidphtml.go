@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package chooseidphtml
@@ -66,6 +66,7 @@ func JS() string { return minifiedJS }
 type IdentityProvider struct {
 	DisplayName string
 	URL         string
+	Unavailable bool
 }
 
 // PageData represents the inputs to the template.
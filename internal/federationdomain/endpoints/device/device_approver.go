@@ -0,0 +1,102 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ory/fosite"
+
+	"go.pinniped.dev/internal/federationdomain/downstreamsession"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/fositestorage/devicecode"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+)
+
+// Approver finishes a device authorization flow after the end user has logged in to an upstream identity
+// provider in their web browser. It is used by the endpoints which finish upstream logins (the callback and
+// login endpoints) when the upstream state param shows that the login was started at the device verification
+// endpoint instead of at the authorization endpoint.
+type Approver struct {
+	downstreamIssuerURL string
+	deviceCodeStorage   devicecode.DeviceCodeStorage
+	auditLogger         plog.AuditLogger
+}
+
+func NewApprover(
+	downstreamIssuerURL string,
+	deviceCodeStorage devicecode.DeviceCodeStorage,
+	auditLogger plog.AuditLogger,
+) *Approver {
+	return &Approver{
+		downstreamIssuerURL: downstreamIssuerURL,
+		deviceCodeStorage:   deviceCodeStorage,
+		auditLogger:         auditLogger,
+	}
+}
+
+// Approve creates the downstream session for the user's upstream identity and stores it on the pending
+// device code session which belongs to the given user code signature, so the device can redeem its device
+// code at the token endpoint. Then it redirects the user's browser to a page which tells them that they are done.
+func (a *Approver) Approve(
+	w http.ResponseWriter,
+	r *http.Request,
+	userCodeSignature string,
+	idp resolvedprovider.FederationDomainResolvedIdentityProvider,
+	identity *resolvedprovider.Identity,
+	loginExtras *resolvedprovider.IdentityLoginExtras,
+) error {
+	deviceCodeSignature, deviceRequester, err := loadDeviceRequest(r, a.deviceCodeStorage, userCodeSignature)
+	if err != nil {
+		plog.InfoErr("error loading device authorization request", err)
+		return httperr.Wrap(http.StatusBadRequest, "device authorization request not found, already used, or expired", err)
+	}
+
+	// The user could have taken a long time to log in at the upstream identity provider.
+	if deviceRequester.GetSession().GetExpiresAt(fosite.UserCode).Before(time.Now()) {
+		return httperr.New(http.StatusBadRequest, "device authorization request not found, already used, or expired")
+	}
+
+	// Automatically grant certain scopes, but only if they were requested. Note that fosite has already validated
+	// that the client was allowed to request these scopes at the device authorization endpoint.
+	downstreamsession.AutoApproveScopes(deviceRequester)
+
+	session, err := downstreamsession.NewPinnipedSession(r.Context(), a.auditLogger, &downstreamsession.SessionConfig{
+		UpstreamIdentity:    identity,
+		UpstreamLoginExtras: loginExtras,
+		ClientID:            deviceRequester.GetClient().GetID(),
+		GrantedScopes:       deviceRequester.GetGrantedScopes(),
+		RequestedScopes:     deviceRequester.GetRequestedScopes(),
+		RequestedAudience:   deviceRequester.GetRequestedAudience(),
+		IdentityProvider:    idp,
+		SessionIDGetter:     deviceRequester,
+	})
+	if err != nil {
+		plog.WarningErr("unable to create a Pinniped session", err,
+			"identityProviderDisplayName", idp.GetDisplayName(),
+			"identityProviderResourceName", idp.GetProvider().GetResourceName())
+		return httperr.Wrap(http.StatusUnprocessableEntity, err.Error(), err)
+	}
+
+	// Keep the expiration times which were decided when the device code and user code were issued.
+	for _, tokenType := range []fosite.TokenType{fosite.DeviceCode, fosite.UserCode} {
+		session.SetExpiresAt(tokenType, deviceRequester.GetSession().GetExpiresAt(tokenType))
+	}
+	deviceRequester.SetSession(session)
+
+	if err = a.deviceCodeStorage.AcceptDeviceCodeSession(r.Context(), deviceCodeSignature, deviceRequester); err != nil {
+		plog.WarningErr("error while approving device authorization request", err)
+		return httperr.Wrap(http.StatusInternalServerError, "error while approving device authorization request", err)
+	}
+
+	http.Redirect(w, r,
+		a.downstreamIssuerURL+oidc.DeviceVerificationEndpointPath+"?"+ResultParamName+"="+ResultApproved,
+		http.StatusSeeOther,
+	)
+
+	return nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/federationdomain/endpoints/jwks"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/federationdomain/strategy"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+	"go.pinniped.dev/internal/testutil/testidplister"
+)
+
+func TestApprover(t *testing.T) {
+	const (
+		upstreamName        = "some-upstream-idp"
+		upstreamResourceUID = "some-upstream-resource-uid"
+	)
+
+	tests := []struct {
+		name string
+		// When true, approve the same device authorization request twice.
+		approveTwice bool
+		// When true, use a user code signature which does not belong to any device authorization request.
+		useWrongUserCodeSignature bool
+
+		wantErr string
+	}{
+		{
+			name: "happy path",
+		},
+		{
+			name:         "the device authorization request was already approved",
+			approveTwice: true,
+			wantErr:      "device authorization request not found, already used, or expired: device code session is not waiting for user approval",
+		},
+		{
+			name:                      "the user code does not belong to any device authorization request",
+			useWrongUserCodeSignature: true,
+			wantErr:                   "device authorization request not found, already used, or expired: not_found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oauthHelper, oauthStore, _ := newTestOAuthHelper(t)
+			deviceCode, userCode := startDeviceAuthorization(t, oauthHelper, url.Values{
+				"client_id": []string{"pinniped-cli"},
+				"scope":     []string{"openid offline_access username groups"},
+			})
+
+			// Before the approval, the device should be told to keep waiting.
+			requireTokenEndpointError(t, oauthHelper, deviceCode, fosite.ErrAuthorizationPending)
+
+			idp, err := testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+					WithName(upstreamName).
+					WithResourceUID(types.UID(upstreamResourceUID)).
+					Build()).
+				WithDefaultIDPDisplayName(upstreamName).
+				BuildFederationDomainIdentityProvidersListerFinder().
+				FindDefaultIDP()
+			require.NoError(t, err)
+
+			auditLogger, _ := plog.TestAuditLogger(t)
+			subject := NewApprover(downstreamIssuer, oauthStore, auditLogger)
+
+			signature := userCodeSignature(t, userCode)
+			if test.useWrongUserCodeSignature {
+				signature = userCodeSignature(t, "WRONGCOD")
+			}
+
+			approve := func() (*httptest.ResponseRecorder, error) {
+				req := httptest.NewRequest(http.MethodGet, downstreamIssuer+"/callback", nil)
+				rsp := httptest.NewRecorder()
+				err := subject.Approve(rsp, req, signature, idp, happyIdentity(), &resolvedprovider.IdentityLoginExtras{})
+				return rsp, err
+			}
+
+			rsp, err := approve()
+			if test.approveTwice {
+				require.NoError(t, err)
+				rsp, err = approve()
+			}
+
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+				var responder httperr.Responder
+				require.ErrorAs(t, err, &responder)
+				errRsp := httptest.NewRecorder()
+				responder.Respond(errRsp)
+				require.Equal(t, http.StatusBadRequest, errRsp.Code)
+				if !test.approveTwice {
+					// The device should still be told to keep waiting.
+					requireTokenEndpointError(t, oauthHelper, deviceCode, fosite.ErrAuthorizationPending)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, http.StatusSeeOther, rsp.Code)
+			require.Equal(t, downstreamIssuer+"/device_verification?result=approved", rsp.Header().Get("Location"))
+
+			// Now the device can redeem its device code.
+			accessRequest := newDeviceCodeAccessRequest(t, oauthHelper, deviceCode)
+			accessResponse, err := oauthHelper.NewAccessResponse(context.Background(), accessRequest)
+			require.NoError(t, err)
+			require.NotEmpty(t, accessResponse.GetAccessToken())
+			require.NotEmpty(t, accessResponse.GetExtra("refresh_token"))
+			require.NotEmpty(t, accessResponse.GetExtra("id_token"))
+			require.ElementsMatch(t, []string{"openid", "offline_access", "username", "groups"}, accessRequest.GetGrantedScopes())
+
+			session := accessRequest.GetSession().(*psession.PinnipedSession)
+			require.Equal(t, "https://some-upstream-issuer?sub=some-subject", session.Fosite.Claims.Subject)
+			require.Equal(t, "some-upstream-username", session.Custom.Username)
+			require.Equal(t, upstreamName, session.Custom.ProviderName)
+			require.Equal(t, types.UID(upstreamResourceUID), session.Custom.ProviderUID)
+			require.Equal(t, psession.ProviderTypeOIDC, session.Custom.ProviderType)
+			require.Equal(t, "some-upstream-refresh-token", session.Custom.OIDC.UpstreamRefreshToken)
+			require.Equal(t, "some-upstream-username", session.Fosite.Claims.Extra["username"])
+			require.Equal(t, []any{"group1", "group2"}, session.Fosite.Claims.Extra["groups"])
+
+			// The device code may only be redeemed once.
+			requireTokenEndpointError(t, oauthHelper, deviceCode, fosite.ErrInvalidGrant)
+		})
+	}
+}
+
+// happyIdentity returns an identity from an OIDC upstream identity provider.
+func happyIdentity() *resolvedprovider.Identity {
+	return &resolvedprovider.Identity{
+		UpstreamUsername:  "some-upstream-username",
+		UpstreamGroups:    []string{"group1", "group2"},
+		DownstreamSubject: "https://some-upstream-issuer?sub=some-subject",
+		IDPSpecificSessionData: &psession.OIDCSessionData{
+			UpstreamRefreshToken: "some-upstream-refresh-token",
+			UpstreamIssuer:       "https://some-upstream-issuer",
+			UpstreamSubject:      "some-subject",
+		},
+	}
+}
+
+// newTestOAuthHelper returns a fosite provider which is configured like the production code, using Kubernetes
+// storage backed by a fake client.
+func newTestOAuthHelper(t *testing.T) (fosite.OAuth2Provider, *storage.KubeStorage, corev1client.SecretInterface) {
+	t.Helper()
+
+	secrets := fake.NewSimpleClientset().CoreV1().Secrets("some-namespace")
+	oidcClientsClient := supervisorfake.NewSimpleClientset().ConfigV1alpha1().OIDCClients("some-namespace")
+
+	// Use lower minimum required bcrypt cost than we would use in production to keep unit the tests fast.
+	oauthStore := storage.NewKubeStorage(secrets, oidcClientsClient, oidc.DefaultOIDCTimeoutsConfiguration(), bcrypt.MinCost)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwksProvider := jwks.NewDynamicJWKSProvider()
+	jwksProvider.SetIssuerToJWKSMap(
+		nil, // public JWKS unused
+		map[string]*jose.JSONWebKey{
+			downstreamIssuer: {Key: key},
+		},
+	)
+
+	oauthHelper := oidc.FositeOauth2Helper(oauthStore, downstreamIssuer, hmacSecretFunc, jwksProvider, oidc.DefaultOIDCTimeoutsConfiguration())
+	return oauthHelper, oauthStore, secrets
+}
+
+// startDeviceAuthorization makes a request to the device authorization endpoint and returns the device code and user code.
+func startDeviceAuthorization(t *testing.T, oauthHelper fosite.OAuth2Provider, params url.Values) (string, string) {
+	t.Helper()
+
+	auditLogger, _ := plog.TestAuditLogger(t)
+	req := httptest.NewRequest(http.MethodPost, downstreamIssuer+oidc.DeviceAuthorizationEndpointPath, strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rsp := httptest.NewRecorder()
+
+	NewAuthorizationHandler(oauthHelper, auditLogger).ServeHTTP(rsp, req)
+	require.Equal(t, http.StatusOK, rsp.Code, rsp.Body.String())
+
+	var body struct {
+		DeviceCode string `json:"device_code"`
+		UserCode   string `json:"user_code"`
+	}
+	require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &body))
+	return body.DeviceCode, body.UserCode
+}
+
+func userCodeSignature(t *testing.T, userCode string) string {
+	t.Helper()
+
+	signature, err := strategy.NewDynamicOauth2HMACStrategy(&fosite.Config{}, hmacSecretFunc).UserCodeSignature(context.Background(), userCode)
+	require.NoError(t, err)
+	return signature
+}
+
+func newDeviceCodeAccessRequest(t *testing.T, oauthHelper fosite.OAuth2Provider, deviceCode string) fosite.AccessRequester {
+	t.Helper()
+
+	accessRequest, err := oauthHelper.NewAccessRequest(context.Background(), newDeviceCodeTokenRequest(deviceCode), &psession.PinnipedSession{})
+	require.NoError(t, err)
+	return accessRequest
+}
+
+func requireTokenEndpointError(t *testing.T, oauthHelper fosite.OAuth2Provider, deviceCode string, wantErr *fosite.RFC6749Error) {
+	t.Helper()
+
+	_, err := oauthHelper.NewAccessRequest(context.Background(), newDeviceCodeTokenRequest(deviceCode), &psession.PinnipedSession{})
+	require.ErrorIs(t, err, wantErr)
+}
+
+func newDeviceCodeTokenRequest(deviceCode string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, downstreamIssuer+oidc.TokenEndpointPath, strings.NewReader(url.Values{
+		"grant_type":  []string{"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": []string{deviceCode},
+		"client_id":   []string{"pinniped-cli"},
+	}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func hmacSecretFunc() []byte {
+	return []byte(hmacSecret)
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package device provides the handlers for the OAuth 2.0 Device Authorization Grant (RFC 8628).
+package device
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/ory/fosite"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+func authorizationParamsSafeToLog() sets.Set[string] {
+	return sets.New(
+		// Standard params from https://datatracker.ietf.org/doc/html/rfc8628#section-3.1.
+		// Redacting client_secret, in case the client sends it as a param instead of using basic auth.
+		"client_id", "scope",
+		// Also allow the custom param used to choose which identity provider will be used during verification.
+		oidcapi.AuthorizeUpstreamIDPNameParamName,
+	)
+}
+
+// NewAuthorizationHandler returns a http.Handler that serves the device authorization endpoint from
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.1. The client receives a device code, which
+// it may redeem at the token endpoint after the end user has visited the device verification endpoint
+// in their web browser, entered the user code, and authenticated with an upstream identity provider.
+func NewAuthorizationHandler(
+	oauthHelper fosite.OAuth2Provider,
+	auditLogger plog.AuditLogger,
+) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if err := auditLogger.AuditRequestParams(r, authorizationParamsSafeToLog()); err != nil {
+			oauthHelper.WriteAccessError(r.Context(), w, nil, err)
+			return nil
+		}
+
+		// Validates that the request is a POST, authenticates the client, and validates the
+		// requested scopes and audience, and that the client is allowed to use the device grant.
+		deviceRequester, err := oauthHelper.NewDeviceRequest(r.Context(), r)
+		if err != nil {
+			plog.Info("device authorization request error", oidc.FositeErrorForLog(err)...)
+			oauthHelper.WriteAccessError(r.Context(), w, nil, err)
+			return nil
+		}
+
+		// Create and store the device code session, which will hold an empty session until the end user
+		// has authenticated and approved the request at the device verification endpoint.
+		deviceResponder, err := oauthHelper.NewDeviceResponse(r.Context(), deviceRequester, psession.NewPinnipedSession())
+		if err != nil {
+			plog.Info("device authorization response error", oidc.FositeErrorForLog(err)...)
+			oauthHelper.WriteAccessError(r.Context(), w, nil, err)
+			return nil
+		}
+
+		// If the client chose an identity provider, then carry that choice through to the verification page,
+		// so the end user does not need to choose again.
+		if idpName := r.PostForm.Get(oidcapi.AuthorizeUpstreamIDPNameParamName); idpName != "" {
+			deviceResponder.SetVerificationURIComplete(deviceResponder.GetVerificationURIComplete() +
+				"&" + oidcapi.AuthorizeUpstreamIDPNameParamName + "=" + url.QueryEscape(idpName))
+		}
+
+		oauthHelper.WriteDeviceResponse(r.Context(), w, deviceRequester, deviceResponder)
+
+		return nil
+	})
+}
@@ -0,0 +1,204 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/fositestorage/devicecode"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/testutil"
+)
+
+const (
+	downstreamIssuer = "https://my-downstream-issuer.com/some-path"
+	hmacSecret       = "this needs to be at least 32 characters to meet entropy requirements"
+)
+
+func TestAuthorizationHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   url.Values
+
+		wantStatus                     int
+		wantBodyJSON                   string
+		wantVerificationURICompleteIDP string
+		wantAuditLogs                  []testutil.WantedAuditLog
+	}{
+		{
+			name:   "happy path",
+			method: http.MethodPost,
+			body: url.Values{
+				"client_id": []string{"pinniped-cli"},
+				"scope":     []string{"openid offline_access pinniped:request-audience username groups"},
+			},
+			wantStatus: http.StatusOK,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"client_id": "pinniped-cli",
+						"scope":     "openid offline_access pinniped:request-audience username groups",
+					},
+				}),
+			},
+		},
+		{
+			name:   "happy path with an identity provider name",
+			method: http.MethodPost,
+			body: url.Values{
+				"client_id":         []string{"pinniped-cli"},
+				"scope":             []string{"openid offline_access"},
+				"pinniped_idp_name": []string{"some idp&name"},
+			},
+			wantStatus:                     http.StatusOK,
+			wantVerificationURICompleteIDP: "some idp&name",
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"client_id":         "pinniped-cli",
+						"scope":             "openid offline_access",
+						"pinniped_idp_name": "some idp&name",
+					},
+				}),
+			},
+		},
+		{
+			name:   "wrong HTTP method",
+			method: http.MethodGet,
+			body: url.Values{
+				"client_id": []string{"pinniped-cli"},
+				"scope":     []string{"openid"},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_request",
+				"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. HTTP method is 'GET', expected 'POST'."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"client_id": "pinniped-cli",
+						"scope":     "openid",
+					},
+				}),
+			},
+		},
+		{
+			name:   "unknown client",
+			method: http.MethodPost,
+			body: url.Values{
+				"client_id": []string{"some-unknown-client"},
+				"scope":     []string{"openid"},
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBodyJSON: `{
+				"error":             "invalid_client",
+				"error_description": "Client authentication failed (e.g., unknown client, no client authentication included, or unsupported authentication method)."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"client_id": "some-unknown-client",
+						"scope":     "openid",
+					},
+				}),
+			},
+		},
+		{
+			name:   "scope not allowed for client",
+			method: http.MethodPost,
+			body: url.Values{
+				"client_id": []string{"pinniped-cli"},
+				"scope":     []string{"openid some-unknown-scope"},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_scope",
+				"error_description": "The requested scope is invalid, unknown, or malformed. The OAuth 2.0 Client is not allowed to request scope 'some-unknown-scope'."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"client_id": "pinniped-cli",
+						"scope":     "openid some-unknown-scope",
+					},
+				}),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oauthHelper, _, secrets := newTestOAuthHelper(t)
+
+			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+			subject := NewAuthorizationHandler(oauthHelper, auditLogger)
+
+			var req *http.Request
+			if test.method == http.MethodPost {
+				req = httptest.NewRequest(test.method, downstreamIssuer+oidc.DeviceAuthorizationEndpointPath, strings.NewReader(test.body.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(test.method, downstreamIssuer+oidc.DeviceAuthorizationEndpointPath+"?"+test.body.Encode(), nil)
+			}
+			rsp := httptest.NewRecorder()
+
+			subject.ServeHTTP(rsp, req)
+			t.Logf("response: %#v", rsp)
+			t.Logf("response body: %q", rsp.Body.String())
+
+			require.Equal(t, test.wantStatus, rsp.Code)
+			require.Equal(t, "application/json;charset=UTF-8", rsp.Header().Get("Content-Type"))
+
+			testutil.CompareAuditLogs(t, test.wantAuditLogs, actualAuditLog.String())
+
+			allSecrets, err := secrets.List(req.Context(), metav1.ListOptions{})
+			require.NoError(t, err)
+
+			if test.wantBodyJSON != "" {
+				require.JSONEq(t, test.wantBodyJSON, rsp.Body.String())
+				require.Empty(t, allSecrets.Items)
+				return
+			}
+
+			var body struct {
+				DeviceCode              string `json:"device_code"`
+				UserCode                string `json:"user_code"`
+				VerificationURI         string `json:"verification_uri"`
+				VerificationURIComplete string `json:"verification_uri_complete"`
+				ExpiresIn               int64  `json:"expires_in"`
+				Interval                int    `json:"interval"`
+			}
+			require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &body))
+
+			require.NotEmpty(t, body.DeviceCode)
+			require.Regexp(t, "^[A-Z]{8}$", body.UserCode)
+			require.Equal(t, downstreamIssuer+oidc.DeviceVerificationEndpointPath, body.VerificationURI)
+			wantVerificationURIComplete := body.VerificationURI + "?user_code=" + body.UserCode
+			if test.wantVerificationURICompleteIDP != "" {
+				wantVerificationURIComplete += "&pinniped_idp_name=" + url.QueryEscape(test.wantVerificationURICompleteIDP)
+			}
+			require.Equal(t, wantVerificationURIComplete, body.VerificationURIComplete)
+			require.InDelta(t, oidc.DefaultOIDCTimeoutsConfiguration().DeviceAndUserCodeLifespan.Seconds(), body.ExpiresIn, 5)
+			require.Positive(t, body.Interval)
+
+			// One device code session and one user code session should have been stored.
+			require.Len(t, allSecrets.Items, 2)
+			storageTypes := []string{
+				allSecrets.Items[0].Labels["storage.pinniped.dev/type"],
+				allSecrets.Items[1].Labels["storage.pinniped.dev/type"],
+			}
+			require.ElementsMatch(t, []string{devicecode.TypeLabelValue, devicecode.UserCodeTypeLabelValue}, storageTypes)
+		})
+	}
+}
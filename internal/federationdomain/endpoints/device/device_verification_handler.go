@@ -0,0 +1,375 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/rfc8628"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/federationdomain/csrftoken"
+	"go.pinniped.dev/internal/federationdomain/endpoints/device/devicehtml"
+	"go.pinniped.dev/internal/federationdomain/endpoints/upstreamlogin"
+	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/stateparam"
+	"go.pinniped.dev/internal/fositestorage/devicecode"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/httputil/securityheader"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/pkg/oidcclient/nonce"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+const (
+	// UserCodeParamName is the name of the param which holds the user code at the device verification endpoint.
+	UserCodeParamName = "user_code"
+
+	// CSRFParamName is the name of the form param which holds the CSRF value when the user confirms the login
+	// at the device verification endpoint.
+	CSRFParamName = "csrf"
+
+	// ResultParamName is the name of the param which the device verification endpoint uses to show the outcome
+	// of the login to the end user.
+	ResultParamName = "result"
+
+	// ResultApproved is the value of the ResultParamName param after a successful login.
+	ResultApproved = "approved"
+
+	invalidUserCodeMessage = "The code you entered is invalid or has expired. Please check the code on your device and try again."
+)
+
+func verificationParamsSafeToLog() sets.Set[string] {
+	return sets.New(
+		oidcapi.AuthorizeUpstreamIDPNameParamName,
+		ResultParamName,
+		// Note that this endpoint also receives the 'user_code' and 'csrf' params, which are not safe to log.
+	)
+}
+
+type verificationHandler struct {
+	downstreamIssuerURL  string
+	idpFinder            federationdomainproviders.FederationDomainIdentityProvidersListerFinderI
+	deviceCodeStorage    devicecode.DeviceCodeStorage
+	userCodeStrategy     rfc8628.UserCodeStrategy
+	generateCSRF         func() (csrftoken.CSRFToken, error)
+	generatePKCE         func() (pkce.Code, error)
+	generateNonce        func() (nonce.Nonce, error)
+	upstreamStateEncoder oidc.Encoder
+	cookieCodec          oidc.Codec
+	auditLogger          plog.AuditLogger
+}
+
+// NewVerificationHandler returns a http.Handler that serves the device verification endpoint from
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.3. The end user enters the user code which was
+// displayed by their device, optionally chooses an identity provider, and confirms the login after seeing which
+// client requested it. Only then is the user sent to log in with that identity provider in the same way as they would
+// be at the authorization endpoint. After the login has
+// finished at the callback or login endpoint, the Approver approves the pending device code session.
+func NewVerificationHandler(
+	downstreamIssuerURL string,
+	idpFinder federationdomainproviders.FederationDomainIdentityProvidersListerFinderI,
+	deviceCodeStorage devicecode.DeviceCodeStorage,
+	userCodeStrategy rfc8628.UserCodeStrategy,
+	generateCSRF func() (csrftoken.CSRFToken, error),
+	generatePKCE func() (pkce.Code, error),
+	generateNonce func() (nonce.Nonce, error),
+	upstreamStateEncoder oidc.Encoder,
+	cookieCodec oidc.Codec,
+	auditLogger plog.AuditLogger,
+) http.Handler {
+	h := &verificationHandler{
+		downstreamIssuerURL:  downstreamIssuerURL,
+		idpFinder:            idpFinder,
+		deviceCodeStorage:    deviceCodeStorage,
+		userCodeStrategy:     userCodeStrategy,
+		generateCSRF:         generateCSRF,
+		generatePKCE:         generatePKCE,
+		generateNonce:        generateNonce,
+		upstreamStateEncoder: upstreamStateEncoder,
+		cookieCodec:          cookieCodec,
+		auditLogger:          auditLogger,
+	}
+	return securityheader.WrapWithCustomCSP(httperr.HandlerFunc(h.serveHTTP), devicehtml.ContentSecurityPolicy())
+}
+
+func (h *verificationHandler) serveHTTP(w http.ResponseWriter, r *http.Request) error {
+	if err := h.auditLogger.AuditRequestParams(r, verificationParamsSafeToLog()); err != nil {
+		plog.DebugErr("error parsing device verification request params", err)
+		return httperr.New(http.StatusBadRequest, "error parsing request params")
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return h.showConfirmation(w, r)
+	case http.MethodPost:
+		return h.startUpstreamLogin(w, r)
+	default:
+		return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET or POST)", r.Method)
+	}
+}
+
+// showConfirmation asks the user to enter their user code and to choose an identity provider, as needed, and then
+// asks the user to confirm the login. The user must confirm the login before they are sent to the upstream identity
+// provider, because anyone can send a link containing a user code to a victim, who might otherwise unknowingly
+// log in on behalf of the attacker's device. See https://datatracker.ietf.org/doc/html/rfc8628#section-5.4.
+func (h *verificationHandler) showConfirmation(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	if query.Get(ResultParamName) == ResultApproved {
+		return devicehtml.Template().Execute(w, &devicehtml.PageData{Approved: true})
+	}
+
+	idpName := query.Get(oidcapi.AuthorizeUpstreamIDPNameParamName)
+	userCode := normalizeUserCode(query.Get(UserCodeParamName))
+
+	formPageData := &devicehtml.PageData{
+		FormPath: h.verificationURL(),
+		UserCode: userCode,
+		IDPName:  idpName,
+	}
+
+	if userCode == "" {
+		// Ask the user to enter the code displayed on their device.
+		return devicehtml.Template().Execute(w, formPageData)
+	}
+
+	_, deviceRequester, err := h.findPendingDeviceCodeSession(r, userCode)
+	if err != nil {
+		plog.InfoErr("device verification user code error", err)
+		formPageData.HasAlertError = true
+		formPageData.AlertMessage = invalidUserCodeMessage
+		return devicehtml.Template().Execute(w, formPageData)
+	}
+
+	if upstreamlogin.ShouldShowIDPChooser(h.idpFinder, idpName) {
+		return devicehtml.Template().Execute(w, &devicehtml.PageData{
+			IdentityProviders: h.identityProvidersForPage(userCode),
+		})
+	}
+
+	idp, err := upstreamlogin.ChooseUpstreamIDP(idpName, h.idpFinder)
+	if err != nil {
+		plog.WarningErr("device verification could not choose upstream provider", err)
+		return httperr.Newf(http.StatusUnprocessableEntity, "%q param error: %s", oidcapi.AuthorizeUpstreamIDPNameParamName, err.Error())
+	}
+
+	csrfValue := upstreamlogin.ReadCSRFCookie(r, h.cookieCodec)
+	if csrfValue == "" {
+		// We did not receive an incoming CSRF cookie, so write a new one.
+		csrfValue, err = h.generateCSRF()
+		if err != nil {
+			plog.Error("device verification error generating CSRF token", err)
+			return httperr.Wrap(http.StatusInternalServerError, "error generating CSRF token", err)
+		}
+		if err = upstreamlogin.AddCSRFSetCookieHeader(w, csrfValue, h.cookieCodec); err != nil {
+			plog.Error("device verification error setting CSRF cookie", err)
+			return httperr.Wrap(http.StatusInternalServerError, "error encoding CSRF cookie", err)
+		}
+	}
+
+	formPageData.Confirmation = &devicehtml.Confirmation{
+		ClientID:                    deviceRequester.GetClient().GetID(),
+		IdentityProviderDisplayName: idp.GetDisplayName(),
+	}
+	formPageData.CSRFToken = string(csrfValue)
+	return devicehtml.Template().Execute(w, formPageData)
+}
+
+// startUpstreamLogin handles the form which the user posts to confirm the login, and redirects the user to the
+// upstream identity provider.
+func (h *verificationHandler) startUpstreamLogin(w http.ResponseWriter, r *http.Request) error {
+	// The form must have been rendered by the confirmation page for this browser, so it must echo the CSRF cookie.
+	csrfFromCookie := upstreamlogin.ReadCSRFCookie(r, h.cookieCodec)
+	if csrfFromCookie == "" ||
+		subtle.ConstantTimeCompare([]byte(r.PostFormValue(CSRFParamName)), []byte(csrfFromCookie)) != 1 {
+		return httperr.New(http.StatusForbidden, "CSRF value does not match")
+	}
+
+	idpName := r.PostFormValue(oidcapi.AuthorizeUpstreamIDPNameParamName)
+	userCode := normalizeUserCode(r.PostFormValue(UserCodeParamName))
+
+	userCodeSignature, _, err := h.findPendingDeviceCodeSession(r, userCode)
+	if err != nil {
+		plog.InfoErr("device verification user code error", err)
+		return devicehtml.Template().Execute(w, &devicehtml.PageData{
+			FormPath:      h.verificationURL(),
+			UserCode:      userCode,
+			IDPName:       idpName,
+			HasAlertError: true,
+			AlertMessage:  invalidUserCodeMessage,
+		})
+	}
+
+	idp, err := upstreamlogin.ChooseUpstreamIDP(idpName, h.idpFinder)
+	if err != nil {
+		plog.WarningErr("device verification could not choose upstream provider", err)
+		return httperr.Newf(http.StatusUnprocessableEntity, "%q param error: %s", oidcapi.AuthorizeUpstreamIDPNameParamName, err.Error())
+	}
+
+	h.auditLogger.Audit(auditevent.UsingUpstreamIDP, &plog.AuditParams{
+		ReqCtx: r.Context(),
+		KeysAndValues: []any{
+			"displayName", idp.GetDisplayName(),
+			"resourceName", idp.GetProvider().GetResourceName(),
+			"resourceUID", idp.GetProvider().GetResourceUID(),
+			"type", idp.GetSessionProviderType(),
+		},
+	})
+
+	authRequestState, err := h.upstreamAuthorizeRequestState(idp, userCodeSignature, csrfFromCookie)
+	if err != nil {
+		plog.Error("device verification error", err)
+		return httperr.Wrap(http.StatusInternalServerError, "error preparing upstream login", err)
+	}
+
+	redirectURL, err := idp.UpstreamAuthorizeRedirectURL(r.Context(), authRequestState, h.downstreamIssuerURL)
+	if err != nil {
+		plog.Error("device verification upstream redirect error", err)
+		return httperr.Wrap(http.StatusInternalServerError, "error preparing upstream login", err)
+	}
+
+	h.auditLogger.Audit(auditevent.UpstreamAuthorizeRedirect, &plog.AuditParams{
+		ReqCtx:        r.Context(),
+		KeysAndValues: []any{"authorizeID", authRequestState.EncodedStateParam.AuthorizeID()},
+	})
+
+	http.Redirect(w, r, redirectURL,
+		http.StatusSeeOther, // match fosite and https://tools.ietf.org/id/draft-ietf-oauth-security-topics-18.html#section-4.11
+	)
+
+	return nil
+}
+
+func (h *verificationHandler) verificationURL() string {
+	return h.downstreamIssuerURL + oidc.DeviceVerificationEndpointPath
+}
+
+// findPendingDeviceCodeSession returns the signature of the given user code and the stored device request, after
+// checking that the user code belongs to a device code session which has not expired and which is still waiting
+// for the user's approval.
+func (h *verificationHandler) findPendingDeviceCodeSession(r *http.Request, userCode string) (string, fosite.DeviceRequester, error) {
+	if userCode == "" {
+		return "", nil, errors.New("missing user code")
+	}
+
+	userCodeSignature, err := h.userCodeStrategy.UserCodeSignature(r.Context(), userCode)
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, deviceRequester, err := loadDeviceRequest(r, h.deviceCodeStorage, userCodeSignature)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Checks that the user code has not expired.
+	if err = h.userCodeStrategy.ValidateUserCode(r.Context(), deviceRequester, userCode); err != nil {
+		return "", nil, err
+	}
+
+	return userCodeSignature, deviceRequester, nil
+}
+
+func (h *verificationHandler) identityProvidersForPage(userCode string) []devicehtml.IdentityProvider {
+	var idps []devicehtml.IdentityProvider
+	for _, p := range h.idpFinder.GetIdentityProviders() {
+		idps = append(idps, devicehtml.IdentityProvider{
+			DisplayName: p.GetDisplayName(),
+			URL: fmt.Sprintf("%s?%s", h.verificationURL(), url.Values{
+				UserCodeParamName:                         []string{userCode},
+				oidcapi.AuthorizeUpstreamIDPNameParamName: []string{p.GetDisplayName()},
+			}.Encode()),
+		})
+	}
+
+	sort.SliceStable(idps, func(i, j int) bool {
+		return idps[i].DisplayName < idps[j].DisplayName
+	})
+
+	return idps
+}
+
+// upstreamAuthorizeRequestState generates the state param and returns the values needed to redirect to the
+// upstream identity provider. This is similar to what the authorization endpoint does for browser-based flows,
+// except that the state param holds the user code's signature instead of the parameters of a downstream
+// authorization request, and the CSRF value always comes from the existing CSRF cookie.
+func (h *verificationHandler) upstreamAuthorizeRequestState(
+	idp resolvedprovider.FederationDomainResolvedIdentityProvider,
+	userCodeSignature string,
+	csrfValue csrftoken.CSRFToken,
+) (*resolvedprovider.UpstreamAuthorizeRequestState, error) {
+	nonceValue, err := h.generateNonce()
+	if err != nil {
+		return nil, fmt.Errorf("error generating nonce param: %w", err)
+	}
+	pkceValue, err := h.generatePKCE()
+	if err != nil {
+		return nil, fmt.Errorf("error generating PKCE param: %w", err)
+	}
+
+	encodedStateParamValue, err := h.upstreamStateEncoder.Encode(oidc.UpstreamStateParamEncodingName, oidc.UpstreamStateParamData{
+		UpstreamName:            idp.GetDisplayName(),
+		UpstreamType:            string(idp.GetSessionProviderType()),
+		Nonce:                   nonceValue,
+		CSRFToken:               csrfValue,
+		PKCECode:                pkceValue,
+		DeviceUserCodeSignature: userCodeSignature,
+		FormatVersion:           oidc.UpstreamStateParamFormatVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding upstream state param: %w", err)
+	}
+
+	return &resolvedprovider.UpstreamAuthorizeRequestState{
+		EncodedStateParam: stateparam.Encoded(encodedStateParamValue),
+		PKCE:              pkceValue,
+		Nonce:             nonceValue,
+	}, nil
+}
+
+// loadDeviceRequest finds the device code session for the given user code signature, and checks that it is
+// still waiting for the user's approval. It returns the signature of the device code and the stored request.
+func loadDeviceRequest(
+	r *http.Request,
+	deviceCodeStorage devicecode.DeviceCodeStorage,
+	userCodeSignature string,
+) (string, fosite.DeviceRequester, error) {
+	deviceCodeSignature, err := deviceCodeStorage.GetDeviceCodeSignatureByUserCode(r.Context(), userCodeSignature)
+	if err != nil {
+		return "", nil, err
+	}
+
+	deviceRequester, err := deviceCodeStorage.GetDeviceCodeSession(r.Context(), deviceCodeSignature, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if deviceRequester.GetUserCodeState() != fosite.UserCodeUnused {
+		return "", nil, devicecode.ErrDeviceCodeSessionNotPending
+	}
+
+	return deviceCodeSignature, deviceRequester, nil
+}
+
+// normalizeUserCode allows the user to type the user code in lowercase, and with spaces or dashes.
+func normalizeUserCode(userCode string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, userCode))
+}
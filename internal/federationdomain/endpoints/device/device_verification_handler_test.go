@@ -0,0 +1,540 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+
+	idpdiscoveryv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
+	"go.pinniped.dev/internal/federationdomain/csrftoken"
+	"go.pinniped.dev/internal/federationdomain/endpoints/device/devicehtml"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/stateparam"
+	"go.pinniped.dev/internal/federationdomain/strategy"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+	"go.pinniped.dev/internal/testutil/testidplister"
+	"go.pinniped.dev/pkg/oidcclient/nonce"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+func TestVerificationHandler(t *testing.T) {
+	const (
+		oidcUpstreamName        = "some-oidc-idp"
+		oidcUpstreamResourceUID = "oidc-resource-uid"
+		ldapUpstreamName        = "some-ldap-idp"
+		ldapUpstreamResourceUID = "ldap-resource-uid"
+
+		happyCSRF  = "test-csrf"
+		happyPKCE  = "test-pkce"
+		happyNonce = "test-nonce"
+	)
+
+	upstreamAuthURL, err := url.Parse("https://some-upstream-idp/authorize")
+	require.NoError(t, err)
+
+	happyCSRFGenerator := func() (csrftoken.CSRFToken, error) { return happyCSRF, nil }
+	happyPKCEGenerator := func() (pkce.Code, error) { return happyPKCE, nil }
+	happyNonceGenerator := func() (nonce.Nonce, error) { return happyNonce, nil }
+
+	var stateEncoderHashKey = []byte("fake-hash-secret")
+	var stateEncoderBlockKey = []byte("0123456789ABCDEF") // block encryption requires 16/24/32 bytes for AES
+	var cookieEncoderHashKey = []byte("fake-hash-secret2")
+	var cookieEncoderBlockKey = []byte("0123456789ABCDE2") // block encryption requires 16/24/32 bytes for AES
+
+	var happyStateEncoder = securecookie.New(stateEncoderHashKey, stateEncoderBlockKey)
+	happyStateEncoder.SetSerializer(securecookie.JSONEncoder{})
+	var happyCookieEncoder = securecookie.New(cookieEncoderHashKey, cookieEncoderBlockKey)
+	happyCookieEncoder.SetSerializer(securecookie.JSONEncoder{})
+
+	oidcUpstream := func() *oidctestutil.TestUpstreamOIDCIdentityProvider {
+		return oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+			WithName(oidcUpstreamName).
+			WithResourceUID(oidcUpstreamResourceUID).
+			WithClientID("some-client-id").
+			WithAuthorizationURL(*upstreamAuthURL).
+			WithScopes([]string{"scope1", "scope2"}).
+			Build()
+	}
+
+	ldapUpstream := func() *oidctestutil.TestUpstreamLDAPIdentityProvider {
+		return oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+			WithName(ldapUpstreamName).
+			WithResourceUID(ldapUpstreamResourceUID).
+			Build()
+	}
+
+	singleDefaultOIDCUpstream := func() *testidplister.UpstreamIDPListerBuilder {
+		return testidplister.NewUpstreamIDPListerBuilder().
+			WithOIDC(oidcUpstream()).
+			WithDefaultIDPDisplayName(oidcUpstreamName)
+	}
+
+	multipleUpstreams := func() *testidplister.UpstreamIDPListerBuilder {
+		return testidplister.NewUpstreamIDPListerBuilder().
+			WithOIDC(oidcUpstream()).
+			WithLDAP(ldapUpstream())
+	}
+
+	// The verification URL is always absolute, since the user may open it from a different device.
+	verificationURL := downstreamIssuer + oidc.DeviceVerificationEndpointPath
+
+	tests := []struct {
+		name   string
+		idps   *testidplister.UpstreamIDPListerBuilder
+		method string
+		// The params to send, in addition to the user code, when sendUserCode is true.
+		// They are sent in the query for a GET, and in the form body for a POST.
+		query url.Values
+		// When true, send the user code from a new device authorization request.
+		sendUserCode bool
+		// When true, send the user code from a new device authorization request in lowercase with a dash in the middle.
+		sendUserCodeWithFormatting bool
+		// When true, send a user code which does not belong to any device authorization request.
+		sendUnknownUserCode bool
+		// When true, the device authorization request was already approved before the user code was sent.
+		alreadyApproved bool
+		// When not empty, send a CSRF cookie which was already set by a previous request.
+		csrfCookie string
+		// When not empty, send this CSRF value in the posted form.
+		csrfFormValue string
+
+		wantStatus          int
+		wantContentType     string
+		wantBodyContains    []string
+		wantBodyNotContains []string
+		wantBodyString      string
+		// When not empty, expect a redirect to the upstream login, with a state param which was encoded with these values.
+		wantUpstreamStateParam *oidctestutil.UpstreamStateParamBuilder
+		// When not empty, expect a redirect to a URL which starts with this prefix.
+		wantLocationPrefix          string
+		wantCSRFValueInCookieHeader string
+		wantAuditLogs               func(encodedStateParam string) []testutil.WantedAuditLog
+	}{
+		{
+			name:            "no user code shows the form to enter a user code",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodGet,
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`<h1>Enter the code displayed on your device</h1>`,
+				fmt.Sprintf(`<form action="%s" method="get">`, verificationURL),
+			},
+			wantBodyNotContains: []string{`role="alert"`},
+		},
+		{
+			name:            "no user code with an identity provider name shows the form and remembers the identity provider name",
+			idps:            multipleUpstreams(),
+			method:          http.MethodGet,
+			query:           url.Values{"pinniped_idp_name": []string{ldapUpstreamName}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				fmt.Sprintf(`<input type="hidden" name="pinniped_idp_name" id="pinniped_idp_name" value="%s">`, ldapUpstreamName),
+			},
+			wantAuditLogs: func(string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"pinniped_idp_name": ldapUpstreamName},
+					}),
+				}
+			},
+		},
+		{
+			name:            "the result param shows the success page",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodGet,
+			query:           url.Values{"result": []string{"approved"}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`<h1>Device login succeeded</h1>`,
+			},
+			wantBodyNotContains: []string{`<form`},
+			wantAuditLogs: func(string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"result": "approved"},
+					}),
+				}
+			},
+		},
+		{
+			name:            "wrong HTTP method",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodPut,
+			wantStatus:      http.StatusMethodNotAllowed,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Method Not Allowed: PUT (try GET or POST)\n",
+		},
+		{
+			name:                "unknown user code shows the form with an error",
+			idps:                singleDefaultOIDCUpstream(),
+			method:              http.MethodGet,
+			sendUnknownUserCode: true,
+			wantStatus:          http.StatusOK,
+			wantContentType:     "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`role="alert"`,
+				`The code you entered is invalid or has expired.`,
+				`<input type="text" name="user_code" id="user_code" value="WRONGCOD"`,
+			},
+		},
+		{
+			name:            "user code which was already approved shows the form with an error",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodGet,
+			sendUserCode:    true,
+			alreadyApproved: true,
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`role="alert"`,
+				`The code you entered is invalid or has expired.`,
+			},
+		},
+		{
+			name:            "multiple identity providers without a default and no identity provider name shows the chooser",
+			idps:            multipleUpstreams(),
+			method:          http.MethodGet,
+			sendUserCode:    true,
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`<h1>Choose an identity provider to log in</h1>`,
+				fmt.Sprintf(`>%s</a></li>`, ldapUpstreamName),
+				fmt.Sprintf(`>%s</a></li>`, oidcUpstreamName),
+				fmt.Sprintf(`?pinniped_idp_name=%s&amp;user_code=`, ldapUpstreamName),
+			},
+			wantBodyNotContains: []string{`<form`},
+		},
+		{
+			name:                        "default OIDC identity provider asks the user to confirm the login and sets a CSRF cookie",
+			idps:                        singleDefaultOIDCUpstream(),
+			method:                      http.MethodGet,
+			sendUserCode:                true,
+			wantStatus:                  http.StatusOK,
+			wantContentType:             "text/html; charset=utf-8",
+			wantCSRFValueInCookieHeader: happyCSRF,
+			wantBodyContains: []string{
+				`<h1>Confirm the device login</h1>`,
+				fmt.Sprintf(`The client <strong>pinniped-cli</strong> is asking to log in using your account from <strong>%s</strong>.`, oidcUpstreamName),
+				fmt.Sprintf(`<form action="%s" method="post">`, verificationURL),
+				fmt.Sprintf(`<input type="hidden" name="csrf" id="csrf" value="%s">`, happyCSRF),
+			},
+			wantBodyNotContains: []string{`role="alert"`},
+			wantAuditLogs: func(string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"user_code": "redacted"},
+					}),
+				}
+			},
+		},
+		{
+			name:            "requested identity provider asks the user to confirm the login and reuses an existing CSRF cookie",
+			idps:            multipleUpstreams(),
+			method:          http.MethodGet,
+			query:           url.Values{"pinniped_idp_name": []string{ldapUpstreamName}},
+			sendUserCode:    true,
+			csrfCookie:      "some-existing-csrf-value",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				fmt.Sprintf(`is asking to log in using your account from <strong>%s</strong>.`, ldapUpstreamName),
+				fmt.Sprintf(`<input type="hidden" name="pinniped_idp_name" id="pinniped_idp_name" value="%s">`, ldapUpstreamName),
+				`<input type="hidden" name="csrf" id="csrf" value="some-existing-csrf-value">`,
+			},
+		},
+		{
+			name:                "confirming the login for the default OIDC identity provider redirects to the upstream authorize endpoint",
+			idps:                singleDefaultOIDCUpstream(),
+			method:              http.MethodPost,
+			sendUserCode:        true,
+			csrfCookie:          happyCSRF,
+			csrfFormValue:       happyCSRF,
+			wantStatus:          http.StatusSeeOther,
+			wantContentType:     "text/html; charset=utf-8",
+			wantBodyNotContains: []string{`<form`},
+			wantUpstreamStateParam: &oidctestutil.UpstreamStateParamBuilder{
+				U: oidcUpstreamName,
+				T: string(idpdiscoveryv1alpha1.IDPTypeOIDC),
+				N: happyNonce,
+				C: happyCSRF,
+				K: happyPKCE,
+				V: "2",
+			},
+			wantLocationPrefix: upstreamAuthURL.String() + "?",
+			wantAuditLogs: func(encodedStateParam string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"csrf": "redacted", "user_code": "redacted"},
+					}),
+					testutil.WantAuditLog("Using Upstream IDP", map[string]any{
+						"displayName":  oidcUpstreamName,
+						"resourceName": oidcUpstreamName,
+						"resourceUID":  oidcUpstreamResourceUID,
+						"type":         "oidc",
+					}),
+					testutil.WantAuditLog("Upstream Authorize Redirect", map[string]any{
+						"authorizeID": stateparam.Encoded(encodedStateParam).AuthorizeID(),
+					}),
+				}
+			},
+		},
+		{
+			name:                       "confirming the login accepts the user code in lowercase with formatting characters",
+			idps:                       singleDefaultOIDCUpstream(),
+			method:                     http.MethodPost,
+			sendUserCodeWithFormatting: true,
+			csrfCookie:                 happyCSRF,
+			csrfFormValue:              happyCSRF,
+			wantStatus:                 http.StatusSeeOther,
+			wantContentType:            "text/html; charset=utf-8",
+			wantUpstreamStateParam: &oidctestutil.UpstreamStateParamBuilder{
+				U: oidcUpstreamName,
+				T: string(idpdiscoveryv1alpha1.IDPTypeOIDC),
+				N: happyNonce,
+				C: happyCSRF,
+				K: happyPKCE,
+				V: "2",
+			},
+			wantLocationPrefix: upstreamAuthURL.String() + "?",
+		},
+		{
+			name:            "confirming the login for the requested LDAP identity provider redirects to the login page",
+			idps:            multipleUpstreams(),
+			method:          http.MethodPost,
+			query:           url.Values{"pinniped_idp_name": []string{ldapUpstreamName}},
+			sendUserCode:    true,
+			csrfCookie:      "some-existing-csrf-value",
+			csrfFormValue:   "some-existing-csrf-value",
+			wantStatus:      http.StatusSeeOther,
+			wantContentType: "text/html; charset=utf-8",
+			wantUpstreamStateParam: &oidctestutil.UpstreamStateParamBuilder{
+				U: ldapUpstreamName,
+				T: string(idpdiscoveryv1alpha1.IDPTypeLDAP),
+				N: happyNonce,
+				C: "some-existing-csrf-value",
+				K: happyPKCE,
+				V: "2",
+			},
+			wantLocationPrefix: downstreamIssuer + "/login?",
+		},
+		{
+			name:            "confirming the login without a CSRF cookie is forbidden",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodPost,
+			sendUserCode:    true,
+			csrfFormValue:   happyCSRF,
+			wantStatus:      http.StatusForbidden,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Forbidden: CSRF value does not match\n",
+			wantAuditLogs: func(string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"csrf": "redacted", "user_code": "redacted"},
+					}),
+				}
+			},
+		},
+		{
+			name:            "confirming the login without a CSRF form value is forbidden",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodPost,
+			sendUserCode:    true,
+			csrfCookie:      happyCSRF,
+			wantStatus:      http.StatusForbidden,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Forbidden: CSRF value does not match\n",
+		},
+		{
+			name:            "confirming the login with a CSRF form value which does not match the cookie is forbidden",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodPost,
+			sendUserCode:    true,
+			csrfCookie:      happyCSRF,
+			csrfFormValue:   "some-other-csrf-value",
+			wantStatus:      http.StatusForbidden,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Forbidden: CSRF value does not match\n",
+		},
+		{
+			name:                "confirming the login with an unknown user code shows the form with an error",
+			idps:                singleDefaultOIDCUpstream(),
+			method:              http.MethodPost,
+			sendUnknownUserCode: true,
+			csrfCookie:          happyCSRF,
+			csrfFormValue:       happyCSRF,
+			wantStatus:          http.StatusOK,
+			wantContentType:     "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`role="alert"`,
+				`The code you entered is invalid or has expired.`,
+				`<input type="text" name="user_code" id="user_code" value="WRONGCOD"`,
+			},
+		},
+		{
+			name:            "confirming the login with a user code which was already approved shows the form with an error",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodPost,
+			sendUserCode:    true,
+			alreadyApproved: true,
+			csrfCookie:      happyCSRF,
+			csrfFormValue:   happyCSRF,
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`role="alert"`,
+				`The code you entered is invalid or has expired.`,
+			},
+		},
+		{
+			name:            "requested identity provider is not found",
+			idps:            singleDefaultOIDCUpstream(),
+			method:          http.MethodGet,
+			query:           url.Values{"pinniped_idp_name": []string{"some-other-idp"}},
+			sendUserCode:    true,
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Unprocessable Entity: \"pinniped_idp_name\" param error: did not find IDP with name \"some-other-idp\"\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oauthHelper, oauthStore, _ := newTestOAuthHelper(t)
+			_, userCode := startDeviceAuthorization(t, oauthHelper, url.Values{
+				"client_id": []string{"pinniped-cli"},
+				"scope":     []string{"openid offline_access username groups"},
+			})
+
+			if test.alreadyApproved {
+				idp, err := test.idps.BuildFederationDomainIdentityProvidersListerFinder().FindDefaultIDP()
+				require.NoError(t, err)
+				approverAuditLogger, _ := plog.TestAuditLogger(t)
+				err = NewApprover(downstreamIssuer, oauthStore, approverAuditLogger).Approve(
+					httptest.NewRecorder(),
+					httptest.NewRequest(http.MethodGet, downstreamIssuer+"/callback", nil),
+					userCodeSignature(t, userCode),
+					idp,
+					happyIdentity(),
+					&resolvedprovider.IdentityLoginExtras{},
+				)
+				require.NoError(t, err)
+			}
+
+			params := url.Values{}
+			for k, v := range test.query {
+				params[k] = v
+			}
+			switch {
+			case test.sendUserCode:
+				params.Set("user_code", userCode)
+			case test.sendUserCodeWithFormatting:
+				params.Set("user_code", strings.ToLower(userCode[:4]+"-"+userCode[4:]))
+			case test.sendUnknownUserCode:
+				params.Set("user_code", "wrongcod")
+			}
+			if test.csrfFormValue != "" {
+				params.Set("csrf", test.csrfFormValue)
+			}
+
+			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+			subject := NewVerificationHandler(
+				downstreamIssuer,
+				test.idps.BuildFederationDomainIdentityProvidersListerFinder(),
+				oauthStore,
+				strategy.NewDynamicOauth2HMACStrategy(&fosite.Config{}, hmacSecretFunc),
+				happyCSRFGenerator,
+				happyPKCEGenerator,
+				happyNonceGenerator,
+				happyStateEncoder,
+				happyCookieEncoder,
+				auditLogger,
+			)
+
+			var req *http.Request
+			if test.method == http.MethodPost {
+				req = httptest.NewRequest(test.method, verificationURL, strings.NewReader(params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(test.method, verificationURL+"?"+params.Encode(), nil)
+			}
+			if test.csrfCookie != "" {
+				encodedCSRF, err := happyCookieEncoder.Encode(oidc.CSRFCookieEncodingName, csrftoken.CSRFToken(test.csrfCookie))
+				require.NoError(t, err)
+				req.AddCookie(&http.Cookie{Name: oidc.CSRFCookieName, Value: encodedCSRF})
+			}
+			rsp := httptest.NewRecorder()
+
+			subject.ServeHTTP(rsp, req)
+			t.Logf("response: %#v", rsp)
+			t.Logf("response body: %q", rsp.Body.String())
+
+			require.Equal(t, test.wantStatus, rsp.Code)
+			testutil.RequireEqualContentType(t, rsp.Header().Get("Content-Type"), test.wantContentType)
+			require.Equal(t, devicehtml.ContentSecurityPolicy(), rsp.Header().Get("Content-Security-Policy"))
+
+			if test.wantBodyString != "" {
+				require.Equal(t, test.wantBodyString, rsp.Body.String())
+			}
+			for _, want := range test.wantBodyContains {
+				require.Contains(t, rsp.Body.String(), want)
+			}
+			for _, notWant := range test.wantBodyNotContains {
+				require.NotContains(t, rsp.Body.String(), notWant)
+			}
+
+			// The user code must never be logged.
+			require.NotContains(t, actualAuditLog.String(), userCode)
+
+			var encodedStateParam string
+			if test.wantUpstreamStateParam != nil {
+				location := rsp.Header().Get("Location")
+				require.True(t, strings.HasPrefix(location, test.wantLocationPrefix),
+					"expected location %q to start with %q", location, test.wantLocationPrefix)
+				parsedLocation, err := url.Parse(location)
+				require.NoError(t, err)
+				encodedStateParam = parsedLocation.Query().Get("state")
+
+				var actualStateParam oidctestutil.ExpectedUpstreamStateParamFormat
+				require.NoError(t, happyStateEncoder.Decode(oidc.UpstreamStateParamEncodingName, encodedStateParam, &actualStateParam))
+				wantStateParam := test.wantUpstreamStateParam.WithDeviceUserCodeSignature(userCodeSignature(t, userCode))
+				require.Equal(t, oidctestutil.ExpectedUpstreamStateParamFormat(*wantStateParam), actualStateParam)
+			} else {
+				require.Empty(t, rsp.Header().Get("Location"))
+			}
+
+			if test.wantCSRFValueInCookieHeader != "" {
+				require.Len(t, rsp.Header().Values("Set-Cookie"), 1)
+				cookie := rsp.Result().Cookies()[0]
+				require.Equal(t, oidc.CSRFCookieName, cookie.Name)
+				require.True(t, cookie.HttpOnly)
+				require.True(t, cookie.Secure)
+				require.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+				var csrfFromCookie csrftoken.CSRFToken
+				require.NoError(t, happyCookieEncoder.Decode(oidc.CSRFCookieEncodingName, cookie.Value, &csrfFromCookie))
+				require.Equal(t, test.wantCSRFValueInCookieHeader, string(csrfFromCookie))
+			} else {
+				require.Empty(t, rsp.Header().Values("Set-Cookie"))
+			}
+
+			if test.wantAuditLogs != nil {
+				testutil.CompareAuditLogs(t, test.wantAuditLogs(encodedStateParam), actualAuditLog.String())
+			}
+		})
+	}
+}
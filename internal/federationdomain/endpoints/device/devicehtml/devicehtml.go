@@ -0,0 +1,86 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package devicehtml defines HTML templates used by the Supervisor's device verification endpoint.
+package devicehtml
+
+import (
+	_ "embed" // Needed to trigger //go:embed directives below.
+	"html/template"
+	"strings"
+
+	"github.com/tdewolff/minify/v2/minify"
+
+	"go.pinniped.dev/internal/federationdomain/csp"
+)
+
+//nolint:gochecknoglobals // This package uses globals to ensure that all parsing and minifying happens at init.
+var (
+	//go:embed device.css
+	rawCSS      string
+	minifiedCSS = panicOnError(minify.CSS(rawCSS))
+
+	//go:embed device.gohtml
+	rawHTMLTemplate string
+
+	// Parse the Go templated HTML and inject functions providing the minified inline CSS.
+	parsedHTMLTemplate = template.Must(template.New("device.gohtml").Funcs(template.FuncMap{
+		"minifiedCSS": func() template.CSS { return template.CSS(CSS()) }, //nolint:gosec // This is 100% static input, not attacker-controlled.
+	}).Parse(rawHTMLTemplate))
+
+	// Generate the CSP header value once since it's effectively constant.
+	cspValue = strings.Join([]string{
+		`default-src 'none'`,
+		`style-src '` + csp.Hash(minifiedCSS) + `'`,
+		`img-src data:`,
+		`frame-ancestors 'none'`,
+	}, "; ")
+)
+
+func panicOnError(s string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ContentSecurityPolicy returns the Content-Security-Policy header value to make the Template() operate correctly.
+//
+// See https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Security-Policy.
+func ContentSecurityPolicy() string { return cspValue }
+
+// Template returns the html/template.Template for rendering the device verification page.
+func Template() *template.Template { return parsedHTMLTemplate }
+
+// CSS returns the minified CSS that will be embedded into the page template.
+func CSS() string { return minifiedCSS }
+
+type IdentityProvider struct {
+	DisplayName string
+	URL         string
+}
+
+// Confirmation describes the pending device login which the user is asked to confirm.
+type Confirmation struct {
+	ClientID                    string
+	IdentityProviderDisplayName string
+}
+
+// PageData represents the inputs to the template.
+//
+// When Approved is true, the page tells the user that their device has been authorized.
+// Otherwise, when IdentityProviders is not empty, the page asks the user to choose an identity provider.
+// Otherwise, when Confirmation is not nil, the page names the client which is requesting the login, and asks the user
+// to confirm the login with a form which is posted back with the CSRFToken.
+// Otherwise, the page shows a form which asks the user to enter the user code displayed on their device.
+type PageData struct {
+	Approved          bool
+	IdentityProviders []IdentityProvider
+	Confirmation      *Confirmation
+	FormPath          string
+	UserCode          string
+	IDPName           string
+	CSRFToken         string
+	HasAlertError     bool
+	AlertMessage      string
+}
@@ -0,0 +1,142 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package devicehtml
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testExpectedCSS = `html{height:100%}body{font-family:metropolis-light,Helvetica,sans-serif;display:flex;flex-flow:column wrap;justify-content:flex-start;align-items:center;background:linear-gradient(to top,#f8f8f8,white);min-height:100%}h1{font-size:20px;margin:0}.box{display:flex;flex-direction:column;flex-wrap:nowrap;border-radius:4px;border-color:#ddd;border-width:1px;border-style:solid;width:400px;padding:30px 30px 0;margin:60px 20px 0;background:#fff;font-size:14px}input{color:inherit;font:inherit;border:0;margin:0;outline:0;padding:0}.form-field{display:flex;margin-bottom:30px}.form-field input[type=text],.form-field input[type=submit]{width:100%;padding:1em}.form-field input[type=text]{text-transform:uppercase;letter-spacing:.2em;border-radius:3px;border-width:1px;border-style:solid;border-color:#a6a6a6}.form-field input[type=submit]{background-color:#218fcf;color:#eee;font-weight:700;cursor:pointer;transition:all .3s}.form-field input[type=submit]:focus,.form-field input[type=submit]:hover{background-color:#1abfd3}.form-field input[type=submit]:active{transform:scale(.99)}.hidden{border:0;clip:rect(0 0 0 0);height:1px;margin:-1px;overflow:hidden;padding:0;position:absolute;width:1px}.alert{color:crimson}.form-field ul{padding-left:1.5em}.form-field li{margin-bottom:.5em}`
+
+	// It's okay if this changes in the future, but this gives us a chance to eyeball the formatting.
+	// Our browser-based integration tests should find any incompatibilities.
+	testExpectedCSP = `default-src 'none'; ` +
+		`style-src 'sha256-elkXx8XMSVbyqHeWK7cXH1zZwovjUMhycYbKBoBB5ik='; ` +
+		`img-src data:; ` +
+		`frame-ancestors 'none'`
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		name           string
+		pageData       *PageData
+		wantContains   []string
+		wantNotContain []string
+	}{
+		{
+			name:     "user code form",
+			pageData: &PageData{FormPath: "/test-path"},
+			wantContains: []string{
+				`<style>` + testExpectedCSS + `</style>`,
+				`<h1>Enter the code displayed on your device</h1>`,
+				`<form action="/test-path" method="get">`,
+				`<input type="text" name="user_code" id="user_code" value=""`,
+			},
+			wantNotContain: []string{
+				`role="alert"`,
+				`name="pinniped_idp_name"`,
+			},
+		},
+		{
+			name: "user code form with alert, user code, and identity provider name",
+			pageData: &PageData{
+				FormPath:      "/test-path",
+				UserCode:      "ABCD-EFGH",
+				IDPName:       "test-idp<name>",
+				HasAlertError: true,
+				AlertMessage:  "test-alert-message",
+			},
+			wantContains: []string{
+				`<span class="alert" role="alert" aria-label="device login error message" id="alert">test-alert-message</span>`,
+				`<input type="hidden" name="pinniped_idp_name" id="pinniped_idp_name" value="test-idp&lt;name&gt;">`,
+				`<input type="text" name="user_code" id="user_code" value="ABCD-EFGH"`,
+			},
+		},
+		{
+			name: "identity provider chooser",
+			pageData: &PageData{
+				FormPath: "/test-path",
+				IdentityProviders: []IdentityProvider{
+					{DisplayName: "idp-1", URL: "https://example.com/path?a=b&c=d"},
+					{DisplayName: "idp-2", URL: "https://example.com/other"},
+				},
+			},
+			wantContains: []string{
+				`<h1>Choose an identity provider to log in</h1>`,
+				`<li><a href="https://example.com/path?a=b&amp;c=d">idp-1</a></li>`,
+				`<li><a href="https://example.com/other">idp-2</a></li>`,
+			},
+			wantNotContain: []string{
+				`<form`,
+			},
+		},
+		{
+			name: "confirmation",
+			pageData: &PageData{
+				FormPath: "/test-path",
+				UserCode: "ABCDEFGH",
+				IDPName:  "test-idp<name>",
+				Confirmation: &Confirmation{
+					ClientID:                    "test-client<id>",
+					IdentityProviderDisplayName: "test-idp<name>",
+				},
+				CSRFToken: "test-csrf",
+			},
+			wantContains: []string{
+				`<h1>Confirm the device login</h1>`,
+				`The client <strong>test-client&lt;id&gt;</strong> is asking to log in using your account from <strong>test-idp&lt;name&gt;</strong>.`,
+				`your device is displaying the code <strong>ABCDEFGH</strong>.`,
+				`<form action="/test-path" method="post">`,
+				`<input type="hidden" name="user_code" id="user_code" value="ABCDEFGH">`,
+				`<input type="hidden" name="pinniped_idp_name" id="pinniped_idp_name" value="test-idp&lt;name&gt;">`,
+				`<input type="hidden" name="csrf" id="csrf" value="test-csrf">`,
+			},
+			wantNotContain: []string{
+				`<input type="text"`,
+			},
+		},
+		{
+			name:     "approved",
+			pageData: &PageData{Approved: true, FormPath: "/test-path"},
+			wantContains: []string{
+				`<h1>Device login succeeded</h1>`,
+				`You may now close this window and return to your device.`,
+			},
+			wantNotContain: []string{
+				`<form`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Template().Execute(&buf, tt.pageData))
+			// t.Logf("actual value:\n%s", buf.String()) // useful when updating minify library causes new output
+			for _, want := range tt.wantContains {
+				require.Contains(t, buf.String(), want)
+			}
+			for _, notWant := range tt.wantNotContain {
+				require.NotContains(t, buf.String(), notWant)
+			}
+		})
+	}
+}
+
+func TestContentSecurityPolicy(t *testing.T) {
+	require.Equal(t, testExpectedCSP, ContentSecurityPolicy())
+}
+
+func TestCSS(t *testing.T) {
+	require.Equal(t, testExpectedCSS, CSS())
+}
+
+func TestHelpers(t *testing.T) {
+	require.Equal(t, "test", panicOnError("test", nil))
+	require.PanicsWithError(t, "some error", func() { panicOnError("", fmt.Errorf("some error")) })
+}
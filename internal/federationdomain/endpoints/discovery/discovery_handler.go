@@ -1,4 +1,4 @@
-// Copyright 2020-2023 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package discovery provides a handler for the OIDC discovery endpoint.
@@ -35,13 +35,39 @@ type Metadata struct {
 
 	// vvv Optional vvv
 
-	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
-	ScopesSupported                   []string `json:"scopes_supported"`
-	ClaimsSupported                   []string `json:"claims_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
 
 	// https://datatracker.ietf.org/doc/html/rfc8414#section-2 says, “If omitted, the authorization server does not support PKCE.”
 	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
 
+	// https://datatracker.ietf.org/doc/html/rfc8628#section-4 defines this for the device authorization grant.
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+
+	// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#OPMetadata defines this for RP-initiated logout.
+	EndSessionEndpoint string `json:"end_session_endpoint"`
+
+	// https://datatracker.ietf.org/doc/html/rfc8414#section-2 defines these for the token revocation endpoint from RFC 7009.
+	RevocationEndpoint                     string   `json:"revocation_endpoint"`
+	RevocationEndpointAuthMethodsSupported []string `json:"revocation_endpoint_auth_methods_supported"`
+
+	// https://datatracker.ietf.org/doc/html/rfc8414#section-2 defines these for the token introspection endpoint from RFC 7662.
+	IntrospectionEndpoint                     string   `json:"introspection_endpoint"`
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported"`
+
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata defines this for the UserInfo endpoint.
+	UserInfoEndpoint string `json:"userinfo_endpoint"`
+
+	// https://datatracker.ietf.org/doc/html/rfc9126#section-5 defines these for the pushed authorization request endpoint.
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint"`
+	RequirePushedAuthorizationRequests bool   `json:"require_pushed_authorization_requests"`
+
+	// https://datatracker.ietf.org/doc/html/rfc8705#section-5 defines this for the endpoints which accept TLS
+	// client certificates.
+	MTLSEndpointAliases *MTLSEndpointAliases `json:"mtls_endpoint_aliases,omitempty"`
+
 	// ^^^ Optional ^^^
 
 	// vvv Custom vvv
@@ -51,26 +77,63 @@ type Metadata struct {
 	// ^^^ Custom ^^^
 }
 
+// MTLSEndpointAliases holds the alternative endpoints which clients should use when they authenticate
+// by their TLS client certificates.
+type MTLSEndpointAliases struct {
+	TokenEndpoint                      string `json:"token_endpoint"`
+	RevocationEndpoint                 string `json:"revocation_endpoint"`
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint"`
+}
+
 // NewHandler returns an http.Handler that serves an OIDC discovery endpoint.
-func NewHandler(issuerURL string) http.Handler {
+// mtlsIssuerURL is the base URL of the endpoints served by the mTLS listener, or empty when it is disabled.
+func NewHandler(issuerURL string, mtlsIssuerURL string) http.Handler {
+	// The client authentication methods which can be configured on OIDCClients. The same methods are supported
+	// by the token and revocation endpoints. The introspection endpoint only supports client_secret_basic.
+	// The tls_client_auth method is only supported by the mTLS endpoint aliases.
+	clientAuthMethods := []string{"client_secret_basic", "private_key_jwt"}
+
+	var mtlsEndpointAliases *MTLSEndpointAliases
+	if mtlsIssuerURL != "" {
+		clientAuthMethods = append(clientAuthMethods, "tls_client_auth")
+		mtlsEndpointAliases = &MTLSEndpointAliases{
+			TokenEndpoint:                      mtlsIssuerURL + oidc.TokenEndpointPath,
+			RevocationEndpoint:                 mtlsIssuerURL + oidc.RevocationEndpointPath,
+			PushedAuthorizationRequestEndpoint: mtlsIssuerURL + oidc.PushedAuthorizeEndpointPath,
+		}
+	}
+
 	oidcConfig := Metadata{
-		Issuer:                issuerURL,
-		AuthorizationEndpoint: issuerURL + oidc.AuthorizationEndpointPath,
-		TokenEndpoint:         issuerURL + oidc.TokenEndpointPath,
-		JWKSURI:               issuerURL + oidc.JWKSEndpointPath,
+		Issuer:                      issuerURL,
+		AuthorizationEndpoint:       issuerURL + oidc.AuthorizationEndpointPath,
+		TokenEndpoint:               issuerURL + oidc.TokenEndpointPath,
+		JWKSURI:                     issuerURL + oidc.JWKSEndpointPath,
+		DeviceAuthorizationEndpoint: issuerURL + oidc.DeviceAuthorizationEndpointPath,
+		EndSessionEndpoint:          issuerURL + oidc.EndSessionEndpointPath,
+		RevocationEndpoint:          issuerURL + oidc.RevocationEndpointPath,
+		IntrospectionEndpoint:       issuerURL + oidc.IntrospectionEndpointPath,
+		UserInfoEndpoint:            issuerURL + oidc.UserInfoEndpointPath,
+		// Pushed authorization requests are only required for the OIDCClients which are configured to require them.
+		PushedAuthorizationRequestEndpoint: issuerURL + oidc.PushedAuthorizeEndpointPath,
+		RequirePushedAuthorizationRequests: false,
+		MTLSEndpointAliases:                mtlsEndpointAliases,
 		OIDCDiscoveryResponse: v1alpha1.OIDCDiscoveryResponse{
 			SupervisorDiscovery: v1alpha1.OIDCDiscoveryResponseIDPEndpoint{
 				PinnipedIDPsEndpoint: issuerURL + oidc.PinnipedIDPsPathV1Alpha1,
 			},
 		},
-		ResponseTypesSupported:            []string{"code"},
-		ResponseModesSupported:            []string{"query", "form_post"},
-		SubjectTypesSupported:             []string{"public"},
-		IDTokenSigningAlgValuesSupported:  []string{"ES256"},
-		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic"},
-		CodeChallengeMethodsSupported:     []string{"S256"},
-		ScopesSupported:                   []string{oidcapi.ScopeOpenID, oidcapi.ScopeOfflineAccess, oidcapi.ScopeRequestAudience, oidcapi.ScopeUsername, oidcapi.ScopeGroups},
-		ClaimsSupported:                   []string{oidcapi.IDTokenClaimUsername, oidcapi.IDTokenClaimGroups, oidcapi.IDTokenClaimAdditionalClaims},
+		ResponseTypesSupported:                    []string{"code"},
+		ResponseModesSupported:                    []string{"query", "form_post"},
+		SubjectTypesSupported:                     []string{"public"},
+		IDTokenSigningAlgValuesSupported:          []string{"ES256"},
+		TokenEndpointAuthMethodsSupported:         clientAuthMethods,
+		RevocationEndpointAuthMethodsSupported:    clientAuthMethods,
+		IntrospectionEndpointAuthMethodsSupported: []string{"client_secret_basic"},
+		// The algorithms which can be configured for the private_key_jwt method on OIDCClients.
+		TokenEndpointAuthSigningAlgValuesSupported: []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"},
+		CodeChallengeMethodsSupported:              []string{"S256"},
+		ScopesSupported:                            []string{oidcapi.ScopeOpenID, oidcapi.ScopeOfflineAccess, oidcapi.ScopeRequestAudience, oidcapi.ScopeUsername, oidcapi.ScopeGroups},
+		ClaimsSupported:                            []string{oidcapi.IDTokenClaimUsername, oidcapi.IDTokenClaimGroups, oidcapi.IDTokenClaimAdditionalClaims},
 	}
 
 	var b bytes.Buffer
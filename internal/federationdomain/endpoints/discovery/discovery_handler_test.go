@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package discovery
@@ -18,9 +18,10 @@ func TestDiscovery(t *testing.T) {
 	tests := []struct {
 		name string
 
-		issuer string
-		method string
-		path   string
+		issuer     string
+		mtlsIssuer string
+		method     string
+		path       string
 
 		wantStatus      int
 		wantContentType string
@@ -44,9 +45,62 @@ func TestDiscovery(t *testing.T) {
 				"response_modes_supported": ["query", "form_post"],
 				"subject_types_supported": ["public"],
 				"id_token_signing_alg_values_supported": ["ES256"],
-				"token_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"token_endpoint_auth_methods_supported": ["client_secret_basic", "private_key_jwt"],
+				"token_endpoint_auth_signing_alg_values_supported": ["RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"],
 				"scopes_supported": ["openid", "offline_access", "pinniped:request-audience", "username", "groups"],
 				"code_challenge_methods_supported": ["S256"],
+				"device_authorization_endpoint": "https://some-issuer.com/some/path/oauth2/device_authorization",
+				"end_session_endpoint": "https://some-issuer.com/some/path/oauth2/logout",
+				"revocation_endpoint": "https://some-issuer.com/some/path/oauth2/revoke",
+				"revocation_endpoint_auth_methods_supported": ["client_secret_basic", "private_key_jwt"],
+				"introspection_endpoint": "https://some-issuer.com/some/path/oauth2/introspect",
+				"introspection_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"userinfo_endpoint": "https://some-issuer.com/some/path/oauth2/userinfo",
+				"pushed_authorization_request_endpoint": "https://some-issuer.com/some/path/oauth2/par",
+				"require_pushed_authorization_requests": false,
+				"claims_supported": ["username", "groups", "additionalClaims"],
+				"discovery.supervisor.pinniped.dev/v1alpha1": {
+					"pinniped_identity_providers_endpoint": "https://some-issuer.com/some/path/v1alpha1/pinniped_identity_providers"
+				}
+			}
+			`),
+		},
+		{
+			name:            "happy path with the mTLS listener enabled",
+			issuer:          "https://some-issuer.com/some/path",
+			mtlsIssuer:      "https://some-issuer.com:8444/some/path",
+			method:          http.MethodGet,
+			path:            "/some/path" + oidc.WellKnownEndpointPath,
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBodyJSON: here.Doc(`
+			{
+				"issuer": "https://some-issuer.com/some/path",
+				"authorization_endpoint": "https://some-issuer.com/some/path/oauth2/authorize",
+				"token_endpoint": "https://some-issuer.com/some/path/oauth2/token",
+				"jwks_uri": "https://some-issuer.com/some/path/jwks.json",
+				"response_types_supported": ["code"],
+				"response_modes_supported": ["query", "form_post"],
+				"subject_types_supported": ["public"],
+				"id_token_signing_alg_values_supported": ["ES256"],
+				"token_endpoint_auth_methods_supported": ["client_secret_basic", "private_key_jwt", "tls_client_auth"],
+				"token_endpoint_auth_signing_alg_values_supported": ["RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"],
+				"scopes_supported": ["openid", "offline_access", "pinniped:request-audience", "username", "groups"],
+				"code_challenge_methods_supported": ["S256"],
+				"device_authorization_endpoint": "https://some-issuer.com/some/path/oauth2/device_authorization",
+				"end_session_endpoint": "https://some-issuer.com/some/path/oauth2/logout",
+				"revocation_endpoint": "https://some-issuer.com/some/path/oauth2/revoke",
+				"revocation_endpoint_auth_methods_supported": ["client_secret_basic", "private_key_jwt", "tls_client_auth"],
+				"introspection_endpoint": "https://some-issuer.com/some/path/oauth2/introspect",
+				"introspection_endpoint_auth_methods_supported": ["client_secret_basic"],
+				"userinfo_endpoint": "https://some-issuer.com/some/path/oauth2/userinfo",
+				"pushed_authorization_request_endpoint": "https://some-issuer.com/some/path/oauth2/par",
+				"require_pushed_authorization_requests": false,
+				"mtls_endpoint_aliases": {
+					"token_endpoint": "https://some-issuer.com:8444/some/path/oauth2/token",
+					"revocation_endpoint": "https://some-issuer.com:8444/some/path/oauth2/revoke",
+					"pushed_authorization_request_endpoint": "https://some-issuer.com:8444/some/path/oauth2/par"
+				},
 				"claims_supported": ["username", "groups", "additionalClaims"],
 				"discovery.supervisor.pinniped.dev/v1alpha1": {
 					"pinniped_identity_providers_endpoint": "https://some-issuer.com/some/path/v1alpha1/pinniped_identity_providers"
@@ -66,7 +120,7 @@ func TestDiscovery(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			handler := NewHandler(test.issuer)
+			handler := NewHandler(test.issuer, test.mtlsIssuer)
 			req := httptest.NewRequest(test.method, test.path, nil)
 			rsp := httptest.NewRecorder()
 			handler.ServeHTTP(rsp, req)
@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package idpdiscovery provides a handler for the upstream IDP discovery endpoint.
@@ -12,17 +12,22 @@ import (
 
 	"go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
 	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
 )
 
 // NewHandler returns an http.Handler that serves the upstream IDP discovery endpoint.
-func NewHandler(upstreamIDPs federationdomainproviders.FederationDomainIdentityProvidersListerI) http.Handler {
+// When upstreamHealth is not nil, it is used to hint which upstream IDPs are currently unavailable.
+func NewHandler(
+	upstreamIDPs federationdomainproviders.FederationDomainIdentityProvidersListerI,
+	upstreamHealth *upstreamhealth.Tracker,
+) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, `Method not allowed (try GET)`, http.StatusMethodNotAllowed)
 			return
 		}
 
-		encodedMetadata, encodeErr := responseAsJSON(upstreamIDPs)
+		encodedMetadata, encodeErr := responseAsJSON(upstreamIDPs, upstreamHealth)
 		if encodeErr != nil {
 			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
 			return
@@ -36,13 +41,19 @@ func NewHandler(upstreamIDPs federationdomainproviders.FederationDomainIdentityP
 	})
 }
 
-func responseAsJSON(upstreamIDPs federationdomainproviders.FederationDomainIdentityProvidersListerI) ([]byte, error) {
+func responseAsJSON(
+	upstreamIDPs federationdomainproviders.FederationDomainIdentityProvidersListerI,
+	upstreamHealth *upstreamhealth.Tracker,
+) ([]byte, error) {
 	r := v1alpha1.IDPDiscoveryResponse{
 		PinnipedSupportedIDPTypes: []v1alpha1.PinnipedSupportedIDPType{
 			{Type: v1alpha1.IDPTypeActiveDirectory},
 			{Type: v1alpha1.IDPTypeGitHub},
 			{Type: v1alpha1.IDPTypeLDAP},
+			{Type: v1alpha1.IDPTypeLocalUser},
+			{Type: v1alpha1.IDPTypeOAuth2},
 			{Type: v1alpha1.IDPTypeOIDC},
+			{Type: v1alpha1.IDPTypeSAML},
 		},
 	}
 
@@ -54,6 +65,9 @@ func responseAsJSON(upstreamIDPs federationdomainproviders.FederationDomainIdent
 			Name:  federationDomainIdentityProvider.GetDisplayName(),
 			Type:  federationDomainIdentityProvider.GetIDPDiscoveryType(),
 			Flows: federationDomainIdentityProvider.GetIDPDiscoveryFlows(),
+			Unavailable: !upstreamHealth.IsAvailable(
+				federationDomainIdentityProvider.GetProvider().GetResourceUID(),
+			),
 		}
 	}
 
@@ -1,16 +1,21 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package idpdiscovery
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
 
 	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
 	"go.pinniped.dev/internal/here"
 	"go.pinniped.dev/internal/testutil/oidctestutil"
 	"go.pinniped.dev/internal/testutil/testidplister"
@@ -24,6 +29,8 @@ func TestIDPDiscovery(t *testing.T) {
 		path      string
 		idpLister *testidplister.TestFederationDomainIdentityProvidersListerFinder
 
+		unavailableUpstreamUIDs []types.UID
+
 		wantStatus                 int
 		wantContentType            string
 		wantFirstResponseBodyJSON  string
@@ -49,38 +56,44 @@ func TestIDPDiscovery(t *testing.T) {
 			wantContentType: "application/json",
 			wantFirstResponseBodyJSON: here.Doc(`{
 				"pinniped_identity_providers": [
-					{"name": "a-some-ldap-idp", "type": "ldap",            "flows": ["cli_password", "browser_authcode"]},
-					{"name": "a-some-oidc-idp", "type": "oidc",            "flows": ["browser_authcode"]},
-					{"name": "g-some-github-idp", "type": "github",        "flows": ["browser_authcode"]},
-					{"name": "x-some-ldap-idp", "type": "ldap",            "flows": ["cli_password", "browser_authcode"]},
-					{"name": "x-some-oidc-idp", "type": "oidc",            "flows": ["browser_authcode"]},
-					{"name": "y-some-ad-idp",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode"]},
-					{"name": "z-some-ad-idp",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode"]},
-					{"name": "z-some-ldap-idp", "type": "ldap",            "flows": ["cli_password", "browser_authcode"]},
-					{"name": "z-some-oidc-idp", "type": "oidc",            "flows": ["browser_authcode", "cli_password"]}
+					{"name": "a-some-ldap-idp", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "a-some-oidc-idp", "type": "oidc",            "flows": ["browser_authcode", "device_code"]},
+					{"name": "g-some-github-idp", "type": "github",        "flows": ["browser_authcode", "device_code"]},
+					{"name": "x-some-ldap-idp", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "x-some-oidc-idp", "type": "oidc",            "flows": ["browser_authcode", "device_code"]},
+					{"name": "y-some-ad-idp",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "z-some-ad-idp",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "z-some-ldap-idp", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "z-some-oidc-idp", "type": "oidc",            "flows": ["browser_authcode", "cli_password", "device_code"]}
 				],
 				"pinniped_supported_identity_provider_types": [
 					{"type": "activedirectory"},
 					{"type": "github"},
 					{"type": "ldap"},
-					{"type": "oidc"}
+					{"type": "localuser"},
+					{"type": "oauth2"},
+					{"type": "oidc"},
+					{"type": "saml"}
 				]
 			}`),
 			wantSecondResponseBodyJSON: here.Doc(`{
 				"pinniped_identity_providers": [
-					{"name": "g-some-github-idp",     "type": "github",          "flows": ["browser_authcode"]},
-					{"name": "some-other-ad-idp-1",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode"]},
-					{"name": "some-other-ad-idp-2",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode"]},
-					{"name": "some-other-ldap-idp-1", "type": "ldap",            "flows": ["cli_password", "browser_authcode"]},
-					{"name": "some-other-ldap-idp-2", "type": "ldap",            "flows": ["cli_password", "browser_authcode"]},
-					{"name": "some-other-oidc-idp-1", "type": "oidc",            "flows": ["browser_authcode", "cli_password"]},
-					{"name": "some-other-oidc-idp-2", "type": "oidc",            "flows": ["browser_authcode"]}
+					{"name": "g-some-github-idp",     "type": "github",          "flows": ["browser_authcode", "device_code"]},
+					{"name": "some-other-ad-idp-1",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ad-idp-2",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ldap-idp-1", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ldap-idp-2", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-oidc-idp-1", "type": "oidc",            "flows": ["browser_authcode", "cli_password", "device_code"]},
+					{"name": "some-other-oidc-idp-2", "type": "oidc",            "flows": ["browser_authcode", "device_code"]}
 				],
 				"pinniped_supported_identity_provider_types": [
 					{"type": "activedirectory"},
 					{"type": "github"},
 					{"type": "ldap"},
-					{"type": "oidc"}
+					{"type": "localuser"},
+					{"type": "oauth2"},
+					{"type": "oidc"},
+					{"type": "saml"}
 				]
 			}`),
 		},
@@ -98,23 +111,75 @@ func TestIDPDiscovery(t *testing.T) {
 					{"type": "activedirectory"},
 					{"type": "github"},
 					{"type": "ldap"},
-					{"type": "oidc"}
+					{"type": "localuser"},
+					{"type": "oauth2"},
+					{"type": "oidc"},
+					{"type": "saml"}
 				]
 			}`),
 			wantSecondResponseBodyJSON: here.Doc(`{
 				"pinniped_identity_providers": [
-					{"name": "some-other-ad-idp-1",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode"]},
-					{"name": "some-other-ad-idp-2",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode"]},
-					{"name": "some-other-ldap-idp-1", "type": "ldap",            "flows": ["cli_password", "browser_authcode"]},
-					{"name": "some-other-ldap-idp-2", "type": "ldap",            "flows": ["cli_password", "browser_authcode"]},
-					{"name": "some-other-oidc-idp-1", "type": "oidc",            "flows": ["browser_authcode", "cli_password"]},
-					{"name": "some-other-oidc-idp-2", "type": "oidc",            "flows": ["browser_authcode"]}
+					{"name": "some-other-ad-idp-1",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ad-idp-2",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ldap-idp-1", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ldap-idp-2", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-oidc-idp-1", "type": "oidc",            "flows": ["browser_authcode", "cli_password", "device_code"]},
+					{"name": "some-other-oidc-idp-2", "type": "oidc",            "flows": ["browser_authcode", "device_code"]}
 				],
 				"pinniped_supported_identity_provider_types": [
 					{"type": "activedirectory"},
 					{"type": "github"},
 					{"type": "ldap"},
-					{"type": "oidc"}
+					{"type": "localuser"},
+					{"type": "oauth2"},
+					{"type": "oidc"},
+					{"type": "saml"}
+				]
+			}`),
+		},
+		{
+			name:   "upstream IDPs which are currently unavailable are marked as unavailable",
+			method: http.MethodGet,
+			path:   "/some/path" + oidc.WellKnownEndpointPath,
+			idpLister: testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().WithName("some-oidc-idp").WithResourceUID("some-oidc-uid").Build()).
+				WithLDAP(oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().WithName("some-ldap-idp").WithResourceUID("some-ldap-uid").Build()).
+				BuildFederationDomainIdentityProvidersListerFinder(),
+			unavailableUpstreamUIDs: []types.UID{"some-ldap-uid"},
+			wantStatus:              http.StatusOK,
+			wantContentType:         "application/json",
+			wantFirstResponseBodyJSON: here.Doc(`{
+				"pinniped_identity_providers": [
+					{"name": "some-ldap-idp", "type": "ldap", "flows": ["cli_password", "browser_authcode", "device_code"], "unavailable": true},
+					{"name": "some-oidc-idp", "type": "oidc", "flows": ["browser_authcode", "device_code"]}
+				],
+				"pinniped_supported_identity_provider_types": [
+					{"type": "activedirectory"},
+					{"type": "github"},
+					{"type": "ldap"},
+					{"type": "localuser"},
+					{"type": "oauth2"},
+					{"type": "oidc"},
+					{"type": "saml"}
+				]
+			}`),
+			wantSecondResponseBodyJSON: here.Doc(`{
+				"pinniped_identity_providers": [
+					{"name": "some-other-ad-idp-1",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ad-idp-2",   "type": "activedirectory", "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ldap-idp-1", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-ldap-idp-2", "type": "ldap",            "flows": ["cli_password", "browser_authcode", "device_code"]},
+					{"name": "some-other-oidc-idp-1", "type": "oidc",            "flows": ["browser_authcode", "cli_password", "device_code"]},
+					{"name": "some-other-oidc-idp-2", "type": "oidc",            "flows": ["browser_authcode", "device_code"]}
+				],
+				"pinniped_supported_identity_provider_types": [
+					{"type": "activedirectory"},
+					{"type": "github"},
+					{"type": "ldap"},
+					{"type": "localuser"},
+					{"type": "oauth2"},
+					{"type": "oidc"},
+					{"type": "saml"}
 				]
 			}`),
 		},
@@ -133,7 +198,14 @@ func TestIDPDiscovery(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			require.NotNil(t, test.idpLister)
-			handler := NewHandler(test.idpLister)
+			upstreamHealth := upstreamhealth.NewTracker(clocktesting.NewFakeClock(time.Now()))
+			for _, uid := range test.unavailableUpstreamUIDs {
+				for range upstreamHealth.FailureThreshold {
+					upstreamHealth.RecordProbe(uid, errors.New("some probe error"))
+				}
+			}
+
+			handler := NewHandler(test.idpLister, upstreamHealth)
 			req := httptest.NewRequest(test.method, test.path, nil)
 			rsp := httptest.NewRecorder()
 			handler.ServeHTTP(rsp, req)
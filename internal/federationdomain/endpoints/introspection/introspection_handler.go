@@ -0,0 +1,148 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package introspection provides a handler for the OAuth 2.0 token introspection endpoint (RFC 7662).
+package introspection
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ory/fosite"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+func paramsSafeToLog() sets.Set[string] {
+	return sets.New(
+		// Standard params from https://datatracker.ietf.org/doc/html/rfc7662#section-2.1.
+		// Redacting token, and also access_token in case the client tries to send one.
+		"token_type_hint",
+	)
+}
+
+// response is the introspection response from https://datatracker.ietf.org/doc/html/rfc7662#section-2.2,
+// along with the username and groups of the user, when they were granted to the token.
+type response struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Expiry    int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Groups    any      `json:"groups,omitempty"`
+}
+
+// NewHandler returns a http.Handler that serves the token introspection endpoint from
+// https://datatracker.ietf.org/doc/html/rfc7662, which allows resource servers to validate the access tokens and
+// refresh tokens issued by the Supervisor. Only dynamic clients which are allowed to use the pinniped:introspect
+// scope may call this endpoint, and they must authenticate using their client secret.
+func NewHandler(
+	issuerURL string,
+	oauthHelper fosite.OAuth2Provider,
+	clientManager fosite.ClientManager,
+	auditLogger plog.AuditLogger,
+) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if err := auditLogger.AuditRequestParams(r, paramsSafeToLog()); err != nil {
+			oauthHelper.WriteIntrospectionError(r.Context(), w, err)
+			return nil
+		}
+
+		clientID, _, basicAuthUsed := r.BasicAuth()
+		if !basicAuthUsed {
+			oauthHelper.WriteIntrospectionError(r.Context(), w,
+				fosite.ErrRequestUnauthorized.WithHint("HTTP Authorization header missing."))
+			return nil
+		}
+		auditLogger.Audit(auditevent.HTTPRequestBasicAuthUsed, &plog.AuditParams{
+			ReqCtx:        r.Context(),
+			KeysAndValues: []any{"clientID", clientID},
+		})
+
+		// Fosite would allow any valid access token to be used as the credential for this endpoint instead of a
+		// client secret, which would allow any end user to introspect tokens, so do not allow that.
+		if r.Form.Has("access_token") {
+			oauthHelper.WriteIntrospectionError(r.Context(), w,
+				fosite.ErrInvalidRequest.WithHint("The access_token parameter is not supported."))
+			return nil
+		}
+
+		// Check that the client is allowed to use this endpoint before asking fosite to authenticate the client.
+		// Use the same error as fosite would use for a wrong client secret, so the response does not reveal anything
+		// about the client.
+		client, err := clientManager.GetClient(r.Context(), clientID)
+		if err != nil || !fosite.Arguments(client.GetScopes()).Has(oidcapi.ScopeIntrospect) {
+			plog.Info("introspection request from a client which is not allowed to introspect tokens", "clientID", clientID)
+			oauthHelper.WriteIntrospectionError(r.Context(), w,
+				fosite.ErrRequestUnauthorized.WithHint("OAuth 2.0 Client credentials are invalid."))
+			return nil
+		}
+
+		// Validates that the request is a POST, authenticates the client, and looks up the token.
+		// Unknown, expired, and revoked tokens result in an error which is rendered as an inactive token response.
+		introspectionResponder, err := oauthHelper.NewIntrospectionRequest(r.Context(), r, psession.NewPinnipedSession())
+		if err != nil {
+			plog.Info("introspection request error", oidc.FositeErrorForLog(err)...)
+			oauthHelper.WriteIntrospectionError(r.Context(), w, err)
+			return nil
+		}
+
+		// Log sessionID for cross-request correlation purposes.
+		auditLogger.Audit(auditevent.SessionFound, &plog.AuditParams{
+			ReqCtx:  r.Context(),
+			Session: introspectionResponder.GetAccessRequester(),
+		})
+
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		return json.NewEncoder(w).Encode(activeResponse(issuerURL, introspectionResponder))
+	})
+}
+
+func activeResponse(issuerURL string, introspectionResponder fosite.IntrospectionResponder) *response {
+	requester := introspectionResponder.GetAccessRequester()
+	session := requester.GetSession().(*psession.PinnipedSession)
+
+	rsp := &response{
+		Active:   true,
+		Scope:    strings.Join(requester.GetGrantedScopes(), " "),
+		ClientID: requester.GetClient().GetID(),
+		Subject:  session.GetSubject(),
+		Audience: requester.GetGrantedAudience(),
+		Issuer:   issuerURL,
+	}
+
+	tokenType := fosite.AccessToken
+	if introspectionResponder.GetTokenUse() == fosite.RefreshToken {
+		tokenType = fosite.RefreshToken
+	} else {
+		rsp.TokenType = introspectionResponder.GetAccessTokenType()
+	}
+	if expiry := session.GetExpiresAt(tokenType); !expiry.IsZero() {
+		rsp.Expiry = expiry.Unix()
+	}
+	if !requester.GetRequestedAt().IsZero() {
+		rsp.IssuedAt = requester.GetRequestedAt().Unix()
+	}
+
+	// The username and groups are only available when they were granted to the token's session,
+	// in which case they were also put into the ID token's claims.
+	claims := session.IDTokenClaims()
+	rsp.Username, _ = claims.Extra[oidcapi.IDTokenClaimUsername].(string)
+	rsp.Groups = claims.Extra[oidcapi.IDTokenClaimGroups]
+
+	return rsp
+}
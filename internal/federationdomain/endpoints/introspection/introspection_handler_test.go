@@ -0,0 +1,484 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package introspection
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	fositejwt "github.com/ory/fosite/token/jwt"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/auditid"
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/oidcclientvalidator"
+	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/federationdomain/strategy"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil"
+)
+
+const (
+	downstreamIssuer = "https://my-downstream-issuer.com/some-path"
+	hmacSecret       = "this needs to be at least 32 characters to meet entropy requirements"
+
+	introspectingClientID  = "client.oauth.pinniped.dev-resource-server"
+	introspectingClientUID = "fake-resource-server-uid"
+	otherClientID          = "client.oauth.pinniped.dev-webapp"
+	otherClientUID         = "fake-webapp-uid"
+
+	sessionID = "some-session-id"
+)
+
+// sessionTokens holds the tokens which were issued for a downstream session.
+type sessionTokens struct {
+	accessToken        string
+	expiredAccessToken string
+	refreshToken       string
+}
+
+func TestIntrospectionEndpoint(t *testing.T) {
+	requestedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	accessTokenExpiresAt := requestedAt.Add(2 * time.Minute)
+	refreshTokenExpiresAt := requestedAt.Add(time.Hour)
+
+	unauthorizedClientBody := `{
+		"error":             "request_unauthorized",
+		"error_description": "The request could not be authorized. OAuth 2.0 Client credentials are invalid."
+	}`
+
+	tests := []struct {
+		name          string
+		params        func(tokens sessionTokens) url.Values
+		method        string
+		basicAuthUser string
+		basicAuthPass string
+		tlsClientCert bool
+
+		wantStatus    int
+		wantBodyJSON  string
+		wantAuditLogs []testutil.WantedAuditLog
+	}{
+		{
+			name: "access token is active",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.accessToken}, "token_type_hint": []string{"access_token"}}
+			},
+			basicAuthUser: introspectingClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			wantStatus:    http.StatusOK,
+			wantBodyJSON: fmt.Sprintf(`{
+				"active":     true,
+				"scope":      "openid offline_access username groups",
+				"client_id":  "pinniped-cli",
+				"token_type": "bearer",
+				"exp":        %d,
+				"iat":        %d,
+				"sub":        "some-subject",
+				"iss":        "https://my-downstream-issuer.com/some-path",
+				"username":   "some-username",
+				"groups":     ["group1", "group2"]
+			}`, accessTokenExpiresAt.Unix(), requestedAt.Unix()),
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted", "token_type_hint": "access_token"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": introspectingClientID}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name: "refresh token is active",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.refreshToken}}
+			},
+			basicAuthUser: introspectingClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			wantStatus:    http.StatusOK,
+			wantBodyJSON: fmt.Sprintf(`{
+				"active":    true,
+				"scope":     "openid offline_access username groups",
+				"client_id": "pinniped-cli",
+				"exp":       %d,
+				"iat":       %d,
+				"sub":       "some-subject",
+				"iss":       "https://my-downstream-issuer.com/some-path",
+				"username":  "some-username",
+				"groups":    ["group1", "group2"]
+			}`, refreshTokenExpiresAt.Unix(), requestedAt.Unix()),
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": introspectingClientID}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name: "expired access token is inactive",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.expiredAccessToken}}
+			},
+			basicAuthUser: introspectingClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			wantStatus:    http.StatusOK,
+			wantBodyJSON:  `{"active": false}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": introspectingClientID}),
+			},
+		},
+		{
+			name: "unknown token is inactive",
+			params: func(_ sessionTokens) url.Values {
+				return url.Values{"token": []string{"some-unknown-token"}}
+			},
+			basicAuthUser: introspectingClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			wantStatus:    http.StatusOK,
+			wantBodyJSON:  `{"active": false}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": introspectingClientID}),
+			},
+		},
+		{
+			name: "client is not allowed to introspect tokens",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.accessToken}}
+			},
+			basicAuthUser: otherClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			wantStatus:    http.StatusUnauthorized,
+			wantBodyJSON:  unauthorizedClientBody,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": otherClientID}),
+			},
+		},
+		{
+			name: "pinniped-cli is not allowed to introspect tokens",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.accessToken}}
+			},
+			basicAuthUser: "pinniped-cli",
+			wantStatus:    http.StatusUnauthorized,
+			wantBodyJSON:  unauthorizedClientBody,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": "pinniped-cli"}),
+			},
+		},
+		{
+			name: "unknown client",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.accessToken}}
+			},
+			basicAuthUser: "client.oauth.pinniped.dev-unknown",
+			basicAuthPass: testutil.PlaintextPassword1,
+			wantStatus:    http.StatusUnauthorized,
+			wantBodyJSON:  unauthorizedClientBody,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": "client.oauth.pinniped.dev-unknown"}),
+			},
+		},
+		{
+			name: "wrong client secret",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.accessToken}}
+			},
+			basicAuthUser: introspectingClientID,
+			basicAuthPass: "wrong-password",
+			wantStatus:    http.StatusUnauthorized,
+			wantBodyJSON:  unauthorizedClientBody,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": introspectingClientID}),
+			},
+		},
+		{
+			name: "no client authentication",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.accessToken}}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBodyJSON: `{
+				"error":             "request_unauthorized",
+				"error_description": "The request could not be authorized. HTTP Authorization header missing."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+			},
+		},
+		{
+			name: "private_key_jwt client authentication is not supported",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{
+					"token":                 []string{tokens.accessToken},
+					"client_id":             []string{introspectingClientID},
+					"client_assertion_type": []string{"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+					"client_assertion":      []string{"some.client.assertion"},
+				}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBodyJSON: `{
+				"error":             "request_unauthorized",
+				"error_description": "The request could not be authorized. HTTP Authorization header missing."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"token":                 "redacted",
+						"client_id":             "redacted",
+						"client_assertion_type": "redacted",
+						"client_assertion":      "redacted",
+					},
+				}),
+			},
+		},
+		{
+			name: "tls_client_auth client authentication is not supported",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.accessToken}, "client_id": []string{introspectingClientID}}
+			},
+			tlsClientCert: true,
+			wantStatus:    http.StatusUnauthorized,
+			wantBodyJSON: `{
+				"error":             "request_unauthorized",
+				"error_description": "The request could not be authorized. HTTP Authorization header missing."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted", "client_id": "redacted"},
+				}),
+			},
+		},
+		{
+			name: "access token used instead of client authentication",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.refreshToken}, "access_token": []string{tokens.accessToken}}
+			},
+			basicAuthUser: introspectingClientID,
+			basicAuthPass: "wrong-password",
+			wantStatus:    http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_request",
+				"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. The access_token parameter is not supported."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted", "access_token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": introspectingClientID}),
+			},
+		},
+		{
+			name: "wrong HTTP method",
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"token": []string{tokens.accessToken}}
+			},
+			method:        http.MethodGet,
+			basicAuthUser: introspectingClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			wantStatus:    http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_request",
+				"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. HTTP method is 'GET' but expected 'POST'."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": introspectingClientID}),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset()
+			supervisorClient := supervisorfake.NewSimpleClientset()
+			addOIDCClient(t, supervisorClient, kubeClient, introspectingClientID, introspectingClientUID, true)
+			addOIDCClient(t, supervisorClient, kubeClient, otherClientID, otherClientUID, false)
+
+			secrets := kubeClient.CoreV1().Secrets("some-namespace")
+			// Use lower minimum required bcrypt cost than we would use in production to keep unit the tests fast.
+			kubeStorage := storage.NewKubeStorage(secrets, supervisorClient.ConfigV1alpha1().OIDCClients("some-namespace"),
+				oidc.DefaultOIDCTimeoutsConfiguration(), 4)
+			oauthHelper := oidc.FositeOauth2Helper(kubeStorage, downstreamIssuer, hmacSecretFunc, nil, oidc.DefaultOIDCTimeoutsConfiguration())
+
+			tokens := createSessionStorage(t, kubeStorage, requestedAt, accessTokenExpiresAt, refreshTokenExpiresAt)
+
+			method := http.MethodPost
+			if test.method != "" {
+				method = test.method
+			}
+			var req *http.Request
+			params := test.params(tokens)
+			if method == http.MethodPost {
+				req = httptest.NewRequest(method, downstreamIssuer+oidc.IntrospectionEndpointPath, strings.NewReader(params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(method, downstreamIssuer+oidc.IntrospectionEndpointPath+"?"+params.Encode(), nil)
+			}
+			if test.basicAuthUser != "" {
+				req.SetBasicAuth(test.basicAuthUser, test.basicAuthPass)
+			}
+			if test.tlsClientCert {
+				ca, err := certauthority.New("some-ca", time.Hour)
+				require.NoError(t, err)
+				clientCert, err := ca.IssueClientCert(introspectingClientID, nil, time.Hour)
+				require.NoError(t, err)
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert.Leaf}}
+			}
+			req, _ = auditid.NewRequestWithAuditID(req, func() string { return "fake-introspection-audit-id" })
+			rsp := httptest.NewRecorder()
+
+			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+			NewHandler(downstreamIssuer, oauthHelper, kubeStorage, auditLogger).ServeHTTP(rsp, req)
+
+			require.Equal(t, test.wantStatus, rsp.Code, rsp.Body.String())
+			require.Equal(t, "application/json;charset=UTF-8", rsp.Header().Get("Content-Type"))
+			require.Equal(t, "no-store", rsp.Header().Get("Cache-Control"))
+			require.JSONEq(t, test.wantBodyJSON, rsp.Body.String())
+
+			testutil.WantAuditIDOnEveryAuditLog(test.wantAuditLogs, "fake-introspection-audit-id")
+			testutil.CompareAuditLogs(t, test.wantAuditLogs, actualAuditLog.String())
+		})
+	}
+}
+
+func TestParamsSafeToLog(t *testing.T) {
+	wantParams := []string{
+		"token_type_hint",
+	}
+
+	require.ElementsMatch(t, wantParams, paramsSafeToLog().UnsortedList())
+}
+
+func hmacSecretFunc() []byte {
+	return []byte(hmacSecret)
+}
+
+func addOIDCClient(
+	t *testing.T,
+	supervisorClient *supervisorfake.Clientset,
+	kubeClient *fake.Clientset,
+	clientID string,
+	clientUID string,
+	allowIntrospection bool,
+) {
+	t.Helper()
+
+	scopes := []supervisorconfigv1alpha1.Scope{"openid", "offline_access", "username", "groups"}
+	if allowIntrospection {
+		scopes = append(scopes, "pinniped:introspect")
+	}
+	oidcClient, secret := testutil.OIDCClientAndStorageSecret(t,
+		"some-namespace",
+		clientID,
+		clientUID,
+		[]supervisorconfigv1alpha1.GrantType{"authorization_code", "refresh_token"},
+		scopes,
+		"https://some-resource-server.example.com/callback",
+		nil, // no custom ID token lifetime
+		[]string{testutil.HashedPassword1AtGoMinCost},
+		oidcclientvalidator.Validate,
+	)
+	require.NoError(t, supervisorClient.Tracker().Add(oidcClient))
+	require.NoError(t, kubeClient.Tracker().Add(secret))
+}
+
+// createSessionStorage creates access token and refresh token storage for a pinniped-cli downstream session,
+// along with the storage for an expired access token, and returns the tokens which were issued for that session.
+func createSessionStorage(
+	t *testing.T,
+	kubeStorage *storage.KubeStorage,
+	requestedAt time.Time,
+	accessTokenExpiresAt time.Time,
+	refreshTokenExpiresAt time.Time,
+) sessionTokens {
+	t.Helper()
+
+	newRequest := func(accessTokenExpiresAt time.Time) *fosite.Request {
+		return &fosite.Request{
+			ID:             sessionID,
+			Client:         clientregistry.PinnipedCLI(),
+			GrantedScope:   fosite.Arguments{"openid", "offline_access", "username", "groups"},
+			RequestedAt:    requestedAt,
+			RequestedScope: fosite.Arguments{"openid", "offline_access", "username", "groups"},
+			Session: &psession.PinnipedSession{
+				Fosite: &openid.DefaultSession{
+					Claims: &fositejwt.IDTokenClaims{
+						Subject: "some-subject",
+						Extra: map[string]any{
+							"username": "some-username",
+							"groups":   []string{"group1", "group2"},
+						},
+					},
+					Subject: "some-subject",
+					ExpiresAt: map[fosite.TokenType]time.Time{
+						fosite.AccessToken:  accessTokenExpiresAt,
+						fosite.RefreshToken: refreshTokenExpiresAt,
+					},
+				},
+				Custom: &psession.CustomSessionData{
+					Username:     "some-username",
+					ProviderUID:  "some-upstream-resource-uid",
+					ProviderName: "some-upstream-idp",
+					ProviderType: psession.ProviderTypeOIDC,
+					OIDC:         &psession.OIDCSessionData{UpstreamRefreshToken: "some-upstream-refresh-token"},
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	hmacStrategy := strategy.NewDynamicOauth2HMACStrategy(&fosite.Config{}, hmacSecretFunc)
+
+	request := newRequest(accessTokenExpiresAt)
+	accessToken, accessSignature, err := hmacStrategy.GenerateAccessToken(ctx, request)
+	require.NoError(t, err)
+	refreshToken, refreshSignature, err := hmacStrategy.GenerateRefreshToken(ctx, request)
+	require.NoError(t, err)
+	require.NoError(t, kubeStorage.CreateAccessTokenSession(ctx, accessSignature, request))
+	require.NoError(t, kubeStorage.CreateRefreshTokenSession(ctx, refreshSignature, accessSignature, request))
+
+	expiredRequest := newRequest(time.Now().Add(-time.Second))
+	expiredAccessToken, expiredAccessSignature, err := hmacStrategy.GenerateAccessToken(ctx, expiredRequest)
+	require.NoError(t, err)
+	require.NoError(t, kubeStorage.CreateAccessTokenSession(ctx, expiredAccessSignature, expiredRequest))
+
+	return sessionTokens{accessToken: accessToken, expiredAccessToken: expiredAccessToken, refreshToken: refreshToken}
+}
@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package login
@@ -15,6 +15,7 @@ import (
 const (
 	internalErrorMessage                    = "An internal error occurred. Please contact your administrator for help."
 	incorrectUsernameOrPasswordErrorMessage = "Incorrect username or password."
+	upstreamUnavailableErrorMessage         = "The identity provider is currently unavailable. Please try again later."
 )
 
 func NewGetHandler(loginPath string) HandlerFunc {
@@ -36,8 +37,11 @@ func getAlert(r *http.Request) (string, bool) {
 	errorParamValue := r.URL.Query().Get(loginurl.ErrParamName)
 
 	message := internalErrorMessage
-	if errorParamValue == string(loginurl.ShowBadUserPassErr) {
+	switch errorParamValue {
+	case string(loginurl.ShowBadUserPassErr):
 		message = incorrectUsernameOrPasswordErrorMessage
+	case string(loginurl.ShowUnavailableError):
+		message = upstreamUnavailableErrorMessage
 	}
 
 	return message, errorParamValue != ""
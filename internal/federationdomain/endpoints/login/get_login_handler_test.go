@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package login
@@ -74,6 +74,20 @@ func TestGetLogin(t *testing.T) {
 				"An internal error occurred. Please contact your administrator for help.",
 			),
 		},
+		{
+			name: "displays error banner when err=upstream_unavailable param is sent",
+			decodedState: &oidc.UpstreamStateParamData{
+				UpstreamName: testUpstreamName,
+				UpstreamType: testUpstreamType,
+			},
+			encodedState:    testEncodedState,
+			errParam:        "upstream_unavailable",
+			wantStatus:      http.StatusOK,
+			wantContentType: htmlContentType,
+			wantBody: testutil.ExpectedLoginPageHTML(loginhtml.CSS(), testUpstreamName, testPath, testEncodedState,
+				"The identity provider is currently unavailable. Please try again later.",
+			),
+		},
 		{
 			// If we get an error that we don't recognize, that's also an error, so we
 			// should probably just tell you to contact your administrator...
@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package login
@@ -79,7 +79,9 @@ func NewHandler(
 		})
 
 		switch decodedState.UpstreamType {
-		case string(idpdiscoveryv1alpha1.IDPTypeLDAP), string(idpdiscoveryv1alpha1.IDPTypeActiveDirectory):
+		case string(idpdiscoveryv1alpha1.IDPTypeLDAP),
+			string(idpdiscoveryv1alpha1.IDPTypeActiveDirectory),
+			string(idpdiscoveryv1alpha1.IDPTypeLocalUser):
 			// these are the types supported by this endpoint, so no error here
 		default:
 			return httperr.Newf(http.StatusBadRequest, "not a supported upstream IDP type for this endpoint: %q", decodedState.UpstreamType)
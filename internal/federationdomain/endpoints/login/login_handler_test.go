@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package login
@@ -78,6 +78,12 @@ func TestLoginEndpoint(t *testing.T) {
 		return s
 	}
 
+	expectedHappyDecodedUpstreamStateParamForLocalUser := func() *oidc.UpstreamStateParamData {
+		s := expectedHappyDecodedUpstreamStateParam()
+		s.UpstreamType = "localuser"
+		return s
+	}
+
 	happyUpstreamStateParam := func() *oidctestutil.UpstreamStateParamBuilder {
 		return &oidctestutil.UpstreamStateParamBuilder{
 			U: happyUpstreamIDPName,
@@ -107,6 +113,8 @@ func TestLoginEndpoint(t *testing.T) {
 
 	happyActiveDirectoryState := happyUpstreamStateParam().WithUpstreamIDPType("activedirectory").Build(t, happyStateCodec)
 
+	happyLocalUserState := happyUpstreamStateParam().WithUpstreamIDPType("localuser").Build(t, happyStateCodec)
+
 	encodedIncomingCookieCSRFValue, err := happyCookieCodec.Encode("csrf", happyDownstreamCSRF)
 	require.NoError(t, err)
 	happyCSRFCookie := "__Host-pinniped-csrf=" + encodedIncomingCookieCSRFValue
@@ -455,6 +463,27 @@ func TestLoginEndpoint(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:             "happy GET request for LocalUser upstream",
+			method:           http.MethodGet,
+			path:             newRequestPath().WithState(happyLocalUserState).String(),
+			csrfCookie:       happyCSRFCookie,
+			wantStatus:       http.StatusOK,
+			wantContentType:  htmlContentType,
+			wantBody:         happyGetResult,
+			wantEncodedState: happyLocalUserState,
+			wantDecodedState: expectedHappyDecodedUpstreamStateParamForLocalUser(),
+			wantAuditLogs: func(encodedStateParam stateparam.Encoded) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{"state": "redacted"},
+					}),
+					testutil.WantAuditLog("AuthorizeID From Parameters", map[string]any{
+						"authorizeID": encodedStateParam.AuthorizeID(),
+					}),
+				}
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -462,6 +491,13 @@ func TestLoginEndpoint(t *testing.T) {
 			t.Parallel()
 
 			req := httptest.NewRequest(test.method, test.path, nil)
+			if test.method == http.MethodPost {
+				// The login form posts its params, including the state param, in the body of the request.
+				parsedPath, err := url.Parse(test.path)
+				require.NoError(t, err)
+				req = httptest.NewRequest(test.method, parsedPath.Path, strings.NewReader(parsedPath.RawQuery))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
 			if test.csrfCookie != "" {
 				req.Header.Set("Cookie", test.csrfCookie)
 			}
@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package login
@@ -12,11 +12,15 @@ import (
 
 	"go.pinniped.dev/internal/auditevent"
 	"go.pinniped.dev/internal/federationdomain/downstreamsession"
+	"go.pinniped.dev/internal/federationdomain/endpoints/device"
 	"go.pinniped.dev/internal/federationdomain/endpoints/loginurl"
 	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
 	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedldap"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedlocaluser"
 	"go.pinniped.dev/internal/federationdomain/stateparam"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/plog"
 )
@@ -25,6 +29,7 @@ func NewPostHandler(
 	issuerURL string,
 	upstreamIDPs federationdomainproviders.FederationDomainIdentityProvidersFinderI,
 	oauthHelper fosite.OAuth2Provider,
+	deviceApprover *device.Approver,
 	auditLogger plog.AuditLogger,
 ) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, encodedState stateparam.Encoded, decodedState *oidc.UpstreamStateParamData) error {
@@ -47,6 +52,12 @@ func NewPostHandler(
 			},
 		})
 
+		if decodedState.DeviceUserCodeSignature != "" {
+			// This login was started at the device verification endpoint, so there is no downstream
+			// authorization request. Instead, approve the device's pending device authorization request.
+			return finishDeviceLogin(w, r, issuerURL, encodedState, decodedState, idp, deviceApprover, auditLogger)
+		}
+
 		// Get the original params that were used at the authorization endpoint.
 		downstreamAuthParams, err := url.ParseQuery(decodedState.AuthParams)
 		if err != nil {
@@ -86,11 +97,16 @@ func NewPostHandler(
 		identity, loginExtras, err := idp.Login(r.Context(), submittedUsername, submittedPassword)
 		if err != nil {
 			switch {
-			case errors.Is(err, resolvedldap.ErrUnexpectedUpstreamLDAPError):
+			case errors.Is(err, upstreamhealth.ErrUpstreamUnavailable):
+				// The upstream is currently considered to be unreachable, so it was not contacted.
+				// The user may try to log in again later, so redirect back to the login page with an error.
+				return redirectToLoginPage(r, w, issuerURL, encodedState, loginurl.ShowUnavailableError)
+			case errors.Is(err, resolvedldap.ErrUnexpectedUpstreamLDAPError), errors.Is(err, resolvedlocaluser.ErrUnexpectedLocalUserError):
 				// There was some problem during authentication with the upstream, aside from bad username/password.
 				// The user may try to log in again if they'd like, so redirect back to the login page with an error.
 				return redirectToLoginPage(r, w, issuerURL, encodedState, loginurl.ShowInternalError)
-			case err == resolvedldap.ErrAccessDeniedDueToUsernamePasswordNotAccepted:
+			case err == resolvedldap.ErrAccessDeniedDueToUsernamePasswordNotAccepted,
+				err == resolvedlocaluser.ErrAccessDeniedDueToUsernamePasswordNotAccepted:
 				auditLogger.Audit(auditevent.IncorrectUsernameOrPassword, &plog.AuditParams{
 					ReqCtx: r.Context(),
 				})
@@ -110,6 +126,8 @@ func NewPostHandler(
 			UpstreamLoginExtras: loginExtras,
 			ClientID:            authorizeRequester.GetClient().GetID(),
 			GrantedScopes:       authorizeRequester.GetGrantedScopes(),
+			RequestedScopes:     authorizeRequester.GetRequestedScopes(),
+			RequestedAudience:   authorizeRequester.GetRequestedAudience(),
 			IdentityProvider:    idp,
 			SessionIDGetter:     authorizeRequester,
 		})
@@ -125,6 +143,45 @@ func NewPostHandler(
 	}
 }
 
+func finishDeviceLogin(
+	w http.ResponseWriter,
+	r *http.Request,
+	issuerURL string,
+	encodedState stateparam.Encoded,
+	decodedState *oidc.UpstreamStateParamData,
+	idp resolvedprovider.FederationDomainResolvedIdentityProvider,
+	deviceApprover *device.Approver,
+	auditLogger plog.AuditLogger,
+) error {
+	submittedUsername := r.PostFormValue(loginurl.UsernameParamName)
+	submittedPassword := r.PostFormValue(loginurl.PasswordParamName)
+
+	if submittedUsername == "" || submittedPassword == "" {
+		return redirectToLoginPage(r, w, issuerURL, encodedState, loginurl.ShowBadUserPassErr)
+	}
+
+	identity, loginExtras, err := idp.Login(r.Context(), submittedUsername, submittedPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, upstreamhealth.ErrUpstreamUnavailable):
+			return redirectToLoginPage(r, w, issuerURL, encodedState, loginurl.ShowUnavailableError)
+		case errors.Is(err, resolvedldap.ErrUnexpectedUpstreamLDAPError), errors.Is(err, resolvedlocaluser.ErrUnexpectedLocalUserError):
+			return redirectToLoginPage(r, w, issuerURL, encodedState, loginurl.ShowInternalError)
+		case err == resolvedldap.ErrAccessDeniedDueToUsernamePasswordNotAccepted,
+			err == resolvedlocaluser.ErrAccessDeniedDueToUsernamePasswordNotAccepted:
+			auditLogger.Audit(auditevent.IncorrectUsernameOrPassword, &plog.AuditParams{
+				ReqCtx: r.Context(),
+			})
+			return redirectToLoginPage(r, w, issuerURL, encodedState, loginurl.ShowBadUserPassErr)
+		default:
+			// There is no downstream client to which an error could be returned, so show it to the user.
+			return httperr.Wrap(http.StatusForbidden, "login failed", err)
+		}
+	}
+
+	return deviceApprover.Approve(w, r, decodedState.DeviceUserCodeSignature, idp, identity, loginExtras)
+}
+
 // redirectToLoginPage redirects to the GET /login page of the specified issuer.
 func redirectToLoginPage(
 	r *http.Request,
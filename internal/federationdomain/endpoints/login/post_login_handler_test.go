@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package login
@@ -22,10 +22,14 @@ import (
 	"go.pinniped.dev/internal/auditid"
 	"go.pinniped.dev/internal/authenticators"
 	"go.pinniped.dev/internal/celtransformer"
+	"go.pinniped.dev/internal/federationdomain/endpoints/device"
 	"go.pinniped.dev/internal/federationdomain/endpoints/jwks"
 	"go.pinniped.dev/internal/federationdomain/oidc"
 	"go.pinniped.dev/internal/federationdomain/oidcclientvalidator"
 	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/idtransform"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/psession"
 	"go.pinniped.dev/internal/testutil"
@@ -61,11 +65,15 @@ func TestPostLoginEndpoint(t *testing.T) {
 		activeDirectoryUpstreamType        = "activedirectory"
 		activeDirectoryUpstreamResourceUID = "active-directory-resource-uid"
 		upstreamLDAPURL                    = "ldaps://some-ldap-host:123?base=ou%3Dusers%2Cdc%3Dpinniped%2Cdc%3Ddev"
+		localUserUpstreamName              = "some-localuser-idp"
+		localUserUpstreamType              = "localuser"
+		localUserUpstreamResourceUID       = "localuser-resource-uid"
 
 		userParam                = "username"
 		passParam                = "password"
 		badUserPassErrParamValue = "incorrect_username_or_password"
 		internalErrParamValue    = "internal_error"
+		unavailableErrParamValue = "upstream_unavailable"
 
 		transformationUsernamePrefix = "username_prefix:"
 		transformationGroupsPrefix   = "groups_prefix:"
@@ -154,6 +162,11 @@ func TestPostLoginEndpoint(t *testing.T) {
 		data.UpstreamType = activeDirectoryUpstreamType
 	})
 
+	happyLocalUserDecodedState := modifyHappyLDAPDecodedState(func(data *oidc.UpstreamStateParamData) {
+		data.UpstreamName = localUserUpstreamName
+		data.UpstreamType = localUserUpstreamType
+	})
+
 	happyLDAPUsername := "some-ldap-user"
 	happyLDAPUsernameFromAuthenticator := "some-mapped-ldap-username"
 	happyLDAPPassword := "some-ldap-password" //nolint:gosec
@@ -202,6 +215,34 @@ func TestPostLoginEndpoint(t *testing.T) {
 
 	upstreamActiveDirectoryIdentityProvider := upstreamActiveDirectoryIdentityProviderBuilder.Build()
 
+	happyLocalUserUID := "some-local-user-secret-uid"
+	happyLocalUserDownstreamSubject := "localuser://some-localuser-idp?idpName=some-localuser-idp&sub=" + happyLDAPUsername
+
+	upstreamLocalUserIdentityProvider := oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().
+		WithName(localUserUpstreamName).
+		WithResourceUID(localUserUpstreamResourceUID).
+		WithAuthenticateFunc(func(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error) {
+			if username != happyLDAPUsername || password != happyLDAPPassword {
+				return nil, false, nil
+			}
+			return &upstreamprovider.LocalUser{
+				Username:          username,
+				Groups:            happyLDAPGroups,
+				UserUID:           happyLocalUserUID,
+				DownstreamSubject: happyLocalUserDownstreamSubject,
+				AMR:               []string{"pwd"},
+			}, true, nil
+		}).
+		Build()
+
+	erroringUpstreamLocalUserIdentityProvider := oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().
+		WithName(localUserUpstreamName).
+		WithResourceUID(localUserUpstreamResourceUID).
+		WithAuthenticateFunc(func(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error) {
+			return nil, false, fmt.Errorf("some local user upstream auth error")
+		}).
+		Build()
+
 	erroringUpstreamLDAPIdentityProvider := oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
 		WithName(ldapUpstreamName).
 		WithResourceUID(ldapUpstreamResourceUID).
@@ -210,6 +251,32 @@ func TestPostLoginEndpoint(t *testing.T) {
 		}).
 		Build()
 
+	unavailableUpstreamLDAPIdentityProvider := oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+		WithName(ldapUpstreamName).
+		WithResourceUID(ldapUpstreamResourceUID).
+		WithAuthenticateFunc(func(ctx context.Context, username, password string) (*authenticators.Response, bool, error) {
+			return nil, false, upstreamhealth.ErrUpstreamUnavailable
+		}).
+		Build()
+
+	happyLDAPAdditionalClaims := map[string][]string{
+		"email":   {"some-ldap-user@example.com"},
+		"aliases": {"alias1@example.com", "alias2@example.com"},
+	}
+
+	upstreamLDAPIdentityProviderWithAdditionalClaims := oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+		WithName(ldapUpstreamName).
+		WithResourceUID(ldapUpstreamResourceUID).
+		WithURL(parsedUpstreamLDAPURL).
+		WithAuthenticateFunc(func(ctx context.Context, username, password string) (*authenticators.Response, bool, error) {
+			response, authenticated, err := ldapAuthenticateFunc(ctx, username, password)
+			if response != nil {
+				response.AdditionalClaims = happyLDAPAdditionalClaims
+			}
+			return response, authenticated, err
+		}).
+		Build()
+
 	expectedHappyActiveDirectoryUpstreamCustomSession := &psession.CustomSessionData{
 		Username:         happyLDAPUsernameFromAuthenticator,
 		ProviderUID:      activeDirectoryUpstreamResourceUID,
@@ -240,6 +307,20 @@ func TestPostLoginEndpoint(t *testing.T) {
 		ActiveDirectory: nil,
 	}
 
+	expectedHappyLocalUserUpstreamCustomSession := &psession.CustomSessionData{
+		Username:         happyLDAPUsername,
+		ProviderUID:      localUserUpstreamResourceUID,
+		ProviderName:     localUserUpstreamName,
+		ProviderType:     psession.ProviderTypeLocalUser,
+		UpstreamUsername: happyLDAPUsername,
+		UpstreamGroups:   happyLDAPGroups,
+		LocalUser:        &psession.LocalUserSessionData{UserUID: happyLocalUserUID},
+		UpstreamData: map[string]any{
+			"username": happyLDAPUsername,
+			"groups":   []any{happyLDAPGroups[0], happyLDAPGroups[1], happyLDAPGroups[2]},
+		},
+	}
+
 	withUsernameAndGroupsInCustomSession := func(expectedCustomSessionData *psession.CustomSessionData, wantDownstreamUsername string, wantUpstreamUsername string, wantUpstreamGroups []string) *psession.CustomSessionData {
 		copyOfCustomSession := *expectedCustomSessionData
 		if expectedCustomSessionData.LDAP != nil {
@@ -327,6 +408,8 @@ func TestPostLoginEndpoint(t *testing.T) {
 		wantDownstreamNonce               string
 		wantDownstreamClient              string
 		wantDownstreamCustomSessionData   *psession.CustomSessionData
+		wantDownstreamAdditionalClaims    map[string]any
+		wantDownstreamAuthnContext        *idtransform.AuthenticationContext
 
 		// Authorization requests for either a successful OIDC upstream or for an error with any upstream
 		// should never use Kube storage. There is only one exception to this rule, which is that certain
@@ -389,6 +472,44 @@ func TestPostLoginEndpoint(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "happy LDAP login with additional claims",
+			idps: testidplister.NewUpstreamIDPListerBuilder().
+				WithLDAP(upstreamLDAPIdentityProviderWithAdditionalClaims),
+			decodedState:                      happyLDAPDecodedState,
+			formParams:                        happyUsernamePasswordFormParams,
+			wantStatus:                        http.StatusSeeOther,
+			wantContentType:                   htmlContentType,
+			wantBodyString:                    "",
+			wantRedirectLocationRegexp:        happyAuthcodeDownstreamRedirectLocationRegexp,
+			wantDownstreamIDTokenSubject:      upstreamLDAPURL + "&idpName=" + ldapUpstreamName + "&sub=" + happyLDAPUID,
+			wantDownstreamIDTokenUsername:     happyLDAPUsernameFromAuthenticator,
+			wantDownstreamIDTokenGroups:       happyLDAPGroups,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamRedirectURI:         downstreamRedirectURI,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamClient:              downstreamPinnipedCLIClientID,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData: &psession.CustomSessionData{
+				Username:         happyLDAPUsernameFromAuthenticator,
+				ProviderUID:      ldapUpstreamResourceUID,
+				ProviderName:     ldapUpstreamName,
+				ProviderType:     psession.ProviderTypeLDAP,
+				UpstreamUsername: happyLDAPUsernameFromAuthenticator,
+				UpstreamGroups:   happyLDAPGroups,
+				LDAP: &psession.LDAPSessionData{
+					UserDN:                 happyLDAPUserDN,
+					ExtraRefreshAttributes: map[string]string{happyLDAPExtraRefreshAttribute: happyLDAPExtraRefreshValue},
+					AdditionalClaims:       happyLDAPAdditionalClaims,
+				},
+			},
+			wantDownstreamAdditionalClaims: map[string]any{
+				"email":   "some-ldap-user@example.com",
+				"aliases": []any{"alias1@example.com", "alias2@example.com"},
+			},
+		},
 		{
 			name: "happy LDAP login with identity transformations which modify the username and group names",
 			idps: testidplister.NewUpstreamIDPListerBuilder().
@@ -891,6 +1012,61 @@ func TestPostLoginEndpoint(t *testing.T) {
 			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
 			wantDownstreamCustomSessionData:   expectedHappyLDAPUpstreamCustomSession,
 		},
+		{
+			name: "happy LocalUser login",
+			idps: testidplister.NewUpstreamIDPListerBuilder().
+				WithLDAP(erroringUpstreamLDAPIdentityProvider).
+				WithLocalUser(upstreamLocalUserIdentityProvider), // should pick this one
+			decodedState:                      happyLocalUserDecodedState,
+			formParams:                        happyUsernamePasswordFormParams,
+			wantStatus:                        http.StatusSeeOther,
+			wantContentType:                   htmlContentType,
+			wantBodyString:                    "",
+			wantRedirectLocationRegexp:        happyAuthcodeDownstreamRedirectLocationRegexp,
+			wantDownstreamIDTokenSubject:      happyLocalUserDownstreamSubject,
+			wantDownstreamIDTokenUsername:     happyLDAPUsername,
+			wantDownstreamIDTokenGroups:       happyLDAPGroups,
+			wantDownstreamRequestedScopes:     happyDownstreamScopesRequested,
+			wantDownstreamRedirectURI:         downstreamRedirectURI,
+			wantDownstreamGrantedScopes:       happyDownstreamScopesGranted,
+			wantDownstreamNonce:               downstreamNonce,
+			wantDownstreamClient:              downstreamPinnipedCLIClientID,
+			wantDownstreamPKCEChallenge:       downstreamPKCEChallenge,
+			wantDownstreamPKCEChallengeMethod: downstreamPKCEChallengeMethod,
+			wantDownstreamCustomSessionData:   expectedHappyLocalUserUpstreamCustomSession,
+			wantDownstreamAuthnContext:        &idtransform.AuthenticationContext{AMR: []string{"pwd"}},
+		},
+		{
+			name:                         "bad password LocalUser login",
+			idps:                         testidplister.NewUpstreamIDPListerBuilder().WithLocalUser(upstreamLocalUserIdentityProvider),
+			decodedState:                 happyLocalUserDecodedState,
+			formParams:                   url.Values{userParam: []string{happyLDAPUsername}, passParam: []string{"wrong!"}},
+			wantStatus:                   http.StatusSeeOther,
+			wantContentType:              htmlContentType,
+			wantBodyString:               "",
+			wantRedirectToLoginPageError: badUserPassErrParamValue,
+			wantAuditLogs: func(sessionID string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("Using Upstream IDP", map[string]any{
+						"displayName":  "some-localuser-idp",
+						"resourceName": "some-localuser-idp",
+						"resourceUID":  "localuser-resource-uid",
+						"type":         "localuser",
+					}),
+					testutil.WantAuditLog("Incorrect Username Or Password", map[string]any{}),
+				}
+			},
+		},
+		{
+			name:                         "error during upstream LocalUser authentication",
+			idps:                         testidplister.NewUpstreamIDPListerBuilder().WithLocalUser(erroringUpstreamLocalUserIdentityProvider),
+			decodedState:                 happyLocalUserDecodedState,
+			formParams:                   happyUsernamePasswordFormParams,
+			wantStatus:                   http.StatusSeeOther,
+			wantContentType:              htmlContentType,
+			wantBodyString:               "",
+			wantRedirectToLoginPageError: internalErrParamValue,
+		},
 		{
 			name:                         "bad username LDAP login",
 			idps:                         testidplister.NewUpstreamIDPListerBuilder().WithLDAP(upstreamLDAPIdentityProvider),
@@ -983,6 +1159,26 @@ func TestPostLoginEndpoint(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:                         "upstream LDAP identity provider is currently unavailable",
+			idps:                         testidplister.NewUpstreamIDPListerBuilder().WithLDAP(unavailableUpstreamLDAPIdentityProvider),
+			decodedState:                 happyLDAPDecodedState,
+			formParams:                   happyUsernamePasswordFormParams,
+			wantStatus:                   http.StatusSeeOther,
+			wantContentType:              htmlContentType,
+			wantBodyString:               "",
+			wantRedirectToLoginPageError: unavailableErrParamValue,
+			wantAuditLogs: func(sessionID string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("Using Upstream IDP", map[string]any{
+						"displayName":  "some-ldap-idp",
+						"resourceName": "some-ldap-idp",
+						"resourceUID":  "ldap-resource-uid",
+						"type":         "ldap",
+					}),
+				}
+			},
+		},
 		{
 			name: "downstream redirect uri does not match what is configured for client",
 			idps: testidplister.NewUpstreamIDPListerBuilder().WithLDAP(upstreamLDAPIdentityProvider),
@@ -1326,7 +1522,8 @@ func TestPostLoginEndpoint(t *testing.T) {
 
 			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
 
-			subject := NewPostHandler(downstreamIssuer, tt.idps.BuildFederationDomainIdentityProvidersListerFinder(), oauthHelper, auditLogger)
+			subject := NewPostHandler(downstreamIssuer, tt.idps.BuildFederationDomainIdentityProvidersListerFinder(), oauthHelper,
+				device.NewApprover(downstreamIssuer, kubeOauthStore, auditLogger), auditLogger)
 
 			err := subject(rsp, req, happyEncodedUpstreamState, tt.decodedState)
 			if tt.wantErr != "" {
@@ -1366,7 +1563,8 @@ func TestPostLoginEndpoint(t *testing.T) {
 					tt.wantDownstreamClient,
 					tt.wantDownstreamRedirectURI,
 					tt.wantDownstreamCustomSessionData,
-					map[string]any{},
+					tt.wantDownstreamAdditionalClaims,
+					tt.wantDownstreamAuthnContext,
 				)
 			case tt.wantRedirectToLoginPageError != "":
 				// Expecting an error redirect to the login UI page.
@@ -1402,7 +1600,8 @@ func TestPostLoginEndpoint(t *testing.T) {
 					tt.wantDownstreamClient,
 					tt.wantDownstreamRedirectURI,
 					tt.wantDownstreamCustomSessionData,
-					map[string]any{},
+					tt.wantDownstreamAdditionalClaims,
+					tt.wantDownstreamAuthnContext,
 				)
 			default:
 				require.Failf(t, "test should have expected a redirect or form body",
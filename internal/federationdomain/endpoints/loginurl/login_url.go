@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package loginurl
@@ -16,9 +16,10 @@ const (
 	StateParamName    = "state"
 	ErrParamName      = "err"
 
-	ShowNoError        ErrorParamValue = ""
-	ShowInternalError  ErrorParamValue = "internal_error"
-	ShowBadUserPassErr ErrorParamValue = "incorrect_username_or_password"
+	ShowNoError          ErrorParamValue = ""
+	ShowInternalError    ErrorParamValue = "internal_error"
+	ShowBadUserPassErr   ErrorParamValue = "incorrect_username_or_password"
+	ShowUnavailableError ErrorParamValue = "upstream_unavailable"
 )
 
 type ErrorParamValue string
@@ -0,0 +1,264 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package logout provides a handler for the OIDC RP-initiated logout endpoint (the end_session_endpoint).
+package logout
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/ory/fosite"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/crud"
+	"go.pinniped.dev/internal/federationdomain/endpoints/jwks"
+	"go.pinniped.dev/internal/federationdomain/endpoints/logout/logouthtml"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/upstreamrevocation"
+	"go.pinniped.dev/internal/fositestorage"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/httputil/securityheader"
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	IDTokenHintParamName           = "id_token_hint"
+	ClientIDParamName              = "client_id"
+	PostLogoutRedirectURIParamName = "post_logout_redirect_uri"
+	StateParamName                 = "state"
+)
+
+func paramsSafeToLog() sets.Set[string] {
+	return sets.New(
+		// Standard params from https://openid.net/specs/openid-connect-rpinitiated-1_0.html, some of which are ignored.
+		// Redacting id_token_hint because it can be used to end the session, and redacting state in case it contains
+		// any info that the client considers sensitive.
+		ClientIDParamName, PostLogoutRedirectURIParamName, "logout_hint", "ui_locales",
+	)
+}
+
+type idTokenHintClaims struct {
+	AuthorizedParty string `json:"azp"`
+	SessionID       string `json:"sid"`
+}
+
+// sessionID allows a session ID to be used when audit logging.
+type sessionID string
+
+func (s sessionID) GetID() string {
+	return string(s)
+}
+
+type logoutHandler struct {
+	issuerURL       string
+	jwksProvider    jwks.DynamicJWKSProvider
+	clientManager   fosite.ClientManager
+	secrets         corev1client.SecretInterface
+	upstreamRevoker *upstreamrevocation.Revoker
+	auditLogger     plog.AuditLogger
+}
+
+// NewHandler returns a http.Handler that serves the end_session_endpoint from
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html. The downstream session is identified by the
+// "sid" claim of the ID token which is sent as the id_token_hint. All session storage for that session is
+// deleted, after first revoking any upstream OIDC or OAuth2 tokens that were held in the session's storage.
+//
+// A GET request never ends the session. Instead, it shows a page which asks the user to confirm the logout, so that
+// a web page which links to this endpoint cannot log out the user without their consent. The confirmation page posts
+// the same params back to this endpoint. A POST request ends the session immediately, because it can only be made
+// by a client which has the ID token, or by the user submitting the confirmation page.
+func NewHandler(
+	issuerURL string,
+	jwksProvider jwks.DynamicJWKSProvider,
+	clientManager fosite.ClientManager,
+	secrets corev1client.SecretInterface,
+	upstreamRevoker *upstreamrevocation.Revoker,
+	auditLogger plog.AuditLogger,
+) http.Handler {
+	h := &logoutHandler{
+		issuerURL:       issuerURL,
+		jwksProvider:    jwksProvider,
+		clientManager:   clientManager,
+		secrets:         secrets,
+		upstreamRevoker: upstreamRevoker,
+		auditLogger:     auditLogger,
+	}
+	return securityheader.WrapWithCustomCSP(httperr.HandlerFunc(h.serveHTTP), logouthtml.ContentSecurityPolicy())
+}
+
+func (h *logoutHandler) serveHTTP(w http.ResponseWriter, r *http.Request) error {
+	if err := h.auditLogger.AuditRequestParams(r, paramsSafeToLog()); err != nil {
+		plog.DebugErr("error parsing logout request params", err)
+		return httperr.New(http.StatusBadRequest, "error parsing request params")
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET or POST)", r.Method)
+	}
+
+	idTokenHint := r.Form.Get(IDTokenHintParamName)
+	if idTokenHint == "" {
+		return httperr.Newf(http.StatusBadRequest, "%s param is required", IDTokenHintParamName)
+	}
+
+	claims, err := h.verifyIDTokenHint(r.Context(), idTokenHint)
+	if err != nil {
+		plog.DebugErr("logout request has invalid id_token_hint", err)
+		return httperr.Newf(http.StatusBadRequest, "%s param is invalid", IDTokenHintParamName)
+	}
+
+	if clientID := r.Form.Get(ClientIDParamName); clientID != "" && clientID != claims.AuthorizedParty {
+		return httperr.Newf(http.StatusBadRequest, "%s param does not match the client of the %s", ClientIDParamName, IDTokenHintParamName)
+	}
+
+	// Validate the redirect before ending the session, so a bad request will not end the session.
+	postLogoutRedirectURI, err := h.validatePostLogoutRedirectURI(r.Context(), claims.AuthorizedParty, r.Form.Get(PostLogoutRedirectURIParamName))
+	if err != nil {
+		plog.DebugErr("logout request has invalid post_logout_redirect_uri", err, "clientID", claims.AuthorizedParty)
+		return httperr.Newf(http.StatusBadRequest, "%s param is not allowed for this client", PostLogoutRedirectURIParamName)
+	}
+
+	if r.Method == http.MethodGet {
+		return h.showConfirmation(w, r, claims.AuthorizedParty)
+	}
+
+	if err := h.endSession(r.Context(), claims.SessionID); err != nil {
+		plog.Error("error ending downstream session", err)
+		return httperr.New(http.StatusInternalServerError, "error ending session")
+	}
+
+	h.auditLogger.Audit(auditevent.SessionEnded, &plog.AuditParams{
+		ReqCtx:        r.Context(),
+		Session:       sessionID(claims.SessionID),
+		KeysAndValues: []any{"clientID", claims.AuthorizedParty},
+	})
+
+	if postLogoutRedirectURI == nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, err = w.Write([]byte("You have been logged out.\n"))
+		return err
+	}
+
+	if state := r.Form.Get(StateParamName); state != "" {
+		query := postLogoutRedirectURI.Query()
+		query.Set(StateParamName, state)
+		postLogoutRedirectURI.RawQuery = query.Encode()
+	}
+	http.Redirect(w, r, postLogoutRedirectURI.String(), http.StatusSeeOther)
+	return nil
+}
+
+// showConfirmation renders a page which asks the user to confirm the logout by posting the request's params back to
+// this endpoint.
+func (h *logoutHandler) showConfirmation(w http.ResponseWriter, r *http.Request, clientID string) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return logouthtml.Template().Execute(w, &logouthtml.PageData{
+		ClientID:              clientID,
+		FormPath:              h.issuerURL + oidc.EndSessionEndpointPath,
+		IDTokenHint:           r.Form.Get(IDTokenHintParamName),
+		ClientIDParam:         r.Form.Get(ClientIDParamName),
+		PostLogoutRedirectURI: r.Form.Get(PostLogoutRedirectURIParamName),
+		State:                 r.Form.Get(StateParamName),
+	})
+}
+
+// verifyIDTokenHint checks that the ID token was issued by this FederationDomain. Expired ID tokens are allowed,
+// since the OIDC RP-Initiated Logout spec says that an ID token is a valid hint even when it has expired.
+func (h *logoutHandler) verifyIDTokenHint(ctx context.Context, rawIDToken string) (*idTokenHintClaims, error) {
+	keySet, _ := h.jwksProvider.GetJWKS(h.issuerURL)
+	if keySet == nil || len(keySet.Keys) == 0 {
+		return nil, errors.New("no JWKS found for issuer")
+	}
+
+	publicKeys := make([]crypto.PublicKey, 0, len(keySet.Keys))
+	for _, key := range keySet.Keys {
+		publicKeys = append(publicKeys, key.Key)
+	}
+
+	verifier := coreosoidc.NewVerifier(h.issuerURL, &coreosoidc.StaticKeySet{PublicKeys: publicKeys}, &coreosoidc.Config{
+		// The audience may be a cluster's audience after a token exchange, so the azp claim is used to find the client.
+		SkipClientIDCheck:    true,
+		SkipExpiryCheck:      true,
+		SupportedSigningAlgs: []string{coreosoidc.ES256},
+	})
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims idTokenHintClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	if claims.AuthorizedParty == "" {
+		return nil, errors.New("ID token is missing azp claim")
+	}
+	if claims.SessionID == "" {
+		return nil, errors.New("ID token is missing sid claim")
+	}
+
+	return &claims, nil
+}
+
+func (h *logoutHandler) validatePostLogoutRedirectURI(ctx context.Context, clientID string, rawURI string) (*url.URL, error) {
+	if rawURI == "" {
+		return nil, nil
+	}
+
+	client, err := h.clientManager.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the same matching rules as the authorization endpoint uses for redirect_uri params,
+	// which allows any port for loopback interface redirect URIs.
+	return fosite.MatchRedirectURIWithClientRedirectURIs(rawURI, client)
+}
+
+// endSession deletes all session storage Secrets for the session, after trying to revoke any upstream tokens
+// that they hold. It is not an error when no storage is found, because the session may have already ended.
+func (h *logoutHandler) endSession(ctx context.Context, requestID string) error {
+	list, err := h.secrets.List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{fositestorage.StorageRequestIDLabelName: requestID}.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list session storage: %w", err)
+	}
+
+	for i := range list.Items {
+		secret := &list.Items[i]
+
+		storageType, isSessionStorage := secret.Labels[crud.SecretLabelKey]
+		if !isSessionStorage {
+			continue
+		}
+
+		// The downstream session must end regardless of whether the upstream revocation works, so only log errors.
+		// Unlike the garbage collector, there is no later opportunity to retry, since the Secret is deleted below.
+		if err := h.upstreamRevoker.MaybeRevokeUpstreamToken(ctx, storageType, secret); err != nil {
+			plog.WarningErr("logout could not revoke upstream token", err,
+				"secretName", secret.Name, "storageTypeLabelValue", storageType)
+		}
+
+		err := h.secrets.Delete(ctx, secret.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &secret.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete session storage Secret %s: %w", secret.Name, err)
+		}
+	}
+
+	return nil
+}
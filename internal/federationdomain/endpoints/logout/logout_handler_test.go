@@ -0,0 +1,535 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package logout
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/auditid"
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/federationdomain/endpoints/jwks"
+	"go.pinniped.dev/internal/federationdomain/endpoints/logout/logouthtml"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamrevocation"
+	"go.pinniped.dev/internal/fositestorage/accesstoken"
+	"go.pinniped.dev/internal/fositestorage/refreshtoken"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+	"go.pinniped.dev/internal/testutil/testidplister"
+)
+
+const (
+	downstreamIssuer    = "https://my-downstream-issuer.com/some-path"
+	upstreamName        = "some-upstream-idp"
+	upstreamResourceUID = "some-upstream-resource-uid"
+	happySessionID      = "some-session-id"
+	otherSessionID      = "some-other-session-id"
+)
+
+func TestLogoutEndpoint(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherSigningKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	happyClaims := func() map[string]any {
+		return map[string]any{
+			"iss": downstreamIssuer,
+			"sub": "some-subject",
+			"aud": []string{"pinniped-cli"},
+			"azp": "pinniped-cli",
+			"sid": happySessionID,
+			"iat": time.Now().Add(-time.Minute).Unix(),
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	happyIDTokenHint := func(t *testing.T) string {
+		return signIDToken(t, signingKey, happyClaims())
+	}
+
+	// The GET tests need to know the value of the id_token_hint to check that it is put into the confirmation form.
+	confirmationIDTokenHint := happyIDTokenHint(t)
+
+	happyRequestParamsAuditLog := testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+		"params": map[string]any{"id_token_hint": "redacted"},
+	})
+
+	happyRevocationAuditLog := testutil.WantAuditLog("Upstream OIDC Token Revoked", map[string]any{
+		"sessionID": happySessionID,
+		"type":      "refresh_token",
+	})
+
+	happySessionEndedAuditLog := testutil.WantAuditLog("Session Ended", map[string]any{
+		"sessionID": happySessionID,
+		"clientID":  "pinniped-cli",
+	})
+
+	tests := []struct {
+		name               string
+		method             string
+		params             func(t *testing.T) url.Values
+		revokeError        error
+		noJWKS             bool
+		wantStatus         int
+		wantBody           string
+		wantLocation       string
+		wantContentType    string
+		wantBodyContains   []string
+		wantBodyNotContain []string
+		wantRevoked        bool
+		wantEnded          bool
+		wantAuditLogs      []testutil.WantedAuditLog
+	}{
+		{
+			name:   "GET asks the user to confirm the logout without ending the session",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				return url.Values{"id_token_hint": []string{confirmationIDTokenHint}}
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`The client <strong>pinniped-cli</strong> is asking to end your session.`,
+				`<form action="` + downstreamIssuer + oidc.EndSessionEndpointPath + `" method="post">`,
+				`<input type="hidden" name="id_token_hint" id="id_token_hint" value="` + confirmationIDTokenHint + `">`,
+			},
+			wantBodyNotContain: []string{
+				`name="client_id"`,
+				`name="post_logout_redirect_uri"`,
+				`name="state"`,
+			},
+			wantAuditLogs: []testutil.WantedAuditLog{happyRequestParamsAuditLog},
+		},
+		{
+			name:   "GET with a post_logout_redirect_uri and state asks the user to confirm the logout with the same params",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				return url.Values{
+					"id_token_hint":            []string{confirmationIDTokenHint},
+					"client_id":                []string{"pinniped-cli"},
+					"post_logout_redirect_uri": []string{"http://127.0.0.1:12345/callback"},
+					"state":                    []string{"some-state"},
+				}
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBodyContains: []string{
+				`<input type="hidden" name="id_token_hint" id="id_token_hint" value="` + confirmationIDTokenHint + `">`,
+				`<input type="hidden" name="client_id" id="client_id" value="pinniped-cli">`,
+				`<input type="hidden" name="post_logout_redirect_uri" id="post_logout_redirect_uri" value="http://127.0.0.1:12345/callback">`,
+				`<input type="hidden" name="state" id="state" value="some-state">`,
+			},
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"id_token_hint":            "redacted",
+						"client_id":                "pinniped-cli",
+						"post_logout_redirect_uri": "http://127.0.0.1:12345/callback",
+						"state":                    "redacted",
+					},
+				}),
+			},
+		},
+		{
+			name:   "happy path using POST without a post_logout_redirect_uri",
+			method: http.MethodPost,
+			params: func(t *testing.T) url.Values {
+				return url.Values{"id_token_hint": []string{happyIDTokenHint(t)}}
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "You have been logged out.\n",
+			wantRevoked:     true,
+			wantEnded:       true,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				happyRequestParamsAuditLog,
+				happyRevocationAuditLog,
+				happySessionEndedAuditLog,
+			},
+		},
+		{
+			name:   "happy path using POST with a post_logout_redirect_uri and state",
+			method: http.MethodPost,
+			params: func(t *testing.T) url.Values {
+				return url.Values{
+					"id_token_hint":            []string{happyIDTokenHint(t)},
+					"client_id":                []string{"pinniped-cli"},
+					"post_logout_redirect_uri": []string{"http://127.0.0.1:12345/callback"},
+					"state":                    []string{"some-state"},
+				}
+			},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "http://127.0.0.1:12345/callback?state=some-state",
+			wantRevoked:  true,
+			wantEnded:    true,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{
+						"id_token_hint":            "redacted",
+						"client_id":                "pinniped-cli",
+						"post_logout_redirect_uri": "http://127.0.0.1:12345/callback",
+						"state":                    "redacted",
+					},
+				}),
+				happyRevocationAuditLog,
+				happySessionEndedAuditLog,
+			},
+		},
+		{
+			name:   "an expired ID token is still a valid hint",
+			method: http.MethodPost,
+			params: func(t *testing.T) url.Values {
+				claims := happyClaims()
+				claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				return url.Values{"id_token_hint": []string{signIDToken(t, signingKey, claims)}}
+			},
+			wantStatus:  http.StatusOK,
+			wantBody:    "You have been logged out.\n",
+			wantRevoked: true,
+			wantEnded:   true,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				happyRequestParamsAuditLog,
+				happyRevocationAuditLog,
+				happySessionEndedAuditLog,
+			},
+		},
+		{
+			name:   "the session still ends when the upstream token revocation fails",
+			method: http.MethodPost,
+			params: func(t *testing.T) url.Values {
+				return url.Values{"id_token_hint": []string{happyIDTokenHint(t)}}
+			},
+			revokeError: errors.New("some revocation error"),
+			wantStatus:  http.StatusOK,
+			wantBody:    "You have been logged out.\n",
+			wantRevoked: true,
+			wantEnded:   true,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				happyRequestParamsAuditLog,
+				happySessionEndedAuditLog,
+			},
+		},
+		{
+			name:   "the session storage was already deleted",
+			method: http.MethodPost,
+			params: func(t *testing.T) url.Values {
+				claims := happyClaims()
+				claims["sid"] = "some-session-id-which-has-no-storage"
+				return url.Values{"id_token_hint": []string{signIDToken(t, signingKey, claims)}}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "You have been logged out.\n",
+			wantAuditLogs: []testutil.WantedAuditLog{
+				happyRequestParamsAuditLog,
+				testutil.WantAuditLog("Session Ended", map[string]any{
+					"sessionID": "some-session-id-which-has-no-storage",
+					"clientID":  "pinniped-cli",
+				}),
+			},
+		},
+		{
+			name:          "wrong method",
+			method:        http.MethodPut,
+			params:        func(t *testing.T) url.Values { return url.Values{} },
+			wantStatus:    http.StatusMethodNotAllowed,
+			wantBody:      "Method Not Allowed: PUT (try GET or POST)\n",
+			wantAuditLogs: []testutil.WantedAuditLog{testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}})},
+		},
+		{
+			name:          "missing id_token_hint",
+			method:        http.MethodGet,
+			params:        func(t *testing.T) url.Values { return url.Values{} },
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      "Bad Request: id_token_hint param is required\n",
+			wantAuditLogs: []testutil.WantedAuditLog{testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}})},
+		},
+		{
+			name:   "id_token_hint is not a JWT",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				return url.Values{"id_token_hint": []string{"not-a-jwt"}}
+			},
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      "Bad Request: id_token_hint param is invalid\n",
+			wantAuditLogs: []testutil.WantedAuditLog{happyRequestParamsAuditLog},
+		},
+		{
+			name:   "id_token_hint was signed by some other key",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				return url.Values{"id_token_hint": []string{signIDToken(t, otherSigningKey, happyClaims())}}
+			},
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      "Bad Request: id_token_hint param is invalid\n",
+			wantAuditLogs: []testutil.WantedAuditLog{happyRequestParamsAuditLog},
+		},
+		{
+			name:   "id_token_hint was issued by some other issuer",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				claims := happyClaims()
+				claims["iss"] = "https://some-other-issuer.com"
+				return url.Values{"id_token_hint": []string{signIDToken(t, signingKey, claims)}}
+			},
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      "Bad Request: id_token_hint param is invalid\n",
+			wantAuditLogs: []testutil.WantedAuditLog{happyRequestParamsAuditLog},
+		},
+		{
+			name:   "id_token_hint has no sid claim",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				claims := happyClaims()
+				delete(claims, "sid")
+				return url.Values{"id_token_hint": []string{signIDToken(t, signingKey, claims)}}
+			},
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      "Bad Request: id_token_hint param is invalid\n",
+			wantAuditLogs: []testutil.WantedAuditLog{happyRequestParamsAuditLog},
+		},
+		{
+			name:   "id_token_hint has no azp claim",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				claims := happyClaims()
+				delete(claims, "azp")
+				return url.Values{"id_token_hint": []string{signIDToken(t, signingKey, claims)}}
+			},
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      "Bad Request: id_token_hint param is invalid\n",
+			wantAuditLogs: []testutil.WantedAuditLog{happyRequestParamsAuditLog},
+		},
+		{
+			name:   "the FederationDomain has no signing keys",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				return url.Values{"id_token_hint": []string{happyIDTokenHint(t)}}
+			},
+			noJWKS:        true,
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      "Bad Request: id_token_hint param is invalid\n",
+			wantAuditLogs: []testutil.WantedAuditLog{happyRequestParamsAuditLog},
+		},
+		{
+			name:   "client_id does not match the azp claim of the id_token_hint",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				return url.Values{
+					"id_token_hint": []string{happyIDTokenHint(t)},
+					"client_id":     []string{"some-other-client"},
+				}
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: client_id param does not match the client of the id_token_hint\n",
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"id_token_hint": "redacted", "client_id": "some-other-client"},
+				}),
+			},
+		},
+		{
+			name:   "post_logout_redirect_uri is not registered for the client",
+			method: http.MethodGet,
+			params: func(t *testing.T) url.Values {
+				return url.Values{
+					"id_token_hint":            []string{happyIDTokenHint(t)},
+					"post_logout_redirect_uri": []string{"https://evil.example.com/callback"},
+				}
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Bad Request: post_logout_redirect_uri param is not allowed for this client\n",
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"id_token_hint": "redacted", "post_logout_redirect_uri": "https://evil.example.com/callback"},
+				}),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secrets := fake.NewSimpleClientset().CoreV1().Secrets("some-namespace")
+			oidcClientsClient := supervisorfake.NewSimpleClientset().ConfigV1alpha1().OIDCClients("some-namespace")
+			kubeStorage := storage.NewKubeStorage(secrets, oidcClientsClient, oidc.DefaultOIDCTimeoutsConfiguration(), 4)
+
+			upstreamBuilder := oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+				WithName(upstreamName).
+				WithResourceUID(types.UID(upstreamResourceUID))
+			if test.revokeError != nil {
+				upstreamBuilder = upstreamBuilder.WithRevokeTokenError(test.revokeError)
+			}
+			idpListerBuilder := testidplister.NewUpstreamIDPListerBuilder().WithOIDC(upstreamBuilder.Build())
+
+			// Create the storage for the session which is ending, and for some other session which should not end.
+			createSessionStorage(t, secrets, happySessionID, "some-upstream-refresh-token")
+			createSessionStorage(t, secrets, otherSessionID, "some-other-upstream-refresh-token")
+
+			jwksProvider := jwks.NewDynamicJWKSProvider()
+			if !test.noJWKS {
+				jwksProvider.SetIssuerToJWKSMap(
+					map[string]*jose.JSONWebKeySet{
+						downstreamIssuer: {Keys: []jose.JSONWebKey{{Key: signingKey.Public(), KeyID: "some-key-id", Algorithm: "ES256", Use: "sig"}}},
+					},
+					map[string]*jose.JSONWebKey{
+						downstreamIssuer: {Key: signingKey, KeyID: "some-key-id", Algorithm: "ES256", Use: "sig"},
+					},
+				)
+			}
+
+			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+			subject := NewHandler(
+				downstreamIssuer,
+				jwksProvider,
+				kubeStorage,
+				secrets,
+				upstreamrevocation.New(idpListerBuilder.BuildDynamicUpstreamIDPProvider(), auditLogger),
+				auditLogger,
+			)
+
+			var req *http.Request
+			params := test.params(t)
+			if test.method == http.MethodGet {
+				req = httptest.NewRequest(test.method, downstreamIssuer+oidc.EndSessionEndpointPath+"?"+params.Encode(), nil)
+			} else {
+				req = httptest.NewRequest(test.method, downstreamIssuer+oidc.EndSessionEndpointPath, strings.NewReader(params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			req, _ = auditid.NewRequestWithAuditID(req, func() string { return "fake-logout-audit-id" })
+			rsp := httptest.NewRecorder()
+
+			subject.ServeHTTP(rsp, req)
+
+			require.Equal(t, test.wantStatus, rsp.Code, rsp.Body.String())
+			if test.wantBodyContains != nil {
+				for _, want := range test.wantBodyContains {
+					require.Contains(t, rsp.Body.String(), want)
+				}
+				for _, notWant := range test.wantBodyNotContain {
+					require.NotContains(t, rsp.Body.String(), notWant)
+				}
+			} else {
+				require.Equal(t, test.wantBody, rsp.Body.String())
+			}
+			if test.wantContentType != "" {
+				require.Equal(t, test.wantContentType, rsp.Header().Get("Content-Type"))
+			}
+			require.Equal(t, logouthtml.ContentSecurityPolicy(), rsp.Header().Get("Content-Security-Policy"))
+			require.Equal(t, test.wantLocation, rsp.Header().Get("Location"))
+
+			if test.wantRevoked {
+				idpListerBuilder.RequireExactlyOneCallToRevokeToken(t, upstreamName, &oidctestutil.RevokeTokenArgs{
+					Ctx:       req.Context(),
+					Token:     "some-upstream-refresh-token",
+					TokenType: upstreamprovider.RefreshTokenType,
+				})
+			} else {
+				idpListerBuilder.RequireExactlyZeroCallsToRevokeToken(t)
+			}
+
+			requireSessionStorageCount(t, secrets, otherSessionID, 2)
+			if test.wantEnded {
+				requireSessionStorageCount(t, secrets, happySessionID, 0)
+			} else {
+				requireSessionStorageCount(t, secrets, happySessionID, 2)
+			}
+
+			testutil.WantAuditIDOnEveryAuditLog(test.wantAuditLogs, "fake-logout-audit-id")
+			testutil.CompareAuditLogs(t, test.wantAuditLogs, actualAuditLog.String())
+		})
+	}
+}
+
+func TestParamsSafeToLog(t *testing.T) {
+	wantParams := []string{
+		"client_id",
+		"post_logout_redirect_uri",
+		"logout_hint",
+		"ui_locales",
+	}
+
+	require.ElementsMatch(t, wantParams, paramsSafeToLog().UnsortedList())
+}
+
+func signIDToken(t *testing.T, key *ecdsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: key, KeyID: "some-key-id"}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	require.NoError(t, err)
+
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.NoError(t, err)
+	return token
+}
+
+// createSessionStorage creates access token and refresh token storage for a downstream session
+// which was started using an upstream OIDC identity provider.
+func createSessionStorage(t *testing.T, secrets corev1client.SecretInterface, requestID string, upstreamRefreshToken string) {
+	t.Helper()
+
+	request := &fosite.Request{
+		ID:             requestID,
+		Client:         clientregistry.PinnipedCLI(),
+		GrantedScope:   fosite.Arguments{"openid", "offline_access"},
+		RequestedAt:    time.Now(),
+		RequestedScope: fosite.Arguments{"openid", "offline_access"},
+		Session: &psession.PinnipedSession{
+			Fosite: &openid.DefaultSession{Subject: "some-subject"},
+			Custom: &psession.CustomSessionData{
+				Username:     "some-username",
+				ProviderUID:  upstreamResourceUID,
+				ProviderName: upstreamName,
+				ProviderType: psession.ProviderTypeOIDC,
+				OIDC: &psession.OIDCSessionData{
+					UpstreamRefreshToken: upstreamRefreshToken,
+					UpstreamIssuer:       "https://some-upstream-issuer",
+					UpstreamSubject:      "some-subject",
+				},
+			},
+		},
+	}
+
+	lifetime := func(_ fosite.Requester) time.Duration { return time.Hour }
+	ctx := context.Background()
+	require.NoError(t, accesstoken.New(secrets, time.Now, lifetime).CreateAccessTokenSession(ctx, requestID+"-access-signature", request))
+	require.NoError(t, refreshtoken.New(secrets, time.Now, lifetime).CreateRefreshTokenSession(ctx, requestID+"-refresh-signature", "", request))
+}
+
+func requireSessionStorageCount(t *testing.T, secrets corev1client.SecretInterface, requestID string, wantCount int) {
+	t.Helper()
+
+	list, err := secrets.List(context.Background(), metav1.ListOptions{
+		LabelSelector: "storage.pinniped.dev/request-id=" + requestID,
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Items, wantCount)
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package logouthtml
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testExpectedCSS = `html{height:100%}body{font-family:metropolis-light,Helvetica,sans-serif;display:flex;flex-flow:column wrap;justify-content:flex-start;align-items:center;background:linear-gradient(to top,#f8f8f8,white);min-height:100%}h1{font-size:20px;margin:0}.box{display:flex;flex-direction:column;flex-wrap:nowrap;border-radius:4px;border-color:#ddd;border-width:1px;border-style:solid;width:400px;padding:30px 30px 0;margin:60px 20px 0;background:#fff;font-size:14px}input{color:inherit;font:inherit;border:0;margin:0;outline:0;padding:0}.form-field{display:flex;margin-bottom:30px}.form-field input[type=submit]{width:100%;padding:1em;background-color:#218fcf;color:#eee;font-weight:700;cursor:pointer;transition:all .3s}.form-field input[type=submit]:focus,.form-field input[type=submit]:hover{background-color:#1abfd3}.form-field input[type=submit]:active{transform:scale(.99)}`
+
+	// It's okay if this changes in the future, but this gives us a chance to eyeball the formatting.
+	// Our browser-based integration tests should find any incompatibilities.
+	testExpectedCSP = `default-src 'none'; ` +
+		`style-src 'sha256-DH2nV1Mouyvt/oUL1XcxRpkIeZ+UJ1u5yhFwCMkfzjo='; ` +
+		`img-src data:; ` +
+		`frame-ancestors 'none'`
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		name           string
+		pageData       *PageData
+		wantContains   []string
+		wantNotContain []string
+	}{
+		{
+			name: "confirmation with only the required params",
+			pageData: &PageData{
+				ClientID:    "test-client<id>",
+				FormPath:    "/test-path",
+				IDTokenHint: "test-id-token",
+			},
+			wantContains: []string{
+				`<style>` + testExpectedCSS + `</style>`,
+				`<h1>Log out</h1>`,
+				`The client <strong>test-client&lt;id&gt;</strong> is asking to end your session.`,
+				`<form action="/test-path" method="post">`,
+				`<input type="hidden" name="id_token_hint" id="id_token_hint" value="test-id-token">`,
+				`<input type="submit" name="submit" id="submit" value="Log out"/>`,
+			},
+			wantNotContain: []string{
+				`name="client_id"`,
+				`name="post_logout_redirect_uri"`,
+				`name="state"`,
+			},
+		},
+		{
+			name: "confirmation with all params",
+			pageData: &PageData{
+				ClientID:              "test-client",
+				FormPath:              "/test-path",
+				IDTokenHint:           "test-id-token",
+				ClientIDParam:         "test-client",
+				PostLogoutRedirectURI: "https://example.com/path?a=b&c=d",
+				State:                 "test<state>",
+			},
+			wantContains: []string{
+				`<input type="hidden" name="id_token_hint" id="id_token_hint" value="test-id-token">`,
+				`<input type="hidden" name="client_id" id="client_id" value="test-client">`,
+				`<input type="hidden" name="post_logout_redirect_uri" id="post_logout_redirect_uri" value="https://example.com/path?a=b&amp;c=d">`,
+				`<input type="hidden" name="state" id="state" value="test&lt;state&gt;">`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Template().Execute(&buf, tt.pageData))
+			// t.Logf("actual value:\n%s", buf.String()) // useful when updating minify library causes new output
+			for _, want := range tt.wantContains {
+				require.Contains(t, buf.String(), want)
+			}
+			for _, notWant := range tt.wantNotContain {
+				require.NotContains(t, buf.String(), notWant)
+			}
+		})
+	}
+}
+
+func TestContentSecurityPolicy(t *testing.T) {
+	require.Equal(t, testExpectedCSP, ContentSecurityPolicy())
+}
+
+func TestCSS(t *testing.T) {
+	require.Equal(t, testExpectedCSS, CSS())
+}
+
+func TestHelpers(t *testing.T) {
+	require.Equal(t, "test", panicOnError("test", nil))
+	require.PanicsWithError(t, "some error", func() { panicOnError("", fmt.Errorf("some error")) })
+}
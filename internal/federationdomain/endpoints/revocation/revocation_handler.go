@@ -0,0 +1,76 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package revocation provides a handler for the OAuth 2.0 token revocation endpoint (RFC 7009).
+package revocation
+
+import (
+	"net/http"
+
+	"github.com/ory/fosite"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/tokenrevocation"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+)
+
+func paramsSafeToLog() sets.Set[string] {
+	return sets.New(
+		// Standard params from https://datatracker.ietf.org/doc/html/rfc7009#section-2.1.
+		// Redacting token, and also client_secret in case the client sends it as a param instead of using basic auth.
+		"token_type_hint", "client_id",
+	)
+}
+
+// NewHandler returns a http.Handler that serves the token revocation endpoint from
+// https://datatracker.ietf.org/doc/html/rfc7009. After authenticating the client, the downstream access or
+// refresh token is looked up, and when it was issued to that same client, then the storage of all access and
+// refresh tokens of that token's session is deleted. As required by the RFC, the response does not reveal
+// whether the token was found.
+func NewHandler(
+	oauthHelper fosite.OAuth2Provider,
+	auditLogger plog.AuditLogger,
+) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if err := auditLogger.AuditRequestParams(r, paramsSafeToLog()); err != nil {
+			oauthHelper.WriteRevocationResponse(r.Context(), w, err)
+			return nil
+		}
+
+		// For dynamic clients, the client ID is from basic auth, not from the request parameters.
+		clientID, _, basicAuthUsed := r.BasicAuth()
+		if basicAuthUsed {
+			auditLogger.Audit(auditevent.HTTPRequestBasicAuthUsed, &plog.AuditParams{
+				ReqCtx:        r.Context(),
+				KeysAndValues: []any{"clientID", clientID},
+			})
+		} else {
+			clientID = r.PostForm.Get("client_id")
+		}
+
+		// Validates that the request is a POST, authenticates the client, and revokes the token when it
+		// belongs to that client.
+		ctx := tokenrevocation.WithRevocationRecorder(r.Context())
+		err := oauthHelper.NewRevocationRequest(ctx, r)
+		if err != nil {
+			plog.Info("revocation request error", oidc.FositeErrorForLog(err)...)
+			oauthHelper.WriteRevocationResponse(r.Context(), w, err)
+			return nil
+		}
+
+		// The request also succeeds for unknown tokens, so only audit when the token's storage was deleted.
+		if tokenrevocation.TokenWasRevoked(ctx) {
+			auditLogger.Audit(auditevent.DownstreamTokenRevoked, &plog.AuditParams{
+				ReqCtx:        r.Context(),
+				KeysAndValues: []any{"clientID", clientID, "tokenTypeHint", r.PostForm.Get("token_type_hint")},
+			})
+		}
+
+		oauthHelper.WriteRevocationResponse(r.Context(), w, nil)
+
+		return nil
+	})
+}
@@ -0,0 +1,330 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package revocation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/auditid"
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/oidcclientvalidator"
+	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/federationdomain/strategy"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil"
+)
+
+const (
+	downstreamIssuer = "https://my-downstream-issuer.com/some-path"
+	hmacSecret       = "this needs to be at least 32 characters to meet entropy requirements"
+
+	dynamicClientID  = "client.oauth.pinniped.dev-test-name"
+	dynamicClientUID = "fake-client-uid"
+
+	cliSessionID     = "some-cli-session-id"
+	dynamicSessionID = "some-dynamic-client-session-id"
+)
+
+// sessionTokens holds the tokens which were issued for a downstream session.
+type sessionTokens struct {
+	accessToken  string
+	refreshToken string
+}
+
+func TestRevocationEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		// params returns the request params, given the tokens of the pinniped-cli session and the dynamic client session.
+		params        func(cliTokens, dynamicTokens sessionTokens) url.Values
+		method        string
+		basicAuthUser string
+		basicAuthPass string
+
+		wantStatus          int
+		wantBodyJSON        string
+		wantRevokedSessions []string
+		wantAuditLogs       []testutil.WantedAuditLog
+	}{
+		{
+			name: "dynamic client revokes its refresh token",
+			params: func(_, dynamicTokens sessionTokens) url.Values {
+				return url.Values{"token": []string{dynamicTokens.refreshToken}, "token_type_hint": []string{"refresh_token"}}
+			},
+			basicAuthUser:       dynamicClientID,
+			basicAuthPass:       testutil.PlaintextPassword1,
+			wantStatus:          http.StatusOK,
+			wantRevokedSessions: []string{dynamicSessionID},
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted", "token_type_hint": "refresh_token"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": dynamicClientID}),
+				testutil.WantAuditLog("Downstream Token Revoked", map[string]any{"clientID": dynamicClientID, "tokenTypeHint": "refresh_token"}),
+			},
+		},
+		{
+			name: "dynamic client revokes its access token without a token type hint",
+			params: func(_, dynamicTokens sessionTokens) url.Values {
+				return url.Values{"token": []string{dynamicTokens.accessToken}}
+			},
+			basicAuthUser:       dynamicClientID,
+			basicAuthPass:       testutil.PlaintextPassword1,
+			wantStatus:          http.StatusOK,
+			wantRevokedSessions: []string{dynamicSessionID},
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": dynamicClientID}),
+				testutil.WantAuditLog("Downstream Token Revoked", map[string]any{"clientID": dynamicClientID, "tokenTypeHint": ""}),
+			},
+		},
+		{
+			name: "pinniped-cli revokes its access token",
+			params: func(cliTokens, _ sessionTokens) url.Values {
+				return url.Values{
+					"token":           []string{cliTokens.accessToken},
+					"token_type_hint": []string{"access_token"},
+					"client_id":       []string{"pinniped-cli"},
+				}
+			},
+			wantStatus:          http.StatusOK,
+			wantRevokedSessions: []string{cliSessionID},
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted", "token_type_hint": "access_token", "client_id": "pinniped-cli"},
+				}),
+				testutil.WantAuditLog("Downstream Token Revoked", map[string]any{"clientID": "pinniped-cli", "tokenTypeHint": "access_token"}),
+			},
+		},
+		{
+			name: "unknown token is considered to be revoked, but is not audited as revoked",
+			params: func(_, _ sessionTokens) url.Values {
+				return url.Values{"token": []string{"some-unknown-token"}}
+			},
+			basicAuthUser: dynamicClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			wantStatus:    http.StatusOK,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": dynamicClientID}),
+				// Nothing was revoked, so there is no audit event for it.
+			},
+		},
+		{
+			name: "client cannot revoke a token which was issued to a different client",
+			params: func(cliTokens, _ sessionTokens) url.Values {
+				return url.Values{"token": []string{cliTokens.refreshToken}}
+			},
+			basicAuthUser: dynamicClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			// RFC 7009 does not allow the response to reveal that the token exists.
+			wantStatus: http.StatusOK,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": dynamicClientID}),
+			},
+		},
+		{
+			name: "wrong client secret",
+			params: func(_, dynamicTokens sessionTokens) url.Values {
+				return url.Values{"token": []string{dynamicTokens.refreshToken}}
+			},
+			basicAuthUser: dynamicClientID,
+			basicAuthPass: "wrong-password",
+			wantStatus:    http.StatusUnauthorized,
+			wantBodyJSON: `{
+				"error":             "invalid_client",
+				"error_description": "Client authentication failed (e.g., unknown client, no client authentication included, or unsupported authentication method)."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted"},
+				}),
+				testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": dynamicClientID}),
+			},
+		},
+		{
+			name: "dynamic client does not use its client secret",
+			params: func(_, dynamicTokens sessionTokens) url.Values {
+				return url.Values{"token": []string{dynamicTokens.refreshToken}, "client_id": []string{dynamicClientID}}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBodyJSON: `{
+				"error":             "invalid_client",
+				"error_description": "Client authentication failed (e.g., unknown client, no client authentication included, or unsupported authentication method)."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted", "client_id": dynamicClientID},
+				}),
+			},
+		},
+		{
+			name: "wrong HTTP method",
+			params: func(cliTokens, _ sessionTokens) url.Values {
+				return url.Values{"token": []string{cliTokens.refreshToken}, "client_id": []string{"pinniped-cli"}}
+			},
+			method:     http.MethodGet,
+			wantStatus: http.StatusBadRequest,
+			wantBodyJSON: `{
+				"error":             "invalid_request",
+				"error_description": "The request is missing a required parameter, includes an invalid parameter value, includes a parameter more than once, or is otherwise malformed. Make sure that the various parameters are correct, be aware of case sensitivity and trim your parameters. Make sure that the client you are using has exactly whitelisted the redirect_uri you specified."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"token": "redacted", "client_id": "pinniped-cli"},
+				}),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset()
+			supervisorClient := supervisorfake.NewSimpleClientset()
+			oidcClient, clientSecret := testutil.FullyCapableOIDCClientAndStorageSecret(t,
+				"some-namespace",
+				dynamicClientID,
+				dynamicClientUID,
+				"https://some-webapp.example.com/callback",
+				nil, // no custom ID token lifetime
+				[]string{testutil.HashedPassword1AtGoMinCost},
+				oidcclientvalidator.Validate,
+			)
+			require.NoError(t, supervisorClient.Tracker().Add(oidcClient))
+			require.NoError(t, kubeClient.Tracker().Add(clientSecret))
+
+			secrets := kubeClient.CoreV1().Secrets("some-namespace")
+			// Use lower minimum required bcrypt cost than we would use in production to keep unit the tests fast.
+			kubeStorage := storage.NewKubeStorage(secrets, supervisorClient.ConfigV1alpha1().OIDCClients("some-namespace"),
+				oidc.DefaultOIDCTimeoutsConfiguration(), 4)
+			oauthHelper := oidc.FositeOauth2Helper(kubeStorage, downstreamIssuer, hmacSecretFunc, nil, oidc.DefaultOIDCTimeoutsConfiguration())
+
+			dynamicClient, err := kubeStorage.GetClient(context.Background(), dynamicClientID)
+			require.NoError(t, err)
+			cliTokens := createSessionStorage(t, kubeStorage, cliSessionID, clientregistry.PinnipedCLI())
+			dynamicTokens := createSessionStorage(t, kubeStorage, dynamicSessionID, dynamicClient.(*clientregistry.Client))
+
+			method := http.MethodPost
+			if test.method != "" {
+				method = test.method
+			}
+			var req *http.Request
+			params := test.params(cliTokens, dynamicTokens)
+			if method == http.MethodPost {
+				req = httptest.NewRequest(method, downstreamIssuer+oidc.RevocationEndpointPath, strings.NewReader(params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(method, downstreamIssuer+oidc.RevocationEndpointPath+"?"+params.Encode(), nil)
+			}
+			if test.basicAuthUser != "" {
+				req.SetBasicAuth(test.basicAuthUser, test.basicAuthPass)
+			}
+			req, _ = auditid.NewRequestWithAuditID(req, func() string { return "fake-revocation-audit-id" })
+			rsp := httptest.NewRecorder()
+
+			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+			NewHandler(oauthHelper, auditLogger).ServeHTTP(rsp, req)
+
+			require.Equal(t, test.wantStatus, rsp.Code, rsp.Body.String())
+			require.Equal(t, "no-store", rsp.Header().Get("Cache-Control"))
+			if test.wantBodyJSON != "" {
+				require.JSONEq(t, test.wantBodyJSON, rsp.Body.String())
+			} else {
+				require.Empty(t, rsp.Body.String())
+			}
+
+			for _, sessionID := range []string{cliSessionID, dynamicSessionID} {
+				if slices.Contains(test.wantRevokedSessions, sessionID) {
+					requireSessionStorageCount(t, secrets, sessionID, 0)
+				} else {
+					requireSessionStorageCount(t, secrets, sessionID, 2)
+				}
+			}
+
+			testutil.WantAuditIDOnEveryAuditLog(test.wantAuditLogs, "fake-revocation-audit-id")
+			testutil.CompareAuditLogs(t, test.wantAuditLogs, actualAuditLog.String())
+		})
+	}
+}
+
+func TestParamsSafeToLog(t *testing.T) {
+	wantParams := []string{
+		"token_type_hint",
+		"client_id",
+	}
+
+	require.ElementsMatch(t, wantParams, paramsSafeToLog().UnsortedList())
+}
+
+func hmacSecretFunc() []byte {
+	return []byte(hmacSecret)
+}
+
+// createSessionStorage creates access token and refresh token storage for a downstream session
+// of the given client, and returns the tokens which were issued for that session.
+func createSessionStorage(t *testing.T, kubeStorage *storage.KubeStorage, requestID string, client *clientregistry.Client) sessionTokens {
+	t.Helper()
+
+	request := &fosite.Request{
+		ID:             requestID,
+		Client:         client,
+		GrantedScope:   fosite.Arguments{"openid", "offline_access"},
+		RequestedAt:    time.Now(),
+		RequestedScope: fosite.Arguments{"openid", "offline_access"},
+		Session: &psession.PinnipedSession{
+			Fosite: &openid.DefaultSession{Subject: "some-subject"},
+			Custom: &psession.CustomSessionData{
+				Username:     "some-username",
+				ProviderUID:  "some-upstream-resource-uid",
+				ProviderName: "some-upstream-idp",
+				ProviderType: psession.ProviderTypeOIDC,
+				OIDC:         &psession.OIDCSessionData{UpstreamRefreshToken: "some-upstream-refresh-token"},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	hmacStrategy := strategy.NewDynamicOauth2HMACStrategy(&fosite.Config{}, hmacSecretFunc)
+	accessToken, accessSignature, err := hmacStrategy.GenerateAccessToken(ctx, request)
+	require.NoError(t, err)
+	refreshToken, refreshSignature, err := hmacStrategy.GenerateRefreshToken(ctx, request)
+	require.NoError(t, err)
+
+	require.NoError(t, kubeStorage.CreateAccessTokenSession(ctx, accessSignature, request))
+	require.NoError(t, kubeStorage.CreateRefreshTokenSession(ctx, refreshSignature, accessSignature, request))
+
+	return sessionTokens{accessToken: accessToken, refreshToken: refreshToken}
+}
+
+func requireSessionStorageCount(t *testing.T, secrets corev1client.SecretInterface, requestID string, wantCount int) {
+	t.Helper()
+
+	list, err := secrets.List(context.Background(), metav1.ListOptions{
+		LabelSelector: "storage.pinniped.dev/request-id=" + requestID,
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Items, wantCount)
+}
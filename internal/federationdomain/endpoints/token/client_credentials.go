@@ -0,0 +1,97 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package token
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/fosite"
+	errorsx "github.com/pkg/errors"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+// startClientCredentialsSession populates the session of a client_credentials grant using the fixed service identity
+// of the client, and grants the requested scopes. There is no end user involved in this grant, so the client acts
+// on its own behalf, and it is the subject of the resulting tokens (as suggested by RFC9068 section 2.2).
+// The client has already been authenticated by fosite by the time that this is called.
+func startClientCredentialsSession(
+	ctx context.Context,
+	accessRequest fosite.AccessRequester,
+	auditLogger plog.AuditLogger,
+) error {
+	client, ok := accessRequest.GetClient().(*clientregistry.Client)
+	if !ok || !client.GetGrantTypes().Has(oidcapi.GrantTypeClientCredentials) || client.GetServiceIdentity() == nil {
+		// This error message is copied from the similar check in fosite's flow_client_credentials.go.
+		return errorsx.WithStack(fosite.ErrUnauthorizedClient.WithHint(
+			"The OAuth 2.0 Client is not allowed to use authorization grant 'client_credentials'."))
+	}
+
+	// Refresh tokens are not issued for this grant, as recommended by RFC6749 section 4.4.3.
+	if oidc.ScopeWasRequested(accessRequest, oidcapi.ScopeOfflineAccess) {
+		return errorsx.WithStack(fosite.ErrInvalidScope.WithHintf(
+			"The '%s' scope is not allowed for authorization grant 'client_credentials'.", oidcapi.ScopeOfflineAccess))
+	}
+
+	// Fosite has already validated that the client is allowed to request these scopes.
+	for _, scope := range []string{
+		oidcapi.ScopeOpenID,
+		oidcapi.ScopeRequestAudience,
+		oidcapi.ScopeUsername,
+		oidcapi.ScopeGroups,
+	} {
+		oidc.GrantScopeIfRequested(accessRequest, scope)
+	}
+
+	serviceIdentity := client.GetServiceIdentity()
+	subject := client.GetID()
+	now := time.Now().UTC()
+
+	session := accessRequest.GetSession().(*psession.PinnipedSession)
+	session.Fosite.Subject = subject
+	session.Fosite.Claims.Subject = subject
+	session.Fosite.Claims.RequestedAt = now
+	session.Fosite.Claims.AuthTime = now
+	session.Custom.Username = serviceIdentity.Username
+
+	extras := map[string]any{
+		oidcapi.IDTokenClaimAuthorizedParty: client.GetID(),
+		oidcapi.IDTokenClaimSessionID:       accessRequest.GetID(),
+	}
+
+	groups := serviceIdentity.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+
+	if accessRequest.GetGrantedScopes().Has(oidcapi.ScopeUsername) {
+		extras[oidcapi.IDTokenClaimUsername] = serviceIdentity.Username
+	}
+	if accessRequest.GetGrantedScopes().Has(oidcapi.ScopeGroups) {
+		extras[oidcapi.IDTokenClaimGroups] = groups
+	}
+
+	session.Fosite.Claims.Extra = extras
+
+	auditLogger.Audit(auditevent.SessionStarted, &plog.AuditParams{
+		ReqCtx:  ctx,
+		Session: accessRequest,
+		PIIKeysAndValues: []any{
+			"username", serviceIdentity.Username,
+			"groups", groups,
+			"subject", subject,
+		},
+		KeysAndValues: []any{
+			"grantType", oidcapi.GrantTypeClientCredentials,
+		},
+	})
+
+	return nil
+}
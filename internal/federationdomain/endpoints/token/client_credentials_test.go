@@ -0,0 +1,509 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/auditid"
+	"go.pinniped.dev/internal/celtransformer"
+	"go.pinniped.dev/internal/crud"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/oidcclientvalidator"
+	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/fositestorage/accesstoken"
+	"go.pinniped.dev/internal/idtransform"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+	"go.pinniped.dev/internal/testutil/testidplister"
+	"go.pinniped.dev/internal/testutil/transformtestutil"
+)
+
+func TestTokenEndpointClientCredentials(t *testing.T) { // tests for grant_type "client_credentials"
+	const (
+		serviceClientID  = "client.oauth.pinniped.dev-service"
+		serviceClientUID = "fake-service-client-uid"
+		serviceUsername  = "some-service-username"
+	)
+	serviceGroups := []string{"some-service-group1", "some-service-group2"}
+
+	clientAssertionKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwksBytes, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: clientAssertionKey.Public(), KeyID: "some-key-id", Algorithm: "ES256", Use: "sig"},
+	}})
+	require.NoError(t, err)
+
+	usePrivateKeyJWT := func(oidcClient *supervisorconfigv1alpha1.OIDCClient) {
+		oidcClient.Spec.ClientAuthentication = &supervisorconfigv1alpha1.OIDCClientAuthentication{
+			Method: "private_key_jwt",
+			PrivateKeyJWT: &supervisorconfigv1alpha1.OIDCClientPrivateKeyJWT{
+				JWKS:             string(jwksBytes),
+				SigningAlgorithm: "ES256",
+			},
+		}
+	}
+
+	signClientAssertion := func(t *testing.T, audience string) string {
+		signer, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: jose.ES256, Key: clientAssertionKey},
+			(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "some-key-id"),
+		)
+		require.NoError(t, err)
+		claims, err := json.Marshal(map[string]any{
+			"iss": serviceClientID,
+			"sub": serviceClientID,
+			"aud": audience,
+			"jti": "some-jti-" + audience,
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		require.NoError(t, err)
+		signed, err := signer.Sign(claims)
+		require.NoError(t, err)
+		serialized, err := signed.CompactSerialize()
+		require.NoError(t, err)
+		return serialized
+	}
+
+	happyAuditLogs := func(scope string, sessionID string, idToken string) []testutil.WantedAuditLog {
+		wantAuditLogs := []testutil.WantedAuditLog{
+			testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+				"params": map[string]any{"grant_type": "client_credentials", "scope": scope},
+			}),
+			testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": serviceClientID}),
+			testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			testutil.WantAuditLog("Session Started", map[string]any{
+				"sessionID": sessionID,
+				"grantType": "client_credentials",
+				"personalInfo": map[string]any{
+					"username": serviceUsername,
+					"groups":   []any{"some-service-group1", "some-service-group2"},
+					"subject":  serviceClientID,
+				},
+			}),
+		}
+		if idToken != "" {
+			wantAuditLogs = append(wantAuditLogs, testutil.WantAuditLog("ID Token Issued", map[string]any{
+				"sessionID": sessionID,
+				"tokenID":   idTokenToHash(idToken),
+			}))
+		}
+		return wantAuditLogs
+	}
+
+	tests := []struct {
+		name             string
+		scope            string
+		basicAuthUser    string
+		basicAuthPass    string
+		formClientID     string
+		modifyOIDCClient func(oidcClient *supervisorconfigv1alpha1.OIDCClient)
+		// When set, the client authenticates using a client assertion with this audience instead of using basic auth.
+		clientAssertionAudience string
+		idps                    *testidplister.UpstreamIDPListerBuilder
+
+		wantStatus            int
+		wantGrantedScopes     string
+		wantIDTokenClaims     []string
+		wantUsername          string
+		wantGroups            []string
+		wantErrorType         string
+		wantErrorDescContains string
+		// When set, the access token from the response will be exchanged for a cluster-scoped ID token with this audience.
+		wantExchangeForAudience string
+		wantExchangeErrorType   string
+		wantExchangeErrorDesc   string
+		wantAuditLogs           func(sessionID string, idToken string) []testutil.WantedAuditLog
+		wantExchangeAuditLogs   func(sessionID string) []testutil.WantedAuditLog
+	}{
+		{
+			name:                    "happy path with all scopes, and the resulting access token can be used for token exchange",
+			scope:                   "openid pinniped:request-audience username groups",
+			basicAuthUser:           serviceClientID,
+			basicAuthPass:           testutil.PlaintextPassword1,
+			wantStatus:              http.StatusOK,
+			wantGrantedScopes:       "openid pinniped:request-audience username groups",
+			wantIDTokenClaims:       []string{"username", "groups"},
+			wantUsername:            serviceUsername,
+			wantGroups:              serviceGroups,
+			wantExchangeForAudience: "some-workload-cluster",
+			wantAuditLogs: func(sessionID string, idToken string) []testutil.WantedAuditLog {
+				return happyAuditLogs("openid pinniped:request-audience username groups", sessionID, idToken)
+			},
+		},
+		{
+			name:          "token exchange is allowed when only the token exchange transformations for other audiences exist",
+			scope:         "openid pinniped:request-audience username groups",
+			basicAuthUser: serviceClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(
+				oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().WithTokenExchangeTransformsForFederationDomain(
+					idtransform.NewTokenExchangePipeline([]string{"some-other-workload-cluster"}, nil,
+						transformtestutil.NewRejectAllAuthPipeline(t)),
+					idtransform.NewTokenExchangePipeline(nil, []string{"some-other-client"},
+						transformtestutil.NewRejectAllAuthPipeline(t)),
+				).Build()),
+			wantStatus:              http.StatusOK,
+			wantGrantedScopes:       "openid pinniped:request-audience username groups",
+			wantIDTokenClaims:       []string{"username", "groups"},
+			wantUsername:            serviceUsername,
+			wantGroups:              serviceGroups,
+			wantExchangeForAudience: "some-workload-cluster",
+		},
+		{
+			name:          "token exchange is rejected when any identity provider has token exchange transformations for the audience and client",
+			scope:         "openid pinniped:request-audience username groups",
+			basicAuthUser: serviceClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(
+				oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().WithTokenExchangeTransformsForFederationDomain(
+					idtransform.NewTokenExchangePipeline([]string{"some-workload-cluster"}, []string{serviceClientID},
+						transformtestutil.NewPipeline(t, []celtransformer.CELTransformation{
+							&celtransformer.UsernameTransformation{Expression: `"prod:" + username`},
+						})),
+				).Build()),
+			wantStatus:              http.StatusOK,
+			wantGrantedScopes:       "openid pinniped:request-audience username groups",
+			wantIDTokenClaims:       []string{"username", "groups"},
+			wantUsername:            serviceUsername,
+			wantGroups:              serviceGroups,
+			wantExchangeForAudience: "some-workload-cluster",
+			wantExchangeErrorType:   "access_denied",
+			wantExchangeErrorDesc:   "Token exchange rejected because configured identity transformations apply to it, but the session was not started by an identity provider.",
+			wantAuditLogs: func(sessionID string, idToken string) []testutil.WantedAuditLog {
+				return happyAuditLogs("openid pinniped:request-audience username groups", sessionID, idToken)
+			},
+			wantExchangeAuditLogs: func(sessionID string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{
+							"audience":             "some-workload-cluster",
+							"grant_type":           "urn:ietf:params:oauth:grant-type:token-exchange",
+							"requested_token_type": "urn:ietf:params:oauth:token-type:jwt",
+							"subject_token":        "redacted",
+							"subject_token_type":   "urn:ietf:params:oauth:token-type:access_token",
+						},
+					}),
+					testutil.WantAuditLog("HTTP Request Basic Auth", map[string]any{"clientID": serviceClientID}),
+					testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+					testutil.WantAuditLog("Authentication Rejected By Transforms", map[string]any{
+						"sessionID": sessionID,
+						"reason":    "Token exchange rejected because configured identity transformations apply to it, but the session was not started by an identity provider.",
+					}),
+				}
+			},
+		},
+		{
+			name:              "happy path without the username and groups scopes",
+			scope:             "openid pinniped:request-audience",
+			basicAuthUser:     serviceClientID,
+			basicAuthPass:     testutil.PlaintextPassword1,
+			wantStatus:        http.StatusOK,
+			wantGrantedScopes: "openid pinniped:request-audience",
+			// The token exchange requires the username to be in the session.
+			wantExchangeForAudience: "some-workload-cluster",
+			wantExchangeErrorType:   "access_denied",
+			wantAuditLogs: func(sessionID string, idToken string) []testutil.WantedAuditLog {
+				return happyAuditLogs("openid pinniped:request-audience", sessionID, idToken)
+			},
+		},
+		{
+			name:              "happy path without the openid scope does not return an ID token",
+			scope:             "username groups",
+			basicAuthUser:     serviceClientID,
+			basicAuthPass:     testutil.PlaintextPassword1,
+			wantStatus:        http.StatusOK,
+			wantGrantedScopes: "username groups",
+			wantAuditLogs: func(sessionID string, idToken string) []testutil.WantedAuditLog {
+				return happyAuditLogs("username groups", sessionID, idToken)
+			},
+		},
+		{
+			name:          "offline_access scope is not allowed, even when the client is allowed to use refresh tokens",
+			scope:         "openid offline_access username groups",
+			basicAuthUser: serviceClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			modifyOIDCClient: func(oidcClient *supervisorconfigv1alpha1.OIDCClient) {
+				oidcClient.Spec.AllowedGrantTypes = append(oidcClient.Spec.AllowedGrantTypes, "refresh_token")
+				oidcClient.Spec.AllowedScopes = append(oidcClient.Spec.AllowedScopes, "offline_access")
+			},
+			wantStatus:            http.StatusBadRequest,
+			wantErrorType:         "invalid_scope",
+			wantErrorDescContains: "The 'offline_access' scope is not allowed for authorization grant 'client_credentials'.",
+		},
+		{
+			name:                  "scope which is not allowed for the client",
+			scope:                 "openid pinniped:introspect",
+			basicAuthUser:         serviceClientID,
+			basicAuthPass:         testutil.PlaintextPassword1,
+			wantStatus:            http.StatusBadRequest,
+			wantErrorType:         "invalid_scope",
+			wantErrorDescContains: "The OAuth 2.0 Client is not allowed to request scope 'pinniped:introspect'.",
+		},
+		{
+			name:          "client which is not allowed to use the client_credentials grant",
+			scope:         "openid username groups",
+			basicAuthUser: serviceClientID,
+			basicAuthPass: testutil.PlaintextPassword1,
+			modifyOIDCClient: func(oidcClient *supervisorconfigv1alpha1.OIDCClient) {
+				oidcClient.Spec.AllowedGrantTypes = []supervisorconfigv1alpha1.GrantType{"authorization_code"}
+				oidcClient.Spec.AllowedScopes = []supervisorconfigv1alpha1.Scope{"openid", "username", "groups"}
+				oidcClient.Spec.ServiceIdentity = nil
+			},
+			wantStatus:            http.StatusBadRequest,
+			wantErrorType:         "unauthorized_client",
+			wantErrorDescContains: "The OAuth 2.0 Client is not allowed to use authorization grant 'client_credentials'.",
+		},
+		{
+			name:                  "public pinniped-cli client cannot use the client_credentials grant",
+			scope:                 "openid username groups",
+			formClientID:          pinnipedCLIClientID,
+			wantStatus:            http.StatusBadRequest,
+			wantErrorType:         "invalid_grant",
+			wantErrorDescContains: "The OAuth 2.0 Client is marked as public and is thus not allowed to use authorization grant 'client_credentials'.",
+		},
+		{
+			name:                    "happy path using private_key_jwt client authentication",
+			scope:                   "openid username groups",
+			modifyOIDCClient:        usePrivateKeyJWT,
+			clientAssertionAudience: goodIssuer + "/oauth2/token",
+			wantStatus:              http.StatusOK,
+			wantGrantedScopes:       "openid username groups",
+			wantIDTokenClaims:       []string{"username", "groups"},
+			wantUsername:            serviceUsername,
+			wantGroups:              serviceGroups,
+		},
+		{
+			name:                    "private_key_jwt client authentication with the wrong audience",
+			scope:                   "openid username groups",
+			modifyOIDCClient:        usePrivateKeyJWT,
+			clientAssertionAudience: "https://some-other-issuer.com/oauth2/token",
+			wantStatus:              http.StatusUnauthorized,
+			wantErrorType:           "invalid_client",
+			wantErrorDescContains:   "Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint",
+		},
+		{
+			name:                  "a private_key_jwt client cannot authenticate using a client secret",
+			scope:                 "openid username groups",
+			basicAuthUser:         serviceClientID,
+			basicAuthPass:         testutil.PlaintextPassword1,
+			modifyOIDCClient:      usePrivateKeyJWT,
+			wantStatus:            http.StatusUnauthorized,
+			wantErrorType:         "invalid_client",
+			wantErrorDescContains: "Client authentication failed",
+		},
+		{
+			name:                  "wrong client secret",
+			scope:                 "openid username groups",
+			basicAuthUser:         serviceClientID,
+			basicAuthPass:         "wrong-secret",
+			wantStatus:            http.StatusUnauthorized,
+			wantErrorType:         "invalid_client",
+			wantErrorDescContains: "Client authentication failed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oidcClient, secret := testutil.ServiceOIDCClientAndStorageSecret(t,
+				"some-namespace",
+				serviceClientID,
+				serviceClientUID,
+				goodRedirectURI,
+				&supervisorconfigv1alpha1.OIDCClientServiceIdentity{Username: serviceUsername, Groups: serviceGroups},
+				[]string{testutil.HashedPassword1AtGoMinCost},
+				oidcclientvalidator.Validate,
+			)
+			if test.modifyOIDCClient != nil {
+				test.modifyOIDCClient(oidcClient)
+			}
+			kubeClient := fake.NewSimpleClientset(secret)
+			supervisorClient := supervisorfake.NewSimpleClientset(oidcClient)
+			secrets := kubeClient.CoreV1().Secrets("some-namespace")
+			oauthStore := storage.NewKubeStorage(secrets, supervisorClient.ConfigV1alpha1().OIDCClients("some-namespace"),
+				oidc.DefaultOIDCTimeoutsConfiguration(), bcrypt.MinCost)
+
+			_, jwkProvider := generateJWTSigningKeyAndJWKSProvider(t, goodIssuer)
+			timeoutsConfiguration := oidc.DefaultOIDCTimeoutsConfiguration()
+			oauthHelper := oidc.FositeOauth2Helper(oauthStore, goodIssuer, hmacSecretFunc, jwkProvider, timeoutsConfiguration)
+
+			idps := test.idps
+			if idps == nil {
+				idps = testidplister.NewUpstreamIDPListerBuilder()
+			}
+
+			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+			subject := NewHandler(
+				idps.BuildFederationDomainIdentityProvidersListerFinder(),
+				oauthHelper,
+				timeoutsConfiguration.OverrideDefaultAccessTokenLifespan,
+				timeoutsConfiguration.OverrideDefaultIDTokenLifespan,
+				auditLogger,
+			)
+
+			params := url.Values{"grant_type": []string{"client_credentials"}, "scope": []string{test.scope}}
+			if test.formClientID != "" {
+				params.Set("client_id", test.formClientID)
+			}
+			if test.clientAssertionAudience != "" {
+				params.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+				params.Set("client_assertion", signClientAssertion(t, test.clientAssertionAudience))
+			}
+			req := httptest.NewRequest(http.MethodPost, "/path/shouldn't/matter", strings.NewReader(params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.basicAuthUser != "" {
+				req.SetBasicAuth(test.basicAuthUser, test.basicAuthPass)
+			}
+			req, _ = auditid.NewRequestWithAuditID(req, func() string { return "fake-client-credentials-audit-id" })
+			rsp := httptest.NewRecorder()
+
+			approxRequestTime := time.Now()
+			subject.ServeHTTP(rsp, req)
+			t.Logf("response: %#v", rsp)
+			t.Logf("response body: %q", rsp.Body.String())
+
+			require.Equal(t, test.wantStatus, rsp.Code)
+			testutil.RequireEqualContentType(t, rsp.Header().Get("Content-Type"), "application/json")
+
+			var parsedResponseBody map[string]any
+			require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &parsedResponseBody))
+
+			if rsp.Code != http.StatusOK {
+				require.Equal(t, test.wantErrorType, parsedResponseBody["error"])
+				require.Contains(t, parsedResponseBody["error_description"], test.wantErrorDescContains)
+
+				// No access token should have been stored.
+				accessTokenSecrets, err := secrets.List(context.Background(), metav1.ListOptions{
+					LabelSelector: crud.SecretLabelKey + "=" + accesstoken.TypeLabelValue,
+				})
+				require.NoError(t, err)
+				require.Empty(t, accessTokenSecrets.Items)
+				return
+			}
+
+			wantBodyFields := []string{"access_token", "token_type", "expires_in", "scope"}
+			idToken := ""
+			if strings.Contains(test.wantGrantedScopes, "openid") {
+				wantBodyFields = append(wantBodyFields, "id_token")
+				idToken = parsedResponseBody["id_token"].(string)
+			}
+			// Refresh tokens are never issued for this grant.
+			require.ElementsMatch(t, wantBodyFields, getMapKeys(parsedResponseBody))
+			require.Equal(t, "bearer", parsedResponseBody["token_type"])
+			require.Equal(t, test.wantGrantedScopes, parsedResponseBody["scope"])
+			require.InDelta(t, accessTokenExpirationSeconds, parsedResponseBody["expires_in"], 2)
+
+			// The access token should have been stored for the service identity.
+			accessToken := parsedResponseBody["access_token"].(string)
+			accessTokenSecret, err := secrets.Get(context.Background(),
+				getSecretNameFromSignature(t, getFositeDataSignature(t, accessToken), accesstoken.TypeLabelValue), metav1.GetOptions{})
+			require.NoError(t, err)
+			storedSession, err := accesstoken.ReadFromSecret(accessTokenSecret)
+			require.NoError(t, err)
+			require.Equal(t, serviceClientID, storedSession.Request.GetClient().GetID())
+			storedPinnipedSession := storedSession.Request.Session.(*psession.PinnipedSession)
+			require.Equal(t, serviceClientID, storedPinnipedSession.Fosite.Subject)
+			require.Equal(t, serviceUsername, storedPinnipedSession.Custom.Username)
+			sessionID := storedSession.Request.GetID()
+
+			if idToken != "" {
+				parsedJWT, err := jose.ParseSigned(idToken, []jose.SignatureAlgorithm{jose.ES256})
+				require.NoError(t, err)
+				var idTokenClaims map[string]any
+				require.NoError(t, json.Unmarshal(parsedJWT.UnsafePayloadWithoutVerification(), &idTokenClaims))
+
+				wantIDTokenClaims := append([]string{"sub", "aud", "iss", "jti", "auth_time", "exp", "iat", "rat", "azp", "sid", "at_hash"},
+					test.wantIDTokenClaims...)
+				require.ElementsMatch(t, wantIDTokenClaims, getMapKeys(idTokenClaims))
+				require.Equal(t, serviceClientID, idTokenClaims["sub"])
+				require.Equal(t, []any{serviceClientID}, idTokenClaims["aud"])
+				require.Equal(t, serviceClientID, idTokenClaims["azp"])
+				require.Equal(t, sessionID, idTokenClaims["sid"])
+				require.Equal(t, goodIssuer, idTokenClaims["iss"])
+				if test.wantUsername != "" {
+					require.Equal(t, test.wantUsername, idTokenClaims["username"])
+				}
+				if test.wantGroups != nil {
+					require.Equal(t, toSliceOfInterface(test.wantGroups), idTokenClaims["groups"])
+				}
+				issuedAt := time.Unix(int64(idTokenClaims["iat"].(float64)), 0)
+				testutil.RequireTimeInDelta(t, approxRequestTime.UTC(), issuedAt, timeComparisonFudge)
+			}
+
+			if test.wantAuditLogs != nil {
+				wantAuditLogs := test.wantAuditLogs(sessionID, idToken)
+				testutil.WantAuditIDOnEveryAuditLog(wantAuditLogs, "fake-client-credentials-audit-id")
+				testutil.CompareAuditLogs(t, wantAuditLogs, actualAuditLog.String())
+			}
+
+			if test.wantExchangeForAudience == "" {
+				return
+			}
+
+			// Exchange the access token for a cluster-scoped ID token.
+			exchangeParams := url.Values{
+				"grant_type":           []string{"urn:ietf:params:oauth:grant-type:token-exchange"},
+				"subject_token":        []string{accessToken},
+				"subject_token_type":   []string{"urn:ietf:params:oauth:token-type:access_token"},
+				"requested_token_type": []string{"urn:ietf:params:oauth:token-type:jwt"},
+				"audience":             []string{test.wantExchangeForAudience},
+			}
+			exchangeReq := httptest.NewRequest(http.MethodPost, "/path/shouldn't/matter", strings.NewReader(exchangeParams.Encode()))
+			exchangeReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			exchangeReq.SetBasicAuth(serviceClientID, testutil.PlaintextPassword1)
+			exchangeReq, _ = auditid.NewRequestWithAuditID(exchangeReq, func() string { return "fake-token-exchange-audit-id" })
+			exchangeRsp := httptest.NewRecorder()
+			actualAuditLog.Reset()
+			subject.ServeHTTP(exchangeRsp, exchangeReq)
+			t.Logf("token exchange response body: %q", exchangeRsp.Body.String())
+
+			if test.wantExchangeAuditLogs != nil {
+				wantAuditLogs := test.wantExchangeAuditLogs(sessionID)
+				testutil.WantAuditIDOnEveryAuditLog(wantAuditLogs, "fake-token-exchange-audit-id")
+				testutil.CompareAuditLogs(t, wantAuditLogs, actualAuditLog.String())
+			}
+
+			var parsedExchangeResponseBody map[string]any
+			require.NoError(t, json.Unmarshal(exchangeRsp.Body.Bytes(), &parsedExchangeResponseBody))
+
+			if test.wantExchangeErrorType != "" {
+				require.Equal(t, http.StatusForbidden, exchangeRsp.Code)
+				require.Equal(t, test.wantExchangeErrorType, parsedExchangeResponseBody["error"])
+				require.Contains(t, parsedExchangeResponseBody["error_description"], test.wantExchangeErrorDesc)
+				return
+			}
+
+			require.Equal(t, http.StatusOK, exchangeRsp.Code)
+			require.Equal(t, "urn:ietf:params:oauth:token-type:jwt", parsedExchangeResponseBody["issued_token_type"])
+			parsedJWT, err := jose.ParseSigned(parsedExchangeResponseBody["access_token"].(string), []jose.SignatureAlgorithm{jose.ES256})
+			require.NoError(t, err)
+			var clusterTokenClaims map[string]any
+			require.NoError(t, json.Unmarshal(parsedJWT.UnsafePayloadWithoutVerification(), &clusterTokenClaims))
+			require.Equal(t, []any{test.wantExchangeForAudience}, clusterTokenClaims["aud"])
+			require.Equal(t, serviceClientID, clusterTokenClaims["sub"])
+			require.Equal(t, test.wantUsername, clusterTokenClaims["username"])
+			require.Equal(t, toSliceOfInterface(test.wantGroups), clusterTokenClaims["groups"])
+		})
+	}
+}
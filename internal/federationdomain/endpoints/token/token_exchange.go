@@ -0,0 +1,172 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package token
+
+import (
+	"context"
+	"maps"
+
+	"github.com/ory/fosite"
+	errorsx "github.com/pkg/errors"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
+	"go.pinniped.dev/internal/idtransform"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+// applyTokenExchangeTransformations evaluates the token exchange transformation pipelines which were configured on the
+// FederationDomain for the identity provider of the session, and which match the requested audience and the client.
+// The results are applied to the session of the token exchange, which is a clone of the stored session, so they
+// only impact the cluster-scoped ID token which is issued by this token exchange. The request has already been
+// validated by the token exchange handler by the time that this is called.
+func applyTokenExchangeTransformations(
+	ctx context.Context,
+	accessRequest fosite.AccessRequester,
+	idpLister federationdomainproviders.FederationDomainIdentityProvidersListerI,
+	auditLogger plog.AuditLogger,
+) error {
+	session := accessRequest.GetSession().(*psession.PinnipedSession)
+
+	customSessionData := session.Custom
+	if customSessionData == nil || customSessionData.ProviderUID == "" {
+		// This session was not started by an upstream identity provider, e.g. it was started by a client
+		// credentials grant, so there are no transformations configured for it.
+		return rejectTokenExchangeSelectedByAnyTransformations(ctx, accessRequest, idpLister, auditLogger)
+	}
+
+	idp, err := findProviderByNameAndType(customSessionData.ProviderName, customSessionData.ProviderType, customSessionData.ProviderUID, idpLister)
+	if err != nil {
+		// Fail closed, because the token exchange transformations of an identity provider which cannot be found
+		// are unknown, and they might have rejected this token exchange.
+		return errorsx.WithStack(fosite.ErrAccessDenied.
+			WithHint("Identity provider from session data was not found.").
+			WithDebugf("provider name: %q, provider type: %q", customSessionData.ProviderName, customSessionData.ProviderType))
+	}
+
+	pipelines := idp.GetTokenExchangeTransforms()
+	if len(pipelines) == 0 {
+		return nil
+	}
+
+	// The username claim is required by the token exchange handler, so it must be present.
+	username, _ := session.Fosite.Claims.Extra[oidcapi.IDTokenClaimUsername].(string)
+
+	// The groups claim is only present when the groups scope was granted during the original authorization.
+	// The groups are not available to the transformations otherwise.
+	_, hasGroups := session.Fosite.Claims.Extra[oidcapi.IDTokenClaimGroups]
+	var groups []string
+	if hasGroups {
+		groups, err = validateAndGetDownstreamGroupsFromSession(session)
+		if err != nil {
+			return err
+		}
+	}
+
+	requestedAudience := accessRequest.GetRequestForm().Get("audience")
+	clientID := accessRequest.GetClient().GetID()
+
+	authContext := &idtransform.AuthenticationContext{
+		ACR:      session.Fosite.Claims.AuthenticationContextClassReference,
+		AMR:      session.Fosite.Claims.AuthenticationMethodsReferences,
+		Upstream: customSessionData.UpstreamData,
+		Request: idtransform.RequestContext{
+			ClientID: clientID,
+			Scopes:   accessRequest.GetRequestedScopes(),
+			Audience: []string{requestedAudience},
+		},
+	}
+
+	result, err := pipelines.Evaluate(ctx, requestedAudience, clientID, username, groups, authContext)
+	if err != nil {
+		fositeErr := fosite.ErrAccessDenied.
+			WithHint("Token exchange error while applying configured identity transformations.").
+			WithTrace(err).
+			WithDebugf("provider name: %q, provider type: %q", customSessionData.ProviderName, customSessionData.ProviderType)
+		auditLogger.Audit(auditevent.AuthenticationRejectedByTransforms, &plog.AuditParams{
+			ReqCtx:        ctx,
+			Session:       accessRequest,
+			KeysAndValues: []any{"reason", fositeErr.HintField},
+		})
+		return errorsx.WithStack(fositeErr)
+	}
+
+	if !result.AuthenticationAllowed {
+		fositeErr := fosite.ErrAccessDenied.
+			WithHintf("Token exchange rejected by configured identity policy: %s.", result.RejectedAuthenticationMessage).
+			WithDebugf("provider name: %q, provider type: %q", customSessionData.ProviderName, customSessionData.ProviderType)
+		auditLogger.Audit(auditevent.AuthenticationRejectedByTransforms, &plog.AuditParams{
+			ReqCtx:        ctx,
+			Session:       accessRequest,
+			KeysAndValues: []any{"reason", fositeErr.HintField},
+		})
+		return errorsx.WithStack(fositeErr)
+	}
+
+	plog.Debug("token exchange identity transformations successfully applied",
+		"requestedAudience", requestedAudience,
+		"clientID", clientID,
+		"originalUsername", username,
+		"newUsername", result.Username,
+		"originalGroups", groups,
+		"newGroups", result.Groups,
+	)
+
+	customSessionData.Username = result.Username
+	session.Fosite.Claims.Extra[oidcapi.IDTokenClaimUsername] = result.Username
+	if hasGroups {
+		session.Fosite.Claims.Extra[oidcapi.IDTokenClaimGroups] = result.Groups
+	}
+
+	if len(result.AdditionalClaims) > 0 {
+		// Claims returned by the token exchange transformations replace any claims of the same name which were
+		// already in the session. The claims from the session have been through a JSON round trip, so they are
+		// a map[string]any.
+		previousClaims, _ := session.Fosite.Claims.Extra[oidcapi.IDTokenClaimAdditionalClaims].(map[string]any)
+		additionalClaims := maps.Clone(previousClaims)
+		if additionalClaims == nil {
+			additionalClaims = map[string]any{}
+		}
+		maps.Copy(additionalClaims, result.AdditionalClaims)
+		session.Fosite.Claims.Extra[oidcapi.IDTokenClaimAdditionalClaims] = additionalClaims
+	}
+
+	return nil
+}
+
+// rejectTokenExchangeSelectedByAnyTransformations returns an error when the token exchange of a session which was not
+// started by an upstream identity provider is selected by the token exchange transformation pipelines of any identity
+// provider of the FederationDomain. Those pipelines might be policies which control which identities may have an ID
+// token for the requested audience, but they cannot be evaluated for this session, so fail closed. Token exchanges
+// which are not selected by any pipeline are allowed.
+func rejectTokenExchangeSelectedByAnyTransformations(
+	ctx context.Context,
+	accessRequest fosite.AccessRequester,
+	idpLister federationdomainproviders.FederationDomainIdentityProvidersListerI,
+	auditLogger plog.AuditLogger,
+) error {
+	requestedAudience := accessRequest.GetRequestForm().Get("audience")
+	clientID := accessRequest.GetClient().GetID()
+
+	for _, idp := range idpLister.GetIdentityProviders() {
+		for _, pipeline := range idp.GetTokenExchangeTransforms() {
+			if !pipeline.Matches(requestedAudience, clientID) {
+				continue
+			}
+			fositeErr := fosite.ErrAccessDenied.
+				WithHint("Token exchange rejected because configured identity transformations apply to it, but the session was not started by an identity provider.").
+				WithDebugf("provider name: %q, provider type: %q", idp.GetProvider().GetResourceName(), idp.GetSessionProviderType())
+			auditLogger.Audit(auditevent.AuthenticationRejectedByTransforms, &plog.AuditParams{
+				ReqCtx:        ctx,
+				Session:       accessRequest,
+				KeysAndValues: []any{"reason", fositeErr.HintField},
+			})
+			return errorsx.WithStack(fositeErr)
+		}
+	}
+
+	return nil
+}
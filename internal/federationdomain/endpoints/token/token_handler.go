@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package token provides a handler for the OIDC token endpoint.
@@ -9,6 +9,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"slices"
 	"time"
@@ -36,6 +37,7 @@ func paramsSafeToLog() sets.Set[string] {
 	return sets.New(
 		// Standard params from https://openid.net/specs/openid-connect-core-1_0.html for authcode and refresh grants.
 		// Redacting code, client_secret, refresh_token, and PKCE code_verifier params.
+		// Also redacting the device_code param of the device authorization grant from RFC 8628.
 		"grant_type", "client_id", "redirect_uri", "scope",
 		// Token exchange params from https://datatracker.ietf.org/doc/html/rfc8693#section-2.1.
 		// Redact subject_token and actor_token.
@@ -87,9 +89,34 @@ func NewHandler(
 			}
 		}
 
-		// When we are in the authorization code flow, check if we have any warnings that previous handlers want us
-		// to send to the client to be printed on the CLI.
-		if accessRequest.GetGrantTypes().ExactOne(oidcapi.GrantTypeAuthorizationCode) {
+		// Check if we are performing a token exchange.
+		if accessRequest.GetGrantTypes().ExactOne(oidcapi.GrantTypeTokenExchange) {
+			// The above call to NewAccessRequest has validated the token exchange and has loaded a clone of the
+			// session from storage into the accessRequest variable. Changes to this session will only impact the
+			// token which is issued by this token exchange.
+			err = applyTokenExchangeTransformations(r.Context(), accessRequest, idpLister, auditLogger)
+			if err != nil {
+				plog.Info("token exchange error", oidc.FositeErrorForLog(err)...)
+				oauthHelper.WriteAccessError(r.Context(), w, accessRequest, err)
+				return nil
+			}
+		}
+
+		// Check if we are performing a client credentials grant.
+		if accessRequest.GetGrantTypes().ExactOne(oidcapi.GrantTypeClientCredentials) {
+			// There is no stored session for this grant, so start a new session for the client's service identity.
+			err = startClientCredentialsSession(r.Context(), accessRequest, auditLogger)
+			if err != nil {
+				plog.Info("client credentials grant error", oidc.FositeErrorForLog(err)...)
+				oauthHelper.WriteAccessError(r.Context(), w, accessRequest, err)
+				return nil
+			}
+		}
+
+		// When we are in the authorization code flow or the device authorization flow, check if we have any warnings
+		// that previous handlers want us to send to the client to be printed on the CLI.
+		if accessRequest.GetGrantTypes().ExactOne(oidcapi.GrantTypeAuthorizationCode) ||
+			accessRequest.GetGrantTypes().ExactOne(oidcapi.GrantTypeDeviceCode) {
 			storedSession := accessRequest.GetSession().(*psession.PinnipedSession)
 			customSessionData := storedSession.Custom
 			if customSessionData != nil {
@@ -245,10 +272,29 @@ func upstreamRefresh(
 		refreshedIdentity.UpstreamGroups = oldUntransformedGroups
 	}
 
-	refreshedTransformedUsername, refreshedTransformedGroups, fositeErr := applyIdentityTransformationsDuringRefresh(ctx,
+	if refreshedIdentity.UpstreamData != nil {
+		// Replace the old value for the raw upstream data in the user's session with the new value.
+		session.Custom.UpstreamData = refreshedIdentity.UpstreamData
+	}
+
+	// How the user authenticated does not change during a refresh, since the user does not authenticate again,
+	// so use the values which were determined during the user's initial login.
+	authContext := &idtransform.AuthenticationContext{
+		ACR:      session.Fosite.Claims.AuthenticationContextClassReference,
+		AMR:      session.Fosite.Claims.AuthenticationMethodsReferences,
+		Upstream: session.Custom.UpstreamData,
+		Request: idtransform.RequestContext{
+			ClientID: accessRequest.GetClient().GetID(),
+			Scopes:   accessRequest.GetRequestedScopes(),
+			Audience: accessRequest.GetRequestedAudience(),
+		},
+	}
+
+	refreshedTransformedUsername, refreshedTransformedGroups, refreshedTransformedClaims, fositeErr := applyIdentityTransformationsDuringRefresh(ctx,
 		idp.GetTransforms(),
 		refreshedIdentity.UpstreamUsername,
 		refreshedIdentity.UpstreamGroups,
+		authContext,
 		providerName,
 		providerType,
 	)
@@ -276,6 +322,16 @@ func upstreamRefresh(
 		session.Fosite.Claims.Extra[oidcapi.IDTokenClaimGroups] = refreshedTransformedGroups
 	}
 
+	if refreshedIdentity.DownstreamAdditionalClaims != nil || len(session.Custom.TransformedClaimNames) > 0 || len(refreshedTransformedClaims) > 0 {
+		// Replace the old value for the downstream additional claims in the user's session with the new value.
+		refreshedAdditionalClaims := refreshedDownstreamAdditionalClaims(session, refreshedIdentity.DownstreamAdditionalClaims, refreshedTransformedClaims)
+		if len(refreshedAdditionalClaims) > 0 {
+			session.Fosite.Claims.Extra[oidcapi.IDTokenClaimAdditionalClaims] = refreshedAdditionalClaims
+		} else {
+			delete(session.Fosite.Claims.Extra, oidcapi.IDTokenClaimAdditionalClaims)
+		}
+	}
+
 	auditLogger.Audit(auditevent.SessionRefreshed, &plog.AuditParams{
 		ReqCtx:  ctx,
 		Session: accessRequest,
@@ -288,6 +344,39 @@ func upstreamRefresh(
 	return nil
 }
 
+// refreshedDownstreamAdditionalClaims returns the new downstream additional claims for the session. When the upstream
+// refresh did not return new additional claims from the upstream identity provider, then the previous claims from the
+// upstream identity provider are kept. The claims which were returned by the identity transformations during the
+// previous login or refresh are always replaced by the claims which were returned by the identity transformations
+// during this refresh. As a side effect, this updates the names of the transformed claims in the session.
+func refreshedDownstreamAdditionalClaims(
+	session *psession.PinnipedSession,
+	refreshedUpstreamClaims map[string]any,
+	refreshedTransformedClaims map[string]any,
+) map[string]any {
+	additionalClaims := maps.Clone(refreshedUpstreamClaims)
+	if additionalClaims == nil {
+		// The previous claims from the session have been through a JSON round trip, so they are a map[string]any.
+		previousClaims, _ := session.Fosite.Claims.Extra[oidcapi.IDTokenClaimAdditionalClaims].(map[string]any)
+		additionalClaims = maps.Clone(previousClaims)
+		for _, claimName := range session.Custom.TransformedClaimNames {
+			delete(additionalClaims, claimName)
+		}
+	}
+	if additionalClaims == nil {
+		additionalClaims = map[string]any{}
+	}
+
+	maps.Copy(additionalClaims, refreshedTransformedClaims)
+
+	session.Custom.TransformedClaimNames = nil
+	if len(refreshedTransformedClaims) > 0 {
+		session.Custom.TransformedClaimNames = slices.Sorted(maps.Keys(refreshedTransformedClaims))
+	}
+
+	return additionalClaims
+}
+
 // findProviderByNameAndType finds the IDP by its resource name and IDP type,
 // and validates that its resource UID matches the expected UID.
 func findProviderByNameAndType(
@@ -325,24 +414,25 @@ func applyIdentityTransformationsDuringRefresh(
 	transforms *idtransform.TransformationPipeline,
 	upstreamUsername string,
 	upstreamGroups []string,
+	authContext *idtransform.AuthenticationContext,
 	providerName string,
 	providerType psession.ProviderType,
-) (string, []string, *fosite.RFC6749Error) {
-	transformationResult, err := transforms.Evaluate(ctx, upstreamUsername, upstreamGroups)
+) (string, []string, map[string]any, *fosite.RFC6749Error) {
+	transformationResult, err := transforms.Evaluate(ctx, upstreamUsername, upstreamGroups, authContext)
 	if err != nil {
-		return "", nil, errUpstreamRefreshError().WithHintf(
+		return "", nil, nil, errUpstreamRefreshError().WithHintf(
 			"Upstream refresh error while applying configured identity transformations.").
 			WithTrace(err).
 			WithDebugf("provider name: %q, provider type: %q", providerName, providerType)
 	}
 
 	if !transformationResult.AuthenticationAllowed {
-		return "", nil, errUpstreamRefreshError().WithHintf(
+		return "", nil, nil, errUpstreamRefreshError().WithHintf(
 			"Upstream refresh rejected by configured identity policy: %s.", transformationResult.RejectedAuthenticationMessage).
 			WithDebugf("provider name: %q, provider type: %q", providerName, providerType)
 	}
 
-	return transformationResult.Username, transformationResult.Groups, nil
+	return transformationResult.Username, transformationResult.Groups, transformationResult.AdditionalClaims, nil
 }
 
 func validateAndGetDownstreamGroupsFromSession(session *psession.PinnipedSession) ([]string, error) {
@@ -62,6 +62,7 @@ import (
 	"go.pinniped.dev/internal/fositestoragei"
 	"go.pinniped.dev/internal/here"
 	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/idtransform"
 	"go.pinniped.dev/internal/oidcclientsecretstorage"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/psession"
@@ -1173,9 +1174,46 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 		}
 	}
 
+	const (
+		oidcUpstreamName        = "some-oidc-idp"
+		oidcUpstreamResourceUID = "oidc-resource-uid"
+		oidcUpstreamType        = "oidc"
+	)
+
+	upstreamOIDCCustomSessionData := &psession.CustomSessionData{
+		Username:         goodUsername,
+		UpstreamUsername: goodUsername,
+		UpstreamGroups:   goodGroups,
+		ProviderName:     oidcUpstreamName,
+		ProviderUID:      oidcUpstreamResourceUID,
+		ProviderType:     oidcUpstreamType,
+		OIDC: &psession.OIDCSessionData{
+			UpstreamSubject: goodUpstreamSubject,
+			UpstreamIssuer:  goodIssuer,
+		},
+	}
+
+	successfulAuthCodeExchangeWithUpstreamOIDCSession := successfulAuthCodeExchange
+	successfulAuthCodeExchangeWithUpstreamOIDCSession.wantCustomSessionDataStored = upstreamOIDCCustomSessionData
+
+	doValidAuthCodeExchangeWithUpstreamOIDCSession := authcodeExchangeInputs{
+		modifyAuthRequest: func(authRequest *http.Request) {
+			authRequest.Form.Set("scope", "openid pinniped:request-audience username groups")
+		},
+		customSessionData: upstreamOIDCCustomSessionData,
+		want:              successfulAuthCodeExchangeWithUpstreamOIDCSession,
+	}
+
+	upstreamOIDCIdentityProviderBuilder := func() *oidctestutil.TestUpstreamOIDCIdentityProviderBuilder {
+		return oidctestutil.NewTestUpstreamOIDCIdentityProviderBuilder().
+			WithName(oidcUpstreamName).
+			WithResourceUID(oidcUpstreamResourceUID)
+	}
+
 	tests := []struct {
 		name string
 
+		idps                 *testidplister.UpstreamIDPListerBuilder
 		authcodeExchange     authcodeExchangeInputs
 		modifyRequestParams  func(t *testing.T, params url.Values)
 		modifyRequestHeaders func(r *http.Request)
@@ -1187,6 +1225,12 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 		wantErrorType         string
 		wantErrorDescContains string
 		wantAuditLogs         func(sessionID string, idToken string) []testutil.WantedAuditLog
+
+		// When the token exchange transforms the identity, these are the expected claims of the new token.
+		// Otherwise, the new token is expected to have the same identity as the token from the authcode exchange.
+		wantTokenExchangeUsername         string
+		wantTokenExchangeGroups           []string
+		wantTokenExchangeAdditionalClaims map[string]any
 	}{
 		{
 			name:              "happy path",
@@ -1194,6 +1238,86 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 			requestedAudience: "some-workload-cluster",
 			wantStatus:        http.StatusOK,
 		},
+		{
+			name: "happy path with token exchange transformations for the requested audience",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(
+				upstreamOIDCIdentityProviderBuilder().WithTokenExchangeTransformsForFederationDomain(
+					idtransform.NewTokenExchangePipeline([]string{"some-other-workload-cluster"}, nil,
+						transformtestutil.NewRejectAllAuthPipeline(t)),
+					idtransform.NewTokenExchangePipeline([]string{"some-workload-cluster"}, nil,
+						transformtestutil.NewPipeline(t, []celtransformer.CELTransformation{
+							&celtransformer.UsernameTransformation{Expression: `"prod:" + username`},
+							&celtransformer.GroupsTransformation{Expression: `groups.filter(g, g == "group1").map(g, "prod:" + g)`},
+						})),
+				).Build()),
+			authcodeExchange:          doValidAuthCodeExchangeWithUpstreamOIDCSession,
+			requestedAudience:         "some-workload-cluster",
+			wantStatus:                http.StatusOK,
+			wantTokenExchangeUsername: "prod:" + goodUsername,
+			wantTokenExchangeGroups:   []string{"prod:group1"},
+		},
+		{
+			name: "happy path with token exchange transformations for the client which add claims",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(
+				upstreamOIDCIdentityProviderBuilder().WithTokenExchangeTransformsForFederationDomain(
+					idtransform.NewTokenExchangePipeline(nil, []string{"some-other-client"},
+						transformtestutil.NewRejectAllAuthPipeline(t)),
+					idtransform.NewTokenExchangePipeline(nil, []string{pinnipedCLIClientID},
+						transformtestutil.NewPipeline(t, []celtransformer.CELTransformation{
+							&celtransformer.ClaimsTransformation{Expression: `{"cluster": request.audience[0], "client": request.clientID}`},
+						})),
+				).Build()),
+			authcodeExchange:          doValidAuthCodeExchangeWithUpstreamOIDCSession,
+			requestedAudience:         "some-workload-cluster",
+			wantStatus:                http.StatusOK,
+			wantTokenExchangeUsername: goodUsername,
+			wantTokenExchangeGroups:   goodGroups,
+			wantTokenExchangeAdditionalClaims: map[string]any{
+				"cluster": "some-workload-cluster",
+				"client":  pinnipedCLIClientID,
+			},
+		},
+		{
+			name: "token exchange transformations for the requested audience reject the token exchange",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(
+				upstreamOIDCIdentityProviderBuilder().WithTokenExchangeTransformsForFederationDomain(
+					idtransform.NewTokenExchangePipeline([]string{"some-workload-cluster"}, nil,
+						transformtestutil.NewRejectAllAuthPipeline(t)),
+				).Build()),
+			authcodeExchange:      doValidAuthCodeExchangeWithUpstreamOIDCSession,
+			requestedAudience:     "some-workload-cluster",
+			wantStatus:            http.StatusForbidden,
+			wantErrorType:         "access_denied",
+			wantErrorDescContains: "Token exchange rejected by configured identity policy: authentication was rejected by a configured policy.",
+			wantAuditLogs: func(sessionID string, idToken string) []testutil.WantedAuditLog {
+				return []testutil.WantedAuditLog{
+					testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+						"params": map[string]any{
+							"audience":             "some-workload-cluster",
+							"client_id":            "pinniped-cli",
+							"grant_type":           "urn:ietf:params:oauth:grant-type:token-exchange",
+							"requested_token_type": "urn:ietf:params:oauth:token-type:jwt",
+							"subject_token":        "redacted",
+							"subject_token_type":   "urn:ietf:params:oauth:token-type:access_token",
+						},
+					}),
+					testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+					testutil.WantAuditLog("Authentication Rejected By Transforms", map[string]any{
+						"sessionID": sessionID,
+						"reason":    "Token exchange rejected by configured identity policy: authentication was rejected by a configured policy.",
+					}),
+				}
+			},
+		},
+		{
+			name:                  "identity provider from the session was not found when there might be token exchange transformations",
+			idps:                  testidplister.NewUpstreamIDPListerBuilder(),
+			authcodeExchange:      doValidAuthCodeExchangeWithUpstreamOIDCSession,
+			requestedAudience:     "some-workload-cluster",
+			wantStatus:            http.StatusForbidden,
+			wantErrorType:         "access_denied",
+			wantErrorDescContains: "Identity provider from session data was not found.",
+		},
 		{
 			name: "happy path with additional claims",
 			authcodeExchange: authcodeExchangeInputs{
@@ -1834,9 +1958,14 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			// Authcode exchange doesn't use the upstream provider cache, so just pass an empty cache.
+			// Authcode exchange doesn't use the upstream provider cache, but token exchange may use it to find the
+			// token exchange transformations, so pass an empty cache unless the test needs one.
+			idps := test.idps
+			if idps == nil {
+				idps = testidplister.NewUpstreamIDPListerBuilder()
+			}
 			subject, rsp, _, _, secrets, oauthStore, actualAuditLog, actualSessionID := exchangeAuthcodeForTokens(t,
-				test.authcodeExchange, testidplister.NewUpstreamIDPListerBuilder().BuildFederationDomainIdentityProvidersListerFinder(), test.kubeResources)
+				test.authcodeExchange, idps.BuildFederationDomainIdentityProvidersListerFinder(), test.kubeResources)
 			var parsedAuthcodeExchangeResponseBody map[string]any
 			require.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &parsedAuthcodeExchangeResponseBody))
 
@@ -1918,12 +2047,21 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 			var tokenClaims map[string]any
 			require.NoError(t, json.Unmarshal(parsedJWT.UnsafePayloadWithoutVerification(), &tokenClaims))
 
+			wantUsername := test.authcodeExchange.want.wantUsername
+			wantGroups := test.authcodeExchange.want.wantGroups
+			wantAdditionalClaims := test.authcodeExchange.want.wantAdditionalClaims
+			if test.wantTokenExchangeUsername != "" {
+				wantUsername = test.wantTokenExchangeUsername
+				wantGroups = test.wantTokenExchangeGroups
+				wantAdditionalClaims = test.wantTokenExchangeAdditionalClaims
+			}
+
 			// Make sure that these are the only fields in the token.
-			idTokenFields := []string{"sub", "aud", "iss", "jti", "auth_time", "exp", "iat", "rat", "username", "azp"}
-			if test.authcodeExchange.want.wantGroups != nil {
+			idTokenFields := []string{"sub", "aud", "iss", "jti", "auth_time", "exp", "iat", "rat", "username", "azp", "sid"}
+			if wantGroups != nil {
 				idTokenFields = append(idTokenFields, "groups")
 			}
-			if len(test.authcodeExchange.want.wantAdditionalClaims) > 0 {
+			if len(wantAdditionalClaims) > 0 {
 				idTokenFields = append(idTokenFields, "additionalClaims")
 			}
 			require.ElementsMatch(t, idTokenFields, getMapKeys(tokenClaims))
@@ -1937,21 +2075,22 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 			require.Len(t, tokenClaims["aud"], 1)
 			require.Contains(t, tokenClaims["aud"], test.requestedAudience)
 			require.Equal(t, test.authcodeExchange.want.wantClientID, tokenClaims["azp"])
+			require.NotEmpty(t, tokenClaims["sid"])
 			require.Equal(t, goodSubject, tokenClaims["sub"])
 			require.Equal(t, goodIssuer, tokenClaims["iss"])
-			if test.authcodeExchange.want.wantUsername != "" {
-				require.Equal(t, test.authcodeExchange.want.wantUsername, tokenClaims["username"])
+			if wantUsername != "" {
+				require.Equal(t, wantUsername, tokenClaims["username"])
 			} else {
 				require.Nil(t, tokenClaims["username"])
 			}
-			if test.authcodeExchange.want.wantGroups != nil {
-				require.Equal(t, toSliceOfInterface(test.authcodeExchange.want.wantGroups), tokenClaims["groups"])
+			if wantGroups != nil {
+				require.Equal(t, toSliceOfInterface(wantGroups), tokenClaims["groups"])
 			} else {
 				require.Nil(t, tokenClaims["groups"])
 			}
 
-			if len(test.authcodeExchange.want.wantAdditionalClaims) > 0 {
-				require.Equal(t, test.authcodeExchange.want.wantAdditionalClaims, tokenClaims["additionalClaims"])
+			if len(wantAdditionalClaims) > 0 {
+				require.Equal(t, wantAdditionalClaims, tokenClaims["additionalClaims"])
 			}
 			additionalClaims, ok := tokenClaims["additionalClaims"].(map[string]any)
 			if ok && tokenClaims["additionalClaims"] != nil {
@@ -1963,7 +2102,7 @@ func TestTokenEndpointTokenExchange(t *testing.T) { // tests for grant_type "urn
 			requireClaimsAreEqual(t, "sub", claimsOfFirstIDToken, tokenClaims)       // subject
 			requireClaimsAreEqual(t, "rat", claimsOfFirstIDToken, tokenClaims)       // requested at
 			requireClaimsAreEqual(t, "auth_time", claimsOfFirstIDToken, tokenClaims) // auth time
-			if len(test.authcodeExchange.want.wantAdditionalClaims) > 0 {
+			if test.wantTokenExchangeUsername == "" && len(wantAdditionalClaims) > 0 {
 				requireClaimsAreEqual(t, "additionalClaims", claimsOfFirstIDToken, tokenClaims)
 			}
 
@@ -2120,15 +2259,30 @@ func TestRefreshGrant(t *testing.T) {
 		}
 	}
 
+	// The claims from the upstream refresh are saved in the session as the new raw upstream identity data.
+	happyOIDCUpstreamRefreshedUpstreamData := map[string]any{"sub": goodUpstreamSubject}
+
 	upstreamOIDCCustomSessionDataWithNewRefreshToken := func(newRefreshToken string) *psession.CustomSessionData {
 		sessionData := initialUpstreamOIDCRefreshTokenCustomSessionData()
 		sessionData.OIDC.UpstreamRefreshToken = newRefreshToken
+		sessionData.UpstreamData = happyOIDCUpstreamRefreshedUpstreamData
 		return sessionData
 	}
 
 	upstreamOIDCCustomSessionDataWithNewRefreshTokenWithUsername := func(newRefreshToken string, downstreamUsername string) *psession.CustomSessionData {
 		sessionData := initialUpstreamOIDCRefreshTokenCustomSessionDataWithUsername(downstreamUsername)
 		sessionData.OIDC.UpstreamRefreshToken = newRefreshToken
+		sessionData.UpstreamData = happyOIDCUpstreamRefreshedUpstreamData
+		return sessionData
+	}
+
+	withUpstreamDataInCustomSessionData := func(sessionData *psession.CustomSessionData, upstreamData map[string]any) *psession.CustomSessionData {
+		sessionData.UpstreamData = upstreamData
+		return sessionData
+	}
+
+	withTransformedClaimNamesInCustomSessionData := func(sessionData *psession.CustomSessionData, transformedClaimNames ...string) *psession.CustomSessionData {
+		sessionData.TransformedClaimNames = transformedClaimNames
 		return sessionData
 	}
 
@@ -2221,6 +2375,12 @@ func TestRefreshGrant(t *testing.T) {
 		return want
 	}
 
+	happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccessWithAdditionalClaims := func(wantCustomSessionDataStored *psession.CustomSessionData, wantAdditionalClaims map[string]any) tokenEndpointResponseExpectedValues {
+		want := happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccess(wantCustomSessionDataStored)
+		want.wantAdditionalClaims = wantAdditionalClaims
+		return want
+	}
+
 	withWantDynamicClientID := func(w tokenEndpointResponseExpectedValues) tokenEndpointResponseExpectedValues {
 		w.wantClientID = dynamicClientID
 		return w
@@ -2281,6 +2441,12 @@ func TestRefreshGrant(t *testing.T) {
 		return want
 	}
 
+	happyRefreshTokenResponseForLDAPWithAdditionalClaims := func(wantCustomSessionDataStored *psession.CustomSessionData, wantAdditionalClaims map[string]any) tokenEndpointResponseExpectedValues {
+		want := happyRefreshTokenResponseForLDAP(wantCustomSessionDataStored)
+		want.wantAdditionalClaims = wantAdditionalClaims
+		return want
+	}
+
 	happyRefreshTokenResponseForLDAPWithUsernameAndGroups := func(wantCustomSessionDataStored *psession.CustomSessionData, wantDownstreamUsername string, wantDownstreamGroups []string) tokenEndpointResponseExpectedValues {
 		want := happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccessWithUsernameAndGroups(wantCustomSessionDataStored, wantDownstreamUsername, wantDownstreamGroups)
 		want.wantLDAPUpstreamRefreshCall = happyLDAPUpstreamRefreshCall()
@@ -2345,6 +2511,14 @@ func TestRefreshGrant(t *testing.T) {
 		return &copyOfCustomSession
 	}
 
+	happyLDAPCustomSessionDataWithAdditionalClaims := func(additionalClaims map[string][]string) *psession.CustomSessionData {
+		copyOfCustomSession := *happyLDAPCustomSessionData
+		copyOfLDAP := *(happyLDAPCustomSessionData.LDAP)
+		copyOfLDAP.AdditionalClaims = additionalClaims
+		copyOfCustomSession.LDAP = &copyOfLDAP
+		return &copyOfCustomSession
+	}
+
 	happyAuthcodeExchangeInputsForOIDCUpstream := authcodeExchangeInputs{
 		modifyAuthRequest: func(r *http.Request) { r.Form.Set("scope", "openid offline_access username groups") },
 		customSessionData: initialUpstreamOIDCRefreshTokenCustomSessionData(),
@@ -2371,6 +2545,19 @@ func TestRefreshGrant(t *testing.T) {
 
 	prefixUsernameAndGroupsPipeline := transformtestutil.NewPrefixingPipeline(t, transformationUsernamePrefix, transformationGroupsPrefix)
 	rejectAuthPipeline := transformtestutil.NewRejectAllAuthPipeline(t)
+	addClaimsPipeline := transformtestutil.NewPipeline(t, []celtransformer.CELTransformation{
+		&celtransformer.ClaimsTransformation{Expression: `{"email": upstream.email, "cost_center": "cc-" + username}`},
+	})
+	// The upstream data is stored in the session as JSON, so the attribute values are read back as []any.
+	ldapUpstreamDataWithDepartment := func(department string) map[string]any {
+		return map[string]any{
+			"dn":         ldapUpstreamDN,
+			"attributes": map[string]any{"department": []any{department}},
+		}
+	}
+	addLDAPAttributeClaimsPipeline := transformtestutil.NewPipeline(t, []celtransformer.CELTransformation{
+		&celtransformer.ClaimsTransformation{Expression: `{"department": upstream.attributes.department[0]}`},
+	})
 
 	tests := []struct {
 		name                      string
@@ -2518,7 +2705,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGroups:                        testutil.AddPrefixToEach(transformationGroupsPrefix, goodGroups),
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithRefreshTokenWithoutIDToken(), false),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshTokenWithUsername(oidcUpstreamRefreshedRefreshToken, transformationUsernamePrefix+goodUsername),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshTokenWithUsername(oidcUpstreamRefreshedRefreshToken, transformationUsernamePrefix+goodUsername), nil),
 				},
 			},
 		},
@@ -2707,6 +2894,92 @@ func TestRefreshGrant(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "happy path refresh grant with OIDC upstream with identity transformations which return additional claims replaces the previously transformed claims",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(
+				upstreamOIDCIdentityProviderBuilder().WithValidatedAndMergedWithUserInfoTokens(&oidctypes.Token{
+					IDToken: &oidctypes.IDToken{
+						Claims: map[string]any{
+							"sub":   goodUpstreamSubject,
+							"email": "new-email@example.com",
+						},
+					},
+				}).WithRefreshedTokens(refreshedUpstreamTokensWithIDAndRefreshTokens()).
+					WithTransformsForFederationDomain(addClaimsPipeline).Build()),
+			authcodeExchange: authcodeExchangeInputs{
+				customSessionData: withTransformedClaimNamesInCustomSessionData(initialUpstreamOIDCRefreshTokenCustomSessionData(), "email", "stale_claim"),
+				modifyAuthRequest: func(r *http.Request) { r.Form.Set("scope", "openid offline_access username groups") },
+				modifySession: func(session *psession.PinnipedSession) {
+					// The authorization flow would have run the transformation pipeline and merged the transformed claims
+					// into the claims from the upstream, so simulate that by setting the expected result.
+					session.IDTokenClaims().Extra["additionalClaims"] = map[string]any{
+						"upstreamString": "string value",
+						"email":          "old-email@example.com",
+						"stale_claim":    "stale value",
+					}
+				},
+				want: happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccessWithAdditionalClaims(
+					withTransformedClaimNamesInCustomSessionData(initialUpstreamOIDCRefreshTokenCustomSessionData(), "email", "stale_claim"),
+					map[string]any{
+						"upstreamString": "string value",
+						"email":          "old-email@example.com",
+						"stale_claim":    "stale value",
+					},
+				),
+			},
+			refreshRequest: refreshRequestInputs{
+				want: happyRefreshTokenResponseForOpenIDAndOfflineAccessWithAdditionalClaims(
+					withTransformedClaimNamesInCustomSessionData(
+						withUpstreamDataInCustomSessionData(
+							upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+							map[string]any{"sub": goodUpstreamSubject, "email": "new-email@example.com"},
+						),
+						"cost_center", "email",
+					),
+					refreshedUpstreamTokensWithIDAndRefreshTokens(),
+					map[string]any{
+						"upstreamString": "string value", // not returned by the transformations, so it is kept
+						"email":          "new-email@example.com",
+						"cost_center":    "cc-" + goodUsername,
+					},
+				),
+			},
+		},
+		{
+			name: "happy path refresh grant with OIDC upstream with identity transformations which no longer return additional claims removes the previously transformed claims",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(
+				upstreamOIDCIdentityProviderBuilder().WithValidatedAndMergedWithUserInfoTokens(&oidctypes.Token{
+					IDToken: &oidctypes.IDToken{
+						Claims: map[string]any{
+							"sub": goodUpstreamSubject,
+						},
+					},
+				}).WithRefreshedTokens(refreshedUpstreamTokensWithIDAndRefreshTokens()).Build()),
+			authcodeExchange: authcodeExchangeInputs{
+				customSessionData: withTransformedClaimNamesInCustomSessionData(initialUpstreamOIDCRefreshTokenCustomSessionData(), "email"),
+				modifyAuthRequest: func(r *http.Request) { r.Form.Set("scope", "openid offline_access username groups") },
+				modifySession: func(session *psession.PinnipedSession) {
+					session.IDTokenClaims().Extra["additionalClaims"] = map[string]any{
+						"email": "old-email@example.com",
+					}
+				},
+				want: happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccessWithAdditionalClaims(
+					withTransformedClaimNamesInCustomSessionData(initialUpstreamOIDCRefreshTokenCustomSessionData(), "email"),
+					map[string]any{
+						"email": "old-email@example.com",
+					},
+				),
+			},
+			refreshRequest: refreshRequestInputs{
+				want: happyRefreshTokenResponseForOpenIDAndOfflineAccess(
+					withUpstreamDataInCustomSessionData(
+						upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+						happyOIDCUpstreamRefreshedUpstreamData,
+					),
+					refreshedUpstreamTokensWithIDAndRefreshTokens(),
+				),
+			},
+		},
 		{
 			name: "happy path refresh grant with openid scope granted (id token returned) using dynamic client",
 			idps: testidplister.NewUpstreamIDPListerBuilder().WithOIDC(
@@ -2879,7 +3152,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantSuccessBodyFields:             []string{"id_token", "refresh_token", "access_token", "token_type", "expires_in", "scope"},
 					wantRequestedScopes:               []string{"openid", "offline_access", "groups"},
 					wantGrantedScopes:                 []string{"openid", "offline_access", "groups"},
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), map[string]any{"some-claim": "some-value", "sub": goodUpstreamSubject, "username-claim": goodUsername}),
 					wantUsername:                      "",
 					wantGroups:                        goodGroups,
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
@@ -2902,7 +3175,7 @@ func TestRefreshGrant(t *testing.T) {
 			authcodeExchange: happyAuthcodeExchangeInputsForOIDCUpstream,
 			refreshRequest: refreshRequestInputs{
 				want: happyRefreshTokenResponseForOpenIDAndOfflineAccess(
-					upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), map[string]any{"some-claim": "some-value", "sub": goodUpstreamSubject, "username-claim": goodUsername}),
 					refreshedUpstreamTokensWithIDAndRefreshTokens(),
 				),
 			},
@@ -2947,7 +3220,7 @@ func TestRefreshGrant(t *testing.T) {
 							RequireUserInfo:      true,
 						},
 					},
-					wantCustomSessionDataStored: initialUpstreamOIDCAccessTokenCustomSessionData(), // doesn't change when we refresh
+					wantCustomSessionDataStored: withUpstreamDataInCustomSessionData(initialUpstreamOIDCAccessTokenCustomSessionData(), map[string]any{"some-claim": "some-value", "sub": goodUpstreamSubject, "username-claim": goodUsername}), // only the upstream data changes when we refresh
 				},
 			},
 		},
@@ -2982,7 +3255,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGrantedScopes:                 []string{"offline_access", "username", "groups"},
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithRefreshTokenWithoutIDToken(), false),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), nil),
 					wantUsername:                      goodUsername,
 					wantGroups:                        goodGroups,
 				},
@@ -3008,7 +3281,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGroups:                        goodGroups,
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithRefreshTokenWithoutIDToken(), false),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), nil),
 				},
 			},
 		},
@@ -3035,7 +3308,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGroups:                        []string{"new-group1", "new-group2", "new-group3"},
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithIDAndRefreshTokens(), true),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), map[string]any{"sub": goodUpstreamSubject, "my-groups-claim": []any{"new-group1", "new-group2", "new-group3"}}),
 					wantWarnings: []RecordedWarning{
 						{Text: `User "some-username" has been added to the following groups: ["new-group1" "new-group2" "new-group3"]`},
 						{Text: `User "some-username" has been removed from the following groups: ["group1" "groups2"]`},
@@ -3116,7 +3389,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGroups:                        []string{"new-group1", "new-group2", "new-group3"},
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithIDAndRefreshTokens(), true),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), map[string]any{"sub": goodUpstreamSubject, "my-groups-claim": []any{"new-group1", "new-group2", "new-group3"}}),
 					wantWarnings:                      nil, // dynamic clients should not get these warnings which are intended for the pinniped-cli client
 				},
 			},
@@ -3144,7 +3417,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGroups:                        []string{"new-group1", "new-group2", "new-group3"},
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithIDAndRefreshTokens(), true),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), map[string]any{"sub": goodUpstreamSubject, "my-groups-claim": []any{"new-group1", "new-group2", "new-group3"}}),
 					wantWarnings: []RecordedWarning{
 						{Text: `User "some-username" has been added to the following groups: ["new-group1" "new-group2" "new-group3"]`},
 						{Text: `User "some-username" has been removed from the following groups: ["group1" "groups2"]`},
@@ -3175,7 +3448,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGroups:                        []string{}, // the user no longer belongs to any groups
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithIDAndRefreshTokens(), true),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), map[string]any{"sub": goodUpstreamSubject, "my-groups-claim": []any{}}),
 					wantWarnings: []RecordedWarning{
 						{Text: `User "some-username" has been removed from the following groups: ["group1" "groups2"]`},
 					},
@@ -3449,7 +3722,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGroups:                        []string{"new-group1", "new-group2", "new-group3"},
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithIDAndRefreshTokens(), true),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), map[string]any{"sub": goodUpstreamSubject, "my-groups-claim": []any{"new-group1", "new-group2", "new-group3"}}),
 					wantWarnings: []RecordedWarning{
 						{Text: `User "some-username" has been added to the following groups: ["new-group1" "new-group2" "new-group3"]`},
 						{Text: `User "some-username" has been removed from the following groups: ["group1" "groups2"]`},
@@ -3502,7 +3775,7 @@ func TestRefreshGrant(t *testing.T) {
 					wantGroups:                        nil,
 					wantOIDCUpstreamRefreshCall:       happyOIDCUpstreamRefreshCall(),
 					wantUpstreamOIDCValidateTokenCall: happyUpstreamValidateTokenCall(refreshedUpstreamTokensWithIDAndRefreshTokens(), true),
-					wantCustomSessionDataStored:       upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken),
+					wantCustomSessionDataStored:       withUpstreamDataInCustomSessionData(upstreamOIDCCustomSessionDataWithNewRefreshToken(oidcUpstreamRefreshedRefreshToken), map[string]any{"sub": goodUpstreamSubject, "my-groups-claim": []any{"new-group1", "new-group2", "new-group3"}}),
 				},
 			},
 		},
@@ -3644,7 +3917,7 @@ func TestRefreshGrant(t *testing.T) {
 			authcodeExchange: happyAuthcodeExchangeInputsForOIDCUpstream,
 			refreshRequest: refreshRequestInputs{
 				want: happyRefreshTokenResponseForOpenIDAndOfflineAccess(
-					initialUpstreamOIDCRefreshTokenCustomSessionData(), // still has the initial refresh token stored
+					withUpstreamDataInCustomSessionData(initialUpstreamOIDCRefreshTokenCustomSessionData(), happyOIDCUpstreamRefreshedUpstreamData), // still has the initial refresh token stored
 					refreshedUpstreamTokensWithIDTokenWithoutRefreshToken(),
 				),
 			},
@@ -4380,6 +4653,148 @@ func TestRefreshGrant(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "upstream ldap refresh happy path updates the additional claims",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithLDAP(oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+				WithName(ldapUpstreamName).
+				WithResourceUID(ldapUpstreamResourceUID).
+				WithURL(ldapUpstreamURL).
+				WithPerformRefreshGroups(goodGroups).
+				WithPerformRefreshAdditionalClaims(map[string][]string{
+					"email":   {"new-email@example.com"},
+					"aliases": {"alias1@example.com", "alias2@example.com"},
+				}).
+				Build(),
+			),
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: func(r *http.Request) { r.Form.Set("scope", "openid offline_access username groups") },
+				customSessionData: happyLDAPCustomSessionDataWithAdditionalClaims(map[string][]string{
+					"email":      {"old-email@example.com"},
+					"employeeID": {"12345"},
+				}),
+				modifySession: func(session *psession.PinnipedSession) {
+					// The authorization flow would have put the additional claims into the ID token claims.
+					session.IDTokenClaims().Extra["additionalClaims"] = map[string]any{
+						"email":      "old-email@example.com",
+						"employeeID": "12345",
+					}
+				},
+				want: happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccessWithAdditionalClaims(
+					happyLDAPCustomSessionDataWithAdditionalClaims(map[string][]string{
+						"email":      {"old-email@example.com"},
+						"employeeID": {"12345"},
+					}),
+					map[string]any{
+						"email":      "old-email@example.com",
+						"employeeID": "12345",
+					},
+				),
+			},
+			refreshRequest: refreshRequestInputs{
+				want: happyRefreshTokenResponseForLDAPWithAdditionalClaims(
+					happyLDAPCustomSessionDataWithAdditionalClaims(map[string][]string{
+						"email":   {"new-email@example.com"},
+						"aliases": {"alias1@example.com", "alias2@example.com"},
+					}),
+					map[string]any{
+						"email":   "new-email@example.com",
+						"aliases": []any{"alias1@example.com", "alias2@example.com"},
+					},
+				),
+			},
+		},
+		{
+			name: "upstream ldap refresh happy path with identity transformations which read the refreshed upstream attributes",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithLDAP(oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+				WithName(ldapUpstreamName).
+				WithResourceUID(ldapUpstreamResourceUID).
+				WithURL(ldapUpstreamURL).
+				WithPerformRefreshGroups(goodGroups).
+				WithPerformRefreshUpstreamData(map[string]any{
+					"dn":         ldapUpstreamDN,
+					"attributes": map[string][]string{"department": {"new-department"}},
+				}).
+				WithTransformsForFederationDomain(addLDAPAttributeClaimsPipeline).
+				Build(),
+			),
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: func(r *http.Request) { r.Form.Set("scope", "openid offline_access username groups") },
+				customSessionData: withTransformedClaimNamesInCustomSessionData(
+					withUpstreamDataInCustomSessionData(
+						happyLDAPCustomSessionDataWithUsername(goodUsername),
+						ldapUpstreamDataWithDepartment("old-department"),
+					),
+					"department",
+				),
+				modifySession: func(session *psession.PinnipedSession) {
+					// The authorization flow would have run the transformation pipeline on the upstream data from login.
+					session.IDTokenClaims().Extra["additionalClaims"] = map[string]any{
+						"department": "old-department",
+					}
+				},
+				want: happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccessWithAdditionalClaims(
+					withTransformedClaimNamesInCustomSessionData(
+						withUpstreamDataInCustomSessionData(
+							happyLDAPCustomSessionDataWithUsername(goodUsername),
+							ldapUpstreamDataWithDepartment("old-department"),
+						),
+						"department",
+					),
+					map[string]any{
+						"department": "old-department",
+					},
+				),
+			},
+			refreshRequest: refreshRequestInputs{
+				want: happyRefreshTokenResponseForLDAPWithAdditionalClaims(
+					withTransformedClaimNamesInCustomSessionData(
+						withUpstreamDataInCustomSessionData(
+							happyLDAPCustomSessionDataWithUsername(goodUsername),
+							ldapUpstreamDataWithDepartment("new-department"),
+						),
+						"department",
+					),
+					map[string]any{
+						"department": "new-department",
+					},
+				),
+			},
+		},
+		{
+			name: "upstream ldap refresh happy path removes the additional claims when they are no longer found",
+			idps: testidplister.NewUpstreamIDPListerBuilder().WithLDAP(oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+				WithName(ldapUpstreamName).
+				WithResourceUID(ldapUpstreamResourceUID).
+				WithURL(ldapUpstreamURL).
+				WithPerformRefreshGroups(goodGroups).
+				Build(),
+			),
+			authcodeExchange: authcodeExchangeInputs{
+				modifyAuthRequest: func(r *http.Request) { r.Form.Set("scope", "openid offline_access username groups") },
+				customSessionData: happyLDAPCustomSessionDataWithAdditionalClaims(map[string][]string{
+					"email": {"old-email@example.com"},
+				}),
+				modifySession: func(session *psession.PinnipedSession) {
+					// The authorization flow would have put the additional claims into the ID token claims.
+					session.IDTokenClaims().Extra["additionalClaims"] = map[string]any{
+						"email": "old-email@example.com",
+					}
+				},
+				want: happyAuthcodeExchangeTokenResponseForOpenIDAndOfflineAccessWithAdditionalClaims(
+					happyLDAPCustomSessionDataWithAdditionalClaims(map[string][]string{
+						"email": {"old-email@example.com"},
+					}),
+					map[string]any{
+						"email": "old-email@example.com",
+					},
+				),
+			},
+			refreshRequest: refreshRequestInputs{
+				want: happyRefreshTokenResponseForLDAP(
+					happyLDAPCustomSessionData,
+				),
+			},
+		},
 		{
 			name: "upstream ldap refresh happy path with identity transformations which modify the username and group names",
 			idps: testidplister.NewUpstreamIDPListerBuilder().WithLDAP(oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
@@ -5549,6 +5964,9 @@ func simulateAuthEndpointHavingAlreadyRun(
 	// The authorization endpoint sets the authorized party to the client ID of the original requester.
 	session.Fosite.Claims.Extra["azp"] = authRequester.GetClient().GetID()
 
+	// The authorization endpoint sets the session ID to the ID of the original request.
+	session.Fosite.Claims.Extra["sid"] = authRequester.GetID()
+
 	// Allow some tests to further modify the session before it is stored.
 	if modifySession != nil {
 		modifySession(session)
@@ -5785,6 +6203,7 @@ func requireValidStoredRequest(
 		expectedExtra["groups"] = toSliceOfInterface(wantGroups)
 	}
 	expectedExtra["azp"] = wantClientID
+	expectedExtra["sid"] = request.GetID()
 	if len(wantAdditionalClaims) > 0 {
 		expectedExtra["additionalClaims"] = wantAdditionalClaims
 	}
@@ -5908,7 +6327,7 @@ func requireValidIDToken(
 		AdditionalClaims map[string]any `json:"additionalClaims"`
 	}
 
-	idTokenFields := []string{"sub", "aud", "iss", "jti", "auth_time", "exp", "iat", "rat", "azp", "at_hash"}
+	idTokenFields := []string{"sub", "aud", "iss", "jti", "auth_time", "exp", "iat", "rat", "azp", "sid", "at_hash"}
 	if wantNonceValueInIDToken {
 		idTokenFields = append(idTokenFields, "nonce")
 	}
@@ -5936,6 +6355,7 @@ func requireValidIDToken(
 	require.Len(t, claims.Audience, 1)
 	require.Equal(t, wantClientID, claims.Audience[0])
 	require.Equal(t, wantClientID, m["azp"])
+	require.NotEmpty(t, m["sid"])
 	require.Equal(t, goodIssuer, claims.Issuer)
 	require.NotEmpty(t, claims.JTI)
 	require.Equal(t, wantAdditionalClaims, claims.AdditionalClaims)
@@ -0,0 +1,84 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package upstreamlogin contains helpers which are shared by the endpoints that start a browser-based login
+// with an upstream identity provider, i.e. the authorization endpoint and the device verification endpoint.
+package upstreamlogin
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.pinniped.dev/internal/federationdomain/csrftoken"
+	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+)
+
+// ShouldShowIDPChooser returns true when the user should be asked to choose an identity provider, because
+// none was requested and the FederationDomain is not in the backwards compatibility mode with a default IDP.
+func ShouldShowIDPChooser(
+	idpFinder federationdomainproviders.FederationDomainIdentityProvidersFinderI,
+	idpNameQueryParamValue string,
+) bool {
+	clientDidNotRequestSpecificIDP := len(idpNameQueryParamValue) == 0
+	inBackwardsCompatMode := idpFinder.HasDefaultIDP()
+	federationDomainSpecHasSomeValidIDPs := idpFinder.IDPCount() > 0
+
+	return clientDidNotRequestSpecificIDP && !inBackwardsCompatMode && federationDomainSpecHasSomeValidIDPs
+}
+
+// ChooseUpstreamIDP selects an upstream IDP, or returns an error.
+func ChooseUpstreamIDP(idpDisplayName string, idpLister federationdomainproviders.FederationDomainIdentityProvidersFinderI) (
+	resolvedprovider.FederationDomainResolvedIdentityProvider,
+	error,
+) {
+	// When a request is made to the authorization endpoint which does not specify the IDP name, then it might
+	// be an old dynamic client (OIDCClient). We need to make this work, but only in the backwards compatibility case
+	// where there is exactly one IDP defined in the namespace and no IDPs listed on the FederationDomain.
+	// This backwards compatibility mode is handled by FindDefaultIDP().
+	if len(idpDisplayName) == 0 {
+		return idpLister.FindDefaultIDP()
+	}
+	return idpLister.FindUpstreamIDPByDisplayName(idpDisplayName)
+}
+
+// ReadCSRFCookie returns the CSRF value from the request's CSRF cookie, or an empty value when there is no
+// valid CSRF cookie.
+func ReadCSRFCookie(r *http.Request, codec oidc.Decoder) csrftoken.CSRFToken {
+	receivedCSRFCookie, err := r.Cookie(oidc.CSRFCookieName)
+	if err != nil {
+		// Error means that the cookie was not found
+		return ""
+	}
+
+	var csrfFromCookie csrftoken.CSRFToken
+	err = codec.Decode(oidc.CSRFCookieEncodingName, receivedCSRFCookie.Value, &csrfFromCookie)
+	if err != nil {
+		// We can ignore any errors and just make a new cookie. Hopefully this will
+		// make the user experience better if, for example, the server rotated
+		// cookie signing keys and then a user submitted a very old cookie.
+		return ""
+	}
+
+	return csrfFromCookie
+}
+
+// AddCSRFSetCookieHeader sets the CSRF cookie to the given CSRF value.
+func AddCSRFSetCookieHeader(w http.ResponseWriter, csrfValue csrftoken.CSRFToken, codec oidc.Encoder) error {
+	encodedCSRFValue, err := codec.Encode(oidc.CSRFCookieEncodingName, csrfValue)
+	if err != nil {
+		return fmt.Errorf("error encoding CSRF cookie: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidc.CSRFCookieName,
+		Value:    encodedCSRFValue,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   true,
+		Path:     "/",
+	})
+
+	return nil
+}
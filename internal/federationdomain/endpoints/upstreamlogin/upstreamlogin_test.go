@@ -0,0 +1,66 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamlogin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/federationdomain/csrftoken"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+)
+
+func TestCSRFCookie(t *testing.T) {
+	codec := securecookie.New([]byte("fake-hash-secret"), []byte("0123456789ABCDEF"))
+	otherCodec := securecookie.New([]byte("other-hash-secret"), []byte("0123456789ABCDEF"))
+
+	rsp := httptest.NewRecorder()
+	require.NoError(t, AddCSRFSetCookieHeader(rsp, "test-csrf", codec))
+	cookies := rsp.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, oidc.CSRFCookieName, cookies[0].Name)
+	require.True(t, cookies[0].HttpOnly)
+	require.True(t, cookies[0].Secure)
+	require.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	require.Equal(t, "/", cookies[0].Path)
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		codec  oidc.Decoder
+		want   csrftoken.CSRFToken
+	}{
+		{
+			name:   "valid cookie",
+			cookie: cookies[0],
+			codec:  codec,
+			want:   "test-csrf",
+		},
+		{
+			name:   "no cookie",
+			cookie: nil,
+			codec:  codec,
+			want:   "",
+		},
+		{
+			name:   "cookie which cannot be decoded, e.g. after the cookie keys were rotated",
+			cookie: cookies[0],
+			codec:  otherCodec,
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			require.Equal(t, tt.want, ReadCSRFCookie(req, tt.codec))
+		})
+	}
+}
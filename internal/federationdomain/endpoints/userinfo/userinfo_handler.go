@@ -0,0 +1,113 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package userinfo provides a handler for the OIDC UserInfo endpoint.
+package userinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/ory/fosite"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+func paramsSafeToLog() sets.Set[string] {
+	// Redacting the access_token param from https://datatracker.ietf.org/doc/html/rfc6750#section-2.2,
+	// and there are no other params for this endpoint.
+	return sets.New[string]()
+}
+
+// NewHandler returns a http.Handler that serves the UserInfo endpoint from
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo. The bearer access token is validated using
+// the access token storage, and the claims are read from the downstream session. Like in ID tokens, the username
+// and groups claims are only returned when the username and groups scopes were granted.
+func NewHandler(
+	oauthHelper fosite.OAuth2Provider,
+	auditLogger plog.AuditLogger,
+) http.Handler {
+	return httperr.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			return httperr.Newf(http.StatusMethodNotAllowed, "%s (try GET or POST)", r.Method)
+		}
+
+		if err := auditLogger.AuditRequestParams(r, paramsSafeToLog()); err != nil {
+			plog.DebugErr("error parsing userinfo request params", err)
+			return httperr.New(http.StatusBadRequest, "error parsing request params")
+		}
+
+		accessToken := fosite.AccessTokenFromRequest(r)
+		if accessToken == "" {
+			// As described in https://datatracker.ietf.org/doc/html/rfc6750#section-3.1, do not include an
+			// error code when the request lacks any authentication information.
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			return httperr.New(http.StatusUnauthorized, "bearer access token is required")
+		}
+
+		tokenUse, requester, err := oauthHelper.IntrospectToken(r.Context(), accessToken, fosite.AccessToken, psession.NewPinnipedSession())
+		if err != nil || tokenUse != fosite.AccessToken {
+			if err != nil {
+				plog.Info("userinfo request error", oidc.FositeErrorForLog(err)...)
+			}
+			writeBearerError(w, http.StatusUnauthorized, "invalid_token", "The access token is invalid or expired.")
+			return nil
+		}
+
+		// Log sessionID for cross-request correlation purposes.
+		auditLogger.Audit(auditevent.SessionFound, &plog.AuditParams{
+			ReqCtx:  r.Context(),
+			Session: requester,
+		})
+
+		grantedScopes := requester.GetGrantedScopes()
+		if !slices.Contains(grantedScopes, oidcapi.ScopeOpenID) {
+			writeBearerError(w, http.StatusForbidden, "insufficient_scope",
+				fmt.Sprintf("The access token was not granted the %q scope.", oidcapi.ScopeOpenID))
+			return nil
+		}
+
+		session := requester.GetSession().(*psession.PinnipedSession)
+		sessionClaims := session.IDTokenClaims().Extra
+
+		claims := map[string]any{
+			"sub": session.GetSubject(),
+		}
+		if slices.Contains(grantedScopes, oidcapi.ScopeUsername) {
+			if username, ok := sessionClaims[oidcapi.IDTokenClaimUsername]; ok {
+				claims[oidcapi.IDTokenClaimUsername] = username
+			}
+		}
+		if slices.Contains(grantedScopes, oidcapi.ScopeGroups) {
+			if groups, ok := sessionClaims[oidcapi.IDTokenClaimGroups]; ok {
+				claims[oidcapi.IDTokenClaimGroups] = groups
+			}
+		}
+		if additionalClaims, ok := sessionClaims[oidcapi.IDTokenClaimAdditionalClaims]; ok {
+			claims[oidcapi.IDTokenClaimAdditionalClaims] = additionalClaims
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		return json.NewEncoder(w).Encode(claims)
+	})
+}
+
+// writeBearerError writes an error response as described in https://datatracker.ietf.org/doc/html/rfc6750#section-3.
+func writeBearerError(w http.ResponseWriter, status int, errorCode string, description string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=%q, error_description=%q", errorCode, description))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+}
@@ -0,0 +1,382 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package userinfo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	fositejwt "github.com/ory/fosite/token/jwt"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	supervisorfake "go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/fake"
+	"go.pinniped.dev/internal/auditid"
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/federationdomain/oidc"
+	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/federationdomain/strategy"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil"
+)
+
+const (
+	downstreamIssuer = "https://my-downstream-issuer.com/some-path"
+	hmacSecret       = "this needs to be at least 32 characters to meet entropy requirements"
+
+	sessionID = "some-session-id"
+)
+
+// sessionTokens holds the tokens which were issued for a downstream session.
+type sessionTokens struct {
+	accessToken        string
+	expiredAccessToken string
+	refreshToken       string
+}
+
+func TestUserInfoEndpoint(t *testing.T) {
+	invalidTokenAuthenticateHeader := `Bearer error="invalid_token", error_description="The access token is invalid or expired."`
+	invalidTokenBody := `{
+		"error":             "invalid_token",
+		"error_description": "The access token is invalid or expired."
+	}`
+
+	tests := []struct {
+		name             string
+		method           string
+		grantedScopes    []string
+		additionalClaims map[string]any
+		// authHeader returns the value of the Authorization header to send, if any.
+		authHeader func(tokens sessionTokens) string
+		// params returns the query params for a GET, or the form params for a POST.
+		params func(tokens sessionTokens) url.Values
+
+		wantStatus          int
+		wantWWWAuthenticate string
+		wantContentType     string
+		wantBodyJSON        string
+		wantBodyString      string
+		wantCacheControl    string
+		wantAuditLogs       []testutil.WantedAuditLog
+	}{
+		{
+			name:             "GET with all scopes granted",
+			method:           http.MethodGet,
+			grantedScopes:    []string{"openid", "offline_access", "username", "groups"},
+			authHeader:       func(tokens sessionTokens) string { return "Bearer " + tokens.accessToken },
+			wantStatus:       http.StatusOK,
+			wantContentType:  "application/json",
+			wantCacheControl: "no-store",
+			wantBodyJSON: `{
+				"sub":      "some-subject",
+				"username": "some-username",
+				"groups":   ["group1", "group2"]
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name:             "POST with additional claims in the session",
+			method:           http.MethodPost,
+			grantedScopes:    []string{"openid", "offline_access", "username", "groups"},
+			additionalClaims: map[string]any{"upstreamStr": "some-value", "upstreamBool": true},
+			authHeader:       func(tokens sessionTokens) string { return "Bearer " + tokens.accessToken },
+			wantStatus:       http.StatusOK,
+			wantContentType:  "application/json",
+			wantCacheControl: "no-store",
+			wantBodyJSON: `{
+				"sub":              "some-subject",
+				"username":         "some-username",
+				"groups":           ["group1", "group2"],
+				"additionalClaims": {"upstreamStr": "some-value", "upstreamBool": true}
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name:          "POST with the access token in the form body",
+			method:        http.MethodPost,
+			grantedScopes: []string{"openid", "offline_access", "username", "groups"},
+			params: func(tokens sessionTokens) url.Values {
+				return url.Values{"access_token": []string{tokens.accessToken}}
+			},
+			wantStatus:       http.StatusOK,
+			wantContentType:  "application/json",
+			wantCacheControl: "no-store",
+			wantBodyJSON: `{
+				"sub":      "some-subject",
+				"username": "some-username",
+				"groups":   ["group1", "group2"]
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{
+					"params": map[string]any{"access_token": "redacted"},
+				}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name:             "only the username scope was granted",
+			method:           http.MethodGet,
+			grantedScopes:    []string{"openid", "offline_access", "username"},
+			additionalClaims: map[string]any{"upstreamStr": "some-value"},
+			authHeader:       func(tokens sessionTokens) string { return "Bearer " + tokens.accessToken },
+			wantStatus:       http.StatusOK,
+			wantContentType:  "application/json",
+			wantCacheControl: "no-store",
+			wantBodyJSON: `{
+				"sub":              "some-subject",
+				"username":         "some-username",
+				"additionalClaims": {"upstreamStr": "some-value"}
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name:             "only the groups scope was granted",
+			method:           http.MethodGet,
+			grantedScopes:    []string{"openid", "groups"},
+			authHeader:       func(tokens sessionTokens) string { return "Bearer " + tokens.accessToken },
+			wantStatus:       http.StatusOK,
+			wantContentType:  "application/json",
+			wantCacheControl: "no-store",
+			wantBodyJSON: `{
+				"sub":    "some-subject",
+				"groups": ["group1", "group2"]
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name:             "neither the username nor groups scopes were granted",
+			method:           http.MethodGet,
+			grantedScopes:    []string{"openid"},
+			authHeader:       func(tokens sessionTokens) string { return "Bearer " + tokens.accessToken },
+			wantStatus:       http.StatusOK,
+			wantContentType:  "application/json",
+			wantCacheControl: "no-store",
+			wantBodyJSON:     `{"sub": "some-subject"}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name:                "the openid scope was not granted",
+			method:              http.MethodGet,
+			grantedScopes:       []string{"username", "groups"},
+			authHeader:          func(tokens sessionTokens) string { return "Bearer " + tokens.accessToken },
+			wantStatus:          http.StatusForbidden,
+			wantWWWAuthenticate: `Bearer error="insufficient_scope", error_description="The access token was not granted the \"openid\" scope."`,
+			wantContentType:     "application/json",
+			wantBodyJSON: `{
+				"error":             "insufficient_scope",
+				"error_description": "The access token was not granted the \"openid\" scope."
+			}`,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+				testutil.WantAuditLog("Session Found", map[string]any{"sessionID": sessionID}),
+			},
+		},
+		{
+			name:                "expired access token",
+			method:              http.MethodGet,
+			grantedScopes:       []string{"openid", "username", "groups"},
+			authHeader:          func(tokens sessionTokens) string { return "Bearer " + tokens.expiredAccessToken },
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: invalidTokenAuthenticateHeader,
+			wantContentType:     "application/json",
+			wantBodyJSON:        invalidTokenBody,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+			},
+		},
+		{
+			name:                "unknown access token",
+			method:              http.MethodGet,
+			grantedScopes:       []string{"openid", "username", "groups"},
+			authHeader:          func(tokens sessionTokens) string { return "Bearer some-unknown-token" },
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: invalidTokenAuthenticateHeader,
+			wantContentType:     "application/json",
+			wantBodyJSON:        invalidTokenBody,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+			},
+		},
+		{
+			name:                "refresh token used as a bearer token",
+			method:              http.MethodGet,
+			grantedScopes:       []string{"openid", "offline_access", "username", "groups"},
+			authHeader:          func(tokens sessionTokens) string { return "Bearer " + tokens.refreshToken },
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: invalidTokenAuthenticateHeader,
+			wantContentType:     "application/json",
+			wantBodyJSON:        invalidTokenBody,
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+			},
+		},
+		{
+			name:                "no access token",
+			method:              http.MethodGet,
+			grantedScopes:       []string{"openid", "username", "groups"},
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: "Bearer",
+			wantContentType:     "text/plain; charset=utf-8",
+			wantBodyString:      "Unauthorized: bearer access token is required\n",
+			wantAuditLogs: []testutil.WantedAuditLog{
+				testutil.WantAuditLog("HTTP Request Parameters", map[string]any{"params": map[string]any{}}),
+			},
+		},
+		{
+			name:            "wrong HTTP method",
+			method:          http.MethodPut,
+			grantedScopes:   []string{"openid", "username", "groups"},
+			authHeader:      func(tokens sessionTokens) string { return "Bearer " + tokens.accessToken },
+			wantStatus:      http.StatusMethodNotAllowed,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBodyString:  "Method Not Allowed: PUT (try GET or POST)\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset()
+			supervisorClient := supervisorfake.NewSimpleClientset()
+			secrets := kubeClient.CoreV1().Secrets("some-namespace")
+			// Use lower minimum required bcrypt cost than we would use in production to keep unit the tests fast.
+			kubeStorage := storage.NewKubeStorage(secrets, supervisorClient.ConfigV1alpha1().OIDCClients("some-namespace"),
+				oidc.DefaultOIDCTimeoutsConfiguration(), 4)
+			oauthHelper := oidc.FositeOauth2Helper(kubeStorage, downstreamIssuer, hmacSecretFunc, nil, oidc.DefaultOIDCTimeoutsConfiguration())
+
+			tokens := createSessionStorage(t, kubeStorage, test.grantedScopes, test.additionalClaims)
+
+			var params url.Values
+			if test.params != nil {
+				params = test.params(tokens)
+			}
+			var req *http.Request
+			if test.method == http.MethodPost {
+				req = httptest.NewRequest(test.method, downstreamIssuer+oidc.UserInfoEndpointPath, strings.NewReader(params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(test.method, downstreamIssuer+oidc.UserInfoEndpointPath+"?"+params.Encode(), nil)
+			}
+			if test.authHeader != nil {
+				req.Header.Set("Authorization", test.authHeader(tokens))
+			}
+			req, _ = auditid.NewRequestWithAuditID(req, func() string { return "fake-userinfo-audit-id" })
+			rsp := httptest.NewRecorder()
+
+			auditLogger, actualAuditLog := plog.TestAuditLogger(t)
+			NewHandler(oauthHelper, auditLogger).ServeHTTP(rsp, req)
+
+			require.Equal(t, test.wantStatus, rsp.Code, rsp.Body.String())
+			require.Equal(t, test.wantWWWAuthenticate, rsp.Header().Get("WWW-Authenticate"))
+			require.Equal(t, test.wantContentType, rsp.Header().Get("Content-Type"))
+			require.Equal(t, test.wantCacheControl, rsp.Header().Get("Cache-Control"))
+			if test.wantBodyJSON != "" {
+				require.JSONEq(t, test.wantBodyJSON, rsp.Body.String())
+			} else {
+				require.Equal(t, test.wantBodyString, rsp.Body.String())
+			}
+
+			testutil.WantAuditIDOnEveryAuditLog(test.wantAuditLogs, "fake-userinfo-audit-id")
+			testutil.CompareAuditLogs(t, test.wantAuditLogs, actualAuditLog.String())
+		})
+	}
+}
+
+func TestParamsSafeToLog(t *testing.T) {
+	require.Empty(t, paramsSafeToLog().UnsortedList())
+}
+
+func hmacSecretFunc() []byte {
+	return []byte(hmacSecret)
+}
+
+// createSessionStorage creates access token and refresh token storage for a pinniped-cli downstream session
+// with the given granted scopes, along with the storage for an expired access token, and returns the tokens
+// which were issued for that session.
+func createSessionStorage(
+	t *testing.T,
+	kubeStorage *storage.KubeStorage,
+	grantedScopes []string,
+	additionalClaims map[string]any,
+) sessionTokens {
+	t.Helper()
+
+	newRequest := func(accessTokenExpiresAt time.Time) *fosite.Request {
+		extra := map[string]any{
+			"username": "some-username",
+			"groups":   []string{"group1", "group2"},
+		}
+		if len(additionalClaims) > 0 {
+			extra["additionalClaims"] = additionalClaims
+		}
+		return &fosite.Request{
+			ID:             sessionID,
+			Client:         clientregistry.PinnipedCLI(),
+			GrantedScope:   grantedScopes,
+			RequestedAt:    time.Now().Add(-time.Minute),
+			RequestedScope: grantedScopes,
+			Session: &psession.PinnipedSession{
+				Fosite: &openid.DefaultSession{
+					Claims: &fositejwt.IDTokenClaims{
+						Subject: "some-subject",
+						Extra:   extra,
+					},
+					Subject: "some-subject",
+					ExpiresAt: map[fosite.TokenType]time.Time{
+						fosite.AccessToken:  accessTokenExpiresAt,
+						fosite.RefreshToken: time.Now().Add(time.Hour),
+					},
+				},
+				Custom: &psession.CustomSessionData{
+					Username:         "some-username",
+					UpstreamUsername: "some-upstream-username",
+					UpstreamGroups:   []string{"group1", "group2"},
+					ProviderUID:      "some-upstream-resource-uid",
+					ProviderName:     "some-upstream-idp",
+					ProviderType:     psession.ProviderTypeOIDC,
+					OIDC:             &psession.OIDCSessionData{UpstreamRefreshToken: "some-upstream-refresh-token"},
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	hmacStrategy := strategy.NewDynamicOauth2HMACStrategy(&fosite.Config{}, hmacSecretFunc)
+
+	request := newRequest(time.Now().Add(2 * time.Minute))
+	accessToken, accessSignature, err := hmacStrategy.GenerateAccessToken(ctx, request)
+	require.NoError(t, err)
+	refreshToken, refreshSignature, err := hmacStrategy.GenerateRefreshToken(ctx, request)
+	require.NoError(t, err)
+	require.NoError(t, kubeStorage.CreateAccessTokenSession(ctx, accessSignature, request))
+	require.NoError(t, kubeStorage.CreateRefreshTokenSession(ctx, refreshSignature, accessSignature, request))
+
+	expiredRequest := newRequest(time.Now().Add(-time.Second))
+	expiredAccessToken, expiredAccessSignature, err := hmacStrategy.GenerateAccessToken(ctx, expiredRequest)
+	require.NoError(t, err)
+	require.NoError(t, kubeStorage.CreateAccessTokenSession(ctx, expiredAccessSignature, expiredRequest))
+
+	return sessionTokens{accessToken: accessToken, expiredAccessToken: expiredAccessToken, refreshToken: refreshToken}
+}
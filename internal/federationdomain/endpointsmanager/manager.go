@@ -1,13 +1,17 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package endpointsmanager
 
 import (
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/ory/fosite"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/typed/config/v1alpha1"
@@ -18,17 +22,25 @@ import (
 	"go.pinniped.dev/internal/federationdomain/endpoints/auth"
 	"go.pinniped.dev/internal/federationdomain/endpoints/callback"
 	"go.pinniped.dev/internal/federationdomain/endpoints/chooseidp"
+	"go.pinniped.dev/internal/federationdomain/endpoints/device"
 	"go.pinniped.dev/internal/federationdomain/endpoints/discovery"
 	"go.pinniped.dev/internal/federationdomain/endpoints/idpdiscovery"
+	"go.pinniped.dev/internal/federationdomain/endpoints/introspection"
 	"go.pinniped.dev/internal/federationdomain/endpoints/jwks"
 	"go.pinniped.dev/internal/federationdomain/endpoints/login"
+	"go.pinniped.dev/internal/federationdomain/endpoints/logout"
+	"go.pinniped.dev/internal/federationdomain/endpoints/revocation"
 	"go.pinniped.dev/internal/federationdomain/endpoints/token"
+	"go.pinniped.dev/internal/federationdomain/endpoints/userinfo"
 	"go.pinniped.dev/internal/federationdomain/federationdomainproviders"
 	"go.pinniped.dev/internal/federationdomain/idplister"
 	"go.pinniped.dev/internal/federationdomain/oidc"
 	"go.pinniped.dev/internal/federationdomain/oidcclientvalidator"
 	"go.pinniped.dev/internal/federationdomain/requestlogger"
 	"go.pinniped.dev/internal/federationdomain/storage"
+	"go.pinniped.dev/internal/federationdomain/strategy"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
+	"go.pinniped.dev/internal/federationdomain/upstreamrevocation"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/secret"
 	"go.pinniped.dev/pkg/oidcclient/nonce"
@@ -45,34 +57,43 @@ type Manager struct {
 	handlerChain        http.Handler                              // http handlers
 	dynamicJWKSProvider jwks.DynamicJWKSProvider                  // in-memory cache of per-issuer JWKS data
 	upstreamIDPs        idplister.UpstreamIdentityProvidersLister // in-memory cache of upstream IDPs
+	upstreamHealth      *upstreamhealth.Tracker                   // in-memory cache of upstream IDP reachability
 	secretCache         *secret.Cache                             // in-memory cache of cryptographic material
 	secretsClient       corev1client.SecretInterface
 	oidcClientsClient   v1alpha1.OIDCClientInterface
 	auditLogger         plog.AuditLogger
+	mtlsAdvertisedPort  int // port of the mTLS listener on each issuer's host, or zero when it is disabled
 }
 
 // NewManager returns an empty Manager.
 // nextHandler will be invoked for any requests that could not be handled by this manager's providers.
 // dynamicJWKSProvider will be used as an in-memory cache for per-issuer JWKS data.
 // upstreamIDPs will be used as an in-memory cache of currently configured upstream IDPs.
+// upstreamHealth will be used to fail fast and to warn users when upstream IDPs are unavailable.
+// mtlsAdvertisedPort is the port at which clients can reach the mTLS listener on each issuer's host, or zero
+// when the mTLS listener is disabled.
 func NewManager(
 	nextHandler http.Handler,
 	dynamicJWKSProvider jwks.DynamicJWKSProvider,
 	upstreamIDPs idplister.UpstreamIdentityProvidersLister,
+	upstreamHealth *upstreamhealth.Tracker,
 	secretCache *secret.Cache,
 	secretsClient corev1client.SecretInterface,
 	oidcClientsClient v1alpha1.OIDCClientInterface,
 	auditLogger plog.AuditLogger,
 	auditInternalPathsCfg supervisor.AuditInternalPaths,
+	mtlsAdvertisedPort int,
 ) *Manager {
 	m := &Manager{
 		providerHandlers:    make(map[string]http.Handler),
 		dynamicJWKSProvider: dynamicJWKSProvider,
 		upstreamIDPs:        upstreamIDPs,
+		upstreamHealth:      upstreamHealth,
 		secretCache:         secretCache,
 		secretsClient:       secretsClient,
 		oidcClientsClient:   oidcClientsClient,
 		auditLogger:         auditLogger,
+		mtlsAdvertisedPort:  mtlsAdvertisedPort,
 	}
 	// nextHandler is the next handler in the chain, called when this manager didn't know how to handle a request
 	m.buildHandlerChain(nextHandler, auditInternalPathsCfg)
@@ -104,6 +125,8 @@ func (m *Manager) SetFederationDomains(federationDomains ...*federationdomainpro
 		func() []byte { return nil },
 	)
 
+	upstreamRevoker := upstreamrevocation.New(m.upstreamIDPs, m.auditLogger)
+
 	for _, incomingFederationDomain := range federationDomains {
 		issuerURL := incomingFederationDomain.Issuer()
 		issuerHostWithPath := strings.ToLower(incomingFederationDomain.IssuerHost()) + "/" + incomingFederationDomain.IssuerPath()
@@ -115,16 +138,18 @@ func (m *Manager) SetFederationDomains(federationDomains ...*federationdomainpro
 		// Use NullStorage for the authorize endpoint because we do not actually want to store anything until
 		// the upstream callback endpoint is called later.
 		oauthHelperWithNullStorage := oidc.FositeOauth2Helper(
-			storage.NewNullStorage(m.secretsClient, m.oidcClientsClient, oidcclientvalidator.DefaultMinBcryptCost),
+			storage.NewNullStorage(m.secretsClient, m.oidcClientsClient, timeoutsConfiguration, oidcclientvalidator.DefaultMinBcryptCost),
 			issuerURL,
 			tokenHMACKeyGetter,
 			nil,
 			timeoutsConfiguration,
 		)
 
+		kubeStorage := storage.NewKubeStorage(m.secretsClient, m.oidcClientsClient, timeoutsConfiguration, oidcclientvalidator.DefaultMinBcryptCost)
+
 		// For all the other endpoints, make another oauth helper with exactly the same settings except use real storage.
 		oauthHelperWithKubeStorage := oidc.FositeOauth2Helper(
-			storage.NewKubeStorage(m.secretsClient, m.oidcClientsClient, timeoutsConfiguration, oidcclientvalidator.DefaultMinBcryptCost),
+			kubeStorage,
 			issuerURL,
 			tokenHMACKeyGetter,
 			m.dynamicJWKSProvider,
@@ -137,19 +162,31 @@ func (m *Manager) SetFederationDomains(federationDomains ...*federationdomainpro
 			wrapGetter(incomingFederationDomain.Issuer(), m.secretCache.GetStateEncoderBlockKey),
 		)
 
-		idpLister := federationdomainproviders.NewFederationDomainIdentityProvidersListerFinder(incomingFederationDomain, m.upstreamIDPs)
+		idpLister := federationdomainproviders.NewFederationDomainIdentityProvidersListerFinder(incomingFederationDomain, m.upstreamIDPs, m.upstreamHealth)
+
+		deviceApprover := device.NewApprover(issuerURL, kubeStorage, m.auditLogger)
 
-		m.providerHandlers[(issuerHostWithPath + oidc.WellKnownEndpointPath)] = discovery.NewHandler(issuerURL)
+		// The endpoints which authenticate clients are also served at the mTLS listener's port of the issuer's host,
+		// so that OIDCClients which use the tls_client_auth method can present their TLS client certificates there.
+		mtlsIssuerURL, mtlsIssuerHostWithPath := "", ""
+		if m.mtlsAdvertisedPort != 0 {
+			mtlsIssuerHost := mtlsHost(incomingFederationDomain.IssuerHost(), m.mtlsAdvertisedPort)
+			mtlsIssuerURL = "https://" + mtlsIssuerHost + incomingFederationDomain.IssuerPath()
+			mtlsIssuerHostWithPath = strings.ToLower(mtlsIssuerHost) + "/" + incomingFederationDomain.IssuerPath()
+		}
+
+		m.providerHandlers[(issuerHostWithPath + oidc.WellKnownEndpointPath)] = discovery.NewHandler(issuerURL, mtlsIssuerURL)
 
 		m.providerHandlers[(issuerHostWithPath + oidc.JWKSEndpointPath)] = jwks.NewHandler(issuerURL, m.dynamicJWKSProvider)
 
-		m.providerHandlers[(issuerHostWithPath + oidc.PinnipedIDPsPathV1Alpha1)] = idpdiscovery.NewHandler(idpLister)
+		m.providerHandlers[(issuerHostWithPath + oidc.PinnipedIDPsPathV1Alpha1)] = idpdiscovery.NewHandler(idpLister, m.upstreamHealth)
 
 		m.providerHandlers[(issuerHostWithPath + oidc.AuthorizationEndpointPath)] = auth.NewHandler(
 			issuerURL,
 			idpLister,
 			oauthHelperWithNullStorage,
 			oauthHelperWithKubeStorage,
+			kubeStorage,
 			csrftoken.Generate,
 			pkce.Generate,
 			nonce.Generate,
@@ -158,18 +195,26 @@ func (m *Manager) SetFederationDomains(federationDomains ...*federationdomainpro
 			m.auditLogger,
 		)
 
+		m.providerHandlers[(issuerHostWithPath + oidc.PushedAuthorizeEndpointPath)] = auth.NewPushedAuthorizeHandler(
+			idpLister,
+			oauthHelperWithKubeStorage,
+			m.auditLogger,
+		)
+
 		m.providerHandlers[(issuerHostWithPath + oidc.CallbackEndpointPath)] = callback.NewHandler(
 			idpLister,
 			oauthHelperWithKubeStorage,
 			upstreamStateEncoder,
 			csrfCookieEncoder,
 			issuerURL+oidc.CallbackEndpointPath,
+			deviceApprover,
 			m.auditLogger,
 		)
 
 		m.providerHandlers[(issuerHostWithPath + oidc.ChooseIDPEndpointPath)] = chooseidp.NewHandler(
 			issuerURL+oidc.AuthorizationEndpointPath,
 			idpLister,
+			m.upstreamHealth,
 		)
 
 		m.providerHandlers[(issuerHostWithPath + oidc.TokenEndpointPath)] = token.NewHandler(
@@ -184,10 +229,61 @@ func (m *Manager) SetFederationDomains(federationDomains ...*federationdomainpro
 			upstreamStateEncoder,
 			csrfCookieEncoder,
 			login.NewGetHandler(incomingFederationDomain.IssuerPath()+oidc.PinnipedLoginPath),
-			login.NewPostHandler(issuerURL, idpLister, oauthHelperWithKubeStorage, m.auditLogger),
+			login.NewPostHandler(issuerURL, idpLister, oauthHelperWithKubeStorage, deviceApprover, m.auditLogger),
+			m.auditLogger,
+		)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.DeviceAuthorizationEndpointPath)] = device.NewAuthorizationHandler(
+			oauthHelperWithKubeStorage,
+			m.auditLogger,
+		)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.DeviceVerificationEndpointPath)] = device.NewVerificationHandler(
+			issuerURL,
+			idpLister,
+			kubeStorage,
+			// Only the HMAC key is needed to compute the signatures of user codes, so an empty config is fine here.
+			strategy.NewDynamicOauth2HMACStrategy(&fosite.Config{}, tokenHMACKeyGetter),
+			csrftoken.Generate,
+			pkce.Generate,
+			nonce.Generate,
+			upstreamStateEncoder,
+			csrfCookieEncoder,
+			m.auditLogger,
+		)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.EndSessionEndpointPath)] = logout.NewHandler(
+			issuerURL,
+			m.dynamicJWKSProvider,
+			kubeStorage,
+			m.secretsClient,
+			upstreamRevoker,
 			m.auditLogger,
 		)
 
+		m.providerHandlers[(issuerHostWithPath + oidc.RevocationEndpointPath)] = revocation.NewHandler(
+			oauthHelperWithKubeStorage,
+			m.auditLogger,
+		)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.IntrospectionEndpointPath)] = introspection.NewHandler(
+			issuerURL,
+			oauthHelperWithKubeStorage,
+			kubeStorage,
+			m.auditLogger,
+		)
+
+		m.providerHandlers[(issuerHostWithPath + oidc.UserInfoEndpointPath)] = userinfo.NewHandler(
+			oauthHelperWithKubeStorage,
+			m.auditLogger,
+		)
+
+		if mtlsIssuerHostWithPath != "" {
+			for _, path := range []string{oidc.TokenEndpointPath, oidc.RevocationEndpointPath, oidc.PushedAuthorizeEndpointPath} {
+				m.providerHandlers[(mtlsIssuerHostWithPath + path)] = m.providerHandlers[(issuerHostWithPath + path)]
+			}
+		}
+
 		plog.Debug("oidc provider manager added or updated issuer", "issuer", issuerURL)
 	}
 }
@@ -223,6 +319,13 @@ func (m *Manager) findHandler(req *http.Request) http.Handler {
 	return m.providerHandlers[strings.ToLower(req.Host)+"/"+req.URL.Path]
 }
 
+// mtlsHost returns the given issuer host with its port, if any, replaced by the given port.
+func mtlsHost(issuerHost string, port int) string {
+	// Parsing as a URL handles both bracketed IPv6 addresses and hosts without ports.
+	u := &url.URL{Host: issuerHost}
+	return net.JoinHostPort(u.Hostname(), strconv.Itoa(port))
+}
+
 func wrapGetter(issuer string, getter func(string) []byte) func() []byte {
 	return func() []byte {
 		return getter(issuer)
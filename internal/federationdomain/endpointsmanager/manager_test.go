@@ -53,6 +53,9 @@ func TestManager(t *testing.T) {
 			issuer2                      = "https://example.com/some/path/more/deeply/nested/path" // note that this is a sub-path of the other issuer url
 			issuer2DifferentCaseHostname = "https://exAmPlE.Com/some/path/more/deeply/nested/path"
 			issuer2KeyID                 = "issuer2-key"
+			mtlsAdvertisedPort           = 8444
+			issuer1MTLS                  = "https://example.com:8444/some/path"
+			issuer2MTLS                  = "https://example.com:8444/some/path/more/deeply/nested/path"
 			upstreamIDPAuthorizationURL1 = "https://test-upstream.com/auth1"
 			upstreamIDPAuthorizationURL2 = "https://test-upstream.com/auth2"
 			upstreamIDPDisplayName1      = "test-idp-display-name-1"
@@ -69,7 +72,7 @@ func TestManager(t *testing.T) {
 		)
 
 		var (
-			upstreamIDPFlows = []string{"browser_authcode"}
+			upstreamIDPFlows = []string{"browser_authcode", "device_code"}
 		)
 
 		newGetRequest := func(url string) *http.Request {
@@ -98,6 +101,9 @@ func TestManager(t *testing.T) {
 			r.NoError(err)
 			r.Equal(expectedIssuer, parsedDiscoveryResult.Issuer)
 			r.Equal(parsedDiscoveryResult.SupervisorDiscovery.PinnipedIDPsEndpoint, expectedIssuer+oidc.PinnipedIDPsPathV1Alpha1)
+			expectedMTLSIssuer := strings.Replace(expectedIssuer, "example.com", "example.com:8444", 1)
+			r.NotNil(parsedDiscoveryResult.MTLSEndpointAliases)
+			r.Equal(expectedMTLSIssuer+oidc.TokenEndpointPath, parsedDiscoveryResult.MTLSEndpointAliases.TokenEndpoint)
 		}
 
 		requirePinnipedIDPsDiscoveryRequestToBeHandled := func(requestIssuer, requestURLSuffix string, expectedIDPNames []string, expectedIDPTypes string, expectedFlows []string) {
@@ -127,7 +133,10 @@ func TestManager(t *testing.T) {
 					{"type":"activedirectory"},
 					{"type":"github"},
 					{"type":"ldap"},
-					{"type":"oidc"}
+					{"type":"localuser"},
+					{"type":"oauth2"},
+					{"type":"oidc"},
+					{"type":"saml"}
 				]
 }`, strings.Join(expectedIDPJSONList, ","))
 
@@ -290,6 +299,60 @@ func TestManager(t *testing.T) {
 			return &parsedJWKSResult
 		}
 
+		requireLogoutRequestToBeHandled := func(requestIssuer string) {
+			recorder := httptest.NewRecorder()
+
+			subject.HandlerChain().ServeHTTP(recorder, newGetRequest(requestIssuer+oidc.EndSessionEndpointPath))
+
+			r.False(fallbackHandlerWasCalled)
+
+			// Minimal check to ensure that the right logout endpoint was called
+			r.Equal(http.StatusBadRequest, recorder.Code, "unexpected response:", recorder)
+			r.Equal("Bad Request: id_token_hint param is required\n", recorder.Body.String())
+		}
+
+		requireRevocationRequestToBeHandled := func(requestIssuer string) {
+			recorder := httptest.NewRecorder()
+
+			revocationRequestBody := url.Values{
+				"token":     []string{"some-unknown-token"},
+				"client_id": []string{downstreamClientID},
+			}.Encode()
+			subject.HandlerChain().ServeHTTP(recorder, newPostRequest(requestIssuer+oidc.RevocationEndpointPath, revocationRequestBody))
+
+			r.False(fallbackHandlerWasCalled)
+
+			// Minimal check to ensure that the right revocation endpoint was called.
+			// Unknown tokens are considered to be successfully revoked.
+			r.Equal(http.StatusOK, recorder.Code, "unexpected response:", recorder)
+			r.Equal("no-store", recorder.Header().Get("Cache-Control"))
+		}
+
+		requireIntrospectionRequestToBeHandled := func(requestIssuer string) {
+			recorder := httptest.NewRecorder()
+
+			introspectionRequestBody := url.Values{"token": []string{"some-token"}}.Encode()
+			subject.HandlerChain().ServeHTTP(recorder, newPostRequest(requestIssuer+oidc.IntrospectionEndpointPath, introspectionRequestBody))
+
+			r.False(fallbackHandlerWasCalled)
+
+			// Minimal check to ensure that the right introspection endpoint was called.
+			r.Equal(http.StatusUnauthorized, recorder.Code, "unexpected response:", recorder)
+			r.Contains(recorder.Body.String(), "HTTP Authorization header missing.")
+		}
+
+		requireUserInfoRequestToBeHandled := func(requestIssuer string) {
+			recorder := httptest.NewRecorder()
+
+			subject.HandlerChain().ServeHTTP(recorder, newGetRequest(requestIssuer+oidc.UserInfoEndpointPath))
+
+			r.False(fallbackHandlerWasCalled)
+
+			// Minimal check to ensure that the right userinfo endpoint was called.
+			r.Equal(http.StatusUnauthorized, recorder.Code, "unexpected response:", recorder)
+			r.Equal("Bearer", recorder.Header().Get("WWW-Authenticate"))
+		}
+
 		it.Before(func() {
 			r = require.New(t)
 			nextHandler = func(http.ResponseWriter, *http.Request) {
@@ -366,11 +429,13 @@ func TestManager(t *testing.T) {
 				nextHandler,
 				dynamicJWKSProvider,
 				idpLister,
+				nil,
 				&cache,
 				secretsClient,
 				oidcClientsClient,
 				auditLogger,
 				supervisor.Enabled,
+				mtlsAdvertisedPort,
 			)
 		})
 
@@ -496,6 +561,26 @@ func TestManager(t *testing.T) {
 			requireTokenRequestToBeHandled(issuer2DifferentCaseHostname, downstreamAuthCode4, issuer2JWKS, issuer2)
 			requireTokenRequestToBeHandled(issuer1DifferentCaseHostname, downstreamAuthCode5, issuer1JWKS, issuer1)
 			requireTokenRequestToBeHandled(issuer2DifferentCaseHostname, downstreamAuthCode6, issuer2JWKS, issuer2)
+
+			requireLogoutRequestToBeHandled(issuer1)
+			requireLogoutRequestToBeHandled(issuer2)
+			requireLogoutRequestToBeHandled(issuer2DifferentCaseHostname)
+
+			requireRevocationRequestToBeHandled(issuer1)
+			requireRevocationRequestToBeHandled(issuer2)
+			requireRevocationRequestToBeHandled(issuer2DifferentCaseHostname)
+
+			// The endpoints which authenticate clients are also served at the mTLS listener's port.
+			requireRevocationRequestToBeHandled(issuer1MTLS)
+			requireRevocationRequestToBeHandled(issuer2MTLS)
+
+			requireIntrospectionRequestToBeHandled(issuer1)
+			requireIntrospectionRequestToBeHandled(issuer2)
+			requireIntrospectionRequestToBeHandled(issuer2DifferentCaseHostname)
+
+			requireUserInfoRequestToBeHandled(issuer1)
+			requireUserInfoRequestToBeHandled(issuer2)
+			requireUserInfoRequestToBeHandled(issuer2DifferentCaseHostname)
 		}
 
 		when("given some valid providers via SetFederationDomains()", func() {
@@ -536,6 +621,12 @@ func TestManager(t *testing.T) {
 				r.True(fallbackHandlerWasCalled)
 			})
 
+			it("sends requests for endpoints which do not authenticate clients at the mTLS listener's port to the nextHandler", func() {
+				r.False(fallbackHandlerWasCalled)
+				subject.HandlerChain().ServeHTTP(httptest.NewRecorder(), newGetRequest(issuer1MTLS+oidc.WellKnownEndpointPath))
+				r.True(fallbackHandlerWasCalled)
+			})
+
 			it("routes matching requests to the appropriate provider", func() {
 				requireRoutesMatchingRequestsToAppropriateProvider()
 			})
@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package federationdomainproviders
@@ -13,18 +13,24 @@ import (
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedgithub"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedldap"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedlocaluser"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedoauth2"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedoidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedsaml"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
 	"go.pinniped.dev/internal/idtransform"
 	"go.pinniped.dev/internal/psession"
 )
 
 // FederationDomainIdentityProvider represents an identity provider as configured in a FederationDomain's spec.
-// All the fields are required and must be non-zero values. Note that this might be a reference to an IDP
-// which is not currently loaded into the cache of available IDPs, e.g. due to the IDP's CR having validation errors.
+// All the fields are required and must be non-zero values, except TokenExchangeTransforms which may be empty.
+// Note that this might be a reference to an IDP which is not currently loaded into the cache of available IDPs,
+// e.g. due to the IDP's CR having validation errors.
 type FederationDomainIdentityProvider struct {
-	DisplayName string
-	UID         types.UID
-	Transforms  *idtransform.TransformationPipeline
+	DisplayName             string
+	UID                     types.UID
+	Transforms              *idtransform.TransformationPipeline
+	TokenExchangeTransforms idtransform.TokenExchangePipelines
 }
 
 type FederationDomainIdentityProvidersFinderI interface {
@@ -54,6 +60,7 @@ var _ FederationDomainIdentityProvidersListerFinderI = (*FederationDomainIdentit
 // discovery endpoint, for example).
 type FederationDomainIdentityProvidersListerFinder struct {
 	wrappedLister                    idplister.UpstreamIdentityProvidersLister
+	upstreamHealth                   *upstreamhealth.Tracker
 	configuredIdentityProviders      []*FederationDomainIdentityProvider
 	defaultIdentityProvider          *FederationDomainIdentityProvider
 	idpDisplayNamesToResourceUIDsMap map[string]types.UID
@@ -68,10 +75,12 @@ type FederationDomainIdentityProvidersListerFinder struct {
 // be thread-safe and to change its contents over time. (Note that it should not contain any invalid or unready identity
 // providers because the controllers that fill this cache should not put invalid or unready providers into the cache.)
 // The FederationDomainIdentityProvidersListerFinder will filter out the ones that don't apply to this federation
-// domain.
+// domain. When upstreamHealth is not nil, the returned identity providers will fail fast while their upstreams
+// are unavailable according to upstreamHealth.
 func NewFederationDomainIdentityProvidersListerFinder(
 	federationDomainIssuer *FederationDomainIssuer,
 	wrappedLister idplister.UpstreamIdentityProvidersLister,
+	upstreamHealth *upstreamhealth.Tracker,
 ) *FederationDomainIdentityProvidersListerFinder {
 	// Create a copy of the input slice so we won't need to worry about the caller accidentally changing it.
 	copyOfFederationDomainIdentityProviders := []*FederationDomainIdentityProvider{}
@@ -88,6 +97,7 @@ func NewFederationDomainIdentityProvidersListerFinder(
 
 	return &FederationDomainIdentityProvidersListerFinder{
 		wrappedLister:                    wrappedLister,
+		upstreamHealth:                   upstreamHealth,
 		configuredIdentityProviders:      copyOfFederationDomainIdentityProviders,
 		defaultIdentityProvider:          federationDomainIssuer.DefaultIdentityProvider(),
 		idpDisplayNamesToResourceUIDsMap: idpDisplayNamesToResourceUIDsMap,
@@ -146,6 +156,9 @@ func (u *FederationDomainIdentityProvidersListerFinder) GetIdentityProviders() [
 	cachedLDAPProviders := u.wrappedLister.GetLDAPIdentityProviders()
 	cachedADProviders := u.wrappedLister.GetActiveDirectoryIdentityProviders()
 	cachedGitHubProviders := u.wrappedLister.GetGitHubIdentityProviders()
+	cachedSAMLProviders := u.wrappedLister.GetSAMLIdentityProviders()
+	cachedOAuth2Providers := u.wrappedLister.GetOAuth2IdentityProviders()
+	cachedLocalUserProviders := u.wrappedLister.GetLocalUserIdentityProviders()
 	providers := []resolvedprovider.FederationDomainResolvedIdentityProvider{}
 	// Every configured identityProvider on the FederationDomain uses an objetRef to an underlying IDP CR that might
 	// be available as a provider in the wrapped cache. For each configured identityProvider/displayName...
@@ -155,10 +168,11 @@ func (u *FederationDomainIdentityProvidersListerFinder) GetIdentityProviders() [
 			if idp.UID == p.GetResourceUID() {
 				// Found it, so append it to the result.
 				providers = append(providers, &resolvedoidc.FederationDomainResolvedOIDCIdentityProvider{
-					DisplayName:         idp.DisplayName,
-					Provider:            p,
-					SessionProviderType: psession.ProviderTypeOIDC,
-					Transforms:          idp.Transforms,
+					DisplayName:             idp.DisplayName,
+					Provider:                p,
+					SessionProviderType:     psession.ProviderTypeOIDC,
+					Transforms:              idp.Transforms,
+					TokenExchangeTransforms: idp.TokenExchangeTransforms,
 				})
 			}
 		}
@@ -167,10 +181,11 @@ func (u *FederationDomainIdentityProvidersListerFinder) GetIdentityProviders() [
 			if idp.UID == p.GetResourceUID() {
 				// Found it, so append it to the result.
 				providers = append(providers, &resolvedldap.FederationDomainResolvedLDAPIdentityProvider{
-					DisplayName:         idp.DisplayName,
-					Provider:            p,
-					SessionProviderType: psession.ProviderTypeLDAP,
-					Transforms:          idp.Transforms,
+					DisplayName:             idp.DisplayName,
+					Provider:                p,
+					SessionProviderType:     psession.ProviderTypeLDAP,
+					Transforms:              idp.Transforms,
+					TokenExchangeTransforms: idp.TokenExchangeTransforms,
 				})
 			}
 		}
@@ -179,23 +194,63 @@ func (u *FederationDomainIdentityProvidersListerFinder) GetIdentityProviders() [
 			if idp.UID == p.GetResourceUID() {
 				// Found it, so append it to the result.
 				providers = append(providers, &resolvedldap.FederationDomainResolvedLDAPIdentityProvider{
-					DisplayName:         idp.DisplayName,
-					Provider:            p,
-					SessionProviderType: psession.ProviderTypeActiveDirectory,
-					Transforms:          idp.Transforms,
+					DisplayName:             idp.DisplayName,
+					Provider:                p,
+					SessionProviderType:     psession.ProviderTypeActiveDirectory,
+					Transforms:              idp.Transforms,
+					TokenExchangeTransforms: idp.TokenExchangeTransforms,
 				})
 			}
 		}
 		for _, p := range cachedGitHubProviders {
 			if idp.UID == p.GetResourceUID() {
 				providers = append(providers, &resolvedgithub.FederationDomainResolvedGitHubIdentityProvider{
-					DisplayName:         idp.DisplayName,
-					Provider:            p,
-					SessionProviderType: psession.ProviderTypeGitHub,
-					Transforms:          idp.Transforms,
+					DisplayName:             idp.DisplayName,
+					Provider:                p,
+					SessionProviderType:     psession.ProviderTypeGitHub,
+					Transforms:              idp.Transforms,
+					TokenExchangeTransforms: idp.TokenExchangeTransforms,
 				})
 			}
 		}
+		for _, p := range cachedSAMLProviders {
+			if idp.UID == p.GetResourceUID() {
+				providers = append(providers, &resolvedsaml.FederationDomainResolvedSAMLIdentityProvider{
+					DisplayName:             idp.DisplayName,
+					Provider:                p,
+					SessionProviderType:     psession.ProviderTypeSAML,
+					Transforms:              idp.Transforms,
+					TokenExchangeTransforms: idp.TokenExchangeTransforms,
+				})
+			}
+		}
+		for _, p := range cachedOAuth2Providers {
+			if idp.UID == p.GetResourceUID() {
+				providers = append(providers, &resolvedoauth2.FederationDomainResolvedOAuth2IdentityProvider{
+					DisplayName:             idp.DisplayName,
+					Provider:                p,
+					SessionProviderType:     psession.ProviderTypeOAuth2,
+					Transforms:              idp.Transforms,
+					TokenExchangeTransforms: idp.TokenExchangeTransforms,
+				})
+			}
+		}
+		for _, p := range cachedLocalUserProviders {
+			if idp.UID == p.GetResourceUID() {
+				providers = append(providers, &resolvedlocaluser.FederationDomainResolvedLocalUserIdentityProvider{
+					DisplayName:             idp.DisplayName,
+					Provider:                p,
+					SessionProviderType:     psession.ProviderTypeLocalUser,
+					Transforms:              idp.Transforms,
+					TokenExchangeTransforms: idp.TokenExchangeTransforms,
+				})
+			}
+		}
+	}
+	if u.upstreamHealth != nil {
+		for i := range providers {
+			providers[i] = upstreamhealth.WithCircuitBreaker(providers[i], u.upstreamHealth)
+		}
 	}
 	return providers
 }
@@ -1,18 +1,25 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package federationdomainproviders
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
 
 	"go.pinniped.dev/internal/federationdomain/idplister"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedgithub"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedldap"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedlocaluser"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedoauth2"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedoidc"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
 	"go.pinniped.dev/internal/testutil/oidctestutil"
 	"go.pinniped.dev/internal/testutil/testidplister"
 )
@@ -61,6 +68,14 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 		WithName("my-github-idp1").
 		WithResourceUID("my-github-uid-idp1").
 		Build()
+	myOAuth2IDP1 := oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+		WithName("my-oauth2-idp1").
+		WithResourceUID("my-oauth2-uid-idp1").
+		Build()
+	myLocalUserIDP1 := oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().
+		WithName("my-localuser-idp1").
+		WithResourceUID("my-localuser-uid-idp1").
+		Build()
 
 	// FederationDomainIssuers
 	fakeIssuerURL := "https://www.fakeissuerurl.com"
@@ -103,6 +118,18 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 	})
 	require.NoError(t, err)
 
+	fdIssuerWithOAuth2IDP, err := NewFederationDomainIssuer(fakeIssuerURL, []*FederationDomainIdentityProvider{
+		{DisplayName: "my-oidc-idp1", UID: "my-oidc-uid-idp1"},
+		{DisplayName: "my-oauth2-idp1", UID: "my-oauth2-uid-idp1"},
+	})
+	require.NoError(t, err)
+
+	fdIssuerWithLocalUserIDP, err := NewFederationDomainIssuer(fakeIssuerURL, []*FederationDomainIdentityProvider{
+		{DisplayName: "my-oidc-idp1", UID: "my-oidc-uid-idp1"},
+		{DisplayName: "my-localuser-idp1", UID: "my-localuser-uid-idp1"},
+	})
+	require.NoError(t, err)
+
 	fdIssuerWithLotsOfIDPs, err := NewFederationDomainIssuer(fakeIssuerURL, []*FederationDomainIdentityProvider{
 		{DisplayName: "my-oidc-idp1", UID: "my-oidc-uid-idp1"},
 		{DisplayName: "my-oidc-idp2", UID: "my-oidc-uid-idp2"},
@@ -155,6 +182,16 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 		Provider:            myGitHubIDP1,
 		SessionProviderType: "github",
 	}
+	myOAuth2IDP1Resolved := &resolvedoauth2.FederationDomainResolvedOAuth2IdentityProvider{
+		DisplayName:         "my-oauth2-idp1",
+		Provider:            myOAuth2IDP1,
+		SessionProviderType: "oauth2",
+	}
+	myLocalUserIDP1Resolved := &resolvedlocaluser.FederationDomainResolvedLocalUserIdentityProvider{
+		DisplayName:         "my-localuser-idp1",
+		Provider:            myLocalUserIDP1,
+		SessionProviderType: "localuser",
+	}
 
 	myDefaultOIDCIDPResolved := &resolvedoidc.FederationDomainResolvedOIDCIdentityProvider{
 		DisplayName:         "my-default-oidc-idp",
@@ -173,14 +210,16 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 	}
 
 	testFindUpstreamIDPByDisplayName := []struct {
-		name                       string
-		wrappedLister              idplister.UpstreamIdentityProvidersLister
-		federationDomainIssuer     *FederationDomainIssuer
-		findIDPByDisplayName       string
-		wantOIDCIDPByDisplayName   *resolvedoidc.FederationDomainResolvedOIDCIdentityProvider
-		wantLDAPIDPByDisplayName   *resolvedldap.FederationDomainResolvedLDAPIdentityProvider
-		wantGitHubIDPByDisplayName *resolvedgithub.FederationDomainResolvedGitHubIdentityProvider
-		wantError                  string
+		name                          string
+		wrappedLister                 idplister.UpstreamIdentityProvidersLister
+		federationDomainIssuer        *FederationDomainIssuer
+		findIDPByDisplayName          string
+		wantOIDCIDPByDisplayName      *resolvedoidc.FederationDomainResolvedOIDCIdentityProvider
+		wantLDAPIDPByDisplayName      *resolvedldap.FederationDomainResolvedLDAPIdentityProvider
+		wantGitHubIDPByDisplayName    *resolvedgithub.FederationDomainResolvedGitHubIdentityProvider
+		wantOAuth2IDPByDisplayName    *resolvedoauth2.FederationDomainResolvedOAuth2IdentityProvider
+		wantLocalUserIDPByDisplayName *resolvedlocaluser.FederationDomainResolvedLocalUserIdentityProvider
+		wantError                     string
 	}{
 		{
 			name:                 "FindUpstreamIDPByDisplayName will find an upstream IdP by display name with one IDP configured",
@@ -271,6 +310,26 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 			federationDomainIssuer:     fdIssuerWithOIDCAndLDAPAndADAndGitHubIDPs,
 			wantGitHubIDPByDisplayName: myGitHub1Resolved,
 		},
+		{
+			name:                 "FindUpstreamIDPByDisplayName will find an upstream IDP of type OAuth2 by display name",
+			findIDPByDisplayName: "my-oauth2-idp1",
+			wrappedLister: testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(myOIDCIDP1).
+				WithOAuth2(myOAuth2IDP1).
+				BuildDynamicUpstreamIDPProvider(),
+			federationDomainIssuer:     fdIssuerWithOAuth2IDP,
+			wantOAuth2IDPByDisplayName: myOAuth2IDP1Resolved,
+		},
+		{
+			name:                 "FindUpstreamIDPByDisplayName will find an upstream IDP of type LocalUser by display name",
+			findIDPByDisplayName: "my-localuser-idp1",
+			wrappedLister: testidplister.NewUpstreamIDPListerBuilder().
+				WithOIDC(myOIDCIDP1).
+				WithLocalUser(myLocalUserIDP1).
+				BuildDynamicUpstreamIDPProvider(),
+			federationDomainIssuer:        fdIssuerWithLocalUserIDP,
+			wantLocalUserIDPByDisplayName: myLocalUserIDP1Resolved,
+		},
 		{
 			name:                 "FindUpstreamIDPByDisplayName will error if IDP by display name is not found - no such display name",
 			findIDPByDisplayName: "i-cant-find-my-idp",
@@ -299,7 +358,7 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister)
+			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister, nil)
 			foundIDP, err := subject.FindUpstreamIDPByDisplayName(tt.findIDPByDisplayName)
 
 			if tt.wantError != "" {
@@ -316,6 +375,12 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 			if tt.wantGitHubIDPByDisplayName != nil {
 				require.Equal(t, tt.wantGitHubIDPByDisplayName, foundIDP)
 			}
+			if tt.wantOAuth2IDPByDisplayName != nil {
+				require.Equal(t, tt.wantOAuth2IDPByDisplayName, foundIDP)
+			}
+			if tt.wantLocalUserIDPByDisplayName != nil {
+				require.Equal(t, tt.wantLocalUserIDPByDisplayName, foundIDP)
+			}
 		})
 	}
 
@@ -386,7 +451,7 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister)
+			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister, nil)
 			foundIDP, err := subject.FindDefaultIDP()
 
 			if tt.wantError != "" {
@@ -467,7 +532,7 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister)
+			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister, nil)
 			idps := subject.GetIdentityProviders()
 
 			require.Equal(t, tt.wantIDPs, idps)
@@ -522,7 +587,7 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister)
+			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister, nil)
 
 			require.Equal(t, tt.wantCount, subject.IDPCount())
 		})
@@ -582,9 +647,47 @@ func TestFederationDomainIdentityProvidersListerFinder(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister)
+			subject := NewFederationDomainIdentityProvidersListerFinder(tt.federationDomainIssuer, tt.wrappedLister, nil)
 
 			require.Equal(t, tt.wantHasDefaultIDP, subject.HasDefaultIDP())
 		})
 	}
 }
+
+func TestFederationDomainIdentityProvidersListerFinderWithUpstreamHealth(t *testing.T) {
+	fdIssuer, err := NewFederationDomainIssuer("https://www.fakeissuerurl.com", []*FederationDomainIdentityProvider{
+		{DisplayName: "my-ldap-idp1", UID: "my-ldap-uid-idp1"},
+		{DisplayName: "my-ldap-idp2", UID: "my-ldap-uid-idp2"},
+	})
+	require.NoError(t, err)
+
+	wrappedLister := testidplister.NewUpstreamIDPListerBuilder().
+		WithLDAP(oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+			WithName("my-ldap-idp1").
+			WithResourceUID("my-ldap-uid-idp1").
+			Build()).
+		WithLDAP(oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+			WithName("my-ldap-idp2").
+			WithResourceUID("my-ldap-uid-idp2").
+			Build()).
+		BuildDynamicUpstreamIDPProvider()
+
+	upstreamHealth := upstreamhealth.NewTracker(clocktesting.NewFakeClock(time.Now()))
+	for range upstreamHealth.FailureThreshold {
+		upstreamHealth.RecordProbe("my-ldap-uid-idp1", errors.New("some probe error"))
+	}
+
+	subject := NewFederationDomainIdentityProvidersListerFinder(fdIssuer, wrappedLister, upstreamHealth)
+
+	providers := subject.GetIdentityProviders()
+	require.Len(t, providers, 2)
+	require.Equal(t, "my-ldap-idp1", providers[0].GetDisplayName())
+	require.Equal(t, "my-ldap-idp2", providers[1].GetDisplayName())
+
+	unavailableIDP, err := subject.FindUpstreamIDPByDisplayName("my-ldap-idp1")
+	require.NoError(t, err)
+	_, _, err = unavailableIDP.Login(context.Background(), "some-username", "some-password")
+	require.ErrorIs(t, err, upstreamhealth.ErrUpstreamUnavailable)
+	_, err = unavailableIDP.UpstreamRefresh(context.Background(), &resolvedprovider.Identity{})
+	require.ErrorIs(t, err, upstreamhealth.ErrUpstreamUnavailable)
+}
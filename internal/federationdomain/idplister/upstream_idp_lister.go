@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package idplister
@@ -23,9 +23,24 @@ type UpstreamGitHubIdentityProviderLister interface {
 	GetGitHubIdentityProviders() []upstreamprovider.UpstreamGithubIdentityProviderI
 }
 
+type UpstreamSAMLIdentityProviderLister interface {
+	GetSAMLIdentityProviders() []upstreamprovider.UpstreamSAMLIdentityProviderI
+}
+
+type UpstreamOAuth2IdentityProviderLister interface {
+	GetOAuth2IdentityProviders() []upstreamprovider.UpstreamOAuth2IdentityProviderI
+}
+
+type UpstreamLocalUserIdentityProviderLister interface {
+	GetLocalUserIdentityProviders() []upstreamprovider.UpstreamLocalUserIdentityProviderI
+}
+
 type UpstreamIdentityProvidersLister interface {
 	UpstreamOIDCIdentityProvidersLister
 	UpstreamLDAPIdentityProvidersLister
 	UpstreamActiveDirectoryIdentityProviderLister
 	UpstreamGitHubIdentityProviderLister
+	UpstreamSAMLIdentityProviderLister
+	UpstreamOAuth2IdentityProviderLister
+	UpstreamLocalUserIdentityProviderLister
 }
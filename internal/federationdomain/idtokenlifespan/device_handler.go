@@ -0,0 +1,97 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package idtokenlifespan
+
+import (
+	"context"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	errorsx "github.com/pkg/errors"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+)
+
+// OpenIDConnectDeviceFactory is similar to the function of the same name in the fosite compose package,
+// except it allows wrapping the IDTokenLifespanProvider. It also does not need any OpenID Connect
+// session storage, because the device code session already holds the downstream session, which
+// the device code token endpoint handler has copied onto the access request by the time that
+// this handler runs.
+func OpenIDConnectDeviceFactory(config fosite.Configurator, _ any, strategy any) any {
+	return &openIDConnectDeviceHandler{newOpenIDConnectGrantHandler(config, strategy, fosite.GrantTypeDeviceCode)}
+}
+
+// OpenIDConnectClientCredentialsFactory is like OpenIDConnectDeviceFactory, except that it issues ID tokens
+// during the client_credentials grant. By the time that this handler runs, the token endpoint has already
+// put the service identity of the client into the session on the access request.
+func OpenIDConnectClientCredentialsFactory(config fosite.Configurator, _ any, strategy any) any {
+	return &openIDConnectClientCredentialsHandler{newOpenIDConnectGrantHandler(config, strategy, fosite.GrantTypeClientCredentials)}
+}
+
+// Fosite ignores a token endpoint handler when another handler of the same type was already registered,
+// so each grant type needs its own handler type.
+type openIDConnectDeviceHandler struct{ *openIDConnectGrantHandler }
+
+type openIDConnectClientCredentialsHandler struct{ *openIDConnectGrantHandler }
+
+func newOpenIDConnectGrantHandler(config fosite.Configurator, strategy any, grantType fosite.GrantType) *openIDConnectGrantHandler {
+	return &openIDConnectGrantHandler{
+		idTokenHandleHelper: &openid.IDTokenHandleHelper{
+			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
+		},
+		config:    &contextAwareIDTokenLifespanProvider{DelegateConfig: config},
+		grantType: grantType,
+	}
+}
+
+// openIDConnectGrantHandler issues ID tokens at the token endpoint for a grant type which does not have
+// any OpenID Connect session storage of its own.
+type openIDConnectGrantHandler struct {
+	idTokenHandleHelper *openid.IDTokenHandleHelper
+	config              fosite.IDTokenLifespanProvider
+	grantType           fosite.GrantType
+}
+
+var (
+	_ fosite.TokenEndpointHandler = (*openIDConnectDeviceHandler)(nil)
+	_ fosite.TokenEndpointHandler = (*openIDConnectClientCredentialsHandler)(nil)
+)
+
+func (c *openIDConnectGrantHandler) HandleTokenEndpointRequest(_ context.Context, _ fosite.AccessRequester) error {
+	return errorsx.WithStack(fosite.ErrUnknownRequest)
+}
+
+func (c *openIDConnectGrantHandler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
+	if !c.CanHandleTokenEndpointRequest(ctx, requester) {
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	if !requester.GetGrantedScopes().Has(oidcapi.ScopeOpenID) {
+		// The client did not ask for an ID token.
+		return errorsx.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	session, ok := requester.GetSession().(openid.Session)
+	if !ok {
+		return errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to generate id token because session must be of type fosite/handler/openid.Session."))
+	}
+
+	claims := session.IDTokenClaims()
+	if claims.Subject == "" {
+		return errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to generate id token because subject is an empty string."))
+	}
+
+	claims.AccessTokenHash = c.idTokenHandleHelper.GetAccessTokenHash(ctx, requester, responder)
+
+	idTokenLifespan := fosite.GetEffectiveLifespan(requester.GetClient(), c.grantType, fosite.IDToken, c.config.GetIDTokenLifespan(ctx))
+	return c.idTokenHandleHelper.IssueExplicitIDToken(ctx, idTokenLifespan, requester, responder)
+}
+
+func (c *openIDConnectGrantHandler) CanSkipClientAuth(_ context.Context, _ fosite.AccessRequester) bool {
+	return false
+}
+
+func (c *openIDConnectGrantHandler) CanHandleTokenEndpointRequest(_ context.Context, requester fosite.AccessRequester) bool {
+	return requester.GetGrantTypes().ExactOne(string(c.grantType))
+}
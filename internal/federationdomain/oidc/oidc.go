@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/felixge/httpsnoop"
@@ -28,6 +29,7 @@ import (
 	"go.pinniped.dev/internal/federationdomain/stateparam"
 	"go.pinniped.dev/internal/federationdomain/strategy"
 	"go.pinniped.dev/internal/federationdomain/timeouts"
+	"go.pinniped.dev/internal/federationdomain/tokenrevocation"
 	"go.pinniped.dev/internal/httputil/httperr"
 	"go.pinniped.dev/internal/plog"
 	"go.pinniped.dev/internal/psession"
@@ -36,14 +38,21 @@ import (
 )
 
 const (
-	WellKnownEndpointPath     = "/.well-known/openid-configuration"
-	AuthorizationEndpointPath = "/oauth2/authorize"
-	TokenEndpointPath         = "/oauth2/token" //nolint:gosec // ignore lint warning that this is a credential
-	CallbackEndpointPath      = "/callback"
-	ChooseIDPEndpointPath     = "/choose_identity_provider"
-	JWKSEndpointPath          = "/jwks.json"
-	PinnipedIDPsPathV1Alpha1  = "/v1alpha1/pinniped_identity_providers"
-	PinnipedLoginPath         = "/login"
+	WellKnownEndpointPath           = "/.well-known/openid-configuration"
+	AuthorizationEndpointPath       = "/oauth2/authorize"
+	TokenEndpointPath               = "/oauth2/token" //nolint:gosec // ignore lint warning that this is a credential
+	DeviceAuthorizationEndpointPath = "/oauth2/device_authorization"
+	DeviceVerificationEndpointPath  = "/device_verification"
+	EndSessionEndpointPath          = "/oauth2/logout"
+	RevocationEndpointPath          = "/oauth2/revoke"
+	IntrospectionEndpointPath       = "/oauth2/introspect"
+	UserInfoEndpointPath            = "/oauth2/userinfo"
+	PushedAuthorizeEndpointPath     = "/oauth2/par"
+	CallbackEndpointPath            = "/callback"
+	ChooseIDPEndpointPath           = "/choose_identity_provider"
+	JWKSEndpointPath                = "/jwks.json"
+	PinnipedIDPsPathV1Alpha1        = "/v1alpha1/pinniped_identity_providers"
+	PinnipedLoginPath               = "/login"
 )
 
 const (
@@ -73,6 +82,11 @@ const (
 	// Supervisor's authorization endpoint should give the browser a new CSRF cookie. We set it to
 	// a week so that it is unlikely to expire during a login.
 	CSRFCookieLifespan = time.Hour * 24 * 7
+
+	// PushedAuthorizeRequestURIPrefix is the prefix of each request_uri returned by the pushed authorization
+	// request endpoint. The authorization endpoint looks up the stored request for a request_uri with this prefix.
+	// This is the prefix recommended by https://datatracker.ietf.org/doc/html/rfc9126#section-2.2.
+	PushedAuthorizeRequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
 )
 
 // Encoder is the encoding side of the securecookie.Codec interface.
@@ -97,14 +111,18 @@ type Codec interface {
 //
 // Keep the JSON to a minimal size because the upstream provider could impose size limitations on
 // the state param.
+//
+// When DeviceUserCodeSignature is set, then the login is being performed on behalf of a pending device
+// authorization request (RFC 8628) instead of a downstream authorization request, and AuthParams will be empty.
 type UpstreamStateParamData struct {
-	AuthParams    string              `json:"p"`
-	UpstreamName  string              `json:"u"`
-	UpstreamType  string              `json:"t"`
-	Nonce         nonce.Nonce         `json:"n"`
-	CSRFToken     csrftoken.CSRFToken `json:"c"`
-	PKCECode      pkce.Code           `json:"k"`
-	FormatVersion string              `json:"v"`
+	AuthParams              string              `json:"p"`
+	UpstreamName            string              `json:"u"`
+	UpstreamType            string              `json:"t"`
+	Nonce                   nonce.Nonce         `json:"n"`
+	CSRFToken               csrftoken.CSRFToken `json:"c"`
+	PKCECode                pkce.Code           `json:"k"`
+	DeviceUserCodeSignature string              `json:"d,omitempty"`
+	FormatVersion           string              `json:"v"`
 }
 
 // DefaultOIDCTimeoutsConfiguration returns the default timeouts for the Supervisor server.
@@ -150,6 +168,14 @@ func DefaultOIDCTimeoutsConfiguration() timeouts.Configuration {
 	// its authcode for tokens.
 	authorizationCodeLifespan := 10 * time.Minute
 
+	// Give the same generous amount of time for the end user to approve a device
+	// authorization request in their browser while the device polls the token endpoint.
+	deviceAndUserCodeLifespan := 10 * time.Minute
+
+	// A pushed authorization request should be used right away by the client, but allow some
+	// extra time in case the end user is shown the IDP chooser page before it gets used.
+	pushedAuthorizeRequestLifespan := 5 * time.Minute
+
 	// This is intended to give a very short amount of time to allow the client to
 	// use the access token to exchange for cluster-scoped ID token(s). After this
 	// time runs out, they will need to perform a refresh to get a new tokens,
@@ -178,6 +204,10 @@ func DefaultOIDCTimeoutsConfiguration() timeouts.Configuration {
 
 		AuthorizeCodeLifespan: authorizationCodeLifespan,
 
+		DeviceAndUserCodeLifespan: deviceAndUserCodeLifespan,
+
+		PushedAuthorizeRequestLifespan: pushedAuthorizeRequestLifespan,
+
 		AccessTokenLifespan: accessTokenLifespan,
 		OverrideDefaultAccessTokenLifespan: func(_ fosite.AccessRequester) (time.Duration, bool) {
 			// Not currently overriding the defaults.
@@ -220,6 +250,18 @@ func DefaultOIDCTimeoutsConfiguration() timeouts.Configuration {
 			return authorizationCodeLifespan + storageExtraLifetime
 		},
 
+		DeviceCodeSessionStorageLifetime: func(_ fosite.Requester) time.Duration {
+			return deviceAndUserCodeLifespan + refreshTokenLifespan
+		},
+
+		UserCodeSessionStorageLifetime: func(_ fosite.Requester) time.Duration {
+			return deviceAndUserCodeLifespan + storageExtraLifetime
+		},
+
+		PushedAuthorizeRequestSessionStorageLifetime: func(_ fosite.Requester) time.Duration {
+			return pushedAuthorizeRequestLifespan + storageExtraLifetime
+		},
+
 		AccessTokenSessionStorageLifetime: func(_ fosite.Requester) time.Duration {
 			return refreshTokenLifespan + accessTokenLifespan
 		},
@@ -240,10 +282,18 @@ func FositeOauth2Helper(
 	oauthConfig := &fosite.Config{
 		IDTokenIssuer: issuer,
 
-		AuthorizeCodeLifespan: timeoutsConfiguration.AuthorizeCodeLifespan,
-		IDTokenLifespan:       timeoutsConfiguration.IDTokenLifespan,
-		AccessTokenLifespan:   timeoutsConfiguration.AccessTokenLifespan,
-		RefreshTokenLifespan:  timeoutsConfiguration.RefreshTokenLifespan,
+		AuthorizeCodeLifespan:     timeoutsConfiguration.AuthorizeCodeLifespan,
+		DeviceAndUserCodeLifespan: timeoutsConfiguration.DeviceAndUserCodeLifespan,
+		IDTokenLifespan:           timeoutsConfiguration.IDTokenLifespan,
+		AccessTokenLifespan:       timeoutsConfiguration.AccessTokenLifespan,
+		RefreshTokenLifespan:      timeoutsConfiguration.RefreshTokenLifespan,
+
+		// The page where the end user enters their user code during the device authorization grant.
+		DeviceVerificationURL: issuer + DeviceVerificationEndpointPath,
+
+		// The request_uri returned by the pushed authorization request endpoint, which the authorization endpoint accepts.
+		PushedAuthorizeRequestURIPrefix: PushedAuthorizeRequestURIPrefix,
+		PushedAuthorizeContextLifespan:  timeoutsConfiguration.PushedAuthorizeRequestLifespan,
 
 		ScopeStrategy: fosite.ExactScopeStrategy,
 		EnforcePKCE:   true,
@@ -266,14 +316,22 @@ func FositeOauth2Helper(
 
 		// defaults to using BCrypt when nil
 		ClientSecretsHasher: nil,
+
+		// The audience of client assertions for the private_key_jwt client authentication method must be the token endpoint.
+		TokenURL: issuer + TokenEndpointPath,
+		// Fetches the JWKS of clients which use the private_key_jwt client authentication method with a JWKS URI.
+		JWKSFetcherStrategy: sharedJWKSFetcherStrategy(),
 	}
 
+	// Note that Fosite requires the HMAC secret to be at least 32 bytes.
+	hmacStrategy := strategy.NewDynamicOauth2HMACStrategy(oauthConfig, hmacSecretOfLengthAtLeast32Func)
+
 	oAuth2Provider := compose.Compose(
 		oauthConfig,
 		oauthStore,
 		&compose.CommonStrategy{
-			// Note that Fosite requires the HMAC secret to be at least 32 bytes.
-			CoreStrategy:               strategy.NewDynamicOauth2HMACStrategy(oauthConfig, hmacSecretOfLengthAtLeast32Func),
+			CoreStrategy:               hmacStrategy,
+			RFC8628CodeStrategy:        hmacStrategy,
 			OpenIDConnectTokenStrategy: strategy.NewDynamicOpenIDConnectECDSAStrategy(oauthConfig, jwksProvider),
 		},
 		compose.OAuth2AuthorizeExplicitFactory,
@@ -283,12 +341,45 @@ func FositeOauth2Helper(
 		// Use a custom factory to allow selective overrides of the ID token lifespan during refresh.
 		idtokenlifespan.OpenIDConnectRefreshFactory,
 		compose.OAuth2PKCEFactory,
+		// Handle the device authorization endpoint and the "urn:ietf:params:oauth:grant-type:device_code" grant type.
+		compose.RFC8628DeviceFactory,
+		compose.RFC8628DeviceAuthorizationTokenFactory,
+		// Use a custom factory to issue ID tokens during the device code grant.
+		idtokenlifespan.OpenIDConnectDeviceFactory,
+		// Handle the "client_credentials" grant type, and use a custom factory to issue ID tokens during that grant.
+		compose.OAuth2ClientCredentialsGrantFactory,
+		idtokenlifespan.OpenIDConnectClientCredentialsFactory,
 		tokenexchange.HandlerFactory, // handle the "urn:ietf:params:oauth:grant-type:token-exchange" grant type
+		// Handle the revocation endpoint from RFC 7009 for downstream access and refresh tokens, and use a custom
+		// factory to record whether any token storage was revoked.
+		tokenrevocation.OAuth2TokenRevocationFactory,
+		// Handle the introspection endpoint from RFC 7662 for downstream access and refresh tokens.
+		compose.OAuth2TokenIntrospectionFactory,
+		// Handle the pushed authorization request endpoint from RFC 9126.
+		compose.PushedAuthorizeHandlerFactory,
 	)
 
+	// Authenticate clients which use the tls_client_auth method by their TLS client certificates.
+	// All other client authentication methods are handled by fosite's default strategy.
+	clientManager, isClientManager := oauthStore.(fosite.ClientManager)
+	fositeProvider, isFosite := oAuth2Provider.(*fosite.Fosite)
+	if isClientManager && isFosite {
+		oauthConfig.ClientAuthenticationStrategy = clientregistry.NewClientAuthenticationStrategy(
+			clientManager, fositeProvider.DefaultClientAuthenticationStrategy)
+	}
+
 	return oAuth2Provider
 }
 
+// sharedJWKSFetcherStrategy returns a single fosite.JWKSFetcherStrategy to be shared by all FederationDomains.
+// Each instance starts its own cache goroutines, so avoid creating a new one every time that the
+// FederationDomains are reconfigured.
+//
+//nolint:gochecknoglobals
+var sharedJWKSFetcherStrategy = sync.OnceValue(func() fosite.JWKSFetcherStrategy {
+	return fosite.NewDefaultJWKSFetcherStrategy()
+})
+
 // FositeErrorForLog generates a list of information about the provided Fosite error that can be
 // passed to a plog function (e.g., plog.Info()).
 //
@@ -317,14 +408,14 @@ func FositeErrorForLog(err error) []any {
 	return keysAndValues
 }
 
-func GrantScopeIfRequested(authorizeRequester fosite.AuthorizeRequester, scopeName string) {
-	if ScopeWasRequested(authorizeRequester, scopeName) {
-		authorizeRequester.GrantScope(scopeName)
+func GrantScopeIfRequested(requester fosite.Requester, scopeName string) {
+	if ScopeWasRequested(requester, scopeName) {
+		requester.GrantScope(scopeName)
 	}
 }
 
-func ScopeWasRequested(authorizeRequester fosite.AuthorizeRequester, scopeName string) bool {
-	for _, scope := range authorizeRequester.GetRequestedScopes() {
+func ScopeWasRequested(requester fosite.Requester, scopeName string) bool {
+	for _, scope := range requester.GetRequestedScopes() {
 		if scope == scopeName {
 			return true
 		}
@@ -368,10 +459,15 @@ func readCSRFCookie(r *http.Request, cookieDecoder Decoder) (csrftoken.CSRFToken
 }
 
 func readStateParam(r *http.Request, stateDecoder Decoder) (string, *UpstreamStateParamData, error) {
+	var encodedState string
 	if r.Method == http.MethodPost {
-		encodedState := r.PostFormValue("state")
+		encodedState = r.PostFormValue("state")
+		if encodedState == "" {
+			// SAML identity providers which use the HTTP-POST binding return the state param as the RelayState.
+			encodedState = r.PostFormValue("RelayState")
+		}
 	} else {
-		encodedState := r.FormValue("state")
+		encodedState = r.FormValue("state")
 	}
 
 	if encodedState == "" {
@@ -1,12 +1,18 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidcclientvalidator
 
 import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 
+	oldjosev3 "github.com/go-jose/go-jose/v3" // fosite uses this older version of jose for client JSON Web Key Sets
 	"golang.org/x/crypto/bcrypt"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,22 +20,29 @@ import (
 	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
 	"go.pinniped.dev/internal/controller/conditionsutil"
+	"go.pinniped.dev/internal/controller/tlsconfigutil"
 	"go.pinniped.dev/internal/oidcclientsecretstorage"
 )
 
 const (
 	DefaultMinBcryptCost = 12
 
-	clientSecretExists     = "ClientSecretExists"
-	allowedGrantTypesValid = "AllowedGrantTypesValid"
-	allowedScopesValid     = "AllowedScopesValid"
+	clientSecretExists        = "ClientSecretExists"
+	allowedGrantTypesValid    = "AllowedGrantTypesValid"
+	allowedScopesValid        = "AllowedScopesValid"
+	clientAuthenticationValid = "ClientAuthenticationValid"
 
 	reasonMissingRequiredValue     = "MissingRequiredValue"
 	reasonNoClientSecretFound      = "NoClientSecretFound"
 	reasonInvalidClientSecretFound = "InvalidClientSecretFound"
+	reasonInvalidClientAuth        = "InvalidClientAuthentication"
 
-	allowedGrantTypesFieldName = "allowedGrantTypes"
-	allowedScopesFieldName     = "allowedScopes"
+	allowedGrantTypesFieldName    = "allowedGrantTypes"
+	allowedScopesFieldName        = "allowedScopes"
+	serviceIdentityFieldName      = "serviceIdentity"
+	clientAuthMethodFieldName     = "clientAuthentication.method"
+	clientAuthPrivateKeyFieldName = "clientAuthentication.privateKeyJWT"
+	clientAuthTLSFieldName        = "clientAuthentication.tlsClientAuth"
 )
 
 // Validate validates the OIDCClient and its corresponding client secret storage Secret.
@@ -38,11 +51,12 @@ const (
 // along with a slice of conditions containing more details, and the list of client secrets in the
 // case that the client was valid.
 func Validate(oidcClient *supervisorconfigv1alpha1.OIDCClient, secret *corev1.Secret, minBcryptCost int) (bool, []*metav1.Condition, []string) {
-	conds := make([]*metav1.Condition, 0, 3)
+	conds := make([]*metav1.Condition, 0, 4)
 
-	conds, clientSecrets := validateSecret(secret, conds, minBcryptCost)
+	conds, clientSecrets := validateSecret(secret, ClientAuthenticationMethod(oidcClient), conds, minBcryptCost)
 	conds = validateAllowedGrantTypes(oidcClient, conds)
 	conds = validateAllowedScopes(oidcClient, conds)
+	conds = validateClientAuthentication(oidcClient, conds)
 
 	valid := true
 	for _, cond := range conds {
@@ -96,7 +110,7 @@ func validateAllowedScopes(oidcClient *supervisorconfigv1alpha1.OIDCClient, cond
 
 // validateAllowedGrantTypes checks if allowedGrantTypes is valid on the OIDCClient.
 func validateAllowedGrantTypes(oidcClient *supervisorconfigv1alpha1.OIDCClient, conditions []*metav1.Condition) []*metav1.Condition {
-	m := make([]string, 0, 3)
+	m := make([]string, 0, 5)
 
 	if !allowedGrantTypesContains(oidcClient, oidcapi.GrantTypeAuthorizationCode) {
 		m = append(m, fmt.Sprintf("%q must always be included in %q",
@@ -110,6 +124,14 @@ func validateAllowedGrantTypes(oidcClient *supervisorconfigv1alpha1.OIDCClient,
 		m = append(m, fmt.Sprintf("%q must be included in %q when %q is included in %q",
 			oidcapi.GrantTypeTokenExchange, allowedGrantTypesFieldName, oidcapi.ScopeRequestAudience, allowedScopesFieldName))
 	}
+	if allowedGrantTypesContains(oidcClient, oidcapi.GrantTypeClientCredentials) && oidcClient.Spec.ServiceIdentity == nil {
+		m = append(m, fmt.Sprintf("%q must be configured when %q is included in %q",
+			serviceIdentityFieldName, oidcapi.GrantTypeClientCredentials, allowedGrantTypesFieldName))
+	}
+	if oidcClient.Spec.ServiceIdentity != nil && !allowedGrantTypesContains(oidcClient, oidcapi.GrantTypeClientCredentials) {
+		m = append(m, fmt.Sprintf("%q must be included in %q when %q is configured",
+			oidcapi.GrantTypeClientCredentials, allowedGrantTypesFieldName, serviceIdentityFieldName))
+	}
 
 	if len(m) == 0 {
 		conditions = append(conditions, &metav1.Condition{
@@ -130,11 +152,141 @@ func validateAllowedGrantTypes(oidcClient *supervisorconfigv1alpha1.OIDCClient,
 	return conditions
 }
 
+// ClientAuthenticationMethod returns the client authentication method of the OIDCClient, applying the default
+// when it was not configured.
+func ClientAuthenticationMethod(oidcClient *supervisorconfigv1alpha1.OIDCClient) supervisorconfigv1alpha1.ClientAuthenticationMethod {
+	if oidcClient.Spec.ClientAuthentication == nil || oidcClient.Spec.ClientAuthentication.Method == "" {
+		return supervisorconfigv1alpha1.ClientAuthenticationMethodClientSecretBasic
+	}
+	return oidcClient.Spec.ClientAuthentication.Method
+}
+
+// ParseJWKS parses the inline JSON Web Key Set of the private_key_jwt client authentication method.
+// It returns an error when it cannot be parsed, or when it does not contain any public signing keys.
+func ParseJWKS(jwks string) (*oldjosev3.JSONWebKeySet, error) {
+	var keySet oldjosev3.JSONWebKeySet
+	if err := json.Unmarshal([]byte(jwks), &keySet); err != nil {
+		return nil, fmt.Errorf("could not parse JSON Web Key Set: %w", err)
+	}
+	for _, key := range keySet.Keys {
+		if key.Use == "sig" && key.IsPublic() {
+			return &keySet, nil
+		}
+	}
+	return nil, errors.New(`JSON Web Key Set does not contain any public keys with "use" set to "sig"`)
+}
+
+// ParseTLSClientAuthCABundle parses the CA bundle of the tls_client_auth client authentication method.
+func ParseTLSClientAuthCABundle(certificateAuthorityData string) (*x509.CertPool, error) {
+	decodedBytes, err := base64.StdEncoding.DecodeString(certificateAuthorityData)
+	if err != nil {
+		return nil, err
+	}
+	caBundle, ok := tlsconfigutil.NewCABundle(decodedBytes)
+	if !ok || caBundle.CertPool() == nil {
+		return nil, fmt.Errorf("no base64-encoded PEM certificates found in %d bytes of data (PEM certificates must begin with \"-----BEGIN CERTIFICATE-----\")",
+			len(certificateAuthorityData))
+	}
+	return caBundle.CertPool(), nil
+}
+
+// validateClientAuthentication checks if clientAuthentication is valid on the OIDCClient.
+func validateClientAuthentication(oidcClient *supervisorconfigv1alpha1.OIDCClient, conditions []*metav1.Condition) []*metav1.Condition {
+	m := make([]string, 0, 4)
+
+	method := ClientAuthenticationMethod(oidcClient)
+	var privateKeyJWT *supervisorconfigv1alpha1.OIDCClientPrivateKeyJWT
+	var tlsClientAuth *supervisorconfigv1alpha1.OIDCClientTLSClientAuth
+	if oidcClient.Spec.ClientAuthentication != nil {
+		privateKeyJWT = oidcClient.Spec.ClientAuthentication.PrivateKeyJWT
+		tlsClientAuth = oidcClient.Spec.ClientAuthentication.TLSClientAuth
+	}
+
+	switch {
+	case method == supervisorconfigv1alpha1.ClientAuthenticationMethodPrivateKeyJWT && privateKeyJWT == nil:
+		m = append(m, fmt.Sprintf("%q must be configured when %q is %q",
+			clientAuthPrivateKeyFieldName, clientAuthMethodFieldName, method))
+	case method != supervisorconfigv1alpha1.ClientAuthenticationMethodPrivateKeyJWT && privateKeyJWT != nil:
+		m = append(m, fmt.Sprintf("%q must not be configured when %q is %q",
+			clientAuthPrivateKeyFieldName, clientAuthMethodFieldName, method))
+	case privateKeyJWT != nil:
+		m = append(m, validatePrivateKeyJWT(privateKeyJWT)...)
+	}
+
+	switch {
+	case method == supervisorconfigv1alpha1.ClientAuthenticationMethodTLSClientAuth && tlsClientAuth == nil:
+		m = append(m, fmt.Sprintf("%q must be configured when %q is %q",
+			clientAuthTLSFieldName, clientAuthMethodFieldName, method))
+	case method != supervisorconfigv1alpha1.ClientAuthenticationMethodTLSClientAuth && tlsClientAuth != nil:
+		m = append(m, fmt.Sprintf("%q must not be configured when %q is %q",
+			clientAuthTLSFieldName, clientAuthMethodFieldName, method))
+	case tlsClientAuth != nil:
+		if _, err := ParseTLSClientAuthCABundle(tlsClientAuth.CertificateAuthorityData); err != nil {
+			m = append(m, fmt.Sprintf("%q is invalid: %s", clientAuthTLSFieldName+".certificateAuthorityData", err.Error()))
+		}
+	}
+
+	if len(m) == 0 {
+		conditions = append(conditions, &metav1.Condition{
+			Type:    clientAuthenticationValid,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditionsutil.ReasonSuccess,
+			Message: fmt.Sprintf("%q is valid", "clientAuthentication"),
+		})
+	} else {
+		conditions = append(conditions, &metav1.Condition{
+			Type:    clientAuthenticationValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonInvalidClientAuth,
+			Message: strings.Join(m, "; "),
+		})
+	}
+
+	return conditions
+}
+
+func validatePrivateKeyJWT(privateKeyJWT *supervisorconfigv1alpha1.OIDCClientPrivateKeyJWT) []string {
+	switch {
+	case privateKeyJWT.JWKS == "" && privateKeyJWT.JWKSURI == "":
+		return []string{fmt.Sprintf("exactly one of %q or %q must be configured",
+			clientAuthPrivateKeyFieldName+".jwks", clientAuthPrivateKeyFieldName+".jwksURI")}
+	case privateKeyJWT.JWKS != "" && privateKeyJWT.JWKSURI != "":
+		return []string{fmt.Sprintf("only one of %q or %q may be configured",
+			clientAuthPrivateKeyFieldName+".jwks", clientAuthPrivateKeyFieldName+".jwksURI")}
+	case privateKeyJWT.JWKS != "":
+		if _, err := ParseJWKS(privateKeyJWT.JWKS); err != nil {
+			return []string{fmt.Sprintf("%q is invalid: %s", clientAuthPrivateKeyFieldName+".jwks", err.Error())}
+		}
+	default:
+		if u, err := url.Parse(privateKeyJWT.JWKSURI); err != nil || u.Scheme != "https" || u.Host == "" {
+			return []string{fmt.Sprintf("%q is invalid: must be an https URL", clientAuthPrivateKeyFieldName+".jwksURI")}
+		}
+	}
+	return nil
+}
+
 // validateSecret checks if the client secret storage Secret is valid and contains at least one client secret.
 // It returns the updated conditions slice along with the client secrets found in that case that it is valid.
-func validateSecret(secret *corev1.Secret, conditions []*metav1.Condition, minBcryptCost int) ([]*metav1.Condition, []string) {
+// Client secrets are only required when the client authenticates using the client_secret_basic method.
+func validateSecret(
+	secret *corev1.Secret,
+	method supervisorconfigv1alpha1.ClientAuthenticationMethod,
+	conditions []*metav1.Condition,
+	minBcryptCost int,
+) ([]*metav1.Condition, []string) {
 	emptyList := []string{}
 
+	if method != supervisorconfigv1alpha1.ClientAuthenticationMethodClientSecretBasic {
+		// Valid: client secrets are not used by the other client authentication methods.
+		conditions = append(conditions, &metav1.Condition{
+			Type:    clientSecretExists,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditionsutil.ReasonSuccess,
+			Message: fmt.Sprintf("client secrets are not required when %q is %q", clientAuthMethodFieldName, method),
+		})
+		return conditions, emptyList
+	}
+
 	if secret == nil {
 		// Invalid: no storage Secret found.
 		conditions = append(conditions, &metav1.Condition{
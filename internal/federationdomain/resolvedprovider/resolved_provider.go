@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package resolvedprovider
@@ -54,6 +54,13 @@ type IdentityLoginExtras struct {
 
 	// Login warnings to show the user after they exchange their downstream authcode, if any.
 	Warnings []string
+
+	// How the user authenticated to the upstream identity provider, if known, and the raw identity data from the
+	// upstream identity provider. When set, it will be made available to identity transformations, its ACR and AMR
+	// will be included in the downstream ID token as the "acr" and "amr" claims, and its Upstream data will be
+	// stored in the user's session for use during refreshes. Its Request will be ignored, since the downstream
+	// request is not known to the identity provider.
+	AuthenticationContext *idtransform.AuthenticationContext
 }
 
 // RefreshedIdentity represents the parts of an identity that an identity provider may update
@@ -78,6 +85,17 @@ type RefreshedIdentity struct {
 	// Set this to be the potentially updated IDP-specific session data. If no updates were required, then
 	// set this to nil.
 	IDPSpecificSessionData any
+
+	// The downstream additional claims determined for this user in an identity provider-specific way.
+	// If the identity provider does not refresh additional claims, then set this to nil, and the user's old
+	// additional claims from their session will be used again. Returning an empty map will mean that the
+	// additional claims will be removed from the user's session.
+	DownstreamAdditionalClaims map[string]any
+
+	// The raw identity data from the upstream identity provider, which will be made available to identity
+	// transformations. If the identity provider could not get new data from the upstream during the refresh,
+	// then set this to nil, and the user's old upstream data from their session will be used again.
+	UpstreamData map[string]any
 }
 
 // UpstreamAuthorizeRequestState is the state capturing the downstream authorization request, used as a parameter to
@@ -114,6 +132,10 @@ type FederationDomainResolvedIdentityProvider interface {
 	// FederationDomain for this identity provider.
 	GetTransforms() *idtransform.TransformationPipeline
 
+	// GetTokenExchangeTransforms returns the compiled version of the additional identity transformations and policies
+	// configured on the FederationDomain for this identity provider, which are only applied during token exchanges.
+	GetTokenExchangeTransforms() idtransform.TokenExchangePipelines
+
 	// CloneIDPSpecificSessionDataFromSession should reach into the provided session and return a clone
 	// of the field which is specific to the upstream identity provider type. If the session's field is
 	// nil, then return nil.
@@ -130,7 +152,7 @@ type FederationDomainResolvedIdentityProvider interface {
 
 	// UpstreamAuthorizeRedirectURL returns the URL to which the user's browser can be redirected to continue
 	// the downstream browser-based authorization flow. Returned errors should be of type fosite.RFC6749Error.
-	UpstreamAuthorizeRedirectURL(state *UpstreamAuthorizeRequestState, downstreamIssuerURL string) (string, error)
+	UpstreamAuthorizeRedirectURL(ctx context.Context, state *UpstreamAuthorizeRequestState, downstreamIssuerURL string) (string, error)
 
 	// LoginFromCallback handles an OAuth-style callback in a browser-based flow. This function should complete
 	// the authorization with the upstream identity provider using the authCode, extract their upstream
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package resolvedgithub
@@ -26,10 +26,11 @@ import (
 // been resolved dynamically based on the currently loaded IDP CRs to include the provider.UpstreamGitHubIdentityProviderI
 // and other metadata about the provider.
 type FederationDomainResolvedGitHubIdentityProvider struct {
-	DisplayName         string
-	Provider            upstreamprovider.UpstreamGithubIdentityProviderI
-	SessionProviderType psession.ProviderType
-	Transforms          *idtransform.TransformationPipeline
+	DisplayName             string
+	Provider                upstreamprovider.UpstreamGithubIdentityProviderI
+	SessionProviderType     psession.ProviderType
+	Transforms              *idtransform.TransformationPipeline
+	TokenExchangeTransforms idtransform.TokenExchangePipelines
 }
 
 var _ resolvedprovider.FederationDomainResolvedIdentityProvider = (*FederationDomainResolvedGitHubIdentityProvider)(nil)
@@ -51,13 +52,17 @@ func (p *FederationDomainResolvedGitHubIdentityProvider) GetIDPDiscoveryType() v
 }
 
 func (p *FederationDomainResolvedGitHubIdentityProvider) GetIDPDiscoveryFlows() []v1alpha1.IDPFlow {
-	return []v1alpha1.IDPFlow{v1alpha1.IDPFlowBrowserAuthcode}
+	return []v1alpha1.IDPFlow{v1alpha1.IDPFlowBrowserAuthcode, v1alpha1.IDPFlowDeviceCode}
 }
 
 func (p *FederationDomainResolvedGitHubIdentityProvider) GetTransforms() *idtransform.TransformationPipeline {
 	return p.Transforms
 }
 
+func (p *FederationDomainResolvedGitHubIdentityProvider) GetTokenExchangeTransforms() idtransform.TokenExchangePipelines {
+	return p.TokenExchangeTransforms
+}
+
 func (p *FederationDomainResolvedGitHubIdentityProvider) CloneIDPSpecificSessionDataFromSession(session *psession.CustomSessionData) any {
 	if session.GitHub == nil {
 		return nil
@@ -70,6 +75,7 @@ func (p *FederationDomainResolvedGitHubIdentityProvider) ApplyIDPSpecificSession
 }
 
 func (p *FederationDomainResolvedGitHubIdentityProvider) UpstreamAuthorizeRedirectURL(
+	_ context.Context,
 	state *resolvedprovider.UpstreamAuthorizeRequestState,
 	downstreamIssuerURL string,
 ) (string, error) {
@@ -134,6 +140,7 @@ func (p *FederationDomainResolvedGitHubIdentityProvider) LoginFromCallback(
 		&resolvedprovider.IdentityLoginExtras{
 			DownstreamAdditionalClaims: nil, // not using this for GitHub
 			Warnings:                   nil, // not using this for GitHub
+			AuthenticationContext:      &idtransform.AuthenticationContext{Upstream: user.UpstreamData},
 		},
 		nil // no error
 }
@@ -167,6 +174,7 @@ func (p *FederationDomainResolvedGitHubIdentityProvider) UpstreamRefresh(
 	return &resolvedprovider.RefreshedIdentity{
 		UpstreamUsername:       refreshedUserInfo.Username,
 		UpstreamGroups:         refreshedUserInfo.Groups,
+		UpstreamData:           refreshedUserInfo.UpstreamData,
 		IDPSpecificSessionData: nil, // nil means that no update to the GitHub-specific portion of the session data is required
 	}, nil
 }
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package resolvedgithub
@@ -19,6 +19,7 @@ import (
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
 	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
 	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/idtransform"
 	"go.pinniped.dev/internal/psession"
 	"go.pinniped.dev/internal/setutil"
 	"go.pinniped.dev/internal/testutil/oidctestutil"
@@ -28,6 +29,9 @@ import (
 
 func TestFederationDomainResolvedGitHubIdentityProvider(t *testing.T) {
 	transforms := transformtestutil.NewRejectAllAuthPipeline(t)
+	tokenExchangeTransforms := idtransform.TokenExchangePipelines{
+		idtransform.NewTokenExchangePipeline([]string{"some-cluster"}, nil, transformtestutil.NewRejectAllAuthPipeline(t)),
+	}
 
 	provider := upstreamgithub.New(upstreamgithub.ProviderConfig{
 		Name:                 "fake-provider-config",
@@ -51,18 +55,20 @@ func TestFederationDomainResolvedGitHubIdentityProvider(t *testing.T) {
 	})
 
 	subject := FederationDomainResolvedGitHubIdentityProvider{
-		DisplayName:         "fake-display-name",
-		Provider:            provider,
-		SessionProviderType: psession.ProviderTypeGitHub,
-		Transforms:          transforms,
+		DisplayName:             "fake-display-name",
+		Provider:                provider,
+		SessionProviderType:     psession.ProviderTypeGitHub,
+		Transforms:              transforms,
+		TokenExchangeTransforms: tokenExchangeTransforms,
 	}
 
 	require.Equal(t, "fake-display-name", subject.GetDisplayName())
 	require.Equal(t, provider, subject.GetProvider())
 	require.Equal(t, psession.ProviderTypeGitHub, subject.GetSessionProviderType())
 	require.Equal(t, idpdiscoveryv1alpha1.IDPTypeGitHub, subject.GetIDPDiscoveryType())
-	require.Equal(t, []idpdiscoveryv1alpha1.IDPFlow{idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode}, subject.GetIDPDiscoveryFlows())
+	require.Equal(t, []idpdiscoveryv1alpha1.IDPFlow{idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode, idpdiscoveryv1alpha1.IDPFlowDeviceCode}, subject.GetIDPDiscoveryFlows())
 	require.Equal(t, transforms, subject.GetTransforms())
+	require.Equal(t, tokenExchangeTransforms, subject.GetTokenExchangeTransforms())
 
 	originalCustomSession := &psession.CustomSessionData{
 		Username:         "fake-username",
@@ -88,6 +94,7 @@ func TestFederationDomainResolvedGitHubIdentityProvider(t *testing.T) {
 	}, customSessionToBeMutated)
 
 	redirectURL, err := subject.UpstreamAuthorizeRedirectURL(
+		context.Background(),
 		&resolvedprovider.UpstreamAuthorizeRequestState{
 			EncodedStateParam: "encodedStateParam12345",
 			PKCE:              "pkce6789",
@@ -138,6 +145,7 @@ func TestLoginFromCallback(t *testing.T) {
 					Username:          "fake-username",
 					Groups:            []string{"fake-group1", "fake-group2"},
 					DownstreamSubject: "https://fake-downstream-subject",
+					UpstreamData:      map[string]any{"login": "fake-login"},
 				}).
 				Build(),
 			idpDisplayName:           "fake-display-name",
@@ -163,7 +171,11 @@ func TestLoginFromCallback(t *testing.T) {
 					UpstreamAccessToken: "fake-access-token",
 				},
 			},
-			wantExtras: &resolvedprovider.IdentityLoginExtras{},
+			wantExtras: &resolvedprovider.IdentityLoginExtras{
+				AuthenticationContext: &idtransform.AuthenticationContext{
+					Upstream: map[string]any{"login": "fake-login"},
+				},
+			},
 		},
 		{
 			name: "error while exchanging authcode",
@@ -311,6 +323,7 @@ func TestUpstreamRefresh(t *testing.T) {
 					Username:          "refreshed-username",
 					Groups:            []string{"refreshed-group1", "refreshed-group2"},
 					DownstreamSubject: "https://fake-downstream-subject",
+					UpstreamData:      map[string]any{"login": "refreshed-login"},
 				}).
 				Build(),
 			identity: &resolvedprovider.Identity{
@@ -329,6 +342,7 @@ func TestUpstreamRefresh(t *testing.T) {
 			wantRefreshedIdentity: &resolvedprovider.RefreshedIdentity{
 				UpstreamUsername:       "refreshed-username",
 				UpstreamGroups:         []string{"refreshed-group1", "refreshed-group2"},
+				UpstreamData:           map[string]any{"login": "refreshed-login"},
 				IDPSpecificSessionData: nil,
 			},
 		},
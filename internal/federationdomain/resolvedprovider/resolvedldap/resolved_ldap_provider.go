@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package resolvedldap
@@ -29,10 +29,11 @@ import (
 // been resolved dynamically based on the currently loaded IDP CRs to include the provider.UpstreamLDAPIdentityProviderI
 // and other metadata about the provider.
 type FederationDomainResolvedLDAPIdentityProvider struct {
-	DisplayName         string
-	Provider            upstreamprovider.UpstreamLDAPIdentityProviderI
-	SessionProviderType psession.ProviderType
-	Transforms          *idtransform.TransformationPipeline
+	DisplayName             string
+	Provider                upstreamprovider.UpstreamLDAPIdentityProviderI
+	SessionProviderType     psession.ProviderType
+	Transforms              *idtransform.TransformationPipeline
+	TokenExchangeTransforms idtransform.TokenExchangePipelines
 }
 
 var _ resolvedprovider.FederationDomainResolvedIdentityProvider = (*FederationDomainResolvedLDAPIdentityProvider)(nil)
@@ -57,13 +58,17 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) GetIDPDiscoveryType() v1a
 }
 
 func (p *FederationDomainResolvedLDAPIdentityProvider) GetIDPDiscoveryFlows() []v1alpha1.IDPFlow {
-	return []v1alpha1.IDPFlow{v1alpha1.IDPFlowCLIPassword, v1alpha1.IDPFlowBrowserAuthcode}
+	return []v1alpha1.IDPFlow{v1alpha1.IDPFlowCLIPassword, v1alpha1.IDPFlowBrowserAuthcode, v1alpha1.IDPFlowDeviceCode}
 }
 
 func (p *FederationDomainResolvedLDAPIdentityProvider) GetTransforms() *idtransform.TransformationPipeline {
 	return p.Transforms
 }
 
+func (p *FederationDomainResolvedLDAPIdentityProvider) GetTokenExchangeTransforms() idtransform.TokenExchangePipelines {
+	return p.TokenExchangeTransforms
+}
+
 func (p *FederationDomainResolvedLDAPIdentityProvider) CloneIDPSpecificSessionDataFromSession(session *psession.CustomSessionData) any {
 	switch p.GetSessionProviderType() {
 	case psession.ProviderTypeLDAP:
@@ -76,7 +81,7 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) CloneIDPSpecificSessionDa
 			return nil
 		}
 		return session.ActiveDirectory.Clone()
-	case psession.ProviderTypeOIDC, psession.ProviderTypeGitHub: // this is just here to avoid a lint error about not handling all cases
+	case psession.ProviderTypeOIDC, psession.ProviderTypeGitHub, psession.ProviderTypeSAML, psession.ProviderTypeOAuth2, psession.ProviderTypeLocalUser: // this is just here to avoid a lint error about not handling all cases
 		fallthrough
 	default:
 		return nil
@@ -91,7 +96,9 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) ApplyIDPSpecificSessionDa
 	session.LDAP = idpSpecificSessionData.(*psession.LDAPSessionData)
 }
 
-func (p *FederationDomainResolvedLDAPIdentityProvider) UpstreamAuthorizeRedirectURL(state *resolvedprovider.UpstreamAuthorizeRequestState, downstreamIssuerURL string) (string, error) {
+func (p *FederationDomainResolvedLDAPIdentityProvider) UpstreamAuthorizeRedirectURL(
+	_ context.Context,
+	state *resolvedprovider.UpstreamAuthorizeRequestState, downstreamIssuerURL string) (string, error) {
 	loginURL, err := loginurl.URL(downstreamIssuerURL, state.EncodedStateParam, loginurl.ShowNoError)
 	if err != nil {
 		return "", fosite.ErrServerError.WithHint("Server could not formulate login UI URL for redirect.").WithWrap(err)
@@ -145,13 +152,15 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) Login(
 		sessionData = &psession.LDAPSessionData{
 			UserDN:                 authenticateResponse.DN,
 			ExtraRefreshAttributes: authenticateResponse.ExtraRefreshAttributes,
+			AdditionalClaims:       authenticateResponse.AdditionalClaims,
 		}
 	case psession.ProviderTypeActiveDirectory:
 		sessionData = &psession.ActiveDirectorySessionData{
 			UserDN:                 authenticateResponse.DN,
 			ExtraRefreshAttributes: authenticateResponse.ExtraRefreshAttributes,
+			AdditionalClaims:       authenticateResponse.AdditionalClaims,
 		}
-	case psession.ProviderTypeOIDC, psession.ProviderTypeGitHub: // this is just here to avoid a lint error about not handling all cases
+	case psession.ProviderTypeOIDC, psession.ProviderTypeGitHub, psession.ProviderTypeSAML, psession.ProviderTypeOAuth2, psession.ProviderTypeLocalUser: // this is just here to avoid a lint error about not handling all cases
 		fallthrough
 	default:
 		return nil, nil, ErrUnexpectedUpstreamLDAPError.WithWrap(fmt.Errorf("unexpected provider type %q", p.GetSessionProviderType()))
@@ -164,8 +173,9 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) Login(
 			IDPSpecificSessionData: sessionData,
 		},
 		&resolvedprovider.IdentityLoginExtras{
-			DownstreamAdditionalClaims: nil,
+			DownstreamAdditionalClaims: downstreamAdditionalClaims(authenticateResponse.AdditionalClaims),
 			Warnings:                   nil,
+			AuthenticationContext:      &idtransform.AuthenticationContext{Upstream: authenticateResponse.UpstreamData},
 		},
 		nil
 }
@@ -187,6 +197,7 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) UpstreamRefresh(
 ) (refreshedIdentity *resolvedprovider.RefreshedIdentity, err error) {
 	var dn string
 	var additionalAttributes map[string]string
+	var sessionAdditionalClaims *map[string][]string
 
 	switch p.GetSessionProviderType() {
 	case psession.ProviderTypeLDAP:
@@ -197,6 +208,7 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) UpstreamRefresh(
 		}
 		dn = sessionData.UserDN
 		additionalAttributes = sessionData.ExtraRefreshAttributes
+		sessionAdditionalClaims = &sessionData.AdditionalClaims
 	case psession.ProviderTypeActiveDirectory:
 		sessionData, ok := identity.IDPSpecificSessionData.(*psession.ActiveDirectorySessionData)
 		if !ok {
@@ -205,7 +217,8 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) UpstreamRefresh(
 		}
 		dn = sessionData.UserDN
 		additionalAttributes = sessionData.ExtraRefreshAttributes
-	case psession.ProviderTypeOIDC, psession.ProviderTypeGitHub: // this is just here to avoid a lint error about not handling all cases
+		sessionAdditionalClaims = &sessionData.AdditionalClaims
+	case psession.ProviderTypeOIDC, psession.ProviderTypeGitHub, psession.ProviderTypeSAML, psession.ProviderTypeOAuth2, psession.ProviderTypeLocalUser: // this is just here to avoid a lint error about not handling all cases
 		fallthrough
 	default:
 		// This shouldn't really happen.
@@ -223,7 +236,7 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) UpstreamRefresh(
 		"identityProviderType", p.GetSessionProviderType(),
 		"identityProviderUID", p.Provider.GetResourceUID())
 
-	refreshedUntransformedGroups, err := p.Provider.PerformRefresh(ctx, upstreamprovider.LDAPRefreshAttributes{
+	refreshedUntransformedGroups, refreshedAdditionalClaims, refreshedUpstreamData, err := p.Provider.PerformRefresh(ctx, upstreamprovider.LDAPRefreshAttributes{
 		Username:             identity.UpstreamUsername,
 		Subject:              identity.DownstreamSubject,
 		DN:                   dn,
@@ -236,12 +249,24 @@ func (p *FederationDomainResolvedLDAPIdentityProvider) UpstreamRefresh(
 			WithDebugf("provider name: %q, provider type: %q", p.Provider.GetResourceName(), p.GetSessionProviderType())
 	}
 
+	// The session data was cloned from the user's session by the caller, so it is safe to update it here.
+	*sessionAdditionalClaims = refreshedAdditionalClaims
+
+	// Never return nil additional claims, so that additional claims which were removed from the user's entry,
+	// or which are no longer mapped, are also removed from the user's session.
+	refreshedDownstreamAdditionalClaims := downstreamAdditionalClaims(refreshedAdditionalClaims)
+	if refreshedDownstreamAdditionalClaims == nil {
+		refreshedDownstreamAdditionalClaims = map[string]any{}
+	}
+
 	return &resolvedprovider.RefreshedIdentity{
 		// LDAP PerformRefresh validates that the username did not change during refresh,
 		// so the original upstream username is also the refreshed upstream username.
-		UpstreamUsername:       identity.UpstreamUsername,
-		UpstreamGroups:         refreshedUntransformedGroups,
-		IDPSpecificSessionData: nil,
+		UpstreamUsername:           identity.UpstreamUsername,
+		UpstreamGroups:             refreshedUntransformedGroups,
+		IDPSpecificSessionData:     identity.IDPSpecificSessionData,
+		DownstreamAdditionalClaims: refreshedDownstreamAdditionalClaims,
+		UpstreamData:               refreshedUpstreamData,
 	}, nil
 }
 
@@ -253,3 +278,21 @@ func downstreamSubjectFromUpstreamLDAP(
 	ldapURL := *ldapUpstream.GetURL()
 	return downstreamsubject.LDAP(authenticateResponse.User.GetUID(), ldapURL, idpDisplayName)
 }
+
+// downstreamAdditionalClaims returns the additional downstream claims for the values of the LDAP attributes which
+// were mapped to additional claims. An attribute with a single value becomes a string claim, and an attribute with
+// multiple values becomes a claim whose value is a list of strings. Returns nil when there are no values.
+func downstreamAdditionalClaims(additionalClaims map[string][]string) map[string]any {
+	if len(additionalClaims) == 0 {
+		return nil
+	}
+	mapped := make(map[string]any, len(additionalClaims))
+	for claimName, values := range additionalClaims {
+		if len(values) == 1 {
+			mapped[claimName] = values[0]
+		} else {
+			mapped[claimName] = values
+		}
+	}
+	return mapped
+}
@@ -0,0 +1,192 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvedlocaluser
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ory/fosite"
+	errorsx "github.com/pkg/errors"
+
+	"go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
+	"go.pinniped.dev/internal/federationdomain/endpoints/loginurl"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/idtransform"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/pkg/oidcclient/nonce"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+// FederationDomainResolvedLocalUserIdentityProvider represents a FederationDomainIdentityProvider which has
+// been resolved dynamically based on the currently loaded IDP CRs to include the provider.UpstreamLocalUserIdentityProviderI
+// and other metadata about the provider.
+type FederationDomainResolvedLocalUserIdentityProvider struct {
+	DisplayName             string
+	Provider                upstreamprovider.UpstreamLocalUserIdentityProviderI
+	SessionProviderType     psession.ProviderType
+	Transforms              *idtransform.TransformationPipeline
+	TokenExchangeTransforms idtransform.TokenExchangePipelines
+}
+
+var _ resolvedprovider.FederationDomainResolvedIdentityProvider = (*FederationDomainResolvedLocalUserIdentityProvider)(nil)
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) GetDisplayName() string {
+	return p.DisplayName
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) GetProvider() upstreamprovider.UpstreamIdentityProviderI {
+	return p.Provider
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) GetSessionProviderType() psession.ProviderType {
+	return p.SessionProviderType
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) GetIDPDiscoveryType() v1alpha1.IDPType {
+	return v1alpha1.IDPTypeLocalUser
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) GetIDPDiscoveryFlows() []v1alpha1.IDPFlow {
+	return []v1alpha1.IDPFlow{v1alpha1.IDPFlowCLIPassword, v1alpha1.IDPFlowBrowserAuthcode, v1alpha1.IDPFlowDeviceCode}
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) GetTransforms() *idtransform.TransformationPipeline {
+	return p.Transforms
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) GetTokenExchangeTransforms() idtransform.TokenExchangePipelines {
+	return p.TokenExchangeTransforms
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) CloneIDPSpecificSessionDataFromSession(session *psession.CustomSessionData) any {
+	if session.LocalUser == nil {
+		return nil
+	}
+	return session.LocalUser.Clone()
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) ApplyIDPSpecificSessionDataToSession(session *psession.CustomSessionData, idpSpecificSessionData any) {
+	session.LocalUser = idpSpecificSessionData.(*psession.LocalUserSessionData)
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) UpstreamAuthorizeRedirectURL(
+	_ context.Context,
+	state *resolvedprovider.UpstreamAuthorizeRequestState,
+	downstreamIssuerURL string,
+) (string, error) {
+	loginURL, err := loginurl.URL(downstreamIssuerURL, state.EncodedStateParam, loginurl.ShowNoError)
+	if err != nil {
+		return "", fosite.ErrServerError.WithHint("Server could not formulate login UI URL for redirect.").WithWrap(err)
+	}
+
+	return loginURL, nil
+}
+
+// These are special errors that can be returned by Login for a FederationDomainResolvedLocalUserIdentityProvider.
+var (
+	// ErrUnexpectedLocalUserError is returned by Login when there was an unexpected error during authentication.
+	// The error returned from Login() should be compared to this using errors.Is().
+	ErrUnexpectedLocalUserError = &fosite.RFC6749Error{
+		ErrorField:       "error", // this string matches what fosite uses for generic errors
+		DescriptionField: "Unexpected error during local user authentication.",
+		CodeField:        http.StatusInternalServerError,
+	}
+
+	// ErrAccessDeniedDueToUsernamePasswordNotAccepted is returned by Login when the authentication failed due to a
+	// bad username or password, or because the user is locked out. Due to the way that fosite implements
+	// RFC6749Error.Is(), you must use "==" to compare this error to an error returned from Login().
+	ErrAccessDeniedDueToUsernamePasswordNotAccepted = &fosite.RFC6749Error{
+		ErrorField:       "access_denied", // this string matches what fosite uses for access denied errors
+		DescriptionField: "The resource owner or authorization server denied the request.",
+		HintField:        "Username/password not accepted by local user provider.",
+		CodeField:        http.StatusForbidden,
+	}
+)
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) Login(
+	ctx context.Context,
+	submittedUsername string,
+	submittedPassword string,
+) (*resolvedprovider.Identity, *resolvedprovider.IdentityLoginExtras, error) {
+	user, authenticated, err := p.Provider.AuthenticateUser(ctx, submittedUsername, submittedPassword, p.GetDisplayName())
+	if err != nil {
+		plog.WarningErr("unexpected error during local user authentication", err, "upstreamName", p.Provider.GetResourceName())
+		return nil, nil, ErrUnexpectedLocalUserError.WithWrap(err)
+	}
+	if !authenticated {
+		return nil, nil, ErrAccessDeniedDueToUsernamePasswordNotAccepted
+	}
+
+	return &resolvedprovider.Identity{
+			UpstreamUsername:       user.Username,
+			UpstreamGroups:         user.Groups,
+			DownstreamSubject:      user.DownstreamSubject,
+			IDPSpecificSessionData: &psession.LocalUserSessionData{UserUID: user.UserUID},
+		},
+		&resolvedprovider.IdentityLoginExtras{
+			AuthenticationContext: &idtransform.AuthenticationContext{
+				AMR:      user.AMR,
+				Upstream: upstreamData(user.Username, user.Groups),
+			},
+		},
+		nil
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) LoginFromCallback(
+	_ context.Context,
+	_ string,
+	_ pkce.Code,
+	_ nonce.Nonce,
+	_ string,
+) (*resolvedprovider.Identity, *resolvedprovider.IdentityLoginExtras, error) {
+	return nil, nil, httperr.New(http.StatusInternalServerError,
+		"LoginFromCallback() is not supported for LocalUser type of identity provider")
+}
+
+func (p *FederationDomainResolvedLocalUserIdentityProvider) UpstreamRefresh(
+	ctx context.Context,
+	identity *resolvedprovider.Identity,
+) (*resolvedprovider.RefreshedIdentity, error) {
+	sessionData, ok := identity.IDPSpecificSessionData.(*psession.LocalUserSessionData)
+	if !ok || sessionData.UserUID == "" {
+		// This shouldn't really happen.
+		return nil, errorsx.WithStack(resolvedprovider.ErrMissingUpstreamSessionInternalError())
+	}
+
+	plog.Debug("attempting upstream refresh request",
+		"identityProviderResourceName", p.Provider.GetResourceName(),
+		"identityProviderType", p.GetSessionProviderType(),
+		"identityProviderUID", p.Provider.GetResourceUID())
+
+	refreshedGroups, err := p.Provider.PerformRefresh(ctx, identity.UpstreamUsername, sessionData.UserUID)
+	if err != nil {
+		return nil, resolvedprovider.ErrUpstreamRefreshError().WithHint(
+			"Upstream refresh failed.").WithTrace(err).
+			WithDebugf("provider name: %q, provider type: %q", p.Provider.GetResourceName(), p.GetSessionProviderType())
+	}
+
+	// Never return nil groups, so that groups which were removed from the user are also removed from the session.
+	if refreshedGroups == nil {
+		refreshedGroups = []string{}
+	}
+
+	return &resolvedprovider.RefreshedIdentity{
+		// PerformRefresh validates that the user still exists by username,
+		// so the original upstream username is also the refreshed upstream username.
+		UpstreamUsername:       identity.UpstreamUsername,
+		UpstreamGroups:         refreshedGroups,
+		UpstreamData:           upstreamData(identity.UpstreamUsername, refreshedGroups),
+		IDPSpecificSessionData: nil,
+	}, nil
+}
+
+// upstreamData returns the raw identity of the local user, as it was before any identity transformations,
+// for use by identity transformations.
+func upstreamData(username string, groups []string) map[string]any {
+	return map[string]any{"username": username, "groups": groups}
+}
@@ -0,0 +1,304 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvedlocaluser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+
+	idpdiscoveryv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/idtransform"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+	"go.pinniped.dev/internal/testutil/transformtestutil"
+)
+
+func TestFederationDomainResolvedLocalUserIdentityProvider(t *testing.T) {
+	transforms := transformtestutil.NewRejectAllAuthPipeline(t)
+	tokenExchangeTransforms := idtransform.TokenExchangePipelines{
+		idtransform.NewTokenExchangePipeline([]string{"some-cluster"}, nil, transformtestutil.NewRejectAllAuthPipeline(t)),
+	}
+
+	provider := oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().
+		WithName("fake-provider-name").
+		WithResourceUID("fake-resource-uid").
+		Build()
+
+	subject := FederationDomainResolvedLocalUserIdentityProvider{
+		DisplayName:             "fake-display-name",
+		Provider:                provider,
+		SessionProviderType:     psession.ProviderTypeLocalUser,
+		Transforms:              transforms,
+		TokenExchangeTransforms: tokenExchangeTransforms,
+	}
+
+	require.Equal(t, "fake-display-name", subject.GetDisplayName())
+	require.Equal(t, provider, subject.GetProvider())
+	require.Equal(t, psession.ProviderTypeLocalUser, subject.GetSessionProviderType())
+	require.Equal(t, idpdiscoveryv1alpha1.IDPTypeLocalUser, subject.GetIDPDiscoveryType())
+	require.Equal(t, []idpdiscoveryv1alpha1.IDPFlow{
+		idpdiscoveryv1alpha1.IDPFlowCLIPassword,
+		idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode,
+		idpdiscoveryv1alpha1.IDPFlowDeviceCode,
+	}, subject.GetIDPDiscoveryFlows())
+	require.Equal(t, transforms, subject.GetTransforms())
+	require.Equal(t, tokenExchangeTransforms, subject.GetTokenExchangeTransforms())
+
+	originalCustomSession := &psession.CustomSessionData{
+		Username:         "fake-username",
+		UpstreamUsername: "fake-upstream-username",
+		LocalUser:        &psession.LocalUserSessionData{UserUID: "fake-user-uid"},
+	}
+	clonedCustomSession := subject.CloneIDPSpecificSessionDataFromSession(originalCustomSession)
+	require.Equal(t, &psession.LocalUserSessionData{UserUID: "fake-user-uid"}, clonedCustomSession)
+	require.NotSame(t, originalCustomSession.LocalUser, clonedCustomSession)
+	require.Nil(t, subject.CloneIDPSpecificSessionDataFromSession(&psession.CustomSessionData{}))
+
+	customSessionToBeMutated := &psession.CustomSessionData{
+		Username:         "fake-username2",
+		UpstreamUsername: "fake-upstream-username2",
+	}
+	subject.ApplyIDPSpecificSessionDataToSession(customSessionToBeMutated, &psession.LocalUserSessionData{UserUID: "OTHER-user-uid"})
+	require.Equal(t, &psession.CustomSessionData{
+		Username:         "fake-username2",
+		UpstreamUsername: "fake-upstream-username2",
+		LocalUser:        &psession.LocalUserSessionData{UserUID: "OTHER-user-uid"},
+	}, customSessionToBeMutated)
+
+	redirectURL, err := subject.UpstreamAuthorizeRedirectURL(
+		context.Background(),
+		&resolvedprovider.UpstreamAuthorizeRequestState{
+			EncodedStateParam: "encodedStateParam12345",
+			PKCE:              "pkce6789",
+			Nonce:             "nonce1289",
+		},
+		"https://localhost/fake/path",
+	)
+	require.NoError(t, err)
+	require.Equal(t, "https://localhost/fake/path/login?state=encodedStateParam12345", redirectURL)
+
+	identity, extras, err := subject.LoginFromCallback(context.Background(), "fake-authcode", "fake-pkce", "fake-nonce", "https://fake-redirect-uri")
+	require.EqualError(t, err, "LoginFromCallback() is not supported for LocalUser type of identity provider")
+	require.Nil(t, identity)
+	require.Nil(t, extras)
+}
+
+func TestLogin(t *testing.T) {
+	uniqueCtx := context.WithValue(context.Background(), "some-unique-key", "some-value") //nolint:staticcheck // okay to use string key for test
+
+	tests := []struct {
+		name         string
+		authenticate func(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error)
+
+		wantIdentity *resolvedprovider.Identity
+		wantExtras   *resolvedprovider.IdentityLoginExtras
+		wantErr      error
+		wantErrMsg   string
+	}{
+		{
+			name: "happy path",
+			authenticate: func(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error) {
+				require.Equal(t, uniqueCtx, ctx)
+				require.Equal(t, "fake-username", username)
+				require.Equal(t, "fake-password", password)
+				require.Equal(t, "fake-display-name", idpDisplayName)
+				return &upstreamprovider.LocalUser{
+					Username:          "fake-username",
+					Groups:            []string{"fake-group1", "fake-group2"},
+					UserUID:           "fake-user-uid",
+					DownstreamSubject: "localuser://fake-downstream-subject",
+					AMR:               []string{"pwd", "otp", "mfa"},
+				}, true, nil
+			},
+			wantIdentity: &resolvedprovider.Identity{
+				UpstreamUsername:       "fake-username",
+				UpstreamGroups:         []string{"fake-group1", "fake-group2"},
+				DownstreamSubject:      "localuser://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.LocalUserSessionData{UserUID: "fake-user-uid"},
+			},
+			wantExtras: &resolvedprovider.IdentityLoginExtras{
+				AuthenticationContext: &idtransform.AuthenticationContext{
+					AMR: []string{"pwd", "otp", "mfa"},
+					Upstream: map[string]any{
+						"username": "fake-username",
+						"groups":   []string{"fake-group1", "fake-group2"},
+					},
+				},
+			},
+		},
+		{
+			name: "username or password not accepted",
+			authenticate: func(_ context.Context, _, _, _ string) (*upstreamprovider.LocalUser, bool, error) {
+				return nil, false, nil
+			},
+			wantErr: ErrAccessDeniedDueToUsernamePasswordNotAccepted,
+		},
+		{
+			name: "unexpected error",
+			authenticate: func(_ context.Context, _, _, _ string) (*upstreamprovider.LocalUser, bool, error) {
+				return nil, false, errors.New("fake authentication error")
+			},
+			wantErrMsg: "fake authentication error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := FederationDomainResolvedLocalUserIdentityProvider{
+				DisplayName: "fake-display-name",
+				Provider: oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().
+					WithName("fake-provider-name").
+					WithAuthenticateFunc(test.authenticate).
+					Build(),
+				SessionProviderType: psession.ProviderTypeLocalUser,
+				Transforms:          transformtestutil.NewRejectAllAuthPipeline(t),
+			}
+
+			identity, extras, err := subject.Login(uniqueCtx, "fake-username", "fake-password")
+
+			switch {
+			case test.wantErr != nil:
+				require.Same(t, test.wantErr, err)
+			case test.wantErrMsg != "":
+				require.ErrorIs(t, err, ErrUnexpectedLocalUserError)
+				var errAsFositeErr *fosite.RFC6749Error
+				require.ErrorAs(t, err, &errAsFositeErr)
+				require.EqualError(t, errAsFositeErr.Unwrap(), test.wantErrMsg)
+			default:
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, test.wantIdentity, identity)
+			require.Equal(t, test.wantExtras, extras)
+		})
+	}
+}
+
+func TestUpstreamRefresh(t *testing.T) {
+	uniqueCtx := context.WithValue(context.Background(), "some-unique-key", "some-value") //nolint:staticcheck // okay to use string key for test
+
+	initialIdentity := func(sessionData any) *resolvedprovider.Identity {
+		return &resolvedprovider.Identity{
+			UpstreamUsername:       "fake-username",
+			UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+			DownstreamSubject:      "localuser://fake-downstream-subject",
+			IDPSpecificSessionData: sessionData,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		provider *oidctestutil.TestUpstreamLocalUserIdentityProvider
+		identity *resolvedprovider.Identity
+
+		wantPerformRefreshCall bool
+		wantRefreshedIdentity  *resolvedprovider.RefreshedIdentity
+		wantWrappedErr         string
+		wantInternalErr        bool
+	}{
+		{
+			name: "happy path",
+			provider: oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().
+				WithPerformRefreshGroups([]string{"refreshed-group1", "refreshed-group2"}).
+				Build(),
+			identity:               initialIdentity(&psession.LocalUserSessionData{UserUID: "fake-user-uid"}),
+			wantPerformRefreshCall: true,
+			wantRefreshedIdentity: &resolvedprovider.RefreshedIdentity{
+				UpstreamUsername: "fake-username",
+				UpstreamGroups:   []string{"refreshed-group1", "refreshed-group2"},
+				UpstreamData: map[string]any{
+					"username": "fake-username",
+					"groups":   []string{"refreshed-group1", "refreshed-group2"},
+				},
+				IDPSpecificSessionData: nil,
+			},
+		},
+		{
+			name:                   "happy path when the user no longer belongs to any groups",
+			provider:               oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().Build(),
+			identity:               initialIdentity(&psession.LocalUserSessionData{UserUID: "fake-user-uid"}),
+			wantPerformRefreshCall: true,
+			wantRefreshedIdentity: &resolvedprovider.RefreshedIdentity{
+				UpstreamUsername:       "fake-username",
+				UpstreamGroups:         []string{},
+				UpstreamData:           map[string]any{"username": "fake-username", "groups": []string{}},
+				IDPSpecificSessionData: nil,
+			},
+		},
+		{
+			name: "error while performing refresh",
+			provider: oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().
+				WithName("fake-provider-name").
+				WithPerformRefreshErr(errors.New("fake refresh error")).
+				Build(),
+			identity:               initialIdentity(&psession.LocalUserSessionData{UserUID: "fake-user-uid"}),
+			wantPerformRefreshCall: true,
+			wantWrappedErr:         "fake refresh error",
+		},
+		{
+			name:            "wrong session data type, which should not really happen",
+			provider:        oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().Build(),
+			identity:        initialIdentity(&psession.OAuth2SessionData{}),
+			wantInternalErr: true,
+		},
+		{
+			name:            "session is missing the user UID, which should not really happen",
+			provider:        oidctestutil.NewTestUpstreamLocalUserIdentityProviderBuilder().Build(),
+			identity:        initialIdentity(&psession.LocalUserSessionData{}),
+			wantInternalErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := FederationDomainResolvedLocalUserIdentityProvider{
+				DisplayName:         "fake-display-name",
+				Provider:            test.provider,
+				SessionProviderType: psession.ProviderTypeLocalUser,
+				Transforms:          transformtestutil.NewRejectAllAuthPipeline(t),
+			}
+
+			refreshedIdentity, err := subject.UpstreamRefresh(uniqueCtx, test.identity)
+
+			if test.wantPerformRefreshCall {
+				require.Equal(t, 1, test.provider.PerformRefreshCallCount())
+				require.Equal(t, &oidctestutil.PerformLocalUserRefreshArgs{
+					Ctx:      uniqueCtx,
+					Username: "fake-username",
+					UserUID:  "fake-user-uid",
+				}, test.provider.PerformRefreshArgs(0))
+			} else {
+				require.Zero(t, test.provider.PerformRefreshCallCount())
+			}
+
+			switch {
+			case test.wantInternalErr:
+				var errAsFositeErr *fosite.RFC6749Error
+				require.ErrorAs(t, err, &errAsFositeErr)
+				require.Equal(t, "There was an internal server error.", errAsFositeErr.DescriptionField)
+				require.Equal(t, "Required upstream data not found in session.", errAsFositeErr.HintField)
+			case test.wantWrappedErr != "":
+				require.NotNil(t, err, "expected to get an error but did not get one")
+				errAsFositeErr, ok := err.(*fosite.RFC6749Error)
+				require.True(t, ok)
+				require.EqualError(t, errAsFositeErr.Unwrap(), test.wantWrappedErr)
+				require.Equal(t, "error", errAsFositeErr.ErrorField)
+				require.Equal(t, "Error during upstream refresh.", errAsFositeErr.DescriptionField)
+				require.Equal(t, http.StatusUnauthorized, errAsFositeErr.CodeField)
+				require.Equal(t, `provider name: "fake-provider-name", provider type: "localuser"`, errAsFositeErr.DebugField)
+			default:
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, test.wantRefreshedIdentity, refreshedIdentity)
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvedoauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ory/fosite"
+	"golang.org/x/oauth2"
+
+	"go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/idtransform"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/pkg/oidcclient/nonce"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+// FederationDomainResolvedOAuth2IdentityProvider represents a FederationDomainIdentityProvider which has
+// been resolved dynamically based on the currently loaded IDP CRs to include the provider.UpstreamOAuth2IdentityProviderI
+// and other metadata about the provider.
+type FederationDomainResolvedOAuth2IdentityProvider struct {
+	DisplayName             string
+	Provider                upstreamprovider.UpstreamOAuth2IdentityProviderI
+	SessionProviderType     psession.ProviderType
+	Transforms              *idtransform.TransformationPipeline
+	TokenExchangeTransforms idtransform.TokenExchangePipelines
+}
+
+var _ resolvedprovider.FederationDomainResolvedIdentityProvider = (*FederationDomainResolvedOAuth2IdentityProvider)(nil)
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) GetDisplayName() string {
+	return p.DisplayName
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) GetProvider() upstreamprovider.UpstreamIdentityProviderI {
+	return p.Provider
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) GetSessionProviderType() psession.ProviderType {
+	return p.SessionProviderType
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) GetIDPDiscoveryType() v1alpha1.IDPType {
+	return v1alpha1.IDPTypeOAuth2
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) GetIDPDiscoveryFlows() []v1alpha1.IDPFlow {
+	return []v1alpha1.IDPFlow{v1alpha1.IDPFlowBrowserAuthcode}
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) GetTransforms() *idtransform.TransformationPipeline {
+	return p.Transforms
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) GetTokenExchangeTransforms() idtransform.TokenExchangePipelines {
+	return p.TokenExchangeTransforms
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) CloneIDPSpecificSessionDataFromSession(session *psession.CustomSessionData) any {
+	if session.OAuth2 == nil {
+		return nil
+	}
+	return session.OAuth2.Clone()
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) ApplyIDPSpecificSessionDataToSession(session *psession.CustomSessionData, idpSpecificSessionData any) {
+	session.OAuth2 = idpSpecificSessionData.(*psession.OAuth2SessionData)
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) UpstreamAuthorizeRedirectURL(
+	_ context.Context,
+	state *resolvedprovider.UpstreamAuthorizeRequestState,
+	downstreamIssuerURL string,
+) (string, error) {
+	upstreamOAuthConfig := oauth2.Config{
+		ClientID: p.Provider.GetClientID(),
+		Endpoint: oauth2.Endpoint{
+			AuthURL: p.Provider.GetAuthorizationURL(),
+		},
+		RedirectURL: fmt.Sprintf("%s/callback", downstreamIssuerURL),
+		Scopes:      p.Provider.GetScopes(),
+	}
+
+	// There is no nonce param because there is no ID token. Authorization servers which do not support PKCE
+	// are expected to ignore the PKCE params.
+	authCodeOptions := []oauth2.AuthCodeOption{
+		state.PKCE.Challenge(),
+		state.PKCE.Method(),
+	}
+
+	for key, val := range p.Provider.GetAdditionalAuthcodeParams() {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(key, val))
+	}
+
+	redirectURL := upstreamOAuthConfig.AuthCodeURL(
+		state.EncodedStateParam.String(),
+		authCodeOptions...,
+	)
+
+	return redirectURL, nil
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) Login(
+	_ context.Context,
+	_ string,
+	_ string,
+) (*resolvedprovider.Identity, *resolvedprovider.IdentityLoginExtras, error) {
+	return nil, nil, errors.New("function Login not yet implemented for OAuth2 IDP")
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) LoginFromCallback(
+	ctx context.Context,
+	authCode string,
+	pkceCodeVerifier pkce.Code,
+	_ nonce.Nonce, // OAuth2 providers do not return an ID token, therefore there is no ID token that could contain the "nonce".
+	redirectURI string,
+) (*resolvedprovider.Identity, *resolvedprovider.IdentityLoginExtras, error) {
+	token, err := p.Provider.ExchangeAuthcode(ctx, authCode, pkceCodeVerifier, redirectURI)
+	if err != nil {
+		return nil, nil, httperr.Wrap(http.StatusBadGateway,
+			"failed to exchange authcode using OAuth2 token endpoint",
+			err,
+		)
+	}
+	if token.AccessToken == "" {
+		return nil, nil, httperr.New(http.StatusBadGateway, "OAuth2 token endpoint did not return an access token")
+	}
+
+	user, err := p.Provider.GetUser(ctx, token.AccessToken, p.GetDisplayName())
+	if err != nil {
+		return nil, nil, httperr.Wrap(http.StatusUnprocessableEntity,
+			"failed to get user info from OAuth2 provider",
+			err,
+		)
+	}
+
+	sessionData := &psession.OAuth2SessionData{UpstreamSubject: user.Subject}
+	if token.RefreshToken != "" {
+		// When there is a refresh token, there is no need to also store the access token.
+		sessionData.UpstreamRefreshToken = token.RefreshToken
+	} else {
+		sessionData.UpstreamAccessToken = token.AccessToken
+	}
+
+	return &resolvedprovider.Identity{
+			UpstreamUsername:       user.Username,
+			UpstreamGroups:         user.Groups,
+			DownstreamSubject:      user.DownstreamSubject,
+			IDPSpecificSessionData: sessionData,
+		},
+		&resolvedprovider.IdentityLoginExtras{
+			DownstreamAdditionalClaims: nil, // not using this for OAuth2
+			Warnings:                   nil, // not using this for OAuth2
+			AuthenticationContext:      &idtransform.AuthenticationContext{Upstream: user.UpstreamData},
+		},
+		nil // no error
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) UpstreamRefresh(
+	ctx context.Context,
+	identity *resolvedprovider.Identity,
+) (*resolvedprovider.RefreshedIdentity, error) {
+	sessionData, ok := identity.IDPSpecificSessionData.(*psession.OAuth2SessionData)
+	if !ok {
+		// This should not really happen.
+		return nil, p.refreshErr(errors.New("wrong data type found for IDPSpecificSessionData"))
+	}
+	if len(sessionData.UpstreamRefreshToken) == 0 && len(sessionData.UpstreamAccessToken) == 0 {
+		// This should not really happen.
+		return nil, p.refreshErr(errors.New("session is missing OAuth2 refresh token and access token"))
+	}
+
+	accessToken := sessionData.UpstreamAccessToken
+	var updatedSessionData *psession.OAuth2SessionData
+
+	if len(sessionData.UpstreamRefreshToken) > 0 {
+		refreshedToken, err := p.Provider.PerformRefresh(ctx, sessionData.UpstreamRefreshToken)
+		if err != nil {
+			return nil, p.refreshErr(err)
+		}
+		accessToken = refreshedToken.AccessToken
+		if len(refreshedToken.RefreshToken) > 0 && refreshedToken.RefreshToken != sessionData.UpstreamRefreshToken {
+			// The upstream provider rotated the refresh token, so the new one must be saved in the session.
+			updatedSessionData = sessionData.Clone()
+			updatedSessionData.UpstreamRefreshToken = refreshedToken.RefreshToken
+		}
+	}
+
+	// Get the user's identity and groups again using the access token.
+	refreshedUser, err := p.Provider.GetUser(ctx, accessToken, p.GetDisplayName())
+	if err != nil {
+		return nil, p.refreshErr(err)
+	}
+
+	if refreshedUser.Subject != sessionData.UpstreamSubject {
+		// The user's upstream identity changed since the initial login in a surprising way.
+		return nil, p.refreshErr(fmt.Errorf("user's upstream subject at initial login was %q but now is %q",
+			sessionData.UpstreamSubject, refreshedUser.Subject))
+	}
+
+	var refreshedIDPSpecificSessionData any
+	if updatedSessionData != nil {
+		refreshedIDPSpecificSessionData = updatedSessionData
+	}
+
+	return &resolvedprovider.RefreshedIdentity{
+		UpstreamUsername:       refreshedUser.Username,
+		UpstreamGroups:         refreshedUser.Groups,
+		UpstreamData:           refreshedUser.UpstreamData,
+		IDPSpecificSessionData: refreshedIDPSpecificSessionData, // nil means that no update to the OAuth2-specific portion of the session data is required
+	}, nil
+}
+
+func (p *FederationDomainResolvedOAuth2IdentityProvider) refreshErr(err error) *fosite.RFC6749Error {
+	return resolvedprovider.ErrUpstreamRefreshError().
+		WithHint("Upstream refresh failed.").
+		WithTrace(err).
+		WithDebugf("provider name: %q, provider type: %q", p.Provider.GetResourceName(), p.GetSessionProviderType())
+}
@@ -0,0 +1,588 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvedoauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	idpdiscoveryv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/idtransform"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+	"go.pinniped.dev/internal/testutil/transformtestutil"
+	"go.pinniped.dev/internal/upstreamoauth2"
+)
+
+func TestFederationDomainResolvedOAuth2IdentityProvider(t *testing.T) {
+	transforms := transformtestutil.NewRejectAllAuthPipeline(t)
+	tokenExchangeTransforms := idtransform.TokenExchangePipelines{
+		idtransform.NewTokenExchangePipeline([]string{"some-cluster"}, nil, transformtestutil.NewRejectAllAuthPipeline(t)),
+	}
+
+	provider := upstreamoauth2.New(upstreamoauth2.ProviderConfig{
+		Name:                     "fake-provider-config",
+		ResourceUID:              "fake-resource-uid",
+		AdditionalAuthcodeParams: map[string]string{"prompt": "consent"},
+		HttpClient:               nil, // not needed for this test
+		OAuth2Config: &oauth2.Config{
+			ClientID:     "fake-client-id",
+			ClientSecret: "fake-client-secret",
+			Scopes:       []string{"read_user", "read_api"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   "https://fake-authorization-url",
+				TokenURL:  "https://fake-token-url",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+		},
+	})
+
+	subject := FederationDomainResolvedOAuth2IdentityProvider{
+		DisplayName:             "fake-display-name",
+		Provider:                provider,
+		SessionProviderType:     psession.ProviderTypeOAuth2,
+		Transforms:              transforms,
+		TokenExchangeTransforms: tokenExchangeTransforms,
+	}
+
+	require.Equal(t, "fake-display-name", subject.GetDisplayName())
+	require.Equal(t, provider, subject.GetProvider())
+	require.Equal(t, psession.ProviderTypeOAuth2, subject.GetSessionProviderType())
+	require.Equal(t, idpdiscoveryv1alpha1.IDPTypeOAuth2, subject.GetIDPDiscoveryType())
+	require.Equal(t, []idpdiscoveryv1alpha1.IDPFlow{idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode}, subject.GetIDPDiscoveryFlows())
+	require.Equal(t, transforms, subject.GetTransforms())
+	require.Equal(t, tokenExchangeTransforms, subject.GetTokenExchangeTransforms())
+
+	originalCustomSession := &psession.CustomSessionData{
+		Username:         "fake-username",
+		UpstreamUsername: "fake-upstream-username",
+		OAuth2:           &psession.OAuth2SessionData{UpstreamRefreshToken: "fake-upstream-refresh-token", UpstreamSubject: "fake-subject"},
+	}
+	clonedCustomSession := subject.CloneIDPSpecificSessionDataFromSession(originalCustomSession)
+	require.Equal(t,
+		&psession.OAuth2SessionData{UpstreamRefreshToken: "fake-upstream-refresh-token", UpstreamSubject: "fake-subject"},
+		clonedCustomSession,
+	)
+	require.NotSame(t, originalCustomSession.OAuth2, clonedCustomSession)
+	require.Nil(t, subject.CloneIDPSpecificSessionDataFromSession(&psession.CustomSessionData{}))
+
+	customSessionToBeMutated := &psession.CustomSessionData{
+		Username:         "fake-username2",
+		UpstreamUsername: "fake-upstream-username2",
+	}
+	subject.ApplyIDPSpecificSessionDataToSession(customSessionToBeMutated, &psession.OAuth2SessionData{UpstreamAccessToken: "OTHER-upstream-access-token"})
+	require.Equal(t, &psession.CustomSessionData{
+		Username:         "fake-username2",
+		UpstreamUsername: "fake-upstream-username2",
+		OAuth2:           &psession.OAuth2SessionData{UpstreamAccessToken: "OTHER-upstream-access-token"},
+	}, customSessionToBeMutated)
+
+	redirectURL, err := subject.UpstreamAuthorizeRedirectURL(
+		context.Background(),
+		&resolvedprovider.UpstreamAuthorizeRequestState{
+			EncodedStateParam: "encodedStateParam12345",
+			PKCE:              "pkce6789",
+			Nonce:             "nonce1289",
+		},
+		"https://localhost/fake/path",
+	)
+	require.NoError(t, err)
+	// Note that the nonce is not sent, because OAuth2 providers do not return ID tokens.
+	require.Equal(t,
+		"https://fake-authorization-url?"+
+			"client_id=fake-client-id&"+
+			"code_challenge=3indzSdebyEpaWs9NNOPrtx0sw3z6VhUM9MPRjji134&"+
+			"code_challenge_method=S256&"+
+			"prompt=consent&"+
+			"redirect_uri=https%3A%2F%2Flocalhost%2Ffake%2Fpath%2Fcallback&"+
+			"response_type=code&"+
+			"scope=read_user+read_api&"+
+			"state=encodedStateParam12345",
+		redirectURL,
+	)
+
+	identity, extras, err := subject.Login(context.Background(), "fake-username", "fake-password")
+	require.EqualError(t, err, "function Login not yet implemented for OAuth2 IDP")
+	require.Nil(t, identity)
+	require.Nil(t, extras)
+}
+
+func TestLoginFromCallback(t *testing.T) {
+	uniqueCtx := context.WithValue(context.Background(), "some-unique-key", "some-value") //nolint:staticcheck // okay to use string key for test
+
+	happyUser := &upstreamprovider.OAuth2User{
+		Username:          "fake-username",
+		Groups:            []string{"fake-group1", "fake-group2"},
+		Subject:           "fake-subject",
+		DownstreamSubject: "https://fake-downstream-subject",
+		UpstreamData:      map[string]any{"userInfo": map[string]any{"sub": "fake-subject"}},
+	}
+
+	tests := []struct {
+		name           string
+		provider       *oidctestutil.TestUpstreamOAuth2IdentityProvider
+		idpDisplayName string
+		authcode       string
+		redirectURI    string
+
+		wantExchangeAuthcodeCall bool
+		wantExchangeAuthcodeArgs *oidctestutil.ExchangeOAuth2AuthcodeArgs
+		wantGetUserCall          bool
+		wantGetUserArgs          *oidctestutil.GetUserArgs
+		wantIdentity             *resolvedprovider.Identity
+		wantExtras               *resolvedprovider.IdentityLoginExtras
+		wantErrMsg               string
+		wantErrResponseMsg       string
+		wantErrStatusCode        int
+	}{
+		{
+			name: "happy path with a refresh token",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithToken(&oauth2.Token{AccessToken: "fake-access-token", RefreshToken: "fake-refresh-token"}).
+				WithUser(happyUser).
+				Build(),
+			idpDisplayName:           "fake-display-name",
+			authcode:                 "fake-authcode",
+			redirectURI:              "https://fake-redirect-uri",
+			wantExchangeAuthcodeCall: true,
+			wantExchangeAuthcodeArgs: &oidctestutil.ExchangeOAuth2AuthcodeArgs{
+				Ctx:              uniqueCtx,
+				Authcode:         "fake-authcode",
+				PKCECodeVerifier: "fake-pkce",
+				RedirectURI:      "https://fake-redirect-uri",
+			},
+			wantGetUserCall: true,
+			wantGetUserArgs: &oidctestutil.GetUserArgs{
+				Ctx:            uniqueCtx,
+				AccessToken:    "fake-access-token",
+				IDPDisplayName: "fake-display-name",
+			},
+			wantIdentity: &resolvedprovider.Identity{
+				UpstreamUsername:  "fake-username",
+				UpstreamGroups:    []string{"fake-group1", "fake-group2"},
+				DownstreamSubject: "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{
+					UpstreamRefreshToken: "fake-refresh-token",
+					UpstreamSubject:      "fake-subject",
+				},
+			},
+			wantExtras: &resolvedprovider.IdentityLoginExtras{
+				AuthenticationContext: &idtransform.AuthenticationContext{Upstream: happyUser.UpstreamData},
+			},
+		},
+		{
+			name: "happy path without a refresh token",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithToken(&oauth2.Token{AccessToken: "fake-access-token"}).
+				WithUser(happyUser).
+				Build(),
+			idpDisplayName:           "fake-display-name",
+			authcode:                 "fake-authcode",
+			redirectURI:              "https://fake-redirect-uri",
+			wantExchangeAuthcodeCall: true,
+			wantExchangeAuthcodeArgs: &oidctestutil.ExchangeOAuth2AuthcodeArgs{
+				Ctx:              uniqueCtx,
+				Authcode:         "fake-authcode",
+				PKCECodeVerifier: "fake-pkce",
+				RedirectURI:      "https://fake-redirect-uri",
+			},
+			wantGetUserCall: true,
+			wantGetUserArgs: &oidctestutil.GetUserArgs{
+				Ctx:            uniqueCtx,
+				AccessToken:    "fake-access-token",
+				IDPDisplayName: "fake-display-name",
+			},
+			wantIdentity: &resolvedprovider.Identity{
+				UpstreamUsername:  "fake-username",
+				UpstreamGroups:    []string{"fake-group1", "fake-group2"},
+				DownstreamSubject: "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{
+					UpstreamAccessToken: "fake-access-token",
+					UpstreamSubject:     "fake-subject",
+				},
+			},
+			wantExtras: &resolvedprovider.IdentityLoginExtras{
+				AuthenticationContext: &idtransform.AuthenticationContext{Upstream: happyUser.UpstreamData},
+			},
+		},
+		{
+			name: "error while exchanging authcode",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithAuthcodeExchangeError(errors.New("fake authcode exchange error")).
+				Build(),
+			idpDisplayName:           "fake-display-name",
+			authcode:                 "fake-authcode",
+			redirectURI:              "https://fake-redirect-uri",
+			wantExchangeAuthcodeCall: true,
+			wantExchangeAuthcodeArgs: &oidctestutil.ExchangeOAuth2AuthcodeArgs{
+				Ctx:              uniqueCtx,
+				Authcode:         "fake-authcode",
+				PKCECodeVerifier: "fake-pkce",
+				RedirectURI:      "https://fake-redirect-uri",
+			},
+			wantGetUserCall:    false,
+			wantErrMsg:         "failed to exchange authcode using OAuth2 token endpoint: fake authcode exchange error",
+			wantErrResponseMsg: "Bad Gateway: failed to exchange authcode using OAuth2 token endpoint",
+			wantErrStatusCode:  http.StatusBadGateway,
+		},
+		{
+			name: "token endpoint does not return an access token",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithToken(&oauth2.Token{RefreshToken: "fake-refresh-token"}).
+				Build(),
+			idpDisplayName:           "fake-display-name",
+			authcode:                 "fake-authcode",
+			redirectURI:              "https://fake-redirect-uri",
+			wantExchangeAuthcodeCall: true,
+			wantExchangeAuthcodeArgs: &oidctestutil.ExchangeOAuth2AuthcodeArgs{
+				Ctx:              uniqueCtx,
+				Authcode:         "fake-authcode",
+				PKCECodeVerifier: "fake-pkce",
+				RedirectURI:      "https://fake-redirect-uri",
+			},
+			wantGetUserCall:    false,
+			wantErrMsg:         "OAuth2 token endpoint did not return an access token",
+			wantErrResponseMsg: "Bad Gateway: OAuth2 token endpoint did not return an access token",
+			wantErrStatusCode:  http.StatusBadGateway,
+		},
+		{
+			name: "error while getting user info",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithToken(&oauth2.Token{AccessToken: "fake-access-token"}).
+				WithGetUserError(errors.New("fake user info error")).
+				Build(),
+			idpDisplayName:           "fake-display-name",
+			authcode:                 "fake-authcode",
+			redirectURI:              "https://fake-redirect-uri",
+			wantExchangeAuthcodeCall: true,
+			wantExchangeAuthcodeArgs: &oidctestutil.ExchangeOAuth2AuthcodeArgs{
+				Ctx:              uniqueCtx,
+				Authcode:         "fake-authcode",
+				PKCECodeVerifier: "fake-pkce",
+				RedirectURI:      "https://fake-redirect-uri",
+			},
+			wantGetUserCall: true,
+			wantGetUserArgs: &oidctestutil.GetUserArgs{
+				Ctx:            uniqueCtx,
+				AccessToken:    "fake-access-token",
+				IDPDisplayName: "fake-display-name",
+			},
+			wantErrMsg:         "failed to get user info from OAuth2 provider: fake user info error",
+			wantErrResponseMsg: "Unprocessable Entity: failed to get user info from OAuth2 provider",
+			wantErrStatusCode:  http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := FederationDomainResolvedOAuth2IdentityProvider{
+				DisplayName:         test.idpDisplayName,
+				Provider:            test.provider,
+				SessionProviderType: psession.ProviderTypeOAuth2,
+				Transforms:          transformtestutil.NewRejectAllAuthPipeline(t),
+			}
+
+			identity, loginExtras, err := subject.LoginFromCallback(uniqueCtx,
+				test.authcode,
+				"fake-pkce",
+				"nonce-will-be-ignored",
+				test.redirectURI,
+			)
+
+			if test.wantExchangeAuthcodeCall {
+				require.Equal(t, 1, test.provider.ExchangeAuthcodeCallCount())
+				require.Equal(t, test.wantExchangeAuthcodeArgs, test.provider.ExchangeAuthcodeArgs(0))
+			} else {
+				require.Zero(t, test.provider.ExchangeAuthcodeCallCount())
+			}
+
+			if test.wantGetUserCall {
+				require.Equal(t, 1, test.provider.GetUserCallCount())
+				require.Equal(t, test.wantGetUserArgs, test.provider.GetUserArgs(0))
+			} else {
+				require.Zero(t, test.provider.GetUserCallCount())
+			}
+
+			if test.wantErrResponseMsg == "" {
+				require.NoError(t, err)
+			} else {
+				require.Implements(t, (*httperr.Responder)(nil), err)
+				errAsResponder := err.(httperr.Responder)
+				rec := httptest.NewRecorder()
+				errAsResponder.Respond(rec)
+				require.Equal(t, test.wantErrStatusCode, rec.Code)
+				require.Equal(t, test.wantErrResponseMsg+"\n", rec.Body.String())
+
+				require.EqualError(t, errAsResponder, test.wantErrMsg)
+			}
+			require.Equal(t, test.wantExtras, loginExtras)
+			require.Equal(t, test.wantIdentity, identity)
+		})
+	}
+}
+
+func TestUpstreamRefresh(t *testing.T) {
+	uniqueCtx := context.WithValue(context.Background(), "some-unique-key", "some-value") //nolint:staticcheck // okay to use string key for test
+
+	refreshedUser := &upstreamprovider.OAuth2User{
+		Username:          "refreshed-username",
+		Groups:            []string{"refreshed-group1", "refreshed-group2"},
+		Subject:           "fake-subject",
+		DownstreamSubject: "https://fake-downstream-subject",
+		UpstreamData:      map[string]any{"userInfo": map[string]any{"sub": "fake-subject"}},
+	}
+
+	tests := []struct {
+		name           string
+		provider       *oidctestutil.TestUpstreamOAuth2IdentityProvider
+		idpDisplayName string
+		identity       *resolvedprovider.Identity
+
+		wantPerformRefreshCall bool
+		wantPerformRefreshArgs *oidctestutil.PerformOAuth2RefreshArgs
+		wantGetUserCall        bool
+		wantGetUserArgs        *oidctestutil.GetUserArgs
+		wantRefreshedIdentity  *resolvedprovider.RefreshedIdentity
+		wantWrappedErr         string
+	}{
+		{
+			name: "happy path using a refresh token which is not rotated",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithRefreshedToken(&oauth2.Token{AccessToken: "refreshed-access-token"}).
+				WithUser(refreshedUser).
+				Build(),
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{UpstreamRefreshToken: "fake-refresh-token", UpstreamSubject: "fake-subject"},
+			},
+			idpDisplayName:         "fake-display-name",
+			wantPerformRefreshCall: true,
+			wantPerformRefreshArgs: &oidctestutil.PerformOAuth2RefreshArgs{
+				Ctx:          uniqueCtx,
+				RefreshToken: "fake-refresh-token",
+			},
+			wantGetUserCall: true,
+			wantGetUserArgs: &oidctestutil.GetUserArgs{
+				Ctx:            uniqueCtx,
+				AccessToken:    "refreshed-access-token",
+				IDPDisplayName: "fake-display-name",
+			},
+			wantRefreshedIdentity: &resolvedprovider.RefreshedIdentity{
+				UpstreamUsername:       "refreshed-username",
+				UpstreamGroups:         []string{"refreshed-group1", "refreshed-group2"},
+				UpstreamData:           refreshedUser.UpstreamData,
+				IDPSpecificSessionData: nil,
+			},
+		},
+		{
+			name: "happy path using a refresh token which is rotated",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithRefreshedToken(&oauth2.Token{AccessToken: "refreshed-access-token", RefreshToken: "rotated-refresh-token"}).
+				WithUser(refreshedUser).
+				Build(),
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{UpstreamRefreshToken: "fake-refresh-token", UpstreamSubject: "fake-subject"},
+			},
+			idpDisplayName:         "fake-display-name",
+			wantPerformRefreshCall: true,
+			wantPerformRefreshArgs: &oidctestutil.PerformOAuth2RefreshArgs{
+				Ctx:          uniqueCtx,
+				RefreshToken: "fake-refresh-token",
+			},
+			wantGetUserCall: true,
+			wantGetUserArgs: &oidctestutil.GetUserArgs{
+				Ctx:            uniqueCtx,
+				AccessToken:    "refreshed-access-token",
+				IDPDisplayName: "fake-display-name",
+			},
+			wantRefreshedIdentity: &resolvedprovider.RefreshedIdentity{
+				UpstreamUsername: "refreshed-username",
+				UpstreamGroups:   []string{"refreshed-group1", "refreshed-group2"},
+				UpstreamData:     refreshedUser.UpstreamData,
+				IDPSpecificSessionData: &psession.OAuth2SessionData{
+					UpstreamRefreshToken: "rotated-refresh-token",
+					UpstreamSubject:      "fake-subject",
+				},
+			},
+		},
+		{
+			name: "happy path using an access token",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithUser(refreshedUser).
+				Build(),
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{UpstreamAccessToken: "fake-access-token", UpstreamSubject: "fake-subject"},
+			},
+			idpDisplayName:         "fake-display-name",
+			wantPerformRefreshCall: false,
+			wantGetUserCall:        true,
+			wantGetUserArgs: &oidctestutil.GetUserArgs{
+				Ctx:            uniqueCtx,
+				AccessToken:    "fake-access-token",
+				IDPDisplayName: "fake-display-name",
+			},
+			wantRefreshedIdentity: &resolvedprovider.RefreshedIdentity{
+				UpstreamUsername:       "refreshed-username",
+				UpstreamGroups:         []string{"refreshed-group1", "refreshed-group2"},
+				UpstreamData:           refreshedUser.UpstreamData,
+				IDPSpecificSessionData: nil,
+			},
+		},
+		{
+			name: "error while performing refresh",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithName("fake-provider-name").
+				WithPerformRefreshError(errors.New("fake refresh error")).
+				Build(),
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{UpstreamRefreshToken: "fake-refresh-token", UpstreamSubject: "fake-subject"},
+			},
+			idpDisplayName:         "fake-display-name",
+			wantPerformRefreshCall: true,
+			wantPerformRefreshArgs: &oidctestutil.PerformOAuth2RefreshArgs{
+				Ctx:          uniqueCtx,
+				RefreshToken: "fake-refresh-token",
+			},
+			wantGetUserCall: false,
+			wantWrappedErr:  "fake refresh error",
+		},
+		{
+			name: "error while getting user info",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithName("fake-provider-name").
+				WithGetUserError(errors.New("fake GetUser error message")).
+				Build(),
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{UpstreamAccessToken: "fake-access-token", UpstreamSubject: "fake-subject"},
+			},
+			idpDisplayName:  "fake-display-name",
+			wantGetUserCall: true,
+			wantGetUserArgs: &oidctestutil.GetUserArgs{
+				Ctx:            uniqueCtx,
+				AccessToken:    "fake-access-token",
+				IDPDisplayName: "fake-display-name",
+			},
+			wantWrappedErr: "fake GetUser error message",
+		},
+		{
+			name: "wrong session data type, which should not really happen",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithName("fake-provider-name").
+				Build(),
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.GitHubSessionData{}, // wrong type
+			},
+			idpDisplayName: "fake-display-name",
+			wantWrappedErr: "wrong data type found for IDPSpecificSessionData",
+		},
+		{
+			name: "session is missing both tokens, which should not really happen",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithName("fake-provider-name").
+				Build(),
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{UpstreamSubject: "fake-subject"}, // missing tokens
+			},
+			idpDisplayName: "fake-display-name",
+			wantWrappedErr: "session is missing OAuth2 refresh token and access token",
+		},
+		{
+			name: "user's upstream subject changes unexpectedly",
+			provider: oidctestutil.NewTestUpstreamOAuth2IdentityProviderBuilder().
+				WithName("fake-provider-name").
+				WithUser(&upstreamprovider.OAuth2User{
+					Username:          "refreshed-username",
+					Subject:           "unexpected-different-subject",
+					DownstreamSubject: "https://unexpected-different-downstream-subject",
+				}).
+				Build(),
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.OAuth2SessionData{UpstreamAccessToken: "fake-access-token", UpstreamSubject: "fake-subject"},
+			},
+			idpDisplayName:  "fake-display-name",
+			wantGetUserCall: true,
+			wantGetUserArgs: &oidctestutil.GetUserArgs{
+				Ctx:            uniqueCtx,
+				AccessToken:    "fake-access-token",
+				IDPDisplayName: "fake-display-name",
+			},
+			wantWrappedErr: `user's upstream subject at initial login was "fake-subject" but now is "unexpected-different-subject"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := FederationDomainResolvedOAuth2IdentityProvider{
+				DisplayName:         test.idpDisplayName,
+				Provider:            test.provider,
+				SessionProviderType: psession.ProviderTypeOAuth2,
+				Transforms:          transformtestutil.NewRejectAllAuthPipeline(t),
+			}
+
+			refreshedIdentity, err := subject.UpstreamRefresh(uniqueCtx, test.identity)
+
+			if test.wantPerformRefreshCall {
+				require.Equal(t, 1, test.provider.PerformRefreshCallCount())
+				require.Equal(t, test.wantPerformRefreshArgs, test.provider.PerformRefreshArgs(0))
+			} else {
+				require.Zero(t, test.provider.PerformRefreshCallCount())
+			}
+
+			if test.wantGetUserCall {
+				require.Equal(t, 1, test.provider.GetUserCallCount())
+				require.Equal(t, test.wantGetUserArgs, test.provider.GetUserArgs(0))
+			} else {
+				require.Zero(t, test.provider.GetUserCallCount())
+			}
+
+			if test.wantWrappedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.NotNil(t, err, "expected to get an error but did not get one")
+				errAsFositeErr, ok := err.(*fosite.RFC6749Error)
+				require.True(t, ok)
+				require.EqualError(t, errAsFositeErr.Unwrap(), test.wantWrappedErr)
+				require.Equal(t, "error", errAsFositeErr.ErrorField)
+				require.Equal(t, "Error during upstream refresh.", errAsFositeErr.DescriptionField)
+				require.Equal(t, http.StatusUnauthorized, errAsFositeErr.CodeField)
+				require.Equal(t, `provider name: "fake-provider-name", provider type: "oauth2"`, errAsFositeErr.DebugField)
+			}
+
+			require.Equal(t, test.wantRefreshedIdentity, refreshedIdentity)
+		})
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package resolvedoidc
@@ -50,10 +50,11 @@ const (
 // been resolved dynamically based on the currently loaded IDP CRs to include the provider.UpstreamOIDCIdentityProviderI
 // and other metadata about the provider.
 type FederationDomainResolvedOIDCIdentityProvider struct {
-	DisplayName         string
-	Provider            upstreamprovider.UpstreamOIDCIdentityProviderI
-	SessionProviderType psession.ProviderType
-	Transforms          *idtransform.TransformationPipeline
+	DisplayName             string
+	Provider                upstreamprovider.UpstreamOIDCIdentityProviderI
+	SessionProviderType     psession.ProviderType
+	Transforms              *idtransform.TransformationPipeline
+	TokenExchangeTransforms idtransform.TokenExchangePipelines
 }
 
 var _ resolvedprovider.FederationDomainResolvedIdentityProvider = (*FederationDomainResolvedOIDCIdentityProvider)(nil)
@@ -79,13 +80,17 @@ func (p *FederationDomainResolvedOIDCIdentityProvider) GetIDPDiscoveryFlows() []
 	if p.Provider.AllowsPasswordGrant() {
 		flows = append(flows, v1alpha1.IDPFlowCLIPassword)
 	}
-	return flows
+	return append(flows, v1alpha1.IDPFlowDeviceCode)
 }
 
 func (p *FederationDomainResolvedOIDCIdentityProvider) GetTransforms() *idtransform.TransformationPipeline {
 	return p.Transforms
 }
 
+func (p *FederationDomainResolvedOIDCIdentityProvider) GetTokenExchangeTransforms() idtransform.TokenExchangePipelines {
+	return p.TokenExchangeTransforms
+}
+
 func (p *FederationDomainResolvedOIDCIdentityProvider) CloneIDPSpecificSessionDataFromSession(session *psession.CustomSessionData) any {
 	if session.OIDC == nil {
 		return nil
@@ -97,7 +102,11 @@ func (p *FederationDomainResolvedOIDCIdentityProvider) ApplyIDPSpecificSessionDa
 	session.OIDC = idpSpecificSessionData.(*psession.OIDCSessionData)
 }
 
-func (p *FederationDomainResolvedOIDCIdentityProvider) UpstreamAuthorizeRedirectURL(state *resolvedprovider.UpstreamAuthorizeRequestState, downstreamIssuerURL string) (string, error) {
+func (p *FederationDomainResolvedOIDCIdentityProvider) UpstreamAuthorizeRedirectURL(
+	ctx context.Context,
+	state *resolvedprovider.UpstreamAuthorizeRequestState,
+	downstreamIssuerURL string,
+) (string, error) {
 	upstreamOAuthConfig := oauth2.Config{
 		ClientID: p.Provider.GetClientID(),
 		Endpoint: oauth2.Endpoint{
@@ -117,12 +126,24 @@ func (p *FederationDomainResolvedOIDCIdentityProvider) UpstreamAuthorizeRedirect
 		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(key, val))
 	}
 
-	redirectURL := upstreamOAuthConfig.AuthCodeURL(
+	redirectURL, err := url.Parse(upstreamOAuthConfig.AuthCodeURL(
 		state.EncodedStateParam.String(),
 		authCodeOptions...,
-	)
+	))
+	if err != nil {
+		return "", fosite.ErrServerError.WithHint("Server could not formulate upstream authorization URL for redirect.").WithWrap(err)
+	}
 
-	return redirectURL, nil
+	// Depending on its configuration, the upstream provider may need to push or sign the authorization request.
+	params, err := p.Provider.PrepareAuthorizationRequest(ctx, redirectURL.Query())
+	if err != nil {
+		plog.WarningErr("error while preparing upstream authorization request", err,
+			"upstreamName", p.Provider.GetResourceName())
+		return "", fosite.ErrServerError.WithHint("Server could not prepare upstream authorization request.").WithWrap(err)
+	}
+	redirectURL.RawQuery = params.Encode()
+
+	return redirectURL.String(), nil
 }
 
 func (p *FederationDomainResolvedOIDCIdentityProvider) Login(
@@ -172,6 +193,7 @@ func (p *FederationDomainResolvedOIDCIdentityProvider) Login(
 		&resolvedprovider.IdentityLoginExtras{
 			DownstreamAdditionalClaims: additionalClaims,
 			Warnings:                   warnings,
+			AuthenticationContext:      getAuthenticationContextFromUpstreamIDToken(token.IDToken.Claims),
 		},
 		nil
 }
@@ -217,6 +239,7 @@ func (p *FederationDomainResolvedOIDCIdentityProvider) LoginFromCallback(
 		&resolvedprovider.IdentityLoginExtras{
 			DownstreamAdditionalClaims: additionalClaims,
 			Warnings:                   warnings,
+			AuthenticationContext:      getAuthenticationContextFromUpstreamIDToken(token.IDToken.Claims),
 		},
 		nil
 }
@@ -320,10 +343,18 @@ func (p *FederationDomainResolvedOIDCIdentityProvider) UpstreamRefresh(
 		updatedSessionData.UpstreamRefreshToken = tokens.RefreshToken
 	}
 
+	// The merged claims may be empty when there was neither a new ID token nor a userinfo endpoint.
+	// In that case, we have no new information about the user, so let the old upstream data in the session remain.
+	var refreshedUpstreamData map[string]any
+	if len(mergedClaims) > 0 {
+		refreshedUpstreamData = mergedClaims
+	}
+
 	return &resolvedprovider.RefreshedIdentity{
 		UpstreamUsername:       refreshedUntransformedUsername,
 		UpstreamGroups:         refreshedUntransformedGroups,
 		IDPSpecificSessionData: updatedSessionData,
+		UpstreamData:           refreshedUpstreamData,
 	}, nil
 }
 
@@ -465,6 +496,22 @@ func mapAdditionalClaimsFromUpstreamIDToken(
 	return mapped
 }
 
+// getAuthenticationContextFromUpstreamIDToken returns how the user authenticated to the upstream provider, according
+// to the "acr" and "amr" claims of the upstream ID token. These claims were already checked against the login policy
+// of the upstream provider, if any. All the claims, which were already merged with the userinfo response, if any,
+// are also returned as the raw upstream data.
+func getAuthenticationContextFromUpstreamIDToken(idTokenClaims map[string]any) *idtransform.AuthenticationContext {
+	acr, _ := getString(idTokenClaims, oidcapi.IDTokenClaimAuthenticationContextClassReference)
+	amrAsInterfaceArray, _ := idTokenClaims[oidcapi.IDTokenClaimAuthenticationMethodsReferences].([]any)
+	var amr []string
+	for _, amrValueAsInterface := range amrAsInterfaceArray {
+		if amrValue, ok := amrValueAsInterface.(string); ok {
+			amr = append(amr, amrValue)
+		}
+	}
+	return &idtransform.AuthenticationContext{ACR: acr, AMR: amr, Upstream: idTokenClaims}
+}
+
 func getDownstreamSubjectAndUpstreamUsernameFromUpstreamIDToken(
 	upstreamIDPConfig upstreamprovider.UpstreamOIDCIdentityProviderI,
 	idTokenClaims map[string]any,
@@ -0,0 +1,198 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvedsaml
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ory/fosite"
+
+	"go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/idtransform"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/pkg/oidcclient/nonce"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+// FederationDomainResolvedSAMLIdentityProvider represents a FederationDomainIdentityProvider which has
+// been resolved dynamically based on the currently loaded IDP CRs to include the provider.UpstreamSAMLIdentityProviderI
+// and other metadata about the provider.
+type FederationDomainResolvedSAMLIdentityProvider struct {
+	DisplayName             string
+	Provider                upstreamprovider.UpstreamSAMLIdentityProviderI
+	SessionProviderType     psession.ProviderType
+	Transforms              *idtransform.TransformationPipeline
+	TokenExchangeTransforms idtransform.TokenExchangePipelines
+}
+
+var _ resolvedprovider.FederationDomainResolvedIdentityProvider = (*FederationDomainResolvedSAMLIdentityProvider)(nil)
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) GetDisplayName() string {
+	return p.DisplayName
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) GetProvider() upstreamprovider.UpstreamIdentityProviderI {
+	return p.Provider
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) GetSessionProviderType() psession.ProviderType {
+	return p.SessionProviderType
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) GetIDPDiscoveryType() v1alpha1.IDPType {
+	return v1alpha1.IDPTypeSAML
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) GetIDPDiscoveryFlows() []v1alpha1.IDPFlow {
+	return []v1alpha1.IDPFlow{v1alpha1.IDPFlowBrowserAuthcode}
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) GetTransforms() *idtransform.TransformationPipeline {
+	return p.Transforms
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) GetTokenExchangeTransforms() idtransform.TokenExchangePipelines {
+	return p.TokenExchangeTransforms
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) CloneIDPSpecificSessionDataFromSession(session *psession.CustomSessionData) any {
+	if session.SAML == nil {
+		return nil
+	}
+	return session.SAML.Clone()
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) ApplyIDPSpecificSessionDataToSession(session *psession.CustomSessionData, idpSpecificSessionData any) {
+	session.SAML = idpSpecificSessionData.(*psession.SAMLSessionData)
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) UpstreamAuthorizeRedirectURL(
+	_ context.Context,
+	state *resolvedprovider.UpstreamAuthorizeRequestState,
+	downstreamIssuerURL string,
+) (string, error) {
+	// The RelayState is the encoded state param, so the callback endpoint can find the downstream authorization
+	// request again. Note that this is longer than the 80 bytes recommended by the SAML bindings specification,
+	// but in practice identity providers return the RelayState unchanged.
+	redirectURL, err := p.Provider.AuthnRequestURL(
+		requestID(state.Nonce),
+		p.serviceProviderEntityID(downstreamIssuerURL),
+		fmt.Sprintf("%s/callback", downstreamIssuerURL),
+		state.EncodedStateParam.String(),
+	)
+	if err != nil {
+		return "", fosite.ErrServerError.WithHint("Error creating SAML AuthnRequest.").WithWrap(err)
+	}
+	return redirectURL, nil
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) Login(
+	_ context.Context,
+	_ string,
+	_ string,
+) (*resolvedprovider.Identity, *resolvedprovider.IdentityLoginExtras, error) {
+	return nil, nil, errors.New("function Login not yet implemented for SAML IDP")
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) LoginFromCallback(
+	_ context.Context,
+	samlResponse string, // for SAML, this is the base64-encoded SAMLResponse which was posted to the callback endpoint
+	_ pkce.Code, // SAML does not use PKCE
+	nonce nonce.Nonce, // the AuthnRequest ID was derived from the nonce
+	redirectURI string,
+) (*resolvedprovider.Identity, *resolvedprovider.IdentityLoginExtras, error) {
+	user, err := p.Provider.ValidateResponse(
+		samlResponse,
+		requestID(nonce),
+		p.serviceProviderEntityID(strings.TrimSuffix(redirectURI, "/callback")),
+		redirectURI,
+		p.GetDisplayName(),
+	)
+	if err != nil {
+		return nil, nil, httperr.Wrap(http.StatusUnprocessableEntity,
+			"failed to validate SAML response",
+			err,
+		)
+	}
+
+	return &resolvedprovider.Identity{
+			UpstreamUsername:  user.Username,
+			UpstreamGroups:    user.Groups,
+			DownstreamSubject: user.DownstreamSubject,
+			IDPSpecificSessionData: &psession.SAMLSessionData{
+				UpstreamIssuer:      user.Issuer,
+				UpstreamNameID:      user.NameID,
+				SessionNotOnOrAfter: user.SessionNotOnOrAfter,
+			},
+		},
+		&resolvedprovider.IdentityLoginExtras{
+			DownstreamAdditionalClaims: nil, // not using this for SAML
+			Warnings:                   nil, // not using this for SAML
+			AuthenticationContext:      &idtransform.AuthenticationContext{Upstream: user.UpstreamData},
+		},
+		nil // no error
+}
+
+// UpstreamRefresh cannot contact the SAML identity provider, because SAML has no back-channel API for refreshing
+// a user's identity. Instead, the identity from the initial login remains valid until the end of the session which
+// was specified by the identity provider's assertion, if any. After that, the user must log in again.
+func (p *FederationDomainResolvedSAMLIdentityProvider) UpstreamRefresh(
+	_ context.Context,
+	identity *resolvedprovider.Identity,
+) (*resolvedprovider.RefreshedIdentity, error) {
+	samlSessionData, ok := identity.IDPSpecificSessionData.(*psession.SAMLSessionData)
+	if !ok {
+		// This should not really happen.
+		return nil, p.refreshErr(errors.New("wrong data type found for IDPSpecificSessionData"))
+	}
+	if len(samlSessionData.UpstreamNameID) == 0 {
+		// This should not really happen.
+		return nil, p.refreshErr(errors.New("session is missing SAML NameID"))
+	}
+
+	if !samlSessionData.SessionNotOnOrAfter.IsZero() && !time.Now().Before(samlSessionData.SessionNotOnOrAfter) {
+		return nil, p.refreshErr(fmt.Errorf("upstream SAML session ended at %s",
+			samlSessionData.SessionNotOnOrAfter.UTC().Format(time.RFC3339)))
+	}
+
+	return &resolvedprovider.RefreshedIdentity{
+		UpstreamUsername:       identity.UpstreamUsername,
+		UpstreamGroups:         identity.UpstreamGroups,
+		IDPSpecificSessionData: nil, // nil means that no update to the SAML-specific portion of the session data is required
+	}, nil
+}
+
+// serviceProviderEntityID returns the configured entity ID of the Supervisor, or the FederationDomain's issuer
+// when none was configured.
+func (p *FederationDomainResolvedSAMLIdentityProvider) serviceProviderEntityID(downstreamIssuerURL string) string {
+	if entityID := p.Provider.GetServiceProviderEntityID(); entityID != "" {
+		return entityID
+	}
+	return downstreamIssuerURL
+}
+
+func (p *FederationDomainResolvedSAMLIdentityProvider) refreshErr(err error) *fosite.RFC6749Error {
+	return resolvedprovider.ErrUpstreamRefreshError().
+		WithHint("Upstream refresh failed.").
+		WithTrace(err).
+		WithDebugf("provider name: %q, provider type: %q", p.Provider.GetResourceName(), p.GetSessionProviderType())
+}
+
+// requestID derives the ID of the AuthnRequest from the nonce which is saved in the state param, so the callback
+// endpoint can require the SAML response to be in response to the same request. An ID must start with a letter or
+// an underscore, so it is prefixed with an underscore.
+func requestID(n nonce.Nonce) string {
+	hash := sha256.Sum256([]byte(n))
+	return "_" + hex.EncodeToString(hash[:])
+}
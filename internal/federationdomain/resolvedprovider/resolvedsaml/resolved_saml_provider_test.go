@@ -0,0 +1,385 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvedsaml
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+
+	idpdiscoveryv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/idpdiscovery/v1alpha1"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/idtransform"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+	"go.pinniped.dev/internal/testutil/transformtestutil"
+)
+
+func TestFederationDomainResolvedSAMLIdentityProvider(t *testing.T) {
+	transforms := transformtestutil.NewRejectAllAuthPipeline(t)
+	tokenExchangeTransforms := idtransform.TokenExchangePipelines{
+		idtransform.NewTokenExchangePipeline([]string{"some-cluster"}, nil, transformtestutil.NewRejectAllAuthPipeline(t)),
+	}
+
+	provider := oidctestutil.NewTestUpstreamSAMLIdentityProviderBuilder().
+		WithName("fake-provider-config").
+		WithResourceUID("fake-resource-uid").
+		WithSingleSignOnURL("https://fake-sso-url").
+		Build()
+
+	subject := FederationDomainResolvedSAMLIdentityProvider{
+		DisplayName:             "fake-display-name",
+		Provider:                provider,
+		SessionProviderType:     psession.ProviderTypeSAML,
+		Transforms:              transforms,
+		TokenExchangeTransforms: tokenExchangeTransforms,
+	}
+
+	require.Equal(t, "fake-display-name", subject.GetDisplayName())
+	require.Equal(t, provider, subject.GetProvider())
+	require.Equal(t, psession.ProviderTypeSAML, subject.GetSessionProviderType())
+	require.Equal(t, idpdiscoveryv1alpha1.IDPTypeSAML, subject.GetIDPDiscoveryType())
+	require.Equal(t, []idpdiscoveryv1alpha1.IDPFlow{idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode}, subject.GetIDPDiscoveryFlows())
+	require.Equal(t, transforms, subject.GetTransforms())
+	require.Equal(t, tokenExchangeTransforms, subject.GetTokenExchangeTransforms())
+
+	originalCustomSession := &psession.CustomSessionData{
+		Username:         "fake-username",
+		UpstreamUsername: "fake-upstream-username",
+		SAML:             &psession.SAMLSessionData{UpstreamIssuer: "fake-issuer", UpstreamNameID: "fake-name-id"},
+	}
+	clonedCustomSession := subject.CloneIDPSpecificSessionDataFromSession(originalCustomSession)
+	require.Equal(t,
+		&psession.SAMLSessionData{UpstreamIssuer: "fake-issuer", UpstreamNameID: "fake-name-id"},
+		clonedCustomSession,
+	)
+	require.NotSame(t, originalCustomSession.SAML, clonedCustomSession)
+	require.Nil(t, subject.CloneIDPSpecificSessionDataFromSession(&psession.CustomSessionData{}))
+
+	customSessionToBeMutated := &psession.CustomSessionData{
+		Username:         "fake-username2",
+		UpstreamUsername: "fake-upstream-username2",
+	}
+	subject.ApplyIDPSpecificSessionDataToSession(customSessionToBeMutated, &psession.SAMLSessionData{UpstreamNameID: "other-name-id"})
+	require.Equal(t, &psession.CustomSessionData{
+		Username:         "fake-username2",
+		UpstreamUsername: "fake-upstream-username2",
+		SAML:             &psession.SAMLSessionData{UpstreamNameID: "other-name-id"},
+	}, customSessionToBeMutated)
+
+	redirectURL, err := subject.UpstreamAuthorizeRedirectURL(
+		context.Background(),
+		&resolvedprovider.UpstreamAuthorizeRequestState{
+			EncodedStateParam: "encodedStateParam12345",
+			PKCE:              "pkce6789",
+			Nonce:             "nonce1289",
+		},
+		"https://localhost/fake/path",
+	)
+	require.NoError(t, err)
+	// The test provider encodes the request ID, service provider entity ID, and assertion consumer service URL
+	// as the SAMLRequest param. The service provider entity ID defaults to the FederationDomain's issuer.
+	require.Equal(t,
+		"https://fake-sso-url?"+
+			"RelayState=encodedStateParam12345&"+
+			"SAMLRequest=_69071c6357f3608c1f7129a28cc09ed2bcf9fa94a54631afaae9574275b058b6+"+
+			"https%3A%2F%2Flocalhost%2Ffake%2Fpath+"+
+			"https%3A%2F%2Flocalhost%2Ffake%2Fpath%2Fcallback",
+		redirectURL,
+	)
+
+	provider.ServiceProviderEntityID = "configured-entity-id"
+	redirectURL, err = subject.UpstreamAuthorizeRedirectURL(
+		context.Background(),
+		&resolvedprovider.UpstreamAuthorizeRequestState{
+			EncodedStateParam: "encodedStateParam12345",
+			Nonce:             "nonce1289",
+		},
+		"https://localhost/fake/path",
+	)
+	require.NoError(t, err)
+	require.Equal(t,
+		"https://fake-sso-url?"+
+			"RelayState=encodedStateParam12345&"+
+			"SAMLRequest=_69071c6357f3608c1f7129a28cc09ed2bcf9fa94a54631afaae9574275b058b6+"+
+			"configured-entity-id+"+
+			"https%3A%2F%2Flocalhost%2Ffake%2Fpath%2Fcallback",
+		redirectURL,
+	)
+
+	identity, extras, err := subject.Login(context.Background(), "username", "password")
+	require.EqualError(t, err, "function Login not yet implemented for SAML IDP")
+	require.Nil(t, identity)
+	require.Nil(t, extras)
+}
+
+func TestLoginFromCallback(t *testing.T) {
+	sessionEnd := time.Date(2025, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		provider       *oidctestutil.TestUpstreamSAMLIdentityProvider
+		idpDisplayName string
+		samlResponse   string
+		redirectURI    string
+
+		wantValidateResponseArgs *oidctestutil.ValidateResponseArgs
+		wantIdentity             *resolvedprovider.Identity
+		wantExtras               *resolvedprovider.IdentityLoginExtras
+		wantErrMsg               string
+		wantErrResponseMsg       string
+		wantErrStatusCode        int
+	}{
+		{
+			name: "happy path",
+			provider: oidctestutil.NewTestUpstreamSAMLIdentityProviderBuilder().
+				WithUser(&upstreamprovider.SAMLUser{
+					Username:            "fake-username",
+					Groups:              []string{"fake-group1", "fake-group2"},
+					DownstreamSubject:   "https://fake-downstream-subject",
+					Issuer:              "https://fake-upstream-issuer",
+					NameID:              "fake-name-id",
+					SessionNotOnOrAfter: sessionEnd,
+					UpstreamData:        map[string]any{"nameID": "fake-name-id"},
+				}).
+				Build(),
+			idpDisplayName: "fake-display-name",
+			samlResponse:   "fake-saml-response",
+			redirectURI:    "https://fake-issuer/path/callback",
+			wantValidateResponseArgs: &oidctestutil.ValidateResponseArgs{
+				EncodedResponse:             "fake-saml-response",
+				RequestID:                   "_7fdb0f1825b65c1ecd963f0d7eddb6612d6b6d6875b75471a7c1fde91eff44c7",
+				ServiceProviderEntityID:     "https://fake-issuer/path",
+				AssertionConsumerServiceURL: "https://fake-issuer/path/callback",
+				IDPDisplayName:              "fake-display-name",
+			},
+			wantIdentity: &resolvedprovider.Identity{
+				UpstreamUsername:  "fake-username",
+				UpstreamGroups:    []string{"fake-group1", "fake-group2"},
+				DownstreamSubject: "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.SAMLSessionData{
+					UpstreamIssuer:      "https://fake-upstream-issuer",
+					UpstreamNameID:      "fake-name-id",
+					SessionNotOnOrAfter: sessionEnd,
+				},
+			},
+			wantExtras: &resolvedprovider.IdentityLoginExtras{
+				AuthenticationContext: &idtransform.AuthenticationContext{
+					Upstream: map[string]any{"nameID": "fake-name-id"},
+				},
+			},
+		},
+		{
+			name: "configured service provider entity ID",
+			provider: oidctestutil.NewTestUpstreamSAMLIdentityProviderBuilder().
+				WithServiceProviderEntityID("configured-entity-id").
+				WithUser(&upstreamprovider.SAMLUser{
+					Username:          "fake-username",
+					DownstreamSubject: "https://fake-downstream-subject",
+					Issuer:            "https://fake-upstream-issuer",
+					NameID:            "fake-name-id",
+				}).
+				Build(),
+			idpDisplayName: "fake-display-name",
+			samlResponse:   "fake-saml-response",
+			redirectURI:    "https://fake-issuer/path/callback",
+			wantValidateResponseArgs: &oidctestutil.ValidateResponseArgs{
+				EncodedResponse:             "fake-saml-response",
+				RequestID:                   "_7fdb0f1825b65c1ecd963f0d7eddb6612d6b6d6875b75471a7c1fde91eff44c7",
+				ServiceProviderEntityID:     "configured-entity-id",
+				AssertionConsumerServiceURL: "https://fake-issuer/path/callback",
+				IDPDisplayName:              "fake-display-name",
+			},
+			wantIdentity: &resolvedprovider.Identity{
+				UpstreamUsername:  "fake-username",
+				DownstreamSubject: "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.SAMLSessionData{
+					UpstreamIssuer: "https://fake-upstream-issuer",
+					UpstreamNameID: "fake-name-id",
+				},
+			},
+			wantExtras: &resolvedprovider.IdentityLoginExtras{
+				AuthenticationContext: &idtransform.AuthenticationContext{},
+			},
+		},
+		{
+			name: "invalid response",
+			provider: oidctestutil.NewTestUpstreamSAMLIdentityProviderBuilder().
+				WithValidateResponseError(errors.New("fake validation error")).
+				Build(),
+			idpDisplayName: "fake-display-name",
+			samlResponse:   "fake-saml-response",
+			redirectURI:    "https://fake-issuer/path/callback",
+			wantValidateResponseArgs: &oidctestutil.ValidateResponseArgs{
+				EncodedResponse:             "fake-saml-response",
+				RequestID:                   "_7fdb0f1825b65c1ecd963f0d7eddb6612d6b6d6875b75471a7c1fde91eff44c7",
+				ServiceProviderEntityID:     "https://fake-issuer/path",
+				AssertionConsumerServiceURL: "https://fake-issuer/path/callback",
+				IDPDisplayName:              "fake-display-name",
+			},
+			wantIdentity:       nil,
+			wantExtras:         nil,
+			wantErrMsg:         "failed to validate SAML response: fake validation error",
+			wantErrResponseMsg: "Unprocessable Entity: failed to validate SAML response",
+			wantErrStatusCode:  http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject := FederationDomainResolvedSAMLIdentityProvider{
+				DisplayName:         test.idpDisplayName,
+				Provider:            test.provider,
+				SessionProviderType: psession.ProviderTypeSAML,
+				Transforms:          transformtestutil.NewRejectAllAuthPipeline(t),
+			}
+
+			identity, loginExtras, err := subject.LoginFromCallback(context.Background(),
+				test.samlResponse,
+				"pkce-will-be-ignored",
+				"fake-nonce",
+				test.redirectURI,
+			)
+
+			require.Equal(t, 1, test.provider.ValidateResponseCallCount())
+			require.Equal(t, test.wantValidateResponseArgs, test.provider.ValidateResponseArgs(0))
+
+			if test.wantErrResponseMsg == "" {
+				require.NoError(t, err)
+			} else {
+				require.Implements(t, (*httperr.Responder)(nil), err)
+				errAsResponder := err.(httperr.Responder)
+				rec := httptest.NewRecorder()
+				errAsResponder.Respond(rec)
+				require.Equal(t, test.wantErrStatusCode, rec.Code)
+				require.Equal(t, test.wantErrResponseMsg+"\n", rec.Body.String())
+
+				require.EqualError(t, errAsResponder, test.wantErrMsg)
+			}
+			require.Equal(t, test.wantExtras, loginExtras)
+			require.Equal(t, test.wantIdentity, identity)
+		})
+	}
+}
+
+func TestUpstreamRefresh(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *resolvedprovider.Identity
+
+		wantRefreshedIdentity *resolvedprovider.RefreshedIdentity
+		wantWrappedErr        string
+	}{
+		{
+			name: "happy path without a session end",
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.SAMLSessionData{UpstreamNameID: "fake-name-id"},
+			},
+			wantRefreshedIdentity: &resolvedprovider.RefreshedIdentity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				IDPSpecificSessionData: nil,
+			},
+		},
+		{
+			name: "happy path before the session end",
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:  "initial-username",
+				UpstreamGroups:    []string{"initial-group1", "initial-group2"},
+				DownstreamSubject: "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.SAMLSessionData{
+					UpstreamNameID:      "fake-name-id",
+					SessionNotOnOrAfter: time.Now().Add(time.Hour),
+				},
+			},
+			wantRefreshedIdentity: &resolvedprovider.RefreshedIdentity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				IDPSpecificSessionData: nil,
+			},
+		},
+		{
+			name: "after the session end",
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:  "initial-username",
+				UpstreamGroups:    []string{"initial-group1", "initial-group2"},
+				DownstreamSubject: "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.SAMLSessionData{
+					UpstreamNameID:      "fake-name-id",
+					SessionNotOnOrAfter: time.Date(2025, time.January, 2, 3, 4, 5, 0, time.UTC),
+				},
+			},
+			wantRefreshedIdentity: nil,
+			wantWrappedErr:        "upstream SAML session ended at 2025-01-02T03:04:05Z",
+		},
+		{
+			name: "wrong session data type, which should not really happen",
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.LDAPSessionData{}, // wrong type
+			},
+			wantRefreshedIdentity: nil,
+			wantWrappedErr:        "wrong data type found for IDPSpecificSessionData",
+		},
+		{
+			name: "session is missing the NameID, which should not really happen",
+			identity: &resolvedprovider.Identity{
+				UpstreamUsername:       "initial-username",
+				UpstreamGroups:         []string{"initial-group1", "initial-group2"},
+				DownstreamSubject:      "https://fake-downstream-subject",
+				IDPSpecificSessionData: &psession.SAMLSessionData{UpstreamNameID: ""}, // missing NameID
+			},
+			wantRefreshedIdentity: nil,
+			wantWrappedErr:        "session is missing SAML NameID",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := oidctestutil.NewTestUpstreamSAMLIdentityProviderBuilder().
+				WithName("fake-provider-name").
+				Build()
+
+			subject := FederationDomainResolvedSAMLIdentityProvider{
+				DisplayName:         "fake-display-name",
+				Provider:            provider,
+				SessionProviderType: psession.ProviderTypeSAML,
+				Transforms:          transformtestutil.NewRejectAllAuthPipeline(t),
+			}
+
+			refreshedIdentity, err := subject.UpstreamRefresh(context.Background(), test.identity)
+
+			// Refreshes never contact the upstream provider.
+			require.Zero(t, provider.ValidateResponseCallCount())
+
+			if test.wantWrappedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.NotNil(t, err, "expected to get an error but did not get one")
+				errAsFositeErr, ok := err.(*fosite.RFC6749Error)
+				require.True(t, ok)
+				require.EqualError(t, errAsFositeErr.Unwrap(), test.wantWrappedErr)
+				require.Equal(t, "error", errAsFositeErr.ErrorField)
+				require.Equal(t, "Error during upstream refresh.", errAsFositeErr.DescriptionField)
+				require.Equal(t, http.StatusUnauthorized, errAsFositeErr.CodeField)
+				require.Equal(t, `provider name: "fake-provider-name", provider type: "saml"`, errAsFositeErr.DebugField)
+			}
+
+			require.Equal(t, test.wantRefreshedIdentity, refreshedIdentity)
+		})
+	}
+}
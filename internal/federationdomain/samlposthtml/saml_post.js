@@ -0,0 +1,7 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+window.onload = () => {
+    // Submit the form right away, so the user does not need to click the button.
+    document.forms[0].submit();
+};
@@ -0,0 +1,72 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package samlposthtml defines the HTML page which resubmits a SAML response to the Supervisor's callback endpoint.
+//
+// SAML identity providers use the HTTP-POST binding to send their response to the callback endpoint, which is
+// a cross-site POST request from the browser's point of view. Browsers do not send the SameSite=Lax CSRF cookie on
+// such requests, so this page re-posts the response from the Supervisor's own origin, which allows the browser to
+// send the cookie. The resubmitted request must still pass the usual CSRF validation.
+package samlposthtml
+
+import (
+	_ "embed" // Needed to trigger //go:embed directives below.
+	"html/template"
+	"strings"
+
+	"github.com/tdewolff/minify/v2/minify"
+
+	"go.pinniped.dev/internal/federationdomain/csp"
+)
+
+// ResubmittedParamName is the name of the form param which marks a SAML response as having been resubmitted
+// by this page, to avoid resubmitting it again when the CSRF cookie is still missing. It must match the template.
+const ResubmittedParamName = "pinniped_resubmitted"
+
+//nolint:gochecknoglobals // This package uses globals to ensure that all parsing and minifying happens at init.
+var (
+	//go:embed saml_post.js
+	rawJS      string
+	minifiedJS = panicOnError(minify.JS(rawJS))
+
+	//go:embed saml_post.gohtml
+	rawHTMLTemplate string
+
+	// Parse the Go templated HTML and inject functions providing the minified inline JS.
+	parsedHTMLTemplate = template.Must(template.New("saml_post.gohtml").Funcs(template.FuncMap{
+		"minifiedJS": func() template.JS { return template.JS(minifiedJS) }, //nolint:gosec // This is 100% static input, not attacker-controlled.
+	}).Parse(rawHTMLTemplate))
+
+	// Generate the CSP header value once since it's effectively constant. Note that there is intentionally no
+	// form-action directive, because some browsers apply it to the redirect which ends the downstream login.
+	cspValue = strings.Join([]string{
+		`default-src 'none'`,
+		`script-src '` + csp.Hash(minifiedJS) + `'`,
+		`frame-ancestors 'none'`,
+	}, "; ")
+)
+
+func panicOnError(s string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// PageData represents the inputs to the template.
+type PageData struct {
+	// Action is the URL of the callback endpoint.
+	Action string
+
+	// SAMLResponse and RelayState are the values which were posted by the SAML identity provider.
+	SAMLResponse string
+	RelayState   string
+}
+
+// ContentSecurityPolicy returns the Content-Security-Policy header value to make the Template() operate correctly.
+//
+// See https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Security-Policy.
+func ContentSecurityPolicy() string { return cspValue }
+
+// Template returns the html/template.Template for rendering the SAML response resubmit page.
+func Template() *template.Template { return parsedHTMLTemplate }
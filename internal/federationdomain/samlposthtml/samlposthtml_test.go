@@ -0,0 +1,53 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package samlposthtml
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/here"
+)
+
+var (
+	testExpectedSAMLPostOutput = here.Doc(`
+        <!DOCTYPE html>
+        <html lang="en">
+        <head>
+            <meta charset="UTF-8">
+            <title>Logging in...</title>
+            <script>window.onload=()=>{document.forms[0].submit()}</script>
+        </head>
+        <body>
+        <form method="post" action="https://example.com/issuer/callback">
+            <input type="hidden" name="SAMLResponse" value="PHNhbWxwOlJlc3BvbnNlLz4&#43;&#34;&lt;script&gt;"/>
+            <input type="hidden" name="RelayState" value="some-state"/>
+            <input type="hidden" name="pinniped_resubmitted" value="true"/>
+            <noscript>
+                <button type="submit">Continue logging in</button>
+            </noscript>
+        </form>
+        </body>
+        </html>
+	`)
+
+	// It's okay if this changes in the future, but this gives us a chance to eyeball the formatting.
+	// Our browser-based integration tests should find any incompatibilities.
+	testExpectedCSP = `default-src 'none'; ` +
+		`script-src 'sha256-JeMUqBmARWp5J5ylWBPI1uUIgjSom9lq0SW/q8WXk4s='; ` +
+		`frame-ancestors 'none'`
+)
+
+func TestTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Template().Execute(&buf, &PageData{
+		Action:       "https://example.com/issuer/callback",
+		SAMLResponse: `PHNhbWxwOlJlc3BvbnNlLz4+"<script>`,
+		RelayState:   "some-state",
+	}))
+	require.Equal(t, testExpectedSAMLPostOutput, buf.String())
+	require.Equal(t, testExpectedCSP, ContentSecurityPolicy())
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package storage
@@ -18,8 +18,11 @@ import (
 	"go.pinniped.dev/internal/federationdomain/timeouts"
 	"go.pinniped.dev/internal/fositestorage/accesstoken"
 	"go.pinniped.dev/internal/fositestorage/authorizationcode"
+	"go.pinniped.dev/internal/fositestorage/clientassertion"
+	"go.pinniped.dev/internal/fositestorage/devicecode"
 	"go.pinniped.dev/internal/fositestorage/openidconnect"
 	"go.pinniped.dev/internal/fositestorage/pkce"
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
 	"go.pinniped.dev/internal/fositestorage/refreshtoken"
 	"go.pinniped.dev/internal/fositestoragei"
 	"go.pinniped.dev/internal/oidcclientsecretstorage"
@@ -32,9 +35,15 @@ type KubeStorage struct {
 	oidcStorage              openid.OpenIDConnectRequestStorage
 	accessTokenStorage       accesstoken.RevocationStorage
 	refreshTokenStorage      refreshtoken.RevocationStorage
+	deviceCodeStorage        devicecode.DeviceCodeStorage
+	clientAssertionStorage   clientassertion.Storage
+	parStorage               fosite.PARStorage
 }
 
-var _ fositestoragei.AllFositeStorage = &KubeStorage{}
+var (
+	_ fositestoragei.AllFositeStorage = &KubeStorage{}
+	_ devicecode.DeviceCodeStorage    = &KubeStorage{}
+)
 
 func NewKubeStorage(
 	secrets corev1client.SecretInterface,
@@ -50,6 +59,10 @@ func NewKubeStorage(
 		oidcStorage:              openidconnect.New(secrets, nowFunc, timeoutsConfiguration.OIDCSessionStorageLifetime),
 		accessTokenStorage:       accesstoken.New(secrets, nowFunc, timeoutsConfiguration.AccessTokenSessionStorageLifetime),
 		refreshTokenStorage:      refreshtoken.New(secrets, nowFunc, timeoutsConfiguration.RefreshTokenSessionStorageLifetime),
+		deviceCodeStorage: devicecode.New(secrets, nowFunc,
+			timeoutsConfiguration.DeviceCodeSessionStorageLifetime, timeoutsConfiguration.UserCodeSessionStorageLifetime),
+		clientAssertionStorage: clientassertion.New(secrets, nowFunc),
+		parStorage:             pushedauthorizerequest.New(secrets, nowFunc, timeoutsConfiguration.PushedAuthorizeRequestSessionStorageLifetime),
 	}
 }
 
@@ -195,6 +208,41 @@ func (k KubeStorage) RotateRefreshToken(ctx context.Context, requestID string, _
 	return k.accessTokenStorage.RevokeAccessToken(ctx, requestID)
 }
 
+//
+// Device code sessions:
+//
+// These are keyed by the signature of the device code. Each also has a companion user code record,
+// keyed by the signature of the user code, which points back to the device code session.
+//
+// Fosite will create these in the device authorization endpoint.
+//
+// The device verification flow will mark them as accepted after the end user has logged in with their web browser,
+// storing the user's downstream session at the same time.
+//
+// Fosite will never delete them. Instead, it wants to mark them as invalidated once the device code is used to redeem
+// tokens. That way, it can later detect the case where a device code that was already redeemed gets used again.
+//
+
+func (k KubeStorage) CreateDeviceAuthSession(ctx context.Context, signatureOfDeviceCode, signatureOfUserCode string, requester fosite.DeviceRequester) error {
+	return k.deviceCodeStorage.CreateDeviceAuthSession(ctx, signatureOfDeviceCode, signatureOfUserCode, requester)
+}
+
+func (k KubeStorage) GetDeviceCodeSession(ctx context.Context, signatureOfDeviceCode string, session fosite.Session) (fosite.DeviceRequester, error) {
+	return k.deviceCodeStorage.GetDeviceCodeSession(ctx, signatureOfDeviceCode, session)
+}
+
+func (k KubeStorage) InvalidateDeviceCodeSession(ctx context.Context, signatureOfDeviceCode string) error {
+	return k.deviceCodeStorage.InvalidateDeviceCodeSession(ctx, signatureOfDeviceCode)
+}
+
+func (k KubeStorage) GetDeviceCodeSignatureByUserCode(ctx context.Context, signatureOfUserCode string) (string, error) {
+	return k.deviceCodeStorage.GetDeviceCodeSignatureByUserCode(ctx, signatureOfUserCode)
+}
+
+func (k KubeStorage) AcceptDeviceCodeSession(ctx context.Context, signatureOfDeviceCode string, requester fosite.DeviceRequester) error {
+	return k.deviceCodeStorage.AcceptDeviceCodeSession(ctx, signatureOfDeviceCode, requester)
+}
+
 //
 // OAuth client definitions:
 //
@@ -203,10 +251,41 @@ func (k KubeStorage) GetClient(ctx context.Context, id string) (fosite.Client, e
 	return k.clientManager.GetClient(ctx, id)
 }
 
+//
+// Client assertion JWTs:
+//
+// These are keyed by a hash of the JTI of the JWT.
+//
+// Fosite will check and create these when a client authenticates using the private_key_jwt method,
+// to prevent replay of the client's JWTs. Fosite will never delete them. They will be garbage collected
+// after the JWT expires.
+//
+
 func (k KubeStorage) ClientAssertionJWTValid(ctx context.Context, jti string) error {
-	return k.clientManager.ClientAssertionJWTValid(ctx, jti)
+	return k.clientAssertionStorage.ClientAssertionJWTValid(ctx, jti)
 }
 
 func (k KubeStorage) SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error {
-	return k.clientManager.SetClientAssertionJWT(ctx, jti, exp)
+	return k.clientAssertionStorage.SetClientAssertionJWT(ctx, jti, exp)
+}
+
+//
+// Pushed authorization requests:
+//
+// These are keyed by a hash of the request_uri.
+//
+// Fosite will create these in the pushed authorization request endpoint. Fosite will get and then delete them
+// when the request_uri is used at the authorization endpoint. Unused requests will be garbage collected.
+//
+
+func (k KubeStorage) CreatePARSession(ctx context.Context, requestURI string, request fosite.AuthorizeRequester) error {
+	return k.parStorage.CreatePARSession(ctx, requestURI, request)
+}
+
+func (k KubeStorage) GetPARSession(ctx context.Context, requestURI string) (fosite.AuthorizeRequester, error) {
+	return k.parStorage.GetPARSession(ctx, requestURI)
+}
+
+func (k KubeStorage) DeletePARSession(ctx context.Context, requestURI string) error {
+	return k.parStorage.DeletePARSession(ctx, requestURI)
 }
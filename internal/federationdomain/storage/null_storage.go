@@ -1,10 +1,11 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/ory/fosite"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -12,6 +13,8 @@ import (
 	"go.pinniped.dev/generated/latest/client/supervisor/clientset/versioned/typed/config/v1alpha1"
 	"go.pinniped.dev/internal/constable"
 	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/federationdomain/timeouts"
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
 	"go.pinniped.dev/internal/fositestoragei"
 	"go.pinniped.dev/internal/oidcclientsecretstorage"
 )
@@ -21,6 +24,9 @@ const errNullStorageNotImplemented = constable.Error("NullStorage does not imple
 type NullStorage struct {
 	// The authorization endpoint uses NullStorage to avoid saving any data, but it still needs to perform client lookups.
 	*clientregistry.ClientManager
+
+	// The authorization endpoint also needs to use up the pushed authorization requests which are sent to it.
+	parStorage fosite.PARStorage
 }
 
 var _ fositestoragei.AllFositeStorage = &NullStorage{}
@@ -28,10 +34,12 @@ var _ fositestoragei.AllFositeStorage = &NullStorage{}
 func NewNullStorage(
 	secrets corev1client.SecretInterface,
 	oidcClientsClient v1alpha1.OIDCClientInterface,
+	timeoutsConfiguration timeouts.Configuration,
 	minBcryptCost int,
 ) *NullStorage {
 	return &NullStorage{
 		ClientManager: clientregistry.NewClientManager(oidcClientsClient, oidcclientsecretstorage.New(secrets), minBcryptCost),
+		parStorage:    pushedauthorizerequest.New(secrets, time.Now, timeoutsConfiguration.PushedAuthorizeRequestSessionStorageLifetime),
 	}
 }
 
@@ -106,3 +114,27 @@ func (NullStorage) GetAuthorizeCodeSession(_ context.Context, _ string, _ fosite
 func (NullStorage) InvalidateAuthorizeCodeSession(_ context.Context, _ string) (err error) {
 	return errNullStorageNotImplemented
 }
+
+func (NullStorage) CreateDeviceAuthSession(_ context.Context, _ string, _ string, _ fosite.DeviceRequester) error {
+	return nil
+}
+
+func (NullStorage) GetDeviceCodeSession(_ context.Context, _ string, _ fosite.Session) (fosite.DeviceRequester, error) {
+	return nil, errNullStorageNotImplemented
+}
+
+func (NullStorage) InvalidateDeviceCodeSession(_ context.Context, _ string) error {
+	return errNullStorageNotImplemented
+}
+
+func (NullStorage) CreatePARSession(_ context.Context, _ string, _ fosite.AuthorizeRequester) error {
+	return errNullStorageNotImplemented
+}
+
+func (n NullStorage) GetPARSession(ctx context.Context, requestURI string) (fosite.AuthorizeRequester, error) {
+	return n.parStorage.GetPARSession(ctx, requestURI)
+}
+
+func (n NullStorage) DeletePARSession(ctx context.Context, requestURI string) error {
+	return n.parStorage.DeletePARSession(ctx, requestURI)
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package strategy
@@ -10,6 +10,8 @@ import (
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/compose"
 	fositeoauth2 "github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/rfc8628"
+	"github.com/ory/fosite/token/hmac"
 	errorsx "github.com/pkg/errors"
 
 	"go.pinniped.dev/internal/federationdomain/storage"
@@ -24,10 +26,13 @@ const (
 
 	pinAuthcodePrefix = "pin_ac_" // "Pinniped authorization code" abbreviated.
 	oryAuthcodePrefix = "ory_ac_"
+
+	pinDeviceCodePrefix = "pin_dc_" // "Pinniped device code" abbreviated.
+	oryDeviceCodePrefix = "ory_dc_"
 )
 
-// DynamicOauth2HMACStrategy is an oauth2.CoreStrategy that can dynamically load an HMAC key to sign
-// stuff (access tokens, refresh tokens, and auth codes). We want this dynamic capability since our
+// DynamicOauth2HMACStrategy is an oauth2.CoreStrategy and rfc8628.RFC8628CodeStrategy that can dynamically load
+// an HMAC key to sign stuff (access tokens, refresh tokens, auth codes, device codes, and user codes). We want this dynamic capability since our
 // controllers for loading FederationDomain's and signing keys run in parallel, and thus the signing key
 // might not be ready when an FederationDomain is otherwise ready.
 //
@@ -44,7 +49,10 @@ type DynamicOauth2HMACStrategy struct {
 	keyFunc      func() []byte
 }
 
-var _ fositeoauth2.CoreStrategy = &DynamicOauth2HMACStrategy{}
+var (
+	_ fositeoauth2.CoreStrategy   = &DynamicOauth2HMACStrategy{}
+	_ rfc8628.RFC8628CodeStrategy = &DynamicOauth2HMACStrategy{}
+)
 
 func NewDynamicOauth2HMACStrategy(
 	fositeConfig *fosite.Config,
@@ -156,6 +164,62 @@ func (s *DynamicOauth2HMACStrategy) ValidateAuthorizeCode(
 	return s.delegate().ValidateAuthorizeCode(ctx, requester, replacePrefix(token, pinAuthcodePrefix, oryAuthcodePrefix))
 }
 
+func (s *DynamicOauth2HMACStrategy) DeviceCodeSignature(ctx context.Context, token string) (string, error) {
+	return s.deviceDelegate().DeviceCodeSignature(ctx, token)
+}
+
+func (s *DynamicOauth2HMACStrategy) GenerateDeviceCode(ctx context.Context) (string, string, error) {
+	deviceCode, sig, err := s.deviceDelegate().GenerateDeviceCode(ctx)
+	if err == nil {
+		if !strings.HasPrefix(deviceCode, oryDeviceCodePrefix) {
+			// This would only happen if fosite changed how it generates tokens. Defensive programming here.
+			return "", "", errorsx.WithStack(fosite.ErrInvalidTokenFormat.
+				WithDebugf("Generated token does not have expected prefix"))
+		}
+		deviceCode = replacePrefix(deviceCode, oryDeviceCodePrefix, pinDeviceCodePrefix)
+	}
+	return deviceCode, sig, err
+}
+
+func (s *DynamicOauth2HMACStrategy) ValidateDeviceCode(
+	ctx context.Context,
+	requester fosite.DeviceRequester,
+	token string,
+) error {
+	if !strings.HasPrefix(token, pinDeviceCodePrefix) {
+		return errorsx.WithStack(fosite.ErrInvalidTokenFormat.
+			WithDebugf("Device code did not have prefix %q", pinDeviceCodePrefix))
+	}
+	return s.deviceDelegate().ValidateDeviceCode(ctx, requester, replacePrefix(token, pinDeviceCodePrefix, oryDeviceCodePrefix))
+}
+
+func (s *DynamicOauth2HMACStrategy) UserCodeSignature(ctx context.Context, token string) (string, error) {
+	return s.deviceDelegate().UserCodeSignature(ctx, token)
+}
+
+func (s *DynamicOauth2HMACStrategy) GenerateUserCode(ctx context.Context) (string, string, error) {
+	return s.deviceDelegate().GenerateUserCode(ctx)
+}
+
+func (s *DynamicOauth2HMACStrategy) ValidateUserCode(
+	ctx context.Context,
+	requester fosite.DeviceRequester,
+	token string,
+) error {
+	return s.deviceDelegate().ValidateUserCode(ctx, requester, token)
+}
+
+func (s *DynamicOauth2HMACStrategy) ShouldRateLimit(ctx context.Context, token string) (bool, error) {
+	return s.deviceDelegate().ShouldRateLimit(ctx, token)
+}
+
 func (s *DynamicOauth2HMACStrategy) delegate() *fositeoauth2.HMACSHAStrategy {
 	return compose.NewOAuth2HMACStrategy(storage.NewDynamicGlobalSecretConfig(s.fositeConfig, s.keyFunc))
 }
+
+func (s *DynamicOauth2HMACStrategy) deviceDelegate() *rfc8628.DefaultDeviceStrategy {
+	return &rfc8628.DefaultDeviceStrategy{
+		Enigma: &hmac.HMACStrategy{Config: storage.NewDynamicGlobalSecretConfig(s.fositeConfig, s.keyFunc)},
+		Config: s.fositeConfig,
+	}
+}
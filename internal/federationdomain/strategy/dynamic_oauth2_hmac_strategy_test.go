@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package strategy
@@ -212,3 +212,111 @@ func TestDynamicOauth2HMACStrategy_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestDynamicOauth2HMACStrategy_DeviceCodes(t *testing.T) {
+	s := NewDynamicOauth2HMACStrategy(
+		&fosite.Config{}, // defaults are good enough for this unit test
+		func() []byte { return []byte("12345678901234567890123456789012") }, // 32 character secret key
+	)
+
+	generateTokenErrorCausingStrategy := NewDynamicOauth2HMACStrategy(
+		&fosite.Config{},
+		func() []byte { return []byte("too_short_causes_error") }, // secret key is below required 32 characters
+	)
+
+	var ctxIsIgnored context.Context
+
+	unexpiredSession := &fosite.DefaultSession{}
+	unexpiredSession.SetExpiresAt(fosite.DeviceCode, time.Now().Add(time.Hour))
+	requesterWithUnexpiredCode := &fosite.DeviceRequest{Request: fosite.Request{Session: unexpiredSession}}
+
+	expiredSession := &fosite.DefaultSession{}
+	expiredSession.SetExpiresAt(fosite.DeviceCode, time.Now().Add(-time.Hour))
+	requesterWithExpiredCode := &fosite.DeviceRequest{Request: fosite.Request{Session: expiredSession}}
+
+	generatedCode1, signature1, err := s.GenerateDeviceCode(ctxIsIgnored)
+	require.NoError(t, err)
+	generatedCode2, signature2, err := s.GenerateDeviceCode(ctxIsIgnored)
+	require.NoError(t, err)
+
+	for _, tt := range []struct{ code, signature string }{{generatedCode1, signature1}, {generatedCode2, signature2}} {
+		// Device codes should start with a custom prefix to make them identifiable as device codes when seen by a user
+		// out of context, such as when accidentally committed to a GitHub repo.
+		require.True(t, strings.HasPrefix(tt.code, "pin_dc_"), "device code %q did not have expected prefix", tt.code)
+		require.Equal(t, 1, strings.Count(tt.code, "."))
+		require.Len(t, tt.signature, 43)
+		require.True(t, strings.HasSuffix(tt.code, "."+tt.signature), "device code %q did not end with dot followed by signature", tt.code)
+		require.Len(t, strings.Split(tt.code, ".")[0], len("pin_dc_")+43)
+
+		// The signature is the part after the dot.
+		signature, err := s.DeviceCodeSignature(ctxIsIgnored, tt.code)
+		require.NoError(t, err)
+		require.Equal(t, tt.signature, signature)
+
+		require.NoError(t, s.ValidateDeviceCode(ctxIsIgnored, requesterWithUnexpiredCode, tt.code))
+	}
+
+	// Each generated code is random/different.
+	require.NotEqual(t, generatedCode1, generatedCode2)
+	require.NotEqual(t, signature1, signature2)
+
+	// Validate when expired according to session.
+	require.EqualError(t, s.ValidateDeviceCode(ctxIsIgnored, requesterWithExpiredCode, generatedCode1), "expired_token")
+
+	// Validate when missing prefix.
+	require.EqualError(t, s.ValidateDeviceCode(ctxIsIgnored, requesterWithUnexpiredCode, strings.TrimPrefix(generatedCode1, "pin_dc_")), "invalid_token")
+
+	// Validate when wrong prefix.
+	require.EqualError(t, s.ValidateDeviceCode(ctxIsIgnored, requesterWithUnexpiredCode, "pin_wrong_"+strings.TrimPrefix(generatedCode1, "pin_dc_")), "invalid_token")
+
+	// Validate when correct prefix but bad signature.
+	var b64 = base64.URLEncoding.WithPadding(base64.NoPadding)
+	codeWithBadSig := "pin_dc_" + b64.EncodeToString([]byte("some-token")) + "." + b64.EncodeToString([]byte("bad-signature"))
+	require.EqualError(t, s.ValidateDeviceCode(ctxIsIgnored, requesterWithUnexpiredCode, codeWithBadSig), "token_signature_mismatch")
+
+	// Test the return values when an error is encountered during generation.
+	generatedCode3, signature3, err := generateTokenErrorCausingStrategy.GenerateDeviceCode(ctxIsIgnored)
+	require.EqualError(t, err, "secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got 22 byte")
+	require.Empty(t, generatedCode3)
+	require.Empty(t, signature3)
+}
+
+func TestDynamicOauth2HMACStrategy_UserCodes(t *testing.T) {
+	s := NewDynamicOauth2HMACStrategy(
+		&fosite.Config{}, // defaults are good enough for this unit test
+		func() []byte { return []byte("12345678901234567890123456789012") }, // 32 character secret key
+	)
+
+	var ctxIsIgnored context.Context
+
+	unexpiredSession := &fosite.DefaultSession{}
+	unexpiredSession.SetExpiresAt(fosite.UserCode, time.Now().Add(time.Hour))
+	requesterWithUnexpiredCode := &fosite.DeviceRequest{Request: fosite.Request{Session: unexpiredSession}}
+
+	expiredSession := &fosite.DefaultSession{}
+	expiredSession.SetExpiresAt(fosite.UserCode, time.Now().Add(-time.Hour))
+	requesterWithExpiredCode := &fosite.DeviceRequest{Request: fosite.Request{Session: expiredSession}}
+
+	userCode1, signature1, err := s.GenerateUserCode(ctxIsIgnored)
+	require.NoError(t, err)
+	userCode2, signature2, err := s.GenerateUserCode(ctxIsIgnored)
+	require.NoError(t, err)
+
+	// User codes are short and easy to type, so they do not contain their own signature.
+	require.Regexp(t, "^[A-Z]{8}$", userCode1)
+	require.Regexp(t, "^[A-Z]{8}$", userCode2)
+	require.NotEqual(t, userCode1, userCode2)
+	require.NotEqual(t, signature1, signature2)
+
+	// The signature of a user code is stable, so it can be used to look up the user code in storage.
+	signature, err := s.UserCodeSignature(ctxIsIgnored, userCode1)
+	require.NoError(t, err)
+	require.Equal(t, signature1, signature)
+
+	require.NoError(t, s.ValidateUserCode(ctxIsIgnored, requesterWithUnexpiredCode, userCode1))
+	require.EqualError(t, s.ValidateUserCode(ctxIsIgnored, requesterWithExpiredCode, userCode1), "expired_token")
+
+	shouldRateLimit, err := s.ShouldRateLimit(ctxIsIgnored, userCode1)
+	require.NoError(t, err)
+	require.False(t, shouldRateLimit)
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package timeouts
@@ -29,6 +29,15 @@ type Configuration struct {
 	// has to come back to exchange the authcode for tokens at the token endpoint.
 	AuthorizeCodeLifespan time.Duration
 
+	// How long a device code and its user code issued by the device authorization endpoint are valid. This determines
+	// how much time the end user has to visit the device verification page and finish logging in, and also how long
+	// the client will keep polling the token endpoint while waiting for that to happen.
+	DeviceAndUserCodeLifespan time.Duration
+
+	// How long a request_uri issued by the pushed authorization request endpoint is valid. This determines how much
+	// time the client has to redirect the end user's browser to the authorization endpoint with that request_uri.
+	PushedAuthorizeRequestLifespan time.Duration
+
 	// The lifetime of an downstream access token issued by the token endpoint. Access tokens should generally
 	// be fairly short-lived.
 	AccessTokenLifespan time.Duration
@@ -85,6 +94,25 @@ type Configuration struct {
 	// as AuthorizeCodeLifespan to avoid any chance of the garbage collector deleting it while it is being used.
 	OIDCSessionStorageLifetime StorageLifetime
 
+	// DeviceCodeSessionStorageLifetime is the length of time after which a device code session is allowed to be garbage
+	// collected from storage. Similar to authcodes, device codes are kept in storage after they are redeemed to allow the
+	// system to mark them as already used, so it can reject any future uses of the same device code and revoke the
+	// access and refresh tokens associated with the session. Therefore, this should be significantly longer than the
+	// DeviceAndUserCodeLifespan, and there is probably no reason to make it longer than the sum of the
+	// DeviceAndUserCodeLifespan and the RefreshTokenLifespan.
+	DeviceCodeSessionStorageLifetime StorageLifetime
+
+	// UserCodeSessionStorageLifetime is the length of time after which the index from a user code to its device code
+	// is allowed to be garbage collected from storage. It is not needed after the user code has expired, so this can
+	// be just slightly longer than the DeviceAndUserCodeLifespan.
+	UserCodeSessionStorageLifetime StorageLifetime
+
+	// PushedAuthorizeRequestSessionStorageLifetime is the length of time after which a pushed authorization request is
+	// allowed to be garbage collected from storage. A pushed authorization request is explicitly deleted when it is used
+	// at the authorization endpoint, and it is not needed anymore after it has expired. Therefore, this can be just
+	// slightly longer than the PushedAuthorizeRequestLifespan.
+	PushedAuthorizeRequestSessionStorageLifetime StorageLifetime
+
 	// AccessTokenSessionStorageLifetime is the length of time after which an access token's session data is allowed
 	// to be garbage collected from storage.  These must exist in storage for as long as the refresh token is valid
 	// or else the refresh flow will not work properly. So this must be longer than RefreshTokenLifespan.
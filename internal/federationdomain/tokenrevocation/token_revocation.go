@@ -0,0 +1,75 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tokenrevocation wraps fosite's token revocation handler, so the revocation endpoint can tell
+// whether a revocation request actually deleted the storage of any downstream tokens.
+package tokenrevocation
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+// contextKey type is unexported to prevent collisions.
+type contextKey int
+
+const tokenRevokedKey contextKey = iota
+
+// OAuth2TokenRevocationFactory is similar to the function of the same name in the fosite compose package,
+// except it records on the request's context when token storage was revoked. See WithRevocationRecorder.
+func OAuth2TokenRevocationFactory(config fosite.Configurator, storage any, strategy any) any {
+	tokenRevocationHandler := compose.OAuth2TokenRevocationFactory(config, storage, strategy).(*oauth2.TokenRevocationHandler)
+	tokenRevocationHandler.TokenRevocationStorage = &recordingTokenRevocationStorage{
+		TokenRevocationStorage: tokenRevocationHandler.TokenRevocationStorage,
+	}
+	return tokenRevocationHandler
+}
+
+var _ oauth2.TokenRevocationStorage = (*recordingTokenRevocationStorage)(nil)
+
+type recordingTokenRevocationStorage struct {
+	oauth2.TokenRevocationStorage
+}
+
+func (s *recordingTokenRevocationStorage) RevokeRefreshToken(ctx context.Context, requestID string) error {
+	err := s.TokenRevocationStorage.RevokeRefreshToken(ctx, requestID)
+	if err == nil {
+		recordTokenRevoked(ctx)
+	}
+	return err
+}
+
+func (s *recordingTokenRevocationStorage) RevokeAccessToken(ctx context.Context, requestID string) error {
+	err := s.TokenRevocationStorage.RevokeAccessToken(ctx, requestID)
+	if err == nil {
+		recordTokenRevoked(ctx)
+	}
+	return err
+}
+
+// WithRevocationRecorder returns a context which should be passed to fosite's NewRevocationRequest, so that
+// TokenWasRevoked can be called with the same context afterward.
+func WithRevocationRecorder(ctx context.Context) context.Context {
+	tokenRevoked := atomic.Bool{} // safe for concurrent access
+	return context.WithValue(ctx, tokenRevokedKey, &tokenRevoked)
+}
+
+// TokenWasRevoked returns true when the storage of a downstream token was deleted by a revocation request that
+// used the given context. This is needed because fosite does not return an error when the token was unknown, e.g.
+// because it was already revoked.
+func TokenWasRevoked(ctx context.Context) bool {
+	tokenRevoked, _ := ctx.Value(tokenRevokedKey).(*atomic.Bool)
+	return tokenRevoked != nil && tokenRevoked.Load()
+}
+
+func recordTokenRevoked(ctx context.Context) {
+	tokenRevoked, _ := ctx.Value(tokenRevokedKey).(*atomic.Bool)
+	if tokenRevoked == nil {
+		return
+	}
+	tokenRevoked.Store(true)
+}
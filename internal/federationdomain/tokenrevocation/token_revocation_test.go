@@ -0,0 +1,84 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package tokenrevocation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokenRevocationStorage struct {
+	oauth2.TokenRevocationStorage
+	revokeRefreshTokenErr error
+	revokeAccessTokenErr  error
+}
+
+func (f *fakeTokenRevocationStorage) RevokeRefreshToken(_ context.Context, _ string) error {
+	return f.revokeRefreshTokenErr
+}
+
+func (f *fakeTokenRevocationStorage) RevokeAccessToken(_ context.Context, _ string) error {
+	return f.revokeAccessTokenErr
+}
+
+func TestTokenWasRevoked(t *testing.T) {
+	tests := []struct {
+		name                  string
+		useRecorder           bool
+		revokeRefreshTokenErr error
+		revokeAccessTokenErr  error
+		wantRevoked           bool
+	}{
+		{
+			name:        "both tokens were revoked",
+			useRecorder: true,
+			wantRevoked: true,
+		},
+		{
+			name:                  "only the access token was revoked",
+			useRecorder:           true,
+			revokeRefreshTokenErr: fosite.ErrNotFound,
+			wantRevoked:           true,
+		},
+		{
+			name:                  "no tokens were revoked",
+			useRecorder:           true,
+			revokeRefreshTokenErr: fosite.ErrNotFound,
+			revokeAccessTokenErr:  errors.New("some error"),
+			wantRevoked:           false,
+		},
+		{
+			name:        "the context has no recorder",
+			useRecorder: false,
+			wantRevoked: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage := &recordingTokenRevocationStorage{TokenRevocationStorage: &fakeTokenRevocationStorage{
+				revokeRefreshTokenErr: tt.revokeRefreshTokenErr,
+				revokeAccessTokenErr:  tt.revokeAccessTokenErr,
+			}}
+
+			ctx := context.Background()
+			if tt.useRecorder {
+				ctx = WithRevocationRecorder(ctx)
+			}
+			require.False(t, TokenWasRevoked(ctx))
+
+			require.Equal(t, tt.revokeRefreshTokenErr, storage.RevokeRefreshToken(ctx, "some-request-id"))
+			require.Equal(t, tt.revokeAccessTokenErr, storage.RevokeAccessToken(ctx, "some-request-id"))
+
+			require.Equal(t, tt.wantRevoked, TokenWasRevoked(ctx))
+		})
+	}
+}
@@ -0,0 +1,466 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package transformscompiler compiles the identity transformations which are configured for the identity providers
+// of FederationDomains and in IdentityTransformLibraries, and evaluates the examples which test them. It is used
+// both by the Supervisor and by the CLI's offline testing of transformations, so they always agree on the results.
+package transformscompiler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
+	"go.pinniped.dev/internal/celtransformer"
+	"go.pinniped.dev/internal/idtransform"
+)
+
+// MaxExpressionRuntime is the maximum time that the Supervisor allows each expression to run.
+const MaxExpressionRuntime = 5 * time.Second
+
+// Paths are the paths to a list of transforms, for use in validation messages.
+type Paths struct {
+	Expressions string // the prefix of messages about invalid expressions
+	Examples    string // the prefix of messages about the examples
+	Types       string // the prefix of errors about invalid types of constants or expressions
+}
+
+// PathsFunc returns the Paths of a list of transforms. The transformsPathSuffix is empty for the main list of
+// transforms, or names a nested list of transforms, e.g. ".tokenExchange[0]".
+type PathsFunc func(transformsPathSuffix string) Paths
+
+// IdentityProviderPaths returns the Paths of the transforms of the FederationDomain identity provider at idpIndex.
+func IdentityProviderPaths(idpIndex int) PathsFunc {
+	return func(transformsPathSuffix string) Paths {
+		return Paths{
+			Expressions: fmt.Sprintf("spec.identityProvider[%d].transforms%s", idpIndex, transformsPathSuffix),
+			Examples:    fmt.Sprintf(".spec.identityProviders[%d].transforms%s", idpIndex, transformsPathSuffix),
+			Types:       "spec.identityProvider[].transforms" + transformsPathSuffix,
+		}
+	}
+}
+
+// LibraryPaths returns the Paths of the transforms of an IdentityTransformLibrary.
+func LibraryPaths() Paths {
+	return Paths{Expressions: "spec", Examples: ".spec", Types: "spec"}
+}
+
+// ValidationErrorMessages collects the validation messages about transforms, grouped by the status condition which
+// the Supervisor uses to report them on a FederationDomain.
+type ValidationErrorMessages struct {
+	Libraries   []string
+	Expressions []string
+	Examples    []string
+}
+
+// Library is the result of compiling an IdentityTransformLibrary and evaluating its examples.
+type Library struct {
+	Pipeline      *idtransform.TransformationPipeline // nil when an expression was invalid
+	Consts        *celtransformer.TransformationConstants
+	ErrorMessages []string // empty when the library is valid
+}
+
+// LibraryGetter returns the compiled IdentityTransformLibrary with the given name, or nil when it does not exist.
+type LibraryGetter func(name string) (*Library, error)
+
+// CompileLibrary compiles the expressions of an IdentityTransformLibrary and evaluates its examples.
+func CompileLibrary(
+	ctx context.Context,
+	compiler *celtransformer.CELTransformer,
+	spec supervisorconfigv1alpha1.IdentityTransformLibrarySpec,
+) (*Library, error) {
+	paths := LibraryPaths()
+
+	consts, err := makeConstants(spec.Constants, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, errorsForExpressions, err := makePipeline(compiler, spec.Expressions, paths, consts)
+	if err != nil {
+		return nil, err
+	}
+	_, errorsForExamples := evaluateExamples(ctx, spec.Examples, paths, pipeline)
+
+	library := &Library{
+		Pipeline: pipeline,
+		Consts:   consts,
+	}
+	for _, errorMessage := range []string{errorsForExpressions, errorsForExamples} {
+		if len(errorMessage) > 0 {
+			library.ErrorMessages = append(library.ErrorMessages, errorMessage)
+		}
+	}
+	return library, nil
+}
+
+// CompileTransforms compiles the transforms of a FederationDomain identity provider, including its libraries and its
+// token exchange transforms, and evaluates all their examples. Validation problems are added to the given
+// validationErrorMessages, and cause the returned bool to be false. Only unexpected problems are returned as errors.
+func CompileTransforms(
+	ctx context.Context,
+	compiler *celtransformer.CELTransformer,
+	transforms supervisorconfigv1alpha1.FederationDomainTransforms,
+	paths PathsFunc,
+	getLibrary LibraryGetter,
+	validationErrorMessages *ValidationErrorMessages,
+) (*idtransform.TransformationPipeline, idtransform.TokenExchangePipelines, bool, error) {
+	libraries, librariesAreValid, err := findLibraries(transforms.Libraries, paths(""), getLibrary, validationErrorMessages)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !librariesAreValid {
+		// The expressions may use the constants of the libraries, so there is no point in trying to compile them.
+		validationErrorMessages.Examples = append(validationErrorMessages.Examples, fmt.Sprintf(
+			"unable to check if the examples specified by %s.examples[] had errors because a library was invalid",
+			paths("").Examples))
+		return nil, nil, false, nil
+	}
+
+	ownConsts, err := makeConstants(transforms.Constants, paths(""))
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	// The constants of the libraries are available to the identity provider's expressions, but the identity
+	// provider's own constants take precedence over them.
+	librariesPipeline := idtransform.NewTransformationPipeline()
+	allConsts := make([]*celtransformer.TransformationConstants, 0, len(libraries)+1)
+	for _, library := range libraries {
+		librariesPipeline.AppendPipeline(library.Pipeline)
+		allConsts = append(allConsts, library.Consts)
+	}
+	consts := mergeConstants(append(allConsts, ownConsts)...)
+
+	pipeline, allExamplesPassed, err := makePipelineAndEvaluateExamples(
+		ctx, compiler, librariesPipeline, transforms.Expressions, transforms.Examples, paths(""), consts, validationErrorMessages)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	// The token exchange pipelines share the constants of the identity provider's transforms.
+	var tokenExchangePipelines idtransform.TokenExchangePipelines
+	for tokenExchangeIndex, tokenExchange := range transforms.TokenExchange {
+		tokenExchangePipeline, tokenExchangeExamplesPassed, err := makePipelineAndEvaluateExamples(
+			ctx, compiler, idtransform.NewTransformationPipeline(), tokenExchange.Expressions, tokenExchange.Examples,
+			paths(fmt.Sprintf(".tokenExchange[%d]", tokenExchangeIndex)), consts, validationErrorMessages)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if !tokenExchangeExamplesPassed {
+			allExamplesPassed = false
+		}
+		tokenExchangePipelines = append(tokenExchangePipelines,
+			idtransform.NewTokenExchangePipeline(tokenExchange.Audiences, tokenExchange.ClientNames, tokenExchangePipeline))
+	}
+
+	return pipeline, tokenExchangePipelines, allExamplesPassed, nil
+}
+
+// findLibraries returns the compiled libraries referenced by a list of transforms, in order.
+// When any library cannot be found or is invalid, it adds validation messages and returns false.
+func findLibraries(
+	libraryRefs []supervisorconfigv1alpha1.FederationDomainTransformsLibraryRef,
+	paths Paths,
+	getLibrary LibraryGetter,
+	validationErrorMessages *ValidationErrorMessages,
+) ([]*Library, bool, error) {
+	libraries := make([]*Library, 0, len(libraryRefs))
+	librariesAreValid := true
+
+	for libraryIndex, libraryRef := range libraryRefs {
+		library, err := getLibrary(libraryRef.Name)
+		if err != nil {
+			return nil, false, err
+		}
+		if library == nil {
+			validationErrorMessages.Libraries = append(validationErrorMessages.Libraries, fmt.Sprintf(
+				"%s.libraries[%d].name refers to an IdentityTransformLibrary %q which was not found",
+				paths.Examples, libraryIndex, libraryRef.Name))
+			librariesAreValid = false
+			continue
+		}
+		if len(library.ErrorMessages) > 0 {
+			validationErrorMessages.Libraries = append(validationErrorMessages.Libraries, fmt.Sprintf(
+				"%s.libraries[%d].name refers to an IdentityTransformLibrary %q which is invalid:\n%s",
+				paths.Examples, libraryIndex, libraryRef.Name, strings.Join(library.ErrorMessages, "\n\n")))
+			librariesAreValid = false
+			continue
+		}
+
+		libraries = append(libraries, library)
+	}
+
+	return libraries, librariesAreValid, nil
+}
+
+// makePipelineAndEvaluateExamples compiles one pipeline of expressions and evaluates its examples.
+// The compiled expressions are appended to a copy of the given basePipeline, and the examples are evaluated
+// against the result.
+func makePipelineAndEvaluateExamples(
+	ctx context.Context,
+	compiler *celtransformer.CELTransformer,
+	basePipeline *idtransform.TransformationPipeline,
+	expressions []supervisorconfigv1alpha1.FederationDomainTransformsExpression,
+	examples []supervisorconfigv1alpha1.FederationDomainTransformsExample,
+	paths Paths,
+	consts *celtransformer.TransformationConstants,
+	validationErrorMessages *ValidationErrorMessages,
+) (*idtransform.TransformationPipeline, bool, error) {
+	expressionsPipeline, errorsForExpressions, err := makePipeline(compiler, expressions, paths, consts)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(errorsForExpressions) > 0 {
+		validationErrorMessages.Expressions = append(validationErrorMessages.Expressions, errorsForExpressions)
+	}
+
+	var pipeline *idtransform.TransformationPipeline
+	if expressionsPipeline != nil {
+		pipeline = idtransform.NewTransformationPipeline()
+		pipeline.AppendPipeline(basePipeline)
+		pipeline.AppendPipeline(expressionsPipeline)
+	}
+
+	allExamplesPassed, errorsForExamples := evaluateExamples(ctx, examples, paths, pipeline)
+	if len(errorsForExamples) > 0 {
+		validationErrorMessages.Examples = append(validationErrorMessages.Examples, errorsForExamples)
+	}
+
+	return pipeline, allExamplesPassed, nil
+}
+
+// makeConstants reads a list of constants.
+func makeConstants(
+	constants []supervisorconfigv1alpha1.FederationDomainTransformsConstant,
+	paths Paths,
+) (*celtransformer.TransformationConstants, error) {
+	consts := &celtransformer.TransformationConstants{
+		StringConstants:     map[string]string{},
+		StringListConstants: map[string][]string{},
+	}
+
+	// Read all the declared constants.
+	for _, constant := range constants {
+		// The CRD requires the name field, and validates that it has at least one character,
+		// and validates that the names are unique within the list.
+		switch constant.Type {
+		case "string":
+			consts.StringConstants[constant.Name] = constant.StringValue
+		case "stringList":
+			consts.StringListConstants[constant.Name] = constant.StringListValue
+		default:
+			// This shouldn't really happen since the CRD validates it, but handle it as an error.
+			return nil, fmt.Errorf("one of %s.constants[].type is invalid: %q", paths.Types, constant.Type)
+		}
+	}
+
+	return consts, nil
+}
+
+// mergeConstants combines several sets of constants. When more than one set declares a constant with the
+// same name, then the one from the set which is listed last wins, regardless of the type of the constant.
+func mergeConstants(allConsts ...*celtransformer.TransformationConstants) *celtransformer.TransformationConstants {
+	merged := &celtransformer.TransformationConstants{
+		StringConstants:     map[string]string{},
+		StringListConstants: map[string][]string{},
+	}
+	for _, consts := range allConsts {
+		for name, value := range consts.StringConstants {
+			delete(merged.StringListConstants, name)
+			merged.StringConstants[name] = value
+		}
+		for name, value := range consts.StringListConstants {
+			delete(merged.StringConstants, name)
+			merged.StringListConstants[name] = value
+		}
+	}
+	return merged
+}
+
+func makePipeline(
+	compiler *celtransformer.CELTransformer,
+	expressions []supervisorconfigv1alpha1.FederationDomainTransformsExpression,
+	paths Paths,
+	consts *celtransformer.TransformationConstants,
+) (*idtransform.TransformationPipeline, string, error) {
+	pipeline := idtransform.NewTransformationPipeline()
+	expressionsCompileErrors := []string{}
+
+	// Compile all the expressions and add them to the pipeline.
+	for exprIndex, expr := range expressions {
+		var rawTransform celtransformer.CELTransformation
+		switch expr.Type {
+		case "username/v1":
+			rawTransform = &celtransformer.UsernameTransformation{Expression: expr.Expression}
+		case "groups/v1":
+			rawTransform = &celtransformer.GroupsTransformation{Expression: expr.Expression}
+		case "policy/v1":
+			rawTransform = &celtransformer.AllowAuthenticationPolicy{
+				Expression:                    expr.Expression,
+				RejectedAuthenticationMessage: expr.Message,
+			}
+		case "claims/v1":
+			rawTransform = &celtransformer.ClaimsTransformation{Expression: expr.Expression}
+		default:
+			// This shouldn't really happen since the CRD validates it, but handle it as an error.
+			return nil, "", fmt.Errorf("one of %s.expressions[].type is invalid: %q", paths.Types, expr.Type)
+		}
+
+		compiledTransform, err := compiler.CompileTransformation(rawTransform, consts)
+		if err != nil {
+			expressionsCompileErrors = append(expressionsCompileErrors,
+				fmt.Sprintf("%s.expressions[%d].expression was invalid:\n%s",
+					paths.Expressions, exprIndex, err.Error()))
+		}
+
+		pipeline.AppendTransformation(compiledTransform)
+	}
+
+	if len(expressionsCompileErrors) > 0 {
+		// One or more of the expressions did not compile, so we don't have a useful pipeline to return.
+		// Return the validation messages.
+		return nil, strings.Join(expressionsCompileErrors, "\n\n"), nil
+	}
+
+	return pipeline, "", nil
+}
+
+func evaluateExamples(
+	ctx context.Context,
+	examples []supervisorconfigv1alpha1.FederationDomainTransformsExample,
+	paths Paths,
+	pipeline *idtransform.TransformationPipeline,
+) (bool, string) {
+	errorFmt := paths.Examples + ".examples[%d] example failed:\nexpected: %s\nactual:   %s"
+	examplesErrors := []string{}
+
+	if pipeline == nil {
+		// Unable to evaluate the conditions where the pipeline of expressions was invalid.
+		return false, fmt.Sprintf(
+			"unable to check if the examples specified by %s.examples[] had errors because an expression was invalid",
+			paths.Examples)
+	}
+
+	// Run all the provided transform examples. If any fail, put errors on the FederationDomain status.
+	for exIndex, e := range examples {
+		result, err := pipeline.Evaluate(ctx, e.Username, e.Groups, &idtransform.AuthenticationContext{ACR: e.ACR, AMR: e.AMR})
+		if err != nil {
+			examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, exIndex,
+				"no transformation errors",
+				fmt.Sprintf("transformations resulted in an unexpected error %q", err.Error())))
+			continue
+		}
+		resultWasAuthRejected := !result.AuthenticationAllowed
+
+		if e.Expects.Rejected && !resultWasAuthRejected {
+			examplesErrors = append(examplesErrors,
+				fmt.Sprintf(errorFmt, exIndex, "authentication to be rejected", "authentication was not rejected"))
+			continue
+		}
+
+		if !e.Expects.Rejected && resultWasAuthRejected {
+			examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, exIndex,
+				"authentication not to be rejected",
+				fmt.Sprintf("authentication was rejected with message %q", result.RejectedAuthenticationMessage)))
+			continue
+		}
+
+		expectedRejectionMessage := e.Expects.Message
+		if len(expectedRejectionMessage) == 0 {
+			expectedRejectionMessage = celtransformer.DefaultPolicyRejectedAuthMessage
+		}
+		if e.Expects.Rejected && resultWasAuthRejected && expectedRejectionMessage != result.RejectedAuthenticationMessage {
+			examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, exIndex,
+				fmt.Sprintf("authentication rejection message %q", expectedRejectionMessage),
+				fmt.Sprintf("authentication rejection message %q", result.RejectedAuthenticationMessage)))
+			continue
+		}
+
+		if result.AuthenticationAllowed {
+			// In the case where the user expected the auth to be allowed and it was allowed, then compare
+			// the expected username and group names to the actual username and group names.
+			if e.Expects.Username != result.Username {
+				examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, exIndex,
+					fmt.Sprintf("username %q", e.Expects.Username),
+					fmt.Sprintf("username %q", result.Username)))
+			}
+			expectedGroups := e.Expects.Groups
+			if expectedGroups == nil {
+				expectedGroups = []string{}
+			}
+			if !stringSetsEqual(expectedGroups, result.Groups) {
+				examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, exIndex,
+					fmt.Sprintf("groups [%s]", strings.Join(sortAndQuote(expectedGroups), ", ")),
+					fmt.Sprintf("groups [%s]", strings.Join(sortAndQuote(result.Groups), ", "))))
+			}
+			expectedClaims, err := expectedAdditionalClaims(e.Expects.AdditionalClaims)
+			if err != nil {
+				examplesErrors = append(examplesErrors, fmt.Sprintf("%s.examples[%d].expects.additionalClaims is invalid: %s",
+					paths.Examples, exIndex, err.Error()))
+				continue
+			}
+			actualClaims := result.AdditionalClaims
+			if actualClaims == nil {
+				actualClaims = map[string]any{}
+			}
+			if !reflect.DeepEqual(expectedClaims, actualClaims) {
+				examplesErrors = append(examplesErrors, fmt.Sprintf(errorFmt, exIndex,
+					fmt.Sprintf("additional claims %s", claimsForDisplay(expectedClaims)),
+					fmt.Sprintf("additional claims %s", claimsForDisplay(actualClaims))))
+			}
+		}
+	}
+
+	if len(examplesErrors) > 0 {
+		return false, strings.Join(examplesErrors, "\n\n")
+	}
+
+	return true, ""
+}
+
+// expectedAdditionalClaims decodes the JSON values of the expected additional claims of an example.
+func expectedAdditionalClaims(jsonClaims map[string]string) (map[string]any, error) {
+	claims := map[string]any{}
+	for _, name := range slices.Sorted(maps.Keys(jsonClaims)) {
+		var value any
+		if err := json.Unmarshal([]byte(jsonClaims[name]), &value); err != nil {
+			return nil, fmt.Errorf("the value of claim %q is not valid JSON: %w", name, err)
+		}
+		claims[name] = value
+	}
+	return claims, nil
+}
+
+// claimsForDisplay returns a JSON representation of the claims, with the claim names sorted.
+func claimsForDisplay(claims map[string]any) string {
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return fmt.Sprintf("%v", claims)
+	}
+	return string(claimsJSON)
+}
+
+func sortAndQuote(strs []string) []string {
+	quoted := make([]string, 0, len(strs))
+	for _, s := range strs {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	sort.Strings(quoted)
+	return quoted
+}
+
+func stringSetsEqual(a []string, b []string) bool {
+	aSet := sets.New(a...)
+	bSet := sets.New(b...)
+	return aSet.Equal(bSet)
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamhealth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ory/fosite"
+	"k8s.io/apimachinery/pkg/types"
+
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/pkg/oidcclient/nonce"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+// ErrUpstreamUnavailable is returned instead of contacting an upstream identity provider which is currently
+// considered to be unavailable. The error returned from a login or refresh should be compared to this using errors.Is().
+var ErrUpstreamUnavailable = &fosite.RFC6749Error{
+	ErrorField:       "temporarily_unavailable", // this string matches what fosite uses for temporarily unavailable errors
+	DescriptionField: "The upstream identity provider is currently unavailable.",
+	HintField:        "Please try again later.",
+	CodeField:        http.StatusServiceUnavailable,
+}
+
+// circuitBreakingIdentityProvider wraps a FederationDomainResolvedIdentityProvider. It fails fast instead of
+// contacting the upstream while the Tracker considers the upstream to be unavailable, and it records the
+// outcomes of logins and refreshes in the Tracker.
+type circuitBreakingIdentityProvider struct {
+	resolvedprovider.FederationDomainResolvedIdentityProvider
+	tracker *Tracker
+}
+
+var _ resolvedprovider.FederationDomainResolvedIdentityProvider = (*circuitBreakingIdentityProvider)(nil)
+
+// WithCircuitBreaker wraps a FederationDomainResolvedIdentityProvider so that its upstream redirects, logins,
+// and refreshes fail fast while its upstream identity provider is unavailable according to the tracker.
+func WithCircuitBreaker(
+	p resolvedprovider.FederationDomainResolvedIdentityProvider,
+	tracker *Tracker,
+) resolvedprovider.FederationDomainResolvedIdentityProvider {
+	return &circuitBreakingIdentityProvider{FederationDomainResolvedIdentityProvider: p, tracker: tracker}
+}
+
+func (p *circuitBreakingIdentityProvider) uid() types.UID {
+	return p.GetProvider().GetResourceUID()
+}
+
+func (p *circuitBreakingIdentityProvider) UpstreamAuthorizeRedirectURL(
+	ctx context.Context,
+	state *resolvedprovider.UpstreamAuthorizeRequestState,
+	downstreamIssuerURL string,
+) (string, error) {
+	// Redirecting the user's browser does not contact the upstream, so do not use up the one request
+	// which Allow would let through to try the upstream again. Just check whether it is available.
+	if !p.tracker.IsAvailable(p.uid()) {
+		return "", ErrUpstreamUnavailable
+	}
+	return p.FederationDomainResolvedIdentityProvider.UpstreamAuthorizeRedirectURL(ctx, state, downstreamIssuerURL)
+}
+
+func (p *circuitBreakingIdentityProvider) LoginFromCallback(
+	ctx context.Context,
+	authCode string,
+	pkce pkce.Code,
+	nonce nonce.Nonce,
+	redirectURI string,
+) (*resolvedprovider.Identity, *resolvedprovider.IdentityLoginExtras, error) {
+	if err := p.tracker.Allow(p.uid()); err != nil {
+		return nil, nil, httperr.Wrap(http.StatusServiceUnavailable, "upstream identity provider is currently unavailable", err)
+	}
+	identity, loginExtras, err := p.FederationDomainResolvedIdentityProvider.LoginFromCallback(ctx, authCode, pkce, nonce, redirectURI)
+	p.tracker.RecordResult(p.uid(), err)
+	return identity, loginExtras, err
+}
+
+func (p *circuitBreakingIdentityProvider) Login(
+	ctx context.Context,
+	submittedUsername string,
+	submittedPassword string,
+) (*resolvedprovider.Identity, *resolvedprovider.IdentityLoginExtras, error) {
+	if err := p.tracker.Allow(p.uid()); err != nil {
+		return nil, nil, err
+	}
+	identity, loginExtras, err := p.FederationDomainResolvedIdentityProvider.Login(ctx, submittedUsername, submittedPassword)
+	p.tracker.RecordResult(p.uid(), err)
+	return identity, loginExtras, err
+}
+
+func (p *circuitBreakingIdentityProvider) UpstreamRefresh(
+	ctx context.Context,
+	identity *resolvedprovider.Identity,
+) (*resolvedprovider.RefreshedIdentity, error) {
+	if err := p.tracker.Allow(p.uid()); err != nil {
+		return nil, err
+	}
+	refreshedIdentity, err := p.FederationDomainResolvedIdentityProvider.UpstreamRefresh(ctx, identity)
+	p.tracker.RecordResult(p.uid(), err)
+	return refreshedIdentity, err
+}
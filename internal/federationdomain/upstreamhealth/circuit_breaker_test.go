@@ -0,0 +1,109 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamhealth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/authenticators"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedldap"
+	"go.pinniped.dev/internal/httputil/httperr"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil/oidctestutil"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	const upstreamUID = "some-ldap-uid"
+
+	setup := func(t *testing.T, authenticateErr error) (resolvedprovider.FederationDomainResolvedIdentityProvider, *Tracker, *int) {
+		t.Helper()
+
+		authenticateCalls := 0
+		upstream := oidctestutil.NewTestUpstreamLDAPIdentityProviderBuilder().
+			WithName("some-ldap-idp").
+			WithResourceUID(upstreamUID).
+			WithAuthenticateFunc(func(_ context.Context, _, _ string) (*authenticators.Response, bool, error) {
+				authenticateCalls++
+				return nil, false, authenticateErr
+			}).
+			Build()
+		tracker := NewTracker(clocktesting.NewFakeClock(time.Now()))
+		subject := WithCircuitBreaker(&resolvedldap.FederationDomainResolvedLDAPIdentityProvider{
+			DisplayName:         "some-ldap-idp",
+			Provider:            upstream,
+			SessionProviderType: psession.ProviderTypeLDAP,
+		}, tracker)
+		return subject, tracker, &authenticateCalls
+	}
+
+	t.Run("logins are passed through while the upstream is available, and unreachable errors open the circuit", func(t *testing.T) {
+		t.Parallel()
+
+		subject, tracker, authenticateCalls := setup(t,
+			&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+
+		for i := range DefaultFailureThreshold {
+			_, _, err := subject.Login(context.Background(), "some-username", "some-password")
+			require.ErrorIs(t, err, resolvedldap.ErrUnexpectedUpstreamLDAPError)
+			require.Equal(t, i+1, *authenticateCalls)
+		}
+		require.False(t, tracker.IsAvailable(upstreamUID))
+
+		_, _, err := subject.Login(context.Background(), "some-username", "some-password")
+		require.ErrorIs(t, err, ErrUpstreamUnavailable)
+		require.Equal(t, DefaultFailureThreshold, *authenticateCalls, "should not have called the upstream")
+	})
+
+	t.Run("bad passwords do not open the circuit", func(t *testing.T) {
+		t.Parallel()
+
+		subject, tracker, authenticateCalls := setup(t, nil)
+
+		for range DefaultFailureThreshold + 1 {
+			_, _, err := subject.Login(context.Background(), "some-username", "wrong-password")
+			require.ErrorIs(t, err, resolvedldap.ErrAccessDeniedDueToUsernamePasswordNotAccepted)
+		}
+		require.True(t, tracker.IsAvailable(upstreamUID))
+		require.Equal(t, DefaultFailureThreshold+1, *authenticateCalls)
+	})
+
+	t.Run("everything fails fast while the upstream is unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		subject, tracker, authenticateCalls := setup(t, nil)
+		for range DefaultFailureThreshold {
+			tracker.RecordProbe(upstreamUID, errors.New("some probe error"))
+		}
+
+		_, _, err := subject.Login(context.Background(), "some-username", "some-password")
+		require.ErrorIs(t, err, ErrUpstreamUnavailable)
+
+		_, err = subject.UpstreamRefresh(context.Background(), &resolvedprovider.Identity{})
+		require.ErrorIs(t, err, ErrUpstreamUnavailable)
+
+		_, err = subject.UpstreamAuthorizeRedirectURL(context.Background(), &resolvedprovider.UpstreamAuthorizeRequestState{}, "https://issuer.example.com")
+		require.ErrorIs(t, err, ErrUpstreamUnavailable)
+
+		_, _, err = subject.LoginFromCallback(context.Background(), "some-code", "", "", "https://issuer.example.com/callback")
+		require.ErrorIs(t, err, ErrUpstreamUnavailable)
+		var responder httperr.Responder
+		require.ErrorAs(t, err, &responder)
+		rec := httptest.NewRecorder()
+		responder.Respond(rec)
+		require.Equal(t, 503, rec.Code)
+
+		require.Zero(t, *authenticateCalls)
+	})
+}
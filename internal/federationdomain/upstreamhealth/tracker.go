@@ -0,0 +1,154 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package upstreamhealth tracks the reachability of upstream identity providers, and provides a circuit breaker
+// which makes logins and refreshes fail fast while an upstream identity provider is unreachable.
+package upstreamhealth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failures after which an upstream is considered unavailable.
+	DefaultFailureThreshold = 3
+
+	// DefaultOpenDuration is how long requests to an unavailable upstream will fail fast before a single request
+	// is allowed to try the upstream again.
+	DefaultOpenDuration = 30 * time.Second
+)
+
+// Tracker remembers the recent health of each upstream identity provider, keyed by the UID of its custom resource.
+// Both the results of background probes and the results of real logins and refreshes are recorded.
+// After FailureThreshold consecutive failures, the circuit for that upstream opens. While it is open,
+// Allow returns an error for OpenDuration, after which it allows one request through to try the upstream
+// again. Any success closes the circuit.
+//
+// It is thread-safe.
+type Tracker struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+
+	clock     clock.PassiveClock
+	mu        sync.Mutex
+	upstreams map[types.UID]*upstreamState
+}
+
+type upstreamState struct {
+	consecutiveFailures int
+	openedAt            time.Time
+}
+
+// NewTracker returns a Tracker using the default failure threshold and open duration.
+func NewTracker(clock clock.PassiveClock) *Tracker {
+	return &Tracker{
+		FailureThreshold: DefaultFailureThreshold,
+		OpenDuration:     DefaultOpenDuration,
+		clock:            clock,
+		upstreams:        map[types.UID]*upstreamState{},
+	}
+}
+
+// RecordProbe records the result of probing the reachability of an upstream. Every failed probe counts as a failure.
+func (t *Tracker) RecordProbe(uid types.UID, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.record(t.getOrCreate(uid), err == nil)
+}
+
+// RecordResult records the result of a real request to an upstream, e.g. a login or a refresh. Only errors
+// which indicate that the upstream could not be reached count as failures, so that users who type the wrong
+// password cannot cause the upstream to be considered unavailable for everyone else.
+func (t *Tracker) RecordResult(uid types.UID, err error) {
+	if err != nil && !IsUnreachableError(err) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.record(t.getOrCreate(uid), err == nil)
+}
+
+// Allow returns ErrUpstreamUnavailable when requests to the upstream should fail fast because its circuit is open.
+func (t *Tracker) Allow(uid types.UID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.upstreams[uid]
+	if !ok || !t.isOpen(state) {
+		return nil
+	}
+
+	now := t.clock.Now()
+	if now.Sub(state.openedAt) < t.OpenDuration {
+		return ErrUpstreamUnavailable
+	}
+
+	// Let this one request through to try the upstream again. Restart the timer so that
+	// other requests keep failing fast until the outcome of this one is known.
+	state.openedAt = now
+	return nil
+}
+
+// IsAvailable returns false when the upstream has recently failed too many times in a row.
+// Upstreams which have never been probed or used are assumed to be available, and a nil Tracker
+// considers every upstream to be available.
+func (t *Tracker) IsAvailable(uid types.UID) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.upstreams[uid]
+	return !ok || !t.isOpen(state)
+}
+
+func (t *Tracker) getOrCreate(uid types.UID) *upstreamState {
+	state, ok := t.upstreams[uid]
+	if !ok {
+		state = &upstreamState{}
+		t.upstreams[uid] = state
+	}
+	return state
+}
+
+func (t *Tracker) record(state *upstreamState, success bool) {
+	if success {
+		state.consecutiveFailures = 0
+		state.openedAt = time.Time{}
+		return
+	}
+
+	state.consecutiveFailures++
+	if state.consecutiveFailures == t.FailureThreshold {
+		state.openedAt = t.clock.Now()
+	}
+}
+
+func (t *Tracker) isOpen(state *upstreamState) bool {
+	return state.consecutiveFailures >= t.FailureThreshold
+}
+
+// IsUnreachableError returns true when the error was caused by a failure to reach a server over the network,
+// including timeouts. It returns false when the request was cancelled by the caller.
+func IsUnreachableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,173 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamhealth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	unreachableErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	t.Run("unknown upstreams are available", func(t *testing.T) {
+		t.Parallel()
+
+		tracker := NewTracker(clocktesting.NewFakeClock(time.Now()))
+
+		require.True(t, tracker.IsAvailable("some-uid"))
+		require.NoError(t, tracker.Allow("some-uid"))
+	})
+
+	t.Run("a nil tracker considers every upstream to be available", func(t *testing.T) {
+		t.Parallel()
+
+		var tracker *Tracker
+
+		require.True(t, tracker.IsAvailable("some-uid"))
+	})
+
+	t.Run("the circuit opens after the threshold of consecutive failed probes, and only for that upstream", func(t *testing.T) {
+		t.Parallel()
+
+		tracker := NewTracker(clocktesting.NewFakeClock(time.Now()))
+
+		for range DefaultFailureThreshold - 1 {
+			tracker.RecordProbe("some-uid", errors.New("some probe error"))
+		}
+		require.True(t, tracker.IsAvailable("some-uid"))
+		require.NoError(t, tracker.Allow("some-uid"))
+
+		tracker.RecordProbe("some-uid", errors.New("some probe error"))
+		require.False(t, tracker.IsAvailable("some-uid"))
+		require.ErrorIs(t, tracker.Allow("some-uid"), ErrUpstreamUnavailable)
+
+		require.True(t, tracker.IsAvailable("some-other-uid"))
+		require.NoError(t, tracker.Allow("some-other-uid"))
+	})
+
+	t.Run("a success resets the count of consecutive failures and closes the circuit", func(t *testing.T) {
+		t.Parallel()
+
+		tracker := NewTracker(clocktesting.NewFakeClock(time.Now()))
+
+		for range DefaultFailureThreshold - 1 {
+			tracker.RecordProbe("some-uid", errors.New("some probe error"))
+		}
+		tracker.RecordProbe("some-uid", nil)
+		tracker.RecordProbe("some-uid", errors.New("some probe error"))
+		require.True(t, tracker.IsAvailable("some-uid"))
+
+		for range DefaultFailureThreshold {
+			tracker.RecordProbe("some-uid", errors.New("some probe error"))
+		}
+		require.False(t, tracker.IsAvailable("some-uid"))
+
+		tracker.RecordResult("some-uid", nil)
+		require.True(t, tracker.IsAvailable("some-uid"))
+		require.NoError(t, tracker.Allow("some-uid"))
+	})
+
+	t.Run("only unreachable errors from real requests count as failures", func(t *testing.T) {
+		t.Parallel()
+
+		tracker := NewTracker(clocktesting.NewFakeClock(time.Now()))
+
+		for range DefaultFailureThreshold {
+			tracker.RecordResult("some-uid", errors.New("wrong password"))
+		}
+		require.True(t, tracker.IsAvailable("some-uid"))
+
+		for range DefaultFailureThreshold {
+			tracker.RecordResult("some-uid", fmt.Errorf("error dialing host: %w", unreachableErr))
+		}
+		require.False(t, tracker.IsAvailable("some-uid"))
+	})
+
+	t.Run("after the open duration, one request is allowed through to try the upstream again", func(t *testing.T) {
+		t.Parallel()
+
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+		tracker := NewTracker(fakeClock)
+
+		for range DefaultFailureThreshold {
+			tracker.RecordProbe("some-uid", errors.New("some probe error"))
+		}
+		require.ErrorIs(t, tracker.Allow("some-uid"), ErrUpstreamUnavailable)
+
+		fakeClock.Step(DefaultOpenDuration - time.Second)
+		require.ErrorIs(t, tracker.Allow("some-uid"), ErrUpstreamUnavailable)
+
+		fakeClock.Step(time.Second)
+		require.NoError(t, tracker.Allow("some-uid"))
+		// Other requests keep failing fast while the trial request is in flight.
+		require.ErrorIs(t, tracker.Allow("some-uid"), ErrUpstreamUnavailable)
+		require.False(t, tracker.IsAvailable("some-uid"))
+
+		// The trial request failed, so wait again.
+		tracker.RecordResult("some-uid", unreachableErr)
+		require.ErrorIs(t, tracker.Allow("some-uid"), ErrUpstreamUnavailable)
+
+		fakeClock.Step(DefaultOpenDuration)
+		require.NoError(t, tracker.Allow("some-uid"))
+
+		// The trial request succeeded, so the circuit closes.
+		tracker.RecordResult("some-uid", nil)
+		require.True(t, tracker.IsAvailable("some-uid"))
+		require.NoError(t, tracker.Allow("some-uid"))
+		require.NoError(t, tracker.Allow("some-uid"))
+	})
+}
+
+func TestIsUnreachableError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "network error",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			want: true,
+		},
+		{
+			name: "wrapped network error",
+			err:  fmt.Errorf("some context: %w", &net.DNSError{Err: "no such host", Name: "example.com"}),
+			want: true,
+		},
+		{
+			name: "deadline exceeded",
+			err:  fmt.Errorf("some context: %w", context.DeadlineExceeded),
+			want: true,
+		},
+		{
+			name: "cancelled by the caller",
+			err:  fmt.Errorf("some context: %w", context.Canceled),
+			want: false,
+		},
+		{
+			name: "any other error",
+			err:  errors.New("some error"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.want, IsUnreachableError(tt.err))
+		})
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package upstreamprovider
@@ -6,6 +6,7 @@ package upstreamprovider
 import (
 	"context"
 	"net/url"
+	"time"
 
 	"golang.org/x/oauth2"
 	"k8s.io/apimachinery/pkg/types"
@@ -49,6 +50,14 @@ type UpstreamIdentityProviderI interface {
 	GetResourceUID() types.UID
 }
 
+// UpstreamHealthProberI is optionally implemented by upstream identity providers which can check whether
+// their upstream server is currently reachable.
+type UpstreamHealthProberI interface {
+	// ProbeHealth checks that the upstream server is reachable, without authenticating any end user.
+	// It returns an error when the upstream server could not be reached or did not respond as expected.
+	ProbeHealth(ctx context.Context) error
+}
+
 type UpstreamOIDCIdentityProviderI interface {
 	UpstreamIdentityProviderI
 
@@ -82,6 +91,11 @@ type UpstreamOIDCIdentityProviderI interface {
 	// GetAdditionalClaimMappings returns additional claims to be mapped from the upstream ID token.
 	GetAdditionalClaimMappings() map[string]string
 
+	// PrepareAuthorizationRequest returns the query params of the authorization request to which the user's
+	// browser should be redirected, given the params of a plain authorization request. Depending on the
+	// configuration of the provider, the params may be pushed to the provider or sent as a signed request object.
+	PrepareAuthorizationRequest(ctx context.Context, params url.Values) (url.Values, error)
+
 	// PasswordCredentialsGrantAndValidateTokens performs upstream OIDC resource owner password credentials grant and
 	// token validation. Returns the validated raw tokens as well as the parsed claims of the ID token.
 	PasswordCredentialsGrantAndValidateTokens(ctx context.Context, username, password string) (*oidctypes.Token, error)
@@ -124,14 +138,17 @@ type UpstreamLDAPIdentityProviderI interface {
 	// UserAuthenticator adds an interface method for performing user authentication against the upstream LDAP provider.
 	authenticators.UserAuthenticator
 
-	// PerformRefresh performs a refresh against the upstream LDAP identity provider
-	PerformRefresh(ctx context.Context, storedRefreshAttributes LDAPRefreshAttributes, idpDisplayName string) (groups []string, err error)
+	// PerformRefresh performs a refresh against the upstream LDAP identity provider. It returns the refreshed groups,
+	// the refreshed values of the attributes which are mapped to additional downstream claims, and the raw identity
+	// data from the refreshed user entry, for identity transformations.
+	PerformRefresh(ctx context.Context, storedRefreshAttributes LDAPRefreshAttributes, idpDisplayName string) (groups []string, additionalClaims map[string][]string, upstreamData map[string]any, err error)
 }
 
 type GitHubUser struct {
-	Username          string   // could be login name, id, or login:id
-	Groups            []string // could be names or slugs
-	DownstreamSubject string   // the whole downstream subject URI
+	Username          string         // could be login name, id, or login:id
+	Groups            []string       // could be names or slugs
+	DownstreamSubject string         // the whole downstream subject URI
+	UpstreamData      map[string]any // the raw identity data from GitHub, for identity transformations
 }
 
 // GitHubLoginDeniedError can be returned by UpstreamGithubIdentityProviderI GetUser() when a policy
@@ -191,3 +208,103 @@ type UpstreamGithubIdentityProviderI interface {
 	// The IDP display name is passed to aid in building a suitable downstream subject string.
 	GetUser(ctx context.Context, accessToken string, idpDisplayName string) (*GitHubUser, error)
 }
+
+type SAMLUser struct {
+	Username            string
+	Groups              []string
+	DownstreamSubject   string         // the whole downstream subject URI
+	Issuer              string         // the identity provider's entity ID
+	NameID              string         // the NameID of the assertion's subject
+	SessionNotOnOrAfter time.Time      // the zero time when the assertion did not specify when the session ends
+	UpstreamData        map[string]any // the raw identity data from the assertion, for identity transformations
+}
+
+type UpstreamSAMLIdentityProviderI interface {
+	UpstreamIdentityProviderI
+
+	// GetServiceProviderEntityID returns the configured entity ID of the Supervisor as a service provider,
+	// or an empty string when the FederationDomain's issuer should be used as the entity ID.
+	GetServiceProviderEntityID() string
+
+	// GetUsernameAttribute returns the name of the SAML attribute to use for the downstream username,
+	// or an empty string when the NameID should be used as the username.
+	GetUsernameAttribute() string
+
+	// GetGroupsAttribute returns the name of the SAML attribute to use for the downstream group names,
+	// or an empty string when groups should not be used.
+	GetGroupsAttribute() string
+
+	// AuthnRequestURL returns the URL of the identity provider's single sign-on service, including an
+	// unsigned AuthnRequest and the RelayState encoded using the HTTP-Redirect binding.
+	AuthnRequestURL(requestID string, serviceProviderEntityID string, assertionConsumerServiceURL string, relayState string) (string, error)
+
+	// ValidateResponse validates a base64-encoded SAMLResponse which was sent using the HTTP-POST binding in
+	// response to the AuthnRequest with the given ID, and returns the user from its assertion.
+	// The IDP display name is passed to aid in building a suitable downstream subject string.
+	ValidateResponse(encodedResponse string, requestID string, serviceProviderEntityID string, assertionConsumerServiceURL string, idpDisplayName string) (*SAMLUser, error)
+}
+
+type OAuth2User struct {
+	Username          string
+	Groups            []string
+	Subject           string         // the result of the subject expression
+	DownstreamSubject string         // the whole downstream subject URI
+	UpstreamData      map[string]any // the raw identity data from the API responses, for identity transformations
+}
+
+type UpstreamOAuth2IdentityProviderI interface {
+	UpstreamIdentityProviderI
+
+	// GetClientID returns the OAuth client ID registered with the upstream provider to be used in the authorization code flow.
+	GetClientID() string
+
+	// GetScopes returns the scopes to request in the authorization code flow.
+	GetScopes() []string
+
+	// GetAuthorizationURL returns the authorization URL for the configured upstream provider.
+	GetAuthorizationURL() string
+
+	// GetAdditionalAuthcodeParams returns additional params to be sent on authcode requests.
+	GetAdditionalAuthcodeParams() map[string]string
+
+	// ExchangeAuthcode performs an upstream OAuth2 authorization code exchange.
+	// Returns the token, which always includes an access token and may include a refresh token.
+	ExchangeAuthcode(ctx context.Context, authcode string, pkceCodeVerifier pkce.Code, redirectURI string) (*oauth2.Token, error)
+
+	// PerformRefresh calls the upstream provider's token endpoint to perform a refresh grant.
+	// Returns the new token, which might not include a new refresh token.
+	PerformRefresh(ctx context.Context, refreshToken string) (*oauth2.Token, error)
+
+	// RevokeToken will attempt to revoke the given token, if the provider has a revocation URL.
+	// It may return an error wrapped by a RetryableRevocationError, which is an error indicating that it may
+	// be worth trying to revoke the same token again later. Any other error returned should be assumed to
+	// represent an error such that it is not worth retrying revocation later, even though revocation failed.
+	RevokeToken(ctx context.Context, token string, tokenType RevocableTokenType) error
+
+	// GetUser calls the userinfo API, and the groups API if one is configured, using the accessToken,
+	// and evaluates the configured expressions to determine the user's identity. It returns a User or an error.
+	// The IDP display name is passed to aid in building a suitable downstream subject string.
+	GetUser(ctx context.Context, accessToken string, idpDisplayName string) (*OAuth2User, error)
+}
+
+type LocalUser struct {
+	Username          string
+	Groups            []string
+	UserUID           string   // the UID of the Secret which defines the user
+	DownstreamSubject string   // the whole downstream subject URI
+	AMR               []string // the RFC 8176 authentication methods references which describe how the user authenticated
+}
+
+type UpstreamLocalUserIdentityProviderI interface {
+	UpstreamIdentityProviderI
+
+	// AuthenticateUser checks the user's password, and their TOTP code when they have one configured, which must be
+	// appended to the password. It returns the user and true when authentication succeeded. It returns nil and false
+	// without an error when the username or password was not accepted, including when the user is locked out.
+	// The IDP display name is passed to aid in building a suitable downstream subject string.
+	AuthenticateUser(ctx context.Context, username, password string, idpDisplayName string) (*LocalUser, bool, error)
+
+	// PerformRefresh looks up the user again, confirming that it is still the same user by comparing the UID of the
+	// Secret which defines it. It returns the user's current groups.
+	PerformRefresh(ctx context.Context, username string, userUID string) (groups []string, err error)
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package upstreamrevocation provides a helper for revoking the upstream OIDC and OAuth2 tokens which are held in
+// downstream session storage Secrets, for use whenever a downstream session is ending.
+package upstreamrevocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ory/fosite"
+	corev1 "k8s.io/api/core/v1"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/auditevent"
+	"go.pinniped.dev/internal/crud"
+	"go.pinniped.dev/internal/federationdomain/idplister"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/fositestorage/accesstoken"
+	"go.pinniped.dev/internal/fositestorage/authorizationcode"
+	"go.pinniped.dev/internal/fositestorage/clientassertion"
+	"go.pinniped.dev/internal/fositestorage/devicecode"
+	"go.pinniped.dev/internal/fositestorage/openidconnect"
+	"go.pinniped.dev/internal/fositestorage/pkce"
+	"go.pinniped.dev/internal/fositestorage/pushedauthorizerequest"
+	"go.pinniped.dev/internal/fositestorage/refreshtoken"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/internal/psession"
+)
+
+// UpstreamIdentityProvidersLister lists the types of upstream identity providers whose tokens can be revoked.
+type UpstreamIdentityProvidersLister interface {
+	idplister.UpstreamOIDCIdentityProvidersLister
+	idplister.UpstreamOAuth2IdentityProviderLister
+}
+
+// Revoker revokes upstream tokens using the currently configured upstream OIDC and OAuth2 identity providers.
+type Revoker struct {
+	idpCache    UpstreamIdentityProvidersLister
+	auditLogger plog.AuditLogger
+}
+
+// New returns a Revoker which will find upstream identity providers using the given idpCache.
+func New(idpCache UpstreamIdentityProvidersLister, auditLogger plog.AuditLogger) *Revoker {
+	return &Revoker{
+		idpCache:    idpCache,
+		auditLogger: auditLogger,
+	}
+}
+
+// MaybeRevokeUpstreamToken revokes the upstream OIDC or OAuth2 token held in the given downstream session storage Secret,
+// but only when that Secret holds the latest upstream token of its downstream session. storageType must be the
+// value of the Secret's crud.SecretLabelKey label. Errors returned by RevokeToken are returned without wrapping,
+// so callers may check if they are of type dynamicupstreamprovider.RetryableRevocationError.
+func (r *Revoker) MaybeRevokeUpstreamToken(ctx context.Context, storageType string, secret *corev1.Secret) error {
+	// All downstream session storage types hold upstream tokens when the upstream IDP is an OIDC or OAuth2 provider.
+	// However, some of them will be outdated because they are not updated by fosite after creation.
+	// Our goal below is to always revoke the latest upstream refresh token that we are holding for the
+	// session, and only the latest, or to revoke the original upstream access token. Note that we don't
+	// bother to store new upstream access tokens seen during upstream refresh because we only need to store
+	// the upstream access token when we intend to use it *instead* of an upstream refresh token.
+	// This implies that all the storage types will contain a copy of the original upstream access token,
+	// since it is never updated in the session. Thus, we can use the same logic to decide which upstream
+	// access token to revoke as we use for upstream refresh tokens, which allows us to avoid revoking an
+	// upstream access token more than once.
+	switch storageType {
+	case authorizationcode.TypeLabelValue:
+		authorizeCodeSession, err := authorizationcode.ReadFromSecret(secret)
+		if err != nil {
+			return err
+		}
+		// Check if this downstream authcode was already used. If it was already used (i.e. not active anymore),
+		// then the latest upstream token can be found in one of the other storage types handled below instead.
+		if !authorizeCodeSession.Active {
+			return nil
+		}
+		// When the downstream authcode was never used, then its storage must contain the latest upstream token.
+		return r.tryRevokeUpstreamToken(ctx,
+			authorizeCodeSession.Request.Session.(*psession.PinnipedSession).Custom,
+			authorizeCodeSession.Request,
+			secret)
+
+	case accesstoken.TypeLabelValue:
+		// For access token storage, check if the "offline_access" scope was granted on the downstream session.
+		// If it was granted, then the latest upstream token should be found in the refresh token storage instead.
+		// If it was not granted, then the user could not possibly have performed a downstream refresh, so the
+		// access token storage has the latest version of the upstream token.
+		accessTokenSession, err := accesstoken.ReadFromSecret(secret)
+		if err != nil {
+			return err
+		}
+		if accessTokenSession.Request.GetGrantedScopes().Has(oidcapi.ScopeOfflineAccess) {
+			return nil
+		}
+		return r.tryRevokeUpstreamToken(ctx,
+			accessTokenSession.Request.Session.(*psession.PinnipedSession).Custom,
+			accessTokenSession.Request,
+			secret)
+
+	case refreshtoken.TypeLabelValue:
+		// For refresh token storage, always revoke its upstream token. This refresh token storage could be
+		// the result of the initial downstream authcode exchange, or it could be the result of a downstream
+		// refresh. Either way, it always contains the latest upstream token when it exists.
+		refreshTokenSession, err := refreshtoken.ReadFromSecret(secret)
+		if err != nil {
+			return err
+		}
+		return r.tryRevokeUpstreamToken(ctx,
+			refreshTokenSession.Request.Session.(*psession.PinnipedSession).Custom,
+			refreshTokenSession.Request,
+			secret)
+
+	case pkce.TypeLabelValue:
+		// For PKCE storage, its very existence means that the downstream authcode was never exchanged, because
+		// these are deleted during downstream authcode exchange. No need to do anything, since the upstream
+		// token revocation is handled by authcode storage case above.
+		return nil
+
+	case openidconnect.TypeLabelValue:
+		// For OIDC storage, there is no need to do anything for reasons similar to the PKCE storage.
+		// These are deleted during downstream authcode exchange. The upstream token contained inside will
+		// be revoked by one of the other cases above.
+		return nil
+
+	case devicecode.TypeLabelValue:
+		deviceCodeSession, err := devicecode.ReadFromSecret(secret)
+		if err != nil {
+			return err
+		}
+		// Similar to authcodes, if the downstream device code was already redeemed (i.e. not active anymore),
+		// then the latest upstream token can be found in one of the other storage types handled above instead.
+		// When the end user never approved the device (i.e. the user code was never accepted), then the
+		// session is still empty, so there is no upstream token to revoke.
+		if !deviceCodeSession.Active || deviceCodeSession.Request.UserCodeState != fosite.UserCodeAccepted {
+			return nil
+		}
+		// When the downstream device code was approved but never redeemed, then its storage must contain
+		// the latest upstream token.
+		return r.tryRevokeUpstreamToken(ctx,
+			deviceCodeSession.Request.Session.(*psession.PinnipedSession).Custom,
+			&deviceCodeSession.Request.Request,
+			secret)
+
+	case devicecode.UserCodeTypeLabelValue:
+		// For user code storage, there is no need to do anything, since it only holds the signature of its
+		// device code. The device code storage case above handles any upstream token revocation.
+		return nil
+
+	case clientassertion.TypeLabelValue:
+		// For client assertion storage, there is no need to do anything, since it only holds the JTI of a
+		// client's JWT, which is not related to any upstream token.
+		return nil
+
+	case pushedauthorizerequest.TypeLabelValue:
+		// For pushed authorization request storage, there is no need to do anything, since the end user has not
+		// logged in to any upstream identity provider yet.
+		return nil
+
+	default:
+		// There are no other storage types, so this should never happen in practice.
+		return errors.New("saw invalid label on Secret when trying to determine if upstream revocation was needed")
+	}
+}
+
+// tokenRevoker is the part of the upstream OIDC and OAuth2 identity provider interfaces which revokes tokens.
+type tokenRevoker interface {
+	RevokeToken(ctx context.Context, token string, tokenType upstreamprovider.RevocableTokenType) error
+}
+
+func (r *Revoker) tryRevokeUpstreamToken(
+	ctx context.Context,
+	customSessionData *psession.CustomSessionData,
+	request *fosite.Request,
+	secret *corev1.Secret,
+) error {
+	switch customSessionData.ProviderType {
+	case psession.ProviderTypeOIDC:
+		// Try to find the provider that was originally used to create the stored session.
+		for _, p := range r.idpCache.GetOIDCIdentityProviders() {
+			if p.GetResourceName() == customSessionData.ProviderName && p.GetResourceUID() == customSessionData.ProviderUID {
+				// In practice, there should only be one of these tokens saved in the session.
+				return r.revokeTokens(ctx, p, auditevent.UpstreamOIDCTokenRevoked,
+					customSessionData.OIDC.UpstreamRefreshToken, customSessionData.OIDC.UpstreamAccessToken, request, secret)
+			}
+		}
+		return fmt.Errorf("could not find upstream OIDC provider named %q with resource UID %q", customSessionData.ProviderName, customSessionData.ProviderUID)
+
+	case psession.ProviderTypeOAuth2:
+		for _, p := range r.idpCache.GetOAuth2IdentityProviders() {
+			if p.GetResourceName() == customSessionData.ProviderName && p.GetResourceUID() == customSessionData.ProviderUID {
+				// Like for OIDC, the access token is only saved when the provider did not return a refresh token.
+				return r.revokeTokens(ctx, p, auditevent.UpstreamOAuth2TokenRevoked,
+					customSessionData.OAuth2.UpstreamRefreshToken, customSessionData.OAuth2.UpstreamAccessToken, request, secret)
+			}
+		}
+		return fmt.Errorf("could not find upstream OAuth2 provider named %q with resource UID %q", customSessionData.ProviderName, customSessionData.ProviderUID)
+
+	default:
+		// When session was for another upstream IDP type, e.g. LDAP, there is no upstream token involved.
+		return nil
+	}
+}
+
+func (r *Revoker) revokeTokens(
+	ctx context.Context,
+	provider tokenRevoker,
+	auditEvent auditevent.Message,
+	upstreamRefreshToken string,
+	upstreamAccessToken string,
+	request *fosite.Request,
+	secret *corev1.Secret,
+) error {
+	if upstreamRefreshToken != "" {
+		err := provider.RevokeToken(ctx, upstreamRefreshToken, upstreamprovider.RefreshTokenType)
+		if err != nil {
+			return err
+		}
+		r.auditLogger.Audit(auditEvent, &plog.AuditParams{
+			ReqCtx:        ctx,
+			Session:       request,
+			KeysAndValues: []any{"type", upstreamprovider.RefreshTokenType},
+		})
+		plog.Trace("successfully revoked upstream refresh token (or provider has no revocation endpoint)", logKV(secret)...)
+	}
+
+	if upstreamAccessToken != "" {
+		err := provider.RevokeToken(ctx, upstreamAccessToken, upstreamprovider.AccessTokenType)
+		if err != nil {
+			return err
+		}
+		r.auditLogger.Audit(auditEvent, &plog.AuditParams{
+			ReqCtx:        ctx,
+			Session:       request,
+			KeysAndValues: []any{"type", upstreamprovider.AccessTokenType},
+		})
+		plog.Trace("successfully revoked upstream access token (or provider has no revocation endpoint)", logKV(secret)...)
+	}
+
+	return nil
+}
+
+func logKV(secret *corev1.Secret) []any {
+	return []any{
+		"secretName", secret.Name,
+		"secretNamespace", secret.Namespace,
+		"storageTypeLabelValue", secret.Labels[crud.SecretLabelKey],
+	}
+}
@@ -36,7 +36,13 @@ const (
 	// Version 6 is when we upgraded fosite in Dec 2023.
 	// Version 7 is when OIDCClients were given configurable ID token lifetimes.
 	// Version 8 is when GitHubIdentityProvider was added.
-	accessTokenStorageVersion = "8"
+	// Version 9 is when SAMLIdentityProvider was added.
+	// Version 10 is when OAuth2IdentityProvider was added.
+	// Version 11 is when LocalUserIdentityProvider was added.
+	// Version 12 is when the raw upstream identity data was added to the session for identity transformations.
+	// Version 13 is when the names of the downstream additional claims returned by identity transformations were added to the session.
+	// Version 14 is when LDAP/AD additional claims were added.
+	accessTokenStorageVersion = "14"
 )
 
 type RevocationStorage interface {
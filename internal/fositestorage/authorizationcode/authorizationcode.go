@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package authorizationcode
@@ -37,7 +37,13 @@ const (
 	// Version 6 is when we upgraded fosite in Dec 2023.
 	// Version 7 is when OIDCClients were given configurable ID token lifetimes.
 	// Version 8 is when GitHubIdentityProvider was added.
-	authorizeCodeStorageVersion = "8"
+	// Version 9 is when SAMLIdentityProvider was added.
+	// Version 10 is when OAuth2IdentityProvider was added.
+	// Version 11 is when LocalUserIdentityProvider was added.
+	// Version 12 is when the raw upstream identity data was added to the session for identity transformations.
+	// Version 13 is when the names of the downstream additional claims returned by identity transformations were added to the session.
+	// Version 14 is when LDAP/AD additional claims were added.
+	authorizeCodeStorageVersion = "14"
 )
 
 var _ fositeoauth2.AuthorizeCodeStorage = &authorizeCodeStorage{}
@@ -99,7 +105,7 @@ func (a *authorizeCodeStorage) CreateAuthorizeCodeSession(ctx context.Context, s
 	_, err = a.storage.Create(ctx,
 		signature,
 		&Session{Active: true, Request: request, Version: authorizeCodeStorageVersion},
-		nil,
+		map[string]string{fositestorage.StorageRequestIDLabelName: requester.GetID()},
 		nil,
 		a.lifetime(requester),
 	)
@@ -357,46 +363,91 @@ const ExpectedAuthorizeCodeSessionJSONFromFuzzing = `{
 					"Ȇ",
 					"ǞʜƢú4¶鎰"
 				],
-				"providerUID": "韁臯氃妪婝rȤ\"h丬鎒ơ娻}ɼƟ",
-				"providerName": "闺髉龳ǽÙ龦O亾EW莛8嘶×",
-				"providerType": "戙鵮碡ʯiŬŽ非Ĝ眧Ĭ葜SŦ",
+				"upstreamData": {
+					"E9嫌ɶȤ\u0026¥潝邎Ȗ莅": {
+						"¬m": {
+							"2兌V囑]鵻\\.悃UƎ": {
+								"tC嵽痊w": false
+							},
+							"葜SŦ餧Ĭ倏4ĵ嶼仒篻ɥ闣ʬ": null
+						},
+						"嘶×姮c恭企Ź邖ɐ5": [
+							2113281374
+						]
+					},
+					"韁臯氃妪婝rȤ\"h丬鎒ơ娻}ɼƟ": 10038690
+				},
+				"transformedClaimNames": [
+					"榨Q|ôɵt毇",
+					"瓕巈"
+				],
+				"providerUID": "鉢緋uƴŤȱʀļÂ?",
+				"providerName": "27就伒犘c钡ɏȫ",
+				"providerType": "鬌",
 				"warnings": [
-					"觛ǂ焺nŐǛ3}Ü#",
-					"(ý綃ʃʚƟ覣k眐4ĈtC嵽痊w©"
+					"OpKȱ藚ɏ¬Ê蒭堜]ȗ韚ʫ繕ȫ碰+ʫ"
 				],
 				"oidc": {
-					"upstreamRefreshToken": "榨Q|ôɵt毇",
-					"upstreamAccessToken": "瓕巈",
-					"upstreamSubject": "鉢緋uƴŤȱʀļÂ?",
-					"upstreamIssuer": "27就伒犘c钡ɏȫ"
+					"upstreamRefreshToken": "曥Ċi磊ůď",
+					"upstreamAccessToken": "xȢ~1Įx",
+					"upstreamSubject": "邔\u0026Ű惫蜀Ģ¡圔鎥",
+					"upstreamIssuer": "×"
 				},
 				"ldap": {
-					"userDN": "š%OpKȱ藚ɏ¬Ê蒭堜",
+					"userDN": "飞O+î",
 					"extraRefreshAttributes": {
-						"1飞": "笿0D餹",
-						"誮rʨ鷞aŚB碠k9帴ʘ赱ŕ瑹xȢ~": ")藵睋邔\u0026Ű惫蜀Ģ¡圔鎥墀"
+						"%Ä摱ìÓȐĨf跞@)¿,ɭS隑i": "Ǘ艱iYn面@yȝƋ鬯犦獢9c5¤",
+						"O灞浛a齙\\蹼偦歛ơ 皦pSǬŝ": "ǅķ?吭匞饫Ƽĝ\"zvư",
+						"s": "OƉ"
+					},
+					"additionalClaims": {
+						"\u003cʘ筫MN\u0026錝D肁Ŷɽ蔒PR}Ų": [
+							"y_º$"
+						],
+						"轘屔挝ʌ鼂.诼消P姧": [
+							"_¸]fś酷ɂ/沴Ȃ僒鬎鉌X縆跣Šɞ",
+							"B鳛Nč乿"
+						]
 					}
 				},
 				"activedirectory": {
-					"userDN": "êĝ",
+					"userDN": "Ǵę鏶9ɣƜ/気ū齢q",
 					"extraRefreshAttributes": {
-						"IȽ齤士bEǎ": "跞@)¿,ɭS隑ip偶宾儮猷V麹",
-						"ȝƋ鬯犦獢9c5¤.岵": "浛a齙\\蹼偦歛"
+						"b璡Ȟ2\\袓,5JƊ津x": "C諡}-ňȝâ融貵捠ŉ0緃責cpbɋ"
+					},
+					"additionalClaims": {
+						"}ſ¯Ɣ 籌Tǘ乚Ȥ2Ķěå=瑅ƍ": [
+							"Ƹ眬筁ƆȴR苚栽",
+							"鲴ļt}% B駚ǛSĘ驧ml婆"
+						]
 					}
 				},
 				"github": {
-					"upstreamAccessToken": " 皦pSǬŝ社Vƅȭǝ*擦28ǅ"
+					"upstreamAccessToken": "鴾oŪWɊɒm者"
+				},
+				"saml": {
+					"upstreamIssuer": "ɗǋ憵芧Ǡt狥[N莈此ŵ",
+					"upstreamNameID": "vęř萊頪ȍ怌ħŧ實鶴",
+					"sessionNotOnOrAfter": "2012-07-05T16:35:57.65126594Z"
+				},
+				"oauth2": {
+					"upstreamRefreshToken": "+?浽Ȕ鑇Å睰ǎƳƺ",
+					"upstreamAccessToken": "匹ǂ熒Ƕ\u003e¨|Y弴hǇ",
+					"upstreamSubject": "趿Ȝa榏熷戒篓Ĳƺ燅ňƳÏg塡"
+				},
+				"localuser": {
+					"userUID": "Wɸǝ0ǏȀ5Ư"
 				}
 			}
 		},
 		"requestedAudience": [
-			"甍 ć\u003cʘ筫",
-			"蛖a³2ʫ承dʬ)ġ,TÀqy_"
+			"姘瞷",
+			"%WqCdēr"
 		],
 		"grantedAudience": [
-			"$+溪ŸȢŒų崓ļ憽",
-			"姧骦:駝重EȫʆɵʮGɃ"
+			"¼鶕f竍ʛle梦q环mN穴əz騹",
+			"ù鴫欥"
 		]
 	},
-	"version": "8"
+	"version": "14"
 }`
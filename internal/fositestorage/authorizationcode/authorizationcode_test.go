@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package authorizationcode
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net/url"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -34,6 +35,7 @@ import (
 	kubetesting "k8s.io/client-go/testing"
 	clocktesting "k8s.io/utils/clock/testing"
 
+	supervisorconfigv1alpha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	"go.pinniped.dev/internal/federationdomain/clientregistry"
 	"go.pinniped.dev/internal/federationdomain/timeouts"
 	"go.pinniped.dev/internal/fositestorage"
@@ -43,7 +45,7 @@ import (
 
 const (
 	namespace       = "test-ns"
-	expectedVersion = "8" // update this when you update the storage version in the production code
+	expectedVersion = "14" // update this when you update the storage version in the production code
 )
 
 var (
@@ -66,7 +68,8 @@ func TestAuthorizationCodeStorage(t *testing.T) {
 				Name:            "pinniped-storage-authcode-pwu5zs7lekbhnln2w4",
 				ResourceVersion: "",
 				Labels: map[string]string{
-					"storage.pinniped.dev/type": "authcode",
+					"storage.pinniped.dev/type":       "authcode",
+					"storage.pinniped.dev/request-id": "abcd-1",
 				},
 				Annotations: map[string]string{
 					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
@@ -86,7 +89,8 @@ func TestAuthorizationCodeStorage(t *testing.T) {
 				Name:            "pinniped-storage-authcode-pwu5zs7lekbhnln2w4",
 				ResourceVersion: "",
 				Labels: map[string]string{
-					"storage.pinniped.dev/type": "authcode",
+					"storage.pinniped.dev/type":       "authcode",
+					"storage.pinniped.dev/request-id": "abcd-1",
 				},
 				Annotations: map[string]string{
 					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
@@ -324,6 +328,14 @@ func TestFuzzAndJSONNewValidEmptyAuthorizeCodeSession(t *testing.T) {
 			*fs = pinnipedSession
 		},
 
+		// these fields of the client are intentionally not saved into session storage
+		func(s **supervisorconfigv1alpha1.OIDCClientServiceIdentity, c fuzz.Continue) {
+			*s = nil
+		},
+		func(s **clientregistry.TLSClientAuth, c fuzz.Continue) {
+			*s = nil
+		},
+
 		// these types contain an any that we need to handle
 		// this is safe because we explicitly provide the PinnipedSession concrete type
 		func(value *map[string]any, c fuzz.Continue) {
@@ -382,6 +394,8 @@ func TestFuzzAndJSONNewValidEmptyAuthorizeCodeSession(t *testing.T) {
 			*s = arguments
 		},
 	)
+	// this field of the client is intentionally not saved into session storage
+	f.SkipFieldsWithPattern(regexp.MustCompile(`^RequirePushedAuthorizationRequests$`))
 
 	f.Fuzz(validSession)
 
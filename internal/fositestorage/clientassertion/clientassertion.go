@@ -0,0 +1,99 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package clientassertion stores the JTIs of the client assertion JWTs which were used by clients
+// for the private_key_jwt client authentication method, so that those JWTs cannot be replayed.
+package clientassertion
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/ory/fosite"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/crud"
+)
+
+const (
+	TypeLabelValue = "client-assertion"
+
+	ErrInvalidClientAssertionVersion = constable.Error("client assertion data has wrong version")
+
+	// Version 1 was the initial release of storage.
+	clientAssertionStorageVersion = "1"
+
+	// Keep the record of each JTI for at least this long, even when the JWT claimed to expire sooner.
+	minimumStorageLifetime = time.Minute
+)
+
+// Storage remembers the JTIs of client assertion JWTs until those JWTs expire.
+type Storage interface {
+	// ClientAssertionJWTValid returns an error if the JTI is known or if the lookup failed,
+	// and returns nil if the JTI is not known.
+	ClientAssertionJWTValid(ctx context.Context, jti string) error
+
+	// SetClientAssertionJWT marks a JTI as known until the given expiry time.
+	SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error
+}
+
+type clientAssertionStorage struct {
+	storage crud.Storage
+	clock   func() time.Time
+}
+
+// Session is the stored record of a JTI. It is keyed by a hash of the JTI.
+type Session struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	Version   string    `json:"version"`
+}
+
+func New(secrets corev1client.SecretInterface, clock func() time.Time) Storage {
+	return &clientAssertionStorage{storage: crud.New(TypeLabelValue, secrets, clock), clock: clock}
+}
+
+func (s *clientAssertionStorage) ClientAssertionJWTValid(ctx context.Context, jti string) error {
+	session := &Session{}
+	_, err := s.storage.Get(ctx, signatureOfJTI(jti), session)
+
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get client assertion session: %w", err)
+	}
+
+	if version := session.Version; version != clientAssertionStorageVersion {
+		return fmt.Errorf("%w: client assertion session has version %s instead of %s",
+			ErrInvalidClientAssertionVersion, version, clientAssertionStorageVersion)
+	}
+
+	return fosite.ErrJTIKnown
+}
+
+func (s *clientAssertionStorage) SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error {
+	_, err := s.storage.Create(ctx,
+		signatureOfJTI(jti),
+		&Session{ExpiresAt: exp.UTC(), Version: clientAssertionStorageVersion},
+		nil,
+		nil,
+		max(exp.Sub(s.clock()), minimumStorageLifetime),
+	)
+	if apierrors.IsAlreadyExists(err) {
+		// Another request used the same JTI concurrently.
+		return fosite.ErrJTIKnown
+	}
+	return err
+}
+
+// signatureOfJTI hashes the JTI, because it is chosen by the client and may be too long to be used in a Secret name.
+func signatureOfJTI(jti string) string {
+	hash := sha256.Sum256([]byte(jti))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
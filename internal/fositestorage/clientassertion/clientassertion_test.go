@@ -0,0 +1,115 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package clientassertion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
+)
+
+const namespace = "test-ns"
+
+var fakeNow = time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestClientAssertionStorage(t *testing.T) {
+	secretsGVR := schema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "secrets",
+	}
+
+	// The name is derived from the SHA256 hash of the JTI.
+	const secretName = "pinniped-storage-client-assertion-lsunzjls3sazqgwsvfcf2ngbwgoo7j2us6x3svct3vpojc5mty7q"
+
+	wantCreatedSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secretName,
+			ResourceVersion: "",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "client-assertion",
+			},
+			Annotations: map[string]string{
+				"storage.pinniped.dev/garbage-collect-after": "2030-01-01T00:05:00Z",
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"expiresAt":"2030-01-01T00:05:00Z","version":"1"}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/client-assertion",
+	}
+
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	secrets := client.CoreV1().Secrets(namespace)
+	storage := New(secrets, func() time.Time { return fakeNow })
+
+	// An unknown JTI is valid.
+	require.NoError(t, storage.ClientAssertionJWTValid(ctx, "some-jti"))
+
+	require.NoError(t, storage.SetClientAssertionJWT(ctx, "some-jti", fakeNow.Add(5*time.Minute)))
+
+	// Now the JTI is known, so it cannot be used again.
+	require.ErrorIs(t, storage.ClientAssertionJWTValid(ctx, "some-jti"), fosite.ErrJTIKnown)
+	require.ErrorIs(t, storage.SetClientAssertionJWT(ctx, "some-jti", fakeNow.Add(5*time.Minute)), fosite.ErrJTIKnown)
+
+	// Other JTIs are not impacted.
+	require.NoError(t, storage.ClientAssertionJWTValid(ctx, "other-jti"))
+
+	require.Equal(t, []coretesting.Action{
+		coretesting.NewGetAction(secretsGVR, namespace, secretName),
+		coretesting.NewCreateAction(secretsGVR, namespace, wantCreatedSecret),
+		coretesting.NewGetAction(secretsGVR, namespace, secretName),
+		coretesting.NewCreateAction(secretsGVR, namespace, wantCreatedSecret),
+		coretesting.NewGetAction(secretsGVR, namespace, "pinniped-storage-client-assertion-hvqampxbvly5r4x4slrsgecx6w3v2zrgatm5csckowxdemulqeaa"),
+	}, client.Actions())
+}
+
+func TestShortLivedJWTsAreStoredForAMinimumLifetime(t *testing.T) {
+	ctx := context.Background()
+	secrets := fake.NewSimpleClientset().CoreV1().Secrets(namespace)
+	storage := New(secrets, func() time.Time { return fakeNow })
+
+	require.NoError(t, storage.SetClientAssertionJWT(ctx, "some-jti", fakeNow.Add(time.Second)))
+
+	secretList, err := secrets.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, secretList.Items, 1)
+	require.Equal(t, "2030-01-01T00:01:00Z", secretList.Items[0].Annotations["storage.pinniped.dev/garbage-collect-after"])
+	require.JSONEq(t, `{"expiresAt":"2030-01-01T00:00:01Z","version":"1"}`, string(secretList.Items[0].Data["pinniped-storage-data"]))
+}
+
+func TestWrongVersion(t *testing.T) {
+	ctx := context.Background()
+	secrets := fake.NewSimpleClientset().CoreV1().Secrets(namespace)
+	storage := New(secrets, func() time.Time { return fakeNow })
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pinniped-storage-client-assertion-lsunzjls3sazqgwsvfcf2ngbwgoo7j2us6x3svct3vpojc5mty7q",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "client-assertion",
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"expiresAt":"2030-01-01T00:05:00Z","version":"not-the-right-version"}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/client-assertion",
+	}
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	err = storage.ClientAssertionJWTValid(ctx, "some-jti")
+	require.EqualError(t, err, "client assertion data has wrong version: client assertion session has version not-the-right-version instead of 1")
+}
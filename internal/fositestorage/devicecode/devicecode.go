@@ -0,0 +1,281 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package devicecode
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/rfc8628"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/crud"
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/federationdomain/timeouts"
+	"go.pinniped.dev/internal/fositestorage"
+	"go.pinniped.dev/internal/psession"
+)
+
+const (
+	TypeLabelValue         = "device-code"
+	UserCodeTypeLabelValue = "user-code"
+
+	ErrInvalidDeviceCodeRequestData    = constable.Error("device code request data must be present")
+	ErrInvalidDeviceCodeRequestVersion = constable.Error("device code request data has wrong version")
+	ErrInvalidUserCodeData             = constable.Error("user code data must be present")
+	ErrInvalidUserCodeVersion          = constable.Error("user code data has wrong version")
+	ErrDeviceCodeSessionNotPending     = constable.Error("device code session is not waiting for user approval")
+
+	// Version 1 was the initial release of storage.
+	deviceCodeStorageVersion = "1"
+
+	// Version 1 was the initial release of storage.
+	userCodeStorageVersion = "1"
+)
+
+// DeviceCodeStorage is the storage needed by fosite's RFC8628 handlers, plus the additional methods needed
+// by the Supervisor's device verification flow to find and approve a pending device code session after the
+// end user has authenticated in their web browser.
+type DeviceCodeStorage interface {
+	rfc8628.DeviceAuthStorage
+
+	// GetDeviceCodeSignatureByUserCode returns the signature of the device code which was issued together
+	// with the user code of the given signature.
+	GetDeviceCodeSignatureByUserCode(ctx context.Context, userCodeSignature string) (string, error)
+
+	// AcceptDeviceCodeSession marks a pending device code session as approved by the end user, and replaces
+	// its stored request with the given request, which should hold the user's new downstream session.
+	// Once accepted, the device code can be redeemed at the token endpoint.
+	AcceptDeviceCodeSession(ctx context.Context, deviceCodeSignature string, requester fosite.DeviceRequester) error
+}
+
+var _ DeviceCodeStorage = &deviceCodeStorage{}
+
+type deviceCodeStorage struct {
+	storage          crud.Storage
+	userCodeStorage  crud.Storage
+	lifetime         timeouts.StorageLifetime
+	userCodeLifetime timeouts.StorageLifetime
+}
+
+// Session is the stored device code session. It is keyed by the signature of the device code.
+type Session struct {
+	Active  bool                  `json:"active"`
+	Request *fosite.DeviceRequest `json:"request"`
+	Version string                `json:"version"`
+}
+
+// UserCodeSession is the stored index from a user code to its device code. It is keyed by the signature of the
+// user code. Its existence also guarantees that the same user code will not be issued twice while it is stored.
+type UserCodeSession struct {
+	DeviceCodeSignature string `json:"deviceCodeSignature"`
+	Version             string `json:"version"`
+}
+
+func New(
+	secrets corev1client.SecretInterface,
+	clock func() time.Time,
+	sessionStorageLifetime timeouts.StorageLifetime,
+	userCodeStorageLifetime timeouts.StorageLifetime,
+) DeviceCodeStorage {
+	return &deviceCodeStorage{
+		storage:          crud.New(TypeLabelValue, secrets, clock),
+		userCodeStorage:  crud.New(UserCodeTypeLabelValue, secrets, clock),
+		lifetime:         sessionStorageLifetime,
+		userCodeLifetime: userCodeStorageLifetime,
+	}
+}
+
+// ReadFromSecret reads the contents of a Secret as a Session.
+func ReadFromSecret(secret *corev1.Secret) (*Session, error) {
+	session := NewValidEmptyDeviceCodeSession()
+	err := crud.FromSecret(TypeLabelValue, secret, session)
+	if err != nil {
+		return nil, err
+	}
+	if session.Version != deviceCodeStorageVersion {
+		return nil, fmt.Errorf("%w: device code session has version %s instead of %s",
+			ErrInvalidDeviceCodeRequestVersion, session.Version, deviceCodeStorageVersion)
+	}
+	if session.Request.ID == "" {
+		return nil, fmt.Errorf("malformed device code session: %w", ErrInvalidDeviceCodeRequestData)
+	}
+	return session, nil
+}
+
+func (d *deviceCodeStorage) CreateDeviceAuthSession(ctx context.Context, deviceCodeSignature, userCodeSignature string, requester fosite.DeviceRequester) error {
+	request, err := fositestorage.ValidateAndExtractDeviceRequest(requester)
+	if err != nil {
+		return err
+	}
+
+	// Store the user code first. If a user code with the same signature is still stored, then fosite
+	// will generate a new user code and try again when it sees this error.
+	_, err = d.userCodeStorage.Create(ctx,
+		userCodeSignature,
+		&UserCodeSession{DeviceCodeSignature: deviceCodeSignature, Version: userCodeStorageVersion},
+		map[string]string{fositestorage.StorageRequestIDLabelName: requester.GetID()},
+		nil,
+		d.userCodeLifetime(requester),
+	)
+	if apierrors.IsAlreadyExists(err) {
+		return fosite.ErrExistingUserCodeSignature
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = d.storage.Create(ctx,
+		deviceCodeSignature,
+		&Session{Active: true, Request: request, Version: deviceCodeStorageVersion},
+		map[string]string{fositestorage.StorageRequestIDLabelName: requester.GetID()},
+		nil,
+		d.lifetime(requester),
+	)
+	return err
+}
+
+func (d *deviceCodeStorage) GetDeviceCodeSession(ctx context.Context, signature string, _ fosite.Session) (fosite.DeviceRequester, error) {
+	session, _, err := d.getSession(ctx, signature)
+
+	// we need to always pass both the request and error back
+	if session == nil {
+		return nil, err
+	}
+
+	return session.Request, err
+}
+
+func (d *deviceCodeStorage) InvalidateDeviceCodeSession(ctx context.Context, signature string) error {
+	session, rv, err := d.getSession(ctx, signature)
+	if err != nil {
+		return err
+	}
+
+	session.Active = false
+	return d.update(ctx, signature, rv, session)
+}
+
+func (d *deviceCodeStorage) GetDeviceCodeSignatureByUserCode(ctx context.Context, userCodeSignature string) (string, error) {
+	userCodeSession := &UserCodeSession{}
+	_, err := d.userCodeStorage.Get(ctx, userCodeSignature, userCodeSession)
+
+	if apierrors.IsNotFound(err) {
+		return "", fosite.ErrNotFound.WithWrap(err).WithDebug(err.Error())
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get user code session for %s: %w", userCodeSignature, err)
+	}
+
+	if version := userCodeSession.Version; version != userCodeStorageVersion {
+		return "", fmt.Errorf("%w: user code session for %s has version %s instead of %s",
+			ErrInvalidUserCodeVersion, userCodeSignature, version, userCodeStorageVersion)
+	}
+
+	if userCodeSession.DeviceCodeSignature == "" {
+		return "", fmt.Errorf("malformed user code session for %s: %w", userCodeSignature, ErrInvalidUserCodeData)
+	}
+
+	return userCodeSession.DeviceCodeSignature, nil
+}
+
+func (d *deviceCodeStorage) AcceptDeviceCodeSession(ctx context.Context, deviceCodeSignature string, requester fosite.DeviceRequester) error {
+	request, err := fositestorage.ValidateAndExtractDeviceRequest(requester)
+	if err != nil {
+		return err
+	}
+
+	session, rv, err := d.getSession(ctx, deviceCodeSignature)
+	if err != nil {
+		return err
+	}
+
+	// A user code may only be used once.
+	if session.Request.GetUserCodeState() != fosite.UserCodeUnused {
+		return fmt.Errorf("device code session for %s: %w", deviceCodeSignature, ErrDeviceCodeSessionNotPending)
+	}
+
+	request.SetUserCodeState(fosite.UserCodeAccepted)
+	session.Request = request
+	return d.update(ctx, deviceCodeSignature, rv, session)
+}
+
+func (d *deviceCodeStorage) update(ctx context.Context, signature string, rv string, session *Session) error {
+	if _, err := d.storage.Update(ctx, signature, rv, session); err != nil {
+		if apierrors.IsConflict(err) {
+			return &errSerializationFailureWithCause{cause: err}
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *deviceCodeStorage) getSession(ctx context.Context, signature string) (*Session, string, error) {
+	session := NewValidEmptyDeviceCodeSession()
+	rv, err := d.storage.Get(ctx, signature, session)
+
+	if apierrors.IsNotFound(err) {
+		return nil, "", fosite.ErrNotFound.WithWrap(err).WithDebug(err.Error())
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get device code session for %s: %w", signature, err)
+	}
+
+	if version := session.Version; version != deviceCodeStorageVersion {
+		return nil, "", fmt.Errorf("%w: device code session for %s has version %s instead of %s",
+			ErrInvalidDeviceCodeRequestVersion, signature, version, deviceCodeStorageVersion)
+	}
+
+	if session.Request.ID == "" {
+		return nil, "", fmt.Errorf("malformed device code session for %s: %w", signature, ErrInvalidDeviceCodeRequestData)
+	}
+
+	// we must return the session in this case to allow fosite to revoke the associated tokens
+	if !session.Active {
+		return session, rv, fmt.Errorf("device code session for %s has already been used: %w", signature, fosite.ErrInvalidatedDeviceCode)
+	}
+
+	return session, rv, nil
+}
+
+func NewValidEmptyDeviceCodeSession() *Session {
+	return &Session{
+		Request: &fosite.DeviceRequest{
+			Request: fosite.Request{
+				Client:  &clientregistry.Client{},
+				Session: &psession.PinnipedSession{},
+			},
+		},
+	}
+}
+
+var _ interface {
+	Is(error) bool
+	Unwrap() error
+	error
+} = &errSerializationFailureWithCause{}
+
+type errSerializationFailureWithCause struct {
+	cause error
+}
+
+func (e *errSerializationFailureWithCause) Is(err error) bool {
+	return stderrors.Is(err, fosite.ErrSerializationFailure)
+}
+
+func (e *errSerializationFailureWithCause) Unwrap() error {
+	return e.cause
+}
+
+func (e *errSerializationFailureWithCause) Error() string {
+	return fmt.Sprintf("%s: %s", fosite.ErrSerializationFailure, e.cause)
+}
@@ -0,0 +1,551 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package devicecode
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	kubetesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil"
+)
+
+const (
+	namespace               = "test-ns"
+	expectedVersion         = "1" // update this when you update the device code storage version in the production code
+	expectedUserCodeVersion = "1" // update this when you update the user code storage version in the production code
+)
+
+var (
+	fakeNow                             = time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	lifetime                            = time.Minute * 30
+	userCodeLifetime                    = time.Minute * 15
+	fakeNowPlusLifetimeAsString         = metav1.Time{Time: fakeNow.Add(lifetime)}.Format(time.RFC3339)
+	fakeNowPlusUserCodeLifetimeAsString = metav1.Time{Time: fakeNow.Add(userCodeLifetime)}.Format(time.RFC3339)
+	lifetimeFunc                        = func(requester fosite.Requester) time.Duration { return lifetime }
+	userCodeLifetimeFunc                = func(requester fosite.Requester) time.Duration { return userCodeLifetime }
+)
+
+func TestDeviceCodeStorage(t *testing.T) {
+	secretsGVR := schema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "secrets",
+	}
+
+	const (
+		wantClientJSON  = `{"id":"pinny","redirect_uris":null,"grant_types":null,"response_types":null,"scopes":null,"audience":null,"public":true,"jwks_uri":"where","jwks":null,"token_endpoint_auth_method":"something","request_uris":null,"request_object_signing_alg":"","token_endpoint_auth_signing_alg":"","IDTokenLifetimeConfiguration":42000000000}`
+		wantSessionJSON = `{"fosite":{"id_token_claims":null,"headers":null,"expires_at":null,"username":"snorlax","subject":"panda"},"custom":{"username":"fake-username","upstreamUsername":"fake-upstream-username","upstreamGroups":["fake-upstream-group1","fake-upstream-group2"],"providerUID":"fake-provider-uid","providerName":"fake-provider-name","providerType":"fake-provider-type","warnings":null,"oidc":{"upstreamRefreshToken":"fake-upstream-refresh-token","upstreamAccessToken":"","upstreamSubject":"some-subject","upstreamIssuer":"some-issuer"}}}`
+	)
+
+	wantActions := []kubetesting.Action{
+		kubetesting.NewCreateAction(secretsGVR, namespace, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "pinniped-storage-user-code-mzqw4y3zfv2xgzlsfvrw6zdffvzwsz3omf2hk4tf",
+				ResourceVersion: "",
+				Labels: map[string]string{
+					"storage.pinniped.dev/type":       "user-code",
+					"storage.pinniped.dev/request-id": "abcd-1",
+				},
+				Annotations: map[string]string{
+					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusUserCodeLifetimeAsString,
+				},
+			},
+			Data: map[string][]byte{
+				"pinniped-storage-data":    []byte(`{"deviceCodeSignature":"fancy-signature","version":"` + expectedUserCodeVersion + `"}`),
+				"pinniped-storage-version": []byte("1"),
+			},
+			Type: "storage.pinniped.dev/user-code",
+		}),
+		kubetesting.NewCreateAction(secretsGVR, namespace, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+				ResourceVersion: "",
+				Labels: map[string]string{
+					"storage.pinniped.dev/type":       "device-code",
+					"storage.pinniped.dev/request-id": "abcd-1",
+				},
+				Annotations: map[string]string{
+					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
+				},
+			},
+			Data: map[string][]byte{
+				"pinniped-storage-data":    []byte(`{"active":true,"request":{"UserCodeState":0,"id":"abcd-1","requestedAt":"0001-01-01T00:00:00Z","client":` + wantClientJSON + `,"scopes":null,"grantedScopes":null,"form":{"key":["val"]},"session":` + wantSessionJSON + `,"requestedAudience":null,"grantedAudience":null},"version":"` + expectedVersion + `"}`),
+				"pinniped-storage-version": []byte("1"),
+			},
+			Type: "storage.pinniped.dev/device-code",
+		}),
+		kubetesting.NewGetAction(secretsGVR, namespace, "pinniped-storage-device-code-pwu5zs7lekbhnln2w4"),
+		kubetesting.NewGetAction(secretsGVR, namespace, "pinniped-storage-user-code-mzqw4y3zfv2xgzlsfvrw6zdffvzwsz3omf2hk4tf"),
+		kubetesting.NewGetAction(secretsGVR, namespace, "pinniped-storage-device-code-pwu5zs7lekbhnln2w4"),
+		kubetesting.NewGetAction(secretsGVR, namespace, "pinniped-storage-device-code-pwu5zs7lekbhnln2w4"),
+		kubetesting.NewUpdateAction(secretsGVR, namespace, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+				ResourceVersion: "",
+				Labels: map[string]string{
+					"storage.pinniped.dev/type":       "device-code",
+					"storage.pinniped.dev/request-id": "abcd-1",
+				},
+				Annotations: map[string]string{
+					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
+				},
+			},
+			Data: map[string][]byte{
+				"pinniped-storage-data":    []byte(`{"active":true,"request":{"UserCodeState":1,"id":"abcd-1","requestedAt":"0001-01-01T00:00:00Z","client":` + wantClientJSON + `,"scopes":null,"grantedScopes":["openid"],"form":{"key":["val"]},"session":` + wantSessionJSON + `,"requestedAudience":null,"grantedAudience":null},"version":"` + expectedVersion + `"}`),
+				"pinniped-storage-version": []byte("1"),
+			},
+			Type: "storage.pinniped.dev/device-code",
+		}),
+		kubetesting.NewGetAction(secretsGVR, namespace, "pinniped-storage-device-code-pwu5zs7lekbhnln2w4"),
+		kubetesting.NewGetAction(secretsGVR, namespace, "pinniped-storage-device-code-pwu5zs7lekbhnln2w4"),
+		kubetesting.NewGetAction(secretsGVR, namespace, "pinniped-storage-device-code-pwu5zs7lekbhnln2w4"),
+		kubetesting.NewUpdateAction(secretsGVR, namespace, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+				ResourceVersion: "",
+				Labels: map[string]string{
+					"storage.pinniped.dev/type":       "device-code",
+					"storage.pinniped.dev/request-id": "abcd-1",
+				},
+				Annotations: map[string]string{
+					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
+				},
+			},
+			Data: map[string][]byte{
+				"pinniped-storage-data":    []byte(`{"active":false,"request":{"UserCodeState":1,"id":"abcd-1","requestedAt":"0001-01-01T00:00:00Z","client":` + wantClientJSON + `,"scopes":null,"grantedScopes":["openid"],"form":{"key":["val"]},"session":` + wantSessionJSON + `,"requestedAudience":null,"grantedAudience":null},"version":"` + expectedVersion + `"}`),
+				"pinniped-storage-version": []byte("1"),
+			},
+			Type: "storage.pinniped.dev/device-code",
+		}),
+	}
+
+	storageLifetimeFuncCallCount := 0
+	var storageLifetimeFuncCallRequesterArg fosite.Requester
+	ctx, client, _, storage := makeTestSubject(func(requester fosite.Requester) time.Duration {
+		storageLifetimeFuncCallCount++
+		storageLifetimeFuncCallRequesterArg = requester
+		return lifetime
+	})
+
+	request := &fosite.DeviceRequest{
+		UserCodeState: fosite.UserCodeUnused,
+		Request: fosite.Request{
+			ID:          "abcd-1",
+			RequestedAt: time.Time{},
+			Client: &clientregistry.Client{
+				IDTokenLifetimeConfiguration: 42 * time.Second,
+				DefaultOpenIDConnectClient: fosite.DefaultOpenIDConnectClient{
+					DefaultClient: &fosite.DefaultClient{
+						ID:            "pinny",
+						Secret:        nil,
+						RedirectURIs:  nil,
+						GrantTypes:    nil,
+						ResponseTypes: nil,
+						Scopes:        nil,
+						Audience:      nil,
+						Public:        true,
+					},
+					JSONWebKeysURI:                    "where",
+					JSONWebKeys:                       nil,
+					TokenEndpointAuthMethod:           "something",
+					RequestURIs:                       nil,
+					RequestObjectSigningAlgorithm:     "",
+					TokenEndpointAuthSigningAlgorithm: "",
+				},
+			},
+			RequestedScope:    nil,
+			GrantedScope:      nil,
+			Form:              url.Values{"key": []string{"val"}},
+			Session:           testutil.NewFakePinnipedSession(),
+			RequestedAudience: nil,
+			GrantedAudience:   nil,
+		},
+	}
+	err := storage.CreateDeviceAuthSession(ctx, "fancy-signature", "fancy-user-code-signature", request)
+	require.NoError(t, err)
+	require.Equal(t, 1, storageLifetimeFuncCallCount)
+	require.Equal(t, request, storageLifetimeFuncCallRequesterArg)
+
+	newRequest, err := storage.GetDeviceCodeSession(ctx, "fancy-signature", nil)
+	require.NoError(t, err)
+	require.Equal(t, request, newRequest)
+
+	deviceCodeSignature, err := storage.GetDeviceCodeSignatureByUserCode(ctx, "fancy-user-code-signature")
+	require.NoError(t, err)
+	require.Equal(t, "fancy-signature", deviceCodeSignature)
+
+	request.GrantScope("openid")
+	err = storage.AcceptDeviceCodeSession(ctx, "fancy-signature", request)
+	require.NoError(t, err)
+
+	acceptedRequest, err := storage.GetDeviceCodeSession(ctx, "fancy-signature", nil)
+	require.NoError(t, err)
+	require.Equal(t, fosite.UserCodeAccepted, acceptedRequest.GetUserCodeState())
+	require.Equal(t, fosite.Arguments{"openid"}, acceptedRequest.GetGrantedScopes())
+
+	err = storage.InvalidateDeviceCodeSession(ctx, "fancy-signature")
+	require.NoError(t, err)
+
+	testutil.LogActualJSONFromCreateAction(t, client, 0)  // makes it easier to update expected values when needed
+	testutil.LogActualJSONFromCreateAction(t, client, 1)  // makes it easier to update expected values when needed
+	testutil.LogActualJSONFromUpdateAction(t, client, 6)  // makes it easier to update expected values when needed
+	testutil.LogActualJSONFromUpdateAction(t, client, 10) // makes it easier to update expected values when needed
+	require.Equal(t, wantActions, client.Actions())
+
+	// Doing a Get on an invalidated session should still return the session, but also return an error.
+	invalidatedRequest, err := storage.GetDeviceCodeSession(ctx, "fancy-signature", nil)
+	require.EqualError(t, err, "device code session for fancy-signature has already been used: Device code has been invalidated")
+	require.True(t, errors.Is(err, fosite.ErrInvalidatedDeviceCode))
+	require.Equal(t, "abcd-1", invalidatedRequest.GetID())
+
+	// Check that there were no more calls to the lifetime func since the original create.
+	require.Equal(t, 1, storageLifetimeFuncCallCount)
+}
+
+func TestCreateWhenUserCodeAlreadyExists(t *testing.T) {
+	ctx, client, _, storage := makeTestSubject(lifetimeFunc)
+
+	request := &fosite.DeviceRequest{
+		Request: fosite.Request{
+			ID:      "some-request-id",
+			Client:  &clientregistry.Client{},
+			Session: testutil.NewFakePinnipedSession(),
+		},
+	}
+	err := storage.CreateDeviceAuthSession(ctx, "fancy-signature", "fancy-user-code-signature", request)
+	require.NoError(t, err)
+	require.Len(t, client.Actions(), 2)
+
+	// Fosite will retry with a new user code when it sees this error.
+	err = storage.CreateDeviceAuthSession(ctx, "other-signature", "fancy-user-code-signature", request)
+	require.ErrorIs(t, err, fosite.ErrExistingUserCodeSignature)
+
+	// Should not have created the device code session when the user code was a duplicate.
+	require.Len(t, client.Actions(), 3)
+	_, err = storage.GetDeviceCodeSession(ctx, "other-signature", nil)
+	require.EqualError(t, err, "not_found")
+}
+
+func TestGetNotFound(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	_, notFoundErr := storage.GetDeviceCodeSession(ctx, "non-existent-signature", nil)
+	require.EqualError(t, notFoundErr, "not_found")
+	require.True(t, errors.Is(notFoundErr, fosite.ErrNotFound))
+
+	_, notFoundErr = storage.GetDeviceCodeSignatureByUserCode(ctx, "non-existent-signature")
+	require.EqualError(t, notFoundErr, "not_found")
+	require.True(t, errors.Is(notFoundErr, fosite.ErrNotFound))
+}
+
+func TestInvalidateWhenNotFound(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	notFoundErr := storage.InvalidateDeviceCodeSession(ctx, "non-existent-signature")
+	require.EqualError(t, notFoundErr, "not_found")
+	require.True(t, errors.Is(notFoundErr, fosite.ErrNotFound))
+}
+
+func TestInvalidateWhenConflictOnUpdateHappens(t *testing.T) {
+	ctx, client, _, storage := makeTestSubject(lifetimeFunc)
+
+	client.PrependReactor("update", "secrets", func(_ kubetesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(schema.GroupResource{
+			Group:    "",
+			Resource: "secrets",
+		}, "some-secret-name", fmt.Errorf("there was a conflict"))
+	})
+
+	request := &fosite.DeviceRequest{
+		Request: fosite.Request{
+			ID:      "some-request-id",
+			Client:  &clientregistry.Client{},
+			Session: testutil.NewFakePinnipedSession(),
+		},
+	}
+	err := storage.CreateDeviceAuthSession(ctx, "fancy-signature", "fancy-user-code-signature", request)
+	require.NoError(t, err)
+	err = storage.InvalidateDeviceCodeSession(ctx, "fancy-signature")
+	require.EqualError(t, err, `error: failed to update device-code for signature fancy-signature at resource version : Operation cannot be fulfilled on secrets "some-secret-name": there was a conflict`)
+	require.True(t, errors.Is(err, fosite.ErrSerializationFailure))
+}
+
+func TestAcceptWhenNotPending(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	request := &fosite.DeviceRequest{
+		Request: fosite.Request{
+			ID:      "some-request-id",
+			Client:  &clientregistry.Client{},
+			Session: testutil.NewFakePinnipedSession(),
+		},
+	}
+	err := storage.CreateDeviceAuthSession(ctx, "fancy-signature", "fancy-user-code-signature", request)
+	require.NoError(t, err)
+
+	err = storage.AcceptDeviceCodeSession(ctx, "fancy-signature", request)
+	require.NoError(t, err)
+
+	// A user code can only be used once.
+	err = storage.AcceptDeviceCodeSession(ctx, "fancy-signature", request)
+	require.EqualError(t, err, "device code session for fancy-signature: device code session is not waiting for user approval")
+
+	// An invalidated device code cannot be accepted.
+	err = storage.InvalidateDeviceCodeSession(ctx, "fancy-signature")
+	require.NoError(t, err)
+	err = storage.AcceptDeviceCodeSession(ctx, "fancy-signature", request)
+	require.EqualError(t, err, "device code session for fancy-signature has already been used: Device code has been invalidated")
+}
+
+func TestWrongVersion(t *testing.T) {
+	ctx, _, secrets, storage := makeTestSubject(lifetimeFunc)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+			ResourceVersion: "",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "device-code",
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"request":{"id":"abcd-1"},"version":"not-the-right-version","active": true}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/device-code",
+	}
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = storage.GetDeviceCodeSession(ctx, "fancy-signature", nil)
+
+	require.EqualError(t, err, "device code request data has wrong version: device code session for fancy-signature has version not-the-right-version instead of "+expectedVersion)
+}
+
+func TestUserCodeWrongVersion(t *testing.T) {
+	ctx, _, secrets, storage := makeTestSubject(lifetimeFunc)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pinniped-storage-user-code-mzqw4y3zfv2xgzlsfvrw6zdffvzwsz3omf2hk4tf",
+			ResourceVersion: "",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "user-code",
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"deviceCodeSignature":"fancy-signature","version":"not-the-right-version"}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/user-code",
+	}
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = storage.GetDeviceCodeSignatureByUserCode(ctx, "fancy-user-code-signature")
+
+	require.EqualError(t, err, "user code data has wrong version: user code session for fancy-user-code-signature has version not-the-right-version instead of "+expectedUserCodeVersion)
+}
+
+func TestNilSessionRequest(t *testing.T) {
+	ctx, _, secrets, storage := makeTestSubject(lifetimeFunc)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+			ResourceVersion: "",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "device-code",
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"nonsense-key": "nonsense-value", "version":"` + expectedVersion + `", "active": true}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/device-code",
+	}
+
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = storage.GetDeviceCodeSession(ctx, "fancy-signature", nil)
+	require.EqualError(t, err, "malformed device code session for fancy-signature: device code request data must be present")
+}
+
+func TestCreateWithNilRequester(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	err := storage.CreateDeviceAuthSession(ctx, "signature-doesnt-matter", "user-code-signature-doesnt-matter", nil)
+	require.EqualError(t, err, "requester must be of type fosite.DeviceRequest")
+}
+
+func TestCreateWithWrongRequesterDataTypes(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	request := &fosite.DeviceRequest{
+		Request: fosite.Request{
+			Session: nil,
+			Client:  &clientregistry.Client{},
+		},
+	}
+	err := storage.CreateDeviceAuthSession(ctx, "signature-doesnt-matter", "user-code-signature-doesnt-matter", request)
+	require.EqualError(t, err, "requester's session must be of type PinnipedSession")
+
+	request = &fosite.DeviceRequest{
+		Request: fosite.Request{
+			Session: &psession.PinnipedSession{},
+			Client:  nil,
+		},
+	}
+	err = storage.CreateDeviceAuthSession(ctx, "signature-doesnt-matter", "user-code-signature-doesnt-matter", request)
+	require.EqualError(t, err, "requester's client must be of type clientregistry.Client")
+}
+
+func makeTestSubject(lifetimeFunc func(requester fosite.Requester) time.Duration) (context.Context, *fake.Clientset, corev1client.SecretInterface, DeviceCodeStorage) {
+	client := fake.NewSimpleClientset()
+	secrets := client.CoreV1().Secrets(namespace)
+	return context.Background(),
+		client,
+		secrets,
+		New(secrets, clocktesting.NewFakeClock(fakeNow).Now, lifetimeFunc, userCodeLifetimeFunc)
+}
+
+func TestReadFromSecret(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      *corev1.Secret
+		wantSession *Session
+		wantErr     string
+	}{
+		{
+			name: "happy path",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+					ResourceVersion: "",
+					Labels: map[string]string{
+						"storage.pinniped.dev/type": "device-code",
+					},
+				},
+				Data: map[string][]byte{
+					"pinniped-storage-data":    []byte(`{"request":{"UserCodeState":1,"id":"abcd-1","session":{"fosite":{"username":"snorlax","subject":"panda"},"custom":{"username":"fake-username","providerUID":"fake-provider-uid","providerName":"fake-provider-name","providerType":"fake-provider-type","oidc":{"upstreamRefreshToken":"fake-upstream-refresh-token"}}}},"version":"` + expectedVersion + `","active": true}`),
+					"pinniped-storage-version": []byte("1"),
+				},
+				Type: "storage.pinniped.dev/device-code",
+			},
+			wantSession: &Session{
+				Version: expectedVersion,
+				Active:  true,
+				Request: &fosite.DeviceRequest{
+					UserCodeState: fosite.UserCodeAccepted,
+					Request: fosite.Request{
+						ID:     "abcd-1",
+						Client: &clientregistry.Client{},
+						Session: &psession.PinnipedSession{
+							Fosite: &openid.DefaultSession{
+								Username: "snorlax",
+								Subject:  "panda",
+							},
+							Custom: &psession.CustomSessionData{
+								Username:     "fake-username",
+								ProviderUID:  "fake-provider-uid",
+								ProviderName: "fake-provider-name",
+								ProviderType: "fake-provider-type",
+								OIDC: &psession.OIDCSessionData{
+									UpstreamRefreshToken: "fake-upstream-refresh-token",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "wrong secret type",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+					ResourceVersion: "",
+					Labels: map[string]string{
+						"storage.pinniped.dev/type": "device-code",
+					},
+				},
+				Data: map[string][]byte{
+					"pinniped-storage-data":    []byte(`{"request":{"id":"abcd-1"},"version":"` + expectedVersion + `","active": true}`),
+					"pinniped-storage-version": []byte("1"),
+				},
+				Type: "storage.pinniped.dev/not-device-code",
+			},
+			wantErr: "secret storage data has incorrect type: storage.pinniped.dev/not-device-code must equal storage.pinniped.dev/device-code",
+		},
+		{
+			name: "wrong session version",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+					ResourceVersion: "",
+					Labels: map[string]string{
+						"storage.pinniped.dev/type": "device-code",
+					},
+				},
+				Data: map[string][]byte{
+					"pinniped-storage-data":    []byte(`{"request":{"id":"abcd-1"},"version":"wrong-version-here","active": true}`),
+					"pinniped-storage-version": []byte("1"),
+				},
+				Type: "storage.pinniped.dev/device-code",
+			},
+			wantErr: "device code request data has wrong version: device code session has version wrong-version-here instead of " + expectedVersion,
+		},
+		{
+			name: "missing request",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "pinniped-storage-device-code-pwu5zs7lekbhnln2w4",
+					ResourceVersion: "",
+					Labels: map[string]string{
+						"storage.pinniped.dev/type": "device-code",
+					},
+				},
+				Data: map[string][]byte{
+					"pinniped-storage-data":    []byte(`{"version":"` + expectedVersion + `","active": true}`),
+					"pinniped-storage-version": []byte("1"),
+				},
+				Type: "storage.pinniped.dev/device-code",
+			},
+			wantErr: "malformed device code session: device code request data must be present",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			session, err := ReadFromSecret(tt.secret)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				require.Equal(t, tt.wantSession, session)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, session)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2023 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package fositestorage
@@ -12,10 +12,12 @@ import (
 )
 
 const (
-	ErrInvalidRequestType     = constable.Error("requester must be of type fosite.Request")
-	ErrInvalidClientType      = constable.Error("requester's client must be of type clientregistry.Client")
-	ErrInvalidSessionType     = constable.Error("requester's session must be of type PinnipedSession")
-	StorageRequestIDLabelName = "storage.pinniped.dev/request-id"
+	ErrInvalidRequestType          = constable.Error("requester must be of type fosite.Request")
+	ErrInvalidDeviceRequestType    = constable.Error("requester must be of type fosite.DeviceRequest")
+	ErrInvalidAuthorizeRequestType = constable.Error("requester must be of type fosite.AuthorizeRequest")
+	ErrInvalidClientType           = constable.Error("requester's client must be of type clientregistry.Client")
+	ErrInvalidSessionType          = constable.Error("requester's session must be of type PinnipedSession")
+	StorageRequestIDLabelName      = "storage.pinniped.dev/request-id"
 )
 
 func ValidateAndExtractAuthorizeRequest(requester fosite.Requester) (*fosite.Request, error) {
@@ -34,3 +36,37 @@ func ValidateAndExtractAuthorizeRequest(requester fosite.Requester) (*fosite.Req
 
 	return request, nil
 }
+
+func ValidateAndExtractDeviceRequest(requester fosite.DeviceRequester) (*fosite.DeviceRequest, error) {
+	request, ok1 := requester.(*fosite.DeviceRequest)
+	if !ok1 {
+		return nil, ErrInvalidDeviceRequestType
+	}
+	_, ok2 := request.Client.(*clientregistry.Client)
+	if !ok2 {
+		return nil, ErrInvalidClientType
+	}
+	_, ok3 := request.Session.(*psession.PinnipedSession)
+	if !ok3 {
+		return nil, ErrInvalidSessionType
+	}
+
+	return request, nil
+}
+
+func ValidateAndExtractPushedAuthorizeRequest(requester fosite.AuthorizeRequester) (*fosite.AuthorizeRequest, error) {
+	request, ok1 := requester.(*fosite.AuthorizeRequest)
+	if !ok1 {
+		return nil, ErrInvalidAuthorizeRequestType
+	}
+	_, ok2 := request.Client.(*clientregistry.Client)
+	if !ok2 {
+		return nil, ErrInvalidClientType
+	}
+	_, ok3 := request.Session.(*psession.PinnipedSession)
+	if !ok3 {
+		return nil, ErrInvalidSessionType
+	}
+
+	return request, nil
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package pkce
@@ -35,7 +35,13 @@ const (
 	// Version 6 is when we upgraded fosite in Dec 2023.
 	// Version 7 is when OIDCClients were given configurable ID token lifetimes.
 	// Version 8 is when GitHubIdentityProvider was added.
-	pkceStorageVersion = "8"
+	// Version 9 is when SAMLIdentityProvider was added.
+	// Version 10 is when OAuth2IdentityProvider was added.
+	// Version 11 is when LocalUserIdentityProvider was added.
+	// Version 12 is when the raw upstream identity data was added to the session for identity transformations.
+	// Version 13 is when the names of the downstream additional claims returned by identity transformations were added to the session.
+	// Version 14 is when LDAP/AD additional claims were added.
+	pkceStorageVersion = "14"
 )
 
 var _ pkce.PKCERequestStorage = &pkceStorage{}
@@ -63,7 +69,7 @@ func (a *pkceStorage) CreatePKCERequestSession(ctx context.Context, signature st
 	_, err = a.storage.Create(ctx,
 		signature,
 		&session{Request: request, Version: pkceStorageVersion},
-		nil,
+		map[string]string{fositestorage.StorageRequestIDLabelName: requester.GetID()},
 		nil,
 		a.lifetime(requester),
 	)
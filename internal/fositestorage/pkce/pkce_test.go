@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package pkce
@@ -29,7 +29,7 @@ import (
 
 const (
 	namespace       = "test-ns"
-	expectedVersion = "8" // update this when you update the storage version in the production code
+	expectedVersion = "14" // update this when you update the storage version in the production code
 )
 
 var (
@@ -52,7 +52,8 @@ func TestPKCEStorage(t *testing.T) {
 				Name:            "pinniped-storage-pkce-pwu5zs7lekbhnln2w4",
 				ResourceVersion: "",
 				Labels: map[string]string{
-					"storage.pinniped.dev/type": "pkce",
+					"storage.pinniped.dev/type":       "pkce",
+					"storage.pinniped.dev/request-id": "abcd-1",
 				},
 				Annotations: map[string]string{
 					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
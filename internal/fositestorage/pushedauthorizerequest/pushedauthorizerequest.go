@@ -0,0 +1,123 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package pushedauthorizerequest stores the authorization requests which were pushed by clients to the
+// pushed authorization request endpoint (RFC 9126), until they are used at the authorization endpoint.
+package pushedauthorizerequest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/ory/fosite"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"go.pinniped.dev/internal/constable"
+	"go.pinniped.dev/internal/crud"
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/federationdomain/timeouts"
+	"go.pinniped.dev/internal/fositestorage"
+	"go.pinniped.dev/internal/psession"
+)
+
+const (
+	TypeLabelValue = "pushed-auth-request"
+
+	ErrInvalidPushedAuthorizeRequestData    = constable.Error("pushed authorize request data must be present")
+	ErrInvalidPushedAuthorizeRequestVersion = constable.Error("pushed authorize request data has wrong version")
+
+	// Version 1 was the initial release of storage.
+	pushedAuthorizeRequestStorageVersion = "1"
+)
+
+var _ fosite.PARStorage = &pushedAuthorizeRequestStorage{}
+
+type pushedAuthorizeRequestStorage struct {
+	storage  crud.Storage
+	clock    func() time.Time
+	lifetime timeouts.StorageLifetime
+}
+
+// Session is the stored pushed authorization request. It is keyed by a hash of its request_uri.
+type Session struct {
+	Request *fosite.AuthorizeRequest `json:"request"`
+	Version string                   `json:"version"`
+}
+
+func New(secrets corev1client.SecretInterface, clock func() time.Time, sessionStorageLifetime timeouts.StorageLifetime) fosite.PARStorage {
+	return &pushedAuthorizeRequestStorage{
+		storage:  crud.New(TypeLabelValue, secrets, clock),
+		clock:    clock,
+		lifetime: sessionStorageLifetime,
+	}
+}
+
+func (p *pushedAuthorizeRequestStorage) CreatePARSession(ctx context.Context, requestURI string, requester fosite.AuthorizeRequester) error {
+	request, err := fositestorage.ValidateAndExtractPushedAuthorizeRequest(requester)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.storage.Create(ctx,
+		signatureOfRequestURI(requestURI),
+		&Session{Request: request, Version: pushedAuthorizeRequestStorageVersion},
+		map[string]string{fositestorage.StorageRequestIDLabelName: requester.GetID()},
+		nil,
+		p.lifetime(requester),
+	)
+	return err
+}
+
+func (p *pushedAuthorizeRequestStorage) GetPARSession(ctx context.Context, requestURI string) (fosite.AuthorizeRequester, error) {
+	session := NewValidEmptyPushedAuthorizeRequestSession()
+	_, err := p.storage.Get(ctx, signatureOfRequestURI(requestURI), session)
+
+	if apierrors.IsNotFound(err) {
+		return nil, fosite.ErrNotFound.WithWrap(err).WithDebug(err.Error())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pushed authorize request session: %w", err)
+	}
+
+	if version := session.Version; version != pushedAuthorizeRequestStorageVersion {
+		return nil, fmt.Errorf("%w: pushed authorize request session has version %s instead of %s",
+			ErrInvalidPushedAuthorizeRequestVersion, version, pushedAuthorizeRequestStorageVersion)
+	}
+
+	if session.Request.ID == "" {
+		return nil, fmt.Errorf("malformed pushed authorize request session: %w", ErrInvalidPushedAuthorizeRequestData)
+	}
+
+	// Unused pushed authorization requests are not deleted until they are garbage collected, so they may be expired.
+	if !session.Request.GetSession().GetExpiresAt(fosite.PushedAuthorizeRequestContext).After(p.clock()) {
+		return nil, fosite.ErrNotFound.WithDebug("pushed authorize request session has expired")
+	}
+
+	return session.Request, nil
+}
+
+func (p *pushedAuthorizeRequestStorage) DeletePARSession(ctx context.Context, requestURI string) error {
+	return p.storage.Delete(ctx, signatureOfRequestURI(requestURI))
+}
+
+func NewValidEmptyPushedAuthorizeRequestSession() *Session {
+	return &Session{
+		Request: &fosite.AuthorizeRequest{
+			Request: fosite.Request{
+				Client:  &clientregistry.Client{},
+				Session: psession.NewPinnipedSession(),
+			},
+		},
+	}
+}
+
+// signatureOfRequestURI hashes the request_uri, because it is too long to be used in a Secret name.
+func signatureOfRequestURI(requestURI string) string {
+	hash := sha256.Sum256([]byte(requestURI))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
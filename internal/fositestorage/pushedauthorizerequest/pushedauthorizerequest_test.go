@@ -0,0 +1,249 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package pushedauthorizerequest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ory/fosite"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	kubetesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/federationdomain/clientregistry"
+	"go.pinniped.dev/internal/psession"
+	"go.pinniped.dev/internal/testutil"
+)
+
+const (
+	namespace       = "test-ns"
+	expectedVersion = "1" // update this when you update the storage version in the production code
+
+	requestURI = "urn:ietf:params:oauth:request_uri:fancy-request-uri"
+	secretName = "pinniped-storage-pushed-auth-request-qgl23yzmqrrbyi7gtuktcms4qtpf6oqrdzco4iedlkrj7tvdc4uq"
+)
+
+var (
+	fakeNow                     = time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	lifetime                    = time.Minute * 6
+	fakeNowPlusLifetimeAsString = metav1.Time{Time: fakeNow.Add(lifetime)}.Format(time.RFC3339)
+	lifetimeFunc                = func(requester fosite.Requester) time.Duration { return lifetime }
+)
+
+func TestPushedAuthorizeRequestStorage(t *testing.T) {
+	secretsGVR := schema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "secrets",
+	}
+
+	const (
+		wantClientJSON  = `{"id":"pinny","redirect_uris":null,"grant_types":null,"response_types":null,"scopes":null,"audience":null,"public":true,"jwks_uri":"where","jwks":null,"token_endpoint_auth_method":"something","request_uris":null,"request_object_signing_alg":"","token_endpoint_auth_signing_alg":"","IDTokenLifetimeConfiguration":42000000000}`
+		wantSessionJSON = `{"fosite":{"id_token_claims":null,"headers":null,"expires_at":{"par_context":"2030-01-01T00:05:00Z"},"username":"snorlax","subject":"panda"},"custom":{"username":"fake-username","upstreamUsername":"fake-upstream-username","upstreamGroups":["fake-upstream-group1","fake-upstream-group2"],"providerUID":"fake-provider-uid","providerName":"fake-provider-name","providerType":"fake-provider-type","warnings":null,"oidc":{"upstreamRefreshToken":"fake-upstream-refresh-token","upstreamAccessToken":"","upstreamSubject":"some-subject","upstreamIssuer":"some-issuer"}}}`
+	)
+
+	wantActions := []kubetesting.Action{
+		kubetesting.NewCreateAction(secretsGVR, namespace, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            secretName,
+				ResourceVersion: "",
+				Labels: map[string]string{
+					"storage.pinniped.dev/type":       "pushed-auth-request",
+					"storage.pinniped.dev/request-id": "abcd-1",
+				},
+				Annotations: map[string]string{
+					"storage.pinniped.dev/garbage-collect-after": fakeNowPlusLifetimeAsString,
+				},
+			},
+			Data: map[string][]byte{
+				"pinniped-storage-data":    []byte(`{"request":{"responseTypes":["code"],"redirectUri":{"Scheme":"http","Opaque":"","User":null,"Host":"127.0.0.1:1234","Path":"/callback","Fragment":"","RawQuery":"","RawPath":"","RawFragment":"","ForceQuery":false,"OmitHost":false},"state":"some-state","handledResponseTypes":null,"ResponseModes":"query","DefaultResponseMode":"query","id":"abcd-1","requestedAt":"0001-01-01T00:00:00Z","client":` + wantClientJSON + `,"scopes":null,"grantedScopes":null,"form":{"key":["val"]},"session":` + wantSessionJSON + `,"requestedAudience":null,"grantedAudience":null},"version":"` + expectedVersion + `"}`),
+				"pinniped-storage-version": []byte("1"),
+			},
+			Type: "storage.pinniped.dev/pushed-auth-request",
+		}),
+		kubetesting.NewGetAction(secretsGVR, namespace, secretName),
+		kubetesting.NewDeleteAction(secretsGVR, namespace, secretName),
+	}
+
+	storageLifetimeFuncCallCount := 0
+	var storageLifetimeFuncCallRequesterArg fosite.Requester
+	ctx, client, _, storage := makeTestSubject(func(requester fosite.Requester) time.Duration {
+		storageLifetimeFuncCallCount++
+		storageLifetimeFuncCallRequesterArg = requester
+		return lifetime
+	})
+
+	session := testutil.NewFakePinnipedSession()
+	session.SetExpiresAt(fosite.PushedAuthorizeRequestContext, fakeNow.Add(5*time.Minute))
+
+	request := &fosite.AuthorizeRequest{
+		ResponseTypes:       fosite.Arguments{"code"},
+		RedirectURI:         &url.URL{Scheme: "http", Host: "127.0.0.1:1234", Path: "/callback"},
+		State:               "some-state",
+		ResponseMode:        fosite.ResponseModeQuery,
+		DefaultResponseMode: fosite.ResponseModeQuery,
+		Request: fosite.Request{
+			ID:          "abcd-1",
+			RequestedAt: time.Time{},
+			Client: &clientregistry.Client{
+				IDTokenLifetimeConfiguration: 42 * time.Second,
+				DefaultOpenIDConnectClient: fosite.DefaultOpenIDConnectClient{
+					DefaultClient: &fosite.DefaultClient{
+						ID:     "pinny",
+						Public: true,
+					},
+					JSONWebKeysURI:          "where",
+					TokenEndpointAuthMethod: "something",
+				},
+			},
+			Form:    url.Values{"key": []string{"val"}},
+			Session: session,
+		},
+	}
+	err := storage.CreatePARSession(ctx, requestURI, request)
+	require.NoError(t, err)
+	require.Equal(t, 1, storageLifetimeFuncCallCount)
+	require.Equal(t, request, storageLifetimeFuncCallRequesterArg)
+
+	newRequest, err := storage.GetPARSession(ctx, requestURI)
+	require.NoError(t, err)
+	require.Equal(t, request, newRequest)
+
+	err = storage.DeletePARSession(ctx, requestURI)
+	require.NoError(t, err)
+
+	testutil.LogActualJSONFromCreateAction(t, client, 0) // makes it easier to update expected values when needed
+	require.Equal(t, wantActions, client.Actions())
+
+	_, err = storage.GetPARSession(ctx, requestURI)
+	require.EqualError(t, err, "not_found")
+	require.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestGetNotFound(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	_, notFoundErr := storage.GetPARSession(ctx, "non-existent-request-uri")
+	require.EqualError(t, notFoundErr, "not_found")
+	require.True(t, errors.Is(notFoundErr, fosite.ErrNotFound))
+}
+
+func TestGetExpired(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	session := testutil.NewFakePinnipedSession()
+	session.SetExpiresAt(fosite.PushedAuthorizeRequestContext, fakeNow)
+
+	request := &fosite.AuthorizeRequest{
+		Request: fosite.Request{
+			ID:      "some-request-id",
+			Client:  &clientregistry.Client{},
+			Session: session,
+		},
+	}
+	err := storage.CreatePARSession(ctx, requestURI, request)
+	require.NoError(t, err)
+
+	// The garbage collector has not deleted it yet, but it has expired, so it should not be found.
+	_, err = storage.GetPARSession(ctx, requestURI)
+	require.EqualError(t, err, "not_found")
+	require.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestWrongVersion(t *testing.T) {
+	ctx, _, secrets, storage := makeTestSubject(lifetimeFunc)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secretName,
+			ResourceVersion: "",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "pushed-auth-request",
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"request":{"id":"abcd-1"},"version":"not-the-right-version"}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/pushed-auth-request",
+	}
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = storage.GetPARSession(ctx, requestURI)
+
+	require.EqualError(t, err, "pushed authorize request data has wrong version: pushed authorize request session has version not-the-right-version instead of "+expectedVersion)
+}
+
+func TestNilSessionRequest(t *testing.T) {
+	ctx, _, secrets, storage := makeTestSubject(lifetimeFunc)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secretName,
+			ResourceVersion: "",
+			Labels: map[string]string{
+				"storage.pinniped.dev/type": "pushed-auth-request",
+			},
+		},
+		Data: map[string][]byte{
+			"pinniped-storage-data":    []byte(`{"nonsense-key": "nonsense-value", "version":"` + expectedVersion + `"}`),
+			"pinniped-storage-version": []byte("1"),
+		},
+		Type: "storage.pinniped.dev/pushed-auth-request",
+	}
+
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = storage.GetPARSession(ctx, requestURI)
+	require.EqualError(t, err, "malformed pushed authorize request session: pushed authorize request data must be present")
+}
+
+func TestCreateWithNilRequester(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	err := storage.CreatePARSession(ctx, requestURI, nil)
+	require.EqualError(t, err, "requester must be of type fosite.AuthorizeRequest")
+}
+
+func TestCreateWithWrongRequesterDataTypes(t *testing.T) {
+	ctx, _, _, storage := makeTestSubject(lifetimeFunc)
+
+	request := &fosite.AuthorizeRequest{
+		Request: fosite.Request{
+			Session: nil,
+			Client:  &clientregistry.Client{},
+		},
+	}
+	err := storage.CreatePARSession(ctx, requestURI, request)
+	require.EqualError(t, err, "requester's session must be of type PinnipedSession")
+
+	request = &fosite.AuthorizeRequest{
+		Request: fosite.Request{
+			Session: &psession.PinnipedSession{},
+			Client:  nil,
+		},
+	}
+	err = storage.CreatePARSession(ctx, requestURI, request)
+	require.EqualError(t, err, "requester's client must be of type clientregistry.Client")
+}
+
+func makeTestSubject(lifetimeFunc func(requester fosite.Requester) time.Duration) (context.Context, *fake.Clientset, corev1client.SecretInterface, fosite.PARStorage) {
+	client := fake.NewSimpleClientset()
+	secrets := client.CoreV1().Secrets(namespace)
+	return context.Background(),
+		client,
+		secrets,
+		New(secrets, clocktesting.NewFakeClock(fakeNow).Now, lifetimeFunc)
+}
@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package fositestoragei
@@ -8,14 +8,17 @@ import (
 	fositeoauth2 "github.com/ory/fosite/handler/oauth2"
 	"github.com/ory/fosite/handler/openid"
 	"github.com/ory/fosite/handler/pkce"
+	"github.com/ory/fosite/handler/rfc8628"
 )
 
 // This interface seems to be missing from Fosite.
 // Not having this interface makes it a pain to avoid cyclical test dependencies, so we'll define it.
 type AllFositeStorage interface {
 	fosite.ClientManager
+	fosite.PARStorage
 	fositeoauth2.CoreStorage
 	fositeoauth2.TokenRevocationStorage
 	openid.OpenIDConnectRequestStorage
 	pkce.PKCERequestStorage
+	rfc8628.DeviceAuthStorage
 }
@@ -0,0 +1,212 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package githubclient
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/go-github/v68/github"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// GitHub rejects app JWTs which expire more than 10 minutes into the future.
+	appJWTLifetime = 9 * time.Minute
+
+	// GitHub recommends backdating the issued-at time of app JWTs to allow for clock drift.
+	appJWTClockSkew = 60 * time.Second
+
+	// Installation access tokens expire after one hour. Stop using them a little early so that
+	// they do not expire in the middle of a request.
+	installationTokenExpiryLeeway = 5 * time.Minute
+)
+
+// GitHubAppInterface makes calls to the GitHub API which are authenticated as an installation of a GitHub App,
+// rather than as the user who is logging in.
+type GitHubAppInterface interface {
+	GetRepositoryRoleForUser(ctx context.Context, owner, repo, login string) (string, error)
+}
+
+type githubAppClient struct {
+	httpClient *http.Client
+	baseURL    *url.URL
+	appID      int64
+	privateKey *rsa.PrivateKey
+	now        func() time.Time
+
+	// installationTokens caches an installation access token for each repository owner, keyed by the
+	// lowercase login name of the owner. A GitHub App is installed at most once per owner.
+	installationTokensMutex sync.Mutex
+	installationTokens      map[string]*github.InstallationToken
+}
+
+var _ GitHubAppInterface = (*githubAppClient)(nil)
+
+// NewGitHubAppClient returns a client which authenticates as the given GitHub App. The returned client caches
+// installation access tokens, so it should be reused for as long as the GitHub App's credentials do not change.
+func NewGitHubAppClient(httpClient *http.Client, apiBaseURL string, appID int64, privateKey *rsa.PrivateKey) (GitHubAppInterface, error) {
+	const errorPrefix = "unable to build new github app client"
+
+	if httpClient == nil {
+		return nil, fmt.Errorf("%s: httpClient cannot be nil", errorPrefix)
+	}
+
+	parsedURL, err := parseAPIBaseURL(errorPrefix, apiBaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if appID < 1 {
+		return nil, fmt.Errorf("%s: appID must be a positive integer", errorPrefix)
+	}
+
+	if privateKey == nil {
+		return nil, fmt.Errorf("%s: privateKey cannot be nil", errorPrefix)
+	}
+
+	return &githubAppClient{
+		httpClient:         httpClient,
+		baseURL:            parsedURL,
+		appID:              appID,
+		privateKey:         privateKey,
+		now:                time.Now,
+		installationTokens: map[string]*github.InstallationToken{},
+	}, nil
+}
+
+// ParseAppPrivateKey parses the PEM-encoded RSA private key of a GitHub App. GitHub generates
+// keys in PKCS #1 format, but PKCS #8 is also accepted.
+func ParseAppPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("PEM block is not a PKCS #1 or PKCS #8 private key")
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key must be an RSA key, found %T instead", key)
+	}
+	return rsaKey, nil
+}
+
+// GetRepositoryRoleForUser returns the highest role of the user with the given login name on the given repository,
+// or RepositoryRoleNone when the user has no role on the repository. The GitHub App must be installed on the owner
+// of the repository.
+func (g *githubAppClient) GetRepositoryRoleForUser(ctx context.Context, owner, repo, login string) (string, error) {
+	errorPrefix := fmt.Sprintf("error fetching role of user on repository %s/%s using GitHub App", owner, repo)
+
+	installationToken, err := g.installationToken(ctx, owner, repo)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", errorPrefix, err)
+	}
+
+	permissionLevel, _, err := g.newClient(installationToken).Repositories.GetPermissionLevel(ctx, owner, repo, login)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", errorPrefix, err)
+	}
+	plog.Trace("got raw GitHub API repository permission level results",
+		"repository", fmt.Sprintf("%s/%s", owner, repo),
+		"permission", permissionLevel.GetPermission(),
+		"roleName", permissionLevel.GetRoleName())
+
+	// The role name is the most precise answer, but it could be the name of a custom repository role.
+	// In that case, fall back to the permission, which is always one of the base roles "admin", "write", or "read".
+	switch roleName := permissionLevel.GetRoleName(); roleName {
+	case RepositoryRoleRead, RepositoryRoleTriage, RepositoryRoleWrite, RepositoryRoleMaintain, RepositoryRoleAdmin:
+		return roleName, nil
+	}
+
+	switch permission := permissionLevel.GetPermission(); permission {
+	case RepositoryRoleRead, RepositoryRoleWrite, RepositoryRoleAdmin:
+		return permission, nil
+	default: // includes "none"
+		return RepositoryRoleNone, nil
+	}
+}
+
+// installationToken returns a cached or newly created installation access token for the owner of the repository.
+// The lock is held while creating a token, so that concurrent logins do not each create a new token.
+func (g *githubAppClient) installationToken(ctx context.Context, owner, repo string) (string, error) {
+	g.installationTokensMutex.Lock()
+	defer g.installationTokensMutex.Unlock()
+
+	cacheKey := strings.ToLower(owner)
+	if cached, ok := g.installationTokens[cacheKey]; ok &&
+		g.now().Add(installationTokenExpiryLeeway).Before(cached.GetExpiresAt().Time) {
+		return cached.GetToken(), nil
+	}
+
+	appJWT, err := g.appJWT()
+	if err != nil {
+		return "", fmt.Errorf("unable to sign GitHub App JWT: %w", err)
+	}
+	appClient := g.newClient(appJWT)
+
+	installation, _, err := appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+	if isNotFound(err) {
+		return "", errors.New("the GitHub App is not installed for this repository")
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to find GitHub App installation: %w", err)
+	}
+
+	token, _, err := appClient.Apps.CreateInstallationToken(ctx, installation.GetID(), nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create GitHub App installation access token: %w", err)
+	}
+	if token.GetToken() == "" {
+		return "", errors.New(`the "token" attribute is missing from the GitHub App installation access token`)
+	}
+
+	g.installationTokens[cacheKey] = token
+	return token.GetToken(), nil
+}
+
+// appJWT returns a short-lived JWT which authenticates as the GitHub App itself.
+// See https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app.
+func (g *githubAppClient) appJWT() (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: g.privateKey},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	now := g.now()
+	return jwt.Signed(signer).Claims(jwt.Claims{
+		Issuer:   strconv.FormatInt(g.appID, 10),
+		IssuedAt: jwt.NewNumericDate(now.Add(-appJWTClockSkew)),
+		Expiry:   jwt.NewNumericDate(now.Add(appJWTLifetime)),
+	}).Serialize()
+}
+
+func (g *githubAppClient) newClient(token string) *github.Client {
+	client := github.NewClient(g.httpClient).WithAuthToken(token)
+	client.BaseURL = g.baseURL
+	return client
+}
@@ -0,0 +1,393 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package githubclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/go-github/v68/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGitHubAppClient(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		httpClient  *http.Client
+		apiBaseURL  string
+		appID       int64
+		privateKey  *rsa.PrivateKey
+		wantBaseURL string
+		wantErr     string
+	}{
+		{
+			name:        "happy path with https://api.github.com",
+			httpClient:  &http.Client{},
+			apiBaseURL:  "https://api.github.com",
+			appID:       12345,
+			privateKey:  privateKey,
+			wantBaseURL: "https://api.github.com/",
+		},
+		{
+			name:        "happy path with Enterprise URL https://fake.enterprise.tld/api/v3",
+			httpClient:  &http.Client{},
+			apiBaseURL:  "https://fake.enterprise.tld/api/v3",
+			appID:       12345,
+			privateKey:  privateKey,
+			wantBaseURL: "https://fake.enterprise.tld/api/v3/",
+		},
+		{
+			name:       "rejects nil http client",
+			apiBaseURL: "https://api.github.com",
+			appID:      12345,
+			privateKey: privateKey,
+			wantErr:    "unable to build new github app client: httpClient cannot be nil",
+		},
+		{
+			name:       "rejects apiBaseURL without https:// scheme",
+			httpClient: &http.Client{},
+			apiBaseURL: "scp://github.com",
+			appID:      12345,
+			privateKey: privateKey,
+			wantErr:    `unable to build new github app client: apiBaseURL must use "https" protocol, found "scp" instead`,
+		},
+		{
+			name:       "rejects appID which is not positive",
+			httpClient: &http.Client{},
+			apiBaseURL: "https://api.github.com",
+			privateKey: privateKey,
+			wantErr:    "unable to build new github app client: appID must be a positive integer",
+		},
+		{
+			name:       "rejects nil private key",
+			httpClient: &http.Client{},
+			apiBaseURL: "https://api.github.com",
+			appID:      12345,
+			wantErr:    "unable to build new github app client: privateKey cannot be nil",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			actualI, err := NewGitHubAppClient(test.httpClient, test.apiBaseURL, test.appID, test.privateKey)
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+				require.Nil(t, actualI)
+				return
+			}
+
+			require.NoError(t, err)
+			actual, ok := actualI.(*githubAppClient)
+			require.True(t, ok)
+			require.Equal(t, test.wantBaseURL, actual.baseURL.String())
+			require.Equal(t, test.appID, actual.appID)
+			require.Same(t, test.privateKey, actual.privateKey)
+		})
+	}
+}
+
+func TestParseAppPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pkcs8RSAKey, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pkcs8ECKey, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantErr string
+	}{
+		{
+			name: "PKCS #1 RSA key, as generated by GitHub",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		},
+		{
+			name: "PKCS #8 RSA key",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8RSAKey}),
+		},
+		{
+			name:    "not PEM",
+			pem:     []byte("this is not PEM"),
+			wantErr: "no PEM block found",
+		},
+		{
+			name:    "PEM block which is not a private key",
+			pem:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("not a key")}),
+			wantErr: "PEM block is not a PKCS #1 or PKCS #8 private key",
+		},
+		{
+			name:    "PKCS #8 key which is not an RSA key",
+			pem:     pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8ECKey}),
+			wantErr: "private key must be an RSA key, found *ecdsa.PrivateKey instead",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParseAppPrivateKey(test.pem)
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+				require.Nil(t, actual)
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, rsaKey.Equal(actual))
+		})
+	}
+}
+
+func TestGetRepositoryRoleForUser(t *testing.T) {
+	t.Parallel()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	now := time.Date(2025, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	installationHandler := func(t *testing.T) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			appJWT, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			require.True(t, found)
+
+			token, err := jwt.ParseSigned(appJWT, []jose.SignatureAlgorithm{jose.RS256})
+			require.NoError(t, err)
+			var claims jwt.Claims
+			require.NoError(t, token.Claims(&privateKey.PublicKey, &claims))
+			require.Equal(t, "12345", claims.Issuer)
+			require.Equal(t, now.Add(-60*time.Second), claims.IssuedAt.Time().UTC())
+			require.Equal(t, now.Add(9*time.Minute), claims.Expiry.Time().UTC())
+
+			_, err = w.Write([]byte(`{"id":42}`))
+			require.NoError(t, err)
+		}
+	}
+
+	tokenHandler := func(t *testing.T) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/app/installations/42/access_tokens", r.URL.Path)
+			_, err := w.Write([]byte(`{"token":"some-installation-token","expires_at":"2025-03-04T06:06:07Z"}`))
+			require.NoError(t, err)
+		}
+	}
+
+	permissionHandler := func(t *testing.T, response string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "Bearer some-installation-token", r.Header.Get("Authorization"))
+			require.Equal(t, "/repos/some-owner/some-repo/collaborators/some-login/permission", r.URL.Path)
+			_, err := w.Write([]byte(response))
+			require.NoError(t, err)
+		}
+	}
+
+	buildHTTPClient := func(t *testing.T, permissionResponse string) *http.Client {
+		return mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(mock.GetReposInstallationByOwnerByRepo, installationHandler(t)),
+			mock.WithRequestMatchHandler(mock.PostAppInstallationsAccessTokensByInstallationId, tokenHandler(t)),
+			mock.WithRequestMatchHandler(mock.GetReposCollaboratorsPermissionByOwnerByRepoByUsername, permissionHandler(t, permissionResponse)),
+		)
+	}
+
+	buildErrorHandler := func(status int, message string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mock.WriteError(w, status, message)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		httpClient func(t *testing.T) *http.Client
+		wantErr    string
+		wantRole   string
+	}{
+		{
+			name: "returns the role name when it is a base role",
+			httpClient: func(t *testing.T) *http.Client {
+				return buildHTTPClient(t, `{"permission":"write","role_name":"maintain"}`)
+			},
+			wantRole: RepositoryRoleMaintain,
+		},
+		{
+			name: "returns triage",
+			httpClient: func(t *testing.T) *http.Client {
+				return buildHTTPClient(t, `{"permission":"read","role_name":"triage"}`)
+			},
+			wantRole: RepositoryRoleTriage,
+		},
+		{
+			name: "falls back to the permission for custom repository roles",
+			httpClient: func(t *testing.T) *http.Client {
+				return buildHTTPClient(t, `{"permission":"write","role_name":"some-custom-role"}`)
+			},
+			wantRole: RepositoryRoleWrite,
+		},
+		{
+			name: "falls back to the permission when the role name is missing",
+			httpClient: func(t *testing.T) *http.Client {
+				return buildHTTPClient(t, `{"permission":"admin"}`)
+			},
+			wantRole: RepositoryRoleAdmin,
+		},
+		{
+			name: "returns none when the user has no permission",
+			httpClient: func(t *testing.T) *http.Client {
+				return buildHTTPClient(t, `{"permission":"none","role_name":""}`)
+			},
+			wantRole: RepositoryRoleNone,
+		},
+		{
+			name: "returns an error when the GitHub App is not installed for the repository",
+			httpClient: func(t *testing.T) *http.Client {
+				return mock.NewMockedHTTPClient(
+					mock.WithRequestMatchHandler(mock.GetReposInstallationByOwnerByRepo, buildErrorHandler(http.StatusNotFound, "Not Found")),
+				)
+			},
+			wantErr: "error fetching role of user on repository some-owner/some-repo using GitHub App: the GitHub App is not installed for this repository",
+		},
+		{
+			name: "returns errors from finding the installation",
+			httpClient: func(t *testing.T) *http.Client {
+				return mock.NewMockedHTTPClient(
+					mock.WithRequestMatchHandler(mock.GetReposInstallationByOwnerByRepo, buildErrorHandler(http.StatusFailedDependency, "some random client error")),
+				)
+			},
+			wantErr: "error fetching role of user on repository some-owner/some-repo using GitHub App: unable to find GitHub App installation: GET {SERVER_URL}/repos/some-owner/some-repo/installation: 424 some random client error []",
+		},
+		{
+			name: "returns errors from creating the installation token",
+			httpClient: func(t *testing.T) *http.Client {
+				return mock.NewMockedHTTPClient(
+					mock.WithRequestMatchHandler(mock.GetReposInstallationByOwnerByRepo, installationHandler(t)),
+					mock.WithRequestMatchHandler(mock.PostAppInstallationsAccessTokensByInstallationId, buildErrorHandler(http.StatusForbidden, "some permission error")),
+				)
+			},
+			wantErr: "error fetching role of user on repository some-owner/some-repo using GitHub App: unable to create GitHub App installation access token: POST {SERVER_URL}/app/installations/42/access_tokens: 403 some permission error []",
+		},
+		{
+			name: "returns an error when the installation token is missing",
+			httpClient: func(t *testing.T) *http.Client {
+				return mock.NewMockedHTTPClient(
+					mock.WithRequestMatchHandler(mock.GetReposInstallationByOwnerByRepo, installationHandler(t)),
+					mock.WithRequestMatch(mock.PostAppInstallationsAccessTokensByInstallationId, github.InstallationToken{}),
+				)
+			},
+			wantErr: `error fetching role of user on repository some-owner/some-repo using GitHub App: the "token" attribute is missing from the GitHub App installation access token`,
+		},
+		{
+			name: "returns errors from fetching the permission level",
+			httpClient: func(t *testing.T) *http.Client {
+				return mock.NewMockedHTTPClient(
+					mock.WithRequestMatchHandler(mock.GetReposInstallationByOwnerByRepo, installationHandler(t)),
+					mock.WithRequestMatchHandler(mock.PostAppInstallationsAccessTokensByInstallationId, tokenHandler(t)),
+					mock.WithRequestMatchHandler(mock.GetReposCollaboratorsPermissionByOwnerByRepoByUsername, buildErrorHandler(http.StatusFailedDependency, "some random client error")),
+				)
+			},
+			wantErr: "error fetching role of user on repository some-owner/some-repo using GitHub App: GET {SERVER_URL}/repos/some-owner/some-repo/collaborators/some-login/permission: 424 some random client error []",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			httpClient := test.httpClient(t)
+			clientI, err := NewGitHubAppClient(httpClient, "https://api.github.com", 12345, privateKey)
+			require.NoError(t, err)
+			client, ok := clientI.(*githubAppClient)
+			require.True(t, ok)
+			client.now = func() time.Time { return now }
+
+			actual, err := client.GetRepositoryRoleForUser(context.Background(), "some-owner", "some-repo", "some-login")
+			if test.wantErr != "" {
+				rt, ok := httpClient.Transport.(*mock.EnforceHostRoundTripper)
+				require.True(t, ok)
+				require.EqualError(t, err, strings.ReplaceAll(test.wantErr, "{SERVER_URL}", rt.Host))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantRole, actual)
+		})
+	}
+
+	t.Run("caches installation tokens per owner until shortly before they expire", func(t *testing.T) {
+		t.Parallel()
+
+		var tokensCreated atomic.Int32
+		httpClient := mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(mock.GetReposInstallationByOwnerByRepo, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte(`{"id":42}`))
+				require.NoError(t, err)
+			})),
+			mock.WithRequestMatchHandler(mock.PostAppInstallationsAccessTokensByInstallationId, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tokensCreated.Add(1)
+				_, err := w.Write([]byte(`{"token":"some-installation-token","expires_at":"2025-03-04T06:06:07Z"}`))
+				require.NoError(t, err)
+			})),
+			mock.WithRequestMatchHandler(mock.GetReposCollaboratorsPermissionByOwnerByRepoByUsername, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := w.Write([]byte(`{"permission":"read","role_name":"read"}`))
+				require.NoError(t, err)
+			})),
+		)
+
+		clientI, err := NewGitHubAppClient(httpClient, "https://api.github.com", 12345, privateKey)
+		require.NoError(t, err)
+		client, ok := clientI.(*githubAppClient)
+		require.True(t, ok)
+		currentTime := now
+		client.now = func() time.Time { return currentTime }
+
+		getRole := func(owner string) {
+			role, err := client.GetRepositoryRoleForUser(context.Background(), owner, "some-repo", "some-login")
+			require.NoError(t, err)
+			require.Equal(t, RepositoryRoleRead, role)
+		}
+
+		getRole("some-owner")
+		require.Equal(t, int32(1), tokensCreated.Load())
+
+		// Owner names are case-insensitive.
+		getRole("SOME-OWNER")
+		require.Equal(t, int32(1), tokensCreated.Load())
+
+		// A different owner needs a different installation token.
+		getRole("other-owner")
+		require.Equal(t, int32(2), tokensCreated.Load())
+
+		// The token expires at 06:06:07, so it is still used until 06:01:07.
+		currentTime = now.Add(54 * time.Minute)
+		getRole("some-owner")
+		require.Equal(t, int32(2), tokensCreated.Load())
+
+		currentTime = now.Add(55 * time.Minute)
+		getRole("some-owner")
+		require.Equal(t, int32(3), tokensCreated.Load())
+	})
+}
@@ -35,10 +35,22 @@ type TeamInfo struct {
 	Org  string
 }
 
+// Repository roles, as they are known in the GitHub UI. The empty string means that the user has no role on a repository.
+// See https://docs.github.com/en/organizations/managing-user-access-to-your-organizations-repositories/managing-repository-roles/repository-roles-for-an-organization.
+const (
+	RepositoryRoleNone     = ""
+	RepositoryRoleRead     = "read"
+	RepositoryRoleTriage   = "triage"
+	RepositoryRoleWrite    = "write"
+	RepositoryRoleMaintain = "maintain"
+	RepositoryRoleAdmin    = "admin"
+)
+
 type GitHubInterface interface {
 	GetUserInfo(ctx context.Context) (*UserInfo, error)
 	GetOrgMembership(ctx context.Context) ([]string, error)
 	GetTeamMembership(ctx context.Context, allowedOrganizations *setutil.CaseInsensitiveSet) ([]TeamInfo, error)
+	GetRepositoryRole(ctx context.Context, owner, repo string) (string, error)
 }
 
 type githubClient struct {
@@ -54,17 +66,9 @@ func NewGitHubClient(httpClient *http.Client, apiBaseURL, token string) (GitHubI
 		return nil, fmt.Errorf("%s: httpClient cannot be nil", errorPrefix)
 	}
 
-	parsedURL, err := url.Parse(apiBaseURL)
+	parsedURL, err := parseAPIBaseURL(errorPrefix, apiBaseURL)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", errorPrefix, err)
-	}
-
-	if !strings.HasSuffix(parsedURL.Path, "/") {
-		parsedURL.Path += "/"
-	}
-
-	if parsedURL.Scheme != "https" {
-		return nil, fmt.Errorf(`%s: apiBaseURL must use "https" protocol, found %q instead`, errorPrefix, parsedURL.Scheme)
+		return nil, err
 	}
 
 	if token == "" {
@@ -79,6 +83,23 @@ func NewGitHubClient(httpClient *http.Client, apiBaseURL, token string) (GitHubI
 	}, nil
 }
 
+func parseAPIBaseURL(errorPrefix, apiBaseURL string) (*url.URL, error) {
+	parsedURL, err := url.Parse(apiBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errorPrefix, err)
+	}
+
+	if !strings.HasSuffix(parsedURL.Path, "/") {
+		parsedURL.Path += "/"
+	}
+
+	if parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf(`%s: apiBaseURL must use "https" protocol, found %q instead`, errorPrefix, parsedURL.Scheme)
+	}
+
+	return parsedURL, nil
+}
+
 // GetUserInfo returns the "Login" and "ID" attributes of the logged-in user.
 func (g *githubClient) GetUserInfo(ctx context.Context) (*UserInfo, error) {
 	const errorPrefix = "error fetching authenticated user"
@@ -233,3 +254,44 @@ func (g *githubClient) GetTeamMembership(ctx context.Context, allowedOrganizatio
 	plog.Trace("calculated response from GitHub teams endpoint", "teams", sortedTeams)
 	return sortedTeams, nil
 }
+
+// GetRepositoryRole returns the highest role of the authenticated user on the given repository,
+// or RepositoryRoleNone when the repository is not visible to the authenticated user.
+func (g *githubClient) GetRepositoryRole(ctx context.Context, owner, repo string) (string, error) {
+	errorPrefix := fmt.Sprintf("error fetching repository %s/%s for authenticated user", owner, repo)
+
+	repository, _, err := g.client.Repositories.Get(ctx, owner, repo)
+	if isNotFound(err) {
+		// GitHub returns 404 for private repositories which the user cannot see.
+		return RepositoryRoleNone, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", errorPrefix, err)
+	}
+	plog.Trace("got raw GitHub API repository permissions", "repository", repository.GetFullName(), "permissions", repository.GetPermissions())
+
+	permissions := repository.GetPermissions()
+	// Check from the most privileged role to the least privileged role. Note that the GitHub API
+	// calls the "write" role "push" and calls the "read" role "pull" in this response.
+	switch {
+	case permissions["admin"]:
+		return RepositoryRoleAdmin, nil
+	case permissions["maintain"]:
+		return RepositoryRoleMaintain, nil
+	case permissions["push"]:
+		return RepositoryRoleWrite, nil
+	case permissions["triage"]:
+		return RepositoryRoleTriage, nil
+	case permissions["pull"]:
+		return RepositoryRoleRead, nil
+	default:
+		return RepositoryRoleNone, nil
+	}
+}
+
+func isNotFound(err error) bool {
+	var errorResponse *github.ErrorResponse
+	return errors.As(err, &errorResponse) &&
+		errorResponse.Response != nil &&
+		errorResponse.Response.StatusCode == http.StatusNotFound
+}
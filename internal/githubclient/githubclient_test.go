@@ -831,3 +831,183 @@ func TestGetTeamMembership(t *testing.T) {
 		})
 	}
 }
+
+func TestGetRepositoryRole(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		httpClient *http.Client
+		token      string
+		ctx        context.Context
+		wantErr    string
+		wantRole   string
+	}{
+		{
+			name: "returns admin when the user has all permissions",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposByOwnerByRepo,
+					github.Repository{
+						FullName: github.Ptr("some-owner/some-repo"),
+						Permissions: map[string]bool{
+							"admin":    true,
+							"maintain": true,
+							"push":     true,
+							"triage":   true,
+							"pull":     true,
+						},
+					},
+				),
+			),
+			token:    "some-token",
+			wantRole: RepositoryRoleAdmin,
+		},
+		{
+			name: "returns maintain",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposByOwnerByRepo,
+					github.Repository{
+						Permissions: map[string]bool{"maintain": true, "push": true, "triage": true, "pull": true},
+					},
+				),
+			),
+			token:    "some-token",
+			wantRole: RepositoryRoleMaintain,
+		},
+		{
+			name: "returns write when the user has push permission",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposByOwnerByRepo,
+					github.Repository{
+						Permissions: map[string]bool{"admin": false, "push": true, "triage": true, "pull": true},
+					},
+				),
+			),
+			token:    "some-token",
+			wantRole: RepositoryRoleWrite,
+		},
+		{
+			name: "returns triage",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposByOwnerByRepo,
+					github.Repository{
+						Permissions: map[string]bool{"triage": true, "pull": true},
+					},
+				),
+			),
+			token:    "some-token",
+			wantRole: RepositoryRoleTriage,
+		},
+		{
+			name: "returns read when the user has pull permission",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposByOwnerByRepo,
+					github.Repository{
+						Permissions: map[string]bool{"pull": true},
+					},
+				),
+			),
+			token:    "some-token",
+			wantRole: RepositoryRoleRead,
+		},
+		{
+			name: "returns none when the repository has no permissions attribute",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposByOwnerByRepo,
+					github.Repository{},
+				),
+			),
+			token:    "some-token",
+			wantRole: RepositoryRoleNone,
+		},
+		{
+			name: "returns none when the repository is not found, since private repositories are hidden from users who cannot see them",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.GetReposByOwnerByRepo,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						mock.WriteError(w, http.StatusNotFound, "Not Found")
+					}),
+				),
+			),
+			token:    "some-token",
+			wantRole: RepositoryRoleNone,
+		},
+		{
+			name: "the token is added in the Authorization header",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.GetReposByOwnerByRepo,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						require.Len(t, r.Header["Authorization"], 1)
+						require.Equal(t, "Bearer does-this-token-work", r.Header.Get("Authorization"))
+						require.Equal(t, "/repos/some-owner/some-repo", r.URL.Path)
+						_, err := w.Write([]byte(`{"permissions":{"pull":true}}`))
+						require.NoError(t, err)
+					}),
+				),
+			),
+			token:    "does-this-token-work",
+			wantRole: RepositoryRoleRead,
+		},
+		{
+			name:       "passes the context parameter into the API call",
+			token:      "some-token",
+			httpClient: mock.NewMockedHTTPClient(),
+			ctx: func() context.Context {
+				canceledCtx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return canceledCtx
+			}(),
+			wantErr: "error fetching repository some-owner/some-repo for authenticated user: context canceled",
+		},
+		{
+			name: "returns errors from the API",
+			httpClient: mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.GetReposByOwnerByRepo,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						mock.WriteError(
+							w,
+							http.StatusFailedDependency,
+							"some random client error",
+						)
+					}),
+				),
+			),
+			token:   "some-token",
+			wantErr: "error fetching repository some-owner/some-repo for authenticated user: GET {SERVER_URL}/repos/some-owner/some-repo: 424 some random client error []",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			githubClient := &githubClient{
+				client: github.NewClient(test.httpClient).WithAuthToken(test.token),
+			}
+
+			ctx := context.Background()
+			if test.ctx != nil {
+				ctx = test.ctx
+			}
+
+			actual, err := githubClient.GetRepositoryRole(ctx, "some-owner", "some-repo")
+			if test.wantErr != "" {
+				rt, ok := test.httpClient.Transport.(*mock.EnforceHostRoundTripper)
+				require.True(t, ok)
+				test.wantErr = strings.ReplaceAll(test.wantErr, "{SERVER_URL}", rt.Host)
+				require.EqualError(t, err, test.wantErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.wantRole, actual)
+			}
+		})
+	}
+}
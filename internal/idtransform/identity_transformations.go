@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package idtransform defines upstream-to-downstream identity transformations which could be
@@ -8,6 +8,7 @@ package idtransform
 import (
 	"context"
 	"fmt"
+	"maps"
 	"sort"
 	"strings"
 
@@ -20,11 +21,50 @@ type TransformationResult struct {
 	Groups                        []string // the new group names for an allowed auth
 	AuthenticationAllowed         bool     // when false, disallow this authentication attempt
 	RejectedAuthenticationMessage string   // should be set when AuthenticationAllowed is false
+
+	// AdditionalClaims are the additional claims for the downstream ID token which were returned by the
+	// transformation, keyed by claim name. The values must be JSON-compatible. May be nil when there are none.
+	AdditionalClaims map[string]any
+}
+
+// AuthenticationContext describes the context of the authentication, when that is known: how the user authenticated
+// to the upstream identity provider, what the upstream identity provider said about the user, and which downstream
+// request is being authenticated. It is made available to transformations as an input, but transformations cannot
+// change it.
+type AuthenticationContext struct {
+	// ACR is the Authentication Context Class Reference ("acr" claim) which was satisfied by the authentication,
+	// or empty when unknown.
+	ACR string
+
+	// AMR is the list of Authentication Methods References ("amr" claim) which were used by the authentication,
+	// or empty when unknown.
+	AMR []string
+
+	// Upstream is the raw identity data from the upstream identity provider, before any of it was mapped to a
+	// username or group names, e.g. the claims of an OIDC provider or the attributes of an LDAP entry. Its contents
+	// depend on the type of the upstream identity provider. The values should be the types produced by decoding
+	// JSON, or slices of strings. Transformations must treat it as read-only. May be nil when unknown.
+	Upstream map[string]any
+
+	// Request describes the downstream request which is being authenticated.
+	Request RequestContext
+}
+
+// RequestContext describes the downstream request which is being authenticated.
+type RequestContext struct {
+	// ClientID is the ID of the downstream client which started the authentication, or empty when unknown.
+	ClientID string
+
+	// Scopes are the scopes which were requested by the downstream client, or empty when unknown.
+	Scopes []string
+
+	// Audience is the audience which was requested by the downstream client, or empty when unknown.
+	Audience []string
 }
 
 // IdentityTransformation is an individual identity transformation which can be evaluated.
 type IdentityTransformation interface {
-	Evaluate(ctx context.Context, username string, groups []string) (*TransformationResult, error)
+	Evaluate(ctx context.Context, username string, groups []string, authContext *AuthenticationContext) (*TransformationResult, error)
 
 	// Source returns some representation of the original source code of the transformation, which is
 	// useful for tests to be able to check that a compiled transformation came from the right source.
@@ -49,14 +89,63 @@ func (p *TransformationPipeline) AppendTransformation(t IdentityTransformation)
 	p.transforms = append(p.transforms, t)
 }
 
+// AppendPipeline adds all the transformations of another pipeline to the end of the list of transformations for
+// this pipeline. The other pipeline is not changed. Like AppendTransformation, this is not thread-safe.
+func (p *TransformationPipeline) AppendPipeline(other *TransformationPipeline) {
+	p.transforms = append(p.transforms, other.transforms...)
+}
+
 // Evaluate runs the transformation pipeline for a given input identity. It returns a potentially transformed or
 // rejected identity, or an error. If any transformation in the list rejects the authentication, then the list is
-// short-circuited but no error is returned. Only unexpected errors are returned as errors. This is safe to call
-// from multiple goroutines.
-func (p *TransformationPipeline) Evaluate(ctx context.Context, username string, groups []string) (*TransformationResult, error) {
+// short-circuited but no error is returned. Only unexpected errors are returned as errors. The authContext may be
+// nil when it is unknown. The additional claims returned by all the transformations are merged into the result,
+// with claims from later transformations replacing claims of the same name from earlier transformations.
+// This is safe to call from multiple goroutines.
+func (p *TransformationPipeline) Evaluate(
+	ctx context.Context,
+	username string,
+	groups []string,
+	authContext *AuthenticationContext,
+) (*TransformationResult, error) {
+	return p.evaluate(ctx, username, groups, authContext, nil)
+}
+
+// TransformationStep describes the evaluation of one transformation of a pipeline.
+type TransformationStep struct {
+	Index  int                   // the index of the transformation in the pipeline
+	Source any                   // the Source of the transformation
+	Result *TransformationResult // the result returned by the transformation
+}
+
+// EvaluateWithSteps is the same as Evaluate, except that it also returns the result of each transformation which
+// was evaluated, in order, which is useful to explain how the final result was decided. When an error is returned,
+// the steps which were evaluated before the error are still returned.
+func (p *TransformationPipeline) EvaluateWithSteps(
+	ctx context.Context,
+	username string,
+	groups []string,
+	authContext *AuthenticationContext,
+) (*TransformationResult, []*TransformationStep, error) {
+	steps := []*TransformationStep{}
+	result, err := p.evaluate(ctx, username, groups, authContext, func(step *TransformationStep) {
+		steps = append(steps, step)
+	})
+	return result, steps, err
+}
+
+func (p *TransformationPipeline) evaluate(
+	ctx context.Context,
+	username string,
+	groups []string,
+	authContext *AuthenticationContext,
+	recordStep func(step *TransformationStep),
+) (*TransformationResult, error) {
 	if groups == nil {
 		groups = []string{}
 	}
+	if authContext == nil {
+		authContext = &AuthenticationContext{}
+	}
 
 	accumulatedResult := &TransformationResult{
 		Username:              username,
@@ -64,13 +153,20 @@ func (p *TransformationPipeline) Evaluate(ctx context.Context, username string,
 		AuthenticationAllowed: true,
 	}
 
+	accumulatedClaims := map[string]any{}
+
 	for i, transform := range p.transforms {
 		var err error
-		accumulatedResult, err = transform.Evaluate(ctx, accumulatedResult.Username, accumulatedResult.Groups)
+		accumulatedResult, err = transform.Evaluate(ctx, accumulatedResult.Username, accumulatedResult.Groups, authContext)
 		if err != nil {
 			// There was an unexpected error evaluating a transformation.
 			return nil, fmt.Errorf("identity transformation at index %d: %w", i, err)
 		}
+		if recordStep != nil {
+			// Record a copy, because the accumulated result may be changed below.
+			stepResult := *accumulatedResult
+			recordStep(&TransformationStep{Index: i, Source: transform.Source(), Result: &stepResult})
+		}
 		if !accumulatedResult.AuthenticationAllowed {
 			// Auth has been rejected by a policy. Stop evaluating the rest of the transformations.
 			return accumulatedResult, nil
@@ -81,9 +177,14 @@ func (p *TransformationPipeline) Evaluate(ctx context.Context, username string,
 		if accumulatedResult.Groups == nil {
 			return nil, fmt.Errorf("identity transformation returned a null list of groups, which is not allowed")
 		}
+		maps.Copy(accumulatedClaims, accumulatedResult.AdditionalClaims)
 	}
 
 	accumulatedResult.Groups = sortAndUniq(accumulatedResult.Groups)
+	accumulatedResult.AdditionalClaims = nil
+	if len(accumulatedClaims) > 0 {
+		accumulatedResult.AdditionalClaims = accumulatedClaims
+	}
 
 	// There were no unexpected errors and no policy which rejected auth.
 	return accumulatedResult, nil
@@ -97,6 +198,101 @@ func (p *TransformationPipeline) Source() []any {
 	return result
 }
 
+// TokenExchangePipeline is a TransformationPipeline which is only evaluated during those token exchanges which
+// request one of its audiences, and which are performed by one of its clients.
+type TokenExchangePipeline struct {
+	audiences sets.Set[string]
+	clientIDs sets.Set[string]
+	pipeline  *TransformationPipeline
+}
+
+// NewTokenExchangePipeline creates a TokenExchangePipeline which evaluates the given pipeline during token exchanges
+// which match both the audiences and the client IDs. An empty list of audiences matches every requested audience,
+// and an empty list of client IDs matches every client.
+func NewTokenExchangePipeline(audiences []string, clientIDs []string, pipeline *TransformationPipeline) *TokenExchangePipeline {
+	return &TokenExchangePipeline{
+		audiences: sets.New(audiences...),
+		clientIDs: sets.New(clientIDs...),
+		pipeline:  pipeline,
+	}
+}
+
+// Matches returns true when this pipeline should be evaluated during a token exchange which requests the given
+// audience and which is performed by the given client.
+func (p *TokenExchangePipeline) Matches(requestedAudience string, clientID string) bool {
+	return (p.audiences.Len() == 0 || p.audiences.Has(requestedAudience)) &&
+		(p.clientIDs.Len() == 0 || p.clientIDs.Has(clientID))
+}
+
+// Audiences returns the sorted list of audiences matched by this TokenExchangePipeline.
+func (p *TokenExchangePipeline) Audiences() []string {
+	return sets.List(p.audiences)
+}
+
+// ClientIDs returns the sorted list of client IDs matched by this TokenExchangePipeline.
+func (p *TokenExchangePipeline) ClientIDs() []string {
+	return sets.List(p.clientIDs)
+}
+
+// Pipeline returns the TransformationPipeline which is evaluated by this TokenExchangePipeline.
+func (p *TokenExchangePipeline) Pipeline() *TransformationPipeline {
+	return p.pipeline
+}
+
+// TokenExchangePipelines is a list of TokenExchangePipeline, which are evaluated in order during a token exchange.
+type TokenExchangePipelines []*TokenExchangePipeline
+
+// Evaluate runs every pipeline from the list which matches the requested audience and client ID, in order, for a
+// given input identity. The input identity should be the result of the identity's login-time TransformationPipeline.
+// Each matching pipeline receives the potentially transformed identity from the previous matching pipeline.
+// When no pipelines match, the input identity is returned unchanged. Like TransformationPipeline.Evaluate, this
+// returns a rejected identity without an error when any matching pipeline rejects the authentication. The additional
+// claims returned by all the matching pipelines are merged into the result, with claims from later pipelines replacing
+// claims of the same name from earlier pipelines. This is safe to call from multiple goroutines.
+func (p TokenExchangePipelines) Evaluate(
+	ctx context.Context,
+	requestedAudience string,
+	clientID string,
+	username string,
+	groups []string,
+	authContext *AuthenticationContext,
+) (*TransformationResult, error) {
+	if groups == nil {
+		groups = []string{}
+	}
+
+	accumulatedResult := &TransformationResult{
+		Username:              username,
+		Groups:                groups,
+		AuthenticationAllowed: true,
+	}
+
+	accumulatedClaims := map[string]any{}
+
+	for i, tokenExchangePipeline := range p {
+		if !tokenExchangePipeline.Matches(requestedAudience, clientID) {
+			continue
+		}
+		var err error
+		accumulatedResult, err = tokenExchangePipeline.pipeline.Evaluate(ctx, accumulatedResult.Username, accumulatedResult.Groups, authContext)
+		if err != nil {
+			return nil, fmt.Errorf("token exchange transformation pipeline at index %d: %w", i, err)
+		}
+		if !accumulatedResult.AuthenticationAllowed {
+			// Auth has been rejected by a policy. Stop evaluating the rest of the pipelines.
+			return accumulatedResult, nil
+		}
+		maps.Copy(accumulatedClaims, accumulatedResult.AdditionalClaims)
+	}
+
+	accumulatedResult.AdditionalClaims = nil
+	if len(accumulatedClaims) > 0 {
+		accumulatedResult.AdditionalClaims = accumulatedClaims
+	}
+
+	return accumulatedResult, nil
+}
+
 func sortAndUniq(s []string) []string {
 	unique := sets.New(s...).UnsortedList()
 	sort.Strings(unique)
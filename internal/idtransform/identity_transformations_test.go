@@ -1,4 +1,4 @@
-// Copyright 2023-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package idtransform
@@ -6,6 +6,7 @@ package idtransform
 import (
 	"context"
 	"errors"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -13,7 +14,7 @@ import (
 
 type fakeNoopTransformer struct{}
 
-func (a fakeNoopTransformer) Evaluate(_ctx context.Context, username string, groups []string) (*TransformationResult, error) {
+func (a fakeNoopTransformer) Evaluate(_ctx context.Context, username string, groups []string, _authContext *AuthenticationContext) (*TransformationResult, error) {
 	return &TransformationResult{
 		Username:                      username,
 		Groups:                        groups,
@@ -28,7 +29,7 @@ func (a fakeNoopTransformer) Source() any {
 
 type fakeNilGroupTransformer struct{}
 
-func (a fakeNilGroupTransformer) Evaluate(_ctx context.Context, username string, _groups []string) (*TransformationResult, error) {
+func (a fakeNilGroupTransformer) Evaluate(_ctx context.Context, username string, _groups []string, _authContext *AuthenticationContext) (*TransformationResult, error) {
 	return &TransformationResult{
 		Username:                      username,
 		Groups:                        nil,
@@ -43,7 +44,7 @@ func (a fakeNilGroupTransformer) Source() any {
 
 type fakeAppendStringTransformer struct{}
 
-func (a fakeAppendStringTransformer) Evaluate(_ctx context.Context, username string, groups []string) (*TransformationResult, error) {
+func (a fakeAppendStringTransformer) Evaluate(_ctx context.Context, username string, groups []string, _authContext *AuthenticationContext) (*TransformationResult, error) {
 	newGroups := []string{}
 	for _, group := range groups {
 		newGroups = append(newGroups, group+":transformed")
@@ -62,7 +63,7 @@ func (a fakeAppendStringTransformer) Source() any {
 
 type fakeDeleteUsernameAndGroupsTransformer struct{}
 
-func (a fakeDeleteUsernameAndGroupsTransformer) Evaluate(_ctx context.Context, _username string, _groups []string) (*TransformationResult, error) {
+func (a fakeDeleteUsernameAndGroupsTransformer) Evaluate(_ctx context.Context, _username string, _groups []string, _authContext *AuthenticationContext) (*TransformationResult, error) {
 	return &TransformationResult{
 		Username:                      "",
 		Groups:                        []string{},
@@ -77,7 +78,7 @@ func (a fakeDeleteUsernameAndGroupsTransformer) Source() any {
 
 type fakeAuthenticationDisallowedTransformer struct{}
 
-func (a fakeAuthenticationDisallowedTransformer) Evaluate(_ctx context.Context, username string, groups []string) (*TransformationResult, error) {
+func (a fakeAuthenticationDisallowedTransformer) Evaluate(_ctx context.Context, username string, groups []string, _authContext *AuthenticationContext) (*TransformationResult, error) {
 	newGroups := []string{}
 	for _, group := range groups {
 		newGroups = append(newGroups, group+":disallowed")
@@ -94,9 +95,70 @@ func (a fakeAuthenticationDisallowedTransformer) Source() any {
 	return nil // not needed for this test
 }
 
+type fakeRequireMFATransformer struct{}
+
+func (a fakeRequireMFATransformer) Evaluate(_ctx context.Context, username string, groups []string, authContext *AuthenticationContext) (*TransformationResult, error) {
+	if !slices.Contains(authContext.AMR, "mfa") {
+		return &TransformationResult{
+			Username:                      username,
+			Groups:                        groups,
+			AuthenticationAllowed:         false,
+			RejectedAuthenticationMessage: "mfa required for " + authContext.ACR,
+		}, nil
+	}
+	return &TransformationResult{
+		Username:              username,
+		Groups:                groups,
+		AuthenticationAllowed: true,
+	}, nil
+}
+
+func (a fakeRequireMFATransformer) Source() any {
+	return nil // not needed for this test
+}
+
+type fakeRequireVerifiedEmailFromClientTransformer struct{}
+
+func (a fakeRequireVerifiedEmailFromClientTransformer) Evaluate(_ctx context.Context, username string, groups []string, authContext *AuthenticationContext) (*TransformationResult, error) {
+	if authContext.Upstream["email_verified"] != true || authContext.Request.ClientID != "some-client" {
+		return &TransformationResult{
+			Username:                      username,
+			Groups:                        groups,
+			AuthenticationAllowed:         false,
+			RejectedAuthenticationMessage: "verified email required for client " + authContext.Request.ClientID,
+		}, nil
+	}
+	return &TransformationResult{
+		Username:              username,
+		Groups:                groups,
+		AuthenticationAllowed: true,
+	}, nil
+}
+
+func (a fakeRequireVerifiedEmailFromClientTransformer) Source() any {
+	return nil // not needed for this test
+}
+
+type fakeAddClaimsTransformer struct {
+	claims map[string]any
+}
+
+func (a fakeAddClaimsTransformer) Evaluate(_ctx context.Context, username string, groups []string, _authContext *AuthenticationContext) (*TransformationResult, error) {
+	return &TransformationResult{
+		Username:              username,
+		Groups:                groups,
+		AuthenticationAllowed: true,
+		AdditionalClaims:      a.claims,
+	}, nil
+}
+
+func (a fakeAddClaimsTransformer) Source() any {
+	return nil // not needed for this test
+}
+
 type fakeErrorTransformer struct{}
 
-func (a fakeErrorTransformer) Evaluate(_ctx context.Context, _username string, _groups []string) (*TransformationResult, error) {
+func (a fakeErrorTransformer) Evaluate(_ctx context.Context, _username string, _groups []string, _authContext *AuthenticationContext) (*TransformationResult, error) {
 	return &TransformationResult{}, errors.New("unexpected catastrophic error")
 }
 
@@ -108,7 +170,7 @@ type fakeTransformerWithSource struct {
 	source string
 }
 
-func (a fakeTransformerWithSource) Evaluate(_ctx context.Context, _username string, _groups []string) (*TransformationResult, error) {
+func (a fakeTransformerWithSource) Evaluate(_ctx context.Context, _username string, _groups []string, _authContext *AuthenticationContext) (*TransformationResult, error) {
 	return nil, nil // not needed for this test
 }
 
@@ -121,11 +183,13 @@ func TestTransformationPipelineEvaluation(t *testing.T) {
 		name                               string
 		username                           string
 		groups                             []string
+		authContext                        *AuthenticationContext
 		transforms                         []IdentityTransformation
 		wantUsername                       string
 		wantGroups                         []string
 		wantAuthenticationAllowed          bool
 		wantRejectionAuthenticationMessage string
+		wantAdditionalClaims               map[string]any
 		wantError                          string
 	}{
 		{
@@ -294,6 +358,94 @@ func TestTransformationPipelineEvaluation(t *testing.T) {
 			// since no transformations run, this will be empty string
 			wantRejectionAuthenticationMessage: "",
 		},
+		{
+			name: "the authentication context is passed to each transformation",
+			transforms: []IdentityTransformation{
+				fakeRequireMFATransformer{},
+				fakeAppendStringTransformer{},
+			},
+			username:                           "foo",
+			groups:                             []string{"bar"},
+			authContext:                        &AuthenticationContext{ACR: "some-acr", AMR: []string{"pwd", "mfa"}},
+			wantUsername:                       "foo:transformed",
+			wantGroups:                         []string{"bar:transformed"},
+			wantAuthenticationAllowed:          true,
+			wantRejectionAuthenticationMessage: "none",
+		},
+		{
+			name: "the upstream data and the request are passed to each transformation",
+			transforms: []IdentityTransformation{
+				fakeRequireVerifiedEmailFromClientTransformer{},
+				fakeAppendStringTransformer{},
+			},
+			username: "foo",
+			groups:   []string{"bar"},
+			authContext: &AuthenticationContext{
+				Upstream: map[string]any{"email_verified": true},
+				Request:  RequestContext{ClientID: "some-client", Scopes: []string{"openid"}},
+			},
+			wantUsername:                       "foo:transformed",
+			wantGroups:                         []string{"bar:transformed"},
+			wantAuthenticationAllowed:          true,
+			wantRejectionAuthenticationMessage: "none",
+		},
+		{
+			name: "the upstream data and the request can cause a transformation to reject the authentication",
+			transforms: []IdentityTransformation{
+				fakeRequireVerifiedEmailFromClientTransformer{},
+			},
+			username: "foo",
+			groups:   []string{"bar"},
+			authContext: &AuthenticationContext{
+				Upstream: map[string]any{"email_verified": true},
+				Request:  RequestContext{ClientID: "some-other-client"},
+			},
+			wantUsername:                       "foo",
+			wantGroups:                         []string{"bar"},
+			wantAuthenticationAllowed:          false,
+			wantRejectionAuthenticationMessage: "verified email required for client some-other-client",
+		},
+		{
+			name: "a nil authentication context is treated as empty",
+			transforms: []IdentityTransformation{
+				fakeRequireMFATransformer{},
+			},
+			username:                           "foo",
+			groups:                             []string{"bar"},
+			wantUsername:                       "foo",
+			wantGroups:                         []string{"bar"},
+			wantAuthenticationAllowed:          false,
+			wantRejectionAuthenticationMessage: "mfa required for ",
+		},
+		{
+			name: "additional claims from all transformations are merged, with later claims replacing earlier claims",
+			transforms: []IdentityTransformation{
+				fakeAddClaimsTransformer{claims: map[string]any{"a": "first", "b": "first"}},
+				fakeAppendStringTransformer{},
+				fakeAddClaimsTransformer{claims: map[string]any{"b": "second", "c": []any{"x"}}},
+				fakeAddClaimsTransformer{claims: nil},
+			},
+			username:                           "foo",
+			groups:                             []string{"bar"},
+			wantUsername:                       "foo:transformed",
+			wantGroups:                         []string{"bar:transformed"},
+			wantAuthenticationAllowed:          true,
+			wantAdditionalClaims:               map[string]any{"a": "first", "b": "second", "c": []any{"x"}},
+			wantRejectionAuthenticationMessage: "",
+		},
+		{
+			name: "additional claims are nil when no transformation returns any claims",
+			transforms: []IdentityTransformation{
+				fakeAddClaimsTransformer{claims: map[string]any{}},
+				fakeNoopTransformer{},
+			},
+			username:                           "foo",
+			groups:                             []string{"bar"},
+			wantUsername:                       "foo",
+			wantGroups:                         []string{"bar"},
+			wantAuthenticationAllowed:          true,
+			wantRejectionAuthenticationMessage: "none",
+		},
 	}
 
 	for _, tt := range tests {
@@ -306,7 +458,7 @@ func TestTransformationPipelineEvaluation(t *testing.T) {
 				pipeline.AppendTransformation(transform)
 			}
 
-			result, err := pipeline.Evaluate(context.Background(), tt.username, tt.groups)
+			result, err := pipeline.Evaluate(context.Background(), tt.username, tt.groups, tt.authContext)
 
 			if tt.wantError != "" {
 				require.EqualError(t, err, tt.wantError)
@@ -319,6 +471,7 @@ func TestTransformationPipelineEvaluation(t *testing.T) {
 			require.Equal(t, tt.wantGroups, result.Groups)
 			require.Equal(t, tt.wantAuthenticationAllowed, result.AuthenticationAllowed)
 			require.Equal(t, tt.wantRejectionAuthenticationMessage, result.RejectedAuthenticationMessage)
+			require.Equal(t, tt.wantAdditionalClaims, result.AdditionalClaims)
 		})
 	}
 }
@@ -337,3 +490,275 @@ func TestTransformationSource(t *testing.T) {
 	require.Equal(t, []any{"foo", "bar", "baz"}, pipeline.Source())
 	require.NotEqual(t, []any{"foo", "something-else", "baz"}, pipeline.Source())
 }
+
+func TestAppendPipeline(t *testing.T) {
+	library := NewTransformationPipeline()
+	library.AppendTransformation(&fakeTransformerWithSource{source: "foo"})
+	library.AppendTransformation(&fakeTransformerWithSource{source: "bar"})
+
+	pipeline := NewTransformationPipeline()
+	pipeline.AppendPipeline(library)
+	pipeline.AppendPipeline(NewTransformationPipeline())
+	pipeline.AppendTransformation(&fakeTransformerWithSource{source: "baz"})
+
+	require.Equal(t, []any{"foo", "bar", "baz"}, pipeline.Source())
+	require.Equal(t, []any{"foo", "bar"}, library.Source(), "the appended pipeline should not be changed")
+}
+
+func TestTransformationPipelineEvaluationWithSteps(t *testing.T) {
+	tests := []struct {
+		name         string
+		transforms   []IdentityTransformation
+		wantResult   *TransformationResult
+		wantSteps    []*TransformationStep
+		wantErrorMsg string
+	}{
+		{
+			name:       "no transformations",
+			transforms: []IdentityTransformation{},
+			wantResult: &TransformationResult{Username: "foo", Groups: []string{"a", "b"}, AuthenticationAllowed: true},
+			wantSteps:  []*TransformationStep{},
+		},
+		{
+			name: "each transformation is a step, and the final result sorts and dedups the groups",
+			transforms: []IdentityTransformation{
+				&fakeAppendStringTransformer{},
+				&fakeAddClaimsTransformer{claims: map[string]any{"dept": "eng"}},
+				&fakeAppendStringTransformer{},
+			},
+			wantResult: &TransformationResult{
+				Username:                      "foo:transformed:transformed",
+				Groups:                        []string{"a:transformed:transformed", "b:transformed:transformed"},
+				AuthenticationAllowed:         true,
+				RejectedAuthenticationMessage: "none",
+				AdditionalClaims:              map[string]any{"dept": "eng"},
+			},
+			wantSteps: []*TransformationStep{
+				{Index: 0, Result: &TransformationResult{
+					Username: "foo:transformed", Groups: []string{"b:transformed", "a:transformed", "b:transformed"},
+					AuthenticationAllowed: true, RejectedAuthenticationMessage: "none",
+				}},
+				{Index: 1, Result: &TransformationResult{
+					Username: "foo:transformed", Groups: []string{"b:transformed", "a:transformed", "b:transformed"},
+					AuthenticationAllowed: true, AdditionalClaims: map[string]any{"dept": "eng"},
+				}},
+				{Index: 2, Result: &TransformationResult{
+					Username: "foo:transformed:transformed", Groups: []string{"b:transformed:transformed", "a:transformed:transformed", "b:transformed:transformed"},
+					AuthenticationAllowed: true, RejectedAuthenticationMessage: "none",
+				}},
+			},
+		},
+		{
+			name: "the steps stop at a rejection",
+			transforms: []IdentityTransformation{
+				&fakeNoopTransformer{},
+				&fakeAuthenticationDisallowedTransformer{},
+				&fakeAppendStringTransformer{},
+			},
+			wantResult: &TransformationResult{
+				Username:                      "foo:disallowed",
+				Groups:                        []string{"b:disallowed", "a:disallowed", "b:disallowed"},
+				AuthenticationAllowed:         false,
+				RejectedAuthenticationMessage: "no authentication is allowed",
+			},
+			wantSteps: []*TransformationStep{
+				{Index: 0, Result: &TransformationResult{
+					Username: "foo", Groups: []string{"b", "a", "b"},
+					AuthenticationAllowed: true, RejectedAuthenticationMessage: "none",
+				}},
+				{Index: 1, Result: &TransformationResult{
+					Username: "foo:disallowed", Groups: []string{"b:disallowed", "a:disallowed", "b:disallowed"},
+					AuthenticationAllowed: false, RejectedAuthenticationMessage: "no authentication is allowed",
+				}},
+			},
+		},
+		{
+			name: "the steps before an error are returned, including the step which returned an invalid result",
+			transforms: []IdentityTransformation{
+				&fakeNoopTransformer{},
+				&fakeDeleteUsernameAndGroupsTransformer{},
+				&fakeAppendStringTransformer{},
+			},
+			wantErrorMsg: "identity transformation returned an empty username, which is not allowed",
+			wantSteps: []*TransformationStep{
+				{Index: 0, Result: &TransformationResult{
+					Username: "foo", Groups: []string{"b", "a", "b"},
+					AuthenticationAllowed: true, RejectedAuthenticationMessage: "none",
+				}},
+				{Index: 1, Result: &TransformationResult{
+					Username: "", Groups: []string{},
+					AuthenticationAllowed: true, RejectedAuthenticationMessage: "none",
+				}},
+			},
+		},
+		{
+			name: "the step which had an unexpected error is not returned",
+			transforms: []IdentityTransformation{
+				&fakeNoopTransformer{},
+				&fakeErrorTransformer{},
+			},
+			wantErrorMsg: "identity transformation at index 1: unexpected catastrophic error",
+			wantSteps: []*TransformationStep{
+				{Index: 0, Result: &TransformationResult{
+					Username: "foo", Groups: []string{"b", "a", "b"},
+					AuthenticationAllowed: true, RejectedAuthenticationMessage: "none",
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewTransformationPipeline()
+			for _, transform := range tt.transforms {
+				p.AppendTransformation(transform)
+			}
+
+			groups := []string{"b", "a", "b"}
+			result, steps, err := p.EvaluateWithSteps(context.Background(), "foo", groups, nil)
+			if tt.wantErrorMsg != "" {
+				require.EqualError(t, err, tt.wantErrorMsg)
+				require.Nil(t, result)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.wantResult, result)
+			}
+			require.Equal(t, tt.wantSteps, steps)
+
+			// Evaluate should always agree with EvaluateWithSteps.
+			evaluateResult, evaluateErr := p.Evaluate(context.Background(), "foo", groups, nil)
+			require.Equal(t, result, evaluateResult)
+			require.Equal(t, err, evaluateErr)
+		})
+	}
+}
+
+func TestTokenExchangePipelinesEvaluation(t *testing.T) {
+	newPipeline := func(transforms ...IdentityTransformation) *TransformationPipeline {
+		p := NewTransformationPipeline()
+		for _, transform := range transforms {
+			p.AppendTransformation(transform)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name                               string
+		pipelines                          TokenExchangePipelines
+		requestedAudience                  string
+		clientID                           string
+		username                           string
+		groups                             []string
+		wantUsername                       string
+		wantGroups                         []string
+		wantAuthenticationAllowed          bool
+		wantRejectionAuthenticationMessage string
+		wantAdditionalClaims               map[string]any
+		wantError                          string
+	}{
+		{
+			name:                      "no pipelines leaves the identity unchanged",
+			pipelines:                 nil,
+			requestedAudience:         "some-cluster",
+			clientID:                  "some-client",
+			username:                  "foo",
+			groups:                    []string{"bar"},
+			wantUsername:              "foo",
+			wantGroups:                []string{"bar"},
+			wantAuthenticationAllowed: true,
+		},
+		{
+			name:                      "nil groups are converted to an empty list when no pipelines match",
+			pipelines:                 nil,
+			requestedAudience:         "some-cluster",
+			clientID:                  "some-client",
+			username:                  "foo",
+			groups:                    nil,
+			wantUsername:              "foo",
+			wantGroups:                []string{},
+			wantAuthenticationAllowed: true,
+		},
+		{
+			name: "only the pipelines which match the requested audience and client are evaluated, in order",
+			pipelines: TokenExchangePipelines{
+				NewTokenExchangePipeline([]string{"other-cluster"}, nil, newPipeline(fakeAuthenticationDisallowedTransformer{})),
+				NewTokenExchangePipeline([]string{"some-cluster", "other-cluster"}, nil, newPipeline(fakeAppendStringTransformer{})),
+				NewTokenExchangePipeline(nil, []string{"other-client"}, newPipeline(fakeAuthenticationDisallowedTransformer{})),
+				NewTokenExchangePipeline(nil, []string{"some-client"}, newPipeline(fakeAppendStringTransformer{})),
+				NewTokenExchangePipeline([]string{"some-cluster"}, []string{"other-client"}, newPipeline(fakeAuthenticationDisallowedTransformer{})),
+				NewTokenExchangePipeline(nil, nil, newPipeline(fakeAddClaimsTransformer{claims: map[string]any{"a": "b"}})),
+			},
+			requestedAudience:         "some-cluster",
+			clientID:                  "some-client",
+			username:                  "foo",
+			groups:                    []string{"bar"},
+			wantUsername:              "foo:transformed:transformed",
+			wantGroups:                []string{"bar:transformed:transformed"},
+			wantAuthenticationAllowed: true,
+			wantAdditionalClaims:      map[string]any{"a": "b"},
+		},
+		{
+			name: "additional claims from later pipelines replace the claims from earlier pipelines",
+			pipelines: TokenExchangePipelines{
+				NewTokenExchangePipeline(nil, nil, newPipeline(fakeAddClaimsTransformer{claims: map[string]any{"a": "b", "c": "d"}})),
+				NewTokenExchangePipeline([]string{"some-cluster"}, nil, newPipeline(fakeAddClaimsTransformer{claims: map[string]any{"a": "e"}})),
+			},
+			requestedAudience:         "some-cluster",
+			clientID:                  "some-client",
+			username:                  "foo",
+			groups:                    []string{"bar"},
+			wantUsername:              "foo",
+			wantGroups:                []string{"bar"},
+			wantAuthenticationAllowed: true,
+			wantAdditionalClaims:      map[string]any{"a": "e", "c": "d"},
+		},
+		{
+			name: "a matching pipeline which rejects the authentication stops the evaluation",
+			pipelines: TokenExchangePipelines{
+				NewTokenExchangePipeline(nil, []string{"some-client"}, newPipeline(fakeAuthenticationDisallowedTransformer{})),
+				NewTokenExchangePipeline(nil, nil, newPipeline(fakeErrorTransformer{})),
+			},
+			requestedAudience:                  "some-cluster",
+			clientID:                           "some-client",
+			username:                           "foo",
+			groups:                             []string{"bar"},
+			wantUsername:                       "foo:disallowed",
+			wantGroups:                         []string{"bar:disallowed"},
+			wantAuthenticationAllowed:          false,
+			wantRejectionAuthenticationMessage: "no authentication is allowed",
+		},
+		{
+			name: "a matching pipeline which returns an error",
+			pipelines: TokenExchangePipelines{
+				NewTokenExchangePipeline([]string{"other-cluster"}, nil, newPipeline(fakeNoopTransformer{})),
+				NewTokenExchangePipeline([]string{"some-cluster"}, nil, newPipeline(fakeNoopTransformer{}, fakeErrorTransformer{})),
+			},
+			requestedAudience: "some-cluster",
+			clientID:          "some-client",
+			username:          "foo",
+			groups:            []string{"bar"},
+			wantError:         "token exchange transformation pipeline at index 1: identity transformation at index 1: unexpected catastrophic error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := tt.pipelines.Evaluate(context.Background(), tt.requestedAudience, tt.clientID, tt.username, tt.groups, nil)
+
+			if tt.wantError != "" {
+				require.EqualError(t, err, tt.wantError)
+				require.Nil(t, result)
+				return
+			}
+
+			require.NoError(t, err, "got an unexpected evaluation error")
+			require.Equal(t, tt.wantUsername, result.Username)
+			require.Equal(t, tt.wantGroups, result.Groups)
+			require.Equal(t, tt.wantAuthenticationAllowed, result.AuthenticationAllowed)
+			require.Equal(t, tt.wantRejectionAuthenticationMessage, result.RejectedAuthenticationMessage)
+			require.Equal(t, tt.wantAdditionalClaims, result.AdditionalClaims)
+		})
+	}
+}
@@ -3,11 +3,11 @@
 //
 
 // Code generated by MockGen. DO NOT EDIT.
-// Source: go.pinniped.dev/internal/githubclient (interfaces: GitHubInterface)
+// Source: go.pinniped.dev/internal/githubclient (interfaces: GitHubInterface,GitHubAppInterface)
 //
 // Generated by this command:
 //
-//	mockgen -destination=mockgithubclient.go -package=mockgithubclient -copyright_file=../../../hack/header.txt go.pinniped.dev/internal/githubclient GitHubInterface
+//	mockgen -destination=mockgithubclient.go -package=mockgithubclient -copyright_file=../../../hack/header.txt go.pinniped.dev/internal/githubclient GitHubInterface,GitHubAppInterface
 //
 
 // Package mockgithubclient is a generated GoMock package.
@@ -61,6 +61,21 @@ func (mr *MockGitHubInterfaceMockRecorder) GetOrgMembership(ctx any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrgMembership", reflect.TypeOf((*MockGitHubInterface)(nil).GetOrgMembership), ctx)
 }
 
+// GetRepositoryRole mocks base method.
+func (m *MockGitHubInterface) GetRepositoryRole(ctx context.Context, owner, repo string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRepositoryRole", ctx, owner, repo)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRepositoryRole indicates an expected call of GetRepositoryRole.
+func (mr *MockGitHubInterfaceMockRecorder) GetRepositoryRole(ctx, owner, repo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepositoryRole", reflect.TypeOf((*MockGitHubInterface)(nil).GetRepositoryRole), ctx, owner, repo)
+}
+
 // GetTeamMembership mocks base method.
 func (m *MockGitHubInterface) GetTeamMembership(ctx context.Context, allowedOrganizations *setutil.CaseInsensitiveSet) ([]githubclient.TeamInfo, error) {
 	m.ctrl.T.Helper()
@@ -90,3 +105,42 @@ func (mr *MockGitHubInterfaceMockRecorder) GetUserInfo(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserInfo", reflect.TypeOf((*MockGitHubInterface)(nil).GetUserInfo), ctx)
 }
+
+// MockGitHubAppInterface is a mock of GitHubAppInterface interface.
+type MockGitHubAppInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockGitHubAppInterfaceMockRecorder
+	isgomock struct{}
+}
+
+// MockGitHubAppInterfaceMockRecorder is the mock recorder for MockGitHubAppInterface.
+type MockGitHubAppInterfaceMockRecorder struct {
+	mock *MockGitHubAppInterface
+}
+
+// NewMockGitHubAppInterface creates a new mock instance.
+func NewMockGitHubAppInterface(ctrl *gomock.Controller) *MockGitHubAppInterface {
+	mock := &MockGitHubAppInterface{ctrl: ctrl}
+	mock.recorder = &MockGitHubAppInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGitHubAppInterface) EXPECT() *MockGitHubAppInterfaceMockRecorder {
+	return m.recorder
+}
+
+// GetRepositoryRoleForUser mocks base method.
+func (m *MockGitHubAppInterface) GetRepositoryRoleForUser(ctx context.Context, owner, repo, login string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRepositoryRoleForUser", ctx, owner, repo, login)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRepositoryRoleForUser indicates an expected call of GetRepositoryRoleForUser.
+func (mr *MockGitHubAppInterfaceMockRecorder) GetRepositoryRoleForUser(ctx, owner, repo, login any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepositoryRoleForUser", reflect.TypeOf((*MockGitHubAppInterface)(nil).GetRepositoryRoleForUser), ctx, owner, repo, login)
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 //
 
@@ -235,6 +235,21 @@ func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) PasswordCredentialsGran
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PasswordCredentialsGrantAndValidateTokens", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).PasswordCredentialsGrantAndValidateTokens), ctx, username, password)
 }
 
+// PrepareAuthorizationRequest mocks base method.
+func (m *MockUpstreamOIDCIdentityProviderI) PrepareAuthorizationRequest(ctx context.Context, params url.Values) (url.Values, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrepareAuthorizationRequest", ctx, params)
+	ret0, _ := ret[0].(url.Values)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PrepareAuthorizationRequest indicates an expected call of PrepareAuthorizationRequest.
+func (mr *MockUpstreamOIDCIdentityProviderIMockRecorder) PrepareAuthorizationRequest(ctx, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrepareAuthorizationRequest", reflect.TypeOf((*MockUpstreamOIDCIdentityProviderI)(nil).PrepareAuthorizationRequest), ctx, params)
+}
+
 // PerformRefresh mocks base method.
 func (m *MockUpstreamOIDCIdentityProviderI) PerformRefresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
 	m.ctrl.T.Helper()
@@ -1,9 +1,10 @@
-// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package phttp
 
 import (
+	"crypto/tls"
 	"crypto/x509"
 	"net/http"
 	"time"
@@ -20,6 +21,16 @@ func Default(rootCAs *x509.CertPool) *http.Client {
 	return buildClient(ptls.Default, rootCAs)
 }
 
+// DefaultWithClientCertificates is like Default, but the returned client also presents the given client
+// certificates to servers which request them, e.g. for mutual TLS.
+func DefaultWithClientCertificates(rootCAs *x509.CertPool, certificates []tls.Certificate) *http.Client {
+	return buildClient(func(rootCAs *x509.CertPool) *tls.Config {
+		c := ptls.Default(rootCAs)
+		c.Certificates = certificates
+		return c
+	}, rootCAs)
+}
+
 func Secure(rootCAs *x509.CertPool) *http.Client {
 	return buildClient(ptls.Secure, rootCAs)
 }
@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package phttp
@@ -24,14 +24,24 @@ import (
 func TestUnwrap(t *testing.T) {
 	t.Parallel()
 
+	testCertificates := []tls.Certificate{{Certificate: [][]byte{[]byte("some-certificate")}}}
+
 	tests := []struct {
-		name string
-		f    func(*x509.CertPool) *http.Client
+		name             string
+		f                func(*x509.CertPool) *http.Client
+		wantCertificates []tls.Certificate
 	}{
 		{
 			name: "default",
 			f:    Default,
 		},
+		{
+			name: "default with client certificates",
+			f: func(rootCAs *x509.CertPool) *http.Client {
+				return DefaultWithClientCertificates(rootCAs, testCertificates)
+			},
+			wantCertificates: testCertificates,
+		},
 		{
 			name: "secure",
 			f:    Secure,
@@ -53,6 +63,7 @@ func TestUnwrap(t *testing.T) {
 			require.NotEmpty(t, tlsConfig.NextProtos)
 			require.GreaterOrEqual(t, tlsConfig.MinVersion, uint16(tls.VersionTLS12))
 			require.Equal(t, p, tlsConfig.RootCAs)
+			require.Equal(t, tt.wantCertificates, tlsConfig.Certificates)
 		})
 	}
 }
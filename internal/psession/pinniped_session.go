@@ -1,10 +1,11 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package psession
 
 import (
 	"maps"
+	"slices"
 	"time"
 
 	"github.com/mohae/deepcopy"
@@ -45,6 +46,18 @@ type CustomSessionData struct {
 	// refresh, and when the LDAP search was configured to skip group refreshes.
 	UpstreamGroups []string `json:"upstreamGroups"`
 
+	// UpstreamData is the raw identity data from the upstream identity provider during the user's initial login or
+	// most recent refresh, which is made available to identity transformations. We store this so that we can still
+	// reapply identity transformations during refresh flows even when the upstream identity provider does not return
+	// this data again during the upstream refresh.
+	UpstreamData map[string]any `json:"upstreamData,omitempty"`
+
+	// TransformedClaimNames are the names of the downstream additional claims which were returned by the identity
+	// transformations, as opposed to the additional claims which were mapped from the upstream identity provider.
+	// We store this so that we can replace those claims with the newly transformed claims during refresh flows,
+	// even when the upstream identity provider does not return its additional claims again during the upstream refresh.
+	TransformedClaimNames []string `json:"transformedClaimNames,omitempty"`
+
 	// The Kubernetes resource UID of the identity provider CRD for the upstream IDP used to start this session.
 	// This should be validated again upon downstream refresh to make sure that we are not refreshing against
 	// a different identity provider CRD which just happens to have the same name.
@@ -77,6 +90,15 @@ type CustomSessionData struct {
 
 	// Only used when ProviderType == "github".
 	GitHub *GitHubSessionData `json:"github,omitempty"`
+
+	// Only used when ProviderType == "saml".
+	SAML *SAMLSessionData `json:"saml,omitempty"`
+
+	// Only used when ProviderType == "oauth2".
+	OAuth2 *OAuth2SessionData `json:"oauth2,omitempty"`
+
+	// Only used when ProviderType == "localuser".
+	LocalUser *LocalUserSessionData `json:"localuser,omitempty"`
 }
 
 type ProviderType string
@@ -86,6 +108,9 @@ const (
 	ProviderTypeLDAP            ProviderType = "ldap"
 	ProviderTypeActiveDirectory ProviderType = "activedirectory"
 	ProviderTypeGitHub          ProviderType = "github"
+	ProviderTypeSAML            ProviderType = "saml"
+	ProviderTypeOAuth2          ProviderType = "oauth2"
+	ProviderTypeLocalUser       ProviderType = "localuser"
 )
 
 // OIDCSessionData is the additional data needed by Pinniped when the upstream IDP is an OIDC provider.
@@ -122,12 +147,17 @@ func (s *OIDCSessionData) Clone() *OIDCSessionData {
 type LDAPSessionData struct {
 	UserDN                 string            `json:"userDN"`
 	ExtraRefreshAttributes map[string]string `json:"extraRefreshAttributes,omitempty"`
+
+	// AdditionalClaims are the values of the LDAP attributes which were mapped to additional downstream claims,
+	// keyed by the name of the downstream claim. They are updated during each upstream refresh.
+	AdditionalClaims map[string][]string `json:"additionalClaims,omitempty"`
 }
 
 func (s *LDAPSessionData) Clone() *LDAPSessionData {
 	return &LDAPSessionData{
 		UserDN:                 s.UserDN,
 		ExtraRefreshAttributes: maps.Clone(s.ExtraRefreshAttributes), // shallow copy works because all keys and values are strings
+		AdditionalClaims:       cloneAdditionalClaims(s.AdditionalClaims),
 	}
 }
 
@@ -135,13 +165,29 @@ func (s *LDAPSessionData) Clone() *LDAPSessionData {
 type ActiveDirectorySessionData struct {
 	UserDN                 string            `json:"userDN"`
 	ExtraRefreshAttributes map[string]string `json:"extraRefreshAttributes,omitempty"`
+
+	// AdditionalClaims are the values of the Active Directory attributes which were mapped to additional downstream claims,
+	// keyed by the name of the downstream claim. They are updated during each upstream refresh.
+	AdditionalClaims map[string][]string `json:"additionalClaims,omitempty"`
 }
 
 func (s *ActiveDirectorySessionData) Clone() *ActiveDirectorySessionData {
 	return &ActiveDirectorySessionData{
 		UserDN:                 s.UserDN,
 		ExtraRefreshAttributes: maps.Clone(s.ExtraRefreshAttributes), // shallow copy works because all keys and values are strings
+		AdditionalClaims:       cloneAdditionalClaims(s.AdditionalClaims),
+	}
+}
+
+func cloneAdditionalClaims(additionalClaims map[string][]string) map[string][]string {
+	if additionalClaims == nil {
+		return nil
+	}
+	cloned := make(map[string][]string, len(additionalClaims))
+	for claimName, values := range additionalClaims {
+		cloned[claimName] = slices.Clone(values)
 	}
+	return cloned
 }
 
 type GitHubSessionData struct {
@@ -153,6 +199,58 @@ func (s *GitHubSessionData) Clone() *GitHubSessionData {
 	return &dataCopy
 }
 
+// SAMLSessionData is the additional data needed by Pinniped when the upstream IDP is a SAML provider.
+type SAMLSessionData struct {
+	// UpstreamIssuer is the Issuer of the assertion from the user's initial login.
+	UpstreamIssuer string `json:"upstreamIssuer"`
+
+	// UpstreamNameID is the NameID of the assertion's subject from the user's initial login.
+	UpstreamNameID string `json:"upstreamNameID"`
+
+	// SessionNotOnOrAfter is the time after which the upstream SAML provider requires the user to
+	// authenticate again, as given by the SessionNotOnOrAfter of the assertion's AuthnStatement.
+	// When the assertion did not specify it, then this field is the zero time.
+	SessionNotOnOrAfter time.Time `json:"sessionNotOnOrAfter"`
+}
+
+func (s *SAMLSessionData) Clone() *SAMLSessionData {
+	dataCopy := *s // this shortcut works because all fields in this type are currently values (no pointers)
+	return &dataCopy
+}
+
+// OAuth2SessionData is the additional data needed by Pinniped when the upstream IDP is a generic OAuth2 provider.
+type OAuth2SessionData struct {
+	// UpstreamRefreshToken will contain the refresh token from the upstream OAuth2 provider, if the upstream provider
+	// returned a refresh token during initial authorization. Otherwise, this field should be empty
+	// and the UpstreamAccessToken should be non-empty.
+	UpstreamRefreshToken string `json:"upstreamRefreshToken"`
+
+	// UpstreamAccessToken will contain the access token returned by the upstream OAuth2 provider during initial
+	// authorization, but only when the provider did not also return a refresh token.
+	UpstreamAccessToken string `json:"upstreamAccessToken"`
+
+	// UpstreamSubject is the result of the subject expression from the user's initial login. We store this
+	// so that we can validate that it does not change upon refresh.
+	UpstreamSubject string `json:"upstreamSubject"`
+}
+
+func (s *OAuth2SessionData) Clone() *OAuth2SessionData {
+	dataCopy := *s // this shortcut works because all fields in this type are currently strings (no pointers)
+	return &dataCopy
+}
+
+// LocalUserSessionData is the additional data needed by Pinniped when the upstream IDP is a LocalUserIdentityProvider.
+type LocalUserSessionData struct {
+	// UserUID is the UID of the Secret which defined the user at the time of their initial login. We store this
+	// so that we can validate during refresh that the user has not been deleted and recreated.
+	UserUID string `json:"userUID"`
+}
+
+func (s *LocalUserSessionData) Clone() *LocalUserSessionData {
+	dataCopy := *s // this shortcut works because all fields in this type are currently strings (no pointers)
+	return &dataCopy
+}
+
 // NewPinnipedSession returns a new empty session.
 func NewPinnipedSession() *PinnipedSession {
 	return &PinnipedSession{
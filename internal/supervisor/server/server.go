@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package server defines the entrypoint for the Pinniped Supervisor server.
@@ -54,8 +54,12 @@ import (
 	"go.pinniped.dev/internal/controller/supervisorconfig/generator"
 	"go.pinniped.dev/internal/controller/supervisorconfig/githubupstreamwatcher"
 	"go.pinniped.dev/internal/controller/supervisorconfig/ldapupstreamwatcher"
+	"go.pinniped.dev/internal/controller/supervisorconfig/localuserupstreamwatcher"
+	"go.pinniped.dev/internal/controller/supervisorconfig/oauth2upstreamwatcher"
 	"go.pinniped.dev/internal/controller/supervisorconfig/oidcclientwatcher"
 	"go.pinniped.dev/internal/controller/supervisorconfig/oidcupstreamwatcher"
+	"go.pinniped.dev/internal/controller/supervisorconfig/samlupstreamwatcher"
+	"go.pinniped.dev/internal/controller/supervisorconfig/upstreamhealthprober"
 	"go.pinniped.dev/internal/controller/supervisorstorage"
 	"go.pinniped.dev/internal/controllerinit"
 	"go.pinniped.dev/internal/controllerlib"
@@ -67,6 +71,7 @@ import (
 	"go.pinniped.dev/internal/federationdomain/dynamicupstreamprovider"
 	"go.pinniped.dev/internal/federationdomain/endpoints/jwks"
 	"go.pinniped.dev/internal/federationdomain/endpointsmanager"
+	"go.pinniped.dev/internal/federationdomain/upstreamhealth"
 	"go.pinniped.dev/internal/groupsuffix"
 	"go.pinniped.dev/internal/kubeclient"
 	"go.pinniped.dev/internal/leaderelection"
@@ -139,6 +144,7 @@ func prepareControllers(
 	dynamicJWKSProvider jwks.DynamicJWKSProvider,
 	dynamicTLSCertProvider dynamictlscertprovider.DynamicTLSCertProvider,
 	dynamicUpstreamIDPProvider dynamicupstreamprovider.DynamicUpstreamIDPProvider,
+	upstreamHealth *upstreamhealth.Tracker,
 	dynamicServingCertProvider dynamiccert.Private,
 	secretCache *secret.Cache,
 	supervisorDeployment *appsv1.Deployment,
@@ -179,10 +185,14 @@ func prepareControllers(
 				clock.RealClock{},
 				pinnipedClient,
 				federationDomainInformer,
+				pinnipedInformers.Config().V1alpha1().IdentityTransformLibraries(),
 				pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
 				pinnipedInformers.IDP().V1alpha1().LDAPIdentityProviders(),
 				pinnipedInformers.IDP().V1alpha1().ActiveDirectoryIdentityProviders(),
 				pinnipedInformers.IDP().V1alpha1().GitHubIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().SAMLIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().OAuth2IdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().LocalUserIdentityProviders(),
 				controllerlib.WithInformer,
 			),
 			singletonWorker,
@@ -348,6 +358,55 @@ func prepareControllers(
 				cache.NewExpiring(),
 			),
 			singletonWorker).
+		WithController(
+			samlupstreamwatcher.New(
+				dynamicUpstreamIDPProvider,
+				pinnipedClient,
+				pinnipedInformers.IDP().V1alpha1().SAMLIdentityProviders(),
+				secretInformer,
+				configMapInformer,
+				plog.New(),
+				controllerlib.WithInformer,
+				cache.NewExpiring(),
+			),
+			singletonWorker).
+		WithController(
+			oauth2upstreamwatcher.New(
+				dynamicUpstreamIDPProvider,
+				pinnipedClient,
+				pinnipedInformers.IDP().V1alpha1().OAuth2IdentityProviders(),
+				secretInformer,
+				configMapInformer,
+				plog.New(),
+				controllerlib.WithInformer,
+			),
+			singletonWorker).
+		WithController(
+			localuserupstreamwatcher.New(
+				dynamicUpstreamIDPProvider,
+				pinnipedClient,
+				pinnipedInformers.IDP().V1alpha1().LocalUserIdentityProviders(),
+				secretInformer,
+				clock.RealClock{},
+				plog.New(),
+				controllerlib.WithInformer,
+			),
+			singletonWorker).
+		WithController(
+			upstreamhealthprober.New(
+				dynamicUpstreamIDPProvider,
+				upstreamHealth,
+				pinnipedClient,
+				pinnipedInformers.IDP().V1alpha1().OIDCIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().LDAPIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().ActiveDirectoryIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().GitHubIdentityProviders(),
+				pinnipedInformers.IDP().V1alpha1().OAuth2IdentityProviders(),
+				clock.RealClock{},
+				plog.New(),
+				controllerlib.WithInformer,
+			),
+			singletonWorker).
 		WithController(
 			apicerts.NewCertsManagerController(
 				podInfo.Namespace,
@@ -479,6 +538,7 @@ func runSupervisor(ctx context.Context, podInfo *downward.PodInfo, cfg *supervis
 	dynamicJWKSProvider := jwks.NewDynamicJWKSProvider()
 	dynamicTLSCertProvider := dynamictlscertprovider.NewDynamicTLSCertProvider()
 	dynamicUpstreamIDPProvider := dynamicupstreamprovider.NewDynamicUpstreamIDPProvider()
+	upstreamHealth := upstreamhealth.NewTracker(clock.RealClock{})
 	secretCache := secret.Cache{}
 
 	// OIDC endpoints will be served by the endpoints manager, and any non-OIDC paths will fallback to the healthMux.
@@ -486,11 +546,13 @@ func runSupervisor(ctx context.Context, podInfo *downward.PodInfo, cfg *supervis
 		healthMux,
 		dynamicJWKSProvider,
 		dynamicUpstreamIDPProvider,
+		upstreamHealth,
 		&secretCache,
 		clientWithoutLeaderElection.Kubernetes.CoreV1().Secrets(serverInstallationNamespace), // writes to kube storage are allowed for non-leaders
 		client.PinnipedSupervisor.ConfigV1alpha1().OIDCClients(serverInstallationNamespace),
 		auditLogger,
 		cfg.Audit.LogInternalPaths,
+		cfg.Endpoints.MTLS.AdvertisedPort,
 	)
 
 	// Get the "real" name of the client secret supervisor API group (i.e., the API group name with the
@@ -503,6 +565,7 @@ func runSupervisor(ctx context.Context, podInfo *downward.PodInfo, cfg *supervis
 		dynamicJWKSProvider,
 		dynamicTLSCertProvider,
 		dynamicUpstreamIDPProvider,
+		upstreamHealth,
 		dynamicServingCertProvider,
 		&secretCache,
 		supervisorDeployment,
@@ -558,7 +621,7 @@ func runSupervisor(ctx context.Context, podInfo *downward.PodInfo, cfg *supervis
 		plog.Debug("supervisor http listener started", "address", httpListener.Addr().String())
 	}
 
-	if e := cfg.Endpoints.HTTPS; e.Network != supervisor.NetworkDisabled { //nolint:nestif
+	if e := cfg.Endpoints.HTTPS; e.Network != supervisor.NetworkDisabled {
 		finishSetupPerms := maybeSetupUnixPerms(e, supervisorPod)
 
 		bootstrapCert, err := getBootstrapCert() // generate this in-memory once per process startup
@@ -566,40 +629,7 @@ func runSupervisor(ctx context.Context, podInfo *downward.PodInfo, cfg *supervis
 			return fmt.Errorf("https listener bootstrap error: %w", err)
 		}
 
-		c := ptls.Default(nil)
-		c.GetCertificate = func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
-			cert := dynamicTLSCertProvider.GetTLSCert(strings.ToLower(info.ServerName))
-			foundServerNameCert := cert != nil
-
-			defaultCert := dynamicTLSCertProvider.GetDefaultTLSCert()
-
-			if !foundServerNameCert {
-				cert = defaultCert
-			}
-
-			// If we still don't have a cert for the request at this point, then using the bootstrapping cert,
-			// but in that case also set the request to fail unless it is a health check request.
-			usingBootstrapCert := false
-			if cert == nil {
-				usingBootstrapCert = true
-				setIsBootstrapConn(info.Context()) // make this connection only work for bootstrap requests
-				cert = bootstrapCert
-			}
-
-			// Emit logs visible at a higher level of logging than the default. Using Info level so the user
-			// can safely configure a production Supervisor to show this message if they choose.
-			plog.Info("choosing TLS cert for incoming request",
-				"requestSNIServerName", info.ServerName,
-				"foundCertForSNIServerNameFromFederationDomain", foundServerNameCert,
-				"foundDefaultCertFromSecret", defaultCert != nil,
-				"defaultCertSecretName", cfg.NamesConfig.DefaultTLSCertificateSecret,
-				"servingBootstrapHealthzCert", usingBootstrapCert,
-				"requestLocalAddr", info.Conn.LocalAddr().String(),
-				"requestRemoteAddr", info.Conn.RemoteAddr().String(),
-			)
-
-			return cert, nil
-		}
+		c := newHTTPSTLSConfig(bootstrapCert, dynamicTLSCertProvider, cfg.NamesConfig.DefaultTLSCertificateSecret)
 
 		httpsListener, err := tls.Listen(e.Network, e.Address, c)
 		if err != nil {
@@ -615,6 +645,36 @@ func runSupervisor(ctx context.Context, podInfo *downward.PodInfo, cfg *supervis
 		plog.Debug("supervisor https listener started", "address", httpsListener.Addr().String())
 	}
 
+	if e := cfg.Endpoints.MTLS; e.Network != supervisor.NetworkDisabled {
+		finishSetupPerms := maybeSetupUnixPerms(&e.Endpoint, supervisorPod)
+
+		bootstrapCert, err := getBootstrapCert() // generate this in-memory once per process startup
+		if err != nil {
+			return fmt.Errorf("mtls listener bootstrap error: %w", err)
+		}
+
+		c := newHTTPSTLSConfig(bootstrapCert, dynamicTLSCertProvider, cfg.NamesConfig.DefaultTLSCertificateSecret)
+
+		// Ask for, but do not require, a TLS client certificate. OIDCClients which use the tls_client_auth client
+		// authentication method present their certificates, which are verified by the client authentication code
+		// of the endpoints which authenticate clients. This is a separate listener so that browsers which connect
+		// to the HTTPS listener are never asked for a client certificate.
+		c.ClientAuth = tls.RequestClientCert
+
+		mtlsListener, err := tls.Listen(e.Network, e.Address, c)
+		if err != nil {
+			return fmt.Errorf("cannot create mtls listener with network %q and address %q: %w", e.Network, e.Address, err)
+		}
+
+		if err := finishSetupPerms(); err != nil {
+			return fmt.Errorf("cannot setup mtls listener permissions for network %q and address %q: %w", e.Network, e.Address, err)
+		}
+
+		defer func() { _ = mtlsListener.Close() }()
+		startServer(ctx, shutdown, mtlsListener, oidProvidersManager.HandlerChain())
+		plog.Debug("supervisor mtls listener started", "address", mtlsListener.Addr().String())
+	}
+
 	plog.Debug("supervisor started")
 	defer plog.Debug("supervisor exiting")
 
@@ -713,6 +773,50 @@ func getAggregatedAPIServerConfig(
 	return apiServerConfig, nil
 }
 
+// newHTTPSTLSConfig returns the TLS config of the listeners which serve the FederationDomain endpoints. The serving
+// certificate of each connection is chosen by the SNI server name of the request.
+func newHTTPSTLSConfig(
+	bootstrapCert *tls.Certificate,
+	dynamicTLSCertProvider dynamictlscertprovider.DynamicTLSCertProvider,
+	defaultTLSCertificateSecretName string,
+) *tls.Config {
+	c := ptls.Default(nil)
+	c.GetCertificate = func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert := dynamicTLSCertProvider.GetTLSCert(strings.ToLower(info.ServerName))
+		foundServerNameCert := cert != nil
+
+		defaultCert := dynamicTLSCertProvider.GetDefaultTLSCert()
+
+		if !foundServerNameCert {
+			cert = defaultCert
+		}
+
+		// If we still don't have a cert for the request at this point, then using the bootstrapping cert,
+		// but in that case also set the request to fail unless it is a health check request.
+		usingBootstrapCert := false
+		if cert == nil {
+			usingBootstrapCert = true
+			setIsBootstrapConn(info.Context()) // make this connection only work for bootstrap requests
+			cert = bootstrapCert
+		}
+
+		// Emit logs visible at a higher level of logging than the default. Using Info level so the user
+		// can safely configure a production Supervisor to show this message if they choose.
+		plog.Info("choosing TLS cert for incoming request",
+			"requestSNIServerName", info.ServerName,
+			"foundCertForSNIServerNameFromFederationDomain", foundServerNameCert,
+			"foundDefaultCertFromSecret", defaultCert != nil,
+			"defaultCertSecretName", defaultTLSCertificateSecretName,
+			"servingBootstrapHealthzCert", usingBootstrapCert,
+			"requestLocalAddr", info.Conn.LocalAddr().String(),
+			"requestRemoteAddr", info.Conn.RemoteAddr().String(),
+		)
+
+		return cert, nil
+	}
+	return c
+}
+
 func maybeSetupUnixPerms(endpoint *supervisor.Endpoint, pod *corev1.Pod) func() error {
 	if endpoint.Network != supervisor.NetworkUnix {
 		return func() error { return nil }
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package testutil
@@ -110,6 +110,18 @@ func RequireSecurityHeadersWithIDPChooserPageCSPs(t *testing.T, response *httpte
 	requireSecurityHeaders(t, response)
 }
 
+func RequireSecurityHeadersWithSAMLPostPageCSPs(t *testing.T, response *httptest.ResponseRecorder) {
+	// Loosely confirm that the unique CSPs needed for the SAML resubmit page were used.
+	cspHeader := response.Header().Get("Content-Security-Policy")
+	require.Contains(t, cspHeader, "script-src '")     // loose assertion
+	require.NotContains(t, cspHeader, "style-src")     // only needed by form_post and login pages
+	require.NotContains(t, cspHeader, "img-src data:") // only needed by form_post page
+	require.NotContains(t, cspHeader, "connect-src *") // only needed by form_post page
+
+	// Also require all the usual security headers.
+	requireSecurityHeaders(t, response)
+}
+
 func RequireSecurityHeadersWithoutCustomCSPs(t *testing.T, response *httptest.ResponseRecorder) {
 	// Confirm that the unique CSPs needed for the form_post or login page were NOT used.
 	cspHeader := response.Header().Get("Content-Security-Policy")
@@ -1,4 +1,4 @@
-// Copyright 2023 the Pinniped contributors. All Rights Reserved.
+// Copyright 2023-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package testutil
@@ -14,6 +14,7 @@ import (
 type ChooseIDPPageExpectedValue struct {
 	DisplayName string
 	URL         string
+	Unavailable bool
 }
 
 func spaces(howMany int) string {
@@ -29,6 +30,13 @@ func htmlEscapedForHTMLTemplate(s string) string {
 	return strings.ReplaceAll(html.EscapeString(s), "+", "&#43;")
 }
 
+func unavailableSuffix(wantIDP ChooseIDPPageExpectedValue) string {
+	if wantIDP.Unavailable {
+		return " (currently unavailable)"
+	}
+	return ""
+}
+
 func ExpectedChooseIDPPageHTML(wantCSS string, wantJS string, wantIDPs []ChooseIDPPageExpectedValue) string {
 	top := here.Docf(`
 		<!DOCTYPE html>
@@ -53,7 +61,7 @@ func ExpectedChooseIDPPageHTML(wantCSS string, wantJS string, wantIDPs []ChooseI
 		withNewline(spaces(12)+`<ul>`) +
 		withNewline(spaces(16))
 	for _, wantIDP := range wantIDPs {
-		noscript += withNewline(spaces(20)+`<li><a href="`+htmlEscapedForHTMLTemplate(wantIDP.URL)+`">`+htmlEscapedForHTMLTemplate(wantIDP.DisplayName)+`</a></li>`) +
+		noscript += withNewline(spaces(20)+`<li><a href="`+htmlEscapedForHTMLTemplate(wantIDP.URL)+`">`+htmlEscapedForHTMLTemplate(wantIDP.DisplayName)+unavailableSuffix(wantIDP)+`</a></li>`) +
 			withNewline(spaces(16))
 	}
 	noscript += withNewline(spaces(12)+`</ul>`) +
@@ -64,7 +72,7 @@ func ExpectedChooseIDPPageHTML(wantCSS string, wantJS string, wantIDPs []ChooseI
 		withNewline(spaces(8))
 	for _, wantIDP := range wantIDPs {
 		buttons += withNewline(spaces(12)+`<div class="form-field">`) +
-			withNewline(spaces(16)+`<button data-url="`+htmlEscapedForHTMLTemplate(wantIDP.URL)+`"><span>`+htmlEscapedForHTMLTemplate(wantIDP.DisplayName)+`</span></button>`) +
+			withNewline(spaces(16)+`<button data-url="`+htmlEscapedForHTMLTemplate(wantIDP.URL)+`"><span>`+htmlEscapedForHTMLTemplate(wantIDP.DisplayName)+unavailableSuffix(wantIDP)+`</span></button>`) +
 			withNewline(spaces(12)+`</div>`) +
 			withNewline(spaces(8))
 	}
@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package testutil
@@ -88,6 +88,34 @@ func FullyCapableOIDCClientAndStorageSecret(
 	return OIDCClientAndStorageSecret(t, namespace, clientID, clientUID, allGrantTypes, allScopes, redirectURI, tokenLifetimesIDTokenSeconds, hashes, validateFunc)
 }
 
+// ServiceOIDCClientAndStorageSecret returns an OIDC client which is allowed to use the client_credentials grant to act
+// as the specified service identity, and to perform RFC8693 token exchanges using the resulting tokens, along with a
+// corresponding client secret storage Secret.
+func ServiceOIDCClientAndStorageSecret(
+	t *testing.T,
+	namespace string,
+	clientID string,
+	clientUID string,
+	redirectURI string,
+	serviceIdentity *supervisorconfigv1alpha1.OIDCClientServiceIdentity,
+	hashes []string,
+	validateFunc OIDCClientValidatorFunc,
+) (*supervisorconfigv1alpha1.OIDCClient, *corev1.Secret) {
+	grantTypes := []supervisorconfigv1alpha1.GrantType{
+		"authorization_code", "client_credentials", "urn:ietf:params:oauth:grant-type:token-exchange",
+	}
+	scopes := []supervisorconfigv1alpha1.Scope{"openid", "pinniped:request-audience", "username", "groups"}
+
+	oidcClient := newOIDCClient(namespace, clientID, clientUID, redirectURI, grantTypes, scopes, nil)
+	oidcClient.Spec.ServiceIdentity = serviceIdentity
+	secret := OIDCClientSecretStorageSecretForUID(t, namespace, clientUID, hashes)
+
+	valid, conditions, _ := validateFunc(oidcClient, secret, bcrypt.MinCost)
+	require.True(t, valid, "Test's OIDCClient should have been valid. See conditions for errors: %s", conditions)
+
+	return oidcClient, secret
+}
+
 // OIDCClientAndStorageSecret returns an OIDC client which is allowed to use the specified grant types and scopes,
 // along with a corresponding client secret storage Secret. It also validates the client to make sure that the specified
 // combination of grant types and scopes is considered valid before returning the client.
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidctestutil
@@ -24,6 +24,7 @@ type ExpectedUpstreamStateParamFormat struct {
 	N string `json:"n"`
 	C string `json:"c"`
 	K string `json:"k"`
+	D string `json:"d,omitempty"`
 	V string `json:"v"`
 }
 
@@ -65,6 +66,11 @@ func (b *UpstreamStateParamBuilder) WithUpstreamIDPName(upstreamIDPName string)
 	return b
 }
 
+func (b *UpstreamStateParamBuilder) WithDeviceUserCodeSignature(signature string) *UpstreamStateParamBuilder {
+	b.D = signature
+	return b
+}
+
 func (b *UpstreamStateParamBuilder) WithStateVersion(version string) *UpstreamStateParamBuilder {
 	b.V = version
 	return b
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidctestutil
@@ -24,6 +24,7 @@ import (
 	"go.pinniped.dev/internal/fositestorage/openidconnect"
 	"go.pinniped.dev/internal/fositestorage/pkce"
 	"go.pinniped.dev/internal/fositestoragei"
+	"go.pinniped.dev/internal/idtransform"
 	"go.pinniped.dev/internal/psession"
 	"go.pinniped.dev/internal/testutil"
 )
@@ -47,6 +48,7 @@ func RequireAuthCodeRegexpMatch(
 	wantDownstreamRedirectURI string,
 	wantCustomSessionData *psession.CustomSessionData,
 	wantDownstreamAdditionalClaims map[string]any,
+	wantDownstreamAuthenticationContext *idtransform.AuthenticationContext,
 ) string {
 	t.Helper()
 
@@ -86,6 +88,7 @@ func RequireAuthCodeRegexpMatch(
 		wantDownstreamRedirectURI,
 		wantCustomSessionData,
 		wantDownstreamAdditionalClaims,
+		wantDownstreamAuthenticationContext,
 	)
 
 	// One PKCE should have been stored.
@@ -141,6 +144,7 @@ func validateAuthcodeStorage(
 	wantDownstreamRedirectURI string,
 	wantCustomSessionData *psession.CustomSessionData,
 	wantDownstreamAdditionalClaims map[string]any,
+	wantDownstreamAuthenticationContext *idtransform.AuthenticationContext,
 ) (string, *fosite.Request, *psession.PinnipedSession) {
 	t.Helper()
 
@@ -199,7 +203,9 @@ func validateAuthcodeStorage(
 
 	// Should always have an azp claim.
 	require.Equal(t, wantDownstreamClientID, actualClaims.Extra["azp"])
-	wantDownstreamIDTokenExtraClaimsCount := 1 // should always have azp claim
+	// Should always have a sid claim, which is the ID of the session.
+	require.Equal(t, sessionID, actualClaims.Extra["sid"])
+	wantDownstreamIDTokenExtraClaimsCount := 2 // should always have azp and sid claims
 
 	if len(wantDownstreamAdditionalClaims) > 0 {
 		wantDownstreamIDTokenExtraClaimsCount++
@@ -252,12 +258,17 @@ func validateAuthcodeStorage(
 	require.Zero(t, actualClaims.ExpiresAt)
 	require.Zero(t, actualClaims.IssuedAt)
 
+	// How the user authenticated to the upstream identity provider is passed through, when it is known.
+	if wantDownstreamAuthenticationContext == nil {
+		wantDownstreamAuthenticationContext = &idtransform.AuthenticationContext{}
+	}
+	require.Equal(t, wantDownstreamAuthenticationContext.ACR, actualClaims.AuthenticationContextClassReference)
+	require.Equal(t, wantDownstreamAuthenticationContext.AMR, actualClaims.AuthenticationMethodsReferences)
+
 	// These are not needed yet.
 	require.Empty(t, actualClaims.JTI)
 	require.Empty(t, actualClaims.CodeHash)
 	require.Empty(t, actualClaims.AccessTokenHash)
-	require.Empty(t, actualClaims.AuthenticationContextClassReference)
-	require.Empty(t, actualClaims.AuthenticationMethodsReferences)
 
 	// Check that the custom Pinniped session data matches.
 	require.Equal(t, wantCustomSessionData, storedSessionFromAuthcode.Custom)
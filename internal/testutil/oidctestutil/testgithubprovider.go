@@ -31,20 +31,21 @@ type GetUserArgs struct {
 }
 
 type TestUpstreamGitHubIdentityProviderBuilder struct {
-	name                           string
-	resourceUID                    types.UID
-	clientID                       string
-	scopes                         []string
-	displayNameForFederationDomain string
-	transformsForFederationDomain  *idtransform.TransformationPipeline
-	usernameAttribute              idpv1alpha1.GitHubUsernameAttribute
-	groupNameAttribute             idpv1alpha1.GitHubGroupNameAttribute
-	allowedOrganizations           *setutil.CaseInsensitiveSet
-	authorizationURL               string
-	authcodeExchangeErr            error
-	accessToken                    string
-	getUserErr                     error
-	getUserUser                    *upstreamprovider.GitHubUser
+	name                                       string
+	resourceUID                                types.UID
+	clientID                                   string
+	scopes                                     []string
+	displayNameForFederationDomain             string
+	transformsForFederationDomain              *idtransform.TransformationPipeline
+	tokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
+	usernameAttribute                          idpv1alpha1.GitHubUsernameAttribute
+	groupNameAttribute                         idpv1alpha1.GitHubGroupNameAttribute
+	allowedOrganizations                       *setutil.CaseInsensitiveSet
+	authorizationURL                           string
+	authcodeExchangeErr                        error
+	accessToken                                string
+	getUserErr                                 error
+	getUserUser                                *upstreamprovider.GitHubUser
 }
 
 func (u *TestUpstreamGitHubIdentityProviderBuilder) WithName(value string) *TestUpstreamGitHubIdentityProviderBuilder {
@@ -117,6 +118,11 @@ func (u *TestUpstreamGitHubIdentityProviderBuilder) WithTransformsForFederationD
 	return u
 }
 
+func (u *TestUpstreamGitHubIdentityProviderBuilder) WithTokenExchangeTransformsForFederationDomain(transforms ...*idtransform.TokenExchangePipeline) *TestUpstreamGitHubIdentityProviderBuilder {
+	u.tokenExchangeTransformsForFederationDomain = transforms
+	return u
+}
+
 func (u *TestUpstreamGitHubIdentityProviderBuilder) Build() *TestUpstreamGitHubIdentityProvider {
 	if u.displayNameForFederationDomain == "" {
 		// default it to the CR name
@@ -133,10 +139,11 @@ func (u *TestUpstreamGitHubIdentityProviderBuilder) Build() *TestUpstreamGitHubI
 		Scopes:                         u.scopes,
 		DisplayNameForFederationDomain: u.displayNameForFederationDomain,
 		TransformsForFederationDomain:  u.transformsForFederationDomain,
-		UsernameAttribute:              u.usernameAttribute,
-		GroupNameAttribute:             u.groupNameAttribute,
-		AllowedOrganizations:           u.allowedOrganizations,
-		AuthorizationURL:               u.authorizationURL,
+		TokenExchangeTransformsForFederationDomain: u.tokenExchangeTransformsForFederationDomain,
+		UsernameAttribute:                          u.usernameAttribute,
+		GroupNameAttribute:                         u.groupNameAttribute,
+		AllowedOrganizations:                       u.allowedOrganizations,
+		AuthorizationURL:                           u.authorizationURL,
 		GetUserFunc: func(ctx context.Context, accessToken string) (*upstreamprovider.GitHubUser, error) {
 			if u.getUserErr != nil {
 				return nil, u.getUserErr
@@ -157,18 +164,19 @@ func NewTestUpstreamGitHubIdentityProviderBuilder() *TestUpstreamGitHubIdentityP
 }
 
 type TestUpstreamGitHubIdentityProvider struct {
-	Name                           string
-	ClientID                       string
-	ResourceUID                    types.UID
-	Scopes                         []string
-	DisplayNameForFederationDomain string
-	TransformsForFederationDomain  *idtransform.TransformationPipeline
-	UsernameAttribute              idpv1alpha1.GitHubUsernameAttribute
-	GroupNameAttribute             idpv1alpha1.GitHubGroupNameAttribute
-	AllowedOrganizations           *setutil.CaseInsensitiveSet
-	AuthorizationURL               string
-	GetUserFunc                    func(ctx context.Context, accessToken string) (*upstreamprovider.GitHubUser, error)
-	ExchangeAuthcodeFunc           func(ctx context.Context, authcode string) (string, error)
+	Name                                       string
+	ClientID                                   string
+	ResourceUID                                types.UID
+	Scopes                                     []string
+	DisplayNameForFederationDomain             string
+	TransformsForFederationDomain              *idtransform.TransformationPipeline
+	TokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
+	UsernameAttribute                          idpv1alpha1.GitHubUsernameAttribute
+	GroupNameAttribute                         idpv1alpha1.GitHubGroupNameAttribute
+	AllowedOrganizations                       *setutil.CaseInsensitiveSet
+	AuthorizationURL                           string
+	GetUserFunc                                func(ctx context.Context, accessToken string) (*upstreamprovider.GitHubUser, error)
+	ExchangeAuthcodeFunc                       func(ctx context.Context, authcode string) (string, error)
 
 	// Fields for tracking actual calls make to mock functions.
 	exchangeAuthcodeCallCount int
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidctestutil
@@ -25,14 +25,17 @@ func NewTestUpstreamLDAPIdentityProviderBuilder() *TestUpstreamLDAPIdentityProvi
 }
 
 type TestUpstreamLDAPIdentityProviderBuilder struct {
-	name                           string
-	resourceUID                    types.UID
-	url                            *url.URL
-	authenticateFunc               func(ctx context.Context, username, password string) (*authenticators.Response, bool, error)
-	performRefreshErr              error
-	performRefreshGroups           []string
-	displayNameForFederationDomain string
-	transformsForFederationDomain  *idtransform.TransformationPipeline
+	name                                       string
+	resourceUID                                types.UID
+	url                                        *url.URL
+	authenticateFunc                           func(ctx context.Context, username, password string) (*authenticators.Response, bool, error)
+	performRefreshErr                          error
+	performRefreshGroups                       []string
+	performRefreshAdditionalClaims             map[string][]string
+	performRefreshUpstreamData                 map[string]any
+	displayNameForFederationDomain             string
+	transformsForFederationDomain              *idtransform.TransformationPipeline
+	tokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
 }
 
 func (t *TestUpstreamLDAPIdentityProviderBuilder) WithName(name string) *TestUpstreamLDAPIdentityProviderBuilder {
@@ -65,6 +68,16 @@ func (t *TestUpstreamLDAPIdentityProviderBuilder) WithPerformRefreshGroups(group
 	return t
 }
 
+func (t *TestUpstreamLDAPIdentityProviderBuilder) WithPerformRefreshAdditionalClaims(additionalClaims map[string][]string) *TestUpstreamLDAPIdentityProviderBuilder {
+	t.performRefreshAdditionalClaims = additionalClaims
+	return t
+}
+
+func (t *TestUpstreamLDAPIdentityProviderBuilder) WithPerformRefreshUpstreamData(upstreamData map[string]any) *TestUpstreamLDAPIdentityProviderBuilder {
+	t.performRefreshUpstreamData = upstreamData
+	return t
+}
+
 func (t *TestUpstreamLDAPIdentityProviderBuilder) WithDisplayNameForFederationDomain(displayName string) *TestUpstreamLDAPIdentityProviderBuilder {
 	t.displayNameForFederationDomain = displayName
 	return t
@@ -75,6 +88,11 @@ func (t *TestUpstreamLDAPIdentityProviderBuilder) WithTransformsForFederationDom
 	return t
 }
 
+func (t *TestUpstreamLDAPIdentityProviderBuilder) WithTokenExchangeTransformsForFederationDomain(transforms ...*idtransform.TokenExchangePipeline) *TestUpstreamLDAPIdentityProviderBuilder {
+	t.tokenExchangeTransformsForFederationDomain = transforms
+	return t
+}
+
 func (t *TestUpstreamLDAPIdentityProviderBuilder) Build() *TestUpstreamLDAPIdentityProvider {
 	if t.displayNameForFederationDomain == "" {
 		// default it to the CR name
@@ -91,20 +109,26 @@ func (t *TestUpstreamLDAPIdentityProviderBuilder) Build() *TestUpstreamLDAPIdent
 		AuthenticateFunc:               t.authenticateFunc,
 		PerformRefreshErr:              t.performRefreshErr,
 		PerformRefreshGroups:           t.performRefreshGroups,
+		PerformRefreshAdditionalClaims: t.performRefreshAdditionalClaims,
+		PerformRefreshUpstreamData:     t.performRefreshUpstreamData,
 		DisplayNameForFederationDomain: t.displayNameForFederationDomain,
 		TransformsForFederationDomain:  t.transformsForFederationDomain,
+		TokenExchangeTransformsForFederationDomain: t.tokenExchangeTransformsForFederationDomain,
 	}
 }
 
 type TestUpstreamLDAPIdentityProvider struct {
-	Name                           string
-	ResourceUID                    types.UID
-	URL                            *url.URL
-	AuthenticateFunc               func(ctx context.Context, username, password string) (*authenticators.Response, bool, error)
-	PerformRefreshErr              error
-	PerformRefreshGroups           []string
-	DisplayNameForFederationDomain string
-	TransformsForFederationDomain  *idtransform.TransformationPipeline
+	Name                                       string
+	ResourceUID                                types.UID
+	URL                                        *url.URL
+	AuthenticateFunc                           func(ctx context.Context, username, password string) (*authenticators.Response, bool, error)
+	PerformRefreshErr                          error
+	PerformRefreshGroups                       []string
+	PerformRefreshAdditionalClaims             map[string][]string
+	PerformRefreshUpstreamData                 map[string]any
+	DisplayNameForFederationDomain             string
+	TransformsForFederationDomain              *idtransform.TransformationPipeline
+	TokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
 
 	// Fields for tracking actual calls make to mock functions.
 	performRefreshCallCount int
@@ -129,7 +153,7 @@ func (u *TestUpstreamLDAPIdentityProvider) GetURL() *url.URL {
 	return u.URL
 }
 
-func (u *TestUpstreamLDAPIdentityProvider) PerformRefresh(ctx context.Context, storedRefreshAttributes upstreamprovider.LDAPRefreshAttributes, idpDisplayName string) ([]string, error) {
+func (u *TestUpstreamLDAPIdentityProvider) PerformRefresh(ctx context.Context, storedRefreshAttributes upstreamprovider.LDAPRefreshAttributes, idpDisplayName string) ([]string, map[string][]string, map[string]any, error) {
 	if u.performRefreshArgs == nil {
 		u.performRefreshArgs = make([]*PerformLDAPRefreshArgs, 0)
 	}
@@ -140,9 +164,9 @@ func (u *TestUpstreamLDAPIdentityProvider) PerformRefresh(ctx context.Context, s
 		IDPDisplayName:          idpDisplayName,
 	})
 	if u.PerformRefreshErr != nil {
-		return nil, u.PerformRefreshErr
+		return nil, nil, nil, u.PerformRefreshErr
 	}
-	return u.PerformRefreshGroups, nil
+	return u.PerformRefreshGroups, u.PerformRefreshAdditionalClaims, u.PerformRefreshUpstreamData, nil
 }
 
 func (u *TestUpstreamLDAPIdentityProvider) PerformRefreshCallCount() int {
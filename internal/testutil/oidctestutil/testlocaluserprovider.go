@@ -0,0 +1,151 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidctestutil
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/idtransform"
+)
+
+type PerformLocalUserRefreshArgs struct {
+	Ctx      context.Context
+	Username string
+	UserUID  string
+}
+
+func NewTestUpstreamLocalUserIdentityProviderBuilder() *TestUpstreamLocalUserIdentityProviderBuilder {
+	return &TestUpstreamLocalUserIdentityProviderBuilder{}
+}
+
+type TestUpstreamLocalUserIdentityProviderBuilder struct {
+	name                                       string
+	resourceUID                                types.UID
+	authenticateFunc                           func(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error)
+	performRefreshErr                          error
+	performRefreshGroups                       []string
+	displayNameForFederationDomain             string
+	transformsForFederationDomain              *idtransform.TransformationPipeline
+	tokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) WithName(name string) *TestUpstreamLocalUserIdentityProviderBuilder {
+	t.name = name
+	return t
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) WithResourceUID(uid types.UID) *TestUpstreamLocalUserIdentityProviderBuilder {
+	t.resourceUID = uid
+	return t
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) WithAuthenticateFunc(f func(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error)) *TestUpstreamLocalUserIdentityProviderBuilder {
+	t.authenticateFunc = f
+	return t
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) WithPerformRefreshErr(err error) *TestUpstreamLocalUserIdentityProviderBuilder {
+	t.performRefreshErr = err
+	return t
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) WithPerformRefreshGroups(groups []string) *TestUpstreamLocalUserIdentityProviderBuilder {
+	t.performRefreshGroups = groups
+	return t
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) WithDisplayNameForFederationDomain(displayName string) *TestUpstreamLocalUserIdentityProviderBuilder {
+	t.displayNameForFederationDomain = displayName
+	return t
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) WithTransformsForFederationDomain(transforms *idtransform.TransformationPipeline) *TestUpstreamLocalUserIdentityProviderBuilder {
+	t.transformsForFederationDomain = transforms
+	return t
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) WithTokenExchangeTransformsForFederationDomain(transforms ...*idtransform.TokenExchangePipeline) *TestUpstreamLocalUserIdentityProviderBuilder {
+	t.tokenExchangeTransformsForFederationDomain = transforms
+	return t
+}
+
+func (t *TestUpstreamLocalUserIdentityProviderBuilder) Build() *TestUpstreamLocalUserIdentityProvider {
+	if t.displayNameForFederationDomain == "" {
+		// default it to the CR name
+		t.displayNameForFederationDomain = t.name
+	}
+	if t.transformsForFederationDomain == nil {
+		// default to an empty pipeline
+		t.transformsForFederationDomain = idtransform.NewTransformationPipeline()
+	}
+	return &TestUpstreamLocalUserIdentityProvider{
+		Name:                           t.name,
+		ResourceUID:                    t.resourceUID,
+		AuthenticateFunc:               t.authenticateFunc,
+		PerformRefreshErr:              t.performRefreshErr,
+		PerformRefreshGroups:           t.performRefreshGroups,
+		DisplayNameForFederationDomain: t.displayNameForFederationDomain,
+		TransformsForFederationDomain:  t.transformsForFederationDomain,
+		TokenExchangeTransformsForFederationDomain: t.tokenExchangeTransformsForFederationDomain,
+	}
+}
+
+type TestUpstreamLocalUserIdentityProvider struct {
+	Name                                       string
+	ResourceUID                                types.UID
+	AuthenticateFunc                           func(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error)
+	PerformRefreshErr                          error
+	PerformRefreshGroups                       []string
+	DisplayNameForFederationDomain             string
+	TransformsForFederationDomain              *idtransform.TransformationPipeline
+	TokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
+
+	// Fields for tracking actual calls make to mock functions.
+	performRefreshCallCount int
+	performRefreshArgs      []*PerformLocalUserRefreshArgs
+}
+
+var _ upstreamprovider.UpstreamLocalUserIdentityProviderI = &TestUpstreamLocalUserIdentityProvider{}
+
+func (u *TestUpstreamLocalUserIdentityProvider) GetResourceUID() types.UID {
+	return u.ResourceUID
+}
+
+func (u *TestUpstreamLocalUserIdentityProvider) GetResourceName() string {
+	return u.Name
+}
+
+func (u *TestUpstreamLocalUserIdentityProvider) AuthenticateUser(ctx context.Context, username, password, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error) {
+	return u.AuthenticateFunc(ctx, username, password, idpDisplayName)
+}
+
+func (u *TestUpstreamLocalUserIdentityProvider) PerformRefresh(ctx context.Context, username string, userUID string) ([]string, error) {
+	if u.performRefreshArgs == nil {
+		u.performRefreshArgs = make([]*PerformLocalUserRefreshArgs, 0)
+	}
+	u.performRefreshCallCount++
+	u.performRefreshArgs = append(u.performRefreshArgs, &PerformLocalUserRefreshArgs{
+		Ctx:      ctx,
+		Username: username,
+		UserUID:  userUID,
+	})
+	if u.PerformRefreshErr != nil {
+		return nil, u.PerformRefreshErr
+	}
+	return u.PerformRefreshGroups, nil
+}
+
+func (u *TestUpstreamLocalUserIdentityProvider) PerformRefreshCallCount() int {
+	return u.performRefreshCallCount
+}
+
+func (u *TestUpstreamLocalUserIdentityProvider) PerformRefreshArgs(call int) *PerformLocalUserRefreshArgs {
+	if u.performRefreshArgs == nil {
+		u.performRefreshArgs = make([]*PerformLocalUserRefreshArgs, 0)
+	}
+	return u.performRefreshArgs[call]
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidctestutil
@@ -62,20 +62,21 @@ type ValidateTokenAndMergeWithUserInfoArgs struct {
 }
 
 type TestUpstreamOIDCIdentityProvider struct {
-	Name                           string
-	ClientID                       string
-	ResourceUID                    types.UID
-	AuthorizationURL               url.URL
-	UserInfoURL                    bool
-	RevocationURL                  *url.URL
-	UsernameClaim                  string
-	GroupsClaim                    string
-	Scopes                         []string
-	AdditionalAuthcodeParams       map[string]string
-	AdditionalClaimMappings        map[string]string
-	AllowPasswordGrant             bool
-	DisplayNameForFederationDomain string
-	TransformsForFederationDomain  *idtransform.TransformationPipeline
+	Name                                       string
+	ClientID                                   string
+	ResourceUID                                types.UID
+	AuthorizationURL                           url.URL
+	UserInfoURL                                bool
+	RevocationURL                              *url.URL
+	UsernameClaim                              string
+	GroupsClaim                                string
+	Scopes                                     []string
+	AdditionalAuthcodeParams                   map[string]string
+	AdditionalClaimMappings                    map[string]string
+	AllowPasswordGrant                         bool
+	DisplayNameForFederationDomain             string
+	TransformsForFederationDomain              *idtransform.TransformationPipeline
+	TokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
 
 	ExchangeAuthcodeAndValidateTokensFunc func(
 		ctx context.Context,
@@ -92,6 +93,9 @@ type TestUpstreamOIDCIdentityProvider struct {
 
 	PerformRefreshFunc func(ctx context.Context, refreshToken string) (*oauth2.Token, error)
 
+	// PrepareAuthorizationRequestFunc returns the given params unchanged when it is nil.
+	PrepareAuthorizationRequestFunc func(ctx context.Context, params url.Values) (url.Values, error)
+
 	RevokeTokenFunc func(ctx context.Context, refreshToken string, tokenType upstreamprovider.RevocableTokenType) error
 
 	ValidateTokenAndMergeWithUserInfoFunc func(ctx context.Context, tok *oauth2.Token, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error)
@@ -159,6 +163,13 @@ func (u *TestUpstreamOIDCIdentityProvider) AllowsPasswordGrant() bool {
 	return u.AllowPasswordGrant
 }
 
+func (u *TestUpstreamOIDCIdentityProvider) PrepareAuthorizationRequest(ctx context.Context, params url.Values) (url.Values, error) {
+	if u.PrepareAuthorizationRequestFunc == nil {
+		return params, nil
+	}
+	return u.PrepareAuthorizationRequestFunc(ctx, params)
+}
+
 func (u *TestUpstreamOIDCIdentityProvider) PasswordCredentialsGrantAndValidateTokens(ctx context.Context, username, password string) (*oidctypes.Token, error) {
 	u.passwordCredentialsGrantAndValidateTokensCallCount++
 	u.passwordCredentialsGrantAndValidateTokensArgs = append(u.passwordCredentialsGrantAndValidateTokensArgs, &PasswordCredentialsGrantAndValidateTokensArgs{
@@ -286,29 +297,32 @@ func (u *TestUpstreamOIDCIdentityProvider) ValidateTokenAndMergeWithUserInfoArgs
 }
 
 type TestUpstreamOIDCIdentityProviderBuilder struct {
-	name                                 string
-	resourceUID                          types.UID
-	clientID                             string
-	scopes                               []string
-	idToken                              map[string]any
-	refreshToken                         *oidctypes.RefreshToken
-	accessToken                          *oidctypes.AccessToken
-	usernameClaim                        string
-	groupsClaim                          string
-	refreshedTokens                      *oauth2.Token
-	validatedAndMergedWithUserInfoTokens *oidctypes.Token
-	authorizationURL                     url.URL
-	hasUserInfoURL                       bool
-	additionalAuthcodeParams             map[string]string
-	additionalClaimMappings              map[string]string
-	allowPasswordGrant                   bool
-	authcodeExchangeErr                  error
-	passwordGrantErr                     error
-	performRefreshErr                    error
-	revokeTokenErr                       error
-	validateTokenAndMergeWithUserInfoErr error
-	displayNameForFederationDomain       string
-	transformsForFederationDomain        *idtransform.TransformationPipeline
+	name                                       string
+	resourceUID                                types.UID
+	clientID                                   string
+	scopes                                     []string
+	idToken                                    map[string]any
+	refreshToken                               *oidctypes.RefreshToken
+	accessToken                                *oidctypes.AccessToken
+	usernameClaim                              string
+	groupsClaim                                string
+	refreshedTokens                            *oauth2.Token
+	validatedAndMergedWithUserInfoTokens       *oidctypes.Token
+	authorizationURL                           url.URL
+	hasUserInfoURL                             bool
+	additionalAuthcodeParams                   map[string]string
+	additionalClaimMappings                    map[string]string
+	allowPasswordGrant                         bool
+	authcodeExchangeErr                        error
+	passwordGrantErr                           error
+	performRefreshErr                          error
+	revokeTokenErr                             error
+	validateTokenAndMergeWithUserInfoErr       error
+	preparedAuthorizationRequestParams         url.Values
+	prepareAuthorizationRequestErr             error
+	displayNameForFederationDomain             string
+	transformsForFederationDomain              *idtransform.TransformationPipeline
+	tokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
 }
 
 func (u *TestUpstreamOIDCIdentityProviderBuilder) WithName(value string) *TestUpstreamOIDCIdentityProviderBuilder {
@@ -458,6 +472,16 @@ func (u *TestUpstreamOIDCIdentityProviderBuilder) WithRevokeTokenError(err error
 	return u
 }
 
+func (u *TestUpstreamOIDCIdentityProviderBuilder) WithPreparedAuthorizationRequestParams(params url.Values) *TestUpstreamOIDCIdentityProviderBuilder {
+	u.preparedAuthorizationRequestParams = params
+	return u
+}
+
+func (u *TestUpstreamOIDCIdentityProviderBuilder) WithPrepareAuthorizationRequestError(err error) *TestUpstreamOIDCIdentityProviderBuilder {
+	u.prepareAuthorizationRequestErr = err
+	return u
+}
+
 func (u *TestUpstreamOIDCIdentityProviderBuilder) WithDisplayNameForFederationDomain(displayName string) *TestUpstreamOIDCIdentityProviderBuilder {
 	u.displayNameForFederationDomain = displayName
 	return u
@@ -468,6 +492,11 @@ func (u *TestUpstreamOIDCIdentityProviderBuilder) WithTransformsForFederationDom
 	return u
 }
 
+func (u *TestUpstreamOIDCIdentityProviderBuilder) WithTokenExchangeTransformsForFederationDomain(transforms ...*idtransform.TokenExchangePipeline) *TestUpstreamOIDCIdentityProviderBuilder {
+	u.tokenExchangeTransformsForFederationDomain = transforms
+	return u
+}
+
 func (u *TestUpstreamOIDCIdentityProviderBuilder) Build() *TestUpstreamOIDCIdentityProvider {
 	if u.displayNameForFederationDomain == "" {
 		// default it to the CR name
@@ -492,6 +521,7 @@ func (u *TestUpstreamOIDCIdentityProviderBuilder) Build() *TestUpstreamOIDCIdent
 		AdditionalClaimMappings:        u.additionalClaimMappings,
 		DisplayNameForFederationDomain: u.displayNameForFederationDomain,
 		TransformsForFederationDomain:  u.transformsForFederationDomain,
+		TokenExchangeTransformsForFederationDomain: u.tokenExchangeTransformsForFederationDomain,
 		ExchangeAuthcodeAndValidateTokensFunc: func(ctx context.Context, authcode string, pkceCodeVerifier oidcpkce.Code, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error) {
 			if u.authcodeExchangeErr != nil {
 				return nil, u.authcodeExchangeErr
@@ -513,6 +543,15 @@ func (u *TestUpstreamOIDCIdentityProviderBuilder) Build() *TestUpstreamOIDCIdent
 		RevokeTokenFunc: func(ctx context.Context, refreshToken string, tokenType upstreamprovider.RevocableTokenType) error {
 			return u.revokeTokenErr
 		},
+		PrepareAuthorizationRequestFunc: func(ctx context.Context, params url.Values) (url.Values, error) {
+			if u.prepareAuthorizationRequestErr != nil {
+				return nil, u.prepareAuthorizationRequestErr
+			}
+			if u.preparedAuthorizationRequestParams != nil {
+				return u.preparedAuthorizationRequestParams, nil
+			}
+			return params, nil
+		},
 		ValidateTokenAndMergeWithUserInfoFunc: func(ctx context.Context, tok *oauth2.Token, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error) {
 			if u.validateTokenAndMergeWithUserInfoErr != nil {
 				return nil, u.validateTokenAndMergeWithUserInfoErr
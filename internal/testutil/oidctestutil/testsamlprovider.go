@@ -0,0 +1,208 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidctestutil
+
+import (
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/idtransform"
+)
+
+// ValidateResponseArgs is used to spy on calls to
+// TestUpstreamSAMLIdentityProvider.ValidateResponseFunc().
+type ValidateResponseArgs struct {
+	EncodedResponse             string
+	RequestID                   string
+	ServiceProviderEntityID     string
+	AssertionConsumerServiceURL string
+	IDPDisplayName              string
+}
+
+type TestUpstreamSAMLIdentityProviderBuilder struct {
+	name                                       string
+	resourceUID                                types.UID
+	displayNameForFederationDomain             string
+	transformsForFederationDomain              *idtransform.TransformationPipeline
+	tokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
+	serviceProviderEntityID                    string
+	usernameAttribute                          string
+	groupsAttribute                            string
+	singleSignOnURL                            string
+	validateResponseErr                        error
+	validateResponseUser                       *upstreamprovider.SAMLUser
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithName(value string) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.name = value
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithResourceUID(value types.UID) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.resourceUID = value
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithDisplayNameForFederationDomain(value string) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.displayNameForFederationDomain = value
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithTransformsForFederationDomain(transforms *idtransform.TransformationPipeline) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.transformsForFederationDomain = transforms
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithTokenExchangeTransformsForFederationDomain(transforms ...*idtransform.TokenExchangePipeline) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.tokenExchangeTransformsForFederationDomain = transforms
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithServiceProviderEntityID(value string) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.serviceProviderEntityID = value
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithUsernameAttribute(value string) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.usernameAttribute = value
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithGroupsAttribute(value string) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.groupsAttribute = value
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithSingleSignOnURL(value string) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.singleSignOnURL = value
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithUser(user *upstreamprovider.SAMLUser) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.validateResponseUser = user
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) WithValidateResponseError(err error) *TestUpstreamSAMLIdentityProviderBuilder {
+	u.validateResponseErr = err
+	return u
+}
+
+func (u *TestUpstreamSAMLIdentityProviderBuilder) Build() *TestUpstreamSAMLIdentityProvider {
+	if u.displayNameForFederationDomain == "" {
+		// default it to the CR name
+		u.displayNameForFederationDomain = u.name
+	}
+	if u.transformsForFederationDomain == nil {
+		// default to an empty pipeline
+		u.transformsForFederationDomain = idtransform.NewTransformationPipeline()
+	}
+	return &TestUpstreamSAMLIdentityProvider{
+		Name:                           u.name,
+		ResourceUID:                    u.resourceUID,
+		DisplayNameForFederationDomain: u.displayNameForFederationDomain,
+		TransformsForFederationDomain:  u.transformsForFederationDomain,
+		TokenExchangeTransformsForFederationDomain: u.tokenExchangeTransformsForFederationDomain,
+		ServiceProviderEntityID:                    u.serviceProviderEntityID,
+		UsernameAttribute:                          u.usernameAttribute,
+		GroupsAttribute:                            u.groupsAttribute,
+		SingleSignOnURL:                            u.singleSignOnURL,
+		ValidateResponseFunc: func(encodedResponse string) (*upstreamprovider.SAMLUser, error) {
+			if u.validateResponseErr != nil {
+				return nil, u.validateResponseErr
+			}
+			return u.validateResponseUser, nil
+		},
+	}
+}
+
+func NewTestUpstreamSAMLIdentityProviderBuilder() *TestUpstreamSAMLIdentityProviderBuilder {
+	return &TestUpstreamSAMLIdentityProviderBuilder{}
+}
+
+type TestUpstreamSAMLIdentityProvider struct {
+	Name                                       string
+	ResourceUID                                types.UID
+	DisplayNameForFederationDomain             string
+	TransformsForFederationDomain              *idtransform.TransformationPipeline
+	TokenExchangeTransformsForFederationDomain idtransform.TokenExchangePipelines
+	ServiceProviderEntityID                    string
+	UsernameAttribute                          string
+	GroupsAttribute                            string
+	SingleSignOnURL                            string
+	ValidateResponseFunc                       func(encodedResponse string) (*upstreamprovider.SAMLUser, error)
+
+	// Fields for tracking actual calls make to mock functions.
+	validateResponseCallCount int
+	validateResponseArgs      []*ValidateResponseArgs
+}
+
+var _ upstreamprovider.UpstreamSAMLIdentityProviderI = &TestUpstreamSAMLIdentityProvider{}
+
+func (u *TestUpstreamSAMLIdentityProvider) GetResourceUID() types.UID {
+	return u.ResourceUID
+}
+
+func (u *TestUpstreamSAMLIdentityProvider) GetResourceName() string {
+	return u.Name
+}
+
+func (u *TestUpstreamSAMLIdentityProvider) GetServiceProviderEntityID() string {
+	return u.ServiceProviderEntityID
+}
+
+func (u *TestUpstreamSAMLIdentityProvider) GetUsernameAttribute() string {
+	return u.UsernameAttribute
+}
+
+func (u *TestUpstreamSAMLIdentityProvider) GetGroupsAttribute() string {
+	return u.GroupsAttribute
+}
+
+// AuthnRequestURL returns a fake encoding of the AuthnRequest, which is easy to assert on in tests.
+func (u *TestUpstreamSAMLIdentityProvider) AuthnRequestURL(
+	requestID string,
+	serviceProviderEntityID string,
+	assertionConsumerServiceURL string,
+	relayState string,
+) (string, error) {
+	return u.SingleSignOnURL + "?" + url.Values{
+		"SAMLRequest": []string{requestID + " " + serviceProviderEntityID + " " + assertionConsumerServiceURL},
+		"RelayState":  []string{relayState},
+	}.Encode(), nil
+}
+
+func (u *TestUpstreamSAMLIdentityProvider) ValidateResponse(
+	encodedResponse string,
+	requestID string,
+	serviceProviderEntityID string,
+	assertionConsumerServiceURL string,
+	idpDisplayName string,
+) (*upstreamprovider.SAMLUser, error) {
+	if u.validateResponseArgs == nil {
+		u.validateResponseArgs = make([]*ValidateResponseArgs, 0)
+	}
+	u.validateResponseCallCount++
+	u.validateResponseArgs = append(u.validateResponseArgs, &ValidateResponseArgs{
+		EncodedResponse:             encodedResponse,
+		RequestID:                   requestID,
+		ServiceProviderEntityID:     serviceProviderEntityID,
+		AssertionConsumerServiceURL: assertionConsumerServiceURL,
+		IDPDisplayName:              idpDisplayName,
+	})
+	return u.ValidateResponseFunc(encodedResponse)
+}
+
+func (u *TestUpstreamSAMLIdentityProvider) ValidateResponseCallCount() int {
+	return u.validateResponseCallCount
+}
+
+func (u *TestUpstreamSAMLIdentityProvider) ValidateResponseArgs(call int) *ValidateResponseArgs {
+	if u.validateResponseArgs == nil {
+		u.validateResponseArgs = make([]*ValidateResponseArgs, 0)
+	}
+	return u.validateResponseArgs[call]
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package samltestutil contains a stand-in for a SAML identity provider, for use in tests.
+package samltestutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// IdentityProvider holds the signing key and metadata of a test SAML identity provider.
+type IdentityProvider struct {
+	EntityID        string
+	SingleSignOnURL string
+	SigningKey      *rsa.PrivateKey
+	Certificate     *x509.Certificate
+}
+
+// NewIdentityProvider creates a test SAML identity provider with a new self-signed RSA signing certificate.
+func NewIdentityProvider(t *testing.T, entityID string, singleSignOnURL string) *IdentityProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// Signatures are only valid while the certificate is valid, so allow tests to use a fixed time in the past.
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: entityID},
+		NotBefore:    time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &IdentityProvider{
+		EntityID:        entityID,
+		SingleSignOnURL: singleSignOnURL,
+		SigningKey:      key,
+		Certificate:     cert,
+	}
+}
+
+// MetadataXML returns the SAML 2.0 metadata document of the test identity provider.
+func (i *IdentityProvider) MetadataXML() string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+        <ds:X509Data>
+          <ds:X509Certificate>%s</ds:X509Certificate>
+        </ds:X509Data>
+      </ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:NameIDFormat>urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified</md:NameIDFormat>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="%s"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="%s"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>
+`, i.EntityID, base64.StdEncoding.EncodeToString(i.Certificate.Raw), i.SingleSignOnURL, i.SingleSignOnURL)
+}
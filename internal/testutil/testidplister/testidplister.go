@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package testidplister
@@ -14,7 +14,10 @@ import (
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedgithub"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedldap"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedlocaluser"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedoauth2"
 	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedoidc"
+	"go.pinniped.dev/internal/federationdomain/resolvedprovider/resolvedsaml"
 	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
 	"go.pinniped.dev/internal/psession"
 	"go.pinniped.dev/internal/testutil/oidctestutil"
@@ -27,6 +30,9 @@ type TestFederationDomainIdentityProvidersListerFinder struct {
 	upstreamLDAPIdentityProviders            []*oidctestutil.TestUpstreamLDAPIdentityProvider
 	upstreamActiveDirectoryIdentityProviders []*oidctestutil.TestUpstreamLDAPIdentityProvider
 	upstreamGitHubIdentityProviders          []*oidctestutil.TestUpstreamGitHubIdentityProvider
+	upstreamSAMLIdentityProviders            []*oidctestutil.TestUpstreamSAMLIdentityProvider
+	upstreamOAuth2IdentityProviders          []*oidctestutil.TestUpstreamOAuth2IdentityProvider
+	upstreamLocalUserIdentityProviders       []*oidctestutil.TestUpstreamLocalUserIdentityProvider
 	defaultIDPDisplayName                    string
 }
 
@@ -40,44 +46,81 @@ func (t *TestFederationDomainIdentityProvidersListerFinder) IDPCount() int {
 
 func (t *TestFederationDomainIdentityProvidersListerFinder) GetIdentityProviders() []resolvedprovider.FederationDomainResolvedIdentityProvider {
 	fdIDPs := make([]resolvedprovider.FederationDomainResolvedIdentityProvider,
-		len(t.upstreamOIDCIdentityProviders)+len(t.upstreamLDAPIdentityProviders)+len(t.upstreamActiveDirectoryIdentityProviders)+len(t.upstreamGitHubIdentityProviders))
+		len(t.upstreamOIDCIdentityProviders)+len(t.upstreamLDAPIdentityProviders)+len(t.upstreamActiveDirectoryIdentityProviders)+len(t.upstreamGitHubIdentityProviders)+len(t.upstreamSAMLIdentityProviders)+len(t.upstreamOAuth2IdentityProviders)+len(t.upstreamLocalUserIdentityProviders))
 	i := 0
 	for _, testIDP := range t.upstreamOIDCIdentityProviders {
 		fdIDP := &resolvedoidc.FederationDomainResolvedOIDCIdentityProvider{
-			DisplayName:         testIDP.DisplayNameForFederationDomain,
-			Provider:            testIDP,
-			SessionProviderType: psession.ProviderTypeOIDC,
-			Transforms:          testIDP.TransformsForFederationDomain,
+			DisplayName:             testIDP.DisplayNameForFederationDomain,
+			Provider:                testIDP,
+			SessionProviderType:     psession.ProviderTypeOIDC,
+			Transforms:              testIDP.TransformsForFederationDomain,
+			TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
 		}
 		fdIDPs[i] = fdIDP
 		i++
 	}
 	for _, testIDP := range t.upstreamLDAPIdentityProviders {
 		fdIDP := &resolvedldap.FederationDomainResolvedLDAPIdentityProvider{
-			DisplayName:         testIDP.DisplayNameForFederationDomain,
-			Provider:            testIDP,
-			SessionProviderType: psession.ProviderTypeLDAP,
-			Transforms:          testIDP.TransformsForFederationDomain,
+			DisplayName:             testIDP.DisplayNameForFederationDomain,
+			Provider:                testIDP,
+			SessionProviderType:     psession.ProviderTypeLDAP,
+			Transforms:              testIDP.TransformsForFederationDomain,
+			TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
 		}
 		fdIDPs[i] = fdIDP
 		i++
 	}
 	for _, testIDP := range t.upstreamActiveDirectoryIdentityProviders {
 		fdIDP := &resolvedldap.FederationDomainResolvedLDAPIdentityProvider{
-			DisplayName:         testIDP.DisplayNameForFederationDomain,
-			Provider:            testIDP,
-			SessionProviderType: psession.ProviderTypeActiveDirectory,
-			Transforms:          testIDP.TransformsForFederationDomain,
+			DisplayName:             testIDP.DisplayNameForFederationDomain,
+			Provider:                testIDP,
+			SessionProviderType:     psession.ProviderTypeActiveDirectory,
+			Transforms:              testIDP.TransformsForFederationDomain,
+			TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
 		}
 		fdIDPs[i] = fdIDP
 		i++
 	}
 	for _, testIDP := range t.upstreamGitHubIdentityProviders {
 		fdIDP := &resolvedgithub.FederationDomainResolvedGitHubIdentityProvider{
-			DisplayName:         testIDP.DisplayNameForFederationDomain,
-			Provider:            testIDP,
-			SessionProviderType: psession.ProviderTypeGitHub,
-			Transforms:          testIDP.TransformsForFederationDomain,
+			DisplayName:             testIDP.DisplayNameForFederationDomain,
+			Provider:                testIDP,
+			SessionProviderType:     psession.ProviderTypeGitHub,
+			Transforms:              testIDP.TransformsForFederationDomain,
+			TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
+		}
+		fdIDPs[i] = fdIDP
+		i++
+	}
+	for _, testIDP := range t.upstreamSAMLIdentityProviders {
+		fdIDP := &resolvedsaml.FederationDomainResolvedSAMLIdentityProvider{
+			DisplayName:             testIDP.DisplayNameForFederationDomain,
+			Provider:                testIDP,
+			SessionProviderType:     psession.ProviderTypeSAML,
+			Transforms:              testIDP.TransformsForFederationDomain,
+			TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
+		}
+		fdIDPs[i] = fdIDP
+		i++
+	}
+	for _, testIDP := range t.upstreamOAuth2IdentityProviders {
+		fdIDP := &resolvedoauth2.FederationDomainResolvedOAuth2IdentityProvider{
+			DisplayName:             testIDP.DisplayNameForFederationDomain,
+			Provider:                testIDP,
+			SessionProviderType:     psession.ProviderTypeOAuth2,
+			Transforms:              testIDP.TransformsForFederationDomain,
+			TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
+		}
+		fdIDPs[i] = fdIDP
+		i++
+	}
+	for _, testIDP := range t.upstreamLocalUserIdentityProviders {
+		fdIDP := &resolvedlocaluser.FederationDomainResolvedLocalUserIdentityProvider{
+			DisplayName:             testIDP.DisplayNameForFederationDomain,
+			Provider:                testIDP,
+			SessionProviderType:     psession.ProviderTypeLocalUser,
+			Transforms:              testIDP.TransformsForFederationDomain,
+			TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
 		}
 		fdIDPs[i] = fdIDP
 		i++
@@ -96,40 +139,77 @@ func (t *TestFederationDomainIdentityProvidersListerFinder) FindUpstreamIDPByDis
 	for _, testIDP := range t.upstreamOIDCIdentityProviders {
 		if upstreamIDPDisplayName == testIDP.DisplayNameForFederationDomain {
 			return &resolvedoidc.FederationDomainResolvedOIDCIdentityProvider{
-				DisplayName:         testIDP.DisplayNameForFederationDomain,
-				Provider:            testIDP,
-				SessionProviderType: psession.ProviderTypeOIDC,
-				Transforms:          testIDP.TransformsForFederationDomain,
+				DisplayName:             testIDP.DisplayNameForFederationDomain,
+				Provider:                testIDP,
+				SessionProviderType:     psession.ProviderTypeOIDC,
+				Transforms:              testIDP.TransformsForFederationDomain,
+				TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
 			}, nil
 		}
 	}
 	for _, testIDP := range t.upstreamLDAPIdentityProviders {
 		if upstreamIDPDisplayName == testIDP.DisplayNameForFederationDomain {
 			return &resolvedldap.FederationDomainResolvedLDAPIdentityProvider{
-				DisplayName:         testIDP.DisplayNameForFederationDomain,
-				Provider:            testIDP,
-				SessionProviderType: psession.ProviderTypeLDAP,
-				Transforms:          testIDP.TransformsForFederationDomain,
+				DisplayName:             testIDP.DisplayNameForFederationDomain,
+				Provider:                testIDP,
+				SessionProviderType:     psession.ProviderTypeLDAP,
+				Transforms:              testIDP.TransformsForFederationDomain,
+				TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
 			}, nil
 		}
 	}
 	for _, testIDP := range t.upstreamActiveDirectoryIdentityProviders {
 		if upstreamIDPDisplayName == testIDP.DisplayNameForFederationDomain {
 			return &resolvedldap.FederationDomainResolvedLDAPIdentityProvider{
-				DisplayName:         testIDP.DisplayNameForFederationDomain,
-				Provider:            testIDP,
-				SessionProviderType: psession.ProviderTypeActiveDirectory,
-				Transforms:          testIDP.TransformsForFederationDomain,
+				DisplayName:             testIDP.DisplayNameForFederationDomain,
+				Provider:                testIDP,
+				SessionProviderType:     psession.ProviderTypeActiveDirectory,
+				Transforms:              testIDP.TransformsForFederationDomain,
+				TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
 			}, nil
 		}
 	}
 	for _, testIDP := range t.upstreamGitHubIdentityProviders {
 		if upstreamIDPDisplayName == testIDP.DisplayNameForFederationDomain {
 			return &resolvedgithub.FederationDomainResolvedGitHubIdentityProvider{
-				DisplayName:         testIDP.DisplayNameForFederationDomain,
-				Provider:            testIDP,
-				SessionProviderType: psession.ProviderTypeGitHub,
-				Transforms:          testIDP.TransformsForFederationDomain,
+				DisplayName:             testIDP.DisplayNameForFederationDomain,
+				Provider:                testIDP,
+				SessionProviderType:     psession.ProviderTypeGitHub,
+				Transforms:              testIDP.TransformsForFederationDomain,
+				TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
+			}, nil
+		}
+	}
+	for _, testIDP := range t.upstreamSAMLIdentityProviders {
+		if upstreamIDPDisplayName == testIDP.DisplayNameForFederationDomain {
+			return &resolvedsaml.FederationDomainResolvedSAMLIdentityProvider{
+				DisplayName:             testIDP.DisplayNameForFederationDomain,
+				Provider:                testIDP,
+				SessionProviderType:     psession.ProviderTypeSAML,
+				Transforms:              testIDP.TransformsForFederationDomain,
+				TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
+			}, nil
+		}
+	}
+	for _, testIDP := range t.upstreamOAuth2IdentityProviders {
+		if upstreamIDPDisplayName == testIDP.DisplayNameForFederationDomain {
+			return &resolvedoauth2.FederationDomainResolvedOAuth2IdentityProvider{
+				DisplayName:             testIDP.DisplayNameForFederationDomain,
+				Provider:                testIDP,
+				SessionProviderType:     psession.ProviderTypeOAuth2,
+				Transforms:              testIDP.TransformsForFederationDomain,
+				TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
+			}, nil
+		}
+	}
+	for _, testIDP := range t.upstreamLocalUserIdentityProviders {
+		if upstreamIDPDisplayName == testIDP.DisplayNameForFederationDomain {
+			return &resolvedlocaluser.FederationDomainResolvedLocalUserIdentityProvider{
+				DisplayName:             testIDP.DisplayNameForFederationDomain,
+				Provider:                testIDP,
+				SessionProviderType:     psession.ProviderTypeLocalUser,
+				Transforms:              testIDP.TransformsForFederationDomain,
+				TokenExchangeTransforms: testIDP.TokenExchangeTransformsForFederationDomain,
 			}, nil
 		}
 	}
@@ -152,6 +232,18 @@ func (t *TestFederationDomainIdentityProvidersListerFinder) SetGitHubIdentityPro
 	t.upstreamGitHubIdentityProviders = providers
 }
 
+func (t *TestFederationDomainIdentityProvidersListerFinder) SetSAMLIdentityProviders(providers []*oidctestutil.TestUpstreamSAMLIdentityProvider) {
+	t.upstreamSAMLIdentityProviders = providers
+}
+
+func (t *TestFederationDomainIdentityProvidersListerFinder) SetOAuth2IdentityProviders(providers []*oidctestutil.TestUpstreamOAuth2IdentityProvider) {
+	t.upstreamOAuth2IdentityProviders = providers
+}
+
+func (t *TestFederationDomainIdentityProvidersListerFinder) SetLocalUserIdentityProviders(providers []*oidctestutil.TestUpstreamLocalUserIdentityProvider) {
+	t.upstreamLocalUserIdentityProviders = providers
+}
+
 // UpstreamIDPListerBuilder can be used to build either a dynamicupstreamprovider.DynamicUpstreamIDPProvider
 // or a FederationDomainIdentityProvidersListerFinderI for testing.
 type UpstreamIDPListerBuilder struct {
@@ -159,6 +251,9 @@ type UpstreamIDPListerBuilder struct {
 	upstreamLDAPIdentityProviders            []*oidctestutil.TestUpstreamLDAPIdentityProvider
 	upstreamActiveDirectoryIdentityProviders []*oidctestutil.TestUpstreamLDAPIdentityProvider
 	upstreamGitHubIdentityProviders          []*oidctestutil.TestUpstreamGitHubIdentityProvider
+	upstreamSAMLIdentityProviders            []*oidctestutil.TestUpstreamSAMLIdentityProvider
+	upstreamOAuth2IdentityProviders          []*oidctestutil.TestUpstreamOAuth2IdentityProvider
+	upstreamLocalUserIdentityProviders       []*oidctestutil.TestUpstreamLocalUserIdentityProvider
 	defaultIDPDisplayName                    string
 }
 
@@ -182,6 +277,21 @@ func (b *UpstreamIDPListerBuilder) WithGitHub(upstreamGithubIdentityProviders ..
 	return b
 }
 
+func (b *UpstreamIDPListerBuilder) WithSAML(upstreamSAMLIdentityProviders ...*oidctestutil.TestUpstreamSAMLIdentityProvider) *UpstreamIDPListerBuilder {
+	b.upstreamSAMLIdentityProviders = slices.Concat(b.upstreamSAMLIdentityProviders, upstreamSAMLIdentityProviders)
+	return b
+}
+
+func (b *UpstreamIDPListerBuilder) WithOAuth2(upstreamOAuth2IdentityProviders ...*oidctestutil.TestUpstreamOAuth2IdentityProvider) *UpstreamIDPListerBuilder {
+	b.upstreamOAuth2IdentityProviders = slices.Concat(b.upstreamOAuth2IdentityProviders, upstreamOAuth2IdentityProviders)
+	return b
+}
+
+func (b *UpstreamIDPListerBuilder) WithLocalUser(upstreamLocalUserIdentityProviders ...*oidctestutil.TestUpstreamLocalUserIdentityProvider) *UpstreamIDPListerBuilder {
+	b.upstreamLocalUserIdentityProviders = slices.Concat(b.upstreamLocalUserIdentityProviders, upstreamLocalUserIdentityProviders)
+	return b
+}
+
 func (b *UpstreamIDPListerBuilder) WithDefaultIDPDisplayName(defaultIDPDisplayName string) *UpstreamIDPListerBuilder {
 	b.defaultIDPDisplayName = defaultIDPDisplayName
 	return b
@@ -193,6 +303,9 @@ func (b *UpstreamIDPListerBuilder) BuildFederationDomainIdentityProvidersListerF
 		upstreamLDAPIdentityProviders:            b.upstreamLDAPIdentityProviders,
 		upstreamActiveDirectoryIdentityProviders: b.upstreamActiveDirectoryIdentityProviders,
 		upstreamGitHubIdentityProviders:          b.upstreamGitHubIdentityProviders,
+		upstreamSAMLIdentityProviders:            b.upstreamSAMLIdentityProviders,
+		upstreamOAuth2IdentityProviders:          b.upstreamOAuth2IdentityProviders,
+		upstreamLocalUserIdentityProviders:       b.upstreamLocalUserIdentityProviders,
 		defaultIDPDisplayName:                    b.defaultIDPDisplayName,
 	}
 }
@@ -224,6 +337,24 @@ func (b *UpstreamIDPListerBuilder) BuildDynamicUpstreamIDPProvider() dynamicupst
 	}
 	idpProvider.SetGitHubIdentityProviders(githubUpstreams)
 
+	samlUpstreams := make([]upstreamprovider.UpstreamSAMLIdentityProviderI, len(b.upstreamSAMLIdentityProviders))
+	for i := range b.upstreamSAMLIdentityProviders {
+		samlUpstreams[i] = upstreamprovider.UpstreamSAMLIdentityProviderI(b.upstreamSAMLIdentityProviders[i])
+	}
+	idpProvider.SetSAMLIdentityProviders(samlUpstreams)
+
+	oauth2Upstreams := make([]upstreamprovider.UpstreamOAuth2IdentityProviderI, len(b.upstreamOAuth2IdentityProviders))
+	for i := range b.upstreamOAuth2IdentityProviders {
+		oauth2Upstreams[i] = upstreamprovider.UpstreamOAuth2IdentityProviderI(b.upstreamOAuth2IdentityProviders[i])
+	}
+	idpProvider.SetOAuth2IdentityProviders(oauth2Upstreams)
+
+	localUserUpstreams := make([]upstreamprovider.UpstreamLocalUserIdentityProviderI, len(b.upstreamLocalUserIdentityProviders))
+	for i := range b.upstreamLocalUserIdentityProviders {
+		localUserUpstreams[i] = upstreamprovider.UpstreamLocalUserIdentityProviderI(b.upstreamLocalUserIdentityProviders[i])
+	}
+	idpProvider.SetLocalUserIdentityProviders(localUserUpstreams)
+
 	return idpProvider
 }
 
@@ -316,6 +447,58 @@ func (b *UpstreamIDPListerBuilder) RequireExactlyOneGitHubAuthcodeExchange(
 	require.Equal(t, expectedArgs, actualArgs)
 }
 
+func (b *UpstreamIDPListerBuilder) RequireExactlyOneSAMLResponseValidation(
+	t *testing.T,
+	expectedPerformedByUpstreamName string,
+	expectedArgs *oidctestutil.ValidateResponseArgs,
+) {
+	t.Helper()
+	var actualArgs *oidctestutil.ValidateResponseArgs
+	var actualNameOfUpstreamWhichMadeCall string
+	actualCallCount := 0
+	for _, upstream := range b.upstreamSAMLIdentityProviders {
+		callCountOnThisUpstream := upstream.ValidateResponseCallCount()
+		actualCallCount += callCountOnThisUpstream
+		if callCountOnThisUpstream == 1 {
+			actualNameOfUpstreamWhichMadeCall = upstream.Name
+			actualArgs = upstream.ValidateResponseArgs(0)
+		}
+	}
+	require.Equal(t, 1, actualCallCount,
+		"expected exactly one call to SAML ValidateResponse()",
+	)
+	require.Equal(t, expectedPerformedByUpstreamName, actualNameOfUpstreamWhichMadeCall,
+		"SAML ValidateResponse() was called on the wrong upstream name",
+	)
+	require.Equal(t, expectedArgs, actualArgs)
+}
+
+func (b *UpstreamIDPListerBuilder) RequireExactlyOneOAuth2AuthcodeExchange(
+	t *testing.T,
+	expectedPerformedByUpstreamName string,
+	expectedArgs *oidctestutil.ExchangeOAuth2AuthcodeArgs,
+) {
+	t.Helper()
+	var actualArgs *oidctestutil.ExchangeOAuth2AuthcodeArgs
+	var actualNameOfUpstreamWhichMadeCall string
+	actualCallCount := 0
+	for _, upstream := range b.upstreamOAuth2IdentityProviders {
+		callCountOnThisUpstream := upstream.ExchangeAuthcodeCallCount()
+		actualCallCount += callCountOnThisUpstream
+		if callCountOnThisUpstream == 1 {
+			actualNameOfUpstreamWhichMadeCall = upstream.Name
+			actualArgs = upstream.ExchangeAuthcodeArgs(0)
+		}
+	}
+	require.Equal(t, 1, actualCallCount,
+		"expected exactly one call to OAuth2 ExchangeAuthcode()",
+	)
+	require.Equal(t, expectedPerformedByUpstreamName, actualNameOfUpstreamWhichMadeCall,
+		"OAuth2 ExchangeAuthcode() was called on the wrong upstream name",
+	)
+	require.Equal(t, expectedArgs, actualArgs)
+}
+
 func (b *UpstreamIDPListerBuilder) RequireExactlyZeroAuthcodeExchanges(t *testing.T) {
 	t.Helper()
 	actualCallCount := 0
@@ -325,9 +508,15 @@ func (b *UpstreamIDPListerBuilder) RequireExactlyZeroAuthcodeExchanges(t *testin
 	for _, upstreamGitHub := range b.upstreamGitHubIdentityProviders {
 		actualCallCount += upstreamGitHub.ExchangeAuthcodeCallCount()
 	}
+	for _, upstreamSAML := range b.upstreamSAMLIdentityProviders {
+		actualCallCount += upstreamSAML.ValidateResponseCallCount()
+	}
+	for _, upstreamOAuth2 := range b.upstreamOAuth2IdentityProviders {
+		actualCallCount += upstreamOAuth2.ExchangeAuthcodeCallCount()
+	}
 
 	require.Equal(t, 0, actualCallCount,
-		"expected exactly zero calls to OIDC ExchangeAuthcodeAndValidateTokens() or GitHub ExchangeAuthcode()",
+		"expected exactly zero calls to OIDC ExchangeAuthcodeAndValidateTokens(), GitHub ExchangeAuthcode(), SAML ValidateResponse(), or OAuth2 ExchangeAuthcode()",
 	)
 }
 
@@ -449,6 +638,13 @@ func (b *UpstreamIDPListerBuilder) CountAllCallsToAnyUpstreamRefresh() int {
 	for _, upstreamGithub := range b.upstreamGitHubIdentityProviders {
 		actualCallCountAcrossAllUpstreams += upstreamGithub.GetUserCallCount()
 	}
+	for _, upstreamOAuth2 := range b.upstreamOAuth2IdentityProviders {
+		// OAuth2 always calls GetUser during refresh, but only calls PerformRefresh when the session has a refresh token.
+		actualCallCountAcrossAllUpstreams += upstreamOAuth2.GetUserCallCount()
+	}
+	for _, upstreamLocalUser := range b.upstreamLocalUserIdentityProviders {
+		actualCallCountAcrossAllUpstreams += upstreamLocalUser.PerformRefreshCallCount()
+	}
 	return actualCallCountAcrossAllUpstreams
 }
 
@@ -497,31 +693,42 @@ func (b *UpstreamIDPListerBuilder) RequireExactlyOneCallToRevokeToken(
 	t.Helper()
 	var actualArgs *oidctestutil.RevokeTokenArgs
 	var actualNameOfUpstreamWhichMadeCall string
-	actualCallCountAcrossAllOIDCUpstreams := 0
+	actualCallCountAcrossAllUpstreams := 0
 	for _, upstreamOIDC := range b.upstreamOIDCIdentityProviders {
 		callCountOnThisUpstream := upstreamOIDC.RevokeTokenCallCount()
-		actualCallCountAcrossAllOIDCUpstreams += callCountOnThisUpstream
+		actualCallCountAcrossAllUpstreams += callCountOnThisUpstream
 		if callCountOnThisUpstream == 1 {
 			actualNameOfUpstreamWhichMadeCall = upstreamOIDC.Name
 			actualArgs = upstreamOIDC.RevokeTokenArgs(0)
 		}
 	}
-	require.Equal(t, 1, actualCallCountAcrossAllOIDCUpstreams,
-		"should have been exactly one call to RevokeToken() by all OIDC upstreams",
+	for _, upstreamOAuth2 := range b.upstreamOAuth2IdentityProviders {
+		callCountOnThisUpstream := upstreamOAuth2.RevokeTokenCallCount()
+		actualCallCountAcrossAllUpstreams += callCountOnThisUpstream
+		if callCountOnThisUpstream == 1 {
+			actualNameOfUpstreamWhichMadeCall = upstreamOAuth2.Name
+			actualArgs = upstreamOAuth2.RevokeTokenArgs(0)
+		}
+	}
+	require.Equal(t, 1, actualCallCountAcrossAllUpstreams,
+		"should have been exactly one call to RevokeToken() by all OIDC and OAuth2 upstreams",
 	)
 	require.Equal(t, expectedPerformedByUpstreamName, actualNameOfUpstreamWhichMadeCall,
-		"RevokeToken() was called on the wrong OIDC upstream",
+		"RevokeToken() was called on the wrong upstream",
 	)
 	require.Equal(t, expectedArgs, actualArgs)
 }
 
 func (b *UpstreamIDPListerBuilder) RequireExactlyZeroCallsToRevokeToken(t *testing.T) {
 	t.Helper()
-	actualCallCountAcrossAllOIDCUpstreams := 0
+	actualCallCountAcrossAllUpstreams := 0
 	for _, upstreamOIDC := range b.upstreamOIDCIdentityProviders {
-		actualCallCountAcrossAllOIDCUpstreams += upstreamOIDC.RevokeTokenCallCount()
+		actualCallCountAcrossAllUpstreams += upstreamOIDC.RevokeTokenCallCount()
 	}
-	require.Equal(t, 0, actualCallCountAcrossAllOIDCUpstreams,
+	for _, upstreamOAuth2 := range b.upstreamOAuth2IdentityProviders {
+		actualCallCountAcrossAllUpstreams += upstreamOAuth2.RevokeTokenCallCount()
+	}
+	require.Equal(t, 0, actualCallCountAcrossAllUpstreams,
 		"expected exactly zero calls to RevokeToken()",
 	)
 }
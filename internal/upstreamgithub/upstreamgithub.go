@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package upstreamgithub implements an abstraction of upstream GitHub provider interactions.
@@ -7,7 +7,9 @@ package upstreamgithub
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
 	"golang.org/x/oauth2"
@@ -21,6 +23,9 @@ import (
 	"go.pinniped.dev/internal/setutil"
 )
 
+// The largest response body which will be read when probing the GitHub API.
+const maxProbeResponseBytes = 1024 * 1024
+
 // ProviderConfig holds the active configuration of an upstream GitHub provider.
 type ProviderConfig struct {
 	Name        string
@@ -37,6 +42,20 @@ type ProviderConfig struct {
 	// AllowedOrganizations, when empty, means to allow users from all orgs.
 	AllowedOrganizations *setutil.CaseInsensitiveSet
 
+	// AllowedTeams and AllowedRepositories, when both empty, mean that users do not need to satisfy any team or
+	// repository rules to log in. Otherwise, users must satisfy at least one of the team or repository rules.
+	// Teams are in the form "org/team-slug".
+	AllowedTeams        *setutil.CaseInsensitiveSet
+	AllowedRepositories []RepositoryAllowRule
+
+	// RepositoryRoleGroups lists the repositories, in the form "owner/repo", for which the user's role should be
+	// added to the user's groups in the form "owner/repo:role".
+	RepositoryRoleGroups []string
+
+	// AppClient, when not nil, is used to look up the user's roles on repositories using the credentials of a GitHub App.
+	// Otherwise, the user's access token is used to look up the user's roles on repositories.
+	AppClient githubclient.GitHubAppInterface
+
 	// HttpClient is a client that can be used to call the GitHub APIs and token endpoint.
 	// This client should be configured with the user-provided CA bundle and a timeout.
 	HttpClient *http.Client
@@ -50,12 +69,30 @@ type ProviderConfig struct {
 	OAuth2Config *oauth2.Config
 }
 
+// RepositoryAllowRule allows users who have at least MinimumRole on the repository to log in.
+type RepositoryAllowRule struct {
+	// Repository is in the form "owner/repo".
+	Repository  string
+	MinimumRole idpv1alpha1.GitHubRepositoryRole
+}
+
+// repositoryRoleRanks orders the repository roles from least privileged to most privileged.
+// Unknown roles, including githubclient.RepositoryRoleNone, have rank zero.
+var repositoryRoleRanks = map[string]int{ //nolint:gochecknoglobals
+	githubclient.RepositoryRoleRead:     1,
+	githubclient.RepositoryRoleTriage:   2,
+	githubclient.RepositoryRoleWrite:    3,
+	githubclient.RepositoryRoleMaintain: 4,
+	githubclient.RepositoryRoleAdmin:    5,
+}
+
 type Provider struct {
 	c                 ProviderConfig
 	buildGitHubClient func(httpClient *http.Client, apiBaseURL, token string) (githubclient.GitHubInterface, error)
 }
 
 var _ upstreamprovider.UpstreamGithubIdentityProviderI = &Provider{}
+var _ upstreamprovider.UpstreamHealthProberI = &Provider{}
 
 // New creates a Provider. The config is not a pointer to ensure that a copy of the config is created,
 // making the resulting Provider use an effectively read-only configuration.
@@ -168,6 +205,30 @@ func (p *Provider) GetUser(ctx context.Context, accessToken string, idpDisplayNa
 		return nil, err
 	}
 
+	repositoryRoles, err := p.getRepositoryRoles(ctx, githubClient, userInfo.Login)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isAllowedByTeamOrRepositoryRules(teamMembership, repositoryRoles) {
+		plog.Warning("user is not allowed to log in due to team and repository policy", // do not log username to avoid PII
+			"configuredAllowedTeams", p.c.AllowedTeams,
+			"configuredAllowedRepositories", p.c.AllowedRepositories,
+			"identityProviderDisplayName", idpDisplayName,
+			"identityProviderResourceName", p.GetResourceName())
+		plog.Trace("user is not allowed to log in due to team and repository policy", // okay to log PII at trace level
+			"githubLogin", userInfo.Login,
+			"githubID", userInfo.ID,
+			"calculatedUsername", githubUser.Username,
+			"userBelongsToTeams", teamMembership,
+			"userRepositoryRoles", repositoryRoles,
+			"configuredAllowedTeams", p.c.AllowedTeams,
+			"configuredAllowedRepositories", p.c.AllowedRepositories,
+			"identityProviderDisplayName", idpDisplayName,
+			"identityProviderResourceName", p.GetResourceName())
+		return nil, upstreamprovider.NewGitHubLoginDeniedError("user is not allowed to log in due to team and repository policy")
+	}
+
 	for _, team := range teamMembership {
 		downstreamGroup := ""
 
@@ -183,9 +244,102 @@ func (p *Provider) GetUser(ctx context.Context, accessToken string, idpDisplayNa
 		githubUser.Groups = append(githubUser.Groups, downstreamGroup)
 	}
 
+	teams := make([]any, 0, len(teamMembership))
+	for _, team := range teamMembership {
+		teams = append(teams, map[string]any{"organization": team.Org, "name": team.Name, "slug": team.Slug})
+	}
+	githubUser.UpstreamData = map[string]any{
+		"login":           userInfo.Login,
+		"id":              userInfo.ID,
+		"organizations":   orgMembership,
+		"teams":           teams,
+		"repositoryRoles": repositoryRoles,
+	}
+
+	for _, repository := range p.c.RepositoryRoleGroups {
+		if role := repositoryRoles[strings.ToLower(repository)]; role != githubclient.RepositoryRoleNone {
+			githubUser.Groups = append(githubUser.Groups, fmt.Sprintf("%s:%s", repository, role))
+		}
+	}
+
 	return &githubUser, nil
 }
 
+// getRepositoryRoles looks up the user's role on each repository which is used by the configuration.
+// The returned map is keyed by the lowercase "owner/repo".
+func (p *Provider) getRepositoryRoles(ctx context.Context, githubClient githubclient.GitHubInterface, login string) (map[string]string, error) {
+	repositories := make([]string, 0, len(p.c.RepositoryRoleGroups)+len(p.c.AllowedRepositories))
+	repositories = append(repositories, p.c.RepositoryRoleGroups...)
+	for _, rule := range p.c.AllowedRepositories {
+		repositories = append(repositories, rule.Repository)
+	}
+
+	repositoryRoles := map[string]string{}
+	for _, repository := range repositories {
+		key := strings.ToLower(repository)
+		if _, alreadyFetched := repositoryRoles[key]; alreadyFetched {
+			continue
+		}
+
+		owner, repo, _ := strings.Cut(repository, "/")
+		var role string
+		var err error
+		if p.c.AppClient != nil {
+			role, err = p.c.AppClient.GetRepositoryRoleForUser(ctx, owner, repo, login)
+		} else {
+			role, err = githubClient.GetRepositoryRole(ctx, owner, repo)
+		}
+		if err != nil {
+			return nil, err
+		}
+		repositoryRoles[key] = role
+	}
+
+	return repositoryRoles, nil
+}
+
+func (p *Provider) isAllowedByTeamOrRepositoryRules(teamMembership []githubclient.TeamInfo, repositoryRoles map[string]string) bool {
+	if p.c.AllowedTeams.Empty() && len(p.c.AllowedRepositories) == 0 {
+		return true
+	}
+
+	for _, team := range teamMembership {
+		if p.c.AllowedTeams.ContainsIgnoringCase(fmt.Sprintf("%s/%s", team.Org, team.Slug)) {
+			return true
+		}
+	}
+
+	for _, rule := range p.c.AllowedRepositories {
+		rank := repositoryRoleRanks[repositoryRoles[strings.ToLower(rule.Repository)]]
+		if rank > 0 && rank >= repositoryRoleRanks[string(rule.MinimumRole)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ProbeHealth checks that the GitHub API is reachable by making an unauthenticated request to its base URL.
+// Unauthenticated requests may be rate limited, so any response which is not a server error means that
+// the GitHub API is reachable. Implements upstreamprovider.UpstreamHealthProberI.
+func (p *Provider) ProbeHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.c.APIBaseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxProbeResponseBytes))
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("unexpected response status %q from %s", resp.Status, p.c.APIBaseURL)
+	}
+	return nil
+}
+
 // GetConfig returns the config. This is not part of the UpstreamGithubIdentityProviderI interface and is just for testing.
 func (p *Provider) GetConfig() ProviderConfig {
 	return p.c
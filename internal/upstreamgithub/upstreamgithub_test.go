@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package upstreamgithub
@@ -9,6 +9,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -189,6 +191,67 @@ func TestExchangeAuthcode(t *testing.T) {
 	}
 }
 
+func TestProbeHealth(t *testing.T) {
+	tests := []struct {
+		name              string
+		returnStatusCode  int
+		unreachableServer bool
+		wantErr           string
+	}{
+		{
+			name:             "success when the API returns 200 OK",
+			returnStatusCode: http.StatusOK,
+		},
+		{
+			name:             "success when the API rate limits the unauthenticated request",
+			returnStatusCode: http.StatusForbidden,
+		},
+		{
+			name:             "error when the API returns a server error",
+			returnStatusCode: http.StatusBadGateway,
+			wantErr:          `unexpected response status "502 Bad Gateway" from {{.URL}}/api/v3/`,
+		},
+		{
+			name:              "error when the API is unreachable",
+			unreachableServer: true,
+			wantErr:           `Get "{{.URL}}/api/v3/": dial tcp {{.Host}}: connect: connection refused`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			numRequests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				numRequests++
+				require.Equal(t, http.MethodGet, r.Method)
+				require.Equal(t, "/api/v3/", r.URL.Path)
+				require.Empty(t, r.Header.Get("Authorization"))
+				w.WriteHeader(test.returnStatusCode)
+			}))
+			t.Cleanup(server.Close)
+
+			subject := New(ProviderConfig{
+				APIBaseURL: server.URL + "/api/v3/",
+				HttpClient: server.Client(),
+			})
+
+			if test.unreachableServer {
+				server.Close() // make the server unreachable by closing it before making any requests
+			}
+
+			err := subject.ProbeHealth(context.Background())
+			if test.wantErr != "" {
+				wantErr := strings.NewReplacer("{{.URL}}", server.URL, "{{.Host}}", server.Listener.Addr().String()).Replace(test.wantErr)
+				require.EqualError(t, err, wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 1, numRequests)
+		})
+	}
+}
+
 func TestGetUser(t *testing.T) {
 	const idpDisplayName = "idp display name 😀"
 	const encodedIDPDisplayName = "idp+display+name+%F0%9F%98%80"
@@ -199,11 +262,22 @@ func TestGetUser(t *testing.T) {
 		Timeout: 1234509,
 	}
 
+	wantUpstreamData := func(organizations []string, teams []any, repositoryRoles map[string]string) map[string]any {
+		return map[string]any{
+			"login":           "some-github-login",
+			"id":              "some-github-id",
+			"organizations":   organizations,
+			"teams":           teams,
+			"repositoryRoles": repositoryRoles,
+		}
+	}
+
 	tests := []struct {
 		name                   string
 		providerConfig         ProviderConfig
 		buildGitHubClientError error
 		buildMockResponses     func(hubInterface *mockgithubclient.MockGitHubInterface)
+		buildMockAppResponses  func(appInterface *mockgithubclient.MockGitHubAppInterface)
 		wantUser               *upstreamprovider.GitHubUser
 		wantErrMsg             string
 		wantErr                error
@@ -226,6 +300,7 @@ func TestGetUser(t *testing.T) {
 			wantUser: &upstreamprovider.GitHubUser{
 				Username:          "some-github-login:some-github-id",
 				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData:      wantUpstreamData(nil, []any{}, map[string]string{}),
 			},
 		},
 		{
@@ -246,6 +321,7 @@ func TestGetUser(t *testing.T) {
 			wantUser: &upstreamprovider.GitHubUser{
 				Username:          "some-github-login",
 				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData:      wantUpstreamData(nil, []any{}, map[string]string{}),
 			},
 		},
 		{
@@ -266,6 +342,7 @@ func TestGetUser(t *testing.T) {
 			wantUser: &upstreamprovider.GitHubUser{
 				Username:          "some-github-id",
 				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData:      wantUpstreamData(nil, []any{}, map[string]string{}),
 			},
 		},
 		{
@@ -287,6 +364,7 @@ func TestGetUser(t *testing.T) {
 			wantUser: &upstreamprovider.GitHubUser{
 				Username:          "some-github-login:some-github-id",
 				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData:      wantUpstreamData([]string{"allowed-org2"}, []any{}, map[string]string{}),
 			},
 		},
 		{
@@ -343,6 +421,11 @@ func TestGetUser(t *testing.T) {
 				Username:          "some-github-login:some-github-id",
 				Groups:            []string{"org1-name/org1-team1-name", "org1-name/org1-team2-name", "org2-name/org2-team1-name"},
 				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData: wantUpstreamData([]string{"allowed-org2"}, []any{
+					map[string]any{"organization": "org1-name", "name": "org1-team1-name", "slug": "org1-team1-slug"},
+					map[string]any{"organization": "org1-name", "name": "org1-team2-name", "slug": "org1-team2-slug"},
+					map[string]any{"organization": "org2-name", "name": "org2-team1-name", "slug": "org2-team1-slug"},
+				}, map[string]string{}),
 			},
 		},
 		{
@@ -382,6 +465,217 @@ func TestGetUser(t *testing.T) {
 				Username:          "some-github-login:some-github-id",
 				Groups:            []string{"org1-name/org1-team1-slug", "org1-name/org1-team2-slug", "org2-name/org2-team1-slug"},
 				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData: wantUpstreamData([]string{"allowed-org2"}, []any{
+					map[string]any{"organization": "org1-name", "name": "org1-team1-name", "slug": "org1-team1-slug"},
+					map[string]any{"organization": "org1-name", "name": "org1-team2-name", "slug": "org1-team2-slug"},
+					map[string]any{"organization": "org2-name", "name": "org2-team1-name", "slug": "org2-team1-slug"},
+				}, map[string]string{}),
+			},
+		},
+		{
+			name: "happy path with user in allowed teams, matching team slugs case-insensitively",
+			providerConfig: ProviderConfig{
+				APIBaseURL:           "https://some-url",
+				HttpClient:           someHttpClient,
+				UsernameAttribute:    idpv1alpha1.GitHubUsernameLogin,
+				GroupNameAttribute:   idpv1alpha1.GitHubUseTeamSlugForGroupName,
+				AllowedOrganizations: setutil.NewCaseInsensitiveSet("org1"),
+				AllowedTeams:         setutil.NewCaseInsensitiveSet("ORG1/Team1-Slug"),
+			},
+			buildMockResponses: func(mockGitHubInterface *mockgithubclient.MockGitHubInterface) {
+				mockGitHubInterface.EXPECT().GetUserInfo(someContext).Return(&githubclient.UserInfo{
+					Login: "some-github-login",
+					ID:    "some-github-id",
+				}, nil)
+				mockGitHubInterface.EXPECT().GetOrgMembership(someContext).Return([]string{"org1"}, nil)
+				mockGitHubInterface.EXPECT().GetTeamMembership(someContext, setutil.NewCaseInsensitiveSet("org1")).Return([]githubclient.TeamInfo{
+					{
+						Name: "Team1 Name",
+						Slug: "team1-slug",
+						Org:  "org1",
+					},
+				}, nil)
+			},
+			wantUser: &upstreamprovider.GitHubUser{
+				Username:          "some-github-login",
+				Groups:            []string{"org1/team1-slug"},
+				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData:      wantUpstreamData([]string{"org1"}, []any{map[string]any{"organization": "org1", "name": "Team1 Name", "slug": "team1-slug"}}, map[string]string{}),
+			},
+		},
+		{
+			name: "returns error when the user does not belong to the allowed teams",
+			providerConfig: ProviderConfig{
+				APIBaseURL:           "https://some-url",
+				HttpClient:           someHttpClient,
+				UsernameAttribute:    idpv1alpha1.GitHubUsernameLogin,
+				GroupNameAttribute:   idpv1alpha1.GitHubUseTeamSlugForGroupName,
+				AllowedOrganizations: setutil.NewCaseInsensitiveSet("org1"),
+				AllowedTeams:         setutil.NewCaseInsensitiveSet("org1/other-team"),
+			},
+			buildMockResponses: func(mockGitHubInterface *mockgithubclient.MockGitHubInterface) {
+				mockGitHubInterface.EXPECT().GetUserInfo(someContext).Return(&githubclient.UserInfo{
+					Login: "some-github-login",
+					ID:    "some-github-id",
+				}, nil)
+				mockGitHubInterface.EXPECT().GetOrgMembership(someContext).Return([]string{"org1"}, nil)
+				mockGitHubInterface.EXPECT().GetTeamMembership(someContext, setutil.NewCaseInsensitiveSet("org1")).Return([]githubclient.TeamInfo{
+					{
+						Name: "Team1 Name",
+						Slug: "team1-slug",
+						Org:  "org1",
+					},
+				}, nil)
+			},
+			wantErr: upstreamprovider.NewGitHubLoginDeniedError("user is not allowed to log in due to team and repository policy"),
+		},
+		{
+			name: "happy path with user who has at least the minimum role on an allowed repository",
+			providerConfig: ProviderConfig{
+				APIBaseURL:           "https://some-url",
+				HttpClient:           someHttpClient,
+				UsernameAttribute:    idpv1alpha1.GitHubUsernameLogin,
+				GroupNameAttribute:   idpv1alpha1.GitHubUseTeamSlugForGroupName,
+				AllowedOrganizations: setutil.NewCaseInsensitiveSet("org1"),
+				AllowedTeams:         setutil.NewCaseInsensitiveSet("org1/other-team"),
+				AllowedRepositories: []RepositoryAllowRule{
+					{Repository: "org1/repo1", MinimumRole: idpv1alpha1.GitHubRepositoryRoleAdmin},
+					{Repository: "org1/repo2", MinimumRole: idpv1alpha1.GitHubRepositoryRoleWrite},
+				},
+			},
+			buildMockResponses: func(mockGitHubInterface *mockgithubclient.MockGitHubInterface) {
+				mockGitHubInterface.EXPECT().GetUserInfo(someContext).Return(&githubclient.UserInfo{
+					Login: "some-github-login",
+					ID:    "some-github-id",
+				}, nil)
+				mockGitHubInterface.EXPECT().GetOrgMembership(someContext).Return([]string{"org1"}, nil)
+				mockGitHubInterface.EXPECT().GetTeamMembership(someContext, setutil.NewCaseInsensitiveSet("org1")).Return([]githubclient.TeamInfo{
+					{
+						Name: "Team1 Name",
+						Slug: "team1-slug",
+						Org:  "org1",
+					},
+				}, nil)
+				mockGitHubInterface.EXPECT().GetRepositoryRole(someContext, "org1", "repo1").Return(githubclient.RepositoryRoleMaintain, nil)
+				mockGitHubInterface.EXPECT().GetRepositoryRole(someContext, "org1", "repo2").Return(githubclient.RepositoryRoleMaintain, nil)
+			},
+			wantUser: &upstreamprovider.GitHubUser{
+				Username:          "some-github-login",
+				Groups:            []string{"org1/team1-slug"},
+				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData: wantUpstreamData([]string{"org1"}, []any{map[string]any{"organization": "org1", "name": "Team1 Name", "slug": "team1-slug"}}, map[string]string{
+					"org1/repo1": githubclient.RepositoryRoleMaintain,
+					"org1/repo2": githubclient.RepositoryRoleMaintain,
+				}),
+			},
+		},
+		{
+			name: "returns error when the user does not have the minimum role on any allowed repository",
+			providerConfig: ProviderConfig{
+				APIBaseURL:           "https://some-url",
+				HttpClient:           someHttpClient,
+				UsernameAttribute:    idpv1alpha1.GitHubUsernameLogin,
+				GroupNameAttribute:   idpv1alpha1.GitHubUseTeamSlugForGroupName,
+				AllowedOrganizations: setutil.NewCaseInsensitiveSet("org1"),
+				AllowedRepositories: []RepositoryAllowRule{
+					{Repository: "org1/repo1", MinimumRole: idpv1alpha1.GitHubRepositoryRoleTriage},
+					{Repository: "org1/repo2", MinimumRole: idpv1alpha1.GitHubRepositoryRoleRead},
+				},
+			},
+			buildMockResponses: func(mockGitHubInterface *mockgithubclient.MockGitHubInterface) {
+				mockGitHubInterface.EXPECT().GetUserInfo(someContext).Return(&githubclient.UserInfo{
+					Login: "some-github-login",
+					ID:    "some-github-id",
+				}, nil)
+				mockGitHubInterface.EXPECT().GetOrgMembership(someContext).Return([]string{"org1"}, nil)
+				mockGitHubInterface.EXPECT().GetTeamMembership(someContext, setutil.NewCaseInsensitiveSet("org1")).Return([]githubclient.TeamInfo{
+					{
+						Name: "Team1 Name",
+						Slug: "team1-slug",
+						Org:  "org1",
+					},
+				}, nil)
+				mockGitHubInterface.EXPECT().GetRepositoryRole(someContext, "org1", "repo1").Return(githubclient.RepositoryRoleRead, nil)
+				mockGitHubInterface.EXPECT().GetRepositoryRole(someContext, "org1", "repo2").Return(githubclient.RepositoryRoleNone, nil)
+			},
+			wantErr: upstreamprovider.NewGitHubLoginDeniedError("user is not allowed to log in due to team and repository policy"),
+		},
+		{
+			name: "happy path with repository role groups, fetching the role for each repository only once",
+			providerConfig: ProviderConfig{
+				APIBaseURL:           "https://some-url",
+				HttpClient:           someHttpClient,
+				UsernameAttribute:    idpv1alpha1.GitHubUsernameLogin,
+				GroupNameAttribute:   idpv1alpha1.GitHubUseTeamSlugForGroupName,
+				AllowedOrganizations: setutil.NewCaseInsensitiveSet("org1"),
+				AllowedRepositories: []RepositoryAllowRule{
+					{Repository: "ORG1/Repo1", MinimumRole: idpv1alpha1.GitHubRepositoryRoleRead},
+				},
+				RepositoryRoleGroups: []string{"org1/repo1", "org1/repo2", "org1/repo3"},
+			},
+			buildMockResponses: func(mockGitHubInterface *mockgithubclient.MockGitHubInterface) {
+				mockGitHubInterface.EXPECT().GetUserInfo(someContext).Return(&githubclient.UserInfo{
+					Login: "some-github-login",
+					ID:    "some-github-id",
+				}, nil)
+				mockGitHubInterface.EXPECT().GetOrgMembership(someContext).Return([]string{"org1"}, nil)
+				mockGitHubInterface.EXPECT().GetTeamMembership(someContext, setutil.NewCaseInsensitiveSet("org1")).Return([]githubclient.TeamInfo{
+					{
+						Name: "Team1 Name",
+						Slug: "team1-slug",
+						Org:  "org1",
+					},
+				}, nil)
+				mockGitHubInterface.EXPECT().GetRepositoryRole(someContext, "org1", "repo1").Return(githubclient.RepositoryRoleTriage, nil)
+				mockGitHubInterface.EXPECT().GetRepositoryRole(someContext, "org1", "repo2").Return(githubclient.RepositoryRoleNone, nil)
+				mockGitHubInterface.EXPECT().GetRepositoryRole(someContext, "org1", "repo3").Return(githubclient.RepositoryRoleAdmin, nil)
+			},
+			wantUser: &upstreamprovider.GitHubUser{
+				Username:          "some-github-login",
+				Groups:            []string{"org1/team1-slug", "org1/repo1:triage", "org1/repo3:admin"},
+				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData: wantUpstreamData([]string{"org1"}, []any{map[string]any{"organization": "org1", "name": "Team1 Name", "slug": "team1-slug"}}, map[string]string{
+					"org1/repo1": githubclient.RepositoryRoleTriage,
+					"org1/repo2": githubclient.RepositoryRoleNone,
+					"org1/repo3": githubclient.RepositoryRoleAdmin,
+				}),
+			},
+		},
+		{
+			name: "happy path using a GitHub App to look up repository roles",
+			providerConfig: ProviderConfig{
+				APIBaseURL:           "https://some-url",
+				HttpClient:           someHttpClient,
+				UsernameAttribute:    idpv1alpha1.GitHubUsernameLogin,
+				GroupNameAttribute:   idpv1alpha1.GitHubUseTeamSlugForGroupName,
+				AllowedOrganizations: setutil.NewCaseInsensitiveSet("org1"),
+				AllowedRepositories: []RepositoryAllowRule{
+					{Repository: "org1/repo1", MinimumRole: idpv1alpha1.GitHubRepositoryRoleWrite},
+				},
+				RepositoryRoleGroups: []string{"org1/repo1"},
+			},
+			buildMockResponses: func(mockGitHubInterface *mockgithubclient.MockGitHubInterface) {
+				mockGitHubInterface.EXPECT().GetUserInfo(someContext).Return(&githubclient.UserInfo{
+					Login: "some-github-login",
+					ID:    "some-github-id",
+				}, nil)
+				mockGitHubInterface.EXPECT().GetOrgMembership(someContext).Return([]string{"org1"}, nil)
+				mockGitHubInterface.EXPECT().GetTeamMembership(someContext, setutil.NewCaseInsensitiveSet("org1")).Return([]githubclient.TeamInfo{
+					{
+						Name: "Team1 Name",
+						Slug: "team1-slug",
+						Org:  "org1",
+					},
+				}, nil)
+			},
+			buildMockAppResponses: func(mockGitHubAppInterface *mockgithubclient.MockGitHubAppInterface) {
+				mockGitHubAppInterface.EXPECT().GetRepositoryRoleForUser(someContext, "org1", "repo1", "some-github-login").Return(githubclient.RepositoryRoleWrite, nil)
+			},
+			wantUser: &upstreamprovider.GitHubUser{
+				Username:          "some-github-login",
+				Groups:            []string{"org1/team1-slug", "org1/repo1:write"},
+				DownstreamSubject: fmt.Sprintf("https://some-url?idpName=%s&login=some-github-login&id=some-github-id", encodedIDPDisplayName),
+				UpstreamData:      wantUpstreamData([]string{"org1"}, []any{map[string]any{"organization": "org1", "name": "Team1 Name", "slug": "team1-slug"}}, map[string]string{"org1/repo1": githubclient.RepositoryRoleWrite}),
 			},
 		},
 		{
@@ -431,6 +725,40 @@ func TestGetUser(t *testing.T) {
 			},
 			wantErrMsg: "error from githubClient.GetTeamMembership",
 		},
+		{
+			name: "returns errors from githubClient.GetRepositoryRole()",
+			providerConfig: ProviderConfig{
+				APIBaseURL:           "https://some-url",
+				HttpClient:           someHttpClient,
+				UsernameAttribute:    idpv1alpha1.GitHubUsernameLoginAndID,
+				RepositoryRoleGroups: []string{"org1/repo1"},
+			},
+			buildMockResponses: func(mockGitHubInterface *mockgithubclient.MockGitHubInterface) {
+				mockGitHubInterface.EXPECT().GetUserInfo(someContext).Return(&githubclient.UserInfo{}, nil)
+				mockGitHubInterface.EXPECT().GetOrgMembership(someContext).Return(nil, nil)
+				mockGitHubInterface.EXPECT().GetTeamMembership(someContext, gomock.Any()).Return(nil, nil)
+				mockGitHubInterface.EXPECT().GetRepositoryRole(someContext, "org1", "repo1").Return("", errors.New("error from githubClient.GetRepositoryRole"))
+			},
+			wantErrMsg: "error from githubClient.GetRepositoryRole",
+		},
+		{
+			name: "returns errors from appClient.GetRepositoryRoleForUser()",
+			providerConfig: ProviderConfig{
+				APIBaseURL:           "https://some-url",
+				HttpClient:           someHttpClient,
+				UsernameAttribute:    idpv1alpha1.GitHubUsernameLoginAndID,
+				RepositoryRoleGroups: []string{"org1/repo1"},
+			},
+			buildMockResponses: func(mockGitHubInterface *mockgithubclient.MockGitHubInterface) {
+				mockGitHubInterface.EXPECT().GetUserInfo(someContext).Return(&githubclient.UserInfo{Login: "some-github-login"}, nil)
+				mockGitHubInterface.EXPECT().GetOrgMembership(someContext).Return(nil, nil)
+				mockGitHubInterface.EXPECT().GetTeamMembership(someContext, gomock.Any()).Return(nil, nil)
+			},
+			buildMockAppResponses: func(mockGitHubAppInterface *mockgithubclient.MockGitHubAppInterface) {
+				mockGitHubAppInterface.EXPECT().GetRepositoryRoleForUser(someContext, "org1", "repo1", "some-github-login").Return("", errors.New("error from appClient.GetRepositoryRoleForUser"))
+			},
+			wantErrMsg: "error from appClient.GetRepositoryRoleForUser",
+		},
 		{
 			name: "bad configuration: UsernameAttribute",
 			providerConfig: ProviderConfig{
@@ -484,7 +812,14 @@ func TestGetUser(t *testing.T) {
 				test.buildMockResponses(mockGitHubInterface)
 			}
 
-			p := New(test.providerConfig)
+			providerConfig := test.providerConfig
+			if test.buildMockAppResponses != nil {
+				mockGitHubAppInterface := mockgithubclient.NewMockGitHubAppInterface(ctrl)
+				test.buildMockAppResponses(mockGitHubAppInterface)
+				providerConfig.AppClient = mockGitHubAppInterface
+			}
+
+			p := New(providerConfig)
 			p.buildGitHubClient = func(httpClient *http.Client, apiBaseURL, token string) (githubclient.GitHubInterface, error) {
 				require.Equal(t, test.providerConfig.HttpClient, httpClient)
 				require.Equal(t, test.providerConfig.APIBaseURL, apiBaseURL)
@@ -0,0 +1,294 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// Idle connections are closed after this amount of time, so that the pool does not keep using connections
+	// which the LDAP server, or a load balancer in front of it, may have already dropped.
+	maxConnectionIdleTime = 5 * time.Minute
+
+	// The hosts found by looking up an SRV record are reused for this amount of time.
+	srvRecordCacheTTL = 5 * time.Minute
+)
+
+// ConnectionPool holds the state of an upstream LDAP IDP which should outlive any one Provider, since the
+// controllers create a new Provider each time that they sync. It keeps idle connections which were bound as the
+// bind account so that they can be reused, remembers which hosts could not be reached, and caches the hosts
+// which were found by looking up an SRV record. A nil *ConnectionPool is valid, and does not keep any state.
+type ConnectionPool struct {
+	mutex              sync.Mutex
+	maxIdleConnections int
+	idle               []*idleConn // ordered from least recently used to most recently used
+	closed             bool
+
+	// hostErrors holds the result of the most recent dial of each host, keyed by the lowercase host.
+	// A nil error means that the host was healthy.
+	hostErrors map[string]error
+
+	srvRecord       string
+	srvHosts        []string
+	srvHostsExpires time.Time
+
+	now func() time.Time
+}
+
+type idleConn struct {
+	conn         *pooledConn
+	settingsHash settingsHash
+	idleSince    time.Time
+}
+
+// NewConnectionPool returns a ConnectionPool which keeps at most maxIdleConnections idle connections.
+// When maxIdleConnections is zero, connections are not reused, but the health of hosts is still remembered.
+func NewConnectionPool(maxIdleConnections int) *ConnectionPool {
+	return &ConnectionPool{
+		maxIdleConnections: max(maxIdleConnections, 0),
+		hostErrors:         map[string]error{},
+		now:                time.Now,
+	}
+}
+
+// IdleConnections returns the number of idle connections in the pool.
+func (c *ConnectionPool) IdleConnections() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.idle)
+}
+
+// MaxIdleConnections returns the maximum number of idle connections in the pool.
+func (c *ConnectionPool) MaxIdleConnections() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.maxIdleConnections
+}
+
+// Close closes all idle connections. After Close, connections are no longer kept for reuse.
+func (c *ConnectionPool) Close() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.closed = true
+	toClose := c.idle
+	c.idle = nil
+	c.mutex.Unlock()
+
+	closeIdleConns(toClose, "closing connection pool")
+}
+
+// CloseExpiredIdleConnections closes the idle connections which have been idle for too long to be reused.
+func (c *ConnectionPool) CloseExpiredIdleConnections() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	toClose := c.removeExpiredLocked()
+	c.mutex.Unlock()
+
+	closeIdleConns(toClose, "closing expired idle connection")
+}
+
+func (c *ConnectionPool) setMaxIdleConnections(maxIdleConnections int) {
+	c.mutex.Lock()
+	c.maxIdleConnections = max(maxIdleConnections, 0)
+	var toClose []*idleConn
+	if excess := len(c.idle) - c.maxIdleConnections; excess > 0 {
+		toClose = c.idle[:excess]
+		c.idle = slices.Clone(c.idle[excess:])
+	}
+	c.mutex.Unlock()
+
+	closeIdleConns(toClose, "reducing size of connection pool")
+}
+
+// pooling returns true when connections should be kept for reuse.
+func (c *ConnectionPool) pooling() bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return !c.closed && c.maxIdleConnections > 0
+}
+
+// get removes the most recently used idle connection which was created using the same settings from the pool,
+// and returns it. It returns nil when there is no such connection.
+func (c *ConnectionPool) get(hash settingsHash) *pooledConn {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	toClose := c.removeExpiredLocked()
+	var found *pooledConn
+	for i := len(c.idle) - 1; i >= 0; i-- {
+		if c.idle[i].settingsHash == hash {
+			found = c.idle[i].conn
+			c.idle = append(c.idle[:i], c.idle[i+1:]...)
+			break
+		}
+	}
+	c.mutex.Unlock()
+
+	closeIdleConns(toClose, "closing expired idle connection")
+	return found
+}
+
+// put adds the connection to the pool. It returns false when the pool is full or closed, in which case the
+// caller is responsible for closing the connection.
+func (c *ConnectionPool) put(conn *pooledConn, hash settingsHash) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed || len(c.idle) >= c.maxIdleConnections {
+		return false
+	}
+	c.idle = append(c.idle, &idleConn{conn: conn, settingsHash: hash, idleSince: c.now()})
+	return true
+}
+
+// removeExpiredLocked removes the idle connections which have expired from the pool and returns them.
+// The caller must hold the lock, and is responsible for closing the returned connections.
+func (c *ConnectionPool) removeExpiredLocked() []*idleConn {
+	expireBefore := c.now().Add(-maxConnectionIdleTime)
+	i := 0
+	for i < len(c.idle) && c.idle[i].idleSince.Before(expireBefore) {
+		i++
+	}
+	expired := c.idle[:i]
+	c.idle = slices.Clone(c.idle[i:])
+	return expired
+}
+
+// hostHealth returns the result of the most recent dial of the host, and whether the host was ever dialed.
+func (c *ConnectionPool) hostHealth(host string) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	err, dialed := c.hostErrors[strings.ToLower(host)]
+	return dialed, err
+}
+
+func (c *ConnectionPool) recordHostHealth(host string, err error) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.hostErrors[strings.ToLower(host)] = err
+}
+
+// orderByHealth returns the hosts which are not known to be unhealthy first, followed by the unhealthy hosts.
+// Otherwise, the hosts remain in the given order.
+func (c *ConnectionPool) orderByHealth(hosts []string) []string {
+	if c == nil {
+		return hosts
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ordered := make([]string, 0, len(hosts))
+	var unhealthy []string
+	for _, host := range hosts {
+		if c.hostErrors[strings.ToLower(host)] != nil {
+			unhealthy = append(unhealthy, host)
+		} else {
+			ordered = append(ordered, host)
+		}
+	}
+	return append(ordered, unhealthy...)
+}
+
+// cachedSRVHosts returns the hosts which were most recently found by looking up the SRV record, and whether
+// they are recent enough to be used without looking up the SRV record again.
+func (c *ConnectionPool) cachedSRVHosts(srvRecord string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.srvRecord != srvRecord {
+		return nil, false
+	}
+	return c.srvHosts, c.now().Before(c.srvHostsExpires)
+}
+
+func (c *ConnectionPool) cacheSRVHosts(srvRecord string, hosts []string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.srvRecord = srvRecord
+	c.srvHosts = hosts
+	c.srvHostsExpires = c.now().Add(srvRecordCacheTTL)
+}
+
+func closeIdleConns(idleConns []*idleConn, doingWhat string) {
+	for _, idle := range idleConns {
+		closeAndLogError(idle.conn, doingWhat)
+	}
+}
+
+// ConnectionPools holds a ConnectionPool for each upstream LDAP IDP, keyed by the UID of its resource.
+// It is safe for concurrent use.
+type ConnectionPools struct {
+	mutex sync.Mutex
+	pools map[types.UID]*ConnectionPool
+}
+
+// NewConnectionPools returns an empty ConnectionPools.
+func NewConnectionPools() *ConnectionPools {
+	return &ConnectionPools{pools: map[types.UID]*ConnectionPool{}}
+}
+
+// Get returns the ConnectionPool of the upstream LDAP IDP with the given UID, creating it when needed.
+// The maximum number of idle connections of an existing pool is updated to the given value.
+func (p *ConnectionPools) Get(uid types.UID, maxIdleConnections int) *ConnectionPool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	pool, ok := p.pools[uid]
+	if !ok {
+		pool = NewConnectionPool(maxIdleConnections)
+		p.pools[uid] = pool
+		return pool
+	}
+	pool.setMaxIdleConnections(maxIdleConnections)
+	return pool
+}
+
+// Retain closes and forgets the pools of all upstream LDAP IDPs whose UIDs are not in the given set.
+func (p *ConnectionPools) Retain(uids sets.Set[types.UID]) {
+	p.mutex.Lock()
+	var toClose []*ConnectionPool
+	for uid, pool := range p.pools {
+		if !uids.Has(uid) {
+			toClose = append(toClose, pool)
+			delete(p.pools, uid)
+		}
+	}
+	p.mutex.Unlock()
+
+	for _, pool := range toClose {
+		pool.Close()
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamldap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"go.pinniped.dev/internal/endpointaddr"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/mocks/mockldapconn"
+)
+
+const (
+	testHost2 = "ldap2.example.com:8443"
+	testHost3 = "ldap3.example.com:8443"
+)
+
+func poolTestProviderConfig(pool *ConnectionPool, dialer LDAPDialerFunc) ProviderConfig {
+	return ProviderConfig{
+		Name:               testUpstreamName,
+		Host:               testHost,
+		AdditionalHosts:    []string{testHost2},
+		ConnectionProtocol: TLS,
+		BindUsername:       testBindUsername,
+		BindPassword:       testBindPassword,
+		Dialer:             dialer,
+		ConnectionPool:     pool,
+	}
+}
+
+// recordingDialer returns a dialer which records the dialed hosts, fails to dial the hosts which have an entry
+// in dialErrors, and otherwise returns the next of the given connections.
+func recordingDialer(t *testing.T, dialed *[]string, dialErrors map[string]error, conns ...Conn) LDAPDialerFunc {
+	return func(_ context.Context, addr endpointaddr.HostPort) (Conn, error) {
+		*dialed = append(*dialed, addr.Endpoint())
+		if err := dialErrors[addr.Endpoint()]; err != nil {
+			return nil, err
+		}
+		require.NotEmpty(t, conns, "unexpected dial of %s", addr.Endpoint())
+		conn := conns[0]
+		conns = conns[1:]
+		return conn, nil
+	}
+}
+
+func TestFailover(t *testing.T) {
+	t.Run("tries the additional hosts when the host cannot be reached, and prefers healthy hosts afterwards", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn1 := mockldapconn.NewMockConn(ctrl)
+		conn2 := mockldapconn.NewMockConn(ctrl)
+		conn1.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+		conn1.EXPECT().Close().Times(1)
+		conn2.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+		conn2.EXPECT().Close().Times(1)
+
+		var dialed []string
+		dialer := recordingDialer(t, &dialed, map[string]error{testHost: errors.New("some dial error")}, conn1, conn2)
+		pool := NewConnectionPool(0)
+
+		require.NoError(t, New(poolTestProviderConfig(pool, dialer)).TestConnection(context.Background()))
+		require.Equal(t, []string{testHost, testHost2}, dialed)
+
+		// The host is known to be unhealthy, so the additional host is tried first by the next Provider.
+		dialed = nil
+		require.NoError(t, New(poolTestProviderConfig(pool, dialer)).TestConnection(context.Background()))
+		require.Equal(t, []string{testHost2}, dialed)
+
+		dialedHost, err := pool.hostHealth(testHost)
+		require.True(t, dialedHost)
+		require.EqualError(t, err, "some dial error")
+		dialedHost, err = pool.hostHealth(testHost2)
+		require.True(t, dialedHost)
+		require.NoError(t, err)
+	})
+
+	t.Run("returns the errors from all hosts when no host can be reached", func(t *testing.T) {
+		var dialed []string
+		dialer := recordingDialer(t, &dialed, map[string]error{
+			testHost:  errors.New("some dial error"),
+			testHost2: errors.New("some other dial error"),
+		})
+
+		err := New(poolTestProviderConfig(nil, dialer)).TestConnection(context.Background())
+		require.EqualError(t, err,
+			`error dialing host "ldap.example.com:8443": some dial error; `+
+				`error dialing host "ldap2.example.com:8443": some other dial error`)
+		require.Equal(t, []string{testHost, testHost2}, dialed)
+	})
+
+	t.Run("stops trying hosts when the context is done, without remembering the error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var dialed []string
+		dialer := recordingDialer(t, &dialed, map[string]error{
+			testHost:  context.Canceled,
+			testHost2: context.Canceled,
+		})
+		pool := NewConnectionPool(0)
+
+		err := New(poolTestProviderConfig(pool, dialer)).TestConnection(ctx)
+		require.EqualError(t, err, `error dialing host "ldap.example.com:8443": context canceled`)
+		require.Equal(t, []string{testHost}, dialed)
+		dialedHost, _ := pool.hostHealth(testHost)
+		require.False(t, dialedHost)
+	})
+}
+
+func TestSRVRecord(t *testing.T) {
+	lookups := 0
+	var lookupErr error
+	lookupSRV := func(_ context.Context, name string) ([]*net.SRV, error) {
+		require.Equal(t, "_ldap._tcp.example.com", name)
+		lookups++
+		return []*net.SRV{
+			{Target: "ldap3.example.com.", Port: 8443},
+			{Target: "LDAP.example.com.", Port: 8443}, // a duplicate of the Host
+			{Target: ".", Port: 0},                    // means that the service is not available
+		}, lookupErr
+	}
+
+	now := time.Now()
+	pool := NewConnectionPool(0)
+	pool.now = func() time.Time { return now }
+
+	config := poolTestProviderConfig(pool, nil)
+	config.SRVRecord = "_ldap._tcp.example.com"
+	config.LookupSRV = lookupSRV
+	p := New(config)
+
+	require.Equal(t, []string{testHost, testHost2, testHost3}, p.hosts(context.Background(), false))
+	require.Equal(t, 1, lookups)
+
+	// The hosts from the SRV record are cached.
+	require.Equal(t, []string{testHost, testHost2, testHost3}, p.hosts(context.Background(), false))
+	require.Equal(t, 1, lookups)
+
+	// Unless asked to refresh them.
+	require.Equal(t, []string{testHost, testHost2, testHost3}, p.hosts(context.Background(), true))
+	require.Equal(t, 2, lookups)
+
+	// Or until they expire. When the lookup fails, the previously found hosts are still used.
+	now = now.Add(srvRecordCacheTTL + time.Second)
+	lookupErr = errors.New("some lookup error")
+	require.Equal(t, []string{testHost, testHost2, testHost3}, p.hosts(context.Background(), false))
+	require.Equal(t, 3, lookups)
+
+	// Without a ConnectionPool, the SRV record is looked up every time.
+	lookupErr = nil
+	config.ConnectionPool = nil
+	p = New(config)
+	require.Equal(t, []string{testHost, testHost2, testHost3}, p.hosts(context.Background(), false))
+	require.Equal(t, []string{testHost, testHost2, testHost3}, p.hosts(context.Background(), false))
+	require.Equal(t, 5, lookups)
+}
+
+func TestConnectionPooling(t *testing.T) {
+	t.Run("reuses idle connections, binding them again as the bind account", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn := mockldapconn.NewMockConn(ctrl)
+		conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(3)
+
+		var dialed []string
+		pool := NewConnectionPool(1)
+		p := New(poolTestProviderConfig(pool, recordingDialer(t, &dialed, nil, conn)))
+
+		for range 3 {
+			require.NoError(t, p.TestConnection(context.Background()))
+			require.Equal(t, 1, pool.IdleConnections())
+		}
+		require.Equal(t, []string{testHost}, dialed)
+	})
+
+	t.Run("discards idle connections which cannot be bound again", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn1 := mockldapconn.NewMockConn(ctrl)
+		conn2 := mockldapconn.NewMockConn(ctrl)
+		gomock.InOrder(
+			conn1.EXPECT().Bind(testBindUsername, testBindPassword),
+			conn1.EXPECT().Bind(testBindUsername, testBindPassword).Return(ldap.NewError(ldap.ErrorNetwork, errors.New("connection closed"))),
+			conn1.EXPECT().Close(),
+		)
+		conn2.EXPECT().Bind(testBindUsername, testBindPassword)
+
+		var dialed []string
+		pool := NewConnectionPool(1)
+		p := New(poolTestProviderConfig(pool, recordingDialer(t, &dialed, nil, conn1, conn2)))
+
+		require.NoError(t, p.TestConnection(context.Background()))
+		require.NoError(t, p.TestConnection(context.Background()))
+		require.Equal(t, []string{testHost, testHost}, dialed)
+		require.Equal(t, 1, pool.IdleConnections())
+	})
+
+	t.Run("closes broken connections instead of returning them to the pool", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn := mockldapconn.NewMockConn(ctrl)
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Search(gomock.Any()).Return(nil, ldap.NewError(ldap.ErrorNetwork, errors.New("connection reset")))
+		conn.EXPECT().Close()
+
+		var dialed []string
+		pool := NewConnectionPool(1)
+		p := New(poolTestProviderConfig(pool, recordingDialer(t, &dialed, nil, conn)))
+
+		_, _, _, err := p.PerformRefresh(context.Background(), upstreamprovider.LDAPRefreshAttributes{DN: testUserSearchResultDNValue}, "some-idp")
+		require.EqualError(t, err, `error searching for user "some-upstream-user-dn": LDAP Result Code 200 "Network Error": connection reset`)
+		require.Equal(t, 0, pool.IdleConnections())
+	})
+
+	t.Run("keeps connections after errors from the LDAP server", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn := mockldapconn.NewMockConn(ctrl)
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Search(gomock.Any()).Return(nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object")))
+
+		var dialed []string
+		pool := NewConnectionPool(1)
+		p := New(poolTestProviderConfig(pool, recordingDialer(t, &dialed, nil, conn)))
+
+		_, _, _, err := p.PerformRefresh(context.Background(), upstreamprovider.LDAPRefreshAttributes{DN: testUserSearchResultDNValue}, "some-idp")
+		require.Error(t, err)
+		require.Equal(t, 1, pool.IdleConnections())
+	})
+
+	t.Run("does not reuse connections which were created using different settings", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn1 := mockldapconn.NewMockConn(ctrl)
+		conn2 := mockldapconn.NewMockConn(ctrl)
+		conn1.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn2.EXPECT().Bind(testBindUsername, "some-new-password")
+		conn2.EXPECT().Close() // the pool is full
+
+		var dialed []string
+		pool := NewConnectionPool(1)
+		dialer := recordingDialer(t, &dialed, nil, conn1, conn2)
+
+		require.NoError(t, New(poolTestProviderConfig(pool, dialer)).TestConnection(context.Background()))
+		config := poolTestProviderConfig(pool, dialer)
+		config.BindPassword = "some-new-password"
+		require.NoError(t, New(config).TestConnection(context.Background()))
+		require.Equal(t, []string{testHost, testHost}, dialed)
+	})
+
+	t.Run("closes connections which have been idle for too long", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn := mockldapconn.NewMockConn(ctrl)
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Close()
+
+		now := time.Now()
+		pool := NewConnectionPool(1)
+		pool.now = func() time.Time { return now }
+		var dialed []string
+		p := New(poolTestProviderConfig(pool, recordingDialer(t, &dialed, nil, conn)))
+
+		require.NoError(t, p.TestConnection(context.Background()))
+		now = now.Add(maxConnectionIdleTime)
+		pool.CloseExpiredIdleConnections()
+		require.Equal(t, 1, pool.IdleConnections())
+
+		now = now.Add(time.Second)
+		pool.CloseExpiredIdleConnections()
+		require.Equal(t, 0, pool.IdleConnections())
+	})
+
+	t.Run("does not pool connections when the maximum number of idle connections is zero", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		conn := mockldapconn.NewMockConn(ctrl)
+		conn.EXPECT().Bind(testBindUsername, testBindPassword)
+		conn.EXPECT().Close()
+
+		var dialed []string
+		pool := NewConnectionPool(0)
+		p := New(poolTestProviderConfig(pool, recordingDialer(t, &dialed, nil, conn)))
+
+		require.NoError(t, p.TestConnection(context.Background()))
+		require.Equal(t, 0, pool.IdleConnections())
+	})
+}
+
+func TestCheckHostHealth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	conn1 := mockldapconn.NewMockConn(ctrl)
+	conn2 := mockldapconn.NewMockConn(ctrl)
+	conn1.EXPECT().Close()
+	conn2.EXPECT().Close()
+
+	var dialed []string
+	dialErrors := map[string]error{testHost2: errors.New("some dial error")}
+	pool := NewConnectionPool(0)
+	p := New(poolTestProviderConfig(pool, recordingDialer(t, &dialed, dialErrors, conn1, conn2)))
+
+	// Hosts which were never dialed are dialed.
+	require.Equal(t, []HostStatus{
+		{Host: testHost},
+		{Host: testHost2, Error: errors.New("some dial error")},
+	}, p.CheckHostHealth(context.Background()))
+	require.Equal(t, []string{testHost, testHost2}, dialed)
+
+	// Healthy hosts are not dialed again, but unhealthy hosts are.
+	dialed = nil
+	delete(dialErrors, testHost2)
+	require.Equal(t, []HostStatus{
+		{Host: testHost},
+		{Host: testHost2},
+	}, p.CheckHostHealth(context.Background()))
+	require.Equal(t, []string{testHost2}, dialed)
+}
+
+func TestConnectionPools(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	conn1 := mockldapconn.NewMockConn(ctrl)
+	conn2 := mockldapconn.NewMockConn(ctrl)
+
+	pools := NewConnectionPools()
+	pool1 := pools.Get("uid-1", 2)
+	pool2 := pools.Get("uid-2", 2)
+	require.NotSame(t, pool1, pool2)
+	require.Same(t, pool1, pools.Get("uid-1", 2))
+
+	require.True(t, pool1.put(&pooledConn{Conn: conn1}, settingsHash{}))
+	require.True(t, pool1.put(&pooledConn{Conn: conn2}, settingsHash{}))
+	require.False(t, pool1.put(&pooledConn{Conn: conn2}, settingsHash{}))
+
+	// Reducing the maximum number of idle connections closes the least recently used connections.
+	conn1.EXPECT().Close()
+	require.Same(t, pool1, pools.Get("uid-1", 1))
+	require.Equal(t, 1, pool1.IdleConnections())
+	require.Equal(t, 1, pool1.MaxIdleConnections())
+
+	// Pools of upstreams which no longer exist are closed.
+	conn2.EXPECT().Close()
+	pools.Retain(sets.New[types.UID]("uid-2"))
+	require.Equal(t, 0, pool1.IdleConnections())
+	require.False(t, pool1.pooling())
+	require.True(t, pool2.pooling())
+	require.NotSame(t, pool1, pools.Get("uid-1", 2))
+}
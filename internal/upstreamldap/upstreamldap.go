@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package upstreamldap implements an abstraction of upstream LDAP IDP interactions.
@@ -6,6 +6,7 @@ package upstreamldap
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
@@ -13,8 +14,11 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-ldap/ldap/v3"
 	"k8s.io/apimachinery/pkg/types"
@@ -37,6 +41,9 @@ const (
 	groupSearchPageSize                     = uint32(250)
 	defaultLDAPPort                         = uint16(389)
 	defaultLDAPSPort                        = uint16(636)
+	defaultMemberOfAttributeName            = "memberOf"
+	defaultGroupSearchMaxDepth              = 10
+	nestedGroupSearchBatchSize              = 100
 )
 
 // Conn abstracts the upstream LDAP communication protocol (mostly for testing).
@@ -74,6 +81,14 @@ const (
 	TLS      = LDAPConnectionProtocol("TLS")
 )
 
+type GroupSearchStrategy string
+
+const (
+	GroupSearchStrategySearch            = GroupSearchStrategy("search")
+	GroupSearchStrategyRecursiveSearch   = GroupSearchStrategy("recursiveSearch")
+	GroupSearchStrategyMemberOfAttribute = GroupSearchStrategy("memberOfAttribute")
+)
+
 // ProviderConfig includes all the settings for connection and searching for users and groups in
 // the upstream LDAP IDP. It also provides methods for testing the connection and performing logins.
 // The nested structs are not pointer fields to enable deep copy on function params and return values.
@@ -88,6 +103,21 @@ type ProviderConfig struct {
 	// the default LDAP port will be used.
 	Host string
 
+	// AdditionalHosts are the hostnames or "hostname:port" of other LDAP servers which serve the same directory
+	// as the Host. They are tried in order when the Host cannot be reached. Can be nil.
+	AdditionalHosts []string
+
+	// SRVRecord is the name of a DNS SRV record which lists other LDAP servers which serve the same directory
+	// as the Host. They are tried after the Host and the AdditionalHosts. Empty means to not look up an SRV record.
+	SRVRecord string
+
+	// LookupSRV exists to enable testing. When nil, will use the default DNS resolver.
+	LookupSRV func(ctx context.Context, name string) ([]*net.SRV, error)
+
+	// ConnectionPool holds the idle connections and the health of the hosts of this upstream LDAP IDP.
+	// When nil, a new connection is dialed for each operation and the health of the hosts is not remembered.
+	ConnectionPool *ConnectionPool
+
 	// ConnectionProtocol determines how to establish the connection to the server. Either StartTLS or TLS.
 	ConnectionProtocol LDAPConnectionProtocol
 
@@ -136,6 +166,10 @@ type UserSearchConfig struct {
 	// UIDAttribute is the attribute in the LDAP entry from which the user's unique ID should be
 	// retrieved.
 	UIDAttribute string
+
+	// AdditionalClaimMappings are the attributes in the LDAP entry whose values should become additional
+	// downstream claims, keyed by the name of the downstream claim. Can be nil.
+	AdditionalClaimMappings map[string]string
 }
 
 // GroupSearchConfig contains information about how to search for group membership for users in the upstream LDAP IDP.
@@ -155,6 +189,17 @@ type GroupSearchConfig struct {
 	// retrieved. Empty means to use 'cn'.
 	GroupNameAttribute string
 
+	// Strategy determines how the groups of the user are found. Empty means to use GroupSearchStrategySearch.
+	Strategy GroupSearchStrategy
+
+	// MemberOfAttribute is the attribute of user and group entries which lists the DNs of the groups to which
+	// the entry belongs. Only used by GroupSearchStrategyMemberOfAttribute. Empty means to use 'memberOf'.
+	MemberOfAttribute string
+
+	// MaxDepth is the maximum number of levels of nested groups to find when using GroupSearchStrategyRecursiveSearch
+	// or GroupSearchStrategyMemberOfAttribute. Zero means to use 10.
+	MaxDepth int
+
 	// SkipGroupRefresh skips the group refresh operation that occurs with each refresh
 	// (every 5 minutes). This can be done if group search is very slow or resource intensive for the LDAP
 	// server.
@@ -165,8 +210,54 @@ type Provider struct {
 	c ProviderConfig
 }
 
+// HostStatus is the health of one of the hosts of a Provider.
+type HostStatus struct {
+	// Host is the hostname or "hostname:port" of the LDAP server.
+	Host string
+
+	// Error is the error from the most recent dial of the host, or nil when the host was healthy.
+	Error error
+}
+
+// settingsHash identifies the settings which were used to create and bind a connection, so that a pooled
+// connection is never used by a Provider with different settings.
+type settingsHash [sha256.Size]byte
+
+// pooledConn is a connection which may be returned to a ConnectionPool after use. It remembers whether any
+// operation failed in a way which suggests that the connection itself is broken, in which case the connection
+// is closed instead of being returned to the pool.
+type pooledConn struct {
+	Conn
+	broken bool
+}
+
+func (c *pooledConn) Bind(username, password string) error {
+	return c.checkBroken(c.Conn.Bind(username, password))
+}
+
+func (c *pooledConn) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result, err := c.Conn.Search(searchRequest)
+	return result, c.checkBroken(err)
+}
+
+func (c *pooledConn) SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	result, err := c.Conn.SearchWithPaging(searchRequest, pagingSize)
+	return result, c.checkBroken(err)
+}
+
+// checkBroken marks the connection as broken for any error which was not returned by the LDAP server, e.g. a
+// network error. The LDAP result codes at or above ErrorNetwork are reserved for errors detected by the client.
+func (c *pooledConn) checkBroken(err error) error {
+	ldapErr := &ldap.Error{}
+	if err != nil && (!errors.As(err, &ldapErr) || ldapErr.ResultCode >= ldap.ErrorNetwork) {
+		c.broken = true
+	}
+	return err
+}
+
 var _ upstreamprovider.UpstreamLDAPIdentityProviderI = &Provider{}
 var _ authenticators.UserAuthenticator = &Provider{}
+var _ upstreamprovider.UpstreamHealthProberI = &Provider{}
 
 // New creates a Provider. The config is not a pointer to ensure that a copy of the config is created,
 // making the resulting Provider use an effectively read-only configuration.
@@ -186,84 +277,85 @@ func closeAndLogError(conn Conn, doingWhat string) {
 	}
 }
 
-func (p *Provider) PerformRefresh(ctx context.Context, storedRefreshAttributes upstreamprovider.LDAPRefreshAttributes, idpDisplayName string) ([]string, error) {
+func (p *Provider) PerformRefresh(ctx context.Context, storedRefreshAttributes upstreamprovider.LDAPRefreshAttributes, idpDisplayName string) ([]string, map[string][]string, map[string]any, error) {
 	t := trace.FromContext(ctx).Nest("slow ldap refresh attempt", trace.Field{Key: "providerName", Value: p.GetResourceName()})
 	defer t.LogIfLong(500 * time.Millisecond) // to help users debug slow LDAP searches
 	userDN := storedRefreshAttributes.DN
 
-	conn, err := p.dial(ctx)
+	conn, err := p.connect(ctx, "before user search")
 	if err != nil {
-		return nil, fmt.Errorf(`error dialing host %q: %w`, p.c.Host, err)
-	}
-	defer closeAndLogError(conn, "refreshing connection")
-
-	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
-	if err != nil {
-		return nil, fmt.Errorf(`error binding as %q before user search: %w`, p.c.BindUsername, err)
+		return nil, nil, nil, err
 	}
+	defer p.releaseConn(conn, "refreshing connection")
 
 	searchResult, err := p.performUserRefreshSearch(conn, userDN)
 	if err != nil {
 		p.traceRefreshFailure(t, err)
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// if any more or less than one entry, error.
 	// we don't need to worry about logging this because we know it's a dn.
 	if len(searchResult.Entries) != 1 {
-		return nil, fmt.Errorf(`searching for user %q resulted in %d search results, but expected 1 result`,
+		return nil, nil, nil, fmt.Errorf(`searching for user %q resulted in %d search results, but expected 1 result`,
 			userDN, len(searchResult.Entries),
 		)
 	}
 
 	userEntry := searchResult.Entries[0]
 	if len(userEntry.DN) == 0 {
-		return nil, fmt.Errorf(`searching for user with original DN %q resulted in search result without DN`, userDN)
+		return nil, nil, nil, fmt.Errorf(`searching for user with original DN %q resulted in search result without DN`, userDN)
 	}
 
 	newUsername, err := p.getSearchResultAttributeValue(p.c.UserSearch.UsernameAttribute, userEntry, userDN)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	if newUsername != storedRefreshAttributes.Username {
-		return nil, fmt.Errorf(`searching for user %q returned a different username than the previous value. expected: %q, actual: %q`,
+		return nil, nil, nil, fmt.Errorf(`searching for user %q returned a different username than the previous value. expected: %q, actual: %q`,
 			userDN, storedRefreshAttributes.Username, newUsername,
 		)
 	}
 
 	newUID, err := p.getSearchResultAttributeRawValueEncoded(p.c.UserSearch.UIDAttribute, userEntry, userDN)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	newSubject := downstreamsubject.LDAP(newUID, *p.GetURL(), idpDisplayName)
 	if newSubject != storedRefreshAttributes.Subject {
-		return nil, fmt.Errorf(`searching for user %q produced a different subject than the previous value. expected: %q, actual: %q`, userDN, storedRefreshAttributes.Subject, newSubject)
+		return nil, nil, nil, fmt.Errorf(`searching for user %q produced a different subject than the previous value. expected: %q, actual: %q`, userDN, storedRefreshAttributes.Subject, newSubject)
 	}
 	for attribute, validateFunc := range p.c.RefreshAttributeChecks {
 		err = validateFunc(userEntry, storedRefreshAttributes)
 		if err != nil {
-			return nil, fmt.Errorf(`validation for attribute %q failed during upstream refresh: %w`, attribute, err)
+			return nil, nil, nil, fmt.Errorf(`validation for attribute %q failed during upstream refresh: %w`, attribute, err)
 		}
 	}
 
+	additionalClaims := p.additionalClaimValues(userEntry)
+	upstreamData := map[string]any{
+		"dn":         userEntry.DN,
+		"attributes": textAttributeValues(userEntry),
+	}
+
 	// If we were configured to always skip group refresh for all users and all sessions, then skip it.
 	if p.c.GroupSearch.SkipGroupRefresh {
-		return storedRefreshAttributes.Groups, nil
+		return storedRefreshAttributes.Groups, additionalClaims, upstreamData, nil
 	}
 
 	var groupSearchUserAttributeForFilterValue string
 	if p.useGroupSearchUserAttributeForFilter() {
 		groupSearchUserAttributeForFilterValue, err = p.getSearchResultAttributeValue(p.c.GroupSearch.UserAttributeForFilter, userEntry, newUsername)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 	}
 
-	mappedGroupNames, err := p.searchGroupsForUserMembership(conn, userDN, groupSearchUserAttributeForFilterValue)
+	mappedGroupNames, err := p.searchGroupsForUserMembership(conn, userDN, userEntry, groupSearchUserAttributeForFilterValue)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	return mappedGroupNames, nil
+	return mappedGroupNames, additionalClaims, upstreamData, nil
 }
 
 func (p *Provider) performUserRefreshSearch(conn Conn, userDN string) (*ldap.SearchResult, error) {
@@ -277,13 +369,186 @@ func (p *Provider) performUserRefreshSearch(conn Conn, userDN string) (*ldap.Sea
 	return searchResult, nil
 }
 
+// connect returns a connection which is bound as the bind account. When connection pooling is enabled, an idle
+// connection is reused when one is available. The caller must pass the connection to releaseConn when finished.
+// The bindErrorContext describes the purpose of the bind for error messages, and may be empty.
+func (p *Provider) connect(ctx context.Context, bindErrorContext string) (Conn, error) {
+	hash := p.settingsHash()
+
+	for conn := p.c.ConnectionPool.get(hash); conn != nil; conn = p.c.ConnectionPool.get(hash) {
+		// Always bind again, since the previous user of the connection may have bound as an end user.
+		// This also checks that the server did not close the connection while it was idle.
+		err := conn.Bind(p.c.BindUsername, p.c.BindPassword)
+		if err == nil {
+			return conn, nil
+		}
+		plog.DebugErr("discarding idle LDAP connection which could not be bound", err, "upstreamName", p.GetResourceName())
+		closeAndLogError(conn, "discarding idle connection")
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.c.ConnectionPool.pooling() {
+		conn = &pooledConn{Conn: conn}
+	}
+
+	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
+	if err != nil {
+		closeAndLogError(conn, "binding as the bind account")
+		if bindErrorContext == "" {
+			return nil, fmt.Errorf(`error binding as %q: %w`, p.c.BindUsername, err)
+		}
+		return nil, fmt.Errorf(`error binding as %q %s: %w`, p.c.BindUsername, bindErrorContext, err)
+	}
+
+	return conn, nil
+}
+
+// releaseConn returns the connection to the connection pool when possible, and otherwise closes it.
+func (p *Provider) releaseConn(conn Conn, doingWhat string) {
+	if pooled, ok := conn.(*pooledConn); ok && !pooled.broken && p.c.ConnectionPool.put(pooled, p.settingsHash()) {
+		return
+	}
+	closeAndLogError(conn, doingWhat)
+}
+
+// settingsHash returns a hash of the settings which determine how connections are created and bound.
+func (p *Provider) settingsHash() settingsHash {
+	h := sha256.New()
+	for _, s := range append([]string{
+		string(p.c.ConnectionProtocol), string(p.c.CABundle), p.c.BindUsername, p.c.BindPassword, p.c.Host, p.c.SRVRecord,
+	}, p.c.AdditionalHosts...) {
+		// Write the length of each value before the value, so that different values cannot produce the same input.
+		_, _ = fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return settingsHash(h.Sum(nil))
+}
+
+// hosts returns the Host, followed by the AdditionalHosts, followed by the hosts found by looking up the SRV record,
+// without duplicates. When refreshSRV is false, the hosts which were recently found by looking up the SRV record
+// may be used without looking it up again.
+func (p *Provider) hosts(ctx context.Context, refreshSRV bool) []string {
+	hosts := append([]string{p.c.Host}, p.c.AdditionalHosts...)
+	if p.c.SRVRecord != "" {
+		hosts = append(hosts, p.srvHosts(ctx, refreshSRV)...)
+	}
+
+	seen := sets.New[string]()
+	deduplicated := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if !seen.Has(strings.ToLower(host)) {
+			seen.Insert(strings.ToLower(host))
+			deduplicated = append(deduplicated, host)
+		}
+	}
+	return deduplicated
+}
+
+// srvHosts returns the hosts found by looking up the SRV record, ordered by their priority and weight.
+// When the lookup fails, the hosts from the most recent successful lookup are used.
+func (p *Provider) srvHosts(ctx context.Context, refresh bool) []string {
+	cachedHosts, fresh := p.c.ConnectionPool.cachedSRVHosts(p.c.SRVRecord)
+	if fresh && !refresh {
+		return cachedHosts
+	}
+
+	lookupSRV := p.c.LookupSRV
+	if lookupSRV == nil {
+		lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+			_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+			return records, err
+		}
+	}
+
+	records, err := lookupSRV(ctx, p.c.SRVRecord)
+	if err != nil {
+		plog.WarningErr("error looking up LDAP SRV record", err,
+			"upstreamName", p.GetResourceName(), "srvRecord", p.c.SRVRecord, "previouslyFoundHosts", cachedHosts)
+		return cachedHosts
+	}
+
+	hosts := make([]string, 0, len(records))
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		if target == "" {
+			continue // a target of "." means that the service is not available
+		}
+		hosts = append(hosts, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+	}
+	p.c.ConnectionPool.cacheSRVHosts(p.c.SRVRecord, hosts)
+	return hosts
+}
+
+// dial tries each host until a connection can be established, trying the hosts which are not known to be
+// unhealthy first.
 func (p *Provider) dial(ctx context.Context) (Conn, error) {
-	tlsAddr, err := endpointaddr.Parse(p.c.Host, defaultLDAPSPort)
+	var errs []error
+	for _, host := range p.c.ConnectionPool.orderByHealth(p.hosts(ctx, false)) {
+		conn, err := p.dialHostAndRecordHealth(ctx, host)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, fmt.Errorf(`error dialing host %q: %w`, host, err))
+		if ctx.Err() != nil {
+			break // no point in trying the other hosts
+		}
+	}
+
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	// Join the errors on a single line, since they are shown in status conditions.
+	format := strings.TrimSuffix(strings.Repeat("%w; ", len(errs)), "; ")
+	args := make([]any, len(errs))
+	for i := range errs {
+		args[i] = errs[i]
+	}
+	return nil, fmt.Errorf(format, args...)
+}
+
+func (p *Provider) dialHostAndRecordHealth(ctx context.Context, host string) (Conn, error) {
+	conn, err := p.dialHost(ctx, host)
+	if err != nil && ctx.Err() != nil {
+		return nil, err // the host did not get a fair chance, so do not remember the error
+	}
+	if err != nil {
+		plog.InfoErr("error dialing LDAP host", err, "upstreamName", p.GetResourceName(), "host", host)
+	}
+	p.c.ConnectionPool.recordHostHealth(host, err)
+	return conn, err
+}
+
+// CheckHostHealth dials each host which was not dialed before, or which could not be reached the last time that it
+// was dialed, so that hosts which have recovered will be preferred again. It also looks up the SRV record again,
+// and closes expired idle connections. It returns the health of every host.
+func (p *Provider) CheckHostHealth(ctx context.Context) []HostStatus {
+	p.c.ConnectionPool.CloseExpiredIdleConnections()
+
+	hosts := p.hosts(ctx, true)
+	statuses := make([]HostStatus, 0, len(hosts))
+	for _, host := range hosts {
+		dialed, err := p.c.ConnectionPool.hostHealth(host)
+		if !dialed || err != nil {
+			var conn Conn
+			conn, err = p.dialHostAndRecordHealth(ctx, host)
+			if err == nil {
+				closeAndLogError(conn, "checking host health")
+			}
+		}
+		statuses = append(statuses, HostStatus{Host: host, Error: err})
+	}
+	return statuses
+}
+
+func (p *Provider) dialHost(ctx context.Context, host string) (Conn, error) {
+	tlsAddr, err := endpointaddr.Parse(host, defaultLDAPSPort)
 	if err != nil {
 		return nil, ldap.NewError(ldap.ErrorNetwork, err)
 	}
 
-	startTLSAddr, err := endpointaddr.Parse(p.c.Host, defaultLDAPPort)
+	startTLSAddr, err := endpointaddr.Parse(host, defaultLDAPPort)
 	if err != nil {
 		return nil, ldap.NewError(ldap.ErrorNetwork, err)
 	}
@@ -404,17 +669,23 @@ func (p *Provider) TestConnection(ctx context.Context) error {
 		return err
 	}
 
-	conn, err := p.dial(ctx)
+	conn, err := p.connect(ctx, "")
 	if err != nil {
-		return fmt.Errorf(`error dialing host %q: %w`, p.c.Host, err)
+		return err
 	}
-	defer closeAndLogError(conn, "testing connection")
+	p.releaseConn(conn, "testing connection")
 
-	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
+	return nil
+}
+
+// ProbeHealth checks that an LDAP server is reachable by dialing it, without binding. Implements
+// upstreamprovider.UpstreamHealthProberI.
+func (p *Provider) ProbeHealth(ctx context.Context) error {
+	conn, err := p.dial(ctx)
 	if err != nil {
-		return fmt.Errorf(`error binding as %q: %w`, p.c.BindUsername, err)
+		return err
 	}
-
+	closeAndLogError(conn, "probing health")
 	return nil
 }
 
@@ -454,18 +725,12 @@ func (p *Provider) authenticateUserImpl(ctx context.Context, username string, bi
 		return nil, false, nil
 	}
 
-	conn, err := p.dial(ctx)
-	if err != nil {
-		p.traceAuthFailure(t, err)
-		return nil, false, fmt.Errorf(`error dialing host %q: %w`, p.c.Host, err)
-	}
-	defer closeAndLogError(conn, "authenticating user")
-
-	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
+	conn, err := p.connect(ctx, "before user search")
 	if err != nil {
 		p.traceAuthFailure(t, err)
-		return nil, false, fmt.Errorf(`error binding as %q before user search: %w`, p.c.BindUsername, err)
+		return nil, false, err
 	}
+	defer p.releaseConn(conn, "authenticating user")
 
 	response, err := p.searchAndBindUser(conn, username, bindFunc)
 	if err != nil {
@@ -481,46 +746,188 @@ func (p *Provider) authenticateUserImpl(ctx context.Context, username string, bi
 	return response, true, nil
 }
 
-func (p *Provider) searchGroupsForUserMembership(conn Conn, userDN string, groupSearchUserAttributeForFilterValue string) ([]string, error) {
+func (p *Provider) searchGroupsForUserMembership(conn Conn, userDN string, userEntry *ldap.Entry, groupSearchUserAttributeForFilterValue string) ([]string, error) {
 	// If we do not have group search configured, skip this search.
 	if len(p.c.GroupSearch.Base) == 0 {
 		return []string{}, nil
 	}
 
-	searchResult, err := conn.SearchWithPaging(p.groupSearchRequest(userDN, groupSearchUserAttributeForFilterValue), groupSearchPageSize)
+	var groups []string
+	var err error
+	switch p.c.GroupSearch.Strategy {
+	case "", GroupSearchStrategySearch:
+		groups, _, err = p.searchGroups(conn, userDN, p.groupSearchFilter(userDN, groupSearchUserAttributeForFilterValue))
+	case GroupSearchStrategyRecursiveSearch:
+		groups, err = p.recursivelySearchGroups(conn, userDN, groupSearchUserAttributeForFilterValue)
+	case GroupSearchStrategyMemberOfAttribute:
+		groups, err = p.followMemberOfAttribute(conn, userDN, userEntry)
+	default:
+		return nil, fmt.Errorf(`unsupported group search strategy %q`, p.c.GroupSearch.Strategy)
+	}
 	if err != nil {
-		return nil, fmt.Errorf(`error searching for group memberships for user with DN %q: %w`, userDN, err)
+		return nil, err
 	}
 
-	groupAttributeName := p.c.GroupSearch.GroupNameAttribute
-	if len(groupAttributeName) == 0 {
-		groupAttributeName = distinguishedNameAttributeName
+	// de-duplicate the list of groups by turning it into a set,
+	// then turn it back into a sorted list.
+	return sets.NewString(groups...).List(), nil
+}
+
+// searchGroups performs one group search using the given filter. It returns the mapped names of the groups which
+// were found, and also their DNs.
+func (p *Provider) searchGroups(conn Conn, userDN string, filter string) ([]string, []string, error) {
+	searchResult, err := conn.SearchWithPaging(p.groupSearchRequest(filter), groupSearchPageSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`error searching for group memberships for user with DN %q: %w`, userDN, err)
 	}
 
-	groups := []string{}
-entries:
+	groups := make([]string, 0, len(searchResult.Entries))
+	groupDNs := make([]string, 0, len(searchResult.Entries))
 	for _, groupEntry := range searchResult.Entries {
 		if len(groupEntry.DN) == 0 {
-			return nil, fmt.Errorf(`searching for group memberships for user with DN %q resulted in search result without DN`, userDN)
+			return nil, nil, fmt.Errorf(`searching for group memberships for user with DN %q resulted in search result without DN`, userDN)
+		}
+		mappedGroupName, err := p.mapGroupName(groupEntry, userDN)
+		if err != nil {
+			return nil, nil, err
+		}
+		groups = append(groups, mappedGroupName)
+		groupDNs = append(groupDNs, groupEntry.DN)
+	}
+	return groups, groupDNs, nil
+}
+
+// recursivelySearchGroups performs the group search for the user, and then repeats the group search for each group
+// which was found, using the group's DN in the filter, to find nested groups up to the configured max depth.
+func (p *Provider) recursivelySearchGroups(conn Conn, userDN string, groupSearchUserAttributeForFilterValue string) ([]string, error) {
+	groups, groupDNs, err := p.searchGroups(conn, userDN, p.groupSearchFilter(userDN, groupSearchUserAttributeForFilterValue))
+	if err != nil {
+		return nil, err
+	}
+
+	visited := sets.New[string]()
+	for depth := 1; ; depth++ {
+		unvisitedGroupDNs := unvisited(visited, groupDNs)
+		if len(unvisitedGroupDNs) == 0 {
+			break
+		}
+		if depth >= p.groupSearchMaxDepth() {
+			plog.Debug("stopped searching for nested groups after reaching max depth",
+				"upstreamName", p.GetResourceName(), "userDN", userDN, "maxDepth", p.groupSearchMaxDepth())
+			break
 		}
-		if overrideFunc := p.c.GroupAttributeParsingOverrides[groupAttributeName]; overrideFunc != nil {
-			overrideGroupName, err := overrideFunc(groupEntry)
+
+		groupDNs = nil
+		for batch := range slices.Chunk(unvisitedGroupDNs, nestedGroupSearchBatchSize) {
+			nestedGroups, nestedGroupDNs, err := p.searchGroups(conn, userDN, p.nestedGroupSearchFilter(batch))
 			if err != nil {
-				return nil, fmt.Errorf("error finding groups for user %s: %w", userDN, err)
+				return nil, err
 			}
-			groups = append(groups, overrideGroupName)
-			continue entries
+			groups = append(groups, nestedGroups...)
+			groupDNs = append(groupDNs, nestedGroupDNs...)
 		}
-		// if none of the overrides matched, use the default behavior (no mapping)
-		mappedGroupName, err := p.getSearchResultAttributeValue(groupAttributeName, groupEntry, userDN)
+	}
+
+	return groups, nil
+}
+
+// followMemberOfAttribute reads the DNs of the user's groups from the memberOf attribute of the user's entry, and then
+// reads the entry of each group to find its name and the DNs of its own groups, up to the configured max depth.
+// Groups which are not within the group search base are ignored.
+func (p *Provider) followMemberOfAttribute(conn Conn, userDN string, userEntry *ldap.Entry) ([]string, error) {
+	base, err := ldap.ParseDN(p.c.GroupSearch.Base)
+	if err != nil {
+		return nil, fmt.Errorf(`error parsing group search base %q: %w`, p.c.GroupSearch.Base, err)
+	}
+
+	groups := []string{}
+	groupDNs := userEntry.GetAttributeValues(p.memberOfAttributeName())
+	visited := sets.New[string]()
+	for depth := 1; ; depth++ {
+		unvisitedGroupDNs := unvisited(visited, withinBase(base, groupDNs))
+		if len(unvisitedGroupDNs) == 0 {
+			break
+		}
+		if depth > p.groupSearchMaxDepth() {
+			plog.Debug("stopped following nested groups after reaching max depth",
+				"upstreamName", p.GetResourceName(), "userDN", userDN, "maxDepth", p.groupSearchMaxDepth())
+			break
+		}
+
+		groupDNs = nil
+		for _, groupDN := range unvisitedGroupDNs {
+			searchResult, err := conn.Search(p.memberOfGroupRequest(groupDN))
+			if err != nil {
+				return nil, fmt.Errorf(`error reading group with DN %q for user with DN %q: %w`, groupDN, userDN, err)
+			}
+			if len(searchResult.Entries) != 1 || len(searchResult.Entries[0].DN) == 0 {
+				return nil, fmt.Errorf(`reading group with DN %q for user with DN %q resulted in %d search results, but expected 1 result`,
+					groupDN, userDN, len(searchResult.Entries))
+			}
+			groupEntry := searchResult.Entries[0]
+			mappedGroupName, err := p.mapGroupName(groupEntry, userDN)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, mappedGroupName)
+			groupDNs = append(groupDNs, groupEntry.GetAttributeValues(p.memberOfAttributeName())...)
+		}
+	}
+
+	return groups, nil
+}
+
+func (p *Provider) mapGroupName(groupEntry *ldap.Entry, userDN string) (string, error) {
+	groupAttributeName := p.c.GroupSearch.GroupNameAttribute
+	if len(groupAttributeName) == 0 {
+		groupAttributeName = distinguishedNameAttributeName
+	}
+
+	if overrideFunc := p.c.GroupAttributeParsingOverrides[groupAttributeName]; overrideFunc != nil {
+		overrideGroupName, err := overrideFunc(groupEntry)
 		if err != nil {
-			return nil, fmt.Errorf(`error searching for group memberships for user with DN %q: %w`, userDN, err)
+			return "", fmt.Errorf("error finding groups for user %s: %w", userDN, err)
 		}
-		groups = append(groups, mappedGroupName)
+		return overrideGroupName, nil
 	}
-	// de-duplicate the list of groups by turning it into a set,
-	// then turn it back into a sorted list.
-	return sets.NewString(groups...).List(), nil
+
+	// if none of the overrides matched, use the default behavior (no mapping)
+	mappedGroupName, err := p.getSearchResultAttributeValue(groupAttributeName, groupEntry, userDN)
+	if err != nil {
+		return "", fmt.Errorf(`error searching for group memberships for user with DN %q: %w`, userDN, err)
+	}
+	return mappedGroupName, nil
+}
+
+// unvisited returns the DNs which have not already been visited, and marks them as visited.
+// DNs are compared case-insensitively.
+func unvisited(visited sets.Set[string], dns []string) []string {
+	result := []string{}
+	for _, dn := range dns {
+		key := strings.ToLower(dn)
+		if visited.Has(key) {
+			continue
+		}
+		visited.Insert(key)
+		result = append(result, dn)
+	}
+	return result
+}
+
+// withinBase returns the DNs which are equal to or descendants of the base DN. DNs which cannot be parsed are ignored.
+func withinBase(base *ldap.DN, dns []string) []string {
+	result := []string{}
+	for _, dn := range dns {
+		parsed, err := ldap.ParseDN(dn)
+		if err != nil {
+			plog.Debug("ignoring group with unparsable DN", "dn", dn, "err", err)
+			continue
+		}
+		if base.EqualFold(parsed) || base.AncestorOfFold(parsed) {
+			result = append(result, dn)
+		}
+	}
+	return result
 }
 
 func (p *Provider) validateConfig() error {
@@ -535,18 +942,12 @@ func (p *Provider) SearchForDefaultNamingContext(ctx context.Context) (string, e
 	t := trace.FromContext(ctx).Nest("slow ldap attempt when searching for default naming context", trace.Field{Key: "providerName", Value: p.GetResourceName()})
 	defer t.LogIfLong(500 * time.Millisecond) // to help users debug slow LDAP searches
 
-	conn, err := p.dial(ctx)
+	conn, err := p.connect(ctx, "before querying for defaultNamingContext")
 	if err != nil {
 		p.traceSearchBaseDiscoveryFailure(t, err)
-		return "", fmt.Errorf(`error dialing host %q: %w`, p.c.Host, err)
-	}
-	defer closeAndLogError(conn, "searching for default naming context")
-
-	err = conn.Bind(p.c.BindUsername, p.c.BindPassword)
-	if err != nil {
-		p.traceSearchBaseDiscoveryFailure(t, err)
-		return "", fmt.Errorf(`error binding as %q before querying for defaultNamingContext: %w`, p.c.BindUsername, err)
+		return "", err
 	}
+	defer p.releaseConn(conn, "searching for default naming context")
 
 	searchResult, err := conn.Search(p.defaultNamingContextRequest())
 	if err != nil {
@@ -618,7 +1019,7 @@ func (p *Provider) searchAndBindUser(conn Conn, username string, bindFunc func(c
 		}
 	}
 
-	mappedGroupNames, err := p.searchGroupsForUserMembership(conn, userEntry.DN, groupSearchUserAttributeForFilterValue)
+	mappedGroupNames, err := p.searchGroupsForUserMembership(conn, userEntry.DN, userEntry, groupSearchUserAttributeForFilterValue)
 	if err != nil {
 		return nil, err
 	}
@@ -657,6 +1058,11 @@ func (p *Provider) searchAndBindUser(conn Conn, username string, bindFunc func(c
 		},
 		DN:                     userEntry.DN,
 		ExtraRefreshAttributes: mappedRefreshAttributes,
+		AdditionalClaims:       p.additionalClaimValues(userEntry),
+		UpstreamData: map[string]any{
+			"dn":         userEntry.DN,
+			"attributes": textAttributeValues(userEntry),
+		},
 	}
 
 	return response, nil
@@ -691,7 +1097,7 @@ func (p *Provider) userSearchRequest(username string) *ldap.SearchRequest {
 	}
 }
 
-func (p *Provider) groupSearchRequest(userDN string, groupSearchUserAttributeForFilterValue string) *ldap.SearchRequest {
+func (p *Provider) groupSearchRequest(filter string) *ldap.SearchRequest {
 	// See https://ldap.com/the-ldap-search-operation for general documentation of LDAP search options.
 	return &ldap.SearchRequest{
 		BaseDN:       p.c.GroupSearch.Base,
@@ -700,12 +1106,27 @@ func (p *Provider) groupSearchRequest(userDN string, groupSearchUserAttributeFor
 		SizeLimit:    0, // unlimited size because we will search with paging
 		TimeLimit:    90,
 		TypesOnly:    false,
-		Filter:       p.groupSearchFilter(userDN, groupSearchUserAttributeForFilterValue),
+		Filter:       filter,
 		Attributes:   p.groupSearchRequestedAttributes(),
 		Controls:     nil, // nil because ldap.SearchWithPaging() will set the appropriate controls for us
 	}
 }
 
+func (p *Provider) memberOfGroupRequest(groupDN string) *ldap.SearchRequest {
+	// See https://ldap.com/the-ldap-search-operation for general documentation of LDAP search options.
+	return &ldap.SearchRequest{
+		BaseDN:       groupDN,
+		Scope:        ldap.ScopeBaseObject,
+		DerefAliases: ldap.NeverDerefAliases,
+		SizeLimit:    2,
+		TimeLimit:    90,
+		TypesOnly:    false,
+		Filter:       "(objectClass=*)", // we already have the dn, so the filter doesn't matter
+		Attributes:   append(p.groupSearchRequestedAttributes(), p.memberOfAttributeName()),
+		Controls:     nil, // this could be used to enable paging, but we're already limiting the result max size
+	}
+}
+
 func (p *Provider) refreshUserSearchRequest(dn string) *ldap.SearchRequest {
 	// See https://ldap.com/the-ldap-search-operation for general documentation of LDAP search options.
 	return &ldap.SearchRequest{
@@ -726,8 +1147,26 @@ func (p *Provider) useGroupSearchUserAttributeForFilter() bool {
 		p.c.GroupSearch.UserAttributeForFilter != distinguishedNameAttributeName
 }
 
+func (p *Provider) useMemberOfAttribute() bool {
+	return len(p.c.GroupSearch.Base) > 0 && p.c.GroupSearch.Strategy == GroupSearchStrategyMemberOfAttribute
+}
+
+func (p *Provider) memberOfAttributeName() string {
+	if len(p.c.GroupSearch.MemberOfAttribute) == 0 {
+		return defaultMemberOfAttributeName
+	}
+	return p.c.GroupSearch.MemberOfAttribute
+}
+
+func (p *Provider) groupSearchMaxDepth() int {
+	if p.c.GroupSearch.MaxDepth < 1 {
+		return defaultGroupSearchMaxDepth
+	}
+	return p.c.GroupSearch.MaxDepth
+}
+
 func (p *Provider) userSearchRequestedAttributes() []string {
-	attributes := make([]string, 0, len(p.c.RefreshAttributeChecks)+2)
+	attributes := make([]string, 0, len(p.c.RefreshAttributeChecks)+len(p.c.UserSearch.AdditionalClaimMappings)+4)
 	if p.c.UserSearch.UsernameAttribute != distinguishedNameAttributeName {
 		attributes = append(attributes, p.c.UserSearch.UsernameAttribute)
 	}
@@ -737,12 +1176,72 @@ func (p *Provider) userSearchRequestedAttributes() []string {
 	if p.useGroupSearchUserAttributeForFilter() {
 		attributes = append(attributes, p.c.GroupSearch.UserAttributeForFilter)
 	}
+	if p.useMemberOfAttribute() {
+		attributes = append(attributes, p.memberOfAttributeName())
+	}
 	for k := range p.c.RefreshAttributeChecks {
 		attributes = append(attributes, k)
 	}
+	for _, attributeName := range p.additionalClaimAttributeNames() {
+		if attributeName != distinguishedNameAttributeName {
+			attributes = append(attributes, attributeName)
+		}
+	}
 	return attributes
 }
 
+// additionalClaimAttributeNames returns the sorted and unique names of the attributes which are mapped
+// to additional downstream claims.
+func (p *Provider) additionalClaimAttributeNames() []string {
+	attributeNames := sets.New[string]()
+	for _, attributeName := range p.c.UserSearch.AdditionalClaimMappings {
+		attributeNames.Insert(attributeName)
+	}
+	return sets.List(attributeNames)
+}
+
+// additionalClaimValues returns the values of the attributes of the user's entry which are mapped to additional
+// downstream claims, keyed by the name of the downstream claim. Attributes which are not present on the entry are
+// skipped. Returns nil when there are no values.
+func (p *Provider) additionalClaimValues(userEntry *ldap.Entry) map[string][]string {
+	var values map[string][]string
+	for claimName, attributeName := range p.c.UserSearch.AdditionalClaimMappings {
+		var attributeValues []string
+		if attributeName == distinguishedNameAttributeName {
+			attributeValues = []string{userEntry.DN}
+		} else {
+			attributeValues = userEntry.GetAttributeValues(attributeName)
+		}
+		if len(attributeValues) == 0 {
+			plog.Debug("additionalClaimMappings attribute missing from user entry",
+				"upstreamName", p.GetResourceName(),
+				"attributeName", attributeName,
+				"dn", userEntry.DN,
+			)
+			continue
+		}
+		if values == nil {
+			values = make(map[string][]string, len(p.c.UserSearch.AdditionalClaimMappings))
+		}
+		values[claimName] = attributeValues
+	}
+	return values
+}
+
+// textAttributeValues returns the values of all the attributes which were read from the entry, keyed by attribute name.
+// Values which are not valid UTF-8 text, e.g. the values of binary attributes like objectGUID, are skipped.
+func textAttributeValues(entry *ldap.Entry) map[string][]string {
+	values := make(map[string][]string, len(entry.Attributes))
+	for _, attribute := range entry.Attributes {
+		for _, value := range attribute.Values {
+			if utf8.ValidString(value) {
+				values[attribute.Name] = append(values[attribute.Name], value)
+			}
+		}
+	}
+	return values
+}
+
 func (p *Provider) groupSearchRequestedAttributes() []string {
 	switch p.c.GroupSearch.GroupNameAttribute {
 	case "":
@@ -770,6 +1269,10 @@ func (p *Provider) groupSearchFilter(userDN string, groupSearchUserAttributeForF
 		// Instead of using the DN in placeholder substitution, use the value of the specified attribute.
 		valueToInterpolate = groupSearchUserAttributeForFilterValue
 	}
+	return p.interpolateGroupSearchFilter(valueToInterpolate)
+}
+
+func (p *Provider) interpolateGroupSearchFilter(valueToInterpolate string) string {
 	// The value to interpolate can contain characters that are considered special characters by LDAP searches,
 	// so it should be escaped before being included in the search filter to prevent bad search syntax.
 	// E.g. for the DN `CN=My User (Admin),OU=Users,OU=my,DC=my,DC=domain` we must escape the parens.
@@ -780,6 +1283,19 @@ func (p *Provider) groupSearchFilter(userDN string, groupSearchUserAttributeForF
 	return interpolateSearchFilter(p.c.GroupSearch.Filter, escapedValueToInterpolate)
 }
 
+// nestedGroupSearchFilter returns a filter which finds the groups containing any of the given groups, by
+// interpolating the DN of each group into the group search filter.
+func (p *Provider) nestedGroupSearchFilter(groupDNs []string) string {
+	filters := make([]string, 0, len(groupDNs))
+	for _, groupDN := range groupDNs {
+		filters = append(filters, p.interpolateGroupSearchFilter(groupDN))
+	}
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return "(|" + strings.Join(filters, "") + ")"
+}
+
 func interpolateSearchFilter(filterFormat, valueToInterpolateIntoFilter string) string {
 	filter := strings.ReplaceAll(filterFormat, searchFilterInterpolationLocationMarker, valueToInterpolateIntoFilter)
 	if strings.HasPrefix(filter, "(") && strings.HasSuffix(filter, ")") {
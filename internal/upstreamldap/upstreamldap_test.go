@@ -1,4 +1,4 @@
-// Copyright 2021-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2021-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package upstreamldap
@@ -9,10 +9,13 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -65,6 +68,54 @@ var (
 	testGroupSearchFilterInterpolated = fmt.Sprintf(testGroupSearchFilterInterpolationSpec, testUserSearchResultDNValue, testUserSearchResultDNValue)
 )
 
+const (
+	testNestedGroupSearchBase = "ou=groups,dc=pinniped,dc=dev"
+	testNestedGroupDN1        = "cn=group1,ou=groups,dc=pinniped,dc=dev"
+	testNestedGroupDN2        = "cn=group2,ou=groups,dc=pinniped,dc=dev"
+	testNestedGroupDN3        = "cn=group3,ou=nested,ou=groups,dc=pinniped,dc=dev"
+	testGroupOutsideBaseDN    = "cn=group4,ou=other,dc=pinniped,dc=dev"
+)
+
+// groupEntry returns a group entry with the given DN, group name, and values of the memberOf attribute.
+func groupEntry(dn string, groupName string, memberOf ...string) *ldap.Entry {
+	attributes := []*ldap.EntryAttribute{
+		ldap.NewEntryAttribute(testGroupSearchGroupNameAttribute, []string{groupName}),
+	}
+	if len(memberOf) > 0 {
+		attributes = append(attributes, ldap.NewEntryAttribute("memberOf", memberOf))
+	}
+	return &ldap.Entry{DN: dn, Attributes: attributes}
+}
+
+func groupSearchResult(entries ...*ldap.Entry) *ldap.SearchResult {
+	return &ldap.SearchResult{Entries: entries, Referrals: []string{}, Controls: []ldap.Control{}}
+}
+
+func expectedMemberOfGroupRead(groupDN string, memberOfAttribute string) *ldap.SearchRequest {
+	return &ldap.SearchRequest{
+		BaseDN:       groupDN,
+		Scope:        ldap.ScopeBaseObject,
+		DerefAliases: ldap.NeverDerefAliases,
+		SizeLimit:    2,
+		TimeLimit:    90,
+		TypesOnly:    false,
+		Filter:       "(objectClass=*)",
+		Attributes:   []string{testGroupSearchGroupNameAttribute, memberOfAttribute},
+		Controls:     nil,
+	}
+}
+
+func nestedGroupSearchFilter(groupDNs ...string) string {
+	filters := ""
+	for _, groupDN := range groupDNs {
+		filters += fmt.Sprintf(testGroupSearchFilterInterpolationSpec, groupDN, groupDN)
+	}
+	if len(groupDNs) == 1 {
+		return filters
+	}
+	return "(|" + filters + ")"
+}
+
 func TestEndUserAuthentication(t *testing.T) {
 	providerConfig := func(editFunc func(p *ProviderConfig)) *ProviderConfig {
 		config := &ProviderConfig{
@@ -166,7 +217,18 @@ func TestEndUserAuthentication(t *testing.T) {
 			UID:    base64.RawURLEncoding.EncodeToString([]byte(testUserSearchResultUIDAttributeValue)),
 			Groups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
 		}
-		response := &authenticators.Response{User: u, DN: testUserSearchResultDNValue, ExtraRefreshAttributes: map[string]string{}}
+		response := &authenticators.Response{
+			User:                   u,
+			DN:                     testUserSearchResultDNValue,
+			ExtraRefreshAttributes: map[string]string{},
+			UpstreamData: map[string]any{
+				"dn": testUserSearchResultDNValue,
+				"attributes": map[string][]string{
+					testUserSearchUsernameAttribute: {testUserSearchResultUsernameAttributeValue},
+					testUserSearchUIDAttribute:      {testUserSearchResultUIDAttributeValue},
+				},
+			},
+		}
 		if editFunc != nil {
 			editFunc(response)
 		}
@@ -204,6 +266,57 @@ func TestEndUserAuthentication(t *testing.T) {
 			},
 			wantAuthResponse: expectedAuthResponse(nil),
 		},
+		{
+			name:     "happy path with additional claim mappings",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.UserSearch.AdditionalClaimMappings = map[string]string{
+					"email":       "mail",
+					"displayName": "displayName",
+					"aliases":     "mailAlternateAddress",
+					"userDN":      "dn",
+					"alsoEmail":   "mail",
+					"employeeID":  "employeeID", // not present on the entry
+				}
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = append(r.Attributes, "displayName", "employeeID", "mail", "mailAlternateAddress")
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: append(slices.Clone(exampleUserSearchResult.Entries[0].Attributes),
+								ldap.NewEntryAttribute("mail", []string{"jane@example.com"}),
+								ldap.NewEntryAttribute("displayName", []string{"Jane Doe"}),
+								ldap.NewEntryAttribute("mailAlternateAddress", []string{"j@example.com", "jdoe@example.com"}),
+							),
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(exampleGroupSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				attributes := r.UpstreamData["attributes"].(map[string][]string)
+				attributes["mail"] = []string{"jane@example.com"}
+				attributes["displayName"] = []string{"Jane Doe"}
+				attributes["mailAlternateAddress"] = []string{"j@example.com", "jdoe@example.com"}
+				r.AdditionalClaims = map[string][]string{
+					"email":       {"jane@example.com"},
+					"displayName": {"Jane Doe"},
+					"aliases":     {"j@example.com", "jdoe@example.com"},
+					"userDN":      {testUserSearchResultDNValue},
+					"alsoEmail":   {"jane@example.com"},
+				}
+			}),
+		},
 		{
 			name:     "when the user search filter is already wrapped by parenthesis then it is not wrapped again",
 			username: testUpstreamUsername,
@@ -289,6 +402,328 @@ func TestEndUserAuthentication(t *testing.T) {
 				info.Groups = []string{}
 			}),
 		},
+		{
+			name:     "when the group search strategy is search then only one group search is performed",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Strategy = GroupSearchStrategySearch
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(exampleGroupSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(nil),
+		},
+		{
+			name:     "when the group search strategy is recursiveSearch then the group search is repeated for nested groups until no new groups are found, following each group only once",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Strategy = GroupSearchStrategyRecursiveSearch
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(exampleGroupSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = nestedGroupSearchFilter(testGroupSearchResultDNValue1, testGroupSearchResultDNValue2)
+				}), expectedGroupSearchPageSize).
+					Return(groupSearchResult(
+						groupEntry(testNestedGroupDN3, "some-nested-group-name"),
+						groupEntry(strings.ToUpper(testGroupSearchResultDNValue1), testGroupSearchResultGroupNameAttributeValue1),
+					), nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = nestedGroupSearchFilter(testNestedGroupDN3)
+				}), expectedGroupSearchPageSize).
+					Return(groupSearchResult(
+						groupEntry(testGroupSearchResultDNValue2, testGroupSearchResultGroupNameAttributeValue2),
+					), nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				info := r.User.(*user.DefaultInfo)
+				info.Groups = []string{"some-nested-group-name", testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2}
+			}),
+		},
+		{
+			name:     "when the group search strategy is recursiveSearch then nested groups beyond the max depth are not found",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Strategy = GroupSearchStrategyRecursiveSearch
+				p.GroupSearch.MaxDepth = 2
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(exampleGroupSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = nestedGroupSearchFilter(testGroupSearchResultDNValue1, testGroupSearchResultDNValue2)
+				}), expectedGroupSearchPageSize).
+					Return(groupSearchResult(groupEntry(testNestedGroupDN3, "some-nested-group-name")), nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				info := r.User.(*user.DefaultInfo)
+				info.Groups = []string{"some-nested-group-name", testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2}
+			}),
+		},
+		{
+			name:     "when the group search strategy is recursiveSearch and the max depth is 1 then only the direct groups are found",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Strategy = GroupSearchStrategyRecursiveSearch
+				p.GroupSearch.MaxDepth = 1
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(exampleGroupSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(nil),
+		},
+		{
+			name:     "when the group search strategy is recursiveSearch and a nested group search fails",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Strategy = GroupSearchStrategyRecursiveSearch
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(exampleGroupSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = nestedGroupSearchFilter(testGroupSearchResultDNValue1, testGroupSearchResultDNValue2)
+				}), expectedGroupSearchPageSize).
+					Return(nil, errors.New("some nested group search error")).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantError: testutil.WantSprintfErrorString(`error searching for group memberships for user with DN %q: some nested group search error`, testUserSearchResultDNValue),
+		},
+		{
+			name:     "when the group search strategy is memberOfAttribute then the groups are read from the memberOf attribute of the user and each group, following each group only once",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Base = testNestedGroupSearchBase
+				p.GroupSearch.Strategy = GroupSearchStrategyMemberOfAttribute
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "memberOf"}
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: []*ldap.EntryAttribute{
+								ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+								ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+								ldap.NewEntryAttribute("memberOf", []string{testNestedGroupDN1, testNestedGroupDN2, testGroupOutsideBaseDN}),
+							},
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().Search(expectedMemberOfGroupRead(testNestedGroupDN1, "memberOf")).
+					Return(groupSearchResult(groupEntry(testNestedGroupDN1, "group1", testNestedGroupDN3)), nil).Times(1)
+				conn.EXPECT().Search(expectedMemberOfGroupRead(testNestedGroupDN2, "memberOf")).
+					Return(groupSearchResult(groupEntry(testNestedGroupDN2, "group2")), nil).Times(1)
+				conn.EXPECT().Search(expectedMemberOfGroupRead(testNestedGroupDN3, "memberOf")).
+					Return(groupSearchResult(groupEntry(testNestedGroupDN3, "group3", strings.ToUpper(testNestedGroupDN1), testGroupOutsideBaseDN)), nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				attributes := r.UpstreamData["attributes"].(map[string][]string)
+				attributes["memberOf"] = []string{testNestedGroupDN1, testNestedGroupDN2, testGroupOutsideBaseDN}
+				info := r.User.(*user.DefaultInfo)
+				info.Groups = []string{"group1", "group2", "group3"}
+			}),
+		},
+		{
+			name:     "when the group search strategy is memberOfAttribute with a custom attribute name and max depth then nested groups beyond the max depth are not found",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Base = testNestedGroupSearchBase
+				p.GroupSearch.Strategy = GroupSearchStrategyMemberOfAttribute
+				p.GroupSearch.MemberOfAttribute = "isMemberOf"
+				p.GroupSearch.MaxDepth = 1
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "isMemberOf"}
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: []*ldap.EntryAttribute{
+								ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+								ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+								ldap.NewEntryAttribute("isMemberOf", []string{testNestedGroupDN1}),
+							},
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().Search(expectedMemberOfGroupRead(testNestedGroupDN1, "isMemberOf")).
+					Return(groupSearchResult(&ldap.Entry{
+						DN: testNestedGroupDN1,
+						Attributes: []*ldap.EntryAttribute{
+							ldap.NewEntryAttribute(testGroupSearchGroupNameAttribute, []string{"group1"}),
+							ldap.NewEntryAttribute("isMemberOf", []string{testNestedGroupDN3}),
+						},
+					}), nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				attributes := r.UpstreamData["attributes"].(map[string][]string)
+				attributes["isMemberOf"] = []string{testNestedGroupDN1}
+				info := r.User.(*user.DefaultInfo)
+				info.Groups = []string{"group1"}
+			}),
+		},
+		{
+			name:     "when the group search strategy is memberOfAttribute and the user does not belong to any groups",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Base = testNestedGroupSearchBase
+				p.GroupSearch.Strategy = GroupSearchStrategyMemberOfAttribute
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "memberOf"}
+				})).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
+			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				info := r.User.(*user.DefaultInfo)
+				info.Groups = []string{}
+			}),
+		},
+		{
+			name:     "when the group search strategy is memberOfAttribute and reading a group fails",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Base = testNestedGroupSearchBase
+				p.GroupSearch.Strategy = GroupSearchStrategyMemberOfAttribute
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "memberOf"}
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: []*ldap.EntryAttribute{
+								ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+								ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+								ldap.NewEntryAttribute("memberOf", []string{testNestedGroupDN1}),
+							},
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().Search(expectedMemberOfGroupRead(testNestedGroupDN1, "memberOf")).
+					Return(nil, errors.New("some group read error")).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantError: testutil.WantSprintfErrorString(`error reading group with DN %q for user with DN %q: some group read error`, testNestedGroupDN1, testUserSearchResultDNValue),
+		},
+		{
+			name:     "when the group search strategy is memberOfAttribute and reading a group returns no entries",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Base = testNestedGroupSearchBase
+				p.GroupSearch.Strategy = GroupSearchStrategyMemberOfAttribute
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "memberOf"}
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: []*ldap.EntryAttribute{
+								ldap.NewEntryAttribute(testUserSearchUsernameAttribute, []string{testUserSearchResultUsernameAttributeValue}),
+								ldap.NewEntryAttribute(testUserSearchUIDAttribute, []string{testUserSearchResultUIDAttributeValue}),
+								ldap.NewEntryAttribute("memberOf", []string{testNestedGroupDN1}),
+							},
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().Search(expectedMemberOfGroupRead(testNestedGroupDN1, "memberOf")).
+					Return(groupSearchResult(), nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantError: testutil.WantSprintfErrorString(`reading group with DN %q for user with DN %q resulted in 0 search results, but expected 1 result`, testNestedGroupDN1, testUserSearchResultDNValue),
+		},
+		{
+			name:     "when the group search strategy is memberOfAttribute and the group search base is not a valid DN",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Strategy = GroupSearchStrategyMemberOfAttribute
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "memberOf"}
+				})).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantError: testutil.WantSprintfErrorString(`error parsing group search base %q: DN ended with incomplete type, value pair`, testGroupSearchBase),
+		},
+		{
+			name:     "when the group search strategy is unknown",
+			username: testUpstreamUsername,
+			password: testUpstreamPassword,
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Strategy = "some-unknown-strategy"
+			}),
+			searchMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(exampleUserSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantError: testutil.WantExactErrorString(`unsupported group search strategy "some-unknown-strategy"`),
+		},
 		{
 			name:     "when the UsernameAttribute is dn and there is a user search filter provided",
 			username: testUpstreamUsername,
@@ -318,6 +753,7 @@ func TestEndUserAuthentication(t *testing.T) {
 				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
 			},
 			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				delete(r.UpstreamData["attributes"].(map[string][]string), testUserSearchUsernameAttribute)
 				info := r.User.(*user.DefaultInfo)
 				info.Name = testUserSearchResultDNValue
 			}),
@@ -351,6 +787,7 @@ func TestEndUserAuthentication(t *testing.T) {
 				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
 			},
 			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				delete(r.UpstreamData["attributes"].(map[string][]string), testUserSearchUIDAttribute)
 				info := r.User.(*user.DefaultInfo)
 				info.UID = base64.RawURLEncoding.EncodeToString([]byte(testUserSearchResultDNValue))
 			}),
@@ -552,6 +989,7 @@ func TestEndUserAuthentication(t *testing.T) {
 				conn.EXPECT().Bind(testUserDNWithSpecialChars, testUpstreamPassword).Times(1)
 			},
 			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				r.UpstreamData["dn"] = testUserDNWithSpecialChars
 				r.DN = testUserDNWithSpecialChars
 			}),
 		},
@@ -585,6 +1023,7 @@ func TestEndUserAuthentication(t *testing.T) {
 				conn.EXPECT().Bind(testUserDNWithSpecialChars, testUpstreamPassword).Times(1)
 			},
 			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				r.UpstreamData["dn"] = testUserDNWithSpecialChars
 				r.DN = testUserDNWithSpecialChars
 			}),
 		},
@@ -626,15 +1065,10 @@ func TestEndUserAuthentication(t *testing.T) {
 			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
 				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
 			},
-			wantAuthResponse: &authenticators.Response{
-				User: &user.DefaultInfo{
-					Name:   testUserSearchResultUsernameAttributeValue,
-					UID:    base64.RawURLEncoding.EncodeToString([]byte(testUserSearchResultUIDAttributeValue)),
-					Groups: []string{"a", "b", "c"},
-				},
-				DN:                     testUserSearchResultDNValue,
-				ExtraRefreshAttributes: map[string]string{},
-			},
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				info := r.User.(*user.DefaultInfo)
+				info.Groups = []string{"a", "b", "c"}
+			}),
 		},
 		{
 			name:     "requesting additional refresh related attributes",
@@ -671,6 +1105,7 @@ func TestEndUserAuthentication(t *testing.T) {
 				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
 			},
 			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				r.UpstreamData["attributes"].(map[string][]string)["some-attribute-to-check-during-refresh"] = []string{"some-attribute-value"}
 				r.ExtraRefreshAttributes = map[string]string{"some-attribute-to-check-during-refresh": "c29tZS1hdHRyaWJ1dGUtdmFsdWU"}
 			}),
 		},
@@ -731,7 +1166,9 @@ func TestEndUserAuthentication(t *testing.T) {
 			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
 				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
 			},
-			wantAuthResponse: expectedAuthResponse(nil),
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				r.UpstreamData["attributes"].(map[string][]string)["someUserAttrName"] = []string{"someUserAttrValue"}
+			}),
 		},
 		{
 			name:     "when the UserAttributeForFilter is set to something other than dn but that attribute is not returned by the user search",
@@ -862,7 +1299,9 @@ func TestEndUserAuthentication(t *testing.T) {
 			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
 				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
 			},
-			wantAuthResponse: expectedAuthResponse(nil),
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				r.UpstreamData["attributes"].(map[string][]string)["someUserAttrName"] = []string{"someUserAttrValue&(abc)"}
+			}),
 		},
 		{
 			name:     "when the UserAttributeForFilter is set to something other than dn but the group search filter is not set",
@@ -900,7 +1339,9 @@ func TestEndUserAuthentication(t *testing.T) {
 			bindEndUserMocks: func(conn *mockldapconn.MockConn) {
 				conn.EXPECT().Bind(testUserSearchResultDNValue, testUpstreamPassword).Times(1)
 			},
-			wantAuthResponse: expectedAuthResponse(nil),
+			wantAuthResponse: expectedAuthResponse(func(r *authenticators.Response) {
+				r.UpstreamData["attributes"].(map[string][]string)["someUserAttrName"] = []string{"someUserAttrValue&(abc)"}
+			}),
 		},
 		{
 			name:           "when dial fails",
@@ -1509,6 +1950,18 @@ func TestUpstreamRefresh(t *testing.T) {
 		Controls:  []ldap.Control{},
 	}
 
+	// The UID attribute of the happy path user entry only has binary values, so it is not part of the upstream data.
+	upstreamData := func(dn string, additionalAttributes map[string][]string) map[string]any {
+		attributes := map[string][]string{
+			testUserSearchUsernameAttribute: {testUserSearchResultUsernameAttributeValue},
+			pwdLastSetAttribute:             {"132801740800000000"},
+		}
+		maps.Copy(attributes, additionalAttributes)
+		return map[string]any{"dn": dn, "attributes": attributes}
+	}
+
+	happyPathUpstreamData := upstreamData(testUserSearchResultDNValue, nil)
+
 	providerConfig := func(editFunc func(p *ProviderConfig)) *ProviderConfig {
 		config := &ProviderConfig{
 			Name:               "some-provider-name",
@@ -1538,13 +1991,15 @@ func TestUpstreamRefresh(t *testing.T) {
 	}
 
 	tests := []struct {
-		name           string
-		providerConfig *ProviderConfig
-		setupMocks     func(conn *mockldapconn.MockConn)
-		refreshUserDN  string
-		dialError      error
-		wantErr        string
-		wantGroups     []string
+		name                 string
+		providerConfig       *ProviderConfig
+		setupMocks           func(conn *mockldapconn.MockConn)
+		refreshUserDN        string
+		dialError            error
+		wantErr              string
+		wantGroups           []string
+		wantAdditionalClaims map[string][]string
+		wantUpstreamData     map[string]any
 	}{
 		{
 			name: "happy path without group search where searching the dn returns a single entry",
@@ -1556,7 +2011,125 @@ func TestUpstreamRefresh(t *testing.T) {
 				conn.EXPECT().Search(expectedUserSearch(nil)).Return(happyPathUserSearchResult, nil).Times(1)
 				conn.EXPECT().Close().Times(1)
 			},
-			wantGroups: []string{},
+			wantGroups:       []string{},
+			wantUpstreamData: happyPathUpstreamData,
+		},
+		{
+			name: "happy path with additional claim mappings returns the refreshed values of the mapped attributes",
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.UserSearch.AdditionalClaimMappings = map[string]string{
+					"email":      "mail",
+					"aliases":    "mailAlternateAddress",
+					"userDN":     "dn",
+					"employeeID": "employeeID", // not present on the entry
+				}
+			}),
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = append(r.Attributes, "employeeID", "mail", "mailAlternateAddress")
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: append(slices.Clone(happyPathUserSearchResult.Entries[0].Attributes),
+								ldap.NewEntryAttribute("mail", []string{"jane@example.com"}),
+								ldap.NewEntryAttribute("mailAlternateAddress", []string{"j@example.com", "jdoe@example.com"}),
+							),
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(happyPathGroupSearchResult, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantGroups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantAdditionalClaims: map[string][]string{
+				"email":   {"jane@example.com"},
+				"aliases": {"j@example.com", "jdoe@example.com"},
+				"userDN":  {testUserSearchResultDNValue},
+			},
+			wantUpstreamData: upstreamData(testUserSearchResultDNValue, map[string][]string{
+				"mail":                 {"jane@example.com"},
+				"mailAlternateAddress": {"j@example.com", "jdoe@example.com"},
+			}),
+		},
+		{
+			name: "happy path with additional claim mappings and skipping group refresh still refreshes the mapped attributes",
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.UserSearch.AdditionalClaimMappings = map[string]string{"email": "mail"}
+				p.GroupSearch.SkipGroupRefresh = true
+			}),
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = append(r.Attributes, "mail")
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: append(slices.Clone(happyPathUserSearchResult.Entries[0].Attributes),
+								ldap.NewEntryAttribute("mail", []string{"jane.new@example.com"}),
+							),
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantAdditionalClaims: map[string][]string{"email": {"jane.new@example.com"}},
+			wantUpstreamData:     upstreamData(testUserSearchResultDNValue, map[string][]string{"mail": {"jane.new@example.com"}}),
+		},
+		{
+			name: "happy path with recursiveSearch group search strategy",
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Strategy = GroupSearchStrategyRecursiveSearch
+			}),
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(nil)).Return(happyPathUserSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).
+					Return(happyPathGroupSearchResult, nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = nestedGroupSearchFilter(testGroupSearchResultDNValue1, testGroupSearchResultDNValue2)
+				}), expectedGroupSearchPageSize).
+					Return(groupSearchResult(groupEntry(testNestedGroupDN3, "some-nested-group-name")), nil).Times(1)
+				conn.EXPECT().SearchWithPaging(expectedGroupSearch(func(r *ldap.SearchRequest) {
+					r.Filter = nestedGroupSearchFilter(testNestedGroupDN3)
+				}), expectedGroupSearchPageSize).
+					Return(groupSearchResult(), nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantGroups:       []string{"some-nested-group-name", testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantUpstreamData: happyPathUpstreamData,
+		},
+		{
+			name: "happy path with memberOfAttribute group search strategy",
+			providerConfig: providerConfig(func(p *ProviderConfig) {
+				p.GroupSearch.Base = testNestedGroupSearchBase
+				p.GroupSearch.Strategy = GroupSearchStrategyMemberOfAttribute
+			}),
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Bind(testBindUsername, testBindPassword).Times(1)
+				conn.EXPECT().Search(expectedUserSearch(func(r *ldap.SearchRequest) {
+					r.Attributes = []string{testUserSearchUsernameAttribute, testUserSearchUIDAttribute, "memberOf", pwdLastSetAttribute}
+				})).Return(&ldap.SearchResult{
+					Entries: []*ldap.Entry{
+						{
+							DN: testUserSearchResultDNValue,
+							Attributes: append(happyPathUserSearchResult.Entries[0].Attributes,
+								ldap.NewEntryAttribute("memberOf", []string{testNestedGroupDN1}),
+							),
+						},
+					},
+				}, nil).Times(1)
+				conn.EXPECT().Search(expectedMemberOfGroupRead(testNestedGroupDN1, "memberOf")).
+					Return(groupSearchResult(groupEntry(testNestedGroupDN1, "group1", testNestedGroupDN2)), nil).Times(1)
+				conn.EXPECT().Search(expectedMemberOfGroupRead(testNestedGroupDN2, "memberOf")).
+					Return(groupSearchResult(groupEntry(testNestedGroupDN2, "group2")), nil).Times(1)
+				conn.EXPECT().Close().Times(1)
+			},
+			wantGroups:       []string{"group1", "group2"},
+			wantUpstreamData: upstreamData(testUserSearchResultDNValue, map[string][]string{"memberOf": {testNestedGroupDN1}}),
 		},
 		{
 			name:           "happy path where group search returns groups",
@@ -1567,7 +2140,8 @@ func TestUpstreamRefresh(t *testing.T) {
 				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).Return(happyPathGroupSearchResult, nil).Times(1)
 				conn.EXPECT().Close().Times(1)
 			},
-			wantGroups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantGroups:       []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantUpstreamData: happyPathUpstreamData,
 		},
 		{
 			name:           "happy path when the user DN has special LDAP search filter characters then they must be properly escaped in the custom group search filter",
@@ -1604,8 +2178,9 @@ func TestUpstreamRefresh(t *testing.T) {
 				}), expectedGroupSearchPageSize).Return(happyPathGroupSearchResult, nil).Times(1)
 				conn.EXPECT().Close().Times(1)
 			},
-			refreshUserDN: testUserDNWithSpecialChars,
-			wantGroups:    []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			refreshUserDN:    testUserDNWithSpecialChars,
+			wantGroups:       []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantUpstreamData: upstreamData(testUserDNWithSpecialChars, nil),
 		},
 		{
 			name: "when the user DN has special LDAP search filter characters then they must be properly escaped in the default group search filter",
@@ -1644,8 +2219,9 @@ func TestUpstreamRefresh(t *testing.T) {
 				}), expectedGroupSearchPageSize).Return(happyPathGroupSearchResult, nil).Times(1)
 				conn.EXPECT().Close().Times(1)
 			},
-			refreshUserDN: testUserDNWithSpecialChars,
-			wantGroups:    []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			refreshUserDN:    testUserDNWithSpecialChars,
+			wantGroups:       []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantUpstreamData: upstreamData(testUserDNWithSpecialChars, nil),
 		},
 		{
 			name:           "happy path where group search returns no groups",
@@ -1660,7 +2236,8 @@ func TestUpstreamRefresh(t *testing.T) {
 				}, nil).Times(1)
 				conn.EXPECT().Close().Times(1)
 			},
-			wantGroups: []string{},
+			wantGroups:       []string{},
+			wantUpstreamData: happyPathUpstreamData,
 		},
 		{
 			name: "happy path where group search is configured but skipGroupRefresh is set",
@@ -1673,7 +2250,8 @@ func TestUpstreamRefresh(t *testing.T) {
 				// note that group search is not expected
 				conn.EXPECT().Close().Times(1)
 			},
-			wantGroups: nil, // do not update groups
+			wantGroups:       nil, // do not update groups
+			wantUpstreamData: happyPathUpstreamData,
 		},
 		{
 			name: "happy path where group search is configured but skipGroupRefresh is set, when the UserAttributeForFilter is set to something other than dn, still skips group refresh, and skips validating UserAttributeForFilter attribute value",
@@ -1690,7 +2268,8 @@ func TestUpstreamRefresh(t *testing.T) {
 				// note that group search is not expected
 				conn.EXPECT().Close().Times(1)
 			},
-			wantGroups: nil, // do not update groups
+			wantGroups:       nil, // do not update groups
+			wantUpstreamData: happyPathUpstreamData,
 		},
 		{
 			name: "happy path when the UserAttributeForFilter is set to something other than dn",
@@ -1729,7 +2308,8 @@ func TestUpstreamRefresh(t *testing.T) {
 				}), expectedGroupSearchPageSize).Return(happyPathGroupSearchResult, nil).Times(1)
 				conn.EXPECT().Close().Times(1)
 			},
-			wantGroups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantGroups:       []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantUpstreamData: upstreamData(testUserSearchResultDNValue, map[string][]string{"someUserAttrName": {"someUserAttrValue"}}),
 		},
 		{
 			name: "happy path when the UserAttributeForFilter is set to something other than dn but the group search filter is not set",
@@ -1769,7 +2349,8 @@ func TestUpstreamRefresh(t *testing.T) {
 				}), expectedGroupSearchPageSize).Return(happyPathGroupSearchResult, nil).Times(1)
 				conn.EXPECT().Close().Times(1)
 			},
-			wantGroups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantGroups:       []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantUpstreamData: upstreamData(testUserSearchResultDNValue, map[string][]string{"someUserAttrName": {"someUserAttrValue&(abc)"}}),
 		},
 		{
 			name: "when the UserAttributeForFilter is set to something other than dn but that attribute is not returned by the user search",
@@ -1884,7 +2465,8 @@ func TestUpstreamRefresh(t *testing.T) {
 				conn.EXPECT().SearchWithPaging(expectedGroupSearch(nil), expectedGroupSearchPageSize).Return(happyPathGroupSearchResult, nil).Times(1)
 				conn.EXPECT().Close().Times(1)
 			},
-			wantGroups: []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantGroups:       []string{testGroupSearchResultGroupNameAttributeValue1, testGroupSearchResultGroupNameAttributeValue2},
+			wantUpstreamData: happyPathUpstreamData,
 		},
 		{
 			name:           "error where dial fails",
@@ -2201,7 +2783,7 @@ func TestUpstreamRefresh(t *testing.T) {
 				"ldaps://ldap.example.com:8443?base=some-upstream-user-base-dn&idpName=%s&sub=c29tZS11cHN0cmVhbS11aWQtdmFsdWU",
 				testUpstreamName,
 			)
-			groups, err := ldapProvider.PerformRefresh(context.Background(), upstreamprovider.LDAPRefreshAttributes{
+			groups, additionalClaims, upstreamData, err := ldapProvider.PerformRefresh(context.Background(), upstreamprovider.LDAPRefreshAttributes{
 				Username:             testUserSearchResultUsernameAttributeValue,
 				Subject:              subject,
 				DN:                   tt.refreshUserDN,
@@ -2215,6 +2797,8 @@ func TestUpstreamRefresh(t *testing.T) {
 			}
 			require.Equal(t, true, dialWasAttempted)
 			require.Equal(t, tt.wantGroups, groups)
+			require.Equal(t, tt.wantAdditionalClaims, additionalClaims)
+			require.Equal(t, tt.wantUpstreamData, upstreamData)
 		})
 	}
 }
@@ -2315,6 +2899,63 @@ func TestTestConnection(t *testing.T) {
 	}
 }
 
+func TestProbeHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		setupMocks func(conn *mockldapconn.MockConn)
+		dialError  error
+		wantError  testutil.RequireErrorStringFunc
+	}{
+		{
+			name: "happy path dials and closes without binding",
+			setupMocks: func(conn *mockldapconn.MockConn) {
+				conn.EXPECT().Close().Times(1)
+			},
+		},
+		{
+			name:      "when dial fails",
+			dialError: errors.New("some dial error"),
+			wantError: testutil.WantSprintfErrorString(`error dialing host "%s": some dial error`, testHost),
+		},
+	}
+
+	for _, test := range tests {
+		tt := test
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			t.Cleanup(ctrl.Finish)
+
+			conn := mockldapconn.NewMockConn(ctrl)
+			if tt.setupMocks != nil {
+				tt.setupMocks(conn)
+			}
+
+			provider := New(ProviderConfig{
+				Name:               "some-provider-name",
+				Host:               testHost,
+				ConnectionProtocol: TLS,
+				BindUsername:       testBindUsername,
+				BindPassword:       testBindPassword,
+				Dialer: LDAPDialerFunc(func(ctx context.Context, addr endpointaddr.HostPort) (Conn, error) {
+					require.Equal(t, testHost, addr.Endpoint())
+					if tt.dialError != nil {
+						return nil, tt.dialError
+					}
+					return conn, nil
+				}),
+			})
+			err := provider.ProbeHealth(context.Background())
+
+			switch {
+			case tt.wantError != nil:
+				testutil.RequireErrorStringFromErr(t, err, tt.wantError)
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestGetConfig(t *testing.T) {
 	c := ProviderConfig{
 		Name:         "original-provider-name",
@@ -2490,7 +3131,7 @@ func TestRealTLSDialing(t *testing.T) {
 				ConnectionProtocol: tt.connProto,
 				Dialer:             nil, // this test is for the default (production) TLS dialer
 			})
-			conn, err := provider.dial(tt.context)
+			conn, err := provider.dialHost(tt.context, tt.host)
 			if conn != nil {
 				defer conn.Close()
 			}
@@ -0,0 +1,110 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamlocaluser
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// LoginAttemptTracker remembers failed login attempts and used TOTP codes for the users of one
+// LocalUserIdentityProvider. It is safe for concurrent use. The same tracker should be used by every
+// ProviderConfig which is created for the same LocalUserIdentityProvider, so that failed login attempts are not
+// forgotten when the users are reloaded. The tracker only lives in the memory of this pod of the Supervisor,
+// so each pod counts failed login attempts separately, as documented on the LocalUserLockout API type.
+type LoginAttemptTracker struct {
+	clock clock.PassiveClock
+
+	lock  sync.Mutex
+	users map[string]*userAttempts
+}
+
+type userAttempts struct {
+	// consecutiveFailures is the number of failed login attempts since the last successful login or lockout.
+	consecutiveFailures int32
+
+	// lockedOutUntil is the zero time when the user is not locked out.
+	lockedOutUntil time.Time
+
+	// lastUsedTOTPStep is the time step of the TOTP code which was used in the last successful login,
+	// to prevent the same code from being used twice.
+	lastUsedTOTPStep int64
+}
+
+// NewLoginAttemptTracker creates a LoginAttemptTracker.
+func NewLoginAttemptTracker(clock clock.PassiveClock) *LoginAttemptTracker {
+	return &LoginAttemptTracker{clock: clock, users: map[string]*userAttempts{}}
+}
+
+func (t *LoginAttemptTracker) now() time.Time {
+	return t.clock.Now()
+}
+
+// isLockedOut returns true when the user is currently locked out.
+func (t *LoginAttemptTracker) isLockedOut(username string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	attempts, ok := t.users[username]
+	return ok && t.now().Before(attempts.lockedOutUntil)
+}
+
+// recordFailure counts a failed login attempt. It locks out the user and returns true when the user has reached
+// maxFailedAttempts consecutive failures. When maxFailedAttempts is zero, users are never locked out.
+func (t *LoginAttemptTracker) recordFailure(username string, maxFailedAttempts int32, lockoutDuration time.Duration) bool {
+	if maxFailedAttempts == 0 {
+		return false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	attempts := t.getOrCreate(username)
+	attempts.consecutiveFailures++
+	if attempts.consecutiveFailures < maxFailedAttempts {
+		return false
+	}
+	attempts.consecutiveFailures = 0
+	attempts.lockedOutUntil = t.now().Add(lockoutDuration)
+	return true
+}
+
+// recordSuccess resets the count of failed login attempts, and remembers the TOTP time step which was used,
+// if any. It returns false without recording anything when a TOTP code from the given time step, or from a later
+// time step, was already used by the user. The check and the update happen while holding the same lock, so two
+// concurrent logins cannot both use the same code.
+func (t *LoginAttemptTracker) recordSuccess(username string, totpStep *int64) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	attempts, ok := t.users[username]
+	if totpStep == nil {
+		if ok {
+			attempts.consecutiveFailures = 0
+		}
+		return true
+	}
+
+	if ok && *totpStep <= attempts.lastUsedTOTPStep {
+		return false
+	}
+
+	if !ok {
+		attempts = t.getOrCreate(username)
+	}
+	attempts.consecutiveFailures = 0
+	attempts.lastUsedTOTPStep = *totpStep
+	return true
+}
+
+func (t *LoginAttemptTracker) getOrCreate(username string) *userAttempts {
+	attempts, ok := t.users[username]
+	if !ok {
+		attempts = &userAttempts{}
+		t.users[username] = attempts
+	}
+	return attempts
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamlocaluser
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Limits on the argon2id parameters of a password hash, to avoid allowing a hash to cause the Supervisor to use
+// an unreasonable amount of memory or CPU time for each login attempt.
+const (
+	maxArgon2MemoryKiB   = 64 * 1024
+	maxArgon2Iterations  = 16
+	maxArgon2Parallelism = 16
+	maxArgon2KeyLength   = 128
+
+	// maxConcurrentArgon2Checks limits how many argon2id password checks may run at the same time, so that
+	// many parallel login attempts, including attempts for unknown users which are checked against a dummy hash,
+	// cannot make the Supervisor allocate more than maxConcurrentArgon2Checks * maxArgon2MemoryKiB at once.
+	maxConcurrentArgon2Checks = 4
+)
+
+// argon2Semaphore holds a token for each argon2id password check which is currently running.
+var argon2Semaphore = make(chan struct{}, maxConcurrentArgon2Checks)
+
+// PasswordHash is a parsed password hash.
+type PasswordHash interface {
+	// Matches returns true when the given password matches the hash.
+	Matches(password string) bool
+
+	// dummy returns a hash with the same algorithm and cost parameters, which no password matches in practice.
+	// Checking a password against the dummy takes as long as checking it against this hash. Dummies are
+	// deterministic, so hashes with the same parameters have equal dummies.
+	dummy() PasswordHash
+}
+
+// ParsePasswordHash parses either a bcrypt hash, e.g. "$2y$10$...", or an argon2id hash in the PHC string format,
+// e.g. "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>".
+func ParsePasswordHash(hash string) (PasswordHash, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return parseArgon2idHash(hash)
+	case strings.HasPrefix(hash, "$2"):
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+		return bcryptHash(hash), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash format: must be a bcrypt hash or an argon2id hash in the PHC string format")
+	}
+}
+
+type bcryptHash []byte
+
+func (h bcryptHash) Matches(password string) bool {
+	return bcrypt.CompareHashAndPassword(h, []byte(password)) == nil
+}
+
+func (h bcryptHash) dummy() PasswordHash {
+	cost, err := bcrypt.Cost(h)
+	if err != nil {
+		cost = bcrypt.DefaultCost // this should never happen, since the hash was already parsed
+	}
+	return dummyBcryptHash(cost)
+}
+
+// dummyBcryptHash returns a bcrypt hash with the given cost, an all zeros salt, and an all zeros hash value.
+func dummyBcryptHash(cost int) bcryptHash {
+	return bcryptHash(fmt.Sprintf("$2a$%02d$%s", cost, strings.Repeat(".", 53)))
+}
+
+type argon2idHash struct {
+	memoryKiB   uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h *argon2idHash) Matches(password string) bool {
+	argon2Semaphore <- struct{}{}
+	defer func() { <-argon2Semaphore }()
+
+	key := argon2.IDKey([]byte(password), h.salt, h.iterations, h.memoryKiB, h.parallelism, uint32(len(h.key))) //nolint:gosec // the key length was already checked to be at most maxArgon2KeyLength
+	return subtle.ConstantTimeCompare(key, h.key) == 1
+}
+
+func (h *argon2idHash) dummy() PasswordHash {
+	return &argon2idHash{
+		memoryKiB:   h.memoryKiB,
+		iterations:  h.iterations,
+		parallelism: h.parallelism,
+		salt:        make([]byte, len(h.salt)),
+		key:         make([]byte, len(h.key)),
+	}
+}
+
+func parseArgon2idHash(hash string) (*argon2idHash, error) {
+	// The format is "$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>".
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("invalid argon2id hash: wrong number of sections")
+	}
+
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return nil, fmt.Errorf("invalid argon2id hash: unsupported version %q", parts[2])
+	}
+
+	h := &argon2idHash{}
+	seen := map[string]bool{}
+	for _, param := range strings.Split(parts[3], ",") {
+		name, value, _ := strings.Cut(param, "=")
+		if seen[name] {
+			return nil, fmt.Errorf("invalid argon2id hash: duplicate parameter %q", name)
+		}
+		seen[name] = true
+		var err error
+		switch name {
+		case "m":
+			h.memoryKiB, err = parseUint32(value, maxArgon2MemoryKiB)
+		case "t":
+			h.iterations, err = parseUint32(value, maxArgon2Iterations)
+		case "p":
+			var p uint32
+			p, err = parseUint32(value, maxArgon2Parallelism)
+			h.parallelism = uint8(p) //nolint:gosec // p was already checked to be at most maxArgon2Parallelism
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid argon2id hash: parameter %q: %w", name, err)
+		}
+	}
+	if len(seen) != 3 {
+		return nil, fmt.Errorf("invalid argon2id hash: parameters m, t, and p are required")
+	}
+
+	var err error
+	h.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(h.salt) == 0 {
+		return nil, fmt.Errorf("invalid argon2id hash: invalid salt")
+	}
+	h.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(h.key) == 0 || len(h.key) > maxArgon2KeyLength {
+		return nil, fmt.Errorf("invalid argon2id hash: invalid hash value")
+	}
+
+	return h, nil
+}
+
+func parseUint32(value string, maxValue uint32) (uint32, error) {
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("not a number")
+	}
+	if parsed < 1 || parsed > uint64(maxValue) {
+		return 0, fmt.Errorf("must be between 1 and %d", maxValue)
+	}
+	return uint32(parsed), nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package upstreamlocaluser implements a directory of users which is stored in Secrets, for clusters which do not
+// have an external identity provider.
+package upstreamlocaluser
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/apimachinery/pkg/types"
+
+	"go.pinniped.dev/internal/federationdomain/downstreamsubject"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/plog"
+)
+
+// User is a user which is defined by a Secret.
+type User struct {
+	Username string
+	Groups   []string
+
+	// UID is the UID of the Secret which defines the user. It changes when the user is deleted and re-created.
+	UID types.UID
+
+	PasswordHash PasswordHash
+
+	// TOTPSecret is nil when the user does not use TOTP.
+	TOTPSecret []byte
+}
+
+// ProviderConfig holds the active configuration of a local user provider.
+type ProviderConfig struct {
+	Name        string
+	ResourceUID types.UID
+
+	// Users are the users of this provider, by username.
+	Users map[string]*User
+
+	// MaxFailedAttempts is the number of consecutive failed login attempts after which a user is locked out.
+	// Zero means that users are never locked out.
+	MaxFailedAttempts int32
+
+	// LockoutDuration is how long a user is locked out.
+	LockoutDuration time.Duration
+
+	// LoginAttempts remembers failed login attempts and used TOTP codes.
+	LoginAttempts *LoginAttemptTracker
+}
+
+type Provider struct {
+	c         ProviderConfig
+	dummyHash PasswordHash
+}
+
+var _ upstreamprovider.UpstreamLocalUserIdentityProviderI = &Provider{}
+
+// New creates a Provider. The config is not a pointer to ensure that a copy of the config is created,
+// making the resulting Provider use an effectively read-only configuration.
+func New(config ProviderConfig) *Provider {
+	return &Provider{c: config, dummyHash: dummyPasswordHash(config.Users)}
+}
+
+func (p *Provider) GetResourceName() string {
+	return p.c.Name
+}
+
+func (p *Provider) GetResourceUID() types.UID {
+	return p.c.ResourceUID
+}
+
+// dummyPasswordHash returns the hash which is compared with the password when the username is not found, so that
+// the response time does not reveal which usernames exist. It uses the algorithm and cost parameters that most of the
+// users' password hashes use, so that it takes about as long as checking the password of a typical user.
+func dummyPasswordHash(users map[string]*User) PasswordHash {
+	counts := map[string]int{}
+	dummies := map[string]PasswordHash{}
+	for _, user := range users {
+		if user.PasswordHash == nil {
+			continue
+		}
+		d := user.PasswordHash.dummy()
+		key := fmt.Sprintf("%v", d)
+		counts[key]++
+		dummies[key] = d
+	}
+
+	var best string
+	for _, key := range slices.Sorted(maps.Keys(counts)) {
+		if counts[key] > counts[best] {
+			best = key
+		}
+	}
+	if best == "" {
+		return dummyBcryptHash(bcrypt.DefaultCost)
+	}
+	return dummies[best]
+}
+
+func (p *Provider) AuthenticateUser(_ context.Context, username, password string, idpDisplayName string) (*upstreamprovider.LocalUser, bool, error) {
+	user, ok := p.c.Users[username]
+	if !ok {
+		_ = p.dummyHash.Matches(password)
+		return nil, false, nil
+	}
+
+	// When the user has a TOTP secret, the code is typed immediately after the password.
+	passwordWithoutCode := password
+	var code string
+	if user.TOTPSecret != nil {
+		if len(password) < totpDigits {
+			passwordWithoutCode, code = "", ""
+		} else {
+			passwordWithoutCode, code = password[:len(password)-totpDigits], password[len(password)-totpDigits:]
+		}
+	}
+
+	// Always check the password, even for locked out users, so that the response time does not reveal
+	// which users are locked out.
+	passwordMatches := user.PasswordHash.Matches(passwordWithoutCode)
+
+	if p.c.LoginAttempts.isLockedOut(username) {
+		plog.Info("login attempt rejected for locked out local user",
+			"identityProviderResourceName", p.c.Name, "username", username)
+		return nil, false, nil
+	}
+
+	var usedTOTPStep *int64
+	codeMatches := true
+	if user.TOTPSecret != nil {
+		step, valid := totpStepForCode(user.TOTPSecret, code, p.c.LoginAttempts.now())
+		codeMatches = valid
+		usedTOTPStep = &step
+	}
+
+	// Only record the success when the password and code match. Recording the success also fails when the
+	// code was already used, e.g. by a concurrent login.
+	if !passwordMatches || !codeMatches || !p.c.LoginAttempts.recordSuccess(username, usedTOTPStep) {
+		if p.c.LoginAttempts.recordFailure(username, p.c.MaxFailedAttempts, p.c.LockoutDuration) {
+			plog.Info("local user locked out due to too many failed login attempts",
+				"identityProviderResourceName", p.c.Name, "username", username,
+				"lockoutDuration", p.c.LockoutDuration.String())
+		}
+		return nil, false, nil
+	}
+
+	// See https://datatracker.ietf.org/doc/html/rfc8176#section-2 for these values.
+	amr := []string{"pwd"}
+	if user.TOTPSecret != nil {
+		amr = append(amr, "otp", "mfa")
+	}
+
+	return &upstreamprovider.LocalUser{
+		Username:          user.Username,
+		Groups:            slices.Clone(user.Groups),
+		UserUID:           string(user.UID),
+		DownstreamSubject: downstreamsubject.LocalUser(p.c.Name, user.Username, idpDisplayName),
+		AMR:               amr,
+	}, true, nil
+}
+
+func (p *Provider) PerformRefresh(_ context.Context, username string, userUID string) ([]string, error) {
+	user, ok := p.c.Users[username]
+	if !ok {
+		return nil, fmt.Errorf("user %q no longer exists", username)
+	}
+	if string(user.UID) != userUID {
+		return nil, fmt.Errorf("user %q has been deleted and re-created since the session was started", username)
+	}
+	return slices.Clone(user.Groups), nil
+}
+
+// GetConfig returns the config. This is not part of the UpstreamLocalUserIdentityProviderI interface and is just for testing.
+func (p *Provider) GetConfig() ProviderConfig {
+	return p.c
+}
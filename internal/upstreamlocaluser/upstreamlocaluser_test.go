@@ -0,0 +1,453 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamlocaluser
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/testutil/totp"
+)
+
+const (
+	testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+)
+
+func bcryptTestHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func argon2idTestHash(password string) string {
+	salt := []byte("some-salt-value!")
+	key := argon2.IDKey([]byte(password), salt, 1, 64, 1, 32)
+	return fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=1$%s$%s",
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestParsePasswordHash(t *testing.T) {
+	validSalt := base64.RawStdEncoding.EncodeToString([]byte("some-salt-value!"))
+	validKey := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr string
+	}{
+		{
+			name: "bcrypt",
+			hash: bcryptTestHash(t, "pass"),
+		},
+		{
+			name: "bcrypt with $2y$ prefix",
+			hash: "$2y$04$" + bcryptTestHash(t, "pass")[7:],
+		},
+		{
+			name: "argon2id",
+			hash: argon2idTestHash("pass"),
+		},
+		{
+			name:    "plain text",
+			hash:    "pass",
+			wantErr: "unsupported hash format: must be a bcrypt hash or an argon2id hash in the PHC string format",
+		},
+		{
+			name:    "argon2i is not supported",
+			hash:    "$argon2i$v=19$m=64,t=1,p=1$" + validSalt + "$" + validKey,
+			wantErr: "unsupported hash format: must be a bcrypt hash or an argon2id hash in the PHC string format",
+		},
+		{
+			name:    "invalid bcrypt",
+			hash:    "$2y$04$tooshort",
+			wantErr: "invalid bcrypt hash: crypto/bcrypt: hashedSecret too short to be a bcrypted password",
+		},
+		{
+			name:    "argon2id with wrong number of sections",
+			hash:    "$argon2id$v=19$m=64,t=1,p=1$" + validSalt,
+			wantErr: "invalid argon2id hash: wrong number of sections",
+		},
+		{
+			name:    "argon2id with wrong version",
+			hash:    "$argon2id$v=16$m=64,t=1,p=1$" + validSalt + "$" + validKey,
+			wantErr: `invalid argon2id hash: unsupported version "v=16"`,
+		},
+		{
+			name:    "argon2id with missing parameter",
+			hash:    "$argon2id$v=19$m=64,t=1$" + validSalt + "$" + validKey,
+			wantErr: "invalid argon2id hash: parameters m, t, and p are required",
+		},
+		{
+			name:    "argon2id with duplicate parameter",
+			hash:    "$argon2id$v=19$m=64,t=1,t=1$" + validSalt + "$" + validKey,
+			wantErr: `invalid argon2id hash: duplicate parameter "t"`,
+		},
+		{
+			name:    "argon2id with unknown parameter",
+			hash:    "$argon2id$v=19$m=64,t=1,x=1$" + validSalt + "$" + validKey,
+			wantErr: `invalid argon2id hash: parameter "x": unknown parameter`,
+		},
+		{
+			name:    "argon2id with non-numeric parameter",
+			hash:    "$argon2id$v=19$m=lots,t=1,p=1$" + validSalt + "$" + validKey,
+			wantErr: `invalid argon2id hash: parameter "m": not a number`,
+		},
+		{
+			name:    "argon2id with too much memory",
+			hash:    "$argon2id$v=19$m=65537,t=1,p=1$" + validSalt + "$" + validKey,
+			wantErr: `invalid argon2id hash: parameter "m": must be between 1 and 65536`,
+		},
+		{
+			name:    "argon2id with zero iterations",
+			hash:    "$argon2id$v=19$m=64,t=0,p=1$" + validSalt + "$" + validKey,
+			wantErr: `invalid argon2id hash: parameter "t": must be between 1 and 16`,
+		},
+		{
+			name:    "argon2id with too much parallelism",
+			hash:    "$argon2id$v=19$m=64,t=1,p=17$" + validSalt + "$" + validKey,
+			wantErr: `invalid argon2id hash: parameter "p": must be between 1 and 16`,
+		},
+		{
+			name:    "argon2id with invalid salt",
+			hash:    "$argon2id$v=19$m=64,t=1,p=1$!!!$" + validKey,
+			wantErr: "invalid argon2id hash: invalid salt",
+		},
+		{
+			name:    "argon2id with empty hash value",
+			hash:    "$argon2id$v=19$m=64,t=1,p=1$" + validSalt + "$",
+			wantErr: "invalid argon2id hash: invalid hash value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := ParsePasswordHash(tt.hash)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, hash)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, hash.Matches("pass"))
+			require.False(t, hash.Matches("wrong"))
+			require.False(t, hash.Matches(""))
+		})
+	}
+}
+
+func TestConcurrentArgon2ChecksAreLimited(t *testing.T) {
+	hash, err := ParsePasswordHash(argon2idTestHash("pass"))
+	require.NoError(t, err)
+
+	// Occupy every slot, as if the maximum number of checks were already running.
+	for range maxConcurrentArgon2Checks {
+		argon2Semaphore <- struct{}{}
+	}
+
+	matched := make(chan bool)
+	go func() {
+		matched <- hash.Matches("pass")
+	}()
+
+	select {
+	case <-matched:
+		t.Fatal("argon2id check should have waited for a free slot")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Freeing one slot lets the waiting check run.
+	<-argon2Semaphore
+	require.True(t, <-matched)
+
+	for range maxConcurrentArgon2Checks - 1 {
+		<-argon2Semaphore
+	}
+}
+
+func TestParseTOTPSecret(t *testing.T) {
+	secret, err := ParseTOTPSecret(testTOTPSecret)
+	require.NoError(t, err)
+	require.Len(t, secret, 20)
+
+	secretWithSpacesAndPadding, err := ParseTOTPSecret("jbsw y3dp ehpk 3pxp jbsw y3dp ehpk 3pxp====")
+	require.NoError(t, err)
+	require.Equal(t, secret, secretWithSpacesAndPadding)
+
+	_, err = ParseTOTPSecret("not base32!")
+	require.EqualError(t, err, "invalid TOTP secret: must be base32-encoded")
+
+	_, err = ParseTOTPSecret("JBSWY3DPEHPK3PXP")
+	require.EqualError(t, err, "invalid TOTP secret: must be at least 16 bytes (26 base32 characters)")
+}
+
+func TestTOTPCode(t *testing.T) {
+	secret, err := ParseTOTPSecret(testTOTPSecret)
+	require.NoError(t, err)
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	want, _ := totp.GenerateOTPCode(t, testTOTPSecret, now)
+	step, ok := totpStepForCode(secret, want, now)
+	require.True(t, ok)
+	require.Equal(t, now.Unix()/30, step)
+
+	previous, _ := totp.GenerateOTPCode(t, testTOTPSecret, now.Add(-30*time.Second))
+	step, ok = totpStepForCode(secret, previous, now)
+	require.True(t, ok)
+	require.Equal(t, now.Unix()/30-1, step)
+
+	tooOld, _ := totp.GenerateOTPCode(t, testTOTPSecret, now.Add(-90*time.Second))
+	_, ok = totpStepForCode(secret, tooOld, now)
+	require.False(t, ok)
+
+	_, ok = totpStepForCode(secret, "12345", now)
+	require.False(t, ok)
+}
+
+func TestAuthenticateUser(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	idpDisplayName := "some display name"
+
+	totpSecret, err := ParseTOTPSecret(testTOTPSecret)
+	require.NoError(t, err)
+
+	bcryptPassword, err := ParsePasswordHash(bcryptTestHash(t, "bcrypt-password"))
+	require.NoError(t, err)
+	argon2Password, err := ParsePasswordHash(argon2idTestHash("argon2-password"))
+	require.NoError(t, err)
+
+	newProvider := func(maxFailedAttempts int32) (*Provider, *clocktesting.FakeClock) {
+		fakeClock := clocktesting.NewFakeClock(now)
+		return New(ProviderConfig{
+			Name:        "some-idp",
+			ResourceUID: "some-idp-uid",
+			Users: map[string]*User{
+				"pinny": {
+					Username:     "pinny",
+					Groups:       []string{"seals", "admins"},
+					UID:          "pinny-secret-uid",
+					PasswordHash: bcryptPassword,
+				},
+				"otto": {
+					Username:     "otto",
+					UID:          "otto-secret-uid",
+					PasswordHash: argon2Password,
+					TOTPSecret:   totpSecret,
+				},
+			},
+			MaxFailedAttempts: maxFailedAttempts,
+			LockoutDuration:   10 * time.Minute,
+			LoginAttempts:     NewLoginAttemptTracker(fakeClock),
+		}), fakeClock
+	}
+
+	requireAuthenticated := func(t *testing.T, p *Provider, username, password string) *upstreamprovider.LocalUser {
+		t.Helper()
+		user, authenticated, err := p.AuthenticateUser(context.Background(), username, password, idpDisplayName)
+		require.NoError(t, err)
+		require.True(t, authenticated)
+		require.NotNil(t, user)
+		return user
+	}
+
+	requireNotAuthenticated := func(t *testing.T, p *Provider, username, password string) {
+		t.Helper()
+		user, authenticated, err := p.AuthenticateUser(context.Background(), username, password, idpDisplayName)
+		require.NoError(t, err)
+		require.False(t, authenticated)
+		require.Nil(t, user)
+	}
+
+	t.Run("successful login with bcrypt password", func(t *testing.T) {
+		p, _ := newProvider(5)
+		require.Equal(t, &upstreamprovider.LocalUser{
+			Username:          "pinny",
+			Groups:            []string{"seals", "admins"},
+			UserUID:           "pinny-secret-uid",
+			DownstreamSubject: "localuser://some-idp?idpName=some+display+name&sub=pinny",
+			AMR:               []string{"pwd"},
+		}, requireAuthenticated(t, p, "pinny", "bcrypt-password"))
+	})
+
+	t.Run("wrong password and unknown user", func(t *testing.T) {
+		p, _ := newProvider(5)
+		requireNotAuthenticated(t, p, "pinny", "wrong")
+		requireNotAuthenticated(t, p, "pinny", "")
+		requireNotAuthenticated(t, p, "unknown", "bcrypt-password")
+		requireNotAuthenticated(t, p, "PINNY", "bcrypt-password")
+	})
+
+	t.Run("successful login with argon2id password and TOTP code", func(t *testing.T) {
+		p, _ := newProvider(5)
+		code, _ := totp.GenerateOTPCode(t, testTOTPSecret, now)
+		require.Equal(t, &upstreamprovider.LocalUser{
+			Username:          "otto",
+			UserUID:           "otto-secret-uid",
+			DownstreamSubject: "localuser://some-idp?idpName=some+display+name&sub=otto",
+			AMR:               []string{"pwd", "otp", "mfa"},
+		}, requireAuthenticated(t, p, "otto", "argon2-password"+code))
+	})
+
+	t.Run("TOTP code is required when configured", func(t *testing.T) {
+		p, _ := newProvider(5)
+		code, _ := totp.GenerateOTPCode(t, testTOTPSecret, now)
+		requireNotAuthenticated(t, p, "otto", "argon2-password")
+		requireNotAuthenticated(t, p, "otto", "argon2-password000000")
+		requireNotAuthenticated(t, p, "otto", "wrong"+code)
+		requireNotAuthenticated(t, p, "otto", code)
+		requireNotAuthenticated(t, p, "otto", "12345")
+	})
+
+	t.Run("TOTP code cannot be used twice", func(t *testing.T) {
+		p, fakeClock := newProvider(5)
+		code, _ := totp.GenerateOTPCode(t, testTOTPSecret, now)
+		requireAuthenticated(t, p, "otto", "argon2-password"+code)
+		requireNotAuthenticated(t, p, "otto", "argon2-password"+code)
+
+		// A code from an earlier step also cannot be used after a code from a later step was used.
+		fakeClock.Step(30 * time.Second)
+		earlierCode, _ := totp.GenerateOTPCode(t, testTOTPSecret, now.Add(-30*time.Second))
+		requireNotAuthenticated(t, p, "otto", "argon2-password"+earlierCode)
+
+		laterCode, _ := totp.GenerateOTPCode(t, testTOTPSecret, fakeClock.Now())
+		requireAuthenticated(t, p, "otto", "argon2-password"+laterCode)
+	})
+
+	t.Run("TOTP code cannot be used by concurrent logins", func(t *testing.T) {
+		p, _ := newProvider(0)
+		code, _ := totp.GenerateOTPCode(t, testTOTPSecret, now)
+
+		var wg sync.WaitGroup
+		var successes atomic.Int32
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, authenticated, err := p.AuthenticateUser(context.Background(), "otto", "argon2-password"+code, idpDisplayName)
+				assert.NoError(t, err)
+				if authenticated {
+					successes.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, int32(1), successes.Load())
+	})
+
+	t.Run("user is locked out after too many consecutive failed attempts", func(t *testing.T) {
+		p, fakeClock := newProvider(3)
+
+		requireNotAuthenticated(t, p, "pinny", "wrong")
+		requireNotAuthenticated(t, p, "pinny", "wrong")
+		// A successful login resets the count.
+		requireAuthenticated(t, p, "pinny", "bcrypt-password")
+		requireNotAuthenticated(t, p, "pinny", "wrong")
+		requireNotAuthenticated(t, p, "pinny", "wrong")
+		requireNotAuthenticated(t, p, "pinny", "wrong")
+
+		// Now locked out, so even the correct password is not accepted.
+		requireNotAuthenticated(t, p, "pinny", "bcrypt-password")
+		fakeClock.Step(10*time.Minute - time.Second)
+		requireNotAuthenticated(t, p, "pinny", "bcrypt-password")
+
+		// Other users are not affected.
+		code, _ := totp.GenerateOTPCode(t, testTOTPSecret, fakeClock.Now())
+		requireAuthenticated(t, p, "otto", "argon2-password"+code)
+
+		// The lockout ends after the lockout duration.
+		fakeClock.Step(time.Second)
+		requireAuthenticated(t, p, "pinny", "bcrypt-password")
+	})
+
+	t.Run("lockout survives reloading the provider when the tracker is reused", func(t *testing.T) {
+		p, _ := newProvider(1)
+		requireNotAuthenticated(t, p, "pinny", "wrong")
+
+		reloaded := New(p.c)
+		requireNotAuthenticated(t, reloaded, "pinny", "bcrypt-password")
+	})
+
+	t.Run("users are never locked out when max failed attempts is zero", func(t *testing.T) {
+		p, _ := newProvider(0)
+		for range 10 {
+			requireNotAuthenticated(t, p, "pinny", "wrong")
+		}
+		requireAuthenticated(t, p, "pinny", "bcrypt-password")
+	})
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	bcryptPassword, err := ParsePasswordHash(bcryptTestHash(t, "bcrypt-password"))
+	require.NoError(t, err)
+	argon2Password, err := ParsePasswordHash(argon2idTestHash("argon2-password"))
+	require.NoError(t, err)
+
+	t.Run("without users, uses bcrypt with the default cost", func(t *testing.T) {
+		dummy := dummyPasswordHash(nil)
+		cost, err := bcrypt.Cost(dummy.(bcryptHash))
+		require.NoError(t, err)
+		require.Equal(t, bcrypt.DefaultCost, cost)
+		require.False(t, dummy.Matches(""))
+	})
+
+	t.Run("uses the bcrypt cost of most users", func(t *testing.T) {
+		dummy := dummyPasswordHash(map[string]*User{
+			"a": {PasswordHash: bcryptPassword},
+			"b": {PasswordHash: bcryptPassword},
+			"c": {PasswordHash: argon2Password},
+		})
+		cost, err := bcrypt.Cost(dummy.(bcryptHash))
+		require.NoError(t, err)
+		require.Equal(t, bcrypt.MinCost, cost)
+		require.False(t, dummy.Matches("bcrypt-password"))
+		require.False(t, dummy.Matches(""))
+	})
+
+	t.Run("uses the argon2id parameters of most users", func(t *testing.T) {
+		dummy := dummyPasswordHash(map[string]*User{
+			"a": {PasswordHash: bcryptPassword},
+			"b": {PasswordHash: argon2Password},
+			"c": {PasswordHash: argon2Password},
+		})
+		require.Equal(t, &argon2idHash{
+			memoryKiB:   64,
+			iterations:  1,
+			parallelism: 1,
+			salt:        make([]byte, 16),
+			key:         make([]byte, 32),
+		}, dummy)
+		require.False(t, dummy.Matches("argon2-password"))
+		require.False(t, dummy.Matches(""))
+	})
+}
+
+func TestPerformRefresh(t *testing.T) {
+	p := New(ProviderConfig{
+		Users: map[string]*User{
+			"pinny": {Username: "pinny", Groups: []string{"seals"}, UID: "pinny-secret-uid"},
+		},
+	})
+
+	groups, err := p.PerformRefresh(context.Background(), "pinny", "pinny-secret-uid")
+	require.NoError(t, err)
+	require.Equal(t, []string{"seals"}, groups)
+
+	_, err = p.PerformRefresh(context.Background(), "pinny", "old-pinny-secret-uid")
+	require.EqualError(t, err, `user "pinny" has been deleted and re-created since the session was started`)
+
+	_, err = p.PerformRefresh(context.Background(), "otto", "otto-secret-uid")
+	require.EqualError(t, err, `user "otto" no longer exists`)
+}
@@ -0,0 +1,378 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package upstreamoauth2 implements an abstraction of upstream generic OAuth2 provider interactions,
+// for OAuth2 authorization servers which do not support OIDC.
+package upstreamoauth2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/types"
+
+	"go.pinniped.dev/internal/celtransformer"
+	"go.pinniped.dev/internal/federationdomain/downstreamsubject"
+	"go.pinniped.dev/internal/federationdomain/dynamicupstreamprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/plog"
+	"go.pinniped.dev/pkg/oidcclient/pkce"
+)
+
+// The largest response body which will be read from the userinfo and groups URLs.
+const maxResponseBytes = 10 * 1024 * 1024
+
+// ProviderConfig holds the active configuration of an upstream OAuth2 provider.
+type ProviderConfig struct {
+	Name        string
+	ResourceUID types.UID
+
+	// UserInfoURL is the URL of the API which returns the authenticated user.
+	UserInfoURL *url.URL
+
+	// GroupsURL is the URL of the API which returns the groups of the authenticated user.
+	// It is nil when no groups URL was configured.
+	GroupsURL *url.URL
+
+	// RevocationURL is the URL of the RFC7009 token revocation endpoint.
+	// It is empty when no revocation URL was configured.
+	RevocationURL string
+
+	// AdditionalAuthcodeParams are extra params to send to the authorization endpoint.
+	AdditionalAuthcodeParams map[string]string
+
+	// Claims holds the compiled expressions which determine the identity of the user.
+	Claims *CompiledClaims
+
+	// HttpClient is a client that can be used to call the token endpoint, userinfo URL, groups URL, and revocation URL.
+	// This client should be configured with the user-provided CA bundle and a timeout.
+	HttpClient *http.Client
+
+	// OAuth2Config contains ClientID, ClientSecret, Scopes, and Endpoint (which contains auth and token endpoint URLs,
+	// and auth style for the token endpoint).
+	// OAuth2Config will not be used to compute the authorize URL because the redirect back to the Supervisor's
+	// callback must be different per FederationDomain. It holds data that may be useful when calculating the
+	// authorize URL, so that data is exposed by interface methods. However, it can be used to call the token endpoint,
+	// for which there is no RedirectURL needed.
+	OAuth2Config *oauth2.Config
+}
+
+// CompiledClaims holds the compiled CEL expressions which extract the identity of a user from the responses
+// of the userinfo and groups URLs.
+type CompiledClaims struct {
+	Username *celtransformer.CompiledStringExpression
+	Subject  *celtransformer.CompiledStringExpression
+	// Groups is nil when no groups expression was configured.
+	Groups *celtransformer.CompiledStringListExpression
+}
+
+type Provider struct {
+	c ProviderConfig
+}
+
+var _ upstreamprovider.UpstreamOAuth2IdentityProviderI = &Provider{}
+var _ upstreamprovider.UpstreamHealthProberI = &Provider{}
+
+// New creates a Provider. The config is not a pointer to ensure that a copy of the config is created,
+// making the resulting Provider use an effectively read-only configuration.
+func New(config ProviderConfig) *Provider {
+	return &Provider{c: config}
+}
+
+func (p *Provider) GetResourceName() string {
+	return p.c.Name
+}
+
+func (p *Provider) GetResourceUID() types.UID {
+	return p.c.ResourceUID
+}
+
+func (p *Provider) GetClientID() string {
+	return p.c.OAuth2Config.ClientID
+}
+
+func (p *Provider) GetScopes() []string {
+	return p.c.OAuth2Config.Scopes
+}
+
+func (p *Provider) GetAuthorizationURL() string {
+	return p.c.OAuth2Config.Endpoint.AuthURL
+}
+
+func (p *Provider) GetAdditionalAuthcodeParams() map[string]string {
+	return p.c.AdditionalAuthcodeParams
+}
+
+func (p *Provider) ExchangeAuthcode(ctx context.Context, authcode string, pkceCodeVerifier pkce.Code, redirectURI string) (*oauth2.Token, error) {
+	tok, err := p.c.OAuth2Config.Exchange(
+		coreosoidc.ClientContext(ctx, p.c.HttpClient),
+		authcode,
+		pkceCodeVerifier.Verifier(),
+		oauth2.SetAuthURLParam("redirect_uri", redirectURI),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging authorization code: %w", err)
+	}
+	return tok, nil
+}
+
+func (p *Provider) PerformRefresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	// Use the provided HTTP client to benefit from its CA, proxy, and other settings.
+	httpClientContext := coreosoidc.ClientContext(ctx, p.c.HttpClient)
+	// Create a TokenSource without an access token, so it thinks that a refresh is immediately required.
+	// Then ask it for the tokens to cause it to perform the refresh and return the results.
+	return p.c.OAuth2Config.TokenSource(httpClientContext, &oauth2.Token{RefreshToken: refreshToken}).Token()
+}
+
+// RevokeToken revokes the token using the revocation URL, as described by RFC7009. It does nothing when no
+// revocation URL is configured, since many OAuth2 authorization servers do not offer token revocation.
+func (p *Provider) RevokeToken(ctx context.Context, token string, tokenType upstreamprovider.RevocableTokenType) error {
+	if p.c.RevocationURL == "" {
+		plog.Trace("RevokeToken() was called but upstream provider has no available revocation URL",
+			"providerName", p.c.Name,
+			"tokenType", tokenType,
+		)
+		return nil
+	}
+	// Like the token endpoint requests, first try sending the client credentials in the Authorization header.
+	tryAnotherClientAuthMethod, err := p.tryRevokeToken(ctx, token, tokenType, true)
+	if tryAnotherClientAuthMethod {
+		// Try again using the request params this time. Overwrite the first client auth error,
+		// which isn't useful anymore when retrying.
+		_, err = p.tryRevokeToken(ctx, token, tokenType, false)
+	}
+	return err
+}
+
+// tryRevokeToken calls the revocation URL using either basic auth or by including client auth in the request params.
+// It returns true for tryAnotherClientAuthMethod when the request failed for a reason that might be due to the client
+// auth method. See https://datatracker.ietf.org/doc/html/rfc7009#section-2.1 for details.
+func (p *Provider) tryRevokeToken(
+	ctx context.Context,
+	token string,
+	tokenType upstreamprovider.RevocableTokenType,
+	useBasicAuth bool,
+) (tryAnotherClientAuthMethod bool, err error) {
+	params := url.Values{
+		"token":           []string{token},
+		"token_type_hint": []string{string(tokenType)},
+	}
+	if !useBasicAuth {
+		params.Set("client_id", p.c.OAuth2Config.ClientID)
+		params.Set("client_secret", p.c.OAuth2Config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.c.RevocationURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if useBasicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.c.OAuth2Config.ClientID), url.QueryEscape(p.c.OAuth2Config.ClientSecret))
+	}
+
+	resp, err := p.c.HttpClient.Do(req)
+	if err != nil {
+		// Could be a temporary network problem, so it might be worth retrying.
+		return false, dynamicupstreamprovider.NewRetryableRevocationError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch status := resp.StatusCode; {
+	case status == http.StatusOK:
+		plog.Trace("RevokeToken() got 200 OK response from provider's revocation URL", "providerName", p.c.Name, "usedBasicAuth", useBasicAuth)
+		return false, nil
+	case status == http.StatusBadRequest || status == http.StatusUnauthorized:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+		if err != nil {
+			return false, fmt.Errorf("error reading response body on response with status code %d: %w", status, err)
+		}
+		var parsedResp struct {
+			ErrorType string `json:"error"`
+		}
+		bodyStr := strings.TrimSpace(string(body)) // trimmed for logging purposes
+		// An "invalid_client" error might mean that the client auth method is not supported by the server,
+		// so it may be worth trying again using the other client auth method.
+		// See https://datatracker.ietf.org/doc/html/rfc6749#section-5.2.
+		tryAnotherClientAuthMethod = json.Unmarshal(body, &parsedResp) == nil && parsedResp.ErrorType == "invalid_client"
+		return tryAnotherClientAuthMethod, fmt.Errorf("server responded with status %d with body: %s", status, bodyStr)
+	case status >= 500 && status <= 599:
+		// The spec says 503 Service Unavailable should be retried by the client later, and other 5xx errors
+		// might also be resolved in the near future. See https://datatracker.ietf.org/doc/html/rfc7009#section-2.2.1.
+		return false, dynamicupstreamprovider.NewRetryableRevocationError(fmt.Errorf("server responded with status %d", status))
+	default:
+		return false, fmt.Errorf("server responded with status %d", status)
+	}
+}
+
+// ProbeHealth checks that the provider is reachable by making an unauthenticated request to its token endpoint.
+// Token endpoints are expected to reject such requests, so any response which is not a server error means that
+// the provider is reachable. Implements upstreamprovider.UpstreamHealthProberI.
+func (p *Provider) ProbeHealth(ctx context.Context) error {
+	tokenURL := p.c.OAuth2Config.Endpoint.TokenURL
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("unexpected response status %q from %s", resp.Status, tokenURL)
+	}
+	return nil
+}
+
+// GetUser calls the userinfo URL, and the groups URL when it is configured, using the access token. Then it
+// evaluates the configured expressions using the responses to determine the identity of the user.
+func (p *Provider) GetUser(ctx context.Context, accessToken string, idpDisplayName string) (*upstreamprovider.OAuth2User, error) {
+	responses := &celtransformer.UpstreamResponses{}
+
+	var err error
+	responses.UserInfo, err = p.getJSON(ctx, p.c.UserInfoURL, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("error calling userinfo URL: %w", err)
+	}
+
+	if p.c.GroupsURL != nil {
+		responses.GroupsResponse, err = p.getJSON(ctx, p.c.GroupsURL, accessToken)
+		if err != nil {
+			return nil, fmt.Errorf("error calling groups URL: %w", err)
+		}
+	}
+
+	username, err := p.c.Claims.Username.Evaluate(ctx, responses)
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating username expression: %w", err)
+	}
+	if username == "" {
+		return nil, errors.New("username expression returned an empty string")
+	}
+
+	subject, err := p.c.Claims.Subject.Evaluate(ctx, responses)
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating subject expression: %w", err)
+	}
+	if subject == "" {
+		return nil, errors.New("subject expression returned an empty string")
+	}
+
+	var groups []string
+	if p.c.Claims.Groups != nil {
+		groups, err = p.c.Claims.Groups.Evaluate(ctx, responses)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating groups expression: %w", err)
+		}
+	}
+
+	// Use the same names for the responses as the claims expressions use for them.
+	upstreamData := map[string]any{"userInfo": responses.UserInfo}
+	if p.c.GroupsURL != nil {
+		upstreamData["groupsResponse"] = responses.GroupsResponse
+	}
+
+	return &upstreamprovider.OAuth2User{
+		Username:          username,
+		Groups:            groups,
+		Subject:           subject,
+		DownstreamSubject: downstreamsubject.OAuth2(subject, *p.c.UserInfoURL, idpDisplayName),
+		UpstreamData:      upstreamData,
+	}, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, apiURL *url.URL, accessToken string) (any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxResponseBytes {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxResponseBytes)
+	}
+
+	return decodeJSON(body)
+}
+
+// decodeJSON decodes a JSON document into the types which CEL understands. JSON numbers which are integers
+// are decoded as int64, so that large integer IDs can be converted to strings without loss of precision
+// or exponent notation. Other JSON numbers are decoded as float64.
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var result any
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("could not decode response as JSON: %w", err)
+	}
+	if decoder.More() {
+		return nil, errors.New("could not decode response as JSON: unexpected data after JSON document")
+	}
+
+	return convertNumbers(result)
+}
+
+func convertNumbers(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			converted, err := convertNumbers(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = converted
+		}
+		return v, nil
+	case []any:
+		for i, item := range v {
+			converted, err := convertNumbers(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+		}
+		return v.Float64()
+	default:
+		return v, nil
+	}
+}
+
+// GetConfig returns the config. This is not part of the UpstreamOAuth2IdentityProviderI interface and is just for testing.
+func (p *Provider) GetConfig() ProviderConfig {
+	return p.c
+}
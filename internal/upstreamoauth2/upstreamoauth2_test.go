@@ -0,0 +1,681 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoauth2
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/cert"
+
+	"go.pinniped.dev/internal/celtransformer"
+	"go.pinniped.dev/internal/federationdomain/dynamicupstreamprovider"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/testutil/tlsserver"
+)
+
+func TestOAuth2Provider(t *testing.T) {
+	userInfoURL, err := url.Parse("https://fake-userinfo-url")
+	require.NoError(t, err)
+
+	subject := New(ProviderConfig{
+		Name:                     "foo",
+		ResourceUID:              "resource-uid-12345",
+		UserInfoURL:              userInfoURL,
+		AdditionalAuthcodeParams: map[string]string{"prompt": "consent"},
+		OAuth2Config: &oauth2.Config{
+			ClientID:     "fake-client-id",
+			ClientSecret: "fake-client-secret",
+			Scopes:       []string{"scope1", "scope2"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   "https://fake-authorization-url",
+				TokenURL:  "https://fake-token-url",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+		},
+		HttpClient: &http.Client{
+			Timeout: 1234509,
+		},
+	})
+
+	require.Equal(t, ProviderConfig{
+		Name:                     "foo",
+		ResourceUID:              "resource-uid-12345",
+		UserInfoURL:              userInfoURL,
+		AdditionalAuthcodeParams: map[string]string{"prompt": "consent"},
+		OAuth2Config: &oauth2.Config{
+			ClientID:     "fake-client-id",
+			ClientSecret: "fake-client-secret",
+			Scopes:       []string{"scope1", "scope2"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   "https://fake-authorization-url",
+				TokenURL:  "https://fake-token-url",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+		},
+		HttpClient: &http.Client{
+			Timeout: 1234509,
+		},
+	}, subject.GetConfig())
+
+	require.Equal(t, "foo", subject.GetResourceName())
+	require.Equal(t, types.UID("resource-uid-12345"), subject.GetResourceUID())
+	require.Equal(t, "fake-client-id", subject.GetClientID())
+	require.Equal(t, []string{"scope1", "scope2"}, subject.GetScopes())
+	require.Equal(t, "https://fake-authorization-url", subject.GetAuthorizationURL())
+	require.Equal(t, map[string]string{"prompt": "consent"}, subject.GetAdditionalAuthcodeParams())
+}
+
+func TestExchangeAuthcodeAndPerformRefresh(t *testing.T) {
+	tests := []struct {
+		name              string
+		tokenEndpointPath string
+		refresh           bool
+		wantToken         *oauth2.Token
+		wantErr           string
+	}{
+		{
+			name:              "authcode exchange happy path",
+			tokenEndpointPath: "/token",
+			wantToken:         &oauth2.Token{AccessToken: "fake-access-token", RefreshToken: "fake-refresh-token", TokenType: "Bearer"},
+		},
+		{
+			name:              "authcode exchange when the token endpoint returns an error",
+			tokenEndpointPath: "/token-error",
+			wantErr:           `error exchanging authorization code: oauth2: "invalid_grant" "some error"`,
+		},
+		{
+			name:              "refresh happy path",
+			tokenEndpointPath: "/token",
+			refresh:           true,
+			wantToken:         &oauth2.Token{AccessToken: "fake-access-token", RefreshToken: "fake-refresh-token", TokenType: "Bearer"},
+		},
+		{
+			name:              "refresh when the token endpoint returns an error",
+			tokenEndpointPath: "/token-error",
+			refresh:           true,
+			wantErr:           `oauth2: "invalid_grant" "some error"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			testServer, testServerCA := tlsserver.TestServerIPv4(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+
+				err := r.ParseForm()
+				require.NoError(t, err)
+				params := r.PostForm
+				require.Equal(t, "fake-client-id", params.Get("client_id"))
+				require.Equal(t, "fake-client-secret", params.Get("client_secret"))
+				if test.refresh {
+					require.Len(t, params, 4)
+					require.Equal(t, "refresh_token", params.Get("grant_type"))
+					require.Equal(t, "fake-old-refresh-token", params.Get("refresh_token"))
+				} else {
+					require.Len(t, params, 6)
+					require.Equal(t, "authorization_code", params.Get("grant_type"))
+					require.Equal(t, "fake-authcode", params.Get("code"))
+					require.Equal(t, "fake-pkce-verifier", params.Get("code_verifier"))
+					require.Equal(t, "https://fake-redirect-url", params.Get("redirect_uri"))
+				}
+
+				w.Header().Set("content-type", "application/json")
+				switch r.URL.Path {
+				case "/token":
+					w.WriteHeader(http.StatusOK)
+					_, err = w.Write([]byte(`{"access_token":"fake-access-token","refresh_token":"fake-refresh-token","token_type":"Bearer"}`))
+					require.NoError(t, err)
+				case "/token-error":
+					w.WriteHeader(http.StatusBadRequest)
+					_, err = w.Write([]byte(`{"error":"invalid_grant","error_description":"some error"}`))
+					require.NoError(t, err)
+				default:
+					t.Fatalf("tried to call provider at unexpected endpoint: %s", r.URL.Path)
+				}
+			}), nil)
+			testServerPool, err := cert.NewPoolFromBytes(testServerCA)
+			require.NoError(t, err)
+
+			subject := New(ProviderConfig{
+				OAuth2Config: &oauth2.Config{
+					ClientID:     "fake-client-id",
+					ClientSecret: "fake-client-secret",
+					Scopes:       []string{"scope1", "scope2"},
+					Endpoint: oauth2.Endpoint{
+						AuthURL:   "https://fake-auth-url",
+						TokenURL:  testServer.URL + test.tokenEndpointPath,
+						AuthStyle: oauth2.AuthStyleInParams,
+					},
+				},
+				HttpClient: &http.Client{
+					Timeout: 10 * time.Second,
+					Transport: &http.Transport{TLSClientConfig: &tls.Config{
+						MinVersion: tls.VersionTLS12,
+						RootCAs:    testServerPool,
+					}},
+				},
+			})
+
+			var tok *oauth2.Token
+			if test.refresh {
+				tok, err = subject.PerformRefresh(context.Background(), "fake-old-refresh-token")
+			} else {
+				tok, err = subject.ExchangeAuthcode(context.Background(), "fake-authcode", "fake-pkce-verifier", "https://fake-redirect-url")
+			}
+			if test.wantErr != "" {
+				require.ErrorContains(t, err, test.wantErr)
+				require.Nil(t, tok)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.wantToken.AccessToken, tok.AccessToken)
+			require.Equal(t, test.wantToken.RefreshToken, tok.RefreshToken)
+			require.Equal(t, test.wantToken.TokenType, tok.TokenType)
+		})
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	tests := []struct {
+		name              string
+		noRevocationURL   bool
+		tokenType         upstreamprovider.RevocableTokenType
+		basicAuthStatus   int
+		basicAuthBody     string
+		paramsAuthStatus  int
+		paramsAuthBody    string
+		wantBasicAuthOnly bool
+		wantRetryableErr  bool
+		wantErr           string
+	}{
+		{
+			name:              "no revocation URL is configured",
+			noRevocationURL:   true,
+			tokenType:         upstreamprovider.RefreshTokenType,
+			wantBasicAuthOnly: true,
+		},
+		{
+			name:              "refresh token revoked using basic auth",
+			tokenType:         upstreamprovider.RefreshTokenType,
+			basicAuthStatus:   http.StatusOK,
+			wantBasicAuthOnly: true,
+		},
+		{
+			name:              "access token revoked using basic auth",
+			tokenType:         upstreamprovider.AccessTokenType,
+			basicAuthStatus:   http.StatusOK,
+			wantBasicAuthOnly: true,
+		},
+		{
+			name:             "basic auth is rejected as an invalid client, so the client credentials are sent as params",
+			tokenType:        upstreamprovider.RefreshTokenType,
+			basicAuthStatus:  http.StatusUnauthorized,
+			basicAuthBody:    `{"error":"invalid_client"}`,
+			paramsAuthStatus: http.StatusOK,
+		},
+		{
+			name:             "both client auth methods are rejected as an invalid client",
+			tokenType:        upstreamprovider.RefreshTokenType,
+			basicAuthStatus:  http.StatusBadRequest,
+			basicAuthBody:    `{"error":"invalid_client"}`,
+			paramsAuthStatus: http.StatusBadRequest,
+			paramsAuthBody:   `{"error":"invalid_client","error_description":"params auth failed"}`,
+			wantErr:          `server responded with status 400 with body: {"error":"invalid_client","error_description":"params auth failed"}`,
+		},
+		{
+			name:              "an error which is unrelated to client auth is not retried",
+			tokenType:         upstreamprovider.RefreshTokenType,
+			basicAuthStatus:   http.StatusBadRequest,
+			basicAuthBody:     `{"error":"unsupported_token_type"}`,
+			wantBasicAuthOnly: true,
+			wantErr:           `server responded with status 400 with body: {"error":"unsupported_token_type"}`,
+		},
+		{
+			name:              "server error is retryable",
+			tokenType:         upstreamprovider.RefreshTokenType,
+			basicAuthStatus:   http.StatusServiceUnavailable,
+			wantBasicAuthOnly: true,
+			wantRetryableErr:  true,
+			wantErr:           "retryable revocation error: server responded with status 503",
+		},
+		{
+			name:              "other unexpected status",
+			tokenType:         upstreamprovider.RefreshTokenType,
+			basicAuthStatus:   http.StatusNotFound,
+			wantBasicAuthOnly: true,
+			wantErr:           "server responded with status 404",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			numRequests := 0
+			testServer, testServerCA := tlsserver.TestServerIPv4(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				numRequests++
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "/revoke", r.URL.Path)
+				require.NoError(t, r.ParseForm())
+				require.Equal(t, "fake-token", r.PostForm.Get("token"))
+				require.Equal(t, string(test.tokenType), r.PostForm.Get("token_type_hint"))
+
+				username, password, hasBasicAuth := r.BasicAuth()
+				w.Header().Set("content-type", "application/json")
+				if hasBasicAuth {
+					require.Equal(t, "fake-client-id", username)
+					require.Equal(t, "fake-client-secret", password)
+					require.Len(t, r.PostForm, 2)
+					w.WriteHeader(test.basicAuthStatus)
+					_, _ = w.Write([]byte(test.basicAuthBody))
+					return
+				}
+				require.Equal(t, "fake-client-id", r.PostForm.Get("client_id"))
+				require.Equal(t, "fake-client-secret", r.PostForm.Get("client_secret"))
+				require.Len(t, r.PostForm, 4)
+				w.WriteHeader(test.paramsAuthStatus)
+				_, _ = w.Write([]byte(test.paramsAuthBody))
+			}), nil)
+			testServerPool, err := cert.NewPoolFromBytes(testServerCA)
+			require.NoError(t, err)
+
+			revocationURL := testServer.URL + "/revoke"
+			if test.noRevocationURL {
+				revocationURL = ""
+			}
+
+			subject := New(ProviderConfig{
+				RevocationURL: revocationURL,
+				OAuth2Config: &oauth2.Config{
+					ClientID:     "fake-client-id",
+					ClientSecret: "fake-client-secret",
+				},
+				HttpClient: &http.Client{
+					Timeout: 10 * time.Second,
+					Transport: &http.Transport{TLSClientConfig: &tls.Config{
+						MinVersion: tls.VersionTLS12,
+						RootCAs:    testServerPool,
+					}},
+				},
+			})
+
+			err = subject.RevokeToken(context.Background(), "fake-token", test.tokenType)
+
+			switch {
+			case test.noRevocationURL:
+				require.Zero(t, numRequests)
+			case test.wantBasicAuthOnly:
+				require.Equal(t, 1, numRequests)
+			default:
+				require.Equal(t, 2, numRequests)
+			}
+
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+				isRetryableErr := errors.As(err, &dynamicupstreamprovider.RetryableRevocationError{})
+				require.Equal(t, test.wantRetryableErr, isRetryableErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProbeHealth(t *testing.T) {
+	tests := []struct {
+		name              string
+		returnStatusCode  int
+		unreachableServer bool
+		wantErr           string
+	}{
+		{
+			name:             "success when the token endpoint returns 200 OK",
+			returnStatusCode: http.StatusOK,
+		},
+		{
+			name:             "success when the token endpoint rejects the unauthenticated request",
+			returnStatusCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:             "error when the token endpoint returns a server error",
+			returnStatusCode: http.StatusBadGateway,
+			wantErr:          `unexpected response status "502 Bad Gateway" from {{.URL}}/token`,
+		},
+		{
+			name:              "error when the token endpoint is unreachable",
+			unreachableServer: true,
+			wantErr:           `Get "{{.URL}}/token": dial tcp {{.Host}}: connect: connection refused`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			numRequests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				numRequests++
+				require.Equal(t, http.MethodGet, r.Method)
+				require.Equal(t, "/token", r.URL.Path)
+				require.Empty(t, r.Header.Get("Authorization"))
+				w.WriteHeader(test.returnStatusCode)
+			}))
+			t.Cleanup(server.Close)
+
+			subject := New(ProviderConfig{
+				OAuth2Config: &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/token"}},
+				HttpClient:   server.Client(),
+			})
+
+			if test.unreachableServer {
+				server.Close() // make the server unreachable by closing it before making any requests
+			}
+
+			err := subject.ProbeHealth(context.Background())
+			if test.wantErr != "" {
+				wantErr := strings.NewReplacer("{{.URL}}", server.URL, "{{.Host}}", server.Listener.Addr().String()).Replace(test.wantErr)
+				require.EqualError(t, err, wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 1, numRequests)
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	const idpDisplayName = "idp display name 😀"
+	const encodedIDPDisplayName = "idp+display+name+%F0%9F%98%80"
+	const accessToken = "fake-access-token"
+
+	mapper, err := celtransformer.NewResponseMapper(5 * time.Second)
+	require.NoError(t, err)
+
+	compileString := func(t *testing.T, expr string) *celtransformer.CompiledStringExpression {
+		t.Helper()
+		compiled, err := mapper.CompileStringExpression(expr)
+		require.NoError(t, err)
+		return compiled
+	}
+	compileStringList := func(t *testing.T, expr string) *celtransformer.CompiledStringListExpression {
+		t.Helper()
+		compiled, err := mapper.CompileStringListExpression(expr)
+		require.NoError(t, err)
+		return compiled
+	}
+
+	tests := []struct {
+		name                string
+		userInfoStatus      int
+		userInfoResponse    string
+		groupsStatus        int
+		groupsResponse      string
+		noGroupsURL         bool
+		usernameExpression  string
+		subjectExpression   string
+		groupsExpression    string
+		wantGroupsURLCalled bool
+		wantUser            *upstreamprovider.OAuth2User
+		wantErr             string
+	}{
+		{
+			name:                "happy path with groups",
+			userInfoResponse:    `{"username":"some-user","id":12345678901234,"score":1.5}`,
+			groupsResponse:      `[{"full_path":"org/group1"},{"full_path":"org/group2"}]`,
+			usernameExpression:  `userInfo.username`,
+			subjectExpression:   `string(userInfo.id)`,
+			groupsExpression:    `groupsResponse.map(g, g.full_path)`,
+			wantGroupsURLCalled: true,
+			wantUser: &upstreamprovider.OAuth2User{
+				Username:          "some-user",
+				Groups:            []string{"org/group1", "org/group2"},
+				Subject:           "12345678901234",
+				DownstreamSubject: fmt.Sprintf("https://fake-userinfo-host/user?idpName=%s&sub=12345678901234", encodedIDPDisplayName),
+				UpstreamData: map[string]any{
+					"userInfo": map[string]any{"username": "some-user", "id": int64(12345678901234), "score": 1.5},
+					"groupsResponse": []any{
+						map[string]any{"full_path": "org/group1"},
+						map[string]any{"full_path": "org/group2"},
+					},
+				},
+			},
+		},
+		{
+			name:               "happy path without a groups URL or groups expression",
+			userInfoResponse:   `{"username":"some-user","id":1}`,
+			noGroupsURL:        true,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `userInfo.username`,
+			wantUser: &upstreamprovider.OAuth2User{
+				Username:          "some-user",
+				Subject:           "some-user",
+				DownstreamSubject: fmt.Sprintf("https://fake-userinfo-host/user?idpName=%s&sub=some-user", encodedIDPDisplayName),
+				UpstreamData: map[string]any{
+					"userInfo": map[string]any{"username": "some-user", "id": int64(1)},
+				},
+			},
+		},
+		{
+			name:               "happy path with groups from the userinfo response and no groups URL",
+			userInfoResponse:   `{"username":"some-user","teams":["a","b"]}`,
+			noGroupsURL:        true,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `userInfo.username`,
+			groupsExpression:   `groupsResponse == null ? userInfo.teams : []`,
+			wantUser: &upstreamprovider.OAuth2User{
+				Username:          "some-user",
+				Groups:            []string{"a", "b"},
+				Subject:           "some-user",
+				DownstreamSubject: fmt.Sprintf("https://fake-userinfo-host/user?idpName=%s&sub=some-user", encodedIDPDisplayName),
+				UpstreamData: map[string]any{
+					"userInfo": map[string]any{"username": "some-user", "teams": []any{"a", "b"}},
+				},
+			},
+		},
+		{
+			name:               "non-integer numbers are available as doubles",
+			userInfoResponse:   `{"username":"some-user","score":1.5,"big":1e3}`,
+			noGroupsURL:        true,
+			usernameExpression: `userInfo.username + ":" + string(userInfo.score) + ":" + string(userInfo.big)`,
+			subjectExpression:  `userInfo.username`,
+			wantUser: &upstreamprovider.OAuth2User{
+				Username:          "some-user:1.5:1000",
+				Subject:           "some-user",
+				DownstreamSubject: fmt.Sprintf("https://fake-userinfo-host/user?idpName=%s&sub=some-user", encodedIDPDisplayName),
+				UpstreamData: map[string]any{
+					"userInfo": map[string]any{"username": "some-user", "score": 1.5, "big": float64(1000)},
+				},
+			},
+		},
+		{
+			name:               "userinfo URL returns an error status",
+			userInfoStatus:     http.StatusUnauthorized,
+			userInfoResponse:   `{}`,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `userInfo.username`,
+			wantErr:            `error calling userinfo URL: unexpected response status "401 Unauthorized"`,
+		},
+		{
+			name:               "userinfo URL returns invalid JSON",
+			userInfoResponse:   `not-json`,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `userInfo.username`,
+			wantErr:            "error calling userinfo URL: could not decode response as JSON: invalid character 'o' in literal null (expecting 'u')",
+		},
+		{
+			name:               "userinfo URL returns more than one JSON document",
+			userInfoResponse:   `{} {}`,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `userInfo.username`,
+			wantErr:            "error calling userinfo URL: could not decode response as JSON: unexpected data after JSON document",
+		},
+		{
+			name:                "groups URL returns an error status",
+			userInfoResponse:    `{"username":"some-user"}`,
+			groupsStatus:        http.StatusForbidden,
+			groupsResponse:      `{}`,
+			usernameExpression:  `userInfo.username`,
+			subjectExpression:   `userInfo.username`,
+			groupsExpression:    `groupsResponse.map(g, g.full_path)`,
+			wantGroupsURLCalled: true,
+			wantErr:             `error calling groups URL: unexpected response status "403 Forbidden"`,
+		},
+		{
+			name:               "username expression fails",
+			userInfoResponse:   `{"login":"some-user"}`,
+			noGroupsURL:        true,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `userInfo.login`,
+			wantErr:            "error evaluating username expression: no such key: username",
+		},
+		{
+			name:               "username expression returns an empty string",
+			userInfoResponse:   `{"username":""}`,
+			noGroupsURL:        true,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `"some-subject"`,
+			wantErr:            "username expression returned an empty string",
+		},
+		{
+			name:               "subject expression fails",
+			userInfoResponse:   `{"username":"some-user"}`,
+			noGroupsURL:        true,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `userInfo.id`,
+			wantErr:            "error evaluating subject expression: no such key: id",
+		},
+		{
+			name:               "subject expression returns an empty string",
+			userInfoResponse:   `{"username":"some-user"}`,
+			noGroupsURL:        true,
+			usernameExpression: `userInfo.username`,
+			subjectExpression:  `""`,
+			wantErr:            "subject expression returned an empty string",
+		},
+		{
+			name:                "groups expression fails",
+			userInfoResponse:    `{"username":"some-user"}`,
+			groupsResponse:      `[{"id":1}]`,
+			usernameExpression:  `userInfo.username`,
+			subjectExpression:   `userInfo.username`,
+			groupsExpression:    `groupsResponse.map(g, g.id)`,
+			wantGroupsURLCalled: true,
+			wantErr:             "error evaluating groups expression: could not convert expression result to []string: unsupported type conversion from 'int' to string",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			groupsURLCalled := false
+			testServer, testServerCA := tlsserver.TestServerIPv4(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodGet, r.Method)
+				require.Equal(t, "Bearer "+accessToken, r.Header.Get("Authorization"))
+				require.Equal(t, "application/json", r.Header.Get("Accept"))
+
+				w.Header().Set("content-type", "application/json")
+				status, body := http.StatusOK, ""
+				switch r.URL.Path {
+				case "/user":
+					status, body = test.userInfoStatus, test.userInfoResponse
+				case "/groups":
+					groupsURLCalled = true
+					status, body = test.groupsStatus, test.groupsResponse
+				default:
+					t.Fatalf("tried to call provider at unexpected endpoint: %s", r.URL.Path)
+				}
+				if status == 0 {
+					status = http.StatusOK
+				}
+				w.WriteHeader(status)
+				_, err := w.Write([]byte(body))
+				require.NoError(t, err)
+			}), nil)
+			testServerPool, err := cert.NewPoolFromBytes(testServerCA)
+			require.NoError(t, err)
+
+			userInfoURL, err := url.Parse(testServer.URL + "/user")
+			require.NoError(t, err)
+			var groupsURL *url.URL
+			if !test.noGroupsURL {
+				groupsURL, err = url.Parse(testServer.URL + "/groups")
+				require.NoError(t, err)
+			}
+
+			claims := &CompiledClaims{
+				Username: compileString(t, test.usernameExpression),
+				Subject:  compileString(t, test.subjectExpression),
+			}
+			if test.groupsExpression != "" {
+				claims.Groups = compileStringList(t, test.groupsExpression)
+			}
+
+			subject := New(ProviderConfig{
+				UserInfoURL: userInfoURL,
+				GroupsURL:   groupsURL,
+				Claims:      claims,
+				HttpClient: &http.Client{
+					Timeout: 10 * time.Second,
+					Transport: &http.Transport{TLSClientConfig: &tls.Config{
+						MinVersion: tls.VersionTLS12,
+						RootCAs:    testServerPool,
+					}},
+				},
+			})
+
+			user, err := subject.GetUser(context.Background(), accessToken, idpDisplayName)
+			require.Equal(t, test.wantGroupsURLCalled, groupsURLCalled)
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+				require.Nil(t, user)
+				return
+			}
+			require.NoError(t, err)
+			// The host of the test server changes for each test, so replace it with a fixed value before comparing.
+			user.DownstreamSubject = strings.Replace(user.DownstreamSubject, testServer.URL, "https://fake-userinfo-host", 1)
+			require.Equal(t, test.wantUser, user)
+		})
+	}
+}
+
+func TestGetUserWithResponseTooLarge(t *testing.T) {
+	testServer, testServerCA := tlsserver.TestServerIPv4(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`"` + strings.Repeat("a", maxResponseBytes) + `"`))
+	}), nil)
+	testServerPool, err := cert.NewPoolFromBytes(testServerCA)
+	require.NoError(t, err)
+
+	userInfoURL, err := url.Parse(testServer.URL + "/user")
+	require.NoError(t, err)
+
+	subject := New(ProviderConfig{
+		UserInfoURL: userInfoURL,
+		HttpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				RootCAs:    testServerPool,
+			}},
+		},
+	})
+
+	user, err := subject.GetUser(context.Background(), "fake-access-token", "some-idp")
+	require.EqualError(t, err, fmt.Sprintf("error calling userinfo URL: response is larger than %d bytes", maxResponseBytes))
+	require.Nil(t, user)
+}
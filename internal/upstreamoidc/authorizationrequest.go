@@ -0,0 +1,153 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	// See https://datatracker.ietf.org/doc/html/rfc9101#section-10.8.
+	requestObjectJWTType = "oauth-authz-req+jwt"
+
+	// The request object is used as soon as the user's browser arrives at the authorization endpoint,
+	// so it can be short-lived.
+	requestObjectLifetime = 5 * time.Minute
+)
+
+// PrepareAuthorizationRequest returns the query params of the authorization request to which the user's browser
+// should be redirected, given the params of a plain authorization request. When the provider is configured to use
+// pushed authorization requests (RFC 9126), the params are first pushed to the provider. When the provider is
+// configured to use signed request objects (RFC 9101), the params are sent as a signed request object.
+// Otherwise, the params are returned unchanged.
+func (p *ProviderConfig) PrepareAuthorizationRequest(ctx context.Context, params url.Values) (url.Values, error) {
+	switch {
+	case p.PushedAuthorizationRequestURL != nil:
+		return p.pushAuthorizationRequest(ctx, params)
+	case p.RequestObjectSigner != nil:
+		return p.signAuthorizationRequest(params)
+	default:
+		return params, nil
+	}
+}
+
+// pushAuthorizationRequest pushes the params to the pushed authorization request endpoint, and returns the params
+// which refer to the pushed request.
+// See https://datatracker.ietf.org/doc/html/rfc9126#section-2.
+func (p *ProviderConfig) pushAuthorizationRequest(ctx context.Context, params url.Values) (url.Values, error) {
+	clientID := p.Config.ClientID
+	endpoint := p.PushedAuthorizationRequestURL.String()
+
+	pushedParams := url.Values{}
+	for key, values := range params {
+		pushedParams[key] = values
+	}
+	pushedParams.Set("client_id", clientID)
+	if p.ClientAssertionSigner != nil {
+		if err := p.ClientAssertionSigner.addTo(pushedParams, clientID, endpoint); err != nil {
+			return nil, err
+		}
+	}
+	useBasicAuth := p.usesClientSecret() && p.Config.Endpoint.AuthStyle != oauth2.AuthStyleInParams
+	if p.usesClientSecret() && !useBasicAuth {
+		pushedParams.Set("client_secret", p.Config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(pushedParams.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if useBasicAuth {
+		// See https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1.
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not push authorization request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not read pushed authorization response: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pushed authorization request failed with status %q and body: %s", resp.Status, body)
+	}
+
+	var response struct {
+		RequestURI string `json:"request_uri"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("could not decode pushed authorization response: %w", err)
+	}
+	if response.RequestURI == "" {
+		return nil, errors.New("pushed authorization response did not contain a request_uri")
+	}
+
+	return url.Values{
+		"client_id":   []string{clientID},
+		"request_uri": []string{response.RequestURI},
+	}, nil
+}
+
+// signAuthorizationRequest returns the params which send the given params as a signed request object.
+// See https://datatracker.ietf.org/doc/html/rfc9101#section-5.
+func (p *ProviderConfig) signAuthorizationRequest(params url.Values) (url.Values, error) {
+	var discoveryClaims struct {
+		Issuer string `json:"issuer"`
+	}
+	if err := p.Provider.Claims(&discoveryClaims); err != nil {
+		return nil, fmt.Errorf("could not read issuer from discovery: %w", err)
+	}
+
+	clientID := p.Config.ClientID
+	requestClaims := make(map[string]any, len(params))
+	for key, values := range params {
+		if len(values) > 0 {
+			requestClaims[key] = values[0]
+		}
+	}
+	requestClaims["client_id"] = clientID
+
+	now := p.RequestObjectSigner.now()
+	requestObject, err := p.RequestObjectSigner.signJWT(requestObjectJWTType, requestClaims, jwt.Claims{
+		Issuer:    clientID,
+		Audience:  jwt.Audience{discoveryClaims.Issuer},
+		ID:        rand.String(32),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(requestObjectLifetime)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not sign request object: %w", err)
+	}
+
+	// OIDC requires that response_type, client_id, and scope are also sent as query params, so that the request is
+	// a valid OAuth 2.0 authorization request. See https://openid.net/specs/openid-connect-core-1_0.html#RequestObject.
+	signedParams := url.Values{
+		"client_id": []string{clientID},
+		"request":   []string{requestObject},
+	}
+	for _, key := range []string{"response_type", "scope"} {
+		if value := params.Get(key); value != "" {
+			signedParams.Set(key, value)
+		}
+	}
+	return signedParams, nil
+}
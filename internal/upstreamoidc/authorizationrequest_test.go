@@ -0,0 +1,280 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestPrepareAuthorizationRequest(t *testing.T) {
+	plainParams := url.Values{
+		"response_type":         []string{"code"},
+		"scope":                 []string{"openid email"},
+		"client_id":             []string{"test-client-id"},
+		"state":                 []string{"some-state"},
+		"nonce":                 []string{"some-nonce"},
+		"code_challenge":        []string{"some-challenge"},
+		"code_challenge_method": []string{"S256"},
+		"redirect_uri":          []string{"https://example.com/callback"},
+	}
+
+	t.Run("query mode returns the params unchanged", func(t *testing.T) {
+		p := ProviderConfig{Config: &oauth2.Config{ClientID: "test-client-id"}}
+		params, err := p.PrepareAuthorizationRequest(context.Background(), plainParams)
+		require.NoError(t, err)
+		require.Equal(t, plainParams, params)
+	})
+
+	t.Run("jar mode sends the params as a signed request object", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		signer, err := NewClientAssertionSigner(pkcs8PEM(t, key), "some-key-id")
+		require.NoError(t, err)
+		now := time.Now().Truncate(time.Second)
+		signer.now = func() time.Time { return now }
+
+		provider := newTestDiscoveryProvider(t)
+		p := ProviderConfig{
+			Config:              &oauth2.Config{ClientID: "test-client-id"},
+			Provider:            provider,
+			RequestObjectSigner: signer,
+		}
+		params, err := p.PrepareAuthorizationRequest(context.Background(), plainParams)
+		require.NoError(t, err)
+		require.Equal(t, []string{"client_id", "request", "response_type", "scope"}, sortedKeys(params))
+		require.Equal(t, "test-client-id", params.Get("client_id"))
+		require.Equal(t, "code", params.Get("response_type"))
+		require.Equal(t, "openid email", params.Get("scope"))
+
+		parsed, err := jwt.ParseSigned(params.Get("request"), []jose.SignatureAlgorithm{jose.ES256})
+		require.NoError(t, err)
+		require.Len(t, parsed.Headers, 1)
+		require.Equal(t, "some-key-id", parsed.Headers[0].KeyID)
+		require.Equal(t, "oauth-authz-req+jwt", parsed.Headers[0].ExtraHeaders[jose.HeaderType])
+
+		var claims jwt.Claims
+		var requestClaims map[string]any
+		require.NoError(t, parsed.Claims(key.Public(), &claims, &requestClaims))
+		require.Equal(t, "test-client-id", claims.Issuer)
+		require.Equal(t, jwt.Audience{strings.TrimSuffix(provider.Endpoint().AuthURL, "/authorize")}, claims.Audience)
+		require.Len(t, claims.ID, 32)
+		require.Equal(t, now, claims.IssuedAt.Time())
+		require.Equal(t, now, claims.NotBefore.Time())
+		require.Equal(t, now.Add(5*time.Minute), claims.Expiry.Time())
+		for key := range plainParams {
+			require.Equal(t, plainParams.Get(key), requestClaims[key], "request object claim %q", key)
+		}
+	})
+
+	tests := []struct {
+		name            string
+		config          *oauth2.Config
+		signer          bool
+		handler         func(t *testing.T, w http.ResponseWriter, r *http.Request)
+		wantParams      url.Values
+		wantErr         string
+		wantErrContains string
+	}{
+		{
+			name: "par mode pushes the params using basic auth",
+			config: &oauth2.Config{
+				ClientID:     "test-client-id",
+				ClientSecret: "test-client-secret",
+			},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				username, password, hasBasicAuth := r.BasicAuth()
+				require.True(t, hasBasicAuth)
+				require.Equal(t, "test-client-id", username)
+				require.Equal(t, "test-client-secret", password)
+				require.Empty(t, r.Form.Get("client_secret"))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"request_uri": "urn:ietf:params:oauth:request_uri:some-request", "expires_in": 60}`))
+			},
+			wantParams: url.Values{
+				"client_id":   []string{"test-client-id"},
+				"request_uri": []string{"urn:ietf:params:oauth:request_uri:some-request"},
+			},
+		},
+		{
+			name: "par mode pushes the params with the client secret in the params",
+			config: &oauth2.Config{
+				ClientID:     "test-client-id",
+				ClientSecret: "test-client-secret",
+				Endpoint:     oauth2.Endpoint{AuthStyle: oauth2.AuthStyleInParams},
+			},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				_, _, hasBasicAuth := r.BasicAuth()
+				require.False(t, hasBasicAuth)
+				require.Equal(t, "test-client-secret", r.Form.Get("client_secret"))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"request_uri": "urn:ietf:params:oauth:request_uri:some-request", "expires_in": 60}`))
+			},
+			wantParams: url.Values{
+				"client_id":   []string{"test-client-id"},
+				"request_uri": []string{"urn:ietf:params:oauth:request_uri:some-request"},
+			},
+		},
+		{
+			name: "par mode pushes the params using a client assertion",
+			config: &oauth2.Config{
+				ClientID: "test-client-id",
+				Endpoint: oauth2.Endpoint{AuthStyle: oauth2.AuthStyleInParams},
+			},
+			signer: true,
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				_, _, hasBasicAuth := r.BasicAuth()
+				require.False(t, hasBasicAuth)
+				require.Empty(t, r.Form.Get("client_secret"))
+				require.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", r.Form.Get("client_assertion_type"))
+				require.NotEmpty(t, r.Form.Get("client_assertion"))
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"request_uri": "urn:ietf:params:oauth:request_uri:some-request", "expires_in": 60}`))
+			},
+			wantParams: url.Values{
+				"client_id":   []string{"test-client-id"},
+				"request_uri": []string{"urn:ietf:params:oauth:request_uri:some-request"},
+			},
+		},
+		{
+			name: "par mode pushes the params of a public client",
+			config: &oauth2.Config{
+				ClientID: "test-client-id",
+				Endpoint: oauth2.Endpoint{AuthStyle: oauth2.AuthStyleInParams},
+			},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				_, _, hasBasicAuth := r.BasicAuth()
+				require.False(t, hasBasicAuth)
+				require.Empty(t, r.Form.Get("client_secret"))
+				require.Empty(t, r.Form.Get("client_assertion"))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"request_uri": "urn:ietf:params:oauth:request_uri:some-request", "expires_in": 60}`))
+			},
+			wantParams: url.Values{
+				"client_id":   []string{"test-client-id"},
+				"request_uri": []string{"urn:ietf:params:oauth:request_uri:some-request"},
+			},
+		},
+		{
+			name:   "par mode when the server returns an error",
+			config: &oauth2.Config{ClientID: "test-client-id", ClientSecret: "test-client-secret"},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "invalid_request"}`))
+			},
+			wantErr: `pushed authorization request failed with status "400 Bad Request" and body: {"error": "invalid_request"}`,
+		},
+		{
+			name:   "par mode when the server returns invalid JSON",
+			config: &oauth2.Config{ClientID: "test-client-id", ClientSecret: "test-client-secret"},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`not json`))
+			},
+			wantErrContains: "could not decode pushed authorization response: ",
+		},
+		{
+			name:   "par mode when the server does not return a request_uri",
+			config: &oauth2.Config{ClientID: "test-client-id", ClientSecret: "test-client-secret"},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"expires_in": 60}`))
+			},
+			wantErr: "pushed authorization response did not contain a request_uri",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "/par", r.URL.Path)
+				require.NoError(t, r.ParseForm())
+				// All the params of the authorization request are pushed.
+				for key := range plainParams {
+					require.Equal(t, plainParams.Get(key), r.PostForm.Get(key), "pushed param %q", key)
+				}
+				tt.handler(t, w, r)
+			}))
+			t.Cleanup(server.Close)
+
+			parURL, err := url.Parse(server.URL + "/par")
+			require.NoError(t, err)
+			p := ProviderConfig{
+				Config:                        tt.config,
+				Client:                        server.Client(),
+				PushedAuthorizationRequestURL: parURL,
+			}
+			if tt.signer {
+				key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				require.NoError(t, err)
+				p.ClientAssertionSigner, err = NewClientAssertionSigner(pkcs8PEM(t, key), "")
+				require.NoError(t, err)
+			}
+
+			params, err := p.PrepareAuthorizationRequest(context.Background(), plainParams)
+			switch {
+			case tt.wantErr != "":
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, params)
+			case tt.wantErrContains != "":
+				require.ErrorContains(t, err, tt.wantErrContains)
+				require.Nil(t, params)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, tt.wantParams, params)
+			}
+		})
+	}
+}
+
+func newTestDiscoveryProvider(t *testing.T) *coreosoidc.Provider {
+	t.Helper()
+
+	var issuer string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/.well-known/openid-configuration", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"jwks_uri":               issuer + "/jwks.json",
+		})
+	}))
+	t.Cleanup(server.Close)
+	issuer = server.URL
+
+	provider, err := coreosoidc.NewProvider(coreosoidc.ClientContext(context.Background(), server.Client()), issuer)
+	require.NoError(t, err)
+	return provider
+}
+
+func sortedKeys(params url.Values) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
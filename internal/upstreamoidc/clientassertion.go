@@ -0,0 +1,200 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	// See https://datatracker.ietf.org/doc/html/rfc7523#section-2.2.
+	clientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// Client assertions are used immediately after they are signed, so they can be short-lived.
+	clientAssertionLifetime = 2 * time.Minute
+)
+
+// ClientAssertionSigner signs JWTs using the private key of the Supervisor's client of an upstream provider.
+// It signs the client assertions which authenticate the Supervisor to the upstream provider using the
+// private_key_jwt client authentication method, and the request objects of signed authorization requests.
+// See https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication.
+type ClientAssertionSigner struct {
+	signingKey jose.SigningKey
+	keyID      string
+	now        func() time.Time
+}
+
+// NewClientAssertionSigner returns a ClientAssertionSigner which signs with the given PEM-encoded RSA or ECDSA
+// private key. The keyID is optional. When it is not empty, it is included in the header of the client assertions
+// so that the upstream provider can find the corresponding public key.
+func NewClientAssertionSigner(privateKeyPEM []byte, keyID string) (*ClientAssertionSigner, error) {
+	privateKey, algorithm, err := parseClientAssertionPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	signingKey := jose.SigningKey{Algorithm: algorithm, Key: privateKey}
+	// Check that the key can be used for signing now, so that any problem with the key is reported early.
+	if _, err := jose.NewSigner(signingKey, nil); err != nil {
+		return nil, fmt.Errorf("could not create signer: %w", err)
+	}
+
+	return &ClientAssertionSigner{signingKey: signingKey, keyID: keyID, now: time.Now}, nil
+}
+
+func parseClientAssertionPrivateKey(privateKeyPEM []byte) (crypto.Signer, jose.SignatureAlgorithm, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, "", errors.New("no PEM block found")
+	}
+
+	var privateKey any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("could not parse private key: %w", err)
+	}
+
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return key, jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return key, jose.ES256, nil
+		case elliptic.P384():
+			return key, jose.ES384, nil
+		case elliptic.P521():
+			return key, jose.ES512, nil
+		}
+		return nil, "", fmt.Errorf("unsupported elliptic curve %s", key.Curve.Params().Name)
+	default:
+		return nil, "", fmt.Errorf("private key must be an RSA or ECDSA key, found %T instead", privateKey)
+	}
+}
+
+// signJWT returns a JWT of the given type ("typ" header) which contains the given claims.
+func (s *ClientAssertionSigner) signJWT(jwtType string, claims ...any) (string, error) {
+	options := (&jose.SignerOptions{}).WithType(jose.ContentType(jwtType))
+	if s.keyID != "" {
+		options = options.WithHeader(jose.HeaderKey("kid"), s.keyID)
+	}
+	signer, err := jose.NewSigner(s.signingKey, options)
+	if err != nil {
+		return "", err
+	}
+
+	builder := jwt.Signed(signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+	return builder.Serialize()
+}
+
+// sign returns a client assertion for the given client which may only be used at the given endpoint.
+func (s *ClientAssertionSigner) sign(clientID string, endpoint string) (string, error) {
+	now := s.now()
+	return s.signJWT("JWT", jwt.Claims{
+		Issuer:   clientID,
+		Subject:  clientID,
+		Audience: jwt.Audience{endpoint},
+		ID:       rand.String(32),
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(clientAssertionLifetime)),
+	})
+}
+
+// addTo adds a newly signed client assertion to the parameters of a request to the given endpoint.
+func (s *ClientAssertionSigner) addTo(params url.Values, clientID string, endpoint string) error {
+	assertion, err := s.sign(clientID, endpoint)
+	if err != nil {
+		return fmt.Errorf("could not sign client assertion: %w", err)
+	}
+	params.Set("client_id", clientID)
+	params.Set("client_assertion_type", clientAssertionTypeJWTBearer)
+	params.Set("client_assertion", assertion)
+	return nil
+}
+
+// clientAssertionRoundTripper adds a client assertion to each request to the token endpoint. This allows the
+// requests made by the oauth2 library, which does not support private_key_jwt, to be authenticated.
+type clientAssertionRoundTripper struct {
+	base     http.RoundTripper
+	signer   *ClientAssertionSigner
+	clientID string
+	tokenURL string
+}
+
+func (rt *clientAssertionRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.URL.String() != rt.tokenURL || req.Body == nil {
+		return rt.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse token request: %w", err)
+	}
+	if err := rt.signer.addTo(params, rt.clientID, rt.tokenURL); err != nil {
+		return nil, err
+	}
+
+	newBody := []byte(params.Encode())
+	newReq := req.Clone(req.Context())
+	newReq.Body = io.NopCloser(bytes.NewReader(newBody))
+	newReq.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(newBody)), nil }
+	newReq.ContentLength = int64(len(newBody))
+	newReq.Header.Set("Content-Length", strconv.Itoa(len(newBody)))
+	return rt.base.RoundTrip(newReq)
+}
+
+// tokenEndpointClient returns the HTTP client to use for requests to the token endpoint. When the private_key_jwt
+// client authentication method is configured, the requests made using the returned client are authenticated
+// using client assertions.
+func (p *ProviderConfig) tokenEndpointClient() *http.Client {
+	if p.ClientAssertionSigner == nil {
+		return p.Client
+	}
+
+	base := p.Client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client := *p.Client
+	client.Transport = &clientAssertionRoundTripper{
+		base:     base,
+		signer:   p.ClientAssertionSigner,
+		clientID: p.Config.ClientID,
+		tokenURL: p.Config.Endpoint.TokenURL,
+	}
+	return &client
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+)
+
+func TestNewClientAssertionSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	p256SEC1, err := x509.MarshalECPrivateKey(p256Key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		privateKeyPEM []byte
+		keyID         string
+		wantPublicKey crypto.PublicKey
+		wantAlgorithm jose.SignatureAlgorithm
+		wantErr       string
+	}{
+		{
+			name:          "PKCS1 RSA key",
+			privateKeyPEM: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			wantPublicKey: rsaKey.Public(),
+			wantAlgorithm: jose.RS256,
+		},
+		{
+			name:          "PKCS8 RSA key with a key ID",
+			privateKeyPEM: pkcs8PEM(t, rsaKey),
+			keyID:         "some-key-id",
+			wantPublicKey: rsaKey.Public(),
+			wantAlgorithm: jose.RS256,
+		},
+		{
+			name:          "SEC1 P-256 key",
+			privateKeyPEM: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: p256SEC1}),
+			wantPublicKey: p256Key.Public(),
+			wantAlgorithm: jose.ES256,
+		},
+		{
+			name:          "PKCS8 P-384 key",
+			privateKeyPEM: pkcs8PEM(t, p384Key),
+			wantPublicKey: p384Key.Public(),
+			wantAlgorithm: jose.ES384,
+		},
+		{
+			name:          "PKCS8 P-521 key",
+			privateKeyPEM: pkcs8PEM(t, p521Key),
+			wantPublicKey: p521Key.Public(),
+			wantAlgorithm: jose.ES512,
+		},
+		{
+			name:          "unsupported key type",
+			privateKeyPEM: pkcs8PEM(t, ed25519Key),
+			wantErr:       "private key must be an RSA or ECDSA key, found ed25519.PrivateKey instead",
+		},
+		{
+			name:          "not PEM",
+			privateKeyPEM: []byte("not a key"),
+			wantErr:       "no PEM block found",
+		},
+		{
+			name:          "invalid key",
+			privateKeyPEM: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("not a key")}),
+			wantErr:       "could not parse private key: asn1: structure error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewClientAssertionSigner(tt.privateKeyPEM, tt.keyID)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				require.Nil(t, signer)
+				return
+			}
+			require.NoError(t, err)
+
+			now := time.Now().Truncate(time.Second)
+			signer.now = func() time.Time { return now }
+			assertion, err := signer.sign("some-client-id", "https://some-provider.example.com/token")
+			require.NoError(t, err)
+
+			parsed, err := jwt.ParseSigned(assertion, []jose.SignatureAlgorithm{tt.wantAlgorithm})
+			require.NoError(t, err)
+			require.Len(t, parsed.Headers, 1)
+			require.Equal(t, tt.keyID, parsed.Headers[0].KeyID)
+
+			var claims jwt.Claims
+			require.NoError(t, parsed.Claims(tt.wantPublicKey, &claims))
+			require.Equal(t, "some-client-id", claims.Issuer)
+			require.Equal(t, "some-client-id", claims.Subject)
+			require.Equal(t, jwt.Audience{"https://some-provider.example.com/token"}, claims.Audience)
+			require.Len(t, claims.ID, 32)
+			require.Equal(t, now, claims.IssuedAt.Time())
+			require.Equal(t, now.Add(2*time.Minute), claims.Expiry.Time())
+		})
+	}
+}
+
+func TestClientAssertionAuthentication(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := NewClientAssertionSigner(pkcs8PEM(t, key), "")
+	require.NoError(t, err)
+
+	var serverURL string
+	var numRevocationRequests int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		_, _, hasBasicAuth := r.BasicAuth()
+		require.False(t, hasBasicAuth)
+		require.Empty(t, r.Form.Get("client_secret"))
+		require.Equal(t, "test-client-id", r.Form.Get("client_id"))
+		require.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", r.Form.Get("client_assertion_type"))
+
+		parsed, err := jwt.ParseSigned(r.Form.Get("client_assertion"), []jose.SignatureAlgorithm{jose.ES256})
+		require.NoError(t, err)
+		var claims jwt.Claims
+		require.NoError(t, parsed.Claims(key.Public(), &claims))
+		require.NoError(t, claims.Validate(jwt.Expected{
+			Issuer:      "test-client-id",
+			Subject:     "test-client-id",
+			AnyAudience: jwt.Audience{serverURL + r.URL.Path},
+		}))
+
+		switch r.URL.Path {
+		case "/token":
+			require.Equal(t, "refresh_token", r.Form.Get("grant_type"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "test-access-token", "token_type": "Bearer", "refresh_token": "new-refresh-token"}`))
+		case "/revoke":
+			numRevocationRequests++
+			http.Error(w, `{"error": "invalid_client"}`, http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	serverURL = server.URL
+
+	revocationURL, err := url.Parse(server.URL + "/revoke")
+	require.NoError(t, err)
+	p := ProviderConfig{
+		Name: "test-name",
+		Config: &oauth2.Config{
+			ClientID: "test-client-id",
+			Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/token", AuthStyle: oauth2.AuthStyleInParams},
+		},
+		Client:                server.Client(),
+		RevocationURL:         revocationURL,
+		ClientAssertionSigner: signer,
+	}
+
+	t.Run("refresh requests are authenticated using client assertions", func(t *testing.T) {
+		tok, err := p.PerformRefresh(context.Background(), "test-refresh-token")
+		require.NoError(t, err)
+		require.Equal(t, "test-access-token", tok.AccessToken)
+		require.Equal(t, "new-refresh-token", tok.RefreshToken)
+	})
+
+	t.Run("revocation requests are authenticated using client assertions and are not retried using basic auth", func(t *testing.T) {
+		err := p.RevokeToken(context.Background(), "test-refresh-token", upstreamprovider.RefreshTokenType)
+		require.EqualError(t, err, `server responded with status 400 with body: {"error": "invalid_client"}`)
+		require.Equal(t, 1, numRevocationRequests)
+	})
+
+	t.Run("the HTTP client is not modified", func(t *testing.T) {
+		require.Same(t, server.Client().Transport, p.Client.Transport)
+	})
+}
+
+func pkcs8PEM(t *testing.T, key crypto.PrivateKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
@@ -0,0 +1,188 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+const (
+	// These claims describe distributed and aggregated claims.
+	// See https://openid.net/specs/openid-connect-core-1_0.html#AggregatedDistributedClaims.
+	claimNamesClaim   = "_claim_names"
+	claimSourcesClaim = "_claim_sources"
+
+	// The largest response body which will be read from a distributed claims endpoint or from the groups endpoint.
+	maxResponseBytes = 10 * 1024 * 1024
+)
+
+// resolveDistributedClaims looks up the values of the claims which are needed to determine the identity of the user
+// when the upstream provider has returned them as distributed or aggregated claims, instead of returning their values.
+// Aggregated claims are only used when they are signed by the upstream provider. Distributed claims are fetched from
+// the HTTPS endpoint named by the upstream provider, using the access token named by the upstream provider or else
+// the upstream access token. The resolved values are added to the claims. Claims which already have a value are
+// not changed.
+func (p *ProviderConfig) resolveDistributedClaims(ctx context.Context, tok *oauth2.Token, claims map[string]any) error {
+	claimNames, _ := claims[claimNamesClaim].(map[string]any)
+	if len(claimNames) == 0 {
+		return nil
+	}
+	claimSources, _ := claims[claimSourcesClaim].(map[string]any)
+
+	resolvedSources := map[string]map[string]any{}
+	for _, claimName := range p.claimsWhichMayBeDistributed() {
+		if _, alreadyHasValue := claims[claimName]; alreadyHasValue {
+			continue
+		}
+		sourceName, ok := claimNames[claimName].(string)
+		if !ok {
+			continue
+		}
+
+		sourceClaims, alreadyResolved := resolvedSources[sourceName]
+		if !alreadyResolved {
+			source, ok := claimSources[sourceName].(map[string]any)
+			if !ok {
+				return fmt.Errorf("claim %q refers to claim source %q, which is not described by the %q claim",
+					claimName, sourceName, claimSourcesClaim)
+			}
+			var err error
+			sourceClaims, err = p.fetchClaimSource(ctx, tok, source)
+			if err != nil {
+				return fmt.Errorf("could not resolve claim %q from claim source %q: %w", claimName, sourceName, err)
+			}
+			resolvedSources[sourceName] = sourceClaims
+		}
+
+		value, ok := sourceClaims[claimName]
+		if !ok {
+			plog.Debug("claim source did not include distributed claim",
+				"providerName", p.Name, "claimName", claimName, "claimSource", sourceName)
+			continue
+		}
+		claims[claimName] = value
+	}
+
+	if len(resolvedSources) > 0 {
+		maybeLogClaims("claims after resolving distributed and aggregated claims", p.Name, claims)
+	}
+	return nil
+}
+
+// claimsWhichMayBeDistributed returns the names of the upstream claims which are used by this provider.
+// The groups claim is not included when the groups endpoint is configured, because the groups endpoint
+// will replace its value anyway.
+func (p *ProviderConfig) claimsWhichMayBeDistributed() []string {
+	var names []string
+	if p.UsernameClaim != "" {
+		names = append(names, p.UsernameClaim)
+	}
+	if p.GroupsClaim != "" && p.GroupsEndpoint == nil {
+		names = append(names, p.GroupsClaim)
+	}
+	return append(names, slices.Sorted(maps.Values(p.AdditionalClaimMappings))...)
+}
+
+// fetchClaimSource returns the claims of an aggregated claim source ("JWT") or a distributed claim source ("endpoint").
+func (p *ProviderConfig) fetchClaimSource(ctx context.Context, tok *oauth2.Token, source map[string]any) (map[string]any, error) {
+	if aggregatedJWT, ok := source["JWT"].(string); ok {
+		return p.verifyClaimSourceJWT(ctx, aggregatedJWT)
+	}
+
+	endpoint, ok := source["endpoint"].(string)
+	if !ok {
+		return nil, errors.New(`claim source must have either a "JWT" or an "endpoint"`)
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse claim source endpoint: %w", err)
+	}
+	if endpointURL.Scheme != "https" {
+		return nil, fmt.Errorf("claim source endpoint %q must be an https URL", endpointURL.Redacted())
+	}
+
+	accessToken, _ := source["access_token"].(string)
+	if accessToken == "" {
+		accessToken = tok.AccessToken
+	}
+	if accessToken == "" {
+		return nil, errors.New("no access token is available to call the claim source endpoint")
+	}
+
+	body, header, err := p.getWithBearerToken(ctx, endpointURL, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not call claim source endpoint %q: %w", endpointURL.Redacted(), err)
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mediaType == "application/jwt" {
+		return p.verifyClaimSourceJWT(ctx, strings.TrimSpace(string(body)))
+	}
+
+	var sourceClaims map[string]any
+	if err := json.Unmarshal(body, &sourceClaims); err != nil {
+		return nil, fmt.Errorf("could not decode claim source endpoint response: %w", err)
+	}
+	return sourceClaims, nil
+}
+
+// verifyClaimSourceJWT verifies that the JWT was signed by the upstream provider and returns its claims.
+// The audience and expiry are not checked, because claim source JWTs are not issued to a particular client,
+// and aggregated claims are only used while the token which contained them is being validated.
+func (p *ProviderConfig) verifyClaimSourceJWT(ctx context.Context, rawJWT string) (map[string]any, error) {
+	verified, err := p.Provider.Verifier(&coreosoidc.Config{SkipClientIDCheck: true, SkipExpiryCheck: true}).
+		Verify(coreosoidc.ClientContext(ctx, p.Client), rawJWT)
+	if err != nil {
+		return nil, fmt.Errorf("claim source JWT is invalid: %w", err)
+	}
+
+	var sourceClaims map[string]any
+	if err := verified.Claims(&sourceClaims); err != nil {
+		return nil, fmt.Errorf("could not unmarshal claim source JWT claims: %w", err)
+	}
+	return sourceClaims, nil
+}
+
+func (p *ProviderConfig) getWithBearerToken(ctx context.Context, endpointURL *url.URL, accessToken string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointURL.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json, application/jwt")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) > maxResponseBytes {
+		return nil, nil, fmt.Errorf("response is larger than %d bytes", maxResponseBytes)
+	}
+
+	return body, resp.Header, nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+const testClaimSourceIssuer = "https://some-issuer.example.com"
+
+func TestResolveDistributedClaims(t *testing.T) {
+	issuerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	groupsAndDepartmentJWT := signTestJWT(t, issuerKey, map[string]any{
+		"iss":        testClaimSourceIssuer,
+		"groups":     []string{"jwt-group1", "jwt-group2"},
+		"department": "jwt-department",
+	})
+	wrongKeyJWT := signTestJWT(t, otherKey, map[string]any{
+		"iss":    testClaimSourceIssuer,
+		"groups": []string{"jwt-group1"},
+	})
+
+	var requestedPaths []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPaths = append(requestedPaths, r.URL.Path)
+		switch r.URL.Path {
+		case "/json":
+			if r.Header.Get("Authorization") != "Bearer source-access-token" {
+				http.Error(w, "wrong access token", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprint(w, `{"groups": ["json-group1", "json-group2"], "username": "json-username"}`)
+		case "/jwt":
+			if r.Header.Get("Authorization") != "Bearer test-access-token" {
+				http.Error(w, "wrong access token", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/jwt")
+			_, _ = fmt.Fprint(w, groupsAndDepartmentJWT)
+		default:
+			http.Error(w, "oops", http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name               string
+		userInfoClaims     string
+		groupsEndpoint     *GroupsEndpointConfig
+		wantClaims         map[string]any
+		wantRequestedPaths []string
+		wantErr            string
+	}{
+		{
+			name:           "no distributed claims",
+			userInfoClaims: `{"sub": "some-subject", "username": "some-username", "groups": ["some-group"]}`,
+			wantClaims: map[string]any{
+				"username": "some-username",
+				"groups":   []any{"some-group"},
+			},
+		},
+		{
+			name: "aggregated claims which were signed by the upstream provider are resolved",
+			userInfoClaims: `{"sub": "some-subject", "username": "some-username",
+				"_claim_names": {"groups": "src1", "department": "src1"},
+				"_claim_sources": {"src1": {"JWT": "` + groupsAndDepartmentJWT + `"}}}`,
+			wantClaims: map[string]any{
+				"username":   "some-username",
+				"groups":     []any{"jwt-group1", "jwt-group2"},
+				"department": "jwt-department",
+			},
+		},
+		{
+			name: "aggregated claims which were not signed by the upstream provider cause an error",
+			userInfoClaims: `{"sub": "some-subject",
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"JWT": "` + wrongKeyJWT + `"}}}`,
+			wantErr: `could not resolve distributed claims: could not resolve claim "groups" from claim source "src1": ` +
+				`claim source JWT is invalid: failed to verify signature: no public keys able to verify jwt`,
+		},
+		{
+			name: "distributed claims are fetched once per claim source using the claim source's access token",
+			userInfoClaims: `{"sub": "some-subject",
+				"_claim_names": {"groups": "src1", "username": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "` + server.URL + `/json", "access_token": "source-access-token"}}}`,
+			wantClaims: map[string]any{
+				"username": "json-username",
+				"groups":   []any{"json-group1", "json-group2"},
+			},
+			wantRequestedPaths: []string{"/json"},
+		},
+		{
+			name: "distributed claims endpoints which return a JWT are called using the upstream access token",
+			userInfoClaims: `{"sub": "some-subject", "username": "some-username",
+				"_claim_names": {"groups": "src1", "department": "src2"},
+				"_claim_sources": {"src1": {"endpoint": "` + server.URL + `/jwt"}, "src2": {"endpoint": "` + server.URL + `/jwt"}}}`,
+			wantClaims: map[string]any{
+				"username":   "some-username",
+				"groups":     []any{"jwt-group1", "jwt-group2"},
+				"department": "jwt-department",
+			},
+			wantRequestedPaths: []string{"/jwt", "/jwt"},
+		},
+		{
+			name: "claims which already have a value and claims which are not used are not resolved",
+			userInfoClaims: `{"sub": "some-subject", "username": "some-username",
+				"_claim_names": {"username": "src1", "unused": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "` + server.URL + `/error"}}}`,
+			wantClaims: map[string]any{
+				"username": "some-username",
+			},
+		},
+		{
+			name: "the groups claim is not resolved when the groups endpoint is configured",
+			userInfoClaims: `{"sub": "some-subject",
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "` + server.URL + `/error"}}}`,
+			groupsEndpoint: &GroupsEndpointConfig{URL: server.URL + "/json", ItemsField: "groups"},
+			wantErr: `could not fetch groups from groups endpoint: could not call groups endpoint "` + server.URL + `/json": ` +
+				`unexpected response status "401 Unauthorized"`,
+			wantRequestedPaths: []string{"/json"},
+		},
+		{
+			name: "claim sources which are not described cause an error",
+			userInfoClaims: `{"sub": "some-subject",
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src2": {"endpoint": "` + server.URL + `/json"}}}`,
+			wantErr: `could not resolve distributed claims: claim "groups" refers to claim source "src1", ` +
+				`which is not described by the "_claim_sources" claim`,
+		},
+		{
+			name: "claim sources without a JWT or endpoint cause an error",
+			userInfoClaims: `{"sub": "some-subject",
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"something": "else"}}}`,
+			wantErr: `could not resolve distributed claims: could not resolve claim "groups" from claim source "src1": ` +
+				`claim source must have either a "JWT" or an "endpoint"`,
+		},
+		{
+			name: "claim source endpoints which are not https cause an error",
+			userInfoClaims: `{"sub": "some-subject",
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "http://claims.example.com/groups"}}}`,
+			wantErr: `could not resolve distributed claims: could not resolve claim "groups" from claim source "src1": ` +
+				`claim source endpoint "http://claims.example.com/groups" must be an https URL`,
+		},
+		{
+			name: "claim source endpoint errors cause an error",
+			userInfoClaims: `{"sub": "some-subject",
+				"_claim_names": {"groups": "src1"},
+				"_claim_sources": {"src1": {"endpoint": "` + server.URL + `/error"}}}`,
+			wantErr: `could not resolve distributed claims: could not resolve claim "groups" from claim source "src1": ` +
+				`could not call claim source endpoint "` + server.URL + `/error": unexpected response status "500 Internal Server Error"`,
+			wantRequestedPaths: []string{"/error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestedPaths = nil
+
+			p := ProviderConfig{
+				Name:                    "test-name",
+				UsernameClaim:           "username",
+				GroupsClaim:             "groups",
+				AdditionalClaimMappings: map[string]string{"dept": "department"},
+				GroupsEndpoint:          tt.groupsEndpoint,
+				Config:                  &oauth2.Config{ClientID: "test-client-id"},
+				Client:                  server.Client(),
+				Provider: &verifyingProvider{
+					mockProvider: &mockProvider{
+						rawClaims: []byte(`{"userinfo_endpoint": "not-empty"}`),
+						userInfo:  forceUserInfoWithClaims("some-subject", tt.userInfoClaims),
+					},
+					keySet: &coreosoidc.StaticKeySet{PublicKeys: []crypto.PublicKey{issuerKey.Public()}},
+				},
+			}
+
+			tok, err := p.ValidateTokenAndMergeWithUserInfo(context.Background(),
+				&oauth2.Token{AccessToken: "test-access-token"}, "", false, true)
+			require.Equal(t, tt.wantRequestedPaths, requestedPaths)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			for _, claimName := range []string{"username", "groups", "department"} {
+				want, wantOK := tt.wantClaims[claimName]
+				got, gotOK := tok.IDToken.Claims[claimName]
+				require.Equal(t, wantOK, gotOK, "presence of claim %q", claimName)
+				require.Equal(t, want, got, "value of claim %q", claimName)
+			}
+		})
+	}
+}
+
+// verifyingProvider is a mockProvider whose verifier checks the issuer and the signature of JWTs.
+type verifyingProvider struct {
+	*mockProvider
+	keySet coreosoidc.KeySet
+}
+
+func (v *verifyingProvider) Verifier(config *coreosoidc.Config) *coreosoidc.IDTokenVerifier {
+	configCopy := *config
+	configCopy.SupportedSigningAlgs = []string{coreosoidc.ES256}
+	return coreosoidc.NewVerifier(testClaimSourceIssuer, v.keySet, &configCopy)
+}
+
+func signTestJWT(t *testing.T, key *ecdsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	require.NoError(t, err)
+	signed, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.NoError(t, err)
+	return signed
+}
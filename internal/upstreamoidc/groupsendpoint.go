@@ -0,0 +1,171 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"go.pinniped.dev/internal/plog"
+)
+
+// The most pages of groups which will be fetched from the groups endpoint for a single user. This protects against
+// a misbehaving endpoint, and is more than enough for the largest group memberships seen in practice.
+const maxGroupsEndpointPages = 100
+
+// GroupsEndpointConfig holds the configuration of an API endpoint which returns the group memberships of a user.
+type GroupsEndpointConfig struct {
+	// URL is the URL of the first page of groups.
+	URL string
+
+	// ItemsField is the name of the field of the response which contains the array of groups.
+	// When it is empty, the response itself must be the array of groups.
+	ItemsField string
+
+	// GroupNameField is the name of the field of each group object which contains the group name.
+	GroupNameField string
+
+	// NextPageField is the name of the field of the response which contains the URL of the next page of groups.
+	NextPageField string
+}
+
+// maybeFetchGroupsAndMergeClaims replaces the value of the groups claim with the groups returned by the groups
+// endpoint, when the groups endpoint is configured.
+func (p *ProviderConfig) maybeFetchGroupsAndMergeClaims(ctx context.Context, tok *oauth2.Token, claims map[string]any) error {
+	if p.GroupsEndpoint == nil {
+		return nil
+	}
+	if tok.AccessToken == "" {
+		return errors.New("no access token is available to call the groups endpoint")
+	}
+
+	firstPageURL, err := url.Parse(p.GroupsEndpoint.URL)
+	if err != nil {
+		return fmt.Errorf("could not parse groups endpoint URL: %w", err)
+	}
+
+	groups := []any{}
+	fetchedPages := sets.New[string]()
+	for pageURL := firstPageURL; pageURL != nil; {
+		if fetchedPages.Len() == maxGroupsEndpointPages {
+			return fmt.Errorf("groups endpoint returned more than %d pages of groups", maxGroupsEndpointPages)
+		}
+		if fetchedPages.Has(pageURL.String()) {
+			return fmt.Errorf("groups endpoint returned a link to page %q, which was already fetched", pageURL.Redacted())
+		}
+		fetchedPages.Insert(pageURL.String())
+
+		body, header, err := p.getWithBearerToken(ctx, pageURL, tok.AccessToken)
+		if err != nil {
+			return fmt.Errorf("could not call groups endpoint %q: %w", pageURL.Redacted(), err)
+		}
+
+		var pageGroups []any
+		pageGroups, pageURL, err = p.GroupsEndpoint.parsePage(pageURL, body, header)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, pageGroups...)
+
+		// The access token is sent to the next page, so do not follow links to other servers.
+		if pageURL != nil && (pageURL.Scheme != "https" || pageURL.Host != firstPageURL.Host) {
+			return fmt.Errorf("next page URL %q of groups endpoint response must be an https URL on host %q",
+				pageURL.Redacted(), firstPageURL.Host)
+		}
+	}
+
+	plog.Debug("fetched groups from groups endpoint",
+		"providerName", p.Name, "pages", fetchedPages.Len(), "groups", len(groups))
+	claims[p.GroupsClaim] = groups
+	maybeLogClaims("claims after fetching groups from groups endpoint", p.Name, claims)
+	return nil
+}
+
+// parsePage returns the group names found in one page of the groups endpoint's response, and the URL
+// of the next page, which is nil when there are no more pages.
+func (g *GroupsEndpointConfig) parsePage(pageURL *url.URL, body []byte, header http.Header) ([]any, *url.URL, error) {
+	var response any
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, nil, fmt.Errorf("could not decode groups endpoint response: %w", err)
+	}
+
+	var items []any
+	var nextPage string
+	if g.ItemsField == "" {
+		var ok bool
+		if items, ok = response.([]any); !ok {
+			return nil, nil, errors.New("groups endpoint response is not an array")
+		}
+	} else {
+		responseObject, ok := response.(map[string]any)
+		if !ok {
+			return nil, nil, errors.New("groups endpoint response is not an object")
+		}
+		if items, ok = responseObject[g.ItemsField].([]any); !ok {
+			return nil, nil, fmt.Errorf("groups endpoint response does not contain an array named %q", g.ItemsField)
+		}
+		nextPage, _ = responseObject[g.NextPageField].(string)
+	}
+
+	groups := make([]any, 0, len(items))
+	for _, item := range items {
+		switch group := item.(type) {
+		case string:
+			groups = append(groups, group)
+		case map[string]any:
+			if groupName, ok := group[g.GroupNameField].(string); ok && groupName != "" {
+				groups = append(groups, groupName)
+			}
+		}
+	}
+
+	if nextPage == "" {
+		nextPage = nextLinkFromHeader(header)
+	}
+	if nextPage == "" {
+		return groups, nil, nil
+	}
+
+	nextPageURL, err := pageURL.Parse(nextPage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse next page URL of groups endpoint response: %w", err)
+	}
+	return groups, nextPageURL, nil
+}
+
+// nextLinkFromHeader returns the URL of the link with relation type "next" from the Link headers, if any.
+// See https://datatracker.ietf.org/doc/html/rfc8288#section-3.
+func nextLinkFromHeader(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for {
+			start := strings.IndexByte(value, '<')
+			end := strings.IndexByte(value, '>')
+			if start < 0 || end < start {
+				break
+			}
+			link, params := value[start+1:end], value[end+1:]
+			value = ""
+			if nextStart := strings.IndexByte(params, '<'); nextStart >= 0 {
+				params, value = params[:nextStart], params[nextStart:]
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, paramValue, _ := strings.Cut(strings.Trim(param, " ,"), "=")
+				if strings.EqualFold(strings.TrimSpace(name), "rel") &&
+					slices.Contains(strings.Fields(strings.ToLower(strings.Trim(strings.TrimSpace(paramValue), `"`))), "next") {
+					return link
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,210 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestGroupsEndpoint(t *testing.T) {
+	var requestedURIs []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedURIs = append(requestedURIs, r.URL.RequestURI())
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			http.Error(w, "wrong access token", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.RequestURI() {
+		case "/memberOf":
+			_, _ = fmt.Fprint(w, `{
+				"value": [{"displayName": "group1"}, {"id": "no-display-name"}, {"displayName": 42}, "group2"],
+				"@odata.nextLink": "/memberOf?page=2"
+			}`)
+		case "/memberOf?page=2":
+			_, _ = fmt.Fprint(w, `{"value": [{"displayName": "group3"}]}`)
+		case "/groups":
+			w.Header().Add("Link", `<https://other.example.com/groups?page=99>; rel="last", </groups?page=2>; rel="next"`)
+			_, _ = fmt.Fprint(w, `[{"name": "group1"}, {"name": "group2"}]`)
+		case "/groups?page=2":
+			_, _ = fmt.Fprint(w, `[{"name": "group3"}]`)
+		case "/empty":
+			_, _ = fmt.Fprint(w, `{"value": []}`)
+		case "/no-items":
+			_, _ = fmt.Fprint(w, `{"something": "else"}`)
+		case "/other-host":
+			_, _ = fmt.Fprint(w, `{"value": [], "@odata.nextLink": "https://other.example.com/memberOf?page=2"}`)
+		case "/loop":
+			_, _ = fmt.Fprint(w, `{"value": ["group1"], "@odata.nextLink": "/loop"}`)
+		default:
+			page := len(requestedURIs)
+			_, _ = fmt.Fprintf(w, `{"value": ["group%d"], "@odata.nextLink": "/forever?page=%d"}`, page, page+1)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name              string
+		groupsEndpoint    *GroupsEndpointConfig
+		userInfoClaims    string
+		noUserInfo        bool
+		wantGroups        any
+		wantRequestedURIs []string
+		wantRequestCount  int
+		wantErr           string
+	}{
+		{
+			name:              "follows next page links in the response body",
+			groupsEndpoint:    &GroupsEndpointConfig{URL: server.URL + "/memberOf", ItemsField: "value", GroupNameField: "displayName", NextPageField: "@odata.nextLink"},
+			wantGroups:        []any{"group1", "group2", "group3"},
+			wantRequestedURIs: []string{"/memberOf", "/memberOf?page=2"},
+		},
+		{
+			name:              "follows next page links in the Link header when the response is an array",
+			groupsEndpoint:    &GroupsEndpointConfig{URL: server.URL + "/groups", GroupNameField: "name", NextPageField: "@odata.nextLink"},
+			wantGroups:        []any{"group1", "group2", "group3"},
+			wantRequestedURIs: []string{"/groups", "/groups?page=2"},
+		},
+		{
+			name:              "replaces the groups claim with an empty list when the user has no groups",
+			groupsEndpoint:    &GroupsEndpointConfig{URL: server.URL + "/empty", ItemsField: "value"},
+			userInfoClaims:    `{"sub": "some-subject", "groups": ["old-group"]}`,
+			wantGroups:        []any{},
+			wantRequestedURIs: []string{"/empty"},
+		},
+		{
+			name:           "the groups endpoint is not called when there are no claims about the user",
+			groupsEndpoint: &GroupsEndpointConfig{URL: server.URL + "/memberOf", ItemsField: "value"},
+			noUserInfo:     true,
+		},
+		{
+			name:              "responses without the items field cause an error",
+			groupsEndpoint:    &GroupsEndpointConfig{URL: server.URL + "/no-items", ItemsField: "value"},
+			wantErr:           `could not fetch groups from groups endpoint: groups endpoint response does not contain an array named "value"`,
+			wantRequestedURIs: []string{"/no-items"},
+		},
+		{
+			name:              "responses which are not an array cause an error when there is no items field",
+			groupsEndpoint:    &GroupsEndpointConfig{URL: server.URL + "/no-items"},
+			wantErr:           `could not fetch groups from groups endpoint: groups endpoint response is not an array`,
+			wantRequestedURIs: []string{"/no-items"},
+		},
+		{
+			name:           "next page links to other hosts cause an error",
+			groupsEndpoint: &GroupsEndpointConfig{URL: server.URL + "/other-host", ItemsField: "value", NextPageField: "@odata.nextLink"},
+			wantErr: `could not fetch groups from groups endpoint: next page URL "https://other.example.com/memberOf?page=2" ` +
+				`of groups endpoint response must be an https URL on host "` + strings.TrimPrefix(server.URL, "https://") + `"`,
+			wantRequestedURIs: []string{"/other-host"},
+		},
+		{
+			name:           "next page links to a page which was already fetched cause an error",
+			groupsEndpoint: &GroupsEndpointConfig{URL: server.URL + "/loop", ItemsField: "value", NextPageField: "@odata.nextLink"},
+			wantErr: `could not fetch groups from groups endpoint: groups endpoint returned a link to page "` +
+				server.URL + `/loop", which was already fetched`,
+			wantRequestedURIs: []string{"/loop"},
+		},
+		{
+			name:             "too many pages cause an error",
+			groupsEndpoint:   &GroupsEndpointConfig{URL: server.URL + "/forever", ItemsField: "value", NextPageField: "@odata.nextLink"},
+			wantErr:          `could not fetch groups from groups endpoint: groups endpoint returned more than 100 pages of groups`,
+			wantRequestCount: maxGroupsEndpointPages,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestedURIs = nil
+
+			userInfoClaims := tt.userInfoClaims
+			if userInfoClaims == "" {
+				userInfoClaims = `{"sub": "some-subject"}`
+			}
+			rawProviderClaims := []byte(`{"userinfo_endpoint": "not-empty"}`)
+			if tt.noUserInfo {
+				rawProviderClaims = []byte(`{}`)
+			}
+
+			p := ProviderConfig{
+				Name:           "test-name",
+				GroupsClaim:    "groups",
+				GroupsEndpoint: tt.groupsEndpoint,
+				Config:         &oauth2.Config{ClientID: "test-client-id"},
+				Client:         server.Client(),
+				Provider: &mockProvider{
+					rawClaims: rawProviderClaims,
+					userInfo:  forceUserInfoWithClaims("some-subject", userInfoClaims),
+				},
+			}
+
+			tok, err := p.ValidateTokenAndMergeWithUserInfo(context.Background(),
+				&oauth2.Token{AccessToken: "test-access-token"}, "", false, false)
+			if tt.wantRequestCount > 0 {
+				require.Len(t, requestedURIs, tt.wantRequestCount)
+			} else {
+				require.Equal(t, tt.wantRequestedURIs, requestedURIs)
+			}
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			gotGroups, ok := tok.IDToken.Claims["groups"]
+			require.Equal(t, tt.wantGroups != nil, ok)
+			require.Equal(t, tt.wantGroups, gotGroups)
+		})
+	}
+}
+
+func TestNextLinkFromHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    string
+	}{
+		{
+			name: "no Link header",
+		},
+		{
+			name:    "one next link",
+			headers: []string{`<https://example.com/groups?page=2>; rel="next"`},
+			want:    "https://example.com/groups?page=2",
+		},
+		{
+			name:    "several links in one header",
+			headers: []string{`<https://example.com/groups?page=1>; rel="prev", <https://example.com/groups?page=3>; rel=next, <https://example.com/groups?page=9>; rel="last"`},
+			want:    "https://example.com/groups?page=3",
+		},
+		{
+			name:    "several relation types and links containing commas and semicolons",
+			headers: []string{`<https://example.com/groups?a=1,2;b=3>; title="x"; REL="prefetch next"`},
+			want:    "https://example.com/groups?a=1,2;b=3",
+		},
+		{
+			name:    "several Link headers",
+			headers: []string{`<https://example.com/groups?page=1>; rel="first"`, `<https://example.com/groups?page=2>; rel="next"`},
+			want:    "https://example.com/groups?page=2",
+		},
+		{
+			name:    "no next link",
+			headers: []string{`<https://example.com/groups?page=1>; rel="first"`, `malformed>; rel="next"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, h := range tt.headers {
+				header.Add("Link", h)
+			}
+			require.Equal(t, tt.want, nextLinkFromHeader(header))
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	oidcapi "go.pinniped.dev/generated/latest/apis/supervisor/oidc"
+	"go.pinniped.dev/internal/httputil/httperr"
+)
+
+// The allowed clock skew between the Supervisor and the upstream provider when checking the auth_time claim.
+const authTimeLeeway = time.Minute
+
+// LoginPolicy holds the requirements which the ID token from an upstream OIDC provider must satisfy during login.
+// It is not enforced during refresh, because providers do not typically repeat these claims in refreshed ID tokens.
+type LoginPolicy struct {
+	// ACRValues are the requested authentication context class references, in order of preference.
+	// When not empty, the "acr" claim must be one of these values.
+	ACRValues []string
+
+	// MaxAge is the maximum allowed elapsed time since the user last actively authenticated.
+	// When not nil, the "auth_time" claim is required and must not be older than MaxAge.
+	MaxAge *time.Duration
+
+	// RequiredAMRValues are the authentication methods references which must all be present in the "amr" claim.
+	RequiredAMRValues []string
+}
+
+// validateLoginPolicy checks that the claims of a validated ID token satisfy the login policy, if there is one.
+func (p *ProviderConfig) validateLoginPolicy(idTokenClaims map[string]any, now time.Time) error {
+	policy := p.LoginPolicy
+	if policy == nil {
+		return nil
+	}
+
+	if len(policy.ACRValues) > 0 {
+		acr := acrFromClaims(idTokenClaims)
+		if !slices.Contains(policy.ACRValues, acr) {
+			return httperr.Newf(http.StatusForbidden,
+				"ID token 'acr' claim (%q) is not one of the required values %q", acr, policy.ACRValues)
+		}
+	}
+
+	if policy.MaxAge != nil {
+		authTimeClaim, ok := idTokenClaims[oidcapi.IDTokenClaimAuthTime].(float64)
+		if !ok {
+			return httperr.New(http.StatusForbidden, "ID token is missing the 'auth_time' claim, which is required when maxAge is configured")
+		}
+		authTime := time.Unix(int64(authTimeClaim), 0)
+		if now.Sub(authTime) > *policy.MaxAge+authTimeLeeway {
+			return httperr.Newf(http.StatusForbidden,
+				"ID token 'auth_time' claim (%s) is older than the configured maxAge (%s)",
+				authTime.UTC().Format(time.RFC3339), policy.MaxAge.String())
+		}
+	}
+
+	if len(policy.RequiredAMRValues) > 0 {
+		amr := amrFromClaims(idTokenClaims)
+		for _, required := range policy.RequiredAMRValues {
+			if !slices.Contains(amr, required) {
+				return httperr.Newf(http.StatusForbidden,
+					"ID token 'amr' claim (%q) does not include the required value %q", amr, required)
+			}
+		}
+	}
+
+	return nil
+}
+
+// acrFromClaims returns the value of the "acr" claim, or the empty string when it is not present.
+func acrFromClaims(claims map[string]any) string {
+	acr, _ := claims[oidcapi.IDTokenClaimAuthenticationContextClassReference].(string)
+	return acr
+}
+
+// amrFromClaims returns the string values of the "amr" claim, or nil when it is not present. Non-string values
+// are ignored.
+func amrFromClaims(claims map[string]any) []string {
+	values, _ := claims[oidcapi.IDTokenClaimAuthenticationMethodsReferences].([]any)
+	var amr []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			amr = append(amr, s)
+		}
+	}
+	return amr
+}
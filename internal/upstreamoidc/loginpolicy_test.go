@@ -0,0 +1,187 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamoidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"k8s.io/utils/ptr"
+)
+
+func TestValidateLoginPolicy(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		policy  *LoginPolicy
+		claims  map[string]any
+		wantErr string
+	}{
+		{
+			name:   "no policy",
+			claims: map[string]any{},
+		},
+		{
+			name:   "empty policy",
+			policy: &LoginPolicy{},
+			claims: map[string]any{},
+		},
+		{
+			name:   "acr is one of the requested values",
+			policy: &LoginPolicy{ACRValues: []string{"phr", "phrh"}},
+			claims: map[string]any{"acr": "phrh"},
+		},
+		{
+			name:    "acr is not one of the requested values",
+			policy:  &LoginPolicy{ACRValues: []string{"phr", "phrh"}},
+			claims:  map[string]any{"acr": "pwd"},
+			wantErr: `ID token 'acr' claim ("pwd") is not one of the required values ["phr" "phrh"]`,
+		},
+		{
+			name:    "acr is missing",
+			policy:  &LoginPolicy{ACRValues: []string{"phr"}},
+			claims:  map[string]any{},
+			wantErr: `ID token 'acr' claim ("") is not one of the required values ["phr"]`,
+		},
+		{
+			name:    "acr is not a string",
+			policy:  &LoginPolicy{ACRValues: []string{"phr"}},
+			claims:  map[string]any{"acr": []any{"phr"}},
+			wantErr: `ID token 'acr' claim ("") is not one of the required values ["phr"]`,
+		},
+		{
+			name:   "auth_time is within max age",
+			policy: &LoginPolicy{MaxAge: ptr.To(time.Hour)},
+			claims: map[string]any{"auth_time": float64(now.Add(-time.Hour).Unix())},
+		},
+		{
+			name:   "auth_time is within max age plus leeway",
+			policy: &LoginPolicy{MaxAge: ptr.To(time.Hour)},
+			claims: map[string]any{"auth_time": float64(now.Add(-time.Hour - 30*time.Second).Unix())},
+		},
+		{
+			name:    "auth_time is older than max age",
+			policy:  &LoginPolicy{MaxAge: ptr.To(time.Hour)},
+			claims:  map[string]any{"auth_time": float64(now.Add(-2 * time.Hour).Unix())},
+			wantErr: "ID token 'auth_time' claim (2025-01-02T01:04:05Z) is older than the configured maxAge (1h0m0s)",
+		},
+		{
+			name:    "zero max age still allows the leeway",
+			policy:  &LoginPolicy{MaxAge: ptr.To(time.Duration(0))},
+			claims:  map[string]any{"auth_time": float64(now.Add(-2 * time.Minute).Unix())},
+			wantErr: "ID token 'auth_time' claim (2025-01-02T03:02:05Z) is older than the configured maxAge (0s)",
+		},
+		{
+			name:    "auth_time is missing",
+			policy:  &LoginPolicy{MaxAge: ptr.To(time.Hour)},
+			claims:  map[string]any{},
+			wantErr: "ID token is missing the 'auth_time' claim, which is required when maxAge is configured",
+		},
+		{
+			name:    "auth_time is not a number",
+			policy:  &LoginPolicy{MaxAge: ptr.To(time.Hour)},
+			claims:  map[string]any{"auth_time": "yesterday"},
+			wantErr: "ID token is missing the 'auth_time' claim, which is required when maxAge is configured",
+		},
+		{
+			name:   "amr contains all the required values",
+			policy: &LoginPolicy{RequiredAMRValues: []string{"pwd", "mfa"}},
+			claims: map[string]any{"amr": []any{"mfa", "otp", "pwd"}},
+		},
+		{
+			name:    "amr does not contain all the required values",
+			policy:  &LoginPolicy{RequiredAMRValues: []string{"pwd", "mfa"}},
+			claims:  map[string]any{"amr": []any{"pwd", 42}},
+			wantErr: `ID token 'amr' claim (["pwd"]) does not include the required value "mfa"`,
+		},
+		{
+			name:    "amr is missing",
+			policy:  &LoginPolicy{RequiredAMRValues: []string{"mfa"}},
+			claims:  map[string]any{},
+			wantErr: `ID token 'amr' claim ([]) does not include the required value "mfa"`,
+		},
+		{
+			name: "all requirements are satisfied",
+			policy: &LoginPolicy{
+				ACRValues:         []string{"phr"},
+				MaxAge:            ptr.To(5 * time.Minute),
+				RequiredAMRValues: []string{"mfa"},
+			},
+			claims: map[string]any{
+				"acr":       "phr",
+				"amr":       []any{"pwd", "mfa"},
+				"auth_time": float64(now.Unix()),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ProviderConfig{LoginPolicy: tt.policy}
+			err := p.validateLoginPolicy(tt.claims, now)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUserInfoCannotOverrideAuthenticationClaims(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	require.NoError(t, err)
+	idToken, err := jwt.Signed(signer).Claims(map[string]any{
+		"iss":       "some-issuer",
+		"sub":       "some-subject",
+		"acr":       "pwd",
+		"auth_time": 1700000000,
+	}).Serialize()
+	require.NoError(t, err)
+
+	p := ProviderConfig{
+		Config: &oauth2.Config{ClientID: "test-client-id"},
+		Provider: &mockProvider{
+			rawClaims: []byte(`{"userinfo_endpoint": "not-empty"}`),
+			userInfo: forceUserInfoWithClaims("some-subject",
+				`{"sub": "some-subject", "name": "Pinny TheSeal", "acr": "phr", "amr": ["mfa"], "auth_time": 1800000000}`),
+		},
+		LoginPolicy: &LoginPolicy{RequiredAMRValues: []string{"mfa"}},
+	}
+
+	tok := (&oauth2.Token{AccessToken: "test-access-token"}).WithExtra(map[string]any{"id_token": idToken})
+	got, err := p.ValidateTokenAndMergeWithUserInfo(context.Background(), tok, "", true, false)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"iss":       "some-issuer",
+		"sub":       "some-subject",
+		"name":      "Pinny TheSeal",
+		"acr":       "pwd",
+		"auth_time": float64(1700000000),
+	}, got.IDToken.Claims)
+
+	// The amr claim from the userinfo response does not satisfy the login policy.
+	require.EqualError(t, p.validateLoginPolicy(got.IDToken.Claims, time.Now()),
+		`ID token 'amr' claim ([]) does not include the required value "mfa"`)
+}
+
+func TestAuthenticationContextClaims(t *testing.T) {
+	claims := map[string]any{"acr": "phr", "amr": []any{"pwd", 1, "mfa"}}
+	require.Equal(t, "phr", acrFromClaims(claims))
+	require.Equal(t, []string{"pwd", "mfa"}, amrFromClaims(claims))
+
+	require.Empty(t, acrFromClaims(map[string]any{}))
+	require.Nil(t, amrFromClaims(map[string]any{}))
+	require.Empty(t, acrFromClaims(map[string]any{"acr": 42}))
+	require.Nil(t, amrFromClaims(map[string]any{"amr": "mfa"}))
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package upstreamoidc implements an abstraction of upstream OIDC provider interactions.
@@ -30,23 +30,36 @@ import (
 	"go.pinniped.dev/pkg/oidcclient/pkce"
 )
 
+// idTokenOnlyClaimNames are the claims which are never taken from the userinfo response.
+var idTokenOnlyClaimNames = []string{ //nolint:gochecknoglobals
+	oidcapi.IDTokenClaimIssuer,
+	oidcapi.IDTokenClaimAuthTime,
+	oidcapi.IDTokenClaimAuthenticationContextClassReference,
+	oidcapi.IDTokenClaimAuthenticationMethodsReferences,
+}
+
 func New(config *oauth2.Config, provider *coreosoidc.Provider, client *http.Client) upstreamprovider.UpstreamOIDCIdentityProviderI {
 	return &ProviderConfig{Config: config, Provider: provider, Client: client}
 }
 
 // ProviderConfig holds the active configuration of an upstream OIDC provider.
 type ProviderConfig struct {
-	Name                     string
-	ResourceUID              types.UID
-	UsernameClaim            string
-	GroupsClaim              string
-	Config                   *oauth2.Config
-	Client                   *http.Client
-	AllowPasswordGrant       bool
-	AdditionalAuthcodeParams map[string]string
-	AdditionalClaimMappings  map[string]string
-	RevocationURL            *url.URL // will commonly be nil: many providers do not offer this
-	Provider                 interface {
+	Name                          string
+	ResourceUID                   types.UID
+	UsernameClaim                 string
+	GroupsClaim                   string
+	Config                        *oauth2.Config
+	Client                        *http.Client
+	AllowPasswordGrant            bool
+	AdditionalAuthcodeParams      map[string]string
+	AdditionalClaimMappings       map[string]string
+	GroupsEndpoint                *GroupsEndpointConfig  // nil when the groups endpoint is not configured
+	ClientAssertionSigner         *ClientAssertionSigner // nil unless the private_key_jwt client authentication method is used
+	RequestObjectSigner           *ClientAssertionSigner // nil unless authorization requests are sent as signed request objects
+	PushedAuthorizationRequestURL *url.URL               // nil unless authorization requests are pushed to the provider
+	LoginPolicy                   *LoginPolicy           // nil when no login policy is configured
+	RevocationURL                 *url.URL               // will commonly be nil: many providers do not offer this
+	Provider                      interface {
 		Verifier(*coreosoidc.Config) *coreosoidc.IDTokenVerifier
 		Claims(v any) error
 		UserInfo(ctx context.Context, tokenSource oauth2.TokenSource) (*coreosoidc.UserInfo, error)
@@ -54,6 +67,7 @@ type ProviderConfig struct {
 }
 
 var _ upstreamprovider.UpstreamOIDCIdentityProviderI = (*ProviderConfig)(nil)
+var _ upstreamprovider.UpstreamHealthProberI = (*ProviderConfig)(nil)
 
 func (p *ProviderConfig) GetResourceUID() types.UID {
 	return p.ResourceUID
@@ -121,7 +135,7 @@ func (p *ProviderConfig) PasswordCredentialsGrantAndValidateTokens(ctx context.C
 
 	// Note that this implicitly uses the scopes from p.Config.Scopes.
 	tok, err := p.Config.PasswordCredentialsToken(
-		coreosoidc.ClientContext(ctx, p.Client),
+		coreosoidc.ClientContext(ctx, p.tokenEndpointClient()),
 		username,
 		password,
 	)
@@ -132,12 +146,12 @@ func (p *ProviderConfig) PasswordCredentialsGrantAndValidateTokens(ctx context.C
 	// There is no nonce to validate for a resource owner password credentials grant because it skips using
 	// the authorize endpoint and goes straight to the token endpoint.
 	const skipNonceValidation nonce.Nonce = ""
-	return p.ValidateTokenAndMergeWithUserInfo(ctx, tok, skipNonceValidation, true, false)
+	return p.validateLoginTokens(ctx, tok, skipNonceValidation)
 }
 
 func (p *ProviderConfig) ExchangeAuthcodeAndValidateTokens(ctx context.Context, authcode string, pkceCodeVerifier pkce.Code, expectedIDTokenNonce nonce.Nonce, redirectURI string) (*oidctypes.Token, error) {
 	tok, err := p.Config.Exchange(
-		coreosoidc.ClientContext(ctx, p.Client),
+		coreosoidc.ClientContext(ctx, p.tokenEndpointClient()),
 		authcode,
 		pkceCodeVerifier.Verifier(),
 		oauth2.SetAuthURLParam("redirect_uri", redirectURI),
@@ -146,12 +160,25 @@ func (p *ProviderConfig) ExchangeAuthcodeAndValidateTokens(ctx context.Context,
 		return nil, err
 	}
 
-	return p.ValidateTokenAndMergeWithUserInfo(ctx, tok, expectedIDTokenNonce, true, false)
+	return p.validateLoginTokens(ctx, tok, expectedIDTokenNonce)
+}
+
+// validateLoginTokens validates the tokens returned by a login, including checking that the ID token satisfies
+// the login policy.
+func (p *ProviderConfig) validateLoginTokens(ctx context.Context, tok *oauth2.Token, expectedIDTokenNonce nonce.Nonce) (*oidctypes.Token, error) {
+	token, err := p.ValidateTokenAndMergeWithUserInfo(ctx, tok, expectedIDTokenNonce, true, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.validateLoginPolicy(token.IDToken.Claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return token, nil
 }
 
 func (p *ProviderConfig) PerformRefresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
 	// Use the provided HTTP client to benefit from its CA, proxy, and other settings.
-	httpClientContext := coreosoidc.ClientContext(ctx, p.Client)
+	httpClientContext := coreosoidc.ClientContext(ctx, p.tokenEndpointClient())
 	// Create a TokenSource without an access token, so it thinks that a refresh is immediately required.
 	// Then ask it for the tokens to cause it to perform the refresh and return the results.
 	return p.Config.TokenSource(httpClientContext, &oauth2.Token{RefreshToken: refreshToken}).Token()
@@ -171,7 +198,7 @@ func (p *ProviderConfig) RevokeToken(ctx context.Context, token string, tokenTyp
 	}
 	// First try using client auth in the request params.
 	tryAnotherClientAuthMethod, err := p.tryRevokeToken(ctx, token, tokenType, false)
-	if tryAnotherClientAuthMethod {
+	if tryAnotherClientAuthMethod && p.usesClientSecret() {
 		// Try again using basic auth this time. Overwrite the first client auth error,
 		// which isn't useful anymore when retrying.
 		_, err = p.tryRevokeToken(ctx, token, tokenType, true)
@@ -179,6 +206,41 @@ func (p *ProviderConfig) RevokeToken(ctx context.Context, token string, tokenTyp
 	return err
 }
 
+// ProbeHealth checks that the provider is reachable by fetching the OpenID Provider Configuration document
+// from its issuer. Implements upstreamprovider.UpstreamHealthProberI.
+func (p *ProviderConfig) ProbeHealth(ctx context.Context) error {
+	var discoveryClaims struct {
+		Issuer string `json:"issuer"`
+	}
+	if err := p.Provider.Claims(&discoveryClaims); err != nil {
+		return fmt.Errorf("could not read issuer from discovery document: %w", err)
+	}
+	discoveryURL := strings.TrimSuffix(discoveryClaims.Issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return err
+	}
+	// Use the provided HTTP client to benefit from its CA, proxy, and other settings.
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status %q from %s", resp.Status, discoveryURL)
+	}
+	return nil
+}
+
+// usesClientSecret returns true when the client authenticates to the upstream provider using a client secret,
+// as opposed to using a client assertion or a client certificate.
+func (p *ProviderConfig) usesClientSecret() bool {
+	return p.ClientAssertionSigner == nil && p.Config.ClientSecret != ""
+}
+
 // tryRevokeToken will call the revocation endpoint using either basic auth or by including
 // client auth in the request params. It will return an error when the request failed. If the
 // request failed for a reason that might be due to bad client auth, then it will return true
@@ -203,7 +265,14 @@ func (p *ProviderConfig) tryRevokeToken(
 	}
 	if !useBasicAuth {
 		params["client_id"] = []string{clientID}
-		params["client_secret"] = []string{clientSecret}
+		if clientSecret != "" {
+			params["client_secret"] = []string{clientSecret}
+		}
+		if p.ClientAssertionSigner != nil {
+			if err := p.ClientAssertionSigner.addTo(params, clientID, p.RevocationURL.String()); err != nil {
+				return false, err
+			}
+		}
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.RevocationURL.String(), strings.NewReader(params.Encode()))
@@ -280,7 +349,8 @@ func (p *ProviderConfig) tryRevokeToken(
 }
 
 // ValidateTokenAndMergeWithUserInfo will validate the ID token. It will also merge the claims from the userinfo endpoint response,
-// if the provider offers the userinfo endpoint.
+// if the provider offers the userinfo endpoint. Distributed and aggregated claims which are needed to determine the identity
+// of the user are resolved, and the groups are fetched from the groups endpoint, if it is configured.
 func (p *ProviderConfig) ValidateTokenAndMergeWithUserInfo(ctx context.Context, tok *oauth2.Token, expectedIDTokenNonce nonce.Nonce, requireIDToken bool, requireUserInfo bool) (*oidctypes.Token, error) {
 	var validatedClaims = make(map[string]any)
 
@@ -302,6 +372,18 @@ func (p *ProviderConfig) ValidateTokenAndMergeWithUserInfo(ctx context.Context,
 		}
 	}
 
+	if err := p.resolveDistributedClaims(ctx, tok, validatedClaims); err != nil {
+		return nil, httperr.Wrap(http.StatusInternalServerError, "could not resolve distributed claims", err)
+	}
+
+	// Only fetch groups when there are claims about the user, so that callers can continue to assume that the
+	// claims are empty when there was neither an ID token nor a userinfo response.
+	if len(validatedClaims) > 0 {
+		if err := p.maybeFetchGroupsAndMergeClaims(ctx, tok, validatedClaims); err != nil {
+			return nil, httperr.Wrap(http.StatusInternalServerError, "could not fetch groups from groups endpoint", err)
+		}
+	}
+
 	return &oidctypes.Token{
 		AccessToken: &oidctypes.AccessToken{
 			Token:  tok.AccessToken,
@@ -376,8 +458,13 @@ func (p *ProviderConfig) maybeFetchUserInfoAndMergeClaims(ctx context.Context, t
 		return httperr.Newf(http.StatusUnprocessableEntity, "userinfo 'sub' claim (%s) did not match id_token 'sub' claim (%s)", userInfo.Subject, idTokenSubject)
 	}
 
-	// keep track of the issuer from the ID token
-	idTokenIssuer := claims[oidcapi.IDTokenClaimIssuer]
+	// keep track of the issuer and the claims describing the authentication from the ID token
+	idTokenOnlyClaims := map[string]any{}
+	for _, claim := range idTokenOnlyClaimNames {
+		if value, ok := claims[claim]; ok {
+			idTokenOnlyClaims[claim] = value
+		}
+	}
 
 	// merge existing claims with user info claims
 	if err := userInfo.Claims(&claims); err != nil {
@@ -387,9 +474,13 @@ func (p *ProviderConfig) maybeFetchUserInfoAndMergeClaims(ctx context.Context, t
 	//  "If signed, the UserInfo Response SHOULD contain the Claims iss (issuer) and aud (audience) as members. The iss value SHOULD be the OP's Issuer Identifier URL."
 	//  See https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
 	//  So we just ignore it and use it the version from the id token, which has stronger guarantees.
-	delete(claims, oidcapi.IDTokenClaimIssuer)
-	if idTokenIssuer != nil {
-		claims[oidcapi.IDTokenClaimIssuer] = idTokenIssuer
+	//  Similarly, the claims which describe how the user authenticated are only trusted from the id token, since
+	//  they may be enforced by the login policy.
+	for _, claim := range idTokenOnlyClaimNames {
+		delete(claims, claim)
+		if value, ok := idTokenOnlyClaims[claim]; ok {
+			claims[claim] = value
+		}
 	}
 
 	maybeLogClaims("claims from ID token and userinfo", p.Name, claims)
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package upstreamoidc
@@ -102,6 +102,7 @@ func TestProviderConfig(t *testing.T) {
 		tests := []struct {
 			name                  string
 			disallowPasswordGrant bool
+			loginPolicy           *LoginPolicy
 			returnIDTok           string
 			tokenStatusCode       int
 			wantErr               string
@@ -194,6 +195,13 @@ func TestProviderConfig(t *testing.T) {
 				returnIDTok: invalidAccessTokenHashIDToken,
 				wantErr:     "received invalid ID token: access token hash does not match value in ID token",
 			},
+			{
+				name:        "ID token does not satisfy the login policy",
+				returnIDTok: validIDToken,
+				loginPolicy: &LoginPolicy{RequiredAMRValues: []string{"mfa"}},
+				rawClaims:   []byte(`{}`), // user info not supported
+				wantErr:     `ID token 'amr' claim ([]) does not include the required value "mfa"`,
+			},
 			{
 				name:        "user info fetch error",
 				returnIDTok: validIDToken,
@@ -295,6 +303,7 @@ func TestProviderConfig(t *testing.T) {
 						userInfoErr: tt.userInfoErr,
 					},
 					AllowPasswordGrant: !tt.disallowPasswordGrant,
+					LoginPolicy:        tt.loginPolicy,
 					Client:             http.DefaultClient,
 				}
 
@@ -725,6 +734,61 @@ func TestProviderConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("ProbeHealth", func(t *testing.T) {
+		tests := []struct {
+			name              string
+			returnStatusCode  int
+			unreachableServer bool
+			wantErr           testutil.RequireErrorStringFunc
+		}{
+			{
+				name:             "success when the discovery endpoint returns 200 OK",
+				returnStatusCode: http.StatusOK,
+			},
+			{
+				name:             "error when the discovery endpoint returns an error status",
+				returnStatusCode: http.StatusBadGateway,
+				wantErr:          testutil.WantMatchingErrorString(`^unexpected response status "502 Bad Gateway" from http://.+/some/path/.well-known/openid-configuration$`),
+			},
+			{
+				name:              "error when the server is unreachable",
+				unreachableServer: true,
+				wantErr:           testutil.WantMatchingErrorString(`^Get "http://.+/some/path/.well-known/openid-configuration": dial tcp .+: connect: connection refused$`),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				numRequests := 0
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					numRequests++
+					require.Equal(t, http.MethodGet, r.Method)
+					require.Equal(t, "/some/path/.well-known/openid-configuration", r.URL.Path)
+					w.WriteHeader(tt.returnStatusCode)
+				}))
+				t.Cleanup(server.Close)
+
+				p := ProviderConfig{
+					Name:     "test-name",
+					Provider: &mockProvider{rawClaims: []byte(fmt.Sprintf(`{"issuer": "%s/some/path/"}`, server.URL))},
+					Client:   http.DefaultClient,
+				}
+
+				if tt.unreachableServer {
+					server.Close() // make the sever unreachable by closing it before making any requests
+				}
+
+				err := p.ProbeHealth(context.Background())
+
+				if tt.wantErr != nil {
+					testutil.RequireErrorStringFromErr(t, err, tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, 1, numRequests)
+			})
+		}
+	})
+
 	t.Run("ValidateTokenAndMergeWithUserInfo", func(t *testing.T) {
 		expiryTime := time.Now().Add(42 * time.Second)
 		testTokenWithoutIDToken := &oauth2.Token{
@@ -1122,6 +1186,7 @@ func TestProviderConfig(t *testing.T) {
 			name        string
 			authCode    string
 			expectNonce nonce.Nonce
+			loginPolicy *LoginPolicy
 			returnIDTok string
 			wantErr     string
 			wantToken   oidctypes.Token
@@ -1248,6 +1313,14 @@ func TestProviderConfig(t *testing.T) {
 				rawClaims:          []byte(`{}`), // user info not supported
 				wantUserInfoCalled: false,
 			},
+			{
+				name:        "ID token does not satisfy the login policy",
+				authCode:    "valid",
+				returnIDTok: validIDToken,
+				loginPolicy: &LoginPolicy{ACRValues: []string{"phr", "phrh"}},
+				rawClaims:   []byte(`{}`), // user info not supported
+				wantErr:     `ID token 'acr' claim ("") is not one of the required values ["phr" "phrh"]`,
+			},
 			{
 				name:        "user info fetch error",
 				authCode:    "valid",
@@ -1383,7 +1456,8 @@ func TestProviderConfig(t *testing.T) {
 						userInfo:    tt.userInfo,
 						userInfoErr: tt.userInfoErr,
 					},
-					Client: http.DefaultClient,
+					LoginPolicy: tt.loginPolicy,
+					Client:      http.DefaultClient,
 				}
 
 				tok, err := p.ExchangeAuthcodeAndValidateTokens(
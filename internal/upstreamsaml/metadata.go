@@ -0,0 +1,143 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamsaml
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	metadataNS           = "urn:oasis:names:tc:SAML:2.0:metadata"
+	protocolSupportEnum  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	httpRedirectBinding  = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	keyDescriptorSigning = "signing"
+)
+
+// IdentityProviderMetadata is the information from an identity provider's SAML 2.0 metadata document
+// which is needed to act as a service provider for that identity provider.
+type IdentityProviderMetadata struct {
+	// EntityID is the identity provider's entity ID, which must be the Issuer of its assertions.
+	EntityID string
+
+	// SingleSignOnURL is the location of the identity provider's single sign-on service which
+	// uses the HTTP-Redirect binding.
+	SingleSignOnURL *url.URL
+
+	// SigningCertificates are the certificates which may be used to sign responses and assertions.
+	SigningCertificates []*x509.Certificate
+}
+
+type entityDescriptorXML struct {
+	EntityID          string                `xml:"entityID,attr"`
+	IDPSSODescriptors []idpSSODescriptorXML `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+}
+
+type entitiesDescriptorXML struct {
+	EntityDescriptors []entityDescriptorXML `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+}
+
+type idpSSODescriptorXML struct {
+	ProtocolSupportEnumeration string                   `xml:"protocolSupportEnumeration,attr"`
+	KeyDescriptors             []keyDescriptorXML       `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+	SingleSignOnServices       []singleSignOnServiceXML `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+}
+
+type keyDescriptorXML struct {
+	Use              string   `xml:"use,attr"`
+	X509Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+}
+
+type singleSignOnServiceXML struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+// ParseIdentityProviderMetadata parses and validates an identity provider's SAML 2.0 metadata document.
+// The document must describe exactly one identity provider, either as its root EntityDescriptor element,
+// or as the only EntityDescriptor in its root EntitiesDescriptor element.
+func ParseIdentityProviderMetadata(data []byte) (*IdentityProviderMetadata, error) {
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var entity entityDescriptorXML
+	switch {
+	case isElement(root, metadataNS, "EntityDescriptor"):
+		if err := xml.Unmarshal(data, &entity); err != nil {
+			return nil, fmt.Errorf("could not parse metadata: %w", err)
+		}
+	case isElement(root, metadataNS, "EntitiesDescriptor"):
+		var entities entitiesDescriptorXML
+		if err := xml.Unmarshal(data, &entities); err != nil {
+			return nil, fmt.Errorf("could not parse metadata: %w", err)
+		}
+		if len(entities.EntityDescriptors) != 1 {
+			return nil, fmt.Errorf("metadata must contain exactly one EntityDescriptor, but found %d", len(entities.EntityDescriptors))
+		}
+		entity = entities.EntityDescriptors[0]
+	default:
+		return nil, errors.New("metadata root element must be an EntityDescriptor or EntitiesDescriptor")
+	}
+
+	if entity.EntityID == "" {
+		return nil, errors.New("metadata EntityDescriptor must have an entityID")
+	}
+
+	var idpDescriptor *idpSSODescriptorXML
+	for i := range entity.IDPSSODescriptors {
+		if strings.Contains(entity.IDPSSODescriptors[i].ProtocolSupportEnumeration, protocolSupportEnum) {
+			idpDescriptor = &entity.IDPSSODescriptors[i]
+			break
+		}
+	}
+	if idpDescriptor == nil {
+		return nil, errors.New("metadata must contain an IDPSSODescriptor which supports the SAML 2.0 protocol")
+	}
+
+	metadata := &IdentityProviderMetadata{EntityID: entity.EntityID}
+
+	for _, sso := range idpDescriptor.SingleSignOnServices {
+		if sso.Binding != httpRedirectBinding {
+			continue
+		}
+		ssoURL, err := url.Parse(sso.Location)
+		if err != nil || ssoURL.Scheme != "https" || ssoURL.Host == "" {
+			return nil, fmt.Errorf("metadata SingleSignOnService location %q must be an https URL", sso.Location)
+		}
+		metadata.SingleSignOnURL = ssoURL
+		break
+	}
+	if metadata.SingleSignOnURL == nil {
+		return nil, errors.New("metadata must contain a SingleSignOnService which uses the HTTP-Redirect binding")
+	}
+
+	for _, key := range idpDescriptor.KeyDescriptors {
+		if key.Use != "" && key.Use != keyDescriptorSigning {
+			continue
+		}
+		for _, encodedCert := range key.X509Certificates {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encodedCert), ""))
+			if err != nil {
+				return nil, fmt.Errorf("could not decode metadata signing certificate: %w", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse metadata signing certificate: %w", err)
+			}
+			metadata.SigningCertificates = append(metadata.SigningCertificates, cert)
+		}
+	}
+	if len(metadata.SigningCertificates) == 0 {
+		return nil, errors.New("metadata must contain at least one signing certificate")
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,323 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamsaml
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	protocolNS         = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNS        = "urn:oasis:names:tc:SAML:2.0:assertion"
+	statusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerConfirmation = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	samlVersion        = "2.0"
+
+	// allowedClockSkew is the tolerance used when comparing the validity times of assertions to the current time.
+	allowedClockSkew = 2 * time.Minute
+)
+
+// assertion is the information from a validated SAML assertion.
+type assertion struct {
+	issuer              string
+	nameID              string
+	attributes          map[string][]string
+	sessionNotOnOrAfter time.Time
+}
+
+type responseExpectations struct {
+	requestID                   string
+	serviceProviderEntityID     string
+	assertionConsumerServiceURL string
+	now                         time.Time
+}
+
+// validateResponse decodes and validates a base64-encoded SAML Response which was sent using the HTTP-POST binding,
+// and returns the information from its assertion. Either the response or the assertion (or both) must be signed
+// by one of the identity provider's signing certificates.
+func validateResponse(encodedResponse string, metadata *IdentityProviderMetadata, expected *responseExpectations) (*assertion, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encodedResponse), ""))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode response: %w", err)
+	}
+
+	response, err := parseXML(decoded)
+	if err != nil {
+		return nil, err
+	}
+	if !isElement(response, protocolNS, "Response") {
+		return nil, errors.New("root element is not a Response")
+	}
+
+	// A signature references the signed element by its ID, so every ID must be unique to avoid signature
+	// wrapping attacks, in which the signed element is moved elsewhere and replaced by an unsigned element.
+	if err := requireUniqueIDs(response); err != nil {
+		return nil, err
+	}
+
+	// Only read the verified copies of the signed elements from here on, because the original elements may
+	// contain content which was not covered by their signatures.
+	response, assertionElement, err := verifySignatures(response, metadata, expected.now)
+	if err != nil {
+		return nil, err
+	}
+
+	if attr(response, "Version") != samlVersion {
+		return nil, fmt.Errorf("unsupported response version %q", attr(response, "Version"))
+	}
+	if destination := attr(response, "Destination"); destination != "" && destination != expected.assertionConsumerServiceURL {
+		return nil, fmt.Errorf("response destination %q does not match %q", destination, expected.assertionConsumerServiceURL)
+	}
+	if inResponseTo := attr(response, "InResponseTo"); inResponseTo != expected.requestID {
+		return nil, fmt.Errorf("response is not in response to the expected request: InResponseTo is %q", inResponseTo)
+	}
+	if issuer := child(response, assertionNS, "Issuer"); issuer != nil && strings.TrimSpace(text(issuer)) != metadata.EntityID {
+		return nil, fmt.Errorf("response issuer %q does not match %q", strings.TrimSpace(text(issuer)), metadata.EntityID)
+	}
+
+	if err := validateStatus(response); err != nil {
+		return nil, err
+	}
+
+	return validateAssertion(assertionElement, metadata, expected)
+}
+
+func requireUniqueIDs(root *etree.Element) error {
+	ids := map[string]bool{}
+	var err error
+	walk(root, func(e *etree.Element) {
+		id := attr(e, "ID")
+		if id == "" || err != nil {
+			return
+		}
+		if ids[id] {
+			err = fmt.Errorf("duplicate ID %q", id)
+		}
+		ids[id] = true
+	})
+	return err
+}
+
+func validateStatus(response *etree.Element) error {
+	status := child(response, protocolNS, "Status")
+	if status == nil {
+		return errors.New("response must have a Status")
+	}
+	statusCode := child(status, protocolNS, "StatusCode")
+	if statusCode == nil {
+		return errors.New("response must have a StatusCode")
+	}
+	if attr(statusCode, "Value") == statusSuccess {
+		return nil
+	}
+	msg := fmt.Sprintf("response status is %q", attr(statusCode, "Value"))
+	if subStatusCode := child(statusCode, protocolNS, "StatusCode"); subStatusCode != nil {
+		msg += fmt.Sprintf(" with second-level status %q", attr(subStatusCode, "Value"))
+	}
+	if statusMessage := child(status, protocolNS, "StatusMessage"); statusMessage != nil {
+		msg += fmt.Sprintf(" and message %q", text(statusMessage))
+	}
+	return errors.New(msg)
+}
+
+// verifySignatures verifies the signatures of the response and of its assertion, of which at least one must be
+// signed, and returns the verified versions of the response and of its assertion.
+func verifySignatures(response *etree.Element, metadata *IdentityProviderMetadata, now time.Time) (*etree.Element, *etree.Element, error) {
+	responseSigned, err := hasSignature(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	if responseSigned {
+		response, err = verifyEnvelopedSignature(response, metadata.SigningCertificates, now)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid response signature: %w", err)
+		}
+	}
+
+	if len(childElements(response, assertionNS, "EncryptedAssertion")) > 0 {
+		return nil, nil, errors.New("encrypted assertions are not supported")
+	}
+	// Only one assertion is allowed, so that an unsigned assertion cannot be smuggled in next to a signed one.
+	assertions := childElements(response, assertionNS, "Assertion")
+	if len(assertions) != 1 {
+		return nil, nil, fmt.Errorf("response must contain exactly one assertion, but had %d", len(assertions))
+	}
+	assertionElement := assertions[0]
+
+	assertionSigned, err := hasSignature(assertionElement)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !responseSigned && !assertionSigned {
+		return nil, nil, errors.New("neither the response nor the assertion is signed")
+	}
+	if assertionSigned {
+		assertionElement, err = verifyEnvelopedSignature(assertionElement, metadata.SigningCertificates, now)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid assertion signature: %w", err)
+		}
+	}
+
+	return response, assertionElement, nil
+}
+
+func validateAssertion(a *etree.Element, metadata *IdentityProviderMetadata, expected *responseExpectations) (*assertion, error) {
+	if attr(a, "Version") != samlVersion {
+		return nil, fmt.Errorf("unsupported assertion version %q", attr(a, "Version"))
+	}
+
+	issuer := child(a, assertionNS, "Issuer")
+	if issuer == nil || strings.TrimSpace(text(issuer)) != metadata.EntityID {
+		return nil, fmt.Errorf("assertion issuer does not match %q", metadata.EntityID)
+	}
+
+	subject := child(a, assertionNS, "Subject")
+	if subject == nil {
+		return nil, errors.New("assertion must have a Subject")
+	}
+	nameID := child(subject, assertionNS, "NameID")
+	if nameID == nil || strings.TrimSpace(text(nameID)) == "" {
+		return nil, errors.New("assertion subject must have a NameID")
+	}
+	if err := validateSubjectConfirmation(subject, expected); err != nil {
+		return nil, err
+	}
+
+	if err := validateConditions(a, expected); err != nil {
+		return nil, err
+	}
+
+	authnStatements := childElements(a, assertionNS, "AuthnStatement")
+	if len(authnStatements) == 0 {
+		return nil, errors.New("assertion must have an AuthnStatement")
+	}
+	result := &assertion{
+		issuer:     metadata.EntityID,
+		nameID:     strings.TrimSpace(text(nameID)),
+		attributes: map[string][]string{},
+	}
+	if sessionNotOnOrAfter := attr(authnStatements[0], "SessionNotOnOrAfter"); sessionNotOnOrAfter != "" {
+		t, err := parseTime(sessionNotOnOrAfter)
+		if err != nil {
+			return nil, err
+		}
+		if !expected.now.Before(t.Add(allowedClockSkew)) {
+			return nil, errors.New("assertion session has expired")
+		}
+		result.sessionNotOnOrAfter = t
+	}
+
+	for _, statement := range childElements(a, assertionNS, "AttributeStatement") {
+		for _, attribute := range childElements(statement, assertionNS, "Attribute") {
+			name := attr(attribute, "Name")
+			for _, value := range childElements(attribute, assertionNS, "AttributeValue") {
+				result.attributes[name] = append(result.attributes[name], text(value))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// validateSubjectConfirmation requires at least one bearer subject confirmation which is valid for this response,
+// as described by the Web Browser SSO Profile in https://docs.oasis-open.org/security/saml/v2.0/saml-profiles-2.0-os.pdf.
+func validateSubjectConfirmation(subject *etree.Element, expected *responseExpectations) error {
+	var lastErr error
+	for _, confirmation := range childElements(subject, assertionNS, "SubjectConfirmation") {
+		if attr(confirmation, "Method") != bearerConfirmation {
+			continue
+		}
+		data := child(confirmation, assertionNS, "SubjectConfirmationData")
+		if data == nil {
+			lastErr = errors.New("bearer subject confirmation must have SubjectConfirmationData")
+			continue
+		}
+		if recipient := attr(data, "Recipient"); recipient != expected.assertionConsumerServiceURL {
+			lastErr = fmt.Errorf("subject confirmation recipient %q does not match %q", recipient, expected.assertionConsumerServiceURL)
+			continue
+		}
+		if inResponseTo := attr(data, "InResponseTo"); inResponseTo != "" && inResponseTo != expected.requestID {
+			lastErr = errors.New("subject confirmation is not in response to the expected request")
+			continue
+		}
+		if err := validateTimeRange(attr(data, "NotBefore"), attr(data, "NotOnOrAfter"), true, expected.now); err != nil {
+			lastErr = fmt.Errorf("subject confirmation: %w", err)
+			continue
+		}
+		return nil
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return errors.New("assertion subject must have a bearer subject confirmation")
+}
+
+func validateConditions(a *etree.Element, expected *responseExpectations) error {
+	conditions := child(a, assertionNS, "Conditions")
+	if conditions == nil {
+		return errors.New("assertion must have Conditions")
+	}
+	if err := validateTimeRange(attr(conditions, "NotBefore"), attr(conditions, "NotOnOrAfter"), false, expected.now); err != nil {
+		return fmt.Errorf("assertion conditions: %w", err)
+	}
+
+	audienceRestrictions := childElements(conditions, assertionNS, "AudienceRestriction")
+	if len(audienceRestrictions) == 0 {
+		return errors.New("assertion conditions must have an AudienceRestriction")
+	}
+	// Every audience restriction must be satisfied.
+	for _, restriction := range audienceRestrictions {
+		found := false
+		for _, audience := range childElements(restriction, assertionNS, "Audience") {
+			if strings.TrimSpace(text(audience)) == expected.serviceProviderEntityID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("assertion audience restriction does not include %q", expected.serviceProviderEntityID)
+		}
+	}
+	return nil
+}
+
+func validateTimeRange(notBefore, notOnOrAfter string, requireNotOnOrAfter bool, now time.Time) error {
+	if notBefore != "" {
+		t, err := parseTime(notBefore)
+		if err != nil {
+			return err
+		}
+		if now.Add(allowedClockSkew).Before(t) {
+			return errors.New("not yet valid")
+		}
+	}
+	if notOnOrAfter == "" {
+		if requireNotOnOrAfter {
+			return errors.New("NotOnOrAfter is required")
+		}
+		return nil
+	}
+	t, err := parseTime(notOnOrAfter)
+	if err != nil {
+		return err
+	}
+	if !now.Before(t.Add(allowedClockSkew)) {
+		return errors.New("expired")
+	}
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse time %q: %w", s, err)
+	}
+	return t, nil
+}
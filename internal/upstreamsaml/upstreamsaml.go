@@ -0,0 +1,180 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package upstreamsaml implements an abstraction of upstream SAML 2.0 identity provider interactions,
+// where the Supervisor acts as a SAML service provider using the Web Browser SSO Profile.
+package upstreamsaml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"go.pinniped.dev/internal/federationdomain/downstreamsubject"
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+)
+
+const httpPostBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+// ProviderConfig holds the active configuration of an upstream SAML provider.
+type ProviderConfig struct {
+	Name        string
+	ResourceUID types.UID
+
+	// Metadata is the validated metadata of the identity provider.
+	Metadata *IdentityProviderMetadata
+
+	// ServiceProviderEntityID is the configured entity ID of the Supervisor. When empty, the issuer
+	// of each FederationDomain is used.
+	ServiceProviderEntityID string
+
+	// UsernameAttribute is the name of the attribute to use as the username. When empty, the NameID is used.
+	UsernameAttribute string
+
+	// GroupsAttribute is the name of the attribute to use as the group names. When empty, there are no groups.
+	GroupsAttribute string
+}
+
+type Provider struct {
+	c     ProviderConfig
+	clock func() time.Time
+}
+
+var _ upstreamprovider.UpstreamSAMLIdentityProviderI = &Provider{}
+
+// New creates a Provider. The config is not a pointer to ensure that a copy of the config is created,
+// making the resulting Provider use an effectively read-only configuration.
+func New(config ProviderConfig) *Provider {
+	return &Provider{c: config, clock: time.Now}
+}
+
+func (p *Provider) GetResourceName() string {
+	return p.c.Name
+}
+
+func (p *Provider) GetResourceUID() types.UID {
+	return p.c.ResourceUID
+}
+
+func (p *Provider) GetServiceProviderEntityID() string {
+	return p.c.ServiceProviderEntityID
+}
+
+func (p *Provider) GetUsernameAttribute() string {
+	return p.c.UsernameAttribute
+}
+
+func (p *Provider) GetGroupsAttribute() string {
+	return p.c.GroupsAttribute
+}
+
+type authnRequestXML struct {
+	XMLName                     xml.Name  `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string    `xml:"ID,attr"`
+	Version                     string    `xml:"Version,attr"`
+	IssueInstant                string    `xml:"IssueInstant,attr"`
+	Destination                 string    `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string    `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string    `xml:"ProtocolBinding,attr"`
+	Issuer                      issuerXML `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+}
+
+type issuerXML struct {
+	Value string `xml:",chardata"`
+}
+
+// AuthnRequestURL returns the URL of the identity provider's single sign-on service with an AuthnRequest which
+// is encoded using the HTTP-Redirect binding, as described in
+// https://docs.oasis-open.org/security/saml/v2.0/saml-bindings-2.0-os.pdf. The request is not signed.
+func (p *Provider) AuthnRequestURL(requestID string, serviceProviderEntityID string, assertionConsumerServiceURL string, relayState string) (string, error) {
+	request, err := xml.Marshal(&authnRequestXML{
+		ID:                          requestID,
+		Version:                     samlVersion,
+		IssueInstant:                p.clock().UTC().Format(time.RFC3339),
+		Destination:                 p.c.Metadata.SingleSignOnURL.String(),
+		AssertionConsumerServiceURL: assertionConsumerServiceURL,
+		ProtocolBinding:             httpPostBinding,
+		Issuer:                      issuerXML{Value: serviceProviderEntityID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not encode AuthnRequest: %w", err)
+	}
+
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		return "", fmt.Errorf("could not encode AuthnRequest: %w", err)
+	}
+	if _, err = writer.Write(request); err != nil {
+		return "", fmt.Errorf("could not encode AuthnRequest: %w", err)
+	}
+	if err = writer.Close(); err != nil {
+		return "", fmt.Errorf("could not encode AuthnRequest: %w", err)
+	}
+
+	redirectURL := *p.c.Metadata.SingleSignOnURL
+	query := redirectURL.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	query.Set("RelayState", relayState)
+	redirectURL.RawQuery = query.Encode()
+	return redirectURL.String(), nil
+}
+
+// ValidateResponse validates a base64-encoded SAMLResponse, and maps its assertion to a user.
+func (p *Provider) ValidateResponse(
+	encodedResponse string,
+	requestID string,
+	serviceProviderEntityID string,
+	assertionConsumerServiceURL string,
+	idpDisplayName string,
+) (*upstreamprovider.SAMLUser, error) {
+	a, err := validateResponse(encodedResponse, p.c.Metadata, &responseExpectations{
+		requestID:                   requestID,
+		serviceProviderEntityID:     serviceProviderEntityID,
+		assertionConsumerServiceURL: assertionConsumerServiceURL,
+		now:                         p.clock(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML response: %w", err)
+	}
+
+	username := a.nameID
+	if p.c.UsernameAttribute != "" {
+		values := a.attributes[p.c.UsernameAttribute]
+		if len(values) != 1 || strings.TrimSpace(values[0]) == "" {
+			return nil, fmt.Errorf("assertion must have exactly one non-empty value for username attribute %q, but had %d values",
+				p.c.UsernameAttribute, len(values))
+		}
+		username = values[0]
+	}
+
+	var groups []string
+	if p.c.GroupsAttribute != "" {
+		groups = a.attributes[p.c.GroupsAttribute]
+	}
+
+	return &upstreamprovider.SAMLUser{
+		Username:            username,
+		Groups:              groups,
+		DownstreamSubject:   downstreamsubject.SAML(a.issuer, a.nameID, idpDisplayName),
+		Issuer:              a.issuer,
+		NameID:              a.nameID,
+		SessionNotOnOrAfter: a.sessionNotOnOrAfter,
+		UpstreamData: map[string]any{
+			"issuer":     a.issuer,
+			"nameID":     a.nameID,
+			"attributes": a.attributes,
+		},
+	}, nil
+}
+
+// GetConfig returns the config. This is not part of the UpstreamSAMLIdentityProviderI interface and is just for testing.
+func (p *Provider) GetConfig() ProviderConfig {
+	return p.c
+}
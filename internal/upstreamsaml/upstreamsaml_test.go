@@ -0,0 +1,604 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamsaml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+
+	"go.pinniped.dev/internal/federationdomain/upstreamprovider"
+	"go.pinniped.dev/internal/testutil/samltestutil"
+)
+
+const (
+	testIDPEntityID = "https://idp.example.com/metadata"
+	testSSOURL      = "https://idp.example.com/sso?tenant=1"
+	testSPEntityID  = "https://supervisor.example.com/issuer"
+	testACSURL      = "https://supervisor.example.com/issuer/callback"
+	testRequestID   = "_request-id-123"
+)
+
+var testNow = time.Date(2025, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+func TestParseIdentityProviderMetadata(t *testing.T) {
+	idp := samltestutil.NewIdentityProvider(t, testIDPEntityID, testSSOURL)
+	certBase64 := base64.StdEncoding.EncodeToString(idp.Certificate.Raw)
+
+	entity := func(entityID, protocol, keyUse, cert, binding, location string) string {
+		return fmt.Sprintf(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">`+
+			`<md:IDPSSODescriptor protocolSupportEnumeration="%s">`+
+			`<md:KeyDescriptor use="%s"><ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data>`+
+			`<ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`+
+			`<md:SingleSignOnService Binding="%s" Location="%s"/>`+
+			`</md:IDPSSODescriptor></md:EntityDescriptor>`,
+			entityID, protocol, keyUse, cert, binding, location)
+	}
+
+	tests := []struct {
+		name     string
+		metadata string
+		wantErr  string
+	}{
+		{
+			name:     "entity descriptor from the test identity provider",
+			metadata: idp.MetadataXML(),
+		},
+		{
+			name:     "entity descriptor with an unspecified key use",
+			metadata: entity(testIDPEntityID, protocolSupportEnum, "", certBase64, httpRedirectBinding, testSSOURL),
+		},
+		{
+			name: "entities descriptor with one entity",
+			metadata: `<EntitiesDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata">` +
+				entity(testIDPEntityID, protocolSupportEnum, "signing", certBase64, httpRedirectBinding, testSSOURL) +
+				`</EntitiesDescriptor>`,
+		},
+		{
+			name: "entities descriptor with two entities",
+			metadata: `<EntitiesDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata">` +
+				entity(testIDPEntityID, protocolSupportEnum, "signing", certBase64, httpRedirectBinding, testSSOURL) +
+				entity("other", protocolSupportEnum, "signing", certBase64, httpRedirectBinding, testSSOURL) +
+				`</EntitiesDescriptor>`,
+			wantErr: "metadata must contain exactly one EntityDescriptor, but found 2",
+		},
+		{
+			name:     "wrong root element",
+			metadata: `<Response xmlns="urn:oasis:names:tc:SAML:2.0:protocol"/>`,
+			wantErr:  "metadata root element must be an EntityDescriptor or EntitiesDescriptor",
+		},
+		{
+			name:     "not XML",
+			metadata: `this is not XML`,
+			wantErr:  "could not parse XML: incomplete document",
+		},
+		{
+			name:     "missing entity ID",
+			metadata: entity("", protocolSupportEnum, "signing", certBase64, httpRedirectBinding, testSSOURL),
+			wantErr:  "metadata EntityDescriptor must have an entityID",
+		},
+		{
+			name:     "no SAML 2.0 identity provider",
+			metadata: entity(testIDPEntityID, "urn:oasis:names:tc:SAML:1.1:protocol", "signing", certBase64, httpRedirectBinding, testSSOURL),
+			wantErr:  "metadata must contain an IDPSSODescriptor which supports the SAML 2.0 protocol",
+		},
+		{
+			name:     "no HTTP-Redirect single sign-on service",
+			metadata: entity(testIDPEntityID, protocolSupportEnum, "signing", certBase64, httpPostBinding, testSSOURL),
+			wantErr:  "metadata must contain a SingleSignOnService which uses the HTTP-Redirect binding",
+		},
+		{
+			name:     "http single sign-on service",
+			metadata: entity(testIDPEntityID, protocolSupportEnum, "signing", certBase64, httpRedirectBinding, "http://idp.example.com/sso"),
+			wantErr:  `metadata SingleSignOnService location "http://idp.example.com/sso" must be an https URL`,
+		},
+		{
+			name:     "only an encryption certificate",
+			metadata: entity(testIDPEntityID, protocolSupportEnum, "encryption", certBase64, httpRedirectBinding, testSSOURL),
+			wantErr:  "metadata must contain at least one signing certificate",
+		},
+		{
+			name:     "certificate is not base64",
+			metadata: entity(testIDPEntityID, protocolSupportEnum, "signing", "!!!", httpRedirectBinding, testSSOURL),
+			wantErr:  "could not decode metadata signing certificate: illegal base64 data at input byte 0",
+		},
+		{
+			name:     "certificate is not a certificate",
+			metadata: entity(testIDPEntityID, protocolSupportEnum, "signing", "YWJj", httpRedirectBinding, testSSOURL),
+			wantErr:  "could not parse metadata signing certificate: x509: malformed certificate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := ParseIdentityProviderMetadata([]byte(tt.metadata))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, metadata)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testIDPEntityID, metadata.EntityID)
+			require.Equal(t, testSSOURL, metadata.SingleSignOnURL.String())
+			require.Len(t, metadata.SigningCertificates, 1)
+			require.True(t, idp.Certificate.Equal(metadata.SigningCertificates[0]))
+		})
+	}
+}
+
+func TestProvider(t *testing.T) {
+	idp := samltestutil.NewIdentityProvider(t, testIDPEntityID, testSSOURL)
+	metadata, err := ParseIdentityProviderMetadata([]byte(idp.MetadataXML()))
+	require.NoError(t, err)
+
+	subject := New(ProviderConfig{
+		Name:                    "foo",
+		ResourceUID:             "resource-uid-12345",
+		Metadata:                metadata,
+		ServiceProviderEntityID: "fake-sp-entity-id",
+		UsernameAttribute:       "fake-username-attribute",
+		GroupsAttribute:         "fake-groups-attribute",
+	})
+
+	require.Equal(t, "foo", subject.GetResourceName())
+	require.Equal(t, types.UID("resource-uid-12345"), subject.GetResourceUID())
+	require.Equal(t, "fake-sp-entity-id", subject.GetServiceProviderEntityID())
+	require.Equal(t, "fake-username-attribute", subject.GetUsernameAttribute())
+	require.Equal(t, "fake-groups-attribute", subject.GetGroupsAttribute())
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	idp := samltestutil.NewIdentityProvider(t, testIDPEntityID, testSSOURL)
+	metadata, err := ParseIdentityProviderMetadata([]byte(idp.MetadataXML()))
+	require.NoError(t, err)
+
+	subject := New(ProviderConfig{Metadata: metadata})
+	subject.clock = func() time.Time { return testNow }
+
+	redirectURL, err := subject.AuthnRequestURL(testRequestID, testSPEntityID, testACSURL, "some-relay-state")
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(redirectURL)
+	require.NoError(t, err)
+	require.Equal(t, "https://idp.example.com/sso", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	require.Equal(t, "1", parsed.Query().Get("tenant"))
+	require.Equal(t, "some-relay-state", parsed.Query().Get("RelayState"))
+
+	deflated, err := base64.StdEncoding.DecodeString(parsed.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	request, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	require.NoError(t, err)
+	require.Equal(t,
+		`<AuthnRequest xmlns="urn:oasis:names:tc:SAML:2.0:protocol" ID="_request-id-123" Version="2.0" `+
+			`IssueInstant="2025-03-04T05:06:07Z" Destination="https://idp.example.com/sso?tenant=1" `+
+			`AssertionConsumerServiceURL="https://supervisor.example.com/issuer/callback" `+
+			`ProtocolBinding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST">`+
+			`<Issuer xmlns="urn:oasis:names:tc:SAML:2.0:assertion">https://supervisor.example.com/issuer</Issuer>`+
+			`</AuthnRequest>`,
+		string(request))
+}
+
+// testResponse describes a SAML Response which is created by the test identity provider.
+type testResponse struct {
+	inResponseTo        string
+	destination         string
+	responseIssuer      string
+	statusCode          string
+	assertionIssuer     string
+	nameID              string
+	recipient           string
+	confirmationMethod  string
+	notBefore           time.Time
+	notOnOrAfter        time.Time
+	audience            string
+	sessionNotOnOrAfter string
+	attributes          string
+
+	signResponse  bool
+	signAssertion bool
+}
+
+func defaultTestResponse() *testResponse {
+	return &testResponse{
+		inResponseTo:       testRequestID,
+		destination:        testACSURL,
+		responseIssuer:     testIDPEntityID,
+		statusCode:         statusSuccess,
+		assertionIssuer:    testIDPEntityID,
+		nameID:             "user-name-id",
+		recipient:          testACSURL,
+		confirmationMethod: bearerConfirmation,
+		notBefore:          testNow.Add(-time.Minute),
+		notOnOrAfter:       testNow.Add(5 * time.Minute),
+		audience:           testSPEntityID,
+		attributes: `<saml:Attribute Name="email"><saml:AttributeValue>user@example.com</saml:AttributeValue></saml:Attribute>` +
+			`<saml:Attribute Name="groups"><saml:AttributeValue>group1</saml:AttributeValue>` +
+			`<saml:AttributeValue>group2</saml:AttributeValue></saml:Attribute>`,
+		signAssertion: true,
+	}
+}
+
+func (r *testResponse) xml() string {
+	sessionNotOnOrAfter := ""
+	if r.sessionNotOnOrAfter != "" {
+		sessionNotOnOrAfter = fmt.Sprintf(` SessionNotOnOrAfter="%s"`, r.sessionNotOnOrAfter)
+	}
+	return fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" `+
+		`ID="_response-id" Version="2.0" IssueInstant="%[1]s" Destination="%[2]s" InResponseTo="%[3]s">`+
+		`<saml:Issuer>%[4]s</saml:Issuer>`+
+		`<samlp:Status><samlp:StatusCode Value="%[5]s"/></samlp:Status>`+
+		`<saml:Assertion ID="_assertion-id" Version="2.0" IssueInstant="%[1]s">`+
+		`<saml:Issuer>%[6]s</saml:Issuer>`+
+		`<saml:Subject><saml:NameID>%[7]s</saml:NameID>`+
+		`<saml:SubjectConfirmation Method="%[8]s">`+
+		`<saml:SubjectConfirmationData InResponseTo="%[3]s" Recipient="%[9]s" NotOnOrAfter="%[10]s"/>`+
+		`</saml:SubjectConfirmation></saml:Subject>`+
+		`<saml:Conditions NotBefore="%[11]s" NotOnOrAfter="%[10]s">`+
+		`<saml:AudienceRestriction><saml:Audience>%[12]s</saml:Audience></saml:AudienceRestriction>`+
+		`</saml:Conditions>`+
+		`<saml:AuthnStatement AuthnInstant="%[1]s"%[13]s><saml:AuthnContext>`+
+		`<saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:Password</saml:AuthnContextClassRef>`+
+		`</saml:AuthnContext></saml:AuthnStatement>`+
+		`<saml:AttributeStatement>%[14]s</saml:AttributeStatement>`+
+		`</saml:Assertion></samlp:Response>`,
+		testNow.Format(time.RFC3339), r.destination, r.inResponseTo, r.responseIssuer, r.statusCode,
+		r.assertionIssuer, r.nameID, r.confirmationMethod, r.recipient,
+		r.notOnOrAfter.Format(time.RFC3339), r.notBefore.Format(time.RFC3339), r.audience,
+		sessionNotOnOrAfter, r.attributes)
+}
+
+// sign returns the document with an enveloped signature added to the element with the given ID,
+// inserted right after its Issuer, which is where the SAML schema requires it to be.
+func sign(t *testing.T, idp *samltestutil.IdentityProvider, doc string, id string) string {
+	t.Helper()
+
+	etreeDoc := etree.NewDocument()
+	require.NoError(t, etreeDoc.ReadFromString(doc))
+	signed := etreeDoc.FindElement(fmt.Sprintf("//[@ID='%s']", id))
+	require.NotNil(t, signed)
+
+	namespaces, err := etreeutils.NSBuildParentContext(signed)
+	require.NoError(t, err)
+	detached, err := etreeutils.NSDetatch(namespaces, signed)
+	require.NoError(t, err)
+
+	signingContext, err := dsig.NewSigningContext(idp.SigningKey, [][]byte{idp.Certificate.Raw})
+	require.NoError(t, err)
+	signingContext.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	signature, err := signingContext.ConstructSignature(detached, true)
+	require.NoError(t, err)
+
+	issuer := signed.SelectElement("Issuer")
+	require.NotNil(t, issuer)
+	signed.InsertChildAt(issuer.Index()+1, signature)
+
+	signedDoc, err := etreeDoc.WriteToString()
+	require.NoError(t, err)
+	return signedDoc
+}
+
+func (r *testResponse) encode(t *testing.T, idp *samltestutil.IdentityProvider) string {
+	t.Helper()
+
+	doc := r.xml()
+	if r.signAssertion {
+		doc = sign(t, idp, doc, "_assertion-id")
+	}
+	if r.signResponse {
+		doc = sign(t, idp, doc, "_response-id")
+	}
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestValidateResponse(t *testing.T) {
+	idp := samltestutil.NewIdentityProvider(t, testIDPEntityID, testSSOURL)
+	otherIDP := samltestutil.NewIdentityProvider(t, testIDPEntityID, testSSOURL)
+	metadata, err := ParseIdentityProviderMetadata([]byte(idp.MetadataXML()))
+	require.NoError(t, err)
+
+	const wantSubject = "https://idp.example.com/metadata?idpName=my-idp&nameID=user-name-id"
+	wantUpstreamData := map[string]any{
+		"issuer": testIDPEntityID,
+		"nameID": "user-name-id",
+		"attributes": map[string][]string{
+			"email":  {"user@example.com"},
+			"groups": {"group1", "group2"},
+		},
+	}
+
+	tests := []struct {
+		name              string
+		usernameAttribute string
+		groupsAttribute   string
+		modifyResponse    func(r *testResponse)
+		encodedResponse   func(t *testing.T, r *testResponse) string
+		wantUser          *upstreamprovider.SAMLUser
+		wantErr           string
+	}{
+		{
+			name: "signed assertion, username from NameID, no groups",
+			wantUser: &upstreamprovider.SAMLUser{
+				Username:          "user-name-id",
+				DownstreamSubject: wantSubject,
+				Issuer:            testIDPEntityID,
+				NameID:            "user-name-id",
+				UpstreamData:      wantUpstreamData,
+			},
+		},
+		{
+			name:              "signed response, username and groups from attributes",
+			usernameAttribute: "email",
+			groupsAttribute:   "groups",
+			modifyResponse: func(r *testResponse) {
+				r.signAssertion = false
+				r.signResponse = true
+				r.sessionNotOnOrAfter = testNow.Add(time.Hour).Format(time.RFC3339)
+			},
+			wantUser: &upstreamprovider.SAMLUser{
+				Username:            "user@example.com",
+				Groups:              []string{"group1", "group2"},
+				DownstreamSubject:   wantSubject,
+				Issuer:              testIDPEntityID,
+				NameID:              "user-name-id",
+				UpstreamData:        wantUpstreamData,
+				SessionNotOnOrAfter: testNow.Add(time.Hour),
+			},
+		},
+		{
+			name: "signed response and assertion",
+			modifyResponse: func(r *testResponse) {
+				r.signResponse = true
+			},
+			wantUser: &upstreamprovider.SAMLUser{
+				Username:          "user-name-id",
+				DownstreamSubject: wantSubject,
+				Issuer:            testIDPEntityID,
+				NameID:            "user-name-id",
+				UpstreamData:      wantUpstreamData,
+			},
+		},
+		{
+			name:            "groups attribute is missing from the assertion",
+			groupsAttribute: "missing",
+			wantUser: &upstreamprovider.SAMLUser{
+				Username:          "user-name-id",
+				DownstreamSubject: wantSubject,
+				Issuer:            testIDPEntityID,
+				NameID:            "user-name-id",
+				UpstreamData:      wantUpstreamData,
+			},
+		},
+		{
+			name:              "username attribute is missing from the assertion",
+			usernameAttribute: "missing",
+			wantErr:           `assertion must have exactly one non-empty value for username attribute "missing", but had 0 values`,
+		},
+		{
+			name:              "username attribute has multiple values",
+			usernameAttribute: "groups",
+			wantErr:           `assertion must have exactly one non-empty value for username attribute "groups", but had 2 values`,
+		},
+		{
+			name: "unsigned",
+			modifyResponse: func(r *testResponse) {
+				r.signAssertion = false
+			},
+			wantErr: "invalid SAML response: neither the response nor the assertion is signed",
+		},
+		{
+			name: "signed by another identity provider",
+			encodedResponse: func(t *testing.T, r *testResponse) string {
+				return r.encode(t, otherIDP)
+			},
+			wantErr: "invalid SAML response: invalid assertion signature: Could not verify certificate against trusted certs",
+		},
+		{
+			name: "assertion modified after signing",
+			encodedResponse: func(t *testing.T, r *testResponse) string {
+				doc, err := base64.StdEncoding.DecodeString(r.encode(t, idp))
+				require.NoError(t, err)
+				return base64.StdEncoding.EncodeToString(bytes.Replace(doc, []byte("user-name-id"), []byte("admin"), 1))
+			},
+			wantErr: "invalid SAML response: invalid assertion signature: Signature could not be verified",
+		},
+		{
+			name: "comment inserted into a signed value after signing does not truncate the value",
+			encodedResponse: func(t *testing.T, r *testResponse) string {
+				doc, err := base64.StdEncoding.DecodeString(r.encode(t, idp))
+				require.NoError(t, err)
+				return base64.StdEncoding.EncodeToString(bytes.Replace(doc, []byte("user-name-id"), []byte("user<!-- comment -->-name-id"), 1))
+			},
+			wantUser: &upstreamprovider.SAMLUser{
+				Username:          "user-name-id",
+				DownstreamSubject: wantSubject,
+				Issuer:            testIDPEntityID,
+				NameID:            "user-name-id",
+				UpstreamData:      wantUpstreamData,
+			},
+		},
+		{
+			name: "signature wrapping with a duplicate ID",
+			encodedResponse: func(t *testing.T, r *testResponse) string {
+				doc, err := base64.StdEncoding.DecodeString(r.encode(t, idp))
+				require.NoError(t, err)
+				wrapped := strings.Replace(string(doc), "<samlp:Status>",
+					`<saml:Advice><saml:Assertion ID="_assertion-id"/></saml:Advice><samlp:Status>`, 1)
+				return base64.StdEncoding.EncodeToString([]byte(wrapped))
+			},
+			wantErr: `invalid SAML response: duplicate ID "_assertion-id"`,
+		},
+		{
+			name: "unsigned assertion after a signed assertion",
+			encodedResponse: func(t *testing.T, r *testResponse) string {
+				doc, err := base64.StdEncoding.DecodeString(r.encode(t, idp))
+				require.NoError(t, err)
+				unsigned := strings.Replace(strings.Replace(r.xml(), "user-name-id", "admin", 1), "_assertion-id", "_other-assertion-id", 1)
+				unsignedAssertion := unsigned[strings.Index(unsigned, "<saml:Assertion "):strings.Index(unsigned, "</samlp:Response>")]
+				return base64.StdEncoding.EncodeToString([]byte(strings.Replace(string(doc), "</samlp:Response>", unsignedAssertion+"</samlp:Response>", 1)))
+			},
+			wantErr: "invalid SAML response: response must contain exactly one assertion, but had 2",
+		},
+		{
+			name: "no assertion",
+			encodedResponse: func(t *testing.T, r *testResponse) string {
+				doc := r.xml()
+				withoutAssertion := doc[:strings.Index(doc, "<saml:Assertion ")] + "</samlp:Response>"
+				return base64.StdEncoding.EncodeToString([]byte(sign(t, idp, withoutAssertion, "_response-id")))
+			},
+			wantErr: "invalid SAML response: response must contain exactly one assertion, but had 0",
+		},
+		{
+			name: "not base64",
+			encodedResponse: func(t *testing.T, r *testResponse) string {
+				return "!!!"
+			},
+			wantErr: "invalid SAML response: could not decode response: illegal base64 data at input byte 0",
+		},
+		{
+			name: "not a response",
+			encodedResponse: func(t *testing.T, r *testResponse) string {
+				return base64.StdEncoding.EncodeToString([]byte(idp.MetadataXML()))
+			},
+			wantErr: "invalid SAML response: root element is not a Response",
+		},
+		{
+			name: "wrong destination",
+			modifyResponse: func(r *testResponse) {
+				r.destination = "https://other.example.com/callback"
+			},
+			wantErr: `invalid SAML response: response destination "https://other.example.com/callback" does not match "https://supervisor.example.com/issuer/callback"`,
+		},
+		{
+			name: "wrong InResponseTo",
+			modifyResponse: func(r *testResponse) {
+				r.inResponseTo = "_other-request-id"
+			},
+			wantErr: `invalid SAML response: response is not in response to the expected request: InResponseTo is "_other-request-id"`,
+		},
+		{
+			name: "wrong response issuer",
+			modifyResponse: func(r *testResponse) {
+				r.responseIssuer = "https://other.example.com"
+			},
+			wantErr: `invalid SAML response: response issuer "https://other.example.com" does not match "https://idp.example.com/metadata"`,
+		},
+		{
+			name: "unsuccessful status",
+			modifyResponse: func(r *testResponse) {
+				r.statusCode = "urn:oasis:names:tc:SAML:2.0:status:Responder"
+			},
+			wantErr: `invalid SAML response: response status is "urn:oasis:names:tc:SAML:2.0:status:Responder"`,
+		},
+		{
+			name: "wrong assertion issuer",
+			modifyResponse: func(r *testResponse) {
+				r.assertionIssuer = "https://other.example.com"
+			},
+			wantErr: `invalid SAML response: assertion issuer does not match "https://idp.example.com/metadata"`,
+		},
+		{
+			name: "empty NameID",
+			modifyResponse: func(r *testResponse) {
+				r.nameID = ""
+			},
+			wantErr: "invalid SAML response: assertion subject must have a NameID",
+		},
+		{
+			name: "wrong recipient",
+			modifyResponse: func(r *testResponse) {
+				r.recipient = "https://other.example.com/callback"
+			},
+			wantErr: `invalid SAML response: subject confirmation recipient "https://other.example.com/callback" does not match "https://supervisor.example.com/issuer/callback"`,
+		},
+		{
+			name: "no bearer subject confirmation",
+			modifyResponse: func(r *testResponse) {
+				r.confirmationMethod = "urn:oasis:names:tc:SAML:2.0:cm:holder-of-key"
+			},
+			wantErr: "invalid SAML response: assertion subject must have a bearer subject confirmation",
+		},
+		{
+			name: "expired",
+			modifyResponse: func(r *testResponse) {
+				r.notOnOrAfter = testNow.Add(-allowedClockSkew)
+			},
+			wantErr: "invalid SAML response: subject confirmation: expired",
+		},
+		{
+			name: "expired but within the allowed clock skew",
+			modifyResponse: func(r *testResponse) {
+				r.notOnOrAfter = testNow.Add(-allowedClockSkew + time.Second)
+			},
+			wantUser: &upstreamprovider.SAMLUser{
+				Username:          "user-name-id",
+				DownstreamSubject: wantSubject,
+				Issuer:            testIDPEntityID,
+				NameID:            "user-name-id",
+				UpstreamData:      wantUpstreamData,
+			},
+		},
+		{
+			name: "not yet valid",
+			modifyResponse: func(r *testResponse) {
+				r.notBefore = testNow.Add(allowedClockSkew + time.Second)
+			},
+			wantErr: "invalid SAML response: assertion conditions: not yet valid",
+		},
+		{
+			name: "wrong audience",
+			modifyResponse: func(r *testResponse) {
+				r.audience = "https://other.example.com"
+			},
+			wantErr: `invalid SAML response: assertion audience restriction does not include "https://supervisor.example.com/issuer"`,
+		},
+		{
+			name: "session expired",
+			modifyResponse: func(r *testResponse) {
+				r.sessionNotOnOrAfter = testNow.Add(-time.Hour).Format(time.RFC3339)
+			},
+			wantErr: "invalid SAML response: assertion session has expired",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := New(ProviderConfig{
+				Metadata:          metadata,
+				UsernameAttribute: tt.usernameAttribute,
+				GroupsAttribute:   tt.groupsAttribute,
+			})
+			subject.clock = func() time.Time { return testNow }
+
+			r := defaultTestResponse()
+			if tt.modifyResponse != nil {
+				tt.modifyResponse(r)
+			}
+			var encoded string
+			if tt.encodedResponse != nil {
+				encoded = tt.encodedResponse(t, r)
+			} else {
+				encoded = r.encode(t, idp)
+			}
+
+			user, err := subject.ValidateResponse(encoded, testRequestID, testSPEntityID, testACSURL, "my-idp")
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, user)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantUser, user)
+		})
+	}
+}
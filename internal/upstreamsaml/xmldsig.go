@@ -0,0 +1,137 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamsaml
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+const xmldsigNS = "http://www.w3.org/2000/09/xmldsig#"
+
+// parseXML parses a document and returns its root element. Documents containing a DTD are rejected.
+func parseXML(data []byte) (*etree.Element, error) {
+	doc := etree.NewDocument()
+	err := doc.ReadFromBytes(data)
+	for _, token := range doc.Child {
+		if _, ok := token.(*etree.Directive); ok {
+			return nil, errors.New("could not parse XML: DTDs are not allowed")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse XML: %w", err)
+	}
+	if len(doc.ChildElements()) > 1 {
+		return nil, errors.New("could not parse XML: more than one root element")
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, errors.New("could not parse XML: incomplete document")
+	}
+	return root, nil
+}
+
+func isElement(e *etree.Element, namespace, local string) bool {
+	return e.Tag == local && e.NamespaceURI() == namespace
+}
+
+// attr returns the value of the unqualified attribute with the given name.
+func attr(e *etree.Element, name string) string {
+	for _, a := range e.Attr {
+		if a.Space == "" && a.Key == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func childElements(e *etree.Element, namespace, local string) []*etree.Element {
+	var found []*etree.Element
+	for _, c := range e.ChildElements() {
+		if isElement(c, namespace, local) {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// child returns the only child element with the given name, or nil when there is not exactly one.
+func child(e *etree.Element, namespace, local string) *etree.Element {
+	found := childElements(e, namespace, local)
+	if len(found) != 1 {
+		return nil
+	}
+	return found[0]
+}
+
+// text returns all the character data of the element. Unlike etree's Element.Text, this includes the character data
+// which follows a comment, because a comment does not change the canonical form of a signed element, so an attacker
+// could otherwise insert a comment to truncate a signed value without invalidating the signature.
+func text(e *etree.Element) string {
+	var b strings.Builder
+	for _, c := range e.Child {
+		if data, ok := c.(*etree.CharData); ok {
+			b.WriteString(data.Data)
+		}
+	}
+	return b.String()
+}
+
+// walk calls f for this element and all of its descendant elements, in document order.
+func walk(e *etree.Element, f func(*etree.Element)) {
+	f(e)
+	for _, c := range e.ChildElements() {
+		walk(c, f)
+	}
+}
+
+// hasSignature returns true when the element has an enveloped ds:Signature child.
+// An error is returned when the element has more than one signature.
+func hasSignature(e *etree.Element) (bool, error) {
+	switch len(childElements(e, xmldsigNS, "Signature")) {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return false, errors.New("element has more than one signature")
+	}
+}
+
+// verifyEnvelopedSignature verifies the enveloped XML signature of the element using one of the certificates,
+// which must also be valid at the given time. It returns a copy of the signed element, with the signature removed,
+// which is the only version of the element that should be trusted after the signature was verified.
+func verifyEnvelopedSignature(e *etree.Element, certs []*x509.Certificate, now time.Time) (*etree.Element, error) {
+	// Detach the element from its ancestors, but keep the namespace declarations which it inherited from them.
+	namespaces, err := etreeutils.NSBuildParentContext(e)
+	if err != nil {
+		return nil, err
+	}
+	detached, err := etreeutils.NSDetatch(namespaces, e)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try each certificate in turn, because the signature does not need to say which certificate was used.
+	errs := make([]error, 0, len(certs))
+	for _, cert := range certs {
+		validationContext := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+			Roots: []*x509.Certificate{cert},
+		})
+		validationContext.Clock = dsig.NewFakeClockAt(now)
+		verified, err := validationContext.Validate(detached)
+		if err == nil {
+			return verified, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,72 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package upstreamsaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		xml     string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			xml:  `<?xml version="1.0"?><!-- comment --><a:Root xmlns:a="urn:a"><a:Child/></a:Root>`,
+		},
+		{
+			name:    "DTD",
+			xml:     `<!DOCTYPE Root [<!ENTITY x "y">]><Root>&x;</Root>`,
+			wantErr: "could not parse XML: DTDs are not allowed",
+		},
+		{
+			name:    "DTD without entities",
+			xml:     `<!DOCTYPE Root><Root/>`,
+			wantErr: "could not parse XML: DTDs are not allowed",
+		},
+		{
+			name:    "two root elements",
+			xml:     `<Root/><Root/>`,
+			wantErr: "could not parse XML: more than one root element",
+		},
+		{
+			name:    "incomplete",
+			xml:     `<Root><Child/>`,
+			wantErr: "could not parse XML: etree: invalid XML format",
+		},
+		{
+			name:    "mismatched end element",
+			xml:     `<Root></Other>`,
+			wantErr: "could not parse XML: etree: invalid XML format",
+		},
+		{
+			name:    "empty",
+			xml:     ``,
+			wantErr: "could not parse XML: incomplete document",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := parseXML([]byte(tt.xml))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				require.Nil(t, root)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, isElement(root, "urn:a", "Root"))
+			require.NotNil(t, child(root, "urn:a", "Child"))
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	root, err := parseXML([]byte(`<Root>user<!-- comment -->@example.com<Child>other</Child><![CDATA[!]]></Root>`))
+	require.NoError(t, err)
+	require.Equal(t, "user@example.com!", text(root))
+}
@@ -1,4 +1,4 @@
-// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package filesession implements a simple YAML file-based login.sessionCache.
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"time"
 
 	"github.com/gofrs/flock"
@@ -113,6 +115,47 @@ func (c *Cache) PutToken(key oidcclient.SessionCacheKey, token *oidctypes.Token)
 	})
 }
 
+// Session is a cached session, as returned by GetSessions.
+type Session struct {
+	Key    oidcclient.SessionCacheKey
+	Tokens *oidctypes.Token
+}
+
+// GetSessions returns all cached sessions for the given issuer and client ID, so that the caller may end those
+// sessions at the issuer before removing them with DeleteToken. It does not return an error but may silently fail
+// to read the session cache.
+func (c *Cache) GetSessions(issuer string, clientID string) []Session {
+	// If the cache file does not exist, exit immediately with no error log
+	if _, err := os.Stat(c.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	var result []Session
+	c.withCache(func(cache *sessionCache) {
+		for i := range cache.Sessions {
+			if cache.Sessions[i].Key.Issuer == issuer && cache.Sessions[i].Key.ClientID == clientID {
+				result = append(result, Session{Key: cache.Sessions[i].Key, Tokens: &cache.Sessions[i].Tokens})
+			}
+		}
+	})
+	return result
+}
+
+// DeleteToken removes the cached session with the given key. It does not return an error but may silently fail
+// to update the session cache.
+func (c *Cache) DeleteToken(key oidcclient.SessionCacheKey) {
+	// If the cache file does not exist, exit immediately with no error log
+	if _, err := os.Stat(c.path); errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	c.withCache(func(cache *sessionCache) {
+		cache.Sessions = slices.DeleteFunc(cache.Sessions, func(entry sessionEntry) bool {
+			return reflect.DeepEqual(entry.Key, key)
+		})
+	})
+}
+
 // withCache is an internal helper which locks, reads the cache, processes/mutates it with the provided function, then
 // saves it back to the file.
 func (c *Cache) withCache(transact func(*sessionCache)) {
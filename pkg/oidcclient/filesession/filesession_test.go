@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package filesession
@@ -493,6 +493,190 @@ func TestPutToken(t *testing.T) {
 	}
 }
 
+func newSessionEntry(now time.Time, issuer string, clientID string, idToken string) sessionEntry {
+	return sessionEntry{
+		Key: oidcclient.SessionCacheKey{
+			Issuer:      issuer,
+			ClientID:    clientID,
+			Scopes:      []string{"email", "offline_access", "openid", "profile"},
+			RedirectURI: "http://localhost:0/callback",
+		},
+		CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+		LastUsedTimestamp: metav1.NewTime(now.Add(-1 * time.Hour)),
+		Tokens: oidctypes.Token{
+			IDToken: &oidctypes.IDToken{
+				Token:  idToken,
+				Expiry: metav1.NewTime(now.Add(1 * time.Hour)),
+			},
+			RefreshToken: &oidctypes.RefreshToken{
+				Token: idToken + "-refresh-token",
+			},
+		},
+	}
+}
+
+func TestGetSessions(t *testing.T) {
+	t.Parallel()
+	now := time.Now().Round(1 * time.Second)
+
+	tests := []struct {
+		name         string
+		makeTestFile func(t *testing.T, tmp string)
+		issuer       string
+		wantTokens   []string
+		wantErrors   []string
+		wantTestFile func(t *testing.T, tmp string)
+	}{
+		{
+			name:   "file does not exist",
+			issuer: "test-issuer",
+			wantTestFile: func(t *testing.T, tmp string) {
+				require.NoFileExists(t, tmp)
+			},
+		},
+		{
+			name: "returns every entry for the issuer and client ID without removing any",
+			makeTestFile: func(t *testing.T, tmp string) {
+				validCache := emptySessionCache()
+				validCache.insert(
+					newSessionEntry(now, "test-issuer", "test-client-id", "id-token-1"),
+					newSessionEntry(now, "other-issuer", "test-client-id", "id-token-2"),
+					newSessionEntry(now, "test-issuer", "test-client-id", "id-token-3"),
+					newSessionEntry(now, "test-issuer", "other-client-id", "id-token-4"),
+				)
+				require.NoError(t, os.MkdirAll(filepath.Dir(tmp), 0700))
+				require.NoError(t, validCache.writeTo(tmp))
+			},
+			issuer:     "test-issuer",
+			wantTokens: []string{"id-token-1", "id-token-3"},
+			wantTestFile: func(t *testing.T, tmp string) {
+				cache, err := readSessionCache(tmp)
+				require.NoError(t, err)
+				require.Len(t, cache.Sessions, 4)
+			},
+		},
+		{
+			name: "no entries for the issuer and client ID",
+			makeTestFile: func(t *testing.T, tmp string) {
+				validCache := emptySessionCache()
+				validCache.insert(newSessionEntry(now, "other-issuer", "test-client-id", "id-token-1"))
+				require.NoError(t, os.MkdirAll(filepath.Dir(tmp), 0700))
+				require.NoError(t, validCache.writeTo(tmp))
+			},
+			issuer: "test-issuer",
+			wantTestFile: func(t *testing.T, tmp string) {
+				cache, err := readSessionCache(tmp)
+				require.NoError(t, err)
+				require.Len(t, cache.Sessions, 1)
+			},
+		},
+		{
+			name: "error writing cache",
+			makeTestFile: func(t *testing.T, tmp string) {
+				require.NoError(t, os.MkdirAll(tmp, 0700))
+			},
+			issuer: "test-issuer",
+			wantErrors: []string{
+				"failed to read cache, resetting: could not read session file: read TEMPFILE: is a directory",
+				"could not write session cache: open TEMPFILE: is a directory",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmp := t.TempDir() + "/sessiondir/sessions.yaml"
+			if tt.makeTestFile != nil {
+				tt.makeTestFile(t, tmp)
+			}
+			// Initialize a cache with a reporter that collects errors
+			errors := errorCollector{t: t}
+			c := New(tmp, errors.collect())
+			sessions := c.GetSessions(tt.issuer, "test-client-id")
+			errors.require(tt.wantErrors, "TEMPFILE", tmp, "TEMPDIR", filepath.Dir(tmp))
+
+			gotTokens := make([]string, 0, len(sessions))
+			for _, session := range sessions {
+				require.Equal(t, tt.issuer, session.Key.Issuer)
+				require.Equal(t, "test-client-id", session.Key.ClientID)
+				gotTokens = append(gotTokens, session.Tokens.IDToken.Token)
+			}
+			require.ElementsMatch(t, tt.wantTokens, gotTokens)
+
+			if tt.wantTestFile != nil {
+				tt.wantTestFile(t, tmp)
+			}
+		})
+	}
+}
+
+func TestDeleteToken(t *testing.T) {
+	t.Parallel()
+	now := time.Now().Round(1 * time.Second)
+
+	tests := []struct {
+		name         string
+		makeTestFile func(t *testing.T, tmp string)
+		wantErrors   []string
+		wantTestFile func(t *testing.T, tmp string)
+	}{
+		{
+			name: "file does not exist",
+			wantTestFile: func(t *testing.T, tmp string) {
+				require.NoFileExists(t, tmp)
+			},
+		},
+		{
+			name: "deletes only the entry with the key",
+			makeTestFile: func(t *testing.T, tmp string) {
+				validCache := emptySessionCache()
+				validCache.insert(
+					newSessionEntry(now, "test-issuer", "test-client-id", "id-token-1"),
+					newSessionEntry(now, "other-issuer", "test-client-id", "id-token-2"),
+					newSessionEntry(now, "test-issuer", "other-client-id", "id-token-3"),
+				)
+				require.NoError(t, os.MkdirAll(filepath.Dir(tmp), 0700))
+				require.NoError(t, validCache.writeTo(tmp))
+			},
+			wantTestFile: func(t *testing.T, tmp string) {
+				cache, err := readSessionCache(tmp)
+				require.NoError(t, err)
+				require.Len(t, cache.Sessions, 2)
+				require.Equal(t, "id-token-2", cache.Sessions[0].Tokens.IDToken.Token)
+				require.Equal(t, "id-token-3", cache.Sessions[1].Tokens.IDToken.Token)
+			},
+		},
+		{
+			name: "error writing cache",
+			makeTestFile: func(t *testing.T, tmp string) {
+				require.NoError(t, os.MkdirAll(tmp, 0700))
+			},
+			wantErrors: []string{
+				"failed to read cache, resetting: could not read session file: read TEMPFILE: is a directory",
+				"could not write session cache: open TEMPFILE: is a directory",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmp := t.TempDir() + "/sessiondir/sessions.yaml"
+			if tt.makeTestFile != nil {
+				tt.makeTestFile(t, tmp)
+			}
+			// Initialize a cache with a reporter that collects errors
+			errors := errorCollector{t: t}
+			c := New(tmp, errors.collect())
+			c.DeleteToken(newSessionEntry(now, "test-issuer", "test-client-id", "").Key)
+			errors.require(tt.wantErrors, "TEMPFILE", tmp, "TEMPDIR", filepath.Dir(tmp))
+
+			if tt.wantTestFile != nil {
+				tt.wantTestFile(t, tmp)
+			}
+		})
+	}
+}
+
 type errorCollector struct {
 	t   *testing.T
 	saw []error
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 // Package oidcclient implements a CLI OIDC login flow.
@@ -316,20 +316,25 @@ func WithCLISendingCredentials() Option {
 // could potentially get logged somewhere by the issuer.
 // When the argument is equal to idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode, it will attempt to open a web browser
 // and perform the OIDC authcode flow.
+// When the argument is equal to idpdiscoveryv1alpha1.IDPFlowDeviceCode, it will perform the OAuth 2.0 device
+// authorization grant flow (RFC 8628), which prints a link and a code that the user can enter in a web browser on
+// any device. This is intended for hosts which do not have a web browser, such as SSH jump hosts.
 // When not used, the default when the issuer is a Pinniped Supervisor will be determined automatically,
 // and the default for non-Supervisor issuers will be the browser authcode flow.
 func WithLoginFlow(loginFlow idpdiscoveryv1alpha1.IDPFlow, flowSource string) Option {
 	return func(h *handlerState) error {
 		switch loginFlow {
 		case idpdiscoveryv1alpha1.IDPFlowCLIPassword,
-			idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode:
+			idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode,
+			idpdiscoveryv1alpha1.IDPFlowDeviceCode:
 		default:
 			return fmt.Errorf(
-				"WithLoginFlow error: loginFlow '%s' from '%s' must be '%s' or '%s'",
+				"WithLoginFlow error: loginFlow '%s' from '%s' must be '%s', '%s', or '%s'",
 				loginFlow,
 				flowSource,
 				idpdiscoveryv1alpha1.IDPFlowCLIPassword,
 				idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode,
+				idpdiscoveryv1alpha1.IDPFlowDeviceCode,
 			)
 		}
 		h.loginFlow = loginFlow
@@ -603,6 +608,11 @@ func (h *handlerState) baseLogin() (*oidctypes.Token, error) {
 		authFunc = h.cliBasedAuth
 	case idpdiscoveryv1alpha1.IDPFlowBrowserAuthcode:
 		// NOOP
+	case idpdiscoveryv1alpha1.IDPFlowDeviceCode:
+		// The nonce, PKCE, and offline access options only apply to authorize requests,
+		// so only send the Pinniped Supervisor's options to the device authorization endpoint.
+		authorizeOptions = pinnipedSupervisorOptions
+		authFunc = h.deviceCodeBasedAuth
 	}
 
 	// Perform the authorize request and authcode exchange to get back OIDC tokens.
@@ -689,10 +699,20 @@ func (h *handlerState) maybePerformPinnipedSupervisorValidations() (idpdiscovery
 		)
 	}
 
+	foundIDP := h.idpDiscovery.PinnipedIDPs[foundIDPIndex]
+
+	// The Supervisor may hint that it has recently been unable to reach the IDP. The hint might already be stale,
+	// so still try to log in, but let the user know why the login might fail.
+	if foundIDP.Unavailable {
+		_, _ = fmt.Fprintf(h.out,
+			"Warning: the Pinniped Supervisor reports that the identity provider %q is currently unavailable, so this login may fail\n",
+			h.upstreamIdentityProviderName,
+		)
+	}
+
 	// If the caller has not requested a specific flow, but has requested a specific IDP, infer the authentication flow
 	// from the found IDP's discovery information.
 	if loginFlow == "" {
-		foundIDP := h.idpDiscovery.PinnipedIDPs[foundIDPIndex]
 		if len(foundIDP.Flows) == 0 {
 			// Note that this should not really happen because the Supervisor's IDP discovery endpoint has always listed flows.
 			return "", nil, fmt.Errorf("unable to infer flow for upstream identity provider with name %q and type %q "+
@@ -917,6 +937,44 @@ func (h *handlerState) webBrowserBasedAuth(authorizeOptions *[]oauth2.AuthCodeOp
 	}
 }
 
+// Make a request to the device authorization endpoint, then print the verification URL and user code so the user
+// can log in using a web browser on any device. Poll the token endpoint until the user has finished logging in.
+// Return the tokens or an error.
+func (h *handlerState) deviceCodeBasedAuth(deviceAuthorizationOptions *[]oauth2.AuthCodeOption) (*oidctypes.Token, error) {
+	if h.oauth2Config.Endpoint.DeviceAuthURL == "" {
+		return nil, fmt.Errorf("login failed: OIDC issuer %q does not advertise a device authorization endpoint in its discovery", h.issuer)
+	}
+	// "Communication with the device authorization endpoint MUST utilize TLS."
+	// (see https://datatracker.ietf.org/doc/html/rfc8628#section-3.1).
+	if err := validateURLUsesHTTPS(h.oauth2Config.Endpoint.DeviceAuthURL, "discovered device authorization URL from issuer"); err != nil {
+		return nil, err
+	}
+
+	deviceAuth, err := h.oauth2Config.DeviceAuth(h.ctx, *deviceAuthorizationOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("could not start device authorization: %w", err)
+	}
+
+	// Prefer the link which already includes the user code, when the server provides one.
+	verificationURL := deviceAuth.VerificationURIComplete
+	if verificationURL == "" {
+		verificationURL = deviceAuth.VerificationURI
+	}
+	_, _ = fmt.Fprintf(h.out, "Log in by visiting this link on any device:\n\n    %s\n\nIf prompted, enter the code: %s\n\n",
+		verificationURL, deviceAuth.UserCode)
+
+	// Poll the token endpoint at the interval requested by the server, until the user has finished
+	// logging in, or the device code expires, or the overall login timeout is reached.
+	token, err := h.oauth2Config.DeviceAccessToken(h.ctx, deviceAuth)
+	if err != nil {
+		return nil, fmt.Errorf("could not complete device authorization: %w", err)
+	}
+
+	// There is no nonce for this flow, so skip the nonce validation (but not other validations).
+	return h.getProvider(h.oauth2Config, h.provider, h.httpClient).
+		ValidateTokenAndMergeWithUserInfo(h.ctx, token, "", true, false)
+}
+
 // promptForWebLogin prints a login URL to the screen, if needed. It will also print the "paste yor authorization code"
 // prompt to the screen and wait for user input, if needed. It can be cancelled by the context provided.
 // It returns a function which should be invoked by the caller to perform some cleanup.
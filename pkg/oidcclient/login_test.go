@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package oidcclient
@@ -17,6 +17,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -469,6 +470,79 @@ func TestLogin(t *testing.T) { //nolint:gocyclo
 		return nil
 	}
 
+	deviceCodeTestOpts := func(t *testing.T, h *handlerState, withUpstreamIDP bool, tokenStatusCode int, tokenResponseBody string) {
+		t.Helper()
+
+		cache := &mockSessionCache{t: t, getReturnsToken: nil}
+		require.NoError(t, WithSessionCache(cache)(h))
+		require.NoError(t, WithLoginFlow(idpdiscoveryv1alpha1.IDPFlowDeviceCode, "flowSource")(h))
+		wantDeviceAuthorizationForm := url.Values{
+			"client_id": []string{"test-client-id"},
+			"scope":     []string{"test-scope"},
+		}
+		if withUpstreamIDP {
+			require.NoError(t, WithUpstreamIdentityProvider("upstream-idp-name-with-device-code-flow", "upstream-idp-type-with-device-code-flow")(h))
+			wantDeviceAuthorizationForm["pinniped_idp_name"] = []string{"upstream-idp-name-with-device-code-flow"}
+			wantDeviceAuthorizationForm["pinniped_idp_type"] = []string{"upstream-idp-type-with-device-code-flow"}
+		}
+
+		deviceAuthorizationRequestWasMade := false
+		tokenRequestWasMade := false
+		t.Cleanup(func() {
+			require.True(t, deviceAuthorizationRequestWasMade, "should have made a device authorization request")
+			require.True(t, tokenRequestWasMade, "should have made a token request")
+		})
+
+		jsonResponse := func(statusCode int, body string) *http.Response {
+			return &http.Response{
+				StatusCode: statusCode,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}
+		}
+
+		client := buildHTTPClientForPEM(successServerCA)
+		client.Transport = roundtripper.Func(func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Scheme + "://" + req.URL.Host + req.URL.Path {
+			case "https://" + successServer.Listener.Addr().String() + "/.well-known/openid-configuration":
+				return jsonResponse(http.StatusOK, fmt.Sprintf(`{`+
+					`"issuer": "%[1]s", "authorization_endpoint": "%[1]s/authorize", "token_endpoint": "%[1]s/token",`+
+					`"device_authorization_endpoint": "%[1]s/device_authorization", "jwks_uri": "%[1]s/keys",`+
+					`"discovery.supervisor.pinniped.dev/v1alpha1": {"pinniped_identity_providers_endpoint": "%[1]s%[2]s"}`+
+					`}`, successServer.URL, federationdomainoidc.PinnipedIDPsPathV1Alpha1)), nil
+			case "https://" + successServer.Listener.Addr().String() + federationdomainoidc.PinnipedIDPsPathV1Alpha1:
+				return jsonResponse(http.StatusOK, `{"pinniped_identity_providers": [{`+
+					`"name": "upstream-idp-name-with-device-code-flow", "type": "upstream-idp-type-with-device-code-flow",`+
+					`"flows": ["browser_authcode", "device_code"]}]}`), nil
+			case "https://" + successServer.Listener.Addr().String() + "/device_authorization":
+				deviceAuthorizationRequestWasMade = true
+				require.Equal(t, http.MethodPost, req.Method)
+				require.NoError(t, req.ParseForm())
+				require.Equal(t, wantDeviceAuthorizationForm, req.PostForm)
+				return jsonResponse(http.StatusOK, `{`+
+					`"device_code": "test-device-code", "user_code": "TEST-CODE",`+
+					`"verification_uri": "https://example.com/device_verification",`+
+					`"verification_uri_complete": "https://example.com/device_verification?user_code=TEST-CODE",`+
+					`"expires_in": 600, "interval": 1}`), nil
+			case "https://" + successServer.Listener.Addr().String() + "/token":
+				tokenRequestWasMade = true
+				require.Equal(t, http.MethodPost, req.Method)
+				require.NoError(t, req.ParseForm())
+				require.Equal(t, url.Values{
+					"client_id":   []string{"test-client-id"},
+					"grant_type":  []string{"urn:ietf:params:oauth:grant-type:device_code"},
+					"device_code": []string{"test-device-code"},
+					"scope":       []string{"test-scope"},
+				}, req.PostForm)
+				return jsonResponse(tokenStatusCode, tokenResponseBody), nil
+			default:
+				require.FailNow(t, fmt.Sprintf("saw unexpected http call from the CLI: %s", req.URL.String()))
+				return nil, nil
+			}
+		})
+		require.NoError(t, WithClient(client)(h))
+	}
+
 	tests := []struct {
 		name       string
 		opt        func(t *testing.T) Option
@@ -515,14 +589,14 @@ func TestLogin(t *testing.T) { //nolint:gocyclo
 			opt: func(t *testing.T) Option {
 				return WithLoginFlow("this is not one of the enum values", "some-flow-source")
 			},
-			wantErr: "WithLoginFlow error: loginFlow 'this is not one of the enum values' from 'some-flow-source' must be 'cli_password' or 'browser_authcode'",
+			wantErr: "WithLoginFlow error: loginFlow 'this is not one of the enum values' from 'some-flow-source' must be 'cli_password', 'browser_authcode', or 'device_code'",
 		},
 		{
 			name: "WithLoginFlow option will not accept empty string either",
 			opt: func(t *testing.T) Option {
 				return WithLoginFlow("", "other-flow-source")
 			},
-			wantErr: "WithLoginFlow error: loginFlow '' from 'other-flow-source' must be 'cli_password' or 'browser_authcode'",
+			wantErr: "WithLoginFlow error: loginFlow '' from 'other-flow-source' must be 'cli_password', 'browser_authcode', or 'device_code'",
 		},
 		{
 			name: "error generating state",
@@ -1775,6 +1849,65 @@ func TestLogin(t *testing.T) { //nolint:gocyclo
 			wantStdErr: "^\nLog in to upstream-idp-name-with-cli-password-flow-first\n\n$",
 			wantToken:  &testToken,
 		},
+		{
+			name:     "device code flow when the issuer does not advertise a device authorization endpoint",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					require.NoError(t, WithLoginFlow(idpdiscoveryv1alpha1.IDPFlowDeviceCode, "flowSource")(h))
+					require.NoError(t, WithClient(buildHTTPClientForPEM(successServerCA))(h))
+					return nil
+				}
+			},
+			issuer:   successServer.URL,
+			wantLogs: []string{`"level"=4 "msg"="Pinniped: Performing OIDC discovery"  "issuer"="` + successServer.URL + `"`},
+			wantErr:  `login failed: OIDC issuer "` + successServer.URL + `" does not advertise a device authorization endpoint in its discovery`,
+		},
+		{
+			name:     "successful device code flow with an upstream identity provider",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					h.getProvider = func(_ *oauth2.Config, _ *coreosoidc.Provider, _ *http.Client) upstreamprovider.UpstreamOIDCIdentityProviderI {
+						mock := mockUpstream(t)
+						mock.EXPECT().
+							ValidateTokenAndMergeWithUserInfo(gomock.Any(), HasAccessToken(testToken.AccessToken.Token), nonce.Nonce(""), true, false).
+							Return(&testToken, nil)
+						return mock
+					}
+					deviceCodeTestOpts(t, h, true, http.StatusOK,
+						`{"access_token": "test-access-token", "token_type": "Bearer", "refresh_token": "test-refresh-token", "id_token": "test-id-token", "expires_in": 3600}`)
+					return nil
+				}
+			},
+			issuer:   successServer.URL,
+			wantLogs: []string{`"level"=4 "msg"="Pinniped: Performing OIDC discovery"  "issuer"="` + successServer.URL + `"`},
+			wantStdErr: "^" +
+				regexp.QuoteMeta("Log in by visiting this link on any device:\n\n") +
+				regexp.QuoteMeta("    https://example.com/device_verification?user_code=TEST-CODE\n\n") +
+				regexp.QuoteMeta("If prompted, enter the code: TEST-CODE\n\n") +
+				"$",
+			wantToken: &testToken,
+		},
+		{
+			name:     "device code flow when the device code expires",
+			clientID: "test-client-id",
+			opt: func(t *testing.T) Option {
+				return func(h *handlerState) error {
+					deviceCodeTestOpts(t, h, false, http.StatusBadRequest,
+						`{"error": "expired_token", "error_description": "The device_code has expired."}`)
+					return nil
+				}
+			},
+			issuer:   successServer.URL,
+			wantLogs: []string{`"level"=4 "msg"="Pinniped: Performing OIDC discovery"  "issuer"="` + successServer.URL + `"`},
+			wantStdErr: "^" +
+				regexp.QuoteMeta("Log in by visiting this link on any device:\n\n") +
+				regexp.QuoteMeta("    https://example.com/device_verification?user_code=TEST-CODE\n\n") +
+				regexp.QuoteMeta("If prompted, enter the code: TEST-CODE\n\n") +
+				"$",
+			wantErr: `could not complete device authorization: oauth2: "expired_token" "The device_code has expired."`,
+		},
 		{
 			name:     "unable to login with unknown IDP, when Supervisor provides its supported IDP types",
 			clientID: "test-client-id",
@@ -3757,6 +3890,7 @@ func TestMaybePerformPinnipedSupervisorValidations(t *testing.T) {
 		wantAuthCodeOptions []oauth2.AuthCodeOption
 		wantLoginFlow       idpdiscoveryv1alpha1.IDPFlow
 		wantErr             string
+		wantOutput          string
 	}{
 		{
 			name: "without IDP name, return the specified login flow, nil options, and no error",
@@ -3778,6 +3912,24 @@ func TestMaybePerformPinnipedSupervisorValidations(t *testing.T) {
 			},
 			wantLoginFlow: idpdiscoveryv1alpha1.IDPFlowCLIPassword,
 		},
+		{
+			name: "with IDP name and IDP type of an IDP which the Supervisor reports as unavailable, warns the user but still continues",
+			options: []Option{
+				WithUpstreamIdentityProvider("some-upstream-name", "some-upstream-type"),
+				withIDPDiscovery(func() idpdiscoveryv1alpha1.IDPDiscoveryResponse {
+					temp := someIDPDiscoveryResponse
+					temp.PinnipedIDPs = slices.Clone(temp.PinnipedIDPs)
+					temp.PinnipedIDPs[0].Unavailable = true
+					return temp
+				}()),
+			},
+			wantAuthCodeOptions: []oauth2.AuthCodeOption{
+				oauth2.SetAuthURLParam(oidcapi.AuthorizeUpstreamIDPNameParamName, "some-upstream-name"),
+				oauth2.SetAuthURLParam(oidcapi.AuthorizeUpstreamIDPTypeParamName, "some-upstream-type"),
+			},
+			wantLoginFlow: idpdiscoveryv1alpha1.IDPFlowCLIPassword,
+			wantOutput:    "Warning: the Pinniped Supervisor reports that the identity provider \"some-upstream-name\" is currently unavailable, so this login may fail\n",
+		},
 		{
 			name: "when the Supervisor lists pinniped_supported_identity_provider_types and the given upstreamType is not found, return a specific error",
 			options: []Option{
@@ -3846,7 +3998,8 @@ func TestMaybePerformPinnipedSupervisorValidations(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			var h handlerState
+			var out bytes.Buffer
+			h := handlerState{out: &out}
 
 			for _, option := range test.options {
 				require.NoError(t, option(&h))
@@ -3862,6 +4015,7 @@ func TestMaybePerformPinnipedSupervisorValidations(t *testing.T) {
 			require.NoError(t, actualError)
 			require.Equal(t, test.wantAuthCodeOptions, actualAuthCodeOptions)
 			require.Equal(t, test.wantLoginFlow, actualLoginFlow)
+			require.Equal(t, test.wantOutput, out.String())
 		})
 	}
 }
@@ -0,0 +1,157 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	coreosoidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"go.pinniped.dev/internal/net/phttp"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+// Logout ends the issuer's session for the given token using the issuer's end_session_endpoint, as defined by
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html. The token's ID token is sent as the id_token_hint.
+// When the token has no ID token, e.g. because it was removed from the session cache after it expired, then its
+// refresh token is used to get a new ID token for the same session. Only the WithContext, WithLogger,
+// WithLoginLogger, and WithClient options have any effect.
+func Logout(issuer string, clientID string, token *oidctypes.Token, opts ...Option) error {
+	h := handlerState{
+		issuer:     issuer,
+		clientID:   clientID,
+		ctx:        context.Background(),
+		logger:     &emptyLogger{},
+		httpClient: phttp.Default(nil),
+	}
+	for _, opt := range opts {
+		if err := opt(&h); err != nil {
+			return err
+		}
+	}
+
+	if h.loggerOptionsCount > 1 {
+		return fmt.Errorf("please use only one mechanism to specify the logger")
+	}
+
+	h.httpClient.Transport = maybePrintAuditID(h.httpClient.Transport, logFailedRequest)
+
+	// Copy the configured HTTP client to set a request timeout (the Go default client has no timeout configured).
+	httpClientWithTimeout := *h.httpClient
+	httpClientWithTimeout.Timeout = httpRequestTimeout
+	h.httpClient = &httpClientWithTimeout
+
+	ctx, cancel := context.WithTimeout(h.ctx, httpRequestTimeout)
+	defer cancel()
+	h.ctx = coreosoidc.ClientContext(ctx, h.httpClient)
+
+	endSessionEndpoint, err := h.discoverEndSessionEndpoint()
+	if err != nil {
+		return err
+	}
+
+	idTokenHint, err := h.idTokenHint(token)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info("Pinniped: Ending session", "issuer", h.issuer)
+	reqBody := strings.NewReader(url.Values{
+		"client_id":     []string{h.clientID},
+		"id_token_hint": []string{idTokenHint},
+	}.Encode())
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodPost, endSessionEndpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("could not build logout request: %w", err)
+	}
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not end session: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// Expect an HTTP 200 response, since no post_logout_redirect_uri was requested.
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("could not end session: unexpected HTTP response status %d: %s",
+			resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+func (h *handlerState) discoverEndSessionEndpoint() (string, error) {
+	// Validate that the issuer URL uses https, or else we cannot trust its discovery endpoint to get the other URLs.
+	if err := validateURLUsesHTTPS(h.issuer, "issuer"); err != nil {
+		return "", err
+	}
+
+	h.logger.Info("Pinniped: Performing OIDC discovery", "issuer", h.issuer)
+	var err error
+	h.provider, err = coreosoidc.NewProvider(h.ctx, h.issuer)
+	if err != nil {
+		return "", fmt.Errorf("could not perform OIDC discovery for %q: %w", h.issuer, err)
+	}
+
+	h.oauth2Config = &oauth2.Config{
+		ClientID: h.clientID,
+		Endpoint: h.provider.Endpoint(),
+	}
+
+	var discoveryClaims struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := h.provider.Claims(&discoveryClaims); err != nil {
+		return "", fmt.Errorf("could not decode end_session_endpoint in OIDC discovery from %q: %w", h.issuer, err)
+	}
+	if discoveryClaims.EndSessionEndpoint == "" {
+		return "", fmt.Errorf("issuer %q does not support logout: no end_session_endpoint in OIDC discovery", h.issuer)
+	}
+	if err := validateURLUsesHTTPS(discoveryClaims.EndSessionEndpoint, "discovered end_session_endpoint from issuer"); err != nil {
+		return "", err
+	}
+
+	return discoveryClaims.EndSessionEndpoint, nil
+}
+
+func (h *handlerState) idTokenHint(token *oidctypes.Token) (string, error) {
+	if token == nil {
+		return "", errors.New("no token to end the session of")
+	}
+
+	if token.IDToken != nil && token.IDToken.Token != "" {
+		return token.IDToken.Token, nil
+	}
+
+	if token.RefreshToken == nil || token.RefreshToken.Token == "" {
+		return "", errors.New("token has neither an ID token nor a refresh token, so its session cannot be identified")
+	}
+
+	if err := validateURLUsesHTTPS(h.oauth2Config.Endpoint.TokenURL, "discovered token URL from issuer"); err != nil {
+		return "", err
+	}
+
+	// Perform a refresh to get a new ID token for the same session. The new ID token does not need to be validated,
+	// because the issuer will validate it when it is used as the id_token_hint.
+	h.logger.Info("Pinniped: Refreshing cached tokens to identify the session.")
+	refreshed, err := h.oauth2Config.TokenSource(h.ctx, &oauth2.Token{RefreshToken: token.RefreshToken.Token}).Token()
+	if err != nil {
+		return "", fmt.Errorf("could not refresh the session to get an ID token: %w", err)
+	}
+	idToken, _ := refreshed.Extra("id_token").(string)
+	if idToken == "" {
+		return "", errors.New("could not refresh the session to get an ID token: refresh response did not include an ID token")
+	}
+
+	return idToken, nil
+}
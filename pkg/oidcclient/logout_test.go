@@ -0,0 +1,145 @@
+// Copyright 2025 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package oidcclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.pinniped.dev/internal/testutil/tlsserver"
+	"go.pinniped.dev/pkg/oidcclient/oidctypes"
+)
+
+func TestLogout(t *testing.T) {
+	type endSessionRequest struct {
+		clientID    string
+		idTokenHint string
+	}
+
+	startServer := func(t *testing.T, endSessionPath string, refreshIDToken string) (*httptest.Server, []byte, *[]endSessionRequest) {
+		var endSessionRequests []endSessionRequest
+
+		mux := http.NewServeMux()
+		server, serverCA := tlsserver.TestServerIPv4(t, mux, nil)
+		mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+			discovery := map[string]any{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"jwks_uri":               server.URL + "/keys",
+			}
+			if endSessionPath != "" {
+				discovery["end_session_endpoint"] = server.URL + endSessionPath
+			}
+			w.Header().Set("content-type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(discovery))
+		})
+		mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "refresh_token", r.Form.Get("grant_type"))
+			require.Equal(t, "test-refresh-token", r.Form.Get("refresh_token"))
+			response := map[string]any{
+				"access_token":  "new-access-token",
+				"token_type":    "Bearer",
+				"refresh_token": "new-refresh-token",
+				"expires_in":    3600,
+			}
+			if refreshIDToken != "" {
+				response["id_token"] = refreshIDToken
+			}
+			w.Header().Set("content-type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(response))
+		})
+		mux.HandleFunc("/oauth2/logout", func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.NoError(t, r.ParseForm())
+			endSessionRequests = append(endSessionRequests, endSessionRequest{
+				clientID:    r.Form.Get("client_id"),
+				idTokenHint: r.Form.Get("id_token_hint"),
+			})
+			_, _ = w.Write([]byte("You have been logged out.\n"))
+		})
+		mux.HandleFunc("/broken-logout", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Bad Request: id_token_hint param is invalid", http.StatusBadRequest)
+		})
+
+		return server, serverCA, &endSessionRequests
+	}
+
+	tests := []struct {
+		name                   string
+		endSessionPath         string
+		refreshIDToken         string
+		token                  *oidctypes.Token
+		wantErr                string
+		wantEndSessionRequests []endSessionRequest
+	}{
+		{
+			name:           "token has an ID token",
+			endSessionPath: "/oauth2/logout",
+			token: &oidctypes.Token{
+				IDToken:      &oidctypes.IDToken{Token: "test-id-token"},
+				RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+			},
+			wantEndSessionRequests: []endSessionRequest{{clientID: "test-client-id", idTokenHint: "test-id-token"}},
+		},
+		{
+			name:           "token has only a refresh token",
+			endSessionPath: "/oauth2/logout",
+			refreshIDToken: "refreshed-id-token",
+			token: &oidctypes.Token{
+				RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+			},
+			wantEndSessionRequests: []endSessionRequest{{clientID: "test-client-id", idTokenHint: "refreshed-id-token"}},
+		},
+		{
+			name:           "refresh does not return an ID token",
+			endSessionPath: "/oauth2/logout",
+			token: &oidctypes.Token{
+				RefreshToken: &oidctypes.RefreshToken{Token: "test-refresh-token"},
+			},
+			wantErr: "could not refresh the session to get an ID token: refresh response did not include an ID token",
+		},
+		{
+			name:           "token has no ID token or refresh token",
+			endSessionPath: "/oauth2/logout",
+			token:          &oidctypes.Token{AccessToken: &oidctypes.AccessToken{Token: "test-access-token"}},
+			wantErr:        "token has neither an ID token nor a refresh token, so its session cannot be identified",
+		},
+		{
+			name:    "issuer does not advertise an end_session_endpoint",
+			token:   &oidctypes.Token{IDToken: &oidctypes.IDToken{Token: "test-id-token"}},
+			wantErr: `issuer "ISSUER" does not support logout: no end_session_endpoint in OIDC discovery`,
+		},
+		{
+			name:           "end_session_endpoint returns an error",
+			endSessionPath: "/broken-logout",
+			token:          &oidctypes.Token{IDToken: &oidctypes.IDToken{Token: "test-id-token"}},
+			wantErr:        "could not end session: unexpected HTTP response status 400: Bad Request: id_token_hint param is invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, serverCA, endSessionRequests := startServer(t, tt.endSessionPath, tt.refreshIDToken)
+
+			err := Logout(server.URL, "test-client-id", tt.token, WithClient(buildHTTPClientForPEM(serverCA)))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, strings.ReplaceAll(tt.wantErr, "ISSUER", server.URL))
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantEndSessionRequests, *endSessionRequests)
+		})
+	}
+
+	t.Run("issuer must use https", func(t *testing.T) {
+		err := Logout("http://example.com", "test-client-id", &oidctypes.Token{IDToken: &oidctypes.IDToken{Token: "test-id-token"}})
+		require.EqualError(t, err, `issuer must be an https URL, but had scheme "http" instead`)
+	})
+}
@@ -750,7 +750,7 @@ func TestE2EFullIntegration_Browser(t *testing.T) {
 		// The output should fail IDP discovery validation, because the OIDCIdentityProvider disallows password grants.
 		t.Log("kubectl command output (expecting a login failed error):\n", kubectlOutput)
 		require.Contains(t, kubectlOutput,
-			fmt.Sprintf(`could not complete Pinniped login: unable to find upstream identity provider with name "%[1]s" and type "oidc" and flow "cli_password". Found these providers: [{"name":"%[1]s","type":"oidc","flows":["browser_authcode"]}]`, oidcIdentityProvider.Name),
+			fmt.Sprintf(`could not complete Pinniped login: unable to find upstream identity provider with name "%[1]s" and type "oidc" and flow "cli_password". Found these providers: [{"name":"%[1]s","type":"oidc","flows":["browser_authcode","device_code"]}]`, oidcIdentityProvider.Name),
 		)
 	})
 
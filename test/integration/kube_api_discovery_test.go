@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package integration
@@ -173,6 +173,14 @@ func TestGetAPIResourceList(t *testing.T) { //nolint:gocyclo // each t.Run is pr
 						Kind:       "FederationDomain",
 						Verbs:      []string{"get", "patch", "update"},
 					},
+					{
+						Name:         "identitytransformlibraries",
+						SingularName: "identitytransformlibrary",
+						Namespaced:   true,
+						Kind:         "IdentityTransformLibrary",
+						Verbs:        []string{"delete", "deletecollection", "get", "list", "patch", "create", "update", "watch"},
+						Categories:   []string{"pinniped"},
+					},
 					{
 						Name:         "oidcclients",
 						SingularName: "oidcclient",
@@ -578,6 +586,11 @@ func TestCRDAdditionalPrinterColumns_Parallel(t *testing.T) {
 				{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
 			},
 		},
+		addSuffix("identitytransformlibraries.config.supervisor"): {
+			"v1alpha1": []apiextensionsv1.CustomResourceColumnDefinition{
+				{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+			},
+		},
 		addSuffix("ldapidentityproviders.idp.supervisor"): {
 			"v1alpha1": []apiextensionsv1.CustomResourceColumnDefinition{
 				{Name: "Host", Type: "string", JSONPath: ".spec.host"},
@@ -615,6 +628,7 @@ func TestCRDAdditionalPrinterColumns_Parallel(t *testing.T) {
 		"credentialissuers.config.concierge." + env.APIGroupSuffix,
 		"federationdomains.config.supervisor." + env.APIGroupSuffix,
 		"githubidentityproviders.idp.supervisor." + env.APIGroupSuffix,
+		"identitytransformlibraries.config.supervisor." + env.APIGroupSuffix,
 		"jwtauthenticators.authentication.concierge." + env.APIGroupSuffix,
 		"ldapidentityproviders.idp.supervisor." + env.APIGroupSuffix,
 		"oidcclients.config.supervisor." + env.APIGroupSuffix,
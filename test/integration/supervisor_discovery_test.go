@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package integration
@@ -604,18 +604,28 @@ func requireWellKnownEndpointIsWorking(t *testing.T, supervisorScheme, superviso
       "issuer": "%s",
       "authorization_endpoint": "%s/oauth2/authorize",
       "token_endpoint": "%s/oauth2/token",
-      "token_endpoint_auth_methods_supported": ["client_secret_basic"],
+      "token_endpoint_auth_methods_supported": ["client_secret_basic", "private_key_jwt"],
+      "token_endpoint_auth_signing_alg_values_supported": ["RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"],
       "jwks_uri": "%s/jwks.json",
       "scopes_supported": ["openid", "offline_access", "pinniped:request-audience", "username", "groups"],
       "response_types_supported": ["code"],
       "response_modes_supported": ["query", "form_post"],
       "code_challenge_methods_supported": ["S256"],
+      "device_authorization_endpoint": "%s/oauth2/device_authorization",
+      "end_session_endpoint": "%s/oauth2/logout",
+      "revocation_endpoint": "%s/oauth2/revoke",
+      "revocation_endpoint_auth_methods_supported": ["client_secret_basic", "private_key_jwt"],
+      "introspection_endpoint": "%s/oauth2/introspect",
+      "introspection_endpoint_auth_methods_supported": ["client_secret_basic"],
+      "userinfo_endpoint": "%s/oauth2/userinfo",
+      "pushed_authorization_request_endpoint": "%s/oauth2/par",
+      "require_pushed_authorization_requests": false,
       "claims_supported": ["username", "groups", "additionalClaims"],
       "discovery.supervisor.pinniped.dev/v1alpha1": {"pinniped_identity_providers_endpoint": "%s/v1alpha1/pinniped_identity_providers"},
       "subject_types_supported": ["public"],
       "id_token_signing_alg_values_supported": ["ES256"]
     }`)
-	expectedJSON := fmt.Sprintf(expectedResultTemplate, issuerName, issuerName, issuerName, issuerName, issuerName)
+	expectedJSON := fmt.Sprintf(expectedResultTemplate, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName, issuerName)
 
 	require.Equal(t, "application/json", response.Header.Get("content-type"))
 	require.JSONEq(t, expectedJSON, responseBody)
@@ -805,6 +815,7 @@ func withAllSuccessfulConditions() map[string]metav1.ConditionStatus {
 		"IdentityProvidersObjectRefKindValid":           metav1.ConditionTrue,
 		"IdentityProvidersObjectRefAPIGroupSuffixValid": metav1.ConditionTrue,
 		"IdentityProvidersDisplayNamesUnique":           metav1.ConditionTrue,
+		"TransformsLibrariesValid":                      metav1.ConditionTrue,
 		"TransformsExpressionsValid":                    metav1.ConditionTrue,
 		"TransformsExamplesPassed":                      metav1.ConditionTrue,
 	}
@@ -1011,5 +1011,9 @@ func allSuccessfulFederationDomainConditions(federationDomainSpec supervisorconf
 			Type: "TransformsExpressionsValid", Status: "True", Reason: "Success",
 			Message: "the expressions specified by .spec.identityProviders[].transforms.expressions[] are valid",
 		},
+		{
+			Type: "TransformsLibrariesValid", Status: "True", Reason: "Success",
+			Message: "the libraries specified by .spec.identityProviders[].transforms.libraries[] are valid",
+		},
 	}
 }
@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package integration
@@ -388,6 +388,12 @@ func TestGitHubIDPPhaseAndConditions_Parallel(t *testing.T) {
 					Reason:  "Success",
 					Message: fmt.Sprintf("clientID and clientSecret have been read from spec.client.SecretName (%q)", happySecretName),
 				},
+				{
+					Type:    "GitHubAppSecretValid",
+					Status:  metav1.ConditionTrue,
+					Reason:  "Success",
+					Message: "spec.app is not configured: using the user's access token to look up repository roles",
+				},
 				{
 					Type:    "GitHubConnectionValid",
 					Status:  metav1.ConditionTrue,
@@ -457,6 +463,12 @@ func TestGitHubIDPPhaseAndConditions_Parallel(t *testing.T) {
 						invalidSecretName,
 						supervisorNamespace),
 				},
+				{
+					Type:    "GitHubAppSecretValid",
+					Status:  metav1.ConditionTrue,
+					Reason:  "Success",
+					Message: "spec.app is not configured: using the user's access token to look up repository roles",
+				},
 				{
 					Type:    "GitHubConnectionValid",
 					Status:  metav1.ConditionTrue,
@@ -664,6 +676,12 @@ func TestGitHubIDPSecretInOtherNamespace_Parallel(t *testing.T) {
 				idp.Spec.Client.SecretName,
 				supervisorNamespace),
 		},
+		{
+			Type:    "GitHubAppSecretValid",
+			Status:  metav1.ConditionTrue,
+			Reason:  "Success",
+			Message: "spec.app is not configured: using the user's access token to look up repository roles",
+		},
 		{
 			Type:    "GitHubConnectionValid",
 			Status:  metav1.ConditionTrue,
@@ -1,4 +1,4 @@
-// Copyright 2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2024-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package integration
@@ -98,6 +98,12 @@ func TestLDAPIDPPhaseAndConditions_Parallel(t *testing.T) {
 						bindSecret.Name,
 						bindSecret.ResourceVersion),
 				},
+				{
+					Type:    "LDAPHostsHealthy",
+					Status:  "True",
+					Reason:  "Success",
+					Message: fmt.Sprintf(`all hosts are healthy: [%q]; connection pooling is disabled`, env.SupervisorUpstreamLDAP.Host),
+				},
 				{
 					Type:    "TLSConfigurationValid",
 					Status:  "True",
@@ -127,6 +133,12 @@ func TestLDAPIDPPhaseAndConditions_Parallel(t *testing.T) {
 					Reason:  "UnableToValidate",
 					Message: "unable to validate; see other conditions for details",
 				},
+				{
+					Type:    "LDAPHostsHealthy",
+					Status:  "Unknown",
+					Reason:  "UnableToValidate",
+					Message: "unable to validate; see other conditions for details",
+				},
 				{
 					Type:    "TLSConfigurationValid",
 					Status:  "False",
@@ -156,6 +168,12 @@ func TestLDAPIDPPhaseAndConditions_Parallel(t *testing.T) {
 					Reason:  "UnableToValidate",
 					Message: "unable to validate; see other conditions for details",
 				},
+				{
+					Type:    "LDAPHostsHealthy",
+					Status:  "Unknown",
+					Reason:  "UnableToValidate",
+					Message: "unable to validate; see other conditions for details",
+				},
 				{
 					Type:    "TLSConfigurationValid",
 					Status:  "True",
@@ -1,4 +1,4 @@
-// Copyright 2020-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2020-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package integration
@@ -2845,12 +2845,13 @@ func requireEventuallySuccessfulLDAPIdentityProviderConditions(
 	caBundleConfigured bool,
 ) {
 	t.Helper()
-	assertions.Len(ldapIDP.Status.Conditions, 3)
+	assertions.Len(ldapIDP.Status.Conditions, 4)
 
 	assertions.ElementsMatch([][]string{
 		{"BindSecretValid", "True", "Success"},
 		{"TLSConfigurationValid", "True", "Success"},
 		{"LDAPConnectionValid", "True", "Success"},
+		{"LDAPHostsHealthy", "True", "Success"},
 	}, conditionsSummaryFromActualConditions(t,
 		assertions, ldapIDP.Status.Conditions, caBundleConfigured, expectedLDAPConnectionValidMessage))
 }
@@ -3133,7 +3134,7 @@ func testSupervisorLogin(
 	}
 	require.NoError(t, err)
 
-	expectedIDTokenClaims := []string{"iss", "exp", "sub", "aud", "auth_time", "iat", "jti", "nonce", "rat", "azp", "at_hash"}
+	expectedIDTokenClaims := []string{"iss", "exp", "sub", "aud", "auth_time", "iat", "jti", "nonce", "rat", "azp", "sid", "at_hash"}
 	if slices.Contains(wantDownstreamScopes, "username") {
 		// If the test wants the username scope to have been granted, then also expect the claim in the ID token.
 		expectedIDTokenClaims = append(expectedIDTokenClaims, "username")
@@ -3210,7 +3211,7 @@ func testSupervisorLogin(
 	require.NoError(t, err)
 
 	// When refreshing, do not expect a "nonce" claim.
-	expectRefreshedIDTokenClaims := []string{"iss", "exp", "sub", "aud", "auth_time", "iat", "jti", "rat", "azp", "at_hash"}
+	expectRefreshedIDTokenClaims := []string{"iss", "exp", "sub", "aud", "auth_time", "iat", "jti", "rat", "azp", "sid", "at_hash"}
 
 	if slices.Contains(wantDownstreamScopes, "username") {
 		// If the test wants the username scope to have been granted, then also expect the claim in the refreshed ID token.
@@ -3240,6 +3241,8 @@ func testSupervisorLogin(
 	require.NotEqual(t, tokenResponse.AccessToken, refreshedTokenResponse.AccessToken)
 	require.NotEqual(t, tokenResponse.RefreshToken, refreshedTokenResponse.RefreshToken)
 	require.NotEqual(t, tokenResponse.Extra("id_token"), refreshedTokenResponse.Extra("id_token"))
+	// The session ID should not change during a refresh.
+	require.Equal(t, initialIDTokenClaims["sid"], refreshedIDTokenClaims["sid"])
 
 	// Perform token exchange on the refreshed token by calling the token endpoint again.
 	doTokenExchange(
@@ -3340,6 +3343,9 @@ func verifyTokenResponse(
 	// the authorization request.
 	require.Equal(t, downstreamOAuth2Config.ClientID, idTokenClaims["azp"])
 
+	// There should always be a "sid" claim, which identifies the downstream session.
+	require.NotEmpty(t, idTokenClaims["sid"])
+
 	// Check username claim of the ID token, if one is expected. Asserting on the lack of a username claim is
 	// handled above where the full list of claims are asserted.
 	if wantDownstreamIDTokenUsernameToMatch != "" {
@@ -1,4 +1,4 @@
-// Copyright 2022-2024 the Pinniped contributors. All Rights Reserved.
+// Copyright 2022-2025 the Pinniped contributors. All Rights Reserved.
 // SPDX-License-Identifier: Apache-2.0
 
 package integration
@@ -224,7 +224,7 @@ func TestOIDCClientStaticValidation_Parallel(t *testing.T) {
 					},
 				},
 			},
-			wantErr: `OIDCClient.config.supervisor.pinniped.dev "client.oauth.pinniped.dev-sky" is invalid: spec.allowedGrantTypes[2]: Unsupported value: "bird": supported values: "authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange"`,
+			wantErr: `OIDCClient.config.supervisor.pinniped.dev "client.oauth.pinniped.dev-sky" is invalid: spec.allowedGrantTypes[2]: Unsupported value: "bird": supported values: "authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange", "urn:ietf:params:oauth:grant-type:device_code", "client_credentials"`,
 		},
 		{
 			name: "bad scope",
@@ -245,7 +245,7 @@ func TestOIDCClientStaticValidation_Parallel(t *testing.T) {
 					},
 				},
 			},
-			wantErr: `OIDCClient.config.supervisor.pinniped.dev "client.oauth.pinniped.dev-blue" is invalid: spec.allowedScopes[0]: Unsupported value: "*": supported values: "openid", "offline_access", "username", "groups", "pinniped:request-audience"`,
+			wantErr: `OIDCClient.config.supervisor.pinniped.dev "client.oauth.pinniped.dev-blue" is invalid: spec.allowedScopes[0]: Unsupported value: "*": supported values: "openid", "offline_access", "username", "groups", "pinniped:request-audience", "pinniped:introspect"`,
 		},
 		{
 			name:    "empty unset all",
@@ -407,7 +407,7 @@ func TestOIDCClientStaticValidation_Parallel(t *testing.T) {
 				statusErr.ErrStatus.Message = errPrefix + strings.Join(out, ", ") + "]"
 				return want // leave the wanted error unchanged
 			},
-			wantErr: `OIDCClient.config.supervisor.pinniped.dev "zone" is invalid: [metadata.name: Invalid value: "zone": metadata.name in body should match '^client\.oauth\.pinniped\.dev-', spec.allowedGrantTypes[0]: Unsupported value: "the": supported values: "authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange", spec.allowedRedirectURIs[0]: Invalid value: "of": spec.allowedRedirectURIs[0] in body should match '^https://.+|^http://(127\.0\.0\.1|\[::1\])(:\d+)?/', spec.allowedScopes[0]: Unsupported value: "enders": supported values: "openid", "offline_access", "username", "groups", "pinniped:request-audience"]`,
+			wantErr: `OIDCClient.config.supervisor.pinniped.dev "zone" is invalid: [metadata.name: Invalid value: "zone": metadata.name in body should match '^client\.oauth\.pinniped\.dev-', spec.allowedGrantTypes[0]: Unsupported value: "the": supported values: "authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange", "urn:ietf:params:oauth:grant-type:device_code", "client_credentials", spec.allowedRedirectURIs[0]: Invalid value: "of": spec.allowedRedirectURIs[0] in body should match '^https://.+|^http://(127\.0\.0\.1|\[::1\])(:\d+)?/', spec.allowedScopes[0]: Unsupported value: "enders": supported values: "openid", "offline_access", "username", "groups", "pinniped:request-audience", "pinniped:introspect"]`,
 		},
 		{
 			name: "just the prefix is not valid",
@@ -602,6 +602,12 @@ func TestOIDCClientControllerValidations_Parallel(t *testing.T) {
 					Reason:  "MissingRequiredValue",
 					Message: `"openid" must always be included in "allowedScopes"; "offline_access" must be included in "allowedScopes" when "refresh_token" is included in "allowedGrantTypes"`,
 				},
+				{
+					Type:    "ClientAuthenticationValid",
+					Status:  "True",
+					Reason:  "Success",
+					Message: `"clientAuthentication" is valid`,
+				},
 				{
 					Type:    "ClientSecretExists",
 					Status:  "False",
@@ -637,6 +643,12 @@ func TestOIDCClientControllerValidations_Parallel(t *testing.T) {
 					Reason:  "Success",
 					Message: `"allowedScopes" is valid`,
 				},
+				{
+					Type:    "ClientAuthenticationValid",
+					Status:  "True",
+					Reason:  "Success",
+					Message: `"clientAuthentication" is valid`,
+				},
 				{
 					Type:    "ClientSecretExists",
 					Status:  "False",
@@ -672,6 +684,12 @@ func TestOIDCClientControllerValidations_Parallel(t *testing.T) {
 					Reason:  "Success",
 					Message: `"allowedScopes" is valid`,
 				},
+				{
+					Type:    "ClientAuthenticationValid",
+					Status:  "True",
+					Reason:  "Success",
+					Message: `"clientAuthentication" is valid`,
+				},
 				{
 					Type:    "ClientSecretExists",
 					Status:  "True",